@@ -17,6 +17,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -720,7 +721,8 @@ func (a *AddrManager) reset() {
 }
 
 // HostToNetAddress returns a netaddress given a host address.  If the address
-// is a Tor .onion address this will be taken care of.  Else if the host is
+// is a Tor .onion or I2P .b32.i2p address this will be taken care of, and IPv6
+// addresses in the FC00::/8 range are CJDNS addresses.  Else if the host is
 // not an IP address it will be resolved (via Tor if required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16,
 	services wire.ServiceFlag) (*wire.NetAddressV2, error) {
@@ -759,6 +761,16 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16,
 		na = wire.NetAddressV2FromBytes(
 			time.Now(), services, data[:wire.TorV3Size], port,
 		)
+	} else if len(host) == wire.I2PEncodedSize && host[wire.I2PEncodedSize-8:] == ".b32.i2p" {
+		// I2P addresses are 52 base32 characters without padding with
+		// the 8 byte b32.i2p suffix.
+		data, err := base32.StdEncoding.WithPadding(base32.NoPadding).
+			DecodeString(strings.ToUpper(host[:wire.I2PEncodedSize-8]))
+		if err != nil {
+			return nil, err
+		}
+
+		na = wire.NetAddressV2FromI2P(time.Now(), services, data, port)
 	} else if ip = net.ParseIP(host); ip == nil {
 		ips, err := a.lookupFunc(host)
 		if err != nil {
@@ -770,6 +782,9 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16,
 		ip = ips[0]
 
 		na = wire.NetAddressV2FromBytes(time.Now(), services, ip, port)
+	} else if cjdnsNet.Contains(ip) {
+		// The FC00::/8 range is only reachable over CJDNS.
+		na = wire.NetAddressV2FromCJDNS(time.Now(), services, ip, port)
 	} else {
 		// This is an non-nil IP address that was parsed in the else if
 		// above.
@@ -780,7 +795,8 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16,
 }
 
 // NetAddressKey returns a string key in the form of ip:port for IPv4 addresses
-// or [ip]:port for IPv6 addresses. It also handles onion v2 and v3 addresses.
+// or [ip]:port for IPv6 and CJDNS addresses. It also handles onion v2 and v3
+// and I2P addresses.
 func NetAddressKey(na *wire.NetAddressV2) string {
 	port := strconv.FormatUint(uint64(na.Port), 10)
 
@@ -1046,6 +1062,38 @@ func (a *AddrManager) AddLocalAddress(na *wire.NetAddressV2, priority AddressPri
 	return nil
 }
 
+// RemoveLocalAddress removes the passed address from the local addresses
+// advertised to peers.  It is used when an address, such as one mapped on a
+// NAT, is no longer reachable.
+func (a *AddrManager) RemoveLocalAddress(na *wire.NetAddressV2) {
+	a.lamtx.Lock()
+	delete(a.localAddresses, NetAddressKey(na))
+	a.lamtx.Unlock()
+}
+
+// LocalAddress is a local address advertised to peers along with the score
+// derived from the priority of the method it was discovered with.
+type LocalAddress struct {
+	NA    *wire.NetAddressV2
+	Score AddressPriority
+}
+
+// LocalAddresses returns the local addresses advertised to peers sorted by
+// their key.
+func (a *AddrManager) LocalAddresses() []LocalAddress {
+	a.lamtx.Lock()
+	addrs := make([]LocalAddress, 0, len(a.localAddresses))
+	for _, la := range a.localAddresses {
+		addrs = append(addrs, LocalAddress{NA: la.na, Score: la.score})
+	}
+	a.lamtx.Unlock()
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return NetAddressKey(addrs[i].NA) < NetAddressKey(addrs[j].NA)
+	})
+	return addrs
+}
+
 // getReachabilityFrom returns the relative reachability of the provided local
 // address to the provided remote address.
 func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddressV2) int {
@@ -1063,6 +1111,19 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddressV2) int {
 		return Unreachable
 	}
 
+	// I2P and CJDNS addresses are only reachable from the same network.
+	if remoteAddr.IsI2P() || remoteAddr.IsCJDNS() {
+		if localAddr.IsI2P() == remoteAddr.IsI2P() &&
+			localAddr.IsCJDNS() == remoteAddr.IsCJDNS() {
+
+			return Private
+		}
+		return Default
+	}
+	if localAddr.IsI2P() || localAddr.IsCJDNS() {
+		return Default
+	}
+
 	if remoteAddr.IsTorV3() {
 		if localAddr.IsTorV3() {
 			return Private
@@ -1183,7 +1244,9 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddressV2) *wire.N
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if remoteAddr.IsTorV3() {
+		if remoteAddr.IsTorV3() || remoteAddr.IsI2P() ||
+			remoteAddr.IsCJDNS() {
+
 			ip = net.IPv4zero
 		} else {
 			remoteLna := remoteAddr.ToLegacy()
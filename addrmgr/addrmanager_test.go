@@ -222,6 +222,47 @@ func TestAddLocalAddress(t *testing.T) {
 	}
 }
 
+// TestRemoveLocalAddress ensures local addresses are listed and removed as
+// intended.
+func TestRemoveLocalAddress(t *testing.T) {
+	amgr := addrmgr.New("testremovelocaladdress", nil)
+	upnp := wire.NetAddressV2FromBytes(
+		time.Now(), 0, net.ParseIP("204.124.1.1"), 8333,
+	)
+	bound := wire.NetAddressV2FromBytes(
+		time.Now(), 0, net.ParseIP("2620:100::1"), 8333,
+	)
+	if err := amgr.AddLocalAddress(upnp, addrmgr.UpnpPrio); err != nil {
+		t.Fatalf("AddLocalAddress: unexpected error: %v", err)
+	}
+	if err := amgr.AddLocalAddress(bound, addrmgr.BoundPrio); err != nil {
+		t.Fatalf("AddLocalAddress: unexpected error: %v", err)
+	}
+
+	addrs := amgr.LocalAddresses()
+	if len(addrs) != 2 || addrs[0].NA != upnp ||
+		addrs[0].Score != addrmgr.UpnpPrio || addrs[1].NA != bound ||
+		addrs[1].Score != addrmgr.BoundPrio {
+
+		t.Fatalf("LocalAddresses: unexpected addresses %v", addrs)
+	}
+
+	amgr.RemoveLocalAddress(upnp)
+	addrs = amgr.LocalAddresses()
+	if len(addrs) != 1 || addrs[0].NA != bound {
+		t.Fatalf("LocalAddresses: unexpected addresses %v", addrs)
+	}
+
+	remote := wire.NetAddressV2FromBytes(
+		time.Now(), 0, net.ParseIP("204.124.8.100"), 8333,
+	)
+	if best := amgr.GetBestLocalAddress(remote); best.Addr.String() ==
+		upnp.Addr.String() {
+
+		t.Fatal("GetBestLocalAddress: removed address returned")
+	}
+}
+
 func TestAttempt(t *testing.T) {
 	n := addrmgr.New("testattempt", lookupFunc)
 
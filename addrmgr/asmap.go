@@ -0,0 +1,123 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ASMap maps IP addresses to the number of the autonomous system (AS) that
+// announces them.  Grouping addresses by AS rather than by IP range makes it
+// harder for a single network operator to control all of the outbound
+// connections, since large operators announce many unrelated ranges.
+type ASMap struct {
+	// prefixes maps the prefix length and the masked address of each
+	// prefix to its AS number, where IPv4 prefixes are stored as
+	// IPv4-mapped IPv6 prefixes.  lengths holds the prefix lengths in
+	// descending order so the longest matching prefix is found first.
+	prefixes map[int]map[[net.IPv6len]byte]uint32
+	lengths  []int
+}
+
+// LoadASMap reads an AS map from the passed reader.  Each line holds an IPv4 or
+// IPv6 prefix in CIDR notation followed by the AS number, optionally prefixed
+// with "AS", such as "1.0.0.0/24 AS13335".  Empty lines and lines starting with
+// '#' are ignored.
+func LoadASMap(r io.Reader) (*ASMap, error) {
+	m := &ASMap{prefixes: make(map[int]map[[net.IPv6len]byte]uint32)}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a prefix and an "+
+				"AS number", lineNum)
+		}
+		_, ipNet, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		asn, err := strconv.ParseUint(strings.TrimPrefix(
+			strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil || asn == 0 {
+			return nil, fmt.Errorf("line %d: invalid AS number %q",
+				lineNum, fields[1])
+		}
+
+		ones, bits := ipNet.Mask.Size()
+		if bits == 8*net.IPv4len {
+			ones += 8 * (net.IPv6len - net.IPv4len)
+		}
+		prefixes, ok := m.prefixes[ones]
+		if !ok {
+			prefixes = make(map[[net.IPv6len]byte]uint32)
+			m.prefixes[ones] = prefixes
+			m.lengths = append(m.lengths, ones)
+		}
+		prefixes[maskedIP(ipNet.IP, ones)] = uint32(asn)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(m.lengths)))
+	return m, nil
+}
+
+// maskedIP returns the 16 byte form of the passed IP address with all but the
+// passed number of leading bits cleared.
+func maskedIP(ip net.IP, ones int) [net.IPv6len]byte {
+	var masked [net.IPv6len]byte
+	copy(masked[:], ip.To16().Mask(net.CIDRMask(ones, 8*net.IPv6len)))
+	return masked
+}
+
+// Lookup returns the number of the AS announcing the passed IP address, or 0
+// when the address is not part of any prefix of the map.
+func (m *ASMap) Lookup(ip net.IP) uint32 {
+	if ip.To16() == nil {
+		return 0
+	}
+	for _, ones := range m.lengths {
+		if asn, ok := m.prefixes[ones][maskedIP(ip, ones)]; ok {
+			return asn
+		}
+	}
+	return 0
+}
+
+// GroupKey returns a string representing the network group an address is part
+// of like the package level GroupKey, except that routable IPv4 and IPv6
+// addresses are grouped by their AS in the form "as:number" when the map
+// contains them.  Addresses of overlay networks and IPv6 addresses that embed
+// an IPv4 address are grouped as by GroupKey.
+func (m *ASMap) GroupKey(na *wire.NetAddressV2) string {
+	if na.IsTorV3() || na.IsI2P() || na.IsCJDNS() || !IsRoutable(na) {
+		return GroupKey(na)
+	}
+
+	lna := na.ToLegacy()
+	if IsOnionCatTor(lna) || IsRFC6145(lna) || IsRFC6052(lna) ||
+		IsRFC3964(lna) || IsRFC4380(lna) {
+
+		return GroupKey(na)
+	}
+	if asn := m.Lookup(lna.IP); asn != 0 {
+		return fmt.Sprintf("as:%d", asn)
+	}
+	return GroupKey(na)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/addrmgr"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestASMap ensures AS maps are loaded as intended and group addresses by the
+// AS of their longest matching prefix.
+func TestASMap(t *testing.T) {
+	const asmapText = `
+# Comments and empty lines are ignored.
+12.0.0.0/8 AS100
+12.1.0.0/16 AS200
+173.1.2.0/24 300
+2602:100::/24 AS400
+`
+	asmap, err := addrmgr.LoadASMap(strings.NewReader(asmapText))
+	if err != nil {
+		t.Fatalf("LoadASMap: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		asn      uint32
+		groupKey string
+	}{
+		{name: "ipv4 shorter prefix", ip: "12.2.3.4", asn: 100, groupKey: "as:100"},
+		{name: "ipv4 longer prefix", ip: "12.1.2.3", asn: 200, groupKey: "as:200"},
+		{name: "ipv4 without as number", ip: "173.1.3.4", asn: 0, groupKey: "173.1.0.0"},
+		{name: "ipv4 as number", ip: "173.1.2.4", asn: 300, groupKey: "as:300"},
+		{name: "ipv6", ip: "2602:100::1", asn: 400, groupKey: "as:400"},
+		{name: "ipv6 without as number", ip: "2602:200::1", asn: 0, groupKey: "2602:200::"},
+		{name: "ipv6 rfc6052 embedding ipv4", ip: "64:ff9b::0c01:0203", asn: 0, groupKey: "12.1.0.0"},
+		{name: "unroutable", ip: "10.1.2.3", asn: 0, groupKey: "unroutable"},
+	}
+
+	for _, test := range tests {
+		ip := net.ParseIP(test.ip)
+		if asn := asmap.Lookup(ip); asn != test.asn {
+			t.Errorf("%s: unexpected AS number - got %d, want %d",
+				test.name, asn, test.asn)
+		}
+
+		na := wire.NetAddressV2FromBytes(
+			time.Now(), wire.SFNodeNetwork, ip, 8333,
+		)
+		if key := asmap.GroupKey(na); key != test.groupKey {
+			t.Errorf("%s: unexpected group key - got %s, want %s",
+				test.name, key, test.groupKey)
+		}
+	}
+
+	// Invalid lines are rejected.
+	invalid := []string{
+		"12.0.0.0/8",
+		"12.0.0.0 AS100",
+		"12.0.0.0/8 ASX",
+		"12.0.0.0/8 AS0",
+	}
+	for _, text := range invalid {
+		if _, err := addrmgr.LoadASMap(strings.NewReader(text)); err == nil {
+			t.Errorf("LoadASMap(%q): expected error", text)
+		}
+	}
+}
@@ -84,6 +84,10 @@ var (
 	// { magic 6 bytes, 10 bytes base32 decode of key hash }
 	onionCatNet = ipNet("fd87:d87e:eb43::", 48, 128)
 
+	// cjdnsNet defines the IPv6 address block used by CJDNS (FC00::/8),
+	// which is part of the RFC4193 unique local IPv6 range.
+	cjdnsNet = ipNet("FC00::", 8, 128)
+
 	// zero4Net defines the IPv4 address block for address staring with 0
 	// (0.0.0.0/8).
 	zero4Net = ipNet("0.0.0.0", 8, 32)
@@ -223,13 +227,12 @@ func IsValid(na *wire.NetAddress) bool {
 // the public internet.  This is true as long as the address is valid and is not
 // in any reserved ranges.
 func IsRoutable(na *wire.NetAddressV2) bool {
-	if na.IsTorV3() {
-		// na is a torv3 address, return true.
+	if na.IsTorV3() || na.IsI2P() || na.IsCJDNS() {
+		// na is a torv3, i2p or cjdns address, return true.
 		return true
 	}
 
-	// Else na can be represented as a legacy NetAddress since i2p and
-	// cjdns are unsupported.
+	// Else na can be represented as a legacy NetAddress.
 	lna := na.ToLegacy()
 	return IsValid(lna) && !(IsRFC1918(lna) || IsRFC2544(lna) ||
 		IsRFC3927(lna) || IsRFC4862(lna) || IsRFC3849(lna) ||
@@ -241,14 +244,23 @@ func IsRoutable(na *wire.NetAddressV2) bool {
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for Tor address, and the string "unroutable" for an unroutable
-// address.
+// onion address for Tor address, the string "i2p:key" where key is the /4 of
+// the destination hash for I2P address, the string "cjdns:key" where key is the
+// /4 after the FC00::/8 prefix for CJDNS address, and the string "unroutable"
+// for an unroutable address.
 func GroupKey(na *wire.NetAddressV2) string {
 	if na.IsTorV3() {
 		// na is a torv3 address. Use the same network group keying as
 		// for torv2.
 		return fmt.Sprintf("tor:%d", na.TorV3Key()&((1<<4)-1))
 	}
+	if na.IsI2P() {
+		return fmt.Sprintf("i2p:%d", na.I2PKey()&((1<<4)-1))
+	}
+	if na.IsCJDNS() {
+		ip := net.ParseIP(na.Addr.String())
+		return fmt.Sprintf("cjdns:%d", ip[1]>>4)
+	}
 
 	lna := na.ToLegacy()
 
@@ -296,3 +308,25 @@ func GroupKey(na *wire.NetAddressV2) string {
 
 	return lna.IP.Mask(net.CIDRMask(bits, 128)).String()
 }
+
+// NetworkName returns the name of the network of the passed address, which is
+// "ipv4", "ipv6", "onion", "i2p" or "cjdns".
+func NetworkName(na *wire.NetAddressV2) string {
+	switch {
+	case na.IsTorV3():
+		return "onion"
+	case na.IsI2P():
+		return "i2p"
+	case na.IsCJDNS():
+		return "cjdns"
+	}
+
+	lna := na.ToLegacy()
+	switch {
+	case IsOnionCatTor(lna):
+		return "onion"
+	case IsIPv4(lna):
+		return "ipv4"
+	}
+	return "ipv6"
+}
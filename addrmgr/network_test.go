@@ -206,3 +206,67 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestAddrV2Networks ensures the I2P and CJDNS addresses, which can only be
+// relayed in addrv2 messages, are parsed, routable, grouped and named as
+// intended.
+func TestAddrV2Networks(t *testing.T) {
+	amgr := addrmgr.New("testaddrv2networks", nil)
+
+	tests := []struct {
+		name     string
+		host     string
+		network  string
+		groupKey string
+	}{{
+		name:     "i2p",
+		host:     "ucq2fi5euwtkpkfjvkv2zlnov6yldmvtws23nn5yxg5lxpf5x27q.b32.i2p",
+		network:  "i2p",
+		groupKey: "i2p:0",
+	}, {
+		name:     "cjdns",
+		host:     "fc32:17ea:583a:41b5:557b:8d6f:8c03:1187",
+		network:  "cjdns",
+		groupKey: "cjdns:3",
+	}, {
+		name:     "ipv6 outside of cjdns range",
+		host:     "fd00::1",
+		network:  "ipv6",
+		groupKey: "unroutable",
+	}, {
+		name:     "onion v3",
+		host:     "xa4r2iadxm55fbnqgwwi5mymqdcofiu3w6rpbtqn7b2dyn7mgwj64jyd.onion",
+		network:  "onion",
+		groupKey: "tor:8",
+	}, {
+		name:     "ipv4",
+		host:     "12.1.2.3",
+		network:  "ipv4",
+		groupKey: "12.1.0.0",
+	}}
+
+	for _, test := range tests {
+		na, err := amgr.HostToNetAddress(test.host, 8333, wire.SFNodeNetwork)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if na.Addr.String() != test.host {
+			t.Errorf("%s: unexpected address - got %s, want %s",
+				test.name, na.Addr.String(), test.host)
+		}
+		if network := addrmgr.NetworkName(na); network != test.network {
+			t.Errorf("%s: unexpected network - got %s, want %s",
+				test.name, network, test.network)
+		}
+		if key := addrmgr.GroupKey(na); key != test.groupKey {
+			t.Errorf("%s: unexpected group key - got %s, want %s",
+				test.name, key, test.groupKey)
+		}
+		routable := test.groupKey != "unroutable"
+		if addrmgr.IsRoutable(na) != routable {
+			t.Errorf("%s: unexpected routable - got %v, want %v",
+				test.name, !routable, routable)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+const (
+	// anchorsFilename is the name of the file in the data directory the
+	// addresses of the block relay only peers are saved to on shutdown.
+	anchorsFilename = "anchors.json"
+
+	// maxAnchors is the maximum number of anchors saved on shutdown.
+	maxAnchors = defaultTargetBlockRelayOnly
+)
+
+// loadAnchors returns the anchor addresses saved to the passed file on the last
+// shutdown and removes the file, so the anchors aren't used again after a crash
+// in case they caused it.  It returns no addresses when there is no such file.
+func loadAnchors(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	var anchors []string
+	if err := json.Unmarshal(data, &anchors); err != nil {
+		return nil, err
+	}
+	if len(anchors) > maxAnchors {
+		anchors = anchors[:maxAnchors]
+	}
+	return anchors, nil
+}
+
+// saveAnchors saves the passed anchor addresses to the passed file so the node
+// connects to them again after a restart.
+func saveAnchors(path string, anchors []string) error {
+	data, err := json.Marshal(anchors)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
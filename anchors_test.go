@@ -0,0 +1,44 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestAnchors ensures the saved anchors are loaded once and limited to
+// maxAnchors.
+func TestAnchors(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), anchorsFilename)
+
+	// There are no anchors without a file.
+	anchors, err := loadAnchors(path)
+	if err != nil || anchors != nil {
+		t.Fatalf("loadAnchors: got %v, %v, want no anchors", anchors, err)
+	}
+
+	saved := []string{"1.2.3.4:8333", "[2602:100::1]:8333", "5.6.7.8:8333"}
+	if err := saveAnchors(path, saved); err != nil {
+		t.Fatalf("saveAnchors: unexpected error: %v", err)
+	}
+	anchors, err = loadAnchors(path)
+	if err != nil {
+		t.Fatalf("loadAnchors: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(anchors, saved[:maxAnchors]) {
+		t.Fatalf("loadAnchors: got %v, want %v", anchors,
+			saved[:maxAnchors])
+	}
+
+	// The file is removed once the anchors are loaded.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("anchors file still exists: %v", err)
+	}
+}
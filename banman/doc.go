@@ -0,0 +1,24 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package banman keeps the bans and discouragements of peers, which persist
+across restarts.
+
+A ban applies to a subnet, which may be a single address, and lasts until it
+expires or is removed.  Connections with peers in a banned subnet are refused.
+Bans are usually set by the operator of the node, for example with the setban
+RPC.
+
+Peers which misbehave are discouraged instead of banned.  A discouragement
+applies to a single address and also expires.  Discouraged peers are not
+connected to automatically and their inbound connections are only accepted when
+there is room to spare, so misbehaving peers sharing an address with honest
+peers, such as those behind a NAT, can't get the honest peers banned.
+
+The bans and discouragements are kept in a bucket of the database of the node.
+Each change is written to the database right away since they are rare, and the
+expired entries are removed when they are loaded and when the bans are listed.
+*/
+package banman
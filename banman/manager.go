@@ -0,0 +1,407 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package banman
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// maxDiscouraged is the maximum number of discouraged addresses.  The
+	// discouragement which expires first is evicted to make room for new
+	// ones so peers can't exhaust memory or disk space by misbehaving from
+	// many addresses.
+	maxDiscouraged = 10000
+
+	// entryVersion is the version of the serialized entries.
+	entryVersion = 1
+
+	// serializedEntryLen is the length of a serialized entry.  It is the
+	// version, the time the entry was created and the time it expires.
+	serializedEntryLen = 1 + 8 + 8
+)
+
+// kind distinguishes bans from discouragements in the database, where the keys
+// of the entries are the kind followed by the subnet.
+type kind byte
+
+const (
+	kindBan kind = iota
+	kindDiscouragement
+)
+
+var (
+	// bucketName is the name of the bucket of the database metadata which
+	// houses the bans and discouragements keyed by their kind and subnet.
+	bucketName = []byte("banlist")
+
+	// byteOrder is the preferred byte order used for serializing the
+	// entries.
+	byteOrder = binary.LittleEndian
+
+	// ErrNotBanned is returned when removing the ban of a subnet which is
+	// not banned.
+	ErrNotBanned = errors.New("subnet is not banned")
+)
+
+// Entry describes the ban or discouragement of a subnet.
+type Entry struct {
+	// Subnet is the banned subnet.  Discouragements always apply to a
+	// single address.
+	Subnet *net.IPNet
+
+	// Created is the time the entry was created.
+	Created time.Time
+
+	// Until is the time the entry expires.
+	Until time.Time
+}
+
+// entryKey returns the database key of the entry of the passed kind for the
+// passed subnet.
+func entryKey(k kind, subnet string) []byte {
+	return append([]byte{byte(k)}, subnet...)
+}
+
+// serializeEntry returns the serialized entry.
+func serializeEntry(entry *Entry) []byte {
+	serialized := make([]byte, serializedEntryLen)
+	serialized[0] = entryVersion
+	byteOrder.PutUint64(serialized[1:], uint64(entry.Created.Unix()))
+	byteOrder.PutUint64(serialized[9:], uint64(entry.Until.Unix()))
+	return serialized
+}
+
+// deserializeEntry returns the kind and the entry with the passed database key
+// serialized by serializeEntry.
+func deserializeEntry(key, serialized []byte) (kind, *Entry, error) {
+	if len(key) == 0 {
+		return 0, nil, errors.New("empty key")
+	}
+	k := kind(key[0])
+	if k != kindBan && k != kindDiscouragement {
+		return 0, nil, fmt.Errorf("unknown kind %d", k)
+	}
+	_, subnet, err := net.ParseCIDR(string(key[1:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(serialized) != serializedEntryLen {
+		return 0, nil, fmt.Errorf("unexpected length %d of serialized "+
+			"entry", len(serialized))
+	}
+	if serialized[0] != entryVersion {
+		return 0, nil, fmt.Errorf("unknown version %d of serialized "+
+			"entry", serialized[0])
+	}
+
+	entry := &Entry{
+		Subnet:  subnet,
+		Created: time.Unix(int64(byteOrder.Uint64(serialized[1:])), 0),
+		Until:   time.Unix(int64(byteOrder.Uint64(serialized[9:])), 0),
+	}
+	return k, entry, nil
+}
+
+// ParseSubnet parses a subnet in CIDR notation, such as 192.168.0.0/16, or a
+// single IP address, which is treated as a subnet of only that address.
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		return hostSubnet(ip), nil
+	}
+
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	return subnet, nil
+}
+
+// hostSubnet returns the subnet of only the passed IP address.
+func hostSubnet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+}
+
+// Manager keeps the bans and discouragements and persists them in the
+// database.
+//
+// All methods are safe for concurrent access.
+type Manager struct {
+	db database.DB
+
+	mtx         sync.Mutex
+	banned      map[string]*Entry
+	discouraged map[string]*Entry
+}
+
+// New returns a manager which persists the bans and discouragements in the
+// passed database after loading those that were persisted previously and have
+// not expired.
+func New(db database.DB) (*Manager, error) {
+	m := &Manager{
+		db:          db,
+		banned:      make(map[string]*Entry),
+		discouraged: make(map[string]*Entry),
+	}
+
+	now := time.Now()
+	err := db.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+
+		var removed [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			kind, entry, err := deserializeEntry(k, v)
+			if err != nil {
+				log.Warnf("Discarding ban %q: %v", k, err)
+				removed = append(removed, append([]byte(nil), k...))
+				return nil
+			}
+			if !now.Before(entry.Until) {
+				removed = append(removed, append([]byte(nil), k...))
+				return nil
+			}
+			key := entry.Subnet.String()
+			if kind == kindBan {
+				m.banned[key] = entry
+			} else {
+				m.discouraged[key] = entry
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range removed {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Loaded %d banned subnets and %d discouraged addresses",
+		len(m.banned), len(m.discouraged))
+	return m, nil
+}
+
+// put writes the passed entry of the passed kind to the database.
+func (m *Manager) put(k kind, entry *Entry) error {
+	return m.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		return bucket.Put(entryKey(k, entry.Subnet.String()),
+			serializeEntry(entry))
+	})
+}
+
+// remove removes the entries of the passed kind for the passed subnets from
+// the database.
+func (m *Manager) remove(k kind, subnets []string) error {
+	if len(subnets) == 0 {
+		return nil
+	}
+	return m.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		for _, subnet := range subnets {
+			if err := bucket.Delete(entryKey(k, subnet)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Ban bans the passed subnet until the passed time.  An existing ban of the
+// subnet is replaced.
+func (m *Manager) Ban(subnet *net.IPNet, until time.Time) error {
+	entry := &Entry{
+		Subnet:  subnet,
+		Created: time.Now(),
+		Until:   until,
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.put(kindBan, entry); err != nil {
+		return err
+	}
+	m.banned[subnet.String()] = entry
+	log.Infof("Banned %s until %v", subnet, until.Format(time.RFC3339))
+	return nil
+}
+
+// Unban removes the ban of the passed subnet.  ErrNotBanned is returned when
+// the subnet is not banned.
+func (m *Manager) Unban(subnet *net.IPNet) error {
+	key := subnet.String()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.banned[key]; !ok {
+		return ErrNotBanned
+	}
+	if err := m.remove(kindBan, []string{key}); err != nil {
+		return err
+	}
+	delete(m.banned, key)
+	log.Infof("Unbanned %s", subnet)
+	return nil
+}
+
+// IsBanned returns whether the passed IP address is part of a banned subnet.
+func (m *Manager) IsBanned(ip net.IP) bool {
+	now := time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, entry := range m.banned {
+		if entry.Subnet.Contains(ip) && now.Before(entry.Until) {
+			return true
+		}
+	}
+	return false
+}
+
+// Discourage discourages the passed IP address until the passed time.  The
+// discouragement is only extended when the address is already discouraged.
+func (m *Manager) Discourage(ip net.IP, until time.Time) error {
+	subnet := hostSubnet(ip)
+	key := subnet.String()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if existing, ok := m.discouraged[key]; ok && !until.After(existing.Until) {
+		return nil
+	}
+
+	// Evict the discouragement which expires first when the maximum number
+	// of addresses is discouraged.
+	var evicted []string
+	if _, ok := m.discouraged[key]; !ok && len(m.discouraged) >= maxDiscouraged {
+		var first string
+		for k, entry := range m.discouraged {
+			if first == "" || entry.Until.Before(m.discouraged[first].Until) {
+				first = k
+			}
+		}
+		evicted = append(evicted, first)
+	}
+	if err := m.remove(kindDiscouragement, evicted); err != nil {
+		return err
+	}
+	for _, k := range evicted {
+		delete(m.discouraged, k)
+	}
+
+	entry := &Entry{
+		Subnet:  subnet,
+		Created: time.Now(),
+		Until:   until,
+	}
+	if err := m.put(kindDiscouragement, entry); err != nil {
+		return err
+	}
+	m.discouraged[key] = entry
+	return nil
+}
+
+// IsDiscouraged returns whether the passed IP address is discouraged.
+func (m *Manager) IsDiscouraged(ip net.IP) bool {
+	key := hostSubnet(ip).String()
+	now := time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	entry, ok := m.discouraged[key]
+	return ok && now.Before(entry.Until)
+}
+
+// BanList returns the bans which have not expired sorted by the time they were
+// created.  The expired bans and discouragements are removed.
+func (m *Manager) BanList() ([]Entry, error) {
+	now := time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for k, entries := range map[kind]map[string]*Entry{
+		kindBan:            m.banned,
+		kindDiscouragement: m.discouraged,
+	} {
+		var expired []string
+		for key, entry := range entries {
+			if !now.Before(entry.Until) {
+				expired = append(expired, key)
+			}
+		}
+		if err := m.remove(k, expired); err != nil {
+			return nil, err
+		}
+		for _, key := range expired {
+			delete(entries, key)
+		}
+	}
+
+	list := make([]Entry, 0, len(m.banned))
+	for _, entry := range m.banned {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Created.Equal(list[j].Created) {
+			return list[i].Subnet.String() < list[j].Subnet.String()
+		}
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+// Clear removes all bans and discouragements.
+func (m *Manager) Clear() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for k, entries := range map[kind]map[string]*Entry{
+		kindBan:            m.banned,
+		kindDiscouragement: m.discouraged,
+	} {
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		if err := m.remove(k, keys); err != nil {
+			return err
+		}
+	}
+	m.banned = make(map[string]*Entry)
+	m.discouraged = make(map[string]*Entry)
+	log.Infof("Cleared all bans and discouragements")
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package banman
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestParseSubnet ensures subnets and single addresses are parsed as intended.
+func TestParseSubnet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"10.0.0.1", "10.0.0.1/32"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"::ffff:10.0.0.1", "10.0.0.1/32"},
+	}
+	for _, test := range tests {
+		subnet, err := ParseSubnet(test.in)
+		if err != nil {
+			t.Errorf("ParseSubnet(%q): unexpected error: %v", test.in, err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("ParseSubnet(%q): got %s, want %s", test.in,
+				subnet, test.want)
+		}
+	}
+
+	for _, in := range []string{"", "10.0.0", "10.0.0.0/33", "host"} {
+		if _, err := ParseSubnet(in); err == nil {
+			t.Errorf("ParseSubnet(%q): expected error", in)
+		}
+	}
+}
+
+// TestManager ensures bans and discouragements apply to the intended
+// addresses, expire and persist across restarts.
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Create("ffldb", t.TempDir(), wire.SimNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	subnet, _ := ParseSubnet("10.0.0.0/16")
+	host, _ := ParseSubnet("2001:db8::1")
+	expired, _ := ParseSubnet("192.168.0.1")
+	for _, ban := range []struct {
+		subnet *net.IPNet
+		until  time.Time
+	}{
+		{subnet, now.Add(time.Hour)},
+		{host, now.Add(2 * time.Hour)},
+		{expired, now.Add(-time.Second)},
+	} {
+		if err := m.Ban(ban.subnet, ban.until); err != nil {
+			t.Fatalf("Ban(%s): unexpected error: %v", ban.subnet, err)
+		}
+	}
+	ip := net.ParseIP("172.16.0.1")
+	if err := m.Discourage(ip, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Discourage: unexpected error: %v", err)
+	}
+
+	checkState := func(m *Manager) {
+		t.Helper()
+
+		for addr, want := range map[string]bool{
+			"10.0.255.1":    true,
+			"10.1.0.1":      false,
+			"2001:db8::1":   true,
+			"2001:db8::2":   false,
+			"192.168.0.1":   false,
+			"::ffff:10.0.0": false,
+		} {
+			if got := m.IsBanned(net.ParseIP(addr)); got != want {
+				t.Fatalf("IsBanned(%s): got %v, want %v", addr,
+					got, want)
+			}
+		}
+		if !m.IsDiscouraged(ip) || m.IsBanned(ip) {
+			t.Fatalf("%s not only discouraged", ip)
+		}
+		if m.IsDiscouraged(net.ParseIP("172.16.0.2")) {
+			t.Fatal("unrelated address discouraged")
+		}
+
+		list, err := m.BanList()
+		if err != nil {
+			t.Fatalf("BanList: unexpected error: %v", err)
+		}
+		if len(list) != 2 || list[0].Subnet.String() != "10.0.0.0/16" ||
+			list[1].Subnet.String() != "2001:db8::1/128" {
+
+			t.Fatalf("unexpected ban list %v", list)
+		}
+		if list[1].Until.Unix() != now.Add(2*time.Hour).Unix() {
+			t.Fatalf("unexpected expiry %v", list[1].Until)
+		}
+	}
+	checkState(m)
+
+	// The bans and discouragements persist across restarts.
+	m, err = New(db)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	checkState(m)
+
+	if err := m.Unban(host); err != nil {
+		t.Fatalf("Unban: unexpected error: %v", err)
+	}
+	if err := m.Unban(host); err != ErrNotBanned {
+		t.Fatalf("Unban of unbanned subnet: got %v, want %v", err,
+			ErrNotBanned)
+	}
+	if m.IsBanned(net.ParseIP("2001:db8::1")) {
+		t.Fatal("unbanned address still banned")
+	}
+
+	if err := m.Clear(); err != nil {
+		t.Fatalf("Clear: unexpected error: %v", err)
+	}
+	m, err = New(db)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	list, err := m.BanList()
+	if err != nil {
+		t.Fatalf("BanList: unexpected error: %v", err)
+	}
+	if len(list) != 0 || m.IsDiscouraged(ip) {
+		t.Fatalf("bans left after clearing: %v", list)
+	}
+}
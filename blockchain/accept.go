@@ -34,6 +34,15 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 		return false, ruleError(ErrInvalidAncestorBlock, str)
 	}
 
+	// Reject blocks that fork the main chain before the finalized block or
+	// a block whose undo data was pruned.
+	if err := b.checkFinalizedFork(prevNode); err != nil {
+		return false, err
+	}
+	if err := b.checkUndoPrunedFork(prevNode); err != nil {
+		return false, err
+	}
+
 	blockHeight := prevNode.height + 1
 	block.SetHeight(blockHeight)
 
@@ -62,12 +71,17 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 
 	// Create a new block node for the block and add it to the node index. Even
 	// if the block ultimately gets connected to the main chain, it starts out
-	// on a side chain.
-	blockHeader := &block.MsgBlock().Header
-	newNode := newBlockNode(blockHeader, prevNode)
-	newNode.status = statusDataStored
-
-	b.index.AddNode(newNode)
+	// on a side chain.  The node already exists when the block was previously
+	// accepted by its header only, in which case its data is marked stored.
+	newNode := b.index.LookupNode(block.Hash())
+	if newNode == nil {
+		blockHeader := &block.MsgBlock().Header
+		newNode = newBlockNode(blockHeader, prevNode)
+		newNode.status = statusDataStored
+		b.index.AddNode(newNode)
+	} else {
+		b.index.SetStatusFlags(newNode, statusDataStored)
+	}
 	err = b.index.flushToDB()
 	if err != nil {
 		return false, err
@@ -92,3 +106,27 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 
 	return isMainChain, nil
 }
+
+// storeBlockData stores the data of the passed block whose node is already
+// connected to the main chain without its data, such as the blocks below the
+// base of a loaded utxo snapshot.  The block must still pass all of the
+// validation rules which depend on its position within the block chain, since
+// otherwise any block with a matching header could be stored.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) storeBlockData(node *blockNode, block *btcutil.Block, flags BehaviorFlags) error {
+	block.SetHeight(node.height)
+	if err := b.checkBlockContext(block, node.parent, flags); err != nil {
+		return err
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbStoreBlock(dbTx, block)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.index.SetStatusFlags(node, statusDataStored)
+	return b.index.flushToDB()
+}
@@ -450,44 +450,66 @@ func (bi *blockIndex) UnsetStatusFlags(node *blockNode, flags blockStatus) {
 	bi.Unlock()
 }
 
-// InactiveTips returns all the block nodes that aren't in the best chain.
+// ForEachNode calls the passed function with each node in the block index, in
+// no particular order, until it returns an error, which is then returned.  The
+// block index is locked for the duration of the traversal, so the function must
+// not call back into it.
 //
 // This function is safe for concurrent access.
-func (bi *blockIndex) InactiveTips(bestChain *chainView) []*blockNode {
+func (bi *blockIndex) ForEachNode(fn func(node *blockNode) error) error {
 	bi.RLock()
 	defer bi.RUnlock()
 
-	// Look through the entire blockindex and look for nodes that aren't in
-	// the best chain. We're gonna keep track of all the orphans and the parents
-	// of the orphans.
-	orphans := make(map[chainhash.Hash]*blockNode)
-	orphanParent := make(map[chainhash.Hash]*blockNode)
-	for hash, node := range bi.index {
-		found := bestChain.Contains(node)
-		if !found {
-			orphans[hash] = node
-			orphanParent[node.parent.hash] = node.parent
+	for _, node := range bi.index {
+		if err := fn(node); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// If an orphan isn't pointed to by another orphan, it is a chain tip.
-	//
-	// We can check this by looking for the orphan in the orphan parent map.
-	// If the orphan exists in the orphan parent map, it means that another
-	// orphan is pointing to it.
-	tips := make([]*blockNode, 0, len(orphans))
-	for hash, orphan := range orphans {
-		_, found := orphanParent[hash]
-		if !found {
-			tips = append(tips, orphan)
+// Tips returns the tips of all the branches of the block tree, which are the
+// block nodes no other node in the block index builds on.  This includes the
+// tip of the best chain.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) Tips() []*blockNode {
+	// Keep track of all the nodes along with the ones which are the parent
+	// of another node.  Every node which isn't a parent is a tip.
+	var nodes []*blockNode
+	parents := make(map[*blockNode]struct{})
+	bi.ForEachNode(func(node *blockNode) error {
+		nodes = append(nodes, node)
+		if node.parent != nil {
+			parents[node.parent] = struct{}{}
 		}
+		return nil
+	})
 
-		delete(orphanParent, hash)
+	tips := make([]*blockNode, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := parents[node]; !ok {
+			tips = append(tips, node)
+		}
 	}
-
 	return tips
 }
 
+// InactiveTips returns the tips of all the branches of the block tree which
+// aren't part of the best chain.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) InactiveTips(bestChain *chainView) []*blockNode {
+	tips := bi.Tips()
+	inactive := tips[:0]
+	for _, tip := range tips {
+		if !bestChain.Contains(tip) {
+			inactive = append(inactive, tip)
+		}
+	}
+	return inactive
+}
+
 // flushToDB writes all dirty block nodes to the database. If all writes
 // succeed, this clears the dirty set.
 func (bi *blockIndex) flushToDB() error {
@@ -0,0 +1,367 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// NumFeeRatePercentiles is the number of fee rate percentiles in the
+	// statistics of a block.
+	NumFeeRatePercentiles = 5
+
+	// perUtxoOverhead is the number of bytes added to the size of each
+	// output to estimate its size in the utxo set, which is the size of
+	// its outpoint, height and coinbase flag as accounted by Bitcoin Core.
+	perUtxoOverhead = 41
+
+	// blockStatsCacheSize is the number of block statistics kept in the
+	// cache of recently calculated statistics.
+	blockStatsCacheSize = 100
+)
+
+// BlockStats houses statistics about a block of the main chain and its
+// transactions as reported by the getblockstats RPC.  Unless stated otherwise
+// the statistics exclude the coinbase transaction.  Fees are in satoshi and fee
+// rates in satoshi per virtual byte.
+type BlockStats struct {
+	Hash       chainhash.Hash
+	Height     int32
+	Time       int64
+	MedianTime int64
+
+	// Txs is the number of transactions including the coinbase, Ins the
+	// number of inputs and Outs the number of outputs including the ones
+	// of the coinbase.
+	Txs  int64
+	Ins  int64
+	Outs int64
+
+	// TotalOut is the total value of the outputs and Subsidy the block
+	// subsidy.
+	TotalOut int64
+	Subsidy  int64
+
+	TotalFee  int64
+	MinFee    int64
+	MaxFee    int64
+	AvgFee    int64
+	MedianFee int64
+
+	// The fee rate percentiles are weighted by the weight of the
+	// transactions at the 10th, 25th, 50th, 75th and 90th percentile.
+	MinFeeRate         int64
+	MaxFeeRate         int64
+	AvgFeeRate         int64
+	FeeRatePercentiles [NumFeeRatePercentiles]int64
+
+	MinTxSize    int64
+	MaxTxSize    int64
+	AvgTxSize    int64
+	MedianTxSize int64
+	TotalSize    int64
+	TotalWeight  int64
+
+	// SegWitTxs is the number of transactions with witness data and
+	// SegWitTotalSize and SegWitTotalWeight their total size and weight.
+	SegWitTxs         int64
+	SegWitTotalSize   int64
+	SegWitTotalWeight int64
+
+	// UtxoIncrease is the change of the number of outputs in the utxo set
+	// and UtxoSizeIncrease the change of its size when counting all
+	// outputs.  The actual changes exclude unspendable outputs and the
+	// outputs of the genesis block and of the coinbases which violate
+	// BIP0030, which never enter the utxo set.
+	UtxoIncrease           int64
+	UtxoSizeIncrease       int64
+	UtxoIncreaseActual     int64
+	UtxoSizeIncreaseActual int64
+}
+
+// blockStatsCache is a least recently used cache of the statistics of blocks.
+// The statistics of a block never change, so they can be kept as long as the
+// block exists.
+type blockStatsCache struct {
+	mtx     sync.Mutex
+	entries map[chainhash.Hash]*list.Element
+	order   *list.List
+	limit   int
+}
+
+// newBlockStatsCache returns a block statistics cache which holds up to limit
+// entries.
+func newBlockStatsCache(limit int) *blockStatsCache {
+	return &blockStatsCache{
+		entries: make(map[chainhash.Hash]*list.Element, limit),
+		order:   list.New(),
+		limit:   limit,
+	}
+}
+
+// lookup returns the cached statistics of the block with the passed hash.
+func (c *blockStatsCache) lookup(hash *chainhash.Hash) (*BlockStats, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.entries[*hash]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*BlockStats), true
+}
+
+// add adds the passed statistics to the cache and evicts the least recently
+// used entry when the cache is full.
+func (c *blockStatsCache) add(stats *BlockStats) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.entries[stats.Hash]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.limit {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*BlockStats).Hash)
+	}
+	c.entries[stats.Hash] = c.order.PushFront(stats)
+}
+
+// truncatedMedian returns the median of the passed values with the average of
+// the two middle values truncated for an even number of values.  The values
+// are sorted in place.
+func truncatedMedian(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// feeRateWeight is the fee rate of a transaction along with its weight.
+type feeRateWeight struct {
+	feeRate int64
+	weight  int64
+}
+
+// calcFeeRatePercentiles returns the fee rates at the percentiles of the total
+// weight of the passed transactions.  The fee rates are sorted in place.
+func calcFeeRatePercentiles(feeRates []feeRateWeight, totalWeight int64) [NumFeeRatePercentiles]int64 {
+	var result [NumFeeRatePercentiles]int64
+	if len(feeRates) == 0 {
+		return result
+	}
+	sort.Slice(feeRates, func(i, j int) bool {
+		if feeRates[i].feeRate != feeRates[j].feeRate {
+			return feeRates[i].feeRate < feeRates[j].feeRate
+		}
+		return feeRates[i].weight < feeRates[j].weight
+	})
+
+	// The weights are calculated like Bitcoin Core does so the results
+	// match for weights that aren't exactly representable.
+	total := float64(totalWeight)
+	weights := [NumFeeRatePercentiles]float64{
+		total / 10, total / 4, total / 2, total * 3 / 4, total * 9 / 10,
+	}
+
+	next := 0
+	var cumulativeWeight int64
+	for _, fr := range feeRates {
+		cumulativeWeight += fr.weight
+		for next < NumFeeRatePercentiles &&
+			float64(cumulativeWeight) >= weights[next] {
+
+			result[next] = fr.feeRate
+			next++
+		}
+	}
+	for ; next < NumFeeRatePercentiles; next++ {
+		result[next] = feeRates[len(feeRates)-1].feeRate
+	}
+	return result
+}
+
+// calcBlockStats calculates the statistics of the passed block given the
+// outputs spent by its transactions in the order they are spent.
+func (b *BlockChain) calcBlockStats(node *blockNode, block *btcutil.Block, stxos []SpentTxOut) (*BlockStats, error) {
+	stats := &BlockStats{
+		Hash:       node.hash,
+		Height:     node.height,
+		Time:       node.timestamp,
+		MedianTime: CalcPastMedianTime(node).Unix(),
+		Txs:        int64(len(block.Transactions())),
+		Subsidy:    CalcBlockSubsidy(node.height, b.chainParams),
+	}
+
+	// The outputs of the genesis block and the coinbases of the blocks
+	// violating BIP0030 are never added to the utxo set.
+	genesis := node.height == 0
+	bip0030Node := isBIP0030Node(node)
+
+	var utxos int64
+	var fees, txSizes []int64
+	var feeRates []feeRateWeight
+	for i, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		isCoinBase := i == 0
+
+		stats.Outs += int64(len(msgTx.TxOut))
+		var totalOut int64
+		for _, txOut := range msgTx.TxOut {
+			totalOut += txOut.Value
+			size := int64(txOut.SerializeSize() + perUtxoOverhead)
+			stats.UtxoSizeIncrease += size
+
+			if genesis || (bip0030Node && isCoinBase) ||
+				txscript.IsUnspendable(txOut.PkScript) {
+
+				continue
+			}
+			utxos++
+			stats.UtxoSizeIncreaseActual += size
+		}
+		if isCoinBase {
+			continue
+		}
+
+		stats.Ins += int64(len(msgTx.TxIn))
+		stats.TotalOut += totalOut
+
+		size := int64(msgTx.SerializeSize())
+		txSizes = append(txSizes, size)
+		if len(txSizes) == 1 || size < stats.MinTxSize {
+			stats.MinTxSize = size
+		}
+		if size > stats.MaxTxSize {
+			stats.MaxTxSize = size
+		}
+		stats.TotalSize += size
+
+		weight := GetTransactionWeight(tx)
+		stats.TotalWeight += weight
+		if msgTx.HasWitness() {
+			stats.SegWitTxs++
+			stats.SegWitTotalSize += size
+			stats.SegWitTotalWeight += weight
+		}
+
+		if len(stxos) < len(msgTx.TxIn) {
+			return nil, AssertError(fmt.Sprintf("spend journal of "+
+				"block %v is missing spent outputs", node.hash))
+		}
+		var totalIn int64
+		for _, stxo := range stxos[:len(msgTx.TxIn)] {
+			totalIn += stxo.Amount
+			spent := wire.NewTxOut(stxo.Amount, stxo.PkScript)
+			size := int64(spent.SerializeSize() + perUtxoOverhead)
+			stats.UtxoSizeIncrease -= size
+			stats.UtxoSizeIncreaseActual -= size
+		}
+		stxos = stxos[len(msgTx.TxIn):]
+
+		fee := totalIn - totalOut
+		fees = append(fees, fee)
+		if len(fees) == 1 || fee < stats.MinFee {
+			stats.MinFee = fee
+		}
+		if fee > stats.MaxFee {
+			stats.MaxFee = fee
+		}
+		stats.TotalFee += fee
+
+		var feeRate int64
+		if weight > 0 {
+			feeRate = fee * WitnessScaleFactor / weight
+		}
+		feeRates = append(feeRates, feeRateWeight{feeRate, weight})
+		if len(feeRates) == 1 || feeRate < stats.MinFeeRate {
+			stats.MinFeeRate = feeRate
+		}
+		if feeRate > stats.MaxFeeRate {
+			stats.MaxFeeRate = feeRate
+		}
+	}
+
+	if n := int64(len(fees)); n > 0 {
+		stats.AvgFee = stats.TotalFee / n
+		stats.AvgTxSize = stats.TotalSize / n
+	}
+	if stats.TotalWeight > 0 {
+		stats.AvgFeeRate = stats.TotalFee * WitnessScaleFactor /
+			stats.TotalWeight
+	}
+	stats.MedianFee = truncatedMedian(fees)
+	stats.MedianTxSize = truncatedMedian(txSizes)
+	stats.FeeRatePercentiles = calcFeeRatePercentiles(feeRates,
+		stats.TotalWeight)
+	stats.UtxoIncrease = stats.Outs - stats.Ins
+	stats.UtxoIncreaseActual = utxos - stats.Ins
+	return stats, nil
+}
+
+// BlockStats returns the statistics of the block of the main chain with the
+// passed hash.  The outputs spent by the block are resolved from the spend
+// journal, so the block and its spend journal must not have been pruned.  The
+// statistics of recently requested blocks are cached and must not be modified
+// by the caller.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockStats(hash *chainhash.Hash) (*BlockStats, error) {
+	if stats, ok := b.blockStatsCache.lookup(hash); ok {
+		return stats, nil
+	}
+
+	b.chainLock.RLock()
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		b.chainLock.RUnlock()
+		str := fmt.Sprintf("block %s is not in the main chain", hash)
+		return nil, errNotInMainChain(str)
+	}
+	if err := b.checkUndoDataAvailable(node); err != nil {
+		b.chainLock.RUnlock()
+		return nil, err
+	}
+	var block *btcutil.Block
+	var stxos []SpentTxOut
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		block, err = dbFetchBlockByNode(dbTx, node)
+		if err != nil {
+			return err
+		}
+		stxos, err = dbFetchSpendJournalEntry(dbTx, block)
+		return err
+	})
+	b.chainLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := b.calcBlockStats(node, block, stxos)
+	if err != nil {
+		return nil, err
+	}
+	b.blockStatsCache.add(stats)
+	return stats, nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestTruncatedMedian ensures medians of even numbers of values are the
+// truncated average of the two middle values.
+func TestTruncatedMedian(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		values []int64
+		want   int64
+	}{
+		{nil, 0},
+		{[]int64{7}, 7},
+		{[]int64{9, 1, 5}, 5},
+		{[]int64{4, 1, 2, 3}, 2},
+		{[]int64{1, 4}, 2},
+	}
+	for _, test := range tests {
+		if got := truncatedMedian(test.values); got != test.want {
+			t.Errorf("truncatedMedian(%v): got %d, want %d",
+				test.values, got, test.want)
+		}
+	}
+}
+
+// TestCalcFeeRatePercentiles ensures the fee rate percentiles are weighted by
+// the weight of the transactions.
+func TestCalcFeeRatePercentiles(t *testing.T) {
+	t.Parallel()
+
+	// The transaction paying 1 sat/vB makes up half of the total weight,
+	// so it covers the 10th, 25th and 50th percentile.
+	feeRates := []feeRateWeight{
+		{feeRate: 10, weight: 400},
+		{feeRate: 1, weight: 1000},
+		{feeRate: 5, weight: 600},
+	}
+	got := calcFeeRatePercentiles(feeRates, 2000)
+	want := [NumFeeRatePercentiles]int64{1, 1, 1, 5, 10}
+	if got != want {
+		t.Fatalf("calcFeeRatePercentiles: got %v, want %v", got, want)
+	}
+
+	got = calcFeeRatePercentiles(nil, 0)
+	if got != [NumFeeRatePercentiles]int64{} {
+		t.Fatalf("calcFeeRatePercentiles: got %v for no transactions",
+			got)
+	}
+}
+
+// TestBlockStats ensures the statistics of blocks account for the fees and
+// the outputs added to and removed from the utxo set by their transactions.
+func TestBlockStats(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestBlockStats")
+	defer tearDown()
+
+	tip := btcutil.NewBlock(params.GenesisBlock)
+	b1, spends, err := addBlock(chain, tip, nil)
+	if err != nil {
+		t.Fatalf("addBlock: unexpected error: %v", err)
+	}
+	b2, _, err := addBlock(chain, b1, spends)
+	if err != nil {
+		t.Fatalf("addBlock: unexpected error: %v", err)
+	}
+
+	stats, err := chain.BlockStats(b2.Hash())
+	if err != nil {
+		t.Fatalf("BlockStats: unexpected error: %v", err)
+	}
+
+	spendTx := b2.Transactions()[1]
+	size := int64(spendTx.MsgTx().SerializeSize())
+	weight := GetTransactionWeight(spendTx)
+	subsidy := CalcBlockSubsidy(2, params)
+
+	// The spending transaction pays a fee of one satoshi and has an
+	// unspendable OP_RETURN output, which doesn't enter the utxo set.
+	if stats.Height != 2 || stats.Txs != 2 || stats.Ins != 1 ||
+		stats.Outs != 3 {
+
+		t.Fatalf("unexpected counts: height %d, txs %d, ins %d, outs %d",
+			stats.Height, stats.Txs, stats.Ins, stats.Outs)
+	}
+	if stats.TotalFee != 1 || stats.MinFee != 1 || stats.MaxFee != 1 ||
+		stats.AvgFee != 1 || stats.MedianFee != 1 {
+
+		t.Fatalf("unexpected fees: %+v", stats)
+	}
+	if stats.Subsidy != subsidy || stats.TotalOut != subsidy-1 {
+		t.Fatalf("unexpected amounts: subsidy %d, total out %d",
+			stats.Subsidy, stats.TotalOut)
+	}
+	if stats.TotalSize != size || stats.MinTxSize != size ||
+		stats.MaxTxSize != size || stats.MedianTxSize != size ||
+		stats.TotalWeight != weight || stats.SegWitTxs != 0 {
+
+		t.Fatalf("unexpected sizes: %+v", stats)
+	}
+	if stats.UtxoIncrease != 2 || stats.UtxoIncreaseActual != 1 {
+		t.Fatalf("unexpected utxo increase: %d, actual %d",
+			stats.UtxoIncrease, stats.UtxoIncreaseActual)
+	}
+	opReturn := spendTx.MsgTx().TxOut[1]
+	if stats.UtxoSizeIncrease-stats.UtxoSizeIncreaseActual !=
+		int64(opReturn.SerializeSize()+perUtxoOverhead) {
+
+		t.Fatalf("unexpected utxo size increase: %d, actual %d",
+			stats.UtxoSizeIncrease, stats.UtxoSizeIncreaseActual)
+	}
+
+	// The statistics are served from the cache afterwards.
+	cached, err := chain.BlockStats(b2.Hash())
+	if err != nil {
+		t.Fatalf("BlockStats: unexpected error: %v", err)
+	}
+	if cached != stats {
+		t.Fatalf("BlockStats: statistics weren't cached")
+	}
+
+	// Blocks which aren't in the main chain are rejected.
+	_, err = chain.BlockStats(&chainhash.Hash{0x01})
+	if !isNotInMainChainErr(err) {
+		t.Fatalf("BlockStats: got error %v, want not in main chain", err)
+	}
+}
+
+// TestBlockStatsCache ensures the least recently used statistics are evicted
+// from the cache once it is full.
+func TestBlockStatsCache(t *testing.T) {
+	t.Parallel()
+
+	cache := newBlockStatsCache(2)
+	cache.add(&BlockStats{Hash: chainhash.Hash{1}})
+	cache.add(&BlockStats{Hash: chainhash.Hash{2}})
+	if _, ok := cache.lookup(&chainhash.Hash{1}); !ok {
+		t.Fatalf("lookup: missing entry 1")
+	}
+	cache.add(&BlockStats{Hash: chainhash.Hash{3}})
+
+	if _, ok := cache.lookup(&chainhash.Hash{2}); ok {
+		t.Fatalf("lookup: least recently used entry 2 wasn't evicted")
+	}
+	for _, hash := range []chainhash.Hash{{1}, {3}} {
+		if _, ok := cache.lookup(&hash); !ok {
+			t.Fatalf("lookup: missing entry %v", hash)
+		}
+	}
+}
@@ -6,8 +6,11 @@
 package blockchain
 
 import (
+	"bytes"
 	"container/list"
 	"fmt"
+	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -102,6 +105,8 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	scriptWorkers       int
+	maxReorgDepth       int32
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -119,6 +124,17 @@ type BlockChain struct {
 	// is pruned.
 	pruneTarget uint64
 
+	// pruneDepth is the number of most recent main chain blocks which are
+	// kept when the node prunes blocks by depth instead of by size.
+	pruneDepth int32
+
+	// undoPruneDepth is the number of most recent main chain blocks whose
+	// spend journal entries are kept when undo data is pruned, or 0 when
+	// it isn't.  undoPrunedHeight is the height of the most recent main
+	// chain block whose spend journal entry was pruned.
+	undoPruneDepth   int32
+	undoPrunedHeight int32
+
 	// These fields are related to the memory block index.  They both have
 	// their own locks, however they are often also protected by the chain
 	// lock to help prevent logic races when blocks are being processed.
@@ -135,6 +151,11 @@ type BlockChain struct {
 	// It is protected by the chain lock.
 	utxoCache *utxoCache
 
+	// verifyMemoryLimit is the estimated memory in bytes VerifyChain may
+	// use for the view of the utxo set the blocks are disconnected from
+	// and the blocks kept to connect them again.
+	verifyMemoryLimit uint64
+
 	// These fields are related to handling of orphan blocks.  They are
 	// protected by a combination of the chain lock and the orphan lock.
 	orphanLock   sync.RWMutex
@@ -161,6 +182,32 @@ type BlockChain struct {
 	stateLock     sync.RWMutex
 	stateSnapshot *BestState
 
+	// utxoSetStats houses the statistics of the utxo set, including its
+	// rolling hash, as of the best block.  Like the state snapshot, it is
+	// replaced rather than modified and protected by the state lock.
+	utxoSetStats *utxoSetStats
+
+	// finalizedNode is the most recent block that was finalized via
+	// FinalizeBlock.  The main chain can't be reorganized across it.  It is
+	// protected by the chain lock.
+	finalizedNode *blockNode
+
+	// pendingReorg describes the reorganization to pendingReorgNode that
+	// is paused because it exceeds the maximum reorganization depth.  They
+	// are protected by the chain lock.
+	pendingReorg     *PendingReorg
+	pendingReorgNode *blockNode
+
+	// preciousSeqs maps the blocks marked precious via PreciousBlock to the
+	// order they were marked in, where blocks marked later have lower
+	// values, so ties between chains with the same work are broken in
+	// favor of the most recently marked tip.  lastPreciousWork is the work
+	// of the main chain when a block was last marked precious.  They are
+	// protected by the chain lock.
+	preciousSeqs     map[*blockNode]int32
+	lastPreciousSeq  int32
+	lastPreciousWork *big.Int
+
 	// The following caches are used to efficiently keep track of the
 	// current deployment threshold state of each rule change deployment.
 	//
@@ -189,6 +236,21 @@ type BlockChain struct {
 	// certain blockchain events.
 	notificationsLock sync.RWMutex
 	notifications     []NotificationCallback
+
+	// The chainEvents field stores a slice of callbacks to be executed
+	// with the canonical chain events.  The lock also serializes delivery
+	// so the events are seen in sequence order.
+	chainEventsLock sync.Mutex
+	chainEvents     []ChainEventCallback
+	chainEventSeq   uint64
+
+	// scriptStats houses the timing statistics of the script validation
+	// performed while connecting blocks.
+	scriptStatsLock sync.Mutex
+	scriptStats     ScriptValidationStats
+
+	// blockStatsCache houses the statistics of recently requested blocks.
+	blockStatsCache *blockStatsCache
 }
 
 // HaveBlock returns whether or not the chain instance has the block represented
@@ -611,14 +673,37 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 		curTotalTxns+numTxns, CalcPastMedianTime(node),
 	)
 
+	// Update the statistics of the utxo set, including its rolling hash,
+	// for the outputs spent and created by the block.
+	utxoStats := b.utxoSetStats.clone()
+	utxoStats.applyBlock(block, node.height, stxos, true)
+
+	// Determine the height up to which the undo data is pruned once the
+	// block is connected.
+	undoPrunedHeight := b.undoPrunedHeight
+	if b.undoPruneDepth != 0 {
+		undoPrunedHeight = b.undoPruneTarget(node, b.undoPruneDepth)
+	}
+
 	// Atomically insert info into the database.
 	err = b.db.Update(func(dbTx database.Tx) error {
-		// If the pruneTarget isn't 0, we should attempt to delete older blocks
-		// from the database.
-		if b.pruneTarget != 0 {
-			// When the total block size is under the prune target, prune blocks is
-			// a no-op and the deleted hashes are nil.
-			deletedHashes, err := dbTx.PruneBlocks(b.pruneTarget)
+		// If the pruneTarget or pruneDepth isn't 0, we should attempt to
+		// delete older blocks from the database.
+		if b.pruneTarget != 0 || b.pruneDepth != 0 {
+			var deletedHashes []chainhash.Hash
+			var err error
+			switch {
+			// When the total block size is under the prune target, prune
+			// blocks is a no-op and the deleted hashes are nil.
+			case b.pruneTarget != 0:
+				deletedHashes, err = dbTx.PruneBlocks(b.pruneTarget)
+
+			// Delete the blocks stored before the oldest block to keep,
+			// which keeps all of its descendants.
+			case node.height > b.pruneDepth:
+				keep := node.Ancestor(node.height - b.pruneDepth + 1)
+				deletedHashes, err = dbTx.PruneBlocksBefore(&keep.hash)
+			}
 			if err != nil {
 				return err
 			}
@@ -656,6 +741,10 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 		if err != nil {
 			return err
 		}
+		err = dbPutUtxoSetStats(dbTx, utxoStats)
+		if err != nil {
+			return err
+		}
 
 		// Add the block hash and height to the block index which tracks
 		// the main chain.
@@ -671,6 +760,16 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 			return err
 		}
 
+		// Remove the spend journal entries of the blocks which fell
+		// behind the undo prune depth.
+		if undoPrunedHeight > b.undoPrunedHeight {
+			err = dbPruneUndoData(dbTx, node, b.undoPrunedHeight,
+				undoPrunedHeight)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being connected so they can
 		// update themselves accordingly.
@@ -689,6 +788,9 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 
 	// This node is now the end of the best chain.
 	b.bestChain.SetTip(node)
+	if undoPrunedHeight > b.undoPrunedHeight {
+		b.undoPrunedHeight = undoPrunedHeight
+	}
 
 	// Update the state for the best block.  Notice how this replaces the
 	// entire struct instead of updating the existing one.  This effectively
@@ -697,6 +799,7 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 	// comments on the state variable for more details.
 	b.stateLock.Lock()
 	b.stateSnapshot = state
+	b.utxoSetStats = utxoStats
 	b.stateLock.Unlock()
 
 	// Notify the caller that the block was connected to the main chain.
@@ -706,6 +809,10 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 		b.chainLock.Unlock()
 		defer b.chainLock.Lock()
 		b.sendNotification(NTBlockConnected, block)
+		b.sendChainEvent(ChainEventBlockConnected, node, block, stxos)
+		if b.isCheckpointNode(node) {
+			b.sendChainEvent(ChainEventFinalized, node, nil, nil)
+		}
 	}()
 
 	// Since we may have changed the UTXO cache, we make sure it didn't exceed its
@@ -756,6 +863,8 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 	state := newBestState(prevNode, blockSize, blockWeight, numTxns,
 		newTotalTxns, CalcPastMedianTime(prevNode))
 
+	var stxos []SpentTxOut
+	var utxoStats *utxoSetStats
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
 		err := dbPutBestState(dbTx, state, node.workSum)
@@ -789,7 +898,17 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 
 		// Before we delete the spend journal entry for this back,
 		// we'll fetch it as is so the indexers can utilize if needed.
-		stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+		stxos, err = dbFetchSpendJournalEntry(dbTx, block)
+		if err != nil {
+			return err
+		}
+
+		// Update the statistics of the utxo set, including its rolling
+		// hash, for the outputs restored and removed by disconnecting
+		// the block.
+		utxoStats = b.utxoSetStats.clone()
+		utxoStats.applyBlock(block, node.height, stxos, false)
+		err = dbPutUtxoSetStats(dbTx, utxoStats)
 		if err != nil {
 			return err
 		}
@@ -831,6 +950,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 	// comments on the state variable for more details.
 	b.stateLock.Lock()
 	b.stateSnapshot = state
+	b.utxoSetStats = utxoStats
 	b.stateLock.Unlock()
 
 	// Notify the caller that the block was disconnected from the main
@@ -840,6 +960,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 		b.chainLock.Unlock()
 		defer b.chainLock.Lock()
 		b.sendNotification(NTBlockDisconnected, block)
+		b.sendChainEvent(ChainEventBlockDisconnected, node, block, stxos)
 	}()
 
 	return nil
@@ -886,6 +1007,23 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		}
 	}
 
+	// Ensure the finalized block and blocks whose undo data was pruned are
+	// not detached.
+	for e := detachNodes.Front(); e != nil; e = e.Next() {
+		n := e.Value.(*blockNode)
+		if n == b.finalizedNode {
+			str := fmt.Sprintf("reorganize would disconnect the "+
+				"finalized block %v at height %d", n.hash, n.height)
+			return ruleError(ErrFinalizedFork, str)
+		}
+		if n.height <= b.undoPrunedHeight {
+			str := fmt.Sprintf("reorganize would disconnect block "+
+				"%v at height %d whose undo data was pruned",
+				n.hash, n.height)
+			return ruleError(ErrUndoDataPruned, str)
+		}
+	}
+
 	// Ensure the provided nodes are for the same fork point.
 	if attachNodes.Len() != 0 && detachNodes.Len() != 0 {
 		firstAttachNode := attachNodes.Front().Value.(*blockNode)
@@ -1024,7 +1162,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// In the case the block is determined to be invalid due to a
 		// rule violation, mark it as invalid and mark all of its
 		// descendants as having an invalid ancestor.
-		err = b.checkConnectBlock(n, block, view, nil)
+		err = b.checkConnectBlock(n, block, view, nil, BFNone)
 		if err != nil {
 			if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(n, statusValidateFailed)
@@ -1177,7 +1315,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 			// expensive memory allocation done by fetch input utxos.
 			view := NewUtxoViewpoint()
 			view.SetBestHash(parentHash)
-			err := b.checkConnectBlock(node, block, view, nil)
+			err := b.checkConnectBlock(node, block, view, nil, flags)
 			if err == nil {
 				b.index.SetStatusFlags(node, statusValid)
 			} else if _, ok := err.(RuleError); ok {
@@ -1191,6 +1329,8 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 			if err != nil {
 				return false, err
 			}
+
+			b.sendNotification(NTBlockValidated, block)
 		}
 
 		// Connect the transactions to the cache.  All the txs are considered valid
@@ -1234,8 +1374,9 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 	}
 
 	// We're extending (or creating) a side chain, but the cumulative
-	// work for this new side chain is not enough to make it the new chain.
-	if node.workSum.Cmp(b.bestChain.Tip().workSum) <= 0 {
+	// work for this new side chain is not enough to make it the new chain,
+	// or it has the same work and isn't preferred via PreciousBlock.
+	if !b.preferChain(node, b.bestChain.Tip()) {
 		// Log information about how the block is forking the chain.
 		fork := b.bestChain.FindFork(node)
 		if fork.hash.IsEqual(parentHash) {
@@ -1258,6 +1399,12 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 	// blocks that form the (now) old fork from the main chain, and attach
 	// the blocks that form the new chain to the main chain starting at the
 	// common ancenstor (the point where the chain forked).
+	//
+	// Reorganizations deeper than the maximum depth are paused until they
+	// are confirmed, leaving the new chain as a side chain for now.
+	if b.reorgNeedsConfirmation(node) {
+		return false, nil
+	}
 	detachNodes, attachNodes := b.getReorganizeNodes(node)
 
 	// Reorganize the chain.
@@ -1346,6 +1493,12 @@ const (
 	// 2: Is not invalid.
 	// 3: Has the block data stored to disk.
 	StatusValidFork
+
+	// StatusHeadersOnly indicates that the tip is not a part of the best
+	// chain and is not known to be invalid, but the data of this tip or any
+	// of the ancestors of this tip which aren't in the best chain isn't
+	// available, so only the headers of the branch are known.
+	StatusHeadersOnly
 )
 
 // String returns the status flags as string.
@@ -1357,6 +1510,8 @@ func (ts TipStatus) String() string {
 		return "invalid"
 	case StatusValidFork:
 		return "valid-fork"
+	case StatusHeadersOnly:
+		return "headers-only"
 	}
 	return fmt.Sprintf("unknown: %b", ts)
 }
@@ -1378,7 +1533,9 @@ type ChainTip struct {
 }
 
 // ChainTips returns all the chain tips the node itself is aware of.  Each tip is
-// represented by its height, block hash, branch length, and status.
+// represented by its height, block hash, branch length, and status.  The tips
+// are ordered by descending height with ties broken by the hash of the tip, so
+// the result is stable across calls.
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) ChainTips() []ChainTip {
@@ -1396,48 +1553,62 @@ func (b *BlockChain) ChainTips() []ChainTip {
 	// Go through all the tips and grab the height, hash, branch length, and the block
 	// status.
 	for _, tip := range tips {
-		var status TipStatus
-		switch {
-		// The tip is considered active if it's in the best chain.
-		case b.bestChain.Contains(tip):
-			status = StatusActive
-
-		// This block or any of the ancestors of this block are invalid.
-		case tip.status.KnownInvalid():
-			status = StatusInvalid
-
-		// If the tip meets the following criteria:
-		// 1: Not a part of the best chain.
-		// 2: Is not invalid.
-		// 3: Has the block data stored to disk.
-		//
-		// The tip is considered a valid fork.
-		//
-		// We can check if a tip is a valid-fork by checking that
-		// its data is available. Since the behavior is to give a
-		// block node the statusDataStored status once it passes
-		// the proof of work checks and basic chain validity checks.
-		//
-		// We can't use the KnownValid status since it's only given
-		// to blocks that passed the validation AND were a part of
-		// the bestChain.
-		case tip.status.HaveData():
-			status = StatusValidFork
-		}
-
+		fork := b.bestChain.FindFork(tip)
 		chainTip := ChainTip{
 			Height:    tip.height,
 			BlockHash: tip.hash,
-			BranchLen: tip.height - b.bestChain.FindFork(tip).height,
-			Status:    status,
+			BranchLen: tip.height - fork.height,
+			Status:    b.branchStatus(tip, fork),
 		}
 
 		chainTips = append(chainTips, chainTip)
 	}
 
+	sort.Slice(chainTips, func(i, j int) bool {
+		if chainTips[i].Height != chainTips[j].Height {
+			return chainTips[i].Height > chainTips[j].Height
+		}
+		return bytes.Compare(chainTips[i].BlockHash[:],
+			chainTips[j].BlockHash[:]) < 0
+	})
+
 	return chainTips
 }
 
+// branchStatus returns the status of the branch which ends at the passed tip and
+// forks off the best chain at the passed fork node.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) branchStatus(tip, fork *blockNode) TipStatus {
+	// The tip is considered active if it's in the best chain.
+	if tip == fork {
+		return StatusActive
+	}
+
+	// Walk the blocks of the branch back to the fork point since the status
+	// depends on all of them.  A block which is known to be invalid makes
+	// the whole branch invalid, while a block which doesn't have its data
+	// stored means the branch is only known by its headers.
+	//
+	// Note the KnownValid status can't be used to determine whether the
+	// branch is a valid fork, since it's only given to blocks that passed
+	// the validation AND were a part of the best chain.  Blocks are given
+	// the statusDataStored status once they pass the proof of work checks
+	// and basic chain validity checks, so a branch with the data of all its
+	// blocks stored is considered a valid fork.
+	status := StatusValidFork
+	for node := tip; node != nil && node != fork; node = node.parent {
+		nodeStatus := b.index.NodeStatus(node)
+		if nodeStatus.KnownInvalid() {
+			return StatusInvalid
+		}
+		if !nodeStatus.HaveData() {
+			status = StatusHeadersOnly
+		}
+	}
+	return status
+}
+
 // HeaderByHash returns the block header identified by the given hash or an
 // error if it doesn't exist. Note that this will return headers from both the
 // main and side chains.
@@ -1460,6 +1631,16 @@ func (b *BlockChain) MainChainHasBlock(hash *chainhash.Hash) bool {
 	return node != nil && b.bestChain.Contains(node)
 }
 
+// IsKnownInvalid returns whether or not the block with the given hash is known
+// to be invalid, either because it failed validation itself or because one of
+// its ancestors did.  Unknown blocks are not considered invalid.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsKnownInvalid(hash *chainhash.Hash) bool {
+	node := b.index.LookupNode(hash)
+	return node != nil && b.index.NodeStatus(node).KnownInvalid()
+}
+
 // BlockLocatorFromHash returns a block locator for the passed block hash.
 // See BlockLocator for details on the algorithm used to create a block locator.
 //
@@ -1501,6 +1682,33 @@ func (b *BlockChain) BlockHeightByHash(hash *chainhash.Hash) (int32, error) {
 	return node.height, nil
 }
 
+// ChainWork returns the cumulative work of the chain ending at the block with
+// the given hash, which doesn't need to be in the main chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainWork(hash *chainhash.Hash) (*big.Int, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return nil, fmt.Errorf("block %s is not known", hash)
+	}
+
+	return new(big.Int).Set(node.workSum), nil
+}
+
+// HeaderCtxByHash returns the context of the block header with the passed hash,
+// which allows headers which are not in the block index yet to be checked
+// contextually on top of it with CheckBlockHeaderContext.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) HeaderCtxByHash(hash *chainhash.Hash) (HeaderCtx, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return nil, fmt.Errorf("block %s is not known", hash)
+	}
+
+	return node, nil
+}
+
 // BlockHashByHeight returns the hash of the block at the given height in the
 // main chain.
 //
@@ -1564,6 +1772,48 @@ func (b *BlockChain) HeightRange(startHeight, endHeight int32) ([]chainhash.Hash
 	return hashes, nil
 }
 
+// HeaderRange returns a range of block headers of the main chain for the given
+// start and end heights.  It is inclusive of the start height and exclusive of
+// the end height.  The end height will be limited to the current main chain
+// height.  The headers are served from the block index, so no blocks are loaded
+// from the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) HeaderRange(startHeight, endHeight int32) ([]wire.BlockHeader, error) {
+	// Ensure requested heights are sane.
+	if startHeight < 0 {
+		return nil, fmt.Errorf("start height of fetch range must not "+
+			"be less than zero - got %d", startHeight)
+	}
+	if endHeight < startHeight {
+		return nil, fmt.Errorf("end height of fetch range must not "+
+			"be less than the start height - got start %d, end %d",
+			startHeight, endHeight)
+	}
+	if startHeight == endHeight {
+		return nil, nil
+	}
+
+	// Grab a lock on the chain view to prevent it from changing due to a
+	// reorg while building the headers.
+	b.bestChain.mtx.Lock()
+	defer b.bestChain.mtx.Unlock()
+
+	latestHeight := b.bestChain.tip().height
+	if startHeight > latestHeight {
+		return nil, nil
+	}
+	if endHeight > latestHeight+1 {
+		endHeight = latestHeight + 1
+	}
+
+	headers := make([]wire.BlockHeader, 0, endHeight-startHeight)
+	for i := startHeight; i < endHeight; i++ {
+		headers = append(headers, b.bestChain.nodeByHeight(i).Header())
+	}
+	return headers, nil
+}
+
 // HeightToHashRange returns a range of block hashes for the given start height
 // and end hash, inclusive on both ends.  The hashes are for all blocks that are
 // ancestors of endHash with height greater than or equal to startHeight.  The
@@ -1824,6 +2074,20 @@ func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
 		return nil
 	}
 
+	// Finalized blocks and their ancestors can't be invalidated.
+	if b.finalizedNode != nil && b.finalizedNode.Ancestor(node.height) == node {
+		return ruleError(ErrFinalizedFork, fmt.Sprintf("block %v is "+
+			"finalized and cannot be invalidated", node.hash))
+	}
+
+	// Neither can main chain blocks whose undo data was pruned since they
+	// can't be disconnected.
+	if node.height <= b.undoPrunedHeight && b.bestChain.Contains(node) {
+		return ruleError(ErrUndoDataPruned, fmt.Sprintf("the undo data "+
+			"of block %v was pruned so it cannot be invalidated",
+			node.hash))
+	}
+
 	// Set the status of the block being invalidated.
 	b.index.SetStatusFlags(node, statusValidateFailed)
 	b.index.UnsetStatusFlags(node, statusValid)
@@ -1914,7 +2178,7 @@ func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
 		} else {
 			// If there is an existing best tip, then compare it
 			// against the current tip.
-			if tip.workSum.Cmp(bestTip.workSum) == 1 {
+			if b.preferChain(tip, bestTip) {
 				bestTip = tip
 			}
 		}
@@ -2032,6 +2296,34 @@ type Config struct {
 	// will target for with block files.  Prune at 0 specifies that no
 	// blocks will be deleted.
 	Prune uint64
+
+	// PruneDepth specifies the number of most recent main chain blocks to
+	// keep when blocks are pruned by depth instead of by size, which
+	// limits the depth of reorganizations the chain can perform since the
+	// blocks to disconnect must be available.  It may not be combined with
+	// Prune.  PruneDepth at 0 specifies that blocks are not pruned by depth.
+	PruneDepth uint32
+
+	// UndoPruneDepth specifies the number of most recent main chain blocks
+	// whose spend journal entries, which are the undo data needed to
+	// disconnect them, are kept.  The entries of older blocks are removed
+	// as new blocks are connected and reorganizations that would
+	// disconnect them are rejected.  It must be at least MinUndoPruneDepth.
+	// UndoPruneDepth at 0 specifies that undo data is not pruned.
+	UndoPruneDepth uint32
+
+	// ScriptValidationWorkers specifies the maximum number of goroutines
+	// used to validate the scripts of a block.
+	//
+	// This field can be zero to use DefaultScriptValidationWorkers.
+	ScriptValidationWorkers int
+
+	// MaxReorgDepth specifies the maximum number of main chain blocks a
+	// reorganization may disconnect without confirmation.  Deeper
+	// reorganizations are paused until they are confirmed via ConfirmReorg.
+	//
+	// This field can be zero to allow reorganizations of any depth.
+	MaxReorgDepth int32
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -2046,6 +2338,14 @@ func New(config *Config) (*BlockChain, error) {
 	if config.TimeSource == nil {
 		return nil, AssertError("blockchain.New timesource is nil")
 	}
+	if config.Prune != 0 && config.PruneDepth != 0 {
+		return nil, AssertError("blockchain.New prune target and prune " +
+			"depth are mutually exclusive")
+	}
+	if config.UndoPruneDepth != 0 && config.UndoPruneDepth < MinUndoPruneDepth {
+		return nil, AssertError(fmt.Sprintf("blockchain.New undo prune "+
+			"depth must be at least %d", MinUndoPruneDepth))
+	}
 
 	// Generate a checkpoint by height map from the provided checkpoints
 	// and assert the provided checkpoints are sorted by height as required.
@@ -2082,13 +2382,22 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		utxoCache:           newUtxoCache(config.DB, config.UtxoCacheMaxSize),
+		verifyMemoryLimit:   config.UtxoCacheMaxSize,
 		hashCache:           config.HashCache,
+		scriptWorkers:       config.ScriptValidationWorkers,
+		maxReorgDepth:       config.MaxReorgDepth,
 		bestChain:           newChainView(nil),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
 		warningCaches:       newThresholdCaches(vbNumBits),
 		deploymentCaches:    newThresholdCaches(chaincfg.DefinedDeployments),
 		pruneTarget:         config.Prune,
+		pruneDepth:          int32(config.PruneDepth),
+		undoPruneDepth:      int32(config.UndoPruneDepth),
+		blockStatsCache:     newBlockStatsCache(blockStatsCacheSize),
+	}
+	if b.verifyMemoryLimit == 0 {
+		b.verifyMemoryLimit = defaultVerifyMemoryLimit
 	}
 
 	// Ensure all the deployments are synchronized with our clock if
@@ -2137,6 +2446,24 @@ func New(config *Config) (*BlockChain, error) {
 	if err := b.InitConsistentState(bestNode, config.Interrupt); err != nil {
 		return nil, err
 	}
+
+	// Load the statistics of the utxo set or calculate them if the
+	// database was created before they were maintained.
+	if err := b.initUtxoSetStats(config.Interrupt); err != nil {
+		return nil, err
+	}
+
+	// Load the finalized block, if any.
+	if err := b.initFinalizedBlock(); err != nil {
+		return nil, err
+	}
+
+	// Prune the undo data which fell behind the undo prune depth while the
+	// node wasn't running.  This must come after loading the finalized
+	// block since its ancestors are always pruned.
+	if err := b.initUndoPrune(config.Interrupt); err != nil {
+		return nil, err
+	}
 	log.Infof("Chain state (height %d, hash %v, totaltx %d, work %v)",
 		bestNode.height, bestNode.hash, b.stateSnapshot.TotalTxns,
 		bestNode.workSum)
@@ -804,6 +804,97 @@ func TestLocateInventory(t *testing.T) {
 	}
 }
 
+// TestHeaderRange ensures that fetching a range of main chain block headers by
+// start and end height works as expected.
+func TestHeaderRange(t *testing.T) {
+	// Construct a synthetic block chain with a block index consisting of
+	// the following structure.
+	// 	genesis -> 1 -> 2 -> ... -> 15 -> 16  -> 17  -> 18
+	// 	                              \-> 16a -> 17a
+	tip := tstTip
+	chain := newFakeChain(&chaincfg.MainNetParams)
+	branch0Nodes := chainedNodes(chain.bestChain.Genesis(), 18)
+	branch1Nodes := chainedNodes(branch0Nodes[14], 2)
+	for _, node := range branch0Nodes {
+		chain.index.AddNode(node)
+	}
+	for _, node := range branch1Nodes {
+		chain.index.AddNode(node)
+	}
+	chain.bestChain.SetTip(tip(branch0Nodes))
+
+	tests := []struct {
+		name        string
+		startHeight int32              // start of requested range
+		endHeight   int32              // end of requested range
+		headers     []wire.BlockHeader // expected headers
+		expectError bool
+	}{
+		{
+			name:        "headers below tip",
+			startHeight: 11,
+			endHeight:   15,
+			headers:     nodeHeaders(branch0Nodes, 10, 11, 12, 13),
+		},
+		{
+			name:        "headers clipped to tip",
+			startHeight: 16,
+			endHeight:   100,
+			headers:     nodeHeaders(branch0Nodes, 15, 16, 17),
+		},
+		{
+			name:        "genesis header",
+			startHeight: 0,
+			endHeight:   2,
+			headers: append([]wire.BlockHeader{
+				chain.bestChain.Genesis().Header(),
+			}, nodeHeaders(branch0Nodes, 0)...),
+		},
+		{
+			name:        "empty range",
+			startHeight: 5,
+			endHeight:   5,
+		},
+		{
+			name:        "start beyond tip",
+			startHeight: 19,
+			endHeight:   25,
+		},
+		{
+			name:        "negative start height",
+			startHeight: -1,
+			endHeight:   5,
+			expectError: true,
+		},
+		{
+			name:        "end before start",
+			startHeight: 10,
+			endHeight:   5,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		headers, err := chain.HeaderRange(test.startHeight, test.endHeight)
+		if err != nil {
+			if !test.expectError {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if test.expectError {
+			t.Errorf("%s: did not receive expected error", test.name)
+			continue
+		}
+
+		if len(headers) != len(test.headers) ||
+			(len(headers) > 0 && !reflect.DeepEqual(headers, test.headers)) {
+
+			t.Errorf("%s: unexpected headers -- got %v, want %v",
+				test.name, headers, test.headers)
+		}
+	}
+}
+
 // TestHeightToHashRange ensures that fetching a range of block hashes by start
 // height and end hash works as expected.
 func TestHeightToHashRange(t *testing.T) {
@@ -1002,12 +1093,12 @@ func TestChainTips(t *testing.T) {
 			},
 		},
 		{
-			name: "one active chain tip, one unknown chain tip",
+			name: "one active chain tip, one headers-only chain tip",
 			chainTipGen: func() (*BlockChain, map[chainhash.Hash]ChainTip) {
 				// Construct a synthetic block chain with a block index consisting of
 				// the following structure.
 				// 	genesis -> 1 -> 2 -> 3 ... -> 10 -> 11  -> 12  -> 13 (active)
-				//                                      \-> 11a -> 12a (unknown)
+				//                                      \-> 11a -> 12a (headers-only)
 				tip := tstTip
 				chain := newFakeChain(&chaincfg.MainNetParams)
 				branch0Nodes := chainedNodes(chain.bestChain.Genesis(), 13)
@@ -1029,15 +1120,15 @@ func TestChainTips(t *testing.T) {
 					BranchLen: 0,
 					Status:    StatusActive,
 				}
-				unknownTip := ChainTip{
+				headersOnlyTip := ChainTip{
 					Height:    12,
 					BlockHash: (tip(branch1Nodes)).hash,
 					BranchLen: 2,
-					Status:    StatusUnknown,
+					Status:    StatusHeadersOnly,
 				}
 				chainTips := make(map[chainhash.Hash]ChainTip)
 				chainTips[activeTip.BlockHash] = activeTip
-				chainTips[unknownTip.BlockHash] = unknownTip
+				chainTips[headersOnlyTip.BlockHash] = headersOnlyTip
 
 				return chain, chainTips
 			},
@@ -1100,6 +1191,60 @@ func TestChainTips(t *testing.T) {
 				chainTips[inactiveTip.BlockHash] = inactiveTip
 				chainTips[invalidTip.BlockHash] = invalidTip
 
+				return chain, chainTips
+			},
+		},
+		{
+			name: "branch status depends on all blocks of the branch",
+			chainTipGen: func() (*BlockChain, map[chainhash.Hash]ChainTip) {
+				// Construct a synthetic block chain with a block index consisting of
+				// the following structure.
+				// 	genesis -> 1  -> 2  -> 3 (active)
+				//            \ -> 1a -> 2a -> 3a (invalid since 1a is invalid)
+				//            \ -> 1b -> 2b (headers-only since 1b has no data)
+				tip := tstTip
+				chain := newFakeChain(&chaincfg.MainNetParams)
+				branch0Nodes := chainedNodes(chain.bestChain.Genesis(), 3)
+				for _, node := range branch0Nodes {
+					chain.index.SetStatusFlags(node, statusDataStored)
+					chain.index.SetStatusFlags(node, statusValid)
+					chain.index.AddNode(node)
+				}
+				chain.bestChain.SetTip(tip(branch0Nodes))
+
+				branch1Nodes := chainedNodes(chain.bestChain.Genesis(), 3)
+				for _, node := range branch1Nodes {
+					chain.index.SetStatusFlags(node, statusDataStored)
+					chain.index.AddNode(node)
+				}
+				chain.index.SetStatusFlags(branch1Nodes[0], statusValidateFailed)
+
+				branch2Nodes := chainedNodes(chain.bestChain.Genesis(), 2)
+				for _, node := range branch2Nodes {
+					chain.index.AddNode(node)
+				}
+				chain.index.SetStatusFlags(branch2Nodes[1], statusDataStored)
+
+				chainTips := make(map[chainhash.Hash]ChainTip)
+				for _, chainTip := range []ChainTip{{
+					Height:    3,
+					BlockHash: tip(branch0Nodes).hash,
+					BranchLen: 0,
+					Status:    StatusActive,
+				}, {
+					Height:    3,
+					BlockHash: tip(branch1Nodes).hash,
+					BranchLen: 3,
+					Status:    StatusInvalid,
+				}, {
+					Height:    2,
+					BlockHash: tip(branch2Nodes).hash,
+					BranchLen: 2,
+					Status:    StatusHeadersOnly,
+				}} {
+					chainTips[chainTip.BlockHash] = chainTip
+				}
+
 				return chain, chainTips
 			},
 		},
@@ -1113,6 +1258,13 @@ func TestChainTips(t *testing.T) {
 				"chain tips, got %d", test.name, len(expectedChainTips), len(gotChainTips))
 		}
 
+		for i := 1; i < len(gotChainTips); i++ {
+			if gotChainTips[i].Height > gotChainTips[i-1].Height {
+				t.Errorf("TestChainTips Failed test %s. Chain tips "+
+					"are not ordered by descending height", test.name)
+			}
+		}
+
 		for _, gotChainTip := range gotChainTips {
 			testChainTip, found := expectedChainTips[gotChainTip.BlockHash]
 			if !found {
@@ -1148,6 +1300,11 @@ func TestChainTips(t *testing.T) {
 					t.Errorf("TestChainTips Fail: Expected string of \"valid-fork\", got \"%s\"",
 						testChainTip.Status.String())
 				}
+			case StatusHeadersOnly:
+				if testChainTip.Status.String() != "headers-only" {
+					t.Errorf("TestChainTips Fail: Expected string of \"headers-only\", got \"%s\"",
+						testChainTip.Status.String())
+				}
 			case StatusUnknown:
 				if testChainTip.Status.String() != fmt.Sprintf("unknown: %b", testChainTip.Status) {
 					t.Errorf("TestChainTips Fail: Expected string of \"unknown\", got \"%s\"",
@@ -1314,6 +1471,52 @@ func TestIsAncestor(t *testing.T) {
 	}
 }
 
+// TestIsKnownInvalid ensures blocks which failed validation and their
+// descendants are reported as known invalid while valid and unknown blocks are
+// not.
+func TestIsKnownInvalid(t *testing.T) {
+	// Construct a synthetic block chain with a block index consisting of
+	// the following structure.
+	// 	genesis -> 1  -> 2 (active)
+	//            \ -> 1a (invalid) -> 2a (invalid ancestor)
+	tip := tstTip
+	chain := newFakeChain(&chaincfg.MainNetParams)
+	branch0Nodes := chainedNodes(chain.bestChain.Genesis(), 2)
+	for _, node := range branch0Nodes {
+		chain.index.SetStatusFlags(node, statusDataStored)
+		chain.index.SetStatusFlags(node, statusValid)
+		chain.index.AddNode(node)
+	}
+	chain.bestChain.SetTip(tip(branch0Nodes))
+
+	branch1Nodes := chainedNodes(chain.bestChain.Genesis(), 2)
+	for _, node := range branch1Nodes {
+		chain.index.SetStatusFlags(node, statusDataStored)
+		chain.index.AddNode(node)
+	}
+	chain.index.SetStatusFlags(branch1Nodes[0], statusValidateFailed)
+	chain.index.SetStatusFlags(branch1Nodes[1], statusInvalidAncestor)
+
+	unknown := chainedNodes(chain.bestChain.Genesis(), 1)[0]
+	tests := []struct {
+		name string
+		hash *chainhash.Hash
+		want bool
+	}{
+		{"genesis", &chain.bestChain.Genesis().hash, false},
+		{"main chain tip", &branch0Nodes[1].hash, false},
+		{"failed validation", &branch1Nodes[0].hash, true},
+		{"invalid ancestor", &branch1Nodes[1].hash, true},
+		{"unknown", &unknown.hash, false},
+	}
+	for _, test := range tests {
+		if got := chain.IsKnownInvalid(test.hash); got != test.want {
+			t.Errorf("%s: IsKnownInvalid: got %v, want %v",
+				test.name, got, test.want)
+		}
+	}
+}
+
 // randomSelect selects random amount of random elements from a slice and returns a
 // new slice.  The selected elements are removed.
 func randomSelect(input []*testhelper.SpendableOut) (
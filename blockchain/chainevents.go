@@ -0,0 +1,125 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ChainEventType represents the type of a canonical chain event.
+type ChainEventType int
+
+// Constants for the type of a chain event.
+const (
+	// ChainEventBlockConnected indicates the associated block was
+	// connected to the main chain.
+	ChainEventBlockConnected ChainEventType = iota
+
+	// ChainEventBlockDisconnected indicates the associated block was
+	// disconnected from the main chain.
+	ChainEventBlockDisconnected
+
+	// ChainEventFinalized indicates the associated block can no longer be
+	// reorganized out of the main chain, such as when it matches a
+	// checkpoint.
+	ChainEventFinalized
+)
+
+// chainEventTypeStrings is a map of chain event types back to their constant
+// names for pretty printing.
+var chainEventTypeStrings = map[ChainEventType]string{
+	ChainEventBlockConnected:    "ChainEventBlockConnected",
+	ChainEventBlockDisconnected: "ChainEventBlockDisconnected",
+	ChainEventFinalized:         "ChainEventFinalized",
+}
+
+// String returns the ChainEventType in human-readable form.
+func (t ChainEventType) String() string {
+	if s, ok := chainEventTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Chain Event Type (%d)", int(t))
+}
+
+// ChainEvent describes a change to the main chain.  Events are delivered in
+// the order the changes are made, so applying them in sequence reproduces the
+// main chain without the need to query the chain.
+type ChainEvent struct {
+	// Type is the type of the event.
+	Type ChainEventType
+
+	// Sequence is a number that increases by one with every event sent by
+	// the chain instance.  Consumers can use it to detect missed events.
+	Sequence uint64
+
+	// Hash and Height identify the block the event is about.
+	Hash   chainhash.Hash
+	Height int32
+
+	// Block is the block that was connected or disconnected.  It is nil
+	// for finalization events.
+	Block *btcutil.Block
+
+	// SpentTxOuts houses the undo data of the block, which consists of an
+	// entry for every output spent by the block in the order the
+	// transactions spend them.  It is nil for finalization events.
+	SpentTxOuts []SpentTxOut
+}
+
+// ChainEventCallback is used for a caller to provide a callback for canonical
+// chain events.
+type ChainEventCallback func(*ChainEvent)
+
+// SubscribeChainEvents registers a callback to be executed with every change
+// made to the main chain.  Unlike the notifications registered via Subscribe,
+// the events include the undo data of the blocks and finalization events, and
+// they carry sequence numbers, which makes them suitable for driving an
+// external consensus or execution layer.
+//
+// The callback is executed synchronously while the chain waits, so it must
+// not block for long periods of time and must not call back into the chain
+// functions that modify the main chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SubscribeChainEvents(callback ChainEventCallback) {
+	b.chainEventsLock.Lock()
+	b.chainEvents = append(b.chainEvents, callback)
+	b.chainEventsLock.Unlock()
+}
+
+// sendChainEvent sends a chain event with the passed type for the passed node
+// to all subscribers.
+func (b *BlockChain) sendChainEvent(typ ChainEventType, node *blockNode,
+	block *btcutil.Block, stxos []SpentTxOut) {
+
+	b.chainEventsLock.Lock()
+	defer b.chainEventsLock.Unlock()
+
+	b.chainEventSeq++
+	if len(b.chainEvents) == 0 {
+		return
+	}
+	event := ChainEvent{
+		Type:        typ,
+		Sequence:    b.chainEventSeq,
+		Hash:        node.hash,
+		Height:      node.height,
+		Block:       block,
+		SpentTxOuts: stxos,
+	}
+	for _, callback := range b.chainEvents {
+		callback(&event)
+	}
+}
+
+// isCheckpointNode returns whether or not the passed node matches one of the
+// checkpoints of the chain.
+func (b *BlockChain) isCheckpointNode(node *blockNode) bool {
+	checkpoint, ok := b.checkpointsByHeight[node.height]
+	return ok && checkpoint.Hash.IsEqual(&node.hash)
+}
@@ -55,6 +55,10 @@ var (
 	// consistency status of the utxo state.
 	utxoStateConsistencyKeyName = []byte("utxostateconsistency")
 
+	// utxoFlushEpochKeyName is the name of the db key used to store the
+	// flush epoch of the utxo state.
+	utxoFlushEpochKeyName = []byte("utxoflushepoch")
+
 	// spendJournalVersionKeyName is the name of the db key used to store
 	// the version of the spend journal currently in the database.
 	spendJournalVersionKeyName = []byte("spendjournalversion")
@@ -267,6 +271,13 @@ func (b *BlockChain) FetchSpendJournal(targetBlock *btcutil.Block) ([]SpentTxOut
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
+	node := b.index.LookupNode(targetBlock.Hash())
+	if node != nil && b.bestChain.Contains(node) {
+		if err := b.checkUndoDataAvailable(node); err != nil {
+			return nil, err
+		}
+	}
+
 	var spendEntries []SpentTxOut
 	err := b.db.View(func(dbTx database.Tx) error {
 		var err error
@@ -926,6 +937,21 @@ func dbFetchHeightByHash(dbTx database.Tx, hash *chainhash.Hash) (int32, error)
 	return int32(byteOrder.Uint32(serializedHeight)), nil
 }
 
+// DBFetchMainChainHash uses an existing database transaction to retrieve the
+// hash of the main chain block at the provided height.  The main chain is
+// updated in the same database transactions blocks are connected and
+// disconnected in, so callers which update the database alongside the chain,
+// such as the optional indexes, can use it to determine the main chain as of
+// their own transaction.  False is returned when there is no main chain block at
+// the height.
+func DBFetchMainChainHash(dbTx database.Tx, height int32) (*chainhash.Hash, bool) {
+	hash, err := dbFetchHashByHeight(dbTx, height)
+	if err != nil {
+		return nil, false
+	}
+	return hash, true
+}
+
 // dbFetchHashByHeight uses an existing database transaction to retrieve the
 // hash for the provided height from the index.
 func dbFetchHashByHeight(dbTx database.Tx, height int32) (*chainhash.Hash, error) {
@@ -1046,19 +1072,65 @@ func dbPutBestState(dbTx database.Tx, snapshot *BestState, workSum *big.Int) err
 	return dbTx.Metadata().Put(chainStateKeyName, serializedData)
 }
 
+// -----------------------------------------------------------------------------
+// The utxo state consistency status records the hash of the block the utxo set
+// in the database is consistent with.
+//
+// The flush epoch, which is a counter that is incremented every time the utxo
+// cache is flushed to the database, is stored under a separate key as a
+// uint64, so the consistency status keeps the format older versions expect.
+// Databases created before the epoch was introduced don't have the key, which
+// is treated as epoch 0.
+// -----------------------------------------------------------------------------
+
 // dbPutUtxoStateConsistency uses an existing database transaction to
-// update the utxo state consistency status with the given parameters.
-func dbPutUtxoStateConsistency(dbTx database.Tx, hash *chainhash.Hash) error {
+// update the utxo state consistency status and the flush epoch with the given
+// parameters.
+func dbPutUtxoStateConsistency(dbTx database.Tx, hash *chainhash.Hash,
+	epoch uint64) error {
+
 	// Store the utxo state consistency status into the database.
-	return dbTx.Metadata().Put(utxoStateConsistencyKeyName, hash[:])
+	err := dbTx.Metadata().Put(utxoStateConsistencyKeyName, hash[:])
+	if err != nil {
+		return err
+	}
+
+	var serialized [8]byte
+	byteOrder.PutUint64(serialized[:], epoch)
+	return dbTx.Metadata().Put(utxoFlushEpochKeyName, serialized[:])
 }
 
 // dbFetchUtxoStateConsistency uses an existing database transaction to retrieve
-// the utxo state consistency status from the database.  The code is 0 when
-// nothing was found.
-func dbFetchUtxoStateConsistency(dbTx database.Tx) []byte {
+// the utxo state consistency status and the flush epoch from the database.  The
+// returned hash is nil when nothing was found.
+func dbFetchUtxoStateConsistency(dbTx database.Tx) (*chainhash.Hash, uint64, error) {
 	// Fetch the serialized data from the database.
-	return dbTx.Metadata().Get(utxoStateConsistencyKeyName)
+	serialized := dbTx.Metadata().Get(utxoStateConsistencyKeyName)
+	if serialized == nil {
+		return nil, 0, nil
+	}
+	if len(serialized) != chainhash.HashSize {
+		return nil, 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo state consistency status",
+		}
+	}
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+
+	var epoch uint64
+	serialized = dbTx.Metadata().Get(utxoFlushEpochKeyName)
+	switch len(serialized) {
+	case 0:
+	case 8:
+		epoch = byteOrder.Uint64(serialized)
+	default:
+		return nil, 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo flush epoch",
+		}
+	}
+	return &hash, epoch, nil
 }
 
 // createChainState initializes both the database and the chain state to the
@@ -1189,6 +1261,25 @@ func (b *BlockChain) initChainState() error {
 		}
 	}
 
+	// The utxo set holds the coins of a partially loaded utxo snapshot when
+	// the node was shut down while loading it.  Remove them so the chain
+	// continues from the genesis block.
+	err = b.db.Update(func(dbTx database.Tx) error {
+		snapState, err := dbFetchUtxoSnapshotState(dbTx)
+		if err != nil || snapState == nil ||
+			snapState.state != snapshotLoading {
+
+			return err
+		}
+		log.Warnf("Loading the utxo snapshot at block %v was "+
+			"interrupted -- removing the partially loaded coins",
+			snapState.baseHash)
+		return dbRemovePartialUtxoSnapshot(dbTx)
+	})
+	if err != nil {
+		return err
+	}
+
 	// Attempt to load the chain state from the database.
 	err = b.db.View(func(dbTx database.Tx) error {
 		// Fetch the stored chain state from the database metadata.
@@ -0,0 +1,420 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// coreBlockFilePattern is the glob pattern that matches the block
+	// files written by Bitcoin Core.
+	coreBlockFilePattern = "blk[0-9][0-9][0-9][0-9][0-9].dat"
+
+	// coreXorKeyFileName is the name of the file in Bitcoin Core's blocks
+	// directory that houses the key used to obfuscate the block files.
+	coreXorKeyFileName = "xor.dat"
+
+	// coreXorKeySize is the size of the key used to obfuscate Bitcoin
+	// Core's block files.
+	coreXorKeySize = 8
+)
+
+// coreBlockLoc identifies a block within one of Bitcoin Core's block files.
+type coreBlockLoc struct {
+	file   *coreBlockFile
+	offset int64
+	size   uint32
+	prev   chainhash.Hash
+	bits   uint32
+}
+
+// coreBlockFile provides access to a Bitcoin Core block file while transparently
+// removing the obfuscation applied by newer versions of Core.
+type coreBlockFile struct {
+	path string
+	xor  []byte
+	file *os.File
+}
+
+// open returns a handle to the file, opening it when needed.
+func (f *coreBlockFile) open() (*os.File, error) {
+	if f.file == nil {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil, err
+		}
+		f.file = file
+	}
+	return f.file, nil
+}
+
+// close closes the file if it is open.
+func (f *coreBlockFile) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// readAt reads len(buf) bytes from the file starting at the passed offset and
+// removes any obfuscation from them.
+func (f *coreBlockFile) readAt(file io.ReaderAt, buf []byte, offset int64) error {
+	if _, err := file.ReadAt(buf, offset); err != nil {
+		return err
+	}
+	if len(f.xor) != 0 {
+		for i := range buf {
+			buf[i] ^= f.xor[(offset+int64(i))%coreXorKeySize]
+		}
+	}
+	return nil
+}
+
+// coreBlockIndex houses the locations of all blocks found in a set of Bitcoin
+// Core block files keyed by their hash along with the hashes of the blocks
+// that build on each block.
+type coreBlockIndex struct {
+	locs     map[chainhash.Hash]coreBlockLoc
+	children map[chainhash.Hash][]chainhash.Hash
+}
+
+// readCoreXorKey returns the key used to obfuscate the block files in the
+// passed directory or nil if they are not obfuscated.
+func readCoreXorKey(dir string) ([]byte, error) {
+	key, err := os.ReadFile(filepath.Join(dir, coreXorKeyFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != coreXorKeySize {
+		return nil, fmt.Errorf("%s has a size of %d bytes instead of %d",
+			coreXorKeyFileName, len(key), coreXorKeySize)
+	}
+	if bytes.Equal(key, make([]byte, coreXorKeySize)) {
+		return nil, nil
+	}
+	return key, nil
+}
+
+// scanCoreBlockFile adds the location of every block in the passed file to the
+// index after ensuring its header has valid proof of work.  Bitcoin Core
+// preallocates block files, so a zero network magic marks the end of the data
+// in the file.
+func (b *BlockChain) scanCoreBlockFile(f *coreBlockFile, idx *coreBlockIndex) error {
+	file, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer f.close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := fi.Size()
+
+	// The block file format is:
+	//  <network> <block length> <serialized block>
+	var frame [8]byte
+	var headerBytes [wire.MaxBlockHeaderPayload]byte
+	var offset int64
+	for offset+int64(len(frame)) <= fileSize {
+		if err := f.readAt(file, frame[:], offset); err != nil {
+			return err
+		}
+		net := binary.LittleEndian.Uint32(frame[0:4])
+		if net == 0 {
+			break
+		}
+		if net != uint32(b.chainParams.Net) {
+			return fmt.Errorf("%s: network mismatch at offset %d -- "+
+				"got %x, want %x", f.path, offset, net,
+				uint32(b.chainParams.Net))
+		}
+
+		blockLen := binary.LittleEndian.Uint32(frame[4:8])
+		blockOffset := offset + int64(len(frame))
+		if blockLen < wire.MaxBlockHeaderPayload ||
+			blockLen > wire.MaxBlockPayload ||
+			blockOffset+int64(blockLen) > fileSize {
+
+			// A truncated final block is expected when Core was not
+			// shut down cleanly, so just stop scanning the file.
+			log.Warnf("Ignoring truncated block at offset %d of %s",
+				offset, f.path)
+			break
+		}
+
+		err := f.readAt(file, headerBytes[:], blockOffset)
+		if err != nil {
+			return err
+		}
+		var header wire.BlockHeader
+		err = header.Deserialize(bytes.NewReader(headerBytes[:]))
+		if err != nil {
+			return err
+		}
+		err = checkProofOfWork(&header, b.chainParams.PowLimit, BFNone)
+		if err != nil {
+			return fmt.Errorf("%s: block at offset %d: %v", f.path,
+				offset, err)
+		}
+
+		hash := header.BlockHash()
+		if _, ok := idx.locs[hash]; !ok {
+			idx.locs[hash] = coreBlockLoc{
+				file:   f,
+				offset: blockOffset,
+				size:   blockLen,
+				prev:   header.PrevBlock,
+				bits:   header.Bits,
+			}
+			idx.children[header.PrevBlock] = append(
+				idx.children[header.PrevBlock], hash)
+		}
+
+		offset = blockOffset + int64(blockLen)
+	}
+
+	return nil
+}
+
+// readCoreBlock reads and deserializes the block at the passed location.
+func readCoreBlock(loc coreBlockLoc) (*btcutil.Block, error) {
+	file, err := loc.file.open()
+	if err != nil {
+		return nil, err
+	}
+
+	serializedBlock := make([]byte, loc.size)
+	if err := loc.file.readAt(file, serializedBlock, loc.offset); err != nil {
+		return nil, err
+	}
+	return btcutil.NewBlockFromBytes(serializedBlock)
+}
+
+// coreBestChain returns the set of blocks in the passed index along the chain
+// with the most cumulative proof of work that builds on one of the passed
+// blocks, all of which must have a parent that is already known.  This is the
+// chain the import is connecting while the other blocks that build on the roots
+// are stale or otherwise belong to side chains.
+func (b *BlockChain) coreBestChain(idx *coreBlockIndex,
+	roots []chainhash.Hash) map[chainhash.Hash]struct{} {
+
+	// Walk the tree of blocks rooted at the passed blocks to find the tip
+	// with the most cumulative work.
+	work := make(map[chainhash.Hash]*big.Int)
+	var bestTip chainhash.Hash
+	var bestWork *big.Int
+	pending := append([]chainhash.Hash(nil), roots...)
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		loc := idx.locs[hash]
+		parentWork, ok := work[loc.prev]
+		if !ok {
+			parentWork = new(big.Int)
+			if node := b.index.LookupNode(&loc.prev); node != nil {
+				parentWork = node.workSum
+			}
+		}
+		blockWork := new(big.Int).Add(parentWork, CalcWork(loc.bits))
+		work[hash] = blockWork
+		if bestWork == nil || blockWork.Cmp(bestWork) > 0 {
+			bestTip, bestWork = hash, blockWork
+		}
+
+		pending = append(pending, idx.children[hash]...)
+	}
+
+	bestChain := make(map[chainhash.Hash]struct{})
+	if bestWork == nil {
+		return bestChain
+	}
+	for hash := bestTip; ; hash = idx.locs[hash].prev {
+		if _, ok := work[hash]; !ok {
+			break
+		}
+		bestChain[hash] = struct{}{}
+	}
+	return bestChain
+}
+
+// ImportBlocksFromCore imports the blocks contained in the blk*.dat files of a
+// Bitcoin Core blocks directory into the chain.  Core stores blocks in the order
+// they were received rather than by height, so the files are first scanned to
+// build an index of the blocks they contain while checking the proof of work of
+// each header.  The blocks that connect to the chain are then processed in
+// order through ProcessBlock with the passed behavior flags, which stores them
+// in the database and connects them to the best chain as usual.  Block files
+// obfuscated with the key in Core's xor.dat are supported.
+//
+// Blocks that are already known are skipped and blocks that don't connect to
+// any known block are ignored.  Core also keeps the stale blocks it received,
+// so blocks that are rejected by the consensus rules are logged and skipped
+// along with their descendants unless they are part of the chain with the most
+// proof of work in the files, in which case the import fails.  The import stops
+// early without error when the interrupt channel is closed.  The number of
+// imported blocks is returned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ImportBlocksFromCore(dir string, flags BehaviorFlags,
+	interrupt <-chan struct{}) (int, error) {
+
+	paths, err := filepath.Glob(filepath.Join(dir, coreBlockFilePattern))
+	if err != nil {
+		return 0, err
+	}
+	if len(paths) == 0 {
+		return 0, fmt.Errorf("no block files found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	xorKey, err := readCoreXorKey(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	// Index the locations of all of the blocks in the files.
+	idx := &coreBlockIndex{
+		locs:     make(map[chainhash.Hash]coreBlockLoc),
+		children: make(map[chainhash.Hash][]chainhash.Hash),
+	}
+	files := make([]*coreBlockFile, 0, len(paths))
+	defer func() {
+		for _, f := range files {
+			f.close()
+		}
+	}()
+	for _, path := range paths {
+		select {
+		case <-interrupt:
+			return 0, nil
+		default:
+		}
+
+		log.Infof("Scanning block file %s", path)
+		f := &coreBlockFile{path: path, xor: xorKey}
+		files = append(files, f)
+		if err := b.scanCoreBlockFile(f, idx); err != nil {
+			return 0, err
+		}
+	}
+	log.Infof("Found %d blocks in %d block files", len(idx.locs),
+		len(paths))
+
+	// Start with all of the blocks that build on a block that is already
+	// known, sorted so the import is deterministic.
+	var queue []chainhash.Hash
+	for parent, children := range idx.children {
+		exists, err := b.HaveBlock(&parent)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			continue
+		}
+		for _, child := range children {
+			exists, err := b.HaveBlock(&child)
+			if err != nil {
+				return 0, err
+			}
+			if !exists {
+				queue = append(queue, child)
+			}
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return bytes.Compare(queue[i][:], queue[j][:]) < 0
+	})
+	bestChain := b.coreBestChain(idx, queue)
+
+	// Process the blocks in an order that ensures the parent of every
+	// block is processed before it.
+	var numImported int
+	var lastFile *coreBlockFile
+	lastLogTime := time.Now()
+	for len(queue) > 0 {
+		select {
+		case <-interrupt:
+			return numImported, nil
+		default:
+		}
+
+		hash := queue[0]
+		queue = queue[1:]
+
+		// Only keep a single block file open at a time since blocks
+		// are typically stored close to the order they are processed.
+		loc := idx.locs[hash]
+		if lastFile != nil && lastFile != loc.file {
+			lastFile.close()
+		}
+		lastFile = loc.file
+
+		block, err := readCoreBlock(loc)
+		if err != nil {
+			return numImported, err
+		}
+		if !block.Hash().IsEqual(&hash) {
+			return numImported, fmt.Errorf("block at offset %d of "+
+				"%s changed while importing", loc.offset,
+				loc.file.path)
+		}
+
+		_, isOrphan, err := b.ProcessBlock(block, flags)
+		if err != nil {
+			rErr, ok := err.(RuleError)
+			if ok && rErr.ErrorCode == ErrDuplicateBlock {
+				continue
+			}
+
+			// Don't let an invalid stale block stop the import
+			// since its descendants are never processed anyway.
+			if _, connecting := bestChain[hash]; ok && !connecting {
+				log.Warnf("Skipping side chain block %v: %v",
+					hash, err)
+				delete(idx.locs, hash)
+				continue
+			}
+			return numImported, fmt.Errorf("failed to process "+
+				"block %v: %v", hash, err)
+		}
+		if isOrphan {
+			return numImported, fmt.Errorf("block %v is unexpectedly "+
+				"an orphan", hash)
+		}
+		numImported++
+
+		queue = append(queue, idx.children[hash]...)
+		delete(idx.locs, hash)
+
+		if now := time.Now(); now.Sub(lastLogTime) > 10*time.Second {
+			best := b.BestSnapshot()
+			log.Infof("Imported %d blocks from Bitcoin Core (best "+
+				"height %d, %s)", numImported, best.Height,
+				best.MedianTime)
+			lastLogTime = now
+		}
+	}
+
+	return numImported, nil
+}
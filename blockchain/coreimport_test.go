@@ -0,0 +1,189 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain/internal/testhelper"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// writeCoreBlockFile writes the passed blocks to a file using Bitcoin Core's
+// block file framing and obfuscation followed by the zero padding Core leaves
+// at the end of preallocated files.
+func writeCoreBlockFile(t *testing.T, path string, net wire.BitcoinNet,
+	xorKey []byte, blocks []*btcutil.Block) {
+
+	var buf bytes.Buffer
+	for _, block := range blocks {
+		serialized, err := block.Bytes()
+		if err != nil {
+			t.Fatalf("unable to serialize block: %v", err)
+		}
+		var frame [8]byte
+		binary.LittleEndian.PutUint32(frame[0:4], uint32(net))
+		binary.LittleEndian.PutUint32(frame[4:8], uint32(len(serialized)))
+		buf.Write(frame[:])
+		buf.Write(serialized)
+	}
+	buf.Write(make([]byte, 1024))
+
+	data := buf.Bytes()
+	for i := range data {
+		data[i] ^= xorKey[i%len(xorKey)]
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("unable to write block file: %v", err)
+	}
+}
+
+// TestImportBlocksFromCore ensures blocks stored out of order across multiple
+// obfuscated Bitcoin Core block files are imported into the chain.
+func TestImportBlocksFromCore(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	// Write the blocks after the genesis block in reverse order across two
+	// files to ensure they're imported in the correct order regardless of
+	// the order they're stored in.
+	dir := t.TempDir()
+	xorKey := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	err = os.WriteFile(filepath.Join(dir, coreXorKeyFileName), xorKey, 0600)
+	if err != nil {
+		t.Fatalf("unable to write xor key: %v", err)
+	}
+	writeCoreBlockFile(t, filepath.Join(dir, "blk00000.dat"),
+		chaincfg.MainNetParams.Net, xorKey,
+		[]*btcutil.Block{blocks[4], blocks[3]})
+	writeCoreBlockFile(t, filepath.Join(dir, "blk00001.dat"),
+		chaincfg.MainNetParams.Net, xorKey,
+		[]*btcutil.Block{blocks[2], blocks[1], blocks[0]})
+
+	chain, teardownFunc, err := chainSetup("importcore",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	numImported, err := chain.ImportBlocksFromCore(dir, BFNone, nil)
+	if err != nil {
+		t.Fatalf("ImportBlocksFromCore: unexpected error: %v", err)
+	}
+	if numImported != 4 {
+		t.Fatalf("ImportBlocksFromCore: imported %d blocks, want 4",
+			numImported)
+	}
+	best := chain.BestSnapshot()
+	if best.Height != 4 || best.Hash != *blocks[4].Hash() {
+		t.Fatalf("unexpected best block after import: got %v (%d), "+
+			"want %v (4)", best.Hash, best.Height, blocks[4].Hash())
+	}
+
+	// Importing again must not import anything since all of the blocks are
+	// already known.
+	numImported, err = chain.ImportBlocksFromCore(dir, BFNone, nil)
+	if err != nil {
+		t.Fatalf("ImportBlocksFromCore: unexpected error: %v", err)
+	}
+	if numImported != 0 {
+		t.Fatalf("ImportBlocksFromCore: imported %d blocks on the "+
+			"second run, want 0", numImported)
+	}
+}
+
+// TestImportBlocksFromCoreStale ensures invalid stale blocks are skipped along
+// with their descendants while an invalid block along the chain with the most
+// work fails the import.
+func TestImportBlocksFromCoreStale(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain(
+		"TestImportBlocksFromCoreStale")
+	defer tearDown()
+
+	// Create a chain of four blocks along with a stale block that forks
+	// from the first one with a timestamp that isn't after the median time
+	// of the past blocks and a child of the stale block.
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	blocks := make([]*btcutil.Block, 0, 4)
+	prev := genesis
+	for i := 0; i < 4; i++ {
+		block, _, err := newBlock(chain, prev, nil)
+		if err != nil {
+			t.Fatalf("unable to create block: %v", err)
+		}
+		blocks = append(blocks, block)
+		prev = block
+	}
+	invalidBlock := func(prev *btcutil.Block) *btcutil.Block {
+		block, _, err := newBlock(chain, prev, nil)
+		if err != nil {
+			t.Fatalf("unable to create block: %v", err)
+		}
+		msgBlock := block.MsgBlock()
+		msgBlock.Header.Timestamp = params.GenesisBlock.Header.Timestamp
+		if !testhelper.SolveBlock(&msgBlock.Header) {
+			t.Fatalf("unable to solve block")
+		}
+		block = btcutil.NewBlock(msgBlock)
+		block.SetHeight(prev.Height() + 1)
+		return block
+	}
+	stale := invalidBlock(blocks[0])
+	staleChild, _, err := newBlock(chain, stale, nil)
+	if err != nil {
+		t.Fatalf("unable to create block: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeCoreBlockFile(t, filepath.Join(dir, "blk00000.dat"), params.Net,
+		[]byte{0}, []*btcutil.Block{blocks[0], staleChild, stale,
+			blocks[3], blocks[2], blocks[1]})
+
+	numImported, err := chain.ImportBlocksFromCore(dir, BFNone, nil)
+	if err != nil {
+		t.Fatalf("ImportBlocksFromCore: unexpected error: %v", err)
+	}
+	if numImported != 4 {
+		t.Fatalf("ImportBlocksFromCore: imported %d blocks, want 4",
+			numImported)
+	}
+	best := chain.BestSnapshot()
+	if best.Height != 4 || best.Hash != *blocks[3].Hash() {
+		t.Fatalf("unexpected best block after import: got %v (%d), "+
+			"want %v (4)", best.Hash, best.Height, blocks[3].Hash())
+	}
+	for _, block := range []*btcutil.Block{stale, staleChild} {
+		exists, err := chain.HaveBlock(block.Hash())
+		if err != nil {
+			t.Fatalf("HaveBlock: unexpected error: %v", err)
+		}
+		if exists {
+			t.Fatalf("stale block %v was imported", block.Hash())
+		}
+	}
+
+	// An invalid block that extends the best chain must fail the import.
+	dir = t.TempDir()
+	writeCoreBlockFile(t, filepath.Join(dir, "blk00000.dat"), params.Net,
+		[]byte{0}, []*btcutil.Block{invalidBlock(blocks[3])})
+	_, err = chain.ImportBlocksFromCore(dir, BFNone, nil)
+	if err == nil {
+		t.Fatal("ImportBlocksFromCore: imported an invalid block " +
+			"along the best chain")
+	}
+}
@@ -220,6 +220,20 @@ const (
 	// current chain tip. This is not a block validation rule, but is required
 	// for block proposals submitted via getblocktemplate RPC.
 	ErrPrevBlockNotBest
+
+	// ErrFinalizedFork indicates a block or reorganize would fork the main
+	// chain before a block that was finalized.
+	ErrFinalizedFork
+
+	// ErrBadSignetSolution indicates the block solution of a block on a
+	// signet network is missing, malformed, or doesn't satisfy the signet
+	// challenge.
+	ErrBadSignetSolution
+
+	// ErrUndoDataPruned indicates a block or reorganize would fork the
+	// main chain before a block whose undo data was pruned, so the blocks
+	// needed to be disconnected can't be.
+	ErrUndoDataPruned
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -267,6 +281,9 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrPreviousBlockUnknown:      "ErrPreviousBlockUnknown",
 	ErrInvalidAncestorBlock:      "ErrInvalidAncestorBlock",
 	ErrPrevBlockNotBest:          "ErrPrevBlockNotBest",
+	ErrFinalizedFork:             "ErrFinalizedFork",
+	ErrBadSignetSolution:         "ErrBadSignetSolution",
+	ErrUndoDataPruned:            "ErrUndoDataPruned",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -58,6 +58,9 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrPreviousBlockUnknown, "ErrPreviousBlockUnknown"},
 		{ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
 		{ErrPrevBlockNotBest, "ErrPrevBlockNotBest"},
+		{ErrFinalizedFork, "ErrFinalizedFork"},
+		{ErrBadSignetSolution, "ErrBadSignetSolution"},
+		{ErrUndoDataPruned, "ErrUndoDataPruned"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
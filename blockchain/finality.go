@@ -0,0 +1,137 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+var (
+	// finalizedBlockKeyName is the name of the db key used to store the
+	// hash of the most recent block that was finalized via FinalizeBlock.
+	finalizedBlockKeyName = []byte("finalizedblock")
+)
+
+// dbPutFinalizedBlock uses an existing database transaction to store the hash
+// of the finalized block.
+func dbPutFinalizedBlock(dbTx database.Tx, hash *chainhash.Hash) error {
+	return dbTx.Metadata().Put(finalizedBlockKeyName, hash[:])
+}
+
+// dbFetchFinalizedBlock uses an existing database transaction to fetch the
+// hash of the finalized block.  It returns nil when no block has been
+// finalized.
+func dbFetchFinalizedBlock(dbTx database.Tx) (*chainhash.Hash, error) {
+	serialized := dbTx.Metadata().Get(finalizedBlockKeyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	hash, err := chainhash.NewHash(serialized)
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt finalized block hash",
+		}
+	}
+	return hash, nil
+}
+
+// initFinalizedBlock loads the finalized block from the database.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) initFinalizedBlock() error {
+	var hash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		hash, err = dbFetchFinalizedBlock(dbTx)
+		return err
+	})
+	if err != nil || hash == nil {
+		return err
+	}
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return AssertError(fmt.Sprintf("finalized block %v is not part "+
+			"of the main chain", hash))
+	}
+	b.finalizedNode = node
+	return nil
+}
+
+// checkFinalizedFork returns an error when a block building on the passed
+// previous node would fork the main chain at or before the finalized block.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkFinalizedFork(prevNode *blockNode) error {
+	finalized := b.finalizedNode
+	if finalized == nil {
+		return nil
+	}
+	if prevNode.height < finalized.height ||
+		prevNode.Ancestor(finalized.height) != finalized {
+
+		str := fmt.Sprintf("block at height %d forks the main chain "+
+			"before the finalized block %v at height %d",
+			prevNode.height+1, finalized.hash, finalized.height)
+		return ruleError(ErrFinalizedFork, str)
+	}
+	return nil
+}
+
+// FinalizeBlock marks the block with the passed hash, which must be part of the
+// main chain, and all of its ancestors as irreversibly final.  Any block that
+// forks the main chain before the finalized block is rejected from then on,
+// regardless of the amount of work it has, and the finalized block can no
+// longer be invalidated.
+//
+// A finalization event is sent to the chain event subscribers for the block.
+// Finalizing a block that is not after the current finalized block has no
+// effect.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FinalizeBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return fmt.Errorf("block %v is not part of the main chain", hash)
+	}
+	if b.finalizedNode != nil && node.height <= b.finalizedNode.height {
+		return nil
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbPutFinalizedBlock(dbTx, &node.hash)
+	})
+	if err != nil {
+		return err
+	}
+	b.finalizedNode = node
+
+	log.Infof("Finalized block %v (height %d)", node.hash, node.height)
+	b.sendChainEvent(ChainEventFinalized, node, nil, nil)
+	return nil
+}
+
+// FinalizedBlock returns the hash and height of the most recent block that was
+// finalized via FinalizeBlock.  It returns nil when no block has been
+// finalized.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FinalizedBlock() (*chainhash.Hash, int32) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.finalizedNode == nil {
+		return nil, 0
+	}
+	hash := b.finalizedNode.hash
+	return &hash, b.finalizedNode.height
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain/internal/testhelper"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestFinalizeBlock ensures blocks and reorganizes that fork the main chain
+// before a finalized block are rejected.
+func TestFinalizeBlock(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestFinalizeBlock")
+	defer tearDown()
+
+	var events []ChainEvent
+	chain.SubscribeChainEvents(func(event *ChainEvent) {
+		if event.Type == ChainEventFinalized {
+			events = append(events, *event)
+		}
+	})
+
+	// Create a main chain with 4 blocks that don't spend anything so the
+	// coinbase outputs of the first block remain available to build a
+	// side chain that differs from the main chain.
+	prev := btcutil.NewBlock(params.GenesisBlock)
+	var mainBlocks []*btcutil.Block
+	var b1Outs []*testhelper.SpendableOut
+	for i := 0; i < 4; i++ {
+		block, outs, err := addBlock(chain, prev, nil)
+		if err != nil {
+			t.Fatalf("addBlock #%d: %v", i, err)
+		}
+		if i == 0 {
+			b1Outs = outs
+		}
+		mainBlocks = append(mainBlocks, block)
+		prev = block
+	}
+
+	if hash, _ := chain.FinalizedBlock(); hash != nil {
+		t.Fatalf("unexpected finalized block %v", hash)
+	}
+
+	// Finalize the block at height 3.
+	finalHash := mainBlocks[2].Hash()
+	if err := chain.FinalizeBlock(finalHash); err != nil {
+		t.Fatalf("FinalizeBlock: unexpected error: %v", err)
+	}
+	hash, height := chain.FinalizedBlock()
+	if hash == nil || *hash != *finalHash || height != 3 {
+		t.Fatalf("FinalizedBlock: got %v at height %d, want %v at "+
+			"height 3", hash, height, finalHash)
+	}
+	if len(events) != 1 || events[0].Hash != *finalHash {
+		t.Fatalf("got %d finalization events, want 1", len(events))
+	}
+
+	// Finalizing an earlier block must not move the finalized block back.
+	if err := chain.FinalizeBlock(mainBlocks[0].Hash()); err != nil {
+		t.Fatalf("FinalizeBlock: unexpected error: %v", err)
+	}
+	if hash, _ := chain.FinalizedBlock(); *hash != *finalHash {
+		t.Fatalf("finalized block moved back to %v", hash)
+	}
+
+	// A side chain block that forks before the finalized block must be
+	// rejected.
+	_, _, err := addBlock(chain, mainBlocks[0], b1Outs)
+	var ruleErr RuleError
+	if !errors.As(err, &ruleErr) || ruleErr.ErrorCode != ErrFinalizedFork {
+		t.Fatalf("side chain block: got error %v, want %v", err,
+			ErrFinalizedFork)
+	}
+
+	// The finalized block and its ancestors can't be invalidated while
+	// its descendants can.
+	for _, block := range mainBlocks[:3] {
+		err := chain.InvalidateBlock(block.Hash())
+		if !errors.As(err, &ruleErr) ||
+			ruleErr.ErrorCode != ErrFinalizedFork {
+
+			t.Fatalf("InvalidateBlock(%v): got error %v, want %v",
+				block.Hash(), err, ErrFinalizedFork)
+		}
+	}
+	if err := chain.InvalidateBlock(mainBlocks[3].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *finalHash {
+		t.Fatalf("got tip %v, want %v", tip, finalHash)
+	}
+
+	// Blocks that build on the finalized block are still accepted.
+	if _, _, err := addBlock(chain, mainBlocks[2], b1Outs); err != nil {
+		t.Fatalf("addBlock: unexpected error: %v", err)
+	}
+
+	// Blocks that aren't part of the main chain can't be finalized.
+	err = chain.FinalizeBlock(mainBlocks[3].Hash())
+	if err == nil {
+		t.Fatal("FinalizeBlock: expected error for side chain block")
+	}
+	if err := chain.FinalizeBlock(&chainhash.Hash{1}); err == nil {
+		t.Fatal("FinalizeBlock: expected error for unknown block")
+	}
+}
@@ -7,6 +7,7 @@ package indexers
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -343,6 +344,131 @@ func dbFetchAddrIndexEntries(bucket internalBucket, addrKey [addrKeySize]byte,
 	return results, numToSkip, nil
 }
 
+// addrIndexPos identifies the position of a transaction in the list of
+// transactions of an address, which are ordered by the ID of the block they are
+// in and then by their offset within it.
+type addrIndexPos struct {
+	blockID uint32
+	offset  uint32
+}
+
+// entryPos returns the position of the transaction of the passed serialized
+// address index entry.
+func entryPos(serialized []byte) addrIndexPos {
+	return addrIndexPos{
+		blockID: byteOrder.Uint32(serialized[0:4]),
+		offset:  byteOrder.Uint32(serialized[4:8]),
+	}
+}
+
+// less returns whether the position comes before the passed one.
+func (p addrIndexPos) less(other addrIndexPos) bool {
+	if p.blockID != other.blockID {
+		return p.blockID < other.blockID
+	}
+	return p.offset < other.offset
+}
+
+// dbFetchAddrIndexEntriesAfter is the same as dbFetchAddrIndexEntries except
+// only the entries which come after the passed position in the requested order
+// are considered.  That is to say the entries after the position when the
+// reverse flag is not set and the entries before it otherwise.  This allows
+// callers to page through the entries with a cursor which remains valid as new
+// entries are added.
+func dbFetchAddrIndexEntriesAfter(bucket internalBucket, addrKey [addrKeySize]byte,
+	pos addrIndexPos, numToSkip, numRequested uint32, reverse bool,
+	fetchBlockHash fetchBlockHashFunc) ([]database.BlockRegion, uint32, error) {
+
+	// numBefore returns the number of entries in the passed serialized
+	// entries which come before the position.
+	numBefore := func(serialized []byte) int {
+		numEntries := len(serialized) / txEntrySize
+		return sort.Search(numEntries, func(i int) bool {
+			return !entryPos(serialized[i*txEntrySize:]).less(pos)
+		})
+	}
+
+	// As with dbFetchAddrIndexEntries, all levels need to be fetched when
+	// the reverse flag is not set.  However, when it is set, only enough
+	// records before the position to satisfy the requested amount are
+	// needed since higher levels only contain older transactions.
+	var level uint8
+	var serialized []byte
+	for !reverse || numBefore(serialized) < int(numToSkip+numRequested) {
+		curLevelKey := keyForLevel(addrKey, level)
+		levelData := bucket.Get(curLevelKey[:])
+		if levelData == nil {
+			// Stop when there are no more levels.
+			break
+		}
+
+		// Higher levels contain older transactions, so prepend them.
+		prepended := make([]byte, len(serialized)+len(levelData))
+		copy(prepended, levelData)
+		copy(prepended[len(levelData):], serialized)
+		serialized = prepended
+		level++
+	}
+
+	// Determine the range of entries which come after the position in the
+	// requested order.
+	numEntries := uint32(len(serialized) / txEntrySize)
+	first, last := uint32(0), numEntries
+	if reverse {
+		last = uint32(numBefore(serialized))
+	} else {
+		first = uint32(sort.Search(int(numEntries), func(i int) bool {
+			return pos.less(entryPos(serialized[i*txEntrySize:]))
+		}))
+	}
+
+	// When the requested number of entries to skip is larger than the
+	// number available, skip them all and return now with the actual number
+	// skipped.
+	numAvailable := last - first
+	if numToSkip >= numAvailable {
+		return nil, numAvailable, nil
+	}
+
+	// Limit the number to load based on the number of available entries,
+	// the number to skip, and the number requested.
+	numToLoad := numAvailable - numToSkip
+	if numToLoad > numRequested {
+		numToLoad = numRequested
+	}
+
+	results := make([]database.BlockRegion, numToLoad)
+	for i := uint32(0); i < numToLoad; i++ {
+		// Calculate the read offset according to the reverse flag.
+		var offset uint32
+		if reverse {
+			offset = (last - numToSkip - i - 1) * txEntrySize
+		} else {
+			offset = (first + numToSkip + i) * txEntrySize
+		}
+
+		// Deserialize and populate the result.
+		err := deserializeAddrIndexEntry(serialized[offset:],
+			&results[i], fetchBlockHash)
+		if err != nil {
+			// Ensure any deserialization errors are returned as
+			// database corruption errors.
+			if isDeserializeErr(err) {
+				err = database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("failed to "+
+						"deserialized address index "+
+						"for key %x: %v", addrKey, err),
+				}
+			}
+
+			return nil, 0, err
+		}
+	}
+
+	return results, numToSkip, nil
+}
+
 // minEntriesToReachLevel returns the minimum number of entries that are
 // required to reach the given address index level.
 func minEntriesToReachLevel(level uint8) int {
@@ -849,6 +975,55 @@ func (idx *AddrIndex) TxRegionsForAddress(dbTx database.Tx, addr btcutil.Address
 	return regions, skipped, err
 }
 
+// TxRegionsForAddressAfter is the same as TxRegionsForAddress except the
+// results start after the transaction at the passed block region in the
+// requested order.  That is to say they only include transactions which come
+// after it in the chain when the reverse flag is not set and the transactions
+// which come before it otherwise.  The block region must be one of a
+// transaction in the main chain such as those returned by this function or the
+// transaction index, which allows callers to page through the transactions of
+// an address without the pages shifting as new transactions are confirmed.
+//
+// NOTE: These results only include transactions confirmed in blocks.  See the
+// UnconfirmedTxnsForAddress method for obtaining unconfirmed transactions
+// that involve a given address.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) TxRegionsForAddressAfter(dbTx database.Tx, addr btcutil.Address,
+	after *database.BlockRegion, numToSkip, numRequested uint32,
+	reverse bool) ([]database.BlockRegion, uint32, error) {
+
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var regions []database.BlockRegion
+	var skipped uint32
+	err = idx.db.View(func(dbTx database.Tx) error {
+		blockID, err := dbFetchBlockIDByHash(dbTx, after.Hash)
+		if err != nil {
+			return err
+		}
+		pos := addrIndexPos{blockID: blockID, offset: after.Offset}
+
+		// Create closure to lookup the block hash given the ID using
+		// the database transaction.
+		fetchBlockHash := func(id []byte) (*chainhash.Hash, error) {
+			// Deserialize and populate the result.
+			return dbFetchBlockHashBySerializedID(dbTx, id)
+		}
+
+		addrIdxBucket := dbTx.Metadata().Bucket(addrIndexKey)
+		regions, skipped, err = dbFetchAddrIndexEntriesAfter(
+			addrIdxBucket, addrKey, pos, numToSkip, numRequested,
+			reverse, fetchBlockHash)
+		return err
+	})
+
+	return regions, skipped, err
+}
+
 // indexUnconfirmedAddresses modifies the unconfirmed (memory-only) address
 // index to include mappings for the addresses encoded by the passed public key
 // script to the transaction.
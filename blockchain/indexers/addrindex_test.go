@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 )
 
@@ -274,3 +275,88 @@ nextTest:
 		}
 	}
 }
+
+// TestAddrIndexEntriesAfter ensures fetching the address index entries after a
+// position returns the expected entries across all levels in both orders.
+func TestAddrIndexEntriesAfter(t *testing.T) {
+	t.Parallel()
+
+	// Insert entries spanning several levels where the entry for block ID i
+	// is at offset i*2 so the positions between entries can be tested too.
+	const numEntries = level0MaxEntries*7 + 1
+	var key [addrKeySize]byte
+	bucket := &addrIndexBucket{levels: make(map[[levelKeySize]byte][]byte)}
+	for i := 0; i < numEntries; i++ {
+		txLoc := wire.TxLoc{TxStart: i * 2}
+		err := dbPutAddrIndexEntry(bucket, key, uint32(i), txLoc)
+		if err != nil {
+			t.Fatalf("dbPutAddrIndexEntry: unexpected error: %v", err)
+		}
+	}
+
+	// The mock block hash is the block ID so the results identify the
+	// entries.
+	fetchBlockHash := func(id []byte) (*chainhash.Hash, error) {
+		var hash chainhash.Hash
+		copy(hash[:], id)
+		return &hash, nil
+	}
+
+	for blockID := uint32(0); blockID < numEntries; blockID += 3 {
+		for _, offset := range []uint32{blockID * 2, blockID*2 + 1} {
+			pos := addrIndexPos{blockID: blockID, offset: offset}
+			for _, reverse := range []bool{false, true} {
+				// Determine the block IDs of the entries after
+				// the position in the requested order.
+				var want []uint32
+				for i := uint32(0); i < numEntries; i++ {
+					entry := addrIndexPos{blockID: i, offset: i * 2}
+					if !reverse && pos.less(entry) {
+						want = append(want, i)
+					}
+					if reverse && entry.less(pos) {
+						want = append([]uint32{i}, want...)
+					}
+				}
+				wantSkipped := uint32(0)
+				if len(want) > 0 {
+					want = want[1:]
+					wantSkipped = 1
+				}
+				if len(want) > 5 {
+					want = want[:5]
+				}
+
+				regions, skipped, err := dbFetchAddrIndexEntriesAfter(
+					bucket, key, pos, 1, 5, reverse,
+					fetchBlockHash)
+				if err != nil {
+					t.Fatalf("dbFetchAddrIndexEntriesAfter: "+
+						"unexpected error: %v", err)
+				}
+				got := make([]uint32, 0, len(regions))
+				for _, region := range regions {
+					id := byteOrder.Uint32(region.Hash[:])
+					if region.Offset != id*2 {
+						t.Fatalf("unexpected offset %d for "+
+							"block ID %d", region.Offset, id)
+					}
+					got = append(got, id)
+				}
+				if len(got) != len(want) || skipped != wantSkipped {
+
+					t.Fatalf("pos %+v reverse %v: got %v "+
+						"(skipped %d), want %v", pos,
+						reverse, got, skipped, want)
+				}
+				for i := range got {
+					if got[i] != want[i] {
+						t.Fatalf("pos %+v reverse %v: got "+
+							"%v, want %v", pos, reverse,
+							got, want)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,437 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// balanceIndexName is the human-readable name for the index.
+	balanceIndexName = "balance index"
+
+	// balanceEntryPrefix and utxoEntryPrefix are the prefixes of the keys
+	// of the balance entries and the unspent output entries in the index
+	// bucket.
+	balanceEntryPrefix = 'b'
+	utxoEntryPrefix    = 'u'
+
+	// balanceEntryKeySize is the size of the key of a balance entry.
+	balanceEntryKeySize = 1 + sha256.Size
+
+	// utxoEntryKeySize is the size of the key of an unspent output entry.
+	utxoEntryKeySize = balanceEntryKeySize + chainhash.HashSize + 4
+)
+
+var (
+	// balanceIndexKey is the key of the balance index and the db bucket
+	// used to house it.
+	balanceIndexKey = []byte("balanceidx")
+)
+
+// -----------------------------------------------------------------------------
+// The balance index consists of two kinds of entries for every script which
+// received outputs in the main chain, both keyed by the script hash, which is
+// the sha256 of the script.  The balance entry holds the running total of the
+// amounts received and spent by the script so its balance is available with a
+// single lookup, and the unspent output entries hold the outputs of the script
+// which haven't been spent yet.
+//
+// Unspendable outputs and the outputs of the genesis block never enter the
+// utxo set and are therefore not indexed.
+//
+// The serialized format for keys and values of the balance entries is:
+//   <prefix><script hash> = <received><spent>
+//
+//   Field           Type              Size
+//   prefix          byte ('b')        1 byte
+//   script hash     [32]byte          32 bytes
+//   received        uint64            8 bytes
+//   spent           uint64            8 bytes
+//   -----
+//   Total: 49 bytes
+//
+// The serialized format for keys and values of the unspent output entries is:
+//   <prefix><script hash><tx hash><output index> = <amount><block height>
+//
+//   Field           Type              Size
+//   prefix          byte ('u')        1 byte
+//   script hash     [32]byte          32 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//   amount          uint64            8 bytes
+//   block height    uint32            4 bytes
+//   -----
+//   Total: 81 bytes
+// -----------------------------------------------------------------------------
+
+// ScriptBalance houses the total amounts received and spent by a script in the
+// main chain.
+type ScriptBalance struct {
+	Received int64
+	Spent    int64
+}
+
+// Balance returns the amount of the unspent outputs of the script.
+func (b *ScriptBalance) Balance() int64 {
+	return b.Received - b.Spent
+}
+
+// ScriptUtxo describes an unspent output of a script in the main chain.
+type ScriptUtxo struct {
+	OutPoint wire.OutPoint
+	Amount   int64
+	Height   int32
+}
+
+// balanceEntryKey returns the key of the balance entry of the passed script.
+func balanceEntryKey(pkScript []byte) []byte {
+	scriptHash := sha256.Sum256(pkScript)
+	key := make([]byte, balanceEntryKeySize, utxoEntryKeySize)
+	key[0] = balanceEntryPrefix
+	copy(key[1:], scriptHash[:])
+	return key
+}
+
+// utxoEntryKey returns the key of the unspent output entry of the passed
+// script and outpoint.
+func utxoEntryKey(pkScript []byte, op *wire.OutPoint) []byte {
+	key := balanceEntryKey(pkScript)[:utxoEntryKeySize]
+	key[0] = utxoEntryPrefix
+	copy(key[balanceEntryKeySize:], op.Hash[:])
+	byteOrder.PutUint32(key[balanceEntryKeySize+chainhash.HashSize:],
+		op.Index)
+	return key
+}
+
+// dbFetchScriptBalance uses an existing database transaction to fetch the
+// balance entry of the passed script.  A zero balance is returned when the
+// script doesn't have an entry.
+func dbFetchScriptBalance(dbTx database.Tx, pkScript []byte) (ScriptBalance, error) {
+	bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+	serialized := bucket.Get(balanceEntryKey(pkScript))
+	if serialized == nil {
+		return ScriptBalance{}, nil
+	}
+	if len(serialized) != 16 {
+		return ScriptBalance{}, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt %s balance entry "+
+				"for script %x", balanceIndexName, pkScript),
+		}
+	}
+	return ScriptBalance{
+		Received: int64(byteOrder.Uint64(serialized)),
+		Spent:    int64(byteOrder.Uint64(serialized[8:])),
+	}, nil
+}
+
+// dbUpdateScriptBalance uses an existing database transaction to add the passed
+// amounts to the balance entry of the passed script.  The entry is removed once
+// both of its totals are zero.
+func dbUpdateScriptBalance(dbTx database.Tx, pkScript []byte, received, spent int64) error {
+	balance, err := dbFetchScriptBalance(dbTx, pkScript)
+	if err != nil {
+		return err
+	}
+	balance.Received += received
+	balance.Spent += spent
+
+	bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+	key := balanceEntryKey(pkScript)
+	if balance.Received == 0 && balance.Spent == 0 {
+		return bucket.Delete(key)
+	}
+	var serialized [16]byte
+	byteOrder.PutUint64(serialized[:], uint64(balance.Received))
+	byteOrder.PutUint64(serialized[8:], uint64(balance.Spent))
+	return bucket.Put(key, serialized[:])
+}
+
+// dbPutScriptUtxo uses an existing database transaction to add an unspent
+// output entry for the passed script.
+func dbPutScriptUtxo(dbTx database.Tx, pkScript []byte, op *wire.OutPoint,
+	amount int64, height int32) error {
+
+	var serialized [12]byte
+	byteOrder.PutUint64(serialized[:], uint64(amount))
+	byteOrder.PutUint32(serialized[8:], uint32(height))
+
+	bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+	return bucket.Put(utxoEntryKey(pkScript, op), serialized[:])
+}
+
+// dbRemoveScriptUtxo uses an existing database transaction to remove an
+// unspent output entry of the passed script.
+func dbRemoveScriptUtxo(dbTx database.Tx, pkScript []byte, op *wire.OutPoint) error {
+	bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+	return bucket.Delete(utxoEntryKey(pkScript, op))
+}
+
+// dbFetchScriptUtxos uses an existing database transaction to fetch all of the
+// unspent outputs of the passed script.
+func dbFetchScriptUtxos(dbTx database.Tx, pkScript []byte) ([]ScriptUtxo, error) {
+	prefix := balanceEntryKey(pkScript)
+	prefix[0] = utxoEntryPrefix
+
+	var utxos []ScriptUtxo
+	cursor := dbTx.Metadata().Bucket(balanceIndexKey).Cursor()
+	for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		serialized := cursor.Value()
+		if len(key) != utxoEntryKeySize || len(serialized) != 12 {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt %s unspent "+
+					"output entry for script %x",
+					balanceIndexName, pkScript),
+			}
+		}
+
+		var utxo ScriptUtxo
+		copy(utxo.OutPoint.Hash[:], key[balanceEntryKeySize:])
+		utxo.OutPoint.Index = byteOrder.Uint32(
+			key[balanceEntryKeySize+chainhash.HashSize:])
+		utxo.Amount = int64(byteOrder.Uint64(serialized))
+		utxo.Height = int32(byteOrder.Uint32(serialized[8:]))
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+// isIndexedOutput returns whether the passed output of a block at the passed
+// height enters the utxo set and is therefore tracked by the balance index.
+func isIndexedOutput(txOut *wire.TxOut, height int32) bool {
+	return height != 0 && !txscript.IsUnspendable(txOut.PkScript)
+}
+
+// BalanceIndex implements a balance index which tracks the amounts received
+// and spent by every script along with its unspent outputs.
+type BalanceIndex struct {
+	db database.DB
+}
+
+// Ensure the BalanceIndex type implements the Indexer interface.
+var _ Indexer = (*BalanceIndex)(nil)
+
+// Ensure the BalanceIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*BalanceIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *BalanceIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init initializes the balance index.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Key() []byte {
+	return balanceIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Name() string {
+	return balanceIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the index.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(balanceIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the outputs created by the
+// block to the balances and unspent outputs of their scripts and removes the
+// outputs spent by the block from them.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	stxoIndex := 0
+	for txIdx, tx := range block.Transactions() {
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				if stxoIndex >= len(stxos) {
+					return fmt.Errorf("missing spent output "+
+						"of block %v", block.Hash())
+				}
+				stxo := &stxos[stxoIndex]
+				stxoIndex++
+
+				err := dbUpdateScriptBalance(dbTx, stxo.PkScript,
+					0, stxo.Amount)
+				if err != nil {
+					return err
+				}
+				err = dbRemoveScriptUtxo(dbTx, stxo.PkScript,
+					&txIn.PreviousOutPoint)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for i, txOut := range tx.MsgTx().TxOut {
+			if !isIndexedOutput(txOut, block.Height()) {
+				continue
+			}
+			err := dbUpdateScriptBalance(dbTx, txOut.PkScript,
+				txOut.Value, 0)
+			if err != nil {
+				return err
+			}
+			op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			err = dbPutScriptUtxo(dbTx, txOut.PkScript, &op,
+				txOut.Value, block.Height())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer reverts the changes made to
+// the balances and unspent outputs when the block was connected.  The
+// transactions are reverted in reverse order so outputs which were created
+// and spent within the block end up removed.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	stxoIndex := len(stxos)
+	txns := block.Transactions()
+	for txIdx := len(txns) - 1; txIdx >= 0; txIdx-- {
+		tx := txns[txIdx]
+		for i, txOut := range tx.MsgTx().TxOut {
+			if !isIndexedOutput(txOut, block.Height()) {
+				continue
+			}
+			err := dbUpdateScriptBalance(dbTx, txOut.PkScript,
+				-txOut.Value, 0)
+			if err != nil {
+				return err
+			}
+			op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			err = dbRemoveScriptUtxo(dbTx, txOut.PkScript, &op)
+			if err != nil {
+				return err
+			}
+		}
+		if txIdx == 0 {
+			continue
+		}
+
+		txIns := tx.MsgTx().TxIn
+		if stxoIndex < len(txIns) {
+			return fmt.Errorf("missing spent output of block %v",
+				block.Hash())
+		}
+		stxoIndex -= len(txIns)
+		for i, txIn := range txIns {
+			stxo := &stxos[stxoIndex+i]
+			err := dbUpdateScriptBalance(dbTx, stxo.PkScript, 0,
+				-stxo.Amount)
+			if err != nil {
+				return err
+			}
+			err = dbPutScriptUtxo(dbTx, stxo.PkScript,
+				&txIn.PreviousOutPoint, stxo.Amount, stxo.Height)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ScriptBalance returns the total amounts received and spent by the passed
+// script in the main chain.
+//
+// This function is safe for concurrent access.
+func (idx *BalanceIndex) ScriptBalance(pkScript []byte) (ScriptBalance, error) {
+	var balance ScriptBalance
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		balance, err = dbFetchScriptBalance(dbTx, pkScript)
+		return err
+	})
+	return balance, err
+}
+
+// ScriptUtxos returns the unspent outputs of the passed script in the main
+// chain.
+//
+// This function is safe for concurrent access.
+func (idx *BalanceIndex) ScriptUtxos(pkScript []byte) ([]ScriptUtxo, error) {
+	var utxos []ScriptUtxo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		utxos, err = dbFetchScriptUtxos(dbTx, pkScript)
+		return err
+	})
+	return utxos, err
+}
+
+// NewBalanceIndex returns a new instance of an indexer that is used to track
+// the amounts received and spent by every script along with its unspent
+// outputs.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewBalanceIndex(db database.DB) *BalanceIndex {
+	return &BalanceIndex{db: db}
+}
+
+// DropBalanceIndex drops the balance index from the provided database if it
+// exists.
+func DropBalanceIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, balanceIndexKey, balanceIndexName, interrupt)
+}
+
+// BalanceIndexInitialized returns true if the balance index has been created
+// previously.
+func BalanceIndexInitialized(db database.DB) bool {
+	var exists bool
+	db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+		exists = bucket != nil
+		return nil
+	})
+
+	return exists
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestBalanceIndex ensures the balance index tracks the amounts received and
+// spent by scripts along with their unspent outputs as blocks are connected and
+// disconnected.
+func TestBalanceIndex(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(t.TempDir(), "ffldb")
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewBalanceIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	if !BalanceIndexInitialized(db) {
+		t.Fatalf("BalanceIndexInitialized: index not initialized")
+	}
+
+	scriptA := []byte{txscript.OP_TRUE}
+	scriptB := []byte{txscript.OP_2}
+	opReturn := []byte{txscript.OP_RETURN}
+
+	// The first block pays 50 to script A in its coinbase along with an
+	// unspendable output, which isn't indexed.
+	coinbase1 := wire.NewMsgTx(wire.TxVersion)
+	coinbase1.AddTxIn(&wire.TxIn{})
+	coinbase1.AddTxOut(wire.NewTxOut(50, scriptA))
+	coinbase1.AddTxOut(wire.NewTxOut(0, opReturn))
+	msgBlock1 := wire.NewMsgBlock(&wire.BlockHeader{})
+	msgBlock1.AddTransaction(coinbase1)
+	block1 := btcutil.NewBlock(msgBlock1)
+	block1.SetHeight(1)
+
+	// The second block spends the output of script A to pay 30 to script
+	// B and 19 back to script A, and then spends the change again within
+	// the block to pay 18 to script B.
+	coinbaseOut := wire.OutPoint{Hash: coinbase1.TxHash(), Index: 0}
+	coinbase2 := wire.NewMsgTx(wire.TxVersion)
+	coinbase2.AddTxIn(&wire.TxIn{Sequence: 2})
+	coinbase2.AddTxOut(wire.NewTxOut(1, scriptB))
+	spend1 := wire.NewMsgTx(wire.TxVersion)
+	spend1.AddTxIn(wire.NewTxIn(&coinbaseOut, nil, nil))
+	spend1.AddTxOut(wire.NewTxOut(30, scriptB))
+	spend1.AddTxOut(wire.NewTxOut(19, scriptA))
+	changeOut := wire.OutPoint{Hash: spend1.TxHash(), Index: 1}
+	spend2 := wire.NewMsgTx(wire.TxVersion)
+	spend2.AddTxIn(wire.NewTxIn(&changeOut, nil, nil))
+	spend2.AddTxOut(wire.NewTxOut(18, scriptB))
+	msgBlock2 := wire.NewMsgBlock(&wire.BlockHeader{})
+	msgBlock2.AddTransaction(coinbase2)
+	msgBlock2.AddTransaction(spend1)
+	msgBlock2.AddTransaction(spend2)
+	block2 := btcutil.NewBlock(msgBlock2)
+	block2.SetHeight(2)
+	stxos2 := []blockchain.SpentTxOut{
+		{Amount: 50, PkScript: scriptA, Height: 1, IsCoinBase: true},
+		{Amount: 19, PkScript: scriptA, Height: 2},
+	}
+
+	// checkScript ensures the balance and unspent outputs of the passed
+	// script match the expected ones.
+	checkScript := func(pkScript []byte, wantBalance ScriptBalance,
+		wantUtxos []ScriptUtxo) {
+
+		t.Helper()
+
+		balance, err := idx.ScriptBalance(pkScript)
+		if err != nil {
+			t.Fatalf("ScriptBalance: unexpected error: %v", err)
+		}
+		if balance != wantBalance {
+			t.Fatalf("ScriptBalance(%x): got %+v, want %+v",
+				pkScript, balance, wantBalance)
+		}
+		utxos, err := idx.ScriptUtxos(pkScript)
+		if err != nil {
+			t.Fatalf("ScriptUtxos: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(utxos, wantUtxos) {
+			t.Fatalf("ScriptUtxos(%x): got %+v, want %+v",
+				pkScript, utxos, wantUtxos)
+		}
+	}
+
+	connect := func(block *btcutil.Block, stxos []blockchain.SpentTxOut) {
+		t.Helper()
+
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, block, stxos)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: unexpected error: %v", err)
+		}
+	}
+	connect(block1, nil)
+	coinbaseUtxo := ScriptUtxo{OutPoint: coinbaseOut, Amount: 50, Height: 1}
+	checkScript(scriptA, ScriptBalance{Received: 50}, []ScriptUtxo{
+		coinbaseUtxo,
+	})
+	checkScript(opReturn, ScriptBalance{}, nil)
+
+	connect(block2, stxos2)
+	checkScript(scriptA, ScriptBalance{Received: 69, Spent: 69}, nil)
+	balance, err := idx.ScriptBalance(scriptB)
+	if err != nil {
+		t.Fatalf("ScriptBalance: unexpected error: %v", err)
+	}
+	if balance.Balance() != 49 {
+		t.Fatalf("ScriptBalance: got balance %d, want 49",
+			balance.Balance())
+	}
+	utxos, err := idx.ScriptUtxos(scriptB)
+	if err != nil {
+		t.Fatalf("ScriptUtxos: unexpected error: %v", err)
+	}
+	if len(utxos) != 3 {
+		t.Fatalf("ScriptUtxos: got %d unspent outputs, want 3",
+			len(utxos))
+	}
+
+	// Disconnecting the second block restores the state after the first
+	// block.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block2, stxos2)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	checkScript(scriptA, ScriptBalance{Received: 50}, []ScriptUtxo{
+		coinbaseUtxo,
+	})
+	checkScript(scriptB, ScriptBalance{}, nil)
+}
@@ -6,6 +6,7 @@ package indexers
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcutil"
@@ -340,6 +341,34 @@ func (idx *CfIndex) FilterHashesByBlockHashes(blockHashes []*chainhash.Hash,
 	return idx.entriesByBlockHashes(cfHashKeys, filterType, blockHashes)
 }
 
+// FilterMatch returns whether any of the passed scripts match the basic
+// committed filter of the block with the passed hash.  As with any filter
+// match, a positive result may be a false positive and the block has to be
+// checked to know whether it really involves the scripts, while a negative
+// result means none of the scripts are involved in the block.
+//
+// An error is returned when the filter for the block does not exist.
+func (idx *CfIndex) FilterMatch(h *chainhash.Hash, scripts [][]byte) (bool, error) {
+	filterBytes, err := idx.FilterByBlockHash(h, wire.GCSFilterRegular)
+	if err != nil {
+		return false, err
+	}
+	if len(filterBytes) == 0 {
+		return false, fmt.Errorf("no committed filter for block %v", h)
+	}
+
+	filter, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM,
+		filterBytes)
+	if err != nil {
+		return false, err
+	}
+	if filter.N() == 0 || len(scripts) == 0 {
+		return false, nil
+	}
+
+	return filter.MatchAny(builder.DeriveKey(h), scripts)
+}
+
 // NewCfIndex returns a new instance of an indexer that is used to create a
 // mapping of the hashes of all blocks in the blockchain to their respective
 // committed filters.
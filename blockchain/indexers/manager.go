@@ -7,6 +7,10 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcutil"
@@ -15,10 +19,21 @@ import (
 	"github.com/btcsuite/btcd/wire"
 )
 
+const (
+	// maxSyncCatchUpBlocks is the maximum number of blocks an index may be
+	// behind the best chain for it to be caught up while the manager is
+	// initialized rather than by a background backfill.
+	maxSyncCatchUpBlocks = 6
+)
+
 var (
 	// indexTipsBucketName is the name of the db bucket used to house the
 	// current tip of each index.
 	indexTipsBucketName = []byte("idxtips")
+
+	// indexBackfillBucketName is the name of the db bucket used to house
+	// the progress of the indexes which are being backfilled.
+	indexBackfillBucketName = []byte("idxbackfill")
 )
 
 // -----------------------------------------------------------------------------
@@ -64,6 +79,67 @@ func dbFetchIndexerTip(dbTx database.Tx, idxKey []byte) (*chainhash.Hash, int32,
 	return &hash, height, nil
 }
 
+// -----------------------------------------------------------------------------
+// Indexes which are far behind the best chain when the manager is initialized
+// are backfilled in the background.  The progress of each backfill is
+// checkpointed in a separate bucket which contains an entry for each index
+// being backfilled until it has caught up, so an interrupted backfill is
+// resumed on the next start.
+//
+// The serialized format for a backfill checkpoint is:
+//
+//   <start height><block hash><block height>
+//
+//   Field           Type             Size
+//   start height    uint32           4 bytes
+//   block hash      chainhash.Hash   chainhash.HashSize
+//   block height    uint32           4 bytes
+// -----------------------------------------------------------------------------
+
+// dbPutBackfillCheckpoint uses an existing database transaction to update or
+// add the backfill checkpoint of the given index.  The start height is the
+// height of the index tip when the backfill started.
+func dbPutBackfillCheckpoint(dbTx database.Tx, idxKey []byte, startHeight int32,
+	hash *chainhash.Hash, height int32) error {
+
+	serialized := make([]byte, 4+chainhash.HashSize+4)
+	byteOrder.PutUint32(serialized, uint32(startHeight))
+	copy(serialized[4:], hash[:])
+	byteOrder.PutUint32(serialized[4+chainhash.HashSize:], uint32(height))
+
+	backfillBucket := dbTx.Metadata().Bucket(indexBackfillBucketName)
+	return backfillBucket.Put(idxKey, serialized)
+}
+
+// dbFetchBackfillStart uses an existing database transaction to retrieve the
+// height the backfill of the given index started at.  False is returned when
+// the index isn't being backfilled.
+func dbFetchBackfillStart(dbTx database.Tx, idxKey []byte) (int32, bool, error) {
+	backfillBucket := dbTx.Metadata().Bucket(indexBackfillBucketName)
+	serialized := backfillBucket.Get(idxKey)
+	if serialized == nil {
+		return 0, false, nil
+	}
+	if len(serialized) < 4+chainhash.HashSize+4 {
+		return 0, false, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("unexpected end of data for "+
+				"index %q backfill checkpoint", string(idxKey)),
+		}
+	}
+	return int32(byteOrder.Uint32(serialized)), true, nil
+}
+
+// dbRemoveBackfillCheckpoint uses an existing database transaction to remove
+// the backfill checkpoint of the given index.
+func dbRemoveBackfillCheckpoint(dbTx database.Tx, idxKey []byte) error {
+	backfillBucket := dbTx.Metadata().Bucket(indexBackfillBucketName)
+	if backfillBucket == nil {
+		return nil
+	}
+	return backfillBucket.Delete(idxKey)
+}
+
 // dbIndexConnectBlock adds all of the index entries associated with the
 // given block using the provided indexer and updates the tip of the indexer
 // accordingly.  An error will be returned if the current tip for the indexer is
@@ -132,6 +208,27 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *btcutil.Bl
 type Manager struct {
 	db             database.DB
 	enabledIndexes []Indexer
+
+	// chain is the chain the indexes are synced with.  It is set when the
+	// manager is initialized.
+	chain *blockchain.BlockChain
+
+	// backfilling tracks which of the enabled indexes are being caught up
+	// in the background.  They are only updated along with the chain once
+	// their tip is the parent of a connected block.  It is protected by
+	// mtx, which must only be acquired while holding a database
+	// transaction when both are needed.
+	mtx         sync.Mutex
+	backfilling []bool
+
+	// backfillRate is the maximum number of blocks per second indexed by
+	// the backfill.  Zero means the rate is unlimited.
+	backfillRate int
+
+	started  int32
+	shutdown int32
+	quit     chan struct{}
+	wg       sync.WaitGroup
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -228,12 +325,15 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 // Init initializes the enabled indexes.  This is called during chain
 // initialization and primarily consists of catching up all indexes to the
 // current best chain tip.  This is necessary since each index can be disabled
-// and re-enabled at any time and attempting to catch-up indexes at the same
-// time new blocks are being downloaded would lead to an overall longer time to
-// catch up due to the I/O contention.
+// and re-enabled at any time.  Indexes which are only a few blocks behind are
+// caught up immediately, while indexes which are far behind are backfilled in
+// the background once the manager is started so chain initialization isn't
+// blocked on them.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{}) error {
+	m.chain = chain
+
 	// Nothing to do when no indexes are enabled.
 	if len(m.enabledIndexes) == 0 {
 		return nil
@@ -256,6 +356,10 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		if err != nil {
 			return err
 		}
+		_, err = meta.CreateBucketIfNotExists(indexBackfillBucketName)
+		if err != nil {
+			return err
+		}
 
 		return m.maybeCreateIndexes(dbTx)
 	})
@@ -365,10 +469,16 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 	// lowest one so the catchup code only needs to start at the earliest
 	// block and is able to skip connecting the block for the indexes that
 	// don't need it.
+	//
+	// Indexes which are far behind the best chain, such as indexes which
+	// were enabled after the chain was synced, are backfilled in the
+	// background once the manager is started instead so the node doesn't
+	// have to wait for them to catch up.
 	bestHeight := chain.BestSnapshot().Height
 	lowestHeight := bestHeight
 	indexerHeights := make([]int32, len(m.enabledIndexes))
-	err = m.db.View(func(dbTx database.Tx) error {
+	m.backfilling = make([]bool, len(m.enabledIndexes))
+	err = m.db.Update(func(dbTx database.Tx) error {
 		for i, indexer := range m.enabledIndexes {
 			idxKey := indexer.Key()
 			hash, height, err := dbFetchIndexerTip(dbTx, idxKey)
@@ -379,8 +489,39 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 			log.Debugf("Current %s tip (height %d, hash %v)",
 				indexer.Name(), height, hash)
 			indexerHeights[i] = height
-			if height < lowestHeight {
-				lowestHeight = height
+
+			startHeight, resume, err := dbFetchBackfillStart(dbTx,
+				idxKey)
+			if err != nil {
+				return err
+			}
+			if bestHeight-height <= maxSyncCatchUpBlocks {
+				if height < lowestHeight {
+					lowestHeight = height
+				}
+				if resume {
+					err := dbRemoveBackfillCheckpoint(dbTx, idxKey)
+					if err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			m.backfilling[i] = true
+			if resume {
+				log.Infof("Resuming %s backfill at height %d "+
+					"(started at height %d)", indexer.Name(),
+					height+1, startHeight+1)
+				continue
+			}
+			log.Infof("The %s will be backfilled in the background "+
+				"from height %d to %d", indexer.Name(), height+1,
+				bestHeight)
+			err = dbPutBackfillCheckpoint(dbTx, idxKey, height, hash,
+				height)
+			if err != nil {
+				return err
 			}
 		}
 		return nil
@@ -419,7 +560,7 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		for i, indexer := range m.enabledIndexes {
 			// Skip indexes that don't need to be updated with this
 			// block.
-			if indexerHeights[i] >= height {
+			if indexerHeights[i] >= height || m.backfilling[i] {
 				continue
 			}
 
@@ -502,9 +643,36 @@ func dbFetchTx(dbTx database.Tx, hash *chainhash.Hash) (*wire.MsgTx, error) {
 func (m *Manager) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
 	stxos []blockchain.SpentTxOut) error {
 
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
 	// being connected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	for i, index := range m.enabledIndexes {
+		// Indexes which are being backfilled are only updated once the
+		// block extends their tip, at which point they have caught up
+		// and are updated along with the chain from then on.
+		if m.isBackfilling(i) {
+			idxKey := index.Key()
+			tipHash, _, err := dbFetchIndexerTip(dbTx, idxKey)
+			if err != nil {
+				return err
+			}
+			prevHash := &block.MsgBlock().Header.PrevBlock
+			if !tipHash.IsEqual(prevHash) {
+				continue
+			}
+			err = dbIndexConnectBlock(dbTx, index, block, stxos)
+			if err != nil {
+				return err
+			}
+			err = m.finishBackfill(dbTx, i, block.Height())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		err := dbIndexConnectBlock(dbTx, index, block, stxos)
 		if err != nil {
 			return err
@@ -522,9 +690,36 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
 func (m *Manager) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 	stxo []blockchain.SpentTxOut) error {
 
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
 	// being disconnected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	for i, index := range m.enabledIndexes {
+		// Indexes which are being backfilled only need to be updated
+		// when the backfill already indexed the block.
+		if m.isBackfilling(i) {
+			idxKey := index.Key()
+			tipHash, _, err := dbFetchIndexerTip(dbTx, idxKey)
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(block.Hash()) {
+				continue
+			}
+			err = dbIndexDisconnectBlock(dbTx, index, block, stxo)
+			if err != nil {
+				return err
+			}
+			err = m.dbPutBackfillProgress(dbTx, index,
+				&block.MsgBlock().Header.PrevBlock,
+				block.Height()-1)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		err := dbIndexDisconnectBlock(dbTx, index, block, stxo)
 		if err != nil {
 			return err
@@ -533,6 +728,306 @@ func (m *Manager) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 	return nil
 }
 
+// isBackfilling returns whether the enabled index at the passed position is
+// being backfilled.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) isBackfilling(i int) bool {
+	return i < len(m.backfilling) && m.backfilling[i]
+}
+
+// dbPutBackfillProgress uses an existing database transaction to checkpoint
+// the progress of the backfill of the passed index at the provided tip.
+func (m *Manager) dbPutBackfillProgress(dbTx database.Tx, indexer Indexer,
+	hash *chainhash.Hash, height int32) error {
+
+	idxKey := indexer.Key()
+	startHeight, _, err := dbFetchBackfillStart(dbTx, idxKey)
+	if err != nil {
+		return err
+	}
+	return dbPutBackfillCheckpoint(dbTx, idxKey, startHeight, hash, height)
+}
+
+// finishBackfill uses an existing database transaction to mark the backfill of
+// the enabled index at the passed position as done now that its tip is at the
+// provided height of the best chain.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) finishBackfill(dbTx database.Tx, i int, height int32) error {
+	indexer := m.enabledIndexes[i]
+	if err := dbRemoveBackfillCheckpoint(dbTx, indexer.Key()); err != nil {
+		return err
+	}
+	m.backfilling[i] = false
+	log.Infof("The %s has caught up with the best chain at height %d",
+		indexer.Name(), height)
+	return nil
+}
+
+// backfillBlock indexes the next main chain block for the indexes which are
+// being backfilled.  The block is loaded before the indexes are updated, so it
+// is skipped and loaded again when the chain was reorganized in the meantime.
+// Indexes which reached the tip of the best chain are marked as caught up.  It
+// returns whether all of the backfills are done.
+func (m *Manager) backfillBlock(progressLogger *blockProgressLogger) (bool, error) {
+	// Load the main chain block after the lowest tip of the indexes which
+	// are being backfilled.
+	var block *btcutil.Block
+	var needsInputs, done bool
+	err := m.db.View(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		lowestHeight := int32(math.MaxInt32)
+		for i, indexer := range m.enabledIndexes {
+			if !m.isBackfilling(i) {
+				continue
+			}
+			_, height, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if height < lowestHeight {
+				lowestHeight = height
+			}
+			needsInputs = needsInputs || indexNeedsInputs(indexer)
+		}
+		if lowestHeight == math.MaxInt32 {
+			done = true
+			return nil
+		}
+
+		hash, ok := blockchain.DBFetchMainChainHash(dbTx, lowestHeight+1)
+		if !ok {
+			return nil
+		}
+		blockBytes, err := dbTx.FetchBlock(hash)
+		if err != nil {
+			return err
+		}
+		block, err = btcutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return err
+		}
+		block.SetHeight(lowestHeight + 1)
+		return nil
+	})
+	if err != nil || done {
+		return done, err
+	}
+
+	// The indexes with the lowest tip are at the tip of the best chain
+	// when it doesn't have a block after it.
+	if block == nil {
+		err := m.db.Update(func(dbTx database.Tx) error {
+			m.mtx.Lock()
+			defer m.mtx.Unlock()
+
+			for i, indexer := range m.enabledIndexes {
+				if !m.isBackfilling(i) {
+					continue
+				}
+				_, height, err := dbFetchIndexerTip(dbTx,
+					indexer.Key())
+				if err != nil {
+					return err
+				}
+				_, ok := blockchain.DBFetchMainChainHash(dbTx,
+					height+1)
+				if ok {
+					continue
+				}
+				if err := m.finishBackfill(dbTx, i, height); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return false, err
+	}
+
+	var stxos []blockchain.SpentTxOut
+	if needsInputs {
+		stxos, err = m.chain.FetchSpendJournal(block)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = m.db.Update(func(dbTx database.Tx) error {
+		// Skip the block when it was disconnected since it was loaded.
+		hash, ok := blockchain.DBFetchMainChainHash(dbTx, block.Height())
+		if !ok || !hash.IsEqual(block.Hash()) {
+			return nil
+		}
+
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		prevHash := &block.MsgBlock().Header.PrevBlock
+		for i, indexer := range m.enabledIndexes {
+			if !m.isBackfilling(i) {
+				continue
+			}
+			tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(prevHash) {
+				continue
+			}
+			err = dbIndexConnectBlock(dbTx, indexer, block, stxos)
+			if err != nil {
+				return err
+			}
+			err = m.dbPutBackfillProgress(dbTx, indexer, block.Hash(),
+				block.Height())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	progressLogger.LogBlockHeight(block)
+	return false, nil
+}
+
+// backfillHandler catches up the indexes which are being backfilled with the
+// best chain one block at a time, limited to the configured rate.  It must be
+// run as a goroutine.
+func (m *Manager) backfillHandler() {
+	defer m.wg.Done()
+
+	var tick <-chan time.Time
+	if m.backfillRate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(m.backfillRate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	progressLogger := newBlockProgressLogger("Backfilled", log)
+	for {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-m.quit:
+				return
+			}
+		} else {
+			select {
+			case <-m.quit:
+				return
+			default:
+			}
+		}
+
+		done, err := m.backfillBlock(progressLogger)
+		if err != nil {
+			log.Errorf("Unable to backfill indexes: %v", err)
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// SetBackfillRate sets the maximum number of blocks per second indexed by the
+// background backfill.  Zero means the rate is unlimited.  It must be called
+// before the manager is started.
+func (m *Manager) SetBackfillRate(blocksPerSecond int) {
+	m.backfillRate = blocksPerSecond
+}
+
+// Start begins backfilling the indexes which are far behind the best chain in
+// the background.  The manager must have been initialized.
+func (m *Manager) Start() {
+	// Already started?
+	if atomic.AddInt32(&m.started, 1) != 1 {
+		return
+	}
+
+	m.wg.Add(1)
+	go m.backfillHandler()
+}
+
+// Stop stops the background backfill and waits for it to finish.  An
+// unfinished backfill is resumed from its checkpoint on the next start.
+func (m *Manager) Stop() {
+	if atomic.AddInt32(&m.shutdown, 1) != 1 {
+		return
+	}
+
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// IndexStatus describes how far an index has caught up with the main chain.
+type IndexStatus struct {
+	// Indexer is the index the status describes.
+	Indexer Indexer
+
+	// BestHash and BestHeight identify the most recent block which has
+	// been indexed.  BestHeight is -1 when no block has been indexed yet.
+	BestHash   chainhash.Hash
+	BestHeight int32
+
+	// Synced is true when the index has caught up with the best block of
+	// the main chain.
+	Synced bool
+}
+
+// Status returns the status of each of the enabled indexes in the order they
+// were enabled.  None of the indexes are synced before the manager has been
+// initialized.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Status() ([]IndexStatus, error) {
+	statuses := make([]IndexStatus, 0, len(m.enabledIndexes))
+	err := m.db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		for _, indexer := range m.enabledIndexes {
+			status := IndexStatus{Indexer: indexer, BestHeight: -1}
+
+			// The index doesn't have a tip until the manager has
+			// created it.
+			if indexesBucket != nil &&
+				indexesBucket.Get(indexer.Key()) != nil {
+
+				hash, height, err := dbFetchIndexerTip(dbTx,
+					indexer.Key())
+				if err != nil {
+					return err
+				}
+				status.BestHash = *hash
+				status.BestHeight = height
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if m.chain == nil {
+		return statuses, nil
+	}
+
+	// The index tips are updated in the same database transaction as the
+	// best chain, so an index which isn't behind the best state snapshot
+	// has caught up.
+	best := m.chain.BestSnapshot()
+	for i := range statuses {
+		statuses[i].Synced = statuses[i].BestHeight >= best.Height
+	}
+	return statuses, nil
+}
+
 // NewManager returns a new index manager with the provided indexes enabled.
 //
 // The manager returned satisfies the blockchain.IndexManager interface and thus
@@ -541,6 +1036,7 @@ func NewManager(db database.DB, enabledIndexes []Indexer) *Manager {
 	return &Manager{
 		db:             db,
 		enabledIndexes: enabledIndexes,
+		quit:           make(chan struct{}),
 	}
 }
 
@@ -677,14 +1173,17 @@ func dropIndex(db database.DB, idxKey []byte, idxName string, interrupt <-chan s
 		}
 	}
 
-	// Remove the index tip, index bucket, and in-progress drop flag now
-	// that all index entries have been removed.
+	// Remove the index tip, backfill checkpoint, index bucket, and
+	// in-progress drop flag now that all index entries have been removed.
 	err = db.Update(func(dbTx database.Tx) error {
 		meta := dbTx.Metadata()
 		indexesBucket := meta.Bucket(indexTipsBucketName)
 		if err := indexesBucket.Delete(idxKey); err != nil {
 			return err
 		}
+		if err := dbRemoveBackfillCheckpoint(dbTx, idxKey); err != nil {
+			return err
+		}
 
 		return indexesBucket.Delete(indexDropKey(idxKey))
 	})
@@ -0,0 +1,175 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestManagerStatus ensures the status of the indexes reflects the blocks
+// connected to them.
+func TestManagerStatus(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(t.TempDir(), "ffldb")
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTxCountIndex(db)
+	m := NewManager(db, []Indexer{idx})
+
+	// The index has no tip before the manager created it.
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: unexpected error: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Indexer != idx ||
+		statuses[0].BestHeight != -1 || statuses[0].Synced {
+
+		t.Fatalf("Status: unexpected status %+v", statuses)
+	}
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	genesis.SetHeight(0)
+	err = db.Update(func(dbTx database.Tx) error {
+		_, err := dbTx.Metadata().CreateBucket(indexTipsBucketName)
+		if err != nil {
+			return err
+		}
+		if err := m.maybeCreateIndexes(dbTx); err != nil {
+			return err
+		}
+		return m.ConnectBlock(dbTx, genesis, nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to connect genesis block: %v", err)
+	}
+
+	statuses, err = m.Status()
+	if err != nil {
+		t.Fatalf("Status: unexpected error: %v", err)
+	}
+	if statuses[0].BestHeight != 0 ||
+		statuses[0].BestHash != *genesis.Hash() {
+
+		t.Fatalf("Status: got tip %v (height %d), want %v (height 0)",
+			statuses[0].BestHash, statuses[0].BestHeight,
+			genesis.Hash())
+	}
+}
+
+// TestManagerBackfillHandoff ensures indexes which are being backfilled are
+// only updated along with the chain once a connected block extends their tip,
+// at which point their backfill is done.
+func TestManagerBackfillHandoff(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(t.TempDir(), "ffldb")
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTxCountIndex(db)
+	m := NewManager(db, []Indexer{idx})
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	genesis.SetHeight(0)
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if _, err := meta.CreateBucket(indexTipsBucketName); err != nil {
+			return err
+		}
+		if _, err := meta.CreateBucket(indexBackfillBucketName); err != nil {
+			return err
+		}
+		if err := m.maybeCreateIndexes(dbTx); err != nil {
+			return err
+		}
+		if err := m.ConnectBlock(dbTx, genesis, nil); err != nil {
+			return err
+		}
+		return dbPutBackfillCheckpoint(dbTx, idx.Key(), 0,
+			genesis.Hash(), 0)
+	})
+	if err != nil {
+		t.Fatalf("unable to connect genesis block: %v", err)
+	}
+	m.backfilling = []bool{true}
+
+	// fetchStart returns the start height of the backfill checkpoint and
+	// whether it exists.
+	fetchStart := func() (int32, bool) {
+		var start int32
+		var ok bool
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			start, ok, err = dbFetchBackfillStart(dbTx, idx.Key())
+			return err
+		})
+		if err != nil {
+			t.Fatalf("dbFetchBackfillStart: unexpected error: %v", err)
+		}
+		return start, ok
+	}
+	if start, ok := fetchStart(); !ok || start != 0 {
+		t.Fatalf("dbFetchBackfillStart: got start %d (exists %v), "+
+			"want 0", start, ok)
+	}
+
+	// A block which doesn't extend the tip of the index is skipped.
+	orphan := btcutil.NewBlock(wire.NewMsgBlock(&wire.BlockHeader{
+		PrevBlock: chainhash.Hash{0x01},
+	}))
+	orphan.SetHeight(5)
+	err = db.Update(func(dbTx database.Tx) error {
+		return m.ConnectBlock(dbTx, orphan, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+	if _, err := idx.TxCount(orphan.Hash()); err == nil {
+		t.Fatalf("ConnectBlock: block not extending the index tip " +
+			"was indexed")
+	}
+	if !m.backfilling[0] {
+		t.Fatalf("ConnectBlock: backfill unexpectedly done")
+	}
+
+	// A block which extends the tip of the index is indexed and ends the
+	// backfill.
+	child := btcutil.NewBlock(wire.NewMsgBlock(&wire.BlockHeader{
+		PrevBlock: *genesis.Hash(),
+	}))
+	child.SetHeight(1)
+	err = db.Update(func(dbTx database.Tx) error {
+		return m.ConnectBlock(dbTx, child, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+	if _, err := idx.TxCount(child.Hash()); err != nil {
+		t.Fatalf("TxCount: unexpected error: %v", err)
+	}
+	if m.backfilling[0] {
+		t.Fatalf("ConnectBlock: backfill not done after handoff")
+	}
+	if _, ok := fetchStart(); ok {
+		t.Fatalf("ConnectBlock: backfill checkpoint not removed")
+	}
+}
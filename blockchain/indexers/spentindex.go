@@ -0,0 +1,243 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// spentIndexName is the human-readable name for the index.
+	spentIndexName = "spent index"
+
+	// spentIndexKeySize is the size of the serialized outpoint used as the
+	// key of a spent index entry.
+	spentIndexKeySize = chainhash.HashSize + 4
+
+	// spentIndexValueSize is the size of a serialized spent index entry.
+	spentIndexValueSize = chainhash.HashSize + 4 + 4
+)
+
+var (
+	// spentIndexKey is the key of the spent index and the db bucket used to
+	// house it.
+	spentIndexKey = []byte("spentidx")
+)
+
+// -----------------------------------------------------------------------------
+// The spent index consists of an entry for every output spent in the main
+// chain which maps the output to the transaction input that spends it.  This
+// allows looking up which transaction spent an output without scanning the
+// chain.
+//
+// The serialized format for keys and values in the spent index bucket is:
+//   <outpoint hash><outpoint index> = <tx hash><input index><block height>
+//
+//   Field           Type              Size
+//   outpoint hash   chainhash.Hash    32 bytes
+//   outpoint index  uint32            4 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   input index     uint32            4 bytes
+//   block height    uint32            4 bytes
+//   -----
+//   Total: 76 bytes
+// -----------------------------------------------------------------------------
+
+// SpentInfo describes the transaction input which spends an output in the
+// main chain.
+type SpentInfo struct {
+	// TxHash is the hash of the spending transaction.
+	TxHash chainhash.Hash
+
+	// InputIndex is the index of the input which spends the output within
+	// the spending transaction.
+	InputIndex uint32
+
+	// Height is the height of the block which contains the spending
+	// transaction.
+	Height int32
+}
+
+// spentIndexKeyFor returns the spent index key for the provided outpoint.
+func spentIndexKeyFor(op *wire.OutPoint) []byte {
+	key := make([]byte, spentIndexKeySize)
+	copy(key, op.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], op.Index)
+	return key
+}
+
+// dbPutSpentIndexEntry uses an existing database transaction to store the
+// transaction input which spends the provided outpoint.
+func dbPutSpentIndexEntry(dbTx database.Tx, op *wire.OutPoint, info *SpentInfo) error {
+	serialized := make([]byte, spentIndexValueSize)
+	copy(serialized, info.TxHash[:])
+	offset := chainhash.HashSize
+	byteOrder.PutUint32(serialized[offset:], info.InputIndex)
+	byteOrder.PutUint32(serialized[offset+4:], uint32(info.Height))
+
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	return bucket.Put(spentIndexKeyFor(op), serialized)
+}
+
+// dbFetchSpentIndexEntry uses an existing database transaction to fetch the
+// transaction input which spends the provided outpoint.  When there is no
+// entry for the outpoint, nil will be returned for both the entry and the
+// error.
+func dbFetchSpentIndexEntry(dbTx database.Tx, op *wire.OutPoint) (*SpentInfo, error) {
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	serialized := bucket.Get(spentIndexKeyFor(op))
+	if serialized == nil {
+		return nil, nil
+	}
+	if len(serialized) != spentIndexValueSize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt %s entry for %v",
+				spentIndexName, op),
+		}
+	}
+
+	var info SpentInfo
+	copy(info.TxHash[:], serialized[:chainhash.HashSize])
+	offset := chainhash.HashSize
+	info.InputIndex = byteOrder.Uint32(serialized[offset:])
+	info.Height = int32(byteOrder.Uint32(serialized[offset+4:]))
+	return &info, nil
+}
+
+// SpentIndex implements a spent output index.  That is to say, it supports
+// querying the transaction input which spends any output in the main chain.
+type SpentIndex struct {
+	db database.DB
+}
+
+// Ensure the SpentIndex type implements the Indexer interface.
+var _ Indexer = (*SpentIndex)(nil)
+
+// Init initializes the spent index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Key() []byte {
+	return spentIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Name() string {
+	return spentIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spentIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every output
+// spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	for _, tx := range block.Transactions()[1:] {
+		info := SpentInfo{
+			TxHash: *tx.Hash(),
+			Height: block.Height(),
+		}
+		for i, txIn := range tx.MsgTx().TxIn {
+			info.InputIndex = uint32(i)
+			err := dbPutSpentIndexEntry(dbTx, &txIn.PreviousOutPoint,
+				&info)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries of the
+// outputs spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			key := spentIndexKeyFor(&txIn.PreviousOutPoint)
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SpentInfo returns the transaction input which spends the provided outpoint
+// in the main chain.  When the outpoint hasn't been spent, nil will be returned
+// for both the entry and the error.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) SpentInfo(op *wire.OutPoint) (*SpentInfo, error) {
+	var info *SpentInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		info, err = dbFetchSpentIndexEntry(dbTx, op)
+		return err
+	})
+	return info, err
+}
+
+// NewSpentIndex returns a new instance of an indexer that is used to create a
+// mapping of every output spent in the main chain to the transaction input
+// which spends it.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpentIndex(db database.DB) *SpentIndex {
+	return &SpentIndex{db: db}
+}
+
+// DropSpentIndex drops the spent index from the provided database if it
+// exists.
+func DropSpentIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, spentIndexKey, spentIndexName, interrupt)
+}
+
+// SpentIndexInitialized returns true if the spent index has been created
+// previously.
+func SpentIndexInitialized(db database.DB) bool {
+	var exists bool
+	db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(spentIndexKey)
+		exists = bucket != nil
+		return nil
+	})
+
+	return exists
+}
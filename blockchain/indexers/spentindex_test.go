@@ -0,0 +1,105 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestSpentIndex ensures the spent index maps the outputs spent by connected
+// blocks to the inputs which spend them and removes the entries when the
+// blocks are disconnected.
+func TestSpentIndex(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(t.TempDir(), "ffldb")
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewSpentIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	if !SpentIndexInitialized(db) {
+		t.Fatalf("SpentIndexInitialized: index not initialized")
+	}
+
+	// Create a block with a coinbase and a transaction which spends two
+	// outputs.
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), nil, nil))
+	spends := []wire.OutPoint{
+		{Hash: chainhash.Hash{0x01}, Index: 0},
+		{Hash: chainhash.Hash{0x02}, Index: 3},
+	}
+	spendTx := wire.NewMsgTx(wire.TxVersion)
+	for i := range spends {
+		spendTx.AddTxIn(wire.NewTxIn(&spends[i], nil, nil))
+	}
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{})
+	msgBlock.AddTransaction(coinbase)
+	msgBlock.AddTransaction(spendTx)
+	block := btcutil.NewBlock(msgBlock)
+	block.SetHeight(7)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	for i := range spends {
+		info, err := idx.SpentInfo(&spends[i])
+		if err != nil {
+			t.Fatalf("SpentInfo: unexpected error: %v", err)
+		}
+		want := SpentInfo{
+			TxHash:     spendTx.TxHash(),
+			InputIndex: uint32(i),
+			Height:     7,
+		}
+		if info == nil || *info != want {
+			t.Fatalf("SpentInfo(%v): got %+v, want %+v", spends[i],
+				info, want)
+		}
+	}
+
+	// The coinbase input doesn't spend an output.
+	info, err := idx.SpentInfo(&coinbase.TxIn[0].PreviousOutPoint)
+	if err != nil || info != nil {
+		t.Fatalf("SpentInfo: got %+v (error %v) for coinbase input",
+			info, err)
+	}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	for i := range spends {
+		info, err := idx.SpentInfo(&spends[i])
+		if err != nil || info != nil {
+			t.Fatalf("SpentInfo(%v): got %+v (error %v) after "+
+				"disconnect", spends[i], info, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// timestampIndexName is the human-readable name for the index.
+	timestampIndexName = "timestamp index"
+
+	// medianTimeBlocks is the number of previous blocks which are used to
+	// calculate the median time of a block.  It must match the value used
+	// by the blockchain package.
+	medianTimeBlocks = 11
+)
+
+var (
+	// timestampIndexKey is the key of the timestamp index and the db bucket
+	// used to house it.
+	timestampIndexKey = []byte("tsidx")
+)
+
+// -----------------------------------------------------------------------------
+// The timestamp index consists of an entry for every block in the main chain
+// keyed by the median time of the block, which is the median of the timestamps
+// of the block and the 10 blocks before it.  Unlike the timestamps of the
+// blocks, the median times never decrease along the chain, so the blocks in a
+// range of time are found with a single scan over the ordered keys.
+//
+// The keys are serialized in big endian so the byte-wise order of the keys
+// matches the order of the median times and heights.
+//
+// The serialized format for keys and values in the timestamp index bucket is:
+//   <median time><height> = <hash>
+//
+//   Field           Type              Size
+//   median time     uint32            4 bytes
+//   height          uint32            4 bytes
+//   hash            chainhash.Hash    32 bytes
+//   -----
+//   Total: 40 bytes
+// -----------------------------------------------------------------------------
+
+// timestampIndexEntryKey returns the timestamp index key for the block with
+// the provided median time and height.
+func timestampIndexEntryKey(medianTime uint32, height int32) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint32(key, medianTime)
+	binary.BigEndian.PutUint32(key[4:], uint32(height))
+	return key
+}
+
+// dbFetchMedianTime uses an existing database transaction to calculate the
+// median time of the passed block from the headers of the blocks before it.
+func dbFetchMedianTime(dbTx database.Tx, block *btcutil.Block) (uint32, error) {
+	header := &block.MsgBlock().Header
+	timestamps := make([]int64, 0, medianTimeBlocks)
+	timestamps = append(timestamps, header.Timestamp.Unix())
+	prevHash := header.PrevBlock
+	for len(timestamps) < medianTimeBlocks && prevHash != (chainhash.Hash{}) {
+		headerBytes, err := dbTx.FetchBlockHeader(&prevHash)
+		if err != nil {
+			return 0, err
+		}
+		var prevHeader wire.BlockHeader
+		err = prevHeader.Deserialize(bytes.NewReader(headerBytes))
+		if err != nil {
+			return 0, err
+		}
+		timestamps = append(timestamps, prevHeader.Timestamp.Unix())
+		prevHash = prevHeader.PrevBlock
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	return uint32(timestamps[len(timestamps)/2]), nil
+}
+
+// TimestampIndex implements a timestamp index which maps the median times of
+// the blocks in the main chain to their hashes.
+type TimestampIndex struct {
+	db database.DB
+}
+
+// Ensure the TimestampIndex type implements the Indexer interface.
+var _ Indexer = (*TimestampIndex)(nil)
+
+// Init initializes the timestamp index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Key() []byte {
+	return timestampIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Name() string {
+	return timestampIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(timestampIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for the block keyed
+// by its median time.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	medianTime, err := dbFetchMedianTime(dbTx, block)
+	if err != nil {
+		return err
+	}
+	key := timestampIndexEntryKey(medianTime, block.Height())
+	bucket := dbTx.Metadata().Bucket(timestampIndexKey)
+	return bucket.Put(key, block.Hash()[:])
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entry of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	medianTime, err := dbFetchMedianTime(dbTx, block)
+	if err != nil {
+		return err
+	}
+	key := timestampIndexEntryKey(medianTime, block.Height())
+	return dbTx.Metadata().Bucket(timestampIndexKey).Delete(key)
+}
+
+// BlockHashesByTime returns the hashes of the blocks in the main chain with a
+// median time greater than or equal to low and less than high in the order of
+// the chain.
+//
+// This function is safe for concurrent access.
+func (idx *TimestampIndex) BlockHashesByTime(low, high uint32) ([]chainhash.Hash, error) {
+	var hashes []chainhash.Hash
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(timestampIndexKey).Cursor()
+		seek := timestampIndexEntryKey(low, 0)
+		for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+			medianTime := binary.BigEndian.Uint32(cursor.Key())
+			if medianTime >= high {
+				break
+			}
+			var hash chainhash.Hash
+			copy(hash[:], cursor.Value())
+			hashes = append(hashes, hash)
+		}
+		return nil
+	})
+	return hashes, err
+}
+
+// NewTimestampIndex returns a new instance of an indexer that is used to
+// create a mapping of the median times of all blocks in the main chain to their
+// hashes.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTimestampIndex(db database.DB) *TimestampIndex {
+	return &TimestampIndex{db: db}
+}
+
+// DropTimestampIndex drops the timestamp index from the provided database if
+// it exists.
+func DropTimestampIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, timestampIndexKey, timestampIndexName, interrupt)
+}
+
+// TimestampIndexInitialized returns true if the timestamp index has been
+// created previously.
+func TimestampIndexInitialized(db database.DB) bool {
+	var exists bool
+	db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(timestampIndexKey)
+		exists = bucket != nil
+		return nil
+	})
+
+	return exists
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestTimestampIndex ensures the timestamp index returns the blocks with a
+// median time within the requested range and removes the entries of
+// disconnected blocks.
+func TestTimestampIndex(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(t.TempDir(), "ffldb")
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTimestampIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	if !TimestampIndexInitialized(db) {
+		t.Fatalf("TimestampIndexInitialized: index not initialized")
+	}
+
+	// Create a chain of blocks which are 10 minutes apart.  The median
+	// time of a block is then the timestamp of the block in the middle of
+	// the window of up to 11 blocks ending at it.
+	const numBlocks = 20
+	base := time.Unix(1700000000, 0)
+	blocks := make([]*btcutil.Block, 0, numBlocks)
+	var prevHash chainhash.Hash
+	for i := 0; i < numBlocks; i++ {
+		block := btcutil.NewBlock(wire.NewMsgBlock(&wire.BlockHeader{
+			PrevBlock: prevHash,
+			Timestamp: base.Add(time.Duration(i) * 10 * time.Minute),
+		}))
+		block.SetHeight(int32(i))
+		blocks = append(blocks, block)
+		prevHash = *block.Hash()
+
+		err := db.Update(func(dbTx database.Tx) error {
+			if err := dbTx.StoreBlock(block); err != nil {
+				return err
+			}
+			return idx.ConnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: unexpected error: %v", err)
+		}
+	}
+	medianTime := func(height int) uint32 {
+		middle := (height + 1) / 2
+		if height >= medianTimeBlocks-1 {
+			middle = height - medianTimeBlocks/2
+		}
+		return uint32(blocks[middle].MsgBlock().Header.Timestamp.Unix())
+	}
+
+	tests := []struct {
+		low, high uint32
+	}{
+		{0, 0},
+		{0, ^uint32(0)},
+		{medianTime(4), medianTime(12)},
+		{medianTime(12), medianTime(12) + 1},
+		{medianTime(numBlocks-1) + 1, ^uint32(0)},
+	}
+	for _, test := range tests {
+		var want []chainhash.Hash
+		for i, block := range blocks {
+			mt := medianTime(i)
+			if mt >= test.low && mt < test.high {
+				want = append(want, *block.Hash())
+			}
+		}
+
+		got, err := idx.BlockHashesByTime(test.low, test.high)
+		if err != nil {
+			t.Fatalf("BlockHashesByTime: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("BlockHashesByTime(%d, %d): got %v, want %v",
+				test.low, test.high, got, want)
+		}
+	}
+
+	// The entry of a disconnected block is removed.
+	tip := blocks[numBlocks-1]
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, tip, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	got, err := idx.BlockHashesByTime(0, ^uint32(0))
+	if err != nil {
+		t.Fatalf("BlockHashesByTime: unexpected error: %v", err)
+	}
+	if len(got) != numBlocks-1 || got[len(got)-1] == *tip.Hash() {
+		t.Fatalf("BlockHashesByTime: disconnected block still indexed")
+	}
+}
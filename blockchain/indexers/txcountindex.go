@@ -0,0 +1,182 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// txCountIndexName is the human-readable name for the index.
+	txCountIndexName = "transaction count index"
+)
+
+var (
+	// txCountIndexKey is the key of the transaction count index and the db
+	// bucket used to house it.
+	txCountIndexKey = []byte("txcountidx")
+)
+
+// -----------------------------------------------------------------------------
+// The transaction count index consists of an entry for every block in the main
+// chain which holds the total number of transactions in the chain up to and
+// including the block.  This allows the number of transactions in any window
+// of blocks to be calculated from two entries without loading the blocks.
+//
+// The serialized format for keys and values in the transaction count bucket
+// is:
+//   <hash> = <tx count>
+//
+//   Field           Type              Size
+//   hash            chainhash.Hash    32 bytes
+//   tx count        uint64            8 bytes
+//   -----
+//   Total: 40 bytes
+// -----------------------------------------------------------------------------
+
+// dbPutTxCountIndexEntry uses an existing database transaction to update or add
+// the total number of transactions in the chain up to and including the block
+// with the provided hash.
+func dbPutTxCountIndexEntry(dbTx database.Tx, hash *chainhash.Hash, count uint64) error {
+	var serialized [8]byte
+	byteOrder.PutUint64(serialized[:], count)
+
+	bucket := dbTx.Metadata().Bucket(txCountIndexKey)
+	return bucket.Put(hash[:], serialized[:])
+}
+
+// dbFetchTxCountIndexEntry uses an existing database transaction to retrieve
+// the total number of transactions in the chain up to and including the block
+// with the provided hash.
+func dbFetchTxCountIndexEntry(dbTx database.Tx, hash *chainhash.Hash) (uint64, error) {
+	bucket := dbTx.Metadata().Bucket(txCountIndexKey)
+	serialized := bucket.Get(hash[:])
+	if serialized == nil {
+		return 0, fmt.Errorf("no entry in the %s for block %s",
+			txCountIndexName, hash)
+	}
+	if len(serialized) != 8 {
+		return 0, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt %s entry for block "+
+				"%s", txCountIndexName, hash),
+		}
+	}
+	return byteOrder.Uint64(serialized), nil
+}
+
+// TxCountIndex implements a transaction count index which tracks the
+// cumulative number of transactions in the main chain at every block.
+type TxCountIndex struct {
+	db database.DB
+}
+
+// Ensure the TxCountIndex type implements the Indexer interface.
+var _ Indexer = (*TxCountIndex)(nil)
+
+// Init initializes the transaction count index.
+//
+// This is part of the Indexer interface.
+func (idx *TxCountIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TxCountIndex) Key() []byte {
+	return txCountIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TxCountIndex) Name() string {
+	return txCountIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the index.
+//
+// This is part of the Indexer interface.
+func (idx *TxCountIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(txCountIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the number of transactions
+// in the block to the count of its parent.
+//
+// This is part of the Indexer interface.
+func (idx *TxCountIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	var count uint64
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	if *prevHash != (chainhash.Hash{}) {
+		var err error
+		count, err = dbFetchTxCountIndexEntry(dbTx, prevHash)
+		if err != nil {
+			return err
+		}
+	}
+	count += uint64(len(block.MsgBlock().Transactions))
+	return dbPutTxCountIndexEntry(dbTx, block.Hash(), count)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entry of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *TxCountIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbTx.Metadata().Bucket(txCountIndexKey).Delete(block.Hash()[:])
+}
+
+// TxCount returns the total number of transactions in the main chain up to and
+// including the block with the provided hash.  An error is returned when the
+// block hasn't been indexed.
+//
+// This function is safe for concurrent access.
+func (idx *TxCountIndex) TxCount(hash *chainhash.Hash) (uint64, error) {
+	var count uint64
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		count, err = dbFetchTxCountIndexEntry(dbTx, hash)
+		return err
+	})
+	return count, err
+}
+
+// NewTxCountIndex returns a new instance of an indexer that is used to track
+// the cumulative number of transactions in the main chain at every block.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTxCountIndex(db database.DB) *TxCountIndex {
+	return &TxCountIndex{db: db}
+}
+
+// TxCountIndexInitialized returns true if the transaction count index has been
+// created previously.
+func TxCountIndexInitialized(db database.DB) bool {
+	var exists bool
+	db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(txCountIndexKey)
+		exists = bucket != nil
+		return nil
+	})
+
+	return exists
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestTxCountIndex ensures the transaction count index tracks the cumulative
+// number of transactions as blocks are connected and disconnected.
+func TestTxCountIndex(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	dbPath := filepath.Join(t.TempDir(), "ffldb")
+	db, err := database.Create("ffldb", dbPath, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTxCountIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	if !TxCountIndexInitialized(db) {
+		t.Fatalf("TxCountIndexInitialized: index not initialized")
+	}
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	child := wire.NewMsgBlock(&wire.BlockHeader{
+		PrevBlock: *genesis.Hash(),
+	})
+	for i := 0; i < 3; i++ {
+		child.AddTransaction(wire.NewMsgTx(wire.TxVersion))
+	}
+	block := btcutil.NewBlock(child)
+
+	for _, b := range []*btcutil.Block{genesis, block} {
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, b, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		block *btcutil.Block
+		want  uint64
+	}{
+		{genesis, 1},
+		{block, 4},
+	}
+	for _, test := range tests {
+		count, err := idx.TxCount(test.block.Hash())
+		if err != nil {
+			t.Fatalf("TxCount: unexpected error: %v", err)
+		}
+		if count != test.want {
+			t.Fatalf("TxCount(%v): got %d, want %d",
+				test.block.Hash(), count, test.want)
+		}
+	}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	if _, err := idx.TxCount(block.Hash()); err == nil {
+		t.Fatalf("TxCount: expected error for disconnected block")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package muhash
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// elementSize is the size in bytes of the numbers the elements of the
+	// set are mapped to.
+	elementSize = 384
+
+	// SerializedSize is the size in bytes of a serialized MuHash.
+	SerializedSize = 2 * elementSize
+)
+
+var (
+	// prime is the modulus 2^3072 - 1103717, which is the largest 3072-bit
+	// safe prime.  It is defined here to avoid the overhead of creating it
+	// multiple times.
+	prime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 3072),
+		big.NewInt(1103717))
+
+	// bigOne is 1 represented as a big.Int.  It is defined here to avoid
+	// the overhead of creating it multiple times.
+	bigOne = big.NewInt(1)
+
+	// chachaNonce is the all zero nonce used to expand the hash of an
+	// element to a number.
+	chachaNonce [chacha20.NonceSize]byte
+)
+
+// MuHash is a rolling hash of a set of byte strings.  The hash of the set does
+// not depend on the order the elements were added or removed in.
+//
+// The hash is kept as a fraction of the product of the added elements over the
+// product of the removed elements, so both adding and removing an element only
+// require a single multiplication.
+type MuHash struct {
+	numerator   *big.Int
+	denominator *big.Int
+}
+
+// New returns a MuHash of the empty set.
+func New() *MuHash {
+	return &MuHash{
+		numerator:   big.NewInt(1),
+		denominator: big.NewInt(1),
+	}
+}
+
+// toNumber maps the passed data to a number modulo the prime by expanding its
+// sha256 hash with chacha20 and interpreting the result as a little-endian
+// number.
+func toNumber(data []byte) *big.Int {
+	key := sha256.Sum256(data)
+	cipher, err := chacha20.NewUnauthenticatedCipher(key[:], chachaNonce[:])
+	if err != nil {
+		// The key and nonce sizes are constant, so this is impossible.
+		panic(err)
+	}
+	var buf [elementSize]byte
+	cipher.XORKeyStream(buf[:], buf[:])
+	reverse(buf[:])
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// reverse reverses the passed bytes in place to convert between the
+// little-endian encoding used by the hash and the big-endian encoding used by
+// the big package.
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// Add adds the passed element to the set.
+func (h *MuHash) Add(data []byte) {
+	h.numerator.Mul(h.numerator, toNumber(data))
+	h.numerator.Mod(h.numerator, prime)
+}
+
+// Remove removes the passed element from the set.  Removing an element that
+// was never added results in a hash that does not correspond to any set,
+// unless the element is added later on.
+func (h *MuHash) Remove(data []byte) {
+	h.denominator.Mul(h.denominator, toNumber(data))
+	h.denominator.Mod(h.denominator, prime)
+}
+
+// Combine adds all elements of the set hashed by other to the set.
+func (h *MuHash) Combine(other *MuHash) {
+	h.numerator.Mul(h.numerator, other.numerator)
+	h.numerator.Mod(h.numerator, prime)
+	h.denominator.Mul(h.denominator, other.denominator)
+	h.denominator.Mod(h.denominator, prime)
+}
+
+// Clone returns a copy of the hash.
+func (h *MuHash) Clone() *MuHash {
+	return &MuHash{
+		numerator:   new(big.Int).Set(h.numerator),
+		denominator: new(big.Int).Set(h.denominator),
+	}
+}
+
+// normalize sets the numerator to the fraction it represents and the
+// denominator to one.
+func (h *MuHash) normalize() {
+	if h.denominator.Cmp(bigOne) == 0 {
+		return
+	}
+	inverse := new(big.Int).ModInverse(h.denominator, prime)
+	h.numerator.Mul(h.numerator, inverse)
+	h.numerator.Mod(h.numerator, prime)
+	h.denominator.SetInt64(1)
+}
+
+// Finalize returns the hash of the set.  It is the sha256 of the number the set
+// is mapped to in little-endian.  This matches the muhash computed by Bitcoin
+// Core, including the byte order used when the hash is displayed.
+func (h *MuHash) Finalize() chainhash.Hash {
+	h.normalize()
+
+	var buf [elementSize]byte
+	h.numerator.FillBytes(buf[:])
+	reverse(buf[:])
+	return chainhash.Hash(sha256.Sum256(buf[:]))
+}
+
+// Serialize returns the serialized state of the hash, which consists of the
+// little-endian numerator followed by the little-endian denominator.
+func (h *MuHash) Serialize() []byte {
+	serialized := make([]byte, SerializedSize)
+	h.numerator.FillBytes(serialized[:elementSize])
+	reverse(serialized[:elementSize])
+	h.denominator.FillBytes(serialized[elementSize:])
+	reverse(serialized[elementSize:])
+	return serialized
+}
+
+// Deserialize returns the hash with the passed serialized state as created by
+// Serialize.
+func Deserialize(serialized []byte) (*MuHash, error) {
+	if len(serialized) != SerializedSize {
+		return nil, errors.New("unexpected serialized muhash size")
+	}
+
+	buf := make([]byte, SerializedSize)
+	copy(buf, serialized)
+	reverse(buf[:elementSize])
+	reverse(buf[elementSize:])
+	h := &MuHash{
+		numerator:   new(big.Int).SetBytes(buf[:elementSize]),
+		denominator: new(big.Int).SetBytes(buf[elementSize:]),
+	}
+	if h.numerator.Cmp(prime) >= 0 || h.denominator.Cmp(prime) >= 0 ||
+		h.denominator.Sign() == 0 {
+
+		return nil, errors.New("serialized muhash is out of range")
+	}
+	return h, nil
+}
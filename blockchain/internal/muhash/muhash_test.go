@@ -0,0 +1,99 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package muhash
+
+import (
+	"testing"
+)
+
+// element returns the 32-byte element used by the Bitcoin Core tests with the
+// passed first byte.
+func element(b byte) []byte {
+	var e [32]byte
+	e[0] = b
+	return e[:]
+}
+
+// TestMuHashVector ensures the finalized hash matches the result of the
+// MuHash3072 tests of Bitcoin Core.
+func TestMuHashVector(t *testing.T) {
+	const want = "10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863"
+
+	h := New()
+	h.Add(element(0))
+	h.Add(element(1))
+	h.Remove(element(2))
+	if got := h.Finalize(); got.String() != want {
+		t.Fatalf("unexpected hash: got %v, want %v", got, want)
+	}
+}
+
+// TestMuHashSet ensures the hash only depends on the set of elements and
+// survives serialization.
+func TestMuHashSet(t *testing.T) {
+	h1 := New()
+	for i := byte(0); i < 10; i++ {
+		h1.Add(element(i))
+	}
+	h1.Remove(element(3))
+	h1.Remove(element(7))
+
+	h2 := New()
+	h2.Remove(element(7))
+	for i := byte(9); i < 10; i-- {
+		if i != 3 {
+			h2.Add(element(i))
+		}
+	}
+	h2.Add(element(7))
+	h2.Remove(element(7))
+
+	if h1.Finalize() != h2.Finalize() {
+		t.Fatalf("hashes of the same set differ: %v != %v",
+			h1.Finalize(), h2.Finalize())
+	}
+
+	// Removing every element must give the hash of the empty set.
+	h3 := h1.Clone()
+	for i := byte(0); i < 10; i++ {
+		if i != 3 && i != 7 {
+			h3.Remove(element(i))
+		}
+	}
+	if h3.Finalize() != New().Finalize() {
+		t.Fatalf("hash of the empty set differs: %v != %v",
+			h3.Finalize(), New().Finalize())
+	}
+
+	// Combining the hashes of disjoint sets must give the hash of their
+	// union.
+	h4, h5 := New(), New()
+	h4.Add(element(20))
+	h5.Add(element(21))
+	h4.Combine(h5)
+	h6 := New()
+	h6.Add(element(21))
+	h6.Add(element(20))
+	if h4.Finalize() != h6.Finalize() {
+		t.Fatalf("hash of the union differs: %v != %v",
+			h4.Finalize(), h6.Finalize())
+	}
+
+	// Ensure a serialized hash that was not normalized round trips.
+	h7 := New()
+	h7.Add(element(30))
+	h7.Remove(element(31))
+	restored, err := Deserialize(h7.Serialize())
+	if err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	if restored.Finalize() != h7.Finalize() {
+		t.Fatalf("restored hash differs: %v != %v",
+			restored.Finalize(), h7.Finalize())
+	}
+	if _, err := Deserialize(make([]byte, SerializedSize)); err == nil {
+		t.Fatal("Deserialize: expected error for zero denominator")
+	}
+}
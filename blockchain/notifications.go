@@ -29,6 +29,18 @@ const (
 	// NTBlockDisconnected indicates the associated block was disconnected
 	// from the main chain.
 	NTBlockDisconnected
+
+	// NTBlockValidated indicates the associated block passed full
+	// validation and is about to be connected to the main chain.  It is
+	// only sent for blocks that extend the main chain and were not already
+	// known to be valid.
+	NTBlockValidated
+
+	// NTReorgPending indicates a chain with more work than the main chain
+	// was found, but switching to it would disconnect more blocks than the
+	// configured maximum reorganization depth, so the reorganization is
+	// paused until it is confirmed.
+	NTReorgPending
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -37,6 +49,8 @@ var notificationTypeStrings = map[NotificationType]string{
 	NTBlockAccepted:     "NTBlockAccepted",
 	NTBlockConnected:    "NTBlockConnected",
 	NTBlockDisconnected: "NTBlockDisconnected",
+	NTBlockValidated:    "NTBlockValidated",
+	NTReorgPending:      "NTReorgPending",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -53,6 +67,8 @@ func (n NotificationType) String() string {
 //   - NTBlockAccepted:     *btcutil.Block
 //   - NTBlockConnected:    *btcutil.Block
 //   - NTBlockDisconnected: *btcutil.Block
+//   - NTBlockValidated:    *btcutil.Block
+//   - NTReorgPending:      *PendingReorg
 type Notification struct {
 	Type NotificationType
 	Data interface{}
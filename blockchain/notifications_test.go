@@ -26,9 +26,13 @@ func TestNotifications(t *testing.T) {
 	defer teardownFunc()
 
 	notificationCount := 0
+	validatedCount := 0
 	callback := func(notification *Notification) {
-		if notification.Type == NTBlockAccepted {
+		switch notification.Type {
+		case NTBlockAccepted:
 			notificationCount++
+		case NTBlockValidated:
+			validatedCount++
 		}
 	}
 
@@ -48,4 +52,85 @@ func TestNotifications(t *testing.T) {
 		t.Fatalf("Expected notification callback to be executed %d "+
 			"times, found %d", numSubscribers, notificationCount)
 	}
+	if validatedCount != numSubscribers {
+		t.Fatalf("Expected validated callback to be executed %d "+
+			"times, found %d", numSubscribers, validatedCount)
+	}
+}
+
+// TestChainEvents ensures chain event callbacks are fired in sequence with the
+// undo data of the connected and disconnected blocks.
+func TestChainEvents(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	// Create a new database and chain instance to run tests against.
+	chain, teardownFunc, err := chainSetup("chainevents",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	// Treat the second block as a checkpoint so a finalization event is
+	// sent when it is connected.
+	chain.checkpointsByHeight = map[int32]*chaincfg.Checkpoint{
+		2: {Height: 2, Hash: blocks[2].Hash()},
+	}
+
+	var events []ChainEvent
+	chain.SubscribeChainEvents(func(event *ChainEvent) {
+		events = append(events, *event)
+	})
+
+	for i := 1; i <= 2; i++ {
+		_, _, err = chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %d: %v\n", i, err)
+		}
+	}
+	if err := chain.InvalidateBlock(blocks[2].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		typ    ChainEventType
+		height int32
+	}{
+		{ChainEventBlockConnected, 1},
+		{ChainEventBlockConnected, 2},
+		{ChainEventFinalized, 2},
+		{ChainEventBlockDisconnected, 2},
+	}
+	if len(events) != len(tests) {
+		t.Fatalf("got %d chain events, want %d", len(events), len(tests))
+	}
+	for i, test := range tests {
+		event := &events[i]
+		if event.Type != test.typ || event.Height != test.height {
+			t.Errorf("event #%d: got %v at height %d, want %v at "+
+				"height %d", i, event.Type, event.Height, test.typ,
+				test.height)
+		}
+		if event.Sequence != uint64(i+1) {
+			t.Errorf("event #%d: got sequence %d, want %d", i,
+				event.Sequence, i+1)
+		}
+		if event.Type != ChainEventFinalized && event.Block == nil {
+			t.Errorf("event #%d: missing block", i)
+		}
+		if event.Block != nil &&
+			len(event.SpentTxOuts) != countSpentOutputs(event.Block) {
+
+			t.Errorf("event #%d: got %d spent outputs, want %d", i,
+				len(event.SpentTxOuts),
+				countSpentOutputs(event.Block))
+		}
+	}
 }
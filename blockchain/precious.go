@@ -0,0 +1,97 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// preferChain returns whether the chain ending at the passed node is preferred
+// over the chain ending at the passed tip.  A chain is preferred when it has
+// more cumulative work, or when it has the same work and its tip was marked
+// precious via PreciousBlock more recently than the other tip.  Otherwise the
+// chain which was seen first is kept.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) preferChain(node, tip *blockNode) bool {
+	if cmp := node.workSum.Cmp(tip.workSum); cmp != 0 {
+		return cmp > 0
+	}
+
+	nodeSeq, ok := b.preciousSeqs[node]
+	if !ok {
+		return false
+	}
+	tipSeq, ok := b.preciousSeqs[tip]
+	return !ok || nodeSeq < tipSeq
+}
+
+// PreciousBlock treats the block with the passed hash as if it were received
+// before any other block with the same cumulative work, which makes the chain
+// ending at it the main chain when it has as much work as the current main
+// chain.  A block marked later is preferred over one marked earlier, and the
+// preference is dropped once the main chain has gained more work.
+//
+// Blocks with less work than the main chain are not affected.  Neither are
+// blocks which are known to be invalid, while blocks whose data isn't
+// available yet become the tip once they are connected.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PreciousBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return fmt.Errorf("block %v is not known", hash)
+	}
+
+	tip := b.bestChain.Tip()
+	if node.workSum.Cmp(tip.workSum) < 0 {
+		return nil
+	}
+
+	// Start over when the main chain gained work since the last block was
+	// marked, since the earlier marks can't have any effect anymore.
+	if b.lastPreciousWork == nil || tip.workSum.Cmp(b.lastPreciousWork) > 0 {
+		b.preciousSeqs = make(map[*blockNode]int32)
+		b.lastPreciousSeq = 0
+	}
+	b.lastPreciousWork = new(big.Int).Set(tip.workSum)
+	b.lastPreciousSeq--
+	b.preciousSeqs[node] = b.lastPreciousSeq
+
+	if !b.preferChain(node, tip) {
+		return nil
+	}
+
+	// The chain can only be reorganized to the block once all of the
+	// blocks of its branch are available and none of them is known to be
+	// invalid.
+	fork := b.bestChain.FindFork(node)
+	for n := node; n != nil && n != fork; n = n.parent {
+		status := b.index.NodeStatus(n)
+		if status.KnownInvalid() || !status.HaveData() {
+			return nil
+		}
+	}
+
+	log.Infof("REORGANIZE: Block %v was marked precious and is causing a "+
+		"reorganize.", node.hash)
+	detachNodes, attachNodes := b.getReorganizeNodes(node)
+	err := b.reorganizeChain(detachNodes, attachNodes)
+
+	// Either getReorganizeNodes or reorganizeChain could have made unsaved
+	// changes to the block index, so flush regardless of whether there was
+	// an error.
+	if writeErr := b.index.flushToDB(); writeErr != nil {
+		log.Warnf("Error flushing block index changes to disk: %v",
+			writeErr)
+	}
+	return err
+}
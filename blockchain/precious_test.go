@@ -0,0 +1,81 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestPreciousBlock ensures marking a block precious makes it the tip when it
+// has the same work as the main chain, and that it has no effect otherwise.
+func TestPreciousBlock(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestPreciousBlock")
+	defer tearDown()
+
+	assertTip := func(block *btcutil.Block) {
+		t.Helper()
+		if tip := chain.BestSnapshot().Hash; tip != *block.Hash() {
+			t.Fatalf("got tip %v, want %v", tip, block.Hash())
+		}
+	}
+	markPrecious := func(block *btcutil.Block) {
+		t.Helper()
+		if err := chain.PreciousBlock(block.Hash()); err != nil {
+			t.Fatalf("PreciousBlock: unexpected error: %v", err)
+		}
+	}
+
+	// Create two branches with the same work on top of a common block,
+	// where the first branch becomes the main chain since it was seen
+	// first.
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	b1, b1Outs, err := addBlock(chain, genesis, nil)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	a2, _, err := addBlock(chain, b1, nil)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	s2, _, err := addBlock(chain, b1, b1Outs)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	assertTip(a2)
+
+	// Marking the side chain tip precious switches to it, and marking the
+	// old tip precious afterwards switches back.
+	markPrecious(s2)
+	assertTip(s2)
+	markPrecious(a2)
+	assertTip(a2)
+
+	// Blocks with less work than the main chain are not affected.
+	a3, _, err := addBlock(chain, a2, nil)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	markPrecious(s2)
+	assertTip(a3)
+
+	// A new block with the same work as the main chain doesn't cause a
+	// reorganize, but the previous marks are dropped once the main chain
+	// gained work, so it can be marked precious.
+	s3, _, err := addBlock(chain, s2, nil)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	assertTip(a3)
+	markPrecious(s3)
+	assertTip(s3)
+
+	// Marking an unknown block is an error.
+	if err := chain.PreciousBlock(&chainhash.Hash{}); err == nil {
+		t.Fatal("PreciousBlock: expected error for unknown block")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // BehaviorFlags is a bitmask defining tweaks to the normal behavior when
@@ -29,6 +30,12 @@ const (
 	// not be performed.
 	BFNoPoWCheck
 
+	// BFAssumeValid may be set to indicate that the block is an ancestor
+	// of a block which is assumed to be valid, so the scripts of its
+	// transactions are not verified.  All other checks are still
+	// performed.
+	BFAssumeValid
+
 	// BFNone is a convenience value to specifically indicate no flags.
 	BFNone BehaviorFlags = 0
 )
@@ -39,8 +46,10 @@ const (
 // This function is safe for concurrent access.
 func (b *BlockChain) blockExists(hash *chainhash.Hash) (bool, error) {
 	// Check block index first (could be main chain or side chain blocks).
-	if b.index.HaveBlock(hash) {
-		return true, nil
+	// Blocks which are only known by their headers don't exist yet since
+	// their data still has to be downloaded.
+	if node := b.index.LookupNode(hash); node != nil {
+		return b.index.NodeStatus(node).HaveData(), nil
 	}
 
 	// Check in the database.
@@ -170,6 +179,37 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 		return false, false, err
 	}
 
+	// The blocks of signet networks must be signed by a block solution
+	// which satisfies the challenge of the network.  Like the proof of
+	// work, the solution isn't checked when the caller requested so.
+	if b.chainParams.SignetChallenge != nil &&
+		flags&BFNoPoWCheck != BFNoPoWCheck {
+
+		err := CheckSignetBlockSolution(
+			block, b.chainParams.SignetChallenge,
+		)
+		if err != nil {
+			return false, false, err
+		}
+	}
+
+	// Blocks of the main chain which are only known by their headers, such
+	// as the blocks below the base of a loaded utxo snapshot, are already
+	// connected, so their data only needs to be stored.  This is done
+	// before the checkpoint checks since those blocks may predate the last
+	// checkpoint.
+	if node := b.index.LookupNode(blockHash); node != nil &&
+		b.bestChain.Contains(node) {
+
+		if err := b.storeBlockData(node, block, flags); err != nil {
+			return false, false, err
+		}
+
+		log.Debugf("Stored data of main chain block %v", blockHash)
+
+		return true, false, nil
+	}
+
 	// Find the previous checkpoint and perform some additional checks based
 	// on the checkpoint.  This provides a few nice properties such as
 	// preventing old side chain blocks before the last checkpoint,
@@ -242,3 +282,67 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 
 	return isMainChain, false, nil
 }
+
+// ProcessBlockHeader is the main workhorse for accepting a block header into
+// the block index without its transactions.  It performs all of the header
+// validation checks, including the ones which depend on the position of the
+// header within the block chain, and adds a node which is only known by its
+// header to the block index.  The block data may be provided later through
+// ProcessBlock, which connects the block once its data is stored.
+//
+// Headers which are already known are ignored, unless they are known to be
+// invalid.  The previous header must already be known since, unlike blocks,
+// headers are not kept as orphans.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessBlockHeader(header *wire.BlockHeader, flags BehaviorFlags) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	hash := header.BlockHash()
+	if node := b.index.LookupNode(&hash); node != nil {
+		if b.index.NodeStatus(node).KnownInvalid() {
+			str := fmt.Sprintf("block %v is known to be invalid", hash)
+			return ruleError(ErrDuplicateBlock, str)
+		}
+		return nil
+	}
+
+	prevNode := b.index.LookupNode(&header.PrevBlock)
+	if prevNode == nil {
+		str := fmt.Sprintf("previous block %s is unknown", header.PrevBlock)
+		return ruleError(ErrPreviousBlockUnknown, str)
+	} else if b.index.NodeStatus(prevNode).KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid",
+			header.PrevBlock)
+		return ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	// Reject headers that fork the main chain before the finalized block or
+	// a block whose undo data was pruned.
+	if err := b.checkFinalizedFork(prevNode); err != nil {
+		return err
+	}
+	if err := b.checkUndoPrunedFork(prevNode); err != nil {
+		return err
+	}
+
+	err := CheckBlockHeaderSanity(header, b.chainParams.PowLimit,
+		b.timeSource, flags)
+	if err != nil {
+		return err
+	}
+	err = CheckBlockHeaderContext(header, prevNode, flags, b, false)
+	if err != nil {
+		return err
+	}
+
+	b.index.AddNode(newBlockNode(header, prevNode))
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	log.Debugf("Accepted block header %v", hash)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestProcessBlockHeader ensures headers are added to the block index without
+// their data, and that the blocks are connected once their data is provided.
+func TestProcessBlockHeader(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestProcessBlockHeader")
+	defer tearDown()
+
+	assertTip := func(block *btcutil.Block) {
+		t.Helper()
+		if tip := chain.BestSnapshot().Hash; tip != *block.Hash() {
+			t.Fatalf("got tip %v, want %v", tip, block.Hash())
+		}
+	}
+	assertHaveBlock := func(block *btcutil.Block, want bool) {
+		t.Helper()
+		have, err := chain.HaveBlock(block.Hash())
+		if err != nil {
+			t.Fatalf("HaveBlock: unexpected error: %v", err)
+		}
+		if have != want {
+			t.Fatalf("HaveBlock %v: got %v, want %v", block.Hash(),
+				have, want)
+		}
+	}
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	b1, _, err := addBlock(chain, genesis, nil)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	b2, _, err := newBlock(chain, b1, nil)
+	if err != nil {
+		t.Fatalf("newBlock: %v", err)
+	}
+	b3, _, err := newBlock(chain, b2, nil)
+	if err != nil {
+		t.Fatalf("newBlock: %v", err)
+	}
+
+	// The header is known once accepted, but the block is neither stored
+	// nor connected.  Accepting it again is not an error.
+	for i := 0; i < 2; i++ {
+		err := chain.ProcessBlockHeader(&b2.MsgBlock().Header, BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlockHeader: unexpected error: %v", err)
+		}
+	}
+	if _, err := chain.HeaderByHash(b2.Hash()); err != nil {
+		t.Fatalf("HeaderByHash: unexpected error: %v", err)
+	}
+	assertHaveBlock(b2, false)
+	assertTip(b1)
+
+	// Headers must connect to a known header.
+	header := b3.MsgBlock().Header
+	header.PrevBlock = chainhash.Hash{0x01}
+	err = chain.ProcessBlockHeader(&header, BFNone)
+	var ruleErr RuleError
+	if !errors.As(err, &ruleErr) ||
+		ruleErr.ErrorCode != ErrPreviousBlockUnknown {
+
+		t.Fatalf("ProcessBlockHeader: got %v, want %v", err,
+			ErrPreviousBlockUnknown)
+	}
+
+	// A block whose parent is only known by its header is an orphan until
+	// the data of the parent is provided, which connects both of them.
+	_, isOrphan, err := chain.ProcessBlock(b3, BFNone)
+	if err != nil {
+		t.Fatalf("ProcessBlock: unexpected error: %v", err)
+	}
+	if !isOrphan {
+		t.Fatal("ProcessBlock: expected orphan block")
+	}
+	if _, _, err := chain.ProcessBlock(b2, BFNone); err != nil {
+		t.Fatalf("ProcessBlock: unexpected error: %v", err)
+	}
+	assertHaveBlock(b2, true)
+	assertTip(b3)
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ErrNoPendingReorg is returned by ConfirmReorg when no reorganization is
+// waiting for confirmation.
+var ErrNoPendingReorg = errors.New("no reorganization is pending " +
+	"confirmation")
+
+// PendingReorg describes a reorganization of the main chain that would
+// disconnect more blocks than the configured maximum reorganization depth and
+// is therefore paused until it is confirmed via ConfirmReorg.
+type PendingReorg struct {
+	// ForkHash and ForkHeight identify the last block the main chain and
+	// the competing chain have in common.
+	ForkHash   chainhash.Hash
+	ForkHeight int32
+
+	// OldTipHash and OldTipHeight identify the tip of the main chain.
+	OldTipHash   chainhash.Hash
+	OldTipHeight int32
+
+	// NewTipHash and NewTipHeight identify the tip of the competing chain
+	// that has more work than the main chain.
+	NewTipHash   chainhash.Hash
+	NewTipHeight int32
+
+	// Depth is the number of main chain blocks the reorganization would
+	// disconnect.
+	Depth int32
+
+	// Detected is the time the competing chain first had more work than
+	// the main chain.
+	Detected time.Time
+}
+
+// reorgNeedsConfirmation returns whether making the passed node, which has
+// more work than the tip of the main chain, the new tip requires a
+// reorganization deeper than the configured maximum depth.  In that case the
+// reorganization is recorded as pending and an NTReorgPending notification is
+// sent.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorgNeedsConfirmation(node *blockNode) bool {
+	if b.maxReorgDepth <= 0 {
+		return false
+	}
+
+	tip := b.bestChain.Tip()
+	fork := b.bestChain.FindFork(node)
+	depth := tip.height - fork.height
+	if depth <= b.maxReorgDepth {
+		return false
+	}
+
+	// Keep the detection time while the same fork keeps growing.
+	detected := time.Now()
+	if prev := b.pendingReorg; prev != nil && prev.ForkHash == fork.hash &&
+		prev.OldTipHash == tip.hash {
+
+		detected = prev.Detected
+	}
+	pending := &PendingReorg{
+		ForkHash:     fork.hash,
+		ForkHeight:   fork.height,
+		OldTipHash:   tip.hash,
+		OldTipHeight: tip.height,
+		NewTipHash:   node.hash,
+		NewTipHeight: node.height,
+		Depth:        depth,
+		Detected:     detected,
+	}
+	b.pendingReorg = pending
+	b.pendingReorgNode = node
+
+	log.Warnf("REORGANIZE PAUSED: Block %v (height %d) would disconnect "+
+		"%d blocks back to the fork at block %v (height %d), which "+
+		"exceeds the maximum reorganize depth of %d.  Use the "+
+		"confirmreorg RPC to proceed.", node.hash, node.height, depth,
+		fork.hash, fork.height, b.maxReorgDepth)
+	b.sendNotification(NTReorgPending, pending)
+	return true
+}
+
+// validPendingReorg returns the pending reorganization when the competing
+// chain it would switch to still has more work than the main chain and is not
+// known to be invalid.  Stale pending reorganizations are cleared.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) validPendingReorg() *PendingReorg {
+	node := b.pendingReorgNode
+	if node == nil {
+		return nil
+	}
+	if node.workSum.Cmp(b.bestChain.Tip().workSum) <= 0 ||
+		b.index.NodeStatus(node).KnownInvalid() {
+
+		b.pendingReorg = nil
+		b.pendingReorgNode = nil
+		return nil
+	}
+	return b.pendingReorg
+}
+
+// PendingReorg returns the reorganization that is paused because it exceeds
+// the configured maximum reorganization depth, or nil when there is none.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PendingReorg() *PendingReorg {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	pending := b.validPendingReorg()
+	if pending == nil {
+		return nil
+	}
+	pendingCopy := *pending
+	return &pendingCopy
+}
+
+// ConfirmReorg performs the pending reorganization to the competing chain with
+// the passed tip hash that was paused because it exceeds the configured
+// maximum reorganization depth.  Requiring the hash ensures the operator
+// confirms the reorganization they reviewed rather than a later one.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ConfirmReorg(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	pending := b.validPendingReorg()
+	if pending == nil {
+		return ErrNoPendingReorg
+	}
+	if *hash != pending.NewTipHash {
+		return fmt.Errorf("block %v is not the tip %v of the pending "+
+			"reorganization", hash, pending.NewTipHash)
+	}
+	node := b.pendingReorgNode
+	b.pendingReorg = nil
+	b.pendingReorgNode = nil
+
+	log.Infof("REORGANIZE: Confirmed reorganize to block %v disconnecting "+
+		"%d blocks", node.hash, pending.Depth)
+	detachNodes, attachNodes := b.getReorganizeNodes(node)
+	err := b.reorganizeChain(detachNodes, attachNodes)
+
+	// Either getReorganizeNodes or reorganizeChain could have made unsaved
+	// changes to the block index, so flush regardless of whether there was
+	// an error.
+	if writeErr := b.index.flushToDB(); writeErr != nil {
+		log.Warnf("Error flushing block index changes to disk: %v",
+			writeErr)
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain/internal/testhelper"
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// TestMaxReorgDepth ensures reorganizations deeper than the maximum depth are
+// paused until they are confirmed while shallower ones proceed.
+func TestMaxReorgDepth(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestMaxReorgDepth")
+	defer tearDown()
+	chain.maxReorgDepth = 2
+
+	var pendingNtfns []*PendingReorg
+	chain.Subscribe(func(n *Notification) {
+		if n.Type == NTReorgPending {
+			pendingNtfns = append(pendingNtfns, n.Data.(*PendingReorg))
+		}
+	})
+
+	// addBlocks adds count blocks on top of prev, spending the passed
+	// outputs in the first one, and returns the last block.
+	addBlocks := func(prev *btcutil.Block, count int,
+		spends []*testhelper.SpendableOut) *btcutil.Block {
+
+		t.Helper()
+		for i := 0; i < count; i++ {
+			block, _, err := addBlock(chain, prev, spends)
+			if err != nil {
+				t.Fatalf("addBlock: unexpected error: %v", err)
+			}
+			prev = block
+			spends = nil
+		}
+		return prev
+	}
+
+	// Create a main chain with 4 blocks that don't spend anything so the
+	// outputs of the first blocks can be spent to create side chains that
+	// differ from the main chain.
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	b1, b1Outs, err := addBlock(chain, genesis, nil)
+	if err != nil {
+		t.Fatalf("addBlock: unexpected error: %v", err)
+	}
+	b2, b2Outs, err := addBlock(chain, b1, nil)
+	if err != nil {
+		t.Fatalf("addBlock: unexpected error: %v", err)
+	}
+	b3 := addBlocks(b2, 1, nil)
+	addBlocks(b3, 1, nil)
+
+	// A reorganization that disconnects a single block must proceed.
+	shallowTip := addBlocks(b3, 2, b1Outs)
+	if tip := chain.BestSnapshot().Hash; tip != *shallowTip.Hash() {
+		t.Fatalf("got tip %v, want %v", tip, shallowTip.Hash())
+	}
+	if pending := chain.PendingReorg(); pending != nil {
+		t.Fatalf("unexpected pending reorganization %+v", pending)
+	}
+
+	// A side chain forking after the first block with more work than the
+	// main chain would disconnect 4 blocks, so the reorganization must be
+	// paused.
+	deepTip := addBlocks(b1, 5, b1Outs)
+	if tip := chain.BestSnapshot().Hash; tip != *shallowTip.Hash() {
+		t.Fatalf("got tip %v, want %v", tip, shallowTip.Hash())
+	}
+	pending := chain.PendingReorg()
+	if pending == nil {
+		t.Fatal("no pending reorganization")
+	}
+	if pending.ForkHash != *b1.Hash() || pending.ForkHeight != 1 ||
+		pending.OldTipHash != *shallowTip.Hash() ||
+		pending.OldTipHeight != 5 || pending.NewTipHash != *deepTip.Hash() ||
+		pending.NewTipHeight != 6 || pending.Depth != 4 {
+
+		t.Fatalf("unexpected pending reorganization %+v", pending)
+	}
+	if len(pendingNtfns) != 1 {
+		t.Fatalf("got %d pending reorganization notifications, want 1",
+			len(pendingNtfns))
+	}
+
+	// Only the tip of the pending reorganization can be confirmed.
+	if err := chain.ConfirmReorg(shallowTip.Hash()); err == nil {
+		t.Fatal("ConfirmReorg: expected error for wrong tip")
+	}
+	if err := chain.ConfirmReorg(deepTip.Hash()); err != nil {
+		t.Fatalf("ConfirmReorg: unexpected error: %v", err)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *deepTip.Hash() {
+		t.Fatalf("got tip %v, want %v", tip, deepTip.Hash())
+	}
+	if pending := chain.PendingReorg(); pending != nil {
+		t.Fatalf("unexpected pending reorganization %+v", pending)
+	}
+	if err := chain.ConfirmReorg(deepTip.Hash()); err != ErrNoPendingReorg {
+		t.Fatalf("ConfirmReorg: got %v, want %v", err,
+			ErrNoPendingReorg)
+	}
+
+	// A pending reorganization is dropped once the main chain has more
+	// work again.
+	otherTip := addBlocks(b3, 4, b2Outs)
+	if chain.PendingReorg() == nil {
+		t.Fatal("no pending reorganization")
+	}
+	addBlocks(deepTip, 1, nil)
+	if pending := chain.PendingReorg(); pending != nil {
+		t.Fatalf("unexpected pending reorganization %+v", pending)
+	}
+	if err := chain.ConfirmReorg(otherTip.Hash()); err != ErrNoPendingReorg {
+		t.Fatalf("ConfirmReorg: got %v, want %v", err,
+			ErrNoPendingReorg)
+	}
+}
@@ -8,13 +8,34 @@ import (
 	"fmt"
 	"math"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
 
+const (
+	// maxScriptValidationBatch is the maximum number of inputs handed to a
+	// validation goroutine at once.  Handing out the inputs in batches
+	// amortizes the channel synchronization over several signature checks
+	// while still spreading the work of a block evenly among the
+	// goroutines.  The BIP 340 signatures of the taproot inputs of each
+	// batch are verified together with batch verification when validating
+	// blocks.
+	maxScriptValidationBatch = 16
+)
+
+// DefaultScriptValidationWorkers returns the number of goroutines used to
+// validate scripts when no specific number is configured.  It is based on the
+// number of processor cores, which helps ensure the system stays reasonably
+// responsive under heavy load.
+func DefaultScriptValidationWorkers() int {
+	return runtime.NumCPU() * 3
+}
+
 // txValidateItem holds a transaction along with which input to validate.
 type txValidateItem struct {
 	txInIndex int
@@ -27,13 +48,23 @@ type txValidateItem struct {
 // inputs.  It provides several channels for communication and a processing
 // function that is intended to be in run multiple goroutines.
 type txValidator struct {
-	validateChan chan *txValidateItem
+	// The following variables must only be used atomically.  They are
+	// the number of batches of schnorr signatures verified at once, the
+	// number of signatures in them, and the number of those batches that
+	// failed verification.
+	schnorrBatches       uint64
+	schnorrBatchSigs     uint64
+	schnorrBatchFailures uint64
+
+	validateChan chan []*txValidateItem
 	quitChan     chan struct{}
 	resultChan   chan error
 	utxoView     *UtxoViewpoint
 	flags        txscript.ScriptFlags
 	sigCache     *txscript.SigCache
 	hashCache    *txscript.HashCache
+	workers      int
+	batchSigs    bool
 }
 
 // sendResult sends the result of a script pair validation on the internal
@@ -47,68 +78,147 @@ func (v *txValidator) sendResult(result error) {
 	}
 }
 
-// validateHandler consumes items to validate from the internal validate channel
-// and returns the result of the validation on the internal result channel. It
-// must be run as a goroutine.
+// validateItem validates the script pair of the passed item.  The verification
+// of the schnorr signatures of taproot spends is deferred to the passed batch
+// when it is not nil.
+func (v *txValidator) validateItem(txVI *txValidateItem,
+	sigBatch *txscript.SchnorrBatch) error {
+
+	// Ensure the referenced input utxo is available.
+	txIn := txVI.txIn
+	utxo := v.utxoView.LookupEntry(txIn.PreviousOutPoint)
+	if utxo == nil {
+		str := fmt.Sprintf("unable to find unspent output %v "+
+			"referenced from transaction %s:%d",
+			txIn.PreviousOutPoint, txVI.tx.Hash(), txVI.txInIndex)
+		return ruleError(ErrMissingTxOut, str)
+	}
+
+	// Create a new script engine for the script pair.
+	sigScript := txIn.SignatureScript
+	witness := txIn.Witness
+	pkScript := utxo.PkScript()
+	inputAmount := utxo.Amount()
+	vm, err := txscript.NewEngine(
+		pkScript, txVI.tx.MsgTx(), txVI.txInIndex,
+		v.flags, v.sigCache, txVI.sigHashes,
+		inputAmount, v.utxoView,
+	)
+	if err != nil {
+		str := fmt.Sprintf("failed to parse input "+
+			"%s:%d which references output %v - "+
+			"%v (input witness %x, input script "+
+			"bytes %x, prev output script bytes %x)",
+			txVI.tx.Hash(), txVI.txInIndex,
+			txIn.PreviousOutPoint, err, witness,
+			sigScript, pkScript)
+		return ruleError(ErrScriptMalformed, str)
+	}
+	if sigBatch != nil {
+		vm.SetSchnorrBatch(sigBatch)
+	}
+
+	// Execute the script pair.
+	if err := vm.Execute(); err != nil {
+		str := fmt.Sprintf("failed to validate input "+
+			"%s:%d which references output %v - "+
+			"%v (input witness %x, input script "+
+			"bytes %x, prev output script bytes %x)",
+			txVI.tx.Hash(), txVI.txInIndex,
+			txIn.PreviousOutPoint, err, witness,
+			sigScript, pkScript)
+		return ruleError(ErrScriptValidation, str)
+	}
+
+	return nil
+}
+
+// validateItems validates the script pairs of the passed items.  When the
+// validator batches signatures, the schnorr signatures of all of the items are
+// verified at once after executing their scripts, and the verified signatures
+// are added to the signature cache.  Since the batch verification doesn't tell
+// which signature is invalid, the offending input is found by bisecting the
+// items when it fails.
+func (v *txValidator) validateItems(items []*txValidateItem) error {
+	if !v.batchSigs {
+		for _, txVI := range items {
+			if err := v.validateItem(txVI, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sigBatch := txscript.NewSchnorrBatch(v.sigCache)
+	for _, txVI := range items {
+		if err := v.validateItem(txVI, sigBatch); err != nil {
+			return err
+		}
+	}
+	numSigs := sigBatch.Len()
+	if numSigs == 0 {
+		return nil
+	}
+	atomic.AddUint64(&v.schnorrBatches, 1)
+	atomic.AddUint64(&v.schnorrBatchSigs, uint64(numSigs))
+	if sigBatch.Verify() {
+		return nil
+	}
+
+	atomic.AddUint64(&v.schnorrBatchFailures, 1)
+	if err := v.bisectItems(items); err != nil {
+		return err
+	}
+	log.Warnf("Batch verification of %d schnorr signatures failed even "+
+		"though they are all valid", numSigs)
+	return nil
+}
+
+// bisectItems finds the input with an invalid schnorr signature among the
+// passed items, whose signatures failed batch verification, and returns its
+// validation error.  The items are split in halves whose signatures are
+// verified as separate batches, descending into a half that fails until a
+// single input is left, which is validated without deferring its signatures
+// to get its error.  The signatures of the halves that verify are added to the
+// signature cache, so they aren't verified again.  It returns nil when all of
+// the signatures turn out to be valid.
+func (v *txValidator) bisectItems(items []*txValidateItem) error {
+	if len(items) == 1 {
+		return v.validateItem(items[0], nil)
+	}
+
+	mid := len(items) / 2
+	for _, half := range [][]*txValidateItem{items[:mid], items[mid:]} {
+		sigBatch := txscript.NewSchnorrBatch(v.sigCache)
+		for _, txVI := range half {
+			if err := v.validateItem(txVI, sigBatch); err != nil {
+				return err
+			}
+		}
+		if sigBatch.Verify() {
+			continue
+		}
+		if err := v.bisectItems(half); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateHandler consumes batches of items to validate from the internal
+// validate channel and returns the result of the validation of each batch on
+// the internal result channel. It must be run as a goroutine.
 func (v *txValidator) validateHandler() {
 out:
 	for {
 		select {
-		case txVI := <-v.validateChan:
-			// Ensure the referenced input utxo is available.
-			txIn := txVI.txIn
-			utxo := v.utxoView.LookupEntry(txIn.PreviousOutPoint)
-			if utxo == nil {
-				str := fmt.Sprintf("unable to find unspent "+
-					"output %v referenced from "+
-					"transaction %s:%d",
-					txIn.PreviousOutPoint, txVI.tx.Hash(),
-					txVI.txInIndex)
-				err := ruleError(ErrMissingTxOut, str)
-				v.sendResult(err)
-				break out
-			}
-
-			// Create a new script engine for the script pair.
-			sigScript := txIn.SignatureScript
-			witness := txIn.Witness
-			pkScript := utxo.PkScript()
-			inputAmount := utxo.Amount()
-			vm, err := txscript.NewEngine(
-				pkScript, txVI.tx.MsgTx(), txVI.txInIndex,
-				v.flags, v.sigCache, txVI.sigHashes,
-				inputAmount, v.utxoView,
-			)
+		case batch := <-v.validateChan:
+			err := v.validateItems(batch)
+			v.sendResult(err)
 			if err != nil {
-				str := fmt.Sprintf("failed to parse input "+
-					"%s:%d which references output %v - "+
-					"%v (input witness %x, input script "+
-					"bytes %x, prev output script bytes %x)",
-					txVI.tx.Hash(), txVI.txInIndex,
-					txIn.PreviousOutPoint, err, witness,
-					sigScript, pkScript)
-				err := ruleError(ErrScriptMalformed, str)
-				v.sendResult(err)
-				break out
-			}
-
-			// Execute the script pair.
-			if err := vm.Execute(); err != nil {
-				str := fmt.Sprintf("failed to validate input "+
-					"%s:%d which references output %v - "+
-					"%v (input witness %x, input script "+
-					"bytes %x, prev output script bytes %x)",
-					txVI.tx.Hash(), txVI.txInIndex,
-					txIn.PreviousOutPoint, err, witness,
-					sigScript, pkScript)
-				err := ruleError(ErrScriptValidation, str)
-				v.sendResult(err)
 				break out
 			}
 
-			// Validation succeeded.
-			v.sendResult(nil)
-
 		case <-v.quitChan:
 			break out
 		}
@@ -122,10 +232,12 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 		return nil
 	}
 
-	// Limit the number of goroutines to do script validation based on the
-	// number of processor cores.  This helps ensure the system stays
-	// reasonably responsive under heavy load.
-	maxGoRoutines := runtime.NumCPU() * 3
+	// Limit the number of goroutines to do script validation to the
+	// configured number of workers.
+	maxGoRoutines := v.workers
+	if maxGoRoutines <= 0 {
+		maxGoRoutines = DefaultScriptValidationWorkers()
+	}
 	if maxGoRoutines <= 0 {
 		maxGoRoutines = 1
 	}
@@ -133,35 +245,51 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 		maxGoRoutines = len(items)
 	}
 
+	// Split the inputs into batches that are small enough for every
+	// goroutine to get several of them so a batch of expensive inputs
+	// doesn't hold up the others.
+	batchSize := len(items) / (maxGoRoutines * 4)
+	if batchSize > maxScriptValidationBatch {
+		batchSize = maxScriptValidationBatch
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
 	// Start up validation handlers that are used to asynchronously
-	// validate each transaction input.
+	// validate each batch of transaction inputs.
 	for i := 0; i < maxGoRoutines; i++ {
 		go v.validateHandler()
 	}
 
-	// Validate each of the inputs.  The quit channel is closed when any
+	// Validate each of the batches.  The quit channel is closed when any
 	// errors occur so all processing goroutines exit regardless of which
 	// input had the validation error.
-	numInputs := len(items)
-	currentItem := 0
-	processedItems := 0
-	for processedItems < numInputs {
-		// Only send items while there are still items that need to
+	numBatches := (len(items) + batchSize - 1) / batchSize
+	currentBatch := 0
+	processedBatches := 0
+	for processedBatches < numBatches {
+		// Only send batches while there are still batches that need to
 		// be processed.  The select statement will never select a nil
 		// channel.
-		var validateChan chan *txValidateItem
-		var item *txValidateItem
-		if currentItem < numInputs {
+		var validateChan chan []*txValidateItem
+		var batch []*txValidateItem
+		if currentBatch < numBatches {
 			validateChan = v.validateChan
-			item = items[currentItem]
+			start := currentBatch * batchSize
+			end := start + batchSize
+			if end > len(items) {
+				end = len(items)
+			}
+			batch = items[start:end]
 		}
 
 		select {
-		case validateChan <- item:
-			currentItem++
+		case validateChan <- batch:
+			currentBatch++
 
 		case err := <-v.resultChan:
-			processedItems++
+			processedBatches++
 			if err != nil {
 				close(v.quitChan)
 				return err
@@ -174,17 +302,21 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 }
 
 // newTxValidator returns a new instance of txValidator to be used for
-// validating transaction scripts asynchronously.
+// validating transaction scripts asynchronously with up to the passed number
+// of goroutines.  A number of zero selects DefaultScriptValidationWorkers.
 func newTxValidator(utxoView *UtxoViewpoint, flags txscript.ScriptFlags,
-	sigCache *txscript.SigCache, hashCache *txscript.HashCache) *txValidator {
+	sigCache *txscript.SigCache, hashCache *txscript.HashCache,
+	workers int) *txValidator {
+
 	return &txValidator{
-		validateChan: make(chan *txValidateItem),
+		validateChan: make(chan []*txValidateItem),
 		quitChan:     make(chan struct{}),
 		resultChan:   make(chan error),
 		utxoView:     utxoView,
 		sigCache:     sigCache,
 		hashCache:    hashCache,
 		flags:        flags,
+		workers:      workers,
 	}
 }
 
@@ -236,15 +368,27 @@ func ValidateTransactionScripts(tx *btcutil.Tx, utxoView *UtxoViewpoint,
 	}
 
 	// Validate all of the inputs.
-	validator := newTxValidator(utxoView, flags, sigCache, hashCache)
+	validator := newTxValidator(utxoView, flags, sigCache, hashCache, 0)
 	return validator.Validate(txValItems)
 }
 
+// scriptValidationResult describes the validation of the scripts of a block.
+type scriptValidationResult struct {
+	inputs               int
+	duration             time.Duration
+	schnorrBatches       uint64
+	schnorrBatchSigs     uint64
+	schnorrBatchFailures uint64
+}
+
 // checkBlockScripts executes and validates the scripts for all transactions in
-// the passed block using multiple goroutines.
+// the passed block using up to the passed number of goroutines.  The schnorr
+// signatures of taproot spends are verified in batches when taproot is active.
+// It returns the number of validated inputs along with the time it took to
+// validate them and how the signatures were batched.
 func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache,
-	hashCache *txscript.HashCache) error {
+	hashCache *txscript.HashCache, workers int) (*scriptValidationResult, error) {
 
 	// First determine if segwit is active according to the scriptFlags. If
 	// it isn't then we don't need to interact with the HashCache.
@@ -299,15 +443,15 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 	}
 
 	// Validate all of the inputs.
-	validator := newTxValidator(utxoView, scriptFlags, sigCache, hashCache)
+	validator := newTxValidator(utxoView, scriptFlags, sigCache, hashCache,
+		workers)
+	validator.batchSigs = scriptFlags&txscript.ScriptVerifyTaproot != 0
 	start := time.Now()
 	if err := validator.Validate(txValItems); err != nil {
-		return err
+		return nil, err
 	}
 	elapsed := time.Since(start)
 
-	log.Tracef("block %v took %v to verify", block.Hash(), elapsed)
-
 	// If the HashCache is present, once we have validated the block, we no
 	// longer need the cached hashes for these transactions, so we purge
 	// them from the cache.
@@ -319,5 +463,86 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 		}
 	}
 
-	return nil
+	return &scriptValidationResult{
+		inputs:               len(txValItems),
+		duration:             elapsed,
+		schnorrBatches:       validator.schnorrBatches,
+		schnorrBatchSigs:     validator.schnorrBatchSigs,
+		schnorrBatchFailures: validator.schnorrBatchFailures,
+	}, nil
+}
+
+// ScriptValidationStats houses timing statistics about the script validation
+// performed while connecting blocks.
+type ScriptValidationStats struct {
+	// Workers is the maximum number of goroutines used to validate the
+	// scripts of a block.
+	Workers int
+
+	// LastBlockHash and LastBlockHeight identify the most recent block
+	// whose scripts were validated.
+	LastBlockHash   chainhash.Hash
+	LastBlockHeight int32
+
+	// LastBlockInputs is the number of inputs of the most recent block
+	// that were validated and LastBlockDuration is the time it took to
+	// validate them.
+	LastBlockInputs   int
+	LastBlockDuration time.Duration
+
+	// Blocks, Inputs, and Duration are the totals of all blocks whose
+	// scripts were validated since the chain instance was created.
+	Blocks   uint64
+	Inputs   uint64
+	Duration time.Duration
+
+	// SchnorrBatches is the number of batches of BIP 340 signatures that
+	// were verified at once and SchnorrBatchSigs is the total number of
+	// signatures in them.  SchnorrBatchFailures is the number of batches
+	// that failed verification, which required verifying the signatures
+	// individually to find the invalid one.
+	SchnorrBatches       uint64
+	SchnorrBatchSigs     uint64
+	SchnorrBatchFailures uint64
+}
+
+// recordScriptValidation records the result of the validation of the scripts
+// of the block represented by the passed node.
+func (b *BlockChain) recordScriptValidation(node *blockNode,
+	result *scriptValidationResult) {
+
+	b.scriptStatsLock.Lock()
+	stats := &b.scriptStats
+	stats.LastBlockHash = node.hash
+	stats.LastBlockHeight = node.height
+	stats.LastBlockInputs = result.inputs
+	stats.LastBlockDuration = result.duration
+	stats.Blocks++
+	stats.Inputs += uint64(result.inputs)
+	stats.Duration += result.duration
+	stats.SchnorrBatches += result.schnorrBatches
+	stats.SchnorrBatchSigs += result.schnorrBatchSigs
+	stats.SchnorrBatchFailures += result.schnorrBatchFailures
+	b.scriptStatsLock.Unlock()
+
+	log.Debugf("Validated %d inputs of block %v (height %d) in %v "+
+		"(%d schnorr signatures in %d batches)", result.inputs,
+		node.hash, node.height, result.duration,
+		result.schnorrBatchSigs, result.schnorrBatches)
+}
+
+// ScriptValidationStats returns timing statistics about the script validation
+// performed while connecting blocks.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ScriptValidationStats() ScriptValidationStats {
+	b.scriptStatsLock.Lock()
+	stats := b.scriptStats
+	b.scriptStatsLock.Unlock()
+
+	stats.Workers = b.scriptWorkers
+	if stats.Workers <= 0 {
+		stats.Workers = DefaultScriptValidationWorkers()
+	}
+	return stats
 }
@@ -6,9 +6,15 @@ package blockchain
 
 import (
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // TestCheckBlockScripts ensures that validating the all of the scripts in a
@@ -37,10 +43,188 @@ func TestCheckBlockScripts(t *testing.T) {
 		return
 	}
 
+	wantInputs := 0
+	for _, tx := range blocks[0].Transactions()[1:] {
+		wantInputs += len(tx.MsgTx().TxIn)
+	}
+
+	// Validate the scripts with various numbers of goroutines, including
+	// the default and more goroutines than there are inputs.
 	scriptFlags := txscript.ScriptBip16
-	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil)
+	for _, workers := range []int{0, 1, 2, wantInputs + 1} {
+		result, err := checkBlockScripts(blocks[0], view,
+			scriptFlags, nil, nil, workers)
+		if err != nil {
+			t.Errorf("Transaction script validation with %d "+
+				"workers failed: %v\n", workers, err)
+			return
+		}
+		if result.inputs != wantInputs {
+			t.Errorf("Validated %d inputs with %d workers, want %d",
+				result.inputs, workers, wantInputs)
+		}
+	}
+}
+
+// TestScriptValidationStats ensures the script validation of connected blocks
+// is recorded in the statistics.
+func TestScriptValidationStats(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestScriptValidationStats")
+	defer tearDown()
+	chain.scriptWorkers = 2
+
+	tip := btcutil.NewBlock(params.GenesisBlock)
+	b1, outs, err := addBlock(chain, tip, nil)
 	if err != nil {
-		t.Errorf("Transaction script validation failed: %v\n", err)
-		return
+		t.Fatal(err)
+	}
+	b2, _, err := addBlock(chain, b1, outs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := chain.ScriptValidationStats()
+	if stats.Workers != 2 || stats.Blocks != 2 ||
+		stats.Inputs != uint64(len(outs)) {
+
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.LastBlockHash != *b2.Hash() || stats.LastBlockHeight != 2 ||
+		stats.LastBlockInputs != len(outs) {
+
+		t.Fatalf("unexpected last block stats %+v", stats)
+	}
+}
+
+// TestSchnorrBatchValidation ensures the schnorr signatures of taproot spends
+// are verified in batches and that an invalid signature is attributed to the
+// input it belongs to.
+func TestSchnorrBatchValidation(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to create private key: %v", err)
+	}
+	pkScript, err := txscript.PayToTaprootScript(
+		txscript.ComputeTaprootKeyNoScript(privKey.PubKey()),
+	)
+	if err != nil {
+		t.Fatalf("unable to create taproot script: %v", err)
+	}
+
+	// Create a transaction with taproot outputs and another one that
+	// spends all of them through the key path.
+	const numInputs = 40
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+	})
+	for i := 0; i < numInputs; i++ {
+		fundingTx.AddTxOut(wire.NewTxOut(1e8, pkScript))
+	}
+	view := NewUtxoViewpoint()
+	view.AddTxOuts(btcutil.NewTx(fundingTx), 1)
+
+	spendTx := wire.NewMsgTx(2)
+	for i := 0; i < numInputs; i++ {
+		spendTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{
+				Hash:  fundingTx.TxHash(),
+				Index: uint32(i),
+			},
+		})
+	}
+	spendTx.AddTxOut(wire.NewTxOut(numInputs*1e8-1000, pkScript))
+	sigHashes := txscript.NewTxSigHashes(spendTx, view)
+	for i, txIn := range spendTx.TxIn {
+		txIn.Witness, err = txscript.TaprootWitnessSignature(
+			spendTx, sigHashes, i, 1e8, pkScript,
+			txscript.SigHashDefault, privKey,
+		)
+		if err != nil {
+			t.Fatalf("unable to sign input %d: %v", i, err)
+		}
+	}
+
+	// validate validates all of the inputs of the passed transaction with
+	// signature batching.
+	flags := txscript.StandardVerifyFlags
+	var sigCache *txscript.SigCache
+	validate := func(tx *btcutil.Tx) (*txValidator, error) {
+		items := make([]*txValidateItem, 0, numInputs)
+		for i, txIn := range tx.MsgTx().TxIn {
+			items = append(items, &txValidateItem{
+				txInIndex: i,
+				txIn:      txIn,
+				tx:        tx,
+				sigHashes: sigHashes,
+			})
+		}
+		validator := newTxValidator(view, flags, sigCache, nil, 2)
+		validator.batchSigs = true
+		return validator, validator.Validate(items)
+	}
+
+	// isCached returns whether the signature of the passed input of the
+	// passed transaction is in the signature cache.
+	isCached := func(tx *wire.MsgTx, i int) bool {
+		sigHash, err := txscript.CalcTaprootSignatureHash(sigHashes,
+			txscript.SigHashDefault, tx, i, view)
+		if err != nil {
+			t.Fatalf("unable to calculate signature hash: %v", err)
+		}
+		return sigCache.Exists(*(*chainhash.Hash)(sigHash),
+			tx.TxIn[i].Witness[0], pkScript[2:])
+	}
+
+	sigCache = txscript.NewSigCache(numInputs * 2)
+	validator, err := validate(btcutil.NewTx(spendTx))
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if validator.schnorrBatches == 0 ||
+		validator.schnorrBatchSigs != numInputs ||
+		validator.schnorrBatchFailures != 0 {
+
+		t.Fatalf("unexpected batches %d with %d signatures and %d "+
+			"failures", validator.schnorrBatches,
+			validator.schnorrBatchSigs,
+			validator.schnorrBatchFailures)
+	}
+
+	// Invalidate the signature of one of the inputs, which must fail the
+	// batch and be reported as the error of that input.
+	const badInput = 21
+	badTx := spendTx.Copy()
+	badTx.TxIn[badInput].Witness[0][10] ^= 0x01
+	validator, err = validate(btcutil.NewTx(badTx))
+	rErr, ok := err.(RuleError)
+	if !ok || rErr.ErrorCode != ErrScriptValidation {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	wantInput := fmt.Sprintf("%s:%d ", badTx.TxHash(), badInput)
+	if !strings.Contains(rErr.Description, wantInput) {
+		t.Fatalf("error %q does not refer to input %d", err, badInput)
+	}
+	failures := atomic.LoadUint64(&validator.schnorrBatchFailures)
+	if failures != 1 {
+		t.Fatalf("unexpected batch failures %d", failures)
+	}
+
+	// The batch verified signatures must be in the signature cache, while
+	// the invalid signature must not be.
+	for i := 0; i < numInputs; i++ {
+		if !isCached(spendTx, i) {
+			t.Fatalf("signature of input %d not cached", i)
+		}
+	}
+	sigCache = txscript.NewSigCache(numInputs * 2)
+	_, err = validate(btcutil.NewTx(badTx))
+	if err == nil {
+		t.Fatal("invalid signature passed validation")
+	}
+	if isCached(badTx, badInput) {
+		t.Fatal("invalid signature was cached")
 	}
 }
@@ -0,0 +1,247 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// signetScriptFlags are the script flags the block solution of a
+	// signet block is verified with, as defined by BIP 325.
+	signetScriptFlags = txscript.ScriptBip16 |
+		txscript.ScriptVerifyWitness |
+		txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptStrictMultiSig
+)
+
+// SignetHeader is the 4-byte header which identifies the data push holding
+// the block solution within the witness commitment output of a signet block.
+var SignetHeader = [4]byte{0xec, 0xc7, 0xda, 0xa2}
+
+// witnessCommitmentIndex returns the index of the output of the coinbase
+// transaction which holds the witness commitment.  The boolean is false when
+// there is no such output.
+func witnessCommitmentIndex(coinbase *wire.MsgTx) (int, bool) {
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		pkScript := coinbase.TxOut[i].PkScript
+		if len(pkScript) >= CoinbaseWitnessPkScriptLength &&
+			bytes.HasPrefix(pkScript, WitnessMagicBytes) {
+
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// appendPush appends a canonical push of the passed data to the script.
+func appendPush(script, data []byte) []byte {
+	switch n := len(data); {
+	case n < txscript.OP_PUSHDATA1:
+		script = append(script, byte(n))
+
+	case n <= math.MaxUint8:
+		script = append(script, txscript.OP_PUSHDATA1, byte(n))
+
+	case n <= math.MaxUint16:
+		var buf [2]byte
+		binary.LittleEndian.PutUint16(buf[:], uint16(n))
+		script = append(script, txscript.OP_PUSHDATA2)
+		script = append(script, buf[:]...)
+
+	default:
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], uint32(n))
+		script = append(script, txscript.OP_PUSHDATA4)
+		script = append(script, buf[:]...)
+	}
+
+	return append(script, data...)
+}
+
+// extractSignetSolution returns the block solution held by the passed witness
+// commitment script, along with the script with the solution removed, which
+// is what the solution commits to.  The boolean is false when the script
+// holds no solution.
+func extractSignetSolution(pkScript []byte) ([]byte, []byte, bool) {
+	var solution, stripped []byte
+	var found bool
+
+	tokenizer := txscript.MakeScriptTokenizer(0, pkScript)
+	for tokenizer.Next() {
+		data := tokenizer.Data()
+		if len(data) == 0 {
+			stripped = append(stripped, tokenizer.Opcode())
+			continue
+		}
+
+		// Only the first push which has the header followed by some
+		// data holds the solution.
+		if !found && len(data) > len(SignetHeader) &&
+			bytes.HasPrefix(data, SignetHeader[:]) {
+
+			solution = data[len(SignetHeader):]
+			data = data[:len(SignetHeader)]
+			found = true
+		}
+		stripped = appendPush(stripped, data)
+	}
+
+	return solution, stripped, found
+}
+
+// parseSignetSolution parses the signature script and the witness of the
+// spending transaction from the passed block solution.
+func parseSignetSolution(solution []byte) ([]byte, wire.TxWitness, error) {
+	r := bytes.NewReader(solution)
+	maxSize := uint32(len(solution))
+	sigScript, err := wire.ReadVarBytes(r, 0, maxSize, "signet scriptsig")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if count > uint64(r.Len()) {
+		return nil, nil, fmt.Errorf("witness item count %d exceeds the "+
+			"remaining %d bytes", count, r.Len())
+	}
+	witness := make(wire.TxWitness, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(r, 0, maxSize, "signet witness")
+		if err != nil {
+			return nil, nil, err
+		}
+		witness = append(witness, item)
+	}
+
+	if r.Len() != 0 {
+		return nil, nil, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+
+	return sigScript, witness, nil
+}
+
+// SignetSpendTxs returns the virtual transactions used to verify the block
+// solution of the passed signet block as defined by BIP 325.  The first one
+// spends nothing and has a single output locked by the challenge, whose
+// signature script commits to the block with the solution removed.  The second
+// one spends that output with the signature script and witness of the block
+// solution.
+//
+// Blocks without a solution are allowed to support challenges which don't
+// need one, in which case the second transaction has an empty signature script
+// and witness.
+func SignetSpendTxs(block *wire.MsgBlock, challenge []byte) (*wire.MsgTx,
+	*wire.MsgTx, error) {
+
+	if len(block.Transactions) == 0 {
+		return nil, nil, fmt.Errorf("block has no coinbase")
+	}
+	coinbase := block.Transactions[0]
+	idx, ok := witnessCommitmentIndex(coinbase)
+	if !ok {
+		return nil, nil, fmt.Errorf("block has no witness commitment")
+	}
+
+	// Remove the solution from the coinbase, which the block data signed
+	// by the solution commits to.
+	var sigScript []byte
+	var witness wire.TxWitness
+	solution, stripped, found := extractSignetSolution(
+		coinbase.TxOut[idx].PkScript,
+	)
+	if found {
+		var err error
+		sigScript, witness, err = parseSignetSolution(solution)
+		if err != nil {
+			return nil, nil, fmt.Errorf("malformed block solution: "+
+				"%v", err)
+		}
+
+		modified := coinbase.Copy()
+		modified.TxOut[idx].PkScript = stripped
+		coinbase = modified
+	}
+
+	txns := make([]*btcutil.Tx, 0, len(block.Transactions))
+	txns = append(txns, btcutil.NewTx(coinbase))
+	for _, tx := range block.Transactions[1:] {
+		txns = append(txns, btcutil.NewTx(tx))
+	}
+	merkleRoot := CalcMerkleRoot(txns, false)
+
+	// The block data consists of the version, previous block hash, merkle
+	// root of the modified block, and timestamp.
+	var blockData bytes.Buffer
+	blockData.Grow(4 + 32 + 32 + 4)
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(block.Header.Version))
+	blockData.Write(buf[:])
+	blockData.Write(block.Header.PrevBlock[:])
+	blockData.Write(merkleRoot[:])
+	binary.LittleEndian.PutUint32(buf[:], uint32(block.Header.Timestamp.Unix()))
+	blockData.Write(buf[:])
+
+	toSpend := wire.NewMsgTx(0)
+	toSpend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: math.MaxUint32},
+		SignatureScript: appendPush(
+			[]byte{txscript.OP_0}, blockData.Bytes(),
+		),
+		Sequence: 0,
+	})
+	toSpend.AddTxOut(wire.NewTxOut(0, challenge))
+
+	toSign := wire.NewMsgTx(0)
+	toSign.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: toSpend.TxHash()},
+		SignatureScript:  sigScript,
+		Witness:          witness,
+		Sequence:         0,
+	})
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+
+	return toSpend, toSign, nil
+}
+
+// CheckSignetBlockSolution ensures the block solution of the passed block
+// satisfies the challenge of the signet network as defined by BIP 325.
+func CheckSignetBlockSolution(block *btcutil.Block, challenge []byte) error {
+	toSpend, toSign, err := SignetSpendTxs(block.MsgBlock(), challenge)
+	if err != nil {
+		str := fmt.Sprintf("block %v: %v", block.Hash(), err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+		toSpend.TxOut[0].PkScript, 0,
+	)
+	vm, err := txscript.NewEngine(
+		challenge, toSign, 0, signetScriptFlags, nil,
+		txscript.NewTxSigHashes(toSign, prevOutFetcher), 0,
+		prevOutFetcher,
+	)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		str := fmt.Sprintf("block %v has an invalid block solution: %v",
+			block.Hash(), err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+
+	return nil
+}
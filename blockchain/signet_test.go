@@ -0,0 +1,244 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// signetTestBlock returns a block with a coinbase whose witness commitment
+// output is followed by the passed pushes, along with a regular transaction.
+func signetTestBlock(t *testing.T, pushes ...[]byte) *wire.MsgBlock {
+	t.Helper()
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{txscript.OP_1, txscript.OP_1},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE}))
+
+	commitment := append([]byte{}, WitnessMagicBytes...)
+	commitment = append(commitment, bytes.Repeat([]byte{0x11}, 32)...)
+	for _, push := range pushes {
+		commitment = appendPush(commitment, push)
+	}
+	coinbase.AddTxOut(wire.NewTxOut(0, commitment))
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 1}})
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: [32]byte{0x01},
+			Timestamp: time.Unix(1700000000, 0),
+			Bits:      0x1e0377ae,
+		},
+		Transactions: []*wire.MsgTx{coinbase, tx},
+	}
+	txns := []*btcutil.Tx{btcutil.NewTx(coinbase), btcutil.NewTx(tx)}
+	block.Header.MerkleRoot = CalcMerkleRoot(txns, false)
+
+	return block
+}
+
+// signetSolution returns the serialized block solution with the passed
+// signature script and witness.
+func signetSolution(sigScript []byte, witness wire.TxWitness) []byte {
+	var solution bytes.Buffer
+	_ = wire.WriteVarBytes(&solution, 0, sigScript)
+	_ = wire.WriteVarInt(&solution, 0, uint64(len(witness)))
+	for _, item := range witness {
+		_ = wire.WriteVarBytes(&solution, 0, item)
+	}
+	return solution.Bytes()
+}
+
+// addSignetSolution adds the passed solution to the last push of the witness
+// commitment of the block, which must be the signet header.
+func addSignetSolution(block *wire.MsgBlock, solution []byte) {
+	coinbase := block.Transactions[0]
+	out := coinbase.TxOut[len(coinbase.TxOut)-1]
+	out.PkScript = out.PkScript[:len(out.PkScript)-len(SignetHeader)-1]
+	push := append(SignetHeader[:], solution...)
+	out.PkScript = appendPush(out.PkScript, push)
+}
+
+// TestCheckSignetBlockSolution ensures the block solutions of signet blocks
+// are verified against the challenge as defined by BIP 325.
+func TestCheckSignetBlockSolution(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	pubKey, err := btcutil.NewAddressPubKey(
+		privKey.PubKey().SerializeCompressed(), &chaincfg.SigNetParams,
+	)
+	if err != nil {
+		t.Fatalf("NewAddressPubKey: unexpected error: %v", err)
+	}
+	challenge, err := txscript.MultiSigScript(
+		[]*btcutil.AddressPubKey{pubKey}, 1,
+	)
+	if err != nil {
+		t.Fatalf("MultiSigScript: unexpected error: %v", err)
+	}
+
+	// signedBlock returns a block whose solution is signed by the key.
+	signedBlock := func() *wire.MsgBlock {
+		block := signetTestBlock(t, SignetHeader[:])
+		_, toSign, err := SignetSpendTxs(block, challenge)
+		if err != nil {
+			t.Fatalf("SignetSpendTxs: unexpected error: %v", err)
+		}
+		sig, err := txscript.RawTxInSignature(
+			toSign, 0, challenge, txscript.SigHashAll, privKey,
+		)
+		if err != nil {
+			t.Fatalf("RawTxInSignature: unexpected error: %v", err)
+		}
+		sigScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_0).AddData(sig).Script()
+		if err != nil {
+			t.Fatalf("NewScriptBuilder: unexpected error: %v", err)
+		}
+		addSignetSolution(block, signetSolution(sigScript, nil))
+		return block
+	}
+
+	tests := []struct {
+		name      string
+		block     func() *wire.MsgBlock
+		challenge []byte
+		valid     bool
+	}{{
+		name:      "signed block",
+		block:     signedBlock,
+		challenge: challenge,
+		valid:     true,
+	}, {
+		name: "signed block with modified timestamp",
+		block: func() *wire.MsgBlock {
+			block := signedBlock()
+			block.Header.Timestamp = block.Header.Timestamp.Add(
+				time.Second,
+			)
+			return block
+		},
+		challenge: challenge,
+	}, {
+		name: "signed block with modified transaction",
+		block: func() *wire.MsgBlock {
+			block := signedBlock()
+			block.Transactions[1].TxOut[0].Value++
+			return block
+		},
+		challenge: challenge,
+	}, {
+		name: "block with empty solution for trivial challenge",
+		block: func() *wire.MsgBlock {
+			block := signetTestBlock(t, SignetHeader[:])
+			addSignetSolution(block, signetSolution(nil, nil))
+			return block
+		},
+		challenge: []byte{txscript.OP_TRUE},
+		valid:     true,
+	}, {
+		name: "block with trailing solution data",
+		block: func() *wire.MsgBlock {
+			block := signetTestBlock(t, SignetHeader[:])
+			solution := append(signetSolution(nil, nil), 0x00)
+			addSignetSolution(block, solution)
+			return block
+		},
+		challenge: []byte{txscript.OP_TRUE},
+	}, {
+		name: "block without solution",
+		block: func() *wire.MsgBlock {
+			return signetTestBlock(t)
+		},
+		challenge: challenge,
+	}, {
+		name: "block without solution for trivial challenge",
+		block: func() *wire.MsgBlock {
+			return signetTestBlock(t)
+		},
+		challenge: []byte{txscript.OP_TRUE},
+		valid:     true,
+	}, {
+		name: "block without witness commitment",
+		block: func() *wire.MsgBlock {
+			block := signetTestBlock(t)
+			coinbase := block.Transactions[0]
+			coinbase.TxOut = coinbase.TxOut[:1]
+			return block
+		},
+		challenge: []byte{txscript.OP_TRUE},
+	}}
+
+	for _, test := range tests {
+		block := btcutil.NewBlock(test.block())
+		err := CheckSignetBlockSolution(block, test.challenge)
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+
+		var rerr RuleError
+		if !errors.As(err, &rerr) ||
+			rerr.ErrorCode != ErrBadSignetSolution {
+
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+// TestExtractSignetSolution ensures the block solution is extracted from the
+// first push of the witness commitment script which holds the signet header
+// followed by some data.
+func TestExtractSignetSolution(t *testing.T) {
+	t.Parallel()
+
+	header := SignetHeader[:]
+	solution := []byte{0x01, 0x02}
+	script := appendPush([]byte{txscript.OP_RETURN}, []byte{0xaa})
+	script = appendPush(script, header)
+	script = appendPush(script, append(header, solution...))
+	script = appendPush(script, append(header, 0x03))
+
+	want := appendPush([]byte{txscript.OP_RETURN}, []byte{0xaa})
+	want = appendPush(want, header)
+	want = appendPush(want, header)
+	want = appendPush(want, append(header, 0x03))
+
+	got, stripped, found := extractSignetSolution(script)
+	if !found {
+		t.Fatal("solution not found")
+	}
+	if !bytes.Equal(got, solution) {
+		t.Fatalf("unexpected solution -- got %x, want %x", got,
+			solution)
+	}
+	if !bytes.Equal(stripped, want) {
+		t.Fatalf("unexpected stripped script -- got %x, want %x",
+			stripped, want)
+	}
+}
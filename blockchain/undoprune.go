@@ -0,0 +1,220 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// MinUndoPruneDepth is the minimum number of most recent main chain
+	// blocks whose spend journal entries must be kept when undo data is
+	// pruned.  It matches the number of blocks pruned nodes keep, which is
+	// deep enough that a reorganization reaching further back is not
+	// expected to happen.
+	MinUndoPruneDepth = 288
+
+	// undoPruneBatchSize is the maximum number of spend journal entries
+	// removed in a single database transaction while catching up with the
+	// undo prune depth.
+	undoPruneBatchSize = 2000
+)
+
+var (
+	// undoPrunedHeightKeyName is the name of the db key used to store the
+	// height of the most recent main chain block whose spend journal entry
+	// was pruned.
+	undoPrunedHeightKeyName = []byte("undoprunedheight")
+)
+
+// dbPutUndoPrunedHeight uses an existing database transaction to store the
+// height of the most recent main chain block whose spend journal entry was
+// pruned.
+func dbPutUndoPrunedHeight(dbTx database.Tx, height int32) error {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], uint32(height))
+	return dbTx.Metadata().Put(undoPrunedHeightKeyName, serialized[:])
+}
+
+// dbFetchUndoPrunedHeight uses an existing database transaction to fetch the
+// height of the most recent main chain block whose spend journal entry was
+// pruned.  It returns 0 when no entries have been pruned.
+func dbFetchUndoPrunedHeight(dbTx database.Tx) (int32, error) {
+	serialized := dbTx.Metadata().Get(undoPrunedHeightKeyName)
+	if serialized == nil {
+		return 0, nil
+	}
+	if len(serialized) != 4 {
+		return 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt undo pruned height",
+		}
+	}
+	return int32(byteOrder.Uint32(serialized)), nil
+}
+
+// dbPruneUndoData uses an existing database transaction to remove the spend
+// journal entries of the main chain blocks after the passed pruned height up to
+// and including the passed target height, and stores the target height as the
+// new pruned height.  The tip must be the tip of the main chain.
+func dbPruneUndoData(dbTx database.Tx, tip *blockNode, prunedHeight,
+	targetHeight int32) error {
+
+	for height := prunedHeight + 1; height <= targetHeight; height++ {
+		node := tip.Ancestor(height)
+		err := dbRemoveSpendJournalEntry(dbTx, &node.hash)
+		if err != nil {
+			return err
+		}
+	}
+	return dbPutUndoPrunedHeight(dbTx, targetHeight)
+}
+
+// undoPruneTarget returns the height up to which the spend journal entries of
+// the main chain ending at the passed tip can be pruned when the passed number
+// of most recent blocks must keep them.  The finalized block and its ancestors
+// can never be disconnected, so their entries may always be pruned.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) undoPruneTarget(tip *blockNode, depth int32) int32 {
+	target := tip.height - depth
+	if b.finalizedNode != nil && b.finalizedNode.height > target {
+		target = b.finalizedNode.height
+	}
+	return target
+}
+
+// pruneUndoData removes the spend journal entries of the main chain blocks up
+// to the passed target height which haven't been pruned yet.  The entries are
+// removed in batches so catching up with a large number of blocks doesn't
+// result in a single huge database transaction.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneUndoData(targetHeight int32,
+	interrupt <-chan struct{}) error {
+
+	tip := b.bestChain.Tip()
+	if targetHeight > tip.height {
+		targetHeight = tip.height
+	}
+	if targetHeight <= b.undoPrunedHeight {
+		return nil
+	}
+
+	log.Infof("Pruning undo data of blocks %d to %d",
+		b.undoPrunedHeight+1, targetHeight)
+
+	for b.undoPrunedHeight < targetHeight {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		batchHeight := b.undoPrunedHeight + undoPruneBatchSize
+		if batchHeight > targetHeight {
+			batchHeight = targetHeight
+		}
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return dbPruneUndoData(dbTx, tip, b.undoPrunedHeight,
+				batchHeight)
+		})
+		if err != nil {
+			return err
+		}
+		b.undoPrunedHeight = batchHeight
+	}
+	return nil
+}
+
+// initUndoPrune loads the height up to which the spend journal entries were
+// pruned from the database and prunes the entries which fell behind the
+// configured undo prune depth while the node wasn't running.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) initUndoPrune(interrupt <-chan struct{}) error {
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		b.undoPrunedHeight, err = dbFetchUndoPrunedHeight(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if b.undoPruneDepth == 0 {
+		return nil
+	}
+	return b.pruneUndoData(b.undoPruneTarget(b.bestChain.Tip(),
+		b.undoPruneDepth), interrupt)
+}
+
+// checkUndoPrunedFork returns an error when a block building on the passed
+// previous node would fork the main chain at or before a block whose spend
+// journal entry was pruned, since the main chain could never be reorganized
+// to it.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkUndoPrunedFork(prevNode *blockNode) error {
+	if b.undoPrunedHeight == 0 {
+		return nil
+	}
+	fork := b.bestChain.FindFork(prevNode)
+	if fork == nil || fork.height >= b.undoPrunedHeight {
+		return nil
+	}
+
+	str := fmt.Sprintf("block at height %d forks the main chain at "+
+		"height %d before the undo data pruned up to height %d",
+		prevNode.height+1, fork.height, b.undoPrunedHeight)
+	return ruleError(ErrUndoDataPruned, str)
+}
+
+// checkUndoDataAvailable returns an error when the spend journal entry of the
+// passed main chain node was pruned.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkUndoDataAvailable(node *blockNode) error {
+	if b.undoPrunedHeight == 0 || node.height > b.undoPrunedHeight {
+		return nil
+	}
+	return fmt.Errorf("the undo data of block %v at height %d was pruned",
+		node.hash, node.height)
+}
+
+// PruneUndoData removes the spend journal entries, which are the undo data
+// needed to disconnect blocks, of all main chain blocks except for the passed
+// number of most recent ones and the blocks whose entries were already pruned.
+// Reorganizations that would disconnect a block whose undo data was pruned are
+// rejected from then on, so the depth must be at least MinUndoPruneDepth.  The
+// entries of the finalized block and its ancestors are always pruned since the
+// main chain can't be reorganized across them anyway.
+//
+// The height up to which the undo data is pruned is returned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PruneUndoData(depth int32) (int32, error) {
+	if depth < MinUndoPruneDepth {
+		return 0, fmt.Errorf("undo data of the most recent %d blocks "+
+			"must be kept", MinUndoPruneDepth)
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	err := b.pruneUndoData(b.undoPruneTarget(b.bestChain.Tip(), depth), nil)
+	return b.undoPrunedHeight, err
+}
+
+// UndoPrunedHeight returns the height of the most recent main chain block whose
+// undo data was pruned, or 0 when no undo data has been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UndoPrunedHeight() int32 {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	return b.undoPrunedHeight
+}
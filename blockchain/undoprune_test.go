@@ -0,0 +1,112 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain/internal/testhelper"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestPruneUndoData ensures the spend journal entries of blocks behind the undo
+// prune depth are removed and that the blocks can't be disconnected afterwards.
+func TestPruneUndoData(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestPruneUndoData")
+	defer tearDown()
+
+	haveUndoData := func(block *btcutil.Block) bool {
+		var have bool
+		err := chain.db.View(func(dbTx database.Tx) error {
+			bucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+			have = bucket.Get(block.Hash()[:]) != nil
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return have
+	}
+
+	// Create a main chain with 4 blocks where each block spends the
+	// outputs of the previous one so all of them have undo data.  Keep
+	// the outputs of the first block to build a side chain later.
+	prev := btcutil.NewBlock(params.GenesisBlock)
+	var mainBlocks []*btcutil.Block
+	var b1Outs, spends []*testhelper.SpendableOut
+	for i := 0; i < 4; i++ {
+		block, outs, err := addBlock(chain, prev, spends)
+		if err != nil {
+			t.Fatalf("addBlock #%d: %v", i, err)
+		}
+		if i == 0 {
+			b1Outs = outs
+		}
+		mainBlocks = append(mainBlocks, block)
+		prev, spends = block, outs
+	}
+	for _, block := range mainBlocks[1:] {
+		if !haveUndoData(block) {
+			t.Fatalf("missing undo data of block %v", block.Hash())
+		}
+	}
+	if height := chain.UndoPrunedHeight(); height != 0 {
+		t.Fatalf("got undo pruned height %d, want 0", height)
+	}
+
+	// The undo data of the most recent blocks must be kept.
+	if _, err := chain.PruneUndoData(MinUndoPruneDepth - 1); err == nil {
+		t.Fatal("PruneUndoData: expected error for too low depth")
+	}
+
+	// Connecting a block with an undo prune depth of 2 prunes the undo
+	// data of all but the two most recent blocks.
+	chain.undoPruneDepth = 2
+	block, _, err := addBlock(chain, prev, spends)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+	mainBlocks = append(mainBlocks, block)
+	if height := chain.UndoPrunedHeight(); height != 3 {
+		t.Fatalf("got undo pruned height %d, want 3", height)
+	}
+	for i, block := range mainBlocks {
+		if want := i >= 3; haveUndoData(block) != want {
+			t.Fatalf("block at height %d: got undo data %v, want %v",
+				i+1, !want, want)
+		}
+	}
+
+	// Blocks whose undo data was pruned can't be disconnected, so side
+	// chain blocks forking before them and invalidating them is rejected.
+	var ruleErr RuleError
+	_, _, err = addBlock(chain, mainBlocks[0], b1Outs)
+	if !errors.As(err, &ruleErr) || ruleErr.ErrorCode != ErrUndoDataPruned {
+		t.Fatalf("side chain block: got error %v, want %v", err,
+			ErrUndoDataPruned)
+	}
+	err = chain.InvalidateBlock(mainBlocks[2].Hash())
+	if !errors.As(err, &ruleErr) || ruleErr.ErrorCode != ErrUndoDataPruned {
+		t.Fatalf("InvalidateBlock: got error %v, want %v", err,
+			ErrUndoDataPruned)
+	}
+	if _, err := chain.BlockStats(mainBlocks[2].Hash()); err == nil {
+		t.Fatal("BlockStats: expected error for pruned undo data")
+	}
+
+	// The most recent blocks still have their undo data, so they can be
+	// disconnected.
+	if err := chain.InvalidateBlock(mainBlocks[4].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *mainBlocks[3].Hash() {
+		t.Fatalf("got tip %v, want %v", tip, mainBlocks[3].Hash())
+	}
+	if _, err := chain.BlockStats(mainBlocks[3].Hash()); err != nil {
+		t.Fatalf("BlockStats: unexpected error: %v", err)
+	}
+}
@@ -224,6 +224,33 @@ type utxoCache struct {
 	// Below fields are used to indicate when the last flush happened.
 	lastFlushHash chainhash.Hash
 	lastFlushTime time.Time
+
+	// epoch is the flush epoch of the utxo set in the database.  It is
+	// incremented with every flush and stored along with the hash of the
+	// block the flushed utxo set is consistent with, so a crash leaves the
+	// database at a well-defined epoch that can be replayed from.
+	epoch uint64
+}
+
+// UtxoCacheStatus describes the state of the utxo cache.
+type UtxoCacheStatus struct {
+	// Entries is the number of entries in the cache.
+	Entries int
+
+	// MemoryUsage and MaxMemoryUsage are the current and maximum memory
+	// usage of the cache in bytes.
+	MemoryUsage    uint64
+	MaxMemoryUsage uint64
+
+	// Epoch is the flush epoch of the utxo set in the database.
+	Epoch uint64
+
+	// LastFlushHash and LastFlushTime describe the most recent flush of
+	// the cache.  The utxo set in the database is consistent with the
+	// block identified by LastFlushHash, and blocks after it are replayed
+	// after a crash.
+	LastFlushHash chainhash.Hash
+	LastFlushTime time.Time
 }
 
 // newUtxoCache initiates a new utxo cache instance with its memory usage limited
@@ -532,8 +559,9 @@ func (s *utxoCache) writeCache(dbTx database.Tx, bestState *BestState) error {
 	s.totalEntryMemory = 0
 
 	// When done, store the best state hash in the database to indicate the state
-	// is consistent until that hash.
-	err := dbPutUtxoStateConsistency(dbTx, &bestState.Hash)
+	// is consistent until that hash, and tag it with the next epoch.
+	epoch := s.epoch + 1
+	err := dbPutUtxoStateConsistency(dbTx, &bestState.Hash, epoch)
 	if err != nil {
 		return err
 	}
@@ -541,6 +569,7 @@ func (s *utxoCache) writeCache(dbTx database.Tx, bestState *BestState) error {
 	// The best state is the new last flush hash.
 	s.lastFlushHash = bestState.Hash
 	s.lastFlushTime = time.Now()
+	s.epoch = epoch
 
 	return nil
 }
@@ -597,6 +626,24 @@ func (b *BlockChain) FlushUtxoCache(mode FlushMode) error {
 	})
 }
 
+// UtxoCacheStatus returns the current state of the utxo cache.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoCacheStatus() UtxoCacheStatus {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	s := b.utxoCache
+	return UtxoCacheStatus{
+		Entries:        s.cachedEntries.length(),
+		MemoryUsage:    s.totalMemoryUsage(),
+		MaxMemoryUsage: s.maxTotalMemoryUsage,
+		Epoch:          s.epoch,
+		LastFlushHash:  s.lastFlushHash,
+		LastFlushTime:  s.lastFlushTime,
+	}
+}
+
 // InitConsistentState checks the consistency status of the utxo state and
 // replays blocks if it lags behind the best state of the blockchain.
 //
@@ -606,18 +653,22 @@ func (b *BlockChain) InitConsistentState(tip *blockNode, interrupt <-chan struct
 	s := b.utxoCache
 
 	// Load the consistency status from the database.
-	var statusBytes []byte
-	s.db.View(func(dbTx database.Tx) error {
-		statusBytes = dbFetchUtxoStateConsistency(dbTx)
-		return nil
+	var statusHash *chainhash.Hash
+	err := s.db.View(func(dbTx database.Tx) error {
+		var err error
+		statusHash, s.epoch, err = dbFetchUtxoStateConsistency(dbTx)
+		return err
 	})
+	if err != nil {
+		return err
+	}
 
 	// If no status was found, the database is old and didn't have a cached utxo
 	// state yet. In that case, we set the status to the best state and write
 	// this to the database.
-	if statusBytes == nil {
+	if statusHash == nil {
 		err := s.db.Update(func(dbTx database.Tx) error {
-			return dbPutUtxoStateConsistency(dbTx, &tip.hash)
+			return dbPutUtxoStateConsistency(dbTx, &tip.hash, s.epoch)
 		})
 
 		// Set the last flush hash as it's the default value of 0s.
@@ -627,14 +678,10 @@ func (b *BlockChain) InitConsistentState(tip *blockNode, interrupt <-chan struct
 		return err
 	}
 
-	statusHash, err := chainhash.NewHash(statusBytes)
-	if err != nil {
-		return err
-	}
-
 	// If state is consistent, we are done.
 	if statusHash.IsEqual(&tip.hash) {
-		log.Debugf("UTXO state consistent at (%d:%v)", tip.height, tip.hash)
+		log.Debugf("UTXO state consistent at (%d:%v) in epoch %d",
+			tip.height, tip.hash, s.epoch)
 
 		// The last flush hash is set to the default value of all 0s. Set
 		// it to the tip since we checked it's consistent.
@@ -648,10 +695,14 @@ func (b *BlockChain) InitConsistentState(tip *blockNode, interrupt <-chan struct
 	}
 
 	lastFlushNode := b.index.LookupNode(statusHash)
+	if lastFlushNode == nil {
+		return AssertError(fmt.Sprintf("last utxo consistency status contains "+
+			"unknown hash: %v", statusHash))
+	}
 	log.Infof("Reconstructing UTXO state after an unclean shutdown. The UTXO state is "+
-		"consistent at block %s (%d) but the chainstate is at block %s (%d),  This may "+
-		"take a long time...", statusHash.String(), lastFlushNode.height,
-		tip.hash.String(), tip.height)
+		"consistent at block %s (%d) as of flush epoch %d but the chainstate is at "+
+		"block %s (%d),  This may take a long time...", statusHash.String(),
+		lastFlushNode.height, s.epoch, tip.hash.String(), tip.height)
 
 	// Even though this should always be true, make sure the fetched hash is in
 	// the best chain.
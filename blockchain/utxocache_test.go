@@ -4,8 +4,10 @@
 package blockchain
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
@@ -336,18 +338,14 @@ func TestUtxoCacheEntrySize(t *testing.T) {
 
 // assertConsistencyState asserts the utxo consistency states of the blockchain.
 func assertConsistencyState(chain *BlockChain, hash *chainhash.Hash) error {
-	var bytes []byte
+	var actualHash *chainhash.Hash
 	err := chain.db.View(func(dbTx database.Tx) (err error) {
-		bytes = dbFetchUtxoStateConsistency(dbTx)
+		actualHash, _, err = dbFetchUtxoStateConsistency(dbTx)
 		return
 	})
 	if err != nil {
 		return fmt.Errorf("Error fetching utxo state consistency: %v", err)
 	}
-	actualHash, err := chainhash.NewHash(bytes)
-	if err != nil {
-		return err
-	}
 	if !actualHash.IsEqual(hash) {
 		return fmt.Errorf("Unexpected consistency hash: %v instead of %v",
 			actualHash, hash)
@@ -847,6 +845,62 @@ func TestFlushOnPrune(t *testing.T) {
 	}
 }
 
+// TestPruneDepth ensures that pruning by depth keeps the configured number of
+// most recent main chain blocks and deletes older ones.
+func TestPruneDepth(t *testing.T) {
+	chain, tearDown, err := chainSetup("TestPruneDepth", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("error loading blockchain with database: %v", err)
+	}
+	defer tearDown()
+
+	// Set the maxBlockFileSize small so that blocks are spread over many
+	// files which can be pruned.
+	const pruneDepth = 100
+	maxBlockFileSize := uint32(8192)
+	chain.pruneDepth = pruneDepth
+
+	blocks, err := loadBlocks("blk_0_to_14131.dat")
+	if err != nil {
+		t.Fatalf("failed to read block from file. %v", err)
+	}
+	blocks = blocks[:2000]
+
+	ffldb.TstRunWithMaxBlockFileSize(chain.db, maxBlockFileSize, func() {
+		for _, block := range blocks[1:] {
+			_, _, err := chain.ProcessBlock(block, BFNone)
+			if err != nil {
+				t.Fatalf("Failed to process block %v(%v). %v",
+					block.Hash(), block.Height(), err)
+			}
+		}
+	})
+
+	err = chain.db.View(func(dbTx database.Tx) error {
+		for i, block := range blocks {
+			has, err := dbTx.HasBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+
+			// All blocks within the prune depth must be kept while
+			// the oldest blocks must be pruned.  Blocks in between
+			// may share a block file with a kept block.
+			switch {
+			case i >= len(blocks)-pruneDepth && !has:
+				return fmt.Errorf("block %d within the prune "+
+					"depth was pruned", i)
+			case i < len(blocks)/2 && has:
+				return fmt.Errorf("block %d was not pruned", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestInitConsistentState(t *testing.T) {
 	//  Boilerplate for creating a chain.
 	dbName := "TestFlushOnPrune"
@@ -918,12 +972,11 @@ func TestInitConsistentState(t *testing.T) {
 
 	// Sanity check to see that the utxo cache was flushed before the
 	// current chain tip.
-	var statusBytes []byte
-	ndb.View(func(dbTx database.Tx) error {
-		statusBytes = dbFetchUtxoStateConsistency(dbTx)
-		return nil
+	var statusHash *chainhash.Hash
+	err = ndb.View(func(dbTx database.Tx) (err error) {
+		statusHash, _, err = dbFetchUtxoStateConsistency(dbTx)
+		return
 	})
-	statusHash, err := chainhash.NewHash(statusBytes)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -960,3 +1013,113 @@ func TestInitConsistentState(t *testing.T) {
 			blocks[len(blocks)-1].Height())
 	}
 }
+
+// TestUtxoStateConsistencyEpoch ensures the flush epoch is incremented with
+// every flush of the utxo cache and survives a restart, that the consistency
+// status keeps the format of older versions, and that databases written before
+// the epoch was introduced are read as epoch 0.
+func TestUtxoStateConsistencyEpoch(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain(
+		"TestUtxoStateConsistencyEpoch")
+	defer tearDown()
+
+	fetchEpoch := func() uint64 {
+		t.Helper()
+		var epoch uint64
+		err := chain.db.View(func(dbTx database.Tx) (err error) {
+			_, epoch, err = dbFetchUtxoStateConsistency(dbTx)
+			return
+		})
+		if err != nil {
+			t.Fatalf("unable to fetch consistency status: %v", err)
+		}
+		return epoch
+	}
+
+	// The database and the cache agree on the initial epoch.
+	initialEpoch := fetchEpoch()
+	if initialEpoch != chain.utxoCache.epoch {
+		t.Fatalf("got initial epoch %d, want %d", initialEpoch,
+			chain.utxoCache.epoch)
+	}
+
+	// Every flush starts a new epoch.
+	tip := btcutil.NewBlock(params.GenesisBlock)
+	for i := initialEpoch + 1; i <= initialEpoch+3; i++ {
+		if _, _, err := addBlocks(1, chain, tip, nil); err != nil {
+			t.Fatal(err)
+		}
+		tip, _ = chain.BlockByHash(&chain.BestSnapshot().Hash)
+		if err := chain.FlushUtxoCache(FlushRequired); err != nil {
+			t.Fatal(err)
+		}
+		if epoch := fetchEpoch(); epoch != i {
+			t.Fatalf("got epoch %d after flush, want %d", epoch, i)
+		}
+		if status := chain.UtxoCacheStatus(); status.Epoch != i ||
+			status.LastFlushHash != *tip.Hash() {
+
+			t.Fatalf("unexpected cache status in epoch %d: %+v",
+				i, status)
+		}
+	}
+
+	// The epoch is loaded on start up.
+	chain.utxoCache.epoch = 0
+	err := chain.InitConsistentState(chain.bestChain.Tip(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.utxoCache.epoch != initialEpoch+3 {
+		t.Fatalf("got epoch %d after restart, want %d",
+			chain.utxoCache.epoch, initialEpoch+3)
+	}
+
+	// The consistency status only consists of the block hash.
+	err = chain.db.View(func(dbTx database.Tx) error {
+		status := dbTx.Metadata().Get(utxoStateConsistencyKeyName)
+		if !bytes.Equal(status, tip.Hash()[:]) {
+			t.Fatalf("got consistency status %x, want %x", status,
+				tip.Hash()[:])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Databases without an epoch are read as epoch 0.
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Delete(utxoFlushEpochKeyName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if epoch := fetchEpoch(); epoch != 0 {
+		t.Fatalf("got legacy epoch %d, want 0", epoch)
+	}
+	if err := assertConsistencyState(chain, tip.Hash()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt statuses and epochs are rejected.
+	for _, key := range [][]byte{utxoFlushEpochKeyName, utxoStateConsistencyKeyName} {
+		err = chain.db.Update(func(dbTx database.Tx) error {
+			return dbTx.Metadata().Put(key, []byte{0x01})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = chain.db.View(func(dbTx database.Tx) error {
+			_, _, err := dbFetchUtxoStateConsistency(dbTx)
+			return err
+		})
+		var dbErr database.Error
+		if !errors.As(err, &dbErr) ||
+			dbErr.ErrorCode != database.ErrCorruption {
+
+			t.Fatalf("got error %v for corrupt %s, want corruption "+
+				"error", err, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// utxoScanInterruptInterval is the number of outputs scanned between checks
+// of the interrupt channel.
+const utxoScanInterruptInterval = 1000
+
+// ErrUtxoScanInterrupted is returned by ScanUtxoSet when the scan is stopped
+// through the interrupt channel.
+var ErrUtxoScanInterrupted = errors.New("utxo set scan interrupted")
+
+// UtxoScanFunc is the function called by ScanUtxoSet with each unspent output.
+// Returning an error stops the scan and makes ScanUtxoSet return the error.
+type UtxoScanFunc func(outpoint wire.OutPoint, entry *UtxoEntry) error
+
+// ScanUtxoSet calls fn with each output of the utxo set and returns the hash
+// and height of the block the scanned set is the state of.  The outputs are
+// streamed from the database in the order of their outpoint keys without
+// loading the set into memory, and the scan is stopped with
+// ErrUtxoScanInterrupted when the interrupt channel is closed.
+//
+// The utxo cache is flushed first and the outputs are read from a consistent
+// view of the database, so the block is the tip of the main chain unless
+// blocks are connected concurrently.  Blocks can still be connected during
+// the scan, which doesn't affect the scanned set.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ScanUtxoSet(interrupt <-chan struct{}, fn UtxoScanFunc) (*chainhash.Hash, int32, error) {
+	if err := b.FlushUtxoCache(FlushRequired); err != nil {
+		return nil, 0, err
+	}
+
+	var hash *chainhash.Hash
+	var height int32
+	err := b.db.View(func(dbTx database.Tx) error {
+		// The utxo set in the database is the state of the block it is
+		// marked consistent with, which might be behind the best chain
+		// tip when a block was connected after the flush.
+		var err error
+		hash, _, err = dbFetchUtxoStateConsistency(dbTx)
+		if err != nil {
+			return err
+		}
+		if hash == nil {
+			return fmt.Errorf("the utxo set is not consistent with " +
+				"any block")
+		}
+		node := b.index.LookupNode(hash)
+		if node == nil {
+			return fmt.Errorf("the utxo set is consistent with the "+
+				"unknown block %v", hash)
+		}
+		height = node.height
+
+		var scanned int
+		cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			if scanned%utxoScanInterruptInterval == 0 &&
+				interruptRequested(interrupt) {
+
+				return ErrUtxoScanInterrupted
+			}
+			scanned++
+
+			outpoint, err := outpointFromKey(cursor.Key())
+			if err != nil {
+				return err
+			}
+			entry, err := deserializeUtxoEntry(cursor.Value())
+			if err != nil {
+				return err
+			}
+			if err := fn(outpoint, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash, height, nil
+}
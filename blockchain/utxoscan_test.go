@@ -0,0 +1,103 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestScanUtxoSet ensures scanning the utxo set visits every unspent output as
+// of the best block and that the scan can be interrupted.
+func TestScanUtxoSet(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxoscan",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+	for i := 1; i < len(blocks); i++ {
+		_, isOrphan, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock #%d: unexpected error: %v", i, err)
+		}
+		if isOrphan {
+			t.Fatalf("ProcessBlock #%d: unexpected orphan", i)
+		}
+	}
+
+	var total int64
+	scanned := make(map[wire.OutPoint]struct{})
+	hash, height, err := chain.ScanUtxoSet(nil,
+		func(outpoint wire.OutPoint, entry *UtxoEntry) error {
+			scanned[outpoint] = struct{}{}
+			total += entry.Amount()
+
+			// Every scanned output must be unspent in the chain.
+			chainEntry, err := chain.FetchUtxoEntry(outpoint)
+			if err != nil {
+				return err
+			}
+			if chainEntry == nil || chainEntry.IsSpent() ||
+				chainEntry.Amount() != entry.Amount() {
+
+				t.Errorf("scanned output %v is not unspent", outpoint)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ScanUtxoSet: unexpected error: %v", err)
+	}
+	best := chain.BestSnapshot()
+	if *hash != best.Hash || height != best.Height {
+		t.Fatalf("ScanUtxoSet: got block %v (%d), want %v (%d)", hash,
+			height, best.Hash, best.Height)
+	}
+	stats := chain.UtxoSetStats()
+	if uint64(len(scanned)) != stats.TxOuts || total != stats.TotalAmount {
+		t.Fatalf("ScanUtxoSet: scanned %d outputs of %d sat, want %d "+
+			"outputs of %d sat", len(scanned), total, stats.TxOuts,
+			stats.TotalAmount)
+	}
+
+	// Every output of the blocks which isn't spent by a later block must
+	// have been scanned.
+	for _, block := range blocks[1:] {
+		for _, tx := range block.Transactions() {
+			for i := range tx.MsgTx().TxOut {
+				op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+				entry, err := chain.FetchUtxoEntry(op)
+				if err != nil {
+					t.Fatalf("FetchUtxoEntry: unexpected error: %v",
+						err)
+				}
+				_, ok := scanned[op]
+				unspent := entry != nil && !entry.IsSpent()
+				if ok != unspent {
+					t.Fatalf("output %v: scanned %v, unspent %v",
+						op, ok, unspent)
+				}
+			}
+		}
+	}
+
+	// A closed interrupt channel stops the scan.
+	interrupt := make(chan struct{})
+	close(interrupt)
+	_, _, err = chain.ScanUtxoSet(interrupt,
+		func(wire.OutPoint, *UtxoEntry) error { return nil })
+	if err != ErrUtxoScanInterrupted {
+		t.Fatalf("ScanUtxoSet: got error %v, want %v", err,
+			ErrUtxoScanInterrupted)
+	}
+}
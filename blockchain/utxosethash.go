@@ -0,0 +1,359 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain/internal/muhash"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// utxoSetStatsSize is the size of the serialized utxo set statistics.
+	utxoSetStatsSize = muhash.SerializedSize + 24
+
+	// bogoSizeOverhead is the fixed part of the size every unspent output
+	// contributes to the bogo size of the utxo set.  It matches the value
+	// used by Bitcoin Core, which consists of the outpoint, the height,
+	// the amount, and the script length.
+	bogoSizeOverhead = 32 + 4 + 4 + 8 + 2
+)
+
+var (
+	// utxoSetStatsKeyName is the name of the db key used to store the
+	// statistics of the utxo set, including its rolling hash, as of the
+	// best block.
+	utxoSetStatsKeyName = []byte("utxosetstats")
+)
+
+// UtxoSetStats houses statistics about the utxo set as of a block.  They are
+// maintained as blocks are connected and disconnected, so they are available
+// without scanning the utxo set.
+type UtxoSetStats struct {
+	// Hash and Height identify the block the statistics are for.
+	Hash   chainhash.Hash
+	Height int32
+
+	// MuHash is the MuHash3072 of the utxo set.  It is computed the same
+	// way as the muhash reported by Bitcoin Core, so it can be used to
+	// compare the utxo set across nodes and implementations.
+	MuHash chainhash.Hash
+
+	// TxOuts is the number of unspent outputs.
+	TxOuts uint64
+
+	// TotalAmount is the total amount of all unspent outputs in satoshi.
+	TotalAmount int64
+
+	// BogoSize is a database-independent metric for the size of the utxo
+	// set.
+	BogoSize uint64
+}
+
+// utxoSetStats houses the rolling hash and the counters of the utxo set.
+type utxoSetStats struct {
+	muHash      *muhash.MuHash
+	txOuts      uint64
+	totalAmount int64
+	bogoSize    uint64
+}
+
+// newUtxoSetStats returns the statistics of an empty utxo set.
+func newUtxoSetStats() *utxoSetStats {
+	return &utxoSetStats{muHash: muhash.New()}
+}
+
+// clone returns a deep copy of the statistics.
+func (s *utxoSetStats) clone() *utxoSetStats {
+	c := *s
+	c.muHash = s.muHash.Clone()
+	return &c
+}
+
+// utxoSetHashElement returns the serialization of an unspent output that is
+// added to the rolling hash of the utxo set.  It matches the serialization
+// used by Bitcoin Core:
+//
+//	<outpoint hash><outpoint index><height and coinbase flag><amount><script>
+//
+// The index and the height code are 32-bit little-endian integers, the height
+// code is the height shifted left by one with the coinbase flag in the lowest
+// bit, the amount is a 64-bit little-endian integer, and the script is
+// prefixed with its length as a variable length integer.
+func utxoSetHashElement(outpoint wire.OutPoint, amount int64, pkScript []byte,
+	height int32, isCoinBase bool) []byte {
+
+	var buf bytes.Buffer
+	buf.Grow(chainhash.HashSize + 16 + wire.VarIntSerializeSize(
+		uint64(len(pkScript))) + len(pkScript))
+
+	var scratch [8]byte
+	buf.Write(outpoint.Hash[:])
+	binary.LittleEndian.PutUint32(scratch[:4], outpoint.Index)
+	buf.Write(scratch[:4])
+	heightCode := uint32(height) << 1
+	if isCoinBase {
+		heightCode |= 1
+	}
+	binary.LittleEndian.PutUint32(scratch[:4], heightCode)
+	buf.Write(scratch[:4])
+	binary.LittleEndian.PutUint64(scratch[:], uint64(amount))
+	buf.Write(scratch[:])
+
+	// Writing to a bytes.Buffer never fails.
+	_ = wire.WriteVarBytes(&buf, 0, pkScript)
+	return buf.Bytes()
+}
+
+// add updates the statistics for the addition of the passed unspent output.
+func (s *utxoSetStats) add(outpoint wire.OutPoint, amount int64,
+	pkScript []byte, height int32, isCoinBase bool) {
+
+	s.muHash.Add(utxoSetHashElement(outpoint, amount, pkScript, height,
+		isCoinBase))
+	s.txOuts++
+	s.totalAmount += amount
+	s.bogoSize += bogoSizeOverhead + uint64(len(pkScript))
+}
+
+// remove updates the statistics for the removal of the passed unspent output.
+func (s *utxoSetStats) remove(outpoint wire.OutPoint, amount int64,
+	pkScript []byte, height int32, isCoinBase bool) {
+
+	s.muHash.Remove(utxoSetHashElement(outpoint, amount, pkScript, height,
+		isCoinBase))
+	s.txOuts--
+	s.totalAmount -= amount
+	s.bogoSize -= bogoSizeOverhead + uint64(len(pkScript))
+}
+
+// applyBlock updates the statistics for the outputs spent and created by the
+// passed block at the passed height.  The statistics are updated for the block
+// being connected when connect is true and for the block being disconnected
+// otherwise.  The passed stxos must be the spent outputs of the block as stored
+// in the spend journal.
+//
+// NOTE: Outputs that overwrite an unspent output with the same outpoint, which
+// only happened for two historical coinbase transactions on the main network,
+// are not accounted for.
+func (s *utxoSetStats) applyBlock(block *btcutil.Block, height int32,
+	stxos []SpentTxOut, connect bool) {
+
+	addOrRemove := func(add bool, outpoint wire.OutPoint, amount int64,
+		pkScript []byte, height int32, isCoinBase bool) {
+
+		if add {
+			s.add(outpoint, amount, pkScript, height, isCoinBase)
+		} else {
+			s.remove(outpoint, amount, pkScript, height, isCoinBase)
+		}
+	}
+
+	var stxoIdx int
+	for txIdx, tx := range block.Transactions() {
+		// The outputs spent by the transaction are removed from the
+		// set when connecting and restored when disconnecting.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+				addOrRemove(!connect, txIn.PreviousOutPoint,
+					stxo.Amount, stxo.PkScript, stxo.Height,
+					stxo.IsCoinBase)
+			}
+		}
+
+		// The outputs created by the transaction are added to the set
+		// when connecting and removed when disconnecting.
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(txOutIdx)
+			addOrRemove(connect, outpoint, txOut.Value,
+				txOut.PkScript, height, txIdx == 0)
+		}
+	}
+}
+
+// serialize returns the serialized statistics.  The format is the serialized
+// rolling hash followed by the number of unspent outputs, their total amount,
+// and the bogo size as 64-bit little-endian integers.
+func (s *utxoSetStats) serialize() []byte {
+	serialized := make([]byte, utxoSetStatsSize)
+	copy(serialized, s.muHash.Serialize())
+	offset := muhash.SerializedSize
+	byteOrder.PutUint64(serialized[offset:], s.txOuts)
+	byteOrder.PutUint64(serialized[offset+8:], uint64(s.totalAmount))
+	byteOrder.PutUint64(serialized[offset+16:], s.bogoSize)
+	return serialized
+}
+
+// deserializeUtxoSetStats returns the statistics stored in the passed
+// serialized data as created by serialize.
+func deserializeUtxoSetStats(serialized []byte) (*utxoSetStats, error) {
+	if len(serialized) != utxoSetStatsSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo set statistics",
+		}
+	}
+
+	muHash, err := muhash.Deserialize(serialized[:muhash.SerializedSize])
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo set hash: " + err.Error(),
+		}
+	}
+	offset := muhash.SerializedSize
+	return &utxoSetStats{
+		muHash:      muHash,
+		txOuts:      byteOrder.Uint64(serialized[offset:]),
+		totalAmount: int64(byteOrder.Uint64(serialized[offset+8:])),
+		bogoSize:    byteOrder.Uint64(serialized[offset+16:]),
+	}, nil
+}
+
+// dbPutUtxoSetStats uses an existing database transaction to store the passed
+// utxo set statistics.
+func dbPutUtxoSetStats(dbTx database.Tx, stats *utxoSetStats) error {
+	return dbTx.Metadata().Put(utxoSetStatsKeyName, stats.serialize())
+}
+
+// dbFetchUtxoSetStats uses an existing database transaction to fetch the
+// stored utxo set statistics.  It returns nil when there are none, which is the
+// case for databases created before the statistics were introduced.
+func dbFetchUtxoSetStats(dbTx database.Tx) (*utxoSetStats, error) {
+	serialized := dbTx.Metadata().Get(utxoSetStatsKeyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	return deserializeUtxoSetStats(serialized)
+}
+
+// outpointFromKey returns the outpoint for the passed utxo set key as created
+// by outpointKey.
+func outpointFromKey(key []byte) (wire.OutPoint, error) {
+	var outpoint wire.OutPoint
+	if len(key) <= chainhash.HashSize {
+		return outpoint, errDeserialize("unexpected end of data")
+	}
+	index, bytesRead := deserializeVLQ(key[chainhash.HashSize:])
+	if bytesRead != len(key)-chainhash.HashSize || index > 0xffffffff {
+		return outpoint, errDeserialize("invalid output index")
+	}
+	copy(outpoint.Hash[:], key[:chainhash.HashSize])
+	outpoint.Index = uint32(index)
+	return outpoint, nil
+}
+
+// calcUtxoSetStats uses an existing database transaction to calculate the
+// statistics of the utxo set stored in the database by scanning it.
+func calcUtxoSetStats(dbTx database.Tx, interrupt <-chan struct{}) (*utxoSetStats, error) {
+	stats := newUtxoSetStats()
+	lastLogTime := time.Now()
+	cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		outpoint, err := outpointFromKey(cursor.Key())
+		if err != nil {
+			return nil, err
+		}
+		entry, err := deserializeUtxoEntry(cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		stats.add(outpoint, entry.Amount(), entry.PkScript(),
+			entry.BlockHeight(), entry.IsCoinBase())
+
+		if stats.txOuts%100000 == 0 {
+			if interruptRequested(interrupt) {
+				return nil, errInterruptRequested
+			}
+			if now := time.Now(); now.Sub(lastLogTime) > 10*time.Second {
+				log.Infof("Hashed %d unspent outputs", stats.txOuts)
+				lastLogTime = now
+			}
+		}
+	}
+	return stats, nil
+}
+
+// initUtxoSetStats loads the utxo set statistics of the best block from the
+// database.  When the database does not have them yet, they are calculated by
+// scanning the utxo set, which requires flushing the utxo cache first.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) initUtxoSetStats(interrupt <-chan struct{}) error {
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		b.utxoSetStats, err = dbFetchUtxoSetStats(dbTx)
+		return err
+	})
+	if err != nil || b.utxoSetStats != nil {
+		return err
+	}
+
+	log.Infof("Calculating the utxo set hash.  This might take a while...")
+	return b.db.Update(func(dbTx database.Tx) error {
+		b.stateLock.RLock()
+		state := b.stateSnapshot
+		b.stateLock.RUnlock()
+		err := b.utxoCache.flush(dbTx, FlushRequired, state)
+		if err != nil {
+			return err
+		}
+
+		stats, err := calcUtxoSetStats(dbTx, interrupt)
+		if err != nil {
+			return err
+		}
+		if err := dbPutUtxoSetStats(dbTx, stats); err != nil {
+			return err
+		}
+		b.utxoSetStats = stats
+		log.Infof("Utxo set hash at height %d: %v", state.Height,
+			stats.muHash.Clone().Finalize())
+		return nil
+	})
+}
+
+// UtxoSetHash returns the MuHash3072 of the utxo set as of the end of the main
+// chain.  The hash is maintained as blocks are connected and disconnected, so
+// this is cheap.  It matches the muhash reported by the gettxoutsetinfo RPC of
+// Bitcoin Core for the same block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoSetHash() chainhash.Hash {
+	return b.UtxoSetStats().MuHash
+}
+
+// UtxoSetStats returns statistics about the utxo set, including its hash, as of
+// the end of the main chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoSetStats() *UtxoSetStats {
+	b.stateLock.RLock()
+	state := b.stateSnapshot
+	stats := b.utxoSetStats.clone()
+	b.stateLock.RUnlock()
+
+	return &UtxoSetStats{
+		Hash:        state.Hash,
+		Height:      state.Height,
+		MuHash:      stats.muHash.Finalize(),
+		TxOuts:      stats.txOuts,
+		TotalAmount: stats.totalAmount,
+		BogoSize:    stats.bogoSize,
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestUtxoSetStats ensures the utxo set statistics maintained as blocks are
+// connected and disconnected match the statistics calculated by scanning the
+// utxo set.
+func TestUtxoSetStats(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxosetstats",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	// checkStats ensures the maintained statistics match the statistics
+	// calculated from the utxo set in the database.
+	checkStats := func(height int32) {
+		t.Helper()
+
+		stats := chain.UtxoSetStats()
+		if stats.Height != height {
+			t.Fatalf("got stats for height %d, want %d",
+				stats.Height, height)
+		}
+
+		var calculated *utxoSetStats
+		err := chain.db.Update(func(dbTx database.Tx) error {
+			err := chain.utxoCache.flush(dbTx, FlushRequired,
+				chain.BestSnapshot())
+			if err != nil {
+				return err
+			}
+			calculated, err = calcUtxoSetStats(dbTx, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("unable to calculate utxo set stats: %v", err)
+		}
+		if got, want := stats.MuHash, calculated.muHash.Finalize(); got != want {
+			t.Fatalf("height %d: got utxo set hash %v, want %v",
+				height, got, want)
+		}
+		if stats.TxOuts != calculated.txOuts ||
+			stats.TotalAmount != calculated.totalAmount ||
+			stats.BogoSize != calculated.bogoSize {
+
+			t.Fatalf("height %d: got stats %+v, want %+v", height,
+				stats, calculated)
+		}
+		if hash := chain.UtxoSetHash(); hash != stats.MuHash {
+			t.Fatalf("height %d: got utxo set hash %v, want %v",
+				height, hash, stats.MuHash)
+		}
+	}
+
+	emptyHash := chain.UtxoSetHash()
+	checkStats(0)
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock #%d: unexpected error: %v", i, err)
+		}
+		checkStats(int32(i))
+	}
+
+	// Disconnecting the blocks must restore the statistics.
+	for i := len(blocks) - 1; i > 0; i-- {
+		if err := chain.InvalidateBlock(blocks[i].Hash()); err != nil {
+			t.Fatalf("InvalidateBlock #%d: unexpected error: %v", i,
+				err)
+		}
+		checkStats(int32(i - 1))
+	}
+	if hash := chain.UtxoSetHash(); hash != emptyHash {
+		t.Fatalf("got utxo set hash %v for the empty set, want %v",
+			hash, emptyHash)
+	}
+}
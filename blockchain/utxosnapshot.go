@@ -0,0 +1,1018 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// utxoSnapshotVersion is the current version of the utxo snapshot
+	// file format.
+	utxoSnapshotVersion = 1
+
+	// snapshotCoinBatchSize is the number of coins written to the database
+	// per transaction while loading a snapshot.
+	snapshotCoinBatchSize = 100000
+
+	// snapshotNodeChunkSize is the number of block nodes allocated at a
+	// time while reading the headers of a snapshot.  The nodes are allocated
+	// as the headers are read rather than up front since the base height of
+	// a snapshot is not trusted.
+	snapshotNodeChunkSize = 50000
+
+	// snapshotValidationFlushBlocks is the number of blocks connected during
+	// background validation of a snapshot before the validation utxo set is
+	// written to the database.
+	snapshotValidationFlushBlocks = 2000
+)
+
+var (
+	// utxoSnapshotMagic is the magic that identifies a utxo snapshot file.
+	utxoSnapshotMagic = [5]byte{'u', 't', 'x', 'o', 0xff}
+
+	// utxoSnapshotStateKeyName is the name of the db key used to store the
+	// state of a utxo snapshot loaded into the database.
+	utxoSnapshotStateKeyName = []byte("utxosnapshotstate")
+
+	// snapshotValidationBucketName is the name of the db bucket used to
+	// house the utxo set that is rebuilt from the history of the chain in
+	// order to validate a loaded utxo snapshot.
+	snapshotValidationBucketName = []byte("snapshotvalidationutxos")
+)
+
+// UtxoSnapshotMetadata describes a utxo snapshot file.
+type UtxoSnapshotMetadata struct {
+	// Network is the bitcoin network the snapshot is for.
+	Network wire.BitcoinNet
+
+	// BaseHash and BaseHeight identify the block the utxo set in the
+	// snapshot is the result of.
+	BaseHash   chainhash.Hash
+	BaseHeight int32
+
+	// TotalTxns is the total number of transactions in the chain up to and
+	// including the base block.
+	TotalTxns uint64
+
+	// NumCoins is the number of unspent transaction outputs in the
+	// snapshot.
+	NumCoins uint64
+
+	// UtxoSetHash is the hash of the serialized utxo set.  It commits to
+	// every unspent output in the snapshot and is the value that must be
+	// obtained from a trusted source before loading a snapshot.
+	UtxoSetHash chainhash.Hash
+}
+
+// UtxoSnapshotStatus describes the state of a utxo snapshot that was loaded
+// into the chain.
+type UtxoSnapshotStatus struct {
+	// BaseHash and BaseHeight identify the block the loaded utxo set is the
+	// result of.
+	BaseHash   chainhash.Hash
+	BaseHeight int32
+
+	// UtxoSetHash is the hash of the loaded utxo set.
+	UtxoSetHash chainhash.Hash
+
+	// ValidatedHeight is the height up to which the history of the chain
+	// was validated in the background.
+	ValidatedHeight int32
+
+	// Validated is set once the utxo set rebuilt from the history of the
+	// chain was found to match the loaded snapshot.
+	Validated bool
+
+	// Invalid is set when the utxo set rebuilt from the history of the
+	// chain does not match the loaded snapshot.
+	Invalid bool
+}
+
+// snapshotState represents the status of a loaded utxo snapshot as stored in
+// the database.
+type snapshotState uint8
+
+const (
+	// snapshotLoading indicates a snapshot load was started but not
+	// completed.
+	snapshotLoading snapshotState = iota
+
+	// snapshotPending indicates the snapshot was loaded and its history is
+	// awaiting validation.
+	snapshotPending
+
+	// snapshotValidated indicates the history of the snapshot was validated.
+	snapshotValidated
+
+	// snapshotInvalid indicates the history of the chain does not produce
+	// the utxo set in the snapshot.
+	snapshotInvalid
+)
+
+// utxoSnapshotState is the state of a loaded utxo snapshot as stored in the
+// database.
+//
+// The serialized format is:
+//
+//	<state><base hash><base height><utxo set hash><validated height>
+//
+//	Field             Type             Size
+//	state             uint8            1
+//	base hash         chainhash.Hash   chainhash.HashSize
+//	base height       uint32           4
+//	utxo set hash     chainhash.Hash   chainhash.HashSize
+//	validated height  uint32           4
+type utxoSnapshotState struct {
+	state           snapshotState
+	baseHash        chainhash.Hash
+	baseHeight      int32
+	utxoSetHash     chainhash.Hash
+	validatedHeight int32
+}
+
+// serializeUtxoSnapshotState returns the serialization of the passed snapshot
+// state.
+func serializeUtxoSnapshotState(s *utxoSnapshotState) []byte {
+	serialized := make([]byte, 1+2*chainhash.HashSize+8)
+	serialized[0] = byte(s.state)
+	offset := 1
+	copy(serialized[offset:], s.baseHash[:])
+	offset += chainhash.HashSize
+	byteOrder.PutUint32(serialized[offset:], uint32(s.baseHeight))
+	offset += 4
+	copy(serialized[offset:], s.utxoSetHash[:])
+	offset += chainhash.HashSize
+	byteOrder.PutUint32(serialized[offset:], uint32(s.validatedHeight))
+	return serialized
+}
+
+// dbFetchUtxoSnapshotState uses an existing database transaction to fetch the
+// state of a loaded utxo snapshot.  It returns nil when no snapshot was loaded.
+func dbFetchUtxoSnapshotState(dbTx database.Tx) (*utxoSnapshotState, error) {
+	serialized := dbTx.Metadata().Get(utxoSnapshotStateKeyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	if len(serialized) != 1+2*chainhash.HashSize+8 {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo snapshot state",
+		}
+	}
+
+	var s utxoSnapshotState
+	s.state = snapshotState(serialized[0])
+	offset := 1
+	copy(s.baseHash[:], serialized[offset:])
+	offset += chainhash.HashSize
+	s.baseHeight = int32(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	copy(s.utxoSetHash[:], serialized[offset:])
+	offset += chainhash.HashSize
+	s.validatedHeight = int32(byteOrder.Uint32(serialized[offset:]))
+	return &s, nil
+}
+
+// dbPutUtxoSnapshotState uses an existing database transaction to store the
+// state of a loaded utxo snapshot.
+func dbPutUtxoSnapshotState(dbTx database.Tx, s *utxoSnapshotState) error {
+	return dbTx.Metadata().Put(utxoSnapshotStateKeyName,
+		serializeUtxoSnapshotState(s))
+}
+
+// writeSnapshotCoin writes a coin to the passed writer using the format of
+// the coins in a utxo snapshot, which is the key of the coin in the utxo set
+// followed by the length of the serialized utxo entry and the entry itself.
+// The same serialization is used to calculate the utxo set hash.
+func writeSnapshotCoin(w io.Writer, key, serializedEntry []byte) error {
+	var lenBuf [10]byte
+	n := putVLQ(lenBuf[:], uint64(len(serializedEntry)))
+	if _, err := w.Write(key); err != nil {
+		return err
+	}
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(serializedEntry)
+	return err
+}
+
+// readVLQ reads a variable length quantity encoded as described by putVLQ
+// from the passed reader.
+func readVLQ(r io.ByteReader) (uint64, int, error) {
+	var n uint64
+	var size int
+	for {
+		val, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		size++
+		if size > 10 {
+			return 0, 0, errDeserialize("variable length quantity " +
+				"is too long")
+		}
+		n = (n << 7) | uint64(val&0x7f)
+		if val&0x80 != 0x80 {
+			break
+		}
+		n++
+	}
+	return n, size, nil
+}
+
+// readSnapshotCoin reads a coin written by writeSnapshotCoin from the passed
+// reader and returns the key of the coin in the utxo set along with the
+// serialized utxo entry.
+func readSnapshotCoin(r *bufio.Reader) ([]byte, []byte, error) {
+	key := make([]byte, chainhash.HashSize, chainhash.HashSize+
+		maxUint32VLQSerializeSize)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, nil, err
+	}
+	index, _, err := readVLQ(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if index > 0xffffffff {
+		return nil, nil, errDeserialize("output index is too large")
+	}
+	var indexBuf [10]byte
+	key = append(key, indexBuf[:putVLQ(indexBuf[:], index)]...)
+
+	entryLen, _, err := readVLQ(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entryLen == 0 || entryLen > wire.MaxBlockPayload {
+		return nil, nil, errDeserialize(fmt.Sprintf("invalid utxo "+
+			"entry length %d", entryLen))
+	}
+	serialized := make([]byte, entryLen)
+	if _, err := io.ReadFull(r, serialized); err != nil {
+		return nil, nil, err
+	}
+	return key, serialized, nil
+}
+
+// hashUtxoBucket returns the hash of the passed utxo set bucket calculated by
+// the same means as the utxo set hash of a snapshot along with the number of
+// coins in it.
+func hashUtxoBucket(bucket database.Bucket) (chainhash.Hash, uint64, error) {
+	hasher := sha256.New()
+	var numCoins uint64
+	err := bucket.ForEach(func(k, v []byte) error {
+		numCoins++
+		return writeSnapshotCoin(hasher, k, v)
+	})
+	if err != nil {
+		return chainhash.Hash{}, 0, err
+	}
+
+	var utxoSetHash chainhash.Hash
+	copy(utxoSetHash[:], hasher.Sum(nil))
+	return utxoSetHash, numCoins, nil
+}
+
+// DumpUtxoSnapshot writes a snapshot of the utxo set to the passed writer.  The
+// utxo cache is flushed first, so the snapshot is taken at the current tip of
+// the main chain unless a block is connected concurrently, in which case the
+// dump fails and must be retried.
+//
+// The snapshot consists of a header describing the base block of the utxo set,
+// the block headers of the main chain up to the base block, the base block
+// itself, and the utxo set in the compressed format used by the database.  The
+// hash of the utxo set is appended so the snapshot can be checked against a
+// trusted value when it is loaded via LoadUtxoSnapshot.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) DumpUtxoSnapshot(w io.Writer) (*UtxoSnapshotMetadata, error) {
+	if err := b.FlushUtxoCache(FlushRequired); err != nil {
+		return nil, err
+	}
+
+	var meta *UtxoSnapshotMetadata
+	err := b.db.View(func(dbTx database.Tx) error {
+		// The utxo set in the database must represent the best chain
+		// tip since the snapshot would otherwise not be consistent.
+		state, err := deserializeBestChainState(
+			dbTx.Metadata().Get(chainStateKeyName))
+		if err != nil {
+			return err
+		}
+		consistentHash, _, err := dbFetchUtxoStateConsistency(dbTx)
+		if err != nil {
+			return err
+		}
+		if consistentHash == nil || *consistentHash != state.hash {
+			return fmt.Errorf("the utxo set is not consistent with "+
+				"the best chain tip %v", state.hash)
+		}
+		base := b.index.LookupNode(&state.hash)
+		if base == nil || !b.MainChainHasBlock(&state.hash) {
+			return fmt.Errorf("the best chain tip changed while " +
+				"creating the snapshot")
+		}
+		if base.height == 0 {
+			return fmt.Errorf("no blocks to create a snapshot of")
+		}
+		baseBlockBytes, err := dbTx.FetchBlock(&base.hash)
+		if err != nil {
+			return err
+		}
+
+		// Calculate the hash of the utxo set and the number of coins
+		// before writing anything.
+		utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		utxoSetHash, numCoins, err := hashUtxoBucket(utxoBucket)
+		if err != nil {
+			return err
+		}
+		meta = &UtxoSnapshotMetadata{
+			Network:     b.chainParams.Net,
+			BaseHash:    base.hash,
+			BaseHeight:  base.height,
+			TotalTxns:   state.totalTxns,
+			NumCoins:    numCoins,
+			UtxoSetHash: utxoSetHash,
+		}
+
+		bw := bufio.NewWriterSize(w, 1<<20)
+		if err := writeUtxoSnapshotHeader(bw, meta); err != nil {
+			return err
+		}
+
+		// Write the headers of all blocks from the block after the
+		// genesis block through the base block.
+		for height := int32(1); height <= base.height; height++ {
+			header := base.Ancestor(height).Header()
+			if err := header.Serialize(bw); err != nil {
+				return err
+			}
+		}
+
+		// Write the base block since it is required to initialize the
+		// chain state.
+		var lenBuf [4]byte
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(baseBlockBytes)))
+		if _, err := bw.Write(lenBuf[:]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(baseBlockBytes); err != nil {
+			return err
+		}
+
+		// Write all of the coins followed by the utxo set hash.
+		err = utxoBucket.ForEach(func(k, v []byte) error {
+			return writeSnapshotCoin(bw, k, v)
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(utxoSetHash[:]); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// writeUtxoSnapshotHeader writes the header of a utxo snapshot described by the
+// passed metadata.
+//
+// The serialized format is:
+//
+//	<magic><version><network><base hash><base height><total txns><num coins>
+//
+//	Field         Type             Size
+//	magic         [5]byte          5
+//	version       uint16           2
+//	network       uint32           4
+//	base hash     chainhash.Hash   chainhash.HashSize
+//	base height   uint32           4
+//	total txns    uint64           8
+//	num coins     uint64           8
+func writeUtxoSnapshotHeader(w io.Writer, meta *UtxoSnapshotMetadata) error {
+	var buf [5 + 2 + 4 + chainhash.HashSize + 4 + 8 + 8]byte
+	offset := copy(buf[:], utxoSnapshotMagic[:])
+	binary.LittleEndian.PutUint16(buf[offset:], utxoSnapshotVersion)
+	offset += 2
+	binary.LittleEndian.PutUint32(buf[offset:], uint32(meta.Network))
+	offset += 4
+	offset += copy(buf[offset:], meta.BaseHash[:])
+	binary.LittleEndian.PutUint32(buf[offset:], uint32(meta.BaseHeight))
+	offset += 4
+	binary.LittleEndian.PutUint64(buf[offset:], meta.TotalTxns)
+	offset += 8
+	binary.LittleEndian.PutUint64(buf[offset:], meta.NumCoins)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// readUtxoSnapshotHeader reads the header of a utxo snapshot written by
+// writeUtxoSnapshotHeader.  The utxo set hash of the returned metadata is not
+// set since it is stored at the end of the snapshot.
+func readUtxoSnapshotHeader(r io.Reader) (*UtxoSnapshotMetadata, error) {
+	var buf [5 + 2 + 4 + chainhash.HashSize + 4 + 8 + 8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(buf[:5], utxoSnapshotMagic[:]) {
+		return nil, errors.New("not a utxo snapshot file")
+	}
+	offset := 5
+	version := binary.LittleEndian.Uint16(buf[offset:])
+	offset += 2
+	if version != utxoSnapshotVersion {
+		return nil, fmt.Errorf("unsupported utxo snapshot version %d",
+			version)
+	}
+
+	var meta UtxoSnapshotMetadata
+	meta.Network = wire.BitcoinNet(binary.LittleEndian.Uint32(buf[offset:]))
+	offset += 4
+	offset += copy(meta.BaseHash[:], buf[offset:])
+	meta.BaseHeight = int32(binary.LittleEndian.Uint32(buf[offset:]))
+	offset += 4
+	meta.TotalTxns = binary.LittleEndian.Uint64(buf[offset:])
+	offset += 8
+	meta.NumCoins = binary.LittleEndian.Uint64(buf[offset:])
+	if meta.BaseHeight <= 0 {
+		return nil, fmt.Errorf("invalid snapshot base height %d",
+			meta.BaseHeight)
+	}
+	return &meta, nil
+}
+
+// LoadUtxoSnapshot loads a utxo snapshot created by DumpUtxoSnapshot into a
+// chain that does not have any blocks other than the genesis block.  Once
+// loaded, the chain continues from the base block of the snapshot as if all of
+// the blocks before it had been connected.
+//
+// The headers in the snapshot must form a valid chain that satisfies all of the
+// checkpoints, and the hash of the utxo set must match the passed expected
+// hash, which must be obtained from a trusted source since the utxo set can't
+// otherwise be verified without the history of the chain.  The history can be
+// validated afterwards via ValidateUtxoSnapshot.
+//
+// Since the blocks before the base block are not available, the optional
+// indexes can't be built for them and the chain can't be reorganized below the
+// base block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LoadUtxoSnapshot(r io.Reader,
+	expectedHash *chainhash.Hash) (_ *UtxoSnapshotMetadata, err error) {
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.bestChain.Height() != 0 {
+		return nil, errors.New("utxo snapshots can only be loaded into " +
+			"a chain without any blocks")
+	}
+
+	br := bufio.NewReaderSize(r, 1<<20)
+	meta, err := readUtxoSnapshotHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Network != b.chainParams.Net {
+		return nil, fmt.Errorf("snapshot is for network %v instead of %v",
+			meta.Network, b.chainParams.Net)
+	}
+
+	// Read and validate the headers while building the nodes that will be
+	// added to the block index.  The nodes are not added to the index
+	// until the entire snapshot is validated.
+	log.Infof("Loading %d headers from the utxo snapshot", meta.BaseHeight)
+	var nodes []*blockNode
+	var chunk []blockNode
+	parent := b.bestChain.Genesis()
+	for parent.height < meta.BaseHeight {
+		var header wire.BlockHeader
+		if err := header.Deserialize(br); err != nil {
+			return nil, err
+		}
+		if header.PrevBlock != parent.hash {
+			return nil, fmt.Errorf("header at height %d does not "+
+				"connect to the previous header", parent.height+1)
+		}
+		err := CheckBlockHeaderSanity(&header, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return nil, err
+		}
+		err = CheckBlockHeaderContext(&header, parent, BFNone, b, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(chunk) == 0 {
+			chunk = make([]blockNode, snapshotNodeChunkSize)
+		}
+		node := &chunk[0]
+		chunk = chunk[1:]
+		initBlockNode(node, &header, parent)
+		if !b.verifyCheckpoint(node.height, &node.hash) {
+			return nil, ruleError(ErrBadCheckpoint, fmt.Sprintf(
+				"header at height %d does not match checkpoint "+
+					"hash", node.height))
+		}
+		node.status = statusValid
+		nodes = append(nodes, node)
+		parent = node
+	}
+	base := parent
+	if base.hash != meta.BaseHash {
+		return nil, fmt.Errorf("snapshot headers end at %v instead of "+
+			"the base block %v", base.hash, meta.BaseHash)
+	}
+
+	// Read the base block and ensure it matches its header.
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	blockLen := binary.LittleEndian.Uint32(lenBuf[:])
+	if blockLen > wire.MaxBlockPayload {
+		return nil, fmt.Errorf("base block size %d is too large",
+			blockLen)
+	}
+	baseBlockBytes := make([]byte, blockLen)
+	if _, err := io.ReadFull(br, baseBlockBytes); err != nil {
+		return nil, err
+	}
+	baseBlock, err := btcutil.NewBlockFromBytes(baseBlockBytes)
+	if err != nil {
+		return nil, err
+	}
+	if *baseBlock.Hash() != base.hash {
+		return nil, errors.New("base block does not match its header")
+	}
+	err = checkBlockSanity(baseBlock, b.chainParams.PowLimit, b.timeSource,
+		BFNone)
+	if err != nil {
+		return nil, err
+	}
+	baseBlock.SetHeight(base.height)
+
+	// Mark the load as in progress and clear any coins left over from an
+	// interrupted load before loading the coins.
+	snapState := &utxoSnapshotState{
+		state:      snapshotLoading,
+		baseHash:   base.hash,
+		baseHeight: base.height,
+	}
+	err = b.db.Update(func(dbTx database.Tx) error {
+		if err := resetUtxoSetBucket(dbTx); err != nil {
+			return err
+		}
+		return dbPutUtxoSnapshotState(dbTx, snapState)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the partially loaded coins when the load fails so the chain
+	// remains usable from the genesis block.
+	defer func() {
+		if err == nil {
+			return
+		}
+		cleanupErr := b.db.Update(dbRemovePartialUtxoSnapshot)
+		if cleanupErr != nil {
+			log.Errorf("Unable to remove partially loaded utxo "+
+				"snapshot: %v", cleanupErr)
+		}
+	}()
+
+	// Load the coins in batches while calculating the utxo set hash.
+	log.Infof("Loading %d coins from the utxo snapshot", meta.NumCoins)
+	hasher := sha256.New()
+	utxoStats := newUtxoSetStats()
+	lastLogTime := time.Now()
+	for loaded := uint64(0); loaded < meta.NumCoins; {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+			for i := 0; i < snapshotCoinBatchSize &&
+				loaded < meta.NumCoins; i++ {
+
+				err := loadSnapshotCoin(br, hasher, utxoStats,
+					utxoBucket, base.height)
+				if err != nil {
+					return err
+				}
+				loaded++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if now := time.Now(); now.Sub(lastLogTime) > 10*time.Second {
+			log.Infof("Loaded %d of %d coins", loaded, meta.NumCoins)
+			lastLogTime = now
+		}
+	}
+	copy(meta.UtxoSetHash[:], hasher.Sum(nil))
+
+	// Ensure the utxo set matches both the hash stored in the snapshot and
+	// the trusted hash.
+	var storedHash chainhash.Hash
+	if _, err := io.ReadFull(br, storedHash[:]); err != nil {
+		return nil, err
+	}
+	if storedHash != meta.UtxoSetHash {
+		return nil, fmt.Errorf("utxo set hash %v does not match the hash "+
+			"%v stored in the snapshot", meta.UtxoSetHash, storedHash)
+	}
+	if expectedHash == nil || *expectedHash != meta.UtxoSetHash {
+		return nil, fmt.Errorf("utxo set hash %v does not match the "+
+			"expected hash %v", meta.UtxoSetHash, expectedHash)
+	}
+
+	// Finally, add the nodes to the block index along with the hash and
+	// height mappings of the main chain, store the base block, and switch
+	// the best chain state over to it.  This is done in a single
+	// transaction so a failed load doesn't leave any of them behind.
+	numTxns := uint64(len(baseBlock.MsgBlock().Transactions))
+	blockWeight := uint64(GetBlockWeight(baseBlock))
+	state := newBestState(base, uint64(blockLen), blockWeight, numTxns,
+		meta.TotalTxns, CalcPastMedianTime(base))
+	snapState.state = snapshotPending
+	snapState.utxoSetHash = meta.UtxoSetHash
+	base.status |= statusDataStored
+	err = b.db.Update(func(dbTx database.Tx) error {
+		for _, node := range nodes {
+			if err := dbStoreBlockNode(dbTx, node); err != nil {
+				return err
+			}
+			err := dbPutBlockIndex(dbTx, &node.hash, node.height)
+			if err != nil {
+				return err
+			}
+		}
+		if err := dbStoreBlock(dbTx, baseBlock); err != nil {
+			return err
+		}
+		err := dbPutBestState(dbTx, state, base.workSum)
+		if err != nil {
+			return err
+		}
+		err = dbPutUtxoStateConsistency(dbTx, &base.hash,
+			b.utxoCache.epoch+1)
+		if err != nil {
+			return err
+		}
+		if err := dbPutUtxoSetStats(dbTx, utxoStats); err != nil {
+			return err
+		}
+		return dbPutUtxoSnapshotState(dbTx, snapState)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		b.index.addNode(node)
+	}
+	b.bestChain.SetTip(base)
+	b.utxoCache.lastFlushHash = base.hash
+	b.utxoCache.epoch++
+	b.stateLock.Lock()
+	b.stateSnapshot = state
+	b.utxoSetStats = utxoStats
+	b.stateLock.Unlock()
+
+	log.Infof("Loaded utxo snapshot at block %v (height %d)", base.hash,
+		base.height)
+	return meta, nil
+}
+
+// resetUtxoSetBucket uses an existing database transaction to remove all
+// entries from the utxo set.
+func resetUtxoSetBucket(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	if err := meta.DeleteBucket(utxoSetBucketName); err != nil {
+		return err
+	}
+	_, err := meta.CreateBucket(utxoSetBucketName)
+	return err
+}
+
+// dbRemovePartialUtxoSnapshot uses an existing database transaction to remove
+// the coins and the state of a utxo snapshot that was not completely loaded.
+// The block index isn't affected since the nodes of a snapshot are only stored
+// once all of its coins are loaded.
+func dbRemovePartialUtxoSnapshot(dbTx database.Tx) error {
+	if err := resetUtxoSetBucket(dbTx); err != nil {
+		return err
+	}
+	return dbTx.Metadata().Delete(utxoSnapshotStateKeyName)
+}
+
+// loadSnapshotCoin reads a coin from a snapshot, ensures it is valid, adds it
+// to the passed hash and utxo set statistics, and stores it in the passed utxo
+// set bucket.
+func loadSnapshotCoin(r *bufio.Reader, hasher hash.Hash, utxoStats *utxoSetStats,
+	utxoBucket database.Bucket, baseHeight int32) error {
+
+	key, serialized, err := readSnapshotCoin(r)
+	if err != nil {
+		return err
+	}
+	entry, err := deserializeUtxoEntry(serialized)
+	if err != nil {
+		return err
+	}
+	if entry.BlockHeight() > baseHeight {
+		return fmt.Errorf("coin at height %d is after the base block",
+			entry.BlockHeight())
+	}
+	outpoint, err := outpointFromKey(key)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshotCoin(hasher, key, serialized); err != nil {
+		return err
+	}
+	utxoStats.add(outpoint, entry.Amount(), entry.PkScript(),
+		entry.BlockHeight(), entry.IsCoinBase())
+	return utxoBucket.Put(key, serialized)
+}
+
+// UtxoSnapshotStatus returns the status of the utxo snapshot that was loaded
+// into the chain or nil when the chain was not initialized from a snapshot.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoSnapshotStatus() (*UtxoSnapshotStatus, error) {
+	var s *utxoSnapshotState
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		s, err = dbFetchUtxoSnapshotState(dbTx)
+		return err
+	})
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	return &UtxoSnapshotStatus{
+		BaseHash:        s.baseHash,
+		BaseHeight:      s.baseHeight,
+		UtxoSetHash:     s.utxoSetHash,
+		ValidatedHeight: s.validatedHeight,
+		Validated:       s.state == snapshotValidated,
+		Invalid:         s.state == snapshotInvalid,
+	}, nil
+}
+
+// loadSnapshotValidationUtxos adds the entries from the validation utxo set for
+// the passed outpoints to the view.  Outpoints that are not in the set are
+// added as spent entries so they are not loaded from the utxo set of the chain,
+// which represents the state at the tip rather than at the block being
+// validated.
+func loadSnapshotValidationUtxos(dbTx database.Tx, view *UtxoViewpoint,
+	outpoints []wire.OutPoint) error {
+
+	bucket := dbTx.Metadata().Bucket(snapshotValidationBucketName)
+	for _, outpoint := range outpoints {
+		if _, ok := view.entries[outpoint]; ok {
+			continue
+		}
+		entry, err := dbFetchUtxoEntry(dbTx, bucket, outpoint)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			entry = &UtxoEntry{packedFlags: tfSpent}
+		}
+		view.entries[outpoint] = entry
+	}
+	return nil
+}
+
+// flushSnapshotValidationUtxos writes the modified entries of the passed view to
+// the validation utxo set and records the passed height as validated.
+func flushSnapshotValidationUtxos(dbTx database.Tx, view *UtxoViewpoint,
+	snapState *utxoSnapshotState, height int32) error {
+
+	bucket := dbTx.Metadata().Bucket(snapshotValidationBucketName)
+	for outpoint, entry := range view.entries {
+		if entry == nil || !entry.isModified() {
+			continue
+		}
+		var err error
+		if entry.IsSpent() {
+			err = dbDeleteUtxoEntry(bucket, outpoint)
+		} else {
+			err = dbPutUtxoEntry(bucket, outpoint, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	snapState.validatedHeight = height
+	return dbPutUtxoSnapshotState(dbTx, snapState)
+}
+
+// ValidateUtxoSnapshot validates the history of a chain that was initialized
+// from a utxo snapshot.  The blocks from the genesis block up to the base block
+// of the snapshot are obtained via the passed function, fully validated, and
+// connected to a separate utxo set.  Once the base block is reached, the hash
+// of the resulting utxo set is compared against the hash of the snapshot.
+//
+// The chain continues to process new blocks while the validation is running,
+// and the progress is saved regularly so an interrupted validation resumes
+// where it left off.  An error is returned when the snapshot is found to be
+// invalid, in which case the chain state must not be trusted.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ValidateUtxoSnapshot(
+	fetchBlock func(hash *chainhash.Hash) (*btcutil.Block, error),
+	interrupt <-chan struct{}) error {
+
+	var snapState *utxoSnapshotState
+	err := b.db.Update(func(dbTx database.Tx) error {
+		var err error
+		snapState, err = dbFetchUtxoSnapshotState(dbTx)
+		if err != nil || snapState == nil {
+			return err
+		}
+		_, err = dbTx.Metadata().CreateBucketIfNotExists(
+			snapshotValidationBucketName)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case snapState == nil:
+		return errors.New("the chain was not loaded from a utxo snapshot")
+	case snapState.state == snapshotValidated:
+		return nil
+	case snapState.state == snapshotInvalid:
+		return errors.New("the utxo snapshot was found to be invalid")
+	case snapState.state == snapshotLoading:
+		return errors.New("the utxo snapshot was not completely loaded")
+	}
+
+	b.chainLock.RLock()
+	base := b.index.LookupNode(&snapState.baseHash)
+	b.chainLock.RUnlock()
+	if base == nil {
+		return AssertError("utxo snapshot base block is not in the " +
+			"block index")
+	}
+
+	log.Infof("Validating the history of the utxo snapshot from height "+
+		"%d to %d", snapState.validatedHeight+1, base.height)
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&base.Ancestor(snapState.validatedHeight).hash)
+	lastLogTime := time.Now()
+	for height := snapState.validatedHeight + 1; height <= base.height; height++ {
+		select {
+		case <-interrupt:
+			return nil
+		default:
+		}
+
+		node := base.Ancestor(height)
+		block, err := fetchBlock(&node.hash)
+		if err != nil {
+			return err
+		}
+		if *block.Hash() != node.hash {
+			return fmt.Errorf("fetched block %v instead of %v",
+				block.Hash(), node.hash)
+		}
+		block.SetHeight(height)
+
+		// Load the utxos spent and overwritten by the block from the
+		// validation utxo set.
+		outpoints := view.findInputsToFetch(block)
+		for _, tx := range block.Transactions() {
+			prevOut := wire.OutPoint{Hash: *tx.Hash()}
+			for txOutIdx := range tx.MsgTx().TxOut {
+				prevOut.Index = uint32(txOutIdx)
+				outpoints = append(outpoints, prevOut)
+			}
+		}
+		err = b.db.View(func(dbTx database.Tx) error {
+			return loadSnapshotValidationUtxos(dbTx, view, outpoints)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Perform the same validation as when connecting the block to
+		// the main chain.
+		b.chainLock.Lock()
+		err = checkBlockSanity(block, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err == nil {
+			err = b.checkBlockContext(block, node.parent, BFNone)
+		}
+		if err == nil {
+			err = b.checkConnectBlock(node, block, view, nil, BFNone)
+		}
+		b.chainLock.Unlock()
+		if err != nil {
+			return b.markSnapshotInvalid(snapState, fmt.Errorf(
+				"block %v (height %d) is invalid: %v", node.hash,
+				height, err))
+		}
+
+		if height%snapshotValidationFlushBlocks != 0 &&
+			height != base.height {
+
+			continue
+		}
+		err = b.db.Update(func(dbTx database.Tx) error {
+			return flushSnapshotValidationUtxos(dbTx, view,
+				snapState, height)
+		})
+		if err != nil {
+			return err
+		}
+		view = NewUtxoViewpoint()
+		view.SetBestHash(&node.hash)
+
+		if now := time.Now(); now.Sub(lastLogTime) > 10*time.Second {
+			log.Infof("Validated utxo snapshot history up to "+
+				"height %d", height)
+			lastLogTime = now
+		}
+	}
+
+	// Compare the resulting utxo set against the snapshot and remove it
+	// once it matches.
+	var utxoSetHash chainhash.Hash
+	err = b.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(snapshotValidationBucketName)
+		var err error
+		utxoSetHash, _, err = hashUtxoBucket(bucket)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if utxoSetHash != snapState.utxoSetHash {
+		return b.markSnapshotInvalid(snapState, fmt.Errorf("utxo set "+
+			"hash %v at the snapshot base block does not match the "+
+			"snapshot hash %v", utxoSetHash, snapState.utxoSetHash))
+	}
+	snapState.state = snapshotValidated
+	err = b.db.Update(func(dbTx database.Tx) error {
+		err := dbTx.Metadata().DeleteBucket(snapshotValidationBucketName)
+		if err != nil {
+			return err
+		}
+		return dbPutUtxoSnapshotState(dbTx, snapState)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("The history of the utxo snapshot at block %v was "+
+		"validated", snapState.baseHash)
+	return nil
+}
+
+// markSnapshotInvalid records that the history of the chain does not produce
+// the loaded utxo snapshot and returns the passed error describing why.
+func (b *BlockChain) markSnapshotInvalid(snapState *utxoSnapshotState,
+	reason error) error {
+
+	log.Errorf("The utxo snapshot at block %v is invalid: %v",
+		snapState.baseHash, reason)
+	snapState.state = snapshotInvalid
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoSnapshotState(dbTx, snapState)
+	})
+	if err != nil {
+		return err
+	}
+	return reason
+}
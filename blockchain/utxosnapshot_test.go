@@ -0,0 +1,138 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestUtxoSnapshot ensures a utxo snapshot dumped from one chain can be loaded
+// into a fresh chain and that the history of the loaded chain validates.
+func TestUtxoSnapshot(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxosnapshotsrc",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	chain.TstSetCoinbaseMaturity(1)
+	for i := 1; i < len(blocks); i++ {
+		_, isOrphan, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			teardownFunc()
+			t.Fatalf("ProcessBlock #%d: unexpected error: %v", i, err)
+		}
+		if isOrphan {
+			teardownFunc()
+			t.Fatalf("ProcessBlock #%d: unexpected orphan", i)
+		}
+	}
+
+	// Dump the snapshot and tear the chain down since the test databases
+	// share a root directory that is removed on teardown.
+	var snapshot bytes.Buffer
+	meta, err := chain.DumpUtxoSnapshot(&snapshot)
+	srcStats := chain.UtxoSetStats()
+	teardownFunc()
+	if err != nil {
+		t.Fatalf("DumpUtxoSnapshot: unexpected error: %v", err)
+	}
+	if meta.BaseHeight != 4 || meta.BaseHash != *blocks[4].Hash() {
+		t.Fatalf("DumpUtxoSnapshot: unexpected base %v (%d)",
+			meta.BaseHash, meta.BaseHeight)
+	}
+
+	newChain, newTeardownFunc, err := chainSetup("utxosnapshotdst",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer newTeardownFunc()
+	newChain.TstSetCoinbaseMaturity(1)
+
+	// Loading the snapshot with the wrong expected hash must fail and
+	// leave the chain untouched.
+	var wrongHash chainhash.Hash
+	_, err = newChain.LoadUtxoSnapshot(bytes.NewReader(snapshot.Bytes()),
+		&wrongHash)
+	if err == nil {
+		t.Fatal("LoadUtxoSnapshot: loaded snapshot with wrong hash")
+	}
+	if height := newChain.BestSnapshot().Height; height != 0 {
+		t.Fatalf("chain height after failed load is %d, want 0", height)
+	}
+	err = newChain.db.View(func(dbTx database.Tx) error {
+		_, err := dbFetchHeightByHash(dbTx, &meta.BaseHash)
+		return err
+	})
+	if err == nil {
+		t.Fatal("base block is in the height index after failed load")
+	}
+
+	// A snapshot claiming a huge base height must fail once it runs out of
+	// headers rather than allocating nodes for all of them up front.
+	const heightOffset = 5 + 2 + 4 + chainhash.HashSize
+	truncated := append([]byte(nil),
+		snapshot.Bytes()[:heightOffset+4+8+8+80]...)
+	binary.LittleEndian.PutUint32(truncated[heightOffset:], 0x7fffffff)
+	_, err = newChain.LoadUtxoSnapshot(bytes.NewReader(truncated),
+		&meta.UtxoSetHash)
+	if err == nil {
+		t.Fatal("LoadUtxoSnapshot: loaded snapshot with huge base height")
+	}
+
+	loadedMeta, err := newChain.LoadUtxoSnapshot(
+		bytes.NewReader(snapshot.Bytes()), &meta.UtxoSetHash)
+	if err != nil {
+		t.Fatalf("LoadUtxoSnapshot: unexpected error: %v", err)
+	}
+	if *loadedMeta != *meta {
+		t.Fatalf("LoadUtxoSnapshot: got metadata %+v, want %+v",
+			loadedMeta, meta)
+	}
+	best := newChain.BestSnapshot()
+	if best.Hash != meta.BaseHash || best.TotalTxns != meta.TotalTxns {
+		t.Fatalf("unexpected best state after load: %+v", best)
+	}
+	if stats := newChain.UtxoSetStats(); *stats != *srcStats {
+		t.Fatalf("unexpected utxo set stats after load: got %+v, "+
+			"want %+v", stats, srcStats)
+	}
+
+	// Validate the history of the loaded chain.
+	blocksByHash := make(map[chainhash.Hash]*btcutil.Block)
+	for _, block := range blocks {
+		blocksByHash[*block.Hash()] = block
+	}
+	fetchBlock := func(hash *chainhash.Hash) (*btcutil.Block, error) {
+		block, ok := blocksByHash[*hash]
+		if !ok {
+			return nil, fmt.Errorf("block %v not found", hash)
+		}
+		return block, nil
+	}
+	if err := newChain.ValidateUtxoSnapshot(fetchBlock, nil); err != nil {
+		t.Fatalf("ValidateUtxoSnapshot: unexpected error: %v", err)
+	}
+	status, err := newChain.UtxoSnapshotStatus()
+	if err != nil {
+		t.Fatalf("UtxoSnapshotStatus: unexpected error: %v", err)
+	}
+	if status == nil || !status.Validated || status.ValidatedHeight != 4 {
+		t.Fatalf("UtxoSnapshotStatus: unexpected status %+v", status)
+	}
+}
@@ -196,7 +196,13 @@ func isBIP0030Node(node *blockNode) bool {
 //
 // At the target block generation rate for the main network, this is
 // approximately every 4 years.
+//
+// Networks which define their own emission schedule by setting the SubsidyFunc
+// of their parameters have the subsidy calculated by that function instead.
 func CalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
+	if chainParams.SubsidyFunc != nil {
+		return chainParams.SubsidyFunc(height)
+	}
 	if chainParams.SubsidyReductionInterval == 0 {
 		return baseSubsidy
 	}
@@ -205,6 +211,17 @@ func CalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
 	return baseSubsidy >> uint(height/chainParams.SubsidyReductionInterval)
 }
 
+// CalcCoinbaseMaturity returns the number of blocks required before the coins
+// of the coinbase transaction of a block at the provided height can be spent.
+// This is the CoinbaseMaturity of the network parameters unless the network
+// defines a CoinbaseMaturityFunc.
+func CalcCoinbaseMaturity(height int32, chainParams *chaincfg.Params) int32 {
+	if chainParams.CoinbaseMaturityFunc != nil {
+		return int32(chainParams.CoinbaseMaturityFunc(height))
+	}
+	return int32(chainParams.CoinbaseMaturity)
+}
+
 // CheckTransactionSanity performs some preliminary checks on a transaction to
 // ensure it is sane.  These checks are context free.
 func CheckTransactionSanity(tx *btcutil.Tx) error {
@@ -943,7 +960,8 @@ func CheckTransactionInputs(tx *btcutil.Tx, txHeight int32, utxoView *UtxoViewpo
 		if utxo.IsCoinBase() {
 			originHeight := utxo.BlockHeight()
 			blocksSincePrev := txHeight - originHeight
-			coinbaseMaturity := int32(chainParams.CoinbaseMaturity)
+			coinbaseMaturity := CalcCoinbaseMaturity(originHeight,
+				chainParams)
 			if blocksSincePrev < coinbaseMaturity {
 				str := fmt.Sprintf("tried to spend coinbase "+
 					"transaction output %v from height %v "+
@@ -1033,8 +1051,11 @@ func CheckTransactionInputs(tx *btcutil.Tx, txHeight int32, utxoView *UtxoViewpo
 // connects to the end of the current main chain and then calls this function
 // with that node.
 //
+// The flags modify the behavior of this function as follows:
+//   - BFAssumeValid: The transaction scripts are not verified.
+//
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut) error {
+func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut, flags BehaviorFlags) error {
 	// If the side chain blocks end up in the database, a call to
 	// CheckBlockSanity should be done here in case a previous version
 	// allowed a block that is no longer valid.  However, since the
@@ -1201,6 +1222,12 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 		runScripts = false
 	}
 
+	// Likewise, don't run scripts for ancestors of the block that is
+	// assumed to be valid.
+	if flags&BFAssumeValid == BFAssumeValid {
+		runScripts = false
+	}
+
 	// Blocks created after the BIP0016 activation time need to have the
 	// pay-to-script-hash checks enabled.
 	var scriptFlags txscript.ScriptFlags
@@ -1284,11 +1311,12 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 	// expensive ECDSA signature check scripts.  Doing this last helps
 	// prevent CPU exhaustion attacks.
 	if runScripts {
-		err := checkBlockScripts(block, view, scriptFlags, b.sigCache,
-			b.hashCache)
+		result, err := checkBlockScripts(block, view, scriptFlags,
+			b.sigCache, b.hashCache, b.scriptWorkers)
 		if err != nil {
 			return err
 		}
+		b.recordScriptValidation(node, result)
 	}
 
 	// Update the best hash for view to include this block since all of its
@@ -1335,7 +1363,7 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *btcutil.Block) error {
 	view := NewUtxoViewpoint()
 	view.SetBestHash(&tip.hash)
 	newNode := newBlockNode(&header, tip)
-	return b.checkConnectBlock(newNode, block, view, nil)
+	return b.checkConnectBlock(newNode, block, view, nil, BFNone)
 }
 
 // ChainParams returns the Blockchain's configured chaincfg.Params.
@@ -5,14 +5,17 @@
 package blockchain
 
 import (
+	"errors"
 	"math"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/blockchain/internal/testhelper"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
 
@@ -234,6 +237,106 @@ func TestCheckSerializedHeight(t *testing.T) {
 	}
 }
 
+// TestCustomSubsidyAndMaturity ensures networks which define their own
+// emission schedule and coinbase maturity have them applied when calculating
+// block subsidies and checking the spending of coinbase outputs.
+func TestCustomSubsidyAndMaturity(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+
+	// The schedule of the network parameters applies without the hooks.
+	const wantSubsidy = 25 * btcutil.SatoshiPerBitcoin
+	if got := CalcBlockSubsidy(200, &params); got != wantSubsidy {
+		t.Fatalf("CalcBlockSubsidy: got %d, want %d", got,
+			int64(wantSubsidy))
+	}
+	if got := CalcCoinbaseMaturity(200, &params); got != 100 {
+		t.Fatalf("CalcCoinbaseMaturity: got %d, want %d", got, 100)
+	}
+
+	// Use a flat subsidy with a tail emission and a maturity which is
+	// lowered after the first 1000 blocks.
+	params.SubsidyFunc = func(height int32) int64 {
+		if height < 1000 {
+			return 10 * 1e8
+		}
+		return 1e8
+	}
+	params.CoinbaseMaturityFunc = func(height int32) uint16 {
+		if height < 1000 {
+			return 100
+		}
+		return 10
+	}
+	subsidyTests := []struct {
+		height   int32
+		subsidy  int64
+		maturity int32
+	}{
+		{height: 0, subsidy: 10 * 1e8, maturity: 100},
+		{height: 999, subsidy: 10 * 1e8, maturity: 100},
+		{height: 1000, subsidy: 1e8, maturity: 10},
+		{height: 5000000, subsidy: 1e8, maturity: 10},
+	}
+	for _, test := range subsidyTests {
+		got := CalcBlockSubsidy(test.height, &params)
+		if got != test.subsidy {
+			t.Errorf("CalcBlockSubsidy(%d): got %d, want %d",
+				test.height, got, test.subsidy)
+		}
+		maturity := CalcCoinbaseMaturity(test.height, &params)
+		if maturity != test.maturity {
+			t.Errorf("CalcCoinbaseMaturity(%d): got %d, want %d",
+				test.height, maturity, test.maturity)
+		}
+	}
+
+	// Spend the output of a coinbase from before and after the maturity
+	// changes.
+	coinbaseTx := wire.NewMsgTx(1)
+	coinbaseTx.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32), nil, nil,
+	))
+	coinbaseTx.AddTxOut(wire.NewTxOut(1e8, []byte{0x51}))
+	coinbase := btcutil.NewTx(coinbaseTx)
+
+	spendTx := wire.NewMsgTx(1)
+	spendTx.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(coinbase.Hash(), 0), nil, nil,
+	))
+	spendTx.AddTxOut(wire.NewTxOut(1e8, []byte{0x51}))
+	spend := btcutil.NewTx(spendTx)
+
+	spendTests := []struct {
+		originHeight int32
+		spendHeight  int32
+		immature     bool
+	}{
+		{originHeight: 500, spendHeight: 599, immature: true},
+		{originHeight: 500, spendHeight: 600, immature: false},
+		{originHeight: 1000, spendHeight: 1009, immature: true},
+		{originHeight: 1000, spendHeight: 1010, immature: false},
+	}
+	for _, test := range spendTests {
+		view := NewUtxoViewpoint()
+		view.AddTxOuts(coinbase, test.originHeight)
+		_, err := CheckTransactionInputs(spend, test.spendHeight, view,
+			&params)
+		immature := false
+		if rerr, ok := err.(RuleError); ok {
+			immature = rerr.ErrorCode == ErrImmatureSpend
+		} else if err != nil {
+			t.Fatalf("CheckTransactionInputs: unexpected error: %v",
+				err)
+		}
+		if immature != test.immature {
+			t.Errorf("CheckTransactionInputs: spend at %d of "+
+				"coinbase from %d: got immature %v, want %v",
+				test.spendHeight, test.originHeight, immature,
+				test.immature)
+		}
+	}
+}
+
 // Block100000 defines block 100,000 of the block chain.  It is used to
 // test Block operations.
 var Block100000 = wire.MsgBlock{
@@ -485,3 +588,51 @@ var Block100000 = wire.MsgBlock{
 		},
 	},
 }
+
+// TestAssumeValid ensures the scripts of blocks processed with BFAssumeValid
+// are not verified while they are verified otherwise.
+func TestAssumeValid(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestAssumeValid")
+	defer tearDown()
+
+	b1, b1Outs, err := addBlock(chain, btcutil.NewBlock(params.GenesisBlock), nil)
+	if err != nil {
+		t.Fatalf("addBlock: %v", err)
+	}
+
+	// badBlock returns a block spending the coinbase of the first block
+	// with a signature script which fails to execute.  The timestamp is
+	// offset by the passed number of seconds to get distinct blocks.
+	badBlock := func(offset time.Duration) *btcutil.Block {
+		t.Helper()
+		block, _, err := newBlock(chain, b1, b1Outs[:1])
+		if err != nil {
+			t.Fatalf("newBlock: %v", err)
+		}
+		msgBlock := block.MsgBlock()
+		msgBlock.Transactions[1].TxIn[0].SignatureScript =
+			[]byte{txscript.OP_RETURN}
+		header := &msgBlock.Header
+		header.MerkleRoot = calcMerkleRoot(msgBlock.Transactions)
+		header.Timestamp = header.Timestamp.Add(offset * time.Second)
+		if !testhelper.SolveBlock(header) {
+			t.Fatal("unable to solve block")
+		}
+		return btcutil.NewBlock(msgBlock)
+	}
+
+	_, _, err = chain.ProcessBlock(badBlock(0), BFNone)
+	var ruleErr RuleError
+	if !errors.As(err, &ruleErr) || ruleErr.ErrorCode != ErrScriptValidation {
+		t.Fatalf("ProcessBlock: got error %v, want %v", err,
+			ErrScriptValidation)
+	}
+
+	block := badBlock(1)
+	if _, _, err := chain.ProcessBlock(block, BFAssumeValid); err != nil {
+		t.Fatalf("ProcessBlock: unexpected error: %v", err)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *block.Hash() {
+		t.Fatalf("got tip %v, want %v", tip, block.Hash())
+	}
+}
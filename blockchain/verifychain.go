@@ -0,0 +1,331 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MaxVerifyLevel is the most thorough level of VerifyChain.
+	MaxVerifyLevel = 4
+
+	// MaxUnboundedVerifyLevel is the most thorough level at which
+	// VerifyChain verifies all blocks when passed a depth of 0.  The higher
+	// levels would hold the chain lock while disconnecting the whole chain.
+	MaxUnboundedVerifyLevel = 2
+
+	// defaultVerifyMemoryLimit is the estimated memory in bytes VerifyChain
+	// uses at most for disconnecting and connecting blocks again when no
+	// utxo cache size is configured.
+	defaultVerifyMemoryLimit = 100 * 1024 * 1024
+)
+
+// VerifyChain verifies the consistency of the most recent main chain blocks
+// with the passed depth, where a depth of 0 verifies all blocks up to
+// MaxUnboundedVerifyLevel.  The level controls how thorough the verification
+// is, where each level includes the checks of the lower ones:
+//
+//   - 0: Every block is in the block index and links to its parent
+//   - 1: The block data is stored in the database and the stored header
+//     matches the block index
+//   - 2: The blocks are read again, which verifies their checksums, and pass
+//     the context-free sanity checks
+//   - 3: The blocks are disconnected from the utxo set in memory using their
+//     undo data, which ensures the undo data is consistent with the utxo set
+//   - 4: The disconnected blocks are connected again in memory, which performs
+//     all consensus checks of the blocks against the utxo set
+//
+// The verification stops at the first block whose data isn't available, such
+// as the blocks of a pruned database or below a loaded utxo snapshot.
+// Likewise, blocks whose undo data was pruned are not disconnected.  Blocks are
+// also no longer disconnected once the view of the utxo set, along with the
+// blocks kept at level 4 to connect them again, is estimated to exceed the size
+// of the utxo cache, while the lower levels are still checked for them.
+// Nothing is written to the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) VerifyChain(level, depth int32, interrupt <-chan struct{}) error {
+	if level < 0 || level > MaxVerifyLevel {
+		return fmt.Errorf("check level %d is not between 0 and %d",
+			level, MaxVerifyLevel)
+	}
+	if depth < 0 {
+		return fmt.Errorf("check depth %d is negative", depth)
+	}
+	if depth == 0 && level > MaxUnboundedVerifyLevel {
+		return fmt.Errorf("check level %d requires a check depth, all "+
+			"blocks are only verified up to level %d", level,
+			MaxUnboundedVerifyLevel)
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	tip := b.bestChain.Tip()
+	if depth == 0 || depth > tip.height {
+		depth = tip.height
+	}
+	log.Infof("Verifying the most recent %d blocks at level %d", depth,
+		level)
+
+	var beenPruned bool
+	if level > 0 {
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			beenPruned, err = dbTx.BeenPruned()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Verify the blocks from the tip back, disconnecting them from a view
+	// of the utxo set at level 3 and above.  The disconnected blocks are
+	// kept at level 4 so they can be connected again.
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&tip.hash)
+	var disconnected []*btcutil.Block
+	var disconnectedSize uint64
+	canDisconnect := level >= 3
+	var verified int32
+	for node := tip; node != nil && verified < depth; node = node.parent {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		// Level 0 ensures the block is part of the block index and links
+		// to its parent.
+		if b.index.LookupNode(&node.hash) != node {
+			return AssertError(fmt.Sprintf("main chain block %v at "+
+				"height %d is not in the block index", node.hash,
+				node.height))
+		}
+		if node.parent == nil || node.parent.height != node.height-1 ||
+			node.parent.hash != node.Header().PrevBlock {
+
+			return AssertError(fmt.Sprintf("main chain block %v at "+
+				"height %d does not link to its parent",
+				node.hash, node.height))
+		}
+		if !b.index.NodeStatus(node).HaveData() {
+			log.Infof("Verification stopped at block %v at height %d "+
+				"whose data is not available", node.hash,
+				node.height)
+			break
+		}
+		if level == 0 {
+			verified++
+			continue
+		}
+
+		// Level 1 ensures the block is stored and its stored header
+		// matches the block index.
+		var haveBlock bool
+		var headerBytes []byte
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			haveBlock, err = dbTx.HasBlock(&node.hash)
+			if err != nil || !haveBlock {
+				return err
+			}
+			headerBytes, err = dbTx.FetchBlockHeader(&node.hash)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if !haveBlock {
+			if beenPruned {
+				log.Infof("Verification stopped at block %v at "+
+					"height %d which was pruned", node.hash,
+					node.height)
+				break
+			}
+			return fmt.Errorf("block %v at height %d is missing from "+
+				"the database", node.hash, node.height)
+		}
+		var header wire.BlockHeader
+		err = header.Deserialize(bytes.NewReader(headerBytes))
+		if err != nil {
+			return err
+		}
+		if header.BlockHash() != node.hash {
+			return fmt.Errorf("stored header of block %v at height "+
+				"%d does not match the block index", node.hash,
+				node.height)
+		}
+		if level == 1 {
+			verified++
+			continue
+		}
+
+		// Level 2 reads the block again and performs the context-free
+		// sanity checks.
+		var block *btcutil.Block
+		err = b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		err = CheckBlockSanity(block, b.chainParams.PowLimit,
+			b.timeSource)
+		if err != nil {
+			return fmt.Errorf("block %v at height %d failed the "+
+				"sanity checks: %v", node.hash, node.height, err)
+		}
+
+		// Level 3 disconnects the block from the view using its undo
+		// data, which is only possible while the blocks after it were
+		// disconnected as well.
+		if canDisconnect && b.checkUndoDataAvailable(node) != nil {
+			log.Infof("Not disconnecting block %v at height %d and "+
+				"below since their undo data was pruned",
+				node.hash, node.height)
+			canDisconnect = false
+		}
+		if canDisconnect {
+			err := b.verifyDisconnectBlock(node, block, view)
+			if err != nil {
+				return err
+			}
+			if level >= 4 {
+				disconnected = append(disconnected, block)
+				disconnectedSize += uint64(
+					block.MsgBlock().SerializeSize())
+			}
+
+			memUsage := estimateViewMemory(view) + disconnectedSize
+			if memUsage > b.verifyMemoryLimit {
+				log.Infof("Not disconnecting the blocks below "+
+					"block %v at height %d since the "+
+					"verification uses %d MiB of memory",
+					node.hash, node.height,
+					memUsage/(1024*1024))
+				canDisconnect = false
+			}
+		}
+		verified++
+	}
+
+	// Level 4 connects the disconnected blocks again, from the oldest one
+	// to the tip, which performs all of the consensus checks.
+	if level >= 4 {
+		for i := len(disconnected) - 1; i >= 0; i-- {
+			if interruptRequested(interrupt) {
+				return errInterruptRequested
+			}
+
+			block := disconnected[i]
+			node := b.index.LookupNode(block.Hash())
+			err := b.checkConnectBlock(node, block, view, nil, BFNone)
+			if err != nil {
+				return fmt.Errorf("block %v at height %d failed "+
+					"to connect: %v", node.hash, node.height,
+					err)
+			}
+		}
+	}
+
+	log.Infof("Verified the most recent %d blocks", verified)
+	return nil
+}
+
+// estimateViewMemory returns the estimated memory usage in bytes of the passed
+// view, assuming its entries are of the average size the utxo cache assumes.
+func estimateViewMemory(view *UtxoViewpoint) uint64 {
+	numEntries := len(view.entries)
+	return uint64(calculateRoughMapSize(numEntries, bucketSize)) +
+		uint64(numEntries)*avgEntrySize
+}
+
+// verifyDisconnectBlock disconnects the passed main chain block from the view,
+// which must be at the block, after ensuring the outputs created by the block
+// are unspent in the view and the undo data of the block is consistent with it.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) verifyDisconnectBlock(node *blockNode, block *btcutil.Block,
+	view *UtxoViewpoint) error {
+
+	// The outputs created by the block must be unspent unless they are
+	// spent by a later transaction of the same block.
+	spentInBlock := make(map[wire.OutPoint]struct{})
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			spentInBlock[txIn.PreviousOutPoint] = struct{}{}
+		}
+	}
+	var outpoints []wire.OutPoint
+	for _, tx := range block.Transactions() {
+		for i, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			outpoint := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			if _, ok := spentInBlock[outpoint]; !ok {
+				outpoints = append(outpoints, outpoint)
+			}
+		}
+	}
+	if err := view.fetchUtxos(b.utxoCache, outpoints); err != nil {
+		return err
+	}
+	for i := range outpoints {
+		entry := view.LookupEntry(outpoints[i])
+		if entry == nil || entry.IsSpent() {
+			return fmt.Errorf("output %v created by block %v at "+
+				"height %d is not in the utxo set", outpoints[i],
+				node.hash, node.height)
+		}
+	}
+
+	// Load the outputs spent by the block so they can be restored and
+	// disconnect the block using its undo data.
+	if err := view.fetchInputUtxos(b.utxoCache, block); err != nil {
+		return err
+	}
+	var stxos []SpentTxOut
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stxos, err = dbFetchSpendJournalEntry(dbTx, block)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(stxos) != countSpentOutputs(block) {
+		return fmt.Errorf("undo data of block %v at height %d has %d "+
+			"spent outputs instead of %d", node.hash, node.height,
+			len(stxos), countSpentOutputs(block))
+	}
+	if err := view.disconnectTransactions(b.db, block, stxos); err != nil {
+		return err
+	}
+
+	// The coinbase outputs of the two blocks which overwrote earlier
+	// coinbase transactions, which is allowed by the exception to BIP0030,
+	// are still in the utxo set as the outputs of the overwritten ones, so
+	// they are reloaded from it when those blocks are disconnected.
+	if isBIP0030Node(node) {
+		coinbase := block.Transactions()[0]
+		for i := range coinbase.MsgTx().TxOut {
+			view.RemoveEntry(wire.OutPoint{
+				Hash:  *coinbase.Hash(),
+				Index: uint32(i),
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain/internal/testhelper"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestVerifyChain ensures VerifyChain accepts a consistent chain at all levels,
+// detects undo data which is inconsistent with the blocks and stops
+// disconnecting blocks once its memory limit is reached.
+func TestVerifyChain(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestVerifyChain")
+	defer tearDown()
+
+	// Create a chain where each block spends the outputs of the previous
+	// one so the blocks have undo data.
+	prev := btcutil.NewBlock(params.GenesisBlock)
+	var parent *btcutil.Block
+	var spends []*testhelper.SpendableOut
+	for i := 0; i < 5; i++ {
+		block, outs, err := addBlock(chain, prev, spends)
+		if err != nil {
+			t.Fatalf("addBlock #%d: %v", i, err)
+		}
+		parent, prev, spends = prev, block, outs
+	}
+
+	for level := int32(0); level <= MaxVerifyLevel; level++ {
+		for _, depth := range []int32{0, 1, 3, 10} {
+			err := chain.VerifyChain(level, depth, nil)
+			if depth == 0 && level > MaxUnboundedVerifyLevel {
+				if err == nil {
+					t.Fatalf("VerifyChain(%d, 0): expected "+
+						"error for unbounded depth", level)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("VerifyChain(%d, %d): unexpected error: %v",
+					level, depth, err)
+			}
+		}
+	}
+	if err := chain.VerifyChain(MaxVerifyLevel+1, 1, nil); err == nil {
+		t.Fatal("VerifyChain: expected error for invalid level")
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *prev.Hash() {
+		t.Fatalf("got tip %v, want %v", tip, prev.Hash())
+	}
+
+	// Remove the undo data of the parent of the tip, which is not detected
+	// once the memory limit stops the verification from disconnecting the
+	// blocks below the tip.
+	err := chain.db.Update(func(dbTx database.Tx) error {
+		return dbRemoveSpendJournalEntry(dbTx, parent.Hash())
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := chain.VerifyChain(3, 3, nil); err == nil {
+		t.Fatal("VerifyChain(3, 3): expected error for missing undo data")
+	}
+	chain.verifyMemoryLimit = 1
+	for level := int32(3); level <= MaxVerifyLevel; level++ {
+		if err := chain.VerifyChain(level, 3, nil); err != nil {
+			t.Fatalf("VerifyChain(%d, 3): unexpected error with "+
+				"memory limit: %v", level, err)
+		}
+	}
+	chain.verifyMemoryLimit = defaultVerifyMemoryLimit
+
+	// Remove the undo data of the tip, which is only detected from level 3
+	// on.
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		return dbRemoveSpendJournalEntry(dbTx, prev.Hash())
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := chain.VerifyChain(2, 1, nil); err != nil {
+		t.Fatalf("VerifyChain(2, 1): unexpected error: %v", err)
+	}
+	if err := chain.VerifyChain(3, 1, nil); err == nil {
+		t.Fatal("VerifyChain(3, 1): expected error for missing undo data")
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package blocktrace records the lifecycle of blocks that are relayed at the tip
+of the chain and exports it as OpenTelemetry traces.
+
+Each block is traced from the first time a peer announces it, through it being
+requested, received, validated and connected, until it is announced to the
+peers of the node.  Every stage is attributed to the peer that caused it where
+applicable.  The trace of a block consists of a root span covering the whole
+lifecycle with an event for every stage, along with a child span for the time
+spent between each pair of consecutive stages, such as the download or the
+validation of the block.
+
+The trace ID is derived from the block hash, so the traces exported by several
+nodes to the same collector are merged into a single trace per block, which
+shows how the block propagated through the network.
+
+Traces are exported in batches by an Exporter.  OTLPExporter sends them to an
+OpenTelemetry collector using the OTLP/HTTP protocol with JSON encoding.
+*/
+package blocktrace
@@ -0,0 +1,233 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blocktrace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// otlpTracesPath is the path the traces are posted to when the
+	// configured endpoint doesn't include one.
+	otlpTracesPath = "/v1/traces"
+
+	// otlpTimeout is the maximum duration of a single export request.
+	otlpTimeout = 10 * time.Second
+
+	// otlpScopeName is the name of the instrumentation scope of the spans.
+	otlpScopeName = "github.com/btcsuite/btcd/blocktrace"
+
+	// otlpSpanKindInternal and otlpStatusError are the OTLP span kind and
+	// status code values used for the spans.
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+// The following types mirror the JSON encoding of the OTLP trace export
+// request.  See opentelemetry-proto for the full definition.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpAnyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+// OTLPExporter exports spans to an OpenTelemetry collector using the OTLP/HTTP
+// protocol with JSON encoding.
+type OTLPExporter struct {
+	url      string
+	client   *http.Client
+	resource []otlpKeyValue
+}
+
+// Ensure OTLPExporter implements the Exporter interface.
+var _ Exporter = (*OTLPExporter)(nil)
+
+// NewOTLPExporter returns an exporter which posts the spans to the passed
+// OTLP/HTTP endpoint, such as http://localhost:4318.  The standard traces path
+// is used when the endpoint does not include a path.  The spans are reported
+// as coming from a service with the passed name.
+func NewOTLPExporter(endpoint, serviceName string) (*OTLPExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported OTLP endpoint scheme %q",
+			u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+
+	return &OTLPExporter{
+		url:    u.String(),
+		client: &http.Client{Timeout: otlpTimeout},
+		resource: otlpAttributes([]Attribute{
+			{Key: "service.name", Value: serviceName},
+		}),
+	}, nil
+}
+
+// ExportSpans posts the passed spans to the collector.
+//
+// This is part of the Exporter interface.
+func (e *OTLPExporter) ExportSpans(spans []*Span) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, newOTLPSpan(span))
+	}
+	req := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: e.resource},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: otlpScopeName},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector responded with %s: %s",
+			resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// newOTLPSpan converts the passed span to its OTLP representation.
+func newOTLPSpan(span *Span) otlpSpan {
+	s := otlpSpan{
+		TraceID:           hex.EncodeToString(span.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.SpanID[:]),
+		Name:              span.Name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(span.Start),
+		EndTimeUnixNano:   otlpTime(span.End),
+		Attributes:        otlpAttributes(span.Attributes),
+	}
+	if span.ParentSpanID != [8]byte{} {
+		s.ParentSpanID = hex.EncodeToString(span.ParentSpanID[:])
+	}
+	for _, event := range span.Events {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano: otlpTime(event.Time),
+			Name:         event.Name,
+			Attributes:   otlpAttributes(event.Attributes),
+		})
+	}
+	if span.Error != "" {
+		s.Status = &otlpStatus{
+			Code:    otlpStatusError,
+			Message: span.Error,
+		}
+	}
+	return s
+}
+
+// otlpTime returns the OTLP representation of the passed time.
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpAttributes converts the passed attributes to their OTLP representation.
+// Attributes with unsupported value types are encoded as strings.
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpAnyValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		kvs = append(kvs, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return kvs
+}
@@ -0,0 +1,477 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blocktrace
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// DefaultFlushInterval is the default interval at which completed
+	// traces are exported.
+	DefaultFlushInterval = 5 * time.Second
+
+	// DefaultTimeout is the default duration after the most recent stage
+	// of a block after which its trace is exported even though the block
+	// was not announced to the peers.
+	DefaultTimeout = 2 * time.Minute
+
+	// maxActiveTraces is the maximum number of blocks that are traced at
+	// the same time.  Announcements of further blocks are ignored until
+	// some of the traces complete so peers can't exhaust memory by
+	// announcing bogus blocks.
+	maxActiveTraces = 100
+
+	// maxPendingSpans is the maximum number of spans that are buffered
+	// while waiting to be exported.  The oldest spans are dropped when the
+	// exporter can't keep up.
+	maxPendingSpans = 10000
+)
+
+// Stage identifies a step in the lifecycle of a block.
+type Stage int
+
+// These constants define the stages of the lifecycle of a block in the order
+// they happen.
+const (
+	// StageHeaderReceived is when a peer first announced the block.
+	StageHeaderReceived Stage = iota
+
+	// StageRequested is when the block was requested from a peer.
+	StageRequested
+
+	// StageReceived is when the block was received from a peer.
+	StageReceived
+
+	// StageValidated is when the block passed full validation.
+	StageValidated
+
+	// StageConnected is when the block was connected to the main chain.
+	StageConnected
+
+	// StageAnnounced is when the block was announced to the peers.
+	StageAnnounced
+
+	// numStages is the number of stages.  It must be the last entry.
+	numStages
+)
+
+// stageNames maps the stages to the names of the span events recorded for
+// them.
+var stageNames = [numStages]string{
+	StageHeaderReceived: "header_received",
+	StageRequested:      "requested",
+	StageReceived:       "received",
+	StageValidated:      "validated",
+	StageConnected:      "connected",
+	StageAnnounced:      "announced",
+}
+
+// phaseNames maps the stages to the names of the child spans covering the time
+// since the previous stage.
+var phaseNames = [numStages]string{
+	StageRequested: "block.request",
+	StageReceived:  "block.download",
+	StageValidated: "block.validate",
+	StageConnected: "block.connect",
+	StageAnnounced: "block.announce",
+}
+
+// String returns the stage in human-readable form.
+func (s Stage) String() string {
+	if s >= 0 && s < numStages {
+		return stageNames[s]
+	}
+	return fmt.Sprintf("Unknown Stage (%d)", int(s))
+}
+
+// Attribute is a key and value pair describing a span or an event.  The value
+// is a string, int64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// SpanEvent is an event that happened at a specific time during a span.
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// Span is a timed operation that is part of the trace of a block.
+type Span struct {
+	// TraceID identifies the trace of the block.  It is the same for all
+	// spans of the block, including the spans exported by other nodes.
+	TraceID [16]byte
+
+	// SpanID identifies the span.  ParentSpanID is the ID of the root span
+	// of the trace for child spans and all zero for the root span itself.
+	SpanID       [8]byte
+	ParentSpanID [8]byte
+
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Events     []SpanEvent
+
+	// Error describes why the block was rejected.  It is empty when the
+	// operation succeeded.
+	Error string
+}
+
+// Exporter is the interface that wraps exporting spans to a tracing backend.
+type Exporter interface {
+	// ExportSpans exports the passed batch of spans.
+	ExportSpans(spans []*Span) error
+}
+
+// Config is a descriptor containing the tracer configuration.
+type Config struct {
+	// Exporter is used to export the traces of the blocks.
+	Exporter Exporter
+
+	// FlushInterval is the interval at which completed traces are
+	// exported.  DefaultFlushInterval is used when it is zero.
+	FlushInterval time.Duration
+
+	// Timeout is the duration after the most recent stage of a block after
+	// which its trace is exported even though the block was not announced
+	// to the peers, such as for side chain blocks.  DefaultTimeout is used
+	// when it is zero.
+	Timeout time.Duration
+}
+
+// stageEvent houses the time a stage of a block happened and the peer that
+// caused it.
+type stageEvent struct {
+	time time.Time
+	peer string
+}
+
+// blockTrace houses the stages recorded for a block that is being traced.
+type blockTrace struct {
+	hash       chainhash.Hash
+	height     int32
+	stages     [numStages]*stageEvent
+	relayPeers int
+	lastUpdate time.Time
+}
+
+// Tracer records the lifecycle of the blocks that are relayed at the tip of
+// the chain and periodically exports the traces with the configured exporter.
+//
+// All of the record methods are safe for concurrent access and are no-ops
+// when called on a nil tracer so callers don't have to check whether tracing
+// is enabled.
+type Tracer struct {
+	cfg Config
+
+	mtx     sync.Mutex
+	active  map[chainhash.Hash]*blockTrace
+	pending []*Span
+	dropped int
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New returns a new tracer with the passed configuration.  Start must be
+// called before any traces are exported.
+func New(cfg *Config) *Tracer {
+	t := &Tracer{
+		cfg:    *cfg,
+		active: make(map[chainhash.Hash]*blockTrace),
+		quit:   make(chan struct{}),
+	}
+	if t.cfg.FlushInterval <= 0 {
+		t.cfg.FlushInterval = DefaultFlushInterval
+	}
+	if t.cfg.Timeout <= 0 {
+		t.cfg.Timeout = DefaultTimeout
+	}
+	return t
+}
+
+// Record records that the passed stage of the block with the passed hash
+// happened now, caused by the passed peer, which may be empty.  A new trace is
+// only started for StageHeaderReceived, the other stages are ignored for
+// blocks that are not being traced.  Only the first occurrence of each stage
+// is recorded.
+func (t *Tracer) Record(hash *chainhash.Hash, stage Stage, peer string) {
+	if t == nil {
+		return
+	}
+	t.record(hash, stage, peer, time.Now())
+}
+
+// RecordConnected records that the block with the passed hash was connected
+// to the main chain at the passed height.
+func (t *Tracer) RecordConnected(hash *chainhash.Hash, height int32) {
+	if t == nil {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if bt := t.recordLocked(hash, StageConnected, "", time.Now()); bt != nil {
+		bt.height = height
+	}
+}
+
+// RecordAnnounced records that the block with the passed hash was announced
+// to the passed number of peers, which completes its trace.
+func (t *Tracer) RecordAnnounced(hash *chainhash.Hash, numPeers int) {
+	if t == nil {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	bt := t.recordLocked(hash, StageAnnounced, "", time.Now())
+	if bt == nil {
+		return
+	}
+	bt.relayPeers = numPeers
+	t.finishLocked(bt, "")
+}
+
+// RecordRejected records that the block with the passed hash was rejected for
+// the passed reason, which completes its trace.
+func (t *Tracer) RecordRejected(hash *chainhash.Hash, reason string) {
+	if t == nil {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if bt, ok := t.active[*hash]; ok {
+		bt.lastUpdate = time.Now()
+		t.finishLocked(bt, reason)
+	}
+}
+
+// record records the passed stage of a block at the passed time.
+func (t *Tracer) record(hash *chainhash.Hash, stage Stage, peer string,
+	now time.Time) {
+
+	t.mtx.Lock()
+	t.recordLocked(hash, stage, peer, now)
+	t.mtx.Unlock()
+}
+
+// recordLocked records the passed stage of a block at the passed time and
+// returns its trace, or nil when the block is not being traced.
+//
+// This function MUST be called with the tracer lock held.
+func (t *Tracer) recordLocked(hash *chainhash.Hash, stage Stage, peer string,
+	now time.Time) *blockTrace {
+
+	if stage < 0 || stage >= numStages {
+		return nil
+	}
+
+	bt, ok := t.active[*hash]
+	if !ok {
+		if stage != StageHeaderReceived || len(t.active) >= maxActiveTraces {
+			return nil
+		}
+		bt = &blockTrace{hash: *hash, height: -1}
+		t.active[*hash] = bt
+	}
+	if bt.stages[stage] == nil {
+		bt.stages[stage] = &stageEvent{time: now, peer: peer}
+	}
+	bt.lastUpdate = now
+	return bt
+}
+
+// finishLocked stops tracing the passed block and queues its spans to be
+// exported.  A non-empty reason marks the block as rejected.
+//
+// This function MUST be called with the tracer lock held.
+func (t *Tracer) finishLocked(bt *blockTrace, reason string) {
+	delete(t.active, bt.hash)
+
+	t.pending = append(t.pending, bt.spans(reason)...)
+	if excess := len(t.pending) - maxPendingSpans; excess > 0 {
+		t.pending = t.pending[excess:]
+		t.dropped += excess
+	}
+}
+
+// expireLocked finishes the traces of all blocks that had no stage recorded
+// within the timeout.
+//
+// This function MUST be called with the tracer lock held.
+func (t *Tracer) expireLocked(now time.Time) {
+	for _, bt := range t.active {
+		if now.Sub(bt.lastUpdate) >= t.cfg.Timeout {
+			t.finishLocked(bt, "")
+		}
+	}
+}
+
+// spans returns the spans of the trace of the block.  A non-empty reason marks
+// the block as rejected.
+func (bt *blockTrace) spans(reason string) []*Span {
+	var traceID [16]byte
+	copy(traceID[:], bt.hash[:])
+
+	root := &Span{
+		TraceID: traceID,
+		SpanID:  newSpanID(),
+		Name:    "block.relay",
+		Error:   reason,
+	}
+	spans := []*Span{root}
+
+	var prev *stageEvent
+	for stage, event := range bt.stages {
+		if event == nil {
+			continue
+		}
+
+		var attrs []Attribute
+		if event.peer != "" {
+			attrs = []Attribute{{Key: "peer", Value: event.peer}}
+		}
+		root.Events = append(root.Events, SpanEvent{
+			Name:       stageNames[stage],
+			Time:       event.time,
+			Attributes: attrs,
+		})
+
+		if prev == nil {
+			root.Start = event.time
+		} else {
+			spans = append(spans, &Span{
+				TraceID:      traceID,
+				SpanID:       newSpanID(),
+				ParentSpanID: root.SpanID,
+				Name:         phaseNames[stage],
+				Start:        prev.time,
+				End:          event.time,
+				Attributes:   attrs,
+			})
+		}
+		root.End = event.time
+		prev = event
+	}
+
+	root.Attributes = []Attribute{
+		{Key: "block.hash", Value: bt.hash.String()},
+		{Key: "block.complete", Value: bt.stages[StageAnnounced] != nil},
+	}
+	if bt.height >= 0 {
+		root.Attributes = append(root.Attributes, Attribute{
+			Key: "block.height", Value: int64(bt.height),
+		})
+	}
+	if event := bt.stages[StageHeaderReceived]; event != nil {
+		root.Attributes = append(root.Attributes, Attribute{
+			Key: "block.announced_by", Value: event.peer,
+		})
+	}
+	if event := bt.stages[StageReceived]; event != nil {
+		root.Attributes = append(root.Attributes, Attribute{
+			Key: "block.received_from", Value: event.peer,
+		})
+	}
+	if bt.stages[StageAnnounced] != nil {
+		root.Attributes = append(root.Attributes, Attribute{
+			Key: "block.relay_peers", Value: int64(bt.relayPeers),
+		})
+	}
+	return spans
+}
+
+// newSpanID returns a random span ID.
+func newSpanID() [8]byte {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// Fall back to the time, which is unique enough for span IDs,
+		// in the unlikely event the random source fails.
+		now := uint64(time.Now().UnixNano())
+		for i := range id {
+			id[i] = byte(now >> (8 * i))
+		}
+	}
+	return id
+}
+
+// flush exports all completed traces along with the traces that timed out.
+func (t *Tracer) flush(now time.Time) {
+	t.mtx.Lock()
+	t.expireLocked(now)
+	spans := t.pending
+	dropped := t.dropped
+	t.pending = nil
+	t.dropped = 0
+	t.mtx.Unlock()
+
+	if dropped > 0 {
+		log.Warnf("Dropped %d block trace spans since the exporter "+
+			"could not keep up", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.cfg.Exporter.ExportSpans(spans); err != nil {
+		log.Warnf("Unable to export %d block trace spans: %v",
+			len(spans), err)
+		return
+	}
+	log.Debugf("Exported %d block trace spans", len(spans))
+}
+
+// exportHandler periodically exports the completed traces.  It must be run as
+// a goroutine.
+func (t *Tracer) exportHandler() {
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			t.flush(time.Now())
+
+		case <-t.quit:
+			break out
+		}
+	}
+
+	// Export the completed traces that were not exported yet.
+	t.flush(time.Now())
+	t.wg.Done()
+}
+
+// Start begins periodically exporting the completed traces.
+func (t *Tracer) Start() {
+	if t == nil {
+		return
+	}
+
+	t.wg.Add(1)
+	go t.exportHandler()
+}
+
+// Stop stops exporting traces after exporting the completed traces that were
+// not exported yet.
+func (t *Tracer) Stop() {
+	if t == nil {
+		return
+	}
+
+	close(t.quit)
+	t.wg.Wait()
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blocktrace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// mockExporter is an exporter which keeps the exported spans in memory.
+type mockExporter struct {
+	spans []*Span
+}
+
+// ExportSpans appends the passed spans to the exported spans.
+func (e *mockExporter) ExportSpans(spans []*Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// TestTracer ensures the stages of the traced blocks are turned into the
+// expected spans.
+func TestTracer(t *testing.T) {
+	exporter := &mockExporter{}
+	tracer := New(&Config{Exporter: exporter, Timeout: time.Minute})
+
+	complete := chainhash.Hash{0x01}
+	rejected := chainhash.Hash{0x02}
+	untraced := chainhash.Hash{0x03}
+	stalled := chainhash.Hash{0x04}
+
+	base := time.Unix(1700000000, 0)
+	at := func(secs int) time.Time {
+		return base.Add(time.Duration(secs) * time.Second)
+	}
+
+	// Trace the full lifecycle of a block.  The repeated announcement must
+	// not change the announcing peer.
+	tracer.record(&complete, StageHeaderReceived, "peer1", at(0))
+	tracer.record(&complete, StageHeaderReceived, "peer2", at(1))
+	tracer.record(&complete, StageRequested, "peer1", at(1))
+	tracer.record(&complete, StageReceived, "peer1", at(3))
+	tracer.record(&complete, StageValidated, "", at(4))
+	tracer.RecordConnected(&complete, 100)
+	tracer.RecordAnnounced(&complete, 8)
+
+	// Reject a block after it was received.
+	tracer.record(&rejected, StageHeaderReceived, "peer3", at(0))
+	tracer.record(&rejected, StageReceived, "peer3", at(1))
+	tracer.RecordRejected(&rejected, "bad block")
+
+	// Stages other than the announcement must not start a trace.
+	tracer.record(&untraced, StageReceived, "peer1", at(0))
+	tracer.RecordConnected(&untraced, 101)
+	tracer.RecordAnnounced(&untraced, 8)
+
+	// A block without any stage for longer than the timeout must be
+	// exported as incomplete.
+	tracer.record(&stalled, StageHeaderReceived, "peer4", at(0))
+	tracer.record(&stalled, StageRequested, "peer4", at(1))
+
+	tracer.flush(at(30))
+	if len(tracer.active) != 1 {
+		t.Fatalf("got %d active traces, want 1", len(tracer.active))
+	}
+	tracer.flush(at(120))
+	if len(tracer.active) != 0 {
+		t.Fatalf("got %d active traces, want 0", len(tracer.active))
+	}
+
+	// Group the exported spans by trace.
+	traces := make(map[chainhash.Hash][]*Span)
+	for _, span := range exporter.spans {
+		var hash chainhash.Hash
+		copy(hash[:], span.TraceID[:])
+		traces[hash] = append(traces[hash], span)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("got %d exported traces, want 3", len(traces))
+	}
+	if _, ok := traces[untraced]; ok {
+		t.Fatal("untraced block was exported")
+	}
+
+	tests := []struct {
+		name     string
+		hash     chainhash.Hash
+		phases   []string
+		events   int
+		err      string
+		complete bool
+	}{{
+		name: "complete",
+		hash: complete,
+		phases: []string{"block.request", "block.download",
+			"block.validate", "block.connect", "block.announce"},
+		events:   6,
+		complete: true,
+	}, {
+		name:   "rejected",
+		hash:   rejected,
+		phases: []string{"block.download"},
+		events: 2,
+		err:    "bad block",
+	}, {
+		name:   "stalled",
+		hash:   stalled,
+		phases: []string{"block.request"},
+		events: 2,
+	}}
+	for _, test := range tests {
+		spans := traces[test.hash]
+		root := spans[0]
+		if root.Name != "block.relay" || root.ParentSpanID != [8]byte{} {
+			t.Errorf("%s: unexpected root span %q", test.name,
+				root.Name)
+			continue
+		}
+		if len(root.Events) != test.events {
+			t.Errorf("%s: got %d events, want %d", test.name,
+				len(root.Events), test.events)
+		}
+		if root.Error != test.err {
+			t.Errorf("%s: got error %q, want %q", test.name,
+				root.Error, test.err)
+		}
+		attrs := make(map[string]interface{})
+		for _, attr := range root.Attributes {
+			attrs[attr.Key] = attr.Value
+		}
+		if attrs["block.complete"] != test.complete {
+			t.Errorf("%s: got complete %v, want %v", test.name,
+				attrs["block.complete"], test.complete)
+		}
+		if len(spans)-1 != len(test.phases) {
+			t.Errorf("%s: got %d child spans, want %d", test.name,
+				len(spans)-1, len(test.phases))
+			continue
+		}
+		for i, phase := range test.phases {
+			span := spans[i+1]
+			if span.Name != phase || span.ParentSpanID != root.SpanID {
+				t.Errorf("%s: unexpected child span #%d %q",
+					test.name, i, span.Name)
+			}
+			if span.End.Before(span.Start) {
+				t.Errorf("%s: child span %q ends before it "+
+					"starts", test.name, span.Name)
+			}
+		}
+	}
+
+	// Ensure the attribution of the fully traced block.
+	root := traces[complete][0]
+	attrs := make(map[string]interface{})
+	for _, attr := range root.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	if attrs["block.announced_by"] != "peer1" ||
+		attrs["block.received_from"] != "peer1" ||
+		attrs["block.height"] != int64(100) ||
+		attrs["block.relay_peers"] != int64(8) {
+
+		t.Errorf("unexpected root span attributes %v", attrs)
+	}
+	download := traces[complete][2]
+	if download.End.Sub(download.Start) != 2*time.Second {
+		t.Errorf("got download duration %v, want 2s",
+			download.End.Sub(download.Start))
+	}
+
+	// Recording with a nil tracer must be a no-op.
+	var nilTracer *Tracer
+	nilTracer.Record(&complete, StageHeaderReceived, "peer1")
+	nilTracer.RecordConnected(&complete, 1)
+	nilTracer.RecordAnnounced(&complete, 1)
+	nilTracer.RecordRejected(&complete, "")
+	nilTracer.Start()
+	nilTracer.Stop()
+}
+
+// TestOTLPExporter ensures the OTLP exporter posts the spans in the OTLP JSON
+// encoding.
+func TestOTLPExporter(t *testing.T) {
+	var got map[string]interface{}
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	exporter, err := NewOTLPExporter(server.URL, "btcd")
+	if err != nil {
+		t.Fatalf("NewOTLPExporter: unexpected error: %v", err)
+	}
+	if _, err := NewOTLPExporter("ftp://localhost", "btcd"); err == nil {
+		t.Fatal("NewOTLPExporter: did not fail for invalid scheme")
+	}
+
+	hash := chainhash.Hash{0xaa, 0xbb}
+	bt := &blockTrace{hash: hash, height: 5}
+	bt.stages[StageHeaderReceived] = &stageEvent{
+		time: time.Unix(1, 0), peer: "peer1",
+	}
+	bt.stages[StageReceived] = &stageEvent{
+		time: time.Unix(2, 0), peer: "peer1",
+	}
+	if err := exporter.ExportSpans(bt.spans("rejected")); err != nil {
+		t.Fatalf("ExportSpans: unexpected error: %v", err)
+	}
+	if gotPath != otlpTracesPath {
+		t.Fatalf("got path %q, want %q", gotPath, otlpTracesPath)
+	}
+
+	resourceSpans := got["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	scopeSpans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})
+	spans := scopeSpans["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	root := spans[0].(map[string]interface{})
+	wantTraceID := "aabb0000000000000000000000000000"
+	if root["traceId"] != wantTraceID {
+		t.Errorf("got trace id %v, want %v", root["traceId"],
+			wantTraceID)
+	}
+	if root["startTimeUnixNano"] != "1000000000" ||
+		root["endTimeUnixNano"] != "2000000000" {
+
+		t.Errorf("unexpected span times %v-%v",
+			root["startTimeUnixNano"], root["endTimeUnixNano"])
+	}
+	status := root["status"].(map[string]interface{})
+	if status["code"] != float64(otlpStatusError) {
+		t.Errorf("got status %v, want error", status)
+	}
+	child := spans[1].(map[string]interface{})
+	if child["parentSpanId"] != root["spanId"] {
+		t.Errorf("got parent span id %v, want %v",
+			child["parentSpanId"], root["spanId"])
+	}
+
+	// Ensure collector errors are returned.
+	failServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failServer.Close()
+	exporter, err = NewOTLPExporter(failServer.URL, "btcd")
+	if err != nil {
+		t.Fatalf("NewOTLPExporter: unexpected error: %v", err)
+	}
+	if err := exporter.ExportSpans(bt.spans("")); err == nil {
+		t.Fatal("ExportSpans: did not fail for collector error")
+	}
+}
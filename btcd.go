@@ -17,8 +17,10 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain/indexers"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
 	"github.com/btcsuite/btcd/limits"
 	"github.com/btcsuite/btcd/ossec"
+	"github.com/btcsuite/btcd/wire"
 )
 
 const (
@@ -100,6 +102,10 @@ func btcdMain(serverChan chan<- *server) error {
 		defer runtime.GC()
 	}
 
+	// Decode the transactions of blocks into per-block arenas if
+	// requested.
+	wire.SetBlockArena(cfg.BlockArena)
+
 	// Perform upgrades to btcd as new versions require it.
 	if err := doUpgrades(); err != nil {
 		btcdLog.Errorf("%v", err)
@@ -148,6 +154,30 @@ func btcdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropSpentIndex {
+		if err := indexers.DropSpentIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropTimestampIndex {
+		if err := indexers.DropTimestampIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropBalanceIndex {
+		if err := indexers.DropBalanceIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 	if cfg.DropCfIndex {
 		if err := indexers.DropCfIndex(db, interrupt); err != nil {
 			btcdLog.Errorf("%v", err)
@@ -168,7 +198,7 @@ func btcdMain(serverChan chan<- *server) error {
 		btcdLog.Errorf("%v", err)
 		return err
 	}
-	if beenPruned && cfg.Prune == 0 {
+	if beenPruned && cfg.Prune == 0 && !cfg.FiltersOnly {
 		err = fmt.Errorf("--prune cannot be disabled as the node has been "+
 			"previously pruned. You must delete the files in the datadir: \"%s\" "+
 			"and sync from the beginning to disable pruning", cfg.DataDir)
@@ -189,6 +219,27 @@ func btcdMain(serverChan chan<- *server) error {
 		btcdLog.Errorf("%v", err)
 		return err
 	}
+	if beenPruned && cfg.SpentIndex {
+		err = fmt.Errorf("--spentindex cannot be enabled as the node has been "+
+			"previously pruned. You must delete the files in the datadir: \"%s\" "+
+			"and sync from the beginning to enable the desired index", cfg.DataDir)
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+	if beenPruned && cfg.TimestampIndex {
+		err = fmt.Errorf("--timestampindex cannot be enabled as the node has been "+
+			"previously pruned. You must delete the files in the datadir: \"%s\" "+
+			"and sync from the beginning to enable the desired index", cfg.DataDir)
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+	if beenPruned && cfg.BalanceIndex {
+		err = fmt.Errorf("--balanceindex cannot be enabled as the node has been "+
+			"previously pruned. You must delete the files in the datadir: \"%s\" "+
+			"and sync from the beginning to enable the desired index", cfg.DataDir)
+		btcdLog.Errorf("%v", err)
+		return err
+	}
 	// If we've previously been pruned and the cfindex isn't present, it means that the
 	// user wants to enable the cfindex after the node has already synced up and been
 	// pruned.
@@ -224,14 +275,15 @@ func btcdMain(serverChan chan<- *server) error {
 	// NOTE: The order is important here because dropping the tx index also
 	// drops the address index since it relies on it.  We explicitly make the
 	// user drop both indexes if --addrindex was enabled previously.
-	if cfg.Prune != 0 && indexers.AddrIndexInitialized(db) {
+	pruning := cfg.Prune != 0 || cfg.FiltersOnly
+	if pruning && indexers.AddrIndexInitialized(db) {
 		err = fmt.Errorf("--prune flag may not be given when the address index " +
 			"has been initialized. Please drop the address index with the " +
 			"--dropaddrindex flag before enabling pruning")
 		btcdLog.Errorf("%v", err)
 		return err
 	}
-	if cfg.Prune != 0 && indexers.TxIndexInitialized(db) {
+	if pruning && indexers.TxIndexInitialized(db) {
 		err = fmt.Errorf("--prune flag may not be given when the transaction index " +
 			"has been initialized. Please drop the transaction index with the " +
 			"--droptxindex flag before enabling pruning")
@@ -239,6 +291,30 @@ func btcdMain(serverChan chan<- *server) error {
 		return err
 	}
 
+	if pruning && indexers.SpentIndexInitialized(db) {
+		err = fmt.Errorf("--prune flag may not be given when the spent index " +
+			"has been initialized. Please drop the spent index with the " +
+			"--dropspentindex flag before enabling pruning")
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+
+	if pruning && indexers.TimestampIndexInitialized(db) {
+		err = fmt.Errorf("--prune flag may not be given when the timestamp index " +
+			"has been initialized. Please drop the timestamp index with the " +
+			"--droptimestampindex flag before enabling pruning")
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+
+	if pruning && indexers.BalanceIndexInitialized(db) {
+		err = fmt.Errorf("--prune flag may not be given when the balance index " +
+			"has been initialized. Please drop the balance index with the " +
+			"--dropbalanceindex flag before enabling pruning")
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+
 	// The config file is already created if it did not exist and the log
 	// file has already been opened by now so we only need to allow
 	// creating rpc cert and key files if they don't exist.
@@ -348,6 +424,38 @@ func warnMultipleDBs() {
 	}
 }
 
+// isCorruptionErr returns whether the passed error is a database corruption
+// error.
+func isCorruptionErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrCorruption
+}
+
+// repairBlockDB restores the damaged parts of the block database at dbPath
+// from the dump configured with --dbrepairsource and opens it again.
+func repairBlockDB(dbPath string) (database.DB, error) {
+	btcdLog.Infof("Repairing block database from '%s'", cfg.DbRepairSource)
+	src, err := ffldb.NewDumpSource(cfg.DbRepairSource,
+		cfg.DbRepairManifestHash)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := ffldb.Repair(dbPath, activeNetParams.Net, src)
+	if err != nil {
+		return nil, fmt.Errorf("unable to repair block database: %v",
+			err)
+	}
+	btcdLog.Infof("Restored %d damaged block file chunks, %d missing "+
+		"block files (%d bytes) and %d metadata ranges",
+		stats.DamagedChunks, stats.RestoredFiles, stats.FetchedBytes,
+		stats.DamagedRanges)
+	if stats.RolledBack {
+		btcdLog.Warnf("The block database was rolled back to the " +
+			"state of the dump")
+	}
+	return database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+}
+
 // loadBlockDB loads (or creates when needed) the block database taking into
 // account the selected database backend and returns a handle to it.  It also
 // contains additional logic such warning the user if there are multiple
@@ -377,6 +485,10 @@ func loadBlockDB() (database.DB, error) {
 
 	btcdLog.Infof("Loading block database from '%s'", dbPath)
 	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if isCorruptionErr(err) && cfg.DbRepairSource != "" {
+		btcdLog.Warnf("Block database is corrupted: %v", err)
+		db, err = repairBlockDB(dbPath)
+	}
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -397,6 +509,58 @@ func loadBlockDB() (database.DB, error) {
 		}
 	}
 
+	// Reference the blocks stored by another node when requested so they
+	// are not stored twice.  This must happen before any blocks are read,
+	// including by the block index upgrade below.
+	if cfg.SharedBlockStore != "" {
+		btcdLog.Infof("Scanning shared block store '%s'",
+			cfg.SharedBlockStore)
+		sharedCfg := ffldb.SharedStoreConfig{Path: cfg.SharedBlockStore}
+		if err := ffldb.ConfigureSharedStore(db, sharedCfg); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// Convert the block index to the compressed format when requested.
+	// This is a no-op once the index is upgraded.
+	if cfg.DbCompressBlockIndex {
+		if err := ffldb.UpgradeBlockIndex(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// Configure the trash for pruned block files when requested.  This is
+	// only supported by the ffldb backend.
+	if cfg.PruneTrashRetention != 0 {
+		trashCfg := ffldb.TrashConfig{
+			Retention: cfg.PruneTrashRetention,
+			MaxSize:   cfg.PruneTrashMaxSize * 1024 * 1024,
+		}
+		if err := ffldb.ConfigureTrash(db, trashCfg); err != nil {
+			db.Close()
+			return nil, err
+		}
+		btcdLog.Infof("Pruned block files will be kept in the trash "+
+			"for %v", cfg.PruneTrashRetention)
+	}
+
+	// Apply back-pressure to block file writes when requested.  This is
+	// only supported by the ffldb backend.
+	if cfg.DbSyncInterval != 0 || cfg.DbMaxWriteRate != 0 {
+		throttleCfg := ffldb.WriteThrottleConfig{
+			SyncInterval:      cfg.DbSyncInterval * 1024 * 1024,
+			TargetSyncLatency: cfg.DbSyncLatency,
+			MaxWriteRate:      cfg.DbMaxWriteRate * 1024 * 1024,
+		}
+		err := ffldb.ConfigureWriteThrottle(db, throttleCfg)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
 	btcdLog.Info("Block database loaded")
 	return db, nil
 }
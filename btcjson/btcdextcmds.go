@@ -44,6 +44,28 @@ func NewNodeCmd(subCmd NodeSubCmd, target string, connectSubCmd *string) *NodeCm
 	}
 }
 
+// CollectProfileCmd defines the collectprofile JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+type CollectProfileCmd struct {
+	Type    string
+	Seconds *uint32
+}
+
+// NewCollectProfileCmd returns a new instance which can be used to issue a
+// collectprofile JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcsuite extension.
+func NewCollectProfileCmd(profileType string, seconds *uint32) *CollectProfileCmd {
+	return &CollectProfileCmd{
+		Type:    profileType,
+		Seconds: seconds,
+	}
+}
+
 // DebugLevelCmd defines the debuglevel JSON-RPC command.  This command is not a
 // standard Bitcoin command.  It is an extension for btcd.
 type DebugLevelCmd struct {
@@ -59,6 +81,19 @@ func NewDebugLevelCmd(levelSpec string) *DebugLevelCmd {
 	}
 }
 
+// DumpStateCmd defines the dumpstate JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+type DumpStateCmd struct{}
+
+// NewDumpStateCmd returns a new instance which can be used to issue a
+// dumpstate JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+func NewDumpStateCmd() *DumpStateCmd {
+	return &DumpStateCmd{}
+}
+
 // GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
 type GenerateToAddressCmd struct {
 	NumBlocks int64
@@ -76,6 +111,42 @@ func NewGenerateToAddressCmd(numBlocks int64, address string, maxTries *int64) *
 	}
 }
 
+// GenerateToDescriptorCmd defines the generatetodescriptor JSON-RPC command.
+type GenerateToDescriptorCmd struct {
+	NumBlocks  int64
+	Descriptor string
+	MaxTries   *int64 `jsonrpcdefault:"1000000"`
+}
+
+// NewGenerateToDescriptorCmd returns a new instance which can be used to issue
+// a generatetodescriptor JSON-RPC command.
+func NewGenerateToDescriptorCmd(numBlocks int64, descriptor string,
+	maxTries *int64) *GenerateToDescriptorCmd {
+
+	return &GenerateToDescriptorCmd{
+		NumBlocks:  numBlocks,
+		Descriptor: descriptor,
+		MaxTries:   maxTries,
+	}
+}
+
+// GenerateBlockCmd defines the generateblock JSON-RPC command.
+type GenerateBlockCmd struct {
+	Output       string
+	Transactions []string
+}
+
+// NewGenerateBlockCmd returns a new instance which can be used to issue a
+// generateblock JSON-RPC command.  The output is either an address or a
+// descriptor and the transactions are either raw transactions in hex or the
+// ids of transactions in the memory pool.
+func NewGenerateBlockCmd(output string, transactions []string) *GenerateBlockCmd {
+	return &GenerateBlockCmd{
+		Output:       output,
+		Transactions: transactions,
+	}
+}
+
 // GenerateCmd defines the generate JSON-RPC command.
 type GenerateCmd struct {
 	NumBlocks uint32
@@ -107,6 +178,34 @@ func NewGetCurrentNetCmd() *GetCurrentNetCmd {
 	return &GetCurrentNetCmd{}
 }
 
+// GetBlockHeadersCmd defines the getblockheaders JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+type GetBlockHeadersCmd struct {
+	Start  HashOrHeight `jsonrpcusage:"start"`
+	Count  uint32
+	Format *string `jsonrpcdefault:"\"json\""`
+}
+
+// NewGetBlockHeadersCmd returns a new instance which can be used to issue a
+// getblockheaders JSON-RPC command.  The start is either the hash or the height
+// of the first block whose header is returned.  The format must be one of
+// "json", "hex" or "raw".
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcsuite extension.
+func NewGetBlockHeadersCmd(start HashOrHeight, count uint32,
+	format *string) *GetBlockHeadersCmd {
+
+	return &GetBlockHeadersCmd{
+		Start:  start,
+		Count:  count,
+		Format: format,
+	}
+}
+
 // GetHeadersCmd defines the getheaders JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -128,6 +227,45 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// MiningPayout describes an output descriptor the coinbase of the blocks
+// generated by the CPU miner pays to along with its weight.  The weight
+// defaults to 1 when it is nil.
+type MiningPayout struct {
+	Desc   string  `json:"desc"`
+	Weight *uint32 `json:"weight,omitempty"`
+}
+
+// ReloadConfigCmd defines the reloadconfig JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+type ReloadConfigCmd struct{}
+
+// NewReloadConfigCmd returns a new instance which can be used to issue a
+// reloadconfig JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+func NewReloadConfigCmd() *ReloadConfigCmd {
+	return &ReloadConfigCmd{}
+}
+
+// SetMiningPayoutsCmd defines the setminingpayouts JSON-RPC command.
+//
+// NOTE: This is a btcsuite extension.
+type SetMiningPayoutsCmd struct {
+	Payouts []MiningPayout
+}
+
+// NewSetMiningPayoutsCmd returns a new instance which can be used to issue a
+// setminingpayouts JSON-RPC command.  Passing no payouts restores the payouts
+// of the server configuration.
+//
+// NOTE: This is a btcsuite extension.
+func NewSetMiningPayoutsCmd(payouts []MiningPayout) *SetMiningPayoutsCmd {
+	return &SetMiningPayoutsCmd{
+		Payouts: payouts,
+	}
+}
+
 // VersionCmd defines the version JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -145,12 +283,19 @@ func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("collectprofile", (*CollectProfileCmd)(nil), flags)
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
+	MustRegisterCmd("dumpstate", (*DumpStateCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
 	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
+	MustRegisterCmd("generatetodescriptor", (*GenerateToDescriptorCmd)(nil), flags)
+	MustRegisterCmd("generateblock", (*GenerateBlockCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
+	MustRegisterCmd("getblockheaders", (*GetBlockHeadersCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("reloadconfig", (*ReloadConfigCmd)(nil), flags)
+	MustRegisterCmd("setminingpayouts", (*SetMiningPayoutsCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
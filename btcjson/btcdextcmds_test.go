@@ -30,6 +30,34 @@ func TestBtcdExtCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "collectprofile",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("collectprofile", "heap")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCollectProfileCmd("heap", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"collectprofile","params":["heap"],"id":1}`,
+			unmarshalled: &btcjson.CollectProfileCmd{
+				Type: "heap",
+			},
+		},
+		{
+			name: "collectprofile seconds",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("collectprofile", "cpu", 60)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCollectProfileCmd("cpu",
+					btcjson.Uint32(60))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"collectprofile","params":["cpu",60],"id":1}`,
+			unmarshalled: &btcjson.CollectProfileCmd{
+				Type:    "cpu",
+				Seconds: btcjson.Uint32(60),
+			},
+		},
 		{
 			name: "debuglevel",
 			newCmd: func() (interface{}, error) {
@@ -43,6 +71,28 @@ func TestBtcdExtCmds(t *testing.T) {
 				LevelSpec: "trace",
 			},
 		},
+		{
+			name: "dumpstate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dumpstate")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDumpStateCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"dumpstate","params":[],"id":1}`,
+			unmarshalled: &btcjson.DumpStateCmd{},
+		},
+		{
+			name: "reloadconfig",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("reloadconfig")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewReloadConfigCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"reloadconfig","params":[],"id":1}`,
+			unmarshalled: &btcjson.ReloadConfigCmd{},
+		},
 		{
 			name: "node",
 			newCmd: func() (interface{}, error) {
@@ -132,6 +182,39 @@ func TestBtcdExtCmds(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: "generatetodescriptor",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetodescriptor", 1, "raw(51)", 10)
+			},
+			staticCmd: func() interface{} {
+				maxTries := int64(10)
+				return btcjson.NewGenerateToDescriptorCmd(1, "raw(51)", &maxTries)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetodescriptor","params":[1,"raw(51)",10],"id":1}`,
+			unmarshalled: &btcjson.GenerateToDescriptorCmd{
+				NumBlocks:  1,
+				Descriptor: "raw(51)",
+				MaxTries: func() *int64 {
+					var i int64 = 10
+					return &i
+				}(),
+			},
+		},
+		{
+			name: "generateblock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address", []string{"txid"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("1Address", []string{"txid"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address",["txid"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Output:       "1Address",
+				Transactions: []string{"txid"},
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -143,6 +226,36 @@ func TestBtcdExtCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getbestblock","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetBestBlockCmd{},
 		},
+		{
+			name: "getblockheaders",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockheaders", btcjson.HashOrHeight{Value: 100}, 2000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHeadersCmd(btcjson.HashOrHeight{Value: 100}, 2000, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockheaders","params":[100,2000],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHeadersCmd{
+				Start:  btcjson.HashOrHeight{Value: 100},
+				Count:  2000,
+				Format: btcjson.String("json"),
+			},
+		},
+		{
+			name: "getblockheaders hash raw",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockheaders", btcjson.HashOrHeight{Value: "123"}, 10, "raw")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHeadersCmd(btcjson.HashOrHeight{Value: "123"}, 10, btcjson.String("raw"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockheaders","params":["123",10,"raw"],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHeadersCmd{
+				Start:  btcjson.HashOrHeight{Value: "123"},
+				Count:  10,
+				Format: btcjson.String("raw"),
+			},
+		},
 		{
 			name: "getcurrentnet",
 			newCmd: func() (interface{}, error) {
@@ -194,6 +307,31 @@ func TestBtcdExtCmds(t *testing.T) {
 				HashStop: "000000000000000000ba33b33e1fad70b69e234fc24414dd47113bff38f523f7",
 			},
 		},
+		{
+			name: "setminingpayouts",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setminingpayouts", `[{"desc":"addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)","weight":3},{"desc":"raw(51)"}]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetMiningPayoutsCmd([]btcjson.MiningPayout{
+					{
+						Desc:   "addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)",
+						Weight: btcjson.Uint32(3),
+					},
+					{Desc: "raw(51)"},
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setminingpayouts","params":[[{"desc":"addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)","weight":3},{"desc":"raw(51)"}]],"id":1}`,
+			unmarshalled: &btcjson.SetMiningPayoutsCmd{
+				Payouts: []btcjson.MiningPayout{
+					{
+						Desc:   "addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)",
+						Weight: btcjson.Uint32(3),
+					},
+					{Desc: "raw(51)"},
+				},
+			},
+		},
 		{
 			name: "version",
 			newCmd: func() (interface{}, error) {
@@ -18,3 +18,44 @@ type VersionResult struct {
 	Prerelease    string `json:"prerelease"`
 	BuildMetadata string `json:"buildmetadata"`
 }
+
+// GenerateBlockResult models the data returned from the generateblock command.
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+}
+
+// MiningPayoutResult models the payouts returned from the setminingpayouts
+// command.
+type MiningPayoutResult struct {
+	Desc   string `json:"desc"`
+	Weight uint32 `json:"weight"`
+}
+
+// CollectProfileResult models the data returned from the collectprofile
+// command.
+//
+// NOTE: This is a btcsuite extension.
+type CollectProfileResult struct {
+	Type    string  `json:"type"`
+	File    string  `json:"file"`
+	Size    int64   `json:"size"`
+	Seconds float64 `json:"seconds,omitempty"`
+}
+
+// ReloadConfigChange models an option which changed when the configuration
+// was reloaded with the reloadconfig command.
+//
+// NOTE: This is a btcsuite extension.
+type ReloadConfigChange struct {
+	Option string `json:"option"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// DumpStateResult models the data returned from the dumpstate command.
+//
+// NOTE: This is a btcsuite extension.
+type DumpStateResult struct {
+	File string `json:"file"`
+	Size int64  `json:"size"`
+}
@@ -35,6 +35,41 @@ func TestBtcdExtCustomResults(t *testing.T) {
 			},
 			expected: `{"versionstring":"1.0.0","major":1,"minor":0,"patch":0,"prerelease":"pr","buildmetadata":"bm"}`,
 		},
+		{
+			name: "miningpayoutresult",
+			result: &btcjson.MiningPayoutResult{
+				Desc:   "addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)",
+				Weight: 3,
+			},
+			expected: `{"desc":"addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)","weight":3}`,
+		},
+		{
+			name: "collectprofileresult",
+			result: &btcjson.CollectProfileResult{
+				Type:    "cpu",
+				File:    "/data/profiles/cpu-20240603T120000.000Z.pprof",
+				Size:    1024,
+				Seconds: 30,
+			},
+			expected: `{"type":"cpu","file":"/data/profiles/cpu-20240603T120000.000Z.pprof","size":1024,"seconds":30}`,
+		},
+		{
+			name: "reloadconfigchange",
+			result: &btcjson.ReloadConfigChange{
+				Option: "maxpeers",
+				Old:    "125",
+				New:    "50",
+			},
+			expected: `{"option":"maxpeers","old":"125","new":"50"}`,
+		},
+		{
+			name: "dumpstateresult",
+			result: &btcjson.DumpStateResult{
+				File: "/data/profiles/state-20240603T120000.000Z.txt",
+				Size: 4096,
+			},
+			expected: `{"file":"/data/profiles/state-20240603T120000.000Z.txt","size":4096}`,
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
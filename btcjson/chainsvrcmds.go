@@ -52,6 +52,41 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// AnalyzePsbtCmd defines the analyzepsbt JSON-RPC command.
+type AnalyzePsbtCmd struct {
+	Psbt string
+}
+
+// NewAnalyzePsbtCmd returns a new instance which can be used to issue an
+// analyzepsbt JSON-RPC command.
+func NewAnalyzePsbtCmd(psbt string) *AnalyzePsbtCmd {
+	return &AnalyzePsbtCmd{
+		Psbt: psbt,
+	}
+}
+
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
+// ConfirmReorgCmd defines the confirmreorg JSON-RPC command.
+type ConfirmReorgCmd struct {
+	BlockHash string
+}
+
+// NewConfirmReorgCmd returns a new instance which can be used to issue a
+// confirmreorg JSON-RPC command.
+func NewConfirmReorgCmd(blockHash string) *ConfirmReorgCmd {
+	return &ConfirmReorgCmd{
+		BlockHash: blockHash,
+	}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -85,9 +120,22 @@ func NewCreateRawTransactionCmd(inputs []TransactionInput, amounts map[string]fl
 	}
 }
 
+// DecodePsbtCmd defines the decodepsbt JSON-RPC command.
+type DecodePsbtCmd struct {
+	Psbt string
+}
+
+// NewDecodePsbtCmd returns a new instance which can be used to issue a
+// decodepsbt JSON-RPC command.
+func NewDecodePsbtCmd(psbt string) *DecodePsbtCmd {
+	return &DecodePsbtCmd{
+		Psbt: psbt,
+	}
+}
+
 // DecodeRawTransactionCmd defines the decoderawtransaction JSON-RPC command.
 type DecodeRawTransactionCmd struct {
-	HexTx string
+	HexTx string `jsonrpcname:"hexstring"`
 }
 
 // NewDecodeRawTransactionCmd returns a new instance which can be used to issue
@@ -111,6 +159,38 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 	}
 }
 
+// DebugScriptCmd defines the debugscript JSON-RPC command.
+//
+// NOTE: This is a btcd extension.
+type DebugScriptCmd struct {
+	ScriptPubKey string
+	ScriptSig    *string
+	Witness      *[]string
+	Amount       *float64
+	HexTx        *string
+	InputIndex   *uint32
+}
+
+// NewDebugScriptCmd returns a new instance which can be used to issue a
+// debugscript JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcd extension.
+func NewDebugScriptCmd(scriptPubKey string, scriptSig *string, witness *[]string,
+	amount *float64, hexTx *string, inputIndex *uint32) *DebugScriptCmd {
+
+	return &DebugScriptCmd{
+		ScriptPubKey: scriptPubKey,
+		ScriptSig:    scriptSig,
+		Witness:      witness,
+		Amount:       amount,
+		HexTx:        hexTx,
+		InputIndex:   inputIndex,
+	}
+}
+
 // DeriveAddressesCmd defines the deriveaddresses JSON-RPC command.
 type DeriveAddressesCmd struct {
 	Descriptor string
@@ -137,6 +217,8 @@ var (
 	ChangeTypeP2SHSegWit ChangeType = "p2sh-segwit"
 	// ChangeTypeBech32 indicates a P2WPKH change address type.
 	ChangeTypeBech32 ChangeType = "bech32"
+	// ChangeTypeBech32m indicates a P2TR change address type.
+	ChangeTypeBech32m ChangeType = "bech32m"
 )
 
 // FundRawTransactionOpts are the different options that can be passed to rawtransaction
@@ -189,6 +271,58 @@ func NewGetAddedNodeInfoCmd(dns bool, node *string) *GetAddedNodeInfoCmd {
 	}
 }
 
+// AddressesRequest models the addresses requested by the getaddressbalance and
+// getaddressutxos JSON-RPC commands.  Compatible with the insight API, the
+// addresses are given as an object with a list of addresses or as a single
+// address string.
+type AddressesRequest struct {
+	Addresses []string `json:"addresses"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for
+// AddressesRequest.
+func (r *AddressesRequest) UnmarshalJSON(data []byte) error {
+	var addr string
+	if err := json.Unmarshal(data, &addr); err == nil {
+		*r = AddressesRequest{Addresses: []string{addr}}
+		return nil
+	}
+
+	type addressesRequest AddressesRequest
+	var req addressesRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("invalid addresses request: %s", data)
+	}
+	*r = AddressesRequest(req)
+	return nil
+}
+
+// GetAddressBalanceCmd defines the getaddressbalance JSON-RPC command.
+type GetAddressBalanceCmd struct {
+	Request AddressesRequest
+}
+
+// NewGetAddressBalanceCmd returns a new instance which can be used to issue a
+// getaddressbalance JSON-RPC command.
+func NewGetAddressBalanceCmd(addresses []string) *GetAddressBalanceCmd {
+	return &GetAddressBalanceCmd{
+		Request: AddressesRequest{Addresses: addresses},
+	}
+}
+
+// GetAddressUtxosCmd defines the getaddressutxos JSON-RPC command.
+type GetAddressUtxosCmd struct {
+	Request AddressesRequest
+}
+
+// NewGetAddressUtxosCmd returns a new instance which can be used to issue a
+// getaddressutxos JSON-RPC command.
+func NewGetAddressUtxosCmd(addresses []string) *GetAddressUtxosCmd {
+	return &GetAddressUtxosCmd{
+		Request: AddressesRequest{Addresses: addresses},
+	}
+}
+
 // GetBestBlockHashCmd defines the getbestblockhash JSON-RPC command.
 type GetBestBlockHashCmd struct{}
 
@@ -200,8 +334,8 @@ func NewGetBestBlockHashCmd() *GetBestBlockHashCmd {
 
 // GetBlockCmd defines the getblock JSON-RPC command.
 type GetBlockCmd struct {
-	Hash      string
-	Verbosity *int `jsonrpcdefault:"1"`
+	Hash      string `jsonrpcname:"blockhash"`
+	Verbosity *int   `jsonrpcdefault:"1"`
 }
 
 // NewGetBlockCmd returns a new instance which can be used to issue a getblock
@@ -261,9 +395,24 @@ func NewGetBlockFilterCmd(blockHash string, filterType *FilterTypeName) *GetBloc
 	}
 }
 
+// GetBlockFromPeerCmd defines the getblockfrompeer JSON-RPC command.
+type GetBlockFromPeerCmd struct {
+	BlockHash string
+	PeerID    int32
+}
+
+// NewGetBlockFromPeerCmd returns a new instance which can be used to issue a
+// getblockfrompeer JSON-RPC command.
+func NewGetBlockFromPeerCmd(blockHash string, peerID int32) *GetBlockFromPeerCmd {
+	return &GetBlockFromPeerCmd{
+		BlockHash: blockHash,
+		PeerID:    peerID,
+	}
+}
+
 // GetBlockHashCmd defines the getblockhash JSON-RPC command.
 type GetBlockHashCmd struct {
-	Index int64
+	Index int64 `jsonrpcname:"height"`
 }
 
 // NewGetBlockHashCmd returns a new instance which can be used to issue a
@@ -274,10 +423,25 @@ func NewGetBlockHashCmd(index int64) *GetBlockHashCmd {
 	}
 }
 
+// GetBlockHashesCmd defines the getblockhashes JSON-RPC command.
+type GetBlockHashesCmd struct {
+	High uint32
+	Low  uint32
+}
+
+// NewGetBlockHashesCmd returns a new instance which can be used to issue a
+// getblockhashes JSON-RPC command.
+func NewGetBlockHashesCmd(high, low uint32) *GetBlockHashesCmd {
+	return &GetBlockHashesCmd{
+		High: high,
+		Low:  low,
+	}
+}
+
 // GetBlockHeaderCmd defines the getblockheader JSON-RPC command.
 type GetBlockHeaderCmd struct {
-	Hash    string
-	Verbose *bool `jsonrpcdefault:"true"`
+	Hash    string `jsonrpcname:"blockhash"`
+	Verbose *bool  `jsonrpcdefault:"true"`
 }
 
 // NewGetBlockHeaderCmd returns a new instance which can be used to issue a
@@ -532,6 +696,22 @@ func NewGetHashesPerSecCmd() *GetHashesPerSecCmd {
 	return &GetHashesPerSecCmd{}
 }
 
+// GetIndexInfoCmd defines the getindexinfo JSON-RPC command.
+type GetIndexInfoCmd struct {
+	IndexName *string
+}
+
+// NewGetIndexInfoCmd returns a new instance which can be used to issue a
+// getindexinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetIndexInfoCmd(indexName *string) *GetIndexInfoCmd {
+	return &GetIndexInfoCmd{
+		IndexName: indexName,
+	}
+}
+
 // GetInfoCmd defines the getinfo JSON-RPC command.
 type GetInfoCmd struct{}
 
@@ -541,6 +721,62 @@ func NewGetInfoCmd() *GetInfoCmd {
 	return &GetInfoCmd{}
 }
 
+// GetMempoolAncestorsCmd defines the getmempoolancestors JSON-RPC command.
+type GetMempoolAncestorsCmd struct {
+	TxID    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetMempoolAncestorsCmd returns a new instance which can be used to issue a
+// getmempoolancestors JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMempoolAncestorsCmd(txHash string,
+	verbose *bool) *GetMempoolAncestorsCmd {
+
+	return &GetMempoolAncestorsCmd{
+		TxID:    txHash,
+		Verbose: verbose,
+	}
+}
+
+// GetMempoolDescendantsCmd defines the getmempooldescendants JSON-RPC command.
+type GetMempoolDescendantsCmd struct {
+	TxID    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetMempoolDescendantsCmd returns a new instance which can be used to
+// issue a getmempooldescendants JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMempoolDescendantsCmd(txHash string,
+	verbose *bool) *GetMempoolDescendantsCmd {
+
+	return &GetMempoolDescendantsCmd{
+		TxID:    txHash,
+		Verbose: verbose,
+	}
+}
+
+// GetMemoryInfoCmd defines the getmemoryinfo JSON-RPC command.
+type GetMemoryInfoCmd struct {
+	Mode *string `jsonrpcdefault:"\"stats\""`
+}
+
+// NewGetMemoryInfoCmd returns a new instance which can be used to issue a
+// getmemoryinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMemoryInfoCmd(mode *string) *GetMemoryInfoCmd {
+	return &GetMemoryInfoCmd{
+		Mode: mode,
+	}
+}
+
 // GetMempoolEntryCmd defines the getmempoolentry JSON-RPC command.
 type GetMempoolEntryCmd struct {
 	TxID string
@@ -610,7 +846,8 @@ func NewGetNetworkHashPSCmd(numBlocks, height *int) *GetNetworkHashPSCmd {
 
 // GetNodeAddressesCmd defines the getnodeaddresses JSON-RPC command.
 type GetNodeAddressesCmd struct {
-	Count *int32 `jsonrpcdefault:"1"`
+	Count   *int32 `jsonrpcdefault:"1"`
+	Network *string
 }
 
 // NewGetNodeAddressesCmd returns a new instance which can be used to issue a
@@ -618,9 +855,10 @@ type GetNodeAddressesCmd struct {
 //
 // The parameters which are pointers indicate they are optional.  Passing nil
 // for optional parameters will use the default value.
-func NewGetNodeAddressesCmd(count *int32) *GetNodeAddressesCmd {
+func NewGetNodeAddressesCmd(count *int32, network *string) *GetNodeAddressesCmd {
 	return &GetNodeAddressesCmd{
-		Count: count,
+		Count:   count,
+		Network: network,
 	}
 }
 
@@ -633,6 +871,65 @@ func NewGetPeerInfoCmd() *GetPeerInfoCmd {
 	return &GetPeerInfoCmd{}
 }
 
+// GetOrphanInfoCmd defines the getorphaninfo JSON-RPC command.
+type GetOrphanInfoCmd struct {
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetOrphanInfoCmd returns a new instance which can be used to issue a
+// getorphaninfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetOrphanInfoCmd(verbose *bool) *GetOrphanInfoCmd {
+	return &GetOrphanInfoCmd{
+		Verbose: verbose,
+	}
+}
+
+// GetPendingReorgCmd defines the getpendingreorg JSON-RPC command.
+type GetPendingReorgCmd struct{}
+
+// NewGetPendingReorgCmd returns a new instance which can be used to issue a
+// getpendingreorg JSON-RPC command.
+func NewGetPendingReorgCmd() *GetPendingReorgCmd {
+	return &GetPendingReorgCmd{}
+}
+
+// GetPeerReputationCmd defines the getpeerreputation JSON-RPC command.
+type GetPeerReputationCmd struct {
+	Addr *string
+}
+
+// NewGetPeerReputationCmd returns a new instance which can be used to issue a
+// getpeerreputation JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetPeerReputationCmd(addr *string) *GetPeerReputationCmd {
+	return &GetPeerReputationCmd{
+		Addr: addr,
+	}
+}
+
+// GetRPCInfoCmd defines the getrpcinfo JSON-RPC command.
+type GetRPCInfoCmd struct{}
+
+// NewGetRPCInfoCmd returns a new instance which can be used to issue a
+// getrpcinfo JSON-RPC command.
+func NewGetRPCInfoCmd() *GetRPCInfoCmd {
+	return &GetRPCInfoCmd{}
+}
+
+// GetRPCStatsCmd defines the getrpcstats JSON-RPC command.
+type GetRPCStatsCmd struct{}
+
+// NewGetRPCStatsCmd returns a new instance which can be used to issue a
+// getrpcstats JSON-RPC command.
+func NewGetRPCStatsCmd() *GetRPCStatsCmd {
+	return &GetRPCStatsCmd{}
+}
+
 // GetRawMempoolCmd defines the getmempool JSON-RPC command.
 type GetRawMempoolCmd struct {
 	Verbose *bool `jsonrpcdefault:"false"`
@@ -670,6 +967,21 @@ func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd
 	}
 }
 
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	Txid  string
+	Index uint32
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(txHash string, index uint32) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		Txid:  txHash,
+		Index: index,
+	}
+}
+
 // GetTxOutCmd defines the gettxout JSON-RPC command.
 type GetTxOutCmd struct {
 	Txid           string
@@ -709,12 +1021,28 @@ func NewGetTxOutProofCmd(txIDs []string, blockHash *string) *GetTxOutProofCmd {
 }
 
 // GetTxOutSetInfoCmd defines the gettxoutsetinfo JSON-RPC command.
-type GetTxOutSetInfoCmd struct{}
+type GetTxOutSetInfoCmd struct {
+	HashType *string `jsonrpcdefault:"\"muhash\""`
+}
 
 // NewGetTxOutSetInfoCmd returns a new instance which can be used to issue a
 // gettxoutsetinfo JSON-RPC command.
-func NewGetTxOutSetInfoCmd() *GetTxOutSetInfoCmd {
-	return &GetTxOutSetInfoCmd{}
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetTxOutSetInfoCmd(hashType *string) *GetTxOutSetInfoCmd {
+	return &GetTxOutSetInfoCmd{
+		HashType: hashType,
+	}
+}
+
+// GetUnbroadcastCmd defines the getunbroadcast JSON-RPC command.
+type GetUnbroadcastCmd struct{}
+
+// NewGetUnbroadcastCmd returns a new instance which can be used to issue a
+// getunbroadcast JSON-RPC command.
+func NewGetUnbroadcastCmd() *GetUnbroadcastCmd {
+	return &GetUnbroadcastCmd{}
 }
 
 // GetWorkCmd defines the getwork JSON-RPC command.
@@ -762,6 +1090,30 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// MatchBlockFilterCmd defines the matchblockfilter JSON-RPC command.
+type MatchBlockFilterCmd struct {
+	BlockHash string
+	Scripts   []string
+}
+
+// NewMatchBlockFilterCmd returns a new instance which can be used to issue a
+// matchblockfilter JSON-RPC command.
+func NewMatchBlockFilterCmd(blockHash string, scripts []string) *MatchBlockFilterCmd {
+	return &MatchBlockFilterCmd{
+		BlockHash: blockHash,
+		Scripts:   scripts,
+	}
+}
+
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -784,6 +1136,23 @@ func NewPreciousBlockCmd(blockHash string) *PreciousBlockCmd {
 	}
 }
 
+// PrioritiseTransactionCmd defines the prioritisetransaction JSON-RPC command.
+type PrioritiseTransactionCmd struct {
+	TxID     string
+	FeeDelta int64
+}
+
+// NewPrioritiseTransactionCmd returns a new instance which can be used to issue
+// a prioritisetransaction JSON-RPC command.
+func NewPrioritiseTransactionCmd(txID string,
+	feeDelta int64) *PrioritiseTransactionCmd {
+
+	return &PrioritiseTransactionCmd{
+		TxID:     txID,
+		FeeDelta: feeDelta,
+	}
+}
+
 // ReconsiderBlockCmd defines the reconsiderblock JSON-RPC command.
 type ReconsiderBlockCmd struct {
 	BlockHash string
@@ -797,22 +1166,80 @@ func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
 	}
 }
 
+// ScanObject is an object the utxo set is scanned for by scantxoutset.  It is
+// either a descriptor, which is encoded as a string, or a ranged descriptor
+// along with the range of the keys to derive.
+type ScanObject struct {
+	Desc  string           `json:"desc"`
+	Range *DescriptorRange `json:"range,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface for ScanObject.
+func (o ScanObject) MarshalJSON() ([]byte, error) {
+	if o.Range == nil {
+		return json.Marshal(o.Desc)
+	}
+	type scanObject ScanObject
+	return json.Marshal(scanObject(o))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for ScanObject.
+func (o *ScanObject) UnmarshalJSON(data []byte) error {
+	var desc string
+	if err := json.Unmarshal(data, &desc); err == nil {
+		*o = ScanObject{Desc: desc}
+		return nil
+	}
+
+	type scanObject ScanObject
+	var obj scanObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid scan object: %s", data)
+	}
+	*o = ScanObject(obj)
+	return nil
+}
+
+// ScanTxOutSetCmd defines the scantxoutset JSON-RPC command.
+type ScanTxOutSetCmd struct {
+	Action      string
+	ScanObjects *[]ScanObject
+}
+
+// NewScanTxOutSetCmd returns a new instance which can be used to issue a
+// scantxoutset JSON-RPC command.  The action is one of start, abort and
+// status, and the scan objects are only used by start.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewScanTxOutSetCmd(action string, scanObjects *[]ScanObject) *ScanTxOutSetCmd {
+	return &ScanTxOutSetCmd{
+		Action:      action,
+		ScanObjects: scanObjects,
+	}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
-	Address     string
-	Verbose     *int  `jsonrpcdefault:"1"`
-	Skip        *int  `jsonrpcdefault:"0"`
-	Count       *int  `jsonrpcdefault:"100"`
-	VinExtra    *int  `jsonrpcdefault:"0"`
-	Reverse     *bool `jsonrpcdefault:"false"`
-	FilterAddrs *[]string
+	Address        string
+	Verbose        *int  `jsonrpcdefault:"1"`
+	Skip           *int  `jsonrpcdefault:"0"`
+	Count          *int  `jsonrpcdefault:"100"`
+	VinExtra       *int  `jsonrpcdefault:"0"`
+	Reverse        *bool `jsonrpcdefault:"false"`
+	FilterAddrs    *[]string
+	IncludeMempool *bool `jsonrpcdefault:"true"`
+	After          *string
 }
 
 // NewSearchRawTransactionsCmd returns a new instance which can be used to issue a
 // sendrawtransaction JSON-RPC command.
 //
 // The parameters which are pointers indicate they are optional.  Passing nil
-// for optional parameters will use the default value.
+// for optional parameters will use the default value.  The IncludeMempool and
+// After fields of the returned command may be set to control whether
+// unconfirmed transactions are included and to page through the results
+// after a given transaction hash.
 func NewSearchRawTransactionsCmd(address string, verbose, skip, count *int, vinExtra *int, reverse *bool, filterAddrs *[]string) *SearchRawTransactionsCmd {
 	return &SearchRawTransactionsCmd{
 		Address:     address,
@@ -877,8 +1304,8 @@ func (a *AllowHighFeesOrMaxFeeRate) UnmarshalJSON(data []byte) error {
 
 // SendRawTransactionCmd defines the sendrawtransaction JSON-RPC command.
 type SendRawTransactionCmd struct {
-	HexTx      string
-	FeeSetting *AllowHighFeesOrMaxFeeRate `jsonrpcdefault:"false"`
+	HexTx      string                     `jsonrpcname:"hexstring"`
+	FeeSetting *AllowHighFeesOrMaxFeeRate `jsonrpcname:"maxfeerate" jsonrpcdefault:"false"`
 }
 
 // NewSendRawTransactionCmd returns a new instance which can be used to issue a
@@ -909,6 +1336,43 @@ func NewBitcoindSendRawTransactionCmd(hexTx string, maxFeeRate BTCPerkvB) *SendR
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified subnet should be
+	// removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	Subnet   string
+	Command  SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subnet string, command SetBanSubCmd, banTime *int64,
+	absolute *bool) *SetBanCmd {
+
+	return &SetBanCmd{
+		Subnet:   subnet,
+		Command:  command,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -979,6 +1443,19 @@ func NewSubmitBlockCmd(hexBlock string, options *SubmitBlockOptions) *SubmitBloc
 	}
 }
 
+// SubmitHeaderCmd defines the submitheader JSON-RPC command.
+type SubmitHeaderCmd struct {
+	HexData string
+}
+
+// NewSubmitHeaderCmd returns a new instance which can be used to issue a
+// submitheader JSON-RPC command.
+func NewSubmitHeaderCmd(hexData string) *SubmitHeaderCmd {
+	return &SubmitHeaderCmd{
+		HexData: hexData,
+	}
+}
+
 // UptimeCmd defines the uptime JSON-RPC command.
 type UptimeCmd struct{}
 
@@ -987,6 +1464,25 @@ func NewUptimeCmd() *UptimeCmd {
 	return &UptimeCmd{}
 }
 
+// UtxoUpdatePsbtCmd defines the utxoupdatepsbt JSON-RPC command.
+type UtxoUpdatePsbtCmd struct {
+	Psbt        string
+	Descriptors *[]ScanObject
+}
+
+// NewUtxoUpdatePsbtCmd returns a new instance which can be used to issue a
+// utxoupdatepsbt JSON-RPC command.  The descriptors are used to add the
+// scripts and key derivations of the inputs and outputs they describe.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewUtxoUpdatePsbtCmd(psbt string, descriptors *[]ScanObject) *UtxoUpdatePsbtCmd {
+	return &UtxoUpdatePsbtCmd{
+		Psbt:        psbt,
+		Descriptors: descriptors,
+	}
+}
+
 // ValidateAddressCmd defines the validateaddress JSON-RPC command.
 type ValidateAddressCmd struct {
 	Address string
@@ -1069,6 +1565,39 @@ func NewTestMempoolAcceptCmd(rawTxns []string,
 	}
 }
 
+// SubmitPackageCmd defines the submitpackage JSON-RPC command.
+type SubmitPackageCmd struct {
+	// An array of hex strings of raw transactions.  The package must
+	// consist of a child transaction, which comes last, and its parents
+	// sorted so that parents precede their children.
+	RawTxns []string
+
+	// Reject transactions whose fee rate is higher than the specified
+	// value, expressed in BTC/kvB, optional, default="0.10".  A value of
+	// 0 disables the check.
+	MaxFeeRate *float64 `jsonrpcdefault:"0.10"`
+
+	// Reject transactions with provably unspendable outputs, such as data
+	// carrier outputs, whose value is greater than the specified value,
+	// expressed in BTC, optional, default="0".
+	MaxBurnAmount *float64 `jsonrpcdefault:"0"`
+}
+
+// NewSubmitPackageCmd returns a new instance which can be used to issue a
+// submitpackage JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSubmitPackageCmd(rawTxns []string, maxFeeRate,
+	maxBurnAmount *float64) *SubmitPackageCmd {
+
+	return &SubmitPackageCmd{
+		RawTxns:       rawTxns,
+		MaxFeeRate:    maxFeeRate,
+		MaxBurnAmount: maxBurnAmount,
+	}
+}
+
 // GetTxSpendingPrevOutCmd defines the gettxspendingprevout JSON-RPC command.
 type GetTxSpendingPrevOutCmd struct {
 	// Outputs is a list of transaction outputs to query.
@@ -1106,18 +1635,27 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("analyzepsbt", (*AnalyzePsbtCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
+	MustRegisterCmd("confirmreorg", (*ConfirmReorgCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("decodepsbt", (*DecodePsbtCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
+	MustRegisterCmd("debugscript", (*DebugScriptCmd)(nil), flags)
 	MustRegisterCmd("deriveaddresses", (*DeriveAddressesCmd)(nil), flags)
 	MustRegisterCmd("fundrawtransaction", (*FundRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
+	MustRegisterCmd("getaddressbalance", (*GetAddressBalanceCmd)(nil), flags)
+	MustRegisterCmd("getaddressutxos", (*GetAddressUtxosCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblock", (*GetBlockCmd)(nil), flags)
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockfilter", (*GetBlockFilterCmd)(nil), flags)
+	MustRegisterCmd("getblockfrompeer", (*GetBlockFromPeerCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
+	MustRegisterCmd("getblockhashes", (*GetBlockHashesCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
 	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
@@ -1130,33 +1668,52 @@ func init() {
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
+	MustRegisterCmd("getindexinfo", (*GetIndexInfoCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
+	MustRegisterCmd("getmempoolancestors", (*GetMempoolAncestorsCmd)(nil), flags)
+	MustRegisterCmd("getmempooldescendants", (*GetMempoolDescendantsCmd)(nil), flags)
 	MustRegisterCmd("getmempoolentry", (*GetMempoolEntryCmd)(nil), flags)
+	MustRegisterCmd("getmemoryinfo", (*GetMemoryInfoCmd)(nil), flags)
 	MustRegisterCmd("getmempoolinfo", (*GetMempoolInfoCmd)(nil), flags)
 	MustRegisterCmd("getmininginfo", (*GetMiningInfoCmd)(nil), flags)
 	MustRegisterCmd("getnetworkinfo", (*GetNetworkInfoCmd)(nil), flags)
 	MustRegisterCmd("getnettotals", (*GetNetTotalsCmd)(nil), flags)
 	MustRegisterCmd("getnetworkhashps", (*GetNetworkHashPSCmd)(nil), flags)
 	MustRegisterCmd("getnodeaddresses", (*GetNodeAddressesCmd)(nil), flags)
+	MustRegisterCmd("getorphaninfo", (*GetOrphanInfoCmd)(nil), flags)
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
+	MustRegisterCmd("getpeerreputation", (*GetPeerReputationCmd)(nil), flags)
+	MustRegisterCmd("getpendingreorg", (*GetPendingReorgCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getrpcinfo", (*GetRPCInfoCmd)(nil), flags)
+	MustRegisterCmd("getrpcstats", (*GetRPCStatsCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
+	MustRegisterCmd("getunbroadcast", (*GetUnbroadcastCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
+	MustRegisterCmd("matchblockfilter", (*MatchBlockFilterCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
+	MustRegisterCmd("prioritisetransaction", (*PrioritiseTransactionCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("scantxoutset", (*ScanTxOutSetCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
 	MustRegisterCmd("signmessagewithprivkey", (*SignMessageWithPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
+	MustRegisterCmd("submitheader", (*SubmitHeaderCmd)(nil), flags)
+	MustRegisterCmd("submitpackage", (*SubmitPackageCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
+	MustRegisterCmd("utxoupdatepsbt", (*UtxoUpdatePsbtCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
 	MustRegisterCmd("verifymessage", (*VerifyMessageCmd)(nil), flags)
@@ -43,6 +43,39 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "analyzepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("analyzepsbt", "cHNidP8B")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAnalyzePsbtCmd("cHNidP8B")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"analyzepsbt","params":["cHNidP8B"],"id":1}`,
+			unmarshalled: &btcjson.AnalyzePsbtCmd{Psbt: "cHNidP8B"},
+		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
+		{
+			name: "confirmreorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("confirmreorg", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewConfirmReorgCmd("123")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"confirmreorg","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.ConfirmReorgCmd{BlockHash: "123"},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -199,6 +232,17 @@ func TestChainSvrCmds(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: "decodepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("decodepsbt", "cHNidP8B")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDecodePsbtCmd("cHNidP8B")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"decodepsbt","params":["cHNidP8B"],"id":1}`,
+			unmarshalled: &btcjson.DecodePsbtCmd{Psbt: "cHNidP8B"},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -221,6 +265,37 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "debugscript",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("debugscript", "51")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDebugScriptCmd("51", nil, nil, nil, nil, nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"debugscript","params":["51"],"id":1}`,
+			unmarshalled: &btcjson.DebugScriptCmd{ScriptPubKey: "51"},
+		},
+		{
+			name: "debugscript optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("debugscript", "0014ab", "", []string{"30", "02"}, 0.5, "0100", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDebugScriptCmd("0014ab", btcjson.String(""),
+					&[]string{"30", "02"}, btcjson.Float64(0.5),
+					btcjson.String("0100"), btcjson.Uint32(1))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"debugscript","params":["0014ab","",["30","02"],0.5,"0100",1],"id":1}`,
+			unmarshalled: &btcjson.DebugScriptCmd{
+				ScriptPubKey: "0014ab",
+				ScriptSig:    btcjson.String(""),
+				Witness:      &[]string{"30", "02"},
+				Amount:       btcjson.Float64(0.5),
+				HexTx:        btcjson.String("0100"),
+				InputIndex:   btcjson.Uint32(1),
+			},
+		},
 		{
 			name: "deriveaddresses no range",
 			newCmd: func() (interface{}, error) {
@@ -291,6 +366,36 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: btcjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressbalance", `{"addresses":["1Address","1Other"]}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressBalanceCmd([]string{"1Address", "1Other"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressbalance","params":[{"addresses":["1Address","1Other"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressBalanceCmd{
+				Request: btcjson.AddressesRequest{
+					Addresses: []string{"1Address", "1Other"},
+				},
+			},
+		},
+		{
+			name: "getaddressutxos single address",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressutxos", `"1Address"`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressUtxosCmd([]string{"1Address"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":[{"addresses":["1Address"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressUtxosCmd{
+				Request: btcjson.AddressesRequest{
+					Addresses: []string{"1Address"},
+				},
+			},
+		},
 		{
 			name: "getbestblockhash",
 			newCmd: func() (interface{}, error) {
@@ -402,6 +507,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getblockfilter","params":["0000afaf","basic"],"id":1}`,
 			unmarshalled: &btcjson.GetBlockFilterCmd{"0000afaf", btcjson.NewFilterTypeName(btcjson.FilterTypeBasic)},
 		},
+		{
+			name: "getblockfrompeer",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockfrompeer", "0000afaf", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockFromPeerCmd("0000afaf", 3)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getblockfrompeer","params":["0000afaf",3],"id":1}`,
+			unmarshalled: &btcjson.GetBlockFromPeerCmd{BlockHash: "0000afaf", PeerID: 3},
+		},
 		{
 			name: "getblockhash",
 			newCmd: func() (interface{}, error) {
@@ -413,6 +529,20 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getblockhash","params":[123],"id":1}`,
 			unmarshalled: &btcjson.GetBlockHashCmd{Index: 123},
 		},
+		{
+			name: "getblockhashes",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockhashes", 1700003600, 1700000000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHashesCmd(1700003600, 1700000000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockhashes","params":[1700003600,1700000000],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHashesCmd{
+				High: 1700003600,
+				Low:  1700000000,
+			},
+		},
 		{
 			name: "getblockheader",
 			newCmd: func() (interface{}, error) {
@@ -689,6 +819,30 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gethashespersec","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetHashesPerSecCmd{},
 		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{},
+		},
+		{
+			name: "getindexinfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo", "txindex")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(btcjson.String("txindex"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":["txindex"],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{
+				IndexName: btcjson.String("txindex"),
+			},
+		},
 		{
 			name: "getinfo",
 			newCmd: func() (interface{}, error) {
@@ -700,6 +854,64 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetInfoCmd{},
 		},
+		{
+			name: "getmempoolancestors",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempoolancestors", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolAncestorsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash"],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempoolancestors optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempoolancestors", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolAncestorsCmd("txhash",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash",true],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getmempooldescendants",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempooldescendants", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolDescendantsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash"],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempooldescendants optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempooldescendants", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolDescendantsCmd("txhash",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash",true],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "getmempoolentry",
 			newCmd: func() (interface{}, error) {
@@ -713,6 +925,32 @@ func TestChainSvrCmds(t *testing.T) {
 				TxID: "txhash",
 			},
 		},
+		{
+			name: "getmemoryinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmemoryinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMemoryInfoCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmemoryinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetMemoryInfoCmd{
+				Mode: btcjson.String("stats"),
+			},
+		},
+		{
+			name: "getmemoryinfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmemoryinfo", "mallocinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMemoryInfoCmd(btcjson.String("mallocinfo"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmemoryinfo","params":["mallocinfo"],"id":1}`,
+			unmarshalled: &btcjson.GetMemoryInfoCmd{
+				Mode: btcjson.String("mallocinfo"),
+			},
+		},
 		{
 			name: "getmempoolinfo",
 			newCmd: func() (interface{}, error) {
@@ -805,7 +1043,7 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("getnodeaddresses")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetNodeAddressesCmd(nil)
+				return btcjson.NewGetNodeAddressesCmd(nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getnodeaddresses","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetNodeAddressesCmd{
@@ -818,13 +1056,39 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("getnodeaddresses", 10)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetNodeAddressesCmd(btcjson.Int32(10))
+				return btcjson.NewGetNodeAddressesCmd(btcjson.Int32(10), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getnodeaddresses","params":[10],"id":1}`,
 			unmarshalled: &btcjson.GetNodeAddressesCmd{
 				Count: btcjson.Int32(10),
 			},
 		},
+		{
+			name: "getnodeaddresses network",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getnodeaddresses", 10, "i2p")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetNodeAddressesCmd(btcjson.Int32(10),
+					btcjson.String("i2p"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getnodeaddresses","params":[10,"i2p"],"id":1}`,
+			unmarshalled: &btcjson.GetNodeAddressesCmd{
+				Count:   btcjson.Int32(10),
+				Network: btcjson.String("i2p"),
+			},
+		},
+		{
+			name: "getpendingreorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpendingreorg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPendingReorgCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getpendingreorg","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetPendingReorgCmd{},
+		},
 		{
 			name: "getpeerinfo",
 			newCmd: func() (interface{}, error) {
@@ -836,6 +1100,78 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getpeerinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetPeerInfoCmd{},
 		},
+		{
+			name: "getorphaninfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getorphaninfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetOrphanInfoCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getorphaninfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetOrphanInfoCmd{
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getorphaninfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getorphaninfo", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetOrphanInfoCmd(btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getorphaninfo","params":[true],"id":1}`,
+			unmarshalled: &btcjson.GetOrphanInfoCmd{
+				Verbose: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getpeerreputation",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpeerreputation")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPeerReputationCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getpeerreputation","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetPeerReputationCmd{},
+		},
+		{
+			name: "getpeerreputation optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpeerreputation", "10.0.0.1")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPeerReputationCmd(btcjson.String("10.0.0.1"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getpeerreputation","params":["10.0.0.1"],"id":1}`,
+			unmarshalled: &btcjson.GetPeerReputationCmd{
+				Addr: btcjson.String("10.0.0.1"),
+			},
+		},
+		{
+			name: "getrpcinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrpcinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRPCInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrpcinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetRPCInfoCmd{},
+		},
+		{
+			name: "getrpcstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrpcstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRPCStatsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrpcstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetRPCStatsCmd{},
+		},
 		{
 			name: "getrawmempool",
 			newCmd: func() (interface{}, error) {
@@ -890,6 +1226,20 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Int(1),
 			},
 		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getspentinfo", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSpentInfoCmd("123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":["123",1],"id":1}`,
+			unmarshalled: &btcjson.GetSpentInfoCmd{
+				Txid:  "123",
+				Index: 1,
+			},
+		},
 		{
 			name: "gettxout",
 			newCmd: func() (interface{}, error) {
@@ -956,10 +1306,36 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("gettxoutsetinfo")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetTxOutSetInfoCmd()
+				return btcjson.NewGetTxOutSetInfoCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType: btcjson.String("muhash"),
+			},
+		},
+		{
+			name: "gettxoutsetinfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxoutsetinfo", "none")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxOutSetInfoCmd(btcjson.String("none"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":["none"],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType: btcjson.String("none"),
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
-			unmarshalled: &btcjson.GetTxOutSetInfoCmd{},
+		},
+		{
+			name: "getunbroadcast",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getunbroadcast")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUnbroadcastCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getunbroadcast","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetUnbroadcastCmd{},
 		},
 		{
 			name: "getwork",
@@ -1026,6 +1402,33 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "matchblockfilter",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("matchblockfilter", "123",
+					`["0014a0b1","6a"]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewMatchBlockFilterCmd("123",
+					[]string{"0014a0b1", "6a"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"matchblockfilter","params":["123",["0014a0b1","6a"]],"id":1}`,
+			unmarshalled: &btcjson.MatchBlockFilterCmd{
+				BlockHash: "123",
+				Scripts:   []string{"0014a0b1", "6a"},
+			},
+		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -1050,6 +1453,20 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "0123",
 			},
 		},
+		{
+			name: "prioritisetransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("prioritisetransaction", "123", -1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewPrioritiseTransactionCmd("123", -1000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"prioritisetransaction","params":["123",-1000],"id":1}`,
+			unmarshalled: &btcjson.PrioritiseTransactionCmd{
+				TxID:     "123",
+				FeeDelta: -1000,
+			},
+		},
 		{
 			name: "reconsiderblock",
 			newCmd: func() (interface{}, error) {
@@ -1063,6 +1480,40 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "scantxoutset status",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "status")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd("status", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["status"],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action: "status",
+			},
+		},
+		{
+			name: "scantxoutset start",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "start",
+					`["addr(1Address)",{"desc":"raw(51)","range":[0,9]}]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd("start", &[]btcjson.ScanObject{
+					{Desc: "addr(1Address)"},
+					{Desc: "raw(51)", Range: &btcjson.DescriptorRange{Value: []int{0, 9}}},
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["start",["addr(1Address)",{"desc":"raw(51)","range":[0,9]}]],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action: "start",
+				ScanObjects: &[]btcjson.ScanObject{
+					{Desc: "addr(1Address)"},
+					{Desc: "raw(51)", Range: &btcjson.DescriptorRange{Value: []int{0, 9}}},
+				},
+			},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -1073,13 +1524,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address"],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(1),
-				Skip:        btcjson.Int(0),
-				Count:       btcjson.Int(100),
-				VinExtra:    btcjson.Int(0),
-				Reverse:     btcjson.Bool(false),
-				FilterAddrs: nil,
+				Address:        "1Address",
+				Verbose:        btcjson.Int(1),
+				Skip:           btcjson.Int(0),
+				Count:          btcjson.Int(100),
+				VinExtra:       btcjson.Int(0),
+				Reverse:        btcjson.Bool(false),
+				FilterAddrs:    nil,
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1093,13 +1545,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(0),
-				Count:       btcjson.Int(100),
-				VinExtra:    btcjson.Int(0),
-				Reverse:     btcjson.Bool(false),
-				FilterAddrs: nil,
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(0),
+				Count:          btcjson.Int(100),
+				VinExtra:       btcjson.Int(0),
+				Reverse:        btcjson.Bool(false),
+				FilterAddrs:    nil,
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1113,13 +1566,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(5),
-				Count:       btcjson.Int(100),
-				VinExtra:    btcjson.Int(0),
-				Reverse:     btcjson.Bool(false),
-				FilterAddrs: nil,
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(100),
+				VinExtra:       btcjson.Int(0),
+				Reverse:        btcjson.Bool(false),
+				FilterAddrs:    nil,
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1133,13 +1587,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5,10],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(5),
-				Count:       btcjson.Int(10),
-				VinExtra:    btcjson.Int(0),
-				Reverse:     btcjson.Bool(false),
-				FilterAddrs: nil,
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(10),
+				VinExtra:       btcjson.Int(0),
+				Reverse:        btcjson.Bool(false),
+				FilterAddrs:    nil,
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1153,13 +1608,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5,10,1],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(5),
-				Count:       btcjson.Int(10),
-				VinExtra:    btcjson.Int(1),
-				Reverse:     btcjson.Bool(false),
-				FilterAddrs: nil,
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(10),
+				VinExtra:       btcjson.Int(1),
+				Reverse:        btcjson.Bool(false),
+				FilterAddrs:    nil,
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1173,13 +1629,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5,10,1,true],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(5),
-				Count:       btcjson.Int(10),
-				VinExtra:    btcjson.Int(1),
-				Reverse:     btcjson.Bool(true),
-				FilterAddrs: nil,
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(10),
+				VinExtra:       btcjson.Int(1),
+				Reverse:        btcjson.Bool(true),
+				FilterAddrs:    nil,
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1193,13 +1650,14 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5,10,1,true,["1Address"]],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(5),
-				Count:       btcjson.Int(10),
-				VinExtra:    btcjson.Int(1),
-				Reverse:     btcjson.Bool(true),
-				FilterAddrs: &[]string{"1Address"},
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(10),
+				VinExtra:       btcjson.Int(1),
+				Reverse:        btcjson.Bool(true),
+				FilterAddrs:    &[]string{"1Address"},
+				IncludeMempool: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1213,13 +1671,39 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5,10,null,true,["1Address"]],"id":1}`,
 			unmarshalled: &btcjson.SearchRawTransactionsCmd{
-				Address:     "1Address",
-				Verbose:     btcjson.Int(0),
-				Skip:        btcjson.Int(5),
-				Count:       btcjson.Int(10),
-				VinExtra:    nil,
-				Reverse:     btcjson.Bool(true),
-				FilterAddrs: &[]string{"1Address"},
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(10),
+				VinExtra:       nil,
+				Reverse:        btcjson.Bool(true),
+				FilterAddrs:    &[]string{"1Address"},
+				IncludeMempool: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "searchrawtransactions",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchrawtransactions", "1Address", 0, 5, 10, 1, true, []string{"1Address"}, false, "0123")
+			},
+			staticCmd: func() interface{} {
+				cmd := btcjson.NewSearchRawTransactionsCmd("1Address",
+					btcjson.Int(0), btcjson.Int(5), btcjson.Int(10), btcjson.Int(1), btcjson.Bool(true), &[]string{"1Address"})
+				cmd.IncludeMempool = btcjson.Bool(false)
+				cmd.After = btcjson.String("0123")
+				return cmd
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactions","params":["1Address",0,5,10,1,true,["1Address"],false,"0123"],"id":1}`,
+			unmarshalled: &btcjson.SearchRawTransactionsCmd{
+				Address:        "1Address",
+				Verbose:        btcjson.Int(0),
+				Skip:           btcjson.Int(5),
+				Count:          btcjson.Int(10),
+				VinExtra:       btcjson.Int(1),
+				Reverse:        btcjson.Bool(true),
+				FilterAddrs:    &[]string{"1Address"},
+				IncludeMempool: btcjson.Bool(false),
+				After:          btcjson.String("0123"),
 			},
 		},
 		{
@@ -1270,6 +1754,41 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd,
+					nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.0/8",
+				Command:  btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.1", "add",
+					1700000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.1", btcjson.SBAdd,
+					btcjson.Int64(1700000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.1","add",1700000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.1",
+				Command:  btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1700000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -1356,6 +1875,17 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "submitheader",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("submitheader", "112233")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSubmitHeaderCmd("112233")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"submitheader","params":["112233"],"id":1}`,
+			unmarshalled: &btcjson.SubmitHeaderCmd{HexData: "112233"},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
@@ -1367,6 +1897,38 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"uptime","params":[],"id":1}`,
 			unmarshalled: &btcjson.UptimeCmd{},
 		},
+		{
+			name: "utxoupdatepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("utxoupdatepsbt", "cHNidP8B")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewUtxoUpdatePsbtCmd("cHNidP8B", nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"utxoupdatepsbt","params":["cHNidP8B"],"id":1}`,
+			unmarshalled: &btcjson.UtxoUpdatePsbtCmd{Psbt: "cHNidP8B"},
+		},
+		{
+			name: "utxoupdatepsbt descriptors",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("utxoupdatepsbt", "cHNidP8B",
+					`["addr(1Address)",{"desc":"raw(51)","range":9}]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewUtxoUpdatePsbtCmd("cHNidP8B", &[]btcjson.ScanObject{
+					{Desc: "addr(1Address)"},
+					{Desc: "raw(51)", Range: &btcjson.DescriptorRange{Value: 9}},
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"utxoupdatepsbt","params":["cHNidP8B",["addr(1Address)",{"desc":"raw(51)","range":9}]],"id":1}`,
+			unmarshalled: &btcjson.UtxoUpdatePsbtCmd{
+				Psbt: "cHNidP8B",
+				Descriptors: &[]btcjson.ScanObject{
+					{Desc: "addr(1Address)"},
+					{Desc: "raw(51)", Range: &btcjson.DescriptorRange{Value: 9}},
+				},
+			},
+		},
 		{
 			name: "validateaddress",
 			newCmd: func() (interface{}, error) {
@@ -1501,6 +2063,37 @@ func TestChainSvrCmds(t *testing.T) {
 				MaxFeeRate: 0.01,
 			},
 		},
+		{
+			name: "submitpackage",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("submitpackage", []string{"parenthex", "childhex"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSubmitPackageCmd([]string{"parenthex", "childhex"}, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"submitpackage","params":[["parenthex","childhex"]],"id":1}`,
+			unmarshalled: &btcjson.SubmitPackageCmd{
+				RawTxns:       []string{"parenthex", "childhex"},
+				MaxFeeRate:    btcjson.Float64(0.10),
+				MaxBurnAmount: btcjson.Float64(0),
+			},
+		},
+		{
+			name: "submitpackage optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("submitpackage", []string{"parenthex", "childhex"}, 0.01, 0.0001)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSubmitPackageCmd([]string{"parenthex", "childhex"},
+					btcjson.Float64(0.01), btcjson.Float64(0.0001))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"submitpackage","params":[["parenthex","childhex"],0.01,0.0001],"id":1}`,
+			unmarshalled: &btcjson.SubmitPackageCmd{
+				RawTxns:       []string{"parenthex", "childhex"},
+				MaxFeeRate:    btcjson.Float64(0.01),
+				MaxBurnAmount: btcjson.Float64(0.0001),
+			},
+		},
 		{
 			name: "gettxspendingprevout",
 			newCmd: func() (interface{}, error) {
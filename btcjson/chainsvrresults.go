@@ -60,9 +60,33 @@ type GetBlockStatsResult struct {
 	TotalOut           int64   `json:"total_out"`
 	TotalSize          int64   `json:"total_size"`
 	TotalWeight        int64   `json:"total_weight"`
+	TotalFee           int64   `json:"totalfee"`
 	Txs                int64   `json:"txs"`
 	UTXOIncrease       int64   `json:"utxo_increase"`
 	UTXOSizeIncrease   int64   `json:"utxo_size_inc"`
+
+	// UTXOIncreaseActual and UTXOSizeIncreaseActual exclude the outputs
+	// which are never added to the utxo set, like unspendable outputs.
+	UTXOIncreaseActual     int64 `json:"utxo_increase_actual"`
+	UTXOSizeIncreaseActual int64 `json:"utxo_size_inc_actual"`
+}
+
+// GetAddressBalanceResult models the data returned from the getaddressbalance
+// command.
+type GetAddressBalanceResult struct {
+	Balance  int64 `json:"balance"`
+	Received int64 `json:"received"`
+}
+
+// GetAddressUtxosResult models an unspent output returned from the
+// getaddressutxos command.
+type GetAddressUtxosResult struct {
+	Address     string `json:"address"`
+	Txid        string `json:"txid"`
+	OutputIndex uint32 `json:"outputIndex"`
+	Script      string `json:"script"`
+	Satoshis    int64  `json:"satoshis"`
+	Height      int32  `json:"height"`
 }
 
 // GetBlockVerboseResult models the data from the getblock command when the
@@ -132,9 +156,9 @@ type GetChainTxStatsResult struct {
 	WindowFinalBlockHash   string  `json:"window_final_block_hash"`
 	WindowFinalBlockHeight int32   `json:"window_final_block_height"`
 	WindowBlockCount       int32   `json:"window_block_count"`
-	WindowTxCount          int32   `json:"window_tx_count"`
-	WindowInterval         int32   `json:"window_interval"`
-	TxRate                 float64 `json:"txrate"`
+	WindowTxCount          int32   `json:"window_tx_count,omitempty"`
+	WindowInterval         int32   `json:"window_interval,omitempty"`
+	TxRate                 float64 `json:"txrate,omitempty"`
 }
 
 // CreateMultiSigResult models the data returned from the createmultisig
@@ -154,6 +178,161 @@ type DecodeScriptResult struct {
 	P2sh      string   `json:"p2sh,omitempty"`
 }
 
+// DebugScriptStep models a step of the execution trace returned by the
+// debugscript command, which is the state of the script engine before an
+// opcode is executed.
+type DebugScriptStep struct {
+	ScriptIndex int      `json:"scriptindex"`
+	OpcodeIndex int      `json:"opcodeindex"`
+	Opcode      string   `json:"opcode,omitempty"`
+	Executing   bool     `json:"executing"`
+	Stack       []string `json:"stack"`
+	AltStack    []string `json:"altstack"`
+}
+
+// DebugScriptResult models the data returned from the debugscript command.
+type DebugScriptResult struct {
+	Valid     bool              `json:"valid"`
+	Error     string            `json:"error,omitempty"`
+	ErrorCode string            `json:"errorcode,omitempty"`
+	Flags     string            `json:"flags"`
+	Steps     []DebugScriptStep `json:"steps"`
+}
+
+// PsbtScriptResult models a redeem or witness script of a PSBT input or output
+// returned by the decodepsbt command.
+type PsbtScriptResult struct {
+	Asm  string `json:"asm"`
+	Hex  string `json:"hex"`
+	Type string `json:"type"`
+}
+
+// PsbtWitnessUtxoResult models the output spent by a segwit PSBT input
+// returned by the decodepsbt command.
+type PsbtWitnessUtxoResult struct {
+	Amount       float64            `json:"amount"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// PsbtBip32DerivResult models the BIP 32 derivation of a public key of a PSBT
+// input or output returned by the decodepsbt command.
+type PsbtBip32DerivResult struct {
+	PubKey            string `json:"pubkey"`
+	MasterFingerprint string `json:"master_fingerprint"`
+	Path              string `json:"path"`
+}
+
+// PsbtTaprootBip32DerivResult models the BIP 32 derivation of an x-only public
+// key of a taproot PSBT input or output along with the hashes of the leaves it
+// is used in returned by the decodepsbt command.
+type PsbtTaprootBip32DerivResult struct {
+	PubKey            string   `json:"pubkey"`
+	MasterFingerprint string   `json:"master_fingerprint"`
+	Path              string   `json:"path"`
+	LeafHashes        []string `json:"leaf_hashes"`
+}
+
+// PsbtTaprootScriptSigResult models a signature for a taproot script path
+// spend of a PSBT input returned by the decodepsbt command.
+type PsbtTaprootScriptSigResult struct {
+	PubKey   string `json:"pubkey"`
+	LeafHash string `json:"leaf_hash"`
+	Sig      string `json:"sig"`
+}
+
+// PsbtTaprootScriptResult models a leaf script of a taproot PSBT input along
+// with the control blocks proving its inclusion returned by the decodepsbt
+// command.
+type PsbtTaprootScriptResult struct {
+	Script        string   `json:"script"`
+	LeafVer       int      `json:"leaf_ver"`
+	ControlBlocks []string `json:"control_blocks"`
+}
+
+// PsbtTaprootLeafResult models a leaf of the taproot tree of a PSBT output
+// returned by the decodepsbt command.
+type PsbtTaprootLeafResult struct {
+	Depth   int    `json:"depth"`
+	LeafVer int    `json:"leaf_ver"`
+	Script  string `json:"script"`
+}
+
+// DecodePsbtInputResult models the data of an input of a PSBT returned by the
+// decodepsbt command.
+type DecodePsbtInputResult struct {
+	NonWitnessUtxo        *TxRawDecodeResult            `json:"non_witness_utxo,omitempty"`
+	WitnessUtxo           *PsbtWitnessUtxoResult        `json:"witness_utxo,omitempty"`
+	PartialSignatures     map[string]string             `json:"partial_signatures,omitempty"`
+	Sighash               string                        `json:"sighash,omitempty"`
+	RedeemScript          *PsbtScriptResult             `json:"redeem_script,omitempty"`
+	WitnessScript         *PsbtScriptResult             `json:"witness_script,omitempty"`
+	Bip32Derivs           []PsbtBip32DerivResult        `json:"bip32_derivs,omitempty"`
+	FinalScriptSig        *ScriptSig                    `json:"final_scriptSig,omitempty"`
+	FinalScriptWitness    []string                      `json:"final_scriptwitness,omitempty"`
+	TaprootKeyPathSig     string                        `json:"taproot_key_path_sig,omitempty"`
+	TaprootScriptPathSigs []PsbtTaprootScriptSigResult  `json:"taproot_script_path_sigs,omitempty"`
+	TaprootScripts        []PsbtTaprootScriptResult     `json:"taproot_scripts,omitempty"`
+	TaprootBip32Derivs    []PsbtTaprootBip32DerivResult `json:"taproot_bip32_derivs,omitempty"`
+	TaprootInternalKey    string                        `json:"taproot_internal_key,omitempty"`
+	TaprootMerkleRoot     string                        `json:"taproot_merkle_root,omitempty"`
+	Unknown               map[string]string             `json:"unknown,omitempty"`
+}
+
+// DecodePsbtOutputResult models the data of an output of a PSBT returned by
+// the decodepsbt command.
+type DecodePsbtOutputResult struct {
+	RedeemScript       *PsbtScriptResult             `json:"redeem_script,omitempty"`
+	WitnessScript      *PsbtScriptResult             `json:"witness_script,omitempty"`
+	Bip32Derivs        []PsbtBip32DerivResult        `json:"bip32_derivs,omitempty"`
+	TaprootInternalKey string                        `json:"taproot_internal_key,omitempty"`
+	TaprootTree        []PsbtTaprootLeafResult       `json:"taproot_tree,omitempty"`
+	TaprootBip32Derivs []PsbtTaprootBip32DerivResult `json:"taproot_bip32_derivs,omitempty"`
+	Unknown            map[string]string             `json:"unknown,omitempty"`
+}
+
+// DecodePsbtResult models the data returned from the decodepsbt command.  The
+// fee is only set when the outputs spent by all inputs are known.
+type DecodePsbtResult struct {
+	Tx          TxRawDecodeResult        `json:"tx"`
+	PsbtVersion uint32                   `json:"psbt_version"`
+	Unknown     map[string]string        `json:"unknown"`
+	Inputs      []DecodePsbtInputResult  `json:"inputs"`
+	Outputs     []DecodePsbtOutputResult `json:"outputs"`
+	Fee         *float64                 `json:"fee,omitempty"`
+}
+
+// AnalyzePsbtMissingResult models the data an input of a PSBT is missing
+// before it can be finalized returned by the analyzepsbt command.  Public keys
+// and signatures are identified by the hash160 of the public key, redeem
+// scripts by their hash160 and witness scripts by their sha256.
+type AnalyzePsbtMissingResult struct {
+	PubKeys       []string `json:"pubkeys,omitempty"`
+	Signatures    []string `json:"signatures,omitempty"`
+	RedeemScript  string   `json:"redeemscript,omitempty"`
+	WitnessScript string   `json:"witnessscript,omitempty"`
+}
+
+// AnalyzePsbtInputResult models the analysis of an input of a PSBT returned by
+// the analyzepsbt command.
+type AnalyzePsbtInputResult struct {
+	HasUtxo bool                      `json:"has_utxo"`
+	IsFinal bool                      `json:"is_final"`
+	Missing *AnalyzePsbtMissingResult `json:"missing,omitempty"`
+	Next    string                    `json:"next,omitempty"`
+}
+
+// AnalyzePsbtResult models the data returned from the analyzepsbt command.
+// The estimated virtual size, fee rate and fee are only set when the outputs
+// spent by all inputs are known.
+type AnalyzePsbtResult struct {
+	Inputs           []AnalyzePsbtInputResult `json:"inputs,omitempty"`
+	EstimatedVSize   *int64                   `json:"estimated_vsize,omitempty"`
+	EstimatedFeeRate *float64                 `json:"estimated_feerate,omitempty"`
+	Fee              *float64                 `json:"fee,omitempty"`
+	Next             string                   `json:"next"`
+	Error            string                   `json:"error,omitempty"`
+}
+
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
 // getaddednodeinfo command.
 type GetAddedNodeInfoResultAddr struct {
@@ -225,22 +404,34 @@ type UnifiedSoftForks struct {
 // GetBlockChainInfoResult models the data returned from the getblockchaininfo
 // command.
 type GetBlockChainInfoResult struct {
-	Chain                string  `json:"chain"`
-	Blocks               int32   `json:"blocks"`
-	Headers              int32   `json:"headers"`
-	BestBlockHash        string  `json:"bestblockhash"`
-	Difficulty           float64 `json:"difficulty"`
-	MedianTime           int64   `json:"mediantime"`
-	VerificationProgress float64 `json:"verificationprogress,omitempty"`
-	InitialBlockDownload bool    `json:"initialblockdownload,omitempty"`
-	Pruned               bool    `json:"pruned"`
-	PruneHeight          int32   `json:"pruneheight,omitempty"`
-	ChainWork            string  `json:"chainwork,omitempty"`
-	SizeOnDisk           int64   `json:"size_on_disk,omitempty"`
+	Chain                string        `json:"chain"`
+	Blocks               int32         `json:"blocks"`
+	Headers              int32         `json:"headers"`
+	BestBlockHash        string        `json:"bestblockhash"`
+	Difficulty           float64       `json:"difficulty"`
+	MedianTime           int64         `json:"mediantime"`
+	VerificationProgress float64       `json:"verificationprogress,omitempty"`
+	InitialBlockDownload bool          `json:"initialblockdownload,omitempty"`
+	Pruned               bool          `json:"pruned"`
+	PruneHeight          int32         `json:"pruneheight,omitempty"`
+	ChainWork            string        `json:"chainwork,omitempty"`
+	SizeOnDisk           int64         `json:"size_on_disk,omitempty"`
+	Finality             *FinalityInfo `json:"finality,omitempty"`
 	*SoftForks
 	*UnifiedSoftForks
 }
 
+// FinalityInfo describes the status of the finality module, which finalizes
+// the blocks a quorum of signers attested to, as part of the getblockchaininfo
+// command.
+type FinalityInfo struct {
+	Signers         int    `json:"signers"`
+	Quorum          int    `json:"quorum"`
+	FinalizedHash   string `json:"finalizedhash,omitempty"`
+	FinalizedHeight int32  `json:"finalizedheight"`
+	Pending         int    `json:"pending"`
+}
+
 // GetBlockFilterResult models the data returned from the getblockfilter
 // command.
 type GetBlockFilterResult struct {
@@ -288,6 +479,14 @@ type GetBlockTemplateResult struct {
 	// Witness commitment defined in BIP 0141.
 	DefaultWitnessCommitment string `json:"default_witness_commitment,omitempty"`
 
+	// Signet challenge defined in BIP 0325.
+	SignetChallenge string `json:"signet_challenge,omitempty"`
+
+	// Version bits rules from BIP 0009.
+	Rules       []string         `json:"rules"`
+	VbAvailable map[string]int32 `json:"vbavailable"`
+	VbRequired  int32            `json:"vbrequired"`
+
 	// Optional long polling from BIP 0022.
 	LongPollID  string `json:"longpollid,omitempty"`
 	LongPollURI string `json:"longpolluri,omitempty"`
@@ -308,9 +507,8 @@ type GetBlockTemplateResult struct {
 	RejectReason string   `json:"reject-reason,omitempty"`
 }
 
-// GetMempoolEntryResult models the data returned from the getmempoolentry's
-// fee field
-
+// MempoolFees models the fees field of the data returned from the
+// getmempoolentry command.
 type MempoolFees struct {
 	Base       float64 `json:"base"`
 	Modified   float64 `json:"modified"`
@@ -318,6 +516,20 @@ type MempoolFees struct {
 	Descendant float64 `json:"descendant"`
 }
 
+// GetIndexInfoResult models the status of an index returned by the
+// getindexinfo command, which returns an object keyed by the index names.
+type GetIndexInfoResult struct {
+	Synced          bool  `json:"synced"`
+	BestBlockHeight int32 `json:"best_block_height"`
+}
+
+// GetSpentInfoResult models the data returned from the getspentinfo command.
+type GetSpentInfoResult struct {
+	Txid   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Height int32  `json:"height"`
+}
+
 // GetMempoolEntryResult models the data returned from the getmempoolentry
 // command.
 type GetMempoolEntryResult struct {
@@ -337,13 +549,62 @@ type GetMempoolEntryResult struct {
 	WTxId           string      `json:"wtxid"`
 	Fees            MempoolFees `json:"fees"`
 	Depends         []string    `json:"depends"`
+
+	SpentBy           []string `json:"spentby"`
+	BIP125Replaceable bool     `json:"bip125-replaceable"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	Usage         int64   `json:"usage"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+	MinRelayTxFee float64 `json:"minrelaytxfee"`
+	Unbroadcast   int64   `json:"unbroadcastcount"`
+}
+
+// GetUnbroadcastResult models a transaction returned by the getunbroadcast
+// command.
+type GetUnbroadcastResult struct {
+	TxID          string `json:"txid"`
+	WTxID         string `json:"wtxid"`
+	Time          int64  `json:"time"`
+	LastAnnounced int64  `json:"lastannounced"`
+	Peers         int    `json:"peers"`
+	Announcements uint32 `json:"announcements"`
+	Requests      uint32 `json:"requests"`
+}
+
+// OrphanPeerResult models the orphans relayed by a peer returned by the
+// getorphaninfo command.
+type OrphanPeerResult struct {
+	ID     uint64 `json:"id"`
+	Size   int    `json:"size"`
+	Weight int64  `json:"weight"`
+}
+
+// OrphanTxResult models an orphan transaction returned by the getorphaninfo
+// command when the verbose flag is set.
+type OrphanTxResult struct {
+	TxID       string `json:"txid"`
+	Weight     int64  `json:"weight"`
+	From       uint64 `json:"from"`
+	Expiration int64  `json:"expiration"`
+}
+
+// GetOrphanInfoResult models the data returned from the getorphaninfo command.
+type GetOrphanInfoResult struct {
+	Size          int                `json:"size"`
+	Weight        int64              `json:"weight"`
+	MaxSize       int                `json:"maxsize"`
+	MaxWeight     int64              `json:"maxweight"`
+	MaxPeerWeight int64              `json:"maxpeerweight"`
+	Expiry        int64              `json:"expiry"`
+	Peers         []OrphanPeerResult `json:"peers"`
+	Orphans       []OrphanTxResult   `json:"orphans,omitempty"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -363,6 +624,16 @@ type LocalAddressesResult struct {
 	Score   int32  `json:"score"`
 }
 
+// PortMappingResult models the portmapping data from the getnetworkinfo
+// command.
+type PortMappingResult struct {
+	Protocol        string `json:"protocol"`
+	ExternalAddress string `json:"externaladdress"`
+	ExternalPort    uint16 `json:"externalport"`
+	InternalPort    uint16 `json:"internalport"`
+	Expires         int64  `json:"expires"`
+}
+
 // GetNetworkInfoResult models the data returned from the getnetworkinfo
 // command.
 type GetNetworkInfoResult struct {
@@ -380,6 +651,7 @@ type GetNetworkInfoResult struct {
 	RelayFee        float64                `json:"relayfee"`
 	IncrementalFee  float64                `json:"incrementalfee"`
 	LocalAddresses  []LocalAddressesResult `json:"localaddresses"`
+	PortMapping     *PortMappingResult     `json:"portmapping,omitempty"`
 	Warnings        string                 `json:"warnings"`
 }
 
@@ -391,6 +663,7 @@ type GetNodeAddressesResult struct {
 	Services uint64 `json:"services"` // The services offered
 	Address  string `json:"address"`  // The address of the node
 	Port     uint16 `json:"port"`     // The port of the node
+	Network  string `json:"network"`  // The network of the address
 }
 
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
@@ -408,6 +681,7 @@ type GetPeerInfoResult struct {
 	TimeOffset     int64   `json:"timeoffset"`
 	PingTime       float64 `json:"pingtime"`
 	PingWait       float64 `json:"pingwait,omitempty"`
+	MinPing        float64 `json:"minping,omitempty"`
 	Version        uint32  `json:"version"`
 	SubVer         string  `json:"subver"`
 	Inbound        bool    `json:"inbound"`
@@ -416,6 +690,52 @@ type GetPeerInfoResult struct {
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+
+	TransportProtocolType string `json:"transport_protocol_type"`
+	SessionID             string `json:"session_id"`
+	Compression           string `json:"compression,omitempty"`
+	ConnectionType        string `json:"connection_type"`
+
+	AddrRelayEnabled bool   `json:"addr_relay_enabled"`
+	AddrProcessed    uint64 `json:"addr_processed"`
+	AddrRateLimited  uint64 `json:"addr_rate_limited"`
+	AddrSent         uint64 `json:"addr_sent"`
+	AddrRecv         uint64 `json:"addr_recv"`
+
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+	MsgsSentPerMsg  map[string]uint64 `json:"msgssent_per_msg"`
+	MsgsRecvPerMsg  map[string]uint64 `json:"msgsrecv_per_msg"`
+
+	BlockDownload *PeerBlockDownloadInfo `json:"blockdownload,omitempty"`
+}
+
+// PeerBlockDownloadInfo describes the blocks downloaded from a peer during the
+// initial block download as part of the getpeerinfo command.
+type PeerBlockDownloadInfo struct {
+	InFlight    int     `json:"inflight"`
+	MaxInFlight int     `json:"maxinflight"`
+	Blocks      uint64  `json:"blocks"`
+	Bytes       uint64  `json:"bytes"`
+	Stalls      int     `json:"stalls"`
+	Latency     float64 `json:"latency"`
+	Throughput  float64 `json:"throughput"`
+	Score       float64 `json:"score"`
+}
+
+// GetPeerReputationResult models the statistics of a peer host returned by the
+// getpeerreputation command.
+type GetPeerReputationResult struct {
+	Host        string  `json:"host"`
+	Score       int64   `json:"score"`
+	Weight      float64 `json:"weight"`
+	Blocks      uint64  `json:"blocks"`
+	Txns        uint64  `json:"txns"`
+	Stalls      uint32  `json:"stalls"`
+	Misbehavior uint32  `json:"misbehavior"`
+	Bans        uint32  `json:"bans"`
+	Connections uint32  `json:"connections"`
+	LastSeen    int64   `json:"lastseen"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -453,16 +773,143 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// GetPendingReorgResult models the data from the getpendingreorg command.
+type GetPendingReorgResult struct {
+	ForkHash     string `json:"forkhash"`
+	ForkHeight   int32  `json:"forkheight"`
+	OldTipHash   string `json:"oldtiphash"`
+	OldTipHeight int32  `json:"oldtipheight"`
+	NewTipHash   string `json:"newtiphash"`
+	NewTipHeight int32  `json:"newtipheight"`
+	Depth        int32  `json:"depth"`
+	Detected     int64  `json:"detected"`
+}
+
+// RPCLatencyBucket models a bucket of an RPC latency histogram.  Count is the
+// number of requests which took longer than the upper bound of the previous
+// bucket and at most UpperBound.
+type RPCLatencyBucket struct {
+	UpperBound string `json:"le"`
+	Count      uint64 `json:"count"`
+}
+
+// RPCMethodStats models the statistics about the requests of an RPC method
+// returned by the getrpcstats command.  Times are in seconds.
+type RPCMethodStats struct {
+	Method    string             `json:"method"`
+	Calls     uint64             `json:"calls"`
+	Errors    uint64             `json:"errors"`
+	SlowCalls uint64             `json:"slowcalls"`
+	TotalTime float64            `json:"totaltime"`
+	MaxTime   float64            `json:"maxtime"`
+	Histogram []RPCLatencyBucket `json:"histogram"`
+}
+
+// GetRPCStatsResult models the data from the getrpcstats command.
+type GetRPCStatsResult struct {
+	SlowThreshold float64          `json:"slowthreshold"`
+	Methods       []RPCMethodStats `json:"methods"`
+}
+
+// RPCActiveCommand models a request which is being processed by the RPC server
+// returned by the getrpcinfo command.
+type RPCActiveCommand struct {
+	Method   string `json:"method"`
+	Duration int64  `json:"duration"` // In microseconds
+}
+
+// GetRPCInfoResult models the data from the getrpcinfo command.
+type GetRPCInfoResult struct {
+	ActiveCommands []RPCActiveCommand `json:"active_commands"`
+	LogPath        string             `json:"logpath"`
+}
+
+// LockedMemoryInfo models the statistics about locked memory returned by the
+// getmemoryinfo command.  They are always zero for btcd, which doesn't lock
+// memory, and are only included for compatibility with Bitcoin Core.
+type LockedMemoryInfo struct {
+	Used       uint64 `json:"used"`
+	Free       uint64 `json:"free"`
+	Total      uint64 `json:"total"`
+	Locked     uint64 `json:"locked"`
+	ChunksUsed uint64 `json:"chunks_used"`
+	ChunksFree uint64 `json:"chunks_free"`
+}
+
+// RuntimeMemoryInfo models the memory statistics of the Go runtime returned by
+// the getmemoryinfo command.  Sizes are in bytes.
+type RuntimeMemoryInfo struct {
+	Sys           uint64  `json:"sys"`
+	HeapAlloc     uint64  `json:"heapalloc"`
+	HeapInuse     uint64  `json:"heapinuse"`
+	HeapIdle      uint64  `json:"heapidle"`
+	HeapReleased  uint64  `json:"heapreleased"`
+	HeapObjects   uint64  `json:"heapobjects"`
+	StackInuse    uint64  `json:"stackinuse"`
+	TotalAlloc    uint64  `json:"totalalloc"`
+	Goroutines    int     `json:"goroutines"`
+	NumGC         uint32  `json:"numgc"`
+	LastGC        int64   `json:"lastgc"`        // Unix time
+	GCPauseTotal  float64 `json:"gcpausetotal"`  // In seconds
+	GCCPUFraction float64 `json:"gccpufraction"` // Between 0 and 1
+}
+
+// CacheMemoryInfo models the memory used by a cache of the node returned by the
+// getmemoryinfo command.  Sizes are in bytes.
+type CacheMemoryInfo struct {
+	Entries int64  `json:"entries"`
+	Used    uint64 `json:"used"`
+	Max     uint64 `json:"max,omitempty"`
+}
+
+// GetMemoryInfoResult models the data from the getmemoryinfo command when the
+// stats mode is requested.
+type GetMemoryInfoResult struct {
+	Locked    LockedMemoryInfo  `json:"locked"`
+	Runtime   RuntimeMemoryInfo `json:"runtime"`
+	UtxoCache CacheMemoryInfo   `json:"utxocache"`
+	DBCache   CacheMemoryInfo   `json:"dbcache"`
+	Mempool   CacheMemoryInfo   `json:"mempool"`
+}
+
 // GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
+//
+// HashSerialized is only set when the hash_serialized_2 hash type was
+// requested and MuHash is only set when the muhash hash type was requested.
+// Transactions and DiskSize are not reported by servers which maintain the
+// statistics without scanning the utxo set.
 type GetTxOutSetInfoResult struct {
-	Height         int64          `json:"height"`
-	BestBlock      chainhash.Hash `json:"bestblock"`
-	Transactions   int64          `json:"transactions"`
-	TxOuts         int64          `json:"txouts"`
-	BogoSize       int64          `json:"bogosize"`
-	HashSerialized chainhash.Hash `json:"hash_serialized_2"`
-	DiskSize       int64          `json:"disk_size"`
-	TotalAmount    btcutil.Amount `json:"total_amount"`
+	Height         int64           `json:"height"`
+	BestBlock      chainhash.Hash  `json:"bestblock"`
+	Transactions   int64           `json:"transactions,omitempty"`
+	TxOuts         int64           `json:"txouts"`
+	BogoSize       int64           `json:"bogosize"`
+	HashSerialized chainhash.Hash  `json:"hash_serialized_2,omitempty"`
+	MuHash         *chainhash.Hash `json:"muhash,omitempty"`
+	DiskSize       int64           `json:"disk_size,omitempty"`
+	TotalAmount    btcutil.Amount  `json:"total_amount"`
+}
+
+// MarshalJSON marshals the result of the gettxoutsetinfo JSON-RPC call in the
+// same format as it is unmarshalled.
+func (g *GetTxOutSetInfoResult) MarshalJSON() ([]byte, error) {
+	type Alias GetTxOutSetInfoResult
+
+	var hashSerialized string
+	if g.HashSerialized != (chainhash.Hash{}) {
+		hashSerialized = g.HashSerialized.String()
+	}
+	return json.Marshal(&struct {
+		BestBlock      string  `json:"bestblock"`
+		HashSerialized string  `json:"hash_serialized_2,omitempty"`
+		TotalAmount    float64 `json:"total_amount"`
+		*Alias
+	}{
+		BestBlock:      g.BestBlock.String(),
+		HashSerialized: hashSerialized,
+		TotalAmount:    g.TotalAmount.ToBTC(),
+		Alias:          (*Alias)(g),
+	})
 }
 
 // UnmarshalJSON unmarshals the result of the gettxoutsetinfo JSON-RPC call
@@ -494,12 +941,14 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 
 	g.BestBlock = *blockHash
 
-	serializedHash, err := chainhash.NewHashFromStr(aux.HashSerialized)
-	if err != nil {
-		return err
-	}
+	if aux.HashSerialized != "" {
+		serializedHash, err := chainhash.NewHashFromStr(aux.HashSerialized)
+		if err != nil {
+			return err
+		}
 
-	g.HashSerialized = *serializedHash
+		g.HashSerialized = *serializedHash
+	}
 
 	amount, err := btcutil.NewAmount(aux.TotalAmount)
 	if err != nil {
@@ -511,11 +960,32 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ListBannedResult models the data of a ban returned by the listbanned command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
+}
+
+// GetNetTotalsUploadTarget models the state of the upload target returned as
+// part of the getnettotals command.
+type GetNetTotalsUploadTarget struct {
+	Timeframe             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv uint64                    `json:"totalbytesrecv"`
+	TotalBytesSent uint64                    `json:"totalbytessent"`
+	TimeMillis     int64                     `json:"timemillis"`
+	UploadTarget   *GetNetTotalsUploadTarget `json:"uploadtarget,omitempty"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -536,6 +1006,10 @@ type Vin struct {
 	ScriptSig *ScriptSig `json:"scriptSig"`
 	Sequence  uint32     `json:"sequence"`
 	Witness   []string   `json:"txinwitness"`
+
+	// PrevOut is the output spent by the input.  It is only set by getblock
+	// with verbosity 3.
+	PrevOut *PrevOutResult `json:"prevout,omitempty"`
 }
 
 // IsCoinBase returns a bool to show if a Vin is a Coinbase one or not.
@@ -566,35 +1040,48 @@ func (v *Vin) MarshalJSON() ([]byte, error) {
 
 	if v.HasWitness() {
 		txStruct := struct {
-			Txid      string     `json:"txid"`
-			Vout      uint32     `json:"vout"`
-			ScriptSig *ScriptSig `json:"scriptSig"`
-			Witness   []string   `json:"txinwitness"`
-			Sequence  uint32     `json:"sequence"`
+			Txid      string         `json:"txid"`
+			Vout      uint32         `json:"vout"`
+			ScriptSig *ScriptSig     `json:"scriptSig"`
+			Witness   []string       `json:"txinwitness"`
+			PrevOut   *PrevOutResult `json:"prevout,omitempty"`
+			Sequence  uint32         `json:"sequence"`
 		}{
 			Txid:      v.Txid,
 			Vout:      v.Vout,
 			ScriptSig: v.ScriptSig,
 			Witness:   v.Witness,
+			PrevOut:   v.PrevOut,
 			Sequence:  v.Sequence,
 		}
 		return json.Marshal(txStruct)
 	}
 
 	txStruct := struct {
-		Txid      string     `json:"txid"`
-		Vout      uint32     `json:"vout"`
-		ScriptSig *ScriptSig `json:"scriptSig"`
-		Sequence  uint32     `json:"sequence"`
+		Txid      string         `json:"txid"`
+		Vout      uint32         `json:"vout"`
+		ScriptSig *ScriptSig     `json:"scriptSig"`
+		PrevOut   *PrevOutResult `json:"prevout,omitempty"`
+		Sequence  uint32         `json:"sequence"`
 	}{
 		Txid:      v.Txid,
 		Vout:      v.Vout,
 		ScriptSig: v.ScriptSig,
+		PrevOut:   v.PrevOut,
 		Sequence:  v.Sequence,
 	}
 	return json.Marshal(txStruct)
 }
 
+// PrevOutResult models the output spent by an input as returned by getblock
+// with verbosity 3.
+type PrevOutResult struct {
+	Generated    bool               `json:"generated"`
+	Height       int32              `json:"height"`
+	Value        float64            `json:"value"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
 // PrevOut represents previous output for an input Vin.
 type PrevOut struct {
 	Addresses []string `json:"addresses,omitempty"`
@@ -735,6 +1222,34 @@ type TxRawResult struct {
 	Blocktime     int64  `json:"blocktime,omitempty"`
 }
 
+// ScanTxOutSetUnspent models an unspent output found by scantxoutset.
+type ScanTxOutSetUnspent struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Coinbase     bool    `json:"coinbase"`
+	Height       int32   `json:"height"`
+}
+
+// ScanTxOutSetResult models the data returned by scantxoutset with the start
+// action.
+type ScanTxOutSetResult struct {
+	Success     bool                  `json:"success"`
+	TxOuts      int64                 `json:"txouts"`
+	Height      int32                 `json:"height"`
+	BestBlock   string                `json:"bestblock"`
+	Unspents    []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount float64               `json:"total_amount"`
+}
+
+// ScanTxOutSetStatusResult models the data returned by scantxoutset with the
+// status action while a scan is in progress.
+type ScanTxOutSetStatusResult struct {
+	Progress float64 `json:"progress"`
+}
+
 // SearchRawTransactionsResult models the data from the searchrawtransaction
 // command.
 type SearchRawTransactionsResult struct {
@@ -833,6 +1348,14 @@ func (f *FundRawTransactionResult) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// FundRawTransactionHexResult models the data returned by the server for the
+// fundrawtransaction command, which FundRawTransactionResult decodes.
+type FundRawTransactionHexResult struct {
+	Hex       string  `json:"hex"`
+	Fee       float64 `json:"fee"`
+	ChangePos int     `json:"changepos"`
+}
+
 // GetDescriptorInfoResult models the data from the getdescriptorinfo command.
 type GetDescriptorInfoResult struct {
 	Descriptor     string `json:"descriptor"`     // descriptor in canonical form, without private keys
@@ -860,7 +1383,7 @@ type DumpWalletResult struct {
 // The result of the mempool acceptance test for each raw transaction in the
 // input array. Returns results for each transaction in the same order they
 // were passed in. Transactions that cannot be fully validated due to failures
-// in other transactions will not contain an 'allowed' result.
+// in other transactions are not allowed and have no reject reason.
 type TestMempoolAcceptResult struct {
 	// Txid is the transaction hash in hex.
 	Txid string `json:"txid"`
@@ -870,12 +1393,11 @@ type TestMempoolAcceptResult struct {
 
 	// PackageError is the package validation error, if any (only possible
 	// if rawtxs had more than 1 transaction).
-	PackageError string `json:"package-error"`
+	PackageError string `json:"package-error,omitempty"`
 
 	// Allowed specifies whether this tx would be accepted to the mempool
-	// and pass client-specified maxfeerate. If not present, the tx was not
-	// fully validated due to a failure in another tx in the list.
-	Allowed bool `json:"allowed,omitempty"`
+	// and pass client-specified maxfeerate.
+	Allowed bool `json:"allowed"`
 
 	// Vsize is the virtual transaction size as defined in BIP 141. This is
 	// different from actual serialized size for witness transactions as
@@ -889,6 +1411,42 @@ type TestMempoolAcceptResult struct {
 	// RejectReason is the rejection string (only present when 'allowed' is
 	// false).
 	RejectReason string `json:"reject-reason,omitempty"`
+
+	// Replacement describes the replacement rule the transaction violated
+	// (only present when it was rejected as a replacement of mempool
+	// transactions).
+	//
+	// NOTE: This is a btcd extension.
+	Replacement *TestMempoolAcceptReplacement `json:"replacement,omitempty"`
+}
+
+// TestMempoolAcceptReplacement models the `replacement` section from the
+// testmempoolaccept command.
+type TestMempoolAcceptReplacement struct {
+	// Rule is the name of the violated replacement rule.
+	Rule string `json:"rule"`
+
+	// BIP125Rule is the number of the violated BIP 125 rule, if the rule
+	// is part of BIP 125.
+	BIP125Rule int32 `json:"bip125-rule,omitempty"`
+
+	// Conflicts are the hashes of the mempool transactions the rule was
+	// violated against.
+	Conflicts []string `json:"conflicts"`
+
+	// Fee is the fee of the replacement in BTC.
+	Fee float64 `json:"fee,omitempty"`
+
+	// FeeRate is the fee rate of the replacement in BTC per kvB.
+	FeeRate float64 `json:"feerate,omitempty"`
+
+	// FeeDelta is the additional fee in BTC the replacement has to pay to
+	// satisfy the absolute fee rules.
+	FeeDelta float64 `json:"fee-delta,omitempty"`
+
+	// FeeRateDelta is the additional fee rate in BTC per kvB the
+	// replacement has to pay to satisfy the fee rate rule.
+	FeeRateDelta float64 `json:"feerate-delta,omitempty"`
 }
 
 // TestMempoolAcceptFees models the `fees` section from the testmempoolaccept
@@ -912,6 +1470,43 @@ type TestMempoolAcceptFees struct {
 	EffectiveIncludes []string `json:"effective-includes"`
 }
 
+// SubmitPackageResult models the data from the submitpackage command.
+type SubmitPackageResult struct {
+	// PackageMsg is "success" when the package was accepted in full and
+	// describes why it wasn't otherwise.
+	PackageMsg string `json:"package_msg"`
+
+	// TxResults are the results of the transactions of the package keyed
+	// by their witness hashes in hex.
+	TxResults map[string]*SubmitPackageTxResult `json:"tx-results"`
+
+	// ReplacedTransactions are the hashes of the transactions which were
+	// replaced by transactions of the package.
+	ReplacedTransactions []string `json:"replaced-transactions"`
+}
+
+// SubmitPackageTxResult models the result of a transaction of a package from
+// the submitpackage command.
+type SubmitPackageTxResult struct {
+	// Txid is the transaction hash in hex.
+	Txid string `json:"txid"`
+
+	// OtherWtxid is the witness hash of a different transaction with the
+	// same hash that is already in the mempool, if any.
+	OtherWtxid string `json:"other-wtxid,omitempty"`
+
+	// Vsize is the virtual transaction size as defined in BIP 141 (only
+	// present when the transaction is in the mempool).
+	Vsize int32 `json:"vsize,omitempty"`
+
+	// Fees specifies the transaction fees (only present when the
+	// transaction is in the mempool).
+	Fees *TestMempoolAcceptFees `json:"fees,omitempty"`
+
+	// Error is the reason the transaction was not accepted, if any.
+	Error string `json:"error,omitempty"`
+}
+
 // GetTxSpendingPrevOutResult defines a single item returned from the
 // gettxspendingprevout command.
 type GetTxSpendingPrevOutResult struct {
@@ -48,6 +48,29 @@ func TestChainSvrCustomResults(t *testing.T) {
 			},
 			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"sequence":4294967295}`,
 		},
+		{
+			name: "custom vin marshal with prevout",
+			result: &btcjson.Vin{
+				Txid: "123",
+				Vout: 1,
+				ScriptSig: &btcjson.ScriptSig{
+					Asm: "0",
+					Hex: "00",
+				},
+				PrevOut: &btcjson.PrevOutResult{
+					Generated: true,
+					Height:    7,
+					Value:     50,
+					ScriptPubKey: btcjson.ScriptPubKeyResult{
+						Asm:  "OP_TRUE",
+						Hex:  "51",
+						Type: "nonstandard",
+					},
+				},
+				Sequence: 4294967295,
+			},
+			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"prevout":{"generated":true,"height":7,"value":50,"scriptPubKey":{"asm":"OP_TRUE","hex":"51","type":"nonstandard"}},"sequence":4294967295}`,
+		},
 		{
 			name: "custom vinprevout marshal with coinbase",
 			result: &btcjson.VinPrevOut{
@@ -148,6 +171,39 @@ func TestGetTxOutSetInfoResult(t *testing.T) {
 						panic(err)
 					}
 
+					return a
+				}(),
+			},
+		},
+		{
+			name:   "GetTxOutSetInfoResult - muhash",
+			result: `{"height":123,"bestblock":"000000000000005f94116250e2407310463c0a7cf950f1af9ebe935b1c0687ab","txouts":1,"bogosize":1,"muhash":"10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863","total_amount":0.2}`,
+			want: btcjson.GetTxOutSetInfoResult{
+				Height: 123,
+				BestBlock: func() chainhash.Hash {
+					h, err := chainhash.NewHashFromStr("000000000000005f94116250e2407310463c0a7cf950f1af9ebe935b1c0687ab")
+					if err != nil {
+						panic(err)
+					}
+
+					return *h
+				}(),
+				TxOuts:   1,
+				BogoSize: 1,
+				MuHash: func() *chainhash.Hash {
+					h, err := chainhash.NewHashFromStr("10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863")
+					if err != nil {
+						panic(err)
+					}
+
+					return h
+				}(),
+				TotalAmount: func() btcutil.Amount {
+					a, err := btcutil.NewAmount(0.2)
+					if err != nil {
+						panic(err)
+					}
+
 					return a
 				}(),
 			},
@@ -170,6 +226,28 @@ func TestGetTxOutSetInfoResult(t *testing.T) {
 				spew.Sdump(test.want))
 			continue
 		}
+
+		// Ensure the result survives a round trip through the custom
+		// marshalling.
+		marshalled, err := json.Marshal(&out)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected marshal error: %v", i,
+				test.name, err)
+			continue
+		}
+		var roundTripped btcjson.GetTxOutSetInfoResult
+		err = json.Unmarshal(marshalled, &roundTripped)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(roundTripped, test.want) {
+			t.Errorf("Test #%d (%s) unexpected round tripped data - "+
+				"got %v, want %v", i, test.name,
+				spew.Sdump(roundTripped), spew.Sdump(test.want))
+			continue
+		}
 	}
 }
 
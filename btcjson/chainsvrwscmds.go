@@ -41,6 +41,75 @@ func NewStopNotifyBlocksCmd() *StopNotifyBlocksCmd {
 	return &StopNotifyBlocksCmd{}
 }
 
+// NotifyChainEventsCmd defines the notifychainevents JSON-RPC command.
+type NotifyChainEventsCmd struct {
+	IncludeBlock *bool `jsonrpcdefault:"false"`
+}
+
+// NewNotifyChainEventsCmd returns a new instance which can be used to issue a
+// notifychainevents JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewNotifyChainEventsCmd(includeBlock *bool) *NotifyChainEventsCmd {
+	return &NotifyChainEventsCmd{
+		IncludeBlock: includeBlock,
+	}
+}
+
+// StopNotifyChainEventsCmd defines the stopnotifychainevents JSON-RPC command.
+type StopNotifyChainEventsCmd struct{}
+
+// NewStopNotifyChainEventsCmd returns a new instance which can be used to issue
+// a stopnotifychainevents JSON-RPC command.
+func NewStopNotifyChainEventsCmd() *StopNotifyChainEventsCmd {
+	return &StopNotifyChainEventsCmd{}
+}
+
+// NotifyFeeHistogramCmd defines the notifyfeehistogram JSON-RPC command.
+type NotifyFeeHistogramCmd struct {
+	Interval *int32 `jsonrpcdefault:"30"`
+}
+
+// NewNotifyFeeHistogramCmd returns a new instance which can be used to issue a
+// notifyfeehistogram JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewNotifyFeeHistogramCmd(interval *int32) *NotifyFeeHistogramCmd {
+	return &NotifyFeeHistogramCmd{
+		Interval: interval,
+	}
+}
+
+// StopNotifyFeeHistogramCmd defines the stopnotifyfeehistogram JSON-RPC
+// command.
+type StopNotifyFeeHistogramCmd struct{}
+
+// NewStopNotifyFeeHistogramCmd returns a new instance which can be used to
+// issue a stopnotifyfeehistogram JSON-RPC command.
+func NewStopNotifyFeeHistogramCmd() *StopNotifyFeeHistogramCmd {
+	return &StopNotifyFeeHistogramCmd{}
+}
+
+// NotifyTxReplacedCmd defines the notifytxreplaced JSON-RPC command.
+type NotifyTxReplacedCmd struct{}
+
+// NewNotifyTxReplacedCmd returns a new instance which can be used to issue a
+// notifytxreplaced JSON-RPC command.
+func NewNotifyTxReplacedCmd() *NotifyTxReplacedCmd {
+	return &NotifyTxReplacedCmd{}
+}
+
+// StopNotifyTxReplacedCmd defines the stopnotifytxreplaced JSON-RPC command.
+type StopNotifyTxReplacedCmd struct{}
+
+// NewStopNotifyTxReplacedCmd returns a new instance which can be used to issue
+// a stopnotifytxreplaced JSON-RPC command.
+func NewStopNotifyTxReplacedCmd() *StopNotifyTxReplacedCmd {
+	return &StopNotifyTxReplacedCmd{}
+}
+
 // NotifyNewTransactionsCmd defines the notifynewtransactions JSON-RPC command.
 type NotifyNewTransactionsCmd struct {
 	Verbose *bool `jsonrpcdefault:"false"`
@@ -228,14 +297,20 @@ func init() {
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("notifychainevents", (*NotifyChainEventsCmd)(nil), flags)
+	MustRegisterCmd("notifyfeehistogram", (*NotifyFeeHistogramCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
+	MustRegisterCmd("notifytxreplaced", (*NotifyTxReplacedCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("stopnotifychainevents", (*StopNotifyChainEventsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifyfeehistogram", (*StopNotifyFeeHistogramCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
+	MustRegisterCmd("stopnotifytxreplaced", (*StopNotifyTxReplacedCmd)(nil), flags)
 	MustRegisterCmd("rescan", (*RescanCmd)(nil), flags)
 	MustRegisterCmd("rescanblocks", (*RescanBlocksCmd)(nil), flags)
 }
@@ -63,6 +63,102 @@ func TestChainSvrWsCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyblocks","params":[],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyBlocksCmd{},
 		},
+		{
+			name: "notifychainevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifychainevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifychainevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{
+				IncludeBlock: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "notifychainevents optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifychainevents", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifychainevents","params":[true],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{
+				IncludeBlock: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "stopnotifychainevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifychainevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyChainEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifychainevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyChainEventsCmd{},
+		},
+		{
+			name: "notifyfeehistogram",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyfeehistogram")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyFeeHistogramCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifyfeehistogram","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyFeeHistogramCmd{
+				Interval: btcjson.Int32(30),
+			},
+		},
+		{
+			name: "notifyfeehistogram optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyfeehistogram", 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyFeeHistogramCmd(btcjson.Int32(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifyfeehistogram","params":[10],"id":1}`,
+			unmarshalled: &btcjson.NotifyFeeHistogramCmd{
+				Interval: btcjson.Int32(10),
+			},
+		},
+		{
+			name: "stopnotifyfeehistogram",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyfeehistogram")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyFeeHistogramCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyfeehistogram","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyFeeHistogramCmd{},
+		},
+		{
+			name: "notifytxreplaced",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifytxreplaced")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyTxReplacedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifytxreplaced","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyTxReplacedCmd{},
+		},
+		{
+			name: "stopnotifytxreplaced",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifytxreplaced")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyTxReplacedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifytxreplaced","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyTxReplacedCmd{},
+		},
 		{
 			name: "notifynewtransactions",
 			newCmd: func() (interface{}, error) {
@@ -75,6 +75,30 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// ChainEventNtfnMethod is the method used for notifications from the
+	// chain server that the main chain has changed.  The notifications
+	// carry the undo data of connected and disconnected blocks and a
+	// sequence number, so they are suitable for driving an external
+	// consensus or execution layer.
+	ChainEventNtfnMethod = "chainevent"
+
+	// ReorgPendingNtfnMethod is the method used for notifications from the
+	// chain server that a chain with more work than the main chain was
+	// found, but switching to it would disconnect more blocks than the
+	// maximum reorganization depth, so it waits for confirmation with the
+	// confirmreorg command.
+	ReorgPendingNtfnMethod = "reorgpending"
+
+	// FeeHistogramNtfnMethod is the method used for the periodic
+	// notifications from the chain server about the distribution of the
+	// fee rates of the transactions in the mempool.
+	FeeHistogramNtfnMethod = "feehistogram"
+
+	// TxReplacedNtfnMethod is the method used for notifications from the
+	// chain server that transactions in the mempool were replaced by a
+	// transaction or package paying a higher fee (RBF).
+	TxReplacedNtfnMethod = "txreplaced"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +309,95 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// ChainEventNtfn defines the chainevent JSON-RPC notification.
+type ChainEventNtfn struct {
+	Event       string
+	Sequence    uint64
+	Hash        string
+	Height      int32
+	Block       string
+	SpentTxOuts []ChainEventSpentTxOut
+}
+
+// NewChainEventNtfn returns a new instance which can be used to issue a
+// chainevent JSON-RPC notification.
+func NewChainEventNtfn(event string, sequence uint64, hash string, height int32,
+	block string, spentTxOuts []ChainEventSpentTxOut) *ChainEventNtfn {
+
+	return &ChainEventNtfn{
+		Event:       event,
+		Sequence:    sequence,
+		Hash:        hash,
+		Height:      height,
+		Block:       block,
+		SpentTxOuts: spentTxOuts,
+	}
+}
+
+// ReorgPendingNtfn defines the reorgpending JSON-RPC notification.
+type ReorgPendingNtfn struct {
+	ForkHash     string
+	ForkHeight   int32
+	OldTipHash   string
+	OldTipHeight int32
+	NewTipHash   string
+	NewTipHeight int32
+	Depth        int32
+}
+
+// NewReorgPendingNtfn returns a new instance which can be used to issue a
+// reorgpending JSON-RPC notification.
+func NewReorgPendingNtfn(forkHash string, forkHeight int32, oldTipHash string,
+	oldTipHeight int32, newTipHash string, newTipHeight int32,
+	depth int32) *ReorgPendingNtfn {
+
+	return &ReorgPendingNtfn{
+		ForkHash:     forkHash,
+		ForkHeight:   forkHeight,
+		OldTipHash:   oldTipHash,
+		OldTipHeight: oldTipHeight,
+		NewTipHash:   newTipHash,
+		NewTipHeight: newTipHeight,
+		Depth:        depth,
+	}
+}
+
+// FeeHistogramNtfn defines the feehistogram JSON-RPC notification.
+type FeeHistogramNtfn struct {
+	Time    int64
+	Buckets []FeeHistogramBucket
+}
+
+// NewFeeHistogramNtfn returns a new instance which can be used to issue a
+// feehistogram JSON-RPC notification.
+func NewFeeHistogramNtfn(time int64, buckets []FeeHistogramBucket) *FeeHistogramNtfn {
+	return &FeeHistogramNtfn{
+		Time:    time,
+		Buckets: buckets,
+	}
+}
+
+// TxReplacedNtfn defines the txreplaced JSON-RPC notification.
+type TxReplacedNtfn struct {
+	ReplacedTxIDs    []string
+	ReplacementTxIDs []string
+	ReplacedFees     float64
+	ReplacementFees  float64
+}
+
+// NewTxReplacedNtfn returns a new instance which can be used to issue a
+// txreplaced JSON-RPC notification.
+func NewTxReplacedNtfn(replacedTxIDs, replacementTxIDs []string, replacedFees,
+	replacementFees float64) *TxReplacedNtfn {
+
+	return &TxReplacedNtfn{
+		ReplacedTxIDs:    replacedTxIDs,
+		ReplacementTxIDs: replacementTxIDs,
+		ReplacedFees:     replacedFees,
+		ReplacementFees:  replacementFees,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +414,8 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(ChainEventNtfnMethod, (*ChainEventNtfn)(nil), flags)
+	MustRegisterCmd(ReorgPendingNtfnMethod, (*ReorgPendingNtfn)(nil), flags)
+	MustRegisterCmd(FeeHistogramNtfnMethod, (*FeeHistogramNtfn)(nil), flags)
+	MustRegisterCmd(TxReplacedNtfnMethod, (*TxReplacedNtfn)(nil), flags)
 }
@@ -225,6 +225,95 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "chainevent",
+			newNtfn: func() (interface{}, error) {
+				stxos := []btcjson.ChainEventSpentTxOut{
+					{Amount: 5000, PkScript: "51", Height: 99, CoinBase: true},
+				}
+				return btcjson.NewCmd("chainevent", "connected", 7, "123",
+					100000, "", stxos)
+			},
+			staticNtfn: func() interface{} {
+				stxos := []btcjson.ChainEventSpentTxOut{
+					{Amount: 5000, PkScript: "51", Height: 99, CoinBase: true},
+				}
+				return btcjson.NewChainEventNtfn("connected", 7, "123",
+					100000, "", stxos)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"chainevent","params":["connected",7,"123",100000,"",[{"amount":5000,"pkscript":"51","height":99,"coinbase":true}]],"id":null}`,
+			unmarshalled: &btcjson.ChainEventNtfn{
+				Event:    "connected",
+				Sequence: 7,
+				Hash:     "123",
+				Height:   100000,
+				Block:    "",
+				SpentTxOuts: []btcjson.ChainEventSpentTxOut{
+					{Amount: 5000, PkScript: "51", Height: 99, CoinBase: true},
+				},
+			},
+		},
+		{
+			name: "reorgpending",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("reorgpending", "123", 100, "456",
+					110, "789", 111, 10)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewReorgPendingNtfn("123", 100, "456",
+					110, "789", 111, 10)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"reorgpending","params":["123",100,"456",110,"789",111,10],"id":null}`,
+			unmarshalled: &btcjson.ReorgPendingNtfn{
+				ForkHash:     "123",
+				ForkHeight:   100,
+				OldTipHash:   "456",
+				OldTipHeight: 110,
+				NewTipHash:   "789",
+				NewTipHeight: 111,
+				Depth:        10,
+			},
+		},
+		{
+			name: "feehistogram",
+			newNtfn: func() (interface{}, error) {
+				buckets := []btcjson.FeeHistogramBucket{
+					{FeeRate: 5, Count: 2, VSize: 300, Fees: 0.00002},
+				}
+				return btcjson.NewCmd("feehistogram", 1700000000, buckets)
+			},
+			staticNtfn: func() interface{} {
+				buckets := []btcjson.FeeHistogramBucket{
+					{FeeRate: 5, Count: 2, VSize: 300, Fees: 0.00002},
+				}
+				return btcjson.NewFeeHistogramNtfn(1700000000, buckets)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"feehistogram","params":[1700000000,[{"feerate":5,"count":2,"vsize":300,"fees":0.00002}]],"id":null}`,
+			unmarshalled: &btcjson.FeeHistogramNtfn{
+				Time: 1700000000,
+				Buckets: []btcjson.FeeHistogramBucket{
+					{FeeRate: 5, Count: 2, VSize: 300, Fees: 0.00002},
+				},
+			},
+		},
+		{
+			name: "txreplaced",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("txreplaced", []string{"123"},
+					[]string{"456"}, 0.0001, 0.0002)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewTxReplacedNtfn([]string{"123"},
+					[]string{"456"}, 0.0001, 0.0002)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"txreplaced","params":[["123"],["456"],0.0001,0.0002],"id":null}`,
+			unmarshalled: &btcjson.TxReplacedNtfn{
+				ReplacedTxIDs:    []string{"123"},
+				ReplacementTxIDs: []string{"456"},
+				ReplacedFees:     0.0001,
+				ReplacementFees:  0.0002,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
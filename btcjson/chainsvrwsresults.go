@@ -19,3 +19,22 @@ type RescannedBlock struct {
 	Hash         string   `json:"hash"`
 	Transactions []string `json:"transactions"`
 }
+
+// ChainEventSpentTxOut models an output spent by a block as part of the undo
+// data sent with the chainevent notification.
+type ChainEventSpentTxOut struct {
+	Amount   int64  `json:"amount"`
+	PkScript string `json:"pkscript"`
+	Height   int32  `json:"height"`
+	CoinBase bool   `json:"coinbase"`
+}
+
+// FeeHistogramBucket models a range of fee rates of the feehistogram
+// notification along with the transactions of the mempool paying a fee rate in
+// the range.
+type FeeHistogramBucket struct {
+	FeeRate float64 `json:"feerate"`
+	Count   int64   `json:"count"`
+	VSize   int64   `json:"vsize"`
+	Fees    float64 `json:"fees"`
+}
@@ -81,7 +81,7 @@ func subStructUsage(structType reflect.Type) string {
 		case fieldKind == reflect.String:
 			fieldValue = `"value"`
 
-		case fieldKind == reflect.Struct:
+		case fieldKind == reflect.Struct && !isMarshaledValue(rtf.Type):
 			fieldValue = subStructUsage(rtf.Type)
 
 		case fieldKind == reflect.Array || fieldKind == reflect.Slice:
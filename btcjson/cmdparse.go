@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -554,3 +555,107 @@ func NewCmd(method string, args ...interface{}) (interface{}, error) {
 
 	return rvp.Interface(), nil
 }
+
+// paramIndex returns the index of the struct field of the passed command type
+// which the passed parameter name identifies, or -1 when it doesn't identify
+// any.  A parameter is identified by the lowercase name of its field, as shown
+// in the one-line usage of the command, or by the name in the 'jsonrpcname'
+// struct tag of its field, which is used for parameters known by a different
+// name to clients of Bitcoin Core.
+func paramIndex(rt reflect.Type, name string) int {
+	for i := 0; i < rt.NumField(); i++ {
+		rtf := rt.Field(i)
+		if name == strings.ToLower(rtf.Name) {
+			return i
+		}
+		if tag := rtf.Tag.Get("jsonrpcname"); tag != "" && name == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewCmdNamed provides a generic mechanism to create a new command that can
+// marshal to a JSON-RPC request from parameters identified by name rather than
+// by position.  Each parameter is identified either by the lowercase name of
+// its struct field, as shown by MethodUsageText, or by the name in the
+// 'jsonrpcname' struct tag of the field when it has one.  The arguments are
+// converted the same way as those of NewCmd.
+//
+// Optional parameters may be skipped.  Those which precede a specified
+// parameter and have a default value are set to it, since positional params
+// can't be omitted in the marshalled request.
+func NewCmdNamed(method string, args map[string]interface{}) (interface{}, error) {
+	// Look up details about the provided method.  Any methods that aren't
+	// registered are an error.
+	registerLock.RLock()
+	rtp, ok := methodToConcreteType[method]
+	info := methodToInfo[method]
+	registerLock.RUnlock()
+	if !ok {
+		str := fmt.Sprintf("%q is not registered", method)
+		return nil, makeError(ErrUnregisteredMethod, str)
+	}
+
+	// Create the appropriate command type for the method.  Since all types
+	// are enforced to be a pointer to a struct at registration time, it's
+	// safe to indirect to the struct now.
+	rvp := reflect.New(rtp.Elem())
+	rv := rvp.Elem()
+	rt := rtp.Elem()
+
+	// Assign each of the arguments to the struct field it identifies in a
+	// stable order so errors are deterministic.
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assigned := make([]bool, rt.NumField())
+	lastParam := -1
+	for _, name := range names {
+		i := paramIndex(rt, name)
+		if i < 0 {
+			str := fmt.Sprintf("unknown parameter '%s'", name)
+			return nil, makeError(ErrInvalidParamName, str)
+		}
+		fieldName := strings.ToLower(rt.Field(i).Name)
+		if assigned[i] {
+			str := fmt.Sprintf("parameter '%s' specified more than "+
+				"once", fieldName)
+			return nil, makeError(ErrInvalidParamName, str)
+		}
+		err := assignField(i+1, fieldName, rv.Field(i),
+			reflect.ValueOf(args[name]))
+		if err != nil {
+			return nil, err
+		}
+		assigned[i] = true
+		if i > lastParam {
+			lastParam = i
+		}
+	}
+
+	// Ensure all of the required parameters were specified.
+	for i := 0; i < info.numReqParams; i++ {
+		if !assigned[i] {
+			str := fmt.Sprintf("missing required parameter '%s'",
+				strings.ToLower(rt.Field(i).Name))
+			return nil, makeError(ErrNumParams, str)
+		}
+	}
+
+	// Populate the default values of the skipped optional parameters which
+	// precede a specified one.  The remaining ones are omitted from the
+	// marshalled request, so the server applies their defaults.
+	for i := info.numReqParams; i < lastParam; i++ {
+		if assigned[i] {
+			continue
+		}
+		if defaultVal, ok := info.defaults[i]; ok {
+			rv.Field(i).Set(defaultVal)
+		}
+	}
+
+	return rvp.Interface(), nil
+}
@@ -422,6 +422,124 @@ func TestNewCmdErrors(t *testing.T) {
 	}
 }
 
+// TestNewCmdNamed tests the NewCmdNamed function creates commands from named
+// parameters and fills in the defaults of skipped optional parameters.
+func TestNewCmdNamed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		method   string
+		args     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "field names",
+			method:   "getblock",
+			args:     map[string]interface{}{"hash": "123", "verbosity": "2"},
+			expected: `{"jsonrpc":"1.0","method":"getblock","params":["123",2],"id":1}`,
+		},
+		{
+			name:     "bitcoin core names",
+			method:   "getblock",
+			args:     map[string]interface{}{"blockhash": "123"},
+			expected: `{"jsonrpc":"1.0","method":"getblock","params":["123"],"id":1}`,
+		},
+		{
+			name:     "skipped optional parameter with default",
+			method:   "getnetworkhashps",
+			args:     map[string]interface{}{"height": "2000"},
+			expected: `{"jsonrpc":"1.0","method":"getnetworkhashps","params":[120,2000],"id":1}`,
+		},
+		{
+			name:     "no parameters",
+			method:   "getnetworkhashps",
+			args:     nil,
+			expected: `{"jsonrpc":"1.0","method":"getnetworkhashps","params":[],"id":1}`,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		cmd, err := btcjson.NewCmdNamed(test.method, test.args)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		bytes, err := btcjson.MarshalCmd(btcjson.RpcVersion1, 1, cmd)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected marshal error: %v",
+				i, test.name, err)
+			continue
+		}
+		if string(bytes) != test.expected {
+			t.Errorf("Test #%d (%s) mismatched marshall result - got "+
+				"%s, want %s", i, test.name, bytes, test.expected)
+		}
+	}
+}
+
+// TestNewCmdNamedErrors ensures any errors that occur in the command during
+// named parameter creation are caught.
+func TestNewCmdNamedErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		method string
+		args   map[string]interface{}
+		err    btcjson.Error
+	}{
+		{
+			name:   "unregistered command",
+			method: "boguscommand",
+			err:    btcjson.Error{ErrorCode: btcjson.ErrUnregisteredMethod},
+		},
+		{
+			name:   "missing required parameter",
+			method: "getblock",
+			args:   map[string]interface{}{"verbosity": "2"},
+			err:    btcjson.Error{ErrorCode: btcjson.ErrNumParams},
+		},
+		{
+			name:   "unknown parameter",
+			method: "getblock",
+			args:   map[string]interface{}{"hash": "123", "verbose": "true"},
+			err:    btcjson.Error{ErrorCode: btcjson.ErrInvalidParamName},
+		},
+		{
+			name:   "parameter specified twice",
+			method: "getblock",
+			args:   map[string]interface{}{"hash": "123", "blockhash": "123"},
+			err:    btcjson.Error{ErrorCode: btcjson.ErrInvalidParamName},
+		},
+		{
+			name:   "incorrect parameter type",
+			method: "getblock",
+			args:   map[string]interface{}{"hash": "123", "verbosity": "high"},
+			err:    btcjson.Error{ErrorCode: btcjson.ErrInvalidType},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := btcjson.NewCmdNamed(test.method, test.args)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("Test #%d (%s) wrong error - got %T (%v), "+
+				"want %T", i, test.name, err, err, test.err)
+			continue
+		}
+		gotErrorCode := err.(btcjson.Error).ErrorCode
+		if gotErrorCode != test.err.ErrorCode {
+			t.Errorf("Test #%d (%s) mismatched error code - got "+
+				"%v (%v), want %v", i, test.name, gotErrorCode,
+				err, test.err.ErrorCode)
+			continue
+		}
+	}
+}
+
 // TestMarshalCmd tests the MarshalCmd function.
 func TestMarshalCmd(t *testing.T) {
 	t.Parallel()
@@ -93,6 +93,9 @@ obviously, run-time which means any mistakes won't be found until the code is
 actually executed.  However, it is quite useful for user-supplied commands
 that are intentionally dynamic.
 
+The NewCmdNamed function is a variant of NewCmd which takes the parameters by
+name rather than by position, so optional parameters may be skipped.
+
 # Custom Command Registration
 
 The command handling of this package is built around the concept of registered
@@ -62,6 +62,11 @@ const (
 	// match the requirements of the associated command.
 	ErrNumParams
 
+	// ErrInvalidParamName indicates a parameter was specified by a name
+	// which doesn't identify a parameter of the associated command, or
+	// more than once.
+	ErrInvalidParamName
+
 	// numErrorCodes is the maximum error code number used in tests.
 	numErrorCodes
 )
@@ -80,6 +85,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrUnregisteredMethod:   "ErrUnregisteredMethod",
 	ErrMissingDescription:   "ErrMissingDescription",
 	ErrNumParams:            "ErrNumParams",
+	ErrInvalidParamName:     "ErrInvalidParamName",
 }
 
 // String returns the ErrorCode as a human-readable name.
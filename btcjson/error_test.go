@@ -30,6 +30,7 @@ func TestErrorCodeStringer(t *testing.T) {
 		{btcjson.ErrUnregisteredMethod, "ErrUnregisteredMethod"},
 		{btcjson.ErrNumParams, "ErrNumParams"},
 		{btcjson.ErrMissingDescription, "ErrMissingDescription"},
+		{btcjson.ErrInvalidParamName, "ErrInvalidParamName"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -6,6 +6,7 @@ package btcjson
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -44,9 +45,32 @@ var baseHelpDescs = map[string]string{
 // a key.
 type descLookupFunc func(string) string
 
+// jsonMarshalerType is the reflect type of the json.Marshaler interface.
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// isMarshaledString returns whether or not the provided Go type is a fixed-size
+// array that marshals itself, such as a hash, which are marshaled as strings.
+func isMarshaledString(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Array && rt.Implements(jsonMarshalerType)
+}
+
+// isMarshaledValue returns whether or not the provided Go type is a struct that
+// marshals itself, such as HashOrHeight, which are marshaled as plain values
+// instead of JSON objects.
+func isMarshaledValue(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Struct && rt.Implements(jsonMarshalerType)
+}
+
 // reflectTypeToJSONType returns a string that represents the JSON type
 // associated with the provided Go type.
 func reflectTypeToJSONType(xT descLookupFunc, rt reflect.Type) string {
+	if isMarshaledString(rt) {
+		return xT("json-type-string")
+	}
+	if isMarshaledValue(rt) {
+		return xT("json-type-value")
+	}
+
 	kind := rt.Kind()
 	if isNumeric(kind) {
 		return xT("json-type-numeric")
@@ -145,6 +169,12 @@ func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel in
 	if rt.Kind() == reflect.Ptr {
 		rt = rt.Elem()
 	}
+	if isMarshaledString(rt) {
+		return []string{`"` + xT("json-example-string") + `"`}, false
+	}
+	if isMarshaledValue(rt) {
+		return []string{xT("json-example-string")}, false
+	}
 	kind := rt.Kind()
 	if isNumeric(kind) {
 		if kind == reflect.Float32 || kind == reflect.Float64 {
@@ -300,7 +330,11 @@ func argTypeHelp(xT descLookupFunc, structField reflect.StructField, defaultVal
 
 	// Convert the field type to a JSON type.
 	details := make([]string, 0, 3)
-	details = append(details, reflectTypeToJSONType(xT, fieldType))
+	if isMarshaledValue(fieldType) {
+		details = append(details, xT("json-type-value"))
+	} else {
+		details = append(details, reflectTypeToJSONType(xT, fieldType))
+	}
 
 	// Add optional and default value to the details if needed.
 	if isOptional {
@@ -359,6 +393,9 @@ func argHelp(xT descLookupFunc, rtp reflect.Type, defaults map[int]reflect.Value
 		kind := fieldType.Kind()
 		switch kind {
 		case reflect.Struct:
+			if isMarshaledValue(fieldType) {
+				break
+			}
 			fieldDescKey := fmt.Sprintf("%s-%s", method, fieldName)
 			resultText := resultTypeHelp(xT, fieldType, fieldDescKey)
 			args = append(args, resultText)
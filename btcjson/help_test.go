@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
 // TestHelpReflectInternals ensures the various help functions which deal with
@@ -139,6 +140,13 @@ func TestHelpReflectInternals(t *testing.T) {
 			examples:    []string{"[n,...]"},
 			help:        "[n,...] (json-type-arrayjson-type-numeric) fdk",
 		},
+		{
+			name:        "hash",
+			reflectType: reflect.TypeOf(chainhash.Hash{}),
+			key:         "json-type-string",
+			examples:    []string{`"json-example-string"`},
+			help:        "\"json-example-string\" (json-type-string) fdk",
+		},
 		{
 			name:        "slice of int",
 			reflectType: reflect.TypeOf([]int{0}),
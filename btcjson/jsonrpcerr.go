@@ -186,4 +186,12 @@ const (
 const (
 	ErrRPCNoWallet      RPCErrorCode = -1
 	ErrRPCUnimplemented RPCErrorCode = -1
+
+	// ErrRPCMethodDisabled indicates the method was disabled in the
+	// configuration of the server.
+	ErrRPCMethodDisabled RPCErrorCode = -32001
+
+	// ErrRPCLimited indicates the client called the method more often than
+	// the rate limit configured for it on the server allows.
+	ErrRPCLimited RPCErrorCode = -32002
 )
@@ -1002,6 +1002,60 @@ func NewImportMultiCmd(requests []ImportMultiRequest, options *ImportMultiOption
 	}
 }
 
+// ImportDescriptorsRequest defines the request struct to be passed to the
+// ImportDescriptorsCmd, as an array.
+type ImportDescriptorsRequest struct {
+	// Descriptor to import, with or without its checksum.
+	Descriptor string `json:"desc"`
+
+	// Set this descriptor to be the active descriptor for the corresponding
+	// output type.
+	Active *bool `json:"active,omitempty"`
+
+	// If the provided Descriptor is ranged, this specifies the end
+	// (as an int) or the range (as []int{begin, end}) to import.
+	Range *DescriptorRange `json:"range,omitempty"`
+
+	// If the provided Descriptor is ranged, this specifies the next index
+	// to generate addresses from.
+	NextIndex *int `json:"next_index,omitempty"`
+
+	// Creation time of the descriptor in seconds since epoch (Jan 1 1970
+	// GMT), or the string "now" to indicate that it has never been used.
+	//
+	// Specifying "now" bypasses scanning.
+	Timestamp TimestampOrNow `json:"timestamp"`
+
+	// States whether matching outputs should be treated as not incoming
+	// payments (also known as change).
+	Internal *bool `json:"internal,omitempty"`
+
+	// Label to assign to the address. Only allowed when Internal is false.
+	Label *string `json:"label,omitempty"`
+}
+
+// ImportDescriptorsCmd defines the importdescriptors JSON-RPC command.
+type ImportDescriptorsCmd struct {
+	Requests []ImportDescriptorsRequest
+}
+
+// NewImportDescriptorsCmd returns a new instance which can be used to issue
+// an importdescriptors JSON-RPC command.
+func NewImportDescriptorsCmd(requests []ImportDescriptorsRequest) *ImportDescriptorsCmd {
+	return &ImportDescriptorsCmd{
+		Requests: requests,
+	}
+}
+
+// ListDescriptorsCmd defines the listdescriptors JSON-RPC command.
+type ListDescriptorsCmd struct{}
+
+// NewListDescriptorsCmd returns a new instance which can be used to issue a
+// listdescriptors JSON-RPC command.
+func NewListDescriptorsCmd() *ListDescriptorsCmd {
+	return &ListDescriptorsCmd{}
+}
+
 // PsbtInput represents an input to include in the PSBT created by the
 // WalletCreateFundedPsbtCmd command.
 type PsbtInput struct {
@@ -1110,11 +1164,13 @@ func init() {
 	MustRegisterCmd("getreceivedbyaddress", (*GetReceivedByAddressCmd)(nil), flags)
 	MustRegisterCmd("gettransaction", (*GetTransactionCmd)(nil), flags)
 	MustRegisterCmd("getwalletinfo", (*GetWalletInfoCmd)(nil), flags)
+	MustRegisterCmd("importdescriptors", (*ImportDescriptorsCmd)(nil), flags)
 	MustRegisterCmd("importmulti", (*ImportMultiCmd)(nil), flags)
 	MustRegisterCmd("importprivkey", (*ImportPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("keypoolrefill", (*KeyPoolRefillCmd)(nil), flags)
 	MustRegisterCmd("listaccounts", (*ListAccountsCmd)(nil), flags)
 	MustRegisterCmd("listaddressgroupings", (*ListAddressGroupingsCmd)(nil), flags)
+	MustRegisterCmd("listdescriptors", (*ListDescriptorsCmd)(nil), flags)
 	MustRegisterCmd("listlockunspent", (*ListLockUnspentCmd)(nil), flags)
 	MustRegisterCmd("listreceivedbyaccount", (*ListReceivedByAccountCmd)(nil), flags)
 	MustRegisterCmd("listreceivedbyaddress", (*ListReceivedByAddressCmd)(nil), flags)
@@ -660,6 +660,17 @@ func TestWalletSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"listaddressgroupings","params":[],"id":1}`,
 			unmarshalled: &btcjson.ListAddressGroupingsCmd{},
 		},
+		{
+			name: "listdescriptors",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listdescriptors")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListDescriptorsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listdescriptors","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListDescriptorsCmd{},
+		},
 		{
 			name: "listlockunspent",
 			newCmd: func() (interface{}, error) {
@@ -1574,6 +1585,51 @@ func TestWalletSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "importdescriptors",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd(
+					"importdescriptors",
+					// Cannot use a native string, due to special types like timestamp.
+					[]btcjson.ImportDescriptorsRequest{
+						{
+							Descriptor: "123",
+							Active:     btcjson.Bool(true),
+							Range:      &btcjson.DescriptorRange{Value: []int{0, 100}},
+							NextIndex:  btcjson.Int(5),
+							Timestamp:  btcjson.TimestampOrNow{Value: "now"},
+							Internal:   btcjson.Bool(true),
+						},
+					},
+				)
+			},
+			staticCmd: func() interface{} {
+				requests := []btcjson.ImportDescriptorsRequest{
+					{
+						Descriptor: "123",
+						Active:     btcjson.Bool(true),
+						Range:      &btcjson.DescriptorRange{Value: []int{0, 100}},
+						NextIndex:  btcjson.Int(5),
+						Timestamp:  btcjson.TimestampOrNow{Value: "now"},
+						Internal:   btcjson.Bool(true),
+					},
+				}
+				return btcjson.NewImportDescriptorsCmd(requests)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importdescriptors","params":[[{"desc":"123","active":true,"range":[0,100],"next_index":5,"timestamp":"now","internal":true}]],"id":1}`,
+			unmarshalled: &btcjson.ImportDescriptorsCmd{
+				Requests: []btcjson.ImportDescriptorsRequest{
+					{
+						Descriptor: "123",
+						Active:     btcjson.Bool(true),
+						Range:      &btcjson.DescriptorRange{Value: []int{0, 100}},
+						NextIndex:  btcjson.Int(5),
+						Timestamp:  btcjson.TimestampOrNow{Value: "now"},
+						Internal:   btcjson.Bool(true),
+					},
+				},
+			},
+		},
 		{
 			name: "importmulti with descriptor + string timestamp",
 			newCmd: func() (interface{}, error) {
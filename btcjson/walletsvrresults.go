@@ -370,6 +370,31 @@ type ImportMultiResults []struct {
 	Warnings *[]string `json:"warnings,omitempty"`
 }
 
+// ImportDescriptorsResult models the result of a single request of the
+// importdescriptors command.
+type ImportDescriptorsResult struct {
+	Success  bool      `json:"success"`
+	Warnings []string  `json:"warnings,omitempty"`
+	Error    *RPCError `json:"error,omitempty"`
+}
+
+// ListDescriptorsDescriptor models a descriptor returned by the
+// listdescriptors command.
+type ListDescriptorsDescriptor struct {
+	Desc      string  `json:"desc"`
+	Timestamp int64   `json:"timestamp"`
+	Active    bool    `json:"active"`
+	Internal  bool    `json:"internal"`
+	Range     *[2]int `json:"range,omitempty"`
+	Next      *int    `json:"next,omitempty"`
+}
+
+// ListDescriptorsResult models the data returned from the listdescriptors
+// command.
+type ListDescriptorsResult struct {
+	Descriptors []ListDescriptorsDescriptor `json:"descriptors"`
+}
+
 // WalletCreateFundedPsbtResult models the data returned from the
 // walletcreatefundedpsbtresult command.
 type WalletCreateFundedPsbtResult struct {
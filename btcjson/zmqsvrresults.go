@@ -5,6 +5,14 @@ import (
 	"net/url"
 )
 
+// ZmqNotification models a notification of the result of the
+// getzmqnotifications command as it is marshalled by GetZmqNotificationResult.
+type ZmqNotification struct {
+	Type          string `json:"type"`
+	Address       string `json:"address"`
+	HighWaterMark int    `json:"hwm"`
+}
+
 // GetZmqNotificationResult models the data returned from the getzmqnotifications command.
 type GetZmqNotificationResult []struct {
 	Type          string   // Type of notification
@@ -13,7 +21,9 @@ type GetZmqNotificationResult []struct {
 }
 
 func (z *GetZmqNotificationResult) MarshalJSON() ([]byte, error) {
-	var out []map[string]interface{}
+	// Marshal no notifications as an empty array rather than null like
+	// Bitcoin Core.
+	out := make([]map[string]interface{}, 0, len(*z))
 	for _, notif := range *z {
 		out = append(out,
 			map[string]interface{}{
@@ -0,0 +1,296 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Combiner merges multiple PSBTs for the same transaction, such as the
+// ones returned by different signers, into a single one holding the union of
+// their data.
+
+import (
+	"bytes"
+)
+
+// Combine returns a new packet holding the union of the data of the passed
+// packets, which serves the role of the Combiner as specified in BIP174 and
+// BIP370.  All of the packets must have the same version and describe the
+// same transaction, so they must have the same unique identifier and, for
+// version 2 packets, the same sequence numbers.  When a field is present in
+// more than one packet with different values, the value of the first packet
+// it is present in is kept.
+//
+// The inputs and outputs of version 2 packets remain modifiable only if they
+// are modifiable in all of the packets, since any of them may hold
+// signatures committing to them.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	// Work on copies of the packets, so that the combined packet doesn't
+	// share any data with the passed ones.
+	combined, err := copyPacket(packets[0])
+	if err != nil {
+		return nil, err
+	}
+	uniqueID := combined.UniqueID()
+
+	for _, packet := range packets[1:] {
+		if packet.Version != combined.Version ||
+			packet.UniqueID() != uniqueID {
+
+			return nil, ErrMismatchedPsbts
+		}
+
+		other, err := copyPacket(packet)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, txIn := range other.UnsignedTx.TxIn {
+			if txIn.Sequence != combined.UnsignedTx.TxIn[i].Sequence {
+				return nil, ErrMismatchedPsbts
+			}
+		}
+
+		// Inputs and outputs remain modifiable only if they are in both
+		// packets, while a SIGHASH_SINGLE signature in either packet is
+		// kept.
+		both := combined.TxModifiable & other.TxModifiable
+		either := combined.TxModifiable | other.TxModifiable
+		combined.TxModifiable = both&TxModifiableInputs |
+			both&TxModifiableOutputs |
+			either&TxModifiableSighashSingle
+
+		combined.Unknowns = combineUnknowns(
+			combined.Unknowns, other.Unknowns,
+		)
+		for i := range combined.Inputs {
+			combined.Inputs[i].combine(&other.Inputs[i])
+		}
+		for i := range combined.Outputs {
+			combined.Outputs[i].combine(&other.Outputs[i])
+		}
+	}
+
+	// The lock time requirements of the inputs were combined as well, so
+	// make sure they still lead to the lock time of the transaction.
+	if err := combined.SanityCheck(); err != nil {
+		return nil, err
+	}
+
+	return combined, nil
+}
+
+// copyPacket returns a deep copy of the passed packet.
+func copyPacket(p *Packet) (*Packet, error) {
+	var b bytes.Buffer
+	if err := p.Serialize(&b); err != nil {
+		return nil, err
+	}
+
+	return NewFromRawBytes(&b, false)
+}
+
+// missingEntries returns the indexes of the entries of a list of otherNum
+// entries which aren't present in a list of num entries, according to the
+// passed function reporting whether the entry at index i of the list has the
+// same key as the entry at index j of the other list.
+func missingEntries(num, otherNum int, sameKey func(i, j int) bool) []int {
+	var missing []int
+	for j := 0; j < otherNum; j++ {
+		found := false
+		for i := 0; i < num; i++ {
+			if sameKey(i, j) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, j)
+		}
+	}
+
+	return missing
+}
+
+// combineUnknowns returns the unknowns with the ones of the other list whose
+// key isn't present in them added.
+func combineUnknowns(unknowns, other []*Unknown) []*Unknown {
+	missing := missingEntries(
+		len(unknowns), len(other), func(i, j int) bool {
+			return bytes.Equal(unknowns[i].Key, other[j].Key)
+		},
+	)
+	for _, j := range missing {
+		unknowns = append(unknowns, other[j])
+	}
+
+	return unknowns
+}
+
+// combine adds the data of the passed input which isn't present in the input.
+func (pi *PInput) combine(other *PInput) {
+	if pi.NonWitnessUtxo == nil {
+		pi.NonWitnessUtxo = other.NonWitnessUtxo
+	}
+	if pi.WitnessUtxo == nil {
+		pi.WitnessUtxo = other.WitnessUtxo
+	}
+	if pi.SighashType == 0 {
+		pi.SighashType = other.SighashType
+	}
+	if pi.RedeemScript == nil {
+		pi.RedeemScript = other.RedeemScript
+	}
+	if pi.WitnessScript == nil {
+		pi.WitnessScript = other.WitnessScript
+	}
+	if pi.FinalScriptSig == nil {
+		pi.FinalScriptSig = other.FinalScriptSig
+	}
+	if pi.FinalScriptWitness == nil {
+		pi.FinalScriptWitness = other.FinalScriptWitness
+	}
+	if pi.TaprootKeySpendSig == nil {
+		pi.TaprootKeySpendSig = other.TaprootKeySpendSig
+	}
+	if pi.TaprootInternalKey == nil {
+		pi.TaprootInternalKey = other.TaprootInternalKey
+	}
+	if pi.TaprootMerkleRoot == nil {
+		pi.TaprootMerkleRoot = other.TaprootMerkleRoot
+	}
+	if pi.RequiredTimeLockTime == 0 {
+		pi.RequiredTimeLockTime = other.RequiredTimeLockTime
+	}
+	if pi.RequiredHeightLockTime == 0 {
+		pi.RequiredHeightLockTime = other.RequiredHeightLockTime
+	}
+
+	missing := missingEntries(
+		len(pi.PartialSigs), len(other.PartialSigs),
+		func(i, j int) bool {
+			return bytes.Equal(
+				pi.PartialSigs[i].PubKey,
+				other.PartialSigs[j].PubKey,
+			)
+		},
+	)
+	for _, j := range missing {
+		pi.PartialSigs = append(pi.PartialSigs, other.PartialSigs[j])
+	}
+
+	missing = missingEntries(
+		len(pi.Bip32Derivation), len(other.Bip32Derivation),
+		func(i, j int) bool {
+			return bytes.Equal(
+				pi.Bip32Derivation[i].PubKey,
+				other.Bip32Derivation[j].PubKey,
+			)
+		},
+	)
+	for _, j := range missing {
+		pi.Bip32Derivation = append(
+			pi.Bip32Derivation, other.Bip32Derivation[j],
+		)
+	}
+
+	missing = missingEntries(
+		len(pi.TaprootScriptSpendSig), len(other.TaprootScriptSpendSig),
+		func(i, j int) bool {
+			return pi.TaprootScriptSpendSig[i].EqualKey(
+				other.TaprootScriptSpendSig[j],
+			)
+		},
+	)
+	for _, j := range missing {
+		pi.TaprootScriptSpendSig = append(
+			pi.TaprootScriptSpendSig, other.TaprootScriptSpendSig[j],
+		)
+	}
+
+	missing = missingEntries(
+		len(pi.TaprootLeafScript), len(other.TaprootLeafScript),
+		func(i, j int) bool {
+			return bytes.Equal(
+				pi.TaprootLeafScript[i].ControlBlock,
+				other.TaprootLeafScript[j].ControlBlock,
+			)
+		},
+	)
+	for _, j := range missing {
+		pi.TaprootLeafScript = append(
+			pi.TaprootLeafScript, other.TaprootLeafScript[j],
+		)
+	}
+
+	missing = missingEntries(
+		len(pi.TaprootBip32Derivation), len(other.TaprootBip32Derivation),
+		func(i, j int) bool {
+			return bytes.Equal(
+				pi.TaprootBip32Derivation[i].XOnlyPubKey,
+				other.TaprootBip32Derivation[j].XOnlyPubKey,
+			)
+		},
+	)
+	for _, j := range missing {
+		pi.TaprootBip32Derivation = append(
+			pi.TaprootBip32Derivation,
+			other.TaprootBip32Derivation[j],
+		)
+	}
+
+	pi.Unknowns = combineUnknowns(pi.Unknowns, other.Unknowns)
+}
+
+// combine adds the data of the passed output which isn't present in the
+// output.
+func (po *POutput) combine(other *POutput) {
+	if po.RedeemScript == nil {
+		po.RedeemScript = other.RedeemScript
+	}
+	if po.WitnessScript == nil {
+		po.WitnessScript = other.WitnessScript
+	}
+	if po.TaprootInternalKey == nil {
+		po.TaprootInternalKey = other.TaprootInternalKey
+	}
+	if po.TaprootTapTree == nil {
+		po.TaprootTapTree = other.TaprootTapTree
+	}
+
+	missing := missingEntries(
+		len(po.Bip32Derivation), len(other.Bip32Derivation),
+		func(i, j int) bool {
+			return bytes.Equal(
+				po.Bip32Derivation[i].PubKey,
+				other.Bip32Derivation[j].PubKey,
+			)
+		},
+	)
+	for _, j := range missing {
+		po.Bip32Derivation = append(
+			po.Bip32Derivation, other.Bip32Derivation[j],
+		)
+	}
+
+	missing = missingEntries(
+		len(po.TaprootBip32Derivation), len(other.TaprootBip32Derivation),
+		func(i, j int) bool {
+			return bytes.Equal(
+				po.TaprootBip32Derivation[i].XOnlyPubKey,
+				other.TaprootBip32Derivation[j].XOnlyPubKey,
+			)
+		},
+	)
+	for _, j := range missing {
+		po.TaprootBip32Derivation = append(
+			po.TaprootBip32Derivation,
+			other.TaprootBip32Derivation[j],
+		)
+	}
+
+	po.Unknowns = combineUnknowns(po.Unknowns, other.Unknowns)
+}
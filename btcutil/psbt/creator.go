@@ -61,3 +61,29 @@ func New(inputs []*wire.OutPoint,
 		Unknowns:   nil,
 	}, nil
 }
+
+// NewV2 returns a new version 2 PSBT packet, as defined in BIP370, for a
+// transaction with the passed version and fallback lock time.  The packet has
+// no inputs or outputs, these are added with the AddInput and AddOutput
+// methods of the Updater as long as the passed TxModifiable flags allow it.
+// The transaction version must be at least 2.  Referencing the PSBT BIP, this
+// function serves the role of the Creator for version 2 packets.
+func NewV2(version int32, fallbackLockTime uint32,
+	txModifiable uint8) (*Packet, error) {
+
+	if version < 2 {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	unsignedTx := wire.NewMsgTx(version)
+	unsignedTx.LockTime = fallbackLockTime
+
+	return &Packet{
+		Version:          2,
+		UnsignedTx:       unsignedTx,
+		FallbackLockTime: fallbackLockTime,
+		TxModifiable:     txModifiable,
+		Inputs:           []PInput{},
+		Outputs:          []POutput{},
+	}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sort"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -28,6 +29,8 @@ type PInput struct {
 	TaprootBip32Derivation []*TaprootBip32Derivation
 	TaprootInternalKey     []byte
 	TaprootMerkleRoot      []byte
+	RequiredTimeLockTime   uint32
+	RequiredHeightLockTime uint32
 	Unknowns               []*Unknown
 }
 
@@ -60,11 +63,26 @@ func (pi *PInput) IsSane() bool {
 	// only one is set anymore.
 	// See https://github.com/bitcoin/bitcoin/pull/19215.
 
+	// The lock time requirements of a version 2 PSBT input must be within
+	// the range of their lock time type.
+	if pi.RequiredTimeLockTime != 0 &&
+		pi.RequiredTimeLockTime < txscript.LockTimeThreshold {
+
+		return false
+	}
+	if pi.RequiredHeightLockTime >= txscript.LockTimeThreshold {
+		return false
+	}
+
 	return true
 }
 
 // deserialize attempts to deserialize a new PInput from the passed io.Reader.
-func (pi *PInput) deserialize(r io.Reader) error {
+// The previous outpoint and sequence number of an input of a version 2 PSBT
+// are stored in the passed transaction input, which must be nil for version 0
+// PSBTs.
+func (pi *PInput) deserialize(r io.Reader, txIn *wire.TxIn) error {
+	var haveTxid, haveIndex, haveSequence bool
 	for {
 		keyCode, keyData, err := getKey(r)
 		if err != nil {
@@ -81,6 +99,20 @@ func (pi *PInput) deserialize(r io.Reader) error {
 			return err
 		}
 
+		// The fields of version 2 PSBTs must be omitted from version 0
+		// PSBTs, where keys of their types with key data are unknowns.
+		if txIn == nil && isV2InputType(InputType(keyCode)) {
+			if keyData == nil {
+				return ErrInvalidPsbtFormat
+			}
+
+			err := pi.addUnknown(keyCode, keyData, value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		switch InputType(keyCode) {
 
 		case NonWitnessUtxoType:
@@ -363,34 +395,139 @@ func (pi *PInput) deserialize(r io.Reader) error {
 
 			pi.TaprootMerkleRoot = value
 
+		case PreviousTxidType:
+			if haveTxid {
+				return ErrDuplicateKey
+			}
+			if keyData != nil || len(value) != chainhash.HashSize {
+				return ErrInvalidKeyData
+			}
+
+			copy(txIn.PreviousOutPoint.Hash[:], value)
+			haveTxid = true
+
+		case OutputIndexType:
+			if haveIndex {
+				return ErrDuplicateKey
+			}
+			if keyData != nil {
+				return ErrInvalidKeyData
+			}
+
+			txIn.PreviousOutPoint.Index, err = readUint32(value)
+			if err != nil {
+				return err
+			}
+			haveIndex = true
+
+		case SequenceType:
+			if haveSequence {
+				return ErrDuplicateKey
+			}
+			if keyData != nil {
+				return ErrInvalidKeyData
+			}
+
+			txIn.Sequence, err = readUint32(value)
+			if err != nil {
+				return err
+			}
+			haveSequence = true
+
+		case RequiredTimeLockTimeType:
+			if pi.RequiredTimeLockTime != 0 {
+				return ErrDuplicateKey
+			}
+			if keyData != nil {
+				return ErrInvalidKeyData
+			}
+
+			lockTime, err := readUint32(value)
+			if err != nil {
+				return err
+			}
+			if lockTime < txscript.LockTimeThreshold {
+				return ErrInvalidKeyData
+			}
+			pi.RequiredTimeLockTime = lockTime
+
+		case RequiredHeightLockTimeType:
+			if pi.RequiredHeightLockTime != 0 {
+				return ErrDuplicateKey
+			}
+			if keyData != nil {
+				return ErrInvalidKeyData
+			}
+
+			lockTime, err := readUint32(value)
+			if err != nil {
+				return err
+			}
+			if lockTime == 0 ||
+				lockTime >= txscript.LockTimeThreshold {
+
+				return ErrInvalidKeyData
+			}
+			pi.RequiredHeightLockTime = lockTime
+
 		default:
 			// A fall through case for any proprietary types.
-			keyCodeAndData := append(
-				[]byte{byte(keyCode)}, keyData...,
-			)
-			newUnknown := &Unknown{
-				Key:   keyCodeAndData,
-				Value: value,
+			err := pi.addUnknown(keyCode, keyData, value)
+			if err != nil {
+				return err
 			}
+		}
+	}
 
-			// Duplicate key+keyData are not allowed.
-			for _, x := range pi.Unknowns {
-				if bytes.Equal(x.Key, newUnknown.Key) &&
-					bytes.Equal(x.Value, newUnknown.Value) {
+	// The previous outpoint is required for the inputs of a version 2
+	// PSBT.
+	if txIn != nil && (!haveTxid || !haveIndex) {
+		return ErrInvalidPsbtFormat
+	}
 
-					return ErrDuplicateKey
-				}
-			}
+	return nil
+}
+
+// addUnknown adds a key-value pair of an unknown type to the PInput.
+func (pi *PInput) addUnknown(keyCode int, keyData, value []byte) error {
+	keyCodeAndData := append([]byte{byte(keyCode)}, keyData...)
+	newUnknown := &Unknown{
+		Key:   keyCodeAndData,
+		Value: value,
+	}
+
+	// Duplicate key+keyData are not allowed.
+	for _, x := range pi.Unknowns {
+		if bytes.Equal(x.Key, newUnknown.Key) &&
+			bytes.Equal(x.Value, newUnknown.Value) {
 
-			pi.Unknowns = append(pi.Unknowns, newUnknown)
+			return ErrDuplicateKey
 		}
 	}
 
+	pi.Unknowns = append(pi.Unknowns, newUnknown)
+
 	return nil
 }
 
+// isV2InputType returns true if the passed type is one of the input fields
+// only defined for version 2 PSBTs.
+func isV2InputType(inputType InputType) bool {
+	switch inputType {
+	case PreviousTxidType, OutputIndexType, SequenceType,
+		RequiredTimeLockTimeType, RequiredHeightLockTimeType:
+
+		return true
+	}
+
+	return false
+}
+
 // serialize attempts to serialize the target PInput into the passed io.Writer.
-func (pi *PInput) serialize(w io.Writer) error {
+// The previous outpoint and sequence number of the passed transaction input
+// are written out as well for version 2 PSBTs, while it must be nil for
+// version 0 PSBTs.
+func (pi *PInput) serialize(w io.Writer, txIn *wire.TxIn) error {
 	if !pi.IsSane() {
 		return ErrInvalidPsbtFormat
 	}
@@ -592,6 +729,12 @@ func (pi *PInput) serialize(w io.Writer) error {
 		}
 	}
 
+	if txIn != nil {
+		if err := pi.serializeV2Fields(w, txIn); err != nil {
+			return err
+		}
+	}
+
 	// Unknown is a special case; we don't have a key type, only a key and
 	// a value field.
 	for _, kv := range pi.Unknowns {
@@ -603,3 +746,55 @@ func (pi *PInput) serialize(w io.Writer) error {
 
 	return nil
 }
+
+// serializeV2Fields writes out the fields describing an input of a version 2
+// PSBT, which are its previous outpoint and sequence number, taken from the
+// passed transaction input, along with its lock time requirements.
+func (pi *PInput) serializeV2Fields(w io.Writer, txIn *wire.TxIn) error {
+	err := serializeKVPairWithType(
+		w, uint8(PreviousTxidType), nil,
+		txIn.PreviousOutPoint.Hash[:],
+	)
+	if err != nil {
+		return err
+	}
+
+	err = serializeUint32KVPair(
+		w, uint8(OutputIndexType), txIn.PreviousOutPoint.Index,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The sequence number is assumed to be final when omitted.
+	if txIn.Sequence != wire.MaxTxInSequenceNum {
+		err := serializeUint32KVPair(
+			w, uint8(SequenceType), txIn.Sequence,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pi.RequiredTimeLockTime != 0 {
+		err := serializeUint32KVPair(
+			w, uint8(RequiredTimeLockTimeType),
+			pi.RequiredTimeLockTime,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pi.RequiredHeightLockTime != 0 {
+		err := serializeUint32KVPair(
+			w, uint8(RequiredHeightLockTimeType),
+			pi.RequiredHeightLockTime,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package psbt
 
 import (
 	"bytes"
+	"encoding/binary"
 	"io"
 	"sort"
 
@@ -33,7 +34,10 @@ func NewPsbtOutput(redeemScript []byte, witnessScript []byte,
 }
 
 // deserialize attempts to recode a new POutput from the passed io.Reader.
-func (po *POutput) deserialize(r io.Reader) error {
+// The amount and script of an output of a version 2 PSBT are stored in the
+// passed transaction output, which must be nil for version 0 PSBTs.
+func (po *POutput) deserialize(r io.Reader, txOut *wire.TxOut) error {
+	var haveAmount, haveScript bool
 	for {
 		keyCode, keyData, err := getKey(r)
 		if err != nil {
@@ -51,6 +55,20 @@ func (po *POutput) deserialize(r io.Reader) error {
 			return err
 		}
 
+		// The fields of version 2 PSBTs must be omitted from version 0
+		// PSBTs, where keys of their types with key data are unknowns.
+		if txOut == nil && isV2OutputType(OutputType(keyCode)) {
+			if keyData == nil {
+				return ErrInvalidPsbtFormat
+			}
+
+			err := po.addUnknown(keyCode, keyData, value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		switch OutputType(keyCode) {
 
 		case RedeemScriptOutputType:
@@ -97,6 +115,28 @@ func (po *POutput) deserialize(r io.Reader) error {
 				},
 			)
 
+		case AmountType:
+			if haveAmount {
+				return ErrDuplicateKey
+			}
+			if keyData != nil || len(value) != 8 {
+				return ErrInvalidKeyData
+			}
+
+			txOut.Value = int64(binary.LittleEndian.Uint64(value))
+			haveAmount = true
+
+		case ScriptType:
+			if haveScript {
+				return ErrDuplicateKey
+			}
+			if keyData != nil {
+				return ErrInvalidKeyData
+			}
+
+			txOut.PkScript = value
+			haveScript = true
+
 		case TaprootInternalKeyOutputType:
 			if po.TaprootInternalKey != nil {
 				return ErrDuplicateKey
@@ -146,33 +186,55 @@ func (po *POutput) deserialize(r io.Reader) error {
 
 		default:
 			// A fall through case for any proprietary types.
-			keyCodeAndData := append(
-				[]byte{byte(keyCode)}, keyData...,
-			)
-			newUnknown := &Unknown{
-				Key:   keyCodeAndData,
-				Value: value,
+			err := po.addUnknown(keyCode, keyData, value)
+			if err != nil {
+				return err
 			}
+		}
+	}
 
-			// Duplicate key+keyData are not allowed.
-			for _, x := range po.Unknowns {
-				if bytes.Equal(x.Key, newUnknown.Key) &&
-					bytes.Equal(x.Value, newUnknown.Value) {
+	// The amount and script are required for the outputs of a version 2
+	// PSBT.
+	if txOut != nil && (!haveAmount || !haveScript) {
+		return ErrInvalidPsbtFormat
+	}
 
-					return ErrDuplicateKey
-				}
-			}
+	return nil
+}
 
-			po.Unknowns = append(po.Unknowns, newUnknown)
+// addUnknown adds a key-value pair of an unknown type to the POutput.
+func (po *POutput) addUnknown(keyCode int, keyData, value []byte) error {
+	keyCodeAndData := append([]byte{byte(keyCode)}, keyData...)
+	newUnknown := &Unknown{
+		Key:   keyCodeAndData,
+		Value: value,
+	}
+
+	// Duplicate key+keyData are not allowed.
+	for _, x := range po.Unknowns {
+		if bytes.Equal(x.Key, newUnknown.Key) &&
+			bytes.Equal(x.Value, newUnknown.Value) {
+
+			return ErrDuplicateKey
 		}
 	}
 
+	po.Unknowns = append(po.Unknowns, newUnknown)
+
 	return nil
 }
 
+// isV2OutputType returns true if the passed type is one of the output fields
+// only defined for version 2 PSBTs.
+func isV2OutputType(outputType OutputType) bool {
+	return outputType == AmountType || outputType == ScriptType
+}
+
 // serialize attempts to write out the target POutput into the passed
-// io.Writer.
-func (po *POutput) serialize(w io.Writer) error {
+// io.Writer. The amount and script of the passed transaction output are
+// written out as well for version 2 PSBTs, while it must be nil for version 0
+// PSBTs.
+func (po *POutput) serialize(w io.Writer, txOut *wire.TxOut) error {
 	if po.RedeemScript != nil {
 		err := serializeKVPairWithType(
 			w, uint8(RedeemScriptOutputType), nil, po.RedeemScript,
@@ -205,6 +267,24 @@ func (po *POutput) serialize(w io.Writer) error {
 		}
 	}
 
+	if txOut != nil {
+		var amount [8]byte
+		binary.LittleEndian.PutUint64(amount[:], uint64(txOut.Value))
+		err := serializeKVPairWithType(
+			w, uint8(AmountType), nil, amount[:],
+		)
+		if err != nil {
+			return err
+		}
+
+		err = serializeKVPairWithType(
+			w, uint8(ScriptType), nil, txOut.PkScript,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	if po.TaprootInternalKey != nil {
 		err := serializeKVPairWithType(
 			w, uint8(TaprootInternalKeyOutputType), nil,
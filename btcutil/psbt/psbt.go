@@ -103,6 +103,28 @@ var (
 	// script witness given is not supported by this codebase, or is
 	// otherwise not valid.
 	ErrUnsupportedScriptType = errors.New("Unsupported script type")
+
+	// ErrUnsupportedPsbtVersion indicates that a PSBT uses a version of the
+	// format other than the ones defined by BIP174 and BIP370, or that an
+	// operation isn't supported by the version of the PSBT.
+	ErrUnsupportedPsbtVersion = errors.New("Unsupported PSBT version")
+
+	// ErrConflictingLockTimes indicates that the lock time of a version 2
+	// PSBT can't be determined since some of its inputs require a height
+	// based lock time while others require a time based one.
+	ErrConflictingLockTimes = errors.New("Inputs require conflicting " +
+		"lock time types")
+
+	// ErrTxNotModifiable indicates that an input or output can't be added
+	// to a version 2 PSBT, either because the TxModifiable flags don't
+	// allow it or because it would change the lock time of a transaction
+	// which already has signatures.
+	ErrTxNotModifiable = errors.New("PSBT transaction is not modifiable")
+
+	// ErrMismatchedPsbts indicates that PSBTs passed to the Combiner can't
+	// be combined since they don't describe the same transaction.
+	ErrMismatchedPsbts = errors.New("PSBTs describe different " +
+		"transactions")
 )
 
 // Unknown is a struct encapsulating a key-value pair for which the key type is
@@ -118,9 +140,27 @@ type Unknown struct {
 // with N inputs and M outputs.  These key-value pairs can contain scripts,
 // signatures, key derivations and other transaction-defining data.
 type Packet struct {
+	// Version is the version of the PSBT format, either 0 as defined in
+	// BIP174 or 2 as defined in BIP370.
+	Version uint32
+
 	// UnsignedTx is the decoded unsigned transaction for this PSBT.
+	//
+	// Version 2 PSBTs don't include the unsigned transaction, instead it
+	// is assembled from the transaction version, the previous outpoints
+	// and sequence numbers of the inputs and the amounts and scripts of
+	// the outputs, while its lock time is determined from the lock time
+	// requirements of the inputs and the fallback lock time.
 	UnsignedTx *wire.MsgTx // Deserialization of unsigned tx
 
+	// FallbackLockTime is the lock time of the transaction of a version 2
+	// PSBT when none of its inputs require a lock time.
+	FallbackLockTime uint32
+
+	// TxModifiable is the bit field of TxModifiable flags which signals
+	// whether inputs and outputs can be added to a version 2 PSBT.
+	TxModifiable uint8
+
 	// Inputs contains all the information needed to properly sign this
 	// target input within the above transaction.
 	Inputs []PInput
@@ -191,40 +231,21 @@ func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
 		return nil, ErrInvalidMagicBytes
 	}
 
-	// Next we parse the GLOBAL section.  There is currently only 1 known
-	// key type, UnsignedTx.  We insist this exists first; unknowns are
-	// allowed, but only after.
-	keyCode, keyData, err := getKey(r)
-	if err != nil {
-		return nil, err
-	}
-	if GlobalType(keyCode) != UnsignedTxType || keyData != nil {
-		return nil, ErrInvalidPsbtFormat
-	}
-
-	// Now that we've verified the global type is present, we'll decode it
-	// into a proper unsigned transaction, and validate it.
-	value, err := wire.ReadVarBytes(
-		r, 0, MaxPsbtValueLength, "PSBT value",
+	// Next we parse the GLOBAL section. Version 0 PSBTs must contain the
+	// unsigned transaction, while version 2 PSBTs describe it through the
+	// transaction version, input and output counts and the per input and
+	// output fields instead. Any other types are kept as unknowns.
+	var (
+		msgTx            *wire.MsgTx
+		version          uint32
+		txVersion        uint32
+		fallbackLockTime uint32
+		inputCount       uint64
+		outputCount      uint64
+		txModifiable     uint8
+		unknownSlice     []*Unknown
 	)
-	if err != nil {
-		return nil, err
-	}
-	msgTx := wire.NewMsgTx(2)
-
-	// BIP-0174 states: "The transaction must be in the old serialization
-	// format (without witnesses)."
-	err = msgTx.DeserializeNoWitness(bytes.NewReader(value))
-	if err != nil {
-		return nil, err
-	}
-	if !validateUnsignedTX(msgTx) {
-		return nil, ErrInvalidRawTxSigned
-	}
-
-	// Next we parse any unknowns that may be present, making sure that we
-	// break at the separator.
-	var unknownSlice []*Unknown
+	seenTypes := make(map[GlobalType]bool)
 	for {
 		keyint, keydata, err := getKey(r)
 		if err != nil {
@@ -241,51 +262,164 @@ func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
 			return nil, err
 		}
 
-		keyintanddata := []byte{byte(keyint)}
-		keyintanddata = append(keyintanddata, keydata...)
+		globalType := GlobalType(keyint)
+		switch globalType {
+		case UnsignedTxType, VersionType, TxVersionType,
+			FallbackLockTimeType, InputCountType, OutputCountType,
+			TxModifiableType:
+
+			if seenTypes[globalType] {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil {
+				return nil, ErrInvalidKeyData
+			}
+			seenTypes[globalType] = true
+		}
 
-		newUnknown := &Unknown{
-			Key:   keyintanddata,
-			Value: value,
+		switch globalType {
+		case UnsignedTxType:
+			msgTx = wire.NewMsgTx(2)
+
+			// BIP-0174 states: "The transaction must be in the old
+			// serialization format (without witnesses)."
+			err = msgTx.DeserializeNoWitness(bytes.NewReader(value))
+			if err != nil {
+				return nil, err
+			}
+			if !validateUnsignedTX(msgTx) {
+				return nil, ErrInvalidRawTxSigned
+			}
+
+		case VersionType:
+			version, err = readUint32(value)
+
+		case TxVersionType:
+			txVersion, err = readUint32(value)
+
+		case FallbackLockTimeType:
+			fallbackLockTime, err = readUint32(value)
+
+		case InputCountType:
+			inputCount, err = readCompactSize(value)
+
+		case OutputCountType:
+			outputCount, err = readCompactSize(value)
+
+		case TxModifiableType:
+			if len(value) != 1 {
+				return nil, ErrInvalidKeyData
+			}
+			txModifiable = value[0]
+
+		default:
+			keyintanddata := []byte{byte(keyint)}
+			keyintanddata = append(keyintanddata, keydata...)
+
+			newUnknown := &Unknown{
+				Key:   keyintanddata,
+				Value: value,
+			}
+			unknownSlice = append(unknownSlice, newUnknown)
+		}
+		if err != nil {
+			return nil, err
 		}
-		unknownSlice = append(unknownSlice, newUnknown)
 	}
 
-	// Next we parse the INPUT section.
-	inSlice := make([]PInput, len(msgTx.TxIn))
-	for i := range msgTx.TxIn {
+	// Ensure the global section contains the fields required by the
+	// version of the PSBT and none of the fields of the other version.
+	switch version {
+	case 0:
+		if msgTx == nil || seenTypes[TxVersionType] ||
+			seenTypes[FallbackLockTimeType] ||
+			seenTypes[InputCountType] ||
+			seenTypes[OutputCountType] ||
+			seenTypes[TxModifiableType] {
+
+			return nil, ErrInvalidPsbtFormat
+		}
+		inputCount = uint64(len(msgTx.TxIn))
+		outputCount = uint64(len(msgTx.TxOut))
+
+	case 2:
+		if msgTx != nil || !seenTypes[TxVersionType] ||
+			!seenTypes[InputCountType] ||
+			!seenTypes[OutputCountType] {
+
+			return nil, ErrInvalidPsbtFormat
+		}
+		msgTx = wire.NewMsgTx(int32(txVersion))
+
+	default:
+		return nil, ErrUnsupportedPsbtVersion
+	}
+
+	// Next we parse the INPUT section. The previous outpoints and sequence
+	// numbers of the inputs of a version 2 PSBT are added to the unsigned
+	// transaction.
+	inSlice := make([]PInput, 0, len(msgTx.TxIn))
+	for i := uint64(0); i < inputCount; i++ {
+		var txIn *wire.TxIn
+		if version == 2 {
+			txIn = &wire.TxIn{Sequence: wire.MaxTxInSequenceNum}
+		}
+
 		input := PInput{}
-		err = input.deserialize(r)
-		if err != nil {
+		if err := input.deserialize(r, txIn); err != nil {
 			return nil, err
 		}
 
-		inSlice[i] = input
+		inSlice = append(inSlice, input)
+		if txIn != nil {
+			msgTx.AddTxIn(txIn)
+		}
 	}
 
-	// Next we parse the OUTPUT section.
-	outSlice := make([]POutput, len(msgTx.TxOut))
-	for i := range msgTx.TxOut {
+	// Next we parse the OUTPUT section, adding the outputs of a version 2
+	// PSBT to the unsigned transaction as well.
+	outSlice := make([]POutput, 0, len(msgTx.TxOut))
+	for i := uint64(0); i < outputCount; i++ {
+		var txOut *wire.TxOut
+		if version == 2 {
+			txOut = &wire.TxOut{}
+		}
+
 		output := POutput{}
-		err = output.deserialize(r)
-		if err != nil {
+		if err := output.deserialize(r, txOut); err != nil {
 			return nil, err
 		}
 
-		outSlice[i] = output
+		outSlice = append(outSlice, output)
+		if txOut != nil {
+			msgTx.AddTxOut(txOut)
+		}
 	}
 
 	// Populate the new Packet object.
 	newPsbt := Packet{
-		UnsignedTx: msgTx,
-		Inputs:     inSlice,
-		Outputs:    outSlice,
-		Unknowns:   unknownSlice,
+		Version:          version,
+		UnsignedTx:       msgTx,
+		FallbackLockTime: fallbackLockTime,
+		TxModifiable:     txModifiable,
+		Inputs:           inSlice,
+		Outputs:          outSlice,
+		Unknowns:         unknownSlice,
+	}
+
+	// The lock time of the transaction of a version 2 PSBT follows from
+	// the lock time requirements of its inputs.
+	if version == 2 {
+		lockTime, err := newPsbt.DetermineLockTime()
+		if err != nil {
+			return nil, err
+		}
+		msgTx.LockTime = lockTime
 	}
 
 	// Extended sanity checking is applied here to make sure the
 	// externally-passed Packet follows all the rules.
-	if err = newPsbt.SanityCheck(); err != nil {
+	if err := newPsbt.SanityCheck(); err != nil {
 		return nil, err
 	}
 
@@ -301,22 +435,16 @@ func (p *Packet) Serialize(w io.Writer) error {
 		return err
 	}
 
-	// Next we prep to write out the unsigned transaction by first
-	// serializing it into an intermediate buffer.
-	serializedTx := bytes.NewBuffer(
-		make([]byte, 0, p.UnsignedTx.SerializeSize()),
-	)
-	if err := p.UnsignedTx.SerializeNoWitness(serializedTx); err != nil {
-		return err
-	}
-
-	// Now that we have the serialized transaction, we'll write it out to
-	// the proper global type.
-	err := serializeKVPairWithType(
-		w, uint8(UnsignedTxType), nil, serializedTx.Bytes(),
-	)
-	if err != nil {
-		return err
+	// Next we write out the global fields describing the transaction,
+	// which depend on the version of the PSBT.
+	if p.Version == 2 {
+		if err := p.serializeV2Globals(w); err != nil {
+			return err
+		}
+	} else {
+		if err := p.serializeUnsignedTx(w); err != nil {
+			return err
+		}
 	}
 
 	// Unknown is a special case; we don't have a key type, only a key and
@@ -335,8 +463,16 @@ func (p *Packet) Serialize(w io.Writer) error {
 		return err
 	}
 
-	for _, pInput := range p.Inputs {
-		err := pInput.serialize(w)
+	// The previous outpoints and sequence numbers of the inputs, as well
+	// as the outputs, of a version 2 PSBT are part of the input and output
+	// sections.
+	for i, pInput := range p.Inputs {
+		var txIn *wire.TxIn
+		if p.Version == 2 {
+			txIn = p.UnsignedTx.TxIn[i]
+		}
+
+		err := pInput.serialize(w, txIn)
 		if err != nil {
 			return err
 		}
@@ -346,8 +482,13 @@ func (p *Packet) Serialize(w io.Writer) error {
 		}
 	}
 
-	for _, pOutput := range p.Outputs {
-		err := pOutput.serialize(w)
+	for i, pOutput := range p.Outputs {
+		var txOut *wire.TxOut
+		if p.Version == 2 {
+			txOut = p.UnsignedTx.TxOut[i]
+		}
+
+		err := pOutput.serialize(w, txOut)
 		if err != nil {
 			return err
 		}
@@ -360,6 +501,81 @@ func (p *Packet) Serialize(w io.Writer) error {
 	return nil
 }
 
+// serializeUnsignedTx writes out the unsigned transaction of a version 0
+// PSBT as a global key-value pair.
+func (p *Packet) serializeUnsignedTx(w io.Writer) error {
+	// Next we prep to write out the unsigned transaction by first
+	// serializing it into an intermediate buffer.
+	serializedTx := bytes.NewBuffer(
+		make([]byte, 0, p.UnsignedTx.SerializeSize()),
+	)
+	if err := p.UnsignedTx.SerializeNoWitness(serializedTx); err != nil {
+		return err
+	}
+
+	// Now that we have the serialized transaction, we'll write it out to
+	// the proper global type.
+	return serializeKVPairWithType(
+		w, uint8(UnsignedTxType), nil, serializedTx.Bytes(),
+	)
+}
+
+// serializeV2Globals writes out the global key-value pairs describing the
+// transaction of a version 2 PSBT, along with the PSBT version.
+func (p *Packet) serializeV2Globals(w io.Writer) error {
+	err := serializeUint32KVPair(
+		w, uint8(TxVersionType), uint32(p.UnsignedTx.Version),
+	)
+	if err != nil {
+		return err
+	}
+
+	if p.FallbackLockTime != 0 {
+		err := serializeUint32KVPair(
+			w, uint8(FallbackLockTimeType), p.FallbackLockTime,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	var count bytes.Buffer
+	err = wire.WriteVarInt(&count, 0, uint64(len(p.UnsignedTx.TxIn)))
+	if err != nil {
+		return err
+	}
+	err = serializeKVPairWithType(
+		w, uint8(InputCountType), nil, count.Bytes(),
+	)
+	if err != nil {
+		return err
+	}
+
+	count.Reset()
+	err = wire.WriteVarInt(&count, 0, uint64(len(p.UnsignedTx.TxOut)))
+	if err != nil {
+		return err
+	}
+	err = serializeKVPairWithType(
+		w, uint8(OutputCountType), nil, count.Bytes(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if p.TxModifiable != 0 {
+		err := serializeKVPairWithType(
+			w, uint8(TxModifiableType), nil,
+			[]byte{p.TxModifiable},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return serializeUint32KVPair(w, uint8(VersionType), p.Version)
+}
+
 // B64Encode returns the base64 encoding of the serialization of
 // the current PSBT, or an error if the encoding fails.
 func (p *Packet) B64Encode() (string, error) {
@@ -397,6 +613,42 @@ func (p *Packet) SanityCheck() error {
 		}
 	}
 
+	switch p.Version {
+	// The fields describing the transaction of a version 2 PSBT have no
+	// version 0 equivalent.
+	case 0:
+		if p.FallbackLockTime != 0 || p.TxModifiable != 0 {
+			return ErrInvalidPsbtFormat
+		}
+		for _, tin := range p.Inputs {
+			if tin.RequiredTimeLockTime != 0 ||
+				tin.RequiredHeightLockTime != 0 {
+
+				return ErrInvalidPsbtFormat
+			}
+		}
+
+	// The unsigned transaction of a version 2 PSBT must match its inputs
+	// and outputs, including the lock time they require.
+	case 2:
+		if len(p.Inputs) != len(p.UnsignedTx.TxIn) ||
+			len(p.Outputs) != len(p.UnsignedTx.TxOut) {
+
+			return ErrInvalidPsbtFormat
+		}
+
+		lockTime, err := p.DetermineLockTime()
+		if err != nil {
+			return err
+		}
+		if lockTime != p.UnsignedTx.LockTime {
+			return ErrInvalidPsbtFormat
+		}
+
+	default:
+		return ErrUnsupportedPsbtVersion
+	}
+
 	return nil
 }
 
@@ -0,0 +1,189 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// sigHashMask defines the number of bits of the sighash type which select the
+// outputs a signature commits to.
+const sigHashMask = 0x1f
+
+// DetermineLockTime returns the lock time of the transaction described by the
+// packet.
+//
+// For version 2 packets this is the lock time determination algorithm of
+// BIP370: the fallback lock time is used when none of the inputs require a
+// lock time.  Otherwise, the greatest required height based lock time is used
+// when all of the inputs with requirements allow one, which is preferred, or
+// else the greatest required time based lock time when all of them allow that
+// instead.  ErrConflictingLockTimes is returned when neither type is allowed
+// by all of the inputs.
+func (p *Packet) DetermineLockTime() (uint32, error) {
+	if p.Version != 2 {
+		return p.UnsignedTx.LockTime, nil
+	}
+
+	return determineLockTime(p.Inputs, p.FallbackLockTime)
+}
+
+// determineLockTime returns the lock time of a version 2 PSBT with the passed
+// inputs and fallback lock time.  See DetermineLockTime for details.
+func determineLockTime(inputs []PInput, fallbackLockTime uint32) (uint32,
+	error) {
+
+	var (
+		haveRequirement bool
+		allowHeight     = true
+		allowTime       = true
+		maxHeight       uint32
+		maxTime         uint32
+	)
+	for i := range inputs {
+		heightLockTime := inputs[i].RequiredHeightLockTime
+		timeLockTime := inputs[i].RequiredTimeLockTime
+		if heightLockTime == 0 && timeLockTime == 0 {
+			continue
+		}
+		haveRequirement = true
+
+		if heightLockTime == 0 {
+			allowHeight = false
+		} else if heightLockTime > maxHeight {
+			maxHeight = heightLockTime
+		}
+
+		if timeLockTime == 0 {
+			allowTime = false
+		} else if timeLockTime > maxTime {
+			maxTime = timeLockTime
+		}
+	}
+
+	switch {
+	case !haveRequirement:
+		return fallbackLockTime, nil
+
+	case allowHeight:
+		return maxHeight, nil
+
+	case allowTime:
+		return maxTime, nil
+	}
+
+	return 0, ErrConflictingLockTimes
+}
+
+// UniqueID returns the identifier of the transaction described by the packet,
+// which packets must share to be combined.  It is the hash of the unsigned
+// transaction, with all of the sequence numbers set to 0 for version 2
+// packets as defined in BIP370.
+func (p *Packet) UniqueID() chainhash.Hash {
+	if p.Version != 2 {
+		return p.UnsignedTx.TxHash()
+	}
+
+	tx := p.UnsignedTx.Copy()
+	for _, txIn := range tx.TxIn {
+		txIn.Sequence = 0
+	}
+	return tx.TxHash()
+}
+
+// ConvertToV2 converts the version 0 packet to a version 2 packet in place.
+// The lock time of the unsigned transaction becomes the fallback lock time,
+// and the inputs and outputs aren't modifiable since they were fixed by the
+// unsigned transaction.  Converting a version 2 packet is a no-op.
+func (p *Packet) ConvertToV2() error {
+	switch p.Version {
+	case 0:
+	case 2:
+		return nil
+	default:
+		return ErrUnsupportedPsbtVersion
+	}
+
+	if err := p.SanityCheck(); err != nil {
+		return err
+	}
+
+	p.Version = 2
+	p.FallbackLockTime = p.UnsignedTx.LockTime
+	p.TxModifiable = 0
+
+	return nil
+}
+
+// ConvertToV0 converts the version 2 packet to a version 0 packet in place.
+// The unsigned transaction keeps the lock time determined for the packet,
+// while the lock time requirements of the inputs, the fallback lock time and
+// the TxModifiable flags, which have no version 0 equivalent, are dropped.
+// Converting a version 0 packet is a no-op.
+func (p *Packet) ConvertToV0() error {
+	switch p.Version {
+	case 0:
+		return nil
+	case 2:
+	default:
+		return ErrUnsupportedPsbtVersion
+	}
+
+	if err := p.SanityCheck(); err != nil {
+		return err
+	}
+
+	p.Version = 0
+	p.FallbackLockTime = 0
+	p.TxModifiable = 0
+	for i := range p.Inputs {
+		p.Inputs[i].RequiredTimeLockTime = 0
+		p.Inputs[i].RequiredHeightLockTime = 0
+	}
+
+	return nil
+}
+
+// hasSignatures returns true if any of the inputs of the packet are signed or
+// finalized.
+func (p *Packet) hasSignatures() bool {
+	for _, pInput := range p.Inputs {
+		if len(pInput.PartialSigs) != 0 ||
+			len(pInput.TaprootKeySpendSig) != 0 ||
+			len(pInput.TaprootScriptSpendSig) != 0 ||
+			pInput.FinalScriptSig != nil ||
+			pInput.FinalScriptWitness != nil {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateTxModifiable updates the TxModifiable flags of a version 2 packet
+// after a signature with the passed sighash type was added, as required of
+// the Signer by BIP370.  Inputs can no longer be added unless the signature
+// commits to its own input only, and outputs can no longer be added unless
+// the signature commits to none of them or only to the output at its own
+// index, which is then flagged to be preserved.
+func (p *Packet) updateTxModifiable(hashType txscript.SigHashType) {
+	if p.Version != 2 {
+		return
+	}
+
+	if hashType&txscript.SigHashAnyOneCanPay == 0 {
+		p.TxModifiable &^= TxModifiableInputs
+	}
+
+	switch hashType & sigHashMask {
+	case txscript.SigHashNone:
+	case txscript.SigHashSingle:
+		p.TxModifiable |= TxModifiableSighashSingle
+	default:
+		p.TxModifiable &^= TxModifiableOutputs
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPacketV2 returns a version 2 packet with two inputs and an output.
+func newTestPacketV2(t *testing.T) *Packet {
+	t.Helper()
+
+	packet, err := NewV2(
+		2, 100, TxModifiableInputs|TxModifiableOutputs,
+	)
+	require.NoError(t, err)
+	updater, err := NewUpdater(packet)
+	require.NoError(t, err)
+
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{1}},
+		Sequence:         wire.MaxTxInSequenceNum,
+	}, PInput{RequiredHeightLockTime: 200})
+	require.NoError(t, err)
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{2}, Index: 3},
+		Sequence:         10,
+	}, PInput{
+		RequiredHeightLockTime: 300,
+		RequiredTimeLockTime:   txscript.LockTimeThreshold,
+	})
+	require.NoError(t, err)
+	err = updater.AddOutput(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}),
+		POutput{RedeemScript: []byte{txscript.OP_1}})
+	require.NoError(t, err)
+
+	return packet
+}
+
+// TestPsbtV2Serialization ensures version 2 packets are serialized and parsed
+// back into the same transaction, and can be converted to and from version 0
+// packets.
+func TestPsbtV2Serialization(t *testing.T) {
+	packet := newTestPacketV2(t)
+	require.EqualValues(t, 300, packet.UnsignedTx.LockTime)
+
+	var b bytes.Buffer
+	require.NoError(t, packet.Serialize(&b))
+	serialized := b.Bytes()
+
+	parsed, err := NewFromRawBytes(bytes.NewReader(serialized), false)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, parsed.Version)
+	require.Equal(t, packet.UnsignedTx, parsed.UnsignedTx)
+	require.Equal(t, packet.FallbackLockTime, parsed.FallbackLockTime)
+	require.Equal(t, packet.TxModifiable, parsed.TxModifiable)
+	require.Equal(t, packet.Inputs, parsed.Inputs)
+	require.Equal(t, packet.Outputs, parsed.Outputs)
+
+	var reserialized bytes.Buffer
+	require.NoError(t, parsed.Serialize(&reserialized))
+	require.Equal(t, serialized, reserialized.Bytes())
+
+	// Converting the packet to version 0 keeps the determined lock time
+	// and drops the lock time requirements.
+	uniqueID := parsed.UniqueID()
+	require.NoError(t, parsed.ConvertToV0())
+	require.EqualValues(t, 0, parsed.Version)
+	require.EqualValues(t, 300, parsed.UnsignedTx.LockTime)
+	require.Zero(t, parsed.Inputs[1].RequiredHeightLockTime)
+
+	b.Reset()
+	require.NoError(t, parsed.Serialize(&b))
+	v0, err := NewFromRawBytes(&b, false)
+	require.NoError(t, err)
+	require.Equal(t, parsed.UnsignedTx.TxHash(), v0.UnsignedTx.TxHash())
+
+	// Converting it back gives a packet describing the same transaction,
+	// with the lock time as the fallback lock time.
+	require.NoError(t, v0.ConvertToV2())
+	require.EqualValues(t, 300, v0.FallbackLockTime)
+	require.Equal(t, uniqueID, v0.UniqueID())
+
+	b.Reset()
+	require.NoError(t, v0.Serialize(&b))
+	_, err = NewFromRawBytes(&b, false)
+	require.NoError(t, err)
+}
+
+// TestPsbtV2InvalidGlobals ensures packets whose global section doesn't match
+// their version are rejected.
+func TestPsbtV2InvalidGlobals(t *testing.T) {
+	unsignedTx := wire.NewMsgTx(2)
+	unsignedTx.AddTxIn(&wire.TxIn{})
+	unsignedTx.AddTxOut(wire.NewTxOut(1, nil))
+	var serializedTx bytes.Buffer
+	require.NoError(t, unsignedTx.SerializeNoWitness(&serializedTx))
+
+	type kvPair struct {
+		keyType uint8
+		value   []byte
+	}
+	unsignedTxPair := kvPair{uint8(UnsignedTxType), serializedTx.Bytes()}
+	txVersionPair := kvPair{uint8(TxVersionType), []byte{2, 0, 0, 0}}
+	inputCountPair := kvPair{uint8(InputCountType), []byte{0}}
+	outputCountPair := kvPair{uint8(OutputCountType), []byte{0}}
+	v2Pair := kvPair{uint8(VersionType), []byte{2, 0, 0, 0}}
+
+	tests := []struct {
+		name    string
+		globals []kvPair
+		wantErr error
+	}{{
+		name:    "version 0 with tx version",
+		globals: []kvPair{unsignedTxPair, txVersionPair},
+		wantErr: ErrInvalidPsbtFormat,
+	}, {
+		name: "version 2 with unsigned tx",
+		globals: []kvPair{
+			unsignedTxPair, txVersionPair, inputCountPair,
+			outputCountPair, v2Pair,
+		},
+		wantErr: ErrInvalidPsbtFormat,
+	}, {
+		name: "version 2 without input count",
+		globals: []kvPair{
+			txVersionPair, outputCountPair, v2Pair,
+		},
+		wantErr: ErrInvalidPsbtFormat,
+	}, {
+		name: "duplicate version",
+		globals: []kvPair{
+			txVersionPair, inputCountPair, outputCountPair, v2Pair,
+			v2Pair,
+		},
+		wantErr: ErrDuplicateKey,
+	}, {
+		name: "unsupported version",
+		globals: []kvPair{
+			unsignedTxPair, {uint8(VersionType), []byte{1, 0, 0, 0}},
+		},
+		wantErr: ErrUnsupportedPsbtVersion,
+	}, {
+		name: "version 2 without inputs and outputs",
+		globals: []kvPair{
+			txVersionPair, inputCountPair, outputCountPair, v2Pair,
+		},
+	}}
+	for _, test := range tests {
+		var b bytes.Buffer
+		b.Write(psbtMagic[:])
+		for _, pair := range test.globals {
+			err := serializeKVPairWithType(
+				&b, pair.keyType, nil, pair.value,
+			)
+			require.NoError(t, err)
+		}
+		b.WriteByte(0x00)
+
+		_, err := NewFromRawBytes(&b, false)
+		require.ErrorIsf(t, err, test.wantErr, test.name)
+	}
+}
+
+// TestDetermineLockTime ensures the lock time of version 2 packets is
+// determined from the lock time requirements of the inputs as defined in
+// BIP370.
+func TestDetermineLockTime(t *testing.T) {
+	const (
+		time1 = txscript.LockTimeThreshold
+		time2 = txscript.LockTimeThreshold + 1
+	)
+
+	tests := []struct {
+		name     string
+		inputs   []PInput
+		lockTime uint32
+		wantErr  error
+	}{{
+		name:     "no requirements",
+		inputs:   []PInput{{}, {}},
+		lockTime: 7,
+	}, {
+		name: "heights",
+		inputs: []PInput{
+			{RequiredHeightLockTime: 10},
+			{},
+			{RequiredHeightLockTime: 20},
+		},
+		lockTime: 20,
+	}, {
+		name: "times",
+		inputs: []PInput{
+			{RequiredTimeLockTime: time2},
+			{RequiredTimeLockTime: time1},
+		},
+		lockTime: time2,
+	}, {
+		name: "height preferred",
+		inputs: []PInput{
+			{RequiredTimeLockTime: time1, RequiredHeightLockTime: 10},
+			{RequiredTimeLockTime: time2, RequiredHeightLockTime: 5},
+		},
+		lockTime: 10,
+	}, {
+		name: "time required by an input",
+		inputs: []PInput{
+			{RequiredTimeLockTime: time1, RequiredHeightLockTime: 10},
+			{RequiredTimeLockTime: time2},
+		},
+		lockTime: time2,
+	}, {
+		name: "conflicting requirements",
+		inputs: []PInput{
+			{RequiredHeightLockTime: 10},
+			{RequiredTimeLockTime: time1},
+		},
+		wantErr: ErrConflictingLockTimes,
+	}}
+	for _, test := range tests {
+		packet := &Packet{
+			Version:          2,
+			FallbackLockTime: 7,
+			Inputs:           test.inputs,
+		}
+		lockTime, err := packet.DetermineLockTime()
+		require.ErrorIsf(t, err, test.wantErr, test.name)
+		require.Equalf(t, test.lockTime, lockTime, test.name)
+	}
+}
+
+// TestPsbtV2Modifiable ensures inputs and outputs can only be added to version
+// 2 packets as allowed by their TxModifiable flags, which are updated as
+// signatures are added.
+func TestPsbtV2Modifiable(t *testing.T) {
+	packet := newTestPacketV2(t)
+	updater, err := NewUpdater(packet)
+	require.NoError(t, err)
+
+	// Inputs requiring a conflicting lock time can't be added.
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{3}},
+	}, PInput{RequiredTimeLockTime: txscript.LockTimeThreshold})
+	require.ErrorIs(t, err, ErrConflictingLockTimes)
+
+	// Neither can inputs spending an output already spent by another
+	// input.
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: packet.UnsignedTx.TxIn[0].PreviousOutPoint,
+	}, PInput{})
+	require.ErrorIs(t, err, ErrDuplicateKey)
+
+	// Once an input is signed, inputs changing the lock time can't be
+	// added while the ones keeping it can.
+	packet.Inputs[0].FinalScriptSig = []byte{txscript.OP_TRUE}
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{3}},
+	}, PInput{RequiredHeightLockTime: 400})
+	require.ErrorIs(t, err, ErrTxNotModifiable)
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{3}},
+	}, PInput{RequiredHeightLockTime: 250})
+	require.NoError(t, err)
+	require.Len(t, packet.Inputs, 3)
+	require.Len(t, packet.UnsignedTx.TxIn, 3)
+
+	tests := []struct {
+		hashType     txscript.SigHashType
+		txModifiable uint8
+	}{{
+		hashType:     txscript.SigHashAll | txscript.SigHashAnyOneCanPay,
+		txModifiable: TxModifiableInputs,
+	}, {
+		hashType:     txscript.SigHashNone,
+		txModifiable: TxModifiableOutputs,
+	}, {
+		hashType: txscript.SigHashSingle | txscript.SigHashAnyOneCanPay,
+		txModifiable: TxModifiableInputs | TxModifiableOutputs |
+			TxModifiableSighashSingle,
+	}, {
+		hashType:     txscript.SigHashAll,
+		txModifiable: 0,
+	}}
+	for _, test := range tests {
+		packet.TxModifiable = TxModifiableInputs | TxModifiableOutputs
+		packet.updateTxModifiable(test.hashType)
+		require.Equalf(t, test.txModifiable, packet.TxModifiable,
+			"hash type %v", test.hashType)
+	}
+
+	// Nothing can be added once the flags don't allow it.
+	err = updater.AddInput(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{4}},
+	}, PInput{})
+	require.ErrorIs(t, err, ErrTxNotModifiable)
+	err = updater.AddOutput(wire.NewTxOut(1, nil), POutput{})
+	require.ErrorIs(t, err, ErrTxNotModifiable)
+
+	// Inputs and outputs can't be added to version 0 packets.
+	v0, err := New(nil, nil, 2, 0, nil)
+	require.NoError(t, err)
+	updater, err = NewUpdater(v0)
+	require.NoError(t, err)
+	err = updater.AddOutput(wire.NewTxOut(1, nil), POutput{})
+	require.ErrorIs(t, err, ErrUnsupportedPsbtVersion)
+}
+
+// TestCombine ensures the Combiner merges the data of packets describing the
+// same transaction and rejects packets describing different ones.
+func TestCombine(t *testing.T) {
+	packet1 := newTestPacketV2(t)
+	packet2 := newTestPacketV2(t)
+
+	packet1.Inputs[0].RedeemScript = []byte{txscript.OP_1}
+	packet1.Inputs[1].Unknowns = []*Unknown{{Key: []byte{0xf0}}}
+	packet2.Inputs[0].RedeemScript = []byte{txscript.OP_2}
+	packet2.Inputs[0].WitnessScript = []byte{txscript.OP_3}
+	packet2.Inputs[1].Unknowns = []*Unknown{
+		{Key: []byte{0xf0}, Value: []byte{1}}, {Key: []byte{0xf1}},
+	}
+	packet2.Outputs[0].WitnessScript = []byte{txscript.OP_4}
+	packet2.TxModifiable = TxModifiableInputs | TxModifiableSighashSingle
+
+	combined, err := Combine(packet1, packet2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{txscript.OP_1}, combined.Inputs[0].RedeemScript)
+	require.Equal(t, []byte{txscript.OP_3}, combined.Inputs[0].WitnessScript)
+	require.Equal(t, []*Unknown{
+		{Key: []byte{0xf0}, Value: []byte{}}, {Key: []byte{0xf1}, Value: []byte{}},
+	}, combined.Inputs[1].Unknowns)
+	require.Equal(t, []byte{txscript.OP_4}, combined.Outputs[0].WitnessScript)
+	require.Equal(t, TxModifiableInputs|TxModifiableSighashSingle,
+		combined.TxModifiable)
+
+	// The passed packets are left untouched.
+	require.Nil(t, packet1.Inputs[0].WitnessScript)
+	require.Len(t, packet1.Inputs[1].Unknowns, 1)
+
+	// Packets with different sequence numbers or describing different
+	// transactions can't be combined.
+	packet2.UnsignedTx.TxIn[1].Sequence = 0
+	_, err = Combine(packet1, packet2)
+	require.ErrorIs(t, err, ErrMismatchedPsbts)
+
+	packet2 = newTestPacketV2(t)
+	packet2.UnsignedTx.TxOut[0].Value++
+	_, err = Combine(packet1, packet2)
+	require.ErrorIs(t, err, ErrMismatchedPsbts)
+
+	require.NoError(t, packet2.ConvertToV0())
+	_, err = Combine(packet1, packet2)
+	require.ErrorIs(t, err, ErrMismatchedPsbts)
+}
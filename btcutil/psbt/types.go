@@ -30,6 +30,31 @@ const (
 	// extended public key.
 	XpubType GlobalType = 1
 
+	// TxVersionType is an empty key ({0x02}) that houses the version of
+	// the transaction being created in a version 2 PSBT. The value is a
+	// 32-bit little endian signed integer.
+	TxVersionType GlobalType = 2
+
+	// FallbackLockTimeType is an empty key ({0x03}) that houses the lock
+	// time to use for the transaction of a version 2 PSBT if none of the
+	// inputs require a particular lock time. The value is a 32-bit little
+	// endian unsigned integer. If omitted, the fallback lock time is 0.
+	FallbackLockTimeType GlobalType = 3
+
+	// InputCountType is an empty key ({0x04}) that houses the number of
+	// inputs of a version 2 PSBT as a compact size unsigned integer.
+	InputCountType GlobalType = 4
+
+	// OutputCountType is an empty key ({0x05}) that houses the number of
+	// outputs of a version 2 PSBT as a compact size unsigned integer.
+	OutputCountType GlobalType = 5
+
+	// TxModifiableType is an empty key ({0x06}) that houses an 8-bit
+	// little endian unsigned integer used as a bit field to signal whether
+	// inputs and outputs can be added to a version 2 PSBT. See the
+	// TxModifiable flags for the meaning of the individual bits.
+	TxModifiableType GlobalType = 6
+
 	// VersionType houses the global version number of this PSBT. There is
 	// no key (only contains the byte type), then the value if omitted, is
 	// assumed to be zero.
@@ -151,6 +176,34 @@ const (
 	// 32-byte hash denoting the root hash of a merkle tree of scripts.
 	TaprootMerkleRootType InputType = 0x18
 
+	// PreviousTxidType is an empty key ({0x0e}) that is required for
+	// every input of a version 2 PSBT. The value is the 32-byte hash of
+	// the transaction containing the output the input spends.
+	PreviousTxidType InputType = 0x0e
+
+	// OutputIndexType is an empty key ({0x0f}) that is required for every
+	// input of a version 2 PSBT. The value is the 32-bit little endian
+	// index of the output the input spends.
+	OutputIndexType InputType = 0x0f
+
+	// SequenceType is an empty key ({0x10}) that houses the 32-bit little
+	// endian sequence number of an input of a version 2 PSBT. If omitted,
+	// the sequence number is assumed to be the final one, 0xffffffff.
+	SequenceType InputType = 0x10
+
+	// RequiredTimeLockTimeType is an empty key ({0x11}) that houses the
+	// minimum time based lock time, as a 32-bit little endian unsigned
+	// integer, that the transaction of a version 2 PSBT must have for the
+	// input to be spendable. The value must be at least 500000000.
+	RequiredTimeLockTimeType InputType = 0x11
+
+	// RequiredHeightLockTimeType is an empty key ({0x12}) that houses the
+	// minimum height based lock time, as a 32-bit little endian unsigned
+	// integer, that the transaction of a version 2 PSBT must have for the
+	// input to be spendable. The value must be greater than 0 and less
+	// than 500000000.
+	RequiredHeightLockTimeType InputType = 0x12
+
 	// ProprietaryInputType is a custom type for use by devs.
 	//
 	// The key ({0xFC}|<prefix>|{subtype}|{key data}), is a Variable length
@@ -184,6 +237,15 @@ const (
 	// Public keys are those needed to spend this output.
 	Bip32DerivationOutputType OutputType = 2
 
+	// AmountType is an empty key ({0x03}) that is required for every
+	// output of a version 2 PSBT. The value is the 64-bit little endian
+	// signed integer amount of the output in satoshis.
+	AmountType OutputType = 3
+
+	// ScriptType is an empty key ({0x04}) that is required for every
+	// output of a version 2 PSBT. The value is the script of the output.
+	ScriptType OutputType = 4
+
 	// TaprootInternalKeyOutputType is an empty key ({0x05}). The value is
 	// an x-only pubkey denoting the internal public key used for
 	// constructing a taproot key.
@@ -201,3 +263,21 @@ const (
 	// is then identical to the Bip32DerivationInputType value.
 	TaprootBip32DerivationOutputType OutputType = 7
 )
+
+const (
+	// TxModifiableInputs is the bit of the TxModifiableType field which
+	// signals that inputs can be added to or removed from the version 2
+	// PSBT.
+	TxModifiableInputs uint8 = 1 << 0
+
+	// TxModifiableOutputs is the bit of the TxModifiableType field which
+	// signals that outputs can be added to or removed from the version 2
+	// PSBT.
+	TxModifiableOutputs uint8 = 1 << 1
+
+	// TxModifiableSighashSingle is the bit of the TxModifiableType field
+	// which signals that the version 2 PSBT has a SIGHASH_SINGLE
+	// signature, so the index of the existing inputs and outputs must be
+	// preserved when adding new ones.
+	TxModifiableSighashSingle uint8 = 1 << 2
+)
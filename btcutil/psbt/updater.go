@@ -36,6 +36,76 @@ func NewUpdater(p *Packet) (*Updater, error) {
 
 }
 
+// AddInput adds an input spending the previous outpoint of the passed
+// transaction input, with its sequence number, to a version 2 PSBT along with
+// the data attached to it.  This serves the role of the Constructor of
+// BIP370, so the TxModifiable flags of the packet must allow inputs to be
+// added.  The lock time requirements of the input must be compatible with the
+// ones of the existing inputs, and they can't change the lock time of the
+// transaction once any of the inputs are signed.
+func (u *Updater) AddInput(txIn *wire.TxIn, pInput PInput) error {
+	p := u.Upsbt
+	if p.Version != 2 {
+		return ErrUnsupportedPsbtVersion
+	}
+	if p.TxModifiable&TxModifiableInputs == 0 {
+		return ErrTxNotModifiable
+	}
+	if len(txIn.SignatureScript) != 0 || len(txIn.Witness) != 0 {
+		return ErrInvalidRawTxSigned
+	}
+	if !pInput.IsSane() {
+		return ErrInvalidPsbtFormat
+	}
+
+	// Inputs spending the same output are not allowed.
+	for _, existing := range p.UnsignedTx.TxIn {
+		if existing.PreviousOutPoint == txIn.PreviousOutPoint {
+			return ErrDuplicateKey
+		}
+	}
+
+	// Since existing signatures commit to the lock time, it can only
+	// change when there are none.
+	inputs := append(p.Inputs[:len(p.Inputs):len(p.Inputs)], pInput)
+	lockTime, err := determineLockTime(inputs, p.FallbackLockTime)
+	if err != nil {
+		return err
+	}
+	if lockTime != p.UnsignedTx.LockTime && p.hasSignatures() {
+		return ErrTxNotModifiable
+	}
+
+	p.UnsignedTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: txIn.PreviousOutPoint,
+		Sequence:         txIn.Sequence,
+	})
+	p.UnsignedTx.LockTime = lockTime
+	p.Inputs = inputs
+
+	return nil
+}
+
+// AddOutput adds the passed transaction output to a version 2 PSBT along with
+// the data attached to it.  This serves the role of the Constructor of BIP370,
+// so the TxModifiable flags of the packet must allow outputs to be added.
+// Outputs are always appended, so the index of outputs paired with the inputs
+// of SIGHASH_SINGLE signatures is preserved.
+func (u *Updater) AddOutput(txOut *wire.TxOut, pOutput POutput) error {
+	p := u.Upsbt
+	if p.Version != 2 {
+		return ErrUnsupportedPsbtVersion
+	}
+	if p.TxModifiable&TxModifiableOutputs == 0 {
+		return ErrTxNotModifiable
+	}
+
+	p.UnsignedTx.AddTxOut(wire.NewTxOut(txOut.Value, txOut.PkScript))
+	p.Outputs = append(p.Outputs, pOutput)
+
+	return nil
+}
+
 // AddInNonWitnessUtxo adds the utxo information for an input which is
 // non-witness. This requires provision of a full transaction (which is the
 // source of the corresponding prevOut), and the input index. If addition of
@@ -215,6 +285,7 @@ func (u *Updater) addPartialSignature(inIndex int, sig []byte,
 	u.Upsbt.Inputs[inIndex].PartialSigs = append(
 		u.Upsbt.Inputs[inIndex].PartialSigs, &partialSig,
 	)
+	u.Upsbt.updateTxModifiable(txscript.SigHashType(sig[len(sig)-1]))
 
 	if err := u.Upsbt.SanityCheck(); err != nil {
 		return err
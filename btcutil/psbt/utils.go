@@ -224,6 +224,38 @@ func serializeKVPairWithType(w io.Writer, kt uint8, keydata []byte,
 	return serializeKVpair(w, serializedKey, value)
 }
 
+// serializeUint32KVPair writes out to the passed writer a key-value pair of
+// the passed type without key data whose value is a 32-bit little endian
+// unsigned integer.
+func serializeUint32KVPair(w io.Writer, kt uint8, value uint32) error {
+	var valueBytes [4]byte
+	binary.LittleEndian.PutUint32(valueBytes[:], value)
+
+	return serializeKVPairWithType(w, kt, nil, valueBytes[:])
+}
+
+// readUint32 decodes the passed value of a key-value pair as a 32-bit little
+// endian unsigned integer.
+func readUint32(value []byte) (uint32, error) {
+	if len(value) != 4 {
+		return 0, ErrInvalidKeyData
+	}
+
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+// readCompactSize decodes the passed value of a key-value pair as a compact
+// size unsigned integer.
+func readCompactSize(value []byte) (uint64, error) {
+	r := bytes.NewReader(value)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil || r.Len() != 0 {
+		return 0, ErrInvalidKeyData
+	}
+
+	return n, nil
+}
+
 // getKey retrieves a single key - both the key type and the keydata (if
 // present) from the stream and returns the key type as an integer, or -1 if
 // the key was of zero length. This integer is used to indicate the presence
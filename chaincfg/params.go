@@ -203,6 +203,19 @@ type Params struct {
 	// is reduced.
 	SubsidyReductionInterval int32
 
+	// SubsidyFunc, when set, returns the subsidy amount a block at the
+	// provided height should have.  It allows networks with an emission
+	// schedule other than the halving of the base subsidy every
+	// SubsidyReductionInterval blocks to be defined.  The schedule of
+	// Bitcoin is used when it is nil.
+	SubsidyFunc func(height int32) int64
+
+	// CoinbaseMaturityFunc, when set, returns the number of blocks required
+	// before the coins of the coinbase transaction of a block at the
+	// provided height can be spent.  CoinbaseMaturity applies to blocks at
+	// all heights when it is nil.
+	CoinbaseMaturityFunc func(height int32) uint16
+
 	// TargetTimespan is the desired amount of time that should elapse
 	// before the block difficulty requirement is examined to determine how
 	// it should be changed in order to maintain the desired block
@@ -236,6 +249,26 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// MinimumChainWork is the minimum cumulative work a chain of block
+	// headers must have before the headers are kept and the blocks are
+	// downloaded during the initial sync.  The headers of chains with less
+	// work are only pre-synced without being stored, so peers can't exhaust
+	// the memory with long chains of low work headers.  It is nil for
+	// networks which don't require a minimum.
+	MinimumChainWork *big.Int
+
+	// AssumeValid is the hash of a block whose ancestors are assumed to
+	// have valid scripts, so their scripts are not verified during the
+	// initial sync when the block is part of the downloaded headers.  All
+	// other checks are still performed.  It is nil for networks which
+	// verify all scripts.
+	AssumeValid *chainhash.Hash
+
+	// SignetChallenge is the challenge script the block solutions of a
+	// signet network must satisfy as defined by BIP 325.  It is nil for
+	// networks which are not signets.
+	SignetChallenge []byte
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -348,6 +381,12 @@ var MainNetParams = Params{
 		{810000, newHashFromStr("000000000000000000028028ca82b6aa81ce789e4eb9e0321b74c3cbaf405dd1")},
 	},
 
+	// The cumulative work of the main chain at block 691719.
+	MinimumChainWork: newBigFromHexStr("00000000000000000000000000000000000000001fa4663bbbe19f82de910280"),
+
+	// The block at height 810000.
+	AssumeValid: newHashFromStr("000000000000000000028028ca82b6aa81ce789e4eb9e0321b74c3cbaf405dd1"),
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -593,6 +632,12 @@ var TestNet3Params = Params{
 		{2344474, newHashFromStr("0000000000000004877fa2d36316398528de4f347df2f8a96f76613a298ce060")},
 	},
 
+	// The cumulative work of the main chain at block 2010000.
+	MinimumChainWork: newBigFromHexStr("0000000000000000000000000000000000000000000005180c3bd8290da33a1a"),
+
+	// The block at height 2344474.
+	AssumeValid: newHashFromStr("0000000000000004877fa2d36316398528de4f347df2f8a96f76613a298ce060"),
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -834,6 +879,9 @@ func CustomSignetParams(challenge []byte, dnsSeeds []DNSSeed) Params {
 		// Checkpoints ordered from oldest to newest.
 		Checkpoints: nil,
 
+		// Block solutions must satisfy the challenge of the network.
+		SignetChallenge: challenge,
+
 		// Consensus rule change deployments.
 		//
 		// The miner confirmation window is defined as:
@@ -1071,6 +1119,17 @@ func newHashFromStr(hexStr string) *chainhash.Hash {
 	return hash
 }
 
+// newBigFromHexStr converts the passed big-endian hex string into a big.Int.
+// Like newHashFromStr, it panics on an error since it will only be called with
+// hard-coded values.
+func newBigFromHexStr(hexStr string) *big.Int {
+	n, ok := new(big.Int).SetString(hexStr, 16)
+	if !ok {
+		panic("invalid hex in source file: " + hexStr)
+	}
+	return n
+}
+
 func init() {
 	// Register all default networks when the package is initialized.
 	mustRegister(&MainNetParams)
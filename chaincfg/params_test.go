@@ -104,32 +104,64 @@ func TestSigNetPowLimit(t *testing.T) {
 	}
 }
 
-// compactToBig is a copy of the blockchain.CompactToBig function. We copy it
-// here so we don't run into a circular dependency just because of a test.
-func compactToBig(compact uint32) *big.Int {
-	// Extract the mantissa, sign bit, and exponent.
-	mantissa := compact & 0x007fffff
-	isNegative := compact&0x00800000 != 0
-	exponent := uint(compact >> 24)
-
-	// Since the base for the exponent is 256, the exponent can be treated
-	// as the number of bytes to represent the full 256-bit number.  So,
-	// treat the exponent as the number of bytes and shift the mantissa
-	// right or left accordingly.  This is equivalent to:
-	// N = mantissa * 256^(exponent-3)
-	var bn *big.Int
-	if exponent <= 3 {
-		mantissa >>= 8 * (3 - exponent)
-		bn = big.NewInt(int64(mantissa))
-	} else {
-		bn = big.NewInt(int64(mantissa))
-		bn.Lsh(bn, 8*(exponent-3))
+// TestAssumeValid ensures the assumed valid block of each network is the block
+// at the height it is documented for, which is pinned by the checkpoint at the
+// same height.
+func TestAssumeValid(t *testing.T) {
+	tests := []struct {
+		params *Params
+		height int32
+		hash   string
+	}{
+		{
+			params: &MainNetParams,
+			height: 810000,
+			hash:   "000000000000000000028028ca82b6aa81ce789e4eb9e0321b74c3cbaf405dd1",
+		},
+		{
+			params: &TestNet3Params,
+			height: 2344474,
+			hash:   "0000000000000004877fa2d36316398528de4f347df2f8a96f76613a298ce060",
+		},
 	}
 
-	// Make it negative if the sign bit is set.
-	if isNegative {
-		bn = bn.Neg(bn)
+	for _, test := range tests {
+		name := test.params.Name
+		if test.params.AssumeValid == nil {
+			t.Errorf("%s: no assumed valid block", name)
+			continue
+		}
+		if test.params.AssumeValid.String() != test.hash {
+			t.Errorf("%s: assumed valid block %v, want %s", name,
+				test.params.AssumeValid, test.hash)
+		}
+
+		var checkpoint *Checkpoint
+		for i := range test.params.Checkpoints {
+			if test.params.Checkpoints[i].Height == test.height {
+				checkpoint = &test.params.Checkpoints[i]
+			}
+		}
+		if checkpoint == nil {
+			t.Errorf("%s: no checkpoint at height %d", name,
+				test.height)
+			continue
+		}
+		if !checkpoint.Hash.IsEqual(test.params.AssumeValid) {
+			t.Errorf("%s: checkpoint at height %d is %v, want the "+
+				"assumed valid block %v", name, test.height,
+				checkpoint.Hash, test.params.AssumeValid)
+		}
 	}
 
-	return bn
+	// Networks without a minimum chain work would skip the scripts of any
+	// chain containing the block, so the two must be set together.
+	for _, params := range []*Params{&MainNetParams, &TestNet3Params,
+		&RegressionNetParams, &SimNetParams, &SigNetParams} {
+
+		if params.AssumeValid != nil && params.MinimumChainWork == nil {
+			t.Errorf("%s: assumed valid block without a minimum "+
+				"chain work", params.Name)
+		}
+	}
 }
@@ -0,0 +1,418 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// deploymentNames maps the names of the deployments in parameter files to
+// their IDs.
+var deploymentNames = map[string]int{
+	"testdummy":              DeploymentTestDummy,
+	"testdummyminactivation": DeploymentTestDummyMinActivation,
+	"csv":                    DeploymentCSV,
+	"segwit":                 DeploymentSegwit,
+	"taproot":                DeploymentTaproot,
+}
+
+// hexBytes is a byte slice which is encoded as a hex string in JSON.
+type hexBytes []byte
+
+// UnmarshalJSON decodes the hex string of the byte slice.
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// duration is a time.Duration which is encoded as a duration string such as
+// "10m" in JSON.
+type duration time.Duration
+
+// UnmarshalJSON decodes the duration string.
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// fileDeployment is the definition of a consensus rule change deployment in a
+// parameter file.
+type fileDeployment struct {
+	Bit                 uint8  `json:"bit"`
+	StartTime           int64  `json:"starttime"`
+	Timeout             int64  `json:"timeout"`
+	MinActivationHeight uint32 `json:"minactivationheight"`
+	Threshold           uint32 `json:"threshold"`
+}
+
+// fileCheckpoint is the definition of a checkpoint in a parameter file.
+type fileCheckpoint struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// paramsFile is the JSON definition of network parameters.  All fields but the
+// name and the network magic are optional and default to the value of the
+// parameters of the base network.
+type paramsFile struct {
+	Base        string    `json:"base"`
+	Name        string    `json:"name"`
+	Net         string    `json:"net"`
+	DefaultPort *string   `json:"defaultport"`
+	DNSSeeds    []DNSSeed `json:"dnsseeds"`
+
+	GenesisBlock             hexBytes         `json:"genesisblock"`
+	PowLimitBits             *uint32          `json:"powlimitbits"`
+	PoWNoRetargeting         *bool            `json:"pownoretargeting"`
+	BIP0034Height            *int32           `json:"bip0034height"`
+	BIP0065Height            *int32           `json:"bip0065height"`
+	BIP0066Height            *int32           `json:"bip0066height"`
+	CoinbaseMaturity         *uint16          `json:"coinbasematurity"`
+	SubsidyReductionInterval *int32           `json:"subsidyreductioninterval"`
+	TargetTimespan           *duration        `json:"targettimespan"`
+	TargetTimePerBlock       *duration        `json:"targettimeperblock"`
+	RetargetAdjustmentFactor *int64           `json:"retargetadjustmentfactor"`
+	ReduceMinDifficulty      *bool            `json:"reducemindifficulty"`
+	MinDiffReductionTime     *duration        `json:"mindiffreductiontime"`
+	GenerateSupported        *bool            `json:"generatesupported"`
+	Checkpoints              []fileCheckpoint `json:"checkpoints"`
+	MinimumChainWork         *string          `json:"minimumchainwork"`
+	AssumeValid              *string          `json:"assumevalid"`
+	SignetChallenge          hexBytes         `json:"signetchallenge"`
+
+	RuleChangeActivationThreshold *uint32                   `json:"rulechangeactivationthreshold"`
+	MinerConfirmationWindow       *uint32                   `json:"minerconfirmationwindow"`
+	Deployments                   map[string]fileDeployment `json:"deployments"`
+
+	RelayNonStdTxs *bool `json:"relaynonstdtxs"`
+
+	Bech32HRPSegwit         *string  `json:"bech32hrpsegwit"`
+	PubKeyHashAddrID        *byte    `json:"pubkeyhashaddrid"`
+	ScriptHashAddrID        *byte    `json:"scripthashaddrid"`
+	PrivateKeyID            *byte    `json:"privatekeyid"`
+	WitnessPubKeyHashAddrID *byte    `json:"witnesspubkeyhashaddrid"`
+	WitnessScriptHashAddrID *byte    `json:"witnessscripthashaddrid"`
+	HDPrivateKeyID          hexBytes `json:"hdprivatekeyid"`
+	HDPublicKeyID           hexBytes `json:"hdpublickeyid"`
+	HDCoinType              *uint32  `json:"hdcointype"`
+}
+
+// compactToBig is a copy of the blockchain.CompactToBig function. We copy it
+// here so we don't run into a circular dependency.
+func compactToBig(compact uint32) *big.Int {
+	// Extract the mantissa, sign bit, and exponent.
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	// Since the base for the exponent is 256, the exponent can be treated
+	// as the number of bytes to represent the full 256-bit number.  So,
+	// treat the exponent as the number of bytes and shift the mantissa
+	// right or left accordingly.  This is equivalent to:
+	// N = mantissa * 256^(exponent-3)
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+
+	// Make it negative if the sign bit is set.
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+
+	return bn
+}
+
+// baseParams returns the parameters of the named network which custom
+// parameters are based on.
+func baseParams(name string) (Params, error) {
+	for _, params := range []*Params{
+		&MainNetParams, &TestNet3Params, &RegressionNetParams,
+		&SimNetParams, &SigNetParams,
+	} {
+		if params.Name == name {
+			return *params, nil
+		}
+	}
+
+	return Params{}, fmt.Errorf("unknown base network %q", name)
+}
+
+// LoadParams reads the JSON definition of custom network parameters from the
+// passed reader, which allows to run private networks without changing the
+// code.
+//
+// The parameters start out as a copy of the parameters of the network named
+// by the "base" field, which defaults to regtest, and the fields which are
+// present in the definition override them.  The "name" of the network and its
+// "net" magic, given as a number such as "0xd9b4bef9", are required, while
+// the genesis block is given as the hex encoded serialized block.  Durations
+// are given as strings such as "10m", and the deployments are keyed by their
+// lowercase names such as "taproot", with their start time and timeout given
+// as unix timestamps, where zero means always available and never expiring
+// respectively.
+//
+// Unknown fields are ignored so that applications can keep settings of their
+// own in the same file.
+//
+// NOTE: The returned parameters must be registered with Register before they
+// are used to decode addresses.
+func LoadParams(r io.Reader) (*Params, error) {
+	var file paramsFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+
+	if file.Base == "" {
+		file.Base = RegressionNetParams.Name
+	}
+	params, err := baseParams(file.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Name == "" {
+		return nil, errors.New("missing network name")
+	}
+	if _, err := baseParams(file.Name); err == nil {
+		return nil, fmt.Errorf("network name %q is already used",
+			file.Name)
+	}
+	params.Name = file.Name
+
+	if file.Net == "" {
+		return nil, errors.New("missing network magic")
+	}
+	net, err := strconv.ParseUint(file.Net, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network magic: %v", err)
+	}
+	params.Net = wire.BitcoinNet(net)
+
+	if file.DefaultPort != nil {
+		params.DefaultPort = *file.DefaultPort
+	}
+	if file.DNSSeeds != nil {
+		params.DNSSeeds = file.DNSSeeds
+	}
+
+	if file.GenesisBlock != nil {
+		var genesis wire.MsgBlock
+		err := genesis.Deserialize(bytes.NewReader(file.GenesisBlock))
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis block: %v", err)
+		}
+		if len(genesis.Transactions) == 0 {
+			return nil, errors.New("genesis block has no transactions")
+		}
+		hash := genesis.BlockHash()
+		params.GenesisBlock = &genesis
+		params.GenesisHash = &hash
+	}
+
+	if file.PowLimitBits != nil {
+		params.PowLimitBits = *file.PowLimitBits
+		params.PowLimit = compactToBig(*file.PowLimitBits)
+		if params.PowLimit.Sign() <= 0 {
+			return nil, errors.New("invalid proof of work limit")
+		}
+	}
+
+	setBool := func(dst *bool, src *bool) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setInt32 := func(dst *int32, src *int32) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setUint32 := func(dst *uint32, src *uint32) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setDuration := func(dst *time.Duration, src *duration) {
+		if src != nil {
+			*dst = time.Duration(*src)
+		}
+	}
+	setByte := func(dst *byte, src *byte) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	setBool(&params.PoWNoRetargeting, file.PoWNoRetargeting)
+	setInt32(&params.BIP0034Height, file.BIP0034Height)
+	setInt32(&params.BIP0065Height, file.BIP0065Height)
+	setInt32(&params.BIP0066Height, file.BIP0066Height)
+	if file.CoinbaseMaturity != nil {
+		params.CoinbaseMaturity = *file.CoinbaseMaturity
+	}
+	setInt32(&params.SubsidyReductionInterval,
+		file.SubsidyReductionInterval)
+	setDuration(&params.TargetTimespan, file.TargetTimespan)
+	setDuration(&params.TargetTimePerBlock, file.TargetTimePerBlock)
+	if file.RetargetAdjustmentFactor != nil {
+		params.RetargetAdjustmentFactor = *file.RetargetAdjustmentFactor
+	}
+	setBool(&params.ReduceMinDifficulty, file.ReduceMinDifficulty)
+	setDuration(&params.MinDiffReductionTime, file.MinDiffReductionTime)
+	setBool(&params.GenerateSupported, file.GenerateSupported)
+	if params.TargetTimePerBlock <= 0 ||
+		params.TargetTimespan < params.TargetTimePerBlock {
+
+		return nil, errors.New("invalid target block times")
+	}
+
+	// Checkpoints of the base network can't be valid for a network with a
+	// different genesis block, so they are only kept when the genesis
+	// block is the same.
+	if file.GenesisBlock != nil {
+		params.Checkpoints = nil
+	}
+	if file.Checkpoints != nil {
+		params.Checkpoints = make([]Checkpoint, 0, len(file.Checkpoints))
+		for _, c := range file.Checkpoints {
+			hash, err := chainhash.NewHashFromStr(c.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("invalid checkpoint "+
+					"hash: %v", err)
+			}
+			params.Checkpoints = append(params.Checkpoints,
+				Checkpoint{Height: c.Height, Hash: hash})
+		}
+	}
+
+	// Neither can the minimum chain work of the base network.
+	if file.GenesisBlock != nil {
+		params.MinimumChainWork = nil
+	}
+	if file.MinimumChainWork != nil {
+		work, ok := new(big.Int).SetString(*file.MinimumChainWork, 16)
+		if !ok || work.Sign() < 0 {
+			return nil, fmt.Errorf("invalid minimum chain work %q",
+				*file.MinimumChainWork)
+		}
+		params.MinimumChainWork = work
+	}
+
+	// Nor can the assumed valid block of the base network.
+	if file.GenesisBlock != nil {
+		params.AssumeValid = nil
+	}
+	if file.AssumeValid != nil {
+		hash, err := chainhash.NewHashFromStr(*file.AssumeValid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assumed valid block "+
+				"hash: %v", err)
+		}
+		params.AssumeValid = hash
+	}
+
+	if file.SignetChallenge != nil {
+		params.SignetChallenge = file.SignetChallenge
+	}
+
+	setUint32(&params.RuleChangeActivationThreshold,
+		file.RuleChangeActivationThreshold)
+	setUint32(&params.MinerConfirmationWindow,
+		file.MinerConfirmationWindow)
+	if params.MinerConfirmationWindow == 0 ||
+		params.RuleChangeActivationThreshold >
+			params.MinerConfirmationWindow {
+
+		return nil, errors.New("invalid rule change activation " +
+			"threshold or miner confirmation window")
+	}
+	for name, d := range file.Deployments {
+		id, ok := deploymentNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown deployment %q", name)
+		}
+		if d.Bit >= 29 {
+			return nil, fmt.Errorf("deployment %q: invalid bit %d",
+				name, d.Bit)
+		}
+
+		var start, end time.Time
+		if d.StartTime != 0 {
+			start = time.Unix(d.StartTime, 0)
+		}
+		if d.Timeout != 0 {
+			end = time.Unix(d.Timeout, 0)
+		}
+		params.Deployments[id] = ConsensusDeployment{
+			BitNumber:                 d.Bit,
+			MinActivationHeight:       d.MinActivationHeight,
+			CustomActivationThreshold: d.Threshold,
+			DeploymentStarter:         NewMedianTimeDeploymentStarter(start),
+			DeploymentEnder:           NewMedianTimeDeploymentEnder(end),
+		}
+	}
+
+	setBool(&params.RelayNonStdTxs, file.RelayNonStdTxs)
+
+	if file.Bech32HRPSegwit != nil {
+		params.Bech32HRPSegwit = *file.Bech32HRPSegwit
+	}
+	setByte(&params.PubKeyHashAddrID, file.PubKeyHashAddrID)
+	setByte(&params.ScriptHashAddrID, file.ScriptHashAddrID)
+	setByte(&params.PrivateKeyID, file.PrivateKeyID)
+	setByte(&params.WitnessPubKeyHashAddrID, file.WitnessPubKeyHashAddrID)
+	setByte(&params.WitnessScriptHashAddrID, file.WitnessScriptHashAddrID)
+	for _, hdKeyID := range []struct {
+		dst *[4]byte
+		src hexBytes
+	}{
+		{&params.HDPrivateKeyID, file.HDPrivateKeyID},
+		{&params.HDPublicKeyID, file.HDPublicKeyID},
+	} {
+		if hdKeyID.src == nil {
+			continue
+		}
+		if len(hdKeyID.src) != 4 {
+			return nil, fmt.Errorf("invalid hd key id %x",
+				[]byte(hdKeyID.src))
+		}
+		copy(hdKeyID.dst[:], hdKeyID.src)
+	}
+	setUint32(&params.HDCoinType, file.HDCoinType)
+
+	return &params, nil
+}
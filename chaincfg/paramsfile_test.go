@@ -0,0 +1,158 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestLoadParams ensures custom network parameters are loaded on top of the
+// parameters of their base network.
+func TestLoadParams(t *testing.T) {
+	t.Parallel()
+
+	// Use a copy of the regtest genesis block with another timestamp.
+	genesis := *RegressionNetParams.GenesisBlock
+	genesis.Header.Timestamp = time.Unix(1700000000, 0)
+	var buf bytes.Buffer
+	if err := genesis.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+
+	def := `{
+		"name": "devnet",
+		"net": "0x0b2c3d4e",
+		"defaultport": "28444",
+		"rpcport": "28445",
+		"dnsseeds": [{"host": "seed.devnet.example", "hasfiltering": true}],
+		"genesisblock": "` + hex.EncodeToString(buf.Bytes()) + `",
+		"targettimeperblock": "1m",
+		"coinbasematurity": 10,
+		"minimumchainwork": "0100000000",
+		"assumevalid": "000000000000000000000000000000000000000000000000000000000000abcd",
+		"bech32hrpsegwit": "dvt",
+		"pubkeyhashaddrid": 30,
+		"hdpublickeyid": "0488b21e",
+		"deployments": {
+			"taproot": {"bit": 2, "starttime": 1700000000}
+		}
+	}`
+	params, err := LoadParams(strings.NewReader(def))
+	if err != nil {
+		t.Fatalf("LoadParams: unexpected error: %v", err)
+	}
+
+	hash := genesis.BlockHash()
+	switch {
+	case params.Name != "devnet":
+		t.Errorf("unexpected name %q", params.Name)
+	case params.Net != wire.BitcoinNet(0x0b2c3d4e):
+		t.Errorf("unexpected net %v", params.Net)
+	case params.DefaultPort != "28444":
+		t.Errorf("unexpected default port %q", params.DefaultPort)
+	case len(params.DNSSeeds) != 1 || !params.DNSSeeds[0].HasFiltering ||
+		params.DNSSeeds[0].Host != "seed.devnet.example":
+		t.Errorf("unexpected dns seeds %v", params.DNSSeeds)
+	case *params.GenesisHash != hash:
+		t.Errorf("unexpected genesis hash %v", params.GenesisHash)
+	case params.TargetTimePerBlock != time.Minute:
+		t.Errorf("unexpected target time per block %v",
+			params.TargetTimePerBlock)
+	case params.CoinbaseMaturity != 10:
+		t.Errorf("unexpected coinbase maturity %d",
+			params.CoinbaseMaturity)
+	case params.Bech32HRPSegwit != "dvt":
+		t.Errorf("unexpected hrp %q", params.Bech32HRPSegwit)
+	case params.PubKeyHashAddrID != 30:
+		t.Errorf("unexpected pubkey hash id %d",
+			params.PubKeyHashAddrID)
+	case params.HDPublicKeyID != [4]byte{0x04, 0x88, 0xb2, 0x1e}:
+		t.Errorf("unexpected hd public key id %x", params.HDPublicKeyID)
+	case params.MinimumChainWork.Int64() != 1<<32:
+		t.Errorf("unexpected minimum chain work %v",
+			params.MinimumChainWork)
+	case params.AssumeValid == nil || params.AssumeValid[0] != 0xcd:
+		t.Errorf("unexpected assumed valid block %v", params.AssumeValid)
+	}
+
+	// The fields which aren't defined are the ones of regtest.
+	if params.PowLimit.Cmp(RegressionNetParams.PowLimit) != 0 ||
+		params.TargetTimespan != RegressionNetParams.TargetTimespan ||
+		params.ScriptHashAddrID != RegressionNetParams.ScriptHashAddrID {
+
+		t.Errorf("undefined fields not inherited from regtest")
+	}
+
+	taproot := params.Deployments[DeploymentTaproot]
+	starter := taproot.DeploymentStarter.(*MedianTimeDeploymentStarter)
+	ender := taproot.DeploymentEnder.(*MedianTimeDeploymentEnder)
+	if taproot.BitNumber != 2 ||
+		!starter.StartTime().Equal(time.Unix(1700000000, 0)) ||
+		!ender.EndTime().IsZero() {
+
+		t.Errorf("unexpected taproot deployment %+v", taproot)
+	}
+
+	// Loading the parameters must not change the base network.
+	regtestTaproot := RegressionNetParams.Deployments[DeploymentTaproot]
+	if RegressionNetParams.Name != "regtest" ||
+		regtestTaproot.DeploymentStarter == taproot.DeploymentStarter {
+
+		t.Errorf("base network parameters changed")
+	}
+}
+
+// TestLoadParamsInvalid ensures invalid network parameters are rejected.
+func TestLoadParamsInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		def  string
+	}{
+		{"invalid json", `{`},
+		{"missing name", `{"net": "0x0b2c3d4e"}`},
+		{"missing net", `{"name": "devnet"}`},
+		{"builtin name", `{"name": "regtest", "net": "0x0b2c3d4e"}`},
+		{"invalid net", `{"name": "devnet", "net": "magic"}`},
+		{"unknown base", `{"base": "x", "name": "devnet", "net": "1"}`},
+		{"invalid genesis", `{"name": "devnet", "net": "1",
+			"genesisblock": "00"}`},
+		{"invalid duration", `{"name": "devnet", "net": "1",
+			"targettimespan": "1"}`},
+		{"invalid block times", `{"name": "devnet", "net": "1",
+			"targettimespan": "1m", "targettimeperblock": "10m"}`},
+		{"invalid pow limit", `{"name": "devnet", "net": "1",
+			"powlimitbits": 0}`},
+		{"unknown deployment", `{"name": "devnet", "net": "1",
+			"deployments": {"x": {"bit": 1}}}`},
+		{"invalid deployment bit", `{"name": "devnet", "net": "1",
+			"deployments": {"csv": {"bit": 29}}}`},
+		{"invalid threshold", `{"name": "devnet", "net": "1",
+			"rulechangeactivationthreshold": 200,
+			"minerconfirmationwindow": 100}`},
+		{"invalid hd key id", `{"name": "devnet", "net": "1",
+			"hdprivatekeyid": "0102"}`},
+		{"invalid checkpoint", `{"name": "devnet", "net": "1",
+			"checkpoints": [{"height": 1, "hash": "xyz"}]}`},
+		{"invalid minimum chain work", `{"name": "devnet", "net": "1",
+			"minimumchainwork": "xyz"}`},
+		{"invalid assumed valid block", `{"name": "devnet", "net": "1",
+			"assumevalid": "xyz"}`},
+	}
+
+	for _, test := range tests {
+		_, err := LoadParams(strings.NewReader(test.def))
+		if err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+}
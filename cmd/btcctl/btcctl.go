@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -16,8 +17,15 @@ import (
 const (
 	showHelpMessage = "Specify -h to show available options"
 	listCmdMessage  = "Specify -l to list available commands"
+
+	// jsonPretty and jsonCompact are the formats of the --json option.
+	jsonPretty  = "pretty"
+	jsonCompact = "compact"
 )
 
+// errNoMoreLines is returned by readStdinLine when stdin has no more lines.
+var errNoMoreLines = errors.New("not enough lines provided on stdin")
+
 // commandUsage display the usage for a specific command.
 func commandUsage(method string) {
 	usage, err := btcjson.MethodUsageText(method)
@@ -40,12 +48,26 @@ func usage(errorMessage string) {
 	appName = strings.TrimSuffix(appName, filepath.Ext(appName))
 	fmt.Fprintln(os.Stderr, errorMessage)
 	fmt.Fprintln(os.Stderr, "Usage:")
-	fmt.Fprintf(os.Stderr, "  %s [OPTIONS] <command> <args...>\n\n",
+	fmt.Fprintf(os.Stderr, "  %s [OPTIONS] <command> <args...>\n",
 		appName)
+	fmt.Fprintf(os.Stderr, "  %s [OPTIONS] --named <command> "+
+		"<name=value...>\n\n", appName)
 	fmt.Fprintln(os.Stderr, showHelpMessage)
 	fmt.Fprintln(os.Stderr, listCmdMessage)
 }
 
+// readStdinLine returns the next line from stdin without its line ending.
+func readStdinLine(bio *bufio.Reader) (string, error) {
+	line, err := bio.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read data from stdin: %v", err)
+	}
+	if err == io.EOF && len(line) == 0 {
+		return "", errNoMoreLines
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
 func main() {
 	cfg, args, err := loadConfig()
 	if err != nil {
@@ -72,39 +94,81 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Convert remaining command line args to a slice of interface values
-	// to be passed along as parameters to new command creation function.
-	//
 	// Since some commands, such as submitblock, can involve data which is
 	// too large for the Operating System to allow as a normal command line
-	// parameter, support using '-' as an argument to allow the argument
-	// to be read from a stdin pipe.
+	// parameter, support using '-' as an argument, or as the value of a
+	// named argument, to allow the argument to be read from a stdin pipe.
+	// All remaining lines are appended to the arguments when the --stdin
+	// option is specified.
 	bio := bufio.NewReader(os.Stdin)
-	params := make([]interface{}, 0, len(args[1:]))
+	cmdArgs := make([]string, 0, len(args[1:]))
 	for _, arg := range args[1:] {
-		if arg == "-" {
-			param, err := bio.ReadString('\n')
-			if err != nil && err != io.EOF {
-				fmt.Fprintf(os.Stderr, "Failed to read data "+
-					"from stdin: %v\n", err)
+		switch {
+		case arg == "-":
+			param, err := readStdinLine(bio)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
-			if err == io.EOF && len(param) == 0 {
-				fmt.Fprintln(os.Stderr, "Not enough lines "+
-					"provided on stdin")
+			arg = param
+
+		case cfg.Named && strings.HasSuffix(arg, "=-"):
+			param, err := readStdinLine(bio)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
-			param = strings.TrimRight(param, "\r\n")
-			params = append(params, param)
-			continue
+			arg = strings.TrimSuffix(arg, "-") + param
+		}
+		cmdArgs = append(cmdArgs, arg)
+	}
+	if cfg.Stdin {
+		for {
+			param, err := readStdinLine(bio)
+			if err == errNoMoreLines {
+				break
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			cmdArgs = append(cmdArgs, param)
 		}
-
-		params = append(params, arg)
 	}
 
 	// Attempt to create the appropriate command using the arguments
-	// provided by the user.
-	cmd, err := btcjson.NewCmd(method, params...)
+	// provided by the user, either by position or by name.
+	var cmd interface{}
+	if cfg.Named {
+		namedParams := make(map[string]interface{}, len(cmdArgs))
+		for _, arg := range cmdArgs {
+			parts := strings.SplitN(arg, "=", 2)
+			if len(parts) != 2 {
+				fmt.Fprintf(os.Stderr, "%s command: argument %q "+
+					"is not in the form name=value\n", method,
+					arg)
+				commandUsage(method)
+				os.Exit(1)
+			}
+			if _, ok := namedParams[parts[0]]; ok {
+				fmt.Fprintf(os.Stderr, "%s command: parameter "+
+					"'%s' specified more than once\n", method,
+					parts[0])
+				commandUsage(method)
+				os.Exit(1)
+			}
+			namedParams[parts[0]] = parts[1]
+		}
+		cmd, err = btcjson.NewCmdNamed(method, namedParams)
+	} else {
+		// Convert the args to a slice of interface values to be passed
+		// along as parameters to new command creation function.
+		params := make([]interface{}, 0, len(cmdArgs))
+		for _, arg := range cmdArgs {
+			params = append(params, arg)
+		}
+		cmd, err = btcjson.NewCmd(method, params...)
+	}
 	if err != nil {
 		// Show the error along with its error code when it's a
 		// btcjson.Error as it reallistcally will always be since the
@@ -141,27 +205,52 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := displayResult(result, cfg.JSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// displayResult writes the passed JSON result of a command to stdout.  When a
+// JSON format is specified, the result is written as returned by the server,
+// either indented (pretty) or on a single line (compact).  Otherwise, the way it
+// is displayed is chosen based on its type, so that, for example, strings are
+// written without quotes.
+func displayResult(result []byte, jsonFormat string) error {
+	var dst bytes.Buffer
+	switch jsonFormat {
+	case jsonPretty:
+		if err := json.Indent(&dst, result, "", "  "); err != nil {
+			return fmt.Errorf("failed to format result: %v", err)
+		}
+		fmt.Println(dst.String())
+		return nil
+
+	case jsonCompact:
+		if err := json.Compact(&dst, result); err != nil {
+			return fmt.Errorf("failed to format result: %v", err)
+		}
+		fmt.Println(dst.String())
+		return nil
+	}
+
 	// Choose how to display the result based on its type.
 	strResult := string(result)
 	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
-		var dst bytes.Buffer
 		if err := json.Indent(&dst, result, "", "  "); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to format result: %v",
-				err)
-			os.Exit(1)
+			return fmt.Errorf("failed to format result: %v", err)
 		}
 		fmt.Println(dst.String())
 
 	} else if strings.HasPrefix(strResult, `"`) {
 		var str string
 		if err := json.Unmarshal(result, &str); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to unmarshal result: %v",
-				err)
-			os.Exit(1)
+			return fmt.Errorf("failed to unmarshal result: %v", err)
 		}
 		fmt.Println(str)
 
 	} else if strResult != "null" {
 		fmt.Println(strResult)
 	}
+	return nil
 }
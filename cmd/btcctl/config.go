@@ -94,17 +94,21 @@ func listCommands() {
 // See loadConfig for details on the configuration load process.
 type config struct {
 	ConfigFile     string `short:"C" long:"configfile" description:"Path to configuration file"`
+	JSON           string `long:"json" optional:"yes" optional-value:"pretty" choice:"pretty" choice:"compact" description:"Display the result as the JSON returned by the server, indented (pretty) or on a single line (compact)"`
 	ListCommands   bool   `short:"l" long:"listcommands" description:"List all of the supported commands and exit"`
+	Named          bool   `long:"named" description:"Pass the parameters of the command by name in the form name=value (eg. getblock blockhash=<hash> verbosity=2)"`
 	NoTLS          bool   `long:"notls" description:"Disable TLS"`
 	Proxy          string `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyPass      string `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	ProxyUser      string `long:"proxyuser" description:"Username for proxy server"`
 	RegressionTest bool   `long:"regtest" description:"Connect to the regression test network"`
 	RPCCert        string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
+	RPCCookieFile  string `long:"rpccookiefile" description:"Authentication cookie written by btcd to use when no rpcuser and rpcpass are specified (default: .cookie in the btcd data directory of the network)"`
 	RPCPassword    string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
 	RPCServer      string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
 	RPCUser        string `short:"u" long:"rpcuser" description:"RPC username"`
 	SimNet         bool   `long:"simnet" description:"Connect to the simulation test network"`
+	Stdin          bool   `long:"stdin" description:"Read additional parameters of the command from standard input, one per line, after those on the command line"`
 	TLSSkipVerify  bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	TestNet3       bool   `long:"testnet" description:"Connect to testnet"`
 	SigNet         bool   `long:"signet" description:"Connect to signet"`
@@ -206,7 +210,9 @@ func loadConfig() (*config, []string, error) {
 			fmt.Fprintln(os.Stderr, "The special parameter `-` "+
 				"indicates that a parameter should be read "+
 				"from the\nnext unread line from standard "+
-				"input.")
+				"input.  With --named, the value of a "+
+				"parameter\nis read from it when specified "+
+				"as name=-.")
 			return nil, nil, err
 		}
 	}
@@ -302,6 +308,17 @@ func loadConfig() (*config, []string, error) {
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
 
+	// Authenticate with the cookie written by btcd when no credentials
+	// were specified.
+	if cfg.RPCUser == "" && cfg.RPCPassword == "" && !cfg.Wallet {
+		err := readCookieFile(&cfg, network)
+		if err != nil && cfg.RPCCookieFile != "" {
+			fmt.Fprintf(os.Stderr, "Error reading cookie file: %v\n",
+				err)
+			return nil, nil, err
+		}
+	}
+
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
 	cfg.RPCServer, err = normalizeAddress(cfg.RPCServer, network, cfg.Wallet)
@@ -312,6 +329,31 @@ func loadConfig() (*config, []string, error) {
 	return &cfg, remainingArgs, nil
 }
 
+// readCookieFile sets the RPC user and password to the ones in the cookie file
+// written by btcd for the passed network.
+func readCookieFile(cfg *config, network *chaincfg.Params) error {
+	path := cfg.RPCCookieFile
+	if path == "" {
+		// The data directory of testnet3 is named testnet.
+		netName := network.Name
+		if network == &chaincfg.TestNet3Params {
+			netName = "testnet"
+		}
+		path = filepath.Join(btcdHomeDir, "data", netName, ".cookie")
+	}
+
+	cookie, err := ioutil.ReadFile(cleanAndExpandPath(path))
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(cookie)), ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("malformed cookie file %s", path)
+	}
+	cfg.RPCUser, cfg.RPCPassword = parts[0], parts[1]
+	return nil
+}
+
 // createDefaultConfig creates a basic config file at the given destination path.
 // For this it tries to read the config file for the RPC server (either btcd or
 // btcwallet), and extract the RPC user and password from it.
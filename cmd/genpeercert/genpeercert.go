@@ -0,0 +1,81 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/peerauth"
+	flags "github.com/jessevdk/go-flags"
+)
+
+type config struct {
+	GenKey       bool   `long:"genkey" description:"Generate a new key pair for an authority or a node"`
+	AuthorityKey string `long:"authoritykey" description:"Hex-encoded private key of the authority to issue the certificate with"`
+	NodeKey      string `long:"nodekey" description:"Hex-encoded compressed public key of the node to issue the certificate for"`
+	Days         int    `long:"days" description:"How many days the certificate is valid for (0 for no expiry)"`
+}
+
+func main() {
+	var cfg config
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+
+	if cfg.GenKey {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot generate key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("private key: %x\n", key.Serialize())
+		fmt.Printf("public key:  %x\n", key.PubKey().SerializeCompressed())
+		return
+	}
+
+	if cfg.AuthorityKey == "" || cfg.NodeKey == "" {
+		fmt.Fprintln(os.Stderr, "both --authoritykey and --nodekey are "+
+			"required to issue a certificate")
+		parser.WriteHelp(os.Stderr)
+		os.Exit(1)
+	}
+	authorityKeyBytes, err := hex.DecodeString(cfg.AuthorityKey)
+	if err != nil || len(authorityKeyBytes) != btcec.PrivKeyBytesLen {
+		fmt.Fprintln(os.Stderr, "--authoritykey is not a hex-encoded "+
+			"private key")
+		os.Exit(1)
+	}
+	authorityKey, _ := btcec.PrivKeyFromBytes(authorityKeyBytes)
+	nodeKeyBytes, err := hex.DecodeString(cfg.NodeKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "--nodekey is not hex-encoded")
+		os.Exit(1)
+	}
+	nodeKey, err := btcec.ParsePubKey(nodeKeyBytes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --nodekey: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Days < 0 {
+		fmt.Fprintln(os.Stderr, "--days may not be negative")
+		os.Exit(1)
+	}
+
+	var expiry time.Time
+	if cfg.Days > 0 {
+		expiry = time.Now().AddDate(0, 0, cfg.Days)
+	}
+	cert := peerauth.IssueCertificate(authorityKey, nodeKey, expiry)
+	fmt.Printf("%x\n", cert.Serialize())
+}
@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"os"
 	"path/filepath"
@@ -22,15 +23,23 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blocktrace"
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
-	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/logging"
 	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/mining/cpuminer"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/peerauth"
+	"github.com/btcsuite/btcd/policy"
+	"github.com/btcsuite/btcd/sv2"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmq"
 	"github.com/btcsuite/go-socks/socks"
 	flags "github.com/jessevdk/go-flags"
 )
@@ -41,6 +50,9 @@ const (
 	defaultLogLevel              = "info"
 	defaultLogDirname            = "logs"
 	defaultLogFilename           = "btcd.log"
+	defaultLogFormat             = "text"
+	defaultLogMaxSize            = 10
+	defaultMaxLogFiles           = 3
 	defaultMaxPeers              = 125
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 100
@@ -48,6 +60,11 @@ const (
 	defaultMaxRPCClients         = 10
 	defaultMaxRPCWebsockets      = 25
 	defaultMaxRPCConcurrentReqs  = 20
+	defaultRPCIdleTimeout        = 2 * time.Minute
+	defaultRPCMaxStreams         = 100
+	defaultRPCSlowThreshold      = 5 * time.Second
+	defaultRescanNtfnInterval    = 10 * time.Second
+	defaultRebroadcastInterval   = 10 * time.Minute
 	defaultDbType                = "ffldb"
 	defaultFreeTxRelayLimit      = 15.0
 	defaultTrickleInterval       = peer.DefaultTrickleInterval
@@ -55,16 +72,31 @@ const (
 	defaultBlockMaxSize          = 750000
 	defaultBlockMinWeight        = 0
 	defaultBlockMaxWeight        = 3000000
+	defaultBlockTemplateStale    = 2 * time.Minute
 	blockMaxSizeMin              = 1000
 	blockMaxSizeMax              = blockchain.MaxBlockBaseSize - 1000
 	blockMaxWeightMin            = 4000
 	blockMaxWeightMax            = blockchain.MaxBlockWeight - 4000
 	defaultGenerate              = false
+	defaultMaxMempool            = mempool.DefaultMaxPoolSize / 1000000
+	minMaxMempool                = 5
 	defaultMaxOrphanTransactions = 100
+	defaultMaxOrphanWeight       = mempool.DefaultMaxOrphanWeight
+	defaultMaxOrphanPeerWeight   = mempool.DefaultMaxOrphanPeerWeight
+	defaultOrphanExpiry          = mempool.DefaultOrphanTTL
+	defaultLimitAncestorCount    = mempool.DefaultMaxAncestorCount
+	defaultLimitAncestorSize     = mempool.DefaultMaxAncestorSize / 1000
+	defaultLimitDescendantCount  = mempool.DefaultMaxDescendantCount
+	defaultLimitDescendantSize   = mempool.DefaultMaxDescendantSize / 1000
 	defaultMaxOrphanTxSize       = 100000
 	defaultSigCacheMaxSize       = 100000
 	defaultUtxoCacheMaxSizeMiB   = 250
+	defaultFinalityDepth         = 6
+	defaultCheckBlocks           = 6
+	defaultCheckLevel            = 3
 	sampleConfigFilename         = "sample-btcd.conf"
+	defaultDNSSeederListen       = ":53"
+	defaultDNSSeederCrawlers     = 8
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
 	pruneMinSize                 = 1536
@@ -102,33 +134,87 @@ type config struct {
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	AgentBlacklist       []string      `long:"agentblacklist" description:"A comma separated list of user-agent substrings which will cause btcd to reject any peers whose user-agent contains any of the blacklisted substrings."`
 	AgentWhitelist       []string      `long:"agentwhitelist" description:"A comma separated list of user-agent substrings which will cause btcd to require all peers' user-agents to contain one of the whitelisted substrings. The blacklist is applied before the whitelist, and an empty whitelist will allow all agents that do not fail the blacklist."`
-	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
-	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
+	ASMap                string        `long:"asmap" description:"Path to a file mapping IP prefixes to the AS numbers announcing them, with one '<prefix> AS<number>' line per prefix, which is used to diversify outbound connections across network operators"`
+	AssumeValid          string        `long:"assumevalid" description:"Hash of a block whose ancestors are processed without verifying their scripts during the initial sync -- All other checks are still performed, and 0 verifies all scripts (default: the value of the network)"`
+	BalanceIndex         bool          `long:"balanceindex" description:"Maintain an index of the balance and unspent outputs of every script which makes the getaddressbalance and getaddressutxos RPCs available"`
+	BanDuration          time.Duration `long:"banduration" description:"How long to discourage misbehaving peers -- Discouraged peers are not connected to and their inbound connections are only accepted when there is room to spare.  Valid time units are {s, m, h}.  Minimum 1 second"`
+	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and discouraging misbehaving peers."`
+	BlockArena           bool          `long:"blockarena" description:"Allocate the transactions of each decoded block from a per-block arena to reduce garbage collection pressure"`
 	BlockMaxSize         uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
 	BlockMinSize         uint32        `long:"blockminsize" description:"Minimum block size in bytes to be used when creating a block"`
 	BlockMaxWeight       uint32        `long:"blockmaxweight" description:"Maximum block weight to be used when creating a block"`
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Minimum block weight to be used when creating a block"`
-	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
+	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Deprecated: Has no effect since transactions are selected by the fee rate of their packages"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
+	BlockTemplateStale   time.Duration `long:"blocktemplatestale" description:"Maximum time the transactions of block templates are incrementally updated as the mempool changes before they are selected from scratch again -- 0 selects them from scratch for every template.  Valid time units are {s, m, h}"`
+	BlockTraceEndpoint   string        `long:"blocktraceendpoint" description:"Export traces of the lifecycle of blocks relayed at the tip of the chain to the OTLP/HTTP endpoint of an OpenTelemetry collector (e.g. http://localhost:4318)"`
+	CaptureMessages      bool          `long:"capturemessages" description:"Record the P2P messages sent to and received from each peer in the message_capture directory of the data directory -- Use the parsemessages command of dbtool to decode them"`
+	CheckBlocks          int32         `long:"checkblocks" description:"Number of the most recent blocks to verify at startup -- 0 verifies all blocks, which requires a checklevel of at most 2"`
+	CheckLevel           int32         `long:"checklevel" description:"How thorough the verification of the blocks at startup is: 0 checks the block index, 1 the stored blocks, 2 their checksums and sanity, 3 their undo data against the utxo set, and 4 reconnects them against the utxo set"`
+	CJDNSReachable       bool          `long:"cjdnsreachable" description:"Connect to CJDNS addresses (FC00::/8), which requires this host to be connected to the CJDNS network"`
 	ConfigFile           string        `short:"C" long:"configfile" description:"Path to configuration file"`
 	ConnectPeers         []string      `long:"connect" description:"Connect only to the specified peers at startup"`
+	ChainParamsFile      string        `long:"chainparams" description:"Run a private network defined by the custom chain parameters of this JSON file -- See docs/custom_chain_params.md"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
 	MemoryProfile        string        `long:"memprofile" description:"Write memory profile to the specified file"`
 	DataDir              string        `short:"b" long:"datadir" description:"Directory to store data"`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	DbCompressBlockIndex bool          `long:"dbcompressblockindex" description:"Convert the block index to the compressed format keyed by short hash prefixes, which stores each block in 36 instead of 44 bytes -- The conversion can't be undone and older versions are unable to open the database afterwards"`
+	DbMaxWriteRate       uint64        `long:"dbmaxwriterate" description:"Maximum average rate in MiB/s at which blocks are written to the block files -- 0 means no limit"`
+	DbRepairSource       string        `long:"dbrepairsource" description:"Path or HTTP URL of a dump of the block database created with dbtool dump -- When opening the block database reports corruption, the damaged parts are restored from the dump automatically"`
+	DbRepairManifestHash string        `long:"dbrepairmanifesthash" description:"Hex-encoded SHA-256 hash of the manifest of the dump set with --dbrepairsource -- The dump is only used when its manifest matches -- Required for plain http URLs"`
+	DbSyncInterval       uint64        `long:"dbsyncinterval" description:"Sync the block files to disk after this many MiB have been written to bound the amount of dirty data in the OS page cache -- 0 leaves writeback to the OS"`
+	DbSyncLatency        time.Duration `long:"dbsynclatency" description:"Throttle block writes when syncing the block files takes longer than this duration (e.g. 500ms) -- Requires --dbsyncinterval"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
+	DNSSeeder            string        `long:"dnsseeder" description:"Crawl the network and answer DNS queries for the seed with this host name (eg. seed.example.com) with the addresses of reliable peers -- The domain must be delegated to this host with an NS record"`
+	DNSSeederCrawlers    int           `long:"dnsseedercrawlers" description:"Number of peers the DNS seeder crawls concurrently"`
+	DNSSeederListen      string        `long:"dnsseederlisten" description:"Address to answer the DNS queries of the seed on over UDP"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	DropBalanceIndex     bool          `long:"dropbalanceindex" description:"Deletes the balance index from the database on start up and then exits."`
 	DropCfIndex          bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
+	DropSpentIndex       bool          `long:"dropspentindex" description:"Deletes the spent output index from the database on start up and then exits."`
+	DropTimestampIndex   bool          `long:"droptimestampindex" description:"Deletes the block timestamp index from the database on start up and then exits."`
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
+	FinalityDepth        int32         `long:"finalitydepth" description:"Number of blocks below the tip of the main chain the block attested to with --finalitykey is at"`
+	FinalityKey          string        `long:"finalitykey" default-mask:"-" description:"Hex-encoded private key of one of the finality signers used to attest to blocks"`
+	FinalityQuorum       int           `long:"finalityquorum" description:"Number of finality signers that must attest to a block to make it irreversibly final (default: majority of the signers)"`
+	FinalitySigners      []string      `long:"finalitysigner" description:"Hex-encoded compressed public key of a signer that attests to blocks for finality on permissioned networks -- Enables the finality module -- May be specified multiple times"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
+	GRPCListeners        []string      `long:"grpclisten" description:"Add an interface/port to serve the gRPC interface on (eg. 127.0.0.1:8335) -- The gRPC server is disabled by default -- Uses the certificate of --rpccert and --rpckey"`
+	GRPCToken            string        `long:"grpctoken" default-mask:"-" description:"Bearer token gRPC clients must send in the authorization header -- Required by --grpclisten"`
+	FiltersOnly          bool          `long:"filtersonly" description:"Only keep the block headers, compact filters, and the most recent 288 blocks -- Older blocks are pruned and fetched from peers on demand for the getblock RPC -- Incompatible with --prune, --nocfilters, --txindex, and --addrindex"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
+	IndexBackfillRate    int           `long:"indexbackfillrate" description:"Max number of blocks per second indexed when indexes which are far behind the best chain, such as indexes enabled after the chain was synced, are caught up in the background -- 0 means no limit"`
+	LimitAncestorCount   int           `long:"limitancestorcount" description:"Maximum number of unconfirmed ancestors, including itself, a transaction of the memory pool may have"`
+	LimitAncestorSize    int64         `long:"limitancestorsize" description:"Maximum total virtual size in kilobytes of a transaction of the memory pool and its unconfirmed ancestors"`
+	LimitDescendantCount int           `long:"limitdescendantcount" description:"Maximum number of unconfirmed descendants, including itself, a transaction of the memory pool may have"`
+	LimitDescendantSize  int64         `long:"limitdescendantsize" description:"Maximum total virtual size in kilobytes of a transaction of the memory pool and its unconfirmed descendants"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
+	LogFormat            string        `long:"logformat" description:"Format of the log output {text, json} -- The json format writes each message as a JSON object with its subsystem and, when they are logged, the peer and block hash on its own line"`
+	LogMaxSize           int64         `long:"logmaxsize" description:"Rotate the log file once it reaches the specified size in MiB -- 0 disables rotation by size"`
+	LogRotateInterval    time.Duration `long:"logrotateinterval" description:"Rotate the log file at the specified interval regardless of its size (eg. 24h) -- 0 disables rotation by time"`
+	MaxLogFiles          int           `long:"maxlogfiles" description:"Number of rotated log files to keep -- 0 keeps all of them"`
+	MaxMempool           int64         `long:"maxmempool" description:"Maximum size of the memory pool in megabytes -- The transactions with the lowest fee rates including their descendants are evicted when it is exceeded, which raises the minimum fee rate of the memory pool"`
+	MaxOrphanPeerWeight  int64         `long:"maxorphanpeerweight" description:"Max total weight of the orphan transactions relayed by a single peer to keep in memory -- The oldest orphans of the peer are evicted to make room for new ones -- 0 means no limit"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxOrphanWeight      int64         `long:"maxorphanweight" description:"Max total weight of the orphan transactions to keep in memory -- 0 means no limit"`
+	MaxPeerRecvRate      uint32        `long:"maxpeerrecvrate" description:"Max rate in KiB/s at which messages are read from a single peer -- 0 means no limit"`
+	MaxPeerServingRate   uint32        `long:"maxpeerservingrate" description:"Max rate in KiB/s at which requested blocks and transactions are sent to a single peer -- 0 means no limit"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
-	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	MaxRecvRate          uint32        `long:"maxrecvrate" description:"Max rate in KiB/s at which messages are read from all peers combined -- 0 means no limit"`
+	MaxReorgDepth        int32         `long:"maxreorgdepth" description:"Max number of blocks a reorganization may disconnect before it is paused until confirmed with the confirmreorg RPC -- 0 allows reorganizations of any depth"`
+	MaxServingRate       uint32        `long:"maxservingrate" description:"Max rate in KiB/s at which requested blocks and transactions are sent to all peers combined -- 0 means no limit"`
+	MaxUploadTarget      uint64        `long:"maxuploadtarget" description:"Max number of MiB sent to peers per 24 hours -- Blocks older than a week are no longer served to peers which aren't whitelisted once less than 576 MiB of the target are left, so recent blocks can still be served, and their mempool requests are refused once it is reached -- 0 means no limit"`
+	MempoolFullRBF       bool          `long:"mempoolfullrbf" description:"Accept transactions that replace existing transactions within the mempool whether or not the replaced transactions signal replaceability (full RBF) -- The remaining Replace-By-Fee (RBF) rules still apply"`
+	MetricsListeners     []string      `long:"metricslisten" description:"Add an interface/port to serve Prometheus metrics on at /metrics (eg. 127.0.0.1:9332) -- The metrics server is disabled by default"`
+	MetricsToken         string        `long:"metricstoken" default-mask:"-" description:"Bearer token scrapers must send in the authorization header -- Metrics are served without authentication when it is not set"`
+	MinimumChainWork     string        `long:"minimumchainwork" description:"Hex-encoded minimum cumulative work a chain of headers must have before its blocks are downloaded during the initial sync -- The headers of chains with less work are only pre-synced without keeping them (default: the value of the network)"`
+	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address or payout is required if the generate option is set"`
+	MiningPayouts        []string      `long:"miningpayout" description:"Add the specified output descriptor, optionally followed by @weight, to the payouts of the blocks generated by the CPU miner -- Each block pays to a payout chosen with a probability proportional to its weight (default: 1) and ranged descriptors pay to the next derived script each time"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
+	NATPMP               bool          `long:"natpmp" description:"Use NAT-PMP or PCP to map our listening port outside of NAT"`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	NoCFilters           bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
@@ -138,53 +224,119 @@ type config struct {
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	NoWinService         bool          `long:"nowinservice" description:"Do not start as a background service on Windows -- NOTE: This flag only works on the command line, not in the config file"`
-	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
+	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass, rpclimituser/rpclimitpass or rpcauth is specified and cookie authentication is disabled"`
+	NoRPCCookie          bool          `long:"norpccookie" description:"Do not write an RPC authentication cookie when no rpcuser/rpcpass is specified"`
+	NoRPCHTTP2           bool          `long:"norpchttp2" description:"Do not offer HTTP/2 to RPC clients connecting over TLS"`
 	DisableStallHandler  bool          `long:"nostalldetect" description:"Disables the stall handler system for each peer, useful in simnet/regtest integration tests frameworks"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 	OnionProxy           string        `long:"onion" description:"Connect to tor hidden services via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	OnionProxyUser       string        `long:"onionuser" description:"Username for onion proxy server"`
+	OrphanExpiry         time.Duration `long:"orphanexpiry" description:"Duration an orphan transaction is kept in memory before it expires (e.g. 15m)"`
+	PeerAuthority        string        `long:"peerauthority" description:"Hex-encoded compressed public key of the authority of a permissioned network -- Only peers presenting a certificate of the authority over the v2 transport are accepted -- Requires --peerkey, --peercert and --v2transport"`
+	PeerCert             string        `long:"peercert" description:"Hex-encoded certificate issued by the peer authority for --peerkey (see genpeercert)"`
+	PeerKey              string        `long:"peerkey" default-mask:"-" description:"Hex-encoded private key used to authenticate to peers of a permissioned network"`
+	PeerRevocationFile   string        `long:"peerrevocationfile" description:"File listing the hex-encoded public keys of revoked peers of a permissioned network, one per line -- Reloaded when modified"`
+	PolicyRules          []string      `long:"policyrule" description:"Change a standardness rule of the relay policy in the form name=value (e.g. datacarriersize=1000) -- May be specified multiple times; use policyrule=help to list the rules"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyPass            string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	ProxyUser            string        `long:"proxyuser" description:"Username for proxy server"`
 	Prune                uint64        `long:"prune" description:"Prune already validated blocks from the database. Must specify a target size in MiB (minimum value of 1536, default value of 0 will disable pruning)"`
+	PruneTrashMaxSize    uint64        `long:"prunetrashmaxsize" description:"Maximum total size in MiB of pruned block files kept in the trash -- 0 means no limit"`
+	PruneTrashRetention  time.Duration `long:"prunetrashretention" description:"Move pruned block files to a trash directory and keep them for this duration before deleting them so they can be restored with dbtool (e.g. 72h) -- 0 deletes them immediately"`
+	RebroadcastInterval  time.Duration `long:"rebroadcastinterval" description:"Average time between attempts to announce the unconfirmed transactions submitted through the RPC server to the connected peers which have not seen them yet (e.g. 10m)"`
+	ReconstructPruned    bool          `long:"reconstructpruned" description:"Serve pruned blocks requested by peers and RPC clients by recovering them from the prune trash on demand instead of failing -- Requires --prunetrashretention"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	RejectReplacement    bool          `long:"rejectreplacement" description:"Reject transactions that attempt to replace existing transactions within the mempool through the Replace-By-Fee (RBF) signaling policy."`
+	REST                 bool          `long:"rest" description:"Serve the unauthenticated REST interface of Bitcoin Core under /rest/ on the RPC listeners -- Requires the RPC server"`
 	RelayNonStd          bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
+	RescanNtfnInterval   time.Duration `long:"rescanprogressinterval" description:"Minimum time between the progress notifications sent to websocket clients during a rescan (e.g. 10s)"`
+	RPCAllowMethods      []string      `long:"rpcallowmethod" description:"Only allow calling the RPC methods specified with this option -- Can be specified multiple times"`
 	RPCCert              string        `long:"rpccert" description:"File containing the certificate file"`
+	RPCCookieFile        string        `long:"rpccookiefile" description:"File to write the RPC authentication cookie to when no rpcuser/rpcpass is specified (default: .cookie in the data directory)"`
+	RPCDisableMethods    []string      `long:"rpcdisablemethod" description:"Disable an RPC method for all users -- Can be specified multiple times"`
+	RPCIdleTimeout       time.Duration `long:"rpcidletimeout" description:"Duration an idle RPC connection is kept open for further requests of the client (e.g. 2m) -- 0 closes connections after each request"`
 	RPCKey               string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCLimitPass         string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
 	RPCListeners         []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
 	RPCMaxClients        int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	RPCMaxStreams        uint32        `long:"rpcmaxconcurrentstreams" description:"Max number of concurrent requests an RPC client may make over a single HTTP/2 connection"`
 	RPCMaxWebsockets     int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
+	RPCPprof             bool          `long:"rpcpprof" description:"Serve the pprof endpoints at /debug/pprof/ on the RPC listeners to clients authenticated as the admin RPC user"`
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
+	RPCRateLimits        []string      `long:"rpcratelimit" description:"Limit how often each client may call an RPC method in the form method:calls/interval (eg. scantxoutset:1/1m) -- Can be specified multiple times"`
+	RPCSlowThreshold     time.Duration `long:"rpcslowthreshold" description:"Log RPC requests which take longer than this duration to process, with their parameters redacted -- 0 disables the log"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
+	SharedBlockStore     string        `long:"sharedblockstore" description:"Reference the blocks already stored in the block database directory of another node, such as a node for the network this one was forked from, instead of storing them again -- The other node must not be pruned"`
+	ScriptWorkers        int           `long:"scriptvalidationworkers" description:"Number of goroutines used to validate the scripts of a block -- 0 uses 3 times the number of CPUs"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
+	SnapshotValidateDB   string        `long:"utxosnapshotvalidatedb" description:"Path to a block database that contains the blocks before the utxo snapshot the chain was loaded from with dbtool loadutxo -- The history of the snapshot is validated from it in the background"`
+	SpentIndex           bool          `long:"spentindex" description:"Maintain an index of the transaction inputs which spend each output which makes the getspentinfo RPC available"`
+	SV2FeeDelta          int64         `long:"sv2feedelta" description:"Minimum increase in satoshi of the fees of the block template for a new template to be pushed to Stratum V2 clients when the mempool changes"`
+	SV2Interval          time.Duration `long:"sv2interval" description:"Minimum time between block templates pushed to Stratum V2 clients when the mempool changes"`
+	SV2Listeners         []string      `long:"sv2listen" description:"Add an interface/port to serve block templates to Stratum V2 pools and job declarators on over the template distribution protocol (eg. 127.0.0.1:8442) -- The template provider is disabled by default"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
 	SigNet               bool          `long:"signet" description:"Use the signet test network"`
 	SigNetChallenge      string        `long:"signetchallenge" description:"Connect to a custom signet network defined by this challenge instead of using the global default signet test network -- Can be specified multiple times"`
 	SigNetSeedNode       []string      `long:"signetseednode" description:"Specify a seed node for the signet network instead of using the global default signet network seed nodes"`
 	TestNet3             bool          `long:"testnet" description:"Use the test network"`
+	TimestampIndex       bool          `long:"timestampindex" description:"Maintain an index of the blocks by their median time which makes the getblockhashes RPC available"`
+	TorControl           string        `long:"torcontrol" description:"Address of the Tor control port (eg. 127.0.0.1:9051) used to create an onion service for the listening port and to connect to onion addresses via the SOCKS proxy of Tor unless --onion or --noonion is set"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorPassword          string        `long:"torpassword" default-mask:"-" description:"Password for the Tor control port -- The cookie of Tor is used when it is not set"`
+	TorProxyNets         []string      `long:"torproxynet" description:"Also make the outbound connections of this network (ipv4 or ipv6) via the SOCKS proxy of Tor learned from --torcontrol"`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	UtxoCacheMaxSizeMiB  uint          `long:"utxocachemaxsize" description:"The maximum size in MiB of the UTXO cache"`
+	TxReconciliation     bool          `long:"txreconciliation" description:"Relay transactions to inbound peers that support it by set reconciliation (Erlay, BIP0330) rather than announcing each transaction"`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
+	UndoPruneDepth       uint32        `long:"undoprunedepth" description:"Prune the undo data needed to disconnect blocks of all but this number of most recent main chain blocks (minimum value of 288) -- Reorganizations reaching further back are rejected -- 0 keeps all undo data -- Incompatible with --addrindex"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	V2Transport          bool          `long:"v2transport" description:"Encrypt connections with peers that support it using the BIP0324 v2 transport protocol, falling back to the unencrypted v1 protocol otherwise"`
+	WireCompression      bool          `long:"wirecompression" description:"Compress block and headers messages exchanged with peers that support it to save bandwidth on slow links -- Only understood by other btcd nodes with this option"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ZMQPubHashBlock      string        `long:"zmqpubhashblock" description:"Publish the hash of each block connected to the main chain over ZeroMQ on this endpoint (eg. tcp://127.0.0.1:28332)"`
+	ZMQPubHashTx         string        `long:"zmqpubhashtx" description:"Publish the hash of each transaction added to the mempool or included in a connected or disconnected block over ZeroMQ on this endpoint"`
+	ZMQPubRawBlock       string        `long:"zmqpubrawblock" description:"Publish each block connected to the main chain over ZeroMQ on this endpoint"`
+	ZMQPubRawTx          string        `long:"zmqpubrawtx" description:"Publish each transaction added to the mempool or included in a connected or disconnected block over ZeroMQ on this endpoint"`
+	ZMQPubSequence       string        `long:"zmqpubsequence" description:"Publish the hashes of connected and disconnected blocks and of transactions added to and removed from the mempool along with the mempool sequence number over ZeroMQ on this endpoint"`
+	ZMQPubHWM            int           `long:"zmqpubhwm" description:"Maximum number of ZeroMQ messages queued for each subscriber before further messages are dropped"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
 	miningAddrs          []btcutil.Address
+	miningPayouts        []cpuminer.Payout
+	finalitySigners      []*btcec.PublicKey
+	finalityKey          *btcec.PrivateKey
+	blockTraceExporter   *blocktrace.OTLPExporter
+	peerAuth             *peerauth.Authenticator
 	minRelayTxFee        btcutil.Amount
+	useConfigFile        bool
+	minChainWork         *big.Int
+	assumeValid          *chainhash.Hash
+	policyRules          policy.Rules
 	whitelists           []*net.IPNet
+	rpcUsers             []*rpcUser
+	rpcAllowedMethods    map[string]struct{}
+	rpcDisabledMethods   map[string]struct{}
+	rpcRateLimits        []*rpcRateLimit
+
+	// RPCUsers defines additional RPC users in a group of its own.
+	RPCUsers rpcUsersOptions `group:"RPC Users"`
+}
+
+// rpcUsersOptions defines the options for additional RPC users.  They are in
+// their own group so they can be listed in an [RPC Users] section of the
+// config file.
+type rpcUsersOptions struct {
+	RPCAuth []string `long:"rpcauth" description:"Add an RPC user as user:password:permissions where permissions is a comma separated list of admin, limited, readonly, wallet or the names of methods the user may call"`
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -384,6 +536,54 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// newPeerAuthenticator returns a peer authenticator for the peer authority,
+// key, certificate and revocation file options of the passed configuration.
+func newPeerAuthenticator(cfg *config) (*peerauth.Authenticator, error) {
+	authorityBytes, err := hex.DecodeString(cfg.PeerAuthority)
+	var authority *btcec.PublicKey
+	if err == nil && len(authorityBytes) == btcec.PubKeyBytesLenCompressed {
+		authority, err = btcec.ParsePubKey(authorityBytes)
+	} else if err == nil {
+		err = errors.New("not a compressed public key")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("peer authority '%s' failed to decode: %v",
+			cfg.PeerAuthority, err)
+	}
+
+	keyBytes, err := hex.DecodeString(cfg.PeerKey)
+	if err != nil || len(keyBytes) != btcec.PrivKeyBytesLen {
+		return nil, errors.New("the peerkey option is not a " +
+			"hex-encoded private key")
+	}
+	key, _ := btcec.PrivKeyFromBytes(keyBytes)
+
+	certBytes, err := hex.DecodeString(cfg.PeerCert)
+	if err != nil {
+		return nil, errors.New("the peercert option is not hex-encoded")
+	}
+	cert, err := peerauth.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("the peercert option is invalid: %v", err)
+	}
+
+	var revocationFile string
+	if cfg.PeerRevocationFile != "" {
+		revocationFile = cleanAndExpandPath(cfg.PeerRevocationFile)
+	}
+	auth, err := peerauth.New(&peerauth.Config{
+		Authority:      authority,
+		Key:            key,
+		Certificate:    cert,
+		RevocationFile: revocationFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to enable peer authentication: "+
+			"%v", err)
+	}
+	return auth, nil
+}
+
 // fileExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -403,21 +603,9 @@ func newConfigParser(cfg *config, so *serviceOptions, options flags.Options) *fl
 	return parser
 }
 
-// loadConfig initializes and parses the config using a config file and command
-// line options.
-//
-// The configuration proceeds as follows:
-//  1. Start with a default config with sane settings
-//  2. Pre-parse the command line to check for an alternative config file
-//  3. Load configuration file overwriting defaults with any specified options
-//  4. Parse CLI options and overwrite/add any specified options
-//
-// The above results in btcd functioning properly without any config settings
-// while still allowing the user to override settings with config files and
-// command line options.  Command line options always take precedence.
-func loadConfig() (*config, []string, error) {
-	// Default config.
-	cfg := config{
+// defaultConfig returns the configuration btcd uses when no options are set.
+func defaultConfig() config {
+	return config{
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
 		MaxPeers:             defaultMaxPeers,
@@ -426,8 +614,16 @@ func loadConfig() (*config, []string, error) {
 		RPCMaxClients:        defaultMaxRPCClients,
 		RPCMaxWebsockets:     defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs: defaultMaxRPCConcurrentReqs,
+		RPCIdleTimeout:       defaultRPCIdleTimeout,
+		RPCMaxStreams:        defaultRPCMaxStreams,
+		RPCSlowThreshold:     defaultRPCSlowThreshold,
+		RescanNtfnInterval:   defaultRescanNtfnInterval,
+		RebroadcastInterval:  defaultRebroadcastInterval,
 		DataDir:              defaultDataDir,
 		LogDir:               defaultLogDir,
+		LogFormat:            defaultLogFormat,
+		LogMaxSize:           defaultLogMaxSize,
+		MaxLogFiles:          defaultMaxLogFiles,
 		DbType:               defaultDbType,
 		RPCKey:               defaultRPCKeyFile,
 		RPCCert:              defaultRPCCertFile,
@@ -438,14 +634,47 @@ func loadConfig() (*config, []string, error) {
 		BlockMaxSize:         defaultBlockMaxSize,
 		BlockMinWeight:       defaultBlockMinWeight,
 		BlockMaxWeight:       defaultBlockMaxWeight,
-		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
+		BlockTemplateStale:   defaultBlockTemplateStale,
+		MaxMempool:           defaultMaxMempool,
+		LimitAncestorCount:   defaultLimitAncestorCount,
+		LimitAncestorSize:    defaultLimitAncestorSize,
+		LimitDescendantCount: defaultLimitDescendantCount,
+		LimitDescendantSize:  defaultLimitDescendantSize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxOrphanWeight:      defaultMaxOrphanWeight,
+		MaxOrphanPeerWeight:  defaultMaxOrphanPeerWeight,
+		OrphanExpiry:         defaultOrphanExpiry,
+		SV2FeeDelta:          sv2.DefaultFeeDelta,
+		SV2Interval:          sv2.DefaultInterval,
+		ZMQPubHWM:            zmq.DefaultHighWaterMark,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
 		UtxoCacheMaxSizeMiB:  defaultUtxoCacheMaxSizeMiB,
+		FinalityDepth:        defaultFinalityDepth,
+		CheckBlocks:          defaultCheckBlocks,
+		CheckLevel:           defaultCheckLevel,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
+		DNSSeederListen:      defaultDNSSeederListen,
+		DNSSeederCrawlers:    defaultDNSSeederCrawlers,
 	}
+}
+
+// loadConfig initializes and parses the config using a config file and command
+// line options.
+//
+// The configuration proceeds as follows:
+//  1. Start with a default config with sane settings
+//  2. Pre-parse the command line to check for an alternative config file
+//  3. Load configuration file overwriting defaults with any specified options
+//  4. Parse CLI options and overwrite/add any specified options
+//
+// The above results in btcd functioning properly without any config settings
+// while still allowing the user to override settings with config files and
+// command line options.  Command line options always take precedence.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := defaultConfig()
 
 	// Service options which are only added on Windows.
 	serviceOpts := serviceOptions{}
@@ -490,6 +719,7 @@ func loadConfig() (*config, []string, error) {
 	if !(preCfg.RegressionTest || preCfg.SimNet || preCfg.SigNet) ||
 		preCfg.ConfigFile != defaultConfigFile {
 
+		cfg.useConfigFile = true
 		if _, err := os.Stat(preCfg.ConfigFile); os.IsNotExist(err) {
 			err := createDefaultConfigFile(preCfg.ConfigFile)
 			if err != nil {
@@ -601,10 +831,24 @@ func loadConfig() (*config, []string, error) {
 		)
 		activeNetParams.Params = &chainParams
 	}
+	if cfg.ChainParamsFile != "" {
+		numNets++
+		netParams, err := loadCustomNetParams(
+			cleanAndExpandPath(cfg.ChainParamsFile),
+		)
+		if err != nil {
+			str := "%s: Failed to load chain parameters: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		activeNetParams = netParams
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, segnet, signet and simnet " +
-			"params can't be used together -- choose one of the " +
-			"five"
+		str := "%s: The testnet, regtest, segnet, signet, simnet and " +
+			"custom chain params can't be used together -- " +
+			"choose one of the six"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -641,6 +885,17 @@ func loadConfig() (*config, []string, error) {
 	}
 	cfg.RelayNonStd = relayNonStd
 
+	// Full RBF only relaxes the replacement policy, so it can't be used
+	// along with rejecting replacements altogether.
+	if cfg.MempoolFullRBF && cfg.RejectReplacement {
+		str := "%s: mempoolfullrbf and rejectreplacement cannot be " +
+			"used together -- choose only one"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Append the network type to the data directory so it is "namespaced"
 	// per network.  In addition to the block database, there are other
 	// pieces of data that are saved to disk such as address manager state.
@@ -655,15 +910,56 @@ func loadConfig() (*config, []string, error) {
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
 	cfg.LogDir = filepath.Join(cfg.LogDir, netName(activeNetParams))
 
+	if cfg.SharedBlockStore != "" {
+		cfg.SharedBlockStore = cleanAndExpandPath(cfg.SharedBlockStore)
+	}
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+	if cfg.SnapshotValidateDB != "" {
+		cfg.SnapshotValidateDB = cleanAndExpandPath(cfg.SnapshotValidateDB)
+	}
+	if cfg.DbRepairSource != "" &&
+		!strings.HasPrefix(cfg.DbRepairSource, "http://") &&
+		!strings.HasPrefix(cfg.DbRepairSource, "https://") {
+
+		cfg.DbRepairSource = cleanAndExpandPath(cfg.DbRepairSource)
+	}
+
 	// Special show command to list supported subsystems and exit.
 	if cfg.DebugLevel == "show" {
 		fmt.Println("Supported subsystems", supportedSubsystems())
 		os.Exit(0)
 	}
 
+	// Validate the log format and rotation options.
+	logFormat, err := logging.ParseFormat(cfg.LogFormat)
+	if err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LogMaxSize < 0 || cfg.LogRotateInterval < 0 ||
+		cfg.MaxLogFiles < 0 {
+
+		str := "%s: The logmaxsize, logrotateinterval and maxlogfiles " +
+			"options may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	backendLog.SetFormat(logFormat)
+
 	// Initialize log rotation.  After log rotation has been initialized, the
 	// logger variables may be used.
-	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename))
+	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename),
+		&logging.RotatorConfig{
+			MaxSize:  cfg.LogMaxSize * 1024 * 1024,
+			Interval: cfg.LogRotateInterval,
+			MaxFiles: cfg.MaxLogFiles,
+		})
 
 	// Parse, validate, and set debug log level(s).
 	if err := parseAndSetDebugLevels(cfg.DebugLevel); err != nil {
@@ -786,9 +1082,88 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// The RPC server is disabled if no username or password is provided.
+	// Parse the additional RPC users and make sure their usernames are
+	// unique.
+	rpcUserNames := map[string]struct{}{
+		cfg.RPCUser:      {},
+		cfg.RPCLimitUser: {},
+	}
+	for _, auth := range cfg.RPCUsers.RPCAuth {
+		user, err := parseRPCAuth(auth)
+		if err != nil {
+			err := fmt.Errorf("%s: invalid rpcauth: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if _, ok := rpcUserNames[user.name]; ok {
+			str := "%s: the RPC username %s is specified more than " +
+				"once"
+			err := fmt.Errorf(str, funcName, user.name)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		rpcUserNames[user.name] = struct{}{}
+		cfg.rpcUsers = append(cfg.rpcUsers, user)
+	}
+
+	if cfg.RPCCookieFile != "" {
+		cfg.RPCCookieFile = cleanAndExpandPath(cfg.RPCCookieFile)
+	}
+
+	// Validate the methods which are allowed, disabled and rate limited.
+	cfg.rpcAllowedMethods = make(map[string]struct{})
+	cfg.rpcDisabledMethods = make(map[string]struct{})
+	methodOptions := []struct {
+		name    string
+		methods []string
+		set     map[string]struct{}
+	}{
+		{"rpcallowmethod", cfg.RPCAllowMethods, cfg.rpcAllowedMethods},
+		{"rpcdisablemethod", cfg.RPCDisableMethods, cfg.rpcDisabledMethods},
+	}
+	for _, option := range methodOptions {
+		for _, method := range option.methods {
+			if !isKnownRPCMethod(method) {
+				str := "%s: the %s option specifies the " +
+					"unknown method %q"
+				err := fmt.Errorf(str, funcName, option.name,
+					method)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			option.set[method] = struct{}{}
+		}
+	}
+	rateLimited := make(map[string]struct{})
+	for _, limit := range cfg.RPCRateLimits {
+		rateLimit, err := parseRPCRateLimit(limit)
+		if err != nil {
+			err := fmt.Errorf("%s: invalid rpcratelimit: %v", funcName,
+				err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if _, ok := rateLimited[rateLimit.method]; ok {
+			str := "%s: the rpcratelimit option specifies more " +
+				"than one limit for %s"
+			err := fmt.Errorf(str, funcName, rateLimit.method)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		rateLimited[rateLimit.method] = struct{}{}
+		cfg.rpcRateLimits = append(cfg.rpcRateLimits, rateLimit)
+	}
+
+	// The RPC server is disabled if no username or password is provided
+	// and cookie authentication is disabled.
 	if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
+		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") &&
+		len(cfg.rpcUsers) == 0 && cfg.NoRPCCookie {
 		cfg.DisableRPC = true
 	}
 
@@ -796,6 +1171,16 @@ func loadConfig() (*config, []string, error) {
 		btcdLog.Infof("RPC service is disabled")
 	}
 
+	// The REST interface is served by the RPC server.
+	if cfg.REST && cfg.DisableRPC {
+		str := "%s: the --rest option requires the RPC server to be " +
+			"enabled"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Default RPC to listen on localhost only.
 	if !cfg.DisableRPC && len(cfg.RPCListeners) == 0 {
 		addrs, err := net.LookupHost("localhost")
@@ -818,6 +1203,51 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.RPCIdleTimeout < 0 {
+		str := "%s: The rpcidletimeout option may not be less " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.RPCIdleTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.RPCMaxStreams == 0 {
+		str := "%s: The rpcmaxconcurrentstreams option must be " +
+			"greater than 0"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.RPCSlowThreshold < 0 {
+		str := "%s: The rpcslowthreshold option may not be less " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.RPCSlowThreshold)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.RebroadcastInterval <= 0 {
+		str := "%s: The rebroadcastinterval option must be greater " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.RebroadcastInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.RescanNtfnInterval <= 0 {
+		str := "%s: The rescanprogressinterval option must be greater " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.RescanNtfnInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Validate the minrelaytxfee.
 	cfg.minRelayTxFee, err = btcutil.NewAmount(cfg.MinRelayTxFee)
 	if err != nil {
@@ -828,6 +1258,27 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Apply the standardness policy rules on top of the defaults.
+	cfg.policyRules = policy.DefaultRules()
+	for _, setting := range cfg.PolicyRules {
+		// Special help value to list the policy rules and exit.
+		if setting == "help" {
+			fmt.Println("Standardness policy rules:")
+			for _, s := range policy.Settings() {
+				fmt.Printf("  %-22s %s\n", s.Name, s.Description)
+			}
+			os.Exit(0)
+		}
+
+		if err := cfg.policyRules.Apply(setting); err != nil {
+			str := "%s: invalid policyrule: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Limit the max block size to a sane value.
 	if cfg.BlockMaxSize < blockMaxSizeMin || cfg.BlockMaxSize >
 		blockMaxSizeMax {
@@ -841,6 +1292,16 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The block template staleness can't be negative.
+	if cfg.BlockTemplateStale < 0 {
+		str := "%s: The blocktemplatestale option may not be negative " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.BlockTemplateStale)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max block weight to a sane value.
 	if cfg.BlockMaxWeight < blockMaxWeightMin ||
 		cfg.BlockMaxWeight > blockMaxWeightMax {
@@ -854,6 +1315,39 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The memory pool must be large enough to hold a few packages of
+	// transactions.
+	if cfg.MaxMempool < minMaxMempool {
+		str := "%s: The maxmempool option may not be less than %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, minMaxMempool, cfg.MaxMempool)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The limits of the chains of unconfirmed transactions must allow a
+	// transaction to have at least one ancestor and descendant, itself.
+	mempoolLimits := []struct {
+		name  string
+		value int64
+	}{
+		{"limitancestorcount", int64(cfg.LimitAncestorCount)},
+		{"limitancestorsize", cfg.LimitAncestorSize},
+		{"limitdescendantcount", int64(cfg.LimitDescendantCount)},
+		{"limitdescendantsize", cfg.LimitDescendantSize},
+	}
+	for _, limit := range mempoolLimits {
+		if limit.value < 1 {
+			str := "%s: The %s option may not be less than 1 " +
+				"-- parsed [%d]"
+			err := fmt.Errorf(str, funcName, limit.name, limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Limit the max orphan count to a sane vlue.
 	if cfg.MaxOrphanTxs < 0 {
 		str := "%s: The maxorphantx option may not be less than 0 " +
@@ -864,8 +1358,182 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// Limit the block priority and minimum block sizes to max block size.
-	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
+	// The orphan weight limits may not be negative.
+	if cfg.MaxOrphanWeight < 0 || cfg.MaxOrphanPeerWeight < 0 {
+		str := "%s: The maxorphanweight and maxorphanpeerweight " +
+			"options may not be less than 0 -- parsed [%d] and [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxOrphanWeight,
+			cfg.MaxOrphanPeerWeight)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The orphan expiry must be positive.
+	if cfg.OrphanExpiry <= 0 {
+		str := "%s: The orphanexpiry option must be greater than 0 " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.OrphanExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate the ZeroMQ endpoints.
+	zmqEndpoints := []struct {
+		option   string
+		endpoint string
+	}{
+		{"zmqpubhashblock", cfg.ZMQPubHashBlock},
+		{"zmqpubhashtx", cfg.ZMQPubHashTx},
+		{"zmqpubrawblock", cfg.ZMQPubRawBlock},
+		{"zmqpubrawtx", cfg.ZMQPubRawTx},
+		{"zmqpubsequence", cfg.ZMQPubSequence},
+	}
+	for _, e := range zmqEndpoints {
+		if e.endpoint == "" {
+			continue
+		}
+		if _, err := zmq.ListenAddress(e.endpoint); err != nil {
+			str := "%s: The %s option is invalid: %v"
+			err := fmt.Errorf(str, funcName, e.option, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if cfg.ZMQPubHWM <= 0 {
+		str := "%s: The zmqpubhwm option must be greater than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.ZMQPubHWM)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The gRPC listeners have no default port and clients must always
+	// authenticate.
+	for _, addr := range cfg.GRPCListeners {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			str := "%s: gRPC listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, addr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if len(cfg.GRPCListeners) > 0 && cfg.GRPCToken == "" {
+		str := "%s: The grpctoken option must be set when the gRPC " +
+			"server is enabled with grpclisten"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The metrics listeners have no default port either.
+	for _, addr := range cfg.MetricsListeners {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			str := "%s: Metrics listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, addr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// The Stratum V2 listeners have no default port either.
+	for _, addr := range cfg.SV2Listeners {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			str := "%s: Stratum V2 listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, addr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if cfg.SV2Interval <= 0 || cfg.SV2FeeDelta < 0 {
+		str := "%s: The sv2interval option must be greater than 0 and " +
+			"the sv2feedelta option must not be negative -- parsed " +
+			"[%v, %d]"
+		err := fmt.Errorf(str, funcName, cfg.SV2Interval, cfg.SV2FeeDelta)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Undo data of the most recent blocks must be kept since it's needed to
+	// handle the reorganizations which are expected to happen.
+	if cfg.UndoPruneDepth != 0 &&
+		cfg.UndoPruneDepth < blockchain.MinUndoPruneDepth {
+
+		err := fmt.Errorf("%s: the minimum value for --undoprunedepth "+
+			"is %d. Got %d", funcName, blockchain.MinUndoPruneDepth,
+			cfg.UndoPruneDepth)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The address index needs the undo data of all blocks when it catches
+	// up with the chain.
+	if cfg.UndoPruneDepth != 0 && cfg.AddrIndex {
+		err := fmt.Errorf("%s: the --undoprunedepth and --addrindex "+
+			"options may not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The startup verification level must be supported and its depth may
+	// not be negative.
+	if cfg.CheckLevel < 0 || cfg.CheckLevel > blockchain.MaxVerifyLevel {
+		err := fmt.Errorf("%s: the --checklevel option must be "+
+			"between 0 and %d. Got %d", funcName,
+			blockchain.MaxVerifyLevel, cfg.CheckLevel)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.CheckBlocks < 0 {
+		err := fmt.Errorf("%s: the --checkblocks option may not be "+
+			"negative. Got %d", funcName, cfg.CheckBlocks)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.CheckBlocks == 0 &&
+		cfg.CheckLevel > blockchain.MaxUnboundedVerifyLevel {
+
+		err := fmt.Errorf("%s: --checkblocks=0 verifies all blocks, "+
+			"which is only supported up to --checklevel=%d. Got "+
+			"--checklevel=%d", funcName,
+			blockchain.MaxUnboundedVerifyLevel, cfg.CheckLevel)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The max reorganization depth may not be negative.
+	if cfg.MaxReorgDepth < 0 {
+		str := "%s: The maxreorgdepth option may not be less than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxReorgDepth)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.IndexBackfillRate < 0 {
+		str := "%s: The indexbackfillrate option may not be less than " +
+			"0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.IndexBackfillRate)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Limit the minimum block sizes to max block size.
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
 	cfg.BlockMinWeight = minUint32(cfg.BlockMinWeight, cfg.BlockMaxWeight)
 
@@ -930,6 +1598,36 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --spentindex and --dropspentindex do not mix.
+	if cfg.SpentIndex && cfg.DropSpentIndex {
+		err := fmt.Errorf("%s: the --spentindex and --dropspentindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --balanceindex and --dropbalanceindex do not mix.
+	if cfg.BalanceIndex && cfg.DropBalanceIndex {
+		err := fmt.Errorf("%s: the --balanceindex and --dropbalanceindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --timestampindex and --droptimestampindex do not mix.
+	if cfg.TimestampIndex && cfg.DropTimestampIndex {
+		err := fmt.Errorf("%s: the --timestampindex and "+
+			"--droptimestampindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]btcutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -951,17 +1649,142 @@ func loadConfig() (*config, []string, error) {
 		cfg.miningAddrs = append(cfg.miningAddrs, addr)
 	}
 
-	// Ensure there is at least one mining address when the generate flag is
-	// set.
-	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
+	// Check the payouts of the CPU miner are valid and save parsed
+	// versions.
+	cfg.miningPayouts = make([]cpuminer.Payout, 0, len(cfg.MiningPayouts))
+	for _, strPayout := range cfg.MiningPayouts {
+		payout, err := cpuminer.ParsePayout(strPayout,
+			activeNetParams.Params)
+		if err != nil {
+			str := "%s: mining payout '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, strPayout, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.miningPayouts = append(cfg.miningPayouts, *payout)
+	}
+
+	// Ensure there is at least one mining address or payout when the
+	// generate flag is set.
+	if cfg.Generate && len(cfg.MiningAddrs) == 0 &&
+		len(cfg.MiningPayouts) == 0 {
+
 		str := "%s: the generate flag is set, but there are no mining " +
-			"addresses specified "
+			"addresses or payouts specified "
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
 
+	// Check the finality signers and signing key are valid and save parsed
+	// versions.
+	for _, strKey := range cfg.FinalitySigners {
+		keyBytes, err := hex.DecodeString(strKey)
+		var pubKey *btcec.PublicKey
+		if err == nil && len(keyBytes) == btcec.PubKeyBytesLenCompressed {
+			pubKey, err = btcec.ParsePubKey(keyBytes)
+		} else if err == nil {
+			err = errors.New("not a compressed public key")
+		}
+		if err != nil {
+			str := "%s: finality signer '%s' failed to decode: %v"
+			err := fmt.Errorf(str, funcName, strKey, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.finalitySigners = append(cfg.finalitySigners, pubKey)
+	}
+	if cfg.FinalityKey != "" {
+		keyBytes, err := hex.DecodeString(cfg.FinalityKey)
+		if err != nil || len(keyBytes) != btcec.PrivKeyBytesLen {
+			str := "%s: the finalitykey option is not a hex-encoded " +
+				"private key"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.finalityKey, _ = btcec.PrivKeyFromBytes(keyBytes)
+	}
+	if len(cfg.finalitySigners) == 0 && (cfg.FinalityKey != "" ||
+		cfg.FinalityQuorum != 0) {
+
+		str := "%s: the finalitykey and finalityquorum options " +
+			"require at least one finalitysigner"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.FinalityQuorum == 0 {
+		cfg.FinalityQuorum = len(cfg.finalitySigners)/2 + 1
+	}
+	// Create the peer authenticator when a peer authority is configured so
+	// an invalid key or certificate is reported on startup.
+	if cfg.PeerAuthority != "" {
+		if !cfg.V2Transport {
+			str := "%s: the peerauthority option requires " +
+				"v2transport"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.peerAuth, err = newPeerAuthenticator(&cfg)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	} else if cfg.PeerKey != "" || cfg.PeerCert != "" ||
+		cfg.PeerRevocationFile != "" {
+
+		str := "%s: the peerkey, peercert and peerrevocationfile " +
+			"options require a peerauthority"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Create the block trace exporter when an endpoint is configured so an
+	// invalid endpoint is reported on startup.
+	if cfg.BlockTraceEndpoint != "" {
+		cfg.blockTraceExporter, err = blocktrace.NewOTLPExporter(
+			cfg.BlockTraceEndpoint, "btcd")
+		if err != nil {
+			str := "%s: the blocktraceendpoint option is not a " +
+				"valid URL: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// The number of script validation workers may not be negative.
+	if cfg.ScriptWorkers < 0 {
+		str := "%s: the scriptvalidationworkers option may not be " +
+			"negative -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.ScriptWorkers)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.FinalityDepth < 0 {
+		str := "%s: the finalitydepth option may not be negative " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.FinalityDepth)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -1028,6 +1851,40 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Parse the minimum chain work overriding the one of the network.
+	if cfg.MinimumChainWork != "" {
+		work, ok := new(big.Int).SetString(
+			strings.TrimPrefix(cfg.MinimumChainWork, "0x"), 16)
+		if !ok || work.Sign() < 0 {
+			str := "%s: The minimumchainwork option must be a hex " +
+				"number -- parsed [%s]"
+			err := fmt.Errorf(str, funcName, cfg.MinimumChainWork)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.minChainWork = work
+	}
+
+	// Parse the assumed valid block overriding the one of the network.
+	cfg.assumeValid = activeNetParams.AssumeValid
+	switch cfg.AssumeValid {
+	case "":
+	case "0":
+		cfg.assumeValid = nil
+	default:
+		hash, err := chainhash.NewHashFromStr(cfg.AssumeValid)
+		if err != nil {
+			str := "%s: The assumevalid option must be a block " +
+				"hash or 0 -- parsed [%s]"
+			err := fmt.Errorf(str, funcName, cfg.AssumeValid)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.assumeValid = hash
+	}
+
 	// Tor stream isolation requires either proxy or onion proxy to be set.
 	if cfg.TorIsolation && cfg.Proxy == "" && cfg.OnionProxy == "" {
 		str := "%s: Tor stream isolation requires either proxy or " +
@@ -1038,6 +1895,68 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the Tor control port address and the networks whose
+	// connections are made via the SOCKS proxy of Tor.
+	if cfg.TorControl != "" {
+		_, _, err := net.SplitHostPort(cfg.TorControl)
+		if err != nil {
+			str := "%s: Tor control address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.TorControl, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	for _, network := range cfg.TorProxyNets {
+		var err error
+		switch {
+		case cfg.TorControl == "":
+			err = fmt.Errorf("%s: the --torproxynet option requires "+
+				"--torcontrol", funcName)
+		case network != "ipv4" && network != "ipv6":
+			err = fmt.Errorf("%s: the --torproxynet option must be "+
+				"ipv4 or ipv6 -- parsed [%s]", funcName, network)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Validate the host name and the options of the DNS seeder.
+	if cfg.DNSSeeder != "" {
+		cfg.DNSSeeder = strings.ToLower(strings.TrimSuffix(cfg.DNSSeeder,
+			"."))
+		var err error
+		for _, label := range strings.Split(cfg.DNSSeeder, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				err = fmt.Errorf("%s: the --dnsseeder option must "+
+					"be a valid host name -- parsed [%s]",
+					funcName, cfg.DNSSeeder)
+				break
+			}
+		}
+		if err == nil {
+			_, _, err = net.SplitHostPort(cfg.DNSSeederListen)
+			if err != nil {
+				err = fmt.Errorf("%s: DNS seeder listen address "+
+					"'%s' is invalid: %v", funcName,
+					cfg.DNSSeederListen, err)
+			}
+		}
+		if err == nil && cfg.DNSSeederCrawlers < 1 {
+			err = fmt.Errorf("%s: the --dnsseedercrawlers option "+
+				"must be at least 1 -- parsed [%d]", funcName,
+				cfg.DNSSeederCrawlers)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Setup dial and DNS resolution (lookup) functions depending on the
 	// specified options.  The default is to use the standard
 	// net.DialTimeout function as well as the system DNS resolver.  When a
@@ -1150,6 +2069,122 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.PruneTrashRetention < 0 {
+		err := fmt.Errorf("%s: the --prunetrashretention option may "+
+			"not be negative", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.Prune == 0 && (cfg.PruneTrashRetention != 0 ||
+		cfg.PruneTrashMaxSize != 0) {
+
+		err := fmt.Errorf("%s: the --prunetrashretention and "+
+			"--prunetrashmaxsize options require --prune", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.ReconstructPruned && cfg.PruneTrashRetention == 0 {
+		err := fmt.Errorf("%s: the --reconstructpruned option requires "+
+			"--prunetrashretention", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.DbSyncLatency < 0 {
+		err := fmt.Errorf("%s: the --dbsynclatency option may not be "+
+			"negative", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.DbSyncLatency != 0 && cfg.DbSyncInterval == 0 {
+		err := fmt.Errorf("%s: the --dbsynclatency option requires "+
+			"--dbsyncinterval", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.SharedBlockStore != "" && cfg.DbType != "ffldb" {
+		err := fmt.Errorf("%s: the --sharedblockstore option is only "+
+			"supported by the ffldb database type", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.DbCompressBlockIndex && cfg.DbType != "ffldb" {
+		err := fmt.Errorf("%s: the --dbcompressblockindex option is "+
+			"only supported by the ffldb database type", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.DbRepairSource != "" && cfg.DbType != "ffldb" {
+		err := fmt.Errorf("%s: the --dbrepairsource option is only "+
+			"supported by the ffldb database type", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.DbRepairManifestHash != "" && cfg.DbRepairSource == "" {
+		err := fmt.Errorf("%s: the --dbrepairmanifesthash option "+
+			"requires --dbrepairsource", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the repair source can be used, which requires a pinned
+	// manifest hash for plain http URLs.
+	if cfg.DbRepairSource != "" {
+		_, err := ffldb.NewDumpSource(cfg.DbRepairSource,
+			cfg.DbRepairManifestHash)
+		if err != nil {
+			err := fmt.Errorf("%s: invalid --dbrepairsource: %v",
+				funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	if cfg.FiltersOnly && cfg.Prune != 0 {
+		err := fmt.Errorf("%s: the --filtersonly and --prune options may "+
+			"not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.FiltersOnly && cfg.NoCFilters {
+		err := fmt.Errorf("%s: the --filtersonly and --nocfilters options "+
+			"may not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.FiltersOnly && (cfg.TxIndex || cfg.AddrIndex || cfg.SpentIndex ||
+		cfg.TimestampIndex || cfg.BalanceIndex) {
+
+		err := fmt.Errorf("%s: the --filtersonly option may not be "+
+			"activated at the same time as --txindex, --addrindex, "+
+			"--spentindex, --timestampindex or --balanceindex",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	if cfg.Prune != 0 && cfg.TxIndex {
 		err := fmt.Errorf("%s: the --prune and --txindex options may "+
 			"not be activated at the same time", funcName)
@@ -1166,6 +2201,33 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.Prune != 0 && cfg.SpentIndex {
+		err := fmt.Errorf("%s: the --prune and --spentindex options may "+
+			"not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.Prune != 0 && cfg.TimestampIndex {
+		err := fmt.Errorf("%s: the --prune and --timestampindex options "+
+			"may not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.Prune != 0 && cfg.BalanceIndex {
+		err := fmt.Errorf("%s: the --prune and --balanceindex options "+
+			"may not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Publish the options which can be reloaded at runtime.
+	liveConfig.Store(newReloadableConfig(&cfg))
+
 	// Warn about missing config file only after all other configuration is
 	// done.  This prevents the warning on help messages and invalid
 	// options.  Note this should go directly before the return.
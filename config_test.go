@@ -3,9 +3,11 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"testing"
+	"time"
 )
 
 var (
@@ -69,3 +71,24 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestReloadableConfigChanges ensures the changes between reloadable configs
+// are reported for the options which differ only.
+func TestReloadableConfigChanges(t *testing.T) {
+	c := defaultConfig()
+	oldCfg := newReloadableConfig(&c)
+	if changes := oldCfg.changes(newReloadableConfig(&c)); len(changes) != 0 {
+		t.Fatalf("unexpected changes for identical configs: %v", changes)
+	}
+
+	c.MaxPeers = 40
+	c.BanDuration = 2 * time.Hour
+	changes := oldCfg.changes(newReloadableConfig(&c))
+	want := []configChange{
+		{Option: "banduration", Old: "24h0m0s", New: "2h0m0s"},
+		{Option: "maxpeers", Old: "125", New: "40"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("unexpected changes: got %v, want %v", changes, want)
+	}
+}
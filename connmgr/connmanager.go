@@ -53,13 +53,16 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  A block relay only connection
+// is used to relay blocks only, not transactions or addresses, which makes it
+// harder for other peers to learn about it and to partition the node.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +127,16 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block relay only outbound
+	// connections to maintain in addition to TargetOutbound.
+	TargetBlockRelayOnly uint32
+
+	// Anchors are the addresses the block relay only connections are made
+	// to first, such as the addresses of the block relay only peers before
+	// a restart, before new addresses are used.  At most
+	// TargetBlockRelayOnly of them are used.
+	Anchors []net.Addr
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -213,12 +226,12 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 			theId := c.id
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
 				cm.Remove(theId)
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
 			go func(theId uint64) {
 				cm.Remove(theId)
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			}(c.id)
 		}
 	}
@@ -327,8 +340,9 @@ out:
 				// re added to the pending map, so that
 				// subsequent processing of connections and
 				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
-					connReq.Permanent {
+				target := cm.cfg.TargetOutbound +
+					cm.cfg.TargetBlockRelayOnly
+				if uint32(len(conns)) < target || connReq.Permanent {
 
 					connReq.updateState(ConnPending)
 					log.Debugf("Reconnecting to %v",
@@ -364,6 +378,18 @@ out:
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// NewBlockRelayOnlyConnReq creates a new block relay only connection request
+// and connects to the corresponding address.
+func (cm *ConnManager) NewBlockRelayOnlyConnReq() {
+	cm.newConnReq(true)
+}
+
+// newConnReq creates a new connection request of the passed type and connects
+// to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -371,7 +397,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -534,6 +560,23 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+
+	// Make the block relay only connections to the anchors first, but only
+	// when new connections are made automatically at all.
+	if cm.cfg.GetNewAddress == nil {
+		return
+	}
+	anchors := cm.cfg.Anchors
+	for i := uint32(0); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		if int(i) < len(anchors) {
+			go cm.Connect(&ConnReq{
+				Addr:           anchors[i],
+				BlockRelayOnly: true,
+			})
+			continue
+		}
+		go cm.NewBlockRelayOnlyConnReq()
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
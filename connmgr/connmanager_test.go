@@ -216,6 +216,59 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests the target number of block relay only
+// connections, which are made to the anchors first.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	anchor := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 18555}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       2,
+		TargetBlockRelayOnly: 2,
+		Anchors:              []net.Addr{anchor},
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	var numBlockRelayOnly, numAnchors int
+	for i := 0; i < 4; i++ {
+		c := <-connected
+		if !c.BlockRelayOnly {
+			continue
+		}
+		numBlockRelayOnly++
+		if c.Addr.String() == anchor.String() {
+			numAnchors++
+		}
+	}
+	if numBlockRelayOnly != 2 {
+		t.Fatalf("block relay only: got %d connections, want 2",
+			numBlockRelayOnly)
+	}
+	if numAnchors != 1 {
+		t.Fatalf("block relay only: got %d anchor connections, want 1",
+			numAnchors)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("block relay only: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
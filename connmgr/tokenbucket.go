@@ -0,0 +1,176 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket limits the rate of an activity, such as the number of bytes read
+// from peers or the number of addresses processed.  The bucket holds up to a
+// burst of tokens and is refilled at a constant rate.  Each unit of the
+// activity takes one token, so the activity can't exceed the rate over time
+// after the burst was used up.
+//
+// A nil TokenBucket imposes no limit.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mtx    sync.Mutex
+}
+
+// NewTokenBucket returns a token bucket which is refilled with the passed rate
+// of tokens per second and holds up to the passed burst of tokens.  The bucket
+// starts with the passed number of tokens.
+func NewTokenBucket(rate, burst, tokens float64) *TokenBucket {
+	if tokens > burst {
+		tokens = burst
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: tokens,
+		last:   time.Now(),
+	}
+}
+
+// Take takes the passed number of tokens from the bucket when it holds that
+// many and returns whether it did.
+//
+// This function is safe for concurrent access.
+func (b *TokenBucket) Take(n float64) bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	r := b.take(n, time.Now())
+	b.mtx.Unlock()
+	return r
+}
+
+// TryTake takes the passed number of tokens from the bucket when it holds that
+// many and returns zero.  Otherwise, it leaves the bucket untouched and returns
+// how long it takes until the bucket holds that many tokens.
+//
+// This function is safe for concurrent access.
+func (b *TokenBucket) TryTake(n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mtx.Lock()
+	delay := b.tryTake(n, time.Now())
+	b.mtx.Unlock()
+	return delay
+}
+
+// Add adds the passed number of tokens to the bucket, up to its burst.  It is
+// used to allow an activity which was solicited, such as the addresses sent in
+// response to a getaddr message.
+//
+// This function is safe for concurrent access.
+func (b *TokenBucket) Add(n float64) {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	b.refill(time.Now())
+	b.tokens += n
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.mtx.Unlock()
+}
+
+// Wait takes the passed number of tokens from the bucket, waiting until they
+// were refilled when the bucket doesn't hold enough of them.  More tokens than
+// the burst may be taken at once, in which case the bucket is left in debt
+// which must be refilled before tokens can be taken again.  It returns false
+// without waiting any further when the passed quit channel is closed.
+//
+// This function is safe for concurrent access.
+func (b *TokenBucket) Wait(n float64, quit <-chan struct{}) bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	delay := b.reserve(n, time.Now())
+	b.mtx.Unlock()
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+// refill adds the tokens earned since the bucket was last refilled at the
+// given point in time.
+//
+// This function is not safe for concurrent access.
+func (b *TokenBucket) refill(t time.Time) {
+	if elapsed := t.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = t
+}
+
+// take takes the passed number of tokens when the bucket holds that many at the
+// given point in time and returns whether it did.
+//
+// This function is not safe for concurrent access.  It is intended to be used
+// internally and during testing.
+func (b *TokenBucket) take(n float64, t time.Time) bool {
+	b.refill(t)
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// tryTake takes the passed number of tokens when the bucket holds that many at
+// the given point in time and returns zero, or otherwise returns how long it
+// takes until the bucket holds that many tokens.
+//
+// This function is not safe for concurrent access.  It is intended to be used
+// internally and during testing.
+func (b *TokenBucket) tryTake(n float64, t time.Time) time.Duration {
+	if b.take(n, t) {
+		return 0
+	}
+	delay := time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+	if delay <= 0 {
+		// Round up delays lost to the precision of the tokens.
+		delay = 1
+	}
+	return delay
+}
+
+// reserve takes the passed number of tokens at the given point in time, even
+// when that leaves the bucket in debt, and returns how long to wait until the
+// debt is refilled.
+//
+// This function is not safe for concurrent access.  It is intended to be used
+// internally and during testing.
+func (b *TokenBucket) reserve(n float64, t time.Time) time.Duration {
+	b.refill(t)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTokenBucket ensures tokens are taken up to the burst and refilled at the
+// configured rate.
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(10, 100, 1)
+	base := b.last
+
+	if !b.take(1, base) {
+		t.Fatal("unable to take the initial token")
+	}
+	if b.take(1, base) {
+		t.Fatal("took a token from an empty bucket")
+	}
+
+	// Half a second refills 5 tokens.
+	if !b.take(5, base.Add(time.Second/2)) {
+		t.Fatal("unable to take refilled tokens")
+	}
+	if b.take(1, base.Add(time.Second/2)) {
+		t.Fatal("took more tokens than were refilled")
+	}
+
+	// The bucket holds no more than the burst.
+	if b.take(101, base.Add(time.Hour)) {
+		t.Fatal("took more tokens than the burst")
+	}
+	if !b.take(100, base.Add(time.Hour)) {
+		t.Fatal("unable to take the burst")
+	}
+
+	// Added tokens are also limited by the burst.
+	b.Add(1000)
+	if b.tokens != 100 {
+		t.Fatalf("bucket holds %v tokens after adding, want 100", b.tokens)
+	}
+}
+
+// TestTokenBucketReserve ensures reserving more tokens than the bucket holds
+// returns the time until the debt is refilled.
+func TestTokenBucketReserve(t *testing.T) {
+	b := NewTokenBucket(1000, 1000, 1000)
+	base := b.last
+
+	if delay := b.reserve(500, base); delay != 0 {
+		t.Fatalf("reserving available tokens waits %v", delay)
+	}
+	if delay := b.reserve(2500, base); delay != 2*time.Second {
+		t.Fatalf("reserving 2000 tokens more than available waits %v, "+
+			"want 2s", delay)
+	}
+	if b.take(1, base.Add(time.Second)) {
+		t.Fatal("took a token before the debt was refilled")
+	}
+	if !b.take(1000, base.Add(3*time.Second)) {
+		t.Fatal("unable to take tokens after the debt was refilled")
+	}
+
+	// Waiting returns early when the quit channel is closed.
+	quit := make(chan struct{})
+	close(quit)
+	if b.Wait(1000000, quit) {
+		t.Fatal("wait did not return false after quit")
+	}
+
+	// A nil bucket imposes no limit.
+	var nilBucket *TokenBucket
+	if !nilBucket.Take(1) || !nilBucket.Wait(1, nil) || nilBucket.TryTake(1) != 0 {
+		t.Fatal("nil bucket limits")
+	}
+}
+
+// TestTokenBucketTryTake ensures trying to take more tokens than the bucket
+// holds leaves the bucket untouched and returns the time until they are
+// refilled.
+func TestTokenBucketTryTake(t *testing.T) {
+	b := NewTokenBucket(2, 2, 2)
+	base := b.last
+
+	if delay := b.tryTake(2, base); delay != 0 {
+		t.Fatalf("taking available tokens waits %v", delay)
+	}
+	if delay := b.tryTake(1, base); delay != time.Second/2 {
+		t.Fatalf("taking a token from an empty bucket waits %v, want "+
+			"500ms", delay)
+	}
+	if delay := b.tryTake(1, base.Add(time.Second/4)); delay != time.Second/4 {
+		t.Fatalf("taking a token from a partially refilled bucket "+
+			"waits %v, want 250ms", delay)
+	}
+	if delay := b.tryTake(1, base.Add(time.Second/2)); delay != 0 {
+		t.Fatalf("taking a refilled token waits %v", delay)
+	}
+}
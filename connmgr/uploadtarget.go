@@ -0,0 +1,183 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// UploadTargetTimeframe is the length of the cycle the upload target
+	// applies to.
+	UploadTargetTimeframe = 24 * time.Hour
+
+	// recentBlocksBuffer is the number of bytes of the upload target which
+	// are reserved for serving recent blocks.  Historical blocks are no
+	// longer served once less than this is left in the cycle, so there is
+	// enough left to serve a maximum sized block for every 10 minutes of
+	// the cycle.
+	recentBlocksBuffer = uint64(UploadTargetTimeframe/(10*time.Minute)) *
+		wire.MaxBlockPayload
+)
+
+// UploadTargetStatus describes the state of an upload target.
+type UploadTargetStatus struct {
+	// Target is the number of bytes which may be sent per cycle, or 0
+	// when there is no target.
+	Target uint64
+
+	// Timeframe is the length of the cycle.
+	Timeframe time.Duration
+
+	// TargetReached is whether the target was reached in the current
+	// cycle.
+	TargetReached bool
+
+	// ServeHistoricalBlocks is whether historical blocks are still served
+	// in the current cycle.
+	ServeHistoricalBlocks bool
+
+	// BytesLeft is the number of bytes left in the current cycle.
+	BytesLeft uint64
+
+	// TimeLeft is the time left until the current cycle ends.
+	TimeLeft time.Duration
+}
+
+// UploadTarget keeps track of the bytes sent to peers per cycle of 24 hours to
+// enforce a daily upload target.  Recent blocks are prioritized over historical
+// ones by reserving a part of the target for serving them.
+//
+// A nil UploadTarget, or one with a target of 0, imposes no limit.
+type UploadTarget struct {
+	target     uint64
+	cycleStart time.Time
+	sent       uint64
+	mtx        sync.Mutex
+}
+
+// NewUploadTarget returns an upload target which allows the passed number of
+// bytes to be sent per cycle.
+func NewUploadTarget(target uint64) *UploadTarget {
+	return &UploadTarget{target: target}
+}
+
+// AddBytesSent adds the passed number of bytes to the bytes sent during the
+// current cycle, which starts a new cycle when the previous one ended.
+//
+// This function is safe for concurrent access.
+func (u *UploadTarget) AddBytesSent(n uint64) {
+	if u == nil {
+		return
+	}
+	u.mtx.Lock()
+	u.addBytesSent(n, time.Now())
+	u.mtx.Unlock()
+}
+
+// Reached returns whether the target was reached in the current cycle.  When
+// historical is true, it returns whether the part of the target available for
+// serving historical blocks was reached instead.
+//
+// This function is safe for concurrent access.
+func (u *UploadTarget) Reached(historical bool) bool {
+	if u == nil {
+		return false
+	}
+	u.mtx.Lock()
+	r := u.reached(historical, time.Now())
+	u.mtx.Unlock()
+	return r
+}
+
+// Status returns the state of the upload target.
+//
+// This function is safe for concurrent access.
+func (u *UploadTarget) Status() UploadTargetStatus {
+	if u == nil {
+		return UploadTargetStatus{
+			Timeframe:             UploadTargetTimeframe,
+			ServeHistoricalBlocks: true,
+		}
+	}
+	u.mtx.Lock()
+	r := u.status(time.Now())
+	u.mtx.Unlock()
+	return r
+}
+
+// addBytesSent adds the passed number of bytes to the bytes sent during the
+// cycle at the given point in time.
+//
+// This function is not safe for concurrent access.  It is intended to be used
+// internally and during testing.
+func (u *UploadTarget) addBytesSent(n uint64, t time.Time) {
+	if u.cycleEnded(t) {
+		u.cycleStart = t
+		u.sent = 0
+	}
+	u.sent += n
+}
+
+// cycleEnded returns whether no cycle was started yet or the current one ended
+// at the given point in time.
+//
+// This function is not safe for concurrent access.
+func (u *UploadTarget) cycleEnded(t time.Time) bool {
+	return u.cycleStart.IsZero() ||
+		!t.Before(u.cycleStart.Add(UploadTargetTimeframe))
+}
+
+// reached returns whether the target, or the part of the target available for
+// serving historical blocks when historical is true, was reached at the given
+// point in time.
+//
+// This function is not safe for concurrent access.
+func (u *UploadTarget) reached(historical bool, t time.Time) bool {
+	if u.target == 0 {
+		return false
+	}
+	var sent uint64
+	if !u.cycleEnded(t) {
+		sent = u.sent
+	}
+	if historical {
+		return recentBlocksBuffer >= u.target ||
+			sent >= u.target-recentBlocksBuffer
+	}
+	return sent >= u.target
+}
+
+// status returns the state of the upload target at the given point in time.
+//
+// This function is not safe for concurrent access.  It is intended to be used
+// internally and during testing.
+func (u *UploadTarget) status(t time.Time) UploadTargetStatus {
+	s := UploadTargetStatus{
+		Target:                u.target,
+		Timeframe:             UploadTargetTimeframe,
+		TargetReached:         u.reached(false, t),
+		ServeHistoricalBlocks: !u.reached(true, t),
+	}
+	if u.target == 0 {
+		return s
+	}
+
+	// A new cycle starts with the next bytes sent once the current one
+	// ended.
+	if u.cycleEnded(t) {
+		s.BytesLeft = u.target
+		s.TimeLeft = UploadTargetTimeframe
+		return s
+	}
+	if u.sent < u.target {
+		s.BytesLeft = u.target - u.sent
+	}
+	s.TimeLeft = u.cycleStart.Add(UploadTargetTimeframe).Sub(t)
+	return s
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUploadTarget ensures the upload target reserves the buffer for recent
+// blocks, is reached once the target was sent and is reset by a new cycle.
+func TestUploadTarget(t *testing.T) {
+	const target = recentBlocksBuffer + 1000
+	u := NewUploadTarget(target)
+	base := time.Now()
+
+	status := u.status(base)
+	if status.TargetReached || !status.ServeHistoricalBlocks ||
+		status.BytesLeft != target || status.TimeLeft != UploadTargetTimeframe {
+
+		t.Fatalf("unexpected initial status %+v", status)
+	}
+
+	u.addBytesSent(999, base)
+	if u.reached(true, base) || u.reached(false, base) {
+		t.Fatal("target reached before the historical limit")
+	}
+
+	u.addBytesSent(1, base.Add(time.Hour))
+	if !u.reached(true, base) || u.reached(false, base) {
+		t.Fatal("historical limit not reached")
+	}
+	status = u.status(base.Add(time.Hour))
+	if status.ServeHistoricalBlocks || status.BytesLeft != recentBlocksBuffer ||
+		status.TimeLeft != UploadTargetTimeframe-time.Hour {
+
+		t.Fatalf("unexpected status %+v after the historical limit",
+			status)
+	}
+
+	u.addBytesSent(recentBlocksBuffer, base.Add(time.Hour))
+	if !u.reached(false, base) {
+		t.Fatal("target not reached")
+	}
+	if status := u.status(base); status.BytesLeft != 0 {
+		t.Fatalf("%d bytes left after reaching the target",
+			status.BytesLeft)
+	}
+
+	// The target is no longer reached once the cycle ended.
+	end := base.Add(UploadTargetTimeframe)
+	if u.reached(false, end) || u.reached(true, end) {
+		t.Fatal("target reached after the cycle ended")
+	}
+	u.addBytesSent(1, end)
+	if status := u.status(end); status.BytesLeft != target-1 ||
+		status.TimeLeft != UploadTargetTimeframe {
+
+		t.Fatalf("unexpected status %+v in the new cycle", status)
+	}
+
+	// A target below the buffer never serves historical blocks.
+	if !NewUploadTarget(recentBlocksBuffer).Reached(true) {
+		t.Fatal("historical blocks served with a target below the buffer")
+	}
+
+	// No target imposes no limit.
+	u = NewUploadTarget(0)
+	u.addBytesSent(recentBlocksBuffer*10, base)
+	if u.Reached(true) || u.Reached(false) {
+		t.Fatal("target of 0 reached")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+)
+
+// cloneCmd defines the configuration options for the clone command.
+type cloneCmd struct {
+	CopyOnWrite bool `long:"cow" description:"Share completed block files with the source database using copy-on-write reflinks (or hard links when unsupported) instead of copying them"`
+}
+
+var (
+	// cloneCfg defines the configuration options for the command.
+	cloneCfg = cloneCmd{
+		CopyOnWrite: false,
+	}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *cloneCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return errors.New("the destination data directory must be " +
+			"specified")
+	}
+
+	// The clone is laid out the same way as the source so the destination
+	// can be used directly as the data directory of another instance.
+	destDataDir := filepath.Join(args[0], netName(activeNetParams))
+	destDbPath := filepath.Join(destDataDir,
+		blockDbNamePrefix+"_"+cfg.DbType)
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log.Infof("Cloning block database to '%s'", destDbPath)
+	startTime := time.Now()
+	stats, err := ffldb.Clone(db, destDbPath, cmd.CopyOnWrite)
+	if err != nil {
+		return err
+	}
+	log.Infof("Cloned %d metadata entries in %v", stats.MetadataEntries,
+		time.Since(startTime))
+	log.Infof("Block files: %d reflinked, %d hard linked, %d copied "+
+		"(%d bytes)", stats.ReflinkedFiles, stats.LinkedFiles,
+		stats.CopiedFiles, stats.CopiedBytes)
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *cloneCmd) Usage() string {
+	return "[--cow] <destination-data-directory>"
+}
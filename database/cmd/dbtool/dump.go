@@ -0,0 +1,77 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+)
+
+// dumpCmd defines the configuration options for the dump command.
+type dumpCmd struct {
+	CopyOnWrite  bool  `long:"cow" description:"Share completed block files with the source database using copy-on-write reflinks (or hard links when unsupported) instead of copying them"`
+	ChunkSize    int64 `long:"chunksize" description:"Size in bytes of the block file chunks that are verified and restored individually by the repair command"`
+	RangeEntries int   `long:"rangeentries" description:"Number of metadata entries per key range"`
+}
+
+var (
+	// dumpCfg defines the configuration options for the command.
+	dumpCfg = dumpCmd{
+		CopyOnWrite:  false,
+		ChunkSize:    16 * 1024 * 1024,
+		RangeEntries: 100000,
+	}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *dumpCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return errors.New("the destination directory must be specified")
+	}
+	if cmd.ChunkSize <= 0 || cmd.RangeEntries <= 0 {
+		return errors.New("the chunk size and range entries must be " +
+			"positive")
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log.Infof("Dumping block database to '%s'", args[0])
+	startTime := time.Now()
+	dumpCfg := ffldb.DumpConfig{
+		CopyOnWrite:  cmd.CopyOnWrite,
+		ChunkSize:    cmd.ChunkSize,
+		RangeEntries: cmd.RangeEntries,
+	}
+	stats, err := ffldb.Dump(db, args[0], dumpCfg)
+	if err != nil {
+		return err
+	}
+	log.Infof("Dumped %d metadata entries in %d ranges in %v",
+		stats.MetadataEntries, stats.MetadataRanges,
+		time.Since(startTime))
+	log.Infof("Block files: %d reflinked, %d hard linked, %d copied "+
+		"(%d bytes)", stats.ReflinkedFiles, stats.LinkedFiles,
+		stats.CopiedFiles, stats.CopiedBytes)
+	log.Infof("Manifest hash: %s", stats.ManifestHash)
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *dumpCmd) Usage() string {
+	return "[--cow] [--chunksize=<bytes>] [--rangeentries=<n>] " +
+		"<destination-directory>"
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+)
+
+// dumpUtxoCmd defines the configuration options for the dumputxo command.
+type dumpUtxoCmd struct {
+	OutFile string `short:"o" long:"outfile" description:"File to write the utxo snapshot to"`
+}
+
+var (
+	// dumpUtxoCfg defines the configuration options for the command.
+	dumpUtxoCfg = dumpUtxoCmd{
+		OutFile: "utxo.dat",
+	}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *dumpUtxoCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file that is renamed once the snapshot is
+	// complete so a partial snapshot is never left behind.
+	tmpFile := cmd.OutFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+
+	log.Infof("Writing utxo snapshot to %s", cmd.OutFile)
+	startTime := time.Now()
+	w := bufio.NewWriter(f)
+	meta, err := chain.DumpUtxoSnapshot(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, cmd.OutFile); err != nil {
+		return err
+	}
+
+	log.Infof("Wrote %d coins at block %v (height %d) in %v",
+		meta.NumCoins, meta.BaseHash, meta.BaseHeight,
+		time.Since(startTime))
+	log.Infof("Utxo set hash: %v", meta.UtxoSetHash)
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// coreMaxBlockFileSize is the maximum size of a single blk*.dat file
+	// written by Bitcoin Core.  It is used as the default size at which a
+	// new file is started when exporting to a directory.
+	coreMaxBlockFileSize = 128 * 1024 * 1024
+
+	// blockFrameHeaderSize is the size of the framing that precedes each
+	// serialized block in the output files.  It consists of the network
+	// magic followed by the length of the serialized block.
+	blockFrameHeaderSize = 8
+)
+
+// exportBlocksCmd defines the configuration options for the exportblocks
+// command.
+type exportBlocksCmd struct {
+	OutFile     string `short:"o" long:"outfile" description:"File to write the blocks to in bootstrap.dat format -- Ignored when --outdir is specified"`
+	OutDir      string `short:"d" long:"outdir" description:"Directory to write the blocks to as Bitcoin Core style blk*.dat files"`
+	MaxFileSize uint32 `long:"maxfilesize" description:"Maximum size in bytes of each blk*.dat file when --outdir is specified"`
+	StartHeight int32  `long:"start" description:"Height of the first block to export"`
+	EndHeight   int32  `long:"end" description:"Height of the last block to export -- Use -1 to export through the current best block"`
+	Progress    int    `short:"p" long:"progress" description:"Show a progress message each time this number of seconds have passed -- Use 0 to disable progress announcements"`
+}
+
+var (
+	// exportBlocksCfg defines the configuration options for the command.
+	exportBlocksCfg = exportBlocksCmd{
+		OutFile:     "bootstrap.dat",
+		MaxFileSize: coreMaxBlockFileSize,
+		StartHeight: 0,
+		EndHeight:   -1,
+		Progress:    10,
+	}
+)
+
+// blockFileWriter writes serialized blocks using the framing expected by
+// Bitcoin Core's -loadblock option and blk*.dat parsers.  When a directory
+// is configured, the output is split across sequentially numbered blk*.dat
+// files which are each limited to a maximum size.
+type blockFileWriter struct {
+	magic       uint32
+	outFile     string
+	outDir      string
+	maxFileSize uint32
+
+	fileNum  uint32
+	fileSize uint32
+	file     *os.File
+	w        *bufio.Writer
+}
+
+// coreBlockFilePath returns the path of the Bitcoin Core style block file
+// with the provided number inside the passed directory.
+func coreBlockFilePath(dir string, fileNum uint32) string {
+	return filepath.Join(dir, fmt.Sprintf("blk%05d.dat", fileNum))
+}
+
+// openFile opens the next output file, closing the current one if needed.
+func (w *blockFileWriter) openFile() error {
+	if w.file != nil {
+		if err := w.close(); err != nil {
+			return err
+		}
+		w.fileNum++
+	}
+
+	path := w.outFile
+	if w.outDir != "" {
+		path = coreBlockFilePath(w.outDir, w.fileNum)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Writing blocks to %s", path)
+	w.file = file
+	w.w = bufio.NewWriterSize(file, 1<<20)
+	w.fileSize = 0
+	return nil
+}
+
+// writeBlock writes the passed serialized block along with its framing to
+// the current output file.  A new file is started first when writing to a
+// directory and the block would cause the current file to exceed the
+// maximum file size.
+func (w *blockFileWriter) writeBlock(serializedBlock []byte) error {
+	frameSize := uint32(blockFrameHeaderSize + len(serializedBlock))
+	needNewFile := w.file == nil || (w.outDir != "" && w.fileSize > 0 &&
+		w.fileSize+frameSize > w.maxFileSize)
+	if needNewFile {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	// The block file format is:
+	//  <network> <block length> <serialized block>
+	var header [blockFrameHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:4], w.magic)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(serializedBlock)))
+	if _, err := w.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(serializedBlock); err != nil {
+		return err
+	}
+	w.fileSize += frameSize
+	return nil
+}
+
+// close flushes and closes the current output file, if any.
+func (w *blockFileWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	if err := w.w.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	err := w.file.Close()
+	w.file = nil
+	w.w = nil
+	return err
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *exportBlocksCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if cmd.StartHeight < 0 {
+		return errors.New("the start height must not be negative")
+	}
+	if cmd.EndHeight >= 0 && cmd.EndHeight < cmd.StartHeight {
+		return fmt.Errorf("the end height %d is before the start "+
+			"height %d", cmd.EndHeight, cmd.StartHeight)
+	}
+	if cmd.OutDir != "" {
+		if cmd.MaxFileSize <= blockFrameHeaderSize {
+			return fmt.Errorf("the maximum file size must be larger "+
+				"than %d bytes", blockFrameHeaderSize)
+		}
+		if err := os.MkdirAll(cmd.OutDir, 0700); err != nil {
+			return err
+		}
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The chain is loaded in order to determine the blocks that make up
+	// the main chain since the database itself has no notion of heights.
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	best := chain.BestSnapshot()
+	endHeight := cmd.EndHeight
+	if endHeight < 0 || endHeight > best.Height {
+		endHeight = best.Height
+	}
+	if cmd.StartHeight > endHeight {
+		return fmt.Errorf("the start height %d is after the best "+
+			"height %d", cmd.StartHeight, best.Height)
+	}
+
+	// Stop the export early on Ctrl+C while still leaving the output in
+	// a consistent state.
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		close(interrupt)
+	})
+
+	writer := &blockFileWriter{
+		magic:       uint32(activeNetParams.Net),
+		outFile:     cmd.OutFile,
+		outDir:      cmd.OutDir,
+		maxFileSize: cmd.MaxFileSize,
+	}
+
+	log.Infof("Exporting blocks %d through %d", cmd.StartHeight, endHeight)
+	var numExported, numBytes int64
+	lastLogTime := time.Now()
+	startTime := lastLogTime
+out:
+	for height := cmd.StartHeight; height <= endHeight; height++ {
+		select {
+		case <-interrupt:
+			log.Infof("Export interrupted at height %d", height)
+			break out
+		default:
+		}
+
+		hash, err := chain.BlockHashByHeight(height)
+		if err != nil {
+			writer.close()
+			return err
+		}
+		var serializedBlock []byte
+		err = db.View(func(tx database.Tx) error {
+			var err error
+			serializedBlock, err = tx.FetchBlock(hash)
+			return err
+		})
+		if err != nil {
+			writer.close()
+			return fmt.Errorf("unable to fetch block %v (height %d): "+
+				"%v", hash, height, err)
+		}
+
+		if err := writer.writeBlock(serializedBlock); err != nil {
+			writer.close()
+			return err
+		}
+		numExported++
+		numBytes += int64(len(serializedBlock)) + blockFrameHeaderSize
+
+		now := time.Now()
+		if cmd.Progress > 0 && now.Sub(lastLogTime) >=
+			time.Second*time.Duration(cmd.Progress) {
+
+			log.Infof("Exported %d blocks (height %d, %d bytes)",
+				numExported, height, numBytes)
+			lastLogTime = now
+		}
+	}
+	if err := writer.close(); err != nil {
+		return err
+	}
+
+	log.Infof("Exported a total of %d blocks (%d bytes) in %v",
+		numExported, numBytes, time.Since(startTime))
+	return nil
+}
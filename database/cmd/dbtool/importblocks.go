@@ -0,0 +1,89 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+)
+
+// importBlocksCmd defines the configuration options for the importblocks
+// command.
+type importBlocksCmd struct {
+	InDir   string `short:"i" long:"indir" description:"Bitcoin Core blocks directory containing the blk*.dat files"`
+	FastAdd bool   `long:"fastadd" description:"Skip script validation and other expensive checks -- WARNING: Only use this with block files from a trusted source"`
+}
+
+var (
+	// importBlocksCfg defines the configuration options for the command.
+	importBlocksCfg = importBlocksCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *importBlocksCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Ensure the specified blocks directory exists.
+	if cmd.InDir == "" {
+		return fmt.Errorf("the blocks directory to import from must be " +
+			"specified with --indir")
+	}
+	if !fileExists(cmd.InDir) {
+		str := "The specified blocks directory [%v] does not exist"
+		return fmt.Errorf(str, cmd.InDir)
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Stop the import on Ctrl+C.  The blocks processed so far remain in
+	// the database.
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		close(interrupt)
+	})
+
+	flags := blockchain.BFNone
+	if cmd.FastAdd {
+		flags |= blockchain.BFFastAdd
+	}
+
+	log.Infof("Importing blocks from %s", cmd.InDir)
+	startTime := time.Now()
+	numImported, err := chain.ImportBlocksFromCore(cmd.InDir, flags,
+		interrupt)
+	if err != nil {
+		return err
+	}
+
+	// Flush the changes made to the blockchain.
+	log.Info("Flushing blockchain caches to the disk...")
+	if err := chain.FlushUtxoCache(blockchain.FlushRequired); err != nil {
+		return err
+	}
+
+	best := chain.BestSnapshot()
+	log.Infof("Imported %d blocks in %v (best block %v, height %d)",
+		numImported, time.Since(startTime), best.Hash, best.Height)
+	return nil
+}
@@ -7,8 +7,8 @@ package main
 import (
 	"time"
 
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
 )
 
 // headersCmd defines the configuration options for the loadheaders command.
@@ -39,28 +39,22 @@ func (cmd *headersCmd) Execute(args []string) error {
 
 	// NOTE: This code will only work for ffldb.  Ideally the package using
 	// the database would keep a metadata index of its own.
-	blockIdxName := []byte("ffldb-blockidx")
+	hashes, err := ffldb.BlockHashes(db)
+	if err != nil {
+		return err
+	}
 	if !headersCfg.Bulk {
 		err = db.View(func(tx database.Tx) error {
-			totalHdrs := 0
-			blockIdxBucket := tx.Metadata().Bucket(blockIdxName)
-			blockIdxBucket.ForEach(func(k, v []byte) error {
-				totalHdrs++
-				return nil
-			})
-			log.Infof("Loading headers for %d blocks...", totalHdrs)
+			log.Infof("Loading headers for %d blocks...", len(hashes))
 			numLoaded := 0
 			startTime := time.Now()
-			blockIdxBucket.ForEach(func(k, v []byte) error {
-				var hash chainhash.Hash
-				copy(hash[:], k)
-				_, err := tx.FetchBlockHeader(&hash)
+			for i := range hashes {
+				_, err := tx.FetchBlockHeader(&hashes[i])
 				if err != nil {
 					return err
 				}
 				numLoaded++
-				return nil
-			})
+			}
 			log.Infof("Loaded %d headers in %v", numLoaded,
 				time.Since(startTime))
 			return nil
@@ -70,15 +64,6 @@ func (cmd *headersCmd) Execute(args []string) error {
 
 	// Bulk load headers.
 	err = db.View(func(tx database.Tx) error {
-		blockIdxBucket := tx.Metadata().Bucket(blockIdxName)
-		hashes := make([]chainhash.Hash, 0, 500000)
-		blockIdxBucket.ForEach(func(k, v []byte) error {
-			var hash chainhash.Hash
-			copy(hash[:], k)
-			hashes = append(hashes, hash)
-			return nil
-		})
-
 		log.Infof("Loading headers for %d blocks...", len(hashes))
 		startTime := time.Now()
 		hdrs, err := tx.FetchBlockHeaders(hashes)
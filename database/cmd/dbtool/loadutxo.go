@@ -0,0 +1,143 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+// loadUtxoCmd defines the configuration options for the loadutxo command.
+type loadUtxoCmd struct {
+	InFile     string `short:"i" long:"infile" description:"File containing the utxo snapshot"`
+	Hash       string `long:"hash" description:"Expected utxo set hash of the snapshot obtained from a trusted source"`
+	ValidateDB string `long:"validatedb" description:"Path to a block database that contains the blocks before the snapshot, used to validate the history of the snapshot after loading it"`
+}
+
+var (
+	// loadUtxoCfg defines the configuration options for the command.
+	loadUtxoCfg = loadUtxoCmd{
+		InFile: "utxo.dat",
+	}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *loadUtxoCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if cmd.Hash == "" {
+		return fmt.Errorf("the expected utxo set hash must be specified " +
+			"with --hash")
+	}
+	expectedHash, err := chainhash.NewHashFromStr(cmd.Hash)
+	if err != nil {
+		return err
+	}
+	if !fileExists(cmd.InFile) {
+		str := "The specified snapshot file [%v] does not exist"
+		return fmt.Errorf(str, cmd.InFile)
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	status, err := chain.UtxoSnapshotStatus()
+	if err != nil {
+		return err
+	}
+
+	// Only load the snapshot when one isn't already loaded so the command
+	// can be run again to resume validating the history.
+	if status == nil {
+		f, err := os.Open(cmd.InFile)
+		if err != nil {
+			return err
+		}
+		log.Infof("Loading utxo snapshot from %s", cmd.InFile)
+		startTime := time.Now()
+		meta, err := chain.LoadUtxoSnapshot(f, expectedHash)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		log.Infof("Loaded %d coins at block %v (height %d) in %v",
+			meta.NumCoins, meta.BaseHash, meta.BaseHeight,
+			time.Since(startTime))
+	}
+
+	if cmd.ValidateDB == "" {
+		return nil
+	}
+	return validateUtxoSnapshot(chain, cmd.ValidateDB)
+}
+
+// validateUtxoSnapshot validates the history of the utxo snapshot loaded into
+// the passed chain using the blocks from the database at the passed path.
+func validateUtxoSnapshot(chain *blockchain.BlockChain, dbPath string) error {
+	log.Infof("Loading source block database from '%s'", dbPath)
+	srcDB, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+
+	// Stop the validation on Ctrl+C.  The progress is saved, so running
+	// the command again resumes it.
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		close(interrupt)
+	})
+
+	fetchBlock := func(hash *chainhash.Hash) (*btcutil.Block, error) {
+		var block *btcutil.Block
+		err := srcDB.View(func(tx database.Tx) error {
+			blockBytes, err := tx.FetchBlock(hash)
+			if err != nil {
+				return err
+			}
+			block, err = btcutil.NewBlockFromBytes(blockBytes)
+			return err
+		})
+		return block, err
+	}
+
+	startTime := time.Now()
+	if err := chain.ValidateUtxoSnapshot(fetchBlock, interrupt); err != nil {
+		return err
+	}
+	status, err := chain.UtxoSnapshotStatus()
+	if err != nil {
+		return err
+	}
+	if !status.Validated {
+		log.Infof("Validated the snapshot history up to height %d in "+
+			"%v", status.ValidatedHeight, time.Since(startTime))
+		return nil
+	}
+	log.Infof("Validated the snapshot history in %v", time.Since(startTime))
+	return nil
+}
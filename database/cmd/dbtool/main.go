@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btclog"
 	flags "github.com/jessevdk/go-flags"
@@ -66,6 +67,9 @@ func realMain() error {
 	dbLog := backendLogger.Logger("BCDB")
 	dbLog.SetLevel(btclog.LevelDebug)
 	database.UseLogger(dbLog)
+	chainLog := backendLogger.Logger("CHAN")
+	chainLog.SetLevel(btclog.LevelInfo)
+	blockchain.UseLogger(chainLog)
 
 	// Setup the parser options and commands.
 	appName := filepath.Base(os.Args[0])
@@ -88,6 +92,83 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("exportblocks",
+		"Export the main chain blocks in Bitcoin Core's block file format",
+		"Export the main chain blocks in height order using the "+
+			"framing of Bitcoin Core's bootstrap.dat and blk*.dat "+
+			"files (network magic, block length, serialized "+
+			"block).  The output can be loaded by Core via "+
+			"-loadblock or by the insecureimport command.",
+		&exportBlocksCfg)
+	parser.AddCommand("importblocks",
+		"Import blocks from Bitcoin Core's blk*.dat files",
+		"Import blocks directly from the blk*.dat files in a Bitcoin "+
+			"Core blocks directory.  The blocks are ordered, fully "+
+			"validated unless --fastadd is specified, and connected "+
+			"to the chain.", &importBlocksCfg)
+	parser.AddCommand("restoretrash",
+		"Restore pruned block files from the trash",
+		"Restore pruned block files that were moved to the trash "+
+			"due to --prunetrashretention back into the database "+
+			"and re-add their blocks to the block index.  All "+
+			"files in the trash are restored when no block file "+
+			"numbers are specified.", &restoreTrashCfg)
+	parser.AddCommand("clone",
+		"Create a consistent copy of the block database",
+		"Create a consistent copy of the block database under the "+
+			"specified data directory, for example to seed a test "+
+			"node.  With --cow, completed block files are shared "+
+			"with the source using copy-on-write reflinks where "+
+			"the file system supports them (hard links "+
+			"otherwise) so the clone uses almost no additional "+
+			"disk space.", &cloneCfg)
+	parser.AddCommand("dump",
+		"Create a dump of the block database for repairs",
+		"Create a dump of the block database in the specified "+
+			"directory along with a manifest of hashes of the "+
+			"chunks of the block files and of metadata key "+
+			"ranges.  The repair command uses the manifest to "+
+			"restore only the damaged parts of a database from "+
+			"the dump.", &dumpCfg)
+	parser.AddCommand("repair",
+		"Repair a damaged block database from a dump",
+		"Verify the block database against the manifest of a dump "+
+			"created by the dump command and restore the damaged "+
+			"block file chunks, missing block files and damaged "+
+			"metadata key ranges from the dump, which is either a "+
+			"local directory or an HTTP URL the dump directory is "+
+			"served from.  The database is rolled back to the "+
+			"state of the dump when its metadata is damaged and "+
+			"changed since the dump was made.", &repairCfg)
+	parser.AddCommand("upgradeblockindex",
+		"Convert the block index to the compressed format",
+		"Convert the block index to the compressed format which "+
+			"is keyed by short block hash prefixes.  Databases "+
+			"use the original format until they are converted.  "+
+			"The conversion can't be undone and older versions "+
+			"are unable to open the database afterwards.",
+		&upgradeBlockIndexCfg)
+	parser.AddCommand("dumputxo",
+		"Write a snapshot of the utxo set",
+		"Write a snapshot of the utxo set at the current best block "+
+			"along with the block headers needed to load it.  The "+
+			"utxo set hash printed when done must be passed to "+
+			"loadutxo.", &dumpUtxoCfg)
+	parser.AddCommand("loadutxo",
+		"Initialize a new database from a utxo snapshot",
+		"Initialize a database without any blocks from a utxo "+
+			"snapshot written by dumputxo so the node syncs from "+
+			"the snapshot block instead of the genesis block.  "+
+			"The history before the snapshot block is validated "+
+			"when --validatedb is specified.", &loadUtxoCfg)
+	parser.AddCommand("parsemessages",
+		"Decode the P2P messages recorded with --capturemessages",
+		"Decode the P2P messages recorded by btcd with "+
+			"--capturemessages in the specified files, or in all "+
+			"capture files under the specified directories, and "+
+			"write them as a JSON array ordered by time.  "+
+			"Messages which fail to decode are written with their "+
+			"payload in hex.", &parseMessagesCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
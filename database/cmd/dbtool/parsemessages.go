@@ -0,0 +1,173 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/btcsuite/btcd/msgcapture"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// parseMessagesCmd defines the configuration options for the parsemessages
+// command.
+type parseMessagesCmd struct {
+	ProtocolVersion uint32 `long:"pver" description:"Protocol version to decode the messages with"`
+	OutFile         string `short:"o" long:"outfile" description:"File to write the decoded messages to instead of stdout"`
+}
+
+var (
+	// parseMessagesCfg defines the configuration options for the command.
+	parseMessagesCfg = parseMessagesCmd{
+		ProtocolVersion: wire.ProtocolVersion,
+	}
+)
+
+// parsedMessage is a decoded message of a message capture file.
+type parsedMessage struct {
+	Time      int64       `json:"time"`
+	Direction string      `json:"direction"`
+	Peer      string      `json:"peer"`
+	Command   string      `json:"msgtype"`
+	Size      int         `json:"size"`
+	Body      interface{} `json:"body,omitempty"`
+	Payload   string      `json:"payload,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// captureFiles returns the message capture files named by the passed paths.
+// Directories are searched for the files recursively.
+func captureFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			name := info.Name()
+			if p == path || name == msgcapture.SentFileName ||
+				name == msgcapture.RecvFileName {
+
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// parseCaptureFile decodes the messages of the passed message capture file.
+func (cmd *parseMessagesCmd) parseCaptureFile(path string) ([]parsedMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	direction := "recv"
+	if filepath.Base(path) == msgcapture.SentFileName {
+		direction = "sent"
+	}
+	peer := filepath.Base(filepath.Dir(path))
+
+	var msgs []parsedMessage
+	for {
+		rec, err := msgcapture.ReadRecord(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		parsed := parsedMessage{
+			Time:      rec.Time.UnixNano() / 1000,
+			Direction: direction,
+			Peer:      peer,
+			Command:   rec.Command,
+			Size:      len(rec.Payload),
+		}
+		msg, err := rec.Decode(cmd.ProtocolVersion, wire.WitnessEncoding)
+		if err != nil {
+			parsed.Error = err.Error()
+			parsed.Payload = hex.EncodeToString(rec.Payload)
+		} else {
+			parsed.Body = msg
+		}
+		msgs = append(msgs, parsed)
+	}
+	return msgs, nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *parseMessagesCmd) Execute(args []string) error {
+	if len(args) == 0 {
+		return errors.New("no message capture files or directories " +
+			"specified")
+	}
+
+	files, err := captureFiles(args)
+	if err != nil {
+		return err
+	}
+
+	// Decode the messages of all files and order them by time so the
+	// messages sent and received are interleaved.
+	var msgs []parsedMessage
+	for _, file := range files {
+		parsed, err := cmd.parseCaptureFile(file)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, parsed...)
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].Time < msgs[j].Time
+	})
+
+	out := os.Stdout
+	if cmd.OutFile != "" {
+		out, err = os.Create(cmd.OutFile)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	if msgs == nil {
+		msgs = []parsedMessage{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(msgs); err != nil {
+		return err
+	}
+
+	// The log is written to stdout as well, so only log when the messages
+	// are written to a file.
+	if cmd.OutFile != "" {
+		log.Infof("Decoded %d messages from %d files", len(msgs),
+			len(files))
+	}
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *parseMessagesCmd) Usage() string {
+	return "<file-or-directory>..."
+}
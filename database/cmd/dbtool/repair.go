@@ -0,0 +1,71 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+)
+
+// repairCmd defines the configuration options for the repair command.
+type repairCmd struct {
+	ManifestHash string `long:"manifesthash" description:"Hex-encoded SHA-256 hash of the manifest of the dump -- The dump is only used when its manifest matches -- Required for plain http URLs"`
+}
+
+var (
+	// repairCfg defines the configuration options for the command.
+	repairCfg = repairCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *repairCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return errors.New("the path or URL of the dump must be " +
+			"specified")
+	}
+	if cfg.DbType != "ffldb" {
+		return errors.New("repairing is only supported by the ffldb " +
+			"database type")
+	}
+
+	// The database is repaired without opening it since opening a
+	// damaged database fails.
+	dbPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	log.Infof("Repairing block database '%s' from '%s'", dbPath, args[0])
+	startTime := time.Now()
+	src, err := ffldb.NewDumpSource(args[0], cmd.ManifestHash)
+	if err != nil {
+		return err
+	}
+	stats, err := ffldb.Repair(dbPath, activeNetParams.Net, src)
+	if err != nil {
+		return err
+	}
+	log.Infof("Verified %d block file chunks in %v", stats.CheckedChunks,
+		time.Since(startTime))
+	log.Infof("Restored %d damaged chunks and %d missing block files "+
+		"(%d bytes)", stats.DamagedChunks, stats.RestoredFiles,
+		stats.FetchedBytes)
+	log.Infof("Restored %d metadata ranges (%d entries)",
+		stats.DamagedRanges, stats.RestoredEntries)
+	if stats.RolledBack {
+		log.Warnf("The database was rolled back to the state of the " +
+			"dump")
+	}
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *repairCmd) Usage() string {
+	return "<dump-directory-or-url>"
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+)
+
+// restoreTrashCmd defines the configuration options for the restoretrash
+// command.
+type restoreTrashCmd struct {
+	List bool `short:"l" long:"list" description:"List the block files in the trash instead of restoring them"`
+}
+
+var (
+	// restoreTrashCfg defines the configuration options for the command.
+	restoreTrashCfg = restoreTrashCmd{
+		List: false,
+	}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *restoreTrashCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Parse the optional file numbers to restore.
+	fileNums := make([]uint32, 0, len(args))
+	for _, arg := range args {
+		fileNum, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid block file number %q", arg)
+		}
+		fileNums = append(fileNums, uint32(fileNum))
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if cmd.List {
+		trashed, err := ffldb.TrashedFiles(db)
+		if err != nil {
+			return err
+		}
+		if len(trashed) == 0 {
+			log.Info("The trash is empty")
+			return nil
+		}
+		for _, file := range trashed {
+			log.Infof("Block file %d: %d bytes, trashed %v", file.FileNum,
+				file.Size, file.TrashedAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	startTime := time.Now()
+	restored, err := ffldb.RestoreTrashedFiles(db, fileNums)
+	if err != nil {
+		return err
+	}
+	log.Infof("Restored %d blocks in %v", len(restored),
+		time.Since(startTime))
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *restoreTrashCmd) Usage() string {
+	return "[<block-file-number> ...]"
+}
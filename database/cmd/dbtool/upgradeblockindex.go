@@ -0,0 +1,43 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+)
+
+// upgradeBlockIndexCmd defines the configuration options for the
+// upgradeblockindex command.
+type upgradeBlockIndexCmd struct{}
+
+var (
+	// upgradeBlockIndexCfg defines the configuration options for the
+	// command.
+	upgradeBlockIndexCfg = upgradeBlockIndexCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *upgradeBlockIndexCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	startTime := time.Now()
+	if err := ffldb.UpgradeBlockIndex(db); err != nil {
+		return err
+	}
+	log.Infof("Finished in %v", time.Since(startTime))
+	return nil
+}
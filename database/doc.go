@@ -63,6 +63,17 @@ call Commit or Rollback when they are finished with it.  Leaving transactions
 open for long periods of time can have several adverse effects, so it is
 recommended that managed transactions are used instead.
 
+# Nested Transactions
+
+Only a single read-write transaction can be active at a time, so starting
+another transaction from the goroutine which started the active read-write
+transaction would either deadlock or not see its pending changes.  Such nested
+calls to Begin, View, and Update fail with ErrTxReentrant instead.  Functions
+which might be called as part of a larger logical operation should accept the
+active transaction, if any, and use the ViewNested and UpdateNested functions,
+which reuse it when provided and only start a new managed transaction when it
+is nil.
+
 # Buckets
 
 The Bucket interface provides the ability to manipulate key/value pairs and
@@ -62,6 +62,12 @@ const (
 	// the database was attempted against a read-only transaction.
 	ErrTxNotWritable
 
+	// ErrTxReentrant indicates an attempt was made to start a transaction
+	// on a goroutine that already has an active read-write transaction.
+	// Waiting for the active transaction to finish would deadlock, so the
+	// nested operation must reuse the active transaction instead.
+	ErrTxReentrant
+
 	// **************************************
 	// Errors related to metadata operations.
 	// **************************************
@@ -143,6 +149,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrCorruption:         "ErrCorruption",
 	ErrTxClosed:           "ErrTxClosed",
 	ErrTxNotWritable:      "ErrTxNotWritable",
+	ErrTxReentrant:        "ErrTxReentrant",
 	ErrBucketNotFound:     "ErrBucketNotFound",
 	ErrBucketExists:       "ErrBucketExists",
 	ErrBucketNameRequired: "ErrBucketNameRequired",
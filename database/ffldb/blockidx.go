@@ -0,0 +1,373 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the functions for reading and writing the block index
+// which maps block hashes to their location in the flat block files.
+
+package ffldb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// blockIdxVersionFullHash is the original block index format which is
+	// keyed by the full block hash and stores the serialized block
+	// location as the value.
+	blockIdxVersionFullHash = 1
+
+	// blockIdxVersionShortHash is the compressed block index format.  It
+	// is keyed by a short prefix of the block hash and stores a list of
+	// entries that each consist of a short check value taken from the hash
+	// followed by the serialized block location.  Multiple entries only
+	// exist when the hashes of several blocks share the same prefix.
+	//
+	// The serialized format of each entry is:
+	//
+	//  [0:16]  Hash check (bytes 8 through 24 of the block hash)
+	//  [16:28] Serialized block location
+	//
+	// Together with the 8 byte key, each block takes 36 bytes instead of
+	// the 44 bytes of the original format, which is about 18% smaller.
+	// Databases only use this format once they are upgraded with
+	// UpgradeBlockIndex.
+	blockIdxVersionShortHash = 2
+
+	// latestBlockIdxVersion is the block index version databases are
+	// converted to by UpgradeBlockIndex.
+	latestBlockIdxVersion = blockIdxVersionShortHash
+
+	// blockIdxKeyLen is the length of the block hash prefix used as the key
+	// in the compressed block index.
+	blockIdxKeyLen = 8
+
+	// blockIdxCheckLen is the length of the hash check stored with each
+	// entry in the compressed block index.  Together with the key, it
+	// identifies the block by 192 bits of its hash, which is enough to rule
+	// out collisions without reading the block headers to compare the full
+	// hashes.
+	blockIdxCheckLen = 16
+
+	// blockIdxEntryLen is the length of each entry in the compressed block
+	// index.
+	blockIdxEntryLen = blockIdxCheckLen + blockLocSize
+)
+
+var (
+	// ErrUnknownBlockIdxVersion is the underlying error of the
+	// database.ErrDriverSpecific error returned when opening a database
+	// whose block index uses a format that is newer than the ones known to
+	// this version of the driver.
+	ErrUnknownBlockIdxVersion = errors.New("unknown block index version")
+
+	// blockIdxVersionKeyName is the name of the key in the metadata bucket
+	// used to store the version of the block index format.  Databases
+	// created before the versioning was introduced do not have the key
+	// and use blockIdxVersionFullHash.
+	blockIdxVersionKeyName = []byte("ffldb-blockidxversion")
+)
+
+// serializeBlockIdxVersion returns the serialized block index version.
+func serializeBlockIdxVersion(version uint32) []byte {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], version)
+	return serialized[:]
+}
+
+// fetchBlockIdxVersion returns the version of the block index format used by
+// the database from the viewpoint of the passed transaction.
+func fetchBlockIdxVersion(tx *transaction) (uint32, error) {
+	serialized := tx.metaBucket.Get(blockIdxVersionKeyName)
+	if serialized == nil {
+		return blockIdxVersionFullHash, nil
+	}
+	if len(serialized) != 4 {
+		str := "block index version is corrupt"
+		return 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	version := byteOrder.Uint32(serialized)
+	switch version {
+	case blockIdxVersionFullHash, blockIdxVersionShortHash:
+	default:
+		str := fmt.Sprintf("block index version %d is not supported, "+
+			"the database was likely written by a newer version of "+
+			"the software", version)
+		return 0, makeDbErr(database.ErrDriverSpecific, str,
+			ErrUnknownBlockIdxVersion)
+	}
+	return version, nil
+}
+
+// blockIdxKey returns the key in the block index for the passed hash given the
+// block index version.
+func blockIdxKey(version uint32, hash *chainhash.Hash) []byte {
+	if version == blockIdxVersionShortHash {
+		return hash[:blockIdxKeyLen]
+	}
+	return hash[:]
+}
+
+// blockIdxCheck returns the hash check stored with the entries in the
+// compressed block index for the passed hash.
+func blockIdxCheck(hash *chainhash.Hash) []byte {
+	return hash[blockIdxKeyLen : blockIdxKeyLen+blockIdxCheckLen]
+}
+
+// findBlockIdxEntry returns the offset of the entry for the passed hash within
+// the serialized entries of the compressed block index or -1 when there is no
+// such entry.
+func findBlockIdxEntry(entries []byte, hash *chainhash.Hash) int {
+	check := blockIdxCheck(hash)
+	for offset := 0; offset+blockIdxEntryLen <= len(entries); offset += blockIdxEntryLen {
+		if bytes.Equal(entries[offset:offset+blockIdxCheckLen], check) {
+			return offset
+		}
+	}
+	return -1
+}
+
+// fetchBlockLoc returns the serialized block location stored in the block index
+// for the passed hash or nil if there is no entry.
+func (tx *transaction) fetchBlockLoc(hash *chainhash.Hash) []byte {
+	version := tx.blockIdxVersion
+	value := tx.blockIdxBucket.Get(blockIdxKey(version, hash))
+	if version != blockIdxVersionShortHash || value == nil {
+		return value
+	}
+
+	offset := findBlockIdxEntry(value, hash)
+	if offset == -1 {
+		return nil
+	}
+	return value[offset+blockIdxCheckLen : offset+blockIdxEntryLen]
+}
+
+// putBlockLoc stores the passed serialized block location for the passed hash
+// in the block index.  In the compressed format, entries for other blocks that
+// share the same hash prefix are preserved.
+func (tx *transaction) putBlockLoc(hash *chainhash.Hash, blockRow []byte) error {
+	version := tx.blockIdxVersion
+	key := blockIdxKey(version, hash)
+	if version != blockIdxVersionShortHash {
+		return tx.blockIdxBucket.Put(key, blockRow)
+	}
+
+	existing := tx.blockIdxBucket.Get(key)
+	entries := make([]byte, 0, len(existing)+blockIdxEntryLen)
+	entries = append(entries, existing...)
+	if offset := findBlockIdxEntry(entries, hash); offset != -1 {
+		copy(entries[offset+blockIdxCheckLen:], blockRow)
+	} else {
+		entries = append(entries, blockIdxCheck(hash)...)
+		entries = append(entries, blockRow...)
+	}
+	return tx.blockIdxBucket.Put(key, entries)
+}
+
+// blockHashAtLoc returns the hash of the block stored at the passed location by
+// reading its header from the flat block files.
+func (tx *transaction) blockHashAtLoc(loc blockLocation) (chainhash.Hash, error) {
+	header, err := tx.db.store.readBlockRegion(loc, 0, blockHdrSize)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return chainhash.DoubleHashH(header), nil
+}
+
+// addPrunedBlock records the passed block of a file pending deletion so it is
+// added to the index of the trash once the file is moved into it.  Nothing is
+// recorded when the trash is disabled.
+func (tx *transaction) addPrunedBlock(hash chainhash.Hash, loc blockLocation) {
+	if tx.db.store.trash.Retention == 0 {
+		return
+	}
+	if tx.prunedBlocks == nil {
+		tx.prunedBlocks = make(map[uint32][]trashedBlock)
+	}
+	tx.prunedBlocks[loc.blockFileNum] = append(
+		tx.prunedBlocks[loc.blockFileNum], trashedBlock{hash, loc})
+}
+
+// removeBlockLocs removes all of the entries from the block index for blocks
+// stored in the passed block files and returns their hashes.  The block files
+// must still exist since the compressed block index requires reading the block
+// headers in order to determine the hashes.
+func (tx *transaction) removeBlockLocs(fileNums map[uint32]struct{}) ([]chainhash.Hash, error) {
+	type update struct {
+		key     []byte
+		entries []byte
+	}
+	var updates []update
+	var hashes []chainhash.Hash
+	version := tx.blockIdxVersion
+	cursor := tx.blockIdxBucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		if version != blockIdxVersionShortHash {
+			loc := deserializeBlockLoc(cursor.Value())
+			if _, found := fileNums[loc.blockFileNum]; !found {
+				continue
+			}
+			hash := *(*chainhash.Hash)(cursor.Key())
+			hashes = append(hashes, hash)
+			tx.addPrunedBlock(hash, loc)
+			key := append([]byte(nil), cursor.Key()...)
+			updates = append(updates, update{key: key})
+			continue
+		}
+
+		value := cursor.Value()
+		var kept []byte
+		var changed bool
+		for offset := 0; offset+blockIdxEntryLen <= len(value); offset += blockIdxEntryLen {
+			entry := value[offset : offset+blockIdxEntryLen]
+			loc := deserializeBlockLoc(entry[blockIdxCheckLen:])
+			if _, found := fileNums[loc.blockFileNum]; !found {
+				kept = append(kept, entry...)
+				continue
+			}
+
+			hash, err := tx.blockHashAtLoc(loc)
+			if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, hash)
+			tx.addPrunedBlock(hash, loc)
+			changed = true
+		}
+		if changed {
+			key := append([]byte(nil), cursor.Key()...)
+			updates = append(updates, update{key: key, entries: kept})
+		}
+	}
+
+	// Apply the updates after iterating since modifying the bucket while
+	// the cursor is active is not safe.
+	for _, u := range updates {
+		var err error
+		if len(u.entries) == 0 {
+			err = tx.blockIdxBucket.Delete(u.key)
+		} else {
+			err = tx.blockIdxBucket.Put(u.key, u.entries)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, nil
+}
+
+// forEachBlockLoc invokes the passed function with the hash and location of
+// every block in the block index.
+func (tx *transaction) forEachBlockLoc(fn func(hash *chainhash.Hash, loc blockLocation) error) error {
+	version := tx.blockIdxVersion
+	return tx.blockIdxBucket.ForEach(func(k, v []byte) error {
+		if version != blockIdxVersionShortHash {
+			return fn((*chainhash.Hash)(k), deserializeBlockLoc(v))
+		}
+
+		for offset := 0; offset+blockIdxEntryLen <= len(v); offset += blockIdxEntryLen {
+			entry := v[offset : offset+blockIdxEntryLen]
+			loc := deserializeBlockLoc(entry[blockIdxCheckLen:])
+			hash, err := tx.blockHashAtLoc(loc)
+			if err != nil {
+				return err
+			}
+			if err := fn(&hash, loc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BlockHashes returns the hashes of all blocks stored in the passed database,
+// which must have been opened with this driver, in the order of the block
+// index.
+func BlockHashes(idb database.DB) ([]chainhash.Hash, error) {
+	if _, err := toFFLDB(idb); err != nil {
+		return nil, err
+	}
+
+	var hashes []chainhash.Hash
+	err := idb.View(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		return tx.forEachBlockLoc(func(hash *chainhash.Hash, _ blockLocation) error {
+			hashes = append(hashes, *hash)
+			return nil
+		})
+	})
+	return hashes, err
+}
+
+// UpgradeBlockIndex converts the block index of the passed database, which must
+// have been opened with this driver, to the latest format.  Databases keep using
+// the original format until they are upgraded, and older versions of the driver
+// are unable to open them afterwards.  Nothing is done when the block index
+// already uses the latest format.  The upgrade is performed in a single transaction, so
+// it is safe to interrupt.
+func UpgradeBlockIndex(idb database.DB) error {
+	if _, err := toFFLDB(idb); err != nil {
+		return err
+	}
+
+	// Each transaction loads the block index version from its snapshot,
+	// so only this transaction uses the new format until it is committed,
+	// and nothing changes when the commit fails.
+	return idb.Update(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		if tx.blockIdxVersion == latestBlockIdxVersion {
+			return nil
+		}
+
+		log.Infof("Upgrading the block index to version %d",
+			latestBlockIdxVersion)
+
+		// Gather all of the existing entries since the bucket can't be
+		// modified while iterating it.
+		type entry struct {
+			hash chainhash.Hash
+			row  []byte
+		}
+		var entries []entry
+		err := tx.blockIdxBucket.ForEach(func(k, v []byte) error {
+			var e entry
+			copy(e.hash[:], k)
+			e.row = append([]byte(nil), v...)
+			entries = append(entries, e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Remove the old entries and add them back in the new format.
+		for i := range entries {
+			if err := tx.blockIdxBucket.Delete(entries[i].hash[:]); err != nil {
+				return err
+			}
+		}
+		tx.blockIdxVersion = latestBlockIdxVersion
+		for i := range entries {
+			err := tx.putBlockLoc(&entries[i].hash, entries[i].row)
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.metaBucket.Put(blockIdxVersionKeyName,
+			serializeBlockIdxVersion(latestBlockIdxVersion))
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Upgraded %d block index entries", len(entries))
+		return nil
+	})
+}
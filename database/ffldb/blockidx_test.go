@@ -0,0 +1,333 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+// blockIdxVersion returns the block index version of the passed database as
+// seen by a new transaction.
+func blockIdxVersion(t *testing.T, idb database.DB) uint32 {
+	t.Helper()
+
+	var version uint32
+	err := idb.View(func(dbTx database.Tx) error {
+		version = dbTx.(*transaction).blockIdxVersion
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	return version
+}
+
+// TestBlockIdxCollisions ensures blocks whose hashes share the prefix used as
+// the key of the compressed block index are stored and looked up independently
+// as long as their hash checks differ.
+func TestBlockIdxCollisions(t *testing.T) {
+	t.Parallel()
+
+	idb, err := database.Create(dbType, t.TempDir(), blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer idb.Close()
+
+	if err := UpgradeBlockIndex(idb); err != nil {
+		t.Fatalf("UpgradeBlockIndex: unexpected error: %v", err)
+	}
+	if got := blockIdxVersion(t, idb); got != latestBlockIdxVersion {
+		t.Fatalf("upgraded database uses block index version %d, "+
+			"want %d", got, latestBlockIdxVersion)
+	}
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	blocks = blocks[:2]
+
+	// Create hashes that share the key with the blocks and differ in the
+	// first and the last byte of the hash check respectively.
+	prefixHash := *blocks[0].Hash()
+	prefixHash[blockIdxKeyLen] ^= 0xff
+	checkHash := *blocks[1].Hash()
+	checkHash[blockIdxKeyLen+blockIdxCheckLen-1] ^= 0xff
+
+	locs := make([]blockLocation, len(blocks))
+	err = idb.Update(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	err = idb.Update(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		for i, block := range blocks {
+			row, err := tx.fetchBlockRow(block.Hash())
+			if err != nil {
+				return err
+			}
+			locs[i] = deserializeBlockLoc(row)
+		}
+
+		// Point the colliding hashes at the other block so the hash
+		// checks are what tell the entries apart.
+		err := tx.putBlockLoc(&prefixHash, serializeBlockLoc(locs[1]))
+		if err != nil {
+			return err
+		}
+		return tx.putBlockLoc(&checkHash, serializeBlockLoc(locs[0]))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	err = idb.View(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		for i, block := range blocks {
+			key := block.Hash()[:blockIdxKeyLen]
+			value := tx.blockIdxBucket.Get(key)
+			if len(value) != 2*blockIdxEntryLen {
+				t.Errorf("got %d bytes for the key of block #%d, "+
+					"want %d", len(value), i, 2*blockIdxEntryLen)
+			}
+
+			if !tx.hasBlock(block.Hash()) {
+				t.Errorf("hasBlock #%d: block not found", i)
+			}
+			row, err := tx.fetchBlockRow(block.Hash())
+			if err != nil {
+				t.Errorf("fetchBlockRow #%d: unexpected error: %v",
+					i, err)
+				continue
+			}
+			if got := deserializeBlockLoc(row); got != locs[i] {
+				t.Errorf("fetchBlockRow #%d: got %+v, want %+v",
+					i, got, locs[i])
+			}
+		}
+
+		// The colliding hashes resolve to their own entries.
+		collisions := []struct {
+			hash chainhash.Hash
+			loc  blockLocation
+		}{
+			{prefixHash, locs[1]},
+			{checkHash, locs[0]},
+		}
+		for _, c := range collisions {
+			if !tx.hasBlock(&c.hash) {
+				t.Errorf("hasBlock: colliding hash %v not found",
+					c.hash)
+			}
+			row, err := tx.fetchBlockRow(&c.hash)
+			if err != nil {
+				return err
+			}
+			if got := deserializeBlockLoc(row); got != c.loc {
+				t.Errorf("fetchBlockRow %v: got %+v, want %+v",
+					c.hash, got, c.loc)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestUnknownBlockIdxVersion ensures opening a database with a block index
+// format newer than the known ones fails with a distinct error.
+func TestUnknownBlockIdxVersion(t *testing.T) {
+	t.Parallel()
+
+	dbPath := t.TempDir()
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	err = idb.Update(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		return tx.metaBucket.Put(blockIdxVersionKeyName,
+			serializeBlockIdxVersion(latestBlockIdxVersion+1))
+	})
+	idb.Close()
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err == nil {
+		idb.Close()
+		t.Fatal("Open: opened database with unknown block index version")
+	}
+	dbErr, ok := err.(database.Error)
+	if !ok || dbErr.ErrorCode != database.ErrDriverSpecific ||
+		dbErr.Err != ErrUnknownBlockIdxVersion {
+
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+}
+
+// TestUpgradeBlockIndex ensures a block index that uses full hashes as keys is
+// converted to the compressed format without losing any blocks.
+func TestUpgradeBlockIndex(t *testing.T) {
+	t.Parallel()
+
+	dbPath := t.TempDir()
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer idb.Close()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+
+	// New databases use the original format until they are upgraded.
+	if got := blockIdxVersion(t, idb); got != blockIdxVersionFullHash {
+		t.Fatalf("new database uses block index version %d, want %d",
+			got, blockIdxVersionFullHash)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	if err := UpgradeBlockIndex(idb); err != nil {
+		t.Fatalf("UpgradeBlockIndex: unexpected error: %v", err)
+	}
+	idb.Close()
+
+	// Reopen the database to ensure the version is persisted.
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	defer idb.Close()
+	if got := blockIdxVersion(t, idb); got != blockIdxVersionShortHash {
+		t.Fatalf("upgraded database uses block index version %d, "+
+			"want %d", got, blockIdxVersionShortHash)
+	}
+
+	err = idb.View(func(tx database.Tx) error {
+		for i, block := range blocks {
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				t.Errorf("FetchBlock #%d: unexpected error: %v",
+					i, err)
+				continue
+			}
+			wantBytes, err := block.Bytes()
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotBytes, wantBytes) {
+				t.Errorf("FetchBlock #%d: mismatched bytes", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+
+	hashes, err := BlockHashes(idb)
+	if err != nil {
+		t.Fatalf("BlockHashes: unexpected error: %v", err)
+	}
+	if len(hashes) != len(blocks) {
+		t.Fatalf("BlockHashes: got %d hashes, want %d", len(hashes),
+			len(blocks))
+	}
+}
+
+// TestUpgradeBlockIndexSnapshot ensures transactions that were started before
+// the block index is upgraded keep reading it in the original format while
+// the ones started afterwards use the new format.
+func TestUpgradeBlockIndexSnapshot(t *testing.T) {
+	t.Parallel()
+
+	idb, err := database.Create(dbType, t.TempDir(), blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer idb.Close()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	oldTx, err := idb.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin: unexpected error: %v", err)
+	}
+	defer oldTx.Rollback()
+
+	if err := UpgradeBlockIndex(idb); err != nil {
+		t.Fatalf("UpgradeBlockIndex: unexpected error: %v", err)
+	}
+	if got := blockIdxVersion(t, idb); got != blockIdxVersionShortHash {
+		t.Fatalf("upgraded database uses block index version %d, "+
+			"want %d", got, blockIdxVersionShortHash)
+	}
+
+	newTx, err := idb.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin: unexpected error: %v", err)
+	}
+	defer newTx.Rollback()
+
+	for _, tx := range []database.Tx{oldTx, newTx} {
+		version := tx.(*transaction).blockIdxVersion
+		for i, block := range blocks {
+			exists, err := tx.HasBlock(block.Hash())
+			if err != nil {
+				t.Fatalf("HasBlock #%d (version %d): unexpected "+
+					"error: %v", i, version, err)
+			}
+			if !exists {
+				t.Fatalf("HasBlock #%d (version %d): block not "+
+					"found", i, version)
+			}
+		}
+	}
+}
@@ -175,6 +175,26 @@ type blockStore struct {
 	// new blocks are written to.
 	writeCursor *writeCursor
 
+	// trash houses the configuration for the trash that pruned block files
+	// are moved into.  It is only accessed by write transactions.
+	trash TrashConfig
+
+	// trashIdxMtx protects trashIdx, which maps the hashes of the blocks
+	// in the trashed block files to their locations so they can be
+	// fetched without scanning the trash.  It is loaded from the index
+	// files of the trash on first use.
+	trashIdxMtx sync.Mutex
+	trashIdx    map[chainhash.Hash]blockLocation
+
+	// throttle houses the state used to apply back-pressure to writes of
+	// the block files.  It is only accessed by write transactions.
+	throttle writeThrottle
+
+	// shared houses the optional read-only shared block store that blocks
+	// at locations flagged with sharedFileFlag are read from.  It is nil
+	// when no shared block store is configured.
+	shared *sharedStore
+
 	// These functions are set to openFile, openWriteFile, and deleteFile by
 	// default, but are exposed here to allow the whitebox tests to replace
 	// them when working with mock files.
@@ -386,6 +406,7 @@ func (s *blockStore) writeData(data []byte, fieldName string) error {
 	wc := s.writeCursor
 	n, err := wc.curFile.file.WriteAt(data, int64(wc.curOffset))
 	wc.curOffset += uint32(n)
+	blockFileWriteBytes.Add(uint64(n))
 	if err != nil {
 		str := fmt.Sprintf("failed to write %s to file %d at "+
 			"offset %d: %v", fieldName, wc.curFileNum,
@@ -435,6 +456,16 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		wc.Lock()
 		wc.curFile.Lock()
 		if wc.curFile.file != nil {
+			// Sync the file before closing it when periodic syncs
+			// are enabled so its dirty pages are written back at
+			// the configured cadence as well.
+			if s.throttle.cfg.SyncInterval != 0 {
+				if err := wc.curFile.file.Sync(); err != nil {
+					log.Warnf("Unable to sync block file %d: %v",
+						wc.curFileNum, err)
+				}
+				s.throttle.unsyncedBytes = 0
+			}
 			_ = wc.curFile.file.Close()
 			wc.curFile.file = nil
 		}
@@ -496,6 +527,7 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		fileOffset:   origOffset,
 		blockLen:     fullLen,
 	}
+	blockFileWrites.Inc()
 	return loc, nil
 }
 
@@ -513,6 +545,15 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 //
 // Format: <network><block length><serialized block><checksum>
 func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
+	// Blocks referenced from the shared block store are read from there.
+	if loc.blockFileNum&sharedFileFlag != 0 {
+		store, sharedLoc, err := s.sharedLoc(loc)
+		if err != nil {
+			return nil, err
+		}
+		return store.readBlock(hash, sharedLoc)
+	}
+
 	// Get the referenced block file handle opening the file as needed.  The
 	// function also handles closing files as needed to avoid going over the
 	// max allowed open files.
@@ -524,6 +565,8 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 	serializedData := make([]byte, loc.blockLen)
 	n, err := blockFile.file.ReadAt(serializedData, int64(loc.fileOffset))
 	blockFile.RUnlock()
+	blockFileReadBytes.Add(uint64(n))
+	blockFileReads.Inc()
 	if err != nil {
 		str := fmt.Sprintf("failed to read block %s from file %d, "+
 			"offset %d: %v", hash, loc.blockFileNum, loc.fileOffset,
@@ -569,6 +612,15 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 //
 // Returns ErrDriverSpecific if the data fails to read for any reason.
 func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+	// Blocks referenced from the shared block store are read from there.
+	if loc.blockFileNum&sharedFileFlag != 0 {
+		store, sharedLoc, err := s.sharedLoc(loc)
+		if err != nil {
+			return nil, err
+		}
+		return store.readBlockRegion(sharedLoc, offset, numBytes)
+	}
+
 	// Get the referenced block file handle opening the file as needed.  The
 	// function also handles closing files as needed to avoid going over the
 	// max allowed open files.
@@ -582,8 +634,10 @@ func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32)
 	// for block length.  Thus, add 8 bytes to adjust.
 	readOffset := loc.fileOffset + 8 + offset
 	serializedData := make([]byte, numBytes)
-	_, err = blockFile.file.ReadAt(serializedData, int64(readOffset))
+	n, err := blockFile.file.ReadAt(serializedData, int64(readOffset))
 	blockFile.RUnlock()
+	blockFileReadBytes.Add(uint64(n))
+	blockFileReads.Inc()
 	if err != nil {
 		str := fmt.Sprintf("failed to read region from block file %d, "+
 			"offset %d, len %d: %v", loc.blockFileNum, readOffset,
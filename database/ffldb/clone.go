@@ -0,0 +1,237 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of cloning a database into a new
+// directory while sharing the immutable flat block files when possible.
+
+package ffldb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/database"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+const (
+	// cloneBatchSize is the maximum number of metadata entries that are
+	// written to the cloned metadata database in a single batch.
+	cloneBatchSize = 10000
+)
+
+// CloneStats describes the work done by Clone.
+type CloneStats struct {
+	// ReflinkedFiles is the number of block files that were cloned with a
+	// copy-on-write reflink and therefore share their extents with the
+	// source database.
+	ReflinkedFiles int
+
+	// LinkedFiles is the number of block files that were hard linked into
+	// the cloned database because the file system does not support
+	// reflinks.
+	LinkedFiles int
+
+	// CopiedFiles is the number of block files that were copied in full.
+	CopiedFiles int
+
+	// CopiedBytes is the number of bytes of block data that were copied.
+	CopiedBytes int64
+
+	// MetadataEntries is the number of metadata entries that were copied.
+	MetadataEntries int
+}
+
+// Clone creates a consistent copy of the passed database, which must have been
+// opened with this driver, at destPath.  The destination must not already
+// exist.  The database remains usable while it is cloned, however writes are
+// blocked until the clone is complete.
+//
+// The metadata is always copied from a snapshot of the underlying leveldb
+// database.  When cow is true, the block files that are no longer being
+// written to are cloned with a copy-on-write reflink so they initially share
+// their extents with the source database.  File systems that do not support
+// reflinks fall back to hard links, which is safe since completed block files
+// are never modified in place, only removed when pruned.  The block file that
+// is currently being written to is always copied since it is still appended
+// to by the source database.
+func Clone(idb database.DB, destPath string, cow bool) (*CloneStats, error) {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prevent the database from being closed and block all writes while
+	// the clone is created so the block files and metadata are consistent.
+	pdb.closeLock.RLock()
+	defer pdb.closeLock.RUnlock()
+	if pdb.closed {
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	pdb.writeLock.Lock()
+	defer pdb.writeLock.Unlock()
+
+	// Flush the cache so all committed metadata is in the underlying leveldb
+	// database and all block data is synced to the flat files.
+	if err := pdb.cache.flush(); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		return nil, fmt.Errorf("clone destination %s already exists",
+			destPath)
+	}
+	if err := os.MkdirAll(destPath, 0700); err != nil {
+		return nil, err
+	}
+
+	stats := &CloneStats{}
+	if err := cloneMetadata(pdb.cache.ldb, destPath, stats); err != nil {
+		return nil, err
+	}
+
+	if _, err := cloneBlockFiles(pdb.store, destPath, cow, stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// cloneBlockFiles clones all of the block files of the passed store up to and
+// including the current write file into destPath and updates the passed stats
+// accordingly.  The numbers of the cloned files are returned.  Files that are
+// missing have been pruned and are skipped.  See Clone for details on how the
+// files are cloned.
+//
+// This function MUST be called with the database write lock held and the
+// cache flushed.
+func cloneBlockFiles(store *blockStore, destPath string, cow bool,
+	stats *CloneStats) ([]uint32, error) {
+
+	wc := store.writeCursor
+	wc.RLock()
+	curFileNum, curOffset := wc.curFileNum, wc.curOffset
+	wc.RUnlock()
+
+	var fileNums []uint32
+	for fileNum := uint32(0); fileNum <= curFileNum; fileNum++ {
+		srcPath := blockFilePath(store.basePath, fileNum)
+		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+			continue
+		}
+		destFile := blockFilePath(destPath, fileNum)
+		fileNums = append(fileNums, fileNum)
+
+		if fileNum == curFileNum {
+			n, err := copyFile(srcPath, destFile, int64(curOffset))
+			if err != nil {
+				return nil, err
+			}
+			stats.CopiedFiles++
+			stats.CopiedBytes += n
+			continue
+		}
+
+		if cow {
+			if err := reflinkFile(srcPath, destFile); err == nil {
+				stats.ReflinkedFiles++
+				continue
+			}
+			log.Debugf("Unable to reflink block file %d, falling "+
+				"back to a hard link", fileNum)
+			if err := os.Link(srcPath, destFile); err == nil {
+				stats.LinkedFiles++
+				continue
+			}
+			log.Debugf("Unable to hard link block file %d, falling "+
+				"back to a copy", fileNum)
+		}
+
+		n, err := copyFile(srcPath, destFile, -1)
+		if err != nil {
+			return nil, err
+		}
+		stats.CopiedFiles++
+		stats.CopiedBytes += n
+	}
+
+	return fileNums, nil
+}
+
+// cloneMetadata copies all entries in a snapshot of the passed leveldb
+// database into a newly created metadata database under destPath.
+func cloneMetadata(ldb *leveldb.DB, destPath string, stats *CloneStats) error {
+	snap, err := ldb.GetSnapshot()
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+	defer snap.Release()
+
+	opts := opt.Options{
+		ErrorIfExist: true,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+		Filter:       filter.NewBloomFilter(10),
+	}
+	metadataDbPath := filepath.Join(destPath, metadataDbName)
+	destLdb, err := leveldb.OpenFile(metadataDbPath, &opts)
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+	defer destLdb.Close()
+
+	iter := snap.NewIterator(nil, nil)
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Put(iter.Key(), iter.Value())
+		stats.MetadataEntries++
+		if batch.Len() < cloneBatchSize {
+			continue
+		}
+		if err := destLdb.Write(batch, nil); err != nil {
+			return convertErr(err.Error(), err)
+		}
+		batch.Reset()
+	}
+	if err := iter.Error(); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	if err := destLdb.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	return nil
+}
+
+// copyFile copies the first size bytes of the file at srcPath to a newly
+// created file at destPath, or the entire file when size is negative.  The
+// destination file is synced before returning.
+func copyFile(srcPath, destPath string, size int64) (int64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0600)
+	if err != nil {
+		return 0, err
+	}
+	defer dest.Close()
+
+	var r io.Reader = src
+	if size >= 0 {
+		r = io.LimitReader(src, size)
+	}
+	n, err := io.Copy(dest, r)
+	if err != nil {
+		return n, err
+	}
+	return n, dest.Sync()
+}
@@ -12,7 +12,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -85,6 +88,11 @@ const (
 	// errTxClosedStr is the text to use for the database.ErrTxClosed error
 	// code.
 	errTxClosedStr = "database tx is closed"
+
+	// errTxReentrantStr is the text to use for the database.ErrTxReentrant
+	// error code.
+	errTxReentrantStr = "database tx started on a goroutine with an " +
+		"active read-write tx"
 )
 
 // bulkFetchData is allows a block location to be specified along with the
@@ -961,6 +969,10 @@ type transaction struct {
 	metaBucket     *bucket          // The root metadata bucket.
 	blockIdxBucket *bucket          // The block index bucket.
 
+	// blockIdxVersion is the version of the block index format as of the
+	// snapshot of the transaction.
+	blockIdxVersion uint32
+
 	// Blocks that need to be stored on commit.  The pendingBlocks map is
 	// kept to allow quick lookups of pending data by block hash.
 	pendingBlocks    map[chainhash.Hash]int
@@ -970,6 +982,11 @@ type transaction struct {
 	// are marked as files to be deleted during pruning.
 	pendingDelFileNums []uint32
 
+	// The blocks contained in the files pending deletion keyed by file
+	// number.  They are recorded in the index of the trash when the files
+	// are moved into it.
+	prunedBlocks map[uint32][]trashedBlock
+
 	// Keys that need to be stored or deleted on commit.
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
@@ -1128,6 +1145,13 @@ func (tx *transaction) Metadata() database.Bucket {
 	return tx.metaBucket
 }
 
+// DB returns the database the transaction was started on.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) DB() database.DB {
+	return tx.db
+}
+
 // hasBlock returns whether or not a block with the given hash exists.
 func (tx *transaction) hasBlock(hash *chainhash.Hash) bool {
 	// Return true if the block is pending to be written on commit since
@@ -1136,6 +1160,11 @@ func (tx *transaction) hasBlock(hash *chainhash.Hash) bool {
 		return true
 	}
 
+	// The compressed block index is keyed by a hash prefix, so the entry
+	// itself must be checked for a match.
+	if tx.blockIdxVersion == blockIdxVersionShortHash {
+		return tx.fetchBlockLoc(hash) != nil
+	}
 	return tx.hasKey(bucketizedKey(blockIdxBucketID, hash[:]))
 }
 
@@ -1176,6 +1205,18 @@ func (tx *transaction) StoreBlock(block *btcutil.Block) error {
 		return makeDbErr(database.ErrDriverSpecific, str, err)
 	}
 
+	// Reference an identical copy of the block in the shared block store
+	// instead of writing the block to the flat files again.
+	sharedLoc, err := tx.sharedBlockLoc(blockHash, blockBytes)
+	if err != nil {
+		return err
+	}
+	if sharedLoc != nil {
+		log.Tracef("Referencing block %s from the shared block store",
+			blockHash)
+		return tx.putBlockLoc(blockHash, serializeBlockLoc(*sharedLoc))
+	}
+
 	// Add the block to be stored to the list of pending blocks to store
 	// when the transaction is committed.  Also, add it to pending blocks
 	// map so it is easy to determine the block is pending based on the
@@ -1233,7 +1274,7 @@ func (tx *transaction) HasBlocks(hashes []chainhash.Hash) ([]bool, error) {
 // fetchBlockRow fetches the metadata stored in the block index for the provided
 // hash.  It will return ErrBlockNotFound if there is no entry.
 func (tx *transaction) fetchBlockRow(hash *chainhash.Hash) ([]byte, error) {
-	blockRow := tx.blockIdxBucket.Get(hash[:])
+	blockRow := tx.fetchBlockLoc(hash)
 	if blockRow == nil {
 		str := fmt.Sprintf("block %s does not exist", hash)
 		return nil, makeDbErr(database.ErrBlockNotFound, str, nil)
@@ -1599,6 +1640,7 @@ func (tx *transaction) close() {
 
 	// Clear pending file deletions.
 	tx.pendingDelFileNums = nil
+	tx.prunedBlocks = nil
 
 	// Clear pending keys that would have been written or deleted on commit.
 	tx.pendingKeys = nil
@@ -1615,6 +1657,7 @@ func (tx *transaction) close() {
 	// Release the writer lock for writable transactions to unblock any
 	// other write transaction which are possibly waiting.
 	if tx.writable {
+		atomic.StoreUint64(&tx.db.writerID, 0)
 		tx.db.writeLock.Unlock()
 	}
 }
@@ -1630,13 +1673,18 @@ func (tx *transaction) writePendingAndCommit() error {
 	// We do this first before doing any of the writes as we can't undo
 	// deletions of files.
 	for _, fileNum := range tx.pendingDelFileNums {
-		err := tx.db.store.deleteFileFunc(fileNum)
+		err := tx.db.store.pruneFile(fileNum, tx.prunedBlocks[fileNum])
 		if err != nil {
 			// Nothing we can do if we fail to delete blocks besides
 			// return an error.
 			return err
 		}
 	}
+	if len(tx.pendingDelFileNums) > 0 {
+		if err := tx.db.store.purgeTrash(time.Now()); err != nil {
+			return err
+		}
+	}
 
 	// Save the current block store write position for potential rollback.
 	// These variables are only updated here in this function and there can
@@ -1656,6 +1704,7 @@ func (tx *transaction) writePendingAndCommit() error {
 	}
 
 	// Loop through all of the pending blocks to store and write them.
+	var bytesWritten uint64
 	for _, blockData := range tx.pendingBlockData {
 		log.Tracef("Storing block %s", blockData.hash)
 		location, err := tx.db.store.writeBlock(blockData.bytes)
@@ -1663,13 +1712,14 @@ func (tx *transaction) writePendingAndCommit() error {
 			rollback()
 			return err
 		}
+		bytesWritten += uint64(location.blockLen)
 
 		// Add a record in the block index for the block.  The record
 		// includes the location information needed to locate the block
 		// on the filesystem as well as the block header since they are
 		// so commonly needed.
 		blockRow := serializeBlockLoc(location)
-		err = tx.blockIdxBucket.Put(blockData.hash[:], blockRow)
+		err = tx.putBlockLoc(blockData.hash, blockRow)
 		if err != nil {
 			rollback()
 			return err
@@ -1685,7 +1735,13 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+
+	// Apply any configured back-pressure now that the blocks are written.
+	tx.db.store.throttleWrites(bytesWritten)
+	return nil
 }
 
 // PruneBlocks deletes the block files until it reaches the target size
@@ -1764,19 +1820,9 @@ func (tx *transaction) PruneBlocks(targetSize uint64) ([]chainhash.Hash, error)
 	}
 
 	// Delete the indexed block locations for the files that we've just deleted.
-	var deletedBlockHashes []chainhash.Hash
-	cursor := tx.blockIdxBucket.Cursor()
-	for ok := cursor.First(); ok; ok = cursor.Next() {
-		loc := deserializeBlockLoc(cursor.Value())
-
-		_, found := deletedFiles[loc.blockFileNum]
-		if found {
-			deletedBlockHashes = append(deletedBlockHashes, *(*chainhash.Hash)(cursor.Key()))
-			err := cursor.Delete()
-			if err != nil {
-				return nil, err
-			}
-		}
+	deletedBlockHashes, err := tx.removeBlockLocs(deletedFiles)
+	if err != nil {
+		return nil, err
 	}
 
 	log.Tracef("Finished pruning. Database now at %d bytes", totalSize)
@@ -1784,6 +1830,59 @@ func (tx *transaction) PruneBlocks(targetSize uint64) ([]chainhash.Hash, error)
 	return deletedBlockHashes, nil
 }
 
+// PruneBlocksBefore deletes the block files which precede the block file the
+// block with the given hash is stored in and returns the hashes of the blocks
+// they contained.  The file currently being written to is never deleted.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocksBefore(hash *chainhash.Hash) ([]chainhash.Hash, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	blockRow, err := tx.fetchBlockRow(hash)
+	if err != nil {
+		return nil, err
+	}
+	keepFileNum := deserializeBlockLoc(blockRow).blockFileNum
+
+	// The flat files don't hold the blocks before a block that is
+	// referenced from the shared block store, so there is nothing to prune.
+	if keepFileNum&sharedFileFlag != 0 {
+		return nil, nil
+	}
+
+	first, last, _, err := scanBlockFiles(tx.db.store.basePath)
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 || uint32(first) >= keepFileNum {
+		return nil, nil
+	}
+	if keepFileNum > uint32(last) {
+		keepFileNum = uint32(last)
+	}
+
+	deletedFiles := make(map[uint32]struct{})
+	for i := uint32(first); i < keepFileNum; i++ {
+		tx.pendingDelFileNums = append(tx.pendingDelFileNums, i)
+		deletedFiles[i] = struct{}{}
+	}
+
+	log.Tracef("Pruning block files %d to %d stored before block %v",
+		first, keepFileNum-1, hash)
+
+	// Delete the indexed block locations for the files that are deleted.
+	return tx.removeBlockLocs(deletedFiles)
+}
+
 // BeenPruned returns if the block storage has ever been pruned.
 //
 // This function is part of the database.Tx interface implementation.
@@ -1830,7 +1929,12 @@ func (tx *transaction) Commit() error {
 	}
 
 	// Write pending data.  The function will rollback if any errors occur.
-	return tx.writePendingAndCommit()
+	start := time.Now()
+	if err := tx.writePendingAndCommit(); err != nil {
+		return err
+	}
+	commitDuration.ObserveSince(start)
+	return nil
 }
 
 // Rollback undoes all changes that have been made to the root bucket and all of
@@ -1857,6 +1961,16 @@ func (tx *transaction) Rollback() error {
 // the database.DB interface.  All database access is performed through
 // transactions which are obtained through the specific Namespace.
 type db struct {
+	// writerID is the id of the goroutine which started the active write
+	// transaction or zero when there is none.  It must be accessed
+	// atomically and is kept first for 64-bit alignment.
+	writerID uint64
+
+	// writeLocked is the time the write lock was acquired by the active
+	// write transaction in nanoseconds since the epoch.  It must be
+	// accessed atomically and is only meaningful while writerID is set.
+	writeLocked int64
+
 	writeLock sync.Mutex   // Limit to one write transaction at a time.
 	closeLock sync.RWMutex // Make database close block while txns active.
 	closed    bool         // Is the database closed?
@@ -1882,12 +1996,27 @@ func (db *db) Type() string {
 // which is used by the managed transaction code while the database method
 // returns the interface.
 func (db *db) begin(writable bool) (*transaction, error) {
+	// Refuse to start a transaction on the goroutine which started the
+	// active write transaction.  A nested write transaction would wait
+	// forever for the write lock held by its own goroutine, and a nested
+	// read-only transaction would not see the pending changes and could
+	// deadlock against a concurrent Close.  The goroutine id is only
+	// looked up while a write transaction is active since it is not free.
+	if writerID := atomic.LoadUint64(&db.writerID); writerID != 0 &&
+		writerID == goroutineID() {
+
+		return nil, makeDbErr(database.ErrTxReentrant,
+			errTxReentrantStr, nil)
+	}
+
 	// Whenever a new writable transaction is started, grab the write lock
 	// to ensure only a single write transaction can be active at the same
 	// time.  This lock will not be released until the transaction is
 	// closed (via Rollback or Commit).
 	if writable {
 		db.writeLock.Lock()
+		atomic.StoreInt64(&db.writeLocked, time.Now().UnixNano())
+		atomic.StoreUint64(&db.writerID, goroutineID())
 	}
 
 	// Whenever a new transaction is started, grab a read lock against the
@@ -1898,6 +2027,7 @@ func (db *db) begin(writable bool) (*transaction, error) {
 	if db.closed {
 		db.closeLock.RUnlock()
 		if writable {
+			atomic.StoreUint64(&db.writerID, 0)
 			db.writeLock.Unlock()
 		}
 		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr,
@@ -1910,6 +2040,7 @@ func (db *db) begin(writable bool) (*transaction, error) {
 	if err != nil {
 		db.closeLock.RUnlock()
 		if writable {
+			atomic.StoreUint64(&db.writerID, 0)
 			db.writeLock.Unlock()
 		}
 
@@ -1927,6 +2058,14 @@ func (db *db) begin(writable bool) (*transaction, error) {
 	}
 	tx.metaBucket = &bucket{tx: tx, id: metadataBucketID}
 	tx.blockIdxBucket = &bucket{tx: tx, id: blockIdxBucketID}
+
+	// Load the block index format from the snapshot so it always matches
+	// the block index the transaction sees.
+	tx.blockIdxVersion, err = fetchBlockIdxVersion(tx)
+	if err != nil {
+		tx.close()
+		return nil, err
+	}
 	return tx, nil
 }
 
@@ -1944,6 +2083,23 @@ func (db *db) Begin(writable bool) (database.Tx, error) {
 	return db.begin(writable)
 }
 
+// goroutineID returns the id of the calling goroutine as found in the header
+// of its stack trace, which has the form "goroutine 123 [running]:".  Zero is
+// returned if the id can't be determined.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	stack := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if end := bytes.IndexByte(stack, ' '); end != -1 {
+		stack = stack[:end]
+	}
+	id, err := strconv.ParseUint(string(stack), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
 // rollbackOnPanic rolls the passed transaction back if the code in the calling
 // function panics.  This is needed since the mutex on a transaction must be
 // released and a panic in called code would prevent that from happening.
@@ -2067,6 +2223,7 @@ func (db *db) Close() error {
 	db.store.openBlockFiles = nil
 	db.store.openBlocksLRU.Init()
 	db.store.fileNumToLRUElem = nil
+	db.store.shared.close()
 
 	return closeErr
 }
@@ -2148,12 +2305,20 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 	// write caching.
 	store, err := newBlockStore(dbPath, network)
 	if err != nil {
+		ldb.Close()
 		return nil, convertErr(err.Error(), err)
 	}
 	cache := newDbCache(ldb, store, defaultCacheSize, defaultFlushSecs)
 	pdb := &db{store: store, cache: cache}
 
 	// Perform any reconciliation needed between the block and metadata as
-	// well as database initialization, if needed.
-	return reconcileDB(pdb, create)
+	// well as database initialization, if needed.  The metadata database
+	// is closed on failure so the files are not left locked, for example
+	// so the database can be repaired.
+	idb, err := reconcileDB(pdb, create)
+	if err != nil {
+		ldb.Close()
+		return nil, err
+	}
+	return idb, nil
 }
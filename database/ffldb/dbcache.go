@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/database/internal/treap"
@@ -354,6 +355,12 @@ func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
 // can commit transactions at will without incurring large performance hits due
 // to frequent disk syncs.
 type dbCache struct {
+	// flushed is the time the cache was last flushed in nanoseconds since
+	// the epoch.  It mirrors lastFlush for readers which don't hold the
+	// database write lock, must be accessed atomically and is kept first
+	// for 64-bit alignment.
+	flushed int64
+
 	// ldb is the underlying leveldb DB for metadata.
 	ldb *leveldb.DB
 
@@ -487,6 +494,7 @@ func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error
 // This function MUST be called with the database write lock held.
 func (c *dbCache) flush() error {
 	c.lastFlush = time.Now()
+	atomic.StoreInt64(&c.flushed, c.lastFlush.UnixNano())
 
 	// Sync the current write file associated with the block store.  This is
 	// necessary before writing the metadata to prevent the case where the
@@ -510,9 +518,11 @@ func (c *dbCache) flush() error {
 	}
 
 	// Perform all leveldb updates using an atomic transaction.
+	start := time.Now()
 	if err := c.commitTreaps(cachedKeys, cachedRemove); err != nil {
 		return err
 	}
+	cacheFlushDuration.ObserveSince(start)
 
 	// Clear the cache since it has been flushed.
 	c.cacheLock.Lock()
@@ -648,12 +658,14 @@ func (c *dbCache) Close() error {
 // exceeds the provided value or it has been longer than the provided interval
 // since the last flush.
 func newDbCache(ldb *leveldb.DB, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
+	now := time.Now()
 	return &dbCache{
+		flushed:       now.UnixNano(),
 		ldb:           ldb,
 		store:         store,
 		maxSize:       maxSize,
 		flushInterval: time.Second * time.Duration(flushIntervalSecs),
-		lastFlush:     time.Now(),
+		lastFlush:     now,
 		cachedKeys:    treap.NewImmutable(),
 		cachedRemove:  treap.NewImmutable(),
 	}
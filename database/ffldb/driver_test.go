@@ -6,17 +6,24 @@ package ffldb_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // dbType is the database type name for this driver.
@@ -428,6 +435,116 @@ func TestPrune(t *testing.T) {
 	})
 }
 
+// TestPruneBlocksBefore ensures the block files preceding the file of a block
+// are deleted while the block and all blocks stored after it are kept.
+func TestPruneBlocksBefore(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := t.TempDir()
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer db.Close()
+
+	testfn := func(t *testing.T, db database.DB) {
+		blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+		if err != nil {
+			t.Errorf("loadBlocks: Unexpected error: %v", err)
+			return
+		}
+		err = db.Update(func(tx database.Tx) error {
+			for i, block := range blocks {
+				err := tx.StoreBlock(block)
+				if err != nil {
+					return fmt.Errorf("StoreBlock #%d: unexpected error: "+
+						"%v", i, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		filesBefore, _ := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+
+		// Pruning before the first block must not delete anything, and
+		// pruning before an unknown block must fail.
+		keep := len(blocks) / 2
+		err = db.Update(func(tx database.Tx) error {
+			deleted, err := tx.PruneBlocksBefore(blocks[0].Hash())
+			if err != nil {
+				return err
+			}
+			if len(deleted) != 0 {
+				return fmt.Errorf("pruned %d blocks before the "+
+					"first block", len(deleted))
+			}
+
+			var unknown chainhash.Hash
+			_, err = tx.PruneBlocksBefore(&unknown)
+			if dbErr, ok := err.(database.Error); !ok ||
+				dbErr.ErrorCode != database.ErrBlockNotFound {
+
+				return fmt.Errorf("expected ErrBlockNotFound but "+
+					"got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var deleted []chainhash.Hash
+		err = db.Update(func(tx database.Tx) error {
+			deleted, err = tx.PruneBlocksBefore(blocks[keep].Hash())
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		filesAfter, _ := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+		if len(deleted) == 0 || len(filesAfter) >= len(filesBefore) {
+			t.Fatalf("expected blocks to be pruned: deleted %d "+
+				"blocks, %d of %d files left", len(deleted),
+				len(filesAfter), len(filesBefore))
+		}
+
+		// Only blocks stored before the kept block may be deleted and all
+		// other blocks must still be available.
+		deletedSet := make(map[chainhash.Hash]struct{}, len(deleted))
+		for _, hash := range deleted {
+			deletedSet[hash] = struct{}{}
+		}
+		err = db.View(func(tx database.Tx) error {
+			for i, block := range blocks {
+				_, isDeleted := deletedSet[*block.Hash()]
+				if isDeleted && i >= keep {
+					return fmt.Errorf("block #%d after the kept "+
+						"block was deleted", i)
+				}
+				has, err := tx.HasBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if has == isDeleted {
+					return fmt.Errorf("block #%d: has block %v, "+
+						"deleted %v", i, has, isDeleted)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		testfn(t, db)
+	})
+}
+
 // TestInterface performs all interfaces tests for this database driver.
 func TestInterface(t *testing.T) {
 	t.Parallel()
@@ -459,3 +576,908 @@ func TestInterface(t *testing.T) {
 		testInterface(t, db)
 	})
 }
+
+// TestReentrantTx ensures that starting a transaction on a goroutine with an
+// active read-write transaction fails instead of deadlocking and that the
+// nested helpers reuse the active transaction.
+func TestReentrantTx(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-reentranttest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	isReentrant := func(err error) bool {
+		dbErr, ok := err.(database.Error)
+		return ok && dbErr.ErrorCode == database.ErrTxReentrant
+	}
+
+	key := []byte("key")
+	err = db.Update(func(tx database.Tx) error {
+		if tx.DB() != db {
+			return fmt.Errorf("DB: unexpected database")
+		}
+
+		// Nested transactions on the same goroutine must fail.
+		err := db.Update(func(database.Tx) error { return nil })
+		if !isReentrant(err) {
+			return fmt.Errorf("Update: unexpected error: %v", err)
+		}
+		err = db.View(func(database.Tx) error { return nil })
+		if !isReentrant(err) {
+			return fmt.Errorf("View: unexpected error: %v", err)
+		}
+		if _, err := db.Begin(true); !isReentrant(err) {
+			return fmt.Errorf("Begin: unexpected error: %v", err)
+		}
+
+		// Read-only transactions on other goroutines must still work.
+		errChan := make(chan error)
+		go func() {
+			errChan <- db.View(func(database.Tx) error { return nil })
+		}()
+		if err := <-errChan; err != nil {
+			return fmt.Errorf("View on other goroutine: %v", err)
+		}
+
+		// The nested helpers must reuse the active transaction.
+		err = database.UpdateNested(db, tx, func(ntx database.Tx) error {
+			if ntx != tx {
+				return fmt.Errorf("UpdateNested: new transaction")
+			}
+			return ntx.Metadata().Put(key, key)
+		})
+		if err != nil {
+			return err
+		}
+		return database.ViewNested(db, tx, func(ntx database.Tx) error {
+			if ntx.Metadata().Get(key) == nil {
+				return fmt.Errorf("ViewNested: missing pending key")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// Transactions must be available again once the outer one is done and
+	// the nested helpers must start their own without an active one.
+	err = database.ViewNested(db, nil, func(tx database.Tx) error {
+		if tx.Metadata().Get(key) == nil {
+			return fmt.Errorf("missing committed key")
+		}
+
+		err := database.UpdateNested(db, tx, func(database.Tx) error {
+			return nil
+		})
+		dbErr, ok := err.(database.Error)
+		if !ok || dbErr.ErrorCode != database.ErrTxNotWritable {
+			return fmt.Errorf("UpdateNested: unexpected error: %v",
+				err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ViewNested: %v", err)
+	}
+	err = database.UpdateNested(db, nil, func(tx database.Tx) error {
+		return tx.Metadata().Delete(key)
+	})
+	if err != nil {
+		t.Fatalf("UpdateNested: %v", err)
+	}
+}
+
+// TestDBStatus ensures the status of a database reports the holder of the
+// write lock and the cached changes without waiting for the write lock.
+func TestDBStatus(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-statustest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	status, err := ffldb.DBStatus(db)
+	if err != nil {
+		t.Fatalf("DBStatus: %v", err)
+	}
+	if status.Writer != 0 || !status.WriteLocked.IsZero() {
+		t.Fatalf("unexpected writer %d locked at %v", status.Writer,
+			status.WriteLocked)
+	}
+	if status.MaxCacheSize == 0 || status.LastFlush.IsZero() {
+		t.Fatalf("unexpected cache status %+v", status)
+	}
+
+	// The status must be available while a write transaction is active
+	// and report it.
+	start := time.Now()
+	err = db.Update(func(tx database.Tx) error {
+		status, err := ffldb.DBStatus(db)
+		if err != nil {
+			return err
+		}
+		if status.Writer == 0 || status.WriteLocked.Before(start) {
+			return fmt.Errorf("unexpected writer %d locked at %v",
+				status.Writer, status.WriteLocked)
+		}
+		return tx.Metadata().Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	status, err = ffldb.DBStatus(db)
+	if err != nil {
+		t.Fatalf("DBStatus: %v", err)
+	}
+	if status.Writer != 0 {
+		t.Fatalf("unexpected writer %d after commit", status.Writer)
+	}
+	if status.CachedKeys == 0 || status.CacheSize == 0 {
+		t.Fatalf("committed key not reported as cached: %+v", status)
+	}
+}
+
+// TestPruneTrash ensures that pruned block files are moved to the trash when it
+// is enabled and that they can be restored from it.
+func TestPruneTrash(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := t.TempDir()
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+
+	err = ffldb.ConfigureTrash(db, ffldb.TrashConfig{Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("ConfigureTrash: unexpected error: %v", err)
+	}
+
+	blockFileSize := uint64(2048)
+	testfn := func(t *testing.T, db database.DB) {
+		blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+		if err != nil {
+			t.Fatalf("loadBlocks: Unexpected error: %v", err)
+		}
+		err = db.Update(func(tx database.Tx) error {
+			for i, block := range blocks {
+				err := tx.StoreBlock(block)
+				if err != nil {
+					return fmt.Errorf("StoreBlock #%d: unexpected "+
+						"error: %v", i, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var deletedBlocks []chainhash.Hash
+		err = db.Update(func(tx database.Tx) error {
+			deletedBlocks, err = tx.PruneBlocks(blockFileSize * 3)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deletedBlocks) == 0 {
+			t.Fatal("PruneBlocks: no blocks were pruned")
+		}
+
+		// The pruned files must be in the trash rather than deleted.
+		trashed, err := ffldb.TrashedFiles(db)
+		if err != nil {
+			t.Fatalf("TrashedFiles: unexpected error: %v", err)
+		}
+		files, _ := filepath.Glob(filepath.Join(dbPath, "trash", "*.fdb"))
+		if len(trashed) == 0 || len(trashed) != len(files) {
+			t.Fatalf("TrashedFiles: got %d trashed files, want %d",
+				len(trashed), len(files))
+		}
+
+		// Each trashed file must have an index of its blocks.
+		idxFiles, _ := filepath.Glob(filepath.Join(dbPath, "trash",
+			"*.idx"))
+		if len(idxFiles) != len(trashed) {
+			t.Fatalf("got %d trash index files, want %d",
+				len(idxFiles), len(trashed))
+		}
+
+		// The pruned blocks must still be fetchable from the trash.
+		blocksByHash := make(map[chainhash.Hash]*btcutil.Block)
+		for _, block := range blocks {
+			blocksByHash[*block.Hash()] = block
+		}
+		for _, hash := range deletedBlocks {
+			gotBytes, err := ffldb.FetchTrashedBlock(db, &hash)
+			if err != nil {
+				t.Fatalf("FetchTrashedBlock: unexpected error: %v",
+					err)
+			}
+			wantBytes, err := blocksByHash[hash].Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotBytes, wantBytes) {
+				t.Fatalf("FetchTrashedBlock: got bytes %x, want "+
+					"bytes %x", gotBytes, wantBytes)
+			}
+		}
+		lastHash := blocks[len(blocks)-1].Hash()
+		_, err = ffldb.FetchTrashedBlock(db, lastHash)
+		if dbErr, ok := err.(database.Error); !ok ||
+			dbErr.ErrorCode != database.ErrBlockNotFound {
+
+			t.Fatalf("FetchTrashedBlock: got %v, want %v", err,
+				database.ErrBlockNotFound)
+		}
+
+		// A restore which fails part of the way through must leave all
+		// of the files it was asked to restore in the trash.
+		_, err = ffldb.RestoreTrashedFiles(db, []uint32{
+			trashed[0].FileNum, math.MaxUint32,
+		})
+		if err == nil {
+			t.Fatal("RestoreTrashedFiles: restored a file which is " +
+				"not in the trash")
+		}
+		files, _ = filepath.Glob(filepath.Join(dbPath, "trash", "*.fdb"))
+		if len(files) != len(trashed) {
+			t.Fatalf("RestoreTrashedFiles: %d trashed files left "+
+				"after a failed restore, want %d", len(files),
+				len(trashed))
+		}
+		err = db.View(func(tx database.Tx) error {
+			exists, err := tx.HasBlock(&deletedBlocks[0])
+			if err == nil && exists {
+				err = fmt.Errorf("block of a failed restore " +
+					"is in the index")
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Restoring the files must make all of the pruned blocks
+		// available again and leave the trash empty.
+		restored, err := ffldb.RestoreTrashedFiles(db, nil)
+		if err != nil {
+			t.Fatalf("RestoreTrashedFiles: unexpected error: %v", err)
+		}
+		if len(restored) != len(deletedBlocks) {
+			t.Fatalf("RestoreTrashedFiles: restored %d blocks, want "+
+				"%d", len(restored), len(deletedBlocks))
+		}
+		err = db.View(func(tx database.Tx) error {
+			for _, block := range blocks {
+				gotBytes, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				wantBytes, err := block.Bytes()
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(gotBytes, wantBytes) {
+					return fmt.Errorf("got bytes %x, want bytes %x",
+						gotBytes, wantBytes)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trashed, err = ffldb.TrashedFiles(db)
+		if err != nil {
+			t.Fatalf("TrashedFiles: unexpected error: %v", err)
+		}
+		if len(trashed) != 0 {
+			t.Fatalf("TrashedFiles: got %d trashed files after "+
+				"restore, want 0", len(trashed))
+		}
+		idxFiles, _ = filepath.Glob(filepath.Join(dbPath, "trash",
+			"*.idx"))
+		if len(idxFiles) != 0 {
+			t.Fatalf("got %d trash index files after restore, "+
+				"want 0", len(idxFiles))
+		}
+		_, err = ffldb.FetchTrashedBlock(db, &deletedBlocks[0])
+		if dbErr, ok := err.(database.Error); !ok ||
+			dbErr.ErrorCode != database.ErrBlockNotFound {
+
+			t.Fatalf("FetchTrashedBlock: got %v for a restored "+
+				"block, want %v", err, database.ErrBlockNotFound)
+		}
+
+		// Prune again and ensure lowering the maximum trash size
+		// permanently removes the trashed files.
+		err = db.Update(func(tx database.Tx) error {
+			_, err := tx.PruneBlocks(blockFileSize * 3)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ffldb.ConfigureTrash(db, ffldb.TrashConfig{
+			Retention: time.Hour,
+			MaxSize:   1,
+		})
+		if err != nil {
+			t.Fatalf("ConfigureTrash: unexpected error: %v", err)
+		}
+		trashed, err = ffldb.TrashedFiles(db)
+		if err != nil {
+			t.Fatalf("TrashedFiles: unexpected error: %v", err)
+		}
+		if len(trashed) != 0 {
+			t.Fatalf("TrashedFiles: got %d trashed files over the "+
+				"quota, want 0", len(trashed))
+		}
+		idxFiles, _ = filepath.Glob(filepath.Join(dbPath, "trash",
+			"*.idx"))
+		if len(idxFiles) != 0 {
+			t.Fatalf("got %d trash index files over the quota, "+
+				"want 0", len(idxFiles))
+		}
+		_, err = ffldb.FetchTrashedBlock(db, &deletedBlocks[0])
+		if dbErr, ok := err.(database.Error); !ok ||
+			dbErr.ErrorCode != database.ErrBlockNotFound {
+
+			t.Fatalf("FetchTrashedBlock: got %v for a purged "+
+				"block, want %v", err, database.ErrBlockNotFound)
+		}
+	}
+	ffldb.TstRunWithMaxBlockFileSize(db, uint32(blockFileSize), func() {
+		testfn(t, db)
+	})
+}
+
+// TestClone ensures a database cloned with and without copy-on-write block
+// files contains all of the blocks and metadata of the source database and
+// that the source database remains usable afterwards.
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+
+	// Create a new database to run tests against.
+	dbPath := t.TempDir()
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+
+	bucketKey := []byte("clonetest")
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		// Store all but the last block so one can be added after the
+		// clone is made.
+		err = db.Update(func(tx database.Tx) error {
+			for i, block := range blocks[:len(blocks)-1] {
+				if err := tx.StoreBlock(block); err != nil {
+					return fmt.Errorf("StoreBlock #%d: "+
+						"unexpected error: %v", i, err)
+				}
+			}
+			_, err := tx.Metadata().CreateBucket(bucketKey)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, cow := range []bool{false, true} {
+			clonePath := filepath.Join(t.TempDir(), "clone")
+			stats, err := ffldb.Clone(db, clonePath, cow)
+			if err != nil {
+				t.Fatalf("Clone(cow=%v): unexpected error: %v",
+					cow, err)
+			}
+			if !cow && stats.ReflinkedFiles+stats.LinkedFiles != 0 {
+				t.Fatalf("Clone(cow=false): shared %d files",
+					stats.ReflinkedFiles+stats.LinkedFiles)
+			}
+			if cow && stats.ReflinkedFiles+stats.LinkedFiles == 0 {
+				t.Fatal("Clone(cow=true): no files were shared")
+			}
+			if _, err := ffldb.Clone(db, clonePath, cow); err == nil {
+				t.Fatal("Clone: did not fail for an existing " +
+					"destination")
+			}
+
+			cloneDb, err := database.Open(dbType, clonePath,
+				blockDataNet)
+			if err != nil {
+				t.Fatalf("Failed to open cloned database: %v",
+					err)
+			}
+			err = cloneDb.View(func(tx database.Tx) error {
+				if tx.Metadata().Bucket(bucketKey) == nil {
+					return fmt.Errorf("missing bucket")
+				}
+				for i, block := range blocks[:len(blocks)-1] {
+					blockBytes, err := tx.FetchBlock(block.Hash())
+					if err != nil {
+						return fmt.Errorf("FetchBlock #%d: "+
+							"unexpected error: %v", i, err)
+					}
+					wantBytes, _ := block.Bytes()
+					if !bytes.Equal(blockBytes, wantBytes) {
+						return fmt.Errorf("FetchBlock #%d: "+
+							"mismatched bytes", i)
+					}
+				}
+				return nil
+			})
+			cloneDb.Close()
+			if err != nil {
+				t.Fatalf("cloned database: %v", err)
+			}
+		}
+
+		// Ensure the source database can still be written to and read
+		// from after being cloned.
+		lastBlock := blocks[len(blocks)-1]
+		err = db.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(lastBlock)
+		})
+		if err != nil {
+			t.Fatalf("StoreBlock after clone: unexpected error: %v",
+				err)
+		}
+		err = db.View(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if _, err := tx.FetchBlock(block.Hash()); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("FetchBlock after clone: unexpected error: %v",
+				err)
+		}
+	})
+}
+
+// newDumpSource returns the dump source for the passed location and pinned
+// manifest hash or fails the test.
+func newDumpSource(t *testing.T, location, manifestHash string) ffldb.DumpSource {
+	t.Helper()
+
+	src, err := ffldb.NewDumpSource(location, manifestHash)
+	if err != nil {
+		t.Fatalf("NewDumpSource: unexpected error: %v", err)
+	}
+	return src
+}
+
+// checkBlocks ensures all of the passed blocks can be fetched from the database
+// at dbPath and match.
+func checkBlocks(t *testing.T, dbPath string, blocks []*btcutil.Block) {
+	t.Helper()
+
+	db, err := database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open repaired database: %v", err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx database.Tx) error {
+		for i, block := range blocks {
+			blockBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return fmt.Errorf("FetchBlock #%d: unexpected "+
+					"error: %v", i, err)
+			}
+			wantBytes, _ := block.Bytes()
+			if !bytes.Equal(blockBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock #%d: mismatched "+
+					"bytes", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("repaired database: %v", err)
+	}
+}
+
+// TestDumpRepair ensures a database can be dumped and that damaged block files
+// and metadata are repaired from the dump, both from a local directory and
+// over HTTP.
+func TestDumpRepair(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	dumped, extra := blocks[:len(blocks)-1], blocks[len(blocks)-1]
+
+	// Create a database with all but the last block, dump it, and add the
+	// last block afterwards so the database progressed since the dump.
+	dbPath := t.TempDir()
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	dumpPath := filepath.Join(t.TempDir(), "dump")
+	var dumpStats *ffldb.DumpStats
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		err = db.Update(func(tx database.Tx) error {
+			for i, block := range dumped {
+				if err := tx.StoreBlock(block); err != nil {
+					return fmt.Errorf("StoreBlock #%d: "+
+						"unexpected error: %v", i, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dumpCfg := ffldb.DumpConfig{ChunkSize: 512, RangeEntries: 50}
+		stats, err := ffldb.Dump(db, dumpPath, dumpCfg)
+		if err != nil {
+			t.Fatalf("Dump: unexpected error: %v", err)
+		}
+		dumpStats = stats
+		if stats.MetadataRanges < 2 {
+			t.Fatalf("Dump: wrote %d metadata ranges",
+				stats.MetadataRanges)
+		}
+		if _, err := ffldb.Dump(db, dumpPath, dumpCfg); err == nil {
+			t.Fatal("Dump: did not fail for an existing destination")
+		}
+
+		err = db.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(extra)
+		})
+		if err != nil {
+			t.Fatalf("StoreBlock after dump: unexpected error: %v",
+				err)
+		}
+	})
+	db.Close()
+
+	manifest, err := ffldb.ReadDumpManifest(newDumpSource(t, dumpPath, ""))
+	if err != nil {
+		t.Fatalf("ReadDumpManifest: unexpected error: %v", err)
+	}
+	if len(manifest.BlockFiles) < 3 {
+		t.Fatalf("dump has %d block files", len(manifest.BlockFiles))
+	}
+
+	// Ensure a dump for another network is rejected.
+	_, err = ffldb.Repair(dbPath, wire.TestNet3, newDumpSource(t, dumpPath, ""))
+	if err == nil {
+		t.Fatal("Repair: did not fail for a dump of another network")
+	}
+
+	// Damage a block file and remove another one.
+	damagedFile := filepath.Join(dbPath, "000000001.fdb")
+	fileBytes, err := os.ReadFile(damagedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileBytes[600] ^= 0xff
+	if err := os.WriteFile(damagedFile, fileBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dbPath, "000000002.fdb")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dumps served over plain HTTP are only used with a pinned manifest
+	// hash that matches the manifest.
+	server := httptest.NewServer(http.FileServer(http.Dir(dumpPath)))
+	defer server.Close()
+	if _, err := ffldb.NewDumpSource(server.URL, ""); err == nil {
+		t.Fatal("NewDumpSource: accepted plain HTTP without a pinned " +
+			"manifest hash")
+	}
+	manifestBytes, err := os.ReadFile(filepath.Join(dumpPath,
+		"manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestHash := sha256.Sum256(manifestBytes)
+	if got := hex.EncodeToString(manifestHash[:]); got != dumpStats.ManifestHash {
+		t.Fatalf("Dump: got manifest hash %s, want %s",
+			dumpStats.ManifestHash, got)
+	}
+	wrongHash := manifestHash
+	wrongHash[0] ^= 0xff
+	_, err = ffldb.Repair(dbPath, blockDataNet, newDumpSource(t,
+		server.URL, hex.EncodeToString(wrongHash[:])))
+	if err == nil {
+		t.Fatal("Repair: did not fail for a manifest that doesn't " +
+			"match the pinned hash")
+	}
+
+	// Files larger than the requested maximum size are not read.
+	src := newDumpSource(t, dumpPath, "")
+	_, err = src.ReadFile("manifest.json", int64(len(manifestBytes)-1))
+	if err == nil {
+		t.Fatal("ReadFile: read a file larger than the maximum size")
+	}
+
+	// Repair the database over HTTP.
+	stats, err := ffldb.Repair(dbPath, blockDataNet, newDumpSource(t,
+		server.URL, hex.EncodeToString(manifestHash[:])))
+	if err != nil {
+		t.Fatalf("Repair: unexpected error: %v", err)
+	}
+	if stats.DamagedChunks != 5 || stats.RestoredFiles != 1 {
+		t.Fatalf("Repair: restored %d chunks and %d files, want 5 "+
+			"and 1", stats.DamagedChunks, stats.RestoredFiles)
+	}
+	if stats.DamagedRanges != 0 || stats.RolledBack {
+		t.Fatalf("Repair: restored %d metadata ranges of intact "+
+			"metadata", stats.DamagedRanges)
+	}
+	checkBlocks(t, dbPath, blocks)
+
+	// Repairing an intact database doesn't fetch anything.
+	stats, err = ffldb.Repair(dbPath, blockDataNet,
+		newDumpSource(t, dumpPath, ""))
+	if err != nil {
+		t.Fatalf("Repair: unexpected error: %v", err)
+	}
+	if stats.FetchedBytes != 0 || stats.DamagedRanges != 0 {
+		t.Fatalf("Repair: fetched %d bytes and %d metadata ranges "+
+			"for an intact database", stats.FetchedBytes,
+			stats.DamagedRanges)
+	}
+
+	// Truncate the block stored after the dump was made, which can't be
+	// restored from the dump, so the database is rolled back to the state
+	// of the dump.
+	lastFiles, err := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastFile := lastFiles[len(lastFiles)-1]
+	fi, err := os.Stat(lastFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(lastFile, fi.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = ffldb.Repair(dbPath, blockDataNet,
+		newDumpSource(t, dumpPath, ""))
+	if err != nil {
+		t.Fatalf("Repair: unexpected error: %v", err)
+	}
+	if !stats.RolledBack {
+		t.Fatal("Repair: database with missing block data was not " +
+			"rolled back")
+	}
+	checkBlocks(t, dbPath, dumped)
+
+	// Remove the metadata so it is restored in full.
+	err = os.RemoveAll(filepath.Join(dbPath, "metadata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err = ffldb.Repair(dbPath, blockDataNet,
+		newDumpSource(t, dumpPath, ""))
+	if err != nil {
+		t.Fatalf("Repair: unexpected error: %v", err)
+	}
+	if !stats.RolledBack ||
+		stats.DamagedRanges != len(manifest.MetadataRanges) {
+
+		t.Fatalf("Repair: restored %d of %d metadata ranges, rolled "+
+			"back %v", stats.DamagedRanges,
+			len(manifest.MetadataRanges), stats.RolledBack)
+	}
+	checkBlocks(t, dbPath, dumped)
+
+	db, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open repaired database: %v", err)
+	}
+	defer db.Close()
+	err = db.View(func(tx database.Tx) error {
+		exists, err := tx.HasBlock(extra.Hash())
+		if err == nil && exists {
+			err = fmt.Errorf("block stored after the dump exists")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("rolled back database: %v", err)
+	}
+}
+
+// localBlockDataSize returns the total size of the flat block files in the
+// passed database directory.
+func localBlockDataSize(t *testing.T, dbPath string) int64 {
+	files, err := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += fi.Size()
+	}
+	return size
+}
+
+// TestSharedStore ensures blocks found in a shared block store are referenced
+// from there instead of being written to the flat files of the database, that
+// blocks appended to the shared block store are picked up when it is
+// configured again, and that all blocks can be fetched back.
+func TestSharedStore(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	storeBlocks := func(db database.DB, blocks []*btcutil.Block) {
+		t.Helper()
+		err := db.Update(func(tx database.Tx) error {
+			for i, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return fmt.Errorf("StoreBlock #%d: "+
+						"unexpected error: %v", i, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create the database of the network the blocks are shared with and
+	// store the first half of the blocks in it.
+	sharedPath := t.TempDir()
+	sharedDb, err := database.Create(dbType, sharedPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create shared database: %v", err)
+	}
+	defer sharedDb.Close()
+	half := len(blocks) / 2
+	storeBlocks(sharedDb, blocks[:half])
+
+	// Create the database of the forked network which references the
+	// shared block store.  None of the shared blocks may be written to its
+	// flat files.
+	dbPath := t.TempDir()
+	db, err := database.Create(dbType, dbPath, wire.TestNet3)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	err = ffldb.ConfigureSharedStore(db, ffldb.SharedStoreConfig{
+		Path: dbPath,
+	})
+	if err == nil {
+		t.Fatal("ConfigureSharedStore: expected error for the " +
+			"database itself")
+	}
+	cfg := ffldb.SharedStoreConfig{Path: sharedPath}
+	if err := ffldb.ConfigureSharedStore(db, cfg); err != nil {
+		t.Fatalf("ConfigureSharedStore: unexpected error: %v", err)
+	}
+	storeBlocks(db, blocks[:half])
+	if size := localBlockDataSize(t, dbPath); size != 0 {
+		t.Fatalf("shared blocks wrote %d bytes to the database", size)
+	}
+
+	// Append more blocks to the shared block store, leaving the last
+	// blocks for the forked network only, and reopen the forked database.
+	split := len(blocks) - 5
+	storeBlocks(sharedDb, blocks[half:split])
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = database.Open(dbType, dbPath, wire.TestNet3)
+	if err != nil {
+		t.Fatalf("Failed to reopen test database: %v", err)
+	}
+	defer db.Close()
+
+	// Shared blocks can't be read without the shared block store.
+	err = db.View(func(tx database.Tx) error {
+		_, err := tx.FetchBlock(blocks[0].Hash())
+		return err
+	})
+	if err == nil {
+		t.Fatal("FetchBlock: expected error without the shared block " +
+			"store")
+	}
+
+	// Configuring the shared block store again picks up the appended
+	// blocks, so only the blocks past the split are written locally.
+	if err := ffldb.ConfigureSharedStore(db, cfg); err != nil {
+		t.Fatalf("ConfigureSharedStore: unexpected error: %v", err)
+	}
+	storeBlocks(db, blocks[half:])
+	var wantSize int64
+	for _, block := range blocks[split:] {
+		blockBytes, err := block.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantSize += int64(len(blockBytes)) + 12
+	}
+	if size := localBlockDataSize(t, dbPath); size != wantSize {
+		t.Fatalf("unexpected size of the local block data - got %d, "+
+			"want %d", size, wantSize)
+	}
+
+	err = db.Update(func(tx database.Tx) error {
+		for i, block := range blocks {
+			blockBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return fmt.Errorf("FetchBlock #%d: unexpected "+
+					"error: %v", i, err)
+			}
+			wantBytes, _ := block.Bytes()
+			if !bytes.Equal(blockBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock #%d: bytes "+
+					"mismatch", i)
+			}
+
+			header, err := tx.FetchBlockHeader(block.Hash())
+			if err != nil {
+				return fmt.Errorf("FetchBlockHeader #%d: "+
+					"unexpected error: %v", i, err)
+			}
+			if !bytes.Equal(header, wantBytes[:80]) {
+				return fmt.Errorf("FetchBlockHeader #%d: bytes "+
+					"mismatch", i)
+			}
+		}
+
+		// Pruning before a shared block must leave the flat files of
+		// the database alone.
+		deleted, err := tx.PruneBlocksBefore(blocks[half].Hash())
+		if err != nil {
+			return err
+		}
+		if len(deleted) != 0 {
+			return fmt.Errorf("pruned %d blocks before a shared "+
+				"block", len(deleted))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
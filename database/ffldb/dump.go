@@ -0,0 +1,690 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of dumping a database along with a
+// manifest which describes its contents in enough detail that damaged parts
+// of a database can later be identified and restored from the dump.  See
+// repair.go for the repair side.
+
+package ffldb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// dumpManifestVersion is the current version of the dump manifest
+	// format.
+	dumpManifestVersion = 1
+
+	// dumpManifestName is the name of the manifest file of a dump.
+	dumpManifestName = "manifest.json"
+
+	// dumpRangesDir is the name of the directory of a dump which holds the
+	// metadata key range files.
+	dumpRangesDir = "ranges"
+
+	// dumpRangeTemplate is the template for the names of the metadata key
+	// range files of a dump.
+	dumpRangeTemplate = "%06d.kv"
+
+	// defaultDumpChunkSize is the default size of the chunks of the block
+	// files which are hashed, and therefore verified and restored,
+	// individually.
+	defaultDumpChunkSize = 16 * 1024 * 1024 // 16 MiB
+
+	// defaultDumpRangeEntries is the default number of metadata entries
+	// per key range.
+	defaultDumpRangeEntries = 100000
+
+	// maxDumpManifestSize is the maximum size of a dump manifest that is
+	// read.
+	maxDumpManifestSize = 64 * 1024 * 1024 // 64 MiB
+
+	// maxDumpRangeSize is the maximum size of a metadata key range file
+	// that is read when the manifest doesn't state the size of the range.
+	maxDumpRangeSize = 1024 * 1024 * 1024 // 1 GiB
+
+	// dumpHTTPTimeout is the time limit for each request to a dump served
+	// over HTTP, including reading the response.
+	dumpHTTPTimeout = 10 * time.Minute
+)
+
+// DumpConfig houses the options of a database dump.
+type DumpConfig struct {
+	// CopyOnWrite shares the completed block files with the source
+	// database using copy-on-write reflinks, or hard links when reflinks
+	// are not supported, instead of copying them.
+	CopyOnWrite bool
+
+	// ChunkSize is the size of the chunks of the block files which are
+	// hashed individually.  Smaller chunks limit the amount of data that
+	// is fetched to restore damaged block data at the cost of a larger
+	// manifest.  The default is used when it is zero.
+	ChunkSize int64
+
+	// RangeEntries is the number of metadata entries per key range.  The
+	// default is used when it is zero.
+	RangeEntries int
+}
+
+// DumpStats describes the work done by Dump.
+type DumpStats struct {
+	CloneStats
+
+	// MetadataRanges is the number of metadata key ranges that were
+	// written.
+	MetadataRanges int
+
+	// ManifestHash is the hex-encoded SHA-256 hash of the manifest of the
+	// dump, which can be pinned to authenticate the dump when repairing a
+	// database from it.
+	ManifestHash string
+}
+
+// DumpBlockFile describes a block file of a dump.
+type DumpBlockFile struct {
+	// FileNum is the number of the block file.
+	FileNum uint32 `json:"filenum"`
+
+	// Size is the size of the block file in the dump.
+	Size int64 `json:"size"`
+
+	// Chunks are the hex-encoded SHA-256 hashes of the consecutive chunks
+	// of the block file.  All chunks except the last one have the chunk
+	// size of the manifest.
+	Chunks []string `json:"chunks"`
+}
+
+// DumpMetadataRange describes a range of metadata keys of a dump.
+type DumpMetadataRange struct {
+	// Start is the hex-encoded first key of the range.  It is empty for
+	// the first range.
+	Start string `json:"start"`
+
+	// End is the hex-encoded key the range ends before.  It is empty for
+	// the last range.
+	End string `json:"end"`
+
+	// Entries is the number of metadata entries in the range.
+	Entries int `json:"entries"`
+
+	// Size is the size of the range file.
+	Size int64 `json:"size,omitempty"`
+
+	// Hash is the hex-encoded SHA-256 hash of the serialized entries of
+	// the range, which is also the hash of the range file.
+	Hash string `json:"hash"`
+
+	// File is the slash-separated path of the file holding the serialized
+	// entries of the range relative to the dump.
+	File string `json:"file"`
+}
+
+// bounds returns the decoded start and end keys of the range.  Nil is returned
+// for unbounded ends.
+func (r *DumpMetadataRange) bounds() ([]byte, []byte, error) {
+	var start, end []byte
+	if r.Start != "" {
+		var err error
+		start, err = hex.DecodeString(r.Start)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if r.End != "" {
+		var err error
+		end, err = hex.DecodeString(r.End)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return start, end, nil
+}
+
+// DumpManifest describes the contents of a database dump.  It holds the
+// hashes of the chunks of all block files and of all metadata key ranges of
+// the dumped database so damaged parts of a database can be identified and
+// restored individually.
+type DumpManifest struct {
+	// Version is the version of the manifest format.
+	Version int `json:"version"`
+
+	// Network is the network the dumped database is for.
+	Network wire.BitcoinNet `json:"network"`
+
+	// WriteFileNum and WriteOffset are the position of the write cursor of
+	// the dumped database.
+	WriteFileNum uint32 `json:"writefilenum"`
+	WriteOffset  uint32 `json:"writeoffset"`
+
+	// ChunkSize is the size of the hashed chunks of the block files.
+	ChunkSize int64 `json:"chunksize"`
+
+	// BlockFiles are the block files of the dump.
+	BlockFiles []DumpBlockFile `json:"blockfiles"`
+
+	// MetadataRanges are the metadata key ranges of the dump in key
+	// order.  They cover the entire key space.
+	MetadataRanges []DumpMetadataRange `json:"metadataranges"`
+}
+
+// DumpSource provides access to the files of a database dump.
+type DumpSource interface {
+	// ReadFile returns the contents of the file of the dump with the
+	// passed slash-separated path.  An error is returned when the file is
+	// larger than maxSize bytes.
+	ReadFile(name string, maxSize int64) ([]byte, error)
+
+	// ReadFileRange returns size bytes of the file of the dump with the
+	// passed slash-separated path starting at the passed offset.
+	ReadFileRange(name string, offset, size int64) ([]byte, error)
+}
+
+// readDumpFile reads the named file of a dump from r and returns an error when
+// it is larger than maxSize bytes.
+func readDumpFile(r io.Reader, name string, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file %s of the dump is larger than %d "+
+			"bytes", name, maxSize)
+	}
+	return data, nil
+}
+
+// dirDumpSource is a DumpSource for a dump in a local directory.
+type dirDumpSource struct {
+	path string
+}
+
+// ReadFile returns the contents of the named file of the dump.
+//
+// This is part of the DumpSource interface.
+func (s *dirDumpSource) ReadFile(name string, maxSize int64) ([]byte, error) {
+	f, err := os.Open(filepath.Join(s.path, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readDumpFile(f, name, maxSize)
+}
+
+// ReadFileRange returns the requested range of the named file of the dump.
+//
+// This is part of the DumpSource interface.
+func (s *dirDumpSource) ReadFileRange(name string, offset, size int64) ([]byte, error) {
+	f, err := os.Open(filepath.Join(s.path, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// httpDumpSource is a DumpSource for a dump served over HTTP.  Only the
+// requested ranges of block files are transferred when the server supports
+// range requests.
+type httpDumpSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+// get issues a GET request for the named file of the dump with the passed
+// range header, if any.  The caller must close the body of the returned
+// response.
+func (s *httpDumpSource) get(name, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, s.baseURL+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusPartialContent {
+
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response fetching %s: %s",
+			req.URL, resp.Status)
+	}
+	return resp, nil
+}
+
+// ReadFile returns the contents of the named file of the dump.
+//
+// This is part of the DumpSource interface.
+func (s *httpDumpSource) ReadFile(name string, maxSize int64) ([]byte, error) {
+	resp, err := s.get(name, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return readDumpFile(resp.Body, name, maxSize)
+}
+
+// ReadFileRange returns the requested range of the named file of the dump.
+// Servers which don't support range requests respond with the entire file, in
+// which case the data before the range is skipped.
+//
+// This is part of the DumpSource interface.
+func (s *httpDumpSource) ReadFileRange(name string, offset, size int64) ([]byte, error) {
+	byteRange := fmt.Sprintf("bytes=%d-%d", offset, offset+size-1)
+	resp, err := s.get(name, byteRange)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return nil, err
+		}
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("short read fetching %s: %v", name, err)
+	}
+	return data, nil
+}
+
+// pinnedDumpSource is a DumpSource which ensures the manifest of the dump
+// matches a known hash.  Since the manifest holds the hashes of all other
+// files of the dump, this authenticates the entire dump.
+type pinnedDumpSource struct {
+	DumpSource
+	manifestHash [sha256.Size]byte
+}
+
+// ReadFile returns the contents of the named file of the dump.  The manifest
+// is only returned when it matches the pinned hash.
+//
+// This is part of the DumpSource interface.
+func (s *pinnedDumpSource) ReadFile(name string, maxSize int64) ([]byte, error) {
+	data, err := s.DumpSource.ReadFile(name, maxSize)
+	if err != nil || name != dumpManifestName {
+		return data, err
+	}
+	if sha256.Sum256(data) != s.manifestHash {
+		return nil, errors.New("dump manifest does not match the " +
+			"pinned manifest hash")
+	}
+	return data, nil
+}
+
+// NewDumpSource returns a DumpSource for the dump at the passed location,
+// which is either the path of a local directory or an http or https URL of
+// the directory the dump is served from.
+//
+// The manifest hash is the hex-encoded SHA-256 hash of the manifest of the
+// dump.  When it is not empty, the dump is only used when its manifest matches
+// the hash.  It is required for plain http URLs since nothing else
+// authenticates a dump served that way.
+func NewDumpSource(location, manifestHash string) (DumpSource, error) {
+	var src DumpSource
+	switch {
+	case strings.HasPrefix(location, "http://") && manifestHash == "":
+		return nil, errors.New("dumps served over plain http require " +
+			"a pinned manifest hash")
+
+	case strings.HasPrefix(location, "http://") ||
+		strings.HasPrefix(location, "https://"):
+
+		src = &httpDumpSource{
+			baseURL: strings.TrimSuffix(location, "/"),
+			client:  &http.Client{Timeout: dumpHTTPTimeout},
+		}
+
+	default:
+		src = &dirDumpSource{path: location}
+	}
+	if manifestHash == "" {
+		return src, nil
+	}
+
+	hash, err := hex.DecodeString(manifestHash)
+	if err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("invalid dump manifest hash %q",
+			manifestHash)
+	}
+	pinned := &pinnedDumpSource{DumpSource: src}
+	copy(pinned.manifestHash[:], hash)
+	return pinned, nil
+}
+
+// ReadDumpManifest reads and validates the manifest of the dump provided by
+// the passed source.
+func ReadDumpManifest(src DumpSource) (*DumpManifest, error) {
+	data, err := src.ReadFile(dumpManifestName, maxDumpManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dump manifest: %v", err)
+	}
+	var manifest DumpManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid dump manifest: %v", err)
+	}
+	if manifest.Version != dumpManifestVersion {
+		return nil, fmt.Errorf("unsupported dump manifest version %d",
+			manifest.Version)
+	}
+	if manifest.ChunkSize <= 0 {
+		return nil, fmt.Errorf("invalid dump manifest chunk size %d",
+			manifest.ChunkSize)
+	}
+	if len(manifest.MetadataRanges) == 0 {
+		return nil, errors.New("dump manifest has no metadata ranges")
+	}
+	for i := range manifest.MetadataRanges {
+		if manifest.MetadataRanges[i].Size < 0 {
+			return nil, fmt.Errorf("invalid size %d of dump "+
+				"metadata range %d",
+				manifest.MetadataRanges[i].Size, i)
+		}
+	}
+	return &manifest, nil
+}
+
+// writeDumpEntry writes the passed metadata entry to w in the format of the
+// metadata key range files of a dump.  Each entry is serialized as the
+// varint-encoded length of the key, the key, the varint-encoded length of the
+// value and the value.
+func writeDumpEntry(w io.Writer, key, value []byte) error {
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(key)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	if _, err := w.Write(key); err != nil {
+		return err
+	}
+	n = binary.PutUvarint(lenBuf[:], uint64(len(value)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(value)
+	return err
+}
+
+// readDumpEntries decodes the serialized metadata entries of a key range file
+// and invokes the passed function with each of them.
+func readDumpEntries(data []byte, fn func(key, value []byte) error) error {
+	r := bytes.NewReader(data)
+	readField := func() ([]byte, error) {
+		fieldLen, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if fieldLen > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		field := make([]byte, fieldLen)
+		_, err = io.ReadFull(r, field)
+		return field, err
+	}
+	for r.Len() > 0 {
+		key, err := readField()
+		if err != nil {
+			return err
+		}
+		value, err := readField()
+		if err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metadataRangeDigest returns the number of entries and the hex-encoded
+// SHA-256 hash of the serialized entries of the passed leveldb database which
+// fall in the key range from start up to but not including end.  Nil bounds
+// are unbounded.
+func metadataRangeDigest(ldb *leveldb.DB, start, end []byte) (int, string, error) {
+	iter := ldb.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	defer iter.Release()
+
+	hasher := sha256.New()
+	var entries int
+	for iter.Next() {
+		if err := writeDumpEntry(hasher, iter.Key(), iter.Value()); err != nil {
+			return 0, "", err
+		}
+		entries++
+	}
+	if err := iter.Error(); err != nil {
+		return 0, "", err
+	}
+	return entries, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// hashBlockFileChunks returns the hex-encoded SHA-256 hashes of the
+// consecutive chunks of the passed size of the file at the passed path along
+// with the size of the file.
+func hashBlockFileChunks(filePath string, chunkSize int64) ([]string, int64, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var chunks []string
+	var size int64
+	for {
+		hasher := sha256.New()
+		n, err := io.CopyN(hasher, f, chunkSize)
+		if n > 0 {
+			chunks = append(chunks, hex.EncodeToString(hasher.Sum(nil)))
+			size += n
+		}
+		if err == io.EOF {
+			return chunks, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// dumpMetadata writes all entries in a snapshot of the passed leveldb database
+// to metadata key range files of rangeEntries entries each under destPath and
+// returns the descriptions of the ranges.
+func dumpMetadata(ldb *leveldb.DB, destPath string,
+	rangeEntries int) ([]DumpMetadataRange, error) {
+
+	snap, err := ldb.GetSnapshot()
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	defer snap.Release()
+
+	rangesPath := filepath.Join(destPath, dumpRangesDir)
+	if err := os.MkdirAll(rangesPath, 0700); err != nil {
+		return nil, err
+	}
+
+	var ranges []DumpMetadataRange
+	var buf bytes.Buffer
+	cur := DumpMetadataRange{}
+	finishRange := func(nextKey []byte) error {
+		fileName := fmt.Sprintf(dumpRangeTemplate, len(ranges))
+		filePath := filepath.Join(rangesPath, fileName)
+		if err := os.WriteFile(filePath, buf.Bytes(), 0600); err != nil {
+			return err
+		}
+		hash := sha256.Sum256(buf.Bytes())
+		cur.Hash = hex.EncodeToString(hash[:])
+		cur.Size = int64(buf.Len())
+		cur.File = path.Join(dumpRangesDir, fileName)
+		if nextKey != nil {
+			cur.End = hex.EncodeToString(nextKey)
+		}
+		ranges = append(ranges, cur)
+
+		buf.Reset()
+		cur = DumpMetadataRange{Start: cur.End}
+		return nil
+	}
+
+	iter := snap.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if cur.Entries == rangeEntries {
+			if err := finishRange(iter.Key()); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeDumpEntry(&buf, iter.Key(), iter.Value()); err != nil {
+			return nil, err
+		}
+		cur.Entries++
+	}
+	if err := iter.Error(); err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	if err := finishRange(nil); err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// Dump creates a dump of the passed database, which must have been opened
+// with this driver, at destPath.  The destination must not already exist.  The
+// database remains usable while it is dumped, however writes are blocked until
+// the dump is complete.
+//
+// A dump consists of copies of the block files, which are cloned the same way
+// as by Clone, the metadata split into files of consecutive key ranges, and a
+// manifest with the hashes of the chunks of the block files and of the key
+// ranges.  A database that became corrupted can be repaired from a dump with
+// Repair, which only fetches the parts of the dump that are damaged in the
+// database.
+func Dump(idb database.DB, destPath string, cfg DumpConfig) (*DumpStats, error) {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := cfg.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultDumpChunkSize
+	}
+	rangeEntries := cfg.RangeEntries
+	if rangeEntries == 0 {
+		rangeEntries = defaultDumpRangeEntries
+	}
+	if chunkSize < 0 || rangeEntries < 0 {
+		return nil, errors.New("dump chunk size and range entries " +
+			"must not be negative")
+	}
+
+	// Prevent the database from being closed and block all writes while
+	// the dump is created so the block files and metadata are consistent.
+	pdb.closeLock.RLock()
+	defer pdb.closeLock.RUnlock()
+	if pdb.closed {
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	pdb.writeLock.Lock()
+	defer pdb.writeLock.Unlock()
+
+	// Flush the cache so all committed metadata is in the underlying leveldb
+	// database and all block data is synced to the flat files.
+	if err := pdb.cache.flush(); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		return nil, fmt.Errorf("dump destination %s already exists",
+			destPath)
+	}
+	if err := os.MkdirAll(destPath, 0700); err != nil {
+		return nil, err
+	}
+
+	wc := pdb.store.writeCursor
+	wc.RLock()
+	manifest := DumpManifest{
+		Version:      dumpManifestVersion,
+		Network:      pdb.store.network,
+		WriteFileNum: wc.curFileNum,
+		WriteOffset:  wc.curOffset,
+		ChunkSize:    chunkSize,
+	}
+	wc.RUnlock()
+
+	stats := &DumpStats{}
+	manifest.MetadataRanges, err = dumpMetadata(pdb.cache.ldb, destPath,
+		rangeEntries)
+	if err != nil {
+		return nil, err
+	}
+	stats.MetadataRanges = len(manifest.MetadataRanges)
+	for _, r := range manifest.MetadataRanges {
+		stats.MetadataEntries += r.Entries
+	}
+
+	fileNums, err := cloneBlockFiles(pdb.store, destPath, cfg.CopyOnWrite,
+		&stats.CloneStats)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileNum := range fileNums {
+		chunks, size, err := hashBlockFileChunks(
+			blockFilePath(destPath, fileNum), chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		manifest.BlockFiles = append(manifest.BlockFiles, DumpBlockFile{
+			FileNum: fileNum,
+			Size:    size,
+			Chunks:  chunks,
+		})
+	}
+
+	// Write the manifest last so an interrupted dump is not mistaken for a
+	// complete one.
+	data, err := json.MarshalIndent(&manifest, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	manifestPath := filepath.Join(destPath, dumpManifestName)
+	if err := os.WriteFile(manifestPath, data, 0600); err != nil {
+		return nil, err
+	}
+	manifestHash := sha256.Sum256(data)
+	stats.ManifestHash = hex.EncodeToString(manifestHash[:])
+	return stats, nil
+}
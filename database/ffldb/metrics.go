@@ -0,0 +1,46 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"github.com/btcsuite/btcd/metrics"
+)
+
+var (
+	// blockFileReadBytes counts the bytes read from the flat block files.
+	blockFileReadBytes = metrics.NewCounter(
+		"btcd_ffldb_block_file_read_bytes_total",
+		"Bytes read from the flat block files.")
+
+	// blockFileReads counts the reads of blocks and block regions from the
+	// flat block files.
+	blockFileReads = metrics.NewCounter(
+		"btcd_ffldb_block_file_reads_total",
+		"Reads of blocks and block regions from the flat block files.")
+
+	// blockFileWriteBytes counts the bytes written to the flat block
+	// files.
+	blockFileWriteBytes = metrics.NewCounter(
+		"btcd_ffldb_block_file_write_bytes_total",
+		"Bytes written to the flat block files.")
+
+	// blockFileWrites counts the blocks written to the flat block files.
+	blockFileWrites = metrics.NewCounter(
+		"btcd_ffldb_block_file_writes_total",
+		"Blocks written to the flat block files.")
+
+	// commitDuration measures how long committing write transactions
+	// takes, including writing their blocks and flushing the cache when
+	// needed.
+	commitDuration = metrics.NewHistogram(
+		"btcd_ffldb_commit_duration_seconds",
+		"Time taken to commit database write transactions.", nil)
+
+	// cacheFlushDuration measures how long flushing the write cache to
+	// leveldb takes.
+	cacheFlushDuration = metrics.NewHistogram(
+		"btcd_ffldb_cache_flush_duration_seconds",
+		"Time taken to flush the database write cache to leveldb.", nil)
+)
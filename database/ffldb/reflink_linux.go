@@ -0,0 +1,43 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package ffldb
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request which makes the destination file share
+// all of the extents of the source file on file systems such as btrfs and xfs.
+const ficlone = 0x40049409
+
+// reflinkFile creates destPath as a copy-on-write clone of srcPath.  An error
+// is returned when the file system does not support reflinks, in which case
+// destPath does not exist on return.
+func reflinkFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0600)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dest.Fd(), ficlone,
+		src.Fd())
+	if errno != 0 {
+		dest.Close()
+		os.Remove(destPath)
+		return errno
+	}
+	return dest.Close()
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package ffldb
+
+import "errors"
+
+// reflinkFile returns an error since copy-on-write file clones are only
+// supported on linux.
+func reflinkFile(srcPath, destPath string) error {
+	return errors.New("reflinks are not supported on this platform")
+}
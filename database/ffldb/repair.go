@@ -0,0 +1,509 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of repairing a damaged database from a
+// dump created by Dump.
+
+package ffldb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/syndtr/goleveldb/leveldb"
+	ldberrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// repairBatchSize is the maximum number of metadata entries that are
+	// written to the rebuilt metadata database in a single batch.
+	repairBatchSize = 10000
+)
+
+// RepairStats describes the work done by Repair.
+type RepairStats struct {
+	// CheckedChunks is the number of block file chunks that were verified
+	// against the dump.
+	CheckedChunks int
+
+	// DamagedChunks is the number of block file chunks that were restored
+	// from the dump.
+	DamagedChunks int
+
+	// RestoredFiles is the number of missing block files that were
+	// restored from the dump.
+	RestoredFiles int
+
+	// FetchedBytes is the number of bytes of block data that were fetched
+	// from the dump.
+	FetchedBytes int64
+
+	// DamagedRanges is the number of metadata key ranges that were
+	// restored from the dump.
+	DamagedRanges int
+
+	// RestoredEntries is the number of metadata entries that were restored
+	// from the dump.
+	RestoredEntries int
+
+	// RolledBack is set when the damaged metadata had diverged from the
+	// dump so the entire metadata was restored, which rolls the database
+	// back to the state of the dump.
+	RolledBack bool
+}
+
+// repairLdbOptions returns the options the metadata database is opened with.
+// Strict checking is enabled so all corruption encountered while reading is
+// reported.
+func repairLdbOptions() *opt.Options {
+	return &opt.Options{
+		Strict:      opt.StrictAll,
+		Compression: opt.NoCompression,
+		Filter:      filter.NewBloomFilter(10),
+	}
+}
+
+// openMetadataForRepair opens the metadata database at the passed path and
+// falls back to recovering it from its table files when the manifest of the
+// leveldb database itself is corrupted.  A missing metadata database is
+// created empty.
+func openMetadataForRepair(metadataDbPath string) (*leveldb.DB, error) {
+	ldb, err := leveldb.OpenFile(metadataDbPath, repairLdbOptions())
+	if ldberrors.IsCorrupted(err) {
+		log.Warnf("Recovering corrupted metadata database: %v", err)
+		ldb, err = leveldb.RecoverFile(metadataDbPath, repairLdbOptions())
+	}
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	return ldb, nil
+}
+
+// readWriteCursor returns the write cursor position stored in the passed
+// metadata database.  False is returned when it is missing or damaged.
+func readWriteCursor(ldb *leveldb.DB) (uint32, uint32, bool) {
+	writeRow, err := ldb.Get(bucketizedKey(metadataBucketID,
+		writeLocKeyName), nil)
+	if err != nil || len(writeRow) != 12 {
+		return 0, 0, false
+	}
+	fileNum, offset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return 0, 0, false
+	}
+	return fileNum, offset, true
+}
+
+// fetchMetadataRange fetches the key range file of the passed range from the
+// dump and ensures it matches the hash of the manifest.
+func fetchMetadataRange(src DumpSource, r *DumpMetadataRange) ([]byte, error) {
+	maxSize := r.Size
+	if maxSize == 0 {
+		maxSize = maxDumpRangeSize
+	}
+	data, err := src.ReadFile(r.File, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	if hex.EncodeToString(hash[:]) != r.Hash {
+		return nil, fmt.Errorf("metadata range file %s of the dump "+
+			"does not match the manifest", r.File)
+	}
+	return data, nil
+}
+
+// rebuildMetadata writes a new metadata database next to the one at the
+// passed path which consists of the entries of the ranges of the dump that
+// are flagged for restoration and the entries of all other ranges of the
+// existing database, and then replaces the existing database with it.
+// Rebuilding the database, rather than patching it in place, ensures no
+// entries of damaged ranges that can't be read anymore are left behind.
+func rebuildMetadata(ldb *leveldb.DB, metadataDbPath string,
+	manifest *DumpManifest, restore []bool, src DumpSource,
+	stats *RepairStats) error {
+
+	rebuildPath := metadataDbPath + ".repair"
+	if err := os.RemoveAll(rebuildPath); err != nil {
+		return err
+	}
+	newLdb, err := leveldb.OpenFile(rebuildPath, repairLdbOptions())
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+
+	batch := new(leveldb.Batch)
+	put := func(key, value []byte) error {
+		batch.Put(key, value)
+		if batch.Len() < repairBatchSize {
+			return nil
+		}
+		err := newLdb.Write(batch, nil)
+		batch.Reset()
+		return err
+	}
+	err = func() error {
+		for i := range manifest.MetadataRanges {
+			r := &manifest.MetadataRanges[i]
+			if restore[i] {
+				data, err := fetchMetadataRange(src, r)
+				if err != nil {
+					return err
+				}
+				err = readDumpEntries(data, func(key, value []byte) error {
+					stats.RestoredEntries++
+					return put(key, value)
+				})
+				if err != nil {
+					return fmt.Errorf("invalid metadata range "+
+						"file %s: %v", r.File, err)
+				}
+				continue
+			}
+
+			start, end, err := r.bounds()
+			if err != nil {
+				return err
+			}
+			iter := ldb.NewIterator(&util.Range{Start: start,
+				Limit: end}, nil)
+			for iter.Next() {
+				if err := put(iter.Key(), iter.Value()); err != nil {
+					iter.Release()
+					return err
+				}
+			}
+			iter.Release()
+			if err := iter.Error(); err != nil {
+				return err
+			}
+		}
+		return newLdb.Write(batch, &opt.WriteOptions{Sync: true})
+	}()
+	if closeErr := newLdb.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(rebuildPath)
+		return err
+	}
+
+	// Swap the rebuilt database into place.  The damaged one is only
+	// removed once the rebuilt one is in place so a failure in between
+	// leaves a database that can be repaired again.
+	if err := ldb.Close(); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	damagedPath := metadataDbPath + ".damaged"
+	if err := os.RemoveAll(damagedPath); err != nil {
+		return err
+	}
+	if err := os.Rename(metadataDbPath, damagedPath); err != nil {
+		return err
+	}
+	if err := os.Rename(rebuildPath, metadataDbPath); err != nil {
+		return err
+	}
+	return os.RemoveAll(damagedPath)
+}
+
+// repairMetadata identifies the damaged key ranges of the metadata database of
+// the database at dbPath and restores them from the dump.
+//
+// Ranges are damaged when reading them reports corruption, which leveldb
+// detects by the checksums of its blocks.  Ranges which can be read but differ
+// from the dump are not damaged, they changed since the dump was made.  Only
+// the damaged ranges are restored when the metadata has not changed since the
+// dump was made.  Otherwise, restoring individual ranges of an older state
+// would leave the metadata inconsistent, so the metadata is restored in full,
+// which rolls the database back to the state of the dump.  The metadata is
+// restored in full regardless of its state when rollBack is set.
+func repairMetadata(dbPath string, manifest *DumpManifest, src DumpSource,
+	rollBack bool, stats *RepairStats) error {
+
+	metadataDbPath := filepath.Join(dbPath, metadataDbName)
+	ldb, err := openMetadataForRepair(metadataDbPath)
+	if err != nil {
+		return err
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			ldb.Close()
+		}
+	}()
+
+	fileNum, offset, cursorOK := readWriteCursor(ldb)
+	if !cursorOK {
+		log.Warnf("The write cursor of the metadata is missing or " +
+			"damaged")
+	}
+	damaged := !cursorOK || rollBack
+	diverged := !cursorOK || rollBack || fileNum != manifest.WriteFileNum ||
+		offset != manifest.WriteOffset
+
+	restore := make([]bool, len(manifest.MetadataRanges))
+	for i := range manifest.MetadataRanges {
+		r := &manifest.MetadataRanges[i]
+		start, end, err := r.bounds()
+		if err != nil {
+			return fmt.Errorf("invalid metadata range in dump "+
+				"manifest: %v", err)
+		}
+		_, hash, err := metadataRangeDigest(ldb, start, end)
+		switch {
+		case err != nil:
+			log.Warnf("Metadata range %d is damaged: %v", i, err)
+			restore[i] = true
+			damaged = true
+
+		case hash != r.Hash:
+			diverged = true
+		}
+	}
+	if !damaged {
+		return nil
+	}
+
+	if diverged {
+		if !rollBack {
+			log.Warnf("The damaged metadata has changed since " +
+				"the dump was made -- rolling the database " +
+				"back to the state of the dump")
+		}
+		for i := range restore {
+			restore[i] = true
+		}
+		stats.RolledBack = true
+	}
+	for _, r := range restore {
+		if r {
+			stats.DamagedRanges++
+		}
+	}
+
+	closed = true
+	return rebuildMetadata(ldb, metadataDbPath, manifest, restore, src,
+		stats)
+}
+
+// fetchBlockFileChunk fetches the passed chunk of a block file from the dump
+// and ensures it matches the hash of the manifest.
+func fetchBlockFileChunk(src DumpSource, fileNum uint32, offset, size int64,
+	wantHash string) ([]byte, error) {
+
+	name := fmt.Sprintf(blockFilenameTemplate, fileNum)
+	data, err := src.ReadFileRange(name, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	if hex.EncodeToString(hash[:]) != wantHash {
+		return nil, fmt.Errorf("block file %s of the dump does not "+
+			"match the manifest at offset %d", name, offset)
+	}
+	return data, nil
+}
+
+// repairBlockFile verifies the chunks of the passed block file of the dump
+// against the local copy at filePath and restores the damaged ones in place.
+// The local file is created when it doesn't exist.  Only the extent of the
+// file that is part of the dump is verified since block files are only ever
+// appended to.
+func repairBlockFile(filePath string, manifest *DumpManifest,
+	bf *DumpBlockFile, src DumpSource, stats *RepairStats) error {
+
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, manifest.ChunkSize)
+	var repaired bool
+	for i, wantHash := range bf.Chunks {
+		offset := int64(i) * manifest.ChunkSize
+		size := manifest.ChunkSize
+		if offset+size > bf.Size {
+			size = bf.Size - offset
+		}
+		stats.CheckedChunks++
+
+		chunk := buf[:size]
+		n, err := f.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if int64(n) == size {
+			hash := sha256.Sum256(chunk)
+			if hex.EncodeToString(hash[:]) == wantHash {
+				continue
+			}
+		}
+
+		log.Debugf("Restoring %d bytes at offset %d of block file %d",
+			size, offset, bf.FileNum)
+		data, err := fetchBlockFileChunk(src, bf.FileNum, offset, size,
+			wantHash)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(data, offset); err != nil {
+			return err
+		}
+		stats.DamagedChunks++
+		stats.FetchedBytes += size
+		repaired = true
+	}
+	if !repaired {
+		return nil
+	}
+	return f.Sync()
+}
+
+// repairBlockFiles verifies the block files of the database at dbPath against
+// the dump and restores the damaged chunks as well as missing files.  Files
+// before the first local block file have been pruned and are only restored
+// when restorePruned is set.
+func repairBlockFiles(dbPath string, manifest *DumpManifest, src DumpSource,
+	restorePruned bool, stats *RepairStats) error {
+
+	firstFile, _, _, err := scanBlockFiles(dbPath)
+	if err != nil {
+		return err
+	}
+	for i := range manifest.BlockFiles {
+		bf := &manifest.BlockFiles[i]
+		filePath := blockFilePath(dbPath, bf.FileNum)
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			if firstFile != -1 && int(bf.FileNum) < firstFile &&
+				!restorePruned {
+
+				continue
+			}
+			log.Infof("Restoring missing block file %d", bf.FileNum)
+			stats.RestoredFiles++
+		}
+		if err := repairBlockFile(filePath, manifest, bf, src, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockDataMissing returns whether block data the metadata of the database at
+// dbPath refers to is missing after the write position of the dump, which
+// means it was written after the dump was made and can't be restored from it.
+func blockDataMissing(dbPath string, manifest *DumpManifest) (bool, error) {
+	ldb, err := openMetadataForRepair(filepath.Join(dbPath, metadataDbName))
+	if err != nil {
+		return false, err
+	}
+	fileNum, offset, cursorOK := readWriteCursor(ldb)
+	ldb.Close()
+	if !cursorOK {
+		return false, nil
+	}
+
+	for num := manifest.WriteFileNum; num <= fileNum; num++ {
+		fi, err := os.Stat(blockFilePath(dbPath, num))
+		if os.IsNotExist(err) {
+			// The current write file doesn't exist yet when the
+			// cursor is at its start.
+			if num == fileNum && offset == 0 {
+				return false, nil
+			}
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if num == fileNum && fi.Size() < int64(offset) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Repair repairs the database at dbPath for the passed network, which must not
+// be open, from the dump provided by the passed source, which must have been
+// created by Dump.  Only the parts of the dump which are damaged or missing in
+// the database are fetched, so it is much cheaper than restoring the entire
+// dump, in particular when the dump is fetched over the network.
+//
+// The chunks of the block files that are part of the dump are verified against
+// the hashes of the manifest and the damaged ones are patched in place.  Block
+// files are only ever appended to, so their data is the same as in the dump
+// regardless of how far the database progressed since the dump was made.
+// Block files that were pruned since are not restored.
+//
+// The metadata is verified by key range.  See repairMetadata for the details
+// of which ranges are restored.  The database is also rolled back to the state
+// of the dump when block data written after the dump was made is missing since
+// it can't be restored from the dump.  When the database is rolled back, block
+// data written since the dump is discarded when the database is opened next,
+// and pruned block files that are referenced by the restored metadata are
+// restored as well.
+func Repair(dbPath string, network wire.BitcoinNet, src DumpSource) (*RepairStats, error) {
+	manifest, err := ReadDumpManifest(src)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Network != network {
+		return nil, fmt.Errorf("dump is for network %v, not %v",
+			manifest.Network, network)
+	}
+	for i := range manifest.MetadataRanges {
+		file := manifest.MetadataRanges[i].File
+		if path.IsAbs(file) || path.Clean(file) != file ||
+			strings.HasPrefix(file, "..") {
+
+			return nil, fmt.Errorf("invalid metadata range file %q "+
+				"in dump manifest", file)
+		}
+	}
+
+	stats := &RepairStats{}
+	err = repairMetadata(dbPath, manifest, src, false, stats)
+	if err != nil {
+		return nil, err
+	}
+	err = repairBlockFiles(dbPath, manifest, src, stats.RolledBack, stats)
+	if err != nil {
+		return nil, err
+	}
+	if stats.RolledBack {
+		return stats, nil
+	}
+
+	// Block data written after the dump was made can't be restored, so
+	// the database is rolled back to the state of the dump when such data
+	// is missing.
+	missing, err := blockDataMissing(dbPath, manifest)
+	if err != nil || !missing {
+		return stats, err
+	}
+	log.Warnf("Block data written since the dump was made is missing -- " +
+		"rolling the database back to the state of the dump")
+	err = repairMetadata(dbPath, manifest, src, true, stats)
+	if err != nil {
+		return nil, err
+	}
+	err = repairBlockFiles(dbPath, manifest, src, true, stats)
+	if err != nil {
+		return nil, err
+	}
+	stats.RolledBack = true
+	return stats, nil
+}
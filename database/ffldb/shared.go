@@ -0,0 +1,397 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of the optional read-only shared block
+// store that blocks are referenced from instead of being written again.
+
+package ffldb
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// sharedFileFlag is set in the block file number of the locations of
+	// blocks which are stored in the shared block store rather than in the
+	// flat files of the database.  The number of block files never gets
+	// anywhere near this value, so it can't clash with a local file.
+	sharedFileFlag uint32 = 1 << 31
+
+	// sharedRescanInterval is the minimum amount of time between scans of
+	// the shared block store for blocks appended to it since the previous
+	// scan.  Scans are triggered by blocks which are not in the shared
+	// index, so this keeps blocks past the split height from causing a scan
+	// each.
+	sharedRescanInterval = time.Minute
+
+	// sharedRecordHeaderSize is the number of bytes read from the start of
+	// each block record while scanning the shared block store.  It covers
+	// the network and block length that precede a serialized block as well
+	// as the block header.
+	sharedRecordHeaderSize = 8 + blockHdrSize
+)
+
+var (
+	// sharedIdxBucketName is the name of the bucket that maps the hashes of
+	// the blocks in the shared block store to their locations in its flat
+	// files.
+	sharedIdxBucketName = []byte("ffldb-sharedblockidx")
+
+	// sharedScanKeyName is the name of the key that houses the position the
+	// next scan of the shared block store starts from.
+	sharedScanKeyName = []byte("ffldb-sharedscan")
+)
+
+// SharedStoreConfig houses the settings for the optional read-only shared
+// block store.
+//
+// A network which shares its history with another one up to a split height can
+// reference the block files of a database of the other network instead of
+// storing its own copy of the common blocks.  Blocks that are stored while the
+// shared block store is configured are looked up in it first, and only blocks
+// which are not found there, or differ from the copy found there, are written
+// to the flat files of the database.  The shared block store is never written
+// to.
+//
+// The database the shared block store belongs to must not be pruned, and the
+// shared block store has to be configured every time the database is opened
+// afterwards since blocks referenced from it can't be read otherwise.
+type SharedStoreConfig struct {
+	// Path is the path of the directory that holds the flat block files of
+	// the shared block store, which is the path of the database the files
+	// belong to.  An empty path disables the shared block store.
+	Path string
+}
+
+// sharedStore houses the state of the shared block store.  It is only modified
+// by write transactions.
+type sharedStore struct {
+	// store reads the blocks from the flat files of the shared block store.
+	// Its network is the network detected in the block records.
+	store *blockStore
+
+	// lastScan is the time of the most recent scan of the flat files.
+	lastScan time.Time
+}
+
+// sharedScanCursor is the position the next scan of the shared block store
+// starts from.
+type sharedScanCursor struct {
+	fileNum uint32
+	offset  uint32
+	network wire.BitcoinNet
+	path    string
+}
+
+// serializeSharedScanCursor returns the serialization of the passed cursor.
+func serializeSharedScanCursor(c *sharedScanCursor) []byte {
+	// The serialized scan cursor format is:
+	//
+	//  [0:4]   Block file (4 bytes)
+	//  [4:8]   File offset (4 bytes)
+	//  [8:12]  Network (4 bytes)
+	//  [12:]   Path of the shared block store
+	serialized := make([]byte, 12+len(c.path))
+	byteOrder.PutUint32(serialized[0:4], c.fileNum)
+	byteOrder.PutUint32(serialized[4:8], c.offset)
+	byteOrder.PutUint32(serialized[8:12], uint32(c.network))
+	copy(serialized[12:], c.path)
+	return serialized
+}
+
+// deserializeSharedScanCursor deserializes the passed serialized cursor.
+func deserializeSharedScanCursor(serialized []byte) (*sharedScanCursor, error) {
+	if len(serialized) < 12 {
+		str := "malformed shared block store scan cursor"
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return &sharedScanCursor{
+		fileNum: byteOrder.Uint32(serialized[0:4]),
+		offset:  byteOrder.Uint32(serialized[4:8]),
+		network: wire.BitcoinNet(byteOrder.Uint32(serialized[8:12])),
+		path:    string(serialized[12:]),
+	}, nil
+}
+
+// ConfigureSharedStore sets the shared block store configuration for the
+// passed database, which must have been opened with this driver.  The flat
+// files of the shared block store are scanned for blocks that were added to
+// them since the previous scan, which can take a while the first time it is
+// configured.  See SharedStoreConfig for details.
+func ConfigureSharedStore(idb database.DB, cfg SharedStoreConfig) error {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Path == "" {
+		pdb.writeLock.Lock()
+		pdb.store.shared.close()
+		pdb.store.shared = nil
+		pdb.writeLock.Unlock()
+		return nil
+	}
+
+	path, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return err
+	}
+	basePath, err := filepath.Abs(pdb.store.basePath)
+	if err != nil {
+		return err
+	}
+	if path == basePath {
+		return fmt.Errorf("the shared block store %s is the database "+
+			"itself", path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("the shared block store %s is not a "+
+			"directory", path)
+	}
+
+	store, err := newBlockStore(path, 0)
+	if err != nil {
+		return err
+	}
+	shared := &sharedStore{store: store}
+
+	return pdb.Update(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		tx.db.store.shared.close()
+		tx.db.store.shared = shared
+
+		cursor, err := shared.loadCursor(tx, path)
+		if err != nil {
+			return err
+		}
+		return shared.scan(tx, cursor)
+	})
+}
+
+// loadCursor returns the position the next scan of the shared block store
+// starts from and sets the network of the shared block store to the one
+// detected by the previous scans.  The index of the shared block store is
+// reset when it was built for a different path.
+func (ss *sharedStore) loadCursor(tx *transaction, path string) (*sharedScanCursor, error) {
+	if serialized := tx.metaBucket.Get(sharedScanKeyName); serialized != nil {
+		cursor, err := deserializeSharedScanCursor(serialized)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.path == path {
+			ss.store.network = cursor.network
+			return cursor, nil
+		}
+
+		log.Infof("Shared block store moved from %s to %s, rebuilding "+
+			"its index", cursor.path, path)
+		err = tx.metaBucket.DeleteBucket(sharedIdxBucketName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.metaBucket.CreateBucketIfNotExists(sharedIdxBucketName); err != nil {
+		return nil, err
+	}
+	first, _, _, err := scanBlockFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &sharedScanCursor{path: path}
+	if first > 0 {
+		cursor.fileNum = uint32(first)
+	}
+	return cursor, nil
+}
+
+// scan adds the blocks appended to the flat files of the shared block store
+// since the position of the passed cursor to the index of the shared block
+// store and saves the position the next scan starts from.
+//
+// Only the start of each block record is read, so the scan is cheap compared
+// to reading the blocks.  A record which is incomplete at the end of the most
+// recent file is picked up by a later scan once it has been written in full.
+func (ss *sharedStore) scan(tx *transaction, cursor *sharedScanCursor) error {
+	ss.lastScan = time.Now()
+
+	_, last, _, err := scanBlockFiles(ss.store.basePath)
+	if err != nil {
+		return err
+	}
+	if last < 0 || cursor.fileNum > uint32(last) {
+		return nil
+	}
+
+	idxBucket := tx.metaBucket.Bucket(sharedIdxBucketName)
+	var added int
+	for ; ; cursor.fileNum, cursor.offset = cursor.fileNum+1, 0 {
+		n, err := ss.scanFile(idxBucket, cursor)
+		if err != nil {
+			return err
+		}
+		added += n
+
+		if cursor.fileNum == uint32(last) {
+			break
+		}
+	}
+	ss.store.network = cursor.network
+
+	if added > 0 {
+		log.Infof("Indexed %d blocks of the shared block store %s", added,
+			ss.store.basePath)
+	}
+	return tx.metaBucket.Put(sharedScanKeyName,
+		serializeSharedScanCursor(cursor))
+}
+
+// scanFile adds the blocks stored in the flat file of the passed cursor from
+// its offset onwards to the index of the shared block store and advances the
+// cursor past them.  It returns the number of blocks added.
+func (ss *sharedStore) scanFile(idxBucket database.Bucket, cursor *sharedScanCursor) (int, error) {
+	file, err := os.Open(blockFilePath(ss.store.basePath, cursor.fileNum))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	fileLen := fi.Size()
+
+	var added int
+	var record [sharedRecordHeaderSize]byte
+	for int64(cursor.offset)+sharedRecordHeaderSize <= fileLen {
+		_, err := file.ReadAt(record[:], int64(cursor.offset))
+		if err != nil && err != io.EOF {
+			str := fmt.Sprintf("failed to read shared block file %d, "+
+				"offset %d: %v", cursor.fileNum, cursor.offset, err)
+			return added, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+
+		// The network of the shared block store is the network of its
+		// first block.  Data for any other network, or records that are
+		// not written in full yet, end the usable part of the file.
+		network := wire.BitcoinNet(byteOrder.Uint32(record[0:4]))
+		if cursor.network == 0 {
+			cursor.network = network
+		}
+		blockLen := int64(byteOrder.Uint32(record[4:8]))
+		recordLen := 8 + blockLen + 4
+		if network != cursor.network || blockLen < blockHdrSize ||
+			int64(cursor.offset)+recordLen > fileLen {
+
+			break
+		}
+
+		hash := chainhash.DoubleHashH(record[8:])
+		loc := blockLocation{
+			blockFileNum: cursor.fileNum,
+			fileOffset:   cursor.offset,
+			blockLen:     uint32(recordLen),
+		}
+		if err := idxBucket.Put(hash[:], serializeBlockLoc(loc)); err != nil {
+			return added, err
+		}
+		cursor.offset += uint32(recordLen)
+		added++
+	}
+	return added, nil
+}
+
+// close closes the open flat files of the shared block store.  It is safe to
+// call on a nil shared block store.
+func (ss *sharedStore) close() {
+	if ss == nil {
+		return
+	}
+	store := ss.store
+	store.obfMutex.Lock()
+	for _, blockFile := range store.openBlockFiles {
+		_ = blockFile.file.Close()
+	}
+	store.openBlockFiles = make(map[uint32]*lockableFile)
+	store.openBlocksLRU.Init()
+	store.fileNumToLRUElem = make(map[uint32]*list.Element)
+	store.obfMutex.Unlock()
+}
+
+// sharedBlockLoc returns the location of the block with the passed hash and
+// serialized bytes in the shared block store, or nil when the shared block
+// store is not configured or doesn't hold an identical copy of the block.  The
+// returned location is flagged as a shared location and can be stored in the
+// block index in place of writing the block to the flat files.
+func (tx *transaction) sharedBlockLoc(hash *chainhash.Hash, blockBytes []byte) (*blockLocation, error) {
+	ss := tx.db.store.shared
+	if ss == nil {
+		return nil, nil
+	}
+
+	idxBucket := tx.metaBucket.Bucket(sharedIdxBucketName)
+	serializedLoc := idxBucket.Get(hash[:])
+	if serializedLoc == nil && time.Since(ss.lastScan) >= sharedRescanInterval {
+		cursor, err := deserializeSharedScanCursor(
+			tx.metaBucket.Get(sharedScanKeyName))
+		if err != nil {
+			return nil, err
+		}
+		if err := ss.scan(tx, cursor); err != nil {
+			return nil, err
+		}
+		serializedLoc = idxBucket.Get(hash[:])
+	}
+	if serializedLoc == nil {
+		return nil, nil
+	}
+
+	// Only reference the copy in the shared block store when it can be
+	// read back and is identical to the block being stored.  The block is
+	// written to the flat files of the database otherwise.
+	loc := deserializeBlockLoc(serializedLoc)
+	sharedBytes, err := ss.store.readBlock(hash, loc)
+	if err != nil {
+		log.Warnf("Unable to read block %s from the shared block store: "+
+			"%v", hash, err)
+		return nil, nil
+	}
+	if !bytes.Equal(sharedBytes, blockBytes) {
+		return nil, nil
+	}
+
+	loc.blockFileNum |= sharedFileFlag
+	return &loc, nil
+}
+
+// sharedLoc returns the block store that holds the block at the passed flagged
+// location along with its location in the flat files of that store.
+func (s *blockStore) sharedLoc(loc blockLocation) (*blockStore, blockLocation, error) {
+	if s.shared == nil {
+		str := fmt.Sprintf("block at offset %d of shared block file %d "+
+			"can't be read without a shared block store", loc.fileOffset,
+			loc.blockFileNum&^sharedFileFlag)
+		return nil, loc, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+	loc.blockFileNum &^= sharedFileFlag
+	return s.shared.store, loc, nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+// Status describes the write lock and the cache of a database at a point in
+// time.  It is meant for diagnosing stalled writers.
+type Status struct {
+	// Writer is the id of the goroutine which started the active write
+	// transaction and therefore holds the write lock, or zero when there
+	// is no active write transaction.  It matches the id goroutine stack
+	// dumps identify the goroutine by.
+	Writer uint64
+
+	// WriteLocked is the time the write lock was acquired by the active
+	// write transaction.  It is the zero time when there is none.
+	WriteLocked time.Time
+
+	// CachedKeys and CachedRemoves are the number of keys which are
+	// written to and removed from the metadata in the cache and are not
+	// flushed yet.
+	CachedKeys    int
+	CachedRemoves int
+
+	// CacheSize is the size of the cached changes in bytes and
+	// MaxCacheSize the size the cache is flushed at.
+	CacheSize    uint64
+	MaxCacheSize uint64
+
+	// LastFlush is the time the cache was last flushed.
+	LastFlush time.Time
+}
+
+// DBStatus returns the status of the passed database, which must have been
+// opened with this driver.  Unlike the other functions of the database, it
+// doesn't wait for the write lock, so it returns even when a writer is stuck.
+//
+// This function is safe for concurrent access.
+func DBStatus(idb database.DB) (*Status, error) {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		MaxCacheSize: pdb.cache.maxSize,
+		LastFlush:    time.Unix(0, atomic.LoadInt64(&pdb.cache.flushed)),
+	}
+	if writer := atomic.LoadUint64(&pdb.writerID); writer != 0 {
+		status.Writer = writer
+		status.WriteLocked = time.Unix(0,
+			atomic.LoadInt64(&pdb.writeLocked))
+	}
+
+	// The cache lock is only held briefly by writers to swap the cached
+	// treaps, so it doesn't block behind a stuck writer.
+	pdb.cache.cacheLock.RLock()
+	cachedKeys := pdb.cache.cachedKeys
+	cachedRemove := pdb.cache.cachedRemove
+	pdb.cache.cacheLock.RUnlock()
+
+	status.CachedKeys = cachedKeys.Len()
+	status.CachedRemoves = cachedRemove.Len()
+	status.CacheSize = cachedKeys.Size() + cachedRemove.Size()
+	return status, nil
+}
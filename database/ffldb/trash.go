@@ -0,0 +1,612 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of the optional trash directory that
+// pruned block files are moved into instead of being removed immediately.
+
+package ffldb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// trashDirName is the name of the directory, relative to the database
+	// path, that pruned block files are moved into when the trash is
+	// enabled.
+	trashDirName = "trash"
+
+	// trashIdxExtension is the extension of the index files kept next to
+	// the trashed block files.  Each index file lists the hashes and
+	// locations of the blocks in the trashed file of the same number.
+	trashIdxExtension = ".idx"
+
+	// trashIdxEntrySize is the size of each entry of a trash index file,
+	// which is the block hash followed by the serialized block location.
+	trashIdxEntrySize = chainhash.HashSize + blockLocSize
+)
+
+// TrashConfig houses the settings for the optional block file trash.  When
+// the trash is enabled, block files removed by PruneBlocks are moved into a
+// trash directory and only permanently deleted once they are older than the
+// retention window or the trash grows beyond its maximum size.  Files that
+// are still in the trash can be restored with RestoreTrashedFiles.
+type TrashConfig struct {
+	// Retention is how long pruned block files are kept in the trash.  A
+	// value of zero disables the trash so pruned files are deleted
+	// immediately.
+	Retention time.Duration
+
+	// MaxSize is the maximum total size in bytes of the files kept in the
+	// trash.  The oldest files are permanently deleted first when it is
+	// exceeded.  A value of zero means no size limit is imposed.
+	MaxSize uint64
+}
+
+// TrashedFile describes a pruned block file that is held in the trash.
+type TrashedFile struct {
+	// FileNum is the number of the block file.
+	FileNum uint32
+
+	// Size is the size of the file in bytes.
+	Size int64
+
+	// TrashedAt is the time the file was moved into the trash.
+	TrashedAt time.Time
+}
+
+// trashedBlock identifies a block in a pruned block file by its hash and its
+// location in the file.
+type trashedBlock struct {
+	hash chainhash.Hash
+	loc  blockLocation
+}
+
+// trashDir returns the path of the trash directory for the block store.
+func (s *blockStore) trashDir() string {
+	return filepath.Join(s.basePath, trashDirName)
+}
+
+// trashIdxPath returns the path of the index file of the trashed block file
+// with the passed number.
+func (s *blockStore) trashIdxPath(fileNum uint32) string {
+	filePath := blockFilePath(s.trashDir(), fileNum)
+	return strings.TrimSuffix(filePath, blockFileExtension) +
+		trashIdxExtension
+}
+
+// writeTrashIdx writes the index file listing the passed blocks for the
+// trashed block file with the passed number.
+func (s *blockStore) writeTrashIdx(fileNum uint32, blocks []trashedBlock) error {
+	serialized := make([]byte, 0, len(blocks)*trashIdxEntrySize)
+	for _, block := range blocks {
+		serialized = append(serialized, block.hash[:]...)
+		serialized = append(serialized, serializeBlockLoc(block.loc)...)
+	}
+	return os.WriteFile(s.trashIdxPath(fileNum), serialized, 0600)
+}
+
+// readTrashIdx reads the index file of the trashed block file with the passed
+// number.
+func (s *blockStore) readTrashIdx(fileNum uint32) ([]trashedBlock, error) {
+	serialized, err := os.ReadFile(s.trashIdxPath(fileNum))
+	if err != nil {
+		return nil, err
+	}
+	if len(serialized)%trashIdxEntrySize != 0 {
+		return nil, fmt.Errorf("trash index of block file %d has an "+
+			"invalid size of %d bytes", fileNum, len(serialized))
+	}
+
+	blocks := make([]trashedBlock, 0, len(serialized)/trashIdxEntrySize)
+	for offset := 0; offset < len(serialized); offset += trashIdxEntrySize {
+		var block trashedBlock
+		copy(block.hash[:], serialized[offset:])
+		block.loc = deserializeBlockLoc(
+			serialized[offset+chainhash.HashSize:])
+		if block.loc.blockFileNum != fileNum {
+			return nil, fmt.Errorf("trash index of block file %d "+
+				"references block file %d", fileNum,
+				block.loc.blockFileNum)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// loadTrashIdx loads the index files of all trashed block files into the map
+// from the hashes of the trashed blocks to their locations unless it is
+// already loaded.  Block files trashed by versions that did not write index
+// files are not included.
+//
+// This function MUST be called with the trash index lock held.
+func (s *blockStore) loadTrashIdx() error {
+	if s.trashIdx != nil {
+		return nil
+	}
+
+	trashed, err := s.trashedFiles()
+	if err != nil {
+		return err
+	}
+	trashIdx := make(map[chainhash.Hash]blockLocation)
+	for _, file := range trashed {
+		blocks, err := s.readTrashIdx(file.FileNum)
+		if os.IsNotExist(err) {
+			log.Debugf("Trashed block file %d has no index",
+				file.FileNum)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			trashIdx[block.hash] = block.loc
+		}
+	}
+	s.trashIdx = trashIdx
+	return nil
+}
+
+// removeTrashIdx removes the index file of the block file with the passed
+// number, which was permanently deleted or restored from the trash, along with
+// the entries of its blocks from the loaded trash index.
+func (s *blockStore) removeTrashIdx(fileNum uint32) error {
+	s.trashIdxMtx.Lock()
+	for hash, loc := range s.trashIdx {
+		if loc.blockFileNum == fileNum {
+			delete(s.trashIdx, hash)
+		}
+	}
+	s.trashIdxMtx.Unlock()
+
+	err := os.Remove(s.trashIdxPath(fileNum))
+	if err != nil && !os.IsNotExist(err) {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return nil
+}
+
+// pruneFile removes the block file for the passed flat file number as the
+// result of pruning.  The file is moved into the trash when it is enabled and
+// deleted outright otherwise.  The passed blocks contained in the file are
+// recorded in the index of the trash so they can be fetched from it.
+func (s *blockStore) pruneFile(fileNum uint32, blocks []trashedBlock) error {
+	if s.trash.Retention == 0 {
+		return s.deleteFileFunc(fileNum)
+	}
+
+	trashDir := s.trashDir()
+	if err := os.MkdirAll(trashDir, 0700); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	// Write the index before moving the file so every trashed file the
+	// index is missing for was trashed by an older version.
+	if err := s.writeTrashIdx(fileNum, blocks); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	filePath := blockFilePath(s.basePath, fileNum)
+	trashPath := blockFilePath(trashDir, fileNum)
+	if err := os.Rename(filePath, trashPath); err != nil {
+		os.Remove(s.trashIdxPath(fileNum))
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	s.trashIdxMtx.Lock()
+	if s.trashIdx != nil {
+		for _, block := range blocks {
+			s.trashIdx[block.hash] = block.loc
+		}
+	}
+	s.trashIdxMtx.Unlock()
+
+	// The modification time of the trashed file is used to track when it
+	// was moved into the trash so it can be expired later.
+	now := time.Now()
+	if err := os.Chtimes(trashPath, now, now); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	log.Debugf("Moved pruned block file %d to the trash", fileNum)
+	return nil
+}
+
+// trashedFiles returns all of the block files currently held in the trash
+// ordered from the oldest to the most recently trashed file.
+func (s *blockStore) trashedFiles() ([]TrashedFile, error) {
+	files, err := filepath.Glob(filepath.Join(s.trashDir(),
+		"*"+blockFileExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	trashed := make([]TrashedFile, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), blockFileExtension)
+		fileNum, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			log.Warnf("Ignoring unexpected file %q in the trash", file)
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, TrashedFile{
+			FileNum:   uint32(fileNum),
+			Size:      fi.Size(),
+			TrashedAt: fi.ModTime(),
+		})
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		if trashed[i].TrashedAt.Equal(trashed[j].TrashedAt) {
+			return trashed[i].FileNum < trashed[j].FileNum
+		}
+		return trashed[i].TrashedAt.Before(trashed[j].TrashedAt)
+	})
+
+	return trashed, nil
+}
+
+// purgeTrash permanently deletes the trashed block files that are past the
+// retention window as well as the oldest files needed to bring the trash back
+// under its maximum size.
+func (s *blockStore) purgeTrash(now time.Time) error {
+	if s.trash.Retention == 0 {
+		return nil
+	}
+
+	trashed, err := s.trashedFiles()
+	if err != nil {
+		return err
+	}
+
+	var totalSize uint64
+	for _, file := range trashed {
+		totalSize += uint64(file.Size)
+	}
+
+	for _, file := range trashed {
+		expired := now.Sub(file.TrashedAt) > s.trash.Retention
+		overQuota := s.trash.MaxSize != 0 && totalSize > s.trash.MaxSize
+		if !expired && !overQuota {
+			break
+		}
+
+		filePath := blockFilePath(s.trashDir(), file.FileNum)
+		if err := os.Remove(filePath); err != nil {
+			return makeDbErr(database.ErrDriverSpecific, err.Error(),
+				err)
+		}
+		totalSize -= uint64(file.Size)
+		if err := s.removeTrashIdx(file.FileNum); err != nil {
+			return err
+		}
+
+		log.Debugf("Permanently deleted trashed block file %d",
+			file.FileNum)
+	}
+
+	return nil
+}
+
+// indexBlockFile scans the passed block file for all of the blocks it
+// contains and adds their locations to the block index.  It returns the
+// hashes of the blocks that were indexed.
+func indexBlockFile(tx *transaction, filePath string, fileNum uint32,
+	network wire.BitcoinNet) ([]chainhash.Hash, error) {
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each block in the file is stored as:
+	//  <network><block length><serialized block><checksum>
+	var hashes []chainhash.Hash
+	var offset uint32
+	for uint64(offset)+12 <= uint64(len(data)) {
+		blockNet := byteOrder.Uint32(data[offset : offset+4])
+		if blockNet != uint32(network) {
+			return nil, fmt.Errorf("block file %d contains a block "+
+				"for network %x at offset %d instead of %x",
+				fileNum, blockNet, offset, uint32(network))
+		}
+		blockLen := byteOrder.Uint32(data[offset+4 : offset+8])
+		fullLen := uint64(blockLen) + 12
+		if uint64(offset)+fullLen > uint64(len(data)) ||
+			blockLen < wire.MaxBlockHeaderPayload {
+
+			return nil, fmt.Errorf("block file %d contains a "+
+				"truncated block at offset %d", fileNum, offset)
+		}
+
+		record := data[offset : uint64(offset)+fullLen]
+		checksumOffset := len(record) - 4
+		wantChecksum := binary.BigEndian.Uint32(record[checksumOffset:])
+		gotChecksum := crc32.Checksum(record[:checksumOffset], castagnoli)
+		if gotChecksum != wantChecksum {
+			return nil, fmt.Errorf("block file %d contains a block "+
+				"with a bad checksum at offset %d", fileNum, offset)
+		}
+
+		header := record[8 : 8+wire.MaxBlockHeaderPayload]
+		hash := chainhash.DoubleHashH(header)
+		loc := blockLocation{
+			blockFileNum: fileNum,
+			fileOffset:   offset,
+			blockLen:     uint32(fullLen),
+		}
+		err := tx.putBlockLoc(&hash, serializeBlockLoc(loc))
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+
+		offset += uint32(fullLen)
+	}
+
+	return hashes, nil
+}
+
+// toFFLDB returns the underlying ffldb instance for the passed database or an
+// error when it was not created by this driver.
+func toFFLDB(idb database.DB) (*db, error) {
+	pdb, ok := idb.(*db)
+	if !ok {
+		return nil, fmt.Errorf("database of type %s is not an %s "+
+			"database", idb.Type(), dbType)
+	}
+	return pdb, nil
+}
+
+// ConfigureTrash sets the trash configuration for the passed database, which
+// must have been opened with this driver.  See TrashConfig for details.
+func ConfigureTrash(idb database.DB, cfg TrashConfig) error {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return err
+	}
+
+	// The trash configuration is only accessed by write transactions, so
+	// hold the write lock while modifying it.
+	pdb.writeLock.Lock()
+	pdb.store.trash = cfg
+	err = pdb.store.purgeTrash(time.Now())
+	pdb.writeLock.Unlock()
+	return err
+}
+
+// TrashedFiles returns the block files currently held in the trash of the
+// passed database ordered from the oldest to the most recently trashed file.
+func TrashedFiles(idb database.DB) ([]TrashedFile, error) {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return nil, err
+	}
+
+	pdb.writeLock.Lock()
+	defer pdb.writeLock.Unlock()
+	return pdb.store.trashedFiles()
+}
+
+// RestoreTrashedFiles moves the trashed block files with the given numbers
+// back into the database and adds the blocks they contain back into the
+// block index so they can be fetched again.  All files in the trash are
+// restored when no file numbers are provided.  It returns the hashes of the
+// restored blocks.
+//
+// Note that only the raw block data is restored.  Any data callers derived
+// from the blocks and removed during pruning, such as spend journals, is not
+// recovered.
+func RestoreTrashedFiles(idb database.DB, fileNums []uint32) ([]chainhash.Hash, error) {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files are moved out of the trash while the transaction that adds
+	// their blocks to the index is still pending, so keep track of the
+	// moves in order to put the files back should any of the files fail to
+	// restore or the transaction fail to commit.  Otherwise the files would
+	// be left live without any index entries referencing them.
+	type restoredFile struct {
+		fileNum   uint32
+		trashPath string
+		filePath  string
+	}
+	var moved []restoredFile
+	var restored []chainhash.Hash
+	err = idb.Update(func(dbTx database.Tx) error {
+		tx := dbTx.(*transaction)
+		store := pdb.store
+
+		trashed, err := store.trashedFiles()
+		if err != nil {
+			return err
+		}
+		inTrash := make(map[uint32]struct{}, len(trashed))
+		for _, file := range trashed {
+			inTrash[file.FileNum] = struct{}{}
+		}
+		if len(fileNums) == 0 {
+			for _, file := range trashed {
+				fileNums = append(fileNums, file.FileNum)
+			}
+		}
+
+		store.writeCursor.RLock()
+		curFileNum := store.writeCursor.curFileNum
+		store.writeCursor.RUnlock()
+
+		for _, fileNum := range fileNums {
+			if _, ok := inTrash[fileNum]; !ok {
+				str := fmt.Sprintf("block file %d is not in the "+
+					"trash", fileNum)
+				return makeDbErr(database.ErrDriverSpecific, str, nil)
+			}
+			if fileNum >= curFileNum {
+				str := fmt.Sprintf("block file %d can't be "+
+					"restored since it is not older than the "+
+					"current write file %d", fileNum, curFileNum)
+				return makeDbErr(database.ErrDriverSpecific, str, nil)
+			}
+
+			filePath := blockFilePath(store.basePath, fileNum)
+			if fileExists(filePath) {
+				str := fmt.Sprintf("block file %d already exists",
+					fileNum)
+				return makeDbErr(database.ErrDriverSpecific, str, nil)
+			}
+
+			trashPath := blockFilePath(store.trashDir(), fileNum)
+			hashes, err := indexBlockFile(tx, trashPath, fileNum,
+				store.network)
+			if err != nil {
+				return makeDbErr(database.ErrCorruption, err.Error(),
+					nil)
+			}
+			if err := os.Rename(trashPath, filePath); err != nil {
+				return makeDbErr(database.ErrDriverSpecific,
+					err.Error(), err)
+			}
+			moved = append(moved, restoredFile{fileNum, trashPath,
+				filePath})
+			restored = append(restored, hashes...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		for i := len(moved) - 1; i >= 0; i-- {
+			file := moved[i]
+			if rerr := os.Rename(file.filePath, file.trashPath); rerr != nil {
+				log.Errorf("Unable to move block file %s back to "+
+					"the trash: %v", file.filePath, rerr)
+			}
+		}
+		return nil, err
+	}
+
+	for _, file := range moved {
+		if err := pdb.store.removeTrashIdx(file.fileNum); err != nil {
+			log.Errorf("Unable to remove the trash index of block "+
+				"file %d: %v", file.fileNum, err)
+		}
+		log.Infof("Restored block file %s from the trash",
+			filepath.Base(file.filePath))
+	}
+	log.Infof("Restored %d blocks from the trash", len(restored))
+
+	return restored, nil
+}
+
+// readTrashedBlock reads the block at the passed location from the passed
+// trashed block file and returns its serialized bytes after ensuring it is
+// intact and has the passed hash.
+func readTrashedBlock(filePath string, loc blockLocation,
+	network wire.BitcoinNet, hash *chainhash.Hash) ([]byte, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// The block is stored as:
+	//  <network><block length><serialized block><checksum>
+	if loc.blockLen < 12+wire.MaxBlockHeaderPayload ||
+		loc.blockLen > 12+wire.MaxBlockPayload {
+
+		return nil, fmt.Errorf("invalid length %d of block %v in "+
+			"block file %d", loc.blockLen, hash, loc.blockFileNum)
+	}
+	record := make([]byte, loc.blockLen)
+	if _, err := file.ReadAt(record, int64(loc.fileOffset)); err != nil {
+		return nil, err
+	}
+	blockNet := byteOrder.Uint32(record[0:4])
+	blockLen := byteOrder.Uint32(record[4:8])
+	checksumOffset := len(record) - 4
+	switch {
+	case blockNet != uint32(network):
+		return nil, fmt.Errorf("block file %d contains a block for "+
+			"network %x at offset %d instead of %x",
+			loc.blockFileNum, blockNet, loc.fileOffset,
+			uint32(network))
+	case blockLen != loc.blockLen-12:
+		return nil, fmt.Errorf("block file %d contains a block of "+
+			"length %d at offset %d instead of %d",
+			loc.blockFileNum, blockLen, loc.fileOffset,
+			loc.blockLen-12)
+	case crc32.Checksum(record[:checksumOffset], castagnoli) !=
+		binary.BigEndian.Uint32(record[checksumOffset:]):
+
+		return nil, fmt.Errorf("block file %d contains a block with "+
+			"a bad checksum at offset %d", loc.blockFileNum,
+			loc.fileOffset)
+	}
+	header := record[8 : 8+wire.MaxBlockHeaderPayload]
+	if chainhash.DoubleHashH(header) != *hash {
+		return nil, fmt.Errorf("block file %d contains a different "+
+			"block than %v at offset %d", loc.blockFileNum, hash,
+			loc.fileOffset)
+	}
+	return record[8:checksumOffset], nil
+}
+
+// FetchTrashedBlock returns the serialized bytes of the block with the passed
+// hash when it was pruned from the passed database but the block file that
+// contained it is still held in the trash.  The block is looked up in the
+// index of the trash, so only the block itself is read.  ErrBlockNotFound is
+// returned when no trashed file contains the block.
+//
+// Unlike RestoreTrashedFiles, the block is not added back into the database.
+func FetchTrashedBlock(idb database.DB, hash *chainhash.Hash) ([]byte, error) {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return nil, err
+	}
+
+	store := pdb.store
+	store.trashIdxMtx.Lock()
+	err = store.loadTrashIdx()
+	loc, ok := store.trashIdx[*hash]
+	store.trashIdxMtx.Unlock()
+	if err != nil {
+		return nil, makeDbErr(database.ErrCorruption, err.Error(), nil)
+	}
+	if ok {
+		trashPath := blockFilePath(store.trashDir(), loc.blockFileNum)
+		blockBytes, err := readTrashedBlock(trashPath, loc,
+			store.network, hash)
+		switch {
+		case err == nil:
+			return blockBytes, nil
+
+		// The file was permanently deleted from the trash after the
+		// block was looked up.
+		case os.IsNotExist(err):
+
+		default:
+			return nil, makeDbErr(database.ErrCorruption, err.Error(),
+				nil)
+		}
+	}
+
+	str := fmt.Sprintf("block %s is not in the trash", hash)
+	return nil, makeDbErr(database.ErrBlockNotFound, str, nil)
+}
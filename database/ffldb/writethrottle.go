@@ -0,0 +1,176 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of the optional back-pressure applied
+// to writes of the flat block files.
+
+package ffldb
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// maxThrottleDelay is the maximum amount of time a single write
+	// transaction is delayed due to slow syncs of the block files.
+	maxThrottleDelay = 5 * time.Second
+)
+
+// WriteThrottleConfig houses the settings used to apply back-pressure to writes
+// of the flat block files.  Without it, block data accumulates as dirty pages
+// in the OS page cache until the kernel decides to write it back, which on
+// slow disks can stall the whole system for a long time during initial block
+// download.  Syncing the files at a regular cadence keeps the amount of dirty
+// data bounded, and measuring how long those syncs take allows writes to be
+// slowed down when the disk can't keep up.
+type WriteThrottleConfig struct {
+	// SyncInterval is the number of bytes written to the block files after
+	// which the current block file is synced to disk.  A value of zero
+	// disables the periodic syncs, which also disables the latency based
+	// throttling.
+	SyncInterval uint64
+
+	// TargetSyncLatency is the sync latency above which subsequent writes
+	// are delayed.  The delay is proportional to the amount the observed
+	// latency exceeds the target.  A value of zero disables the latency
+	// based throttling.
+	TargetSyncLatency time.Duration
+
+	// MaxWriteRate is the maximum average number of bytes per second that
+	// are written to the block files.  A value of zero means the write
+	// rate is not limited.
+	MaxWriteRate uint64
+}
+
+// writeThrottle houses the state used to apply back-pressure to writes of the
+// flat block files.  It is only accessed by write transactions.
+type writeThrottle struct {
+	cfg WriteThrottleConfig
+
+	// unsyncedBytes is the number of bytes written since the last sync.
+	unsyncedBytes uint64
+
+	// delay is the delay applied to each write transaction due to the
+	// most recently observed sync latency.
+	delay time.Duration
+
+	// rateStart and rateBytes track the start of the current rate limiting
+	// window and the number of bytes written within it.
+	rateStart time.Time
+	rateBytes uint64
+
+	// sleep is the function used to delay writes.  It is a field so the
+	// tests can observe the delays without actually waiting.
+	sleep func(time.Duration)
+}
+
+// enabled returns whether or not any back-pressure is configured.
+func (t *writeThrottle) enabled() bool {
+	return t.cfg.SyncInterval != 0 || t.cfg.MaxWriteRate != 0
+}
+
+// calcDelay returns the delay to apply to subsequent writes given the latency
+// of the most recent sync.  The delay grows with the amount the latency
+// exceeds the target, is reduced again once syncs complete within the target,
+// and never exceeds maxThrottleDelay.
+func (t *writeThrottle) calcDelay(latency time.Duration) time.Duration {
+	target := t.cfg.TargetSyncLatency
+	if target == 0 {
+		return 0
+	}
+	if latency <= target {
+		return t.delay / 2
+	}
+
+	delay := t.delay + (latency - target)
+	if delay > maxThrottleDelay {
+		delay = maxThrottleDelay
+	}
+	return delay
+}
+
+// rateDelay records that the passed number of bytes were written and returns
+// how long the writer needs to wait in order to stay within the configured
+// maximum write rate.
+func (t *writeThrottle) rateDelay(numBytes uint64, now time.Time) time.Duration {
+	if t.cfg.MaxWriteRate == 0 {
+		return 0
+	}
+
+	// Start a new window when the previous one is idle for a while so
+	// that a long pause doesn't allow a large burst of writes.
+	elapsed := now.Sub(t.rateStart)
+	if t.rateStart.IsZero() || elapsed > 10*time.Second {
+		t.rateStart = now
+		t.rateBytes = 0
+		elapsed = 0
+	}
+	t.rateBytes += numBytes
+
+	expected := time.Duration(float64(t.rateBytes) /
+		float64(t.cfg.MaxWriteRate) * float64(time.Second))
+	if expected <= elapsed {
+		return 0
+	}
+	return expected - elapsed
+}
+
+// throttleWrites applies the configured back-pressure after the passed number
+// of bytes were written to the block files.  It periodically syncs the current
+// block file and delays the caller when the syncs are slow or the maximum write
+// rate is exceeded.
+//
+// This function MUST only be called from a write transaction.
+func (s *blockStore) throttleWrites(numBytes uint64) {
+	t := &s.throttle
+	if !t.enabled() || numBytes == 0 {
+		return
+	}
+
+	var delay time.Duration
+	if t.cfg.SyncInterval != 0 {
+		t.unsyncedBytes += numBytes
+		if t.unsyncedBytes >= t.cfg.SyncInterval {
+			start := time.Now()
+			if err := s.syncBlocks(); err != nil {
+				log.Warnf("Unable to sync block files: %v", err)
+			}
+			latency := time.Since(start)
+			t.unsyncedBytes = 0
+
+			t.delay = t.calcDelay(latency)
+			if t.delay > 0 {
+				log.Debugf("Block file sync took %v, throttling "+
+					"writes by %v", latency, t.delay)
+			}
+		}
+		delay = t.delay
+	}
+
+	if rateDelay := t.rateDelay(numBytes, time.Now()); rateDelay > delay {
+		delay = rateDelay
+	}
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
+
+// ConfigureWriteThrottle sets the configuration used to apply back-pressure to
+// writes of the flat block files for the passed database, which must have been
+// opened with this driver.  See WriteThrottleConfig for details.
+func ConfigureWriteThrottle(idb database.DB, cfg WriteThrottleConfig) error {
+	pdb, err := toFFLDB(idb)
+	if err != nil {
+		return err
+	}
+
+	// The throttle state is only accessed by write transactions, so hold
+	// the write lock while modifying it.
+	pdb.writeLock.Lock()
+	pdb.store.throttle = writeThrottle{cfg: cfg, sleep: time.Sleep}
+	pdb.writeLock.Unlock()
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWriteThrottleDelay ensures the delay applied due to slow block file syncs
+// grows while syncs exceed the target latency, is capped, and decays once they
+// are fast again.
+func TestWriteThrottleDelay(t *testing.T) {
+	t.Parallel()
+
+	throttle := writeThrottle{cfg: WriteThrottleConfig{
+		SyncInterval:      1,
+		TargetSyncLatency: 100 * time.Millisecond,
+	}}
+
+	tests := []struct {
+		latency time.Duration
+		want    time.Duration
+	}{
+		{latency: 50 * time.Millisecond, want: 0},
+		{latency: 300 * time.Millisecond, want: 200 * time.Millisecond},
+		{latency: 400 * time.Millisecond, want: 500 * time.Millisecond},
+		{latency: 10 * time.Second, want: maxThrottleDelay},
+		{latency: 10 * time.Millisecond, want: maxThrottleDelay / 2},
+		{latency: 10 * time.Millisecond, want: maxThrottleDelay / 4},
+	}
+	for i, test := range tests {
+		throttle.delay = throttle.calcDelay(test.latency)
+		if throttle.delay != test.want {
+			t.Fatalf("#%d: unexpected delay for latency %v - got %v, "+
+				"want %v", i, test.latency, throttle.delay, test.want)
+		}
+	}
+
+	// No delay is ever applied without a target latency.
+	throttle.cfg.TargetSyncLatency = 0
+	if delay := throttle.calcDelay(time.Hour); delay != 0 {
+		t.Fatalf("unexpected delay without a target latency: %v", delay)
+	}
+}
+
+// TestWriteThrottleRate ensures writes are delayed as needed to stay within the
+// configured maximum write rate.
+func TestWriteThrottleRate(t *testing.T) {
+	t.Parallel()
+
+	throttle := writeThrottle{cfg: WriteThrottleConfig{
+		MaxWriteRate: 1000,
+	}}
+
+	start := time.Unix(1700000000, 0)
+	if delay := throttle.rateDelay(500, start); delay != 500*time.Millisecond {
+		t.Fatalf("unexpected delay for first write: %v", delay)
+	}
+
+	// Writing another 500 bytes a full second later is within the rate.
+	now := start.Add(time.Second)
+	if delay := throttle.rateDelay(500, now); delay != 0 {
+		t.Fatalf("unexpected delay for write within rate: %v", delay)
+	}
+
+	// Writing 2000 bytes immediately after requires waiting two seconds.
+	if delay := throttle.rateDelay(2000, now); delay != 2*time.Second {
+		t.Fatalf("unexpected delay for burst: %v", delay)
+	}
+
+	// A long idle period starts a new window.
+	now = now.Add(time.Minute)
+	if delay := throttle.rateDelay(100, now); delay != 100*time.Millisecond {
+		t.Fatalf("unexpected delay after idle period: %v", delay)
+	}
+}
@@ -212,6 +212,9 @@ type BlockRegion struct {
 // view of the database at the time it was created.  Transactions should not be
 // long running operations.
 type Tx interface {
+	// DB returns the database the transaction was started on.
+	DB() DB
+
 	// Metadata returns the top-most bucket for all metadata storage.
 	Metadata() Bucket
 
@@ -404,6 +407,22 @@ type Tx interface {
 	// implementations.
 	PruneBlocks(targetSize uint64) ([]chainhash.Hash, error)
 
+	// PruneBlocksBefore deletes the block storage that only holds blocks
+	// which were stored before the block with the given hash and returns
+	// the hashes of the deleted blocks.  The block itself and all blocks
+	// stored after it are kept, while some blocks stored before it may be
+	// kept as well depending on how the implementation groups blocks.
+	//
+	// Since blocks are only stored after their parent, pruning before a
+	// main chain block keeps all of its descendants.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrBlockNotFound if the block with the given hash does not exist
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	PruneBlocksBefore(hash *chainhash.Hash) ([]chainhash.Hash, error)
+
 	// BeenPruned returns if the block storage has ever been pruned.
 	//
 	// Implementation specific errors are possible.
@@ -455,6 +474,11 @@ type DB interface {
 	// transaction can be started at a time.  The call will block when
 	// starting a read-write transaction when one is already open.
 	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxReentrant if the calling goroutine already has an active
+	//     read-write transaction
+	//
 	// NOTE: The transaction must be closed by calling Rollback or Commit on
 	// it when it is no longer needed.  Failure to do so can result in
 	// unclaimed memory and/or inablity to close the database due to locks
@@ -465,6 +489,11 @@ type DB interface {
 	// read-only transaction.  Any errors returned from the user-supplied
 	// function are returned from this function.
 	//
+	// ErrTxReentrant is returned without invoking the function when the
+	// calling goroutine already has an active read-write transaction, since
+	// the new transaction would not see its uncommitted changes.  Use
+	// ViewNested to reuse the active transaction instead.
+	//
 	// Calling Rollback or Commit on the transaction passed to the
 	// user-supplied function will result in a panic.
 	View(fn func(tx Tx) error) error
@@ -475,6 +504,11 @@ type DB interface {
 	// returned from this function.  Otherwise, the transaction is committed
 	// when the user-supplied function returns a nil error.
 	//
+	// ErrTxReentrant is returned without invoking the function when the
+	// calling goroutine already has an active read-write transaction, since
+	// waiting for it would deadlock.  Use UpdateNested to reuse the active
+	// transaction instead.
+	//
 	// Calling Rollback or Commit on the transaction passed to the
 	// user-supplied function will result in a panic.
 	Update(fn func(tx Tx) error) error
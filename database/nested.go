@@ -0,0 +1,60 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database
+
+// checkNestedTx returns an error if the passed transaction was not started on
+// the passed database.
+func checkNestedTx(db DB, tx Tx) error {
+	if tx.DB() != db {
+		str := "transaction was started on a different database"
+		return makeError(ErrInvalid, str, nil)
+	}
+	return nil
+}
+
+// ViewNested invokes the passed function in the context of the passed
+// transaction when it is not nil, or in a new managed read-only transaction on
+// the database otherwise.
+//
+// This allows functions which read from the database to be called both on
+// their own and as part of a larger logical operation which already has an
+// active transaction, which is not possible with View since it refuses to
+// start a new transaction on a goroutine with an active read-write transaction.
+func ViewNested(db DB, tx Tx, fn func(tx Tx) error) error {
+	if tx == nil {
+		return db.View(fn)
+	}
+	if err := checkNestedTx(db, tx); err != nil {
+		return err
+	}
+	return fn(tx)
+}
+
+// UpdateNested invokes the passed function in the context of the passed
+// read-write transaction when it is not nil, or in a new managed read-write
+// transaction on the database otherwise.
+//
+// When a transaction is passed, it is neither committed nor rolled back, so
+// any changes made by the function only become visible when the caller which
+// owns the transaction commits it.  ErrTxNotWritable is returned when the
+// passed transaction is read-only.
+//
+// This allows functions which write to the database to be called both on their
+// own and as part of a larger logical operation which already has an active
+// transaction, which is not possible with Update since waiting for the active
+// transaction to finish would deadlock.
+func UpdateNested(db DB, tx Tx, fn func(tx Tx) error) error {
+	if tx == nil {
+		return db.Update(fn)
+	}
+	if err := checkNestedTx(db, tx); err != nil {
+		return err
+	}
+	if !tx.Metadata().Writable() {
+		str := "nested update requires a writable transaction"
+		return makeError(ErrTxNotWritable, str, nil)
+	}
+	return fn(tx)
+}
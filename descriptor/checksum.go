@@ -0,0 +1,120 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// inputCharset is the set of characters allowed in descriptors, in the
+	// order which determines the values they contribute to the checksum.
+	inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// checksumCharset is the set of characters of checksums.
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// checksumLength is the number of characters of a checksum.
+	checksumLength = 8
+)
+
+// checksumGenerator holds the generator coefficients of the BCH code of the
+// descriptor checksum.
+var checksumGenerator = [5]uint64{
+	0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd,
+}
+
+// polyMod updates the checksum state c with the 5-bit value val.
+func polyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	for i, g := range checksumGenerator {
+		if (c0>>uint(i))&1 != 0 {
+			c ^= g
+		}
+	}
+	return c
+}
+
+// Checksum returns the checksum of the passed descriptor, which must not
+// include a checksum already, as defined by BIP 380.
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(inputCharset, desc[i])
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character %q in descriptor",
+				desc[i])
+		}
+
+		// Every character contributes the lower 5 bits of its position
+		// in the charset, and the upper bits of each group of three
+		// characters are combined into an additional value.
+		c = polyMod(c, pos&31)
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			c = polyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polyMod(c, cls)
+	}
+	for i := 0; i < checksumLength; i++ {
+		c = polyMod(c, 0)
+	}
+	c ^= 1
+
+	var sum [checksumLength]byte
+	for i := range sum {
+		sum[i] = checksumCharset[(c>>(5*uint(7-i)))&31]
+	}
+	return string(sum[:]), nil
+}
+
+// AddChecksum returns the passed descriptor followed by its checksum.
+func AddChecksum(desc string) (string, error) {
+	sum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + sum, nil
+}
+
+// splitChecksum separates a descriptor from its checksum and validates the
+// checksum when present.  It returns an error when the checksum is missing
+// and required is set.
+func splitChecksum(desc string, required bool) (string, error) {
+	i := strings.IndexByte(desc, '#')
+	if i == -1 {
+		if required {
+			return "", fmt.Errorf("missing checksum")
+		}
+		return desc, nil
+	}
+
+	body, sum := desc[:i], desc[i+1:]
+	if strings.IndexByte(sum, '#') != -1 {
+		return "", fmt.Errorf("multiple '#' symbols")
+	}
+	if len(sum) != checksumLength {
+		return "", fmt.Errorf("expected %d character checksum, not %d "+
+			"characters", checksumLength, len(sum))
+	}
+	want, err := Checksum(body)
+	if err != nil {
+		return "", err
+	}
+	if sum != want {
+		return "", fmt.Errorf("provided checksum '%s' does not match "+
+			"computed checksum '%s'", sum, want)
+	}
+	return body, nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"testing"
+)
+
+// TestChecksum ensures descriptor checksums are computed and validated as
+// specified by the test vectors of BIP 380.
+func TestChecksum(t *testing.T) {
+	t.Parallel()
+
+	sum, err := Checksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatalf("Checksum: unexpected error: %v", err)
+	}
+	if sum != "89f8spxm" {
+		t.Fatalf("Checksum: got %q, want %q", sum, "89f8spxm")
+	}
+
+	tests := []struct {
+		desc     string
+		required bool
+		valid    bool
+	}{
+		{"raw(deadbeef)#89f8spxm", true, true},
+		{"raw(deadbeef)", false, true},
+		{"raw(deadbeef)", true, false},
+		{"raw(deadbeef)#", false, false},
+		{"raw(deadbeef)#89f8spxmx", false, false},
+		{"raw(deadbeef)#89f8spx", false, false},
+		{"raw(deadbeef)#89f8spxn", false, false},
+		{"raw(deedbeef)#89f8spxm", false, false},
+		{"raw(deadbeef)##9f8spxm", false, false},
+		{"raw(deadbeef)#89f8spxm#", false, false},
+	}
+	for _, test := range tests {
+		_, err := splitChecksum(test.desc, test.required)
+		if test.valid && err != nil {
+			t.Errorf("splitChecksum(%q): unexpected error: %v",
+				test.desc, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("splitChecksum(%q): expected error", test.desc)
+		}
+	}
+
+	if _, err := Checksum("raw(Ü)"); err == nil {
+		t.Errorf("Checksum: expected error for invalid character")
+	}
+}
@@ -0,0 +1,904 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// maxPubKeysPerMultiSig is the maximum number of keys of multi() and
+	// sortedmulti() expressions.
+	maxPubKeysPerMultiSig = txscript.MaxPubKeysPerMultiSig
+
+	// maxBareMultiSigKeys is the maximum number of keys of multi() and
+	// sortedmulti() expressions which aren't wrapped in sh() or wsh().
+	maxBareMultiSigKeys = 3
+
+	// maxTapTreeDepth is the maximum depth of the script tree of tr()
+	// expressions.
+	maxTapTreeDepth = txscript.ControlBlockMaxNodeCount
+)
+
+// ErrNoAddress is returned by Address for descriptors whose scripts don't have
+// an address, such as pk(), bare multi() and raw() scripts of a non-standard
+// type.
+var ErrNoAddress = errors.New("descriptor does not have a corresponding " +
+	"address")
+
+// scriptContext is the context a script expression is parsed in, which
+// determines the expressions and keys allowed in it.
+type scriptContext int
+
+const (
+	// contextTop is the context of the outermost expression.
+	contextTop scriptContext = iota
+
+	// contextP2SH is the context of the expression within sh().
+	contextP2SH
+
+	// contextP2WPKH is the context of the key within wpkh().
+	contextP2WPKH
+
+	// contextP2WSH is the context of the expression within wsh().
+	contextP2WSH
+
+	// contextP2TR is the context of the internal key and the leaf
+	// expressions of tr().
+	contextP2TR
+)
+
+// isSegwit returns whether the context is within a witness program, which
+// only allows compressed keys.
+func (c scriptContext) isSegwit() bool {
+	return c == contextP2WPKH || c == contextP2WSH || c == contextP2TR
+}
+
+// expr is a script expression of a descriptor.
+type expr interface {
+	// script returns the script of the expression with its keys derived
+	// at the passed index.
+	script(index uint32) ([]byte, error)
+
+	// string renders the expression.  Private keys are rendered as their
+	// public keys unless private is set.
+	string(private bool) string
+
+	// derivedString renders the expression with its keys derived at the
+	// passed index.
+	derivedString(index uint32) (string, error)
+
+	// keys returns the key expressions of the expression and the
+	// expressions it contains.
+	keys() []*keyExpr
+}
+
+// Descriptor is a parsed output descriptor, which describes the scripts of a
+// set of outputs.
+type Descriptor struct {
+	root   expr
+	params *chaincfg.Params
+}
+
+// Parse parses an output descriptor as defined by BIP 380 and the BIPs of the
+// script expressions it supports.  The checksum of the descriptor is validated
+// when present and required when requireChecksum is set.
+func Parse(desc string, requireChecksum bool, params *chaincfg.Params) (*Descriptor, error) {
+	body, err := splitChecksum(desc, requireChecksum)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Checksum(body); err != nil {
+		return nil, err
+	}
+
+	p := parser{params: params}
+	root, err := p.parseScript(body, contextTop)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{root: root, params: params}, nil
+}
+
+// String returns the descriptor with its private keys replaced by their
+// public keys followed by its checksum.
+func (d *Descriptor) String() string {
+	// The charset of rendered descriptors is always valid.
+	desc, _ := AddChecksum(d.root.string(false))
+	return desc
+}
+
+// PrivateString returns the descriptor including its private keys followed by
+// its checksum.
+func (d *Descriptor) PrivateString() string {
+	desc, _ := AddChecksum(d.root.string(true))
+	return desc
+}
+
+// DerivedString returns the descriptor with its keys derived at the passed
+// index followed by its checksum.  Derived keys are rendered as public keys
+// with the origin of their derivation.
+func (d *Descriptor) DerivedString(index uint32) (string, error) {
+	s, err := d.root.derivedString(index)
+	if err != nil {
+		return "", err
+	}
+	return AddChecksum(s)
+}
+
+// IsRange returns whether the scripts of the descriptor depend on the
+// derivation index.
+func (d *Descriptor) IsRange() bool {
+	for _, k := range d.root.keys() {
+		if k.isRange() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSolvable returns whether the descriptor contains the information needed
+// to sign for its scripts given the private keys, which is the case for all
+// descriptors except addr() and raw().
+func (d *Descriptor) IsSolvable() bool {
+	switch d.root.(type) {
+	case *addrExpr, *rawExpr:
+		return false
+	}
+	return true
+}
+
+// HasPrivateKeys returns whether the descriptor contains any private key.
+func (d *Descriptor) HasPrivateKeys() bool {
+	for _, k := range d.root.keys() {
+		if k.hasPrivateKey() {
+			return true
+		}
+	}
+	return false
+}
+
+// Script returns the output script of the descriptor at the passed derivation
+// index.  The index is ignored for descriptors which aren't ranged.
+func (d *Descriptor) Script(index uint32) ([]byte, error) {
+	return d.root.script(index)
+}
+
+// Address returns the address of the output script of the descriptor at the
+// passed derivation index.  ErrNoAddress is returned when the script doesn't
+// have an address.
+func (d *Descriptor) Address(index uint32) (btcutil.Address, error) {
+	if a, ok := d.root.(*addrExpr); ok {
+		return a.addr, nil
+	}
+
+	script, err := d.root.script(index)
+	if err != nil {
+		return nil, err
+	}
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy, txscript.ScriptHashTy,
+		txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy,
+		txscript.WitnessV1TaprootTy:
+
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, d.params)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 1 {
+			return addrs[0], nil
+		}
+	}
+	return nil, ErrNoAddress
+}
+
+// Expansion holds the scripts and keys of a descriptor at a derivation index
+// which are needed to sign for its output script.
+type Expansion struct {
+	// Script is the output script.
+	Script []byte
+
+	// RedeemScript is the script of sh() descriptors and WitnessScript the
+	// script of wsh() descriptors, including ones nested in sh().
+	RedeemScript  []byte
+	WitnessScript []byte
+
+	// Keys are the origins of the keys of the scripts for descriptors
+	// other than tr().
+	Keys []*KeyOrigin
+
+	// InternalKey is the origin of the internal key of tr() descriptors.
+	// The keys of the leaves of their script trees are not included.
+	InternalKey *KeyOrigin
+}
+
+// Expand returns the scripts and keys of the descriptor at the passed
+// derivation index.  The index is ignored for descriptors which aren't ranged.
+func (d *Descriptor) Expand(index uint32) (*Expansion, error) {
+	script, err := d.root.script(index)
+	if err != nil {
+		return nil, err
+	}
+	exp := &Expansion{Script: script}
+
+	if tr, ok := d.root.(*trExpr); ok {
+		exp.InternalKey, err = tr.internal.keyOrigin(index, true)
+		if err != nil {
+			return nil, err
+		}
+		return exp, nil
+	}
+
+	inner := d.root
+	if sh, ok := inner.(*shExpr); ok {
+		inner = sh.sub
+		if exp.RedeemScript, err = inner.script(index); err != nil {
+			return nil, err
+		}
+	}
+	if wsh, ok := inner.(*wshExpr); ok {
+		exp.WitnessScript, err = wsh.sub.script(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range d.root.keys() {
+		origin, err := k.keyOrigin(index, false)
+		if err != nil {
+			return nil, err
+		}
+		exp.Keys = append(exp.Keys, origin)
+	}
+	return exp, nil
+}
+
+// parser parses the expressions of a descriptor.
+type parser struct {
+	params *chaincfg.Params
+}
+
+// splitArgs splits the arguments of an expression at the commas which aren't
+// nested within other expressions, key origins or script trees.
+func splitArgs(s string) []string {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, s[start:])
+}
+
+// splitFunc splits an expression of the form name(args) into its name and
+// arguments.  It returns false when the expression isn't of that form.
+func splitFunc(s string) (string, string, bool) {
+	open := strings.IndexByte(s, '(')
+	if open == -1 || !strings.HasSuffix(s, ")") {
+		return "", "", false
+	}
+	return s[:open], s[open+1 : len(s)-1], true
+}
+
+// parseScript parses a script expression in the passed context.
+func (p *parser) parseScript(s string, ctx scriptContext) (expr, error) {
+	name, args, ok := splitFunc(s)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a valid descriptor function", s)
+	}
+
+	switch name {
+	case "pk":
+		key, err := p.parseSingleKeyArg(name, args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &pkExpr{key: key, tapscript: ctx == contextP2TR}, nil
+
+	case "pkh":
+		if ctx == contextP2TR {
+			return nil, fmt.Errorf("pkh() is not allowed in tapscript")
+		}
+		key, err := p.parseSingleKeyArg(name, args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &pkhExpr{key: key}, nil
+
+	case "wpkh":
+		if ctx != contextTop && ctx != contextP2SH {
+			return nil, fmt.Errorf("wpkh() is only allowed at top " +
+				"level or inside sh()")
+		}
+		key, err := p.parseSingleKeyArg(name, args, contextP2WPKH)
+		if err != nil {
+			return nil, err
+		}
+		return &wpkhExpr{key: key}, nil
+
+	case "sh":
+		if ctx != contextTop {
+			return nil, fmt.Errorf("sh() is only allowed at top level")
+		}
+		sub, err := p.parseScript(args, contextP2SH)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := sub.(*multiExpr); ok &&
+			m.scriptLen() > txscript.MaxScriptElementSize {
+
+			return nil, fmt.Errorf("P2SH script is too large, %d "+
+				"bytes is larger than %d bytes", m.scriptLen(),
+				txscript.MaxScriptElementSize)
+		}
+		return &shExpr{sub: sub}, nil
+
+	case "wsh":
+		if ctx != contextTop && ctx != contextP2SH {
+			return nil, fmt.Errorf("wsh() is only allowed at top " +
+				"level or inside sh()")
+		}
+		sub, err := p.parseScript(args, contextP2WSH)
+		if err != nil {
+			return nil, err
+		}
+		return &wshExpr{sub: sub}, nil
+
+	case "multi", "sortedmulti":
+		if ctx == contextP2TR {
+			return nil, fmt.Errorf("%s() is not allowed in "+
+				"tapscript", name)
+		}
+		return p.parseMulti(name, args, ctx)
+
+	case "tr":
+		if ctx != contextTop {
+			return nil, fmt.Errorf("tr() is only allowed at top level")
+		}
+		return p.parseTr(args)
+
+	case "addr":
+		if ctx != contextTop {
+			return nil, fmt.Errorf("addr() is only allowed at top " +
+				"level")
+		}
+		addr, err := btcutil.DecodeAddress(args, p.params)
+		if err != nil || !addr.IsForNet(p.params) {
+			return nil, fmt.Errorf("address '%s' is not valid", args)
+		}
+		return &addrExpr{addr: addr}, nil
+
+	case "raw":
+		if ctx != contextTop {
+			return nil, fmt.Errorf("raw() is only allowed at top " +
+				"level")
+		}
+		script, err := hex.DecodeString(args)
+		if err != nil {
+			return nil, fmt.Errorf("raw script '%s' is not hex", args)
+		}
+		return &rawExpr{raw: script}, nil
+	}
+	return nil, fmt.Errorf("'%s' is not a valid descriptor function", name)
+}
+
+// parseSingleKeyArg parses the single key argument of the named expression.
+func (p *parser) parseSingleKeyArg(name, args string, ctx scriptContext) (*keyExpr, error) {
+	if len(splitArgs(args)) != 1 {
+		return nil, fmt.Errorf("%s() takes a single key", name)
+	}
+	key, err := parseKey(args, ctx, p.params)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %v", name, err)
+	}
+	return key, nil
+}
+
+// parseMulti parses the arguments of a multi() or sortedmulti() expression.
+func (p *parser) parseMulti(name, args string, ctx scriptContext) (expr, error) {
+	parts := splitArgs(args)
+	threshold, err := strconv.Atoi(parts[0])
+	if err != nil || (len(parts[0]) > 1 && parts[0][0] == '0') {
+		return nil, fmt.Errorf("multi threshold '%s' is not valid",
+			parts[0])
+	}
+
+	m := &multiExpr{threshold: threshold, sorted: name == "sortedmulti"}
+	for _, s := range parts[1:] {
+		key, err := parseKey(s, ctx, p.params)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %v", name, err)
+		}
+		m.pubKeys = append(m.pubKeys, key)
+	}
+
+	n := len(m.pubKeys)
+	switch {
+	case n == 0 || n > maxPubKeysPerMultiSig:
+		return nil, fmt.Errorf("cannot have %d keys in multisig; must "+
+			"have between 1 and %d keys, inclusive", n,
+			maxPubKeysPerMultiSig)
+
+	case threshold < 1:
+		return nil, fmt.Errorf("multisig threshold cannot be %d, must "+
+			"be at least 1", threshold)
+
+	case threshold > n:
+		return nil, fmt.Errorf("multisig threshold cannot be larger "+
+			"than the number of keys; threshold is %d but only %d "+
+			"keys specified", threshold, n)
+
+	case ctx == contextTop && n > maxBareMultiSigKeys:
+		return nil, fmt.Errorf("cannot have %d pubkeys in bare "+
+			"multisig; only at most %d pubkeys", n,
+			maxBareMultiSigKeys)
+	}
+	return m, nil
+}
+
+// parseTr parses the arguments of a tr() expression.
+func (p *parser) parseTr(args string) (expr, error) {
+	parts := splitArgs(args)
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("tr() takes a key and an optional " +
+			"script tree")
+	}
+	internal, err := parseKey(parts[0], contextP2TR, p.params)
+	if err != nil {
+		return nil, fmt.Errorf("tr(): %v", err)
+	}
+
+	t := &trExpr{internal: internal}
+	if len(parts) == 2 {
+		t.tree, err = p.parseTapTree(parts[1], 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// parseTapTree parses the script tree of a tr() expression at the passed
+// depth.
+func (p *parser) parseTapTree(s string, depth int) (*tapTree, error) {
+	if depth > maxTapTreeDepth {
+		return nil, fmt.Errorf("tr() supports at most %d nesting "+
+			"levels", maxTapTreeDepth)
+	}
+	if !strings.HasPrefix(s, "{") {
+		leaf, err := p.parseScript(s, contextP2TR)
+		if err != nil {
+			return nil, err
+		}
+		return &tapTree{leaf: leaf}, nil
+	}
+
+	if !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("script tree '%s' is missing '}'", s)
+	}
+	parts := splitArgs(s[1 : len(s)-1])
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("script tree branches must have two " +
+			"children")
+	}
+	left, err := p.parseTapTree(parts[0], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.parseTapTree(parts[1], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return &tapTree{left: left, right: right}, nil
+}
+
+// pkExpr is a pk(KEY) expression, a pay-to-pubkey script or a checksig of
+// an x-only key in tapscript.
+type pkExpr struct {
+	key       *keyExpr
+	tapscript bool
+}
+
+func (e *pkExpr) script(index uint32) ([]byte, error) {
+	pubKey, err := e.key.key(index)
+	if err != nil {
+		return nil, err
+	}
+	var serialized []byte
+	if e.tapscript {
+		serialized = schnorr.SerializePubKey(pubKey)
+	} else if serialized, err = e.key.serialize(index); err != nil {
+		return nil, err
+	}
+	return txscript.NewScriptBuilder().AddData(serialized).
+		AddOp(txscript.OP_CHECKSIG).Script()
+}
+
+func (e *pkExpr) string(private bool) string {
+	return "pk(" + e.key.string(private) + ")"
+}
+
+func (e *pkExpr) derivedString(index uint32) (string, error) {
+	key, err := e.key.derivedString(index)
+	return "pk(" + key + ")", err
+}
+
+func (e *pkExpr) keys() []*keyExpr {
+	return []*keyExpr{e.key}
+}
+
+// pkhExpr is a pkh(KEY) expression, a pay-to-pubkey-hash script.
+type pkhExpr struct {
+	key *keyExpr
+}
+
+func (e *pkhExpr) script(index uint32) ([]byte, error) {
+	serialized, err := e.key.serialize(index)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(btcutil.Hash160(serialized)).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+		Script()
+}
+
+func (e *pkhExpr) string(private bool) string {
+	return "pkh(" + e.key.string(private) + ")"
+}
+
+func (e *pkhExpr) derivedString(index uint32) (string, error) {
+	key, err := e.key.derivedString(index)
+	return "pkh(" + key + ")", err
+}
+
+func (e *pkhExpr) keys() []*keyExpr {
+	return []*keyExpr{e.key}
+}
+
+// wpkhExpr is a wpkh(KEY) expression, a pay-to-witness-pubkey-hash script.
+type wpkhExpr struct {
+	key *keyExpr
+}
+
+func (e *wpkhExpr) script(index uint32) ([]byte, error) {
+	serialized, err := e.key.serialize(index)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(serialized)).Script()
+}
+
+func (e *wpkhExpr) string(private bool) string {
+	return "wpkh(" + e.key.string(private) + ")"
+}
+
+func (e *wpkhExpr) derivedString(index uint32) (string, error) {
+	key, err := e.key.derivedString(index)
+	return "wpkh(" + key + ")", err
+}
+
+func (e *wpkhExpr) keys() []*keyExpr {
+	return []*keyExpr{e.key}
+}
+
+// shExpr is a sh(SCRIPT) expression, a pay-to-script-hash script.
+type shExpr struct {
+	sub expr
+}
+
+func (e *shExpr) script(index uint32) ([]byte, error) {
+	redeemScript, err := e.sub.script(index)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(redeemScript)).
+		AddOp(txscript.OP_EQUAL).Script()
+}
+
+func (e *shExpr) string(private bool) string {
+	return "sh(" + e.sub.string(private) + ")"
+}
+
+func (e *shExpr) derivedString(index uint32) (string, error) {
+	sub, err := e.sub.derivedString(index)
+	return "sh(" + sub + ")", err
+}
+
+func (e *shExpr) keys() []*keyExpr {
+	return e.sub.keys()
+}
+
+// wshExpr is a wsh(SCRIPT) expression, a pay-to-witness-script-hash script.
+type wshExpr struct {
+	sub expr
+}
+
+func (e *wshExpr) script(index uint32) ([]byte, error) {
+	witnessScript, err := e.sub.script(index)
+	if err != nil {
+		return nil, err
+	}
+	hash := chainhash.HashB(witnessScript)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(hash).Script()
+}
+
+func (e *wshExpr) string(private bool) string {
+	return "wsh(" + e.sub.string(private) + ")"
+}
+
+func (e *wshExpr) derivedString(index uint32) (string, error) {
+	sub, err := e.sub.derivedString(index)
+	return "wsh(" + sub + ")", err
+}
+
+func (e *wshExpr) keys() []*keyExpr {
+	return e.sub.keys()
+}
+
+// multiExpr is a multi(k,KEY,...) or sortedmulti(k,KEY,...) expression, a
+// bare multisig script.  The keys of sortedmulti() are sorted by their
+// serialization in the script.
+type multiExpr struct {
+	threshold int
+	pubKeys   []*keyExpr
+	sorted    bool
+}
+
+// scriptLen returns the length of the script of the expression.
+func (e *multiExpr) scriptLen() int {
+	// OP_k <keys> OP_n OP_CHECKMULTISIG, where each compressed key and
+	// uncompressed key is pushed with a single byte data push.
+	n := 3
+	for _, k := range e.pubKeys {
+		if k.extKey == nil && !k.compressed {
+			n += 1 + 65
+			continue
+		}
+		n += 1 + 33
+	}
+	return n
+}
+
+func (e *multiExpr) script(index uint32) ([]byte, error) {
+	serialized := make([][]byte, 0, len(e.pubKeys))
+	for _, k := range e.pubKeys {
+		pubKey, err := k.serialize(index)
+		if err != nil {
+			return nil, err
+		}
+		serialized = append(serialized, pubKey)
+	}
+	if e.sorted {
+		sort.Slice(serialized, func(i, j int) bool {
+			return bytes.Compare(serialized[i], serialized[j]) < 0
+		})
+	}
+
+	builder := txscript.NewScriptBuilder().AddInt64(int64(e.threshold))
+	for _, pubKey := range serialized {
+		builder.AddData(pubKey)
+	}
+	return builder.AddInt64(int64(len(serialized))).
+		AddOp(txscript.OP_CHECKMULTISIG).Script()
+}
+
+// name returns the function name of the expression.
+func (e *multiExpr) name() string {
+	if e.sorted {
+		return "sortedmulti"
+	}
+	return "multi"
+}
+
+func (e *multiExpr) string(private bool) string {
+	args := make([]string, 0, len(e.pubKeys)+1)
+	args = append(args, strconv.Itoa(e.threshold))
+	for _, k := range e.pubKeys {
+		args = append(args, k.string(private))
+	}
+	return e.name() + "(" + strings.Join(args, ",") + ")"
+}
+
+func (e *multiExpr) derivedString(index uint32) (string, error) {
+	args := make([]string, 0, len(e.pubKeys)+1)
+	args = append(args, strconv.Itoa(e.threshold))
+	for _, k := range e.pubKeys {
+		key, err := k.derivedString(index)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, key)
+	}
+	return e.name() + "(" + strings.Join(args, ",") + ")", nil
+}
+
+func (e *multiExpr) keys() []*keyExpr {
+	return e.pubKeys
+}
+
+// tapTree is a node of the script tree of a tr() expression, which is either
+// a leaf script or a branch with two children.
+type tapTree struct {
+	leaf        expr
+	left, right *tapTree
+}
+
+// tapNode returns the taproot script tree node of the tree at the passed
+// derivation index.
+func (t *tapTree) tapNode(index uint32) (txscript.TapNode, error) {
+	if t.leaf != nil {
+		script, err := t.leaf.script(index)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.NewBaseTapLeaf(script), nil
+	}
+
+	left, err := t.left.tapNode(index)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.right.tapNode(index)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NewTapBranch(left, right), nil
+}
+
+// render renders the tree with the passed function for leaf scripts.
+func (t *tapTree) render(leafString func(expr) (string, error)) (string, error) {
+	if t.leaf != nil {
+		return leafString(t.leaf)
+	}
+	left, err := t.left.render(leafString)
+	if err != nil {
+		return "", err
+	}
+	right, err := t.right.render(leafString)
+	if err != nil {
+		return "", err
+	}
+	return "{" + left + "," + right + "}", nil
+}
+
+// keys returns the key expressions of the leaf scripts of the tree.
+func (t *tapTree) keys() []*keyExpr {
+	if t.leaf != nil {
+		return t.leaf.keys()
+	}
+	return append(t.left.keys(), t.right.keys()...)
+}
+
+// trExpr is a tr(KEY) or tr(KEY,TREE) expression, a pay-to-taproot script
+// with the internal key and the optional script tree.
+type trExpr struct {
+	internal *keyExpr
+	tree     *tapTree
+}
+
+func (e *trExpr) script(index uint32) ([]byte, error) {
+	internalKey, err := e.internal.key(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var rootHash []byte
+	if e.tree != nil {
+		node, err := e.tree.tapNode(index)
+		if err != nil {
+			return nil, err
+		}
+		hash := node.TapHash()
+		rootHash = hash[:]
+	}
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_1).
+		AddData(schnorr.SerializePubKey(outputKey)).Script()
+}
+
+func (e *trExpr) string(private bool) string {
+	s := "tr(" + e.internal.string(private)
+	if e.tree != nil {
+		// Rendering the expressions of leaves doesn't fail.
+		tree, _ := e.tree.render(func(leaf expr) (string, error) {
+			return leaf.string(private), nil
+		})
+		s += "," + tree
+	}
+	return s + ")"
+}
+
+func (e *trExpr) derivedString(index uint32) (string, error) {
+	internal, err := e.internal.derivedString(index)
+	if err != nil {
+		return "", err
+	}
+	s := "tr(" + internal
+	if e.tree != nil {
+		tree, err := e.tree.render(func(leaf expr) (string, error) {
+			return leaf.derivedString(index)
+		})
+		if err != nil {
+			return "", err
+		}
+		s += "," + tree
+	}
+	return s + ")", nil
+}
+
+func (e *trExpr) keys() []*keyExpr {
+	keys := []*keyExpr{e.internal}
+	if e.tree != nil {
+		keys = append(keys, e.tree.keys()...)
+	}
+	return keys
+}
+
+// addrExpr is an addr(ADDR) expression, the script paying to an address.
+type addrExpr struct {
+	addr btcutil.Address
+}
+
+func (e *addrExpr) script(uint32) ([]byte, error) {
+	return txscript.PayToAddrScript(e.addr)
+}
+
+func (e *addrExpr) string(bool) string {
+	return "addr(" + e.addr.EncodeAddress() + ")"
+}
+
+func (e *addrExpr) derivedString(uint32) (string, error) {
+	return e.string(false), nil
+}
+
+func (e *addrExpr) keys() []*keyExpr {
+	return nil
+}
+
+// rawExpr is a raw(HEX) expression, a script given in hex.
+type rawExpr struct {
+	raw []byte
+}
+
+func (e *rawExpr) script(uint32) ([]byte, error) {
+	return e.raw, nil
+}
+
+func (e *rawExpr) string(bool) string {
+	return "raw(" + hex.EncodeToString(e.raw) + ")"
+}
+
+func (e *rawExpr) derivedString(uint32) (string, error) {
+	return e.string(false), nil
+}
+
+func (e *rawExpr) keys() []*keyExpr {
+	return nil
+}
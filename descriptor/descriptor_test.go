@@ -0,0 +1,397 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// testXprv and testXpub are the master key of test vector 1 of BIP 32
+	// and testFingerprint its fingerprint.
+	testXprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPq" +
+		"jiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	testXpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGheP" +
+		"Y2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+	testFingerprint = "3442193e"
+
+	// testPubKey1 and testPubKey2 are the public keys of the private keys
+	// 1 and 2.
+	testPubKey1 = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815" +
+		"b16f81798"
+	testPubKey2 = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b" +
+		"95c709ee5"
+)
+
+// mustDecodeHex decodes a hex string and panics on error.
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestParseScripts ensures the scripts and addresses of descriptors match the
+// test vectors of the BIPs of their script expressions.
+func TestParseScripts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc   string
+		script string
+		addr   string
+	}{{
+		desc:   "pk(" + testPubKey1 + ")",
+		script: "21" + testPubKey1 + "ac",
+	}, {
+		desc:   "pkh(" + testPubKey2 + ")",
+		script: "76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac",
+		addr:   "1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP",
+	}, {
+		desc:   "wpkh(" + testPubKey1 + ")",
+		script: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		addr:   "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+	}, {
+		desc:   "sh(wpkh(" + testPubKey1 + "))",
+		script: "a914bcfeb728b584253d5f3f70bcb780e9ef218a68f487",
+		addr:   "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+	}, {
+		desc: "multi(1," + testPubKey2 + "," + testPubKey1 + ")",
+		script: "5121" + testPubKey2 + "21" + testPubKey1 +
+			"52ae",
+	}, {
+		desc: "sortedmulti(1," + testPubKey2 + "," + testPubKey1 + ")",
+		script: "5121" + testPubKey1 + "21" + testPubKey2 +
+			"52ae",
+	}, {
+		desc:   "tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+		script: "512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11",
+		addr:   "bc1pw74tdcrxlzn5r8z6ku2vztr86fgq0m245s72mjktf4afwzsf8ugs0gs8zu",
+	}, {
+		desc:   "tr(L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1)",
+		script: "512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11",
+		addr:   "bc1pw74tdcrxlzn5r8z6ku2vztr86fgq0m245s72mjktf4afwzsf8ugs0gs8zu",
+	}, {
+		desc:   "raw(deadbeef)",
+		script: "deadbeef",
+	}}
+
+	for _, test := range tests {
+		d, err := Parse(test.desc, false, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.desc, err)
+			continue
+		}
+		script, err := d.Script(0)
+		if err != nil {
+			t.Errorf("Script(%q): unexpected error: %v", test.desc, err)
+			continue
+		}
+		if got := hex.EncodeToString(script); got != test.script {
+			t.Errorf("Script(%q): got %s, want %s", test.desc, got,
+				test.script)
+		}
+
+		addr, err := d.Address(0)
+		switch {
+		case test.addr == "" && err != ErrNoAddress:
+			t.Errorf("Address(%q): got %v, want ErrNoAddress",
+				test.desc, err)
+		case test.addr != "" && err != nil:
+			t.Errorf("Address(%q): unexpected error: %v", test.desc,
+				err)
+		case test.addr != "" && addr.EncodeAddress() != test.addr:
+			t.Errorf("Address(%q): got %s, want %s", test.desc,
+				addr.EncodeAddress(), test.addr)
+		}
+	}
+}
+
+// TestParseRanged ensures ranged descriptors derive the keys of their scripts
+// at the requested index and render their derived form with key origins.
+func TestParseRanged(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	desc := "wpkh(" + testXprv + "/0h/*)"
+	d, err := Parse(desc, false, params)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if !d.IsRange() || !d.IsSolvable() || !d.HasPrivateKeys() {
+		t.Fatalf("unexpected properties: range %v, solvable %v, "+
+			"private keys %v", d.IsRange(), d.IsSolvable(),
+			d.HasPrivateKeys())
+	}
+
+	wantPublic := "wpkh(" + testXpub + "/0h/*)"
+	if got := d.String(); !strings.HasPrefix(got, wantPublic+"#") {
+		t.Fatalf("String: got %s, want %s with checksum", got,
+			wantPublic)
+	}
+	if got := d.PrivateString(); !strings.HasPrefix(got, desc+"#") {
+		t.Fatalf("PrivateString: got %s, want %s with checksum", got,
+			desc)
+	}
+
+	// Derive the key of index 7 directly to compare it with the one of
+	// the descriptor.
+	master, err := hdkeychain.NewKeyFromString(testXprv)
+	if err != nil {
+		t.Fatalf("NewKeyFromString: unexpected error: %v", err)
+	}
+	account, err := master.Derive(hdkeychain.HardenedKeyStart)
+	if err != nil {
+		t.Fatalf("Derive: unexpected error: %v", err)
+	}
+	child, err := account.Derive(7)
+	if err != nil {
+		t.Fatalf("Derive: unexpected error: %v", err)
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		t.Fatalf("ECPubKey: unexpected error: %v", err)
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pubKey.SerializeCompressed()), params)
+	if err != nil {
+		t.Fatalf("NewAddressWitnessPubKeyHash: unexpected error: %v", err)
+	}
+
+	got, err := d.Address(7)
+	if err != nil {
+		t.Fatalf("Address: unexpected error: %v", err)
+	}
+	if got.EncodeAddress() != addr.EncodeAddress() {
+		t.Fatalf("Address: got %s, want %s", got, addr)
+	}
+
+	derived, err := d.DerivedString(7)
+	if err != nil {
+		t.Fatalf("DerivedString: unexpected error: %v", err)
+	}
+	wantDerived := "wpkh([" + testFingerprint + "/0h/7]" +
+		hex.EncodeToString(pubKey.SerializeCompressed()) + ")#"
+	if !strings.HasPrefix(derived, wantDerived) {
+		t.Fatalf("DerivedString: got %s, want %s with checksum",
+			derived, wantDerived)
+	}
+
+	// Round trip the public form, which must keep the key origin and
+	// produce the same scripts.
+	withOrigin := "pkh([d34db33f/44'/0'/0']" + testXpub + "/1/*')"
+	if _, err := Parse(withOrigin, false, params); err == nil {
+		t.Fatalf("Parse: expected error for hardened derivation " +
+			"from a public key")
+	}
+	withOrigin = "pkh([D34DB33F/44'/0'/0']" + testXpub + "/1/*)"
+	d, err = Parse(withOrigin, false, params)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	if d.HasPrivateKeys() {
+		t.Fatalf("HasPrivateKeys: got true for public descriptor")
+	}
+	reparsed, err := Parse(d.String(), true, params)
+	if err != nil {
+		t.Fatalf("Parse(%q): unexpected error: %v", d.String(), err)
+	}
+	if !strings.HasPrefix(reparsed.String(), "pkh([d34db33f/44'/0'/0']") {
+		t.Fatalf("String: got %s, want lowercase origin",
+			reparsed.String())
+	}
+	for i := uint32(0); i < 3; i++ {
+		a, err := d.Script(i)
+		if err != nil {
+			t.Fatalf("Script: unexpected error: %v", err)
+		}
+		b, err := reparsed.Script(i)
+		if err != nil {
+			t.Fatalf("Script: unexpected error: %v", err)
+		}
+		if hex.EncodeToString(a) != hex.EncodeToString(b) {
+			t.Fatalf("Script(%d): scripts of round trip differ", i)
+		}
+	}
+}
+
+// TestExpand ensures the redeem and witness scripts of descriptors are expanded
+// along with the origins of their keys.
+func TestExpand(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	desc := "sh(wsh(multi(1,[d34db33f/48h/0h]" + testXpub + "/0/*," +
+		testPubKey1 + ")))"
+	d, err := Parse(desc, false, params)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	exp, err := d.Expand(3)
+	if err != nil {
+		t.Fatalf("Expand: unexpected error: %v", err)
+	}
+	script, err := d.Script(3)
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	if hex.EncodeToString(exp.Script) != hex.EncodeToString(script) {
+		t.Fatalf("Expand: script %x, want %x", exp.Script, script)
+	}
+	wantRedeem, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(chainhash.HashB(exp.WitnessScript)).Script()
+	if err != nil {
+		t.Fatalf("NewScriptBuilder: unexpected error: %v", err)
+	}
+	if hex.EncodeToString(exp.RedeemScript) != hex.EncodeToString(wantRedeem) {
+		t.Fatalf("Expand: redeem script %x, want %x", exp.RedeemScript,
+			wantRedeem)
+	}
+	if len(exp.Keys) != 2 || exp.InternalKey != nil {
+		t.Fatalf("Expand: got %d keys and internal key %v, want 2 "+
+			"keys", len(exp.Keys), exp.InternalKey)
+	}
+	if got := hex.EncodeToString(exp.Keys[0].Fingerprint[:]); got != "d34db33f" {
+		t.Fatalf("Expand: fingerprint %s, want d34db33f", got)
+	}
+	wantPath := []uint32{hdkeychain.HardenedKeyStart + 48,
+		hdkeychain.HardenedKeyStart, 0, 3}
+	if len(exp.Keys[0].Path) != len(wantPath) {
+		t.Fatalf("Expand: path %v, want %v", exp.Keys[0].Path, wantPath)
+	}
+	for i := range wantPath {
+		if exp.Keys[0].Path[i] != wantPath[i] {
+			t.Fatalf("Expand: path %v, want %v", exp.Keys[0].Path,
+				wantPath)
+		}
+	}
+	pubKey1 := mustDecodeHex(testPubKey1)
+	wantFingerprint := btcutil.Hash160(pubKey1)[:4]
+	if hex.EncodeToString(exp.Keys[1].PubKey) != testPubKey1 ||
+		hex.EncodeToString(exp.Keys[1].Fingerprint[:]) !=
+			hex.EncodeToString(wantFingerprint) ||
+		len(exp.Keys[1].Path) != 0 {
+
+		t.Fatalf("Expand: unexpected origin of single key %+v",
+			exp.Keys[1])
+	}
+
+	// The internal key of tr() descriptors is an x-only key whose origin
+	// is the extended key it was derived from.
+	d, err = Parse("tr("+testXpub+"/*)", false, params)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	exp, err = d.Expand(5)
+	if err != nil {
+		t.Fatalf("Expand: unexpected error: %v", err)
+	}
+	internal := exp.InternalKey
+	if internal == nil || len(exp.Keys) != 0 ||
+		len(internal.PubKey) != schnorr.PubKeyBytesLen ||
+		hex.EncodeToString(internal.Fingerprint[:]) != testFingerprint ||
+		len(internal.Path) != 1 || internal.Path[0] != 5 {
+
+		t.Fatalf("Expand: unexpected internal key %+v", internal)
+	}
+}
+
+// TestParseTapTree ensures the script trees of tr() descriptors commit to
+// their leaf scripts.
+func TestParseTapTree(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	internal := "a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	desc := "tr(" + internal + ",{pk(" + testPubKey1 + "),pk(" +
+		testPubKey2 + ")})"
+	d, err := Parse(desc, false, params)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	script, err := d.Script(0)
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+
+	internalKey, err := schnorr.ParsePubKey(mustDecodeHex(internal))
+	if err != nil {
+		t.Fatalf("ParsePubKey: unexpected error: %v", err)
+	}
+	leaves := make([]txscript.TapLeaf, 0, 2)
+	for _, key := range []string{testPubKey1, testPubKey2} {
+		leafScript := append([]byte{0x20}, mustDecodeHex(key)[1:]...)
+		leafScript = append(leafScript, txscript.OP_CHECKSIG)
+		leaves = append(leaves, txscript.NewBaseTapLeaf(leafScript))
+	}
+	root := txscript.AssembleTaprootScriptTree(leaves...).RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, root[:])
+	want := append([]byte{txscript.OP_1, 0x20},
+		schnorr.SerializePubKey(outputKey)...)
+	if hex.EncodeToString(script) != hex.EncodeToString(want) {
+		t.Fatalf("Script: got %x, want %x", script, want)
+	}
+
+	if got := d.String(); !strings.HasPrefix(got, desc+"#") {
+		t.Fatalf("String: got %s, want %s with checksum", got, desc)
+	}
+}
+
+// TestParseErrors ensures invalid descriptors are rejected.
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	uncompressed := "04a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56a" +
+		"c1c540c5bd5b8dec5235a0fa8722476c7709c02559e3aa73aa03918ba2d492eea" +
+		"75abea235"
+	tests := []string{
+		"",
+		"foo(" + testPubKey1 + ")",
+		"pk(" + testPubKey1 + "," + testPubKey2 + ")",
+		"pk(" + testPubKey1[:64] + ")",
+		"pkh(deadbeef)",
+		"wpkh(" + uncompressed + ")",
+		"wsh(pk(" + uncompressed + "))",
+		"sh(sh(pk(" + testPubKey1 + ")))",
+		"wsh(wpkh(" + testPubKey1 + "))",
+		"sh(tr(" + testPubKey1 + "))",
+		"tr(" + testPubKey1 + ",pkh(" + testPubKey2 + "))",
+		"tr(" + testPubKey1 + ",{pk(" + testPubKey2 + ")})",
+		"multi(0," + testPubKey1 + ")",
+		"multi(3," + testPubKey1 + "," + testPubKey2 + ")",
+		"multi(1," + strings.Repeat(testPubKey1+",", 3) + testPubKey2 + ")",
+		"sh(multi(1," + strings.Repeat(uncompressed+",", 8) + testPubKey2 + "))",
+		"pkh([d34db33f/44'/0']" + testXpub + "/0'/*)",
+		"pkh([d34db33/44'/0']" + testXpub + "/0/*)",
+		"pkh([d34db33f]" + testXpub + "/2147483648)",
+		"pkh(" + testXpub + "/00/*)",
+		"pkh([d34db33f][d34db33f]" + testPubKey1 + ")",
+		"addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)#xxxxxxxx",
+		"raw(zz)",
+		"sh(raw(deadbeef))",
+	}
+	for _, desc := range tests {
+		if _, err := Parse(desc, false, &chaincfg.MainNetParams); err == nil {
+			t.Errorf("Parse(%q): expected error", desc)
+		}
+	}
+
+	// Keys of other networks are rejected.
+	if _, err := Parse("pkh("+testXpub+")", false,
+		&chaincfg.TestNet3Params); err == nil {
+
+		t.Errorf("Parse: expected error for mainnet key on testnet")
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package descriptor implements parsing of output script descriptors and the
+derivation of their scripts and addresses.
+
+Output descriptors, specified by BIP 380 and the BIPs of their script
+expressions, describe a set of output scripts in a human readable language, for
+example wpkh([d34db33f/84'/0'/0']xpub.../0/*) for the pay-to-witness-pubkey-hash
+scripts of the receiving keys of a BIP 84 account.  The following script
+expressions are supported:
+
+  - pk(KEY), pkh(KEY) and wpkh(KEY) (BIP 381 and BIP 382)
+  - sh(SCRIPT) and wsh(SCRIPT) (BIP 381 and BIP 382)
+  - multi(k,KEY,...) and sortedmulti(k,KEY,...) (BIP 383)
+  - tr(KEY) and tr(KEY,TREE) with pk(KEY) leaves (BIP 386)
+  - addr(ADDR) and raw(HEX) (BIP 385)
+
+Keys are hex-encoded public keys, WIF-encoded private keys or extended keys
+followed by a derivation path, optionally prefixed with their origin in the
+form [fingerprint/path].  A derivation path ending with /* makes the descriptor
+ranged, in which case its scripts are derived at a derivation index.
+
+Descriptors may be followed by a checksum in the form #checksum, which is
+validated when present and can be required by the caller.
+*/
+package descriptor
@@ -0,0 +1,471 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// deriveType describes the derivation of the last step of the path of an
+// extended key.
+type deriveType int
+
+const (
+	// deriveNone indicates the path of the key is fixed.
+	deriveNone deriveType = iota
+
+	// deriveUnhardened indicates the path ends with the unhardened child
+	// at the derivation index, written as /*.
+	deriveUnhardened
+
+	// deriveHardened indicates the path ends with the hardened child at
+	// the derivation index, written as /*' or /*h.
+	deriveHardened
+)
+
+// keyExpr is a key expression of a descriptor.  It is either a single public
+// or private key, or an extended key followed by a derivation path.
+type keyExpr struct {
+	// origin is the key origin the expression is prefixed with without
+	// the enclosing brackets, or empty when there is none.
+	origin string
+
+	// pubKey and wif are the key of a single key expression.  The private
+	// key is nil for public keys.
+	pubKey *btcec.PublicKey
+	wif    *btcutil.WIF
+
+	// compressed is whether a single public key is serialized compressed
+	// and xOnly whether it is serialized as a 32-byte x-only key.
+	compressed bool
+	xOnly      bool
+
+	// extKey is the extended key of the expression and path the steps
+	// derived from it, ending with the derivation index unless derive is
+	// deriveNone.  derived is the key at the end of the fixed part of the
+	// path and hardenedMarker the marker used in the expression.
+	extKey         *hdkeychain.ExtendedKey
+	path           []uint32
+	derive         deriveType
+	derived        *hdkeychain.ExtendedKey
+	hardenedMarker string
+}
+
+// isRange returns whether the key depends on the derivation index.
+func (k *keyExpr) isRange() bool {
+	return k.derive != deriveNone
+}
+
+// hasPrivateKey returns whether the expression holds a private key.
+func (k *keyExpr) hasPrivateKey() bool {
+	if k.extKey != nil {
+		return k.extKey.IsPrivate()
+	}
+	return k.wif != nil
+}
+
+// key returns the public key of the expression at the passed derivation index.
+func (k *keyExpr) key(index uint32) (*btcec.PublicKey, error) {
+	if k.extKey == nil {
+		return k.pubKey, nil
+	}
+
+	child := k.derived
+	switch k.derive {
+	case deriveUnhardened:
+		if index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("derivation index %d is out of "+
+				"range", index)
+		}
+		var err error
+		child, err = child.Derive(index)
+		if err != nil {
+			return nil, err
+		}
+
+	case deriveHardened:
+		if index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("derivation index %d is out of "+
+				"range", index)
+		}
+		var err error
+		child, err = child.Derive(index + hdkeychain.HardenedKeyStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return child.ECPubKey()
+}
+
+// serialize returns the serialization of the public key at the passed
+// derivation index as used in the scripts of the expression.
+func (k *keyExpr) serialize(index uint32) ([]byte, error) {
+	pubKey, err := k.key(index)
+	if err != nil {
+		return nil, err
+	}
+	if k.extKey == nil && !k.compressed {
+		return pubKey.SerializeUncompressed(), nil
+	}
+	return pubKey.SerializeCompressed(), nil
+}
+
+// formatPath renders a derivation path with the passed hardened marker.
+func formatPath(path []uint32, marker string) string {
+	var b strings.Builder
+	for _, step := range path {
+		b.WriteByte('/')
+		if step >= hdkeychain.HardenedKeyStart {
+			b.WriteString(strconv.FormatUint(uint64(
+				step-hdkeychain.HardenedKeyStart), 10))
+			b.WriteString(marker)
+			continue
+		}
+		b.WriteString(strconv.FormatUint(uint64(step), 10))
+	}
+	return b.String()
+}
+
+// string renders the expression.  Private keys are rendered as their public
+// keys unless private is set.
+func (k *keyExpr) string(private bool) string {
+	var b strings.Builder
+	if k.origin != "" {
+		b.WriteString("[" + k.origin + "]")
+	}
+
+	switch {
+	case k.extKey != nil:
+		extKey := k.extKey
+		if !private && extKey.IsPrivate() {
+			// Neutering only fails for keys that aren't private.
+			extKey, _ = extKey.Neuter()
+		}
+		b.WriteString(extKey.String())
+		b.WriteString(formatPath(k.path, k.hardenedMarker))
+		switch k.derive {
+		case deriveUnhardened:
+			b.WriteString("/*")
+		case deriveHardened:
+			b.WriteString("/*" + k.hardenedMarker)
+		}
+
+	case private && k.wif != nil:
+		b.WriteString(k.wif.String())
+
+	case k.xOnly:
+		b.WriteString(hex.EncodeToString(schnorr.SerializePubKey(k.pubKey)))
+
+	case k.compressed:
+		b.WriteString(hex.EncodeToString(k.pubKey.SerializeCompressed()))
+
+	default:
+		b.WriteString(hex.EncodeToString(k.pubKey.SerializeUncompressed()))
+	}
+	return b.String()
+}
+
+// derivedString renders the public key at the passed derivation index with
+// the origin of the derived key.  Keys derived from an extended key without
+// an origin get the fingerprint of the extended key as the origin.
+func (k *keyExpr) derivedString(index uint32) (string, error) {
+	if k.extKey == nil {
+		return k.string(false), nil
+	}
+
+	pubKey, err := k.key(index)
+	if err != nil {
+		return "", err
+	}
+
+	origin := k.origin
+	if origin == "" {
+		extPubKey, err := k.extKey.ECPubKey()
+		if err != nil {
+			return "", err
+		}
+		fingerprint := btcutil.Hash160(extPubKey.SerializeCompressed())[:4]
+		origin = hex.EncodeToString(fingerprint)
+	}
+	origin += formatPath(k.derivedPath(index), k.hardenedMarker)
+
+	var key string
+	if k.xOnly {
+		key = hex.EncodeToString(schnorr.SerializePubKey(pubKey))
+	} else {
+		key = hex.EncodeToString(pubKey.SerializeCompressed())
+	}
+	return "[" + origin + "]" + key, nil
+}
+
+// derivedPath returns the derivation path of the key at the passed derivation
+// index relative to its extended key.
+func (k *keyExpr) derivedPath(index uint32) []uint32 {
+	path := k.path
+	switch k.derive {
+	case deriveUnhardened:
+		path = append(path[:len(path):len(path)], index)
+	case deriveHardened:
+		path = append(path[:len(path):len(path)],
+			index+hdkeychain.HardenedKeyStart)
+	}
+	return path
+}
+
+// KeyOrigin is a public key of the scripts of a descriptor along with the
+// fingerprint of the key it was derived from and its derivation path, as
+// needed by signers to find the private key.
+type KeyOrigin struct {
+	// PubKey is the public key serialized as in the scripts, or as an
+	// x-only key for the internal key of tr().
+	PubKey []byte
+
+	// Fingerprint is the fingerprint of the key the public key was
+	// derived from.  It is the fingerprint of the origin of the key when
+	// there is one, of the extended key when it has none, and the
+	// fingerprint of the public key itself for single keys without an
+	// origin.
+	Fingerprint [4]byte
+
+	// Path is the derivation path of the public key from the key with the
+	// fingerprint.
+	Path []uint32
+}
+
+// keyOrigin returns the origin of the public key at the passed derivation
+// index.  The public key is serialized as an x-only key when xOnly is set.
+func (k *keyExpr) keyOrigin(index uint32, xOnly bool) (*KeyOrigin, error) {
+	pubKey, err := k.key(index)
+	if err != nil {
+		return nil, err
+	}
+	serialized, err := k.serialize(index)
+	if err != nil {
+		return nil, err
+	}
+
+	origin := &KeyOrigin{PubKey: serialized}
+	if xOnly {
+		origin.PubKey = schnorr.SerializePubKey(pubKey)
+	}
+	switch {
+	case k.origin != "":
+		// The origin was validated when it was parsed.
+		parts := strings.Split(k.origin, "/")
+		fingerprint, _ := hex.DecodeString(parts[0])
+		copy(origin.Fingerprint[:], fingerprint)
+		for _, s := range parts[1:] {
+			step, _, _ := parsePathStep(s)
+			origin.Path = append(origin.Path, step)
+		}
+
+	case k.extKey != nil:
+		extPubKey, err := k.extKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		copy(origin.Fingerprint[:], btcutil.Hash160(
+			extPubKey.SerializeCompressed()))
+
+	default:
+		copy(origin.Fingerprint[:], btcutil.Hash160(serialized))
+		return origin, nil
+	}
+	if k.extKey != nil {
+		origin.Path = append(origin.Path, k.derivedPath(index)...)
+	}
+	return origin, nil
+}
+
+// parsePathStep parses a single step of a derivation path and returns it
+// with the hardened marker used, if any.
+func parsePathStep(s string) (uint32, string, error) {
+	marker := ""
+	if strings.HasSuffix(s, "'") || strings.HasSuffix(s, "h") {
+		marker = s[len(s)-1:]
+		s = s[:len(s)-1]
+	}
+	step, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || step >= hdkeychain.HardenedKeyStart ||
+		(len(s) > 1 && s[0] == '0') {
+
+		return 0, "", fmt.Errorf("key path value '%s' is not a valid "+
+			"uint31", s)
+	}
+	if marker != "" {
+		step += hdkeychain.HardenedKeyStart
+	}
+	return uint32(step), marker, nil
+}
+
+// parseOrigin validates a key origin without the enclosing brackets and
+// returns it with the fingerprint in lowercase.
+func parseOrigin(origin string) (string, error) {
+	parts := strings.Split(origin, "/")
+	fingerprint := parts[0]
+	if len(fingerprint) != 8 {
+		return "", fmt.Errorf("fingerprint '%s' is not hex", fingerprint)
+	}
+	if _, err := hex.DecodeString(fingerprint); err != nil {
+		return "", fmt.Errorf("fingerprint '%s' is not hex", fingerprint)
+	}
+	for _, s := range parts[1:] {
+		if _, _, err := parsePathStep(s); err != nil {
+			return "", err
+		}
+	}
+	parts[0] = strings.ToLower(fingerprint)
+	return strings.Join(parts, "/"), nil
+}
+
+// parseKey parses a key expression in the passed script context.
+func parseKey(s string, ctx scriptContext, params *chaincfg.Params) (*keyExpr, error) {
+	k := &keyExpr{}
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end == -1 {
+			return nil, fmt.Errorf("key origin start '[ character " +
+				"without corresponding ']'")
+		}
+		origin, err := parseOrigin(s[1:end])
+		if err != nil {
+			return nil, err
+		}
+		k.origin = origin
+		s = s[end+1:]
+	}
+	if strings.ContainsAny(s, "[]") {
+		return nil, fmt.Errorf("multiple key origins are not allowed")
+	}
+
+	parts := strings.Split(s, "/")
+	if len(parts) == 1 {
+		ok, err := k.parseSingleKey(s, ctx, params)
+		if ok || err != nil {
+			return k, err
+		}
+	}
+	return k, k.parseExtendedKey(parts, params)
+}
+
+// parseSingleKey parses a hex encoded public key or a WIF encoded private key
+// into the expression.  It returns false when the string is neither.
+func (k *keyExpr) parseSingleKey(s string, ctx scriptContext, params *chaincfg.Params) (bool, error) {
+	if data, err := hex.DecodeString(s); err == nil {
+		switch {
+		case len(data) == schnorr.PubKeyBytesLen && ctx == contextP2TR:
+			pubKey, err := schnorr.ParsePubKey(data)
+			if err != nil {
+				return true, fmt.Errorf("pubkey '%s' is invalid", s)
+			}
+			k.pubKey, k.compressed, k.xOnly = pubKey, true, true
+
+		case len(data) == btcec.PubKeyBytesLenCompressed:
+			pubKey, err := btcec.ParsePubKey(data)
+			if err != nil {
+				return true, fmt.Errorf("pubkey '%s' is invalid", s)
+			}
+			k.pubKey, k.compressed = pubKey, true
+
+		case len(data) == 65 &&
+			data[0] == 0x04:
+
+			if ctx.isSegwit() {
+				return true, fmt.Errorf("uncompressed keys are not " +
+					"allowed")
+			}
+			pubKey, err := btcec.ParsePubKey(data)
+			if err != nil {
+				return true, fmt.Errorf("pubkey '%s' is invalid", s)
+			}
+			k.pubKey = pubKey
+
+		default:
+			return true, fmt.Errorf("pubkey '%s' is invalid", s)
+		}
+		return true, nil
+	}
+
+	wif, err := btcutil.DecodeWIF(s)
+	if err != nil {
+		return false, nil
+	}
+	if !wif.IsForNet(params) {
+		return true, fmt.Errorf("key '%s' is not valid for the %s "+
+			"network", s, params.Name)
+	}
+	if !wif.CompressPubKey && ctx.isSegwit() {
+		return true, fmt.Errorf("uncompressed keys are not allowed")
+	}
+	k.wif = wif
+	k.pubKey = wif.PrivKey.PubKey()
+	k.compressed = wif.CompressPubKey
+	k.xOnly = ctx == contextP2TR
+	return true, nil
+}
+
+// parseExtendedKey parses an extended key followed by the steps of its
+// derivation path into the expression.
+func (k *keyExpr) parseExtendedKey(parts []string, params *chaincfg.Params) error {
+	extKey, err := hdkeychain.NewKeyFromString(parts[0])
+	if err != nil {
+		return fmt.Errorf("key '%s' is not valid", parts[0])
+	}
+	if !extKey.IsForNet(params) {
+		return fmt.Errorf("key '%s' is not valid for the %s network",
+			parts[0], params.Name)
+	}
+	k.extKey = extKey
+	k.hardenedMarker = "'"
+
+	steps := parts[1:]
+	if n := len(steps); n > 0 {
+		switch steps[n-1] {
+		case "*":
+			k.derive = deriveUnhardened
+			steps = steps[:n-1]
+		case "*'", "*h":
+			k.derive = deriveHardened
+			k.hardenedMarker = steps[n-1][1:]
+			steps = steps[:n-1]
+		}
+	}
+
+	derived := extKey
+	for _, s := range steps {
+		step, marker, err := parsePathStep(s)
+		if err != nil {
+			return err
+		}
+		if marker != "" {
+			k.hardenedMarker = marker
+		}
+		k.path = append(k.path, step)
+
+		derived, err = derived.Derive(step)
+		if err == hdkeychain.ErrDeriveHardFromPublic {
+			return fmt.Errorf("hardened derivation of '%s' requires "+
+				"a private key", parts[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if k.derive == deriveHardened && !derived.IsPrivate() {
+		return fmt.Errorf("hardened derivation of '%s' requires a "+
+			"private key", parts[0])
+	}
+	k.derived = derived
+	return nil
+}
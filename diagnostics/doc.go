@@ -0,0 +1,25 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package diagnostics captures runtime profiles of the node, so operators can
+collect data about stalls and performance problems of a running node without
+restarting it with profiling enabled.
+
+A Collector writes CPU profiles, execution traces and snapshots of the heap,
+allocations, goroutines, blocking, mutex contention and thread creation to
+files in a directory on demand.  Only one CPU profile and one execution trace
+can be captured at a time, since the runtime doesn't support more.
+
+A Collector also writes state dumps, which are plain text files made of
+sections describing the state of the node followed by the stacks of all
+goroutines.  Each section is written with a timeout, so a section waiting for a
+lock held by a stuck goroutine is left out instead of hanging the dump, which
+is meant to diagnose exactly such deadlocks.
+
+Handler serves the standard pprof endpoints under /debug/pprof/ without any
+authentication, so it must be wrapped by a handler authenticating the requests
+before it is exposed.
+*/
+package diagnostics
@@ -0,0 +1,25 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// PathPrefix is the path prefix the pprof endpoints are served under.
+const PathPrefix = "/debug/pprof/"
+
+// Handler returns a handler serving the pprof endpoints under PathPrefix.  It
+// doesn't authenticate the requests, which is left to the caller.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathPrefix, pprof.Index)
+	mux.HandleFunc(PathPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PathPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(PathPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(PathPrefix+"trace", pprof.Trace)
+	return mux
+}
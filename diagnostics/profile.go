@@ -0,0 +1,259 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProfileType identifies a kind of profile which can be collected.
+type ProfileType string
+
+const (
+	// ProfileCPU samples the CPU usage of the node for the duration of the
+	// capture.
+	ProfileCPU ProfileType = "cpu"
+
+	// ProfileTrace records an execution trace of the node for the duration
+	// of the capture.
+	ProfileTrace ProfileType = "trace"
+
+	// ProfileHeap is a snapshot of the live heap allocations.
+	ProfileHeap ProfileType = "heap"
+
+	// ProfileAllocs is a snapshot of all past allocations.
+	ProfileAllocs ProfileType = "allocs"
+
+	// ProfileGoroutine is a snapshot of the stacks of all goroutines.
+	ProfileGoroutine ProfileType = "goroutine"
+
+	// ProfileBlock is a snapshot of the stacks which blocked on
+	// synchronization primitives.  It is only populated when the block
+	// profile rate is set.
+	ProfileBlock ProfileType = "block"
+
+	// ProfileMutex is a snapshot of the stacks holding contended mutexes.
+	// It is only populated when the mutex profile fraction is set.
+	ProfileMutex ProfileType = "mutex"
+
+	// ProfileThreadCreate is a snapshot of the stacks which created OS
+	// threads.
+	ProfileThreadCreate ProfileType = "threadcreate"
+)
+
+const (
+	// DefaultDuration is the duration of CPU profiles and execution traces
+	// when no duration is requested.
+	DefaultDuration = 30 * time.Second
+
+	// MaxDuration is the maximum duration of CPU profiles and execution
+	// traces.
+	MaxDuration = 10 * time.Minute
+)
+
+// timed returns whether the profile type is captured over a duration rather
+// than being a snapshot.
+func (t ProfileType) timed() bool {
+	return t == ProfileCPU || t == ProfileTrace
+}
+
+// extension returns the file extension of profiles of the type.
+func (t ProfileType) extension() string {
+	if t == ProfileTrace {
+		return ".trace"
+	}
+	return ".pprof"
+}
+
+var (
+	// ErrUnknownProfile is returned when an unknown profile type is
+	// requested.
+	ErrUnknownProfile = errors.New("unknown profile type")
+
+	// ErrInvalidDuration is returned when the duration of a CPU profile or
+	// execution trace is out of range.
+	ErrInvalidDuration = fmt.Errorf("duration must be positive and at "+
+		"most %v", MaxDuration)
+
+	// ErrCaptureInProgress is returned when a CPU profile or execution
+	// trace is requested while another one is being captured, since the
+	// runtime only supports one at a time.
+	ErrCaptureInProgress = errors.New("a capture of the same type is " +
+		"already in progress")
+)
+
+// ProfileTypes returns the names of the supported profile types sorted by
+// name.
+func ProfileTypes() []string {
+	types := []string{
+		string(ProfileAllocs), string(ProfileBlock), string(ProfileCPU),
+		string(ProfileGoroutine), string(ProfileHeap),
+		string(ProfileMutex), string(ProfileThreadCreate),
+		string(ProfileTrace),
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ParseProfileType returns the profile type with the passed name.
+func ParseProfileType(s string) (ProfileType, error) {
+	for _, name := range ProfileTypes() {
+		if name == s {
+			return ProfileType(s), nil
+		}
+	}
+	return "", ErrUnknownProfile
+}
+
+// Profile describes a collected profile.
+type Profile struct {
+	Type     ProfileType
+	Path     string
+	Duration time.Duration
+	Size     int64
+}
+
+// Collector writes profiles of the running process to files in a directory on
+// demand.
+type Collector struct {
+	dir string
+
+	mtx       sync.Mutex
+	capturing map[ProfileType]bool
+}
+
+// NewCollector returns a collector writing profiles to the passed directory,
+// which is created when the first profile is collected.
+func NewCollector(dir string) *Collector {
+	return &Collector{
+		dir:       dir,
+		capturing: make(map[ProfileType]bool),
+	}
+}
+
+// Collect writes a profile of the passed type to a new file in the directory
+// of the collector, named after the type and the current time.  CPU profiles
+// and execution traces are captured for the passed duration, or DefaultDuration
+// when it is zero, and end early when the passed channel is closed.  The
+// duration is ignored for the other types, which are snapshots.
+//
+// This function is safe for concurrent access.
+func (c *Collector) Collect(typ ProfileType, duration time.Duration,
+	quit <-chan struct{}) (*Profile, error) {
+
+	if _, err := ParseProfileType(string(typ)); err != nil {
+		return nil, err
+	}
+	if !typ.timed() {
+		duration = 0
+	} else if duration == 0 {
+		duration = DefaultDuration
+	} else if duration < 0 || duration > MaxDuration {
+		return nil, ErrInvalidDuration
+	}
+
+	// Only one CPU profile and one execution trace can be captured at a
+	// time.  Snapshots can be taken concurrently.
+	if typ.timed() {
+		c.mtx.Lock()
+		if c.capturing[typ] {
+			c.mtx.Unlock()
+			return nil, ErrCaptureInProgress
+		}
+		c.capturing[typ] = true
+		c.mtx.Unlock()
+
+		defer func() {
+			c.mtx.Lock()
+			delete(c.capturing, typ)
+			c.mtx.Unlock()
+		}()
+	}
+
+	f, err := c.create(string(typ), typ.extension())
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+
+	start := time.Now()
+	err = capture(f, typ, duration, quit)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	profile := &Profile{Type: typ, Path: path}
+	if typ.timed() {
+		profile.Duration = time.Since(start)
+	}
+	if info, err := os.Stat(path); err == nil {
+		profile.Size = info.Size()
+	}
+	return profile, nil
+}
+
+// create creates a new file in the directory of the collector named after the
+// passed prefix and the current time, which is only readable by the owner.
+func (c *Collector) create(prefix, extension string) (*os.File, error) {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s%s", prefix,
+		time.Now().UTC().Format("20060102T150405.000Z"), extension)
+	return os.OpenFile(filepath.Join(c.dir, name),
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+}
+
+// capture writes a profile of the passed type to the passed file.
+func capture(f *os.File, typ ProfileType, duration time.Duration,
+	quit <-chan struct{}) error {
+
+	switch typ {
+	case ProfileCPU:
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return ErrCaptureInProgress
+		}
+		wait(duration, quit)
+		pprof.StopCPUProfile()
+		return nil
+
+	case ProfileTrace:
+		if err := trace.Start(f); err != nil {
+			return ErrCaptureInProgress
+		}
+		wait(duration, quit)
+		trace.Stop()
+		return nil
+	}
+
+	profile := pprof.Lookup(string(typ))
+	if profile == nil {
+		return ErrUnknownProfile
+	}
+	return profile.WriteTo(f, 0)
+}
+
+// wait blocks for the passed duration or until the passed channel is closed.
+func wait(duration time.Duration, quit <-chan struct{}) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-quit:
+	}
+}
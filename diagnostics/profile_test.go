@@ -0,0 +1,59 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCollect ensures profiles are written to the directory of the collector
+// and invalid requests are rejected.
+func TestCollect(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	c := NewCollector(dir)
+
+	// Snapshots ignore the duration.
+	profile, err := c.Collect(ProfileGoroutine, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Collect(goroutine): unexpected error: %v", err)
+	}
+	if filepath.Dir(profile.Path) != dir ||
+		!strings.HasPrefix(filepath.Base(profile.Path), "goroutine-") ||
+		profile.Size == 0 || profile.Duration != 0 {
+
+		t.Fatalf("Collect(goroutine): unexpected profile %+v", profile)
+	}
+
+	// Timed captures end once the quit channel is closed.
+	quit := make(chan struct{})
+	close(quit)
+	profile, err = c.Collect(ProfileTrace, MaxDuration, quit)
+	if err != nil {
+		t.Fatalf("Collect(trace): unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(profile.Path, ".trace") || profile.Size == 0 {
+		t.Fatalf("Collect(trace): unexpected profile %+v", profile)
+	}
+
+	if _, err := c.Collect("bogus", 0, nil); err != ErrUnknownProfile {
+		t.Fatalf("Collect(bogus): got %v, want %v", err,
+			ErrUnknownProfile)
+	}
+	_, err = c.Collect(ProfileCPU, MaxDuration+time.Second, nil)
+	if err != ErrInvalidDuration {
+		t.Fatalf("Collect(cpu): got %v, want %v", err,
+			ErrInvalidDuration)
+	}
+
+	// Only one CPU profile can be captured at a time.
+	c.capturing[ProfileCPU] = true
+	if _, err := c.Collect(ProfileCPU, time.Second, nil); err != ErrCaptureInProgress {
+		t.Fatalf("Collect(cpu): got %v, want %v", err,
+			ErrCaptureInProgress)
+	}
+}
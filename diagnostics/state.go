@@ -0,0 +1,122 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/pprof"
+	"time"
+)
+
+// SectionTimeout is the maximum amount of time a section of a state dump may
+// take to write.  Sections which take longer are likely waiting for a lock
+// held by a stuck goroutine and are left out of the dump.
+var SectionTimeout = 5 * time.Second
+
+// Section is a named part of a state dump.
+type Section struct {
+	// Name is the title of the section.
+	Name string
+
+	// Write writes the contents of the section.  It may block on state
+	// locked by a stuck goroutine, in which case the section is left out
+	// after SectionTimeout and the goroutine writing it is abandoned.
+	Write func(w io.Writer) error
+}
+
+// StateDump describes a state dump written to a file.
+type StateDump struct {
+	Path string
+	Size int64
+}
+
+// errSectionTimeout is written in place of sections which took longer than
+// SectionTimeout to write.
+var errSectionTimeout = errors.New("timed out, likely blocked on a lock " +
+	"held by one of the goroutines below")
+
+// writeSection writes the passed section to a buffer on its own goroutine and
+// returns the buffer, or errSectionTimeout when the section doesn't finish in
+// time.
+func writeSection(section Section) ([]byte, error) {
+	type result struct {
+		buf []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var buf bytes.Buffer
+		err := section.Write(&buf)
+		done <- result{buf.Bytes(), err}
+	}()
+
+	timer := time.NewTimer(SectionTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.buf, r.err
+	case <-timer.C:
+		return nil, errSectionTimeout
+	}
+}
+
+// WriteState writes a plain text state dump made of the passed sections
+// followed by the stacks of all goroutines to the passed writer.  The error of
+// a section, or of a section which timed out, is written to the dump in its
+// place, so a failing section doesn't hide the others.
+func WriteState(w io.Writer, sections []Section) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "State dump at %s\n",
+		time.Now().UTC().Format(time.RFC3339Nano))
+
+	for _, section := range sections {
+		fmt.Fprintf(bw, "\n== %s ==\n\n", section.Name)
+		contents, err := writeSection(section)
+		bw.Write(contents)
+		if err != nil {
+			fmt.Fprintf(bw, "unavailable: %v\n", err)
+		}
+	}
+
+	// The stacks come last since they are by far the largest section.
+	fmt.Fprintf(bw, "\n== Goroutines ==\n\n")
+	if err := pprof.Lookup(string(ProfileGoroutine)).WriteTo(bw, 2); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// DumpState writes a state dump made of the passed sections to a new file in
+// the directory of the collector, named after the current time.  See
+// WriteState for the contents of the dump.
+//
+// This function is safe for concurrent access.
+func (c *Collector) DumpState(sections []Section) (*StateDump, error) {
+	f, err := c.create("state", ".txt")
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+
+	err = WriteState(f, sections)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	dump := &StateDump{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		dump.Size = info.Size()
+	}
+	return dump, nil
+}
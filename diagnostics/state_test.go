@@ -0,0 +1,86 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDumpState ensures state dumps contain all sections followed by the
+// goroutine stacks and failing or blocked sections don't hide the others.
+func TestDumpState(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	c := NewCollector(dir)
+
+	defer func(timeout time.Duration) {
+		SectionTimeout = timeout
+	}(SectionTimeout)
+	SectionTimeout = 100 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+
+	dump, err := c.DumpState([]Section{{
+		Name: "Blocked",
+		Write: func(io.Writer) error {
+			<-block
+			return nil
+		},
+	}, {
+		Name: "Failing",
+		Write: func(io.Writer) error {
+			return errors.New("not responding")
+		},
+	}, {
+		Name: "Chain",
+		Write: func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, "height 100")
+			return err
+		},
+	}})
+	if err != nil {
+		t.Fatalf("DumpState: unexpected error: %v", err)
+	}
+	if filepath.Dir(dump.Path) != dir ||
+		!strings.HasPrefix(filepath.Base(dump.Path), "state-") {
+
+		t.Fatalf("DumpState: unexpected dump %+v", dump)
+	}
+
+	contents, err := os.ReadFile(dump.Path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if int64(len(contents)) != dump.Size {
+		t.Fatalf("DumpState: size %d, file has %d bytes", dump.Size,
+			len(contents))
+	}
+
+	// The sections must be written in order with the stacks, which
+	// include the goroutine of the test, last.
+	dumped := string(contents)
+	wants := []string{
+		"== Blocked ==\n\nunavailable: " + errSectionTimeout.Error(),
+		"== Failing ==\n\nunavailable: not responding\n",
+		"== Chain ==\n\nheight 100\n",
+		"== Goroutines ==\n",
+		"TestDumpState",
+	}
+	prev := 0
+	for _, want := range wants {
+		idx := strings.Index(dumped[prev:], want)
+		if idx == -1 {
+			t.Fatalf("dump doesn't contain %q after offset %d:\n%s",
+				want, prev, dumped)
+		}
+		prev += idx + len(want)
+	}
+}
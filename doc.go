@@ -42,10 +42,14 @@ Application Options:
 	                            block (default: 3000000)
 	    --blockminweight=       Minimum block weight to be used when creating a
 	                            block
-	    --blockprioritysize=    Size in bytes for high-priority/low-fee
-	                            transactions when creating a block (default:
-	                            50000)
+	    --blockprioritysize=    Deprecated: Has no effect since transactions are
+	                            selected by the fee rate of their packages
 	    --blocksonly            Do not accept transactions from remote peers.
+	    --blocktemplatestale=   Maximum time the transactions of block templates
+	                            are incrementally updated as the mempool changes
+	                            before they are selected from scratch again -- 0
+	                            selects them from scratch for every template.
+	                            Valid time units are {s, m, h} (default: 2m0s)
 	-C, --configfile=           Path to configuration file
 	    --connect=              Connect only to the specified peers at startup
 	    --cpuprofile=           Write CPU profile to the specified file
@@ -76,16 +80,42 @@ Application Options:
 	                            (default all interfaces port: 8333, testnet:
 	                            18333, signet: 38333)
 	    --logdir=               Directory to log output
+	    --logformat=            Format of the log output {text, json} -- The json
+	                            format writes each message as a JSON object with
+	                            its subsystem and, when they are logged, the peer
+	                            and block hash on its own line (default: text)
+	    --logmaxsize=           Rotate the log file once it reaches the specified
+	                            size in MiB -- 0 disables rotation by size
+	                            (default: 10)
+	    --logrotateinterval=    Rotate the log file at the specified interval
+	                            regardless of its size (eg. 24h) -- 0 disables
+	                            rotation by time
+	    --maxlogfiles=          Number of rotated log files to keep -- 0 keeps all
+	                            of them (default: 3)
 	    --maxorphantx=          Max number of orphan transactions to keep in
 	                            memory (default: 100)
 	    --maxpeers=             Max number of inbound and outbound peers
 	                            (default: 125)
+	    --metricslisten=        Add an interface/port to serve Prometheus metrics
+	                            on at /metrics (eg. 127.0.0.1:9332) -- The
+	                            metrics server is disabled by default
+	    --metricstoken=         Bearer token scrapers must send in the
+	                            authorization header -- Metrics are served
+	                            without authentication when it is not set
 	    --miningaddr=           Add the specified payment address to the list of
 	                            addresses to use for generated blocks -- At least
-	                            one address is required if the generate option is
-	                            set
+	                            one address or payout is required if the generate
+	                            option is set
+	    --miningpayout=         Add the specified output descriptor, optionally
+	                            followed by @weight, to the payouts of the blocks
+	                            generated by the CPU miner -- Each block pays to a
+	                            payout chosen with a probability proportional to
+	                            its weight (default: 1) and ranged descriptors pay
+	                            to the next derived script each time
 	    --minrelaytxfee=        The minimum transaction fee in BTC/kB to be
 	                            considered a non-zero fee. (default: 1e-05)
+	    --natpmp                Use NAT-PMP or PCP to map our listening port
+	                            outside of NAT
 	    --nobanning             Disable banning of misbehaving peers
 	    --nocfilters            Disable committed filtering (CF) support
 	    --nocheckpoints         Disable built-in checkpoints.  Don't do this
@@ -101,8 +131,13 @@ Application Options:
 	                            have high priority for relaying
 	    --norpc                 Disable built-in RPC server -- NOTE: The RPC
 	                            server is disabled by default if no
-	                            rpcuser/rpcpass or rpclimituser/rpclimitpass is
-	                            specified
+	                            rpcuser/rpcpass, rpclimituser/rpclimitpass or
+	                            rpcauth is specified and cookie authentication
+	                            is disabled
+	    --norpccookie           Do not write an RPC authentication cookie when
+	                            no rpcuser/rpcpass is specified
+	    --norpchttp2            Do not offer HTTP/2 to RPC clients connecting
+	                            over TLS
 	    --notls                 Disable TLS for the RPC server -- NOTE: This is
 	                            only allowed if the RPC server is bound to
 	                            localhost
@@ -115,12 +150,28 @@ Application Options:
 	    --proxy=                Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
 	    --proxypass=            Password for proxy server
 	    --proxyuser=            Username for proxy server
+	    --rebroadcastinterval=  Average time between attempts to announce the
+	                            unconfirmed transactions submitted through the
+	                            RPC server to the connected peers which have not
+	                            seen them yet (e.g. 10m) (default: 10m0s)
 	    --regtest               Use the regression test network
 	    --rejectnonstd          Reject non-standard transactions regardless of
 	                            the default settings for the active network.
 	    --relaynonstd           Relay non-standard transactions regardless of the
 	                            default settings for the active network.
+	    --rpcallowmethod=       Only allow calling the RPC methods specified
+	                            with this option -- Can be specified multiple
+	                            times
 	    --rpccert=              File containing the certificate file
+	    --rpccookiefile=        File to write the RPC authentication cookie to
+	                            when no rpcuser/rpcpass is specified (default:
+	                            .cookie in the data directory)
+	    --rpcdisablemethod=     Disable an RPC method for all users -- Can be
+	                            specified multiple times
+	    --rpcidletimeout=       Duration an idle RPC connection is kept open for
+	                            further requests of the client (e.g. 2m) -- 0
+	                            closes connections after each request (default:
+	                            2m0s)
 	    --rpckey=               File containing the certificate key
 	    --rpclimitpass=         Password for limited RPC connections
 	    --rpclimituser=         Username for limited RPC connections
@@ -132,9 +183,16 @@ Application Options:
 	                            processed concurrently (default: 20)
 	    --rpcmaxwebsockets=     Max number of RPC websocket connections (default:
 	                            25)
+	    --rpcpprof              Serve the pprof endpoints at /debug/pprof/ on the
+	                            RPC listeners to clients authenticated as the
+	                            admin RPC user
 	    --rpcquirks             Mirror some JSON-RPC quirks of Bitcoin Core --
 	                            NOTE: Discouraged unless interoperability issues
 	                            need to be worked around
+	    --rpcratelimit=         Limit how often each client may call an RPC
+	                            method in the form method:calls/interval (eg.
+	                            scantxoutset:1/1m) -- Can be specified multiple
+	                            times
 	-P, --rpcpass=              Password for RPC connections
 	-u, --rpcuser=              Username for RPC connections
 	    --sigcachemaxsize=      The maximum number of entries in the signature
@@ -155,6 +213,13 @@ Application Options:
 	    --whitelist=            Add an IP network or IP that will not be banned.
 	                            (eg. 192.168.1.0/24 or ::1)
 
+RPC Users:
+
+	--rpcauth=              Add an RPC user as user:password:permissions
+	                        where permissions is a comma separated list of
+	                        admin, limited, readonly, wallet or the names of
+	                        methods the user may call
+
 Help Options:
 
 	-h, --help           Show this help message
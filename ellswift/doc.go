@@ -0,0 +1,14 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package ellswift implements the ElligatorSwift encoding of secp256k1 public
+keys and the x-only key exchange over them as specified by BIP0324.
+
+An ElligatorSwift encoding is 64 bytes that are indistinguishable from uniformly
+random data, and every 64-byte string decodes to the X coordinate of a point on
+the curve.  Encodings are randomized, so encoding the same key twice yields
+different results.
+*/
+package ellswift
@@ -0,0 +1,254 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ellswift
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Size is the size of an ElligatorSwift encoded public key.
+const Size = 64
+
+var (
+	// fieldPrime is the prime of the field the secp256k1 curve is defined
+	// over.
+	fieldPrime = btcec.S256().P
+
+	// sqrtMinus3 is the square root of -3 in the field which is selected by
+	// the decoding function defined in BIP 324.
+	sqrtMinus3 = new(big.Int).ModSqrt(new(big.Int).Sub(fieldPrime,
+		big.NewInt(3)), fieldPrime)
+
+	// xdhTag is the tag of the hash the shared secret of an ElligatorSwift
+	// key exchange is derived with.
+	xdhTag = []byte("bip324_ellswift_xonly_ecdh")
+
+	// ErrInfinity is returned when a key exchange results in the point at
+	// infinity.
+	ErrInfinity = errors.New("shared secret is the point at infinity")
+)
+
+// fieldElement returns the passed value reduced modulo the field prime.
+func fieldElement(v *big.Int) *big.Int {
+	return v.Mod(v, fieldPrime)
+}
+
+// fieldDiv returns a/b in the field.  The divisor must not be zero.
+func fieldDiv(a, b *big.Int) *big.Int {
+	inv := new(big.Int).ModInverse(b, fieldPrime)
+	return fieldElement(inv.Mul(inv, a))
+}
+
+// isValidX returns whether the passed field element is the X coordinate of a
+// point on the curve, which is the case when x^3+7 is a square.
+func isValidX(x *big.Int) bool {
+	y2 := new(big.Int).Exp(x, big.NewInt(3), fieldPrime)
+	y2 = fieldElement(y2.Add(y2, big.NewInt(7)))
+	return big.Jacobi(y2, fieldPrime) != -1
+}
+
+// fieldSqrt returns a square root of the passed field element or nil when it
+// isn't a square.
+func fieldSqrt(v *big.Int) *big.Int {
+	return new(big.Int).ModSqrt(v, fieldPrime)
+}
+
+// xSwiftEC returns the X coordinate the field elements u and t decode to as
+// specified by the xswiftec function of BIP 324.
+func xSwiftEC(u, t *big.Int) *big.Int {
+	u = new(big.Int).Set(u)
+	t = new(big.Int).Set(t)
+	if u.Sign() == 0 {
+		u.SetInt64(1)
+	}
+	if t.Sign() == 0 {
+		t.SetInt64(1)
+	}
+
+	// Double t when u^3 + t^2 + 7 is zero.
+	u3Plus7 := new(big.Int).Exp(u, big.NewInt(3), fieldPrime)
+	u3Plus7 = fieldElement(u3Plus7.Add(u3Plus7, big.NewInt(7)))
+	t2 := fieldElement(new(big.Int).Mul(t, t))
+	if fieldElement(new(big.Int).Add(u3Plus7, t2)).Sign() == 0 {
+		t = fieldElement(t.Lsh(t, 1))
+		t2 = fieldElement(new(big.Int).Mul(t, t))
+	}
+
+	// X = (u^3 + 7 - t^2) / (2t)
+	// Y = (X + t) / (sqrt(-3) * u)
+	x := fieldDiv(new(big.Int).Sub(u3Plus7, t2), new(big.Int).Lsh(t, 1))
+	y := fieldDiv(new(big.Int).Add(x, t), new(big.Int).Mul(sqrtMinus3, u))
+
+	// The candidates are u + 4Y^2, (-X/Y - u)/2 and (X/Y - u)/2, the first
+	// of which that's on the curve is returned.
+	x3 := new(big.Int).Mul(y, y)
+	x3 = fieldElement(x3.Lsh(x3, 2).Add(x3, u))
+	if isValidX(x3) {
+		return x3
+	}
+	xDivY := fieldDiv(x, y)
+	x1 := fieldDiv(new(big.Int).Sub(new(big.Int).Neg(xDivY), u), big.NewInt(2))
+	if isValidX(x1) {
+		return x1
+	}
+	return fieldDiv(new(big.Int).Sub(xDivY, u), big.NewInt(2))
+}
+
+// xSwiftECInv returns a field element t such that xSwiftEC(u, t) returns x, or
+// nil when there is none for the passed case, which selects one of up to eight
+// solutions, as specified by the xswiftec_inv function of BIP 324.
+func xSwiftECInv(x, u *big.Int, c int) *big.Int {
+	u3Plus7 := new(big.Int).Exp(u, big.NewInt(3), fieldPrime)
+	u3Plus7 = fieldElement(u3Plus7.Add(u3Plus7, big.NewInt(7)))
+
+	var v, s *big.Int
+	if c&2 == 0 {
+		// The decoder picks -x-u over x when it is on the curve.
+		if isValidX(fieldElement(new(big.Int).Neg(new(big.Int).Add(x, u)))) {
+			return nil
+		}
+
+		// s = -(u^3 + 7) / (u^2 + u*x + x^2)
+		v = x
+		denom := new(big.Int).Mul(u, u)
+		denom.Add(denom, new(big.Int).Mul(u, v))
+		denom.Add(denom, new(big.Int).Mul(v, v))
+		denom = fieldElement(denom)
+		if denom.Sign() == 0 {
+			return nil
+		}
+		s = fieldDiv(new(big.Int).Neg(u3Plus7), denom)
+	} else {
+		s = fieldElement(new(big.Int).Sub(x, u))
+		if s.Sign() == 0 {
+			return nil
+		}
+
+		// r = sqrt(-s * (4(u^3 + 7) + 3u^2 s))
+		inner := new(big.Int).Lsh(u3Plus7, 2)
+		u2s := new(big.Int).Mul(u, u)
+		u2s.Mul(u2s, s)
+		inner.Add(inner, u2s.Mul(u2s, big.NewInt(3)))
+		inner.Mul(inner, new(big.Int).Neg(s))
+		r := fieldSqrt(fieldElement(inner))
+		if r == nil {
+			return nil
+		}
+		if c&1 != 0 && r.Sign() == 0 {
+			return nil
+		}
+
+		// v = (r/s - u) / 2
+		v = fieldDiv(new(big.Int).Sub(fieldDiv(r, s), u), big.NewInt(2))
+	}
+
+	w := fieldSqrt(s)
+	if w == nil {
+		return nil
+	}
+
+	// The solution is w * (u * (1 -/+ sqrt(-3)) / 2 + v) with the sign of
+	// the result and of sqrt(-3) selected by the case.
+	var coeff *big.Int
+	if c&1 == 0 {
+		coeff = new(big.Int).Sub(big.NewInt(1), sqrtMinus3)
+	} else {
+		coeff = new(big.Int).Add(big.NewInt(1), sqrtMinus3)
+	}
+	t := fieldDiv(coeff.Mul(coeff, u), big.NewInt(2))
+	t.Add(t, v).Mul(t, w)
+	if c&5 == 0 || c&5 == 5 {
+		t.Neg(t)
+	}
+	return fieldElement(t)
+}
+
+// randomFieldElement returns a uniformly random non-zero field element.
+func randomFieldElement() (*big.Int, error) {
+	var b [32]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		v := new(big.Int).SetBytes(b[:])
+		if v.Sign() != 0 && v.Cmp(fieldPrime) < 0 {
+			return v, nil
+		}
+	}
+}
+
+// Encode returns a random ElligatorSwift encoding of the passed public key,
+// which consists of the 32-byte big-endian encodings of the field elements u and
+// t.  Only the X coordinate of the key is encoded.
+func Encode(pubKey *btcec.PublicKey) ([Size]byte, error) {
+	var encoded [Size]byte
+	x := pubKey.X()
+	var c [1]byte
+	for {
+		u, err := randomFieldElement()
+		if err != nil {
+			return encoded, err
+		}
+		if _, err := rand.Read(c[:]); err != nil {
+			return encoded, err
+		}
+		t := xSwiftECInv(x, u, int(c[0]&7))
+		if t == nil || xSwiftEC(u, t).Cmp(x) != 0 {
+			continue
+		}
+		u.FillBytes(encoded[:32])
+		t.FillBytes(encoded[32:])
+		return encoded, nil
+	}
+}
+
+// DecodeX returns the X coordinate of the public key the passed ElligatorSwift
+// encoding decodes to.  Every encoding of Size bytes is valid.
+func DecodeX(encoded []byte) *big.Int {
+	u := fieldElement(new(big.Int).SetBytes(encoded[:32]))
+	t := fieldElement(new(big.Int).SetBytes(encoded[32:Size]))
+	return xSwiftEC(u, t)
+}
+
+// Decode returns the public key with an even Y coordinate the passed
+// ElligatorSwift encoding decodes to.
+func Decode(encoded []byte) (*btcec.PublicKey, error) {
+	var compressed [33]byte
+	compressed[0] = 0x02
+	DecodeX(encoded).FillBytes(compressed[1:])
+	return btcec.ParsePubKey(compressed[:])
+}
+
+// XDH returns the shared secret of an ElligatorSwift key exchange between the
+// passed private key, whose public key is encoded as ours, and the public key
+// encoded as theirs as defined by the ellswift_ecdh_xonly function of BIP 324.
+// The encoding of the initiator of the exchange is hashed first.
+func XDH(privKey *btcec.PrivateKey, ours, theirs []byte,
+	initiator bool) ([32]byte, error) {
+
+	pubKey, err := Decode(theirs)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	var point, result btcec.JacobianPoint
+	pubKey.AsJacobian(&point)
+	btcec.ScalarMultNonConst(&privKey.Key, &point, &result)
+	if (result.X.IsZero() && result.Y.IsZero()) || result.Z.IsZero() {
+		return [32]byte{}, ErrInfinity
+	}
+	result.ToAffine()
+	x := result.X.Bytes()
+
+	ellA, ellB := ours, theirs
+	if !initiator {
+		ellA, ellB = theirs, ours
+	}
+	return *chainhash.TaggedHash(xdhTag, ellA, ellB, x[:]), nil
+}
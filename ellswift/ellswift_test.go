@@ -0,0 +1,119 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ellswift
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// TestEncode ensures public keys survive a round trip through their
+// ElligatorSwift encoding and both parties of a key exchange derive the same
+// shared secret.
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 16; i++ {
+		keyA, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatalf("unable to generate key: %v", err)
+		}
+		keyB, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatalf("unable to generate key: %v", err)
+		}
+		ellA, err := Encode(keyA.PubKey())
+		if err != nil {
+			t.Fatalf("Encode: unexpected error: %v", err)
+		}
+		ellB, err := Encode(keyB.PubKey())
+		if err != nil {
+			t.Fatalf("Encode: unexpected error: %v", err)
+		}
+		if got := DecodeX(ellA[:]); got.Cmp(keyA.PubKey().X()) != 0 {
+			t.Fatalf("DecodeX: got %x, want %x", got,
+				keyA.PubKey().X())
+		}
+
+		secretA, err := XDH(keyA, ellA[:], ellB[:], true)
+		if err != nil {
+			t.Fatalf("XDH: unexpected error: %v", err)
+		}
+		secretB, err := XDH(keyB, ellB[:], ellA[:], false)
+		if err != nil {
+			t.Fatalf("XDH: unexpected error: %v", err)
+		}
+		if secretA != secretB {
+			t.Fatalf("shared secrets differ: %x != %x", secretA,
+				secretB)
+		}
+
+		// The order of the encodings is part of the secret.
+		swapped, err := XDH(keyA, ellA[:], ellB[:], false)
+		if err != nil {
+			t.Fatalf("XDH: unexpected error: %v", err)
+		}
+		if swapped == secretA {
+			t.Fatal("shared secret doesn't depend on the roles")
+		}
+	}
+
+	// Every 64-byte string decodes to a point on the curve, including the
+	// ones with field elements that are zero or exceed the field prime.
+	var encoded [Size]byte
+	for i := range encoded {
+		encoded[i] = 0xff
+	}
+	for _, b := range [][]byte{make([]byte, Size), encoded[:]} {
+		if _, err := Decode(b); err != nil {
+			t.Fatalf("Decode(%x): unexpected error: %v", b, err)
+		}
+	}
+}
+
+// TestXSwiftECInv ensures every solution returned by the inverse decoding
+// function for any of its cases decodes to the X coordinate it was computed
+// for.
+func TestXSwiftECInv(t *testing.T) {
+	t.Parallel()
+
+	var found int
+	for i := 0; i < 8; i++ {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatalf("unable to generate key: %v", err)
+		}
+		x := key.PubKey().X()
+		u, err := randomFieldElement()
+		if err != nil {
+			t.Fatalf("randomFieldElement: unexpected error: %v", err)
+		}
+		for c := 0; c < 8; c++ {
+			tv := xSwiftECInv(x, u, c)
+			if tv == nil {
+				continue
+			}
+			found++
+			if got := xSwiftEC(u, tv); got.Cmp(x) != 0 {
+				t.Fatalf("xSwiftEC(%x, %x) for case %d: got %x, "+
+					"want %x", u, tv, c, got, x)
+			}
+		}
+	}
+	if found == 0 {
+		t.Fatal("xSwiftECInv found no solutions")
+	}
+
+	// The decoding of an X coordinate that isn't on the curve never
+	// succeeds, so every decoded value must be valid.
+	for _, u := range []int64{0, 1, 7} {
+		x := xSwiftEC(big.NewInt(u), big.NewInt(0))
+		if !isValidX(x) {
+			t.Fatalf("xSwiftEC(%d, 0) = %x is not on the curve", u, x)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package finality implements an optional finality gadget for permissioned
+networks.
+
+A fixed set of signers, identified by their public keys, attest to blocks of
+the main chain by signing their hash and height.  Attestations are gossiped
+between peers with the attest wire message.  Once a configured quorum of the
+signers has attested to a block, the block is finalized in the chain, which
+means the main chain can never be reorganized across it.
+
+Nodes that are configured with the private key of one of the signers attest
+to the main chain block at a configured depth below the tip whenever the tip
+changes.
+*/
+package finality
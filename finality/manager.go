@@ -0,0 +1,385 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package finality
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// attestationTag is prepended to the block hash and height that are signed by
+// an attestation so the signatures can't be mistaken for transaction or
+// message signatures made with the same key.
+var attestationTag = []byte("btcd finality attestation")
+
+// ErrUnknownSigner is returned by ProcessAttestation when the attestation is
+// not signed by one of the configured signers.
+var ErrUnknownSigner = errors.New("attestation is not from a known signer")
+
+// ErrInvalidSignature is returned by ProcessAttestation when the signature of
+// the attestation is not valid.
+var ErrInvalidSignature = errors.New("invalid attestation signature")
+
+// Config is a descriptor containing the finality manager configuration.
+type Config struct {
+	// Chain is the chain the attested blocks are finalized in.
+	Chain *blockchain.BlockChain
+
+	// Signers are the public keys of the signers that attest to blocks.
+	Signers []*btcec.PublicKey
+
+	// Quorum is the number of signers that must attest to a block for it
+	// to be finalized.
+	Quorum int
+
+	// SigningKey is the private key used to attest to blocks.  The
+	// matching public key must be one of the signers.  It is nil for
+	// nodes that only follow the attestations of the signers.
+	SigningKey *btcec.PrivateKey
+
+	// AttestDepth is the number of blocks below the tip of the main chain
+	// the block that is attested to with the signing key is at.  Zero
+	// means the tip itself is attested to.
+	AttestDepth int32
+
+	// Broadcast is invoked with the attestations made with the signing key
+	// so they can be sent to the peers.
+	Broadcast func(*wire.MsgAttest)
+}
+
+// Status describes the current state of the finality manager.
+type Status struct {
+	// Signers is the number of configured signers.
+	Signers int
+
+	// Quorum is the number of signers required to finalize a block.
+	Quorum int
+
+	// FinalizedHash and FinalizedHeight identify the most recent finalized
+	// block.  FinalizedHash is nil when no block has been finalized.
+	FinalizedHash   *chainhash.Hash
+	FinalizedHeight int32
+
+	// Pending is the number of blocks with attestations that did not reach
+	// the quorum yet.
+	Pending int
+}
+
+// pendingBlock houses the signers that attested to a block that is not
+// finalized yet.
+type pendingBlock struct {
+	height  int32
+	signers map[[wire.AttestPubKeySize]byte]struct{}
+}
+
+// Manager collects the attestations of the signers and finalizes the blocks
+// that reach the quorum.
+type Manager struct {
+	cfg     Config
+	signers map[[wire.AttestPubKeySize]byte]struct{}
+	pubKey  [wire.AttestPubKeySize]byte
+
+	mtx          sync.Mutex
+	pending      map[chainhash.Hash]*pendingBlock
+	lastAttested int32
+
+	tipChanged chan struct{}
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// AttestationHash returns the hash that is signed by an attestation for the
+// block with the passed hash and height.
+func AttestationHash(hash *chainhash.Hash, height int32) chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Grow(len(attestationTag) + chainhash.HashSize + 4)
+	buf.Write(attestationTag)
+	buf.Write(hash[:])
+	var heightBytes [4]byte
+	binary.LittleEndian.PutUint32(heightBytes[:], uint32(height))
+	buf.Write(heightBytes[:])
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// SignAttestation returns an attestation for the block with the passed hash and
+// height signed with the passed key.
+func SignAttestation(key *btcec.PrivateKey, hash *chainhash.Hash,
+	height int32) *wire.MsgAttest {
+
+	var pubKey [wire.AttestPubKeySize]byte
+	copy(pubKey[:], key.PubKey().SerializeCompressed())
+	sigHash := AttestationHash(hash, height)
+	sig := ecdsa.Sign(key, sigHash[:])
+	return wire.NewMsgAttest(hash, height, pubKey, sig.Serialize())
+}
+
+// New returns a new finality manager with the passed configuration.  Use Start
+// to begin attesting to blocks with the signing key.
+func New(cfg *Config) (*Manager, error) {
+	if len(cfg.Signers) == 0 {
+		return nil, errors.New("no finality signers")
+	}
+
+	signers := make(map[[wire.AttestPubKeySize]byte]struct{},
+		len(cfg.Signers))
+	for _, signer := range cfg.Signers {
+		var key [wire.AttestPubKeySize]byte
+		copy(key[:], signer.SerializeCompressed())
+		signers[key] = struct{}{}
+	}
+	if cfg.Quorum <= 0 || cfg.Quorum > len(signers) {
+		return nil, fmt.Errorf("finality quorum %d is not between 1 and "+
+			"the number of signers %d", cfg.Quorum, len(signers))
+	}
+	if cfg.AttestDepth < 0 {
+		return nil, fmt.Errorf("negative finality attest depth %d",
+			cfg.AttestDepth)
+	}
+
+	m := Manager{
+		cfg:        *cfg,
+		signers:    signers,
+		pending:    make(map[chainhash.Hash]*pendingBlock),
+		tipChanged: make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}
+	if cfg.SigningKey != nil {
+		copy(m.pubKey[:], cfg.SigningKey.PubKey().SerializeCompressed())
+		if _, ok := signers[m.pubKey]; !ok {
+			return nil, errors.New("finality signing key is not one " +
+				"of the signers")
+		}
+	}
+
+	cfg.Chain.Subscribe(m.handleBlockchainNotification)
+	return &m, nil
+}
+
+// handleBlockchainNotification wakes up the attestation handler whenever a
+// block is connected to the main chain.
+func (m *Manager) handleBlockchainNotification(n *blockchain.Notification) {
+	if n.Type != blockchain.NTBlockConnected {
+		return
+	}
+
+	// The chain lock is held while notifications are sent, so the
+	// handler can't be run synchronously.  Any number of tip changes are
+	// coalesced into a single wake up since only the latest tip matters.
+	select {
+	case m.tipChanged <- struct{}{}:
+	default:
+	}
+}
+
+// attestHandler attests to blocks with the signing key and finalizes blocks
+// that reached the quorum before they were connected whenever the tip of the
+// main chain changes.
+//
+// It must be run as a goroutine.
+func (m *Manager) attestHandler() {
+out:
+	for {
+		select {
+		case <-m.tipChanged:
+			m.finalizePending()
+			if m.cfg.SigningKey != nil {
+				m.attestTip()
+			}
+
+		case <-m.quit:
+			break out
+		}
+	}
+	m.wg.Done()
+}
+
+// attestTip attests to the main chain block at the attest depth below the tip
+// unless a block at that height or higher was already attested to.
+func (m *Manager) attestTip() {
+	best := m.cfg.Chain.BestSnapshot()
+	height := best.Height - m.cfg.AttestDepth
+	m.mtx.Lock()
+	attested := height <= m.lastAttested
+	m.mtx.Unlock()
+	if height <= 0 || attested {
+		return
+	}
+
+	hash, err := m.cfg.Chain.BlockHashByHeight(height)
+	if err != nil {
+		log.Debugf("Unable to look up block to attest at height %d: %v",
+			height, err)
+		return
+	}
+
+	msg := SignAttestation(m.cfg.SigningKey, hash, height)
+	if _, err := m.ProcessAttestation(msg); err != nil {
+		log.Errorf("Unable to process own attestation for block %v: %v",
+			hash, err)
+		return
+	}
+	m.mtx.Lock()
+	m.lastAttested = height
+	m.mtx.Unlock()
+
+	log.Debugf("Attested to block %v (height %d)", hash, height)
+	if m.cfg.Broadcast != nil {
+		m.cfg.Broadcast(msg)
+	}
+}
+
+// ProcessAttestation validates the passed attestation and records it.  The
+// attested block is finalized once the quorum of signers attested to it and it
+// is part of the main chain.  It returns whether or not the attestation was
+// new, in which case it should be relayed to the other peers.
+//
+// This function is safe for concurrent access.
+func (m *Manager) ProcessAttestation(msg *wire.MsgAttest) (bool, error) {
+	if _, ok := m.signers[msg.PubKey]; !ok {
+		return false, ErrUnknownSigner
+	}
+
+	// Ignore attestations for blocks at or before the finalized block
+	// since they can't change anything.
+	_, finalizedHeight := m.cfg.Chain.FinalizedBlock()
+	if msg.Height <= finalizedHeight {
+		return false, nil
+	}
+
+	m.mtx.Lock()
+	pending, ok := m.pending[msg.BlockHash]
+	if ok && pending.height == msg.Height {
+		if _, ok := pending.signers[msg.PubKey]; ok {
+			m.mtx.Unlock()
+			return false, nil
+		}
+	}
+	m.mtx.Unlock()
+
+	pubKey, err := btcec.ParsePubKey(msg.PubKey[:])
+	if err != nil {
+		return false, ErrUnknownSigner
+	}
+	sig, err := ecdsa.ParseDERSignature(msg.Signature)
+	if err != nil {
+		return false, ErrInvalidSignature
+	}
+	sigHash := AttestationHash(&msg.BlockHash, msg.Height)
+	if !sig.Verify(sigHash[:], pubKey) {
+		return false, ErrInvalidSignature
+	}
+
+	m.mtx.Lock()
+	pending, ok = m.pending[msg.BlockHash]
+	if !ok || pending.height != msg.Height {
+		// Attestations for a mismatched height are from a faulty
+		// signer, so only keep the ones for the first height seen.
+		if ok {
+			m.mtx.Unlock()
+			return false, nil
+		}
+		pending = &pendingBlock{
+			height:  msg.Height,
+			signers: make(map[[wire.AttestPubKeySize]byte]struct{}),
+		}
+		m.pending[msg.BlockHash] = pending
+	}
+	if _, ok := pending.signers[msg.PubKey]; ok {
+		m.mtx.Unlock()
+		return false, nil
+	}
+	pending.signers[msg.PubKey] = struct{}{}
+	m.mtx.Unlock()
+
+	log.Debugf("Received attestation for block %v (height %d) from "+
+		"signer %x", msg.BlockHash, msg.Height, msg.PubKey)
+	m.finalizePending()
+	return true, nil
+}
+
+// finalizePending finalizes the highest main chain block that reached the
+// quorum and removes the attestations that are no longer needed.
+func (m *Manager) finalizePending() {
+	chain := m.cfg.Chain
+
+	m.mtx.Lock()
+	var bestHash *chainhash.Hash
+	var bestHeight int32
+	for hash, pending := range m.pending {
+		if len(pending.signers) < m.cfg.Quorum ||
+			pending.height <= bestHeight {
+
+			continue
+		}
+		hash := hash
+		height, err := chain.BlockHeightByHash(&hash)
+		if err != nil || height != pending.height {
+			continue
+		}
+		bestHash, bestHeight = &hash, height
+	}
+	m.mtx.Unlock()
+
+	if bestHash != nil {
+		err := chain.FinalizeBlock(bestHash)
+		if err != nil {
+			log.Warnf("Unable to finalize block %v: %v", bestHash,
+				err)
+		}
+	}
+
+	_, finalizedHeight := chain.FinalizedBlock()
+	m.mtx.Lock()
+	for hash, pending := range m.pending {
+		if pending.height <= finalizedHeight {
+			delete(m.pending, hash)
+		}
+	}
+	m.mtx.Unlock()
+}
+
+// Status returns the current state of the finality manager.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Status() *Status {
+	hash, height := m.cfg.Chain.FinalizedBlock()
+	m.mtx.Lock()
+	pending := len(m.pending)
+	m.mtx.Unlock()
+
+	return &Status{
+		Signers:         len(m.signers),
+		Quorum:          m.cfg.Quorum,
+		FinalizedHash:   hash,
+		FinalizedHeight: height,
+		Pending:         pending,
+	}
+}
+
+// Start begins attesting to blocks with the signing key, if any.
+func (m *Manager) Start() {
+	m.wg.Add(1)
+	go m.attestHandler()
+
+	// Attest to the current tip right away.
+	m.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockConnected,
+	})
+}
+
+// Stop stops the manager and waits for it to finish.
+func (m *Manager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package finality
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+)
+
+// newTestChain returns a chain instance backed by a temporary database that
+// only contains the genesis block.
+func newTestChain(t *testing.T) *blockchain.BlockChain {
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", t.TempDir(), params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    nil,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+	return chain
+}
+
+// TestProcessAttestation ensures attestations are validated and collected.
+func TestProcessAttestation(t *testing.T) {
+	chain := newTestChain(t)
+
+	var keys []*btcec.PrivateKey
+	var signers []*btcec.PublicKey
+	for i := 0; i < 3; i++ {
+		key, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatalf("unable to generate key: %v", err)
+		}
+		keys = append(keys, key)
+		signers = append(signers, key.PubKey())
+	}
+
+	// Ensure invalid configurations are rejected.
+	badConfigs := []Config{
+		{Chain: chain, Quorum: 1},
+		{Chain: chain, Signers: signers, Quorum: 0},
+		{Chain: chain, Signers: signers, Quorum: 4},
+		{Chain: chain, Signers: signers[1:], Quorum: 1,
+			SigningKey: keys[0]},
+	}
+	for i, cfg := range badConfigs {
+		if _, err := New(&cfg); err == nil {
+			t.Errorf("config #%d: expected error", i)
+		}
+	}
+
+	m, err := New(&Config{Chain: chain, Signers: signers, Quorum: 2})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	hash := chainhash.Hash{0x01}
+	msg := SignAttestation(keys[0], &hash, 1)
+
+	// Attestations from unknown signers must be rejected.
+	unknownKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	_, err = m.ProcessAttestation(SignAttestation(unknownKey, &hash, 1))
+	if err != ErrUnknownSigner {
+		t.Fatalf("unknown signer: got error %v, want %v", err,
+			ErrUnknownSigner)
+	}
+
+	// Attestations with a signature that doesn't commit to the block
+	// height must be rejected.
+	badMsg := *msg
+	badMsg.Height = 2
+	if _, err := m.ProcessAttestation(&badMsg); err != ErrInvalidSignature {
+		t.Fatalf("bad signature: got error %v, want %v", err,
+			ErrInvalidSignature)
+	}
+
+	// A valid attestation is new the first time only.
+	for i, wantNew := range []bool{true, false} {
+		isNew, err := m.ProcessAttestation(msg)
+		if err != nil {
+			t.Fatalf("attestation #%d: unexpected error: %v", i, err)
+		}
+		if isNew != wantNew {
+			t.Fatalf("attestation #%d: got new %v, want %v", i,
+				isNew, wantNew)
+		}
+	}
+
+	// Reaching the quorum for an unknown block must leave the block
+	// pending.
+	isNew, err := m.ProcessAttestation(SignAttestation(keys[1], &hash, 1))
+	if err != nil || !isNew {
+		t.Fatalf("second signer: got new %v, error %v", isNew, err)
+	}
+	status := m.Status()
+	if status.Signers != 3 || status.Quorum != 2 || status.Pending != 1 ||
+		status.FinalizedHash != nil {
+
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// Attestations for the genesis block are ignored since it can't be
+	// reorganized anyways.
+	genesis := chaincfg.RegressionNetParams.GenesisHash
+	isNew, err = m.ProcessAttestation(SignAttestation(keys[0], genesis, 0))
+	if err != nil || isNew {
+		t.Fatalf("genesis: got new %v, error %v", isNew, err)
+	}
+}
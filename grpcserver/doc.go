@@ -0,0 +1,34 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package grpcserver serves a gRPC interface to the core data of the node next to
+the JSON-RPC server, for services which prefer typed requests and streams over
+JSON-RPC and websocket notifications.
+
+The service is defined in btcd.proto, from which clients can generate their
+stubs in any language supported by protoc.  It provides:
+
+  - GetBestBlock, GetBlock and GetBlockHeaders to query the main chain
+  - GetMempool and GetRawTransaction to query the memory pool and, when the
+    transaction index is enabled, confirmed transactions
+  - SendRawTransaction to submit and relay transactions
+  - SubscribeBlocks and SubscribeTransactions to stream the blocks connected to
+    and disconnected from the main chain and the transactions accepted to the
+    memory pool
+
+The gRPC protocol is implemented on top of the HTTP/2 support of net/http and
+the messages are encoded by hand, so the package doesn't depend on the gRPC and
+protocol buffer runtimes.  Compressed messages are not supported.  HTTP/2 is
+only negotiated over TLS, so the server always uses TLS.
+
+Clients authenticate with a bearer token in the authorization header of each
+call:
+
+	authorization: Bearer <token>
+
+A stream is terminated with the RESOURCE_EXHAUSTED status when the client falls
+too far behind, so a slow client can't hold up the node.
+*/
+package grpcserver
@@ -0,0 +1,484 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcserver
+
+// The types in this file are the messages defined in btcd.proto.  They are
+// encoded by hand so the package doesn't depend on a protocol buffer runtime.
+// Unknown fields are ignored when decoding as required by proto3.
+
+// Message is a protocol buffer message of the gRPC interface.
+type Message interface {
+	// Marshal returns the encoding of the message.
+	Marshal() []byte
+
+	// Unmarshal decodes the passed encoding into the message.
+	Unmarshal(b []byte) error
+}
+
+// skipFields ensures the passed encoding is well-formed without decoding any
+// of its fields.  It is used by the messages without fields.
+func skipFields(b []byte) error {
+	return decodeFields(b, func(*field) error { return nil })
+}
+
+// GetBestBlockRequest is the request of GetBestBlock.
+type GetBestBlockRequest struct{}
+
+// Marshal returns the encoding of the message.
+func (m *GetBestBlockRequest) Marshal() []byte { return nil }
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetBestBlockRequest) Unmarshal(b []byte) error { return skipFields(b) }
+
+// GetBestBlockResponse is the response of GetBestBlock.
+type GetBestBlockResponse struct {
+	Hash   []byte
+	Height int32
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetBestBlockResponse) Marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.Hash)
+	b = appendInt32Field(b, 2, m.Height)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetBestBlockResponse) Unmarshal(b []byte) error {
+	*m = GetBestBlockResponse{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.Hash, err = f.bytes()
+		case 2:
+			m.Height, err = f.int32()
+		}
+		return err
+	})
+}
+
+// GetBlockRequest is the request of GetBlock.  The block of the main chain at
+// Height is requested when Hash is empty.
+type GetBlockRequest struct {
+	Hash   []byte
+	Height int32
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetBlockRequest) Marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.Hash)
+	b = appendInt32Field(b, 2, m.Height)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetBlockRequest) Unmarshal(b []byte) error {
+	*m = GetBlockRequest{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.Hash, err = f.bytes()
+		case 2:
+			m.Height, err = f.int32()
+		}
+		return err
+	})
+}
+
+// GetBlockResponse is the response of GetBlock.
+type GetBlockResponse struct {
+	Hash          []byte
+	Height        int32
+	Confirmations int32
+	Block         []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetBlockResponse) Marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.Hash)
+	b = appendInt32Field(b, 2, m.Height)
+	b = appendInt32Field(b, 3, m.Confirmations)
+	b = appendBytesField(b, 4, m.Block)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetBlockResponse) Unmarshal(b []byte) error {
+	*m = GetBlockResponse{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.Hash, err = f.bytes()
+		case 2:
+			m.Height, err = f.int32()
+		case 3:
+			m.Confirmations, err = f.int32()
+		case 4:
+			m.Block, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// GetBlockHeadersRequest is the request of GetBlockHeaders.
+type GetBlockHeadersRequest struct {
+	StartHeight int32
+	Count       uint32
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetBlockHeadersRequest) Marshal() []byte {
+	var b []byte
+	b = appendInt32Field(b, 1, m.StartHeight)
+	b = appendVarintField(b, 2, uint64(m.Count))
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetBlockHeadersRequest) Unmarshal(b []byte) error {
+	*m = GetBlockHeadersRequest{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.StartHeight, err = f.int32()
+		case 2:
+			m.Count, err = f.uint32()
+		}
+		return err
+	})
+}
+
+// GetBlockHeadersResponse is the response of GetBlockHeaders.
+type GetBlockHeadersResponse struct {
+	Headers [][]byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetBlockHeadersResponse) Marshal() []byte {
+	var b []byte
+	for _, header := range m.Headers {
+		b = appendRepeatedBytes(b, 1, header)
+	}
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetBlockHeadersResponse) Unmarshal(b []byte) error {
+	*m = GetBlockHeadersResponse{}
+	return decodeFields(b, func(f *field) error {
+		if f.num != 1 {
+			return nil
+		}
+		header, err := f.bytes()
+		if err != nil {
+			return err
+		}
+		m.Headers = append(m.Headers, header)
+		return nil
+	})
+}
+
+// GetMempoolRequest is the request of GetMempool.
+type GetMempoolRequest struct{}
+
+// Marshal returns the encoding of the message.
+func (m *GetMempoolRequest) Marshal() []byte { return nil }
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetMempoolRequest) Unmarshal(b []byte) error { return skipFields(b) }
+
+// MempoolEntry describes a transaction of the memory pool.
+type MempoolEntry struct {
+	TxID   []byte
+	Fee    int64
+	VSize  int64
+	Time   int64
+	Height int32
+}
+
+// Marshal returns the encoding of the message.
+func (m *MempoolEntry) Marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.TxID)
+	b = appendInt64Field(b, 2, m.Fee)
+	b = appendInt64Field(b, 3, m.VSize)
+	b = appendInt64Field(b, 4, m.Time)
+	b = appendInt32Field(b, 5, m.Height)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *MempoolEntry) Unmarshal(b []byte) error {
+	*m = MempoolEntry{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.TxID, err = f.bytes()
+		case 2:
+			m.Fee, err = f.int64()
+		case 3:
+			m.VSize, err = f.int64()
+		case 4:
+			m.Time, err = f.int64()
+		case 5:
+			m.Height, err = f.int32()
+		}
+		return err
+	})
+}
+
+// GetMempoolResponse is the response of GetMempool.
+type GetMempoolResponse struct {
+	Entries []*MempoolEntry
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetMempoolResponse) Marshal() []byte {
+	var b []byte
+	for _, entry := range m.Entries {
+		b = appendRepeatedBytes(b, 1, entry.Marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetMempoolResponse) Unmarshal(b []byte) error {
+	*m = GetMempoolResponse{}
+	return decodeFields(b, func(f *field) error {
+		if f.num != 1 {
+			return nil
+		}
+		if err := f.expect(wireBytes); err != nil {
+			return err
+		}
+		var entry MempoolEntry
+		if err := entry.Unmarshal(f.data); err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, &entry)
+		return nil
+	})
+}
+
+// GetRawTransactionRequest is the request of GetRawTransaction.
+type GetRawTransactionRequest struct {
+	TxID []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetRawTransactionRequest) Marshal() []byte {
+	return appendBytesField(nil, 1, m.TxID)
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetRawTransactionRequest) Unmarshal(b []byte) error {
+	*m = GetRawTransactionRequest{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		if f.num == 1 {
+			m.TxID, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// GetRawTransactionResponse is the response of GetRawTransaction.  BlockHash
+// is empty for transactions of the memory pool.
+type GetRawTransactionResponse struct {
+	Transaction []byte
+	BlockHash   []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *GetRawTransactionResponse) Marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.Transaction)
+	b = appendBytesField(b, 2, m.BlockHash)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *GetRawTransactionResponse) Unmarshal(b []byte) error {
+	*m = GetRawTransactionResponse{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.Transaction, err = f.bytes()
+		case 2:
+			m.BlockHash, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// SendRawTransactionRequest is the request of SendRawTransaction.
+type SendRawTransactionRequest struct {
+	Transaction []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *SendRawTransactionRequest) Marshal() []byte {
+	return appendBytesField(nil, 1, m.Transaction)
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *SendRawTransactionRequest) Unmarshal(b []byte) error {
+	*m = SendRawTransactionRequest{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		if f.num == 1 {
+			m.Transaction, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// SendRawTransactionResponse is the response of SendRawTransaction.
+type SendRawTransactionResponse struct {
+	TxID []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *SendRawTransactionResponse) Marshal() []byte {
+	return appendBytesField(nil, 1, m.TxID)
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *SendRawTransactionResponse) Unmarshal(b []byte) error {
+	*m = SendRawTransactionResponse{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		if f.num == 1 {
+			m.TxID, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// SubscribeBlocksRequest is the request of SubscribeBlocks.
+type SubscribeBlocksRequest struct{}
+
+// Marshal returns the encoding of the message.
+func (m *SubscribeBlocksRequest) Marshal() []byte { return nil }
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *SubscribeBlocksRequest) Unmarshal(b []byte) error { return skipFields(b) }
+
+// BlockNotificationType is the type of a BlockNotification.
+type BlockNotificationType int32
+
+// Types of block notifications.
+const (
+	BlockConnected    BlockNotificationType = 0
+	BlockDisconnected BlockNotificationType = 1
+)
+
+// BlockNotification is a message of the SubscribeBlocks stream.
+type BlockNotification struct {
+	Type   BlockNotificationType
+	Hash   []byte
+	Height int32
+	Header []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *BlockNotification) Marshal() []byte {
+	var b []byte
+	b = appendInt32Field(b, 1, int32(m.Type))
+	b = appendBytesField(b, 2, m.Hash)
+	b = appendInt32Field(b, 3, m.Height)
+	b = appendBytesField(b, 4, m.Header)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *BlockNotification) Unmarshal(b []byte) error {
+	*m = BlockNotification{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			var typ int32
+			typ, err = f.int32()
+			m.Type = BlockNotificationType(typ)
+		case 2:
+			m.Hash, err = f.bytes()
+		case 3:
+			m.Height, err = f.int32()
+		case 4:
+			m.Header, err = f.bytes()
+		}
+		return err
+	})
+}
+
+// SubscribeTransactionsRequest is the request of SubscribeTransactions.
+type SubscribeTransactionsRequest struct {
+	IncludeTransaction bool
+}
+
+// Marshal returns the encoding of the message.
+func (m *SubscribeTransactionsRequest) Marshal() []byte {
+	return appendBoolField(nil, 1, m.IncludeTransaction)
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *SubscribeTransactionsRequest) Unmarshal(b []byte) error {
+	*m = SubscribeTransactionsRequest{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		if f.num == 1 {
+			m.IncludeTransaction, err = f.bool()
+		}
+		return err
+	})
+}
+
+// TransactionNotification is a message of the SubscribeTransactions stream.
+// Transaction is only set when it was requested.
+type TransactionNotification struct {
+	TxID        []byte
+	Fee         int64
+	VSize       int64
+	Transaction []byte
+}
+
+// Marshal returns the encoding of the message.
+func (m *TransactionNotification) Marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.TxID)
+	b = appendInt64Field(b, 2, m.Fee)
+	b = appendInt64Field(b, 3, m.VSize)
+	b = appendBytesField(b, 4, m.Transaction)
+	return b
+}
+
+// Unmarshal decodes the passed encoding into the message.
+func (m *TransactionNotification) Unmarshal(b []byte) error {
+	*m = TransactionNotification{}
+	return decodeFields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.TxID, err = f.bytes()
+		case 2:
+			m.Fee, err = f.int64()
+		case 3:
+			m.VSize, err = f.int64()
+		case 4:
+			m.Transaction, err = f.bytes()
+		}
+		return err
+	})
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcserver
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Wire types of the protocol buffer encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errMalformedMessage is returned when a message can't be decoded.
+var errMalformedMessage = errors.New("malformed protocol buffer message")
+
+// appendVarint appends the passed value as a base 128 varint.
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// appendTag appends the key of the field with the passed number and wire
+// type.
+func appendTag(b []byte, num int, wireType int) []byte {
+	return appendVarint(b, uint64(num)<<3|uint64(wireType))
+}
+
+// appendVarintField appends a varint field unless the value is zero, which is
+// the default value that proto3 doesn't encode.
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, num, wireVarint)
+	return appendVarint(b, v)
+}
+
+// appendInt32Field appends an int32 field.  Negative values are sign extended
+// to 64 bits as required by the encoding.
+func appendInt32Field(b []byte, num int, v int32) []byte {
+	return appendVarintField(b, num, uint64(int64(v)))
+}
+
+// appendInt64Field appends an int64 field.
+func appendInt64Field(b []byte, num int, v int64) []byte {
+	return appendVarintField(b, num, uint64(v))
+}
+
+// appendBoolField appends a bool field.
+func appendBoolField(b []byte, num int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarintField(b, num, 1)
+}
+
+// appendBytesField appends a bytes field unless it is empty.
+func appendBytesField(b []byte, num int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendRepeatedBytes(b, num, v)
+}
+
+// appendRepeatedBytes appends an element of a repeated bytes or message field,
+// which is encoded even when it is empty.
+func appendRepeatedBytes(b []byte, num int, v []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// field is a field of a decoded message.  Varint fields have their value set
+// while length-delimited fields have their data set.
+type field struct {
+	num      int
+	wireType int
+	value    uint64
+	data     []byte
+}
+
+// decodeFields calls fn with each field of the passed message in the order
+// they are encoded.  Fields with the fixed size wire types are skipped since
+// none of the messages use them.
+func decodeFields(b []byte, fn func(f *field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformedMessage
+		}
+		b = b[n:]
+
+		f := field{num: int(key >> 3), wireType: int(key & 7)}
+		if f.num <= 0 {
+			return errMalformedMessage
+		}
+		switch f.wireType {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformedMessage
+			}
+			b = b[n:]
+
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return errMalformedMessage
+			}
+			f.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+
+		case wireFixed64:
+			if len(b) < 8 {
+				return errMalformedMessage
+			}
+			b = b[8:]
+			continue
+
+		case wireFixed32:
+			if len(b) < 4 {
+				return errMalformedMessage
+			}
+			b = b[4:]
+			continue
+
+		default:
+			return errMalformedMessage
+		}
+
+		if err := fn(&f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expect returns an error when the field isn't encoded with the passed wire
+// type.
+func (f *field) expect(wireType int) error {
+	if f.wireType != wireType {
+		return errMalformedMessage
+	}
+	return nil
+}
+
+// bytes returns a copy of the data of a length-delimited field.
+func (f *field) bytes() ([]byte, error) {
+	if err := f.expect(wireBytes); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// int32 returns the value of a varint field as an int32.
+func (f *field) int32() (int32, error) {
+	if err := f.expect(wireVarint); err != nil {
+		return 0, err
+	}
+	return int32(f.value), nil
+}
+
+// uint32 returns the value of a varint field as a uint32.
+func (f *field) uint32() (uint32, error) {
+	if err := f.expect(wireVarint); err != nil {
+		return 0, err
+	}
+	return uint32(f.value), nil
+}
+
+// int64 returns the value of a varint field as an int64.
+func (f *field) int64() (int64, error) {
+	if err := f.expect(wireVarint); err != nil {
+		return 0, err
+	}
+	return int64(f.value), nil
+}
+
+// bool returns the value of a varint field as a bool.
+func (f *field) bool() (bool, error) {
+	if err := f.expect(wireVarint); err != nil {
+		return false, err
+	}
+	return f.value != 0, nil
+}
@@ -0,0 +1,651 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcserver
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// servicePrefix is the path prefix of the methods of the service.
+	servicePrefix = "/btcd.Btcd/"
+
+	// maxHeadersPerRequest is the maximum number of headers returned by
+	// GetBlockHeaders, which matches the limit of the headers message of
+	// the wire protocol.
+	maxHeadersPerRequest = wire.MaxBlockHeadersPerMsg
+
+	// notificationQueueSize is the number of notifications queued for a
+	// stream.  Streams which fall further behind are terminated so a slow
+	// client can't hold up the node.
+	notificationQueueSize = 1000
+
+	// readHeaderTimeout is the maximum amount of time a client may take to
+	// send the headers of a request.
+	readHeaderTimeout = 10 * time.Second
+)
+
+// Chain provides the main chain data served by the server.
+// *blockchain.BlockChain implements it.
+type Chain interface {
+	BestSnapshot() *blockchain.BestState
+	BlockByHash(hash *chainhash.Hash) (*btcutil.Block, error)
+	BlockHashByHeight(height int32) (*chainhash.Hash, error)
+	HeaderByHash(hash *chainhash.Hash) (wire.BlockHeader, error)
+}
+
+// TxSource provides the memory pool data served by the server.
+// *mempool.TxPool implements it.
+type TxSource interface {
+	TxDescs() []*mempool.TxDesc
+	FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
+}
+
+// Config is the configuration of a Server.
+type Config struct {
+	// Listeners defines a slice of listeners for which the server will
+	// serve the gRPC interface over TLS.
+	Listeners []net.Listener
+
+	// Chain is the chain the blocks and headers are served from.
+	Chain Chain
+
+	// TxMemPool is the memory pool the transactions are served from.
+	TxMemPool TxSource
+
+	// FetchConfirmedTx returns the serialized transaction with the passed
+	// hash along with the hash of the block of the main chain which
+	// includes it, or nil when the transaction isn't known.  It is nil
+	// when the transaction index is disabled, in which case only the
+	// transactions of the memory pool are served.
+	FetchConfirmedTx func(txHash *chainhash.Hash) ([]byte, *chainhash.Hash, error)
+
+	// ProcessTransaction submits the passed transaction to the memory pool
+	// and relays it along with the transactions it makes acceptable.
+	ProcessTransaction func(tx *btcutil.Tx) error
+
+	// Token is the bearer token clients must send in the authorization
+	// header.  Clients aren't authenticated when it is empty.
+	Token string
+
+	// TLSConfig is the TLS configuration of the listeners.  gRPC requires
+	// HTTP/2, which is only negotiated over TLS.
+	TLSConfig *tls.Config
+}
+
+// subscription houses the notification queue of a stream.
+type subscription struct {
+	msgs     chan Message
+	overflow chan struct{}
+
+	// includeTx specifies whether the serialized transactions are
+	// included in transaction notifications.
+	includeTx bool
+}
+
+// Server serves the gRPC interface of the node defined in btcd.proto.
+type Server struct {
+	cfg        Config
+	httpServer *http.Server
+	started    int32
+	shutdown   int32
+
+	mtx       sync.Mutex
+	blockSubs map[*subscription]struct{}
+	txSubs    map[*subscription]struct{}
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New returns a new gRPC server with the passed configuration.
+func New(cfg *Config) *Server {
+	s := &Server{
+		cfg:       *cfg,
+		blockSubs: make(map[*subscription]struct{}),
+		txSubs:    make(map[*subscription]struct{}),
+		quit:      make(chan struct{}),
+	}
+	s.httpServer = &http.Server{
+		Handler:           s,
+		TLSConfig:         cfg.TLSConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	return s
+}
+
+// Start begins serving the configured listeners.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go func(listener net.Listener) {
+			defer s.wg.Done()
+
+			log.Infof("gRPC server listening on %s", listener.Addr())
+			err := s.httpServer.ServeTLS(listener, "", "")
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("gRPC server on %s failed: %v",
+					listener.Addr(), err)
+			}
+		}(listener)
+	}
+}
+
+// Stop terminates the streams, closes the listeners and waits for the server
+// to finish.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		return
+	}
+
+	log.Infof("gRPC server shutting down")
+	close(s.quit)
+	s.httpServer.Close()
+	s.wg.Wait()
+}
+
+// unaryHandler handles a method returning a single response.
+type unaryHandler func(s *Server, req []byte) (Message, error)
+
+// streamHandler handles a method returning a stream of responses.  It returns
+// once the stream ends, which happens when the done channel is closed.
+type streamHandler func(s *Server, req []byte, w http.ResponseWriter,
+	done <-chan struct{}) error
+
+// unaryHandlers maps the names of the methods returning a single response to
+// their handlers.
+var unaryHandlers = map[string]unaryHandler{
+	"GetBestBlock":       handleGetBestBlock,
+	"GetBlock":           handleGetBlock,
+	"GetBlockHeaders":    handleGetBlockHeaders,
+	"GetMempool":         handleGetMempool,
+	"GetRawTransaction":  handleGetRawTransaction,
+	"SendRawTransaction": handleSendRawTransaction,
+}
+
+// streamHandlers maps the names of the methods returning a stream of responses
+// to their handlers.
+var streamHandlers = map[string]streamHandler{
+	"SubscribeBlocks":       handleSubscribeBlocks,
+	"SubscribeTransactions": handleSubscribeTransactions,
+}
+
+// ServeHTTP handles a gRPC request.  The status of the call is sent in the
+// trailers of the response.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2",
+			http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), contentType) {
+		http.Error(w, "unsupported content type",
+			http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	err := s.serve(w, r)
+	if err != nil {
+		log.Debugf("gRPC call %s from %s failed: %v", r.URL.Path,
+			r.RemoteAddr, err)
+	}
+	writeStatus(w, err)
+}
+
+// serve authenticates the passed request and calls the handler of the
+// requested method.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	if !s.authenticated(r) {
+		return statusError(CodeUnauthenticated, "invalid token")
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, servicePrefix)
+	unary, isUnary := unaryHandlers[name]
+	stream, isStream := streamHandlers[name]
+	if name == r.URL.Path || (!isUnary && !isStream) {
+		return statusError(CodeUnimplemented, "unknown method %s",
+			r.URL.Path)
+	}
+
+	req, err := readMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	if isStream {
+		return stream(s, req, w, r.Context().Done())
+	}
+	resp, err := unary(s, req)
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, resp)
+}
+
+// authenticated returns whether the passed request carries the configured
+// bearer token.
+func (s *Server) authenticated(r *http.Request) bool {
+	if s.cfg.Token == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(auth, prefix))
+	return subtle.ConstantTimeCompare(token, []byte(s.cfg.Token)) == 1
+}
+
+// unmarshalRequest decodes the passed request into the passed message.
+func unmarshalRequest(req []byte, msg Message) error {
+	if err := msg.Unmarshal(req); err != nil {
+		return statusError(CodeInvalidArgument, "%v", err)
+	}
+	return nil
+}
+
+// parseHash returns the hash of the passed bytes.
+func parseHash(b []byte) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHash(b)
+	if err != nil {
+		return nil, statusError(CodeInvalidArgument, "%v", err)
+	}
+	return hash, nil
+}
+
+// handleGetBestBlock implements the GetBestBlock method.
+func handleGetBestBlock(s *Server, req []byte) (Message, error) {
+	var r GetBestBlockRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return nil, err
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	return &GetBestBlockResponse{
+		Hash:   best.Hash.CloneBytes(),
+		Height: best.Height,
+	}, nil
+}
+
+// handleGetBlock implements the GetBlock method.
+func handleGetBlock(s *Server, req []byte) (Message, error) {
+	var r GetBlockRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return nil, err
+	}
+
+	var hash *chainhash.Hash
+	if len(r.Hash) != 0 {
+		var err error
+		hash, err = parseHash(r.Hash)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		hash, err = s.cfg.Chain.BlockHashByHeight(r.Height)
+		if err != nil {
+			return nil, statusError(CodeNotFound, "%v", err)
+		}
+	}
+
+	block, err := s.cfg.Chain.BlockByHash(hash)
+	if err != nil {
+		return nil, statusError(CodeNotFound, "%v", err)
+	}
+	serialized, err := block.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	best := s.cfg.Chain.BestSnapshot()
+	return &GetBlockResponse{
+		Hash:          hash.CloneBytes(),
+		Height:        block.Height(),
+		Confirmations: best.Height - block.Height() + 1,
+		Block:         serialized,
+	}, nil
+}
+
+// handleGetBlockHeaders implements the GetBlockHeaders method.
+func handleGetBlockHeaders(s *Server, req []byte) (Message, error) {
+	var r GetBlockHeadersRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return nil, err
+	}
+	if r.StartHeight < 0 {
+		return nil, statusError(CodeInvalidArgument,
+			"negative start height %d", r.StartHeight)
+	}
+	if r.Count > maxHeadersPerRequest {
+		return nil, statusError(CodeInvalidArgument, "count %d exceeds "+
+			"the maximum of %d", r.Count, maxHeadersPerRequest)
+	}
+
+	// Limit the headers to the ones of the main chain.
+	count := int64(r.Count)
+	best := s.cfg.Chain.BestSnapshot()
+	if available := int64(best.Height) - int64(r.StartHeight) + 1; available < count {
+		count = available
+	}
+
+	resp := &GetBlockHeadersResponse{}
+	var buf bytes.Buffer
+	for i := int64(0); i < count; i++ {
+		height := r.StartHeight + int32(i)
+		hash, err := s.cfg.Chain.BlockHashByHeight(height)
+		if err != nil {
+			// The chain was reorganized to a lower height since the
+			// snapshot was taken.
+			break
+		}
+		header, err := s.cfg.Chain.HeaderByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		buf.Reset()
+		if err := header.Serialize(&buf); err != nil {
+			return nil, err
+		}
+		resp.Headers = append(resp.Headers,
+			append([]byte(nil), buf.Bytes()...))
+	}
+	return resp, nil
+}
+
+// handleGetMempool implements the GetMempool method.
+func handleGetMempool(s *Server, req []byte) (Message, error) {
+	var r GetMempoolRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return nil, err
+	}
+
+	txDescs := s.cfg.TxMemPool.TxDescs()
+	resp := &GetMempoolResponse{
+		Entries: make([]*MempoolEntry, 0, len(txDescs)),
+	}
+	for _, txD := range txDescs {
+		resp.Entries = append(resp.Entries, &MempoolEntry{
+			TxID:   txD.Tx.Hash().CloneBytes(),
+			Fee:    txD.Fee,
+			VSize:  mempool.GetTxVirtualSize(txD.Tx),
+			Time:   txD.Added.Unix(),
+			Height: txD.Height,
+		})
+	}
+	return resp, nil
+}
+
+// serializeTx returns the serialization of the passed transaction.
+func serializeTx(tx *btcutil.Tx) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(tx.MsgTx().SerializeSize())
+	if err := tx.MsgTx().Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// handleGetRawTransaction implements the GetRawTransaction method.
+func handleGetRawTransaction(s *Server, req []byte) (Message, error) {
+	var r GetRawTransactionRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return nil, err
+	}
+	txHash, err := parseHash(r.TxID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx, err := s.cfg.TxMemPool.FetchTransaction(txHash); err == nil {
+		serialized, err := serializeTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		return &GetRawTransactionResponse{Transaction: serialized}, nil
+	}
+
+	if s.cfg.FetchConfirmedTx == nil {
+		return nil, statusError(CodeNotFound, "transaction %v is not in "+
+			"the memory pool and the transaction index is disabled",
+			txHash)
+	}
+	serialized, blockHash, err := s.cfg.FetchConfirmedTx(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if serialized == nil {
+		return nil, statusError(CodeNotFound, "transaction %v not found",
+			txHash)
+	}
+	return &GetRawTransactionResponse{
+		Transaction: serialized,
+		BlockHash:   blockHash.CloneBytes(),
+	}, nil
+}
+
+// handleSendRawTransaction implements the SendRawTransaction method.
+func handleSendRawTransaction(s *Server, req []byte) (Message, error) {
+	var r SendRawTransactionRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return nil, err
+	}
+
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(r.Transaction)); err != nil {
+		return nil, statusError(CodeInvalidArgument,
+			"unable to decode transaction: %v", err)
+	}
+	tx := btcutil.NewTx(&msgTx)
+	if err := s.cfg.ProcessTransaction(tx); err != nil {
+		if _, ok := err.(mempool.RuleError); ok {
+			return nil, statusError(CodeFailedPrecondition,
+				"transaction rejected: %v", err)
+		}
+		return nil, err
+	}
+	return &SendRawTransactionResponse{TxID: tx.Hash().CloneBytes()}, nil
+}
+
+// subscribe adds a subscription to the passed set of subscriptions.
+func (s *Server) subscribe(subs map[*subscription]struct{},
+	includeTx bool) *subscription {
+
+	sub := &subscription{
+		msgs:      make(chan Message, notificationQueueSize),
+		overflow:  make(chan struct{}),
+		includeTx: includeTx,
+	}
+	s.mtx.Lock()
+	subs[sub] = struct{}{}
+	s.mtx.Unlock()
+	return sub
+}
+
+// unsubscribe removes a subscription from the passed set of subscriptions.
+func (s *Server) unsubscribe(subs map[*subscription]struct{},
+	sub *subscription) {
+
+	s.mtx.Lock()
+	delete(subs, sub)
+	s.mtx.Unlock()
+}
+
+// enqueue queues the passed notification for the passed subscription.  The
+// subscription is removed and its stream terminated when its queue is full.
+//
+// This function MUST be called with the server lock held.
+func enqueue(subs map[*subscription]struct{}, sub *subscription, msg Message) {
+	select {
+	case sub.msgs <- msg:
+	default:
+		delete(subs, sub)
+		close(sub.overflow)
+	}
+}
+
+// runStream sends the notifications of the passed subscription to the client
+// until the stream ends.
+func (s *Server) runStream(sub *subscription, w http.ResponseWriter,
+	done <-chan struct{}) error {
+
+	// Send the headers right away so the client knows the stream was
+	// established.
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	for {
+		select {
+		case msg := <-sub.msgs:
+			if err := writeMessage(w, msg); err != nil {
+				return err
+			}
+		case <-sub.overflow:
+			return statusError(CodeResourceExhausted, "client is "+
+				"too slow to receive the notifications")
+		case <-done:
+			return statusError(CodeCanceled, "stream canceled")
+		case <-s.quit:
+			return statusError(CodeUnavailable, "server is shutting "+
+				"down")
+		}
+	}
+}
+
+// handleSubscribeBlocks implements the SubscribeBlocks method.
+func handleSubscribeBlocks(s *Server, req []byte, w http.ResponseWriter,
+	done <-chan struct{}) error {
+
+	var r SubscribeBlocksRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return err
+	}
+
+	sub := s.subscribe(s.blockSubs, false)
+	defer s.unsubscribe(s.blockSubs, sub)
+	return s.runStream(sub, w, done)
+}
+
+// handleSubscribeTransactions implements the SubscribeTransactions method.
+func handleSubscribeTransactions(s *Server, req []byte, w http.ResponseWriter,
+	done <-chan struct{}) error {
+
+	var r SubscribeTransactionsRequest
+	if err := unmarshalRequest(req, &r); err != nil {
+		return err
+	}
+
+	sub := s.subscribe(s.txSubs, r.IncludeTransaction)
+	defer s.unsubscribe(s.txSubs, sub)
+	return s.runStream(sub, w, done)
+}
+
+// notifyBlock sends a notification of the passed type about the passed block
+// to the SubscribeBlocks streams.
+func (s *Server) notifyBlock(typ BlockNotificationType, block *btcutil.Block) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.blockSubs) == 0 {
+		return
+	}
+
+	var header bytes.Buffer
+	if err := block.MsgBlock().Header.Serialize(&header); err != nil {
+		log.Errorf("Unable to serialize header of block %v: %v",
+			block.Hash(), err)
+		return
+	}
+	msg := &BlockNotification{
+		Type:   typ,
+		Hash:   block.Hash().CloneBytes(),
+		Height: block.Height(),
+		Header: header.Bytes(),
+	}
+	for sub := range s.blockSubs {
+		enqueue(s.blockSubs, sub, msg)
+	}
+}
+
+// NotifyBlockConnected notifies the SubscribeBlocks streams about the passed
+// block, which was connected to the main chain.
+//
+// This function is safe for concurrent access.
+func (s *Server) NotifyBlockConnected(block *btcutil.Block) {
+	s.notifyBlock(BlockConnected, block)
+}
+
+// NotifyBlockDisconnected notifies the SubscribeBlocks streams about the
+// passed block, which was disconnected from the main chain.
+//
+// This function is safe for concurrent access.
+func (s *Server) NotifyBlockDisconnected(block *btcutil.Block) {
+	s.notifyBlock(BlockDisconnected, block)
+}
+
+// NotifyNewTransactions notifies the SubscribeTransactions streams about the
+// passed transactions, which were accepted to the memory pool.
+//
+// This function is safe for concurrent access.
+func (s *Server) NotifyNewTransactions(txns []*mempool.TxDesc) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.txSubs) == 0 {
+		return
+	}
+
+	for _, txD := range txns {
+		msg := &TransactionNotification{
+			TxID:  txD.Tx.Hash().CloneBytes(),
+			Fee:   txD.Fee,
+			VSize: mempool.GetTxVirtualSize(txD.Tx),
+		}
+		var msgWithTx *TransactionNotification
+		for sub := range s.txSubs {
+			if !sub.includeTx {
+				enqueue(s.txSubs, sub, msg)
+				continue
+			}
+			if msgWithTx == nil {
+				serialized, err := serializeTx(txD.Tx)
+				if err != nil {
+					log.Errorf("Unable to serialize "+
+						"transaction %v: %v",
+						txD.Tx.Hash(), err)
+					return
+				}
+				withTx := *msg
+				withTx.Transaction = serialized
+				msgWithTx = &withTx
+			}
+			enqueue(s.txSubs, sub, msgWithTx)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakeChain is a main chain of empty blocks.
+type fakeChain struct {
+	blocks []*btcutil.Block
+}
+
+// newFakeChain returns a main chain of the passed number of blocks.
+func newFakeChain(numBlocks int) *fakeChain {
+	c := &fakeChain{}
+	var prevHash chainhash.Hash
+	for i := 0; i < numBlocks; i++ {
+		msgBlock := &wire.MsgBlock{Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: prevHash,
+			Nonce:     uint32(i),
+		}}
+		block := btcutil.NewBlock(msgBlock)
+		block.SetHeight(int32(i))
+		c.blocks = append(c.blocks, block)
+		prevHash = *block.Hash()
+	}
+	return c
+}
+
+func (c *fakeChain) BestSnapshot() *blockchain.BestState {
+	tip := c.blocks[len(c.blocks)-1]
+	return &blockchain.BestState{Hash: *tip.Hash(), Height: tip.Height()}
+}
+
+func (c *fakeChain) BlockByHash(hash *chainhash.Hash) (*btcutil.Block, error) {
+	for _, block := range c.blocks {
+		if block.Hash().IsEqual(hash) {
+			return block, nil
+		}
+	}
+	return nil, fmt.Errorf("block %v is not in the main chain", hash)
+}
+
+func (c *fakeChain) BlockHashByHeight(height int32) (*chainhash.Hash, error) {
+	if height < 0 || int(height) >= len(c.blocks) {
+		return nil, fmt.Errorf("no block at height %d exists", height)
+	}
+	return c.blocks[height].Hash(), nil
+}
+
+func (c *fakeChain) HeaderByHash(hash *chainhash.Hash) (wire.BlockHeader, error) {
+	block, err := c.BlockByHash(hash)
+	if err != nil {
+		return wire.BlockHeader{}, err
+	}
+	return block.MsgBlock().Header, nil
+}
+
+// fakeTxSource is a memory pool holding a fixed set of transactions.
+type fakeTxSource struct {
+	txDescs []*mempool.TxDesc
+}
+
+func (m *fakeTxSource) TxDescs() []*mempool.TxDesc {
+	return m.txDescs
+}
+
+func (m *fakeTxSource) FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error) {
+	for _, txD := range m.txDescs {
+		if txD.Tx.Hash().IsEqual(txHash) {
+			return txD.Tx, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %v is not in the pool", txHash)
+}
+
+// testServer starts a server with the passed configuration over TLS and
+// returns it along with a client speaking HTTP/2 to it.
+func testServer(t *testing.T, cfg *Config) (*Server, *httptest.Server) {
+	t.Helper()
+
+	s := New(cfg)
+	ts := httptest.NewUnstartedServer(s)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// call calls the passed method and returns the response body along with the
+// status of the call.
+func call(t *testing.T, ts *httptest.Server, method, token string,
+	req Message) ([]byte, Code, string) {
+
+	t.Helper()
+
+	resp := openCall(t, ts, method, token, req)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unable to read response: %v", err)
+	}
+	code, msg := callStatus(t, resp)
+	return body, code, msg
+}
+
+// openCall sends a request for the passed method and returns the response
+// without reading its body.
+func openCall(t *testing.T, ts *httptest.Server, method, token string,
+	req Message) *http.Response {
+
+	t.Helper()
+
+	data := req.Marshal()
+	body := make([]byte, messageHeaderSize, messageHeaderSize+len(data))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(data)))
+	body = append(body, data...)
+
+	httpReq, err := http.NewRequest(http.MethodPost,
+		ts.URL+servicePrefix+method, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("unable to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("TE", "trailers")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ts.Client().Do(httpReq)
+	if err != nil {
+		t.Fatalf("unable to call %s: %v", method, err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("unexpected protocol %s", resp.Proto)
+	}
+	return resp
+}
+
+// callStatus returns the status of a call whose response was read.
+func callStatus(t *testing.T, resp *http.Response) (Code, string) {
+	t.Helper()
+
+	code, err := strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+	if err != nil {
+		t.Fatalf("invalid status %q", resp.Trailer.Get("Grpc-Status"))
+	}
+	return Code(code), resp.Trailer.Get("Grpc-Message")
+}
+
+// decodeMessages splits a response body into its messages.
+func decodeMessages(t *testing.T, body []byte) [][]byte {
+	t.Helper()
+
+	var msgs [][]byte
+	r := bytes.NewReader(body)
+	for r.Len() > 0 {
+		msg, err := readMessage(r)
+		if err != nil {
+			t.Fatalf("unable to decode response: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// TestUnaryCalls ensures the methods returning a single response serve the
+// expected data and statuses.
+func TestUnaryCalls(t *testing.T) {
+	chain := newFakeChain(5)
+	tx := btcutil.NewTx(&wire.MsgTx{
+		Version: 2,
+		TxIn:    []*wire.TxIn{{Sequence: wire.MaxTxInSequenceNum}},
+		TxOut:   []*wire.TxOut{{Value: 1000, PkScript: []byte{0x51}}},
+	})
+	txSource := &fakeTxSource{txDescs: []*mempool.TxDesc{{
+		TxDesc: mining.TxDesc{
+			Tx:     tx,
+			Added:  time.Unix(1700000000, 0),
+			Height: 4,
+			Fee:    250,
+		},
+	}}}
+	var processed []*btcutil.Tx
+	_, ts := testServer(t, &Config{
+		Chain:     chain,
+		TxMemPool: txSource,
+		ProcessTransaction: func(tx *btcutil.Tx) error {
+			processed = append(processed, tx)
+			return nil
+		},
+		Token: "secret",
+	})
+
+	// Calls without the token are rejected.
+	_, code, _ := call(t, ts, "GetBestBlock", "", &GetBestBlockRequest{})
+	if code != CodeUnauthenticated {
+		t.Fatalf("unexpected status without token: %d", code)
+	}
+	_, code, _ = call(t, ts, "GetBestBlock", "wrong", &GetBestBlockRequest{})
+	if code != CodeUnauthenticated {
+		t.Fatalf("unexpected status with wrong token: %d", code)
+	}
+	_, code, _ = call(t, ts, "Unknown", "secret", &GetBestBlockRequest{})
+	if code != CodeUnimplemented {
+		t.Fatalf("unexpected status of unknown method: %d", code)
+	}
+
+	// unary calls the passed method and decodes its response.
+	unary := func(method string, req, resp Message) {
+		t.Helper()
+
+		body, code, msg := call(t, ts, method, "secret", req)
+		if code != CodeOK {
+			t.Fatalf("%s: unexpected status %d: %s", method, code, msg)
+		}
+		msgs := decodeMessages(t, body)
+		if len(msgs) != 1 {
+			t.Fatalf("%s: got %d messages, want 1", method, len(msgs))
+		}
+		if err := resp.Unmarshal(msgs[0]); err != nil {
+			t.Fatalf("%s: unable to decode response: %v", method, err)
+		}
+	}
+
+	var best GetBestBlockResponse
+	unary("GetBestBlock", &GetBestBlockRequest{}, &best)
+	if !bytes.Equal(best.Hash, chain.blocks[4].Hash()[:]) || best.Height != 4 {
+		t.Fatalf("unexpected best block %x at height %d", best.Hash,
+			best.Height)
+	}
+
+	// Blocks can be requested by height, including the genesis block
+	// whose height isn't encoded, and by hash.
+	for height := 0; height < 5; height += 2 {
+		var block GetBlockResponse
+		unary("GetBlock", &GetBlockRequest{Height: int32(height)}, &block)
+		want, _ := chain.blocks[height].Bytes()
+		if !bytes.Equal(block.Block, want) ||
+			block.Height != int32(height) ||
+			block.Confirmations != int32(5-height) {
+
+			t.Fatalf("unexpected block at height %d: %+v", height,
+				block)
+		}
+	}
+	var block GetBlockResponse
+	unary("GetBlock", &GetBlockRequest{Hash: chain.blocks[3].Hash()[:]},
+		&block)
+	if block.Height != 3 {
+		t.Fatalf("unexpected block height %d", block.Height)
+	}
+	_, code, _ = call(t, ts, "GetBlock", "secret",
+		&GetBlockRequest{Height: 5})
+	if code != CodeNotFound {
+		t.Fatalf("unexpected status of unknown block: %d", code)
+	}
+	_, code, _ = call(t, ts, "GetBlock", "secret",
+		&GetBlockRequest{Hash: []byte{1, 2, 3}})
+	if code != CodeInvalidArgument {
+		t.Fatalf("unexpected status of invalid hash: %d", code)
+	}
+
+	// Headers are limited to the main chain.
+	var headers GetBlockHeadersResponse
+	unary("GetBlockHeaders", &GetBlockHeadersRequest{StartHeight: 2,
+		Count: 10}, &headers)
+	if len(headers.Headers) != 3 {
+		t.Fatalf("got %d headers, want 3", len(headers.Headers))
+	}
+	for i, serialized := range headers.Headers {
+		var header wire.BlockHeader
+		err := header.Deserialize(bytes.NewReader(serialized))
+		if err != nil || header.BlockHash() != *chain.blocks[2+i].Hash() {
+			t.Fatalf("unexpected header %d: %v", i, err)
+		}
+	}
+	_, code, _ = call(t, ts, "GetBlockHeaders", "secret",
+		&GetBlockHeadersRequest{Count: maxHeadersPerRequest + 1})
+	if code != CodeInvalidArgument {
+		t.Fatalf("unexpected status of too many headers: %d", code)
+	}
+
+	var pool GetMempoolResponse
+	unary("GetMempool", &GetMempoolRequest{}, &pool)
+	wantEntry := MempoolEntry{
+		TxID:   tx.Hash()[:],
+		Fee:    250,
+		VSize:  mempool.GetTxVirtualSize(tx),
+		Time:   1700000000,
+		Height: 4,
+	}
+	if len(pool.Entries) != 1 ||
+		!bytes.Equal(pool.Entries[0].TxID, wantEntry.TxID) ||
+		pool.Entries[0].Fee != wantEntry.Fee ||
+		pool.Entries[0].VSize != wantEntry.VSize ||
+		pool.Entries[0].Time != wantEntry.Time ||
+		pool.Entries[0].Height != wantEntry.Height {
+
+		t.Fatalf("unexpected mempool entries: %+v", pool.Entries)
+	}
+
+	serializedTx, _ := serializeTx(tx)
+	var rawTx GetRawTransactionResponse
+	unary("GetRawTransaction", &GetRawTransactionRequest{TxID: tx.Hash()[:]},
+		&rawTx)
+	if !bytes.Equal(rawTx.Transaction, serializedTx) || rawTx.BlockHash != nil {
+		t.Fatalf("unexpected raw transaction: %+v", rawTx)
+	}
+	_, code, _ = call(t, ts, "GetRawTransaction", "secret",
+		&GetRawTransactionRequest{TxID: make([]byte, 32)})
+	if code != CodeNotFound {
+		t.Fatalf("unexpected status of unknown transaction: %d", code)
+	}
+
+	var sent SendRawTransactionResponse
+	unary("SendRawTransaction",
+		&SendRawTransactionRequest{Transaction: serializedTx}, &sent)
+	if !bytes.Equal(sent.TxID, tx.Hash()[:]) || len(processed) != 1 {
+		t.Fatalf("unexpected sent transaction %x", sent.TxID)
+	}
+	_, code, _ = call(t, ts, "SendRawTransaction", "secret",
+		&SendRawTransactionRequest{Transaction: []byte{1}})
+	if code != CodeInvalidArgument {
+		t.Fatalf("unexpected status of invalid transaction: %d", code)
+	}
+}
+
+// TestStreams ensures the streams deliver the notifications of the server and
+// are terminated when the server shuts down.
+func TestStreams(t *testing.T) {
+	chain := newFakeChain(2)
+	s, ts := testServer(t, &Config{
+		Chain:     chain,
+		TxMemPool: &fakeTxSource{},
+	})
+
+	blocks := openCall(t, ts, "SubscribeBlocks", "",
+		&SubscribeBlocksRequest{})
+	defer blocks.Body.Close()
+	txns := openCall(t, ts, "SubscribeTransactions", "",
+		&SubscribeTransactionsRequest{IncludeTransaction: true})
+	defer txns.Body.Close()
+
+	// Wait for the server to register the streams.
+	for i := 0; ; i++ {
+		s.mtx.Lock()
+		registered := len(s.blockSubs) == 1 && len(s.txSubs) == 1
+		s.mtx.Unlock()
+		if registered {
+			break
+		}
+		if i == 100 {
+			t.Fatal("streams were not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.NotifyBlockConnected(chain.blocks[1])
+	s.NotifyBlockDisconnected(chain.blocks[1])
+	msg, err := readMessage(blocks.Body)
+	if err != nil {
+		t.Fatalf("unable to read notification: %v", err)
+	}
+	var ntfn BlockNotification
+	if err := ntfn.Unmarshal(msg); err != nil {
+		t.Fatalf("unable to decode notification: %v", err)
+	}
+	if ntfn.Type != BlockConnected || ntfn.Height != 1 ||
+		!bytes.Equal(ntfn.Hash, chain.blocks[1].Hash()[:]) ||
+		len(ntfn.Header) != wire.MaxBlockHeaderPayload {
+
+		t.Fatalf("unexpected notification: %+v", ntfn)
+	}
+	msg, err = readMessage(blocks.Body)
+	if err != nil {
+		t.Fatalf("unable to read notification: %v", err)
+	}
+	if err := ntfn.Unmarshal(msg); err != nil || ntfn.Type != BlockDisconnected {
+		t.Fatalf("unexpected notification: %+v (%v)", ntfn, err)
+	}
+
+	tx := btcutil.NewTx(&wire.MsgTx{Version: 2})
+	s.NotifyNewTransactions([]*mempool.TxDesc{{
+		TxDesc: mining.TxDesc{Tx: tx, Fee: 100},
+	}})
+	msg, err = readMessage(txns.Body)
+	if err != nil {
+		t.Fatalf("unable to read notification: %v", err)
+	}
+	var txNtfn TransactionNotification
+	if err := txNtfn.Unmarshal(msg); err != nil {
+		t.Fatalf("unable to decode notification: %v", err)
+	}
+	serializedTx, _ := serializeTx(tx)
+	if !bytes.Equal(txNtfn.TxID, tx.Hash()[:]) || txNtfn.Fee != 100 ||
+		!bytes.Equal(txNtfn.Transaction, serializedTx) {
+
+		t.Fatalf("unexpected notification: %+v", txNtfn)
+	}
+
+	// Shutting down the server ends the streams with the unavailable
+	// status.
+	close(s.quit)
+	if _, err := io.ReadAll(blocks.Body); err != nil {
+		t.Fatalf("unable to read stream: %v", err)
+	}
+	if code, _ := callStatus(t, blocks); code != CodeUnavailable {
+		t.Fatalf("unexpected status after shutdown: %d", code)
+	}
+}
+
+// TestMessageEncoding ensures messages survive a round trip through their
+// encoding and that negative numbers and unknown fields are handled.
+func TestMessageEncoding(t *testing.T) {
+	t.Parallel()
+
+	req := &GetBlockRequest{Hash: []byte{1, 2}, Height: -1}
+	encoded := req.Marshal()
+
+	// A negative int32 is sign extended to ten bytes.
+	if len(encoded) != 4+11 {
+		t.Fatalf("unexpected encoding %x", encoded)
+	}
+
+	// Append an unknown field of each wire type.
+	encoded = appendVarintField(encoded, 10, 7)
+	encoded = appendBytesField(encoded, 11, []byte("unknown"))
+	encoded = appendTag(encoded, 12, wireFixed32)
+	encoded = append(encoded, 0, 0, 0, 0)
+	encoded = appendTag(encoded, 13, wireFixed64)
+	encoded = append(encoded, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	var decoded GetBlockRequest
+	if err := decoded.Unmarshal(encoded); err != nil {
+		t.Fatalf("Unmarshal: unexpected error: %v", err)
+	}
+	if !bytes.Equal(decoded.Hash, req.Hash) || decoded.Height != -1 {
+		t.Fatalf("unexpected decoded message: %+v", decoded)
+	}
+
+	// Truncated messages and fields of the wrong wire type are rejected.
+	if err := decoded.Unmarshal(encoded[:3]); err == nil {
+		t.Fatal("Unmarshal: accepted truncated message")
+	}
+	wrongType := appendVarintField(nil, 1, 5)
+	if err := decoded.Unmarshal(wrongType); err == nil {
+		t.Fatal("Unmarshal: accepted field of wrong wire type")
+	}
+}
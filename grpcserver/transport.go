@@ -0,0 +1,135 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxRequestSize is the maximum size of a request message, which
+	// allows submitting any standard transaction.
+	maxRequestSize = 4 * 1024 * 1024
+
+	// messageHeaderSize is the size of the header preceding each message
+	// on the wire, consisting of the compression flag and the size.
+	messageHeaderSize = 5
+
+	// contentType is the content type of gRPC requests and responses.
+	contentType = "application/grpc"
+)
+
+// Code is a gRPC status code.
+type Code uint32
+
+// The gRPC status codes returned by the server.
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeInvalidArgument    Code = 3
+	CodeNotFound           Code = 5
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeUnauthenticated    Code = 16
+)
+
+// Error is an error carrying a gRPC status.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *Error) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", e.Code, e.Message)
+}
+
+// statusError returns an Error with the passed code and formatted message.
+func statusError(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// readMessage reads a length-prefixed message of a request.
+func readMessage(r io.Reader) ([]byte, error) {
+	var header [messageHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, statusError(CodeInvalidArgument,
+			"unable to read request: %v", err)
+	}
+	if header[0] != 0 {
+		return nil, statusError(CodeUnimplemented,
+			"compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxRequestSize {
+		return nil, statusError(CodeResourceExhausted,
+			"request of %d bytes exceeds the maximum of %d bytes", size,
+			maxRequestSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, statusError(CodeInvalidArgument,
+			"unable to read request: %v", err)
+	}
+	return msg, nil
+}
+
+// writeMessage writes the passed message with its length prefix to the
+// response and flushes it to the client.
+func writeMessage(w http.ResponseWriter, msg Message) error {
+	data := msg.Marshal()
+	buf := make([]byte, messageHeaderSize, messageHeaderSize+len(data))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(data)))
+	buf = append(buf, data...)
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// writeStatus sets the trailers of the response to the status of the passed
+// error, which is OK when it is nil.
+func writeStatus(w http.ResponseWriter, err error) {
+	code, message := CodeOK, ""
+	if err != nil {
+		statusErr, ok := err.(*Error)
+		if !ok {
+			statusErr = statusError(CodeInternal, "%v", err)
+		}
+		code, message = statusErr.Code, statusErr.Message
+	}
+
+	h := w.Header()
+	h.Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(code)))
+	if message != "" {
+		h.Set(http.TrailerPrefix+"Grpc-Message", encodeStatusMessage(message))
+	}
+}
+
+// encodeStatusMessage percent-encodes the passed status message as required
+// for the grpc-message trailer.
+func encodeStatusMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
@@ -125,6 +125,22 @@ func TestTestMempoolAccept(t *testing.T) {
 			// When multiple txns are provided, the method should
 			// return the correct results for each of the txns.
 			name: "multiple txns",
+			txns: []*wire.MsgTx{validTx, invalidTx},
+			expectedResult: []*btcjson.TestMempoolAcceptResult{{
+				Txid:    validTx.TxHash().String(),
+				Wtxid:   validTx.TxHash().String(),
+				Allowed: true,
+			}, {
+				Txid:         invalidTx.TxHash().String(),
+				Wtxid:        invalidTx.TxHash().String(),
+				Allowed:      false,
+				RejectReason: "missing-inputs",
+			}},
+		},
+		{
+			// The txns following a tx which is not allowed are not
+			// evaluated since they are tested as a package.
+			name: "txns following a rejected tx",
 			txns: []*wire.MsgTx{invalidTx, validTx},
 			expectedResult: []*btcjson.TestMempoolAcceptResult{{
 				Txid:         invalidTx.TxHash().String(),
@@ -132,9 +148,8 @@ func TestTestMempoolAccept(t *testing.T) {
 				Allowed:      false,
 				RejectReason: "missing-inputs",
 			}, {
-				Txid:    validTx.TxHash().String(),
-				Wtxid:   validTx.TxHash().String(),
-				Allowed: true,
+				Txid:  validTx.TxHash().String(),
+				Wtxid: validTx.TxHash().String(),
 			}},
 		},
 	}
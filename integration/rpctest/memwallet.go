@@ -267,7 +267,9 @@ func (m *memWallet) evalOutputs(outputs []*wire.TxOut, txHash *chainhash.Hash,
 			// future.
 			var maturityHeight int32
 			if isCoinbase {
-				maturityHeight = m.currentHeight + int32(m.net.CoinbaseMaturity)
+				maturityHeight = m.currentHeight +
+					blockchain.CalcCoinbaseMaturity(
+						m.currentHeight, m.net)
 			}
 
 			op := wire.OutPoint{Hash: *txHash, Index: uint32(i)}
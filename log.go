@@ -11,19 +11,31 @@ import (
 	"path/filepath"
 
 	"github.com/btcsuite/btcd/addrmgr"
+	"github.com/btcsuite/btcd/banman"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/blocktrace"
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/finality"
+	"github.com/btcsuite/btcd/grpcserver"
+	"github.com/btcsuite/btcd/logging"
 	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/metrics"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
 	"github.com/btcsuite/btcd/netsync"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/rebroadcast"
+	"github.com/btcsuite/btcd/reputation"
+	"github.com/btcsuite/btcd/seeder"
+	"github.com/btcsuite/btcd/sv2"
+	"github.com/btcsuite/btcd/torcontrol"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/watchonly"
+	"github.com/btcsuite/btcd/zmq"
 
 	"github.com/btcsuite/btclog"
-	"github.com/jrick/logrotate/rotator"
 )
 
 // logWriter implements an io.Writer that outputs to both standard output and
@@ -47,43 +59,68 @@ func (logWriter) Write(p []byte) (n int, err error) {
 var (
 	// backendLog is the logging backend used to create all subsystem loggers.
 	// The backend must not be used before the log rotator has been initialized,
-	// or data races and/or nil pointer dereferences will occur.
-	backendLog = btclog.NewBackend(logWriter{})
+	// or data races and/or nil pointer dereferences will occur.  Its format
+	// is set once the configuration is loaded.
+	backendLog = logging.NewBackend(logWriter{})
 
 	// logRotator is one of the logging outputs.  It should be closed on
 	// application shutdown.
-	logRotator *rotator.Rotator
+	logRotator *logging.Rotator
 
 	adxrLog = backendLog.Logger("ADXR")
 	amgrLog = backendLog.Logger("AMGR")
 	cmgrLog = backendLog.Logger("CMGR")
+	banmLog = backendLog.Logger("BANM")
 	bcdbLog = backendLog.Logger("BCDB")
 	btcdLog = backendLog.Logger("BTCD")
+	btrcLog = backendLog.Logger("BTRC")
 	chanLog = backendLog.Logger("CHAN")
 	discLog = backendLog.Logger("DISC")
+	fnltLog = backendLog.Logger("FNLT")
+	grpcLog = backendLog.Logger("GRPC")
 	indxLog = backendLog.Logger("INDX")
 	minrLog = backendLog.Logger("MINR")
+	mtrcLog = backendLog.Logger("MTRC")
 	peerLog = backendLog.Logger("PEER")
+	rbrdLog = backendLog.Logger("RBRD")
+	rptnLog = backendLog.Logger("RPTN")
 	rpcsLog = backendLog.Logger("RPCS")
 	scrpLog = backendLog.Logger("SCRP")
+	seedLog = backendLog.Logger("SEED")
 	srvrLog = backendLog.Logger("SRVR")
+	stv2Log = backendLog.Logger("STV2")
 	syncLog = backendLog.Logger("SYNC")
+	torcLog = backendLog.Logger("TORC")
 	txmpLog = backendLog.Logger("TXMP")
+	wtchLog = backendLog.Logger("WTCH")
+	zmqpLog = backendLog.Logger("ZMQP")
 )
 
 // Initialize package-global logger variables.
 func init() {
 	addrmgr.UseLogger(amgrLog)
 	connmgr.UseLogger(cmgrLog)
+	banman.UseLogger(banmLog)
 	database.UseLogger(bcdbLog)
 	blockchain.UseLogger(chanLog)
+	blocktrace.UseLogger(btrcLog)
 	indexers.UseLogger(indxLog)
+	finality.UseLogger(fnltLog)
+	grpcserver.UseLogger(grpcLog)
+	metrics.UseLogger(mtrcLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
 	peer.UseLogger(peerLog)
+	rebroadcast.UseLogger(rbrdLog)
+	reputation.UseLogger(rptnLog)
 	txscript.UseLogger(scrpLog)
+	seeder.UseLogger(seedLog)
+	sv2.UseLogger(stv2Log)
 	netsync.UseLogger(syncLog)
 	mempool.UseLogger(txmpLog)
+	torcontrol.UseLogger(torcLog)
+	watchonly.UseLogger(wtchLog)
+	zmq.UseLogger(zmqpLog)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -91,31 +128,44 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"ADXR": adxrLog,
 	"AMGR": amgrLog,
 	"CMGR": cmgrLog,
+	"BANM": banmLog,
 	"BCDB": bcdbLog,
 	"BTCD": btcdLog,
+	"BTRC": btrcLog,
 	"CHAN": chanLog,
 	"DISC": discLog,
+	"FNLT": fnltLog,
+	"GRPC": grpcLog,
 	"INDX": indxLog,
 	"MINR": minrLog,
+	"MTRC": mtrcLog,
 	"PEER": peerLog,
+	"RBRD": rbrdLog,
+	"RPTN": rptnLog,
 	"RPCS": rpcsLog,
 	"SCRP": scrpLog,
+	"SEED": seedLog,
 	"SRVR": srvrLog,
+	"STV2": stv2Log,
 	"SYNC": syncLog,
+	"TORC": torcLog,
 	"TXMP": txmpLog,
+	"WTCH": wtchLog,
+	"ZMQP": zmqpLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
-// create roll files in the same directory.  It must be called before the
-// package-global log rotater variables are used.
-func initLogRotator(logFile string) {
+// create roll files in the same directory according to the passed rotation
+// configuration.  It must be called before the package-global log rotater
+// variables are used.
+func initLogRotator(logFile string, rotatorCfg *logging.RotatorConfig) {
 	logDir, _ := filepath.Split(logFile)
 	err := os.MkdirAll(logDir, 0700)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create log directory: %v\n", err)
 		os.Exit(1)
 	}
-	r, err := rotator.New(logFile, 10*1024, false, 3)
+	r, err := logging.NewRotator(logFile, rotatorCfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create file rotator: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,350 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btclog"
+)
+
+// Format identifies the format log messages are written in.
+type Format string
+
+const (
+	// FormatText writes each message as a line of plain text prefixed by
+	// its time, level and subsystem, which is the format of btclog.
+	FormatText Format = "text"
+
+	// FormatJSON writes each message as a JSON object on its own line.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format with the passed name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown log format %q", s)
+}
+
+// Field is implemented by values which add a field to the JSON records of the
+// messages they are logged in, such as peers.  Only the first value logged for
+// each key is recorded.
+type Field interface {
+	LogField() (key, value string)
+}
+
+// Backend creates the loggers of the subsystems, which write to a common
+// writer in the format of the backend.  The format can be changed while the
+// loggers are in use, so the loggers can be created before the configuration
+// is loaded.
+type Backend struct {
+	w    io.Writer
+	text *btclog.Backend
+	json int32 // accessed atomically
+
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+// NewBackend returns a backend writing to the passed writer in the text format.
+func NewBackend(w io.Writer) *Backend {
+	return &Backend{
+		w:    w,
+		text: btclog.NewBackend(w),
+	}
+}
+
+// SetFormat changes the format of the messages written by the loggers of the
+// backend.
+func (b *Backend) SetFormat(format Format) {
+	var json int32
+	if format == FormatJSON {
+		json = 1
+	}
+	atomic.StoreInt32(&b.json, json)
+}
+
+// Logger returns a logger for the passed subsystem.  Like the loggers of
+// btclog, it logs messages at the info level and above by default.
+func (b *Backend) Logger(subsystem string) btclog.Logger {
+	return &logger{
+		backend:   b,
+		text:      b.text.Logger(subsystem),
+		subsystem: subsystem,
+		level:     uint32(btclog.LevelInfo),
+	}
+}
+
+// record is a message logged by a subsystem.
+type record struct {
+	time      time.Time
+	level     btclog.Level
+	subsystem string
+	msg       string
+	fields    map[string]string
+}
+
+// levelNames maps the levels to their names in JSON records.
+var levelNames = map[btclog.Level]string{
+	btclog.LevelTrace:    "trace",
+	btclog.LevelDebug:    "debug",
+	btclog.LevelInfo:     "info",
+	btclog.LevelWarn:     "warn",
+	btclog.LevelError:    "error",
+	btclog.LevelCritical: "critical",
+}
+
+// writeJSON writes the passed record as a JSON object on its own line.  The
+// time, level, subsystem and message come first, followed by the fields
+// ordered by key.
+func (b *Backend) writeJSON(r *record) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	buf := &b.buf
+	buf.Reset()
+	buf.WriteString(`{"time":`)
+	writeJSONString(buf, r.time.Format("2006-01-02T15:04:05.000Z07:00"))
+	buf.WriteString(`,"level":`)
+	writeJSONString(buf, levelNames[r.level])
+	buf.WriteString(`,"subsystem":`)
+	writeJSONString(buf, r.subsystem)
+	buf.WriteString(`,"msg":`)
+	writeJSONString(buf, r.msg)
+
+	keys := make([]string, 0, len(r.fields))
+	for key := range r.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buf.WriteByte(',')
+		writeJSONString(buf, key)
+		buf.WriteByte(':')
+		writeJSONString(buf, r.fields[key])
+	}
+	buf.WriteString("}\n")
+
+	b.w.Write(buf.Bytes())
+}
+
+// writeJSONString writes the passed string as a JSON string.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	// Marshalling a string never fails.
+	encoded, _ := json.Marshal(s)
+	buf.Write(encoded)
+}
+
+// fields returns the fields of the passed log parameters.  Hashes and the
+// hashes of blocks are recorded under the hash key and values implementing
+// Field under their own key.
+func fields(params []interface{}) map[string]string {
+	var fields map[string]string
+	add := func(key, value string) {
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	for _, param := range params {
+		switch v := param.(type) {
+		case Field:
+			add(v.LogField())
+		case *chainhash.Hash:
+			if v != nil {
+				add("hash", v.String())
+			}
+		case chainhash.Hash:
+			add("hash", v.String())
+		case *btcutil.Block:
+			if v != nil {
+				add("hash", v.Hash().String())
+			}
+		}
+	}
+	return fields
+}
+
+// logger is a subsystem logger of a backend.
+type logger struct {
+	backend   *Backend
+	text      btclog.Logger
+	subsystem string
+	level     uint32 // accessed atomically
+}
+
+// log writes a message at the passed level unless the logger is at a higher
+// level.  The message is formatted with fmt.Sprintf when format is not nil and
+// with fmt.Sprint otherwise.
+func (l *logger) log(level btclog.Level, format *string, params []interface{}) {
+	if level < l.Level() {
+		return
+	}
+
+	var msg string
+	if format != nil {
+		msg = fmt.Sprintf(*format, params...)
+	} else {
+		msg = fmt.Sprint(params...)
+	}
+	l.backend.writeJSON(&record{
+		time:      time.Now(),
+		level:     level,
+		subsystem: l.subsystem,
+		msg:       msg,
+		fields:    fields(params),
+	})
+}
+
+// json returns whether the backend writes JSON records.
+func (l *logger) json() bool {
+	return atomic.LoadInt32(&l.backend.json) == 1
+}
+
+// Tracef formats message according to format specifier and writes to log with
+// LevelTrace.
+func (l *logger) Tracef(format string, params ...interface{}) {
+	if !l.json() {
+		l.text.Tracef(format, params...)
+		return
+	}
+	l.log(btclog.LevelTrace, &format, params)
+}
+
+// Debugf formats message according to format specifier and writes to log with
+// LevelDebug.
+func (l *logger) Debugf(format string, params ...interface{}) {
+	if !l.json() {
+		l.text.Debugf(format, params...)
+		return
+	}
+	l.log(btclog.LevelDebug, &format, params)
+}
+
+// Infof formats message according to format specifier and writes to log with
+// LevelInfo.
+func (l *logger) Infof(format string, params ...interface{}) {
+	if !l.json() {
+		l.text.Infof(format, params...)
+		return
+	}
+	l.log(btclog.LevelInfo, &format, params)
+}
+
+// Warnf formats message according to format specifier and writes to log with
+// LevelWarn.
+func (l *logger) Warnf(format string, params ...interface{}) {
+	if !l.json() {
+		l.text.Warnf(format, params...)
+		return
+	}
+	l.log(btclog.LevelWarn, &format, params)
+}
+
+// Errorf formats message according to format specifier and writes to log with
+// LevelError.
+func (l *logger) Errorf(format string, params ...interface{}) {
+	if !l.json() {
+		l.text.Errorf(format, params...)
+		return
+	}
+	l.log(btclog.LevelError, &format, params)
+}
+
+// Criticalf formats message according to format specifier and writes to log
+// with LevelCritical.
+func (l *logger) Criticalf(format string, params ...interface{}) {
+	if !l.json() {
+		l.text.Criticalf(format, params...)
+		return
+	}
+	l.log(btclog.LevelCritical, &format, params)
+}
+
+// Trace formats message using the default formats for its operands and writes
+// to log with LevelTrace.
+func (l *logger) Trace(v ...interface{}) {
+	if !l.json() {
+		l.text.Trace(v...)
+		return
+	}
+	l.log(btclog.LevelTrace, nil, v)
+}
+
+// Debug formats message using the default formats for its operands and writes
+// to log with LevelDebug.
+func (l *logger) Debug(v ...interface{}) {
+	if !l.json() {
+		l.text.Debug(v...)
+		return
+	}
+	l.log(btclog.LevelDebug, nil, v)
+}
+
+// Info formats message using the default formats for its operands and writes
+// to log with LevelInfo.
+func (l *logger) Info(v ...interface{}) {
+	if !l.json() {
+		l.text.Info(v...)
+		return
+	}
+	l.log(btclog.LevelInfo, nil, v)
+}
+
+// Warn formats message using the default formats for its operands and writes
+// to log with LevelWarn.
+func (l *logger) Warn(v ...interface{}) {
+	if !l.json() {
+		l.text.Warn(v...)
+		return
+	}
+	l.log(btclog.LevelWarn, nil, v)
+}
+
+// Error formats message using the default formats for its operands and writes
+// to log with LevelError.
+func (l *logger) Error(v ...interface{}) {
+	if !l.json() {
+		l.text.Error(v...)
+		return
+	}
+	l.log(btclog.LevelError, nil, v)
+}
+
+// Critical formats message using the default formats for its operands and
+// writes to log with LevelCritical.
+func (l *logger) Critical(v ...interface{}) {
+	if !l.json() {
+		l.text.Critical(v...)
+		return
+	}
+	l.log(btclog.LevelCritical, nil, v)
+}
+
+// Level returns the current logging level.
+func (l *logger) Level() btclog.Level {
+	return btclog.Level(atomic.LoadUint32(&l.level))
+}
+
+// SetLevel changes the logging level to the passed level.
+func (l *logger) SetLevel(level btclog.Level) {
+	atomic.StoreUint32(&l.level, uint32(level))
+	l.text.SetLevel(level)
+}
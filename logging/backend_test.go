@@ -0,0 +1,100 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btclog"
+)
+
+// testPeer is a peer which adds its address to the records it is logged in.
+type testPeer string
+
+// LogField returns the address of the peer as the peer field.
+func (p testPeer) LogField() (string, string) {
+	return "peer", string(p)
+}
+
+// TestJSONFormat ensures messages are written as JSON records with the fields
+// of their parameters once the format is changed to JSON.
+func TestJSONFormat(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	backend := NewBackend(&buf)
+	log := backend.Logger("SYNC")
+
+	// Messages are written as text by default.
+	log.Infof("text %d", 1)
+	if got := buf.String(); !strings.HasSuffix(got, " [INF] SYNC: text 1\n") {
+		t.Fatalf("unexpected text message %q", got)
+	}
+	buf.Reset()
+
+	backend.SetFormat(FormatJSON)
+	hash := chainhash.DoubleHashH([]byte("block"))
+	log.Infof("Block %v from %v, then %v", &hash, testPeer("1.2.3.4:8333"),
+		chainhash.DoubleHashH(nil))
+	log.Debugf("filtered")
+	log.SetLevel(btclog.LevelDebug)
+	log.Debug("quoted \"message\"")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2: %q", len(lines), buf.String())
+	}
+
+	var record map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("unable to decode record %q: %v", lines[0], err)
+	}
+	want := map[string]string{
+		"level":     "info",
+		"subsystem": "SYNC",
+		"msg": "Block " + hash.String() + " from 1.2.3.4:8333, " +
+			"then " + chainhash.DoubleHashH(nil).String(),
+		"peer": "1.2.3.4:8333",
+		"hash": hash.String(),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("field %s: got %q, want %q", key, record[key],
+				value)
+		}
+	}
+	if record["time"] == "" || len(record) != len(want)+1 {
+		t.Errorf("unexpected fields in record %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[0], `{"time":`) {
+		t.Errorf("record %q does not start with the time", lines[0])
+	}
+
+	record = nil
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("unable to decode record %q: %v", lines[1], err)
+	}
+	if record["level"] != "debug" || record["msg"] != `quoted "message"` {
+		t.Errorf("unexpected record %q", lines[1])
+	}
+}
+
+// TestParseFormat ensures only the supported formats are accepted.
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"text", "json", "JSON"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("ParseFormat(%q): unexpected error: %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(\"xml\"): expected error")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package logging provides the logging backend of btcd, which writes the messages
+of the subsystem loggers either as plain text or as JSON records, along with a
+log file rotator.
+
+The loggers implement the btclog.Logger interface, so packages keep logging
+through the loggers passed to their UseLogger functions.  The format of the
+backend can be changed after the loggers are created, which allows them to be
+created before the configuration is loaded.
+
+In the JSON format, each message is written as an object on its own line:
+
+	{"time":"2024-06-03T12:00:00.000Z","level":"info","subsystem":"SYNC",
+	 "msg":"...","hash":"...","peer":"..."}
+
+Fields are added from the parameters of the message.  Hashes are added under
+the hash key, which is the block hash for messages about blocks, either passed
+as hashes or as blocks.  Values implementing Field, such as peers, are added
+under their own key.  Only the first value of each key is recorded.
+
+The rotator rotates the log file once it reaches a maximum size, at a fixed
+interval, or both, and keeps a limited number of gzip compressed rotated files.
+*/
+package logging
@@ -0,0 +1,224 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatorConfig is the configuration of a Rotator.
+type RotatorConfig struct {
+	// MaxSize is the size in bytes the log file is rotated at.  The file
+	// is not rotated by size when it is zero.
+	MaxSize int64
+
+	// Interval is the interval the log file is rotated at regardless of
+	// its size.  The file is not rotated by time when it is zero.
+	Interval time.Duration
+
+	// MaxFiles is the number of rotated files which are kept.  All of
+	// them are kept when it is zero.
+	MaxFiles int
+}
+
+// Rotator is a writer to a log file which rotates the file once it reaches a
+// maximum size or at a fixed interval.  Rotated files are renamed by appending
+// an increasing number to the file name and compressed with gzip in the
+// background, the same way jrick/logrotate names them, so the files rotated by
+// earlier versions of btcd are numbered and removed along with the new ones.
+//
+// Files are only rotated after a complete line is written, so a message is
+// never split across files.
+type Rotator struct {
+	cfg      RotatorConfig
+	filename string
+
+	mtx          sync.Mutex
+	out          *os.File
+	size         int64
+	nextRotation time.Time
+
+	wg sync.WaitGroup
+}
+
+// NewRotator opens or creates the passed log file and returns a rotator
+// writing to it.  A file which is already larger than the maximum size is
+// rotated on the first write.
+func NewRotator(filename string, cfg *RotatorConfig) (*Rotator, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &Rotator{
+		cfg:      *cfg,
+		filename: filename,
+		out:      f,
+		size:     stat.Size(),
+	}
+	if r.cfg.Interval > 0 {
+		r.nextRotation = time.Now().Add(r.cfg.Interval)
+	}
+	return r, nil
+}
+
+// Write writes the passed bytes to the log file and rotates it when it is due
+// and the bytes end a line.  Errors writing to the file are ignored, since
+// there is nowhere to log them to.
+//
+// This function is safe for concurrent access.
+func (r *Rotator) Write(p []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.out == nil {
+		return len(p), nil
+	}
+	n, _ := r.out.Write(p)
+	r.size += int64(n)
+
+	if len(p) == 0 || p[len(p)-1] != '\n' || !r.due(time.Now()) {
+		return len(p), nil
+	}
+	if err := r.rotate(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to rotate log file: %v\n", err)
+	}
+	return len(p), nil
+}
+
+// due returns whether the log file must be rotated at the passed time.
+//
+// This function MUST be called with the rotator lock held.
+func (r *Rotator) due(now time.Time) bool {
+	if r.cfg.MaxSize > 0 && r.size >= r.cfg.MaxSize {
+		return true
+	}
+	return !r.nextRotation.IsZero() && !now.Before(r.nextRotation)
+}
+
+// Close closes the log file and waits for the compression of the rotated files
+// to finish.
+func (r *Rotator) Close() error {
+	r.mtx.Lock()
+	var err error
+	if r.out != nil {
+		err = r.out.Close()
+		r.out = nil
+	}
+	r.mtx.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+// rotatedNum returns the number of the passed rotated file, which is either
+// named <file>.<num> or <file>.<num>.gz.
+func (r *Rotator) rotatedNum(name string) (int, bool) {
+	suffix := strings.TrimPrefix(name, r.filename+".")
+	suffix = strings.TrimSuffix(suffix, ".gz")
+	num, err := strconv.Atoi(suffix)
+	return num, err == nil
+}
+
+// rotate renames the current log file to the next rotated file, opens a new
+// log file, removes the rotated files exceeding the maximum number of files
+// and compresses the renamed file in the background.
+//
+// This function MUST be called with the rotator lock held.
+func (r *Rotator) rotate() error {
+	existing, err := filepath.Glob(r.filename + ".*")
+	if err != nil {
+		return err
+	}
+	maxNum := 0
+	for _, name := range existing {
+		if num, ok := r.rotatedNum(name); ok && num > maxNum {
+			maxNum = num
+		}
+	}
+
+	if err := r.out.Close(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%d", r.filename, maxNum+1)
+	renameErr := os.Rename(r.filename, rotated)
+
+	// Reopen the log file even when it couldn't be renamed, so logging
+	// continues and the rotation is retried once it is due again.
+	r.out, err = os.OpenFile(r.filename,
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		r.out = nil
+		return err
+	}
+	r.size = 0
+	if r.cfg.Interval > 0 {
+		r.nextRotation = time.Now().Add(r.cfg.Interval)
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if r.cfg.MaxFiles > 0 {
+		for _, name := range existing {
+			num, ok := r.rotatedNum(name)
+			if ok && num <= maxNum+1-r.cfg.MaxFiles {
+				os.Remove(name)
+			}
+		}
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := compress(rotated); err == nil {
+			os.Remove(rotated)
+		}
+	}()
+
+	return nil
+}
+
+// compress writes the passed file compressed with gzip to the file with the
+// same name followed by .gz.
+func compress(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	arc, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0644)
+	if err != nil {
+		return err
+	}
+	z := gzip.NewWriter(arc)
+	if _, err := io.Copy(z, f); err != nil {
+		arc.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := z.Close(); err != nil {
+		arc.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	return arc.Close()
+}
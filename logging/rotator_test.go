@@ -0,0 +1,113 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// rotatedFiles returns the names of the rotated files of the passed log file.
+func rotatedFiles(t *testing.T, logFile string) []string {
+	t.Helper()
+
+	names, err := filepath.Glob(logFile + ".*")
+	if err != nil {
+		t.Fatalf("Glob: unexpected error: %v", err)
+	}
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestRotatorSize ensures the log file is rotated once it reaches the maximum
+// size, only at the end of lines, and that only the configured number of
+// rotated files is kept.
+func TestRotatorSize(t *testing.T) {
+	t.Parallel()
+
+	logFile := filepath.Join(t.TempDir(), "btcd.log")
+	r, err := NewRotator(logFile, &RotatorConfig{MaxSize: 10, MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("NewRotator: unexpected error: %v", err)
+	}
+
+	// A partial line is not rotated even though it exceeds the size.
+	r.Write([]byte("0123456789ab"))
+	r.Write([]byte("c\n"))
+	for i := 0; i < 3; i++ {
+		r.Write([]byte("0123456789\n"))
+	}
+	r.Write([]byte("tail\n"))
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	want := []string{"btcd.log.3.gz", "btcd.log.4.gz"}
+	got := rotatedFiles(t, logFile)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got rotated files %v, want %v", got, want)
+	}
+
+	f, err := os.Open(logFile + ".4.gz")
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: unexpected error: %v", err)
+	}
+	content, err := io.ReadAll(z)
+	if err != nil || string(content) != "0123456789\n" {
+		t.Fatalf("got rotated content %q (%v)", content, err)
+	}
+	content, err = os.ReadFile(logFile)
+	if err != nil || string(content) != "tail\n" {
+		t.Fatalf("got log file content %q (%v)", content, err)
+	}
+}
+
+// TestRotatorInterval ensures the log file is rotated once the interval passed
+// regardless of its size.
+func TestRotatorInterval(t *testing.T) {
+	t.Parallel()
+
+	logFile := filepath.Join(t.TempDir(), "btcd.log")
+	r, err := NewRotator(logFile, &RotatorConfig{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRotator: unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	r.Write([]byte("first\n"))
+	if got := rotatedFiles(t, logFile); len(got) != 0 {
+		t.Fatalf("rotated before the interval passed: %v", got)
+	}
+
+	r.mtx.Lock()
+	r.nextRotation = time.Now().Add(-time.Second)
+	r.mtx.Unlock()
+	r.Write([]byte("second\n"))
+	r.Write([]byte("third\n"))
+	r.wg.Wait()
+
+	if got := rotatedFiles(t, logFile); len(got) != 1 ||
+		got[0] != "btcd.log.1.gz" {
+
+		t.Fatalf("got rotated files %v, want [btcd.log.1.gz]", got)
+	}
+	content, err := os.ReadFile(logFile)
+	if err != nil || string(content) != "third\n" {
+		t.Fatalf("got log file content %q (%v)", content, err)
+	}
+}
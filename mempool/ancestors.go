@@ -0,0 +1,336 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultMaxAncestorCount is the default maximum number of unconfirmed
+	// ancestors, including itself, a transaction of the pool may have.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default maximum total virtual size in
+	// bytes of a transaction of the pool and its unconfirmed ancestors.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default maximum number of
+	// unconfirmed descendants, including itself, a transaction of the pool
+	// may have.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default maximum total virtual size in
+	// bytes of a transaction of the pool and its unconfirmed descendants.
+	DefaultMaxDescendantSize = 101000
+)
+
+// unconfirmedAncestors returns all of the unconfirmed ancestors of the passed
+// transaction.  Unlike txAncestors, the ancestors include the transactions of
+// the passed package, if any, which have not been added to the pool yet.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) unconfirmedAncestors(tx *btcutil.Tx,
+	pkg *packageContext) map[chainhash.Hash]*btcutil.Tx {
+
+	ancestors := make(map[chainhash.Hash]*btcutil.Tx)
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	var addParents func(tx *btcutil.Tx)
+	addParents = func(tx *btcutil.Tx) {
+		for _, txIn := range tx.MsgTx().TxIn {
+			hash := txIn.PreviousOutPoint.Hash
+			if _, ok := ancestors[hash]; ok {
+				continue
+			}
+
+			if parent, ok := mp.pool[hash]; ok {
+				ancestors[hash] = parent.Tx
+				for ancestorHash, ancestor := range mp.txAncestors(
+					parent.Tx, cache) {
+
+					ancestors[ancestorHash] = ancestor
+				}
+				continue
+			}
+
+			if pkg == nil {
+				continue
+			}
+			if parent, ok := pkg.txns[hash]; ok {
+				ancestors[hash] = parent
+				addParents(parent)
+			}
+		}
+	}
+	addParents(tx)
+
+	return ancestors
+}
+
+// checkAncestorLimits ensures the passed transaction, which has the passed
+// virtual size, doesn't exceed the limits of the number and total size of its
+// unconfirmed ancestors, and that it doesn't make any of its ancestors exceed
+// the limits of the number and total size of their unconfirmed descendants.
+// The passed conflicts, which are removed from the pool when the transaction
+// is accepted, don't count towards the descendants of its ancestors.
+//
+// When the transaction is evaluated as part of a package, the transactions of
+// the package that precede it count towards the limits as if they were in the
+// pool already.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkAncestorLimits(tx *btcutil.Tx, txSize int64,
+	conflicts map[chainhash.Hash]*btcutil.Tx, pkg *packageContext) error {
+
+	policy := &mp.cfg.Policy
+	ancestors := mp.unconfirmedAncestors(tx, pkg)
+	ancestorCount := len(ancestors) + 1
+	if policy.MaxAncestorCount > 0 &&
+		ancestorCount > policy.MaxAncestorCount {
+
+		str := fmt.Sprintf("transaction %v has too many unconfirmed "+
+			"ancestors: max is %d, has %d", tx.Hash(),
+			policy.MaxAncestorCount, ancestorCount)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	ancestorSize := txSize
+	for _, ancestor := range ancestors {
+		ancestorSize += GetTxVirtualSize(ancestor)
+	}
+	if policy.MaxAncestorSize > 0 && ancestorSize > policy.MaxAncestorSize {
+		str := fmt.Sprintf("transaction %v has unconfirmed ancestors "+
+			"which are too large: max is %d vbytes, has %d",
+			tx.Hash(), policy.MaxAncestorSize, ancestorSize)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	if policy.MaxDescendantCount <= 0 && policy.MaxDescendantSize <= 0 {
+		return nil
+	}
+
+	// The transactions of the package which are ancestors of the
+	// transaction are descendants of their own ancestors as well.
+	pkgAncestors := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	if pkg != nil {
+		for hash, ancestor := range ancestors {
+			if _, ok := pkg.txns[hash]; ok {
+				pkgAncestors[hash] = mp.unconfirmedAncestors(
+					ancestor, pkg,
+				)
+			}
+		}
+	}
+
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	for hash, ancestor := range ancestors {
+		descendantCount := 2
+		descendantSize := GetTxVirtualSize(ancestor) + txSize
+		if _, ok := mp.pool[hash]; ok {
+			for descendantHash, descendant := range mp.txDescendants(
+				ancestor, cache) {
+
+				if _, ok := conflicts[descendantHash]; ok {
+					continue
+				}
+				descendantCount++
+				descendantSize += GetTxVirtualSize(descendant)
+			}
+		}
+		for pkgHash, pkgTxAncestors := range pkgAncestors {
+			if _, ok := pkgTxAncestors[hash]; ok {
+				descendantCount++
+				descendantSize += GetTxVirtualSize(pkg.txns[pkgHash])
+			}
+		}
+
+		if policy.MaxDescendantCount > 0 &&
+			descendantCount > policy.MaxDescendantCount {
+
+			str := fmt.Sprintf("transaction %v exceeds the "+
+				"descendant limit of unconfirmed ancestor %v: "+
+				"max is %d, would have %d", tx.Hash(), hash,
+				policy.MaxDescendantCount, descendantCount)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		if policy.MaxDescendantSize > 0 &&
+			descendantSize > policy.MaxDescendantSize {
+
+			str := fmt.Sprintf("transaction %v exceeds the "+
+				"descendant size limit of unconfirmed ancestor "+
+				"%v: max is %d vbytes, would have %d", tx.Hash(),
+				hash, policy.MaxDescendantSize, descendantSize)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	return nil
+}
+
+// sortedHashStrings returns the strings of the hashes of the passed
+// transactions in order.
+func sortedHashStrings(txns map[chainhash.Hash]*btcutil.Tx) []string {
+	hashes := sortedTxHashes(txns)
+	strs := make([]string, len(hashes))
+	for i := range hashes {
+		strs[i] = hashes[i].String()
+	}
+	return strs
+}
+
+// mempoolEntry returns the passed transaction of the pool as a fully populated
+// btcjson result including the number, total size, and total fees of its
+// unconfirmed ancestors and descendants.  The caches are optional and serve
+// as an optimization when the entries of several related transactions are
+// returned.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntry(txD *TxDesc, ancestorCache,
+	descendantCache map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx) *btcjson.GetMempoolEntryResult {
+
+	tx := txD.Tx
+	vsize := GetTxVirtualSize(tx)
+	ancestors := mp.txAncestors(tx, ancestorCache)
+	modifiedFee := txD.Fee + txD.FeeDelta
+	ancestorSize, ancestorFees := vsize, modifiedFee
+	for hash, ancestor := range ancestors {
+		ancestorSize += GetTxVirtualSize(ancestor)
+		ancestorFees += mp.pool[hash].Fee + mp.pool[hash].FeeDelta
+	}
+	descendants := mp.txDescendants(tx, descendantCache)
+	descendantSize, descendantFees := vsize, modifiedFee
+	for hash, descendant := range descendants {
+		descendantSize += GetTxVirtualSize(descendant)
+		descendantFees += mp.pool[hash].Fee + mp.pool[hash].FeeDelta
+	}
+
+	// The direct parents and children of the transaction are the ones it
+	// depends on and the ones which spend it.
+	parents := make(map[chainhash.Hash]*btcutil.Tx)
+	for _, txIn := range tx.MsgTx().TxIn {
+		if parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]; ok {
+			parents[*parent.Tx.Hash()] = parent.Tx
+		}
+	}
+	children := make(map[chainhash.Hash]*btcutil.Tx)
+	op := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		op.Index = uint32(i)
+		if child, ok := mp.outpoints[op]; ok {
+			children[*child.Hash()] = child
+		}
+	}
+
+	fee := btcutil.Amount(txD.Fee).ToBTC()
+	return &btcjson.GetMempoolEntryResult{
+		VSize:           int32(vsize),
+		Size:            int32(tx.MsgTx().SerializeSize()),
+		Weight:          blockchain.GetTransactionWeight(tx),
+		Fee:             fee,
+		ModifiedFee:     btcutil.Amount(modifiedFee).ToBTC(),
+		Time:            txD.Added.Unix(),
+		Height:          int64(txD.Height),
+		DescendantCount: int64(len(descendants) + 1),
+		DescendantSize:  descendantSize,
+		DescendantFees:  float64(descendantFees),
+		AncestorCount:   int64(len(ancestors) + 1),
+		AncestorSize:    ancestorSize,
+		AncestorFees:    float64(ancestorFees),
+		WTxId:           tx.WitnessHash().String(),
+		Fees: btcjson.MempoolFees{
+			Base:       fee,
+			Modified:   btcutil.Amount(modifiedFee).ToBTC(),
+			Ancestor:   btcutil.Amount(ancestorFees).ToBTC(),
+			Descendant: btcutil.Amount(descendantFees).ToBTC(),
+		},
+		Depends:           sortedHashStrings(parents),
+		SpentBy:           sortedHashStrings(children),
+		BIP125Replaceable: mp.signalsReplacement(tx, nil),
+	}
+}
+
+// mempoolEntries returns the passed transactions of the pool as fully
+// populated btcjson results keyed by transaction hash.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntries(
+	txns map[chainhash.Hash]*btcutil.Tx) map[string]*btcjson.GetMempoolEntryResult {
+
+	ancestorCache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	descendantCache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	entries := make(map[string]*btcjson.GetMempoolEntryResult, len(txns))
+	for hash := range txns {
+		entries[hash.String()] = mp.mempoolEntry(
+			mp.pool[hash], ancestorCache, descendantCache,
+		)
+	}
+	return entries
+}
+
+// MempoolEntry returns the requested transaction of the pool as a fully
+// populated btcjson result including the number, total size, and total fees
+// of its unconfirmed ancestors and descendants.  It returns an error when the
+// transaction is not in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolEntry(txHash *chainhash.Hash) (
+	*btcjson.GetMempoolEntryResult, error) {
+
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	txD, ok := mp.pool[*txHash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %v is not in the pool",
+			txHash)
+	}
+	return mp.mempoolEntry(txD, nil, nil), nil
+}
+
+// MempoolAncestors returns all of the unconfirmed ancestors of the requested
+// transaction of the pool as fully populated btcjson results keyed by
+// transaction hash.  It returns an error when the transaction is not in the
+// main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolAncestors(txHash *chainhash.Hash) (
+	map[string]*btcjson.GetMempoolEntryResult, error) {
+
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	txD, ok := mp.pool[*txHash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %v is not in the pool",
+			txHash)
+	}
+	return mp.mempoolEntries(mp.txAncestors(txD.Tx, nil)), nil
+}
+
+// MempoolDescendants returns all of the unconfirmed descendants of the
+// requested transaction of the pool as fully populated btcjson results keyed
+// by transaction hash.  It returns an error when the transaction is not in the
+// main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolDescendants(txHash *chainhash.Hash) (
+	map[string]*btcjson.GetMempoolEntryResult, error) {
+
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	txD, ok := mp.pool[*txHash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %v is not in the pool",
+			txHash)
+	}
+	return mp.mempoolEntries(mp.txDescendants(txD.Tx, nil)), nil
+}
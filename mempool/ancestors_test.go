@@ -0,0 +1,163 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// spendOutput returns the passed output of the passed transaction as the only
+// input to spend.
+func spendOutput(tx *btcutil.Tx, index uint32) []spendableOutput {
+	return []spendableOutput{txOutToSpendableOut(tx, index)}
+}
+
+// TestAncestorLimits ensures transactions which would exceed the limits of the
+// number of unconfirmed ancestors or descendants are rejected, including when
+// they are evaluated as part of a package.
+func TestAncestorLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	pool := ctx.harness.txPool
+	coinbase := ctx.addCoinbaseTx(1)
+
+	// Create a chain of three transactions where the first one has an
+	// additional output.
+	first := ctx.addSignedTx(spendOutput(coinbase, 0), 2, 1000, false, false)
+	second := ctx.addSignedTx(spendOutput(first, 0), 1, 1000, false, false)
+	third := ctx.addSignedTx(spendOutput(second, 0), 1, 1000, false, false)
+
+	assertRejected := func(tx *btcutil.Tx) {
+		t.Helper()
+
+		_, err := pool.ProcessTransaction(tx, false, false, 0)
+		code, _ := extractRejectCode(err)
+		if code != wire.RejectNonstandard {
+			t.Fatalf("expected transaction exceeding the limits to "+
+				"be rejected, got %v", err)
+		}
+		testPoolMembership(ctx, tx, false, false)
+	}
+
+	// A fourth transaction of the chain has too many ancestors.
+	pool.cfg.Policy.MaxAncestorCount = 3
+	assertRejected(ctx.createSignedTx(spendOutput(third, 0), 1, 1000, false))
+
+	// A transaction spending the additional output of the first one gives
+	// it too many descendants.
+	pool.cfg.Policy.MaxAncestorCount = 0
+	pool.cfg.Policy.MaxDescendantCount = 3
+	assertRejected(ctx.createSignedTx(spendOutput(first, 1), 1, 1000, false))
+
+	// The same goes for the descendant sizes.
+	pool.cfg.Policy.MaxDescendantCount = 0
+	pool.cfg.Policy.MaxDescendantSize = GetTxVirtualSize(first) +
+		GetTxVirtualSize(second) + GetTxVirtualSize(third)
+	assertRejected(ctx.createSignedTx(spendOutput(first, 1), 1, 1000, false))
+
+	// Without limits the transaction is accepted.
+	pool.cfg.Policy.MaxDescendantSize = 0
+	ctx.addSignedTx(spendOutput(first, 1), 1, 1000, false, false)
+
+	// The parent of a package which is not in the pool yet counts towards
+	// the ancestors of its child.
+	pool.cfg.Policy.MaxAncestorCount = 4
+	parent := ctx.createSignedTx(spendOutput(third, 0), 1, 0, false)
+	child := ctx.createSignedTx(spendOutput(parent, 0), 1, 5000, false)
+	result, err := pool.ProcessPackage(msgTxns(parent, child))
+	if err != nil {
+		t.Fatalf("ProcessPackage: unexpected error: %v", err)
+	}
+	if code, _ := extractRejectCode(result.Err); code != wire.RejectNonstandard {
+		t.Fatalf("expected package exceeding the ancestor limit to be "+
+			"rejected, got %v", result.Err)
+	}
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+}
+
+// TestMempoolEntry ensures the entries of transactions of the pool report their
+// unconfirmed ancestors and descendants.
+func TestMempoolEntry(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	pool := ctx.harness.txPool
+	coinbase := ctx.addCoinbaseTx(1)
+
+	// Create a parent with two children, one of which signals
+	// replaceability.
+	parent := ctx.addSignedTx(spendOutput(coinbase, 0), 2, 1000, false, false)
+	child1 := ctx.addSignedTx(spendOutput(parent, 0), 1, 2000, false, false)
+	child2 := ctx.addSignedTx(spendOutput(parent, 1), 1, 3000, true, false)
+
+	entry, err := pool.MempoolEntry(parent.Hash())
+	if err != nil {
+		t.Fatalf("MempoolEntry: unexpected error: %v", err)
+	}
+	wantSize := GetTxVirtualSize(parent) + GetTxVirtualSize(child1) +
+		GetTxVirtualSize(child2)
+	if entry.AncestorCount != 1 || entry.DescendantCount != 3 ||
+		entry.DescendantSize != wantSize ||
+		entry.Fees.Descendant != btcutil.Amount(6000).ToBTC() {
+
+		t.Fatalf("unexpected entry of the parent: %+v", entry)
+	}
+	wantSpentBy := sortedHashStrings(map[chainhash.Hash]*btcutil.Tx{
+		*child1.Hash(): child1,
+		*child2.Hash(): child2,
+	})
+	if !reflect.DeepEqual(entry.SpentBy, wantSpentBy) {
+		t.Fatalf("unexpected spending transactions: got %v, want %v",
+			entry.SpentBy, wantSpentBy)
+	}
+	if entry.BIP125Replaceable {
+		t.Fatal("parent unexpectedly signals replaceability")
+	}
+
+	entry, err = pool.MempoolEntry(child2.Hash())
+	if err != nil {
+		t.Fatalf("MempoolEntry: unexpected error: %v", err)
+	}
+	if entry.AncestorCount != 2 || entry.DescendantCount != 1 ||
+		entry.Fees.Ancestor != btcutil.Amount(4000).ToBTC() ||
+		!reflect.DeepEqual(entry.Depends, []string{parent.Hash().String()}) ||
+		!entry.BIP125Replaceable {
+
+		t.Fatalf("unexpected entry of the child: %+v", entry)
+	}
+
+	ancestors, err := pool.MempoolAncestors(child1.Hash())
+	if err != nil {
+		t.Fatalf("MempoolAncestors: unexpected error: %v", err)
+	}
+	if _, ok := ancestors[parent.Hash().String()]; !ok || len(ancestors) != 1 {
+		t.Fatalf("unexpected ancestors: %v", ancestors)
+	}
+	descendants, err := pool.MempoolDescendants(parent.Hash())
+	if err != nil {
+		t.Fatalf("MempoolDescendants: unexpected error: %v", err)
+	}
+	if len(descendants) != 2 {
+		t.Fatalf("unexpected descendants: %v", descendants)
+	}
+	for _, hash := range wantSpentBy {
+		if _, ok := descendants[hash]; !ok {
+			t.Fatalf("descendant %v is missing", hash)
+		}
+	}
+
+	// Transactions which are not in the pool have no entry.
+	if _, err := pool.MempoolEntry(coinbase.Hash()); err == nil {
+		t.Fatal("MempoolEntry: expected error for transaction which " +
+			"is not in the pool")
+	}
+}
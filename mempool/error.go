@@ -5,7 +5,11 @@
 package mempool
 
 import (
+	"fmt"
+
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/wire"
 )
 
@@ -32,9 +36,14 @@ func (e RuleError) Error() string {
 // rules.  The caller can use type assertions to determine if a failure was
 // specifically due to a rule violation and access the ErrorCode field to
 // ascertain the specific reason for the rule violation.
+//
+// Transactions which were rejected as replacements of transactions in the pool
+// carry the details about the replacement rule they violated in the
+// Replacement field.
 type TxRuleError struct {
-	RejectCode  wire.RejectCode // The code to send with reject messages
-	Description string          // Human readable description of the issue
+	RejectCode  wire.RejectCode       // The code to send with reject messages
+	Description string                // Human readable description of the issue
+	Replacement *ReplacementRejection // Details of a rejected replacement
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -50,6 +59,174 @@ func txRuleError(c wire.RejectCode, desc string) RuleError {
 	}
 }
 
+// policyRuleError creates an underlying TxRuleError for a violation of the
+// given standardness rule and returns a RuleError that encapsulates it.  The
+// description of the error names the violated rule.
+func policyRuleError(rule policy.Rule, c wire.RejectCode, desc string) RuleError {
+	return txRuleError(c, policy.NewError(rule, c, desc).Error())
+}
+
+// replacementRuleError creates an underlying TxRuleError with the given set of
+// arguments and the details of a rejected replacement and returns a RuleError
+// that encapsulates it.
+func replacementRuleError(c wire.RejectCode, desc string,
+	rejection *ReplacementRejection) RuleError {
+
+	return RuleError{
+		Err: TxRuleError{
+			RejectCode:  c,
+			Description: desc,
+			Replacement: rejection,
+		},
+	}
+}
+
+// ReplacementRule identifies a rule of the replace-by-fee (RBF) policy a
+// transaction which conflicts with transactions in the pool violated.  The
+// policy is based on BIP 125 with the additional rules Bitcoin Core enforces.
+type ReplacementRule int
+
+// These constants are used to identify a specific replacement rule.
+const (
+	// RBFReplacementsDisabled indicates the pool is configured to reject
+	// all replacements.
+	RBFReplacementsDisabled ReplacementRule = iota
+
+	// RBFNotSignaled indicates a conflicting transaction doesn't signal
+	// replaceability, explicitly or through its ancestors, while full RBF
+	// is disabled (BIP 125 rule 1).
+	RBFNotSignaled
+
+	// RBFNewUnconfirmedInput indicates the replacement spends unconfirmed
+	// outputs which aren't spent by the conflicting transactions (BIP 125
+	// rule 2).
+	RBFNewUnconfirmedInput
+
+	// RBFInsufficientAbsoluteFee indicates the replacement doesn't pay at
+	// least the fees of all of the transactions it replaces (BIP 125 rule
+	// 3).
+	RBFInsufficientAbsoluteFee
+
+	// RBFInsufficientRelayFee indicates the additional fees paid by the
+	// replacement don't pay for its own bandwidth at the minimum relay fee
+	// rate (BIP 125 rule 4).
+	RBFInsufficientRelayFee
+
+	// RBFTooManyReplacements indicates the replacement would evict more
+	// than the maximum allowed number of transactions (BIP 125 rule 5).
+	RBFTooManyReplacements
+
+	// RBFInsufficientFeeRate indicates the replacement doesn't pay a higher
+	// fee rate than each of the transactions it directly conflicts with.
+	RBFInsufficientFeeRate
+
+	// RBFSpendsConflict indicates the replacement spends outputs of the
+	// transactions it would replace.
+	RBFSpendsConflict
+
+	// RBFPackageTooLarge indicates a package which isn't made up of one
+	// parent and one child attempted to replace transactions.
+	RBFPackageTooLarge
+)
+
+// Map of ReplacementRule values back to their names for pretty printing.
+var replacementRuleStrings = map[ReplacementRule]string{
+	RBFReplacementsDisabled:    "replacements-disabled",
+	RBFNotSignaled:             "replacement-not-signaled",
+	RBFNewUnconfirmedInput:     "replacement-adds-unconfirmed",
+	RBFInsufficientAbsoluteFee: "insufficient-absolute-fee",
+	RBFInsufficientRelayFee:    "insufficient-relay-fee",
+	RBFTooManyReplacements:     "too-many-replacements",
+	RBFInsufficientFeeRate:     "insufficient-fee-rate",
+	RBFSpendsConflict:          "spends-conflicting-tx",
+	RBFPackageTooLarge:         "package-not-1p1c",
+}
+
+// String returns the ReplacementRule as a human-readable name.
+func (r ReplacementRule) String() string {
+	if s := replacementRuleStrings[r]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ReplacementRule (%d)", int(r))
+}
+
+// BIP125Rule returns the number of the BIP 125 rule the replacement rule
+// corresponds to, or zero for rules which are not part of BIP 125.
+func (r ReplacementRule) BIP125Rule() int {
+	switch r {
+	case RBFNotSignaled:
+		return 1
+	case RBFNewUnconfirmedInput:
+		return 2
+	case RBFInsufficientAbsoluteFee:
+		return 3
+	case RBFInsufficientRelayFee:
+		return 4
+	case RBFTooManyReplacements:
+		return 5
+	}
+	return 0
+}
+
+// ReplacementRejection describes why a transaction, or a package, was rejected
+// as a replacement of conflicting transactions in the pool.
+type ReplacementRejection struct {
+	// Rule is the replacement rule that was violated.
+	Rule ReplacementRule
+
+	// Conflicts are the hashes of the transactions in the pool the rule
+	// was violated against.  These are the transactions which don't
+	// signal replaceability, the ones with a fee rate at least as high as
+	// the replacement, the ones spent by the replacement or all of the
+	// transactions which would be replaced depending on the rule.
+	Conflicts []chainhash.Hash
+
+	// Fee and FeeRate are the fee in satoshi and the fee rate in satoshi
+	// per kvB of the replacement.
+	Fee     int64
+	FeeRate int64
+
+	// FeeDelta is the additional fee in satoshi the replacement has to
+	// pay to satisfy the absolute fee rules.  It is only set when one of
+	// these rules was violated.
+	FeeDelta int64
+
+	// FeeRateDelta is the additional fee rate in satoshi per kvB the
+	// replacement has to pay to satisfy the fee rate rule.  It is only set
+	// when that rule was violated.
+	FeeRateDelta int64
+}
+
+// String returns a human-readable summary of the rejection suitable for logs.
+func (r *ReplacementRejection) String() string {
+	str := fmt.Sprintf("rule %v", r.Rule)
+	if n := r.Rule.BIP125Rule(); n != 0 {
+		str += fmt.Sprintf(" (BIP 125 rule %d)", n)
+	}
+	str += fmt.Sprintf(", conflicts %v, fee %d sat, fee rate %d sat/kvB",
+		r.Conflicts, r.Fee, r.FeeRate)
+	if r.FeeDelta != 0 {
+		str += fmt.Sprintf(", needs %d sat more", r.FeeDelta)
+	}
+	if r.FeeRateDelta != 0 {
+		str += fmt.Sprintf(", needs %d sat/kvB more", r.FeeRateDelta)
+	}
+	return str
+}
+
+// ExtractReplacementRejection returns the details of a rejected replacement
+// carried by the passed error, or nil when the error didn't reject a
+// replacement.
+func ExtractReplacementRejection(err error) *ReplacementRejection {
+	if rerr, ok := err.(RuleError); ok {
+		err = rerr.Err
+	}
+	if txErr, ok := err.(TxRuleError); ok {
+		return txErr.Replacement
+	}
+	return nil
+}
+
 // chainRuleError returns a RuleError that encapsulates the given
 // blockchain.RuleError.
 func chainRuleError(chainErr blockchain.RuleError) RuleError {
@@ -100,6 +277,9 @@ func extractRejectCode(err error) (wire.RejectCode, bool) {
 	case TxRuleError:
 		return err.RejectCode, true
 
+	case policy.Error:
+		return err.RejectCode, true
+
 	case nil:
 		return wire.RejectInvalid, false
 	}
@@ -9,11 +9,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
 	"math"
-	"math/rand"
 	"sort"
-	"strings"
 	"sync"
 
 	"github.com/btcsuite/btcd/btcutil"
@@ -21,29 +18,66 @@ import (
 	"github.com/btcsuite/btcd/mining"
 )
 
-// TODO incorporate Alex Morcos' modifications to Gavin's initial model
-// https://lists.linuxfoundation.org/pipermail/bitcoin-dev/2014-October/006824.html
+// The fee estimator follows the design of the block policy estimator of
+// Bitcoin Core.  Transactions are grouped into buckets by their fee rate and
+// the number of blocks it took them to confirm, or that they spent in the pool
+// without confirming, is tracked per bucket as exponentially decaying moving
+// averages over three time horizons.  An estimate for a confirmation target is
+// the fee rate of the cheapest range of buckets whose transactions confirmed
+// within the target often enough.
 
 const (
-	// estimateFeeDepth is the maximum number of blocks before a transaction
-	// is confirmed that we want to track.
-	estimateFeeDepth = 25
-
-	// estimateFeeBinSize is the number of txs stored in each bin.
-	estimateFeeBinSize = 100
-
-	// estimateFeeMaxReplacements is the max number of replacements that
-	// can be made by the txs found in a given block.
-	estimateFeeMaxReplacements = 10
-
-	// DefaultEstimateFeeMaxRollback is the default number of rollbacks
-	// allowed by the fee estimator for orphaned blocks.
-	DefaultEstimateFeeMaxRollback = 2
-
-	// DefaultEstimateFeeMinRegisteredBlocks is the default minimum
-	// number of blocks which must be observed by the fee estimator before
-	// it will provide fee estimations.
-	DefaultEstimateFeeMinRegisteredBlocks = 3
+	// minBucketFeeRate is the upper bound in satoshi per kvB of the bucket
+	// with the lowest fee rates.  Transactions paying less are tracked in
+	// that bucket.
+	minBucketFeeRate = 1000
+
+	// maxBucketFeeRate is the fee rate in satoshi per kvB above which all
+	// transactions are tracked in the same bucket.
+	maxBucketFeeRate = 1e7
+
+	// feeBucketSpacing is the ratio between the fee rates of adjacent
+	// buckets.
+	feeBucketSpacing = 1.05
+
+	// The short horizon tracks confirmation targets of up to 12 blocks and
+	// decays with a half-life of 18 blocks, the medium horizon tracks
+	// targets of up to 48 blocks in periods of 2 blocks and decays with a
+	// half-life of 144 blocks, and the long horizon tracks targets of up to
+	// 1008 blocks in periods of 24 blocks and decays with a half-life of
+	// 1008 blocks.
+	shortBlockPeriods = 12
+	shortScale        = 1
+	shortDecay        = 0.962
+	medBlockPeriods   = 24
+	medScale          = 2
+	medDecay          = 0.9952
+	longBlockPeriods  = 42
+	longScale         = 24
+	longDecay         = 0.99931
+
+	// halfSuccessPct, successPct and doubleSuccessPct are the rates at
+	// which transactions must have confirmed within half the target, the
+	// target and twice the target for their fee rate to be estimated.
+	halfSuccessPct   = 0.6
+	successPct       = 0.85
+	doubleSuccessPct = 0.95
+
+	// sufficientFeeTxs and sufficientTxsShort are the average number of
+	// transactions per block a range of buckets must have received for its
+	// success rate to be evaluated in the medium and long horizons and the
+	// short horizon respectively.
+	sufficientFeeTxs   = 0.1
+	sufficientTxsShort = 0.5
+
+	// MaxEstimateFeeTarget is the maximum confirmation target in blocks the
+	// fee estimator provides estimates for.
+	MaxEstimateFeeTarget = longBlockPeriods * longScale
+
+	// maxEstimateFeeHistory is the maximum number of blocks a saved fee
+	// estimator may be behind the best chain for its state to still be
+	// used after a restart.
+	maxEstimateFeeHistory = 6 * MaxEstimateFeeTarget
 
 	bytePerKb = 1000
 
@@ -54,6 +88,21 @@ var (
 	// EstimateFeeDatabaseKey is the key that we use to
 	// store the fee estimator in the database.
 	EstimateFeeDatabaseKey = []byte("estimatefee")
+
+	// ErrInsufficientFeeData is returned by the fee estimator when it has
+	// not observed enough transactions to provide an estimate.
+	ErrInsufficientFeeData = errors.New("insufficient data or no feerate found")
+
+	// feeBuckets houses the upper bounds of the fee rates in satoshi per kvB
+	// of the buckets transactions are grouped into.  The last bucket has no
+	// upper bound.
+	feeBuckets = func() []float64 {
+		var buckets []float64
+		for bound := float64(minBucketFeeRate); bound <= maxBucketFeeRate; bound *= feeBucketSpacing {
+			buckets = append(buckets, bound)
+		}
+		return append(buckets, math.Inf(1))
+	}()
 )
 
 // SatoshiPerByte is number with units of satoshis per byte.
@@ -90,111 +139,288 @@ func NewSatoshiPerByte(fee btcutil.Amount, size uint32) SatoshiPerByte {
 	return SatoshiPerByte(float64(fee) / float64(size))
 }
 
-// observedTransaction represents an observed transaction and some
-// additional data required for the fee estimation algorithm.
-type observedTransaction struct {
-	// A transaction hash.
-	hash chainhash.Hash
+// feeBucketIndex returns the index of the bucket transactions with the passed
+// fee rate in satoshi per kvB are grouped into.
+func feeBucketIndex(feeRate float64) int {
+	return sort.SearchFloat64s(feeBuckets, feeRate)
+}
 
-	// The fee per byte of the transaction in satoshis.
-	feeRate SatoshiPerByte
+// confirmStats tracks the number of blocks it took the transactions of each fee
+// rate bucket to confirm over a time horizon.  The confirmation targets are
+// tracked in periods of scale blocks.
+type confirmStats struct {
+	scale int32
+	decay float64
+
+	// confAvg houses the moving averages of the number of transactions of
+	// each bucket that confirmed within each number of periods.
+	confAvg [][]float64
+
+	// failAvg houses the moving averages of the number of transactions of
+	// each bucket that left the pool without confirming after each number
+	// of periods.
+	failAvg [][]float64
+
+	// txCtAvg and feeRateAvg house the moving averages of the number of
+	// transactions of each bucket that confirmed and the sum of their fee
+	// rates.
+	txCtAvg    []float64
+	feeRateAvg []float64
+
+	// unconfTxs houses the number of unconfirmed transactions of each
+	// bucket indexed by the height they entered the pool at modulo the
+	// maximum confirmation target.  oldUnconfTxs houses those that are
+	// unconfirmed for longer than that.
+	unconfTxs    [][]int
+	oldUnconfTxs []int
+}
 
-	// The block height when it was observed.
-	observed int32
+// newConfirmStats returns statistics tracking the passed number of periods of
+// scale blocks which decay by the passed factor every block.
+func newConfirmStats(periods, scale int32, decay float64) *confirmStats {
+	numBuckets := len(feeBuckets)
+	stats := &confirmStats{
+		scale:        scale,
+		decay:        decay,
+		confAvg:      make([][]float64, periods),
+		failAvg:      make([][]float64, periods),
+		txCtAvg:      make([]float64, numBuckets),
+		feeRateAvg:   make([]float64, numBuckets),
+		unconfTxs:    make([][]int, periods*scale),
+		oldUnconfTxs: make([]int, numBuckets),
+	}
+	for i := range stats.confAvg {
+		stats.confAvg[i] = make([]float64, numBuckets)
+		stats.failAvg[i] = make([]float64, numBuckets)
+	}
+	for i := range stats.unconfTxs {
+		stats.unconfTxs[i] = make([]int, numBuckets)
+	}
+	return stats
+}
+
+// maxConfirms returns the maximum confirmation target tracked.
+func (s *confirmStats) maxConfirms() int32 {
+	return int32(len(s.confAvg)) * s.scale
+}
 
-	// The height of the block in which it was mined.
-	// If the transaction has not yet been mined, it is zero.
-	mined int32
+// unconfIndex returns the index of unconfTxs for the passed height.
+func (s *confirmStats) unconfIndex(height int32) int {
+	bins := int32(len(s.unconfTxs))
+	return int(((height % bins) + bins) % bins)
 }
 
-func (o *observedTransaction) Serialize(w io.Writer) {
-	binary.Write(w, binary.BigEndian, o.hash)
-	binary.Write(w, binary.BigEndian, o.feeRate)
-	binary.Write(w, binary.BigEndian, o.observed)
-	binary.Write(w, binary.BigEndian, o.mined)
+// clearCurrent moves the unconfirmed transactions that are now unconfirmed for
+// longer than the maximum confirmation target once the block at the passed
+// height is connected.
+func (s *confirmStats) clearCurrent(height int32) {
+	unconfTxs := s.unconfTxs[s.unconfIndex(height)]
+	for bucket, count := range unconfTxs {
+		s.oldUnconfTxs[bucket] += count
+		unconfTxs[bucket] = 0
+	}
 }
 
-func deserializeObservedTransaction(r io.Reader) (*observedTransaction, error) {
-	ot := observedTransaction{}
+// newTx records an unconfirmed transaction of the passed bucket which entered
+// the pool at the passed height.
+func (s *confirmStats) newTx(height int32, bucket int) {
+	s.unconfTxs[s.unconfIndex(height)][bucket]++
+}
 
-	// The first 32 bytes should be a hash.
-	binary.Read(r, binary.BigEndian, &ot.hash)
+// removeTx removes an unconfirmed transaction of the passed bucket which
+// entered the pool at the passed height once the best height is bestHeight.
+// Transactions which left the pool without confirming for at least a period
+// count as failures of the periods they were in the pool for.
+func (s *confirmStats) removeTx(height, bestHeight int32, bucket int,
+	inBlock bool) {
 
-	// The next 8 are SatoshiPerByte
-	binary.Read(r, binary.BigEndian, &ot.feeRate)
+	blocksAgo := bestHeight - height
+	if blocksAgo < 0 {
+		return
+	}
 
-	// And next there are two uint32's.
-	binary.Read(r, binary.BigEndian, &ot.observed)
-	binary.Read(r, binary.BigEndian, &ot.mined)
+	if blocksAgo >= int32(len(s.unconfTxs)) {
+		if s.oldUnconfTxs[bucket] > 0 {
+			s.oldUnconfTxs[bucket]--
+		}
+	} else {
+		unconfTxs := s.unconfTxs[s.unconfIndex(height)]
+		if unconfTxs[bucket] > 0 {
+			unconfTxs[bucket]--
+		}
+	}
 
-	return &ot, nil
+	if !inBlock && blocksAgo >= s.scale {
+		periodsAgo := int(blocksAgo / s.scale)
+		for i := 0; i < periodsAgo && i < len(s.failAvg); i++ {
+			s.failAvg[i][bucket]++
+		}
+	}
 }
 
-// registeredBlock has the hash of a block and the list of transactions
-// it mined which had been previously observed by the FeeEstimator. It
-// is used if Rollback is called to reverse the effect of registering
-// a block.
-type registeredBlock struct {
-	hash         chainhash.Hash
-	transactions []*observedTransaction
-}
+// record records a transaction of the passed bucket and fee rate which
+// confirmed after the passed number of blocks.
+func (s *confirmStats) record(blocksToConfirm int32, bucket int,
+	feeRate float64) {
 
-func (rb *registeredBlock) serialize(w io.Writer, txs map[*observedTransaction]uint32) {
-	binary.Write(w, binary.BigEndian, rb.hash)
+	if blocksToConfirm < 1 {
+		return
+	}
 
-	binary.Write(w, binary.BigEndian, uint32(len(rb.transactions)))
-	for _, o := range rb.transactions {
-		binary.Write(w, binary.BigEndian, txs[o])
+	periodsToConfirm := int((blocksToConfirm + s.scale - 1) / s.scale)
+	for i := periodsToConfirm; i <= len(s.confAvg); i++ {
+		s.confAvg[i-1][bucket]++
 	}
+	s.txCtAvg[bucket]++
+	s.feeRateAvg[bucket] += feeRate
 }
 
-// FeeEstimator manages the data necessary to create
-// fee estimations. It is safe for concurrent access.
-type FeeEstimator struct {
-	maxRollback uint32
-	binSize     int32
+// updateMovingAverages decays all moving averages once a block is connected.
+func (s *confirmStats) updateMovingAverages() {
+	for bucket := range s.txCtAvg {
+		for i := range s.confAvg {
+			s.confAvg[i][bucket] *= s.decay
+			s.failAvg[i][bucket] *= s.decay
+		}
+		s.txCtAvg[bucket] *= s.decay
+		s.feeRateAvg[bucket] *= s.decay
+	}
+}
 
-	// The maximum number of replacements that can be made in a single
-	// bin per block. Default is estimateFeeMaxReplacements
-	maxReplacements int32
+// estimateMedianVal returns the fee rate in satoshi per kvB of the cheapest
+// range of buckets whose transactions confirmed within the passed target at
+// least at the passed success rate, or -1 if there is none.
+//
+// Starting with the highest fee rates, buckets are grouped until they received
+// enough transactions for their success rate to be meaningful.  The estimate is
+// the average fee rate of the bucket holding the median transaction of the last
+// group which passed.
+func (s *confirmStats) estimateMedianVal(confTarget int32,
+	sufficientTxVal, successBreakPoint float64, bestHeight int32) float64 {
+
+	var (
+		nConf, totalNum, failNum float64
+		extraNum                 int
+	)
+	periodTarget := int((confTarget + s.scale - 1) / s.scale)
+	maxBucket := len(feeBuckets) - 1
+	curNearBucket, bestNearBucket := maxBucket, maxBucket
+	curFarBucket, bestFarBucket := maxBucket, maxBucket
+	foundAnswer := false
+	newBucketRange := true
+
+	for bucket := maxBucket; bucket >= 0; bucket-- {
+		if newBucketRange {
+			curNearBucket = bucket
+			newBucketRange = false
+		}
+		curFarBucket = bucket
+		nConf += s.confAvg[periodTarget-1][bucket]
+		totalNum += s.txCtAvg[bucket]
+		failNum += s.failAvg[periodTarget-1][bucket]
+		for confct := confTarget; confct < s.maxConfirms(); confct++ {
+			extraNum += s.unconfTxs[s.unconfIndex(bestHeight-confct)][bucket]
+		}
+		extraNum += s.oldUnconfTxs[bucket]
 
-	// The minimum number of blocks that can be registered with the fee
-	// estimator before it will provide answers.
-	minRegisteredBlocks uint32
+		// Only test the success rate once enough transactions were
+		// confirmed in the range of buckets.
+		if totalNum < sufficientTxVal/(1-s.decay) {
+			continue
+		}
 
-	// The last known height.
-	lastKnownHeight int32
+		curPct := nConf / (totalNum + failNum + float64(extraNum))
+		if curPct < successBreakPoint {
+			continue
+		}
 
-	// The number of blocks that have been registered.
-	numBlocksRegistered uint32
+		// The range passed, so start a new range with the next bucket.
+		foundAnswer = true
+		nConf, totalNum, failNum, extraNum = 0, 0, 0, 0
+		bestNearBucket = curNearBucket
+		bestFarBucket = curFarBucket
+		newBucketRange = true
+	}
 
-	mtx      sync.RWMutex
-	observed map[chainhash.Hash]*observedTransaction
-	bin      [estimateFeeDepth][]*observedTransaction
+	if !foundAnswer {
+		return -1
+	}
+
+	// Report the average fee rate of the bucket with the median
+	// transaction of the best range.
+	minBucket, maxBucket := bestFarBucket, bestNearBucket
+	var txSum float64
+	for bucket := minBucket; bucket <= maxBucket; bucket++ {
+		txSum += s.txCtAvg[bucket]
+	}
+	if txSum == 0 {
+		return -1
+	}
+	txSum /= 2
+	for bucket := minBucket; bucket <= maxBucket; bucket++ {
+		if s.txCtAvg[bucket] < txSum {
+			txSum -= s.txCtAvg[bucket]
+			continue
+		}
+		return s.feeRateAvg[bucket] / s.txCtAvg[bucket]
+	}
+	return -1
+}
+
+// observedTransaction is a transaction of the pool tracked by the fee
+// estimator.
+type observedTransaction struct {
+	// The block height when it was observed.
+	height int32
 
-	// The cached estimates.
-	cached []SatoshiPerByte
+	// The fee rate of the transaction in satoshi per kvB and the bucket it
+	// is grouped into.
+	feeRate float64
+	bucket  int
+}
 
-	// Transactions that have been removed from the bins. This allows us to
-	// revert in case of an orphaned block.
-	dropped []*registeredBlock
+// FeeEstimator manages the data necessary to create
+// fee estimations. It is safe for concurrent access.
+type FeeEstimator struct {
+	mtx sync.RWMutex
+
+	// The last known height and the height of the first block whose
+	// transactions were recorded.
+	lastKnownHeight     int32
+	firstRecordedHeight int32
+
+	// The statistics of the short, medium and long time horizons.
+	shortStats *confirmStats
+	medStats   *confirmStats
+	longStats  *confirmStats
+
+	// observed houses the unconfirmed transactions of the pool which are
+	// tracked while removed houses those which left the pool since the
+	// last block.  The removed transactions count as failures unless they
+	// are included in the next block.
+	observed map[chainhash.Hash]*observedTransaction
+	removed  map[chainhash.Hash]struct{}
 }
 
-// NewFeeEstimator creates a FeeEstimator for which at most maxRollback blocks
-// can be unregistered and which returns an error unless minRegisteredBlocks
-// have been registered with it.
-func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
+// NewFeeEstimator returns a new fee estimator which has not observed any
+// transactions yet.
+func NewFeeEstimator() *FeeEstimator {
 	return &FeeEstimator{
-		maxRollback:         maxRollback,
-		minRegisteredBlocks: minRegisteredBlocks,
 		lastKnownHeight:     mining.UnminedHeight,
-		binSize:             estimateFeeBinSize,
-		maxReplacements:     estimateFeeMaxReplacements,
+		firstRecordedHeight: mining.UnminedHeight,
+		shortStats:          newConfirmStats(shortBlockPeriods, shortScale, shortDecay),
+		medStats:            newConfirmStats(medBlockPeriods, medScale, medDecay),
+		longStats:           newConfirmStats(longBlockPeriods, longScale, longDecay),
 		observed:            make(map[chainhash.Hash]*observedTransaction),
-		dropped:             make([]*registeredBlock, 0, maxRollback),
+		removed:             make(map[chainhash.Hash]struct{}),
 	}
 }
 
+// allStats returns the statistics of all time horizons.
+func (ef *FeeEstimator) allStats() []*confirmStats {
+	return []*confirmStats{ef.shortStats, ef.medStats, ef.longStats}
+}
+
 // ObserveTransaction is called when a new transaction is observed in the mempool.
 func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	ef.mtx.Lock()
@@ -207,132 +433,114 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	}
 
 	hash := *t.Tx.Hash()
-	if _, ok := ef.observed[hash]; !ok {
-		size := uint32(GetTxVirtualSize(t.Tx))
-
-		ef.observed[hash] = &observedTransaction{
-			hash:     hash,
-			feeRate:  NewSatoshiPerByte(btcutil.Amount(t.Fee), size),
-			observed: t.Height,
-			mined:    mining.UnminedHeight,
-		}
+	if _, ok := ef.observed[hash]; ok {
+		return
+	}
+
+	feeRate := float64(t.Fee) * bytePerKb / float64(GetTxVirtualSize(t.Tx))
+	o := &observedTransaction{
+		height:  ef.lastKnownHeight,
+		feeRate: feeRate,
+		bucket:  feeBucketIndex(feeRate),
+	}
+	for _, stats := range ef.allStats() {
+		stats.newTx(o.height, o.bucket)
 	}
+	ef.observed[hash] = o
+	delete(ef.removed, hash)
 }
 
-// RegisterBlock informs the fee estimator of a new block to take into account.
-func (ef *FeeEstimator) RegisterBlock(block *btcutil.Block) error {
+// RemoveTransaction is called when a transaction leaves the mempool.  It counts
+// as a failure to confirm unless it is included in the next registered block.
+func (ef *FeeEstimator) RemoveTransaction(hash *chainhash.Hash) {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
-	// The previous sorted list is invalid, so delete it.
-	ef.cached = nil
-
-	height := block.Height()
-	if height != ef.lastKnownHeight+1 && ef.lastKnownHeight != mining.UnminedHeight {
-		return fmt.Errorf("intermediate block not recorded; current height is %d; new height is %d",
-			ef.lastKnownHeight, height)
+	if _, ok := ef.observed[*hash]; ok {
+		ef.removed[*hash] = struct{}{}
 	}
+}
 
-	// Update the last known height.
-	ef.lastKnownHeight = height
-	ef.numBlocksRegistered++
-
-	// Randomly order txs in block.
-	transactions := make(map[*btcutil.Tx]struct{})
-	for _, t := range block.Transactions() {
-		transactions[t] = struct{}{}
+// removeObserved stops tracking the passed transaction, which was either
+// included in a block or left the pool without confirming.
+func (ef *FeeEstimator) removeObserved(hash chainhash.Hash, inBlock bool) {
+	o := ef.observed[hash]
+	for _, stats := range ef.allStats() {
+		stats.removeTx(o.height, ef.lastKnownHeight, o.bucket, inBlock)
 	}
+	delete(ef.observed, hash)
+	delete(ef.removed, hash)
+}
 
-	// Count the number of replacements we make per bin so that we don't
-	// replace too many.
-	var replacementCounts [estimateFeeDepth]int
+// RegisterBlock informs the fee estimator of a new block to take into account.
+// The time the observed transactions included in the block took to confirm is
+// recorded, while the transactions which left the pool since the previous
+// block without being included count as failures.
+//
+// Blocks which don't extend the highest block registered so far, such as those
+// connected during a reorganization, are not taken into account.  The observed
+// transactions they include stop being tracked without recording how long they
+// took to confirm or counting as failures.  Likewise, disconnected blocks are
+// not rolled back, which would require keeping the moving averages from before
+// every recent block.  Reorganizations are rare and shallow enough not to bias
+// the estimates noticeably, which is also what Bitcoin Core assumes.
+func (ef *FeeEstimator) RegisterBlock(block *btcutil.Block) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
 
-	// Keep track of which txs were dropped in case of an orphan block.
-	dropped := &registeredBlock{
-		hash:         *block.Hash(),
-		transactions: make([]*observedTransaction, 0, 100),
+	height := block.Height()
+	if ef.lastKnownHeight != mining.UnminedHeight &&
+		height <= ef.lastKnownHeight {
+
+		var numRemoved int
+		for _, tx := range block.Transactions() {
+			if _, ok := ef.observed[*tx.Hash()]; ok {
+				ef.removeObserved(*tx.Hash(), true)
+				numRemoved++
+			}
+		}
+		log.Debugf("Fee estimator ignored block %v (height %d) below "+
+			"the last known height %d, stopped tracking %d "+
+			"transactions", block.Hash(), height, ef.lastKnownHeight,
+			numRemoved)
+		return
 	}
+	ef.lastKnownHeight = height
 
-	// Go through the txs in the block.
-	for t := range transactions {
-		hash := *t.Hash()
+	for _, stats := range ef.allStats() {
+		stats.clearCurrent(height)
+	}
 
-		// Have we observed this tx in the mempool?
+	var numRecorded int
+	for _, tx := range block.Transactions() {
+		hash := *tx.Hash()
 		o, ok := ef.observed[hash]
 		if !ok {
 			continue
 		}
+		ef.removeObserved(hash, true)
 
-		// Put the observed tx in the oppropriate bin.
-		blocksToConfirm := height - o.observed - 1
-
-		// This shouldn't happen if the fee estimator works correctly,
-		// but return an error if it does.
-		if o.mined != mining.UnminedHeight {
-			log.Error("Estimate fee: transaction ", hash.String(), " has already been mined")
-			return errors.New("Transaction has already been mined")
+		blocksToConfirm := height - o.height
+		for _, stats := range ef.allStats() {
+			stats.record(blocksToConfirm, o.bucket, o.feeRate)
 		}
-
-		// This shouldn't happen but check just in case to avoid
-		// an out-of-bounds array index later.
-		//
-		// Also check that blocksToConfirm is not negative as this causes
-		// the node to crash on reorgs.  A tx that was observed at height X
-		// might be included in heights less than X because of chain reorgs.
-		// Refer to github.com/btcsuite/btcd/issues/1660 for more information.
-		//
-		// TODO(kcalvinalvin) a better design that doesn't just skip over the
-		// transaction would result in a more accurate fee estimator.  Properly
-		// implement this later.
-		if blocksToConfirm >= estimateFeeDepth || blocksToConfirm < 0 {
-			continue
-		}
-
-		// Make sure we do not replace too many transactions per min.
-		if replacementCounts[blocksToConfirm] == int(ef.maxReplacements) {
-			continue
-		}
-
-		o.mined = height
-
-		replacementCounts[blocksToConfirm]++
-
-		bin := ef.bin[blocksToConfirm]
-
-		// Remove a random element and replace it with this new tx.
-		if len(bin) == int(ef.binSize) {
-			// Don't drop transactions we have just added from this same block.
-			l := int(ef.binSize) - replacementCounts[blocksToConfirm]
-			drop := rand.Intn(l)
-			dropped.transactions = append(dropped.transactions, bin[drop])
-
-			bin[drop] = bin[l-1]
-			bin[l-1] = o
-		} else {
-			bin = append(bin, o)
-		}
-		ef.bin[blocksToConfirm] = bin
+		numRecorded++
 	}
-
-	// Go through the mempool for txs that have been in too long.
-	for hash, o := range ef.observed {
-		if o.mined == mining.UnminedHeight && height-o.observed >= estimateFeeDepth {
-			delete(ef.observed, hash)
-		}
+	for hash := range ef.removed {
+		ef.removeObserved(hash, false)
 	}
 
-	// Add dropped list to history.
-	if ef.maxRollback == 0 {
-		return nil
+	for _, stats := range ef.allStats() {
+		stats.updateMovingAverages()
 	}
 
-	if uint32(len(ef.dropped)) == ef.maxRollback {
-		ef.dropped = append(ef.dropped[1:], dropped)
-	} else {
-		ef.dropped = append(ef.dropped, dropped)
+	if numRecorded > 0 && ef.firstRecordedHeight == mining.UnminedHeight {
+		ef.firstRecordedHeight = height
 	}
 
-	return nil
+	log.Debugf("Fee estimator recorded %d of %d transactions of block %v "+
+		"(height %d), tracking %d unconfirmed transactions", numRecorded,
+		len(block.Transactions()), block.Hash(), height, len(ef.observed))
 }
 
 // LastKnownHeight returns the height of the last block which was registered.
@@ -343,344 +551,207 @@ func (ef *FeeEstimator) LastKnownHeight() int32 {
 	return ef.lastKnownHeight
 }
 
-// Rollback unregisters a recently registered block from the FeeEstimator.
-// This can be used to reverse the effect of an orphaned block on the fee
-// estimator. The maximum number of rollbacks allowed is given by
-// maxRollbacks.
-//
-// Note: not everything can be rolled back because some transactions are
-// deleted if they have been observed too long ago. That means the result
-// of Rollback won't always be exactly the same as if the last block had not
-// happened, but it should be close enough.
-func (ef *FeeEstimator) Rollback(hash *chainhash.Hash) error {
-	ef.mtx.Lock()
-	defer ef.mtx.Unlock()
-
-	// Find this block in the stack of recent registered blocks.
-	var n int
-	for n = 1; n <= len(ef.dropped); n++ {
-		if ef.dropped[len(ef.dropped)-n].hash.IsEqual(hash) {
-			break
-		}
-	}
-
-	if n > len(ef.dropped) {
-		return errors.New("no such block was recently registered")
+// maxUsableEstimate returns the highest confirmation target an estimate can be
+// provided for, which is half the number of blocks whose transactions were
+// recorded.
+func (ef *FeeEstimator) maxUsableEstimate() int32 {
+	if ef.firstRecordedHeight == mining.UnminedHeight {
+		return 0
 	}
 
-	for i := 0; i < n; i++ {
-		ef.rollback()
+	maxTarget := (ef.lastKnownHeight - ef.firstRecordedHeight) / 2
+	if maxTarget > MaxEstimateFeeTarget {
+		maxTarget = MaxEstimateFeeTarget
 	}
-
-	return nil
+	return maxTarget
 }
 
-// rollback rolls back the effect of the last block in the stack
-// of registered blocks.
-func (ef *FeeEstimator) rollback() {
-	// The previous sorted list is invalid, so delete it.
-	ef.cached = nil
-
-	// pop the last list of dropped txs from the stack.
-	last := len(ef.dropped) - 1
-	if last == -1 {
-		// Cannot really happen because the exported calling function
-		// only rolls back a block already known to be in the list
-		// of dropped transactions.
-		return
-	}
-
-	dropped := ef.dropped[last]
-
-	// where we are in each bin as we replace txs?
-	var replacementCounters [estimateFeeDepth]int
-
-	// Go through the txs in the dropped block.
-	for _, o := range dropped.transactions {
-		// Which bin was this tx in?
-		blocksToConfirm := o.mined - o.observed - 1
-
-		bin := ef.bin[blocksToConfirm]
-
-		var counter = replacementCounters[blocksToConfirm]
-
-		// Continue to go through that bin where we left off.
-		for {
-			if counter >= len(bin) {
-				// Panic, as we have entered an unrecoverable invalid state.
-				panic(errors.New("illegal state: cannot rollback dropped transaction"))
-			}
-
-			prev := bin[counter]
-
-			if prev.mined == ef.lastKnownHeight {
-				prev.mined = mining.UnminedHeight
-
-				bin[counter] = o
-
-				counter++
-				break
-			}
-
-			counter++
+// estimateCombinedFee returns the fee rate in satoshi per kvB for the passed
+// confirmation target at the passed success rate from the shortest time
+// horizon which tracks the target, or -1 if there is none.  When
+// checkShorterHorizon is true, the maximum targets of the shorter horizons are
+// considered as well and the lowest fee rate is returned.
+func (ef *FeeEstimator) estimateCombinedFee(confTarget int32,
+	successThreshold float64, checkShorterHorizon bool) float64 {
+
+	if confTarget < 1 || confTarget > ef.longStats.maxConfirms() {
+		return -1
+	}
+
+	var estimate float64
+	switch {
+	case confTarget <= ef.shortStats.maxConfirms():
+		estimate = ef.shortStats.estimateMedianVal(confTarget,
+			sufficientTxsShort, successThreshold, ef.lastKnownHeight)
+	case confTarget <= ef.medStats.maxConfirms():
+		estimate = ef.medStats.estimateMedianVal(confTarget,
+			sufficientFeeTxs, successThreshold, ef.lastKnownHeight)
+	default:
+		estimate = ef.longStats.estimateMedianVal(confTarget,
+			sufficientFeeTxs, successThreshold, ef.lastKnownHeight)
+	}
+	if !checkShorterHorizon {
+		return estimate
+	}
+
+	// Use the estimate of a lower target from a more recent horizon if it
+	// is lower.
+	shorter := []struct {
+		stats      *confirmStats
+		sufficient float64
+	}{
+		{ef.medStats, sufficientFeeTxs},
+		{ef.shortStats, sufficientTxsShort},
+	}
+	for _, h := range shorter {
+		maxConfirms := h.stats.maxConfirms()
+		if confTarget <= maxConfirms {
+			continue
 		}
+		shorterEstimate := h.stats.estimateMedianVal(maxConfirms,
+			h.sufficient, successThreshold, ef.lastKnownHeight)
+		if shorterEstimate > 0 && (estimate == -1 ||
+			shorterEstimate < estimate) {
 
-		replacementCounters[blocksToConfirm] = counter
-	}
-
-	// Continue going through bins to find other txs to remove
-	// which did not replace any other when they were entered.
-	for i, j := range replacementCounters {
-		for {
-			l := len(ef.bin[i])
-			if j >= l {
-				break
-			}
-
-			prev := ef.bin[i][j]
-
-			if prev.mined == ef.lastKnownHeight {
-				prev.mined = mining.UnminedHeight
-
-				newBin := append(ef.bin[i][0:j], ef.bin[i][j+1:l]...)
-				// TODO This line should prevent an unintentional memory
-				// leak but it causes a panic when it is uncommented.
-				// ef.bin[i][j] = nil
-				ef.bin[i] = newBin
-
-				continue
-			}
-
-			j++
+			estimate = shorterEstimate
 		}
 	}
-
-	ef.dropped = ef.dropped[0:last]
-
-	// The number of blocks the fee estimator has seen is decrimented.
-	ef.numBlocksRegistered--
-	ef.lastKnownHeight--
-}
-
-// estimateFeeSet is a set of txs that can that is sorted
-// by the fee per kb rate.
-type estimateFeeSet struct {
-	feeRate []SatoshiPerByte
-	bin     [estimateFeeDepth]uint32
+	return estimate
 }
 
-func (b *estimateFeeSet) Len() int { return len(b.feeRate) }
-
-func (b *estimateFeeSet) Less(i, j int) bool {
-	return b.feeRate[i] > b.feeRate[j]
-}
-
-func (b *estimateFeeSet) Swap(i, j int) {
-	b.feeRate[i], b.feeRate[j] = b.feeRate[j], b.feeRate[i]
+// estimateConservativeFee returns the highest fee rate in satoshi per kvB of
+// the medium and long time horizons for the passed target, which is twice the
+// target requested, at the highest success rate, or -1 if there is none.  This
+// accounts for fee rates which rose recently but have not yet decayed in the
+// longer horizons.
+func (ef *FeeEstimator) estimateConservativeFee(doubleTarget int32) float64 {
+	estimate := float64(-1)
+	if doubleTarget <= ef.shortStats.maxConfirms() {
+		estimate = ef.medStats.estimateMedianVal(doubleTarget,
+			sufficientFeeTxs, doubleSuccessPct, ef.lastKnownHeight)
+	}
+	if doubleTarget <= ef.medStats.maxConfirms() {
+		longEstimate := ef.longStats.estimateMedianVal(doubleTarget,
+			sufficientFeeTxs, doubleSuccessPct, ef.lastKnownHeight)
+		if longEstimate > estimate {
+			estimate = longEstimate
+		}
+	}
+	return estimate
 }
 
-// estimateFee returns the estimated fee for a transaction
-// to confirm in confirmations blocks from now, given
-// the data set we have collected.
-func (b *estimateFeeSet) estimateFee(confirmations int) SatoshiPerByte {
-	if confirmations <= 0 {
-		return SatoshiPerByte(math.Inf(1))
-	}
+// EstimateSmartFee returns the fee rate in satoshi per kvB a transaction needs
+// to pay to confirm within the passed number of blocks along with the number
+// of blocks the estimate is for, which is lower than the target when not
+// enough blocks have been observed for it.  The estimate is the highest of the
+// fee rates which confirmed within half the target, the target and twice the
+// target at increasing success rates.  Conservative estimates also consider the
+// longer time horizons, so they are less responsive to short-term drops of the
+// fee rates.
+//
+// ErrInsufficientFeeData is returned when not enough transactions have been
+// observed to provide an estimate.
+func (ef *FeeEstimator) EstimateSmartFee(confTarget int32,
+	conservative bool) (btcutil.Amount, int32, error) {
 
-	if confirmations > estimateFeeDepth {
-		return 0
+	if confTarget < 1 || confTarget > MaxEstimateFeeTarget {
+		return 0, 0, fmt.Errorf("confirmation target must be between "+
+			"1 and %d", MaxEstimateFeeTarget)
 	}
 
-	// We don't have any transactions!
-	if len(b.feeRate) == 0 {
-		return 0
-	}
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
 
-	var min, max int = 0, 0
-	for i := 0; i < confirmations-1; i++ {
-		min += int(b.bin[i])
+	// Transactions can't be expected to confirm in the next block
+	// reliably.
+	if confTarget == 1 {
+		confTarget = 2
 	}
-
-	max = min + int(b.bin[confirmations-1]) - 1
-	if max < min {
-		max = min
+	if maxTarget := ef.maxUsableEstimate(); confTarget > maxTarget {
+		confTarget = maxTarget
 	}
-	feeIndex := (min + max) / 2
-	if feeIndex >= len(b.feeRate) {
-		feeIndex = len(b.feeRate) - 1
+	if confTarget <= 1 {
+		return 0, 0, ErrInsufficientFeeData
 	}
 
-	return b.feeRate[feeIndex]
-}
-
-// newEstimateFeeSet creates a temporary data structure that
-// can be used to find all fee estimates.
-func (ef *FeeEstimator) newEstimateFeeSet() *estimateFeeSet {
-	set := &estimateFeeSet{}
-
-	capacity := 0
-	for i, b := range ef.bin {
-		l := len(b)
-		set.bin[i] = uint32(l)
-		capacity += l
+	median := ef.estimateCombinedFee(confTarget/2, halfSuccessPct, true)
+	actualEst := ef.estimateCombinedFee(confTarget, successPct, true)
+	if actualEst > median {
+		median = actualEst
 	}
-
-	set.feeRate = make([]SatoshiPerByte, capacity)
-
-	i := 0
-	for _, b := range ef.bin {
-		for _, o := range b {
-			set.feeRate[i] = o.feeRate
-			i++
+	doubleEst := ef.estimateCombinedFee(2*confTarget, doubleSuccessPct,
+		!conservative)
+	if doubleEst > median {
+		median = doubleEst
+	}
+	if conservative || median == -1 {
+		consEst := ef.estimateConservativeFee(2 * confTarget)
+		if consEst > median {
+			median = consEst
 		}
 	}
-
-	sort.Sort(set)
-
-	return set
-}
-
-// estimates returns the set of all fee estimates from 1 to estimateFeeDepth
-// confirmations from now.
-func (ef *FeeEstimator) estimates() []SatoshiPerByte {
-	set := ef.newEstimateFeeSet()
-
-	estimates := make([]SatoshiPerByte, estimateFeeDepth)
-	for i := 0; i < estimateFeeDepth; i++ {
-		estimates[i] = set.estimateFee(i + 1)
+	if median < 0 {
+		return 0, confTarget, ErrInsufficientFeeData
 	}
 
-	return estimates
+	return btcutil.Amount(math.Round(median)), confTarget, nil
 }
 
-// EstimateFee estimates the fee per byte to have a tx confirmed a given
-// number of blocks from now.
+// EstimateFee estimates the fee per kilobyte to have a tx confirmed a given
+// number of blocks from now.  It returns the economical estimate of
+// EstimateSmartFee.
 func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
-	ef.mtx.Lock()
-	defer ef.mtx.Unlock()
-
-	// If the number of registered blocks is below the minimum, return
-	// an error.
-	if ef.numBlocksRegistered < ef.minRegisteredBlocks {
-		return -1, errors.New("not enough blocks have been observed")
-	}
-
 	if numBlocks == 0 {
 		return -1, errors.New("cannot confirm transaction in zero blocks")
 	}
 
-	if numBlocks > estimateFeeDepth {
+	if numBlocks > MaxEstimateFeeTarget {
 		return -1, fmt.Errorf(
 			"can only estimate fees for up to %d blocks from now",
-			estimateFeeDepth)
+			MaxEstimateFeeTarget)
 	}
 
-	// If there are no cached results, generate them.
-	if ef.cached == nil {
-		ef.cached = ef.estimates()
+	feeRate, _, err := ef.EstimateSmartFee(int32(numBlocks), false)
+	if err != nil {
+		return -1, err
 	}
 
-	return ef.cached[int(numBlocks)-1].ToBtcPerKb(), nil
+	return BtcPerKilobyte(feeRate.ToBTC()), nil
 }
 
 // In case the format for the serialized version of the FeeEstimator changes,
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
 // start fee estimation over.
-const estimateFeeSaveVersion = 1
-
-func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
-	var lenTransactions uint32
-
-	rb := &registeredBlock{}
-	binary.Read(r, binary.BigEndian, &rb.hash)
-	binary.Read(r, binary.BigEndian, &lenTransactions)
-
-	rb.transactions = make([]*observedTransaction, lenTransactions)
-
-	for i := uint32(0); i < lenTransactions; i++ {
-		var index uint32
-		binary.Read(r, binary.BigEndian, &index)
-		rb.transactions[i] = txs[index]
-	}
-
-	return rb, nil
-}
+const estimateFeeSaveVersion = 2
 
 // FeeEstimatorState represents a saved FeeEstimator that can be
 // restored with data from an earlier session of the program.
 type FeeEstimatorState []byte
 
-// observedTxSet is a set of txs that can that is sorted
-// by hash. It exists for serialization purposes so that
-// a serialized state always comes out the same.
-type observedTxSet []*observedTransaction
-
-func (q observedTxSet) Len() int { return len(q) }
-
-func (q observedTxSet) Less(i, j int) bool {
-	return strings.Compare(q[i].hash.String(), q[j].hash.String()) < 0
-}
-
-func (q observedTxSet) Swap(i, j int) {
-	q[i], q[j] = q[j], q[i]
-}
-
 // Save records the current state of the FeeEstimator to a []byte that
-// can be restored later.
+// can be restored later.  Only the moving averages are saved since the
+// transactions of the pool are not kept across restarts.
 func (ef *FeeEstimator) Save() FeeEstimatorState {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
-	// TODO figure out what the capacity should be.
 	w := bytes.NewBuffer(make([]byte, 0))
 
 	binary.Write(w, binary.BigEndian, uint32(estimateFeeSaveVersion))
-
-	// Insert basic parameters.
-	binary.Write(w, binary.BigEndian, &ef.maxRollback)
-	binary.Write(w, binary.BigEndian, &ef.binSize)
-	binary.Write(w, binary.BigEndian, &ef.maxReplacements)
-	binary.Write(w, binary.BigEndian, &ef.minRegisteredBlocks)
-	binary.Write(w, binary.BigEndian, &ef.lastKnownHeight)
-	binary.Write(w, binary.BigEndian, &ef.numBlocksRegistered)
-
-	// Put all the observed transactions in a sorted list.
-	var txCount uint32
-	ots := make([]*observedTransaction, len(ef.observed))
-	for hash := range ef.observed {
-		ots[txCount] = ef.observed[hash]
-		txCount++
-	}
-
-	sort.Sort(observedTxSet(ots))
-
-	txCount = 0
-	observed := make(map[*observedTransaction]uint32)
-	binary.Write(w, binary.BigEndian, uint32(len(ef.observed)))
-	for _, ot := range ots {
-		ot.Serialize(w)
-		observed[ot] = txCount
-		txCount++
-	}
-
-	// Save all the right bins.
-	for _, list := range ef.bin {
-
-		binary.Write(w, binary.BigEndian, uint32(len(list)))
-
-		for _, o := range list {
-			binary.Write(w, binary.BigEndian, observed[o])
+	binary.Write(w, binary.BigEndian, uint32(len(feeBuckets)))
+	binary.Write(w, binary.BigEndian, ef.lastKnownHeight)
+	binary.Write(w, binary.BigEndian, ef.firstRecordedHeight)
+
+	for _, stats := range ef.allStats() {
+		binary.Write(w, binary.BigEndian, stats.txCtAvg)
+		binary.Write(w, binary.BigEndian, stats.feeRateAvg)
+		for i := range stats.confAvg {
+			binary.Write(w, binary.BigEndian, stats.confAvg[i])
+			binary.Write(w, binary.BigEndian, stats.failAvg[i])
 		}
 	}
 
-	// Dropped transactions.
-	binary.Write(w, binary.BigEndian, uint32(len(ef.dropped)))
-	for _, registered := range ef.dropped {
-		registered.serialize(w, observed)
-	}
-
-	// Commit the tx and return.
 	return FeeEstimatorState(w.Bytes())
 }
 
@@ -699,60 +770,53 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 		return nil, fmt.Errorf("Incorrect version: expected %d found %d", estimateFeeSaveVersion, version)
 	}
 
-	ef := &FeeEstimator{
-		observed: make(map[chainhash.Hash]*observedTransaction),
+	var numBuckets uint32
+	if err := binary.Read(r, binary.BigEndian, &numBuckets); err != nil {
+		return nil, err
+	}
+	if numBuckets != uint32(len(feeBuckets)) {
+		return nil, fmt.Errorf("unexpected number of fee buckets: "+
+			"expected %d found %d", len(feeBuckets), numBuckets)
 	}
 
-	// Read basic parameters.
-	binary.Read(r, binary.BigEndian, &ef.maxRollback)
-	binary.Read(r, binary.BigEndian, &ef.binSize)
-	binary.Read(r, binary.BigEndian, &ef.maxReplacements)
-	binary.Read(r, binary.BigEndian, &ef.minRegisteredBlocks)
-	binary.Read(r, binary.BigEndian, &ef.lastKnownHeight)
-	binary.Read(r, binary.BigEndian, &ef.numBlocksRegistered)
+	ef := NewFeeEstimator()
+	err = binary.Read(r, binary.BigEndian, &ef.lastKnownHeight)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(r, binary.BigEndian, &ef.firstRecordedHeight)
+	if err != nil {
+		return nil, err
+	}
 
-	// Read transactions.
-	var numObserved uint32
-	observed := make(map[uint32]*observedTransaction)
-	binary.Read(r, binary.BigEndian, &numObserved)
-	for i := uint32(0); i < numObserved; i++ {
-		ot, err := deserializeObservedTransaction(r)
-		if err != nil {
-			return nil, err
+	for _, stats := range ef.allStats() {
+		averages := [][]float64{stats.txCtAvg, stats.feeRateAvg}
+		for i := range stats.confAvg {
+			averages = append(averages, stats.confAvg[i],
+				stats.failAvg[i])
 		}
-		observed[i] = ot
-		ef.observed[ot.hash] = ot
-	}
-
-	// Read bins.
-	for i := 0; i < estimateFeeDepth; i++ {
-		var numTransactions uint32
-		binary.Read(r, binary.BigEndian, &numTransactions)
-		bin := make([]*observedTransaction, numTransactions)
-		for j := uint32(0); j < numTransactions; j++ {
-			var index uint32
-			binary.Read(r, binary.BigEndian, &index)
-
-			var exists bool
-			bin[j], exists = observed[index]
-			if !exists {
-				return nil, fmt.Errorf("Invalid transaction reference %d", index)
+		for _, avg := range averages {
+			if err := binary.Read(r, binary.BigEndian, avg); err != nil {
+				return nil, err
 			}
 		}
-		ef.bin[i] = bin
-	}
-
-	// Read dropped transactions.
-	var numDropped uint32
-	binary.Read(r, binary.BigEndian, &numDropped)
-	ef.dropped = make([]*registeredBlock, numDropped)
-	for i := uint32(0); i < numDropped; i++ {
-		var err error
-		ef.dropped[int(i)], err = deserializeRegisteredBlock(r, observed)
-		if err != nil {
-			return nil, err
-		}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d unexpected trailing bytes", r.Len())
 	}
 
 	return ef, nil
 }
+
+// IsStale returns whether the saved state of the fee estimator is too far
+// behind the passed best height to be used, or ahead of it.  The data of a
+// fee estimator whose last known height is ahead of the best chain, which
+// happens when the chain is rebuilt, would only be updated once the chain
+// catches up.
+func (ef *FeeEstimator) IsStale(bestHeight int32) bool {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	return ef.lastKnownHeight > bestHeight ||
+		bestHeight-ef.lastKnownHeight > maxEstimateFeeHistory
+}
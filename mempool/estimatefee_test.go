@@ -6,419 +6,376 @@ package mempool
 
 import (
 	"bytes"
-	"math/rand"
+	"errors"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/wire"
 )
 
-// newTestFeeEstimator creates a feeEstimator with some different parameters
-// for testing purposes.
-func newTestFeeEstimator(binSize, maxReplacements, maxRollback uint32) *FeeEstimator {
-	return &FeeEstimator{
-		maxRollback:         maxRollback,
-		lastKnownHeight:     0,
-		binSize:             int32(binSize),
-		minRegisteredBlocks: 0,
-		maxReplacements:     int32(maxReplacements),
-		observed:            make(map[chainhash.Hash]*observedTransaction),
-		dropped:             make([]*registeredBlock, 0, maxRollback),
-	}
-}
-
-// lastBlock is a linked list of the block hashes which have been
-// processed by the test FeeEstimator.
-type lastBlock struct {
-	hash *chainhash.Hash
-	prev *lastBlock
-}
-
-// estimateFeeTester interacts with the FeeEstimator to keep track
-// of its expected state.
+// estimateFeeTester interacts with the FeeEstimator to simulate transactions
+// entering the pool and being confirmed or removed from it.
 type estimateFeeTester struct {
-	ef      *FeeEstimator
 	t       *testing.T
+	ef      *FeeEstimator
 	version int32
 	height  int32
-	last    *lastBlock
 }
 
-func (eft *estimateFeeTester) testTx(fee btcutil.Amount) *TxDesc {
-	eft.version++
-	return &TxDesc{
-		TxDesc: mining.TxDesc{
-			Tx: btcutil.NewTx(&wire.MsgTx{
-				Version: eft.version,
-			}),
-			Height: eft.height,
-			Fee:    int64(fee),
-		},
-		StartingPriority: 0,
-	}
+// newEstimateFeeTester returns a tester whose fee estimator registered the
+// block at height 1.
+func newEstimateFeeTester(t *testing.T) *estimateFeeTester {
+	eft := &estimateFeeTester{t: t, ef: NewFeeEstimator()}
+	eft.newBlock(nil)
+	return eft
 }
 
-func expectedFeePerKilobyte(t *TxDesc) BtcPerKilobyte {
-	size := float64(t.TxDesc.Tx.MsgTx().SerializeSize())
-	fee := float64(t.TxDesc.Fee)
+// observe makes the fee estimator observe the passed number of new
+// transactions paying the passed fee rate in satoshi per kvB.
+func (eft *estimateFeeTester) observe(count int, feeRate int64) []*btcutil.Tx {
+	txns := make([]*btcutil.Tx, 0, count)
+	for i := 0; i < count; i++ {
+		eft.version++
+		tx := btcutil.NewTx(&wire.MsgTx{Version: eft.version})
+		eft.ef.ObserveTransaction(&TxDesc{
+			TxDesc: mining.TxDesc{
+				Tx:     tx,
+				Height: eft.height,
+				Fee:    feeRate * GetTxVirtualSize(tx) / 1000,
+			},
+		})
+		txns = append(txns, tx)
+	}
+	return txns
+}
 
-	return SatoshiPerByte(fee / size).ToBtcPerKb()
+// remove informs the fee estimator the passed transactions left the pool.
+func (eft *estimateFeeTester) remove(txns []*btcutil.Tx) {
+	for _, tx := range txns {
+		eft.ef.RemoveTransaction(tx.Hash())
+	}
 }
 
-func (eft *estimateFeeTester) newBlock(txs []*wire.MsgTx) {
-	eft.height++
+// newBlock registers a new block including the passed transactions, which are
+// removed from the pool first as the pool does.
+func (eft *estimateFeeTester) newBlock(txns []*btcutil.Tx) {
+	eft.remove(txns)
 
-	block := btcutil.NewBlock(&wire.MsgBlock{
-		Transactions: txs,
-	})
+	eft.height++
+	msgBlock := &wire.MsgBlock{}
+	for _, tx := range txns {
+		msgBlock.AddTransaction(tx.MsgTx())
+	}
+	block := btcutil.NewBlock(msgBlock)
 	block.SetHeight(eft.height)
-
-	eft.last = &lastBlock{block.Hash(), eft.last}
-
 	eft.ef.RegisterBlock(block)
 }
 
-func (eft *estimateFeeTester) rollback() {
-	if eft.last == nil {
-		return
-	}
+// estimate returns the estimate of the fee estimator for the passed target in
+// the passed mode and fails the test if there is none.
+func (eft *estimateFeeTester) estimate(confTarget int32,
+	conservative bool) btcutil.Amount {
 
-	err := eft.ef.Rollback(eft.last.hash)
+	eft.t.Helper()
 
+	feeRate, _, err := eft.ef.EstimateSmartFee(confTarget, conservative)
 	if err != nil {
-		eft.t.Errorf("Could not rollback: %v", err)
+		eft.t.Fatalf("EstimateSmartFee(%d, %v): unexpected error: %v",
+			confTarget, conservative, err)
 	}
-
-	eft.height--
-	eft.last = eft.last.prev
+	return feeRate
 }
 
-// TestEstimateFee tests basic functionality in the FeeEstimator.
-func TestEstimateFee(t *testing.T) {
-	ef := newTestFeeEstimator(5, 3, 1)
-	eft := estimateFeeTester{ef: ef, t: t}
-
-	// Try with no txs and get zero for all queries.
-	expected := BtcPerKilobyte(0.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
+// TestFeeBuckets ensures fee rates are grouped into the expected buckets.
+func TestFeeBuckets(t *testing.T) {
+	t.Parallel()
 
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when estimator is empty; got %f", expected, estimated)
+	for i := 1; i < len(feeBuckets); i++ {
+		if feeBuckets[i] <= feeBuckets[i-1] {
+			t.Fatalf("bucket %d does not exceed the previous one", i)
 		}
 	}
 
-	// Now insert a tx.
-	tx := eft.testTx(1000000)
-	ef.ObserveTransaction(tx)
-
-	// Expected should still be zero because this is still in the mempool.
-	expected = BtcPerKilobyte(0.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when estimator has one tx in mempool; got %f", expected, estimated)
-		}
+	lastBucket := len(feeBuckets) - 1
+	tests := []struct {
+		feeRate float64
+		bucket  int
+	}{
+		{0, 0},
+		{minBucketFeeRate, 0},
+		{minBucketFeeRate + 1, 1},
+		{minBucketFeeRate * feeBucketSpacing, 1},
+		{maxBucketFeeRate, lastBucket},
+		{maxBucketFeeRate * 10, lastBucket},
 	}
-
-	// Change minRegisteredBlocks to make sure that works. Error return
-	// value expected.
-	ef.minRegisteredBlocks = 1
-	expected = BtcPerKilobyte(-1.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f before any blocks have been registered; got %f", expected, estimated)
+	for _, test := range tests {
+		if bucket := feeBucketIndex(test.feeRate); bucket != test.bucket {
+			t.Errorf("feeBucketIndex(%v): got %d, want %d",
+				test.feeRate, bucket, test.bucket)
 		}
 	}
+}
 
-	// Record a block with the new tx.
-	eft.newBlock([]*wire.MsgTx{tx.Tx.MsgTx()})
-	expected = expectedFeePerKilobyte(tx)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when one tx is binned; got %f", expected, estimated)
-		}
+// TestEstimateSmartFee ensures the fee estimator estimates the fee rates of
+// transactions which confirmed, that transactions which left the pool without
+// confirming count as failures and that conservative estimates remember those
+// failures for longer than economical ones.
+func TestEstimateSmartFee(t *testing.T) {
+	t.Parallel()
+
+	const lowFeeRate, highFeeRate = 5000, 50000
+	eft := newEstimateFeeTester(t)
+
+	// There is no estimate before any transactions were confirmed.
+	_, _, err := eft.ef.EstimateSmartFee(2, false)
+	if !errors.Is(err, ErrInsufficientFeeData) {
+		t.Fatalf("EstimateSmartFee: expected insufficient data, got %v",
+			err)
+	}
+	if _, _, err := eft.ef.EstimateSmartFee(0, false); err == nil {
+		t.Fatal("EstimateSmartFee: expected error for zero target")
 	}
 
-	// Roll back the last block; this was an orphan block.
-	ef.minRegisteredBlocks = 0
-	eft.rollback()
-	expected = BtcPerKilobyte(0.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f after rolling back block; got %f", expected, estimated)
+	// Transactions paying the high fee rate confirm in the next block
+	// while those paying the low fee rate are removed from the pool after
+	// ten blocks without confirming.
+	var lowTxns [][]*btcutil.Tx
+	highTxns := eft.observe(10, highFeeRate)
+	for i := 0; i < 60; i++ {
+		lowTxns = append(lowTxns, eft.observe(10, lowFeeRate))
+		if len(lowTxns) > 10 {
+			eft.remove(lowTxns[0])
+			lowTxns = lowTxns[1:]
 		}
+		txns := highTxns
+		highTxns = eft.observe(10, highFeeRate)
+		eft.newBlock(txns)
 	}
 
-	// Record an empty block and then a block with the new tx.
-	// This test was made because of a bug that only appeared when there
-	// were no transactions in the first bin.
-	eft.newBlock([]*wire.MsgTx{})
-	eft.newBlock([]*wire.MsgTx{tx.Tx.MsgTx()})
-	expected = expectedFeePerKilobyte(tx)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when one tx is binned; got %f", expected, estimated)
+	// Only the high fee rate is estimated for any target, and the target is
+	// reduced to what has been observed.
+	for _, conservative := range []bool{false, true} {
+		if got := eft.estimate(2, conservative); got != highFeeRate {
+			t.Fatalf("unexpected estimate (conservative %v): got %v, "+
+				"want %v", conservative, got, highFeeRate)
 		}
 	}
-
-	// Create some more transactions.
-	txA := eft.testTx(500000)
-	txB := eft.testTx(2000000)
-	txC := eft.testTx(4000000)
-	ef.ObserveTransaction(txA)
-	ef.ObserveTransaction(txB)
-	ef.ObserveTransaction(txC)
-
-	// Record 7 empty blocks.
-	for i := 0; i < 7; i++ {
-		eft.newBlock([]*wire.MsgTx{})
+	_, blocks, err := eft.ef.EstimateSmartFee(MaxEstimateFeeTarget, false)
+	if err != nil {
+		t.Fatalf("EstimateSmartFee: unexpected error: %v", err)
 	}
-
-	// Mine the first tx.
-	eft.newBlock([]*wire.MsgTx{txA.Tx.MsgTx()})
-
-	// Now the estimated amount should depend on the value
-	// of the argument to estimate fee.
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-		if i > 2 {
-			expected = expectedFeePerKilobyte(txA)
-		} else {
-			expected = expectedFeePerKilobyte(tx)
-		}
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f on round %d; got %f", expected, i, estimated)
-		}
+	if blocks != 29 {
+		t.Fatalf("unexpected target of the estimate: got %d, want 29",
+			blocks)
 	}
 
-	// Record 5 more empty blocks.
-	for i := 0; i < 5; i++ {
-		eft.newBlock([]*wire.MsgTx{})
+	// From now on the transactions paying the low fee rate confirm as
+	// well.
+	for _, txns := range lowTxns {
+		eft.remove(txns)
 	}
-
-	// Mine the next tx.
-	eft.newBlock([]*wire.MsgTx{txB.Tx.MsgTx()})
-
-	// Now the estimated amount should depend on the value
-	// of the argument to estimate fee.
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-		if i <= 2 {
-			expected = expectedFeePerKilobyte(txB)
-		} else if i <= 8 {
-			expected = expectedFeePerKilobyte(tx)
-		} else {
-			expected = expectedFeePerKilobyte(txA)
-		}
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f on round %d; got %f", expected, i, estimated)
-		}
+	for i := 0; i < 100; i++ {
+		txns := append(highTxns, eft.observe(10, lowFeeRate)...)
+		highTxns = eft.observe(10, highFeeRate)
+		eft.newBlock(txns)
 	}
 
-	// Record 9 more empty blocks.
-	for i := 0; i < 10; i++ {
-		eft.newBlock([]*wire.MsgTx{})
+	// The economical estimate follows the lower fee rate while the
+	// conservative one still remembers the failures.
+	if got := eft.estimate(2, false); got != lowFeeRate {
+		t.Fatalf("unexpected economical estimate: got %v, want %v", got,
+			lowFeeRate)
 	}
-
-	// Mine txC.
-	eft.newBlock([]*wire.MsgTx{txC.Tx.MsgTx()})
-
-	// This should have no effect on the outcome because too
-	// many blocks have been mined for txC to be recorded.
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-		if i <= 2 {
-			expected = expectedFeePerKilobyte(txC)
-		} else if i <= 8 {
-			expected = expectedFeePerKilobyte(txB)
-		} else if i <= 8+6 {
-			expected = expectedFeePerKilobyte(tx)
-		} else {
-			expected = expectedFeePerKilobyte(txA)
-		}
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f on round %d; got %f", expected, i, estimated)
-		}
+	if got := eft.estimate(2, true); got != highFeeRate {
+		t.Fatalf("unexpected conservative estimate: got %v, want %v",
+			got, highFeeRate)
 	}
-}
 
-func (eft *estimateFeeTester) estimates() [estimateFeeDepth]BtcPerKilobyte {
+	// The legacy estimate is the economical one in BTC per kvB.
+	feeRate, err := eft.ef.EstimateFee(2)
+	if err != nil {
+		t.Fatalf("EstimateFee: unexpected error: %v", err)
+	}
+	if want := BtcPerKilobyte(btcutil.Amount(lowFeeRate).ToBTC()); feeRate != want {
+		t.Fatalf("unexpected legacy estimate: got %v, want %v", feeRate,
+			want)
+	}
 
-	// Generate estimates
-	var estimates [estimateFeeDepth]BtcPerKilobyte
-	for i := 0; i < estimateFeeDepth; i++ {
-		estimates[i], _ = eft.ef.EstimateFee(uint32(i + 1))
+	// Blocks which don't extend the highest block registered so far are
+	// not taken into account.
+	lastKnownHeight := eft.ef.LastKnownHeight()
+	eft.height -= 2
+	eft.newBlock(highTxns)
+	if eft.ef.LastKnownHeight() != lastKnownHeight {
+		t.Fatal("fee estimator registered block of a reorganization")
 	}
+	if len(eft.ef.observed) != 0 || len(eft.ef.removed) != 0 {
+		t.Fatal("fee estimator still tracks transactions of the block")
+	}
+}
 
-	// Check that all estimated fee results go in descending order.
-	for i := 1; i < estimateFeeDepth; i++ {
-		if estimates[i] > estimates[i-1] {
-			eft.t.Error("Estimates not in descending order; got ",
-				estimates[i], " for estimate ", i, " and ", estimates[i-1], " for ", (i - 1))
-			panic("invalid state.")
+// numUnconfirmed returns the number of unconfirmed transactions tracked by the
+// passed statistics.
+func numUnconfirmed(stats *confirmStats) int {
+	var n int
+	for _, unconfTxs := range stats.unconfTxs {
+		for _, count := range unconfTxs {
+			n += count
 		}
 	}
-
-	return estimates
+	for _, count := range stats.oldUnconfTxs {
+		n += count
+	}
+	return n
 }
 
-func (eft *estimateFeeTester) round(txHistory [][]*TxDesc,
-	estimateHistory [][estimateFeeDepth]BtcPerKilobyte,
-	txPerRound, txPerBlock uint32) ([][]*TxDesc, [][estimateFeeDepth]BtcPerKilobyte) {
-
-	// generate new txs.
-	var newTxs []*TxDesc
-	for i := uint32(0); i < txPerRound; i++ {
-		newTx := eft.testTx(btcutil.Amount(rand.Intn(1000000)))
-		eft.ef.ObserveTransaction(newTx)
-		newTxs = append(newTxs, newTx)
+// numFailures returns the sum of the moving averages of the transactions which
+// failed to confirm within any number of periods.
+func numFailures(stats *confirmStats) float64 {
+	var n float64
+	for _, failAvg := range stats.failAvg {
+		for _, count := range failAvg {
+			n += count
+		}
 	}
+	return n
+}
 
-	// Generate mempool.
-	mempool := make(map[*observedTransaction]*TxDesc)
-	for _, h := range txHistory {
-		for _, t := range h {
-			if o, exists := eft.ef.observed[*t.Tx.Hash()]; exists && o.mined == mining.UnminedHeight {
-				mempool[o] = t
+// TestEstimateFeeReorg ensures the observed transactions included in blocks of
+// a reorganization stop being tracked without being recorded as confirmed or
+// failed, while the transactions which left the pool without being included
+// still count as failures once a new block is registered.
+func TestEstimateFeeReorg(t *testing.T) {
+	t.Parallel()
+
+	const feeRate = 10000
+	eft := newEstimateFeeTester(t)
+	txnsA := eft.observe(5, feeRate)
+	txnsB := eft.observe(3, feeRate)
+	txnsC := eft.observe(2, feeRate)
+	eft.newBlock(nil)
+	eft.newBlock(nil)
+
+	checkUnconfirmed := func(want int) {
+		t.Helper()
+
+		if len(eft.ef.observed) != want {
+			t.Fatalf("unexpected number of observed transactions: "+
+				"got %d, want %d", len(eft.ef.observed), want)
+		}
+		for i, stats := range eft.ef.allStats() {
+			if n := numUnconfirmed(stats); n != want {
+				t.Fatalf("unexpected number of unconfirmed "+
+					"transactions of stats %d: got %d, want %d",
+					i, n, want)
 			}
 		}
 	}
-
-	// generate new block, with no duplicates.
-	i := uint32(0)
-	newBlockList := make([]*wire.MsgTx, 0, txPerBlock)
-	for _, t := range mempool {
-		newBlockList = append(newBlockList, t.TxDesc.Tx.MsgTx())
-		i++
-
-		if i == txPerBlock {
-			break
+	checkUnconfirmed(10)
+
+	// Blocks replacing the last two blocks are connected.  The first one
+	// includes some of the transactions while others left the pool.
+	var txCtAvg, failures []float64
+	for _, stats := range eft.ef.allStats() {
+		var n float64
+		for _, count := range stats.txCtAvg {
+			n += count
 		}
+		txCtAvg = append(txCtAvg, n)
+		failures = append(failures, numFailures(stats))
 	}
-
-	// Register a new block.
-	eft.newBlock(newBlockList)
-
-	// return results.
-	estimates := eft.estimates()
-
-	// Return results
-	return append(txHistory, newTxs), append(estimateHistory, estimates)
-}
-
-// TestEstimateFeeRollback tests the rollback function, which undoes the
-// effect of a adding a new block.
-func TestEstimateFeeRollback(t *testing.T) {
-	txPerRound := uint32(7)
-	txPerBlock := uint32(5)
-	binSize := uint32(6)
-	maxReplacements := uint32(4)
-	stepsBack := 2
-	rounds := 30
-
-	eft := estimateFeeTester{ef: newTestFeeEstimator(binSize, maxReplacements, uint32(stepsBack)), t: t}
-	var txHistory [][]*TxDesc
-	estimateHistory := [][estimateFeeDepth]BtcPerKilobyte{eft.estimates()}
-
-	for round := 0; round < rounds; round++ {
-		// Go forward a few rounds.
-		for step := 0; step <= stepsBack; step++ {
-			txHistory, estimateHistory =
-				eft.round(txHistory, estimateHistory, txPerRound, txPerBlock)
+	lastKnownHeight := eft.ef.LastKnownHeight()
+	eft.remove(txnsC)
+	eft.height -= 2
+	eft.newBlock(txnsA)
+	checkUnconfirmed(5)
+	eft.newBlock(txnsB)
+	checkUnconfirmed(2)
+	if eft.ef.LastKnownHeight() != lastKnownHeight {
+		t.Fatal("fee estimator registered block of a reorganization")
+	}
+	for i, stats := range eft.ef.allStats() {
+		var n float64
+		for _, count := range stats.txCtAvg {
+			n += count
 		}
-
-		// Now go back.
-		for step := 0; step < stepsBack; step++ {
-			eft.rollback()
-
-			// After rolling back, we should have the same estimated
-			// fees as before.
-			expected := estimateHistory[len(estimateHistory)-step-2]
-			estimates := eft.estimates()
-
-			// Ensure that these are both the same.
-			for i := 0; i < estimateFeeDepth; i++ {
-				if expected[i] != estimates[i] {
-					t.Errorf("Rollback value mismatch. Expected %f, got %f. ",
-						expected[i], estimates[i])
-					return
-				}
-			}
+		if n != txCtAvg[i] {
+			t.Fatalf("confirmations of stats %d recorded for block "+
+				"of a reorganization", i)
 		}
+		if numFailures(stats) != failures[i] {
+			t.Fatalf("failures of stats %d recorded for block of "+
+				"a reorganization", i)
+		}
+	}
+	if len(eft.ef.removed) != len(txnsC) {
+		t.Fatalf("unexpected number of removed transactions: got %d, "+
+			"want %d", len(eft.ef.removed), len(txnsC))
+	}
 
-		// Erase history.
-		txHistory = txHistory[0 : len(txHistory)-stepsBack]
-		estimateHistory = estimateHistory[0 : len(estimateHistory)-stepsBack]
+	// The transactions which left the pool count as failures of the next
+	// block extending the chain.
+	eft.newBlock(nil)
+	checkUnconfirmed(0)
+	if len(eft.ef.removed) != 0 {
+		t.Fatal("fee estimator still tracks removed transactions")
+	}
+	if n := numFailures(eft.ef.shortStats); n <= failures[0] {
+		t.Fatalf("failures not recorded: got %v, want more than %v", n,
+			failures[0])
 	}
 }
 
-func (eft *estimateFeeTester) checkSaveAndRestore(
-	previousEstimates [estimateFeeDepth]BtcPerKilobyte) {
-
-	// Get the save state.
-	save := eft.ef.Save()
+// TestEstimateFeeDatabase ensures the state of the fee estimator is saved and
+// restored.
+func TestEstimateFeeDatabase(t *testing.T) {
+	t.Parallel()
+
+	eft := newEstimateFeeTester(t)
+	txns := eft.observe(10, 20000)
+	for i := 0; i < 20; i++ {
+		next := eft.observe(10, 20000)
+		eft.newBlock(txns)
+		txns = next
+	}
 
-	// Save and restore database.
-	var err error
-	eft.ef, err = RestoreFeeEstimator(save)
+	saved := eft.ef.Save()
+	restored, err := RestoreFeeEstimator(saved)
 	if err != nil {
-		eft.t.Fatalf("Could not restore database: %s", err)
+		t.Fatalf("RestoreFeeEstimator: unexpected error: %v", err)
 	}
-
-	// Save again and check that it matches the previous one.
-	redo := eft.ef.Save()
-	if !bytes.Equal(save, redo) {
-		eft.t.Fatalf("Restored states do not match: %v %v", save, redo)
+	if !bytes.Equal(restored.Save(), saved) {
+		t.Fatal("restored fee estimator differs from the saved one")
 	}
-
-	// Check that the results match.
-	newEstimates := eft.estimates()
-
-	for i, prev := range previousEstimates {
-		if prev != newEstimates[i] {
-			eft.t.Error("Mismatch in estimate ", i, " after restore; got ", newEstimates[i], " but expected ", prev)
+	for _, conservative := range []bool{false, true} {
+		want := eft.estimate(5, conservative)
+		got, _, err := restored.EstimateSmartFee(5, conservative)
+		if err != nil || got != want {
+			t.Fatalf("unexpected estimate of restored fee estimator: "+
+				"got %v (%v), want %v", got, err, want)
 		}
 	}
-}
 
-// TestSave tests saving and restoring to a []byte.
-func TestDatabase(t *testing.T) {
-
-	txPerRound := uint32(7)
-	txPerBlock := uint32(5)
-	binSize := uint32(6)
-	maxReplacements := uint32(4)
-	rounds := 8
-
-	eft := estimateFeeTester{ef: newTestFeeEstimator(binSize, maxReplacements, uint32(rounds)+1), t: t}
-	var txHistory [][]*TxDesc
-	estimateHistory := [][estimateFeeDepth]BtcPerKilobyte{eft.estimates()}
-
-	for round := 0; round < rounds; round++ {
-		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-1])
+	// The restored fee estimator is only used when it is not too far
+	// behind the chain and not ahead of it.
+	height := restored.LastKnownHeight()
+	if restored.IsStale(height) || restored.IsStale(height+10) {
+		t.Fatal("restored fee estimator unexpectedly stale")
+	}
+	if !restored.IsStale(height-1) ||
+		!restored.IsStale(height+maxEstimateFeeHistory+1) {
 
-		// Go forward one step.
-		txHistory, estimateHistory =
-			eft.round(txHistory, estimateHistory, txPerRound, txPerBlock)
+		t.Fatal("restored fee estimator unexpectedly not stale")
 	}
 
-	// Reverse the process and try again.
-	for round := 1; round <= rounds; round++ {
-		eft.rollback()
-		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-round-1])
+	// States of other versions and truncated states are rejected.
+	other := append([]byte(nil), saved...)
+	other[3]++
+	if _, err := RestoreFeeEstimator(other); err == nil {
+		t.Fatal("RestoreFeeEstimator: expected error for other version")
+	}
+	if _, err := RestoreFeeEstimator(saved[:len(saved)-1]); err == nil {
+		t.Fatal("RestoreFeeEstimator: expected error for truncated state")
 	}
 }
@@ -28,6 +28,38 @@ type TxMempool interface {
 	// not include the orphan pool.
 	Count() int
 
+	// MemoryUsage returns the number of bytes of memory used by the
+	// transactions of the main pool. It does not include the orphan pool.
+	MemoryUsage() int64
+
+	// OrphanInfo returns the usage and limits of the orphan pool along
+	// with the orphans relayed by each peer as a btcjson result.  The
+	// orphans themselves are included when verbose is set.
+	OrphanInfo(verbose bool) *btcjson.GetOrphanInfoResult
+
+	// MinFeeRate returns the minimum fee rate per kvB transactions must
+	// pay to enter the pool in addition to the minimum relay fee. It is
+	// raised when transactions are evicted because the pool is full.
+	MinFeeRate() btcutil.Amount
+
+	// MempoolEntry returns the requested transaction of the pool as a
+	// fully populated btcjson result including the number, total size,
+	// and total fees of its unconfirmed ancestors and descendants.
+	MempoolEntry(txHash *chainhash.Hash) (*btcjson.GetMempoolEntryResult,
+		error)
+
+	// MempoolAncestors returns all of the unconfirmed ancestors of the
+	// requested transaction of the pool as fully populated btcjson results
+	// keyed by transaction hash.
+	MempoolAncestors(txHash *chainhash.Hash) (
+		map[string]*btcjson.GetMempoolEntryResult, error)
+
+	// MempoolDescendants returns all of the unconfirmed descendants of the
+	// requested transaction of the pool as fully populated btcjson results
+	// keyed by transaction hash.
+	MempoolDescendants(txHash *chainhash.Hash) (
+		map[string]*btcjson.GetMempoolEntryResult, error)
+
 	// FetchTransaction returns the requested transaction from the
 	// transaction pool. This only fetches from the main transaction pool
 	// and does not include orphans.
@@ -50,6 +82,13 @@ type TxMempool interface {
 	ProcessTransaction(tx *btcutil.Tx, allowOrphan,
 		rateLimit bool, tag Tag) ([]*TxDesc, error)
 
+	// ProcessPackage evaluates the passed transactions, which must be a
+	// child and its parents sorted so parents come first, as a package and
+	// adds the acceptable ones to the memory pool.  Transactions which
+	// don't pay enough fees on their own are accepted when the fee rate of
+	// the package meets the minimum relay fee rate.
+	ProcessPackage(txns []*wire.MsgTx) (*PackageAcceptResult, error)
+
 	// RemoveTransaction removes the passed transaction from the mempool.
 	// When the removeRedeemers flag is set, any transactions that redeem
 	// outputs from the removed transaction will also be removed
@@ -64,8 +103,21 @@ type TxMempool interface {
 	// actions based on it.
 	CheckMempoolAcceptance(tx *btcutil.Tx) (*MempoolAcceptResult, error)
 
+	// CheckPackageAcceptance behaves similarly to bitcoind's
+	// `testmempoolaccept` RPC method for multiple transactions.  It
+	// decides whether the passed transactions, sorted so parents precede
+	// their children, would be accepted to the mempool one after the
+	// other without adding any of them.
+	CheckPackageAcceptance(txns []*btcutil.Tx) (*PackageAcceptResult, error)
+
 	// CheckSpend checks whether the passed outpoint is already spent by
 	// a transaction in the mempool. If that's the case the spending
 	// transaction will be returned, if not nil will be returned.
 	CheckSpend(op wire.OutPoint) *btcutil.Tx
+
+	// PrioritiseTransaction adds the passed fee delta in satoshi to the
+	// virtual fee delta of the transaction with the passed hash, which is
+	// used by the fee policies of the pool and when selecting transactions
+	// for new blocks, and returns the resulting fee delta.
+	PrioritiseTransaction(hash *chainhash.Hash, feeDelta int64) int64
 }
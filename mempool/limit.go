@@ -0,0 +1,306 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"container/heap"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultMaxPoolSize is the default maximum memory usage in bytes of
+	// the transactions of the pool.
+	DefaultMaxPoolSize = 300 * 1000 * 1000
+
+	// rollingMinFeeHalfLife is the time it takes for the minimum fee rate
+	// of the pool, which is raised when transactions are evicted, to decay
+	// to half its value.  The fee rate decays faster when the pool is
+	// using less than half or a quarter of its maximum size.
+	rollingMinFeeHalfLife = 12 * time.Hour
+)
+
+var (
+	// txDescUsage is the memory used by the descriptor of a transaction
+	// and the transaction itself, including its cached hashes and the
+	// entry of the pool which references it.
+	txDescUsage = int64(unsafe.Sizeof(TxDesc{}) + unsafe.Sizeof(btcutil.Tx{}) +
+		unsafe.Sizeof(wire.MsgTx{}) + 2*chainhash.HashSize +
+		chainhash.HashSize + unsafe.Sizeof(uintptr(0)))
+
+	// txInUsage is the memory used by a transaction input, excluding its
+	// scripts, including the entry of the outpoints of the pool which
+	// references its previous output.
+	txInUsage = int64(unsafe.Sizeof(wire.TxIn{}) + unsafe.Sizeof(uintptr(0)) +
+		unsafe.Sizeof(wire.OutPoint{}) + unsafe.Sizeof(uintptr(0)))
+
+	// txOutUsage is the memory used by a transaction output, excluding
+	// its script.
+	txOutUsage = int64(unsafe.Sizeof(wire.TxOut{}) + unsafe.Sizeof(uintptr(0)))
+
+	// witnessItemUsage is the memory used by an item of the witness of a
+	// transaction input, excluding its data.
+	witnessItemUsage = int64(unsafe.Sizeof([]byte(nil)))
+)
+
+// txMemoryUsage returns the number of bytes of memory the pool uses to keep
+// the passed transaction.  It accounts for the structures of the transaction
+// and all of its scripts along with the entries of the pool which reference
+// it, so the usage of the pool reflects the memory it actually holds rather
+// than the serialized sizes of the transactions.
+func txMemoryUsage(tx *btcutil.Tx) int64 {
+	msgTx := tx.MsgTx()
+	usage := txDescUsage
+	for _, txIn := range msgTx.TxIn {
+		usage += txInUsage + int64(len(txIn.SignatureScript))
+		for _, item := range txIn.Witness {
+			usage += witnessItemUsage + int64(len(item))
+		}
+	}
+	for _, txOut := range msgTx.TxOut {
+		usage += txOutUsage + int64(len(txOut.PkScript))
+	}
+	return usage
+}
+
+// descendantEntry tracks the total fee, including fee deltas, and the total
+// virtual size of a transaction of the pool and all of its descendants along
+// with its position in the eviction queue of the pool.
+type descendantEntry struct {
+	hash  chainhash.Hash
+	fee   int64
+	size  int64
+	count int
+	index int
+}
+
+// feeRate returns the fee rate in satoshi per kvB of the transaction of the
+// entry including its descendants.
+func (e *descendantEntry) feeRate() int64 {
+	return e.fee * 1000 / e.size
+}
+
+// descendantFeeQueue implements a priority queue of descendantEntry elements
+// ordered by their fee rates including descendants, lowest first, so the
+// transactions that are least likely to be mined are evicted first.
+type descendantFeeQueue []*descendantEntry
+
+// Len returns the number of items in the priority queue.  It is part of the
+// heap.Interface implementation.
+func (pq descendantFeeQueue) Len() int {
+	return len(pq)
+}
+
+// Less returns whether the item in the priority queue with index i should sort
+// before the item with index j.  Entries paying a lower fee per virtual byte
+// sort first, followed by larger entries when the fee rates are equal.  It is
+// part of the heap.Interface implementation.
+func (pq descendantFeeQueue) Less(i, j int) bool {
+	// Compare the fee rates without dividing.  Floats are used since the
+	// products can overflow 64-bit integers.
+	a := float64(pq[i].fee) * float64(pq[j].size)
+	b := float64(pq[j].fee) * float64(pq[i].size)
+	if a == b {
+		return pq[i].size > pq[j].size
+	}
+	return a < b
+}
+
+// Swap swaps the items at the passed indices in the priority queue.  It is
+// part of the heap.Interface implementation.
+func (pq descendantFeeQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+// Push pushes the passed item onto the priority queue.  It is part of the
+// heap.Interface implementation.
+func (pq *descendantFeeQueue) Push(x interface{}) {
+	entry := x.(*descendantEntry)
+	entry.index = len(*pq)
+	*pq = append(*pq, entry)
+}
+
+// Pop removes the lowest fee rate item (according to Less) from the priority
+// queue and returns it.  It is part of the heap.Interface implementation.
+func (pq *descendantFeeQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*pq = old[0 : n-1]
+	return entry
+}
+
+// updateDescendantEntries recalculates the totals including descendants of the
+// passed transaction, when it is in the pool, and of all of its ancestors in the
+// pool, which are the only transactions whose descendants change when the
+// passed transaction is added, removed, or prioritised.  This keeps the
+// eviction queue up to date without rescanning the entire pool, and the work
+// is bounded by the ancestor and descendant limits of the pool.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updateDescendantEntries(tx *btcutil.Tx) {
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	if txD, ok := mp.pool[*tx.Hash()]; ok {
+		mp.updateDescendantEntry(txD, cache)
+	}
+	for hash := range mp.txAncestors(tx, nil) {
+		mp.updateDescendantEntry(mp.pool[hash], cache)
+	}
+}
+
+// updateDescendantEntry recalculates the totals including descendants of the
+// passed transaction of the pool and updates its position in the eviction
+// queue accordingly.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updateDescendantEntry(txD *TxDesc,
+	cache map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx) {
+
+	descendants := mp.txDescendants(txD.Tx, cache)
+	fee := txD.Fee + txD.FeeDelta
+	size := GetTxVirtualSize(txD.Tx)
+	for hash, descendant := range descendants {
+		fee += mp.pool[hash].Fee + mp.pool[hash].FeeDelta
+		size += GetTxVirtualSize(descendant)
+	}
+
+	entry, ok := mp.descendantEntries[*txD.Tx.Hash()]
+	if !ok {
+		entry = &descendantEntry{hash: *txD.Tx.Hash()}
+		mp.descendantEntries[entry.hash] = entry
+		entry.fee, entry.size, entry.count = fee, size, len(descendants)
+		heap.Push(&mp.evictionQueue, entry)
+		return
+	}
+	entry.fee, entry.size, entry.count = fee, size, len(descendants)
+	heap.Fix(&mp.evictionQueue, entry.index)
+}
+
+// removeDescendantEntry removes the entry of the passed transaction, which was
+// removed from the pool, from the eviction queue and recalculates the totals of
+// its ancestors.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeDescendantEntry(tx *btcutil.Tx) {
+	if entry, ok := mp.descendantEntries[*tx.Hash()]; ok {
+		heap.Remove(&mp.evictionQueue, entry.index)
+		delete(mp.descendantEntries, entry.hash)
+	}
+	mp.updateDescendantEntries(tx)
+}
+
+// limitPoolSize evicts the transactions with the lowest descendant fee rates
+// along with their descendants until the memory usage of the pool no longer
+// exceeds its maximum size.  The minimum fee rate of the pool is raised above
+// the fee rate of each evicted package by the minimum relay fee rate so that
+// transactions which would be evicted right away are no longer accepted.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitPoolSize() {
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	for maxSize > 0 && mp.usage > maxSize && len(mp.evictionQueue) > 0 {
+		entry := mp.evictionQueue[0]
+		txD := mp.pool[entry.hash]
+		feeRate := entry.feeRate()
+		mp.raiseMinFeeRate(feeRate + int64(mp.cfg.Policy.MinRelayTxFee))
+
+		log.Debugf("Evicting transaction %v and %d descendants "+
+			"(fee_rate=%v sat/kb) as the pool exceeds its maximum "+
+			"size of %d bytes", txD.Tx.Hash(), entry.count,
+			feeRate, maxSize)
+
+		mp.removeTransaction(txD.Tx, true)
+	}
+}
+
+// minFeeRate returns the minimum fee rate in satoshi per kvB transactions
+// must pay to enter the pool in addition to the minimum relay fee.  It is
+// raised when transactions are evicted because the pool is full and then
+// decays over time, faster when the pool is using less than half or a quarter
+// of its maximum size.  It drops to zero once it decays below half the
+// minimum relay fee rate.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) minFeeRate() int64 {
+	if mp.rollingMinFee == 0 {
+		return 0
+	}
+
+	halfLife := rollingMinFeeHalfLife
+	switch maxSize := mp.cfg.Policy.MaxPoolSize; {
+	case mp.usage < maxSize/4:
+		halfLife /= 4
+	case mp.usage < maxSize/2:
+		halfLife /= 2
+	}
+
+	elapsed := time.Since(mp.rollingMinFeeTime)
+	feeRate := float64(mp.rollingMinFee) /
+		math.Pow(2, elapsed.Seconds()/halfLife.Seconds())
+	if feeRate < float64(mp.cfg.Policy.MinRelayTxFee)/2 {
+		return 0
+	}
+	return int64(feeRate)
+}
+
+// raiseMinFeeRate raises the minimum fee rate of the pool to the passed fee
+// rate in satoshi per kvB unless it is already higher.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) raiseMinFeeRate(feeRate int64) {
+	if feeRate <= mp.minFeeRate() {
+		return
+	}
+
+	log.Debugf("Raising the minimum fee rate of the pool to %v sat/kb",
+		feeRate)
+	mp.rollingMinFee = feeRate
+	mp.rollingMinFeeTime = time.Now()
+}
+
+// MemoryUsage returns the number of bytes of memory used by the transactions
+// of the main pool.  It does not include the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MemoryUsage() int64 {
+	mp.mtx.RLock()
+	usage := mp.usage
+	mp.mtx.RUnlock()
+
+	return usage
+}
+
+// Bytes returns the total serialized size of the transactions of the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Bytes() int64 {
+	mp.mtx.RLock()
+	bytes := mp.bytes
+	mp.mtx.RUnlock()
+
+	return bytes
+}
+
+// MinFeeRate returns the minimum fee rate per kvB transactions must pay to
+// enter the pool in addition to the minimum relay fee.  It is zero unless
+// transactions were evicted recently because the pool was full.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFeeRate() btcutil.Amount {
+	mp.mtx.RLock()
+	feeRate := mp.minFeeRate()
+	mp.mtx.RUnlock()
+
+	return btcutil.Amount(feeRate)
+}
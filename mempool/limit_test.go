@@ -0,0 +1,227 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestLimitPoolSize ensures the transactions with the lowest fee rates
+// including their descendants are evicted when the pool exceeds its maximum
+// size, that doing so raises the minimum fee rate of the pool, and that the
+// minimum fee rate decays over time.
+func TestLimitPoolSize(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	pool := ctx.harness.txPool
+	coinbase := ctx.addCoinbaseTx(6)
+	output := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+
+	// A low fee parent whose child pays for it, so the parent is not the
+	// first to be evicted, and two transactions with moderate fees.
+	parent := ctx.addSignedTx(output(0), 1, 1000, false, false)
+	ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(parent, 0)}, 1, 20000,
+		false, false,
+	)
+	low := ctx.addSignedTx(output(1), 1, 2000, false, false)
+	moderate := ctx.addSignedTx(output(2), 1, 3000, false, false)
+
+	var usage, bytes int64
+	for _, txD := range pool.TxDescs() {
+		usage += txMemoryUsage(txD.Tx)
+		bytes += int64(txD.Tx.MsgTx().SerializeSize())
+	}
+	if pool.MemoryUsage() != usage {
+		t.Fatalf("unexpected memory usage: got %d, want %d",
+			pool.MemoryUsage(), usage)
+	}
+	if pool.Bytes() != bytes {
+		t.Fatalf("unexpected size: got %d, want %d", pool.Bytes(),
+			bytes)
+	}
+	if pool.MinFeeRate() != 0 {
+		t.Fatalf("unexpected minimum fee rate %v of a pool which is "+
+			"not full", pool.MinFeeRate())
+	}
+
+	// Make the pool full so adding another transaction evicts the one with
+	// the lowest fee rate.  The sizes of the transactions differ by the
+	// few bytes the lengths of their signatures vary by.
+	pool.cfg.Policy.MaxPoolSize = usage + 8
+	lowFeeRate := 2000 * 1000 / GetTxVirtualSize(low)
+	ctx.addSignedTx(output(3), 1, 5000, false, false)
+	testPoolMembership(ctx, low, false, false)
+	testPoolMembership(ctx, parent, false, true)
+	checkDescendantEntries(t, pool)
+	if pool.MemoryUsage() > pool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("memory usage %d exceeds maximum size %d",
+			pool.MemoryUsage(), pool.cfg.Policy.MaxPoolSize)
+	}
+	wantMinFeeRate := btcutil.Amount(lowFeeRate) +
+		pool.cfg.Policy.MinRelayTxFee
+	minFeeRate := pool.MinFeeRate()
+	if minFeeRate < wantMinFeeRate-1 || minFeeRate > wantMinFeeRate {
+		t.Fatalf("unexpected minimum fee rate: got %v, want %v",
+			minFeeRate, wantMinFeeRate)
+	}
+
+	// Transactions which don't pay the minimum fee rate of the pool are
+	// rejected even though they pay the minimum relay fee.
+	tx := ctx.createSignedTx(output(4), 1, 1000, false)
+	_, err := pool.ProcessTransaction(tx, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("expected transaction below the minimum fee rate of "+
+			"the pool to be rejected, got %v", err)
+	}
+
+	// A transaction which pays enough evicts the next one.
+	ctx.addSignedTx(output(4), 1, 4000, false, false)
+	testPoolMembership(ctx, moderate, false, false)
+
+	// The minimum fee rate halves every half-life while the pool is full
+	// and drops to zero once it is below half the minimum relay fee rate.
+	minFeeRate = pool.MinFeeRate()
+	pool.rollingMinFeeTime = pool.rollingMinFeeTime.Add(
+		-rollingMinFeeHalfLife,
+	)
+	if got := pool.MinFeeRate(); got < minFeeRate/2-1 || got > minFeeRate/2 {
+		t.Fatalf("unexpected minimum fee rate after one half-life: "+
+			"got %v, want %v", got, minFeeRate/2)
+	}
+	pool.rollingMinFeeTime = time.Now().Add(-10 * rollingMinFeeHalfLife)
+	if pool.MinFeeRate() != 0 {
+		t.Fatalf("unexpected minimum fee rate %v after it decayed",
+			pool.MinFeeRate())
+	}
+
+	// The memory usage and size drop to zero once all transactions are
+	// removed.
+	for _, txD := range pool.TxDescs() {
+		pool.RemoveTransaction(txD.Tx, true)
+	}
+	if pool.MemoryUsage() != 0 {
+		t.Fatalf("unexpected memory usage %d of an empty pool",
+			pool.MemoryUsage())
+	}
+	if pool.Bytes() != 0 {
+		t.Fatalf("unexpected size %d of an empty pool", pool.Bytes())
+	}
+}
+
+// checkDescendantEntries ensures the totals including descendants tracked for
+// the eviction queue of the pool match the totals calculated from scratch.
+func checkDescendantEntries(t *testing.T, pool *TxPool) {
+	t.Helper()
+
+	if len(pool.descendantEntries) != len(pool.pool) ||
+		len(pool.evictionQueue) != len(pool.pool) {
+
+		t.Fatalf("got %d descendant entries and %d queued entries for "+
+			"%d transactions", len(pool.descendantEntries),
+			len(pool.evictionQueue), len(pool.pool))
+	}
+	for hash, txD := range pool.pool {
+		entry, ok := pool.descendantEntries[hash]
+		if !ok {
+			t.Fatalf("no descendant entry for transaction %v", hash)
+		}
+		if pool.evictionQueue[entry.index] != entry {
+			t.Fatalf("descendant entry of transaction %v is not at "+
+				"its index %d in the eviction queue", hash,
+				entry.index)
+		}
+
+		descendants := pool.txDescendants(txD.Tx, nil)
+		fee := txD.Fee + txD.FeeDelta
+		size := GetTxVirtualSize(txD.Tx)
+		for descHash, descendant := range descendants {
+			fee += pool.pool[descHash].Fee + pool.pool[descHash].FeeDelta
+			size += GetTxVirtualSize(descendant)
+		}
+		if entry.fee != fee || entry.size != size ||
+			entry.count != len(descendants) {
+
+			t.Fatalf("unexpected descendant entry of transaction %v: "+
+				"got fee %d, size %d, count %d, want fee %d, "+
+				"size %d, count %d", hash, entry.fee, entry.size,
+				entry.count, fee, size, len(descendants))
+		}
+	}
+}
+
+// TestDescendantEntries ensures the totals including descendants used to order
+// the transactions for eviction are kept up to date as transactions are added,
+// prioritised, and removed, and that the minimum fee rate of the pool applies
+// to the fees including the fee deltas.
+func TestDescendantEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	pool := ctx.harness.txPool
+	coinbase := ctx.addCoinbaseTx(2)
+
+	// A parent with two children where the second child also spends the
+	// first one, so the parent reaches the second child by two paths.
+	parent := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 2, 1000,
+		false, false,
+	)
+	child := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(parent, 0)}, 1, 2000,
+		false, false,
+	)
+	ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 1),
+		txOutToSpendableOut(child, 0),
+	}, 1, 3000, false, false)
+	checkDescendantEntries(t, pool)
+
+	// The fee delta of a transaction counts towards its ancestors.
+	pool.PrioritiseTransaction(child.Hash(), 5000)
+	checkDescendantEntries(t, pool)
+	if lowest := pool.evictionQueue[0].hash; lowest == *parent.Hash() {
+		t.Fatalf("parent paid for by its children is the first to " +
+			"be evicted")
+	}
+
+	// Confirming the parent leaves its children in the pool.
+	pool.RemoveConfirmedTransaction(parent)
+	checkDescendantEntries(t, pool)
+
+	// Removing the first child removes the second one as well.
+	pool.RemoveTransaction(child, true)
+	checkDescendantEntries(t, pool)
+	if pool.Count() != 0 {
+		t.Fatalf("unexpected %d transactions left in the pool",
+			pool.Count())
+	}
+
+	// A transaction below the minimum fee rate of the pool is accepted
+	// once its fee including the fee delta pays it.
+	pool.rollingMinFee = 100000
+	pool.rollingMinFeeTime = time.Now()
+	tx := ctx.createSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 1)}, 1, 1000,
+		false,
+	)
+	_, err := pool.ProcessTransaction(tx, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("expected transaction below the minimum fee rate of "+
+			"the pool to be rejected, got %v", err)
+	}
+	pool.PrioritiseTransaction(tx.Hash(), 100000)
+	if _, err := pool.ProcessTransaction(tx, false, false, 0); err != nil {
+		t.Fatalf("prioritised transaction was rejected: %v", err)
+	}
+	checkDescendantEntries(t, pool)
+}
@@ -5,9 +5,11 @@
 package mempool
 
 import (
+	"bytes"
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +21,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/davecgh/go-spew/spew"
@@ -31,10 +34,18 @@ const (
 	// inclusion when generating block templates.
 	DefaultBlockPrioritySize = 50000
 
-	// orphanTTL is the maximum amount of time an orphan is allowed to
-	// stay in the orphan pool before it expires and is evicted during the
-	// next scan.
-	orphanTTL = time.Minute * 15
+	// DefaultOrphanTTL is the default maximum amount of time an orphan is
+	// allowed to stay in the orphan pool before it expires and is evicted
+	// during the next scan.
+	DefaultOrphanTTL = time.Minute * 15
+
+	// DefaultMaxOrphanWeight is the default maximum total weight of the
+	// transactions of the orphan pool.
+	DefaultMaxOrphanWeight = 4000000
+
+	// DefaultMaxOrphanPeerWeight is the default maximum total weight of the
+	// orphans of the orphan pool that were relayed by the same peer.
+	DefaultMaxOrphanPeerWeight = 400000
 
 	// orphanExpireScanInterval is the minimum amount of time in between
 	// scans of the orphan pool to evict expired transactions.
@@ -108,15 +119,35 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// TxAdded defines an optional function to invoke with each transaction
+	// added to the main pool along with the mempool sequence number of the
+	// addition.  It is invoked with the mempool lock held, so it must not
+	// block or call back into the mempool.
+	TxAdded func(tx *btcutil.Tx, sequence uint64)
+
+	// TxRemoved defines an optional function to invoke with each
+	// transaction removed from the main pool for any reason other than
+	// being included in a block, such as a conflict, a replacement or an
+	// eviction, along with the mempool sequence number of the removal.  It
+	// is invoked with the mempool lock held, so it must not block or call
+	// back into the mempool.
+	TxRemoved func(tx *btcutil.Tx, sequence uint64)
+
+	// TxReplaced defines an optional function to invoke when transactions
+	// of the main pool are replaced (RBF) by a transaction or a package of
+	// transactions, which are passed along with the replaced ones.  It is
+	// invoked with the mempool lock held, so it must not block or call
+	// back into the mempool.
+	TxReplaced func(replaced, replacements []*TxDesc)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
 // control the mempool.
 type Policy struct {
-	// MaxTxVersion is the transaction version that the mempool should
-	// accept.  All transactions above this version are rejected as
-	// non-standard.
-	MaxTxVersion int32
+	// Rules are the standardness rules transactions must follow to be
+	// accepted unless AcceptNonStd is set.
+	Rules policy.Rules
 
 	// DisableRelayPriority defines whether to relay free or low-fee
 	// transactions that do not have enough priority to be relayed.
@@ -140,6 +171,21 @@ type Policy struct {
 	// of big orphans.
 	MaxOrphanTxSize int
 
+	// MaxOrphanWeight is the maximum total weight of the transactions of
+	// the orphan pool.  There is no limit when it is 0.
+	MaxOrphanWeight int64
+
+	// MaxOrphanPeerWeight is the maximum total weight of the orphans that
+	// were relayed by the same peer, as identified by their tag.  Once a
+	// peer reaches it, its own oldest orphans are evicted to make room for
+	// new ones so that a single peer can't crowd out the orphans of the
+	// other peers.  There is no limit when it is 0.
+	MaxOrphanPeerWeight int64
+
+	// OrphanTTL is the maximum amount of time an orphan is allowed to stay
+	// in the orphan pool.  DefaultOrphanTTL is used when it is 0.
+	OrphanTTL time.Duration
+
 	// MaxSigOpCostPerTx is the cumulative maximum cost of all the signature
 	// operations in a single transaction we will relay or mine.  It is a
 	// fraction of the max signature operations for a block.
@@ -153,6 +199,37 @@ type Policy struct {
 	// transactions using the Replace-By-Fee (RBF) signaling policy into
 	// the mempool.
 	RejectReplacement bool
+
+	// FullRBF, if true, accepts replacement transactions into the mempool
+	// whether or not the transactions they replace signal replaceability.
+	// The remaining rules of the Replace-By-Fee (RBF) policy still apply.
+	FullRBF bool
+
+	// MaxPoolSize is the maximum number of bytes of memory the
+	// transactions of the mempool may use.  The transactions with the
+	// lowest fee rates including their descendants are evicted when the
+	// mempool exceeds it.  There is no limit when it is 0.
+	MaxPoolSize int64
+
+	// MaxAncestorCount is the maximum number of unconfirmed ancestors,
+	// including itself, a transaction of the mempool may have.  There is
+	// no limit when it is 0.
+	MaxAncestorCount int
+
+	// MaxAncestorSize is the maximum total virtual size in bytes of a
+	// transaction of the mempool and its unconfirmed ancestors.  There is
+	// no limit when it is 0.
+	MaxAncestorSize int64
+
+	// MaxDescendantCount is the maximum number of unconfirmed descendants,
+	// including itself, a transaction of the mempool may have.  There is
+	// no limit when it is 0.
+	MaxDescendantCount int
+
+	// MaxDescendantSize is the maximum total virtual size in bytes of a
+	// transaction of the mempool and its unconfirmed descendants.  There
+	// is no limit when it is 0.
+	MaxDescendantSize int64
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -171,25 +248,69 @@ type TxDesc struct {
 type orphanTx struct {
 	tx         *btcutil.Tx
 	tag        Tag
+	weight     int64
 	expiration time.Time
 }
 
+// orphanUsage tracks the number and total weight of orphan transactions.
+type orphanUsage struct {
+	count  int
+	weight int64
+}
+
 // TxPool is used as a source of transactions that need to be mined into blocks
 // and relayed to other peers.  It is safe for concurrent access from multiple
 // peers.
 type TxPool struct {
 	// The following variables must only be used atomically.
-	lastUpdated int64 // last time pool was updated
+	lastUpdated int64 // last time pool was updated in unix nanoseconds
 
 	mtx           sync.RWMutex
 	cfg           Config
 	pool          map[chainhash.Hash]*TxDesc
 	orphans       map[chainhash.Hash]*orphanTx
 	orphansByPrev map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx
+	orphanUsage   orphanUsage
+	orphansByTag  map[Tag]*orphanUsage
 	outpoints     map[wire.OutPoint]*btcutil.Tx
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 
+	// usage is the number of bytes of memory used by the transactions of
+	// the pool.
+	usage int64
+
+	// bytes is the total serialized size of the transactions of the pool.
+	bytes int64
+
+	// sequence is the mempool sequence number, which is incremented each
+	// time a transaction is added to or removed from the main pool.
+	sequence uint64
+
+	// rollingMinFee is the minimum fee rate in satoshi per kvB the pool
+	// was raised to when transactions were last evicted at
+	// rollingMinFeeTime.  It decays over time.
+	rollingMinFee     int64
+	rollingMinFeeTime time.Time
+
+	// descendantEntries houses the totals including descendants of the
+	// transactions of the pool keyed by transaction hash, and
+	// evictionQueue orders them by fee rate so the transactions to evict
+	// when the pool is full are found without rescanning it.
+	descendantEntries map[chainhash.Hash]*descendantEntry
+	evictionQueue     descendantFeeQueue
+
+	// feeDeltas houses the fee deltas set with PrioritiseTransaction keyed
+	// by transaction hash.  They are kept for transactions which are not
+	// in the pool yet, so they apply once the transactions arrive, until
+	// the transactions are confirmed.
+	feeDeltas map[chainhash.Hash]int64
+
+	// reconsiderable houses transactions which were rejected for
+	// insufficient fees so they can be accepted along with a child which
+	// pays for them once it arrives.
+	reconsiderable map[chainhash.Hash]*btcutil.Tx
+
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
@@ -242,6 +363,14 @@ func (mp *TxPool) removeOrphan(tx *btcutil.Tx, removeRedeemers bool) {
 
 	// Remove the transaction from the orphan pool.
 	delete(mp.orphans, *txHash)
+	mp.orphanUsage.count--
+	mp.orphanUsage.weight -= otx.weight
+	tagUsage := mp.orphansByTag[otx.tag]
+	tagUsage.count--
+	tagUsage.weight -= otx.weight
+	if tagUsage.count == 0 {
+		delete(mp.orphansByTag, otx.tag)
+	}
 }
 
 // RemoveOrphan removes the passed orphan transaction from the orphan pool and
@@ -271,57 +400,118 @@ func (mp *TxPool) RemoveOrphansByTag(tag Tag) uint64 {
 	return numEvicted
 }
 
-// limitNumOrphans limits the number of orphan transactions by evicting a random
-// orphan if adding a new one would cause it to overflow the max allowed.
+// orphanTTL returns the maximum amount of time an orphan is allowed to stay in
+// the orphan pool.
+func (mp *TxPool) orphanTTL() time.Duration {
+	if mp.cfg.Policy.OrphanTTL > 0 {
+		return mp.cfg.Policy.OrphanTTL
+	}
+	return DefaultOrphanTTL
+}
+
+// orphanScanInterval returns the interval at which the orphan pool is scanned
+// for expired orphans, which is shortened to the expiry when it is shorter.
+func (mp *TxPool) orphanScanInterval() time.Duration {
+	if ttl := mp.orphanTTL(); ttl < orphanExpireScanInterval {
+		return ttl
+	}
+	return orphanExpireScanInterval
+}
+
+// expireOrphans removes the orphans which expired when it's time to scan the
+// orphan pool.  This is done for efficiency so the scan only happens
+// periodically instead of on every orphan added to the pool.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) limitNumOrphans() error {
-	// Scan through the orphan pool and remove any expired orphans when it's
-	// time.  This is done for efficiency so the scan only happens
-	// periodically instead of on every orphan added to the pool.
-	if now := time.Now(); now.After(mp.nextExpireScan) {
-		origNumOrphans := len(mp.orphans)
-		for _, otx := range mp.orphans {
-			if now.After(otx.expiration) {
-				// Remove redeemers too because the missing
-				// parents are very unlikely to ever materialize
-				// since the orphan has already been around more
-				// than long enough for them to be delivered.
-				mp.removeOrphan(otx.tx, true)
-			}
-		}
-
-		// Set next expiration scan to occur after the scan interval.
-		mp.nextExpireScan = now.Add(orphanExpireScanInterval)
+func (mp *TxPool) expireOrphans() {
+	now := time.Now()
+	if !now.After(mp.nextExpireScan) {
+		return
+	}
 
-		numOrphans := len(mp.orphans)
-		if numExpired := origNumOrphans - numOrphans; numExpired > 0 {
-			log.Debugf("Expired %d %s (remaining: %d)", numExpired,
-				pickNoun(numExpired, "orphan", "orphans"),
-				numOrphans)
+	origNumOrphans := len(mp.orphans)
+	for _, otx := range mp.orphans {
+		if now.After(otx.expiration) {
+			// Remove redeemers too because the missing parents are
+			// very unlikely to ever materialize since the orphan
+			// has already been around more than long enough for
+			// them to be delivered.
+			mp.removeOrphan(otx.tx, true)
 		}
 	}
 
-	// Nothing to do if adding another orphan will not cause the pool to
-	// exceed the limit.
-	if len(mp.orphans)+1 <= mp.cfg.Policy.MaxOrphanTxs {
-		return nil
+	// Set next expiration scan to occur after the scan interval.
+	mp.nextExpireScan = now.Add(mp.orphanScanInterval())
+
+	numOrphans := len(mp.orphans)
+	if numExpired := origNumOrphans - numOrphans; numExpired > 0 {
+		log.Debugf("Expired %d %s (remaining: %d)", numExpired,
+			pickNoun(numExpired, "orphan", "orphans"), numOrphans)
 	}
+}
 
-	// Remove a random entry from the map.  For most compilers, Go's
-	// range statement iterates starting at a random item although
-	// that is not 100% guaranteed by the spec.  The iteration order
-	// is not important here because an adversary would have to be
-	// able to pull off preimage attacks on the hashing function in
-	// order to target eviction of specific entries anyways.
+// evictOldestOrphan removes the orphan which expires first of those tagged with
+// the passed identifier.  Its redeemers are kept in the orphan pool since it is
+// quite possible it might be needed again shortly.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) evictOldestOrphan(tag Tag) {
+	var oldest *orphanTx
 	for _, otx := range mp.orphans {
-		// Don't remove redeemers in the case of a random eviction since
-		// it is quite possible it might be needed again shortly.
-		mp.removeOrphan(otx.tx, false)
-		break
+		if otx.tag != tag {
+			continue
+		}
+		if oldest == nil || otx.expiration.Before(oldest.expiration) {
+			oldest = otx
+		}
+	}
+	if oldest != nil {
+		mp.removeOrphan(oldest.tx, false)
 	}
+}
 
-	return nil
+// limitOrphans makes room in the orphan pool for an orphan of the passed weight
+// tagged with the passed identifier.  Expired orphans are removed first.  When
+// the orphans of the tag would then exceed the weight allowed per tag, its own
+// oldest orphans are evicted.  While the orphan pool would still exceed the
+// maximum number or total weight of orphans, the oldest orphans of the tag
+// whose orphans weigh the most are evicted, so the peers relaying the most
+// orphans are the ones whose orphans are evicted.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitOrphans(tag Tag, weight int64) {
+	mp.expireOrphans()
+
+	policy := &mp.cfg.Policy
+	if policy.MaxOrphanPeerWeight > 0 {
+		for {
+			tagUsage, ok := mp.orphansByTag[tag]
+			if !ok || tagUsage.weight+weight <= policy.MaxOrphanPeerWeight {
+				break
+			}
+			mp.evictOldestOrphan(tag)
+		}
+	}
+
+	for len(mp.orphans) > 0 {
+		if len(mp.orphans)+1 <= policy.MaxOrphanTxs &&
+			(policy.MaxOrphanWeight <= 0 ||
+				mp.orphanUsage.weight+weight <= policy.MaxOrphanWeight) {
+
+			break
+		}
+
+		var (
+			heaviestTag Tag
+			heaviest    *orphanUsage
+		)
+		for tag, tagUsage := range mp.orphansByTag {
+			if heaviest == nil || tagUsage.weight > heaviest.weight {
+				heaviestTag, heaviest = tag, tagUsage
+			}
+		}
+		mp.evictOldestOrphan(heaviestTag)
+	}
 }
 
 // addOrphan adds an orphan transaction to the orphan pool.
@@ -333,16 +523,28 @@ func (mp *TxPool) addOrphan(tx *btcutil.Tx, tag Tag) {
 		return
 	}
 
-	// Limit the number orphan transactions to prevent memory exhaustion.
-	// This will periodically remove any expired orphans and evict a random
-	// orphan if space is still needed.
-	mp.limitNumOrphans()
+	// Limit the number and weight of the orphan transactions to prevent
+	// memory exhaustion.  This will periodically remove any expired orphans
+	// and evict orphans of the peers relaying the most if space is still
+	// needed.
+	weight := blockchain.GetTransactionWeight(tx)
+	mp.limitOrphans(tag, weight)
 
 	mp.orphans[*tx.Hash()] = &orphanTx{
 		tx:         tx,
 		tag:        tag,
-		expiration: time.Now().Add(orphanTTL),
-	}
+		weight:     weight,
+		expiration: time.Now().Add(mp.orphanTTL()),
+	}
+	mp.orphanUsage.count++
+	mp.orphanUsage.weight += weight
+	tagUsage, ok := mp.orphansByTag[tag]
+	if !ok {
+		tagUsage = &orphanUsage{}
+		mp.orphansByTag[tag] = tagUsage
+	}
+	tagUsage.count++
+	tagUsage.weight += weight
 	for _, txIn := range tx.MsgTx().TxIn {
 		if _, exists := mp.orphansByPrev[txIn.PreviousOutPoint]; !exists {
 			mp.orphansByPrev[txIn.PreviousOutPoint] =
@@ -351,8 +553,8 @@ func (mp *TxPool) addOrphan(tx *btcutil.Tx, tag Tag) {
 		mp.orphansByPrev[txIn.PreviousOutPoint][*tx.Hash()] = tx
 	}
 
-	log.Debugf("Stored orphan transaction %v (total: %d)", tx.Hash(),
-		len(mp.orphans))
+	log.Debugf("Stored orphan transaction %v (total: %d, weight: %d)",
+		tx.Hash(), len(mp.orphans), mp.orphanUsage.weight)
 }
 
 // maybeAddOrphan potentially adds an orphan to the orphan pool.
@@ -377,6 +579,19 @@ func (mp *TxPool) maybeAddOrphan(tx *btcutil.Tx, tag Tag) error {
 		return txRuleError(wire.RejectNonstandard, str)
 	}
 
+	// Ignore orphan transactions which could never fit into the orphan
+	// pool or the share of a single peer.
+	policy := &mp.cfg.Policy
+	weight := blockchain.GetTransactionWeight(tx)
+	if (policy.MaxOrphanWeight > 0 && weight > policy.MaxOrphanWeight) ||
+		(policy.MaxOrphanPeerWeight > 0 &&
+			weight > policy.MaxOrphanPeerWeight) {
+
+		str := fmt.Sprintf("orphan transaction weight of %d is larger "+
+			"than the orphan pool allows", weight)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
 	// Add the orphan if the none of the above disqualified it.
 	mp.addOrphan(tx, tag)
 
@@ -411,6 +626,20 @@ func (mp *TxPool) isTransactionInPool(hash *chainhash.Hash) bool {
 	return false
 }
 
+// hasPoolParent returns whether or not the passed transaction spends an output
+// of a transaction in the main pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) hasPoolParent(tx *btcutil.Tx) bool {
+	for _, txIn := range tx.MsgTx().TxIn {
+		if mp.isTransactionInPool(&txIn.PreviousOutPoint.Hash) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsTransactionInPool returns whether or not the passed transaction already
 // exists in the main pool.
 //
@@ -475,6 +704,19 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 //
 // This function MUST be called with the mempool lock held (for writes).
 func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+	mp.removeTransactionWithReason(tx, removeRedeemers, false)
+}
+
+// removeTransactionWithReason removes the passed transaction from the main
+// pool, along with the transactions which redeem its outputs recursively when
+// removeRedeemers is set.  The confirmed flag indicates the transaction is
+// removed because it was included in a block, which is not reported to the
+// TxRemoved callback.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeTransactionWithReason(tx *btcutil.Tx,
+	removeRedeemers, confirmed bool) {
+
 	txHash := tx.Hash()
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
@@ -499,7 +741,20 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
-		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		mp.removeDescendantEntry(txDesc.Tx)
+		mp.usage -= txMemoryUsage(txDesc.Tx)
+		mp.bytes -= int64(txDesc.Tx.MsgTx().SerializeSize())
+
+		// Inform the fee estimator the transaction left the pool.
+		if mp.cfg.FeeEstimator != nil {
+			mp.cfg.FeeEstimator.RemoveTransaction(txHash)
+		}
+		atomic.StoreInt64(&mp.lastUpdated, time.Now().UnixNano())
+
+		mp.sequence++
+		if mp.cfg.TxRemoved != nil && !confirmed {
+			mp.cfg.TxRemoved(txDesc.Tx, mp.sequence)
+		}
 	}
 }
 
@@ -516,6 +771,22 @@ func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 	mp.mtx.Unlock()
 }
 
+// RemoveConfirmedTransaction removes the passed transaction, which was included
+// in a block connected to the main chain, from the mempool.  The transactions
+// that redeem its outputs are not removed since they are still valid, and the
+// removal is not reported to the TxRemoved callback since the transaction did
+// not leave the pool without being confirmed.  Any fee delta set for the
+// transaction is cleared as well.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) RemoveConfirmedTransaction(tx *btcutil.Tx) {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	mp.removeTransactionWithReason(tx, false, true)
+	delete(mp.feeDeltas, *tx.Hash())
+	mp.mtx.Unlock()
+}
+
 // RemoveDoubleSpends removes all transactions which spend outputs spent by the
 // passed transaction from the memory pool.  Removing those transactions then
 // leads to removing all transactions which rely on them, recursively.  This is
@@ -551,6 +822,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
 	}
@@ -559,7 +831,10 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
-	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+	mp.updateDescendantEntries(tx)
+	mp.usage += txMemoryUsage(tx)
+	mp.bytes += int64(tx.MsgTx().SerializeSize())
+	atomic.StoreInt64(&mp.lastUpdated, time.Now().UnixNano())
 
 	// Add unconfirmed address index entries associated with the transaction
 	// if enabled.
@@ -567,21 +842,28 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
 
-	// Record this tx for fee estimation if enabled.
-	if mp.cfg.FeeEstimator != nil {
+	// Record this tx for fee estimation if enabled.  Transactions with
+	// unconfirmed parents are not recorded since their confirmation also
+	// depends on the fee rates of the parents.
+	if mp.cfg.FeeEstimator != nil && !mp.hasPoolParent(tx) {
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	mp.sequence++
+	if mp.cfg.TxAdded != nil {
+		mp.cfg.TxAdded(tx, mp.sequence)
+	}
+
 	return txD
 }
 
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
 // If it does, we'll check whether each of those transactions are signaling for
-// replacement. If just one of them isn't, an error is returned. Otherwise, a
-// boolean is returned signaling that the transaction is a replacement. Note it
-// does not check for double spends against transactions already in the main
-// chain.
+// replacement unless full RBF is enabled. If just one of them isn't, an error
+// is returned. Otherwise, a boolean is returned signaling that the transaction
+// is a replacement. Note it does not check for double spends against
+// transactions already in the main chain.
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) checkPoolDoubleSpend(tx *btcutil.Tx) (bool, error) {
@@ -593,16 +875,31 @@ func (mp *TxPool) checkPoolDoubleSpend(tx *btcutil.Tx) (bool, error) {
 		}
 
 		// Reject the transaction if we don't accept replacement
-		// transactions or if it doesn't signal replacement.
-		if mp.cfg.Policy.RejectReplacement ||
-			!mp.signalsReplacement(conflict, nil) {
-			str := fmt.Sprintf("output already spent in mempool: "+
-				"output=%v, tx=%v", txIn.PreviousOutPoint,
-				conflict.Hash())
-			return false, txRuleError(wire.RejectDuplicate, str)
+		// transactions or if it doesn't signal replacement while full
+		// RBF is disabled.
+		var rule ReplacementRule
+		switch {
+		case mp.cfg.Policy.RejectReplacement:
+			rule = RBFReplacementsDisabled
+
+		case !mp.cfg.Policy.FullRBF &&
+			!mp.signalsReplacement(conflict, nil):
+
+			rule = RBFNotSignaled
+
+		default:
+			isReplacement = true
+			continue
 		}
 
-		isReplacement = true
+		str := fmt.Sprintf("output already spent in mempool: "+
+			"output=%v, tx=%v", txIn.PreviousOutPoint,
+			conflict.Hash())
+		return false, replacementRuleError(wire.RejectDuplicate, str,
+			&ReplacementRejection{
+				Rule:      rule,
+				Conflicts: []chainhash.Hash{*conflict.Hash()},
+			})
 	}
 
 	return isReplacement, nil
@@ -833,35 +1130,77 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
+// sortedTxHashes returns the hashes of the passed transactions in ascending
+// order so they are reported in a stable order.
+func sortedTxHashes(txns map[chainhash.Hash]*btcutil.Tx) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0, len(txns))
+	for hash := range txns {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	return hashes
+}
+
+// logRejectedReplacement logs the details of the violated replacement rule
+// when the passed error rejected the transaction, or package, with the passed
+// hash as a replacement of transactions in the pool.
+func logRejectedReplacement(hash *chainhash.Hash, err error) {
+	if rejection := ExtractReplacementRejection(err); rejection != nil {
+		log.Debugf("Rejected replacement %v: %v", hash, rejection)
+	}
+}
+
 // validateReplacement determines whether a transaction is deemed as a valid
 // replacement of all of its conflicts according to the RBF policy. If it is
 // valid, no error is returned. Otherwise, an error is returned indicating what
-// went wrong.
+// went wrong along with the details of the violated rule.
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	txFee int64) (map[chainhash.Hash]*btcutil.Tx, error) {
 
+	var (
+		conflicts = mp.txConflicts(tx)
+		txSize    = GetTxVirtualSize(tx)
+		txFeeRate = txFee * 1000 / txSize
+	)
+	rejection := func(rule ReplacementRule,
+		conflicts map[chainhash.Hash]*btcutil.Tx) *ReplacementRejection {
+
+		return &ReplacementRejection{
+			Rule:      rule,
+			Conflicts: sortedTxHashes(conflicts),
+			Fee:       txFee,
+			FeeRate:   txFeeRate,
+		}
+	}
+
 	// First, we'll make sure the set of conflicting transactions doesn't
 	// exceed the maximum allowed.
-	conflicts := mp.txConflicts(tx)
 	if len(conflicts) > MaxReplacementEvictions {
 		str := fmt.Sprintf("%v: replacement transaction evicts more "+
 			"transactions than permitted: max is %v, evicts %v",
 			tx.Hash(), MaxReplacementEvictions, len(conflicts))
-		return nil, txRuleError(wire.RejectNonstandard, str)
+		return nil, replacementRuleError(wire.RejectNonstandard, str,
+			rejection(RBFTooManyReplacements, conflicts))
 	}
 
 	// The set of conflicts (transactions we'll replace) and ancestors
 	// should not overlap, otherwise the replacement would be spending an
 	// output that no longer exists.
-	for ancestorHash := range mp.txAncestors(tx, nil) {
-		if _, ok := conflicts[ancestorHash]; !ok {
-			continue
+	spentConflicts := make(map[chainhash.Hash]*btcutil.Tx)
+	for ancestorHash, ancestor := range mp.txAncestors(tx, nil) {
+		if _, ok := conflicts[ancestorHash]; ok {
+			spentConflicts[ancestorHash] = ancestor
 		}
+	}
+	if len(spentConflicts) > 0 {
+		r := rejection(RBFSpendsConflict, spentConflicts)
 		str := fmt.Sprintf("%v: replacement transaction spends parent "+
-			"transaction %v", tx.Hash(), ancestorHash)
-		return nil, txRuleError(wire.RejectInvalid, str)
+			"transaction %v", tx.Hash(), r.Conflicts[0])
+		return nil, replacementRuleError(wire.RejectInvalid, str, r)
 	}
 
 	// The replacement should have a higher fee rate than each of the
@@ -873,21 +1212,21 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	// block. Requiring that the fee rate always be increased is also an
 	// easy-to-reason about way to prevent DoS attacks via replacements.
 	var (
-		txSize           = GetTxVirtualSize(tx)
-		txFeeRate        = txFee * 1000 / txSize
 		conflictsFee     int64
+		maxFeeRate       int64
+		feeRateConflicts = make(map[chainhash.Hash]*btcutil.Tx)
 		conflictsParents = make(map[chainhash.Hash]struct{})
 	)
 	for hash, conflict := range conflicts {
-		if txFeeRate <= mp.pool[hash].FeePerKB {
-			str := fmt.Sprintf("%v: replacement transaction has an "+
-				"insufficient fee rate: needs more than %v, "+
-				"has %v", tx.Hash(), mp.pool[hash].FeePerKB,
-				txFeeRate)
-			return nil, txRuleError(wire.RejectInsufficientFee, str)
+		conflictFeeRate := modifiedFeeRate(mp.pool[hash])
+		if txFeeRate <= conflictFeeRate {
+			feeRateConflicts[hash] = conflict
+			if conflictFeeRate > maxFeeRate {
+				maxFeeRate = conflictFeeRate
+			}
 		}
 
-		conflictsFee += mp.pool[hash].Fee
+		conflictsFee += mp.pool[hash].Fee + mp.pool[hash].FeeDelta
 
 		// We'll track each conflict's parents to ensure the replacement
 		// isn't spending any new unconfirmed inputs.
@@ -895,16 +1234,34 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 			conflictsParents[txIn.PreviousOutPoint.Hash] = struct{}{}
 		}
 	}
+	if len(feeRateConflicts) > 0 {
+		r := rejection(RBFInsufficientFeeRate, feeRateConflicts)
+		r.FeeRateDelta = maxFeeRate + 1 - txFeeRate
+		str := fmt.Sprintf("%v: replacement transaction has an "+
+			"insufficient fee rate: needs more than %v, has %v",
+			tx.Hash(), maxFeeRate, txFeeRate)
+		return nil, replacementRuleError(wire.RejectInsufficientFee,
+			str, r)
+	}
 
 	// It should also have an absolute fee greater than all of the
 	// transactions it intends to replace and pay for its own bandwidth,
 	// which is determined by our minimum relay fee.
-	minFee := calcMinRequiredTxRelayFee(txSize, mp.cfg.Policy.MinRelayTxFee)
+	minFee := policy.CalcMinRequiredTxRelayFee(
+		txSize, mp.cfg.Policy.MinRelayTxFee,
+	)
 	if txFee < conflictsFee+minFee {
+		rule := RBFInsufficientRelayFee
+		if txFee < conflictsFee {
+			rule = RBFInsufficientAbsoluteFee
+		}
+		r := rejection(rule, conflicts)
+		r.FeeDelta = conflictsFee + minFee - txFee
 		str := fmt.Sprintf("%v: replacement transaction has an "+
 			"insufficient absolute fee: needs %v, has %v",
 			tx.Hash(), conflictsFee+minFee, txFee)
-		return nil, txRuleError(wire.RejectInsufficientFee, str)
+		return nil, replacementRuleError(wire.RejectInsufficientFee,
+			str, r)
 	}
 
 	// Finally, it should not spend any new unconfirmed outputs, other than
@@ -921,7 +1278,8 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 		str := fmt.Sprintf("replacement transaction spends new "+
 			"unconfirmed input %v not found in conflicting "+
 			"transactions", txIn.PreviousOutPoint)
-		return nil, txRuleError(wire.RejectInvalid, str)
+		return nil, replacementRuleError(wire.RejectInvalid, str,
+			rejection(RBFNewUnconfirmedInput, conflicts))
 	}
 
 	return conflicts, nil
@@ -939,7 +1297,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit,
 
 	// Check for mempool acceptance.
 	r, err := mp.checkMempoolAcceptance(
-		tx, isNew, rateLimit, rejectDupOrphans,
+		tx, isNew, rateLimit, rejectDupOrphans, nil,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -953,7 +1311,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit,
 	// Now that we've deemed the transaction as valid, we can add it to the
 	// mempool. If it ended up replacing any transactions, we'll remove them
 	// first.
+	var replaced []*TxDesc
 	for _, conflict := range r.Conflicts {
+		replaced = append(replaced, mp.pool[*conflict.Hash()])
 		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
 			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
 			mp.pool[*conflict.Hash()].FeePerKB, tx.Hash(),
@@ -966,6 +1326,19 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit,
 	}
 	txD := mp.addTransaction(r.utxoView, tx, r.bestHeight, int64(r.TxFee))
 
+	// Make room for the transaction when the pool is full.  The
+	// transaction is rejected if it is among the evicted ones.
+	mp.limitPoolSize()
+	if !mp.isTransactionInPool(txHash) {
+		str := fmt.Sprintf("transaction %v was evicted right away "+
+			"because the mempool is full", txHash)
+		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	if len(replaced) > 0 && mp.cfg.TxReplaced != nil {
+		mp.cfg.TxReplaced(replaced, []*TxDesc{txD})
+	}
+
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
 
@@ -1103,6 +1476,12 @@ func (mp *TxPool) ProcessOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 // with any additional orphan transactions that were added as a result of
 // the passed one being accepted.
 //
+// When orphans are allowed, a transaction which is rejected for insufficient
+// fees on its own is also evaluated as a package along with any orphan which
+// spends it, and an orphan is evaluated as a package along with its parent if
+// the parent was rejected for insufficient fees earlier.  See ProcessPackage.
+// In that case the list starts with the parent of the package.
+//
 // This function is safe for concurrent access.
 func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool, tag Tag) ([]*TxDesc, error) {
 	log.Tracef("Processing transaction %v", tx.Hash())
@@ -1115,6 +1494,16 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
 		true)
 	if err != nil {
+		// A transaction which only lacks fees may be paid for by an
+		// orphan child that is already known, otherwise it is kept
+		// around in case such a child arrives later.
+		if allowOrphan && isReconsiderable(err) {
+			acceptedTxs := mp.acceptWithOrphanChild(tx)
+			if acceptedTxs != nil {
+				return acceptedTxs, nil
+			}
+			mp.addReconsiderable(tx)
+		}
 		return nil, err
 	}
 
@@ -1152,6 +1541,14 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 		return nil, txRuleError(wire.RejectDuplicate, str)
 	}
 
+	// The orphan may pay for a parent which was rejected for insufficient
+	// fees on its own, in which case both are accepted as a package.
+	if acceptedTxs := mp.acceptWithReconsiderableParent(tx,
+		missingParents); acceptedTxs != nil {
+
+		return acceptedTxs, nil
+	}
+
 	// Potentially add the orphan transaction to the orphan pool.
 	err = mp.maybeAddOrphan(tx, tag)
 	return nil, err
@@ -1271,12 +1668,74 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// OrphanInfo returns the usage and limits of the orphan pool along with the
+// number and weight of the orphans relayed by each peer as a btcjson result.
+// The orphans themselves are included when verbose is set.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) OrphanInfo(verbose bool) *btcjson.GetOrphanInfoResult {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	policy := &mp.cfg.Policy
+	result := &btcjson.GetOrphanInfoResult{
+		Size:          mp.orphanUsage.count,
+		Weight:        mp.orphanUsage.weight,
+		MaxSize:       policy.MaxOrphanTxs,
+		MaxWeight:     policy.MaxOrphanWeight,
+		MaxPeerWeight: policy.MaxOrphanPeerWeight,
+		Expiry:        int64(mp.orphanTTL() / time.Second),
+		Peers:         make([]btcjson.OrphanPeerResult, 0, len(mp.orphansByTag)),
+	}
+	for tag, tagUsage := range mp.orphansByTag {
+		result.Peers = append(result.Peers, btcjson.OrphanPeerResult{
+			ID:     uint64(tag),
+			Size:   tagUsage.count,
+			Weight: tagUsage.weight,
+		})
+	}
+	sort.Slice(result.Peers, func(i, j int) bool {
+		return result.Peers[i].ID < result.Peers[j].ID
+	})
+
+	if !verbose {
+		return result
+	}
+	result.Orphans = make([]btcjson.OrphanTxResult, 0, len(mp.orphans))
+	for hash, otx := range mp.orphans {
+		result.Orphans = append(result.Orphans, btcjson.OrphanTxResult{
+			TxID:       hash.String(),
+			Weight:     otx.weight,
+			From:       uint64(otx.tag),
+			Expiration: otx.expiration.Unix(),
+		})
+	}
+	sort.Slice(result.Orphans, func(i, j int) bool {
+		return result.Orphans[i].Expiration < result.Orphans[j].Expiration
+	})
+
+	return result
+}
+
+// SetMinRelayTxFee changes the minimum fee in satoshi per 1000 bytes
+// transactions must pay to be accepted into the pool and relayed.  It only
+// applies to transactions processed afterwards, so the transactions which are
+// already in the pool are kept.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SetMinRelayTxFee(fee btcutil.Amount) {
+	mp.mtx.Lock()
+	mp.cfg.Policy.MinRelayTxFee = fee
+	mp.mtx.Unlock()
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
-// the main pool.  It does not include the orphan pool.
+// the main pool or had its fee delta changed.  It does not include the orphan
+// pool.
 //
 // This function is safe for concurrent access.
 func (mp *TxPool) LastUpdated() time.Time {
-	return time.Unix(atomic.LoadInt64(&mp.lastUpdated), 0)
+	return time.Unix(0, atomic.LoadInt64(&mp.lastUpdated))
 }
 
 // MempoolAcceptResult holds the result from mempool acceptance check.
@@ -1322,7 +1781,7 @@ func (mp *TxPool) CheckMempoolAcceptance(tx *btcutil.Tx) (
 	// which has the effect that we always check the fee paid from this tx
 	// is greater than min relay fee. We also reject this tx if it's
 	// already an orphan.
-	result, err := mp.checkMempoolAcceptance(tx, true, true, true)
+	result, err := mp.checkMempoolAcceptance(tx, true, true, true, nil)
 	if err != nil {
 		log.Errorf("CheckMempoolAcceptance: %v", err)
 		return nil, err
@@ -1337,8 +1796,14 @@ func (mp *TxPool) CheckMempoolAcceptance(tx *btcutil.Tx) (
 // checkMempoolAcceptance performs a series of validations on the given
 // transaction. It returns an error when the transaction fails to meet the
 // mempool policy, otherwise a `mempoolAcceptResult` is returned.
+//
+// When the transaction is evaluated as part of a package, the package context
+// provides the outputs of the transactions of the package that precede it, and
+// the fee and replacement fee checks are left to the caller since they apply
+// to the package as a whole, unless the context requires individual fees.
 func (mp *TxPool) checkMempoolAcceptance(tx *btcutil.Tx,
-	isNew, rateLimit, rejectDupOrphans bool) (*MempoolAcceptResult, error) {
+	isNew, rateLimit, rejectDupOrphans bool,
+	pkg *packageContext) (*MempoolAcceptResult, error) {
 
 	txHash := tx.Hash()
 
@@ -1404,6 +1869,7 @@ func (mp *TxPool) checkMempoolAcceptance(tx *btcutil.Tx,
 	// spend data and prevents double spends.
 	isReplacement, err := mp.checkPoolDoubleSpend(tx)
 	if err != nil {
+		logRejectedReplacement(tx.Hash(), err)
 		return nil, err
 	}
 
@@ -1420,6 +1886,12 @@ func (mp *TxPool) checkMempoolAcceptance(tx *btcutil.Tx,
 		return nil, err
 	}
 
+	// The outputs of the transactions of the package that precede this one
+	// are not in the pool yet.
+	if pkg != nil {
+		pkg.addInputUtxos(tx, utxoView)
+	}
+
 	// Don't allow the transaction if it exists in the main chain and is
 	// already fully spent.
 	prevOut := wire.OutPoint{Hash: *txHash}
@@ -1515,25 +1987,44 @@ func (mp *TxPool) checkMempoolAcceptance(tx *btcutil.Tx,
 
 	txSize := GetTxVirtualSize(tx)
 
+	// The fee policies apply to the fee including the fee delta set for
+	// the transaction, if any.
+	modifiedFee := txFee + mp.feeDeltas[*txHash]
+
 	// Don't allow transactions with fees too low to get into a mined
 	// block.
-	err = mp.validateRelayFeeMet(
-		tx, txFee, txSize, utxoView, nextBlockHeight, isNew, rateLimit,
-	)
-	if err != nil {
-		return nil, err
+	if pkg == nil || pkg.individualFees {
+		err = mp.validateRelayFeeMet(
+			tx, modifiedFee, txSize, utxoView, nextBlockHeight,
+			isNew, rateLimit,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// If the transaction has any conflicts, and we've made it this far,
 	// then we're processing a potential replacement.
 	var conflicts map[chainhash.Hash]*btcutil.Tx
-	if isReplacement {
-		conflicts, err = mp.validateReplacement(tx, txFee)
+	switch {
+	case isReplacement && pkg != nil && !pkg.individualFees:
+		conflicts = mp.txConflicts(tx)
+
+	case isReplacement:
+		conflicts, err = mp.validateReplacement(tx, modifiedFee)
 		if err != nil {
+			logRejectedReplacement(tx.Hash(), err)
 			return nil, err
 		}
 	}
 
+	// Don't allow transactions which would make the chains of unconfirmed
+	// transactions they are part of too long or too large.
+	err = mp.checkAncestorLimits(tx, txSize, conflicts, pkg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Verify crypto signatures for each input and reject the transaction
 	// if any don't verify.
 	err = blockchain.ValidateTransactionScripts(tx, utxoView,
@@ -1607,9 +2098,9 @@ func (mp *TxPool) validateStandardness(tx *btcutil.Tx, nextBlockHeight int32,
 	}
 
 	// Check the transaction standard.
-	err := CheckTransactionStandard(
+	err := policy.CheckTransactionStandard(
 		tx, nextBlockHeight, medianTimePast,
-		mp.cfg.Policy.MinRelayTxFee, mp.cfg.Policy.MaxTxVersion,
+		mp.cfg.Policy.MinRelayTxFee, &mp.cfg.Policy.Rules,
 	)
 	if err != nil {
 		// Attempt to extract a reject code from the error so it can be
@@ -1626,7 +2117,7 @@ func (mp *TxPool) validateStandardness(tx *btcutil.Tx, nextBlockHeight int32,
 	}
 
 	// Check the inputs standard.
-	err = checkInputsStandard(tx, utxoView)
+	err = policy.CheckInputsStandard(tx, utxoView, &mp.cfg.Policy.Rules)
 	if err != nil {
 		// Attempt to extract a reject code from the error so it can be
 		// retained. When not possible, fall back to a non-standard
@@ -1693,13 +2184,32 @@ func (mp *TxPool) validateRelayFeeMet(tx *btcutil.Tx, txFee, txSize int64,
 	// which is more desirable. Therefore, as long as the size of the
 	// transaction does not exceed 1000 less than the reserved space for
 	// high-priority transactions, don't require a fee for it.
-	minFee := calcMinRequiredTxRelayFee(txSize, mp.cfg.Policy.MinRelayTxFee)
+	minFee := policy.CalcMinRequiredTxRelayFee(
+		txSize, mp.cfg.Policy.MinRelayTxFee,
+	)
+
+	// New transactions must also pay the minimum fee rate of the pool,
+	// which is raised when transactions are evicted because the pool is
+	// full.  Free transactions are not exempt from it.
+	if isNew {
+		poolMinFee := policy.CalcMinRequiredTxRelayFee(txSize,
+			btcutil.Amount(mp.minFeeRate()))
+		if txFee < poolMinFee {
+			str := fmt.Sprintf("transaction %v has %d fees which "+
+				"is under the required amount of %d for the "+
+				"full mempool", txHash, txFee, poolMinFee)
+
+			return policyRuleError(policy.RuleMinRelayFee,
+				wire.RejectInsufficientFee, str)
+		}
+	}
 
 	if txSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
 			"the required amount of %d", txHash, txFee, minFee)
 
-		return txRuleError(wire.RejectInsufficientFee, str)
+		return policyRuleError(policy.RuleMinRelayFee,
+			wire.RejectInsufficientFee, str)
 	}
 
 	// Exit early if the min relay fee is met.
@@ -1760,12 +2270,17 @@ func (mp *TxPool) validateRelayFeeMet(tx *btcutil.Tx, txFee, txSize int64,
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
-	return &TxPool{
-		cfg:            *cfg,
-		pool:           make(map[chainhash.Hash]*TxDesc),
-		orphans:        make(map[chainhash.Hash]*orphanTx),
-		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
-		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
-		outpoints:      make(map[wire.OutPoint]*btcutil.Tx),
-	}
+	mp := &TxPool{
+		cfg:               *cfg,
+		pool:              make(map[chainhash.Hash]*TxDesc),
+		orphans:           make(map[chainhash.Hash]*orphanTx),
+		orphansByPrev:     make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
+		orphansByTag:      make(map[Tag]*orphanUsage),
+		outpoints:         make(map[wire.OutPoint]*btcutil.Tx),
+		reconsiderable:    make(map[chainhash.Hash]*btcutil.Tx),
+		feeDeltas:         make(map[chainhash.Hash]int64),
+		descendantEntries: make(map[chainhash.Hash]*descendantEntry),
+	}
+	mp.nextExpireScan = time.Now().Add(mp.orphanScanInterval())
+	return mp
 }
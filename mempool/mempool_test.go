@@ -14,9 +14,11 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -308,6 +310,8 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 
 	// Create a new fake chain and harness bound to it.
 	chain := &fakeChain{utxos: blockchain.NewUtxoViewpoint()}
+	rules := policy.DefaultRules()
+	rules.MaxTxVersion = 1
 	harness := poolHarness{
 		signKey:     signKey,
 		payAddr:     payAddr,
@@ -323,7 +327,7 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 				MaxOrphanTxSize:      1000,
 				MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
 				MinRelayTxFee:        1000, // 1 Satoshi per byte
-				MaxTxVersion:         1,
+				Rules:                rules,
 			},
 			ChainParams:      chainParams,
 			FetchUtxoView:    chain.FetchUtxoView,
@@ -634,6 +638,242 @@ func TestOrphanEviction(t *testing.T) {
 	}
 }
 
+// TestOrphanWeightLimits ensures the orphan pool enforces the weight allowed per
+// peer by evicting the oldest orphans of the peer, and the total weight by
+// evicting the oldest orphans of the peer whose orphans weigh the most.
+func TestOrphanWeightLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// The transactions of the chain only differ in weight by a few
+	// signature bytes, so the limits allow for half a transaction more
+	// than the number of transactions they are expressed in.
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 9)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	weight := blockchain.GetTransactionWeight(chainedTxns[1])
+	policy := &harness.txPool.cfg.Policy
+	policy.MaxOrphanTxs = 100
+	policy.MaxOrphanPeerWeight = 3*weight + weight/2
+	policy.MaxOrphanWeight = 5*weight + weight/2
+
+	addOrphan := func(tx *btcutil.Tx, tag Tag) {
+		t.Helper()
+
+		_, err := harness.txPool.ProcessTransaction(tx, true, false, tag)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"orphan %v", err)
+		}
+		testPoolMembership(tc, tx, true, false)
+	}
+
+	// The fourth orphan of the first peer exceeds its share, so its oldest
+	// orphan is evicted.
+	for _, tx := range chainedTxns[1:5] {
+		addOrphan(tx, 1)
+	}
+	testPoolMembership(tc, chainedTxns[1], false, false)
+
+	// The third orphan of the second peer exceeds the total weight, so the
+	// oldest orphan of the first peer, whose orphans weigh the most, is
+	// evicted.
+	for _, tx := range chainedTxns[5:8] {
+		addOrphan(tx, 2)
+	}
+	testPoolMembership(tc, chainedTxns[2], false, false)
+	for _, tx := range chainedTxns[3:8] {
+		testPoolMembership(tc, tx, true, false)
+	}
+
+	txnsWeight := func(txns []*btcutil.Tx) int64 {
+		var weight int64
+		for _, tx := range txns {
+			weight += blockchain.GetTransactionWeight(tx)
+		}
+		return weight
+	}
+	info := harness.txPool.OrphanInfo(true)
+	wantWeight := txnsWeight(chainedTxns[3:8])
+	if info.Size != 5 || info.Weight != wantWeight || len(info.Orphans) != 5 {
+		t.Fatalf("unexpected orphan pool usage: %d orphans (%d listed) "+
+			"of weight %d", info.Size, len(info.Orphans), info.Weight)
+	}
+	wantPeers := []btcjson.OrphanPeerResult{
+		{ID: 1, Size: 2, Weight: txnsWeight(chainedTxns[3:5])},
+		{ID: 2, Size: 3, Weight: txnsWeight(chainedTxns[5:8])},
+	}
+	if !reflect.DeepEqual(info.Peers, wantPeers) {
+		t.Fatalf("unexpected orphans per peer: got %v, want %v",
+			info.Peers, wantPeers)
+	}
+
+	// Orphans heavier than the share of a peer are rejected.
+	policy.MaxOrphanPeerWeight = blockchain.GetTransactionWeight(
+		chainedTxns[8]) - 1
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[8], true, false,
+		3)
+	if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+		t.Fatalf("ProcessTransaction: unexpected result for heavy "+
+			"orphan: %v", err)
+	}
+	testPoolMembership(tc, chainedTxns[8], false, false)
+}
+
+// TestOrphanExpiry ensures orphans are removed from the orphan pool once they
+// expire.
+func TestOrphanExpiry(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	harness.txPool.cfg.Policy.OrphanTTL = time.Millisecond
+	harness.txPool.nextExpireScan = time.Now()
+
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[1], true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid orphan %v",
+			err)
+	}
+	testPoolMembership(tc, chainedTxns[1], true, false)
+
+	// Adding another orphan once the first one expired removes it.
+	time.Sleep(5 * time.Millisecond)
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[2], true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid orphan %v",
+			err)
+	}
+	testPoolMembership(tc, chainedTxns[1], false, false)
+	testPoolMembership(tc, chainedTxns[2], true, false)
+
+	if info := harness.txPool.OrphanInfo(false); info.Size != 1 ||
+		info.Orphans != nil {
+
+		t.Fatalf("unexpected orphan pool info: %+v", info)
+	}
+}
+
+// TestTxNotifications ensures the additions to and removals from the main pool
+// are reported along with the mempool sequence number, except for removals of
+// confirmed transactions.
+func TestTxNotifications(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+
+	type notification struct {
+		hash     chainhash.Hash
+		added    bool
+		sequence uint64
+	}
+	var notifications []notification
+	harness.txPool.cfg.TxAdded = func(tx *btcutil.Tx, sequence uint64) {
+		notifications = append(notifications,
+			notification{*tx.Hash(), true, sequence})
+	}
+	harness.txPool.cfg.TxRemoved = func(tx *btcutil.Tx, sequence uint64) {
+		notifications = append(notifications,
+			notification{*tx.Hash(), false, sequence})
+	}
+
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"transaction: %v", err)
+		}
+	}
+
+	// Confirming the first transaction removes it without a notification
+	// while removing the second one removes the third one as well.
+	harness.txPool.RemoveConfirmedTransaction(chainedTxns[0])
+	harness.txPool.RemoveTransaction(chainedTxns[1], true)
+
+	want := []notification{
+		{*chainedTxns[0].Hash(), true, 1},
+		{*chainedTxns[1].Hash(), true, 2},
+		{*chainedTxns[2].Hash(), true, 3},
+		{*chainedTxns[2].Hash(), false, 5},
+		{*chainedTxns[1].Hash(), false, 6},
+	}
+	if !reflect.DeepEqual(notifications, want) {
+		t.Fatalf("unexpected notifications: got %v, want %v",
+			notifications, want)
+	}
+}
+
+// TestTxReplacedNotification ensures the mempool reports replaced transactions,
+// including their descendants, along with the replacement.
+func TestTxReplacedNotification(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	var replaced, replacements []*TxDesc
+	harness.txPool.cfg.TxReplaced = func(r, rs []*TxDesc) {
+		replaced = append(replaced, r...)
+		replacements = append(replacements, rs...)
+	}
+
+	const fee = btcutil.SatoshiPerBitcoin
+	coinbase := ctx.addCoinbaseTx(1)
+	outs := []spendableOutput{txOutToSpendableOut(coinbase, 0)}
+	parent := ctx.addSignedTx(outs, 1, fee, true, false)
+	child := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(parent, 0)}, 1, fee,
+		false, false,
+	)
+	if len(replaced) != 0 {
+		t.Fatalf("unexpected replacement notification")
+	}
+
+	replacement := ctx.addSignedTx(outs, 1, 3*fee, false, false)
+
+	replacedHashes := make(map[chainhash.Hash]struct{})
+	for _, txD := range replaced {
+		replacedHashes[*txD.Tx.Hash()] = struct{}{}
+	}
+	wantReplaced := map[chainhash.Hash]struct{}{
+		*parent.Hash(): {},
+		*child.Hash():  {},
+	}
+	if len(replaced) != 2 || !reflect.DeepEqual(replacedHashes, wantReplaced) {
+		t.Fatalf("unexpected replaced transactions: got %v, want %v",
+			replacedHashes, wantReplaced)
+	}
+	if len(replacements) != 1 ||
+		*replacements[0].Tx.Hash() != *replacement.Hash() {
+
+		t.Fatalf("unexpected replacements: %v", replacements)
+	}
+}
+
 // TestBasicOrphanRemoval ensure that orphan removal works as expected when an
 // orphan that doesn't exist is removed  both when there is another orphan that
 // redeems it and when there is not.
@@ -1463,6 +1703,7 @@ func TestRBF(t *testing.T) {
 		name  string
 		setup func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx)
 		err   string
+		rule  ReplacementRule
 	}{
 		{
 			// A transaction cannot replace another if it doesn't
@@ -1491,7 +1732,8 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "already spent in mempool",
+			err:  "already spent in mempool",
+			rule: RBFNotSignaled,
 		},
 		{
 			// A transaction cannot replace another if we don't
@@ -1522,7 +1764,68 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "already spent in mempool",
+			err:  "already spent in mempool",
+			rule: RBFReplacementsDisabled,
+		},
+		{
+			// A transaction can replace another which doesn't
+			// signal replacement when full RBF is enabled.
+			name: "full rbf non-replaceable parent",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				ctx.harness.txPool.cfg.Policy.FullRBF = true
+
+				coinbase := ctx.addCoinbaseTx(1)
+
+				// Create a transaction that spends the coinbase
+				// output and doesn't signal for replacement.
+				coinbaseOut := txOutToSpendableOut(coinbase, 0)
+				outs := []spendableOutput{coinbaseOut}
+				parent := ctx.addSignedTx(
+					outs, 1, defaultFee, false, false,
+				)
+
+				// A transaction that spends the same output
+				// and pays a higher fee should replace it.
+				tx, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee*2, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return tx, []*btcutil.Tx{parent}
+			},
+			err: "",
+		},
+		{
+			// Full RBF doesn't override a policy which rejects all
+			// replacements.
+			name: "full rbf reject replacement policy",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				ctx.harness.txPool.cfg.Policy.FullRBF = true
+				ctx.harness.txPool.cfg.Policy.RejectReplacement = true
+
+				coinbase := ctx.addCoinbaseTx(1)
+
+				coinbaseOut := txOutToSpendableOut(coinbase, 0)
+				outs := []spendableOutput{coinbaseOut}
+				parent := ctx.addSignedTx(
+					outs, 1, defaultFee, false, false,
+				)
+
+				tx, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee*2, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return tx, []*btcutil.Tx{parent}
+			},
+			err:  "already spent in mempool",
+			rule: RBFReplacementsDisabled,
 		},
 		{
 			// A transaction cannot replace another if doing so
@@ -1569,7 +1872,8 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "evicts more transactions than permitted",
+			err:  "evicts more transactions than permitted",
+			rule: RBFTooManyReplacements,
 		},
 		{
 			// A transaction cannot replace another if the
@@ -1602,7 +1906,8 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "spends parent transaction",
+			err:  "spends parent transaction",
+			rule: RBFSpendsConflict,
 		},
 		{
 			// A transaction cannot replace another if it has a
@@ -1639,7 +1944,8 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "insufficient fee rate",
+			err:  "insufficient fee rate",
+			rule: RBFInsufficientFeeRate,
 		},
 		{
 			// A transaction cannot replace another if it doesn't
@@ -1670,7 +1976,8 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "insufficient absolute fee",
+			err:  "insufficient absolute fee",
+			rule: RBFInsufficientRelayFee,
 		},
 		{
 			// A transaction cannot replace another if it introduces
@@ -1708,7 +2015,8 @@ func TestRBF(t *testing.T) {
 
 				return tx, nil
 			},
-			err: "spends new unconfirmed input",
+			err:  "spends new unconfirmed input",
+			rule: RBFNewUnconfirmedInput,
 		},
 		{
 			// A transaction can replace another with a higher fee.
@@ -1834,6 +2142,39 @@ func TestRBF(t *testing.T) {
 					ctx.t.Fatalf("expected error: %v\n"+
 						"got: %v", testCase.err, err)
 				}
+
+				// Ensure the error carries the details of
+				// the violated replacement rule.
+				rejection := ExtractReplacementRejection(err)
+				if rejection == nil {
+					ctx.t.Fatalf("expected replacement "+
+						"details for error: %v", err)
+				}
+				if rejection.Rule != testCase.rule {
+					ctx.t.Fatalf("expected rule %v, got %v",
+						testCase.rule, rejection.Rule)
+				}
+				if len(rejection.Conflicts) == 0 {
+					ctx.t.Fatalf("expected conflicts for "+
+						"error: %v", err)
+				}
+				switch rejection.Rule {
+				case RBFInsufficientAbsoluteFee,
+					RBFInsufficientRelayFee:
+
+					if rejection.FeeDelta <= 0 {
+						ctx.t.Fatalf("expected fee "+
+							"delta, got %d",
+							rejection.FeeDelta)
+					}
+
+				case RBFInsufficientFeeRate:
+					if rejection.FeeRateDelta <= 0 {
+						ctx.t.Fatalf("expected fee "+
+							"rate delta, got %d",
+							rejection.FeeRateDelta)
+					}
+				}
 			}
 
 			// If the replacement transaction is valid, we'll check
@@ -1851,3 +2192,33 @@ func TestRBF(t *testing.T) {
 		}
 	}
 }
+
+// TestSetMinRelayTxFee ensures changing the minimum relay fee applies to the
+// transactions processed afterwards and keeps the ones already in the pool.
+func TestSetMinRelayTxFee(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	pool := ctx.harness.txPool
+	coinbase := ctx.addCoinbaseTx(2)
+	output := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+
+	accepted := ctx.addSignedTx(output(0), 1, 1000, false, false)
+
+	// A fee of 1000 satoshi no longer meets a minimum relay fee of 10000
+	// satoshi per kB, but the transaction already in the pool is kept.
+	pool.SetMinRelayTxFee(10000)
+	tx := ctx.createSignedTx(output(1), 1, 1000, false)
+	_, err := pool.ProcessTransaction(tx, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("expected transaction below the new minimum relay "+
+			"fee to be rejected, got %v", err)
+	}
+	testPoolMembership(ctx, accepted, false, true)
+
+	// Lowering the fee again accepts the transaction.
+	pool.SetMinRelayTxFee(1000)
+	ctx.addSignedTx(output(1), 1, 1000, false, false)
+}
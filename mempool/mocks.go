@@ -47,6 +47,71 @@ func (m *MockTxMempool) Count() int {
 	return args.Get(0).(int)
 }
 
+// MemoryUsage returns the number of bytes of memory used by the transactions
+// of the main pool. It does not include the orphan pool.
+func (m *MockTxMempool) MemoryUsage() int64 {
+	args := m.Called()
+	return args.Get(0).(int64)
+}
+
+// OrphanInfo returns the usage and limits of the orphan pool along with the
+// orphans relayed by each peer as a btcjson result.
+func (m *MockTxMempool) OrphanInfo(verbose bool) *btcjson.GetOrphanInfoResult {
+	args := m.Called(verbose)
+	return args.Get(0).(*btcjson.GetOrphanInfoResult)
+}
+
+// MinFeeRate returns the minimum fee rate per kvB transactions must pay to
+// enter the pool in addition to the minimum relay fee.
+func (m *MockTxMempool) MinFeeRate() btcutil.Amount {
+	args := m.Called()
+	return args.Get(0).(btcutil.Amount)
+}
+
+// MempoolEntry returns the requested transaction of the pool as a fully
+// populated btcjson result.
+func (m *MockTxMempool) MempoolEntry(
+	txHash *chainhash.Hash) (*btcjson.GetMempoolEntryResult, error) {
+
+	args := m.Called(txHash)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*btcjson.GetMempoolEntryResult), args.Error(1)
+}
+
+// MempoolAncestors returns all of the unconfirmed ancestors of the requested
+// transaction of the pool as fully populated btcjson results.
+func (m *MockTxMempool) MempoolAncestors(txHash *chainhash.Hash) (
+	map[string]*btcjson.GetMempoolEntryResult, error) {
+
+	args := m.Called(txHash)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(map[string]*btcjson.GetMempoolEntryResult),
+		args.Error(1)
+}
+
+// MempoolDescendants returns all of the unconfirmed descendants of the
+// requested transaction of the pool as fully populated btcjson results.
+func (m *MockTxMempool) MempoolDescendants(txHash *chainhash.Hash) (
+	map[string]*btcjson.GetMempoolEntryResult, error) {
+
+	args := m.Called(txHash)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(map[string]*btcjson.GetMempoolEntryResult),
+		args.Error(1)
+}
+
 // FetchTransaction returns the requested transaction from the transaction
 // pool. This only fetches from the main transaction pool and does not include
 // orphans.
@@ -85,6 +150,20 @@ func (m *MockTxMempool) ProcessTransaction(tx *btcutil.Tx, allowOrphan,
 	return args.Get(0).([]*TxDesc), args.Error(1)
 }
 
+// ProcessPackage evaluates the passed transactions as a package and adds the
+// acceptable ones to the memory pool.
+func (m *MockTxMempool) ProcessPackage(
+	txns []*wire.MsgTx) (*PackageAcceptResult, error) {
+
+	args := m.Called(txns)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*PackageAcceptResult), args.Error(1)
+}
+
 // RemoveTransaction removes the passed transaction from the mempool.  When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
@@ -111,6 +190,22 @@ func (m *MockTxMempool) CheckMempoolAcceptance(
 	return args.Get(0).(*MempoolAcceptResult), args.Error(1)
 }
 
+// CheckPackageAcceptance behaves similarly to bitcoind's `testmempoolaccept` RPC
+// method for multiple transactions.  It decides whether the passed
+// transactions would be accepted to the mempool one after the other without
+// adding any of them.
+func (m *MockTxMempool) CheckPackageAcceptance(
+	txns []*btcutil.Tx) (*PackageAcceptResult, error) {
+
+	args := m.Called(txns)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*PackageAcceptResult), args.Error(1)
+}
+
 // CheckSpend checks whether the passed outpoint is already spent by a
 // transaction in the mempool. If that's the case the spending transaction will
 // be returned, if not nil will be returned.
@@ -123,3 +218,13 @@ func (m *MockTxMempool) CheckSpend(op wire.OutPoint) *btcutil.Tx {
 
 	return args.Get(0).(*btcutil.Tx)
 }
+
+// PrioritiseTransaction adds the passed fee delta in satoshi to the virtual fee
+// delta of the transaction with the passed hash and returns the resulting fee
+// delta.
+func (m *MockTxMempool) PrioritiseTransaction(hash *chainhash.Hash,
+	feeDelta int64) int64 {
+
+	args := m.Called(hash, feeDelta)
+	return args.Get(0).(int64)
+}
@@ -0,0 +1,712 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/policy"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MaxPackageCount is the maximum number of transactions a package may
+	// contain.
+	MaxPackageCount = 25
+
+	// MaxPackageWeight is the maximum total weight of the transactions of
+	// a package.
+	MaxPackageWeight = 404000
+
+	// maxReconsiderableTxns is the maximum number of transactions which
+	// were rejected for insufficient fees that are kept around so they can
+	// be accepted along with a child which pays for them.
+	maxReconsiderableTxns = 100
+)
+
+// PackageTxResult describes the outcome of the evaluation of a transaction of
+// a package.
+type PackageTxResult struct {
+	// Tx is the transaction.
+	Tx *btcutil.Tx
+
+	// AlreadyInPool is set when the transaction was in the pool before the
+	// package was processed.
+	AlreadyInPool bool
+
+	// Fee is the fee paid by the transaction in satoshi.  It is only set
+	// for transactions which are in the pool.
+	Fee btcutil.Amount
+
+	// VSize is the virtual size of the transaction.
+	VSize int64
+
+	// EffectiveFeeRate is the fee rate in satoshi per kvB the transaction
+	// was accepted at.  It is the fee rate of the package for transactions
+	// which were only acceptable as part of the package.
+	EffectiveFeeRate int64
+
+	// EffectiveIncludes are the transactions whose fees and sizes make up
+	// the effective fee rate.
+	EffectiveIncludes []*btcutil.Tx
+
+	// MissingParents are the transactions whose outputs are spent by the
+	// transaction but which are neither in the chain, the pool nor the
+	// preceding transactions of the package.  It is only set by
+	// CheckPackageAcceptance.
+	MissingParents []*chainhash.Hash
+
+	// Err is the reason the transaction was not accepted.  It is nil for
+	// transactions which are in the pool.
+	Err error
+}
+
+// PackageAcceptResult holds the result of processing a package.
+type PackageAcceptResult struct {
+	// TxResults are the results of the transactions of the package in the
+	// order of the package.
+	TxResults []*PackageTxResult
+
+	// ReplacedTxns are the transactions which were evicted from the pool
+	// by transactions of the package.
+	ReplacedTxns []*btcutil.Tx
+
+	// AcceptedTxns are the transactions which were added to the pool in
+	// the order they were added.  This includes orphans which were
+	// accepted as a result of the package being accepted.
+	AcceptedTxns []*TxDesc
+
+	// Err is the reason the package was not accepted in full.  It is nil
+	// when all transactions of the package are in the pool.
+	Err error
+}
+
+// packageContext houses the transactions of a package which are evaluated
+// together because they are not acceptable on their own.
+type packageContext struct {
+	// txns are the transactions of the package which have been evaluated
+	// so far.
+	txns map[chainhash.Hash]*btcutil.Tx
+
+	// individualFees is set when each transaction of the package must pay
+	// the minimum relay fee and follow the replacement rules on its own
+	// rather than as part of the package.
+	individualFees bool
+}
+
+// addInputUtxos adds the outputs of the transactions of the package that are
+// spent by the passed transaction to the passed view.
+func (pkg *packageContext) addInputUtxos(tx *btcutil.Tx,
+	utxoView *blockchain.UtxoViewpoint) {
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(*prevOut)
+		if entry != nil && !entry.IsSpent() {
+			continue
+		}
+
+		if pkgTx, exists := pkg.txns[prevOut.Hash]; exists {
+			// AddTxOut ignores out of range index values, so it is
+			// safe to call without bounds checking here.
+			utxoView.AddTxOut(pkgTx, prevOut.Index,
+				mining.UnminedHeight)
+		}
+	}
+}
+
+// isReconsiderable returns whether the passed error rejected a transaction
+// only because it doesn't pay enough fees on its own, which means it might be
+// acceptable along with a child which pays for it.
+func isReconsiderable(err error) bool {
+	code, ok := extractRejectCode(err)
+	return ok && code == wire.RejectInsufficientFee
+}
+
+// checkPackageConsistency ensures the passed transactions are sorted so parents
+// precede their children, don't contain duplicate or conflicting transactions,
+// and don't exceed the package limits.
+func checkPackageConsistency(txns []*btcutil.Tx) error {
+	if len(txns) == 0 {
+		return txRuleError(wire.RejectInvalid, "package is empty")
+	}
+	if len(txns) > MaxPackageCount {
+		str := fmt.Sprintf("package contains too many transactions: "+
+			"max is %v, has %v", MaxPackageCount, len(txns))
+		return txRuleError(wire.RejectInvalid, str)
+	}
+
+	var weight int64
+	index := make(map[chainhash.Hash]int, len(txns))
+	for i, tx := range txns {
+		if _, exists := index[*tx.Hash()]; exists {
+			str := fmt.Sprintf("package contains duplicate "+
+				"transaction %v", tx.Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+		index[*tx.Hash()] = i
+		weight += blockchain.GetTransactionWeight(tx)
+	}
+	if weight > MaxPackageWeight {
+		str := fmt.Sprintf("package is too large: max weight is %v, "+
+			"has %v", MaxPackageWeight, weight)
+		return txRuleError(wire.RejectInvalid, str)
+	}
+
+	spent := make(map[wire.OutPoint]struct{})
+	for i, tx := range txns {
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevOut := txIn.PreviousOutPoint
+			if j, exists := index[prevOut.Hash]; exists && j > i {
+				str := fmt.Sprintf("package is not sorted: "+
+					"transaction %v spends transaction %v "+
+					"which follows it", tx.Hash(), prevOut.Hash)
+				return txRuleError(wire.RejectInvalid, str)
+			}
+			if _, exists := spent[prevOut]; exists {
+				str := fmt.Sprintf("package contains conflicting "+
+					"transactions spending output %v", prevOut)
+				return txRuleError(wire.RejectInvalid, str)
+			}
+			spent[prevOut] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// checkPackageTopology ensures the passed transactions form a package which may
+// be evaluated as a whole.  Packages must be consistent as defined by
+// checkPackageConsistency, and they must consist of a child transaction which
+// comes last and its parents.
+func checkPackageTopology(txns []*btcutil.Tx) error {
+	if err := checkPackageConsistency(txns); err != nil {
+		return err
+	}
+
+	child := txns[len(txns)-1]
+	parents := make(map[chainhash.Hash]struct{})
+	for _, txIn := range child.MsgTx().TxIn {
+		parents[txIn.PreviousOutPoint.Hash] = struct{}{}
+	}
+	for _, tx := range txns[:len(txns)-1] {
+		if _, ok := parents[*tx.Hash()]; !ok {
+			str := fmt.Sprintf("package is not a child with its "+
+				"parents: transaction %v is not a parent of %v",
+				tx.Hash(), child.Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+	}
+
+	return nil
+}
+
+// validatePackageReplacement determines whether the transactions of a package,
+// which pay the passed total fee and have the passed total virtual size, may
+// replace the passed conflicting transactions of the pool.  The same rules as
+// for a single replacement apply with the fee rate and fee of the package in
+// place of the ones of a single transaction.  Replacements by packages are
+// only allowed for packages of one parent and one child.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) validatePackageReplacement(txns []*btcutil.Tx, pkgFee,
+	pkgSize int64, conflicts map[chainhash.Hash]*btcutil.Tx) error {
+
+	pkgFeeRate := pkgFee * 1000 / pkgSize
+	rejection := func(rule ReplacementRule,
+		conflicts map[chainhash.Hash]*btcutil.Tx) *ReplacementRejection {
+
+		return &ReplacementRejection{
+			Rule:      rule,
+			Conflicts: sortedTxHashes(conflicts),
+			Fee:       pkgFee,
+			FeeRate:   pkgFeeRate,
+		}
+	}
+
+	if len(txns) > 2 {
+		str := fmt.Sprintf("package of %d transactions replaces "+
+			"transactions: only packages of one parent and one "+
+			"child may replace transactions", len(txns))
+		return replacementRuleError(wire.RejectNonstandard, str,
+			rejection(RBFPackageTooLarge, conflicts))
+	}
+
+	if len(conflicts) > MaxReplacementEvictions {
+		str := fmt.Sprintf("replacement package evicts more "+
+			"transactions than permitted: max is %v, evicts %v",
+			MaxReplacementEvictions, len(conflicts))
+		return replacementRuleError(wire.RejectNonstandard, str,
+			rejection(RBFTooManyReplacements, conflicts))
+	}
+
+	// The package may not spend outputs of the transactions it replaces.
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	spentConflicts := make(map[chainhash.Hash]*btcutil.Tx)
+	for _, tx := range txns {
+		for ancestorHash, ancestor := range mp.txAncestors(tx, cache) {
+			if _, ok := conflicts[ancestorHash]; ok {
+				spentConflicts[ancestorHash] = ancestor
+			}
+		}
+	}
+	if len(spentConflicts) > 0 {
+		r := rejection(RBFSpendsConflict, spentConflicts)
+		str := fmt.Sprintf("replacement package spends parent "+
+			"transaction %v", r.Conflicts[0])
+		return replacementRuleError(wire.RejectInvalid, str, r)
+	}
+
+	var conflictsFee, maxFeeRate int64
+	feeRateConflicts := make(map[chainhash.Hash]*btcutil.Tx)
+	conflictsParents := make(map[chainhash.Hash]struct{})
+	for hash, conflict := range conflicts {
+		if conflictFeeRate := modifiedFeeRate(mp.pool[hash]); pkgFeeRate <= conflictFeeRate {
+			feeRateConflicts[hash] = conflict
+			if conflictFeeRate > maxFeeRate {
+				maxFeeRate = conflictFeeRate
+			}
+		}
+		conflictsFee += mp.pool[hash].Fee + mp.pool[hash].FeeDelta
+
+		for _, txIn := range conflict.MsgTx().TxIn {
+			conflictsParents[txIn.PreviousOutPoint.Hash] = struct{}{}
+		}
+	}
+	if len(feeRateConflicts) > 0 {
+		r := rejection(RBFInsufficientFeeRate, feeRateConflicts)
+		r.FeeRateDelta = maxFeeRate + 1 - pkgFeeRate
+		str := fmt.Sprintf("replacement package has an "+
+			"insufficient fee rate: needs more than %v, has %v",
+			maxFeeRate, pkgFeeRate)
+		return replacementRuleError(wire.RejectInsufficientFee, str, r)
+	}
+
+	minFee := policy.CalcMinRequiredTxRelayFee(
+		pkgSize, mp.cfg.Policy.MinRelayTxFee,
+	)
+	if pkgFee < conflictsFee+minFee {
+		rule := RBFInsufficientRelayFee
+		if pkgFee < conflictsFee {
+			rule = RBFInsufficientAbsoluteFee
+		}
+		r := rejection(rule, conflicts)
+		r.FeeDelta = conflictsFee + minFee - pkgFee
+		str := fmt.Sprintf("replacement package has an insufficient "+
+			"absolute fee: needs %v, has %v", conflictsFee+minFee,
+			pkgFee)
+		return replacementRuleError(wire.RejectInsufficientFee, str, r)
+	}
+
+	// The package may not spend new unconfirmed outputs other than the
+	// ones of its own transactions and the parents of the conflicts.
+	for _, tx := range txns {
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevHash := txIn.PreviousOutPoint.Hash
+			if _, ok := conflictsParents[prevHash]; ok {
+				continue
+			}
+			if _, ok := mp.pool[prevHash]; !ok {
+				continue
+			}
+			str := fmt.Sprintf("replacement package spends new "+
+				"unconfirmed input %v not found in conflicting "+
+				"transactions", txIn.PreviousOutPoint)
+			return replacementRuleError(wire.RejectInvalid, str,
+				rejection(RBFNewUnconfirmedInput, conflicts))
+		}
+	}
+
+	return nil
+}
+
+// acceptPackage evaluates the passed transactions of a package, which are not
+// acceptable on their own, as a whole and adds them to the pool when the
+// package as a whole follows the rules.  The fee rate of the package must meet
+// the minimum relay fee rate and replacements of pool transactions must follow
+// the package replacement rules.  The results for the transactions are stored
+// in the passed results, which are keyed by transaction hash.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) acceptPackage(txns []*btcutil.Tx,
+	txResults map[chainhash.Hash]*PackageTxResult,
+	result *PackageAcceptResult) error {
+
+	pkg := &packageContext{
+		txns: make(map[chainhash.Hash]*btcutil.Tx, len(txns)),
+	}
+	accepts := make([]*MempoolAcceptResult, len(txns))
+	conflicts := make(map[chainhash.Hash]*btcutil.Tx)
+	var pkgFee, pkgSize int64
+	for i, tx := range txns {
+		r, err := mp.checkMempoolAcceptance(tx, true, false, false, pkg)
+		if err == nil && len(r.MissingParents) > 0 {
+			str := fmt.Sprintf("transaction %v of the package "+
+				"references outputs of unknown or fully-spent "+
+				"transaction %v", tx.Hash(), r.MissingParents[0])
+			err = txRuleError(wire.RejectInvalid, str)
+		}
+		if err != nil {
+			txResults[*tx.Hash()].Err = err
+			return err
+		}
+
+		pkg.txns[*tx.Hash()] = tx
+		accepts[i] = r
+		pkgFee += int64(r.TxFee) + mp.feeDeltas[*tx.Hash()]
+		pkgSize += r.TxSize
+		for hash, conflict := range r.Conflicts {
+			conflicts[hash] = conflict
+		}
+	}
+
+	// The package must pay the minimum fee rate of the pool when it is
+	// higher than the minimum relay fee rate because the pool is full.
+	minFeeRate := mp.cfg.Policy.MinRelayTxFee
+	poolMinFeeRate := btcutil.Amount(mp.minFeeRate())
+	if poolMinFeeRate > minFeeRate {
+		minFeeRate = poolMinFeeRate
+	}
+	minFee := policy.CalcMinRequiredTxRelayFee(pkgSize, minFeeRate)
+	if pkgFee < minFee {
+		str := fmt.Sprintf("package has %d fees which is under the "+
+			"required amount of %d", pkgFee, minFee)
+		return policyRuleError(policy.RuleMinRelayFee,
+			wire.RejectInsufficientFee, str)
+	}
+
+	if len(conflicts) > 0 {
+		err := mp.validatePackageReplacement(txns, pkgFee, pkgSize,
+			conflicts)
+		if err != nil {
+			logRejectedReplacement(txns[len(txns)-1].Hash(), err)
+			return err
+		}
+	}
+
+	// The package is acceptable, so replace the conflicts and add its
+	// transactions to the pool.
+	pkgFeeRate := pkgFee * 1000 / pkgSize
+	var replaced, replacements []*TxDesc
+	for _, conflict := range conflicts {
+		replaced = append(replaced, mp.pool[*conflict.Hash()])
+		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
+			"with package (fee_rate=%v sat/kb)", conflict.Hash(),
+			mp.pool[*conflict.Hash()].FeePerKB, pkgFeeRate)
+
+		// The conflict set already includes the descendants of each
+		// conflict, so there is no need to remove redeemers here.
+		mp.removeTransaction(conflict, false)
+		result.ReplacedTxns = append(result.ReplacedTxns, conflict)
+	}
+	for i, tx := range txns {
+		r := accepts[i]
+		txD := mp.addTransaction(r.utxoView, tx, r.bestHeight,
+			int64(r.TxFee))
+		mp.removeOrphan(tx, false)
+		result.AcceptedTxns = append(result.AcceptedTxns, txD)
+		replacements = append(replacements, txD)
+
+		txResult := txResults[*tx.Hash()]
+		txResult.Fee = r.TxFee
+		txResult.EffectiveFeeRate = pkgFeeRate
+		txResult.EffectiveIncludes = txns
+		txResult.Err = nil
+
+		log.Debugf("Accepted transaction %v as part of a package "+
+			"(pool size: %v)", tx.Hash(), len(mp.pool))
+	}
+
+	// Make room for the package when the pool is full.  The package is
+	// rejected if any of its transactions are among the evicted ones.
+	mp.limitPoolSize()
+	for _, tx := range txns {
+		if !mp.isTransactionInPool(tx.Hash()) {
+			str := fmt.Sprintf("transaction %v of the package was "+
+				"evicted right away because the mempool is full",
+				tx.Hash())
+			return txRuleError(wire.RejectInsufficientFee, str)
+		}
+	}
+
+	if len(replaced) > 0 && mp.cfg.TxReplaced != nil {
+		mp.cfg.TxReplaced(replaced, replacements)
+	}
+
+	return nil
+}
+
+// processPackage is the internal function which implements the public
+// ProcessPackage.  See the comment for ProcessPackage for more details.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) processPackage(txns []*btcutil.Tx) (*PackageAcceptResult, error) {
+	if err := checkPackageTopology(txns); err != nil {
+		return nil, err
+	}
+
+	result := &PackageAcceptResult{
+		TxResults: make([]*PackageTxResult, len(txns)),
+	}
+	txResults := make(map[chainhash.Hash]*PackageTxResult, len(txns))
+	for i, tx := range txns {
+		txResult := &PackageTxResult{
+			Tx:    tx,
+			VSize: GetTxVirtualSize(tx),
+		}
+		result.TxResults[i] = txResult
+		txResults[*tx.Hash()] = txResult
+	}
+
+	// Add the transactions which are acceptable on their own to the pool
+	// individually.  The ones which only lack fees or depend on such
+	// transactions are evaluated as a package afterwards.
+	var deferred []*btcutil.Tx
+	for _, tx := range txns {
+		txResult := txResults[*tx.Hash()]
+		if txD, exists := mp.pool[*tx.Hash()]; exists {
+			txResult.AlreadyInPool = true
+			txResult.Fee = btcutil.Amount(txD.Fee)
+			txResult.EffectiveFeeRate = txD.FeePerKB
+			txResult.EffectiveIncludes = []*btcutil.Tx{tx}
+			continue
+		}
+
+		conflicts := mp.txConflicts(tx)
+		missing, txD, err := mp.maybeAcceptTransaction(tx, true, false,
+			false)
+		switch {
+		case err == nil && len(missing) == 0:
+			mp.removeOrphan(tx, false)
+			for _, conflict := range conflicts {
+				result.ReplacedTxns = append(result.ReplacedTxns,
+					conflict)
+			}
+			result.AcceptedTxns = append(result.AcceptedTxns, txD)
+			txResult.Fee = btcutil.Amount(txD.Fee)
+			txResult.EffectiveFeeRate = txD.FeePerKB
+			txResult.EffectiveIncludes = []*btcutil.Tx{tx}
+
+		case err == nil || isReconsiderable(err):
+			txResult.Err = err
+			deferred = append(deferred, tx)
+
+		default:
+			txResult.Err = err
+			result.Err = err
+		}
+		if result.Err != nil {
+			break
+		}
+	}
+
+	if result.Err == nil && len(deferred) > 0 {
+		result.Err = mp.acceptPackage(deferred, txResults, result)
+	}
+
+	// Transactions which weren't accepted and have no error of their own
+	// were rejected because of the package.
+	if result.Err != nil {
+		for _, txResult := range result.TxResults {
+			_, inPool := mp.pool[*txResult.Tx.Hash()]
+			if txResult.Err == nil && !inPool {
+				txResult.Err = result.Err
+			}
+		}
+	}
+
+	// Accept any orphans which depend on the accepted transactions.
+	accepted := result.AcceptedTxns
+	for _, txD := range accepted {
+		result.AcceptedTxns = append(result.AcceptedTxns,
+			mp.processOrphans(txD.Tx)...)
+	}
+
+	// Transactions may have been evicted again to make room for the ones
+	// which were added after them when the pool is full.
+	accepted = result.AcceptedTxns[:0]
+	for _, txD := range result.AcceptedTxns {
+		if mp.isTransactionInPool(txD.Tx.Hash()) {
+			accepted = append(accepted, txD)
+		}
+	}
+	result.AcceptedTxns = accepted
+
+	return result, nil
+}
+
+// ProcessPackage evaluates the passed transactions as a package and adds the
+// acceptable ones to the memory pool.  A package consists of a child
+// transaction, which must come last, and its parents sorted so that parents
+// precede their children.
+//
+// Transactions which are acceptable on their own are added to the pool
+// individually first.  The remaining transactions, typically parents which
+// don't pay the minimum relay fee and a child which pays for them (CPFP), are
+// then evaluated as a whole so the fee rate of the package is what has to meet
+// the minimum relay fee rate.  Packages of one parent and one child may also
+// replace transactions of the pool when the package as a whole follows the
+// replacement rules.
+//
+// An error is only returned when the transactions don't form a valid package.
+// Otherwise the outcome for each transaction is part of the returned result
+// along with the transactions that were added to the pool, which should be
+// relayed, and the ones that were replaced.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessPackage(msgTxns []*wire.MsgTx) (*PackageAcceptResult, error) {
+	txns := make([]*btcutil.Tx, len(msgTxns))
+	for i, msgTx := range msgTxns {
+		txns[i] = btcutil.NewTx(msgTx)
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	return mp.processPackage(txns)
+}
+
+// CheckPackageAcceptance behaves similarly to bitcoind's `testmempoolaccept` RPC
+// method for multiple transactions.  It decides whether the passed
+// transactions, sorted so parents precede their children, would be accepted to
+// the pool one after the other without adding any of them to it.  The outputs
+// of the transactions that precede a transaction are available to it, but
+// unlike with ProcessPackage each transaction must pay the minimum relay fee
+// and follow the replacement rules on its own, and the transactions don't have
+// to be a child with its parents.
+//
+// An error is only returned when the transactions don't form a consistent
+// package.  Otherwise the result holds the outcomes of the transactions in
+// order up to and including the first one which is not acceptable, since the
+// transactions following it can't be evaluated without it.  Its Err field is
+// the reason that transaction is not acceptable.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) CheckPackageAcceptance(txns []*btcutil.Tx) (*PackageAcceptResult, error) {
+	if err := checkPackageConsistency(txns); err != nil {
+		return nil, err
+	}
+
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	pkg := &packageContext{
+		txns:           make(map[chainhash.Hash]*btcutil.Tx, len(txns)),
+		individualFees: true,
+	}
+	result := &PackageAcceptResult{
+		TxResults: make([]*PackageTxResult, 0, len(txns)),
+	}
+	for _, tx := range txns {
+		txResult := &PackageTxResult{
+			Tx:    tx,
+			VSize: GetTxVirtualSize(tx),
+		}
+		result.TxResults = append(result.TxResults, txResult)
+
+		r, err := mp.checkMempoolAcceptance(tx, true, true, true, pkg)
+		if err == nil && len(r.MissingParents) > 0 {
+			txResult.MissingParents = r.MissingParents
+			str := fmt.Sprintf("transaction %v references outputs "+
+				"of unknown or fully-spent transaction %v",
+				tx.Hash(), r.MissingParents[0])
+			err = txRuleError(wire.RejectInvalid, str)
+		}
+		if err != nil {
+			txResult.Err = err
+			result.Err = err
+			break
+		}
+
+		pkg.txns[*tx.Hash()] = tx
+		txResult.Fee = r.TxFee
+		txResult.EffectiveFeeRate = (int64(r.TxFee) +
+			mp.feeDeltas[*tx.Hash()]) * 1000 / r.TxSize
+		txResult.EffectiveIncludes = []*btcutil.Tx{tx}
+	}
+
+	return result, nil
+}
+
+// addReconsiderable keeps the passed transaction, which was rejected for
+// insufficient fees, around so it can be accepted along with a child which
+// pays for it once the child arrives.  A random transaction is evicted when the
+// maximum number of such transactions is exceeded.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addReconsiderable(tx *btcutil.Tx) {
+	if len(mp.reconsiderable) >= maxReconsiderableTxns {
+		for hash := range mp.reconsiderable {
+			delete(mp.reconsiderable, hash)
+			break
+		}
+	}
+	mp.reconsiderable[*tx.Hash()] = tx
+}
+
+// acceptWithOrphanChild attempts to accept the passed transaction, which was
+// rejected for insufficient fees on its own, as a package of one parent and
+// one child along with each orphan that spends it until one such package is
+// accepted.  It returns the transactions added to the pool, or nil when no
+// package was accepted.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) acceptWithOrphanChild(parent *btcutil.Tx) []*TxDesc {
+	prevOut := wire.OutPoint{Hash: *parent.Hash()}
+	for txOutIdx := range parent.MsgTx().TxOut {
+		prevOut.Index = uint32(txOutIdx)
+		for _, orphan := range mp.orphansByPrev[prevOut] {
+			result, err := mp.processPackage(
+				[]*btcutil.Tx{parent, orphan},
+			)
+			if err == nil && result.Err == nil {
+				log.Debugf("Accepted transaction %v along with "+
+					"orphan child %v", parent.Hash(),
+					orphan.Hash())
+				return result.AcceptedTxns
+			}
+		}
+	}
+	return nil
+}
+
+// acceptWithReconsiderableParent attempts to accept the passed orphan
+// transaction as a package of one parent and one child along with its missing
+// parent when the parent was rejected for insufficient fees on its own.  It
+// returns the transactions added to the pool, or nil when the package was not
+// accepted.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) acceptWithReconsiderableParent(child *btcutil.Tx,
+	missingParents []*chainhash.Hash) []*TxDesc {
+
+	// Only orphans which miss a single parent form such a package.
+	parentHash := missingParents[0]
+	for _, hash := range missingParents[1:] {
+		if !hash.IsEqual(parentHash) {
+			return nil
+		}
+	}
+	parent, ok := mp.reconsiderable[*parentHash]
+	if !ok {
+		return nil
+	}
+
+	result, err := mp.processPackage([]*btcutil.Tx{parent, child})
+	if err != nil || result.Err != nil {
+		return nil
+	}
+	delete(mp.reconsiderable, *parentHash)
+
+	log.Debugf("Accepted transaction %v along with parent %v", child.Hash(),
+		parentHash)
+	return result.AcceptedTxns
+}
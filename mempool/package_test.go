@@ -0,0 +1,516 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newPackageTestContext returns a test context whose pool rejects transactions
+// which don't pay the minimum relay fee instead of rate limiting them.
+func newPackageTestContext(t *testing.T) *testContext {
+	t.Helper()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	harness.txPool.cfg.Policy.FreeTxRelayLimit = 0
+	return &testContext{t, harness}
+}
+
+// createSignedTx creates a transaction that spends the inputs with the given
+// fee without adding it to the pool.
+func (ctx *testContext) createSignedTx(inputs []spendableOutput,
+	numOutputs uint32, fee btcutil.Amount,
+	signalsReplacement bool) *btcutil.Tx {
+
+	ctx.t.Helper()
+
+	tx, err := ctx.harness.CreateSignedTx(
+		inputs, numOutputs, fee, signalsReplacement,
+	)
+	if err != nil {
+		ctx.t.Fatalf("unable to create transaction: %v", err)
+	}
+	return tx
+}
+
+// msgTxns returns the wire transactions of the passed transactions.
+func msgTxns(txns ...*btcutil.Tx) []*wire.MsgTx {
+	msgTxns := make([]*wire.MsgTx, len(txns))
+	for i, tx := range txns {
+		msgTxns[i] = tx.MsgTx()
+	}
+	return msgTxns
+}
+
+// TestCheckPackageTopology ensures packages which are not a sorted child with
+// its parents, or exceed the package limits, are rejected.
+func TestCheckPackageTopology(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	coinbase := ctx.addCoinbaseTx(2)
+	coinbaseOut0 := txOutToSpendableOut(coinbase, 0)
+	coinbaseOut1 := txOutToSpendableOut(coinbase, 1)
+
+	parent1 := ctx.createSignedTx(
+		[]spendableOutput{coinbaseOut0}, 2, 0, false,
+	)
+	parent2 := ctx.createSignedTx(
+		[]spendableOutput{coinbaseOut1}, 1, 0, false,
+	)
+	child := ctx.createSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent1, 0),
+		txOutToSpendableOut(parent2, 0),
+	}, 1, 1000, false)
+	conflict := ctx.createSignedTx(
+		[]spendableOutput{coinbaseOut0}, 1, 0, false,
+	)
+	unrelated := ctx.createSignedTx(
+		[]spendableOutput{txOutToSpendableOut(parent1, 1)}, 1, 0, false,
+	)
+
+	tooMany := make([]*btcutil.Tx, MaxPackageCount+1)
+	for i := range tooMany {
+		tooMany[i] = parent1
+	}
+
+	tests := []struct {
+		name string
+		txns []*btcutil.Tx
+		err  string
+	}{
+		{
+			name: "single transaction",
+			txns: []*btcutil.Tx{child},
+		},
+		{
+			name: "child with parents",
+			txns: []*btcutil.Tx{parent1, parent2, child},
+		},
+		{
+			name: "empty",
+			err:  "package is empty",
+		},
+		{
+			name: "too many transactions",
+			txns: tooMany,
+			err:  "too many transactions",
+		},
+		{
+			name: "duplicate transaction",
+			txns: []*btcutil.Tx{parent1, parent1, child},
+			err:  "duplicate transaction",
+		},
+		{
+			name: "not sorted",
+			txns: []*btcutil.Tx{parent1, child, parent2},
+			err:  "not sorted",
+		},
+		{
+			name: "conflicting transactions",
+			txns: []*btcutil.Tx{parent1, conflict, child},
+			err:  "conflicting transactions",
+		},
+		{
+			name: "not a parent of the child",
+			txns: []*btcutil.Tx{parent1, parent2, unrelated},
+			err:  "not a child with its parents",
+		},
+	}
+
+	for _, test := range tests {
+		err := checkPackageTopology(test.txns)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v",
+				test.name, test.err, err)
+		}
+	}
+}
+
+// TestProcessPackage ensures packages are accepted based on the fee rate of
+// the package when their transactions are not acceptable on their own.
+func TestProcessPackage(t *testing.T) {
+	t.Parallel()
+
+	const childFee = 20000
+
+	t.Run("child pays for parent", func(t *testing.T) {
+		ctx := newPackageTestContext(t)
+		coinbase := ctx.addCoinbaseTx(1)
+		parent := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}, 1, 0, false)
+		child := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, childFee, false)
+
+		// The parent is not acceptable on its own.
+		_, err := ctx.harness.txPool.ProcessTransaction(
+			parent, false, false, 0,
+		)
+		if !isReconsiderable(err) {
+			t.Fatalf("expected insufficient fee error, got %v", err)
+		}
+
+		result, err := ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent, child),
+		)
+		if err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v", err)
+		}
+		if result.Err != nil {
+			t.Fatalf("package was rejected: %v", result.Err)
+		}
+		testPoolMembership(ctx, parent, false, true)
+		testPoolMembership(ctx, child, false, true)
+
+		if len(result.AcceptedTxns) != 2 ||
+			!result.AcceptedTxns[0].Tx.Hash().IsEqual(parent.Hash()) ||
+			!result.AcceptedTxns[1].Tx.Hash().IsEqual(child.Hash()) {
+
+			t.Fatalf("unexpected accepted transactions: %v",
+				result.AcceptedTxns)
+		}
+
+		pkgSize := GetTxVirtualSize(parent) + GetTxVirtualSize(child)
+		wantRate := int64(childFee) * 1000 / pkgSize
+		for i, txResult := range result.TxResults {
+			if txResult.Err != nil || txResult.AlreadyInPool {
+				t.Fatalf("tx %d: unexpected result %+v", i,
+					txResult)
+			}
+			if txResult.EffectiveFeeRate != wantRate {
+				t.Fatalf("tx %d: effective fee rate %d, want %d",
+					i, txResult.EffectiveFeeRate, wantRate)
+			}
+			if len(txResult.EffectiveIncludes) != 2 {
+				t.Fatalf("tx %d: effective fee rate includes "+
+					"%d transactions", i,
+					len(txResult.EffectiveIncludes))
+			}
+		}
+		if result.TxResults[1].Fee != childFee {
+			t.Fatalf("child fee %v, want %v", result.TxResults[1].Fee,
+				childFee)
+		}
+
+		// Processing the package again reports both transactions as
+		// already in the pool.
+		result, err = ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent, child),
+		)
+		if err != nil || result.Err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v %v", err,
+				result.Err)
+		}
+		for i, txResult := range result.TxResults {
+			if !txResult.AlreadyInPool {
+				t.Fatalf("tx %d: not reported as already in pool",
+					i)
+			}
+		}
+	})
+
+	t.Run("package fee too low", func(t *testing.T) {
+		ctx := newPackageTestContext(t)
+		coinbase := ctx.addCoinbaseTx(1)
+		parent := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}, 1, 0, false)
+		child := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, 250, false)
+
+		result, err := ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent, child),
+		)
+		if err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v", err)
+		}
+		if !isReconsiderable(result.Err) {
+			t.Fatalf("expected insufficient fee error, got %v",
+				result.Err)
+		}
+		for i, txResult := range result.TxResults {
+			if txResult.Err == nil {
+				t.Fatalf("tx %d: missing error", i)
+			}
+		}
+		testPoolMembership(ctx, parent, false, false)
+		testPoolMembership(ctx, child, false, false)
+	})
+
+	t.Run("parent accepted on its own", func(t *testing.T) {
+		ctx := newPackageTestContext(t)
+		coinbase := ctx.addCoinbaseTx(1)
+		parent := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}, 1, childFee, false)
+		child := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, 0, false)
+
+		// The parent pays for itself, so it is accepted even though
+		// the child which doesn't pay any fees is rejected.
+		result, err := ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent, child),
+		)
+		if err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v", err)
+		}
+		if result.Err == nil || result.TxResults[0].Err != nil ||
+			result.TxResults[1].Err == nil {
+
+			t.Fatalf("unexpected results: %v %v %v", result.Err,
+				result.TxResults[0].Err, result.TxResults[1].Err)
+		}
+		if len(result.TxResults[0].EffectiveIncludes) != 1 {
+			t.Fatal("parent fee rate includes other transactions")
+		}
+		testPoolMembership(ctx, parent, false, true)
+		testPoolMembership(ctx, child, false, false)
+	})
+
+	t.Run("invalid child", func(t *testing.T) {
+		ctx := newPackageTestContext(t)
+		coinbase := ctx.addCoinbaseTx(1)
+		parent := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}, 1, 0, false)
+		child := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, childFee, false)
+		child.MsgTx().TxIn[0].SignatureScript = nil
+		child = btcutil.NewTx(child.MsgTx())
+
+		result, err := ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent, child),
+		)
+		if err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v", err)
+		}
+		if result.Err == nil || isReconsiderable(result.Err) {
+			t.Fatalf("expected script error, got %v", result.Err)
+		}
+		testPoolMembership(ctx, parent, false, false)
+		testPoolMembership(ctx, child, false, false)
+	})
+
+	t.Run("package replacement", func(t *testing.T) {
+		ctx := newPackageTestContext(t)
+		coinbase := ctx.addCoinbaseTx(1)
+		coinbaseOuts := []spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}
+		original := ctx.addSignedTx(coinbaseOuts, 1, 1000, true, false)
+
+		// The parent of the package pays less than the transaction it
+		// replaces, which is paid for by the child.
+		parent := ctx.createSignedTx(coinbaseOuts, 2, 0, true)
+		child := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, childFee, false)
+
+		_, err := ctx.harness.txPool.ProcessTransaction(
+			parent, false, false, 0,
+		)
+		if !isReconsiderable(err) {
+			t.Fatalf("expected insufficient fee error, got %v", err)
+		}
+
+		result, err := ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent, child),
+		)
+		if err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v", err)
+		}
+		if result.Err != nil {
+			t.Fatalf("package was rejected: %v", result.Err)
+		}
+		if len(result.ReplacedTxns) != 1 ||
+			!result.ReplacedTxns[0].Hash().IsEqual(original.Hash()) {
+
+			t.Fatalf("unexpected replaced transactions: %v",
+				result.ReplacedTxns)
+		}
+		testPoolMembership(ctx, original, false, false)
+		testPoolMembership(ctx, parent, false, true)
+		testPoolMembership(ctx, child, false, true)
+
+		// Packages of more than one parent may not replace
+		// transactions.
+		coinbase = ctx.addCoinbaseTx(2)
+		coinbaseOut0 := txOutToSpendableOut(coinbase, 0)
+		ctx.addSignedTx(
+			[]spendableOutput{coinbaseOut0}, 1, 1000, true, false,
+		)
+		parent1 := ctx.createSignedTx(
+			[]spendableOutput{coinbaseOut0}, 1, 0, true,
+		)
+		parent2 := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 1),
+		}, 1, 0, true)
+		child = ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent1, 0),
+			txOutToSpendableOut(parent2, 0),
+		}, 1, childFee, false)
+		result, err = ctx.harness.txPool.ProcessPackage(
+			msgTxns(parent1, parent2, child),
+		)
+		if err != nil {
+			t.Fatalf("ProcessPackage: unexpected error: %v", err)
+		}
+		if result.Err == nil ||
+			!strings.Contains(result.Err.Error(), "one parent") {
+
+			t.Fatalf("expected package replacement error, got %v",
+				result.Err)
+		}
+	})
+}
+
+// TestOpportunisticPackage ensures a parent which was rejected for insufficient
+// fees is accepted along with an orphan child which pays for it regardless of
+// the order they arrive in.
+func TestOpportunisticPackage(t *testing.T) {
+	t.Parallel()
+
+	for _, parentFirst := range []bool{true, false} {
+		ctx := newPackageTestContext(t)
+		coinbase := ctx.addCoinbaseTx(1)
+		parent := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}, 1, 0, false)
+		child := ctx.createSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, 20000, false)
+
+		first, second := parent, child
+		if !parentFirst {
+			first, second = child, parent
+		}
+
+		acceptedTxns, err := ctx.harness.txPool.ProcessTransaction(
+			first, true, false, 0,
+		)
+		if len(acceptedTxns) != 0 {
+			t.Fatalf("parentFirst=%v: first transaction was accepted",
+				parentFirst)
+		}
+		if parentFirst && !isReconsiderable(err) {
+			t.Fatalf("expected insufficient fee error, got %v", err)
+		}
+		if !parentFirst && err != nil {
+			t.Fatalf("unexpected error for orphan: %v", err)
+		}
+
+		acceptedTxns, err = ctx.harness.txPool.ProcessTransaction(
+			second, true, false, 0,
+		)
+		if err != nil {
+			t.Fatalf("parentFirst=%v: unexpected error: %v",
+				parentFirst, err)
+		}
+		if len(acceptedTxns) != 2 ||
+			!acceptedTxns[0].Tx.Hash().IsEqual(parent.Hash()) ||
+			!acceptedTxns[1].Tx.Hash().IsEqual(child.Hash()) {
+
+			t.Fatalf("parentFirst=%v: unexpected accepted "+
+				"transactions %v", parentFirst, acceptedTxns)
+		}
+		testPoolMembership(ctx, parent, false, true)
+		testPoolMembership(ctx, child, false, true)
+	}
+}
+
+// TestCheckPackageAcceptance ensures transactions are tested as a package
+// without being added to the pool, with each transaction paying its own fees
+// and spending the outputs of the transactions that precede it.
+func TestCheckPackageAcceptance(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.createSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 1000, false)
+	child := ctx.createSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 1000, false)
+	freeParent := ctx.createSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 0, false)
+	freeChild := ctx.createSignedTx([]spendableOutput{
+		txOutToSpendableOut(freeParent, 0),
+	}, 1, 20000, false)
+
+	// The child may spend the output of its parent in the package.
+	txPool := ctx.harness.txPool
+	result, err := txPool.CheckPackageAcceptance(
+		[]*btcutil.Tx{parent, child},
+	)
+	if err != nil {
+		t.Fatalf("CheckPackageAcceptance: unexpected error: %v", err)
+	}
+	if result.Err != nil || len(result.TxResults) != 2 {
+		t.Fatalf("package was rejected: %v", result.Err)
+	}
+	for i, txResult := range result.TxResults {
+		if txResult.Err != nil || txResult.Fee != 1000 {
+			t.Fatalf("tx %d: unexpected result %+v", i, txResult)
+		}
+		wantRate := 1000 * 1000 / txResult.VSize
+		if txResult.EffectiveFeeRate != wantRate {
+			t.Fatalf("tx %d: effective fee rate %d, want %d", i,
+				txResult.EffectiveFeeRate, wantRate)
+		}
+	}
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+
+	// The child is missing its parent on its own.
+	result, err = txPool.CheckPackageAcceptance([]*btcutil.Tx{child})
+	if err != nil {
+		t.Fatalf("CheckPackageAcceptance: unexpected error: %v", err)
+	}
+	if result.Err == nil || len(result.TxResults[0].MissingParents) != 1 {
+		t.Fatalf("child without parent was not rejected as missing "+
+			"inputs: %v", result.Err)
+	}
+
+	// A child doesn't pay for its parent, and the evaluation stops at the
+	// parent.
+	result, err = txPool.CheckPackageAcceptance(
+		[]*btcutil.Tx{freeParent, freeChild},
+	)
+	if err != nil {
+		t.Fatalf("CheckPackageAcceptance: unexpected error: %v", err)
+	}
+	if !isReconsiderable(result.Err) || len(result.TxResults) != 1 {
+		t.Fatalf("expected insufficient fee error for the parent "+
+			"only, got %v for %d transactions", result.Err,
+			len(result.TxResults))
+	}
+
+	// Packages which are not sorted are rejected.
+	_, err = txPool.CheckPackageAcceptance([]*btcutil.Tx{child, parent})
+	if err == nil || !strings.Contains(err.Error(), "not sorted") {
+		t.Fatalf("expected unsorted package error, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// modifiedFeeRate returns the fee rate in satoshi per kvB of the passed
+// transaction including its fee delta.
+func modifiedFeeRate(txD *TxDesc) int64 {
+	return (txD.Fee + txD.FeeDelta) * 1000 / GetTxVirtualSize(txD.Tx)
+}
+
+// PrioritiseTransaction adds the passed fee delta in satoshi to the fee delta
+// of the transaction with the passed hash and returns the resulting fee delta.
+// The fee delta is a virtual fee which is added to the fee the transaction
+// actually pays by the fee policies of the pool, such as the minimum relay fee,
+// replacements and eviction, and when selecting transactions for new blocks.
+// Negative deltas deprioritize transactions.
+//
+// Deltas may be set for transactions which are not in the pool, in which case
+// they apply once the transactions are accepted.  They are kept until the
+// transactions are confirmed.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PrioritiseTransaction(hash *chainhash.Hash,
+	feeDelta int64) int64 {
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	total := mp.feeDeltas[*hash] + feeDelta
+	if total == 0 {
+		delete(mp.feeDeltas, *hash)
+	} else {
+		mp.feeDeltas[*hash] = total
+	}
+
+	// Replace the descriptor of the transaction when it is in the pool
+	// rather than modifying it, since the descriptors handed out by
+	// MiningDescs are read without holding the lock.
+	if txD, ok := mp.pool[*hash]; ok {
+		prioritised := *txD
+		prioritised.FeeDelta = total
+		mp.pool[*hash] = &prioritised
+		mp.updateDescendantEntries(prioritised.Tx)
+		atomic.StoreInt64(&mp.lastUpdated, time.Now().UnixNano())
+	}
+
+	log.Debugf("Set fee delta of transaction %v to %d", hash, total)
+
+	return total
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestPrioritiseTransaction ensures fee deltas set for transactions apply to
+// the fee policies of the pool and the mining descriptors, whether they are set
+// before or after the transactions enter the pool, and are cleared once the
+// transactions are confirmed.
+func TestPrioritiseTransaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := newPackageTestContext(t)
+	pool := ctx.harness.txPool
+	coinbase := ctx.addCoinbaseTx(2)
+	output := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+
+	// A transaction which doesn't pay the minimum relay fee is rejected
+	// without a fee delta.
+	tx := ctx.createSignedTx(output(0), 1, 0, false)
+	_, err := pool.ProcessTransaction(tx, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("expected free transaction to be rejected, got %v", err)
+	}
+
+	// Deltas set before the transaction arrives accumulate and make it
+	// acceptable.
+	if delta := pool.PrioritiseTransaction(tx.Hash(), 500); delta != 500 {
+		t.Fatalf("unexpected fee delta: got %d, want 500", delta)
+	}
+	if delta := pool.PrioritiseTransaction(tx.Hash(), 1500); delta != 2000 {
+		t.Fatalf("unexpected fee delta: got %d, want 2000", delta)
+	}
+	if _, err := pool.ProcessTransaction(tx, false, false, 0); err != nil {
+		t.Fatalf("unable to accept prioritised transaction: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, true)
+
+	// The delta is reported along with the actual fee and changes for
+	// transactions of the pool, which bumps the last update time.
+	miningDesc := func() (int64, int64) {
+		for _, desc := range pool.MiningDescs() {
+			if *desc.Tx.Hash() == *tx.Hash() {
+				return desc.Fee, desc.FeeDelta
+			}
+		}
+		t.Fatalf("transaction %v is not in the mining descriptors",
+			tx.Hash())
+		return 0, 0
+	}
+	if fee, delta := miningDesc(); fee != 0 || delta != 2000 {
+		t.Fatalf("unexpected fee %d and delta %d, want 0 and 2000",
+			fee, delta)
+	}
+	lastUpdated := pool.LastUpdated()
+	pool.PrioritiseTransaction(tx.Hash(), -3000)
+	if fee, delta := miningDesc(); fee != 0 || delta != -1000 {
+		t.Fatalf("unexpected fee %d and delta %d, want 0 and -1000",
+			fee, delta)
+	}
+	if !pool.LastUpdated().After(lastUpdated) {
+		t.Fatal("fee delta change did not update the pool")
+	}
+	entry, err := pool.MempoolEntry(tx.Hash())
+	if err != nil {
+		t.Fatalf("unable to fetch mempool entry: %v", err)
+	}
+	if entry.Fees.Base != 0 || entry.Fees.Modified != -0.00001 {
+		t.Fatalf("unexpected base fee %v and modified fee %v",
+			entry.Fees.Base, entry.Fees.Modified)
+	}
+
+	// Deltas which cancel out are forgotten.
+	pool.PrioritiseTransaction(tx.Hash(), 1000)
+	if _, ok := pool.feeDeltas[*tx.Hash()]; ok {
+		t.Fatal("zero fee delta was not removed")
+	}
+
+	// Confirming the transaction clears its delta.
+	pool.PrioritiseTransaction(tx.Hash(), 1000)
+	pool.RemoveConfirmedTransaction(tx)
+	testPoolMembership(ctx, tx, false, false)
+	if _, ok := pool.feeDeltas[*tx.Hash()]; ok {
+		t.Fatal("fee delta of confirmed transaction was not removed")
+	}
+}
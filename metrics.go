@@ -0,0 +1,196 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+
+	"github.com/btcsuite/btcd/metrics"
+)
+
+var (
+	// rpcCallDuration measures the latency of the requests served by the
+	// RPC server per method.
+	rpcCallDuration = metrics.DefaultRegistry.NewHistogramVec(
+		"btcd_rpc_call_duration_seconds",
+		"Time taken to serve JSON-RPC requests.", "method",
+		rpcLatencyBucketSeconds())
+
+	// rpcCallErrors counts the requests served by the RPC server which
+	// failed per method.
+	rpcCallErrors = metrics.DefaultRegistry.NewCounterVec(
+		"btcd_rpc_call_errors_total",
+		"JSON-RPC requests which returned an error.", "method")
+)
+
+// rpcLatencyBucketSeconds returns the bounds of the RPC latency buckets in
+// seconds.
+func rpcLatencyBucketSeconds() []float64 {
+	bounds := make([]float64, len(rpcLatencyBuckets))
+	for i, bound := range rpcLatencyBuckets {
+		bounds[i] = bound.Seconds()
+	}
+	return bounds
+}
+
+// registerMetrics registers the metrics which are read from the state of the
+// server each time the metrics are scraped with the default registry.
+func (s *server) registerMetrics() {
+	r := metrics.DefaultRegistry
+
+	// Chain.
+	r.NewGaugeFunc("btcd_chain_height",
+		"Height of the best block of the main chain.",
+		func() float64 {
+			return float64(s.chain.BestSnapshot().Height)
+		})
+	r.NewGaugeFunc("btcd_header_height",
+		"Height of the best known block header.",
+		func() float64 {
+			return float64(s.syncManager.HeaderHeight())
+		})
+
+	// Script validation.
+	r.NewGaugeFunc("btcd_script_validation_workers",
+		"Maximum number of goroutines validating the scripts of a block.",
+		func() float64 {
+			return float64(s.chain.ScriptValidationStats().Workers)
+		})
+	r.NewCounterFunc("btcd_script_validation_inputs_total",
+		"Inputs whose scripts were validated while connecting blocks.",
+		func() float64 {
+			return float64(s.chain.ScriptValidationStats().Inputs)
+		})
+	r.NewCounterFunc("btcd_script_validation_seconds_total",
+		"Time spent validating scripts while connecting blocks.",
+		func() float64 {
+			return s.chain.ScriptValidationStats().Duration.Seconds()
+		})
+	r.NewGaugeFunc("btcd_script_validation_last_block_seconds",
+		"Time taken to validate the scripts of the last connected block.",
+		func() float64 {
+			stats := s.chain.ScriptValidationStats()
+			return stats.LastBlockDuration.Seconds()
+		})
+	r.NewCounterFunc("btcd_schnorr_batches_total",
+		"Batches of schnorr signatures verified at once.",
+		func() float64 {
+			return float64(s.chain.ScriptValidationStats().SchnorrBatches)
+		})
+	r.NewCounterFunc("btcd_schnorr_batch_signatures_total",
+		"Schnorr signatures verified in batches.",
+		func() float64 {
+			stats := s.chain.ScriptValidationStats()
+			return float64(stats.SchnorrBatchSigs)
+		})
+	r.NewCounterFunc("btcd_schnorr_batch_failures_total",
+		"Batches of schnorr signatures which failed verification.",
+		func() float64 {
+			stats := s.chain.ScriptValidationStats()
+			return float64(stats.SchnorrBatchFailures)
+		})
+
+	// Memory pool.
+	r.NewGaugeFunc("btcd_mempool_transactions",
+		"Number of transactions in the memory pool.",
+		func() float64 {
+			return float64(s.txMemPool.Count())
+		})
+	r.NewGaugeFunc("btcd_mempool_bytes",
+		"Total serialized size of the transactions in the memory pool.",
+		func() float64 {
+			return float64(s.txMemPool.Bytes())
+		})
+	r.NewGaugeFunc("btcd_mempool_usage_bytes",
+		"Memory used by the transactions in the memory pool.",
+		func() float64 {
+			return float64(s.txMemPool.MemoryUsage())
+		})
+
+	// Peers.
+	r.NewGaugeFunc("btcd_peers_inbound",
+		"Number of connected inbound peers.",
+		func() float64 {
+			inbound, _ := s.peerCounts()
+			return float64(inbound)
+		})
+	r.NewGaugeFunc("btcd_peers_outbound",
+		"Number of connected outbound peers.",
+		func() float64 {
+			_, outbound := s.peerCounts()
+			return float64(outbound)
+		})
+	r.NewCounterFunc("btcd_network_received_bytes_total",
+		"Bytes received from peers.",
+		func() float64 {
+			received, _ := s.NetTotals()
+			return float64(received)
+		})
+	r.NewCounterFunc("btcd_network_sent_bytes_total",
+		"Bytes sent to peers.",
+		func() float64 {
+			_, sent := s.NetTotals()
+			return float64(sent)
+		})
+
+	// UTXO cache.
+	r.NewGaugeFunc("btcd_utxo_cache_entries",
+		"Number of entries in the UTXO cache.",
+		func() float64 {
+			return float64(s.chain.UtxoCacheStatus().Entries)
+		})
+	r.NewGaugeFunc("btcd_utxo_cache_bytes",
+		"Memory used by the UTXO cache.",
+		func() float64 {
+			return float64(s.chain.UtxoCacheStatus().MemoryUsage)
+		})
+	r.NewGaugeFunc("btcd_utxo_cache_max_bytes",
+		"Maximum memory the UTXO cache may use before it is flushed.",
+		func() float64 {
+			return float64(s.chain.UtxoCacheStatus().MaxMemoryUsage)
+		})
+}
+
+// peerCounts returns the number of connected inbound and outbound peers.  It
+// returns zero for both once the server is shutting down.
+func (s *server) peerCounts() (int, int) {
+	reply := make(chan []*serverPeer)
+	select {
+	case s.query <- getPeersMsg{reply: reply}:
+	case <-s.quit:
+		return 0, 0
+	}
+
+	var inbound, outbound int
+	for _, sp := range <-reply {
+		if sp.Inbound() {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+	return inbound, outbound
+}
+
+// setupMetricsListeners returns the listeners of the configured metrics listen
+// addresses.
+func setupMetricsListeners() ([]net.Listener, error) {
+	netAddrs, err := parseListeners(cfg.MetricsListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			mtrcLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
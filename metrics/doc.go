@@ -0,0 +1,28 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package metrics implements a registry of metrics which is served over HTTP in
+the Prometheus text exposition format, so the node can be monitored without
+depending on the Prometheus client libraries.
+
+The registry supports:
+
+  - counters, which only increase, and gauges, which go up and down
+  - counters and gauges whose values are read from a function each time the
+    registry is written, for values which are already tracked elsewhere
+  - histograms sampling observations such as latencies into cumulative
+    buckets
+  - families of counters and histograms partitioned by the value of a label
+
+Packages instrumenting their code register their metrics with DefaultRegistry
+through the package level constructors when they are initialized.  Metrics are
+updated atomically, so they can be updated from any goroutine without locking.
+
+Server serves a registry at /metrics.  Scrapers can be required to send a
+bearer token in the authorization header:
+
+	authorization: Bearer <token>
+*/
+package metrics
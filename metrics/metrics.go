@@ -0,0 +1,534 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRegistry is the registry the package level constructors register
+// their metrics with.  It is the registry served by the node.
+var DefaultRegistry = NewRegistry()
+
+// DefBuckets are the default upper bounds in seconds of the buckets of
+// histograms measuring latencies.
+var DefBuckets = []float64{
+	0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10,
+}
+
+// metric is implemented by the metric families of a registry.
+type metric interface {
+	// name returns the name of the family.
+	name() string
+
+	// write writes the samples of the family in the text exposition
+	// format.
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics which can be written in the Prometheus text
+// exposition format.  Registering a metric with a name which is already taken
+// panics, since metric names are fixed by the code defining them.
+type Registry struct {
+	mtx     sync.Mutex
+	metrics map[string]metric
+	help    map[string]string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+		help:    make(map[string]string),
+	}
+}
+
+// register adds the passed metric to the registry.
+func (r *Registry) register(m metric, help string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.metrics[m.name()]; ok {
+		panic(fmt.Sprintf("metric %q registered twice", m.name()))
+	}
+	r.metrics[m.name()] = m
+	r.help[m.name()] = help
+}
+
+// WriteTo writes all metrics of the registry ordered by name in the Prometheus
+// text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	help := make([]string, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+		help[i] = r.help[name]
+	}
+	r.mtx.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for i, m := range metrics {
+		bw.WriteString("# HELP ")
+		bw.WriteString(names[i])
+		bw.WriteByte(' ')
+		bw.WriteString(helpEscaper.Replace(help[i]))
+		bw.WriteByte('\n')
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written to the wrapped writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes the passed bytes to the wrapped writer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+var (
+	// helpEscaper escapes the help text of a metric.
+	helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+	// labelEscaper escapes label values.
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeType writes the type line of a metric family.
+func writeType(w *bufio.Writer, name, typ string) {
+	w.WriteString("# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(typ)
+	w.WriteByte('\n')
+}
+
+// writeSample writes a sample with an optional label.  The label is omitted
+// when its name is empty.
+func writeSample(w *bufio.Writer, name, label, value string, v float64) {
+	w.WriteString(name)
+	if label != "" {
+		w.WriteByte('{')
+		w.WriteString(label)
+		w.WriteString(`="`)
+		w.WriteString(labelEscaper.Replace(value))
+		w.WriteString(`"}`)
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// atomicFloat is a float64 which can be updated atomically.
+type atomicFloat struct {
+	bits uint64
+}
+
+// load returns the value.
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// store sets the value.
+func (f *atomicFloat) store(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+// add adds the passed delta to the value.
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		v := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&f.bits, old, v) {
+			return
+		}
+	}
+}
+
+// Counter is a metric counting events.  Its value only increases.
+type Counter struct {
+	metricName string
+	value      uint64
+}
+
+// NewCounter registers a new counter with the passed name and help text with
+// the registry.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{metricName: name}
+	r.register(c, help)
+	return c
+}
+
+// NewCounter registers a new counter with the default registry.
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// Inc increments the counter.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add adds the passed value to the counter.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) name() string {
+	return c.metricName
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeType(w, c.metricName, "counter")
+	writeSample(w, c.metricName, "", "", float64(c.Value()))
+}
+
+// Gauge is a metric holding a value which can go up and down.
+type Gauge struct {
+	metricName string
+	value      atomicFloat
+}
+
+// NewGauge registers a new gauge with the passed name and help text with the
+// registry.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{metricName: name}
+	r.register(g, help)
+	return g
+}
+
+// NewGauge registers a new gauge with the default registry.
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// Set sets the gauge to the passed value.
+func (g *Gauge) Set(v float64) {
+	g.value.store(v)
+}
+
+// Add adds the passed delta, which may be negative, to the gauge.
+func (g *Gauge) Add(delta float64) {
+	g.value.add(delta)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+func (g *Gauge) name() string {
+	return g.metricName
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeType(w, g.metricName, "gauge")
+	writeSample(w, g.metricName, "", "", g.Value())
+}
+
+// funcMetric is a counter or gauge whose value is read from a function each
+// time the metric is written.
+type funcMetric struct {
+	metricName string
+	typ        string
+	fn         func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by the passed function
+// with the registry.  The function is called each time the registry is
+// written and must be safe for concurrent access.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{metricName: name, typ: "gauge", fn: fn}, help)
+}
+
+// NewCounterFunc registers a counter whose value is returned by the passed
+// function with the registry.  The function is called each time the registry
+// is written and must be safe for concurrent access.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{metricName: name, typ: "counter", fn: fn}, help)
+}
+
+func (m *funcMetric) name() string {
+	return m.metricName
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	writeType(w, m.metricName, m.typ)
+	writeSample(w, m.metricName, "", "", m.fn())
+}
+
+// Histogram is a metric sampling observations, such as latencies, into
+// buckets.  Buckets are cumulative as required by the exposition format.
+type Histogram struct {
+	metricName string
+	bounds     []float64
+	buckets    []uint64
+	count      uint64
+	sum        atomicFloat
+}
+
+// newHistogram returns a histogram with the passed bucket upper bounds, which
+// must be sorted in increasing order.
+func newHistogram(name string, bounds []float64) *Histogram {
+	if !sort.Float64sAreSorted(bounds) {
+		panic(fmt.Sprintf("buckets of histogram %q are not sorted", name))
+	}
+	return &Histogram{
+		metricName: name,
+		bounds:     bounds,
+		buckets:    make([]uint64, len(bounds)),
+	}
+}
+
+// NewHistogram registers a new histogram with the passed bucket upper bounds
+// with the registry.  DefBuckets is used when the bounds are nil.
+func (r *Registry) NewHistogram(name, help string, bounds []float64) *Histogram {
+	if bounds == nil {
+		bounds = DefBuckets
+	}
+	h := newHistogram(name, bounds)
+	r.register(h, help)
+	return h
+}
+
+// NewHistogram registers a new histogram with the default registry.
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, bounds)
+}
+
+// Observe adds the passed value to the histogram.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	if i < len(h.buckets) {
+		atomic.AddUint64(&h.buckets[i], 1)
+	}
+	h.sum.add(v)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// ObserveSince adds the time elapsed since the passed time in seconds to the
+// histogram.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	writeType(w, h.metricName, "histogram")
+	h.writeSamples(w, "", "")
+}
+
+// writeSamples writes the buckets, sum and count of the histogram with an
+// optional label.
+func (h *Histogram) writeSamples(w *bufio.Writer, label, value string) {
+	// Observations are recorded without a lock, so the count is read
+	// before the buckets to keep the +Inf bucket no smaller than the
+	// others.
+	count := h.Count()
+	var cumulative uint64
+	labels := `le="`
+	if label != "" {
+		labels = label + `="` + labelEscaper.Replace(value) + `",le="`
+	}
+	for i, bound := range h.bounds {
+		cumulative += atomic.LoadUint64(&h.buckets[i])
+		if cumulative > count {
+			cumulative = count
+		}
+		w.WriteString(h.metricName)
+		w.WriteString("_bucket{")
+		w.WriteString(labels)
+		w.WriteString(formatFloat(bound))
+		w.WriteString(`"} `)
+		w.WriteString(strconv.FormatUint(cumulative, 10))
+		w.WriteByte('\n')
+	}
+	w.WriteString(h.metricName)
+	w.WriteString("_bucket{")
+	w.WriteString(labels)
+	w.WriteString(`+Inf"} `)
+	w.WriteString(strconv.FormatUint(count, 10))
+	w.WriteByte('\n')
+	writeSample(w, h.metricName+"_sum", label, value, h.sum.load())
+	writeSample(w, h.metricName+"_count", label, value,
+		float64(count))
+}
+
+// CounterVec is a family of counters partitioned by the value of a label.
+type CounterVec struct {
+	metricName string
+	label      string
+
+	mtx      sync.RWMutex
+	counters map[string]*Counter
+}
+
+// NewCounterVec registers a new family of counters partitioned by the passed
+// label with the registry.
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{
+		metricName: name,
+		label:      label,
+		counters:   make(map[string]*Counter),
+	}
+	r.register(v, help)
+	return v
+}
+
+// With returns the counter for the passed label value, creating it when it
+// doesn't exist.
+func (v *CounterVec) With(value string) *Counter {
+	v.mtx.RLock()
+	c, ok := v.counters[value]
+	v.mtx.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if c, ok := v.counters[value]; ok {
+		return c
+	}
+	c = &Counter{metricName: v.metricName}
+	v.counters[value] = c
+	return c
+}
+
+func (v *CounterVec) name() string {
+	return v.metricName
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	writeType(w, v.metricName, "counter")
+	v.mtx.RLock()
+	values := make([]string, 0, len(v.counters))
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		writeSample(w, v.metricName, v.label, value,
+			float64(v.counters[value].Value()))
+	}
+	v.mtx.RUnlock()
+}
+
+// HistogramVec is a family of histograms partitioned by the value of a label.
+type HistogramVec struct {
+	metricName string
+	label      string
+	bounds     []float64
+
+	mtx        sync.RWMutex
+	histograms map[string]*Histogram
+}
+
+// NewHistogramVec registers a new family of histograms partitioned by the
+// passed label with the registry.  DefBuckets is used when the bounds are nil.
+func (r *Registry) NewHistogramVec(name, help, label string,
+	bounds []float64) *HistogramVec {
+
+	if bounds == nil {
+		bounds = DefBuckets
+	}
+	if !sort.Float64sAreSorted(bounds) {
+		panic(fmt.Sprintf("buckets of histogram %q are not sorted", name))
+	}
+	v := &HistogramVec{
+		metricName: name,
+		label:      label,
+		bounds:     bounds,
+		histograms: make(map[string]*Histogram),
+	}
+	r.register(v, help)
+	return v
+}
+
+// With returns the histogram for the passed label value, creating it when it
+// doesn't exist.
+func (v *HistogramVec) With(value string) *Histogram {
+	v.mtx.RLock()
+	h, ok := v.histograms[value]
+	v.mtx.RUnlock()
+	if ok {
+		return h
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if h, ok := v.histograms[value]; ok {
+		return h
+	}
+	h = newHistogram(v.metricName, v.bounds)
+	v.histograms[value] = h
+	return h
+}
+
+func (v *HistogramVec) name() string {
+	return v.metricName
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	writeType(w, v.metricName, "histogram")
+	v.mtx.RLock()
+	values := make([]string, 0, len(v.histograms))
+	for value := range v.histograms {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		v.histograms[value].writeSamples(w, v.label, value)
+	}
+	v.mtx.RUnlock()
+}
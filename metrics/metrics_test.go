@@ -0,0 +1,117 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// TestRegistryWrite ensures the metrics of a registry are written in the text
+// exposition format ordered by name.
+func TestRegistryWrite(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	counter := r.NewCounter("test_events_total", "Events.")
+	gauge := r.NewGauge("test_level", "Level with a\nnewline.")
+	r.NewGaugeFunc("test_func", "Function.", func() float64 {
+		return math.Inf(1)
+	})
+	histogram := r.NewHistogram("test_duration_seconds", "Duration.",
+		[]float64{0.1, 1})
+	errors := r.NewCounterVec("test_errors_total", "Errors.", "method")
+
+	counter.Inc()
+	counter.Add(2)
+	gauge.Set(1.5)
+	gauge.Add(-3)
+	histogram.Observe(0.05)
+	histogram.Observe(0.1)
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+	errors.With("getblock").Inc()
+	errors.With(`a"b`).Add(2)
+	errors.With("getblock").Inc()
+
+	want := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 2
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 2.65
+test_duration_seconds_count 4
+# HELP test_errors_total Errors.
+# TYPE test_errors_total counter
+test_errors_total{method="a\"b"} 2
+test_errors_total{method="getblock"} 2
+# HELP test_events_total Events.
+# TYPE test_events_total counter
+test_events_total 3
+# HELP test_func Function.
+# TYPE test_func gauge
+test_func +Inf
+# HELP test_level Level with a\nnewline.
+# TYPE test_level gauge
+test_level -1.5
+`
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: unexpected error: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo: got %d bytes, wrote %d", n, buf.Len())
+	}
+}
+
+// TestHistogramVec ensures the histograms of a family are written per label
+// value.
+func TestHistogramVec(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	v := r.NewHistogramVec("test_rpc_seconds", "RPC.", "method",
+		[]float64{1})
+	v.With("b").Observe(2)
+	v.With("a").Observe(0.5)
+
+	want := `# HELP test_rpc_seconds RPC.
+# TYPE test_rpc_seconds histogram
+test_rpc_seconds_bucket{method="a",le="1"} 1
+test_rpc_seconds_bucket{method="a",le="+Inf"} 1
+test_rpc_seconds_sum{method="a"} 0.5
+test_rpc_seconds_count{method="a"} 1
+test_rpc_seconds_bucket{method="b",le="1"} 0
+test_rpc_seconds_bucket{method="b",le="+Inf"} 1
+test_rpc_seconds_sum{method="b"} 2
+test_rpc_seconds_count{method="b"} 1
+`
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRegisterTwice ensures registering two metrics with the same name panics.
+func TestRegisterTwice(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a name twice did not panic")
+		}
+	}()
+	r.NewGauge("test_total", "Test.")
+}
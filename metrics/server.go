@@ -0,0 +1,136 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Path is the path the metrics are served on.
+	Path = "/metrics"
+
+	// contentType is the content type of the text exposition format.
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// readHeaderTimeout is the maximum amount of time a client may take to
+	// send the headers of a request.
+	readHeaderTimeout = 10 * time.Second
+)
+
+// Config is the configuration of a Server.
+type Config struct {
+	// Listeners defines a slice of listeners for which the server will
+	// serve the metrics.
+	Listeners []net.Listener
+
+	// Registry is the registry whose metrics are served.  The default
+	// registry is served when it is nil.
+	Registry *Registry
+
+	// Token is the bearer token clients must send in the authorization
+	// header.  Clients aren't authenticated when it is empty.
+	Token string
+}
+
+// Server serves the metrics of a registry over HTTP for Prometheus to scrape.
+type Server struct {
+	cfg        Config
+	httpServer *http.Server
+	started    int32
+	shutdown   int32
+	wg         sync.WaitGroup
+}
+
+// New returns a new metrics server with the passed configuration.
+func New(cfg *Config) *Server {
+	s := &Server{cfg: *cfg}
+	if s.cfg.Registry == nil {
+		s.cfg.Registry = DefaultRegistry
+	}
+	s.httpServer = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	return s
+}
+
+// Start begins serving the configured listeners.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go func(listener net.Listener) {
+			defer s.wg.Done()
+
+			log.Infof("Metrics server listening on %s", listener.Addr())
+			err := s.httpServer.Serve(listener)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("Metrics server on %s failed: %v",
+					listener.Addr(), err)
+			}
+		}(listener)
+	}
+}
+
+// Stop closes the listeners and waits for the server to finish.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		return
+	}
+
+	log.Infof("Metrics server shutting down")
+	s.httpServer.Close()
+	s.wg.Wait()
+}
+
+// ServeHTTP serves the metrics of the registry in the text exposition format.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Path {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticated(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="btcd"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized),
+			http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := s.cfg.Registry.WriteTo(w); err != nil {
+		log.Debugf("Unable to write metrics to %s: %v", r.RemoteAddr,
+			err)
+	}
+}
+
+// authenticated returns whether the request carries the configured bearer
+// token.
+func (s *Server) authenticated(r *http.Request) bool {
+	if s.cfg.Token == "" {
+		return true
+	}
+	want := []byte("Bearer " + s.cfg.Token)
+	got := []byte(r.Header.Get("Authorization"))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestServeHTTP ensures the metrics are only served at their path to clients
+// sending the configured token.
+func TestServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.").Inc()
+	s := New(&Config{Registry: r, Token: "secret"})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		status int
+	}{{
+		name:   "authenticated",
+		method: http.MethodGet,
+		path:   Path,
+		auth:   "Bearer secret",
+		status: http.StatusOK,
+	}, {
+		name:   "no token",
+		method: http.MethodGet,
+		path:   Path,
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "wrong token",
+		method: http.MethodGet,
+		path:   Path,
+		auth:   "Bearer secreT",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "wrong path",
+		method: http.MethodGet,
+		path:   "/",
+		auth:   "Bearer secret",
+		status: http.StatusNotFound,
+	}, {
+		name:   "wrong method",
+		method: http.MethodPost,
+		path:   Path,
+		auth:   "Bearer secret",
+		status: http.StatusMethodNotAllowed,
+	}}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, w.Code,
+				test.status)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != contentType {
+			t.Errorf("%s: got content type %q, want %q", test.name,
+				got, contentType)
+		}
+		if !strings.Contains(w.Body.String(), "\ntest_total 1\n") {
+			t.Errorf("%s: unexpected body %q", test.name, w.Body)
+		}
+	}
+}
@@ -7,7 +7,6 @@ package cpuminer
 import (
 	"errors"
 	"fmt"
-	"math/rand"
 	"runtime"
 	"sync"
 	"time"
@@ -61,6 +60,11 @@ type Config struct {
 	// blocks.  Each generated block will randomly choose one of them.
 	MiningAddrs []btcutil.Address
 
+	// Payouts is a list of weighted output descriptors to use for the
+	// generated blocks in addition to MiningAddrs, which are each given a
+	// weight of one.  They can be replaced with SetPayouts.
+	Payouts []Payout
+
 	// ProcessBlock defines the function to call with any solved blocks.
 	// It typically must run the provided block through the same set of
 	// rules and handling as any other block coming from the network.
@@ -104,6 +108,11 @@ type CPUMiner struct {
 	updateHashes      chan uint64
 	speedMonitorQuit  chan struct{}
 	quit              chan struct{}
+
+	// payouts holds the payouts the coinbase of generated blocks pays to.
+	// It is protected by the payoutMtx.
+	payoutMtx sync.Mutex
+	payouts   *payoutSet
 }
 
 // speedMonitor handles tracking the number of hashes per second the mining
@@ -334,9 +343,14 @@ out:
 			continue
 		}
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payout at random.
+		payToAddr, err := m.nextPayAddr()
+		if err != nil {
+			m.submitBlockLock.Unlock()
+			log.Errorf("Failed to derive payment address: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
@@ -539,18 +553,16 @@ func (m *CPUMiner) NumWorkers() int32 {
 	return int32(m.numWorkers)
 }
 
-// GenerateNBlocks generates the requested number of blocks. It is self
-// contained in that it creates block templates and attempts to solve them while
-// detecting when it is performing stale work and reacting accordingly by
-// generating a new block template.  When a block is solved, it is submitted.
-// The function returns a list of the hashes of generated blocks.
-func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+// startDiscreteMining marks the miner as running in discrete mode and starts
+// the speed monitor the solving process reports to.  An error is returned when
+// the miner is already running.
+func (m *CPUMiner) startDiscreteMining() error {
 	m.Lock()
+	defer m.Unlock()
 
 	// Respond with an error if server is already mining.
 	if m.started || m.discreteMining {
-		m.Unlock()
-		return nil, errors.New("Server is already CPU mining. Please call " +
+		return errors.New("Server is already CPU mining. Please call " +
 			"`setgenerate 0` before calling discrete `generate` commands.")
 	}
 
@@ -560,13 +572,58 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 	m.speedMonitorQuit = make(chan struct{})
 	m.wg.Add(1)
 	go m.speedMonitor()
+	return nil
+}
 
+// stopDiscreteMining stops the speed monitor and marks the miner as no longer
+// running once discrete mining started by startDiscreteMining is done.
+func (m *CPUMiner) stopDiscreteMining() {
+	m.Lock()
+	close(m.speedMonitorQuit)
+	m.wg.Wait()
+	m.started = false
+	m.discreteMining = false
 	m.Unlock()
+}
+
+// GenerateNBlocks generates the requested number of blocks. It is self
+// contained in that it creates block templates and attempts to solve them while
+// detecting when it is performing stale work and reacting accordingly by
+// generating a new block template.  When a block is solved, it is submitted.
+// The function returns a list of the hashes of generated blocks.
+func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, nil)
+}
+
+// GenerateNBlocksToAddress generates the requested number of blocks in the same
+// way as GenerateNBlocks, except the coinbase of each block pays to the passed
+// address instead of one of the configured payouts.
+func (m *CPUMiner) GenerateNBlocksToAddress(n uint32,
+	payToAddr btcutil.Address) ([]*chainhash.Hash, error) {
+
+	if payToAddr == nil {
+		return nil, errors.New("no payment address provided")
+	}
+	return m.generateNBlocks(n, payToAddr)
+}
+
+// generateNBlocks generates the requested number of blocks paying to the passed
+// address, or to randomly chosen payouts when it is nil.
+func (m *CPUMiner) generateNBlocks(n uint32,
+	payToAddr btcutil.Address) ([]*chainhash.Hash, error) {
+
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
+	defer m.stopDiscreteMining()
 
 	log.Tracef("Generating %d blocks", n)
 
 	i := uint32(0)
 	blockHashes := make([]*chainhash.Hash, n)
+	if n == 0 {
+		return blockHashes, nil
+	}
 
 	// Start a ticker which is used to signal checks for stale work and
 	// updates to the speed monitor.
@@ -588,14 +645,21 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payout at random unless an address was provided.
+		addr := payToAddr
+		if addr == nil {
+			var err error
+			addr, err = m.nextPayAddr()
+			if err != nil {
+				m.submitBlockLock.Unlock()
+				return nil, err
+			}
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := m.g.NewBlockTemplate(addr)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
@@ -615,23 +679,151 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 			i++
 			if i == n {
 				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
 				return blockHashes, nil
 			}
 		}
 	}
 }
 
+// GenerateBlock generates a single block which contains exactly the passed
+// transactions, in the passed order, after a coinbase paying to the provided
+// address.  The transactions don't need to be in the memory pool, however the
+// resulting block must be valid under the consensus rules, otherwise an error
+// describing the violation is returned.  The hash of the generated block is
+// returned once it has been accepted by the chain.
+func (m *CPUMiner) GenerateBlock(payToAddr btcutil.Address,
+	txs []*btcutil.Tx) (*chainhash.Hash, error) {
+
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
+	defer m.stopDiscreteMining()
+
+	ticker := time.NewTicker(time.Second * hashUpdateSecs)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.updateNumWorkers:
+		default:
+		}
+
+		// Build the block on the current best chain.  Unlike with
+		// templates built from the memory pool, failing to create the
+		// template is reported since it means the requested block is
+		// invalid.
+		m.submitBlockLock.Lock()
+		template, err := m.g.NewBlockTemplateWithTxs(payToAddr, txs)
+		m.submitBlockLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		// Start over with a new template when the block became stale
+		// while it was being solved.
+		if !m.solveBlock(template.Block, template.Height, ticker, nil) {
+			continue
+		}
+		block := btcutil.NewBlock(template.Block)
+		if !m.submitBlock(block) {
+			if !template.Block.Header.PrevBlock.IsEqual(
+				&m.g.BestSnapshot().Hash) {
+
+				continue
+			}
+			return nil, fmt.Errorf("generated block %v was "+
+				"rejected", block.Hash())
+		}
+		return block.Hash(), nil
+	}
+}
+
+// configuredPayouts returns the valid payouts of the configuration, which are
+// made up of the mining addresses followed by the payouts.
+func (m *CPUMiner) configuredPayouts() []Payout {
+	params := m.cfg.ChainParams
+	payouts := make([]Payout, 0, len(m.cfg.MiningAddrs)+len(m.cfg.Payouts))
+	for _, addr := range m.cfg.MiningAddrs {
+		payout, err := addressPayout(addr, params)
+		if err != nil {
+			log.Warnf("Ignoring mining address %v: %v", addr, err)
+			continue
+		}
+		payouts = append(payouts, payout)
+	}
+	for _, payout := range m.cfg.Payouts {
+		if err := payout.validate(params); err != nil {
+			log.Warnf("Ignoring payout %v: %v", &payout, err)
+			continue
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts
+}
+
+// nextPayAddr returns the address to pay the coinbase of the next generated
+// block to, or nil when there are no payouts.
+//
+// This function is safe for concurrent access.
+func (m *CPUMiner) nextPayAddr() (btcutil.Address, error) {
+	m.payoutMtx.Lock()
+	defer m.payoutMtx.Unlock()
+
+	return m.payouts.choose()
+}
+
+// SetPayouts replaces the payouts the coinbase of generated blocks pays to,
+// including the ones of the configuration, with the passed payouts.  Passing
+// no payouts restores the payouts of the configuration.  An error is returned
+// when any of the payouts is invalid, in which case the payouts are unchanged.
+// Ranged descriptors start over at the first derivation index.
+//
+// This function is safe for concurrent access.
+func (m *CPUMiner) SetPayouts(payouts []Payout) error {
+	if len(payouts) == 0 {
+		payouts = m.configuredPayouts()
+	}
+	set, err := newPayoutSet(payouts, m.cfg.ChainParams)
+	if err != nil {
+		return err
+	}
+
+	m.payoutMtx.Lock()
+	m.payouts = set
+	m.payoutMtx.Unlock()
+
+	log.Infof("CPU miner paying to %d payouts", len(payouts))
+	return nil
+}
+
+// Payouts returns the payouts the coinbase of generated blocks pays to.
+//
+// This function is safe for concurrent access.
+func (m *CPUMiner) Payouts() []Payout {
+	m.payoutMtx.Lock()
+	defer m.payoutMtx.Unlock()
+
+	payouts := make([]Payout, len(m.payouts.payouts))
+	copy(payouts, m.payouts.payouts)
+	return payouts
+}
+
+// HasPayouts returns whether there are any payouts the coinbase of generated
+// blocks can pay to, which is required to generate blocks.
+//
+// This function is safe for concurrent access.
+func (m *CPUMiner) HasPayouts() bool {
+	m.payoutMtx.Lock()
+	defer m.payoutMtx.Unlock()
+
+	return len(m.payouts.payouts) != 0
+}
+
 // New returns a new instance of a CPU miner for the provided configuration.
 // Use Start to begin the mining process.  See the documentation for CPUMiner
 // type for more details.
 func New(cfg *Config) *CPUMiner {
-	return &CPUMiner{
+	m := &CPUMiner{
 		g:                 cfg.BlockTemplateGenerator,
 		cfg:               *cfg,
 		numWorkers:        defaultNumWorkers,
@@ -639,4 +831,7 @@ func New(cfg *Config) *CPUMiner {
 		queryHashesPerSec: make(chan float64),
 		updateHashes:      make(chan uint64),
 	}
+	// The configured payouts are valid, so creating the set can't fail.
+	m.payouts, _ = newPayoutSet(m.configuredPayouts(), cfg.ChainParams)
+	return m
 }
@@ -0,0 +1,164 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cpuminer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Payout is an output descriptor the coinbase of generated blocks pays to along
+// with its weight.  Each generated block pays to one of the payouts chosen at
+// random with a probability proportional to its weight.  Ranged descriptors
+// pay to the script derived at the next index each time they are chosen.
+type Payout struct {
+	Descriptor *descriptor.Descriptor
+	Weight     uint32
+}
+
+// String returns the payout in the form accepted by ParsePayout.
+func (p *Payout) String() string {
+	return fmt.Sprintf("%s@%d", p.Descriptor, p.Weight)
+}
+
+// ParsePayout parses a payout in the form DESCRIPTOR[@WEIGHT], where the weight
+// defaults to 1.  The descriptor must be for the passed network and pay to a
+// script the coinbase can pay to, which excludes bare multisig and raw scripts
+// without an address.
+func ParsePayout(s string, params *chaincfg.Params) (*Payout, error) {
+	desc, weight := s, uint64(1)
+	if i := strings.LastIndexByte(s, '@'); i >= 0 {
+		var err error
+		desc = s[:i]
+		weight, err = strconv.ParseUint(s[i+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q", s[i+1:])
+		}
+	}
+	d, err := descriptor.Parse(desc, false, params)
+	if err != nil {
+		return nil, err
+	}
+	payout := &Payout{Descriptor: d, Weight: uint32(weight)}
+	if err := payout.validate(params); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// validate returns an error when the payout has no weight or its descriptor
+// can't be paid to.
+func (p *Payout) validate(params *chaincfg.Params) error {
+	if p.Weight == 0 {
+		return errors.New("payout weight must be positive")
+	}
+	_, err := payoutAddress(p.Descriptor, 0, params)
+	return err
+}
+
+// payoutAddress returns the address the coinbase pays to for the passed
+// descriptor at the passed derivation index.  Pay-to-pubkey scripts don't have
+// an address in the usual sense, but can still be paid to.
+func payoutAddress(d *descriptor.Descriptor, index uint32,
+	params *chaincfg.Params) (btcutil.Address, error) {
+
+	addr, err := d.Address(index)
+	if !errors.Is(err, descriptor.ErrNoAddress) {
+		return addr, err
+	}
+
+	script, err := d.Script(index)
+	if err != nil {
+		return nil, err
+	}
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(script, params)
+	if err != nil {
+		return nil, err
+	}
+	if class != txscript.PubKeyTy || len(addrs) != 1 {
+		return nil, descriptor.ErrNoAddress
+	}
+	return addrs[0], nil
+}
+
+// addressPayout returns a payout with a weight of one for the passed address.
+// Public keys are paid to directly rather than to their hash.
+func addressPayout(addr btcutil.Address, params *chaincfg.Params) (Payout, error) {
+	desc := "addr(" + addr.EncodeAddress() + ")"
+	if pk, ok := addr.(*btcutil.AddressPubKey); ok {
+		desc = "pk(" + hex.EncodeToString(pk.ScriptAddress()) + ")"
+	}
+	d, err := descriptor.Parse(desc, false, params)
+	if err != nil {
+		return Payout{}, err
+	}
+	return Payout{Descriptor: d, Weight: 1}, nil
+}
+
+// payoutSet holds the payouts of the miner along with the next derivation index
+// of the ranged ones.  It is not safe for concurrent access.
+type payoutSet struct {
+	params      *chaincfg.Params
+	rand        *rand.Rand
+	payouts     []Payout
+	next        []uint32
+	totalWeight uint64
+}
+
+// newPayoutSet returns a payout set for the passed payouts after validating
+// them against the passed network.
+func newPayoutSet(payouts []Payout, params *chaincfg.Params) (*payoutSet, error) {
+	set := &payoutSet{
+		params:  params,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		payouts: make([]Payout, len(payouts)),
+		next:    make([]uint32, len(payouts)),
+	}
+	for i := range payouts {
+		if err := payouts[i].validate(params); err != nil {
+			return nil, err
+		}
+		set.payouts[i] = payouts[i]
+		set.totalWeight += uint64(payouts[i].Weight)
+	}
+	return set, nil
+}
+
+// choose returns the address to pay the coinbase of the next block to, or nil
+// when the set is empty.  The payout is chosen at random according to the
+// weights, and ranged descriptors advance to their next derivation index.
+func (s *payoutSet) choose() (btcutil.Address, error) {
+	if s.totalWeight == 0 {
+		return nil, nil
+	}
+
+	n := uint64(s.rand.Int63n(int64(s.totalWeight)))
+	i := 0
+	for ; n >= uint64(s.payouts[i].Weight); i++ {
+		n -= uint64(s.payouts[i].Weight)
+	}
+
+	payout := &s.payouts[i]
+	if !payout.Descriptor.IsRange() {
+		return payoutAddress(payout.Descriptor, 0, s.params)
+	}
+	addr, err := payoutAddress(payout.Descriptor, s.next[i], s.params)
+	s.next[i]++
+	if s.next[i] >= hdkeychain.HardenedKeyStart {
+		s.next[i] = 0
+	}
+	return addr, err
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cpuminer
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// testXpub is the master public key of test vector 1 of BIP 32.
+	testXpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGheP" +
+		"Y2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+
+	// testPubKey is the public key of the private key 1.
+	testPubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815" +
+		"b16f81798"
+)
+
+// TestParsePayout ensures payouts are parsed along with their weights and only
+// accepted when the coinbase can pay to their descriptors.
+func TestParsePayout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		payout string
+		weight uint32
+		script string
+		valid  bool
+	}{{
+		payout: "addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)",
+		weight: 1,
+		script: "76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac",
+		valid:  true,
+	}, {
+		payout: "tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)@5",
+		weight: 5,
+		script: "512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11",
+		valid:  true,
+	}, {
+		payout: "pk(" + testPubKey + ")@2",
+		weight: 2,
+		script: "21" + testPubKey + "ac",
+		valid:  true,
+	}, {
+		payout: "multi(1," + testPubKey + ")",
+	}, {
+		payout: "raw(deadbeef)",
+	}, {
+		payout: "addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)@0",
+	}, {
+		payout: "addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)@x",
+	}, {
+		payout: "addr(mkusNZtZdRs1gsMmXmjojGSYwhYbW6WKp4)",
+	}}
+
+	for _, test := range tests {
+		payout, err := ParsePayout(test.payout, &chaincfg.MainNetParams)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("ParsePayout(%q): expected error",
+					test.payout)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePayout(%q): unexpected error: %v",
+				test.payout, err)
+			continue
+		}
+		if payout.Weight != test.weight {
+			t.Errorf("ParsePayout(%q): got weight %d, want %d",
+				test.payout, payout.Weight, test.weight)
+		}
+
+		addr, err := payoutAddress(payout.Descriptor, 0,
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("payoutAddress(%q): unexpected error: %v",
+				test.payout, err)
+			continue
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Errorf("PayToAddrScript(%q): unexpected error: %v",
+				test.payout, err)
+			continue
+		}
+		if got := hex.EncodeToString(script); got != test.script {
+			t.Errorf("script of %q: got %s, want %s", test.payout,
+				got, test.script)
+		}
+	}
+}
+
+// TestPayoutSet ensures payouts are chosen according to their weights and that
+// ranged descriptors pay to a new script each time they are chosen.
+func TestPayoutSet(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	parse := func(s string) Payout {
+		t.Helper()
+		payout, err := ParsePayout(s, params)
+		if err != nil {
+			t.Fatalf("ParsePayout(%q): unexpected error: %v", s, err)
+		}
+		return *payout
+	}
+
+	// An empty set has nothing to pay to.
+	set, err := newPayoutSet(nil, params)
+	if err != nil {
+		t.Fatalf("newPayoutSet: unexpected error: %v", err)
+	}
+	if addr, err := set.choose(); addr != nil || err != nil {
+		t.Fatalf("choose: got %v, %v, want nil", addr, err)
+	}
+
+	// Payouts without weight are rejected.
+	zero := parse("pk(" + testPubKey + ")")
+	zero.Weight = 0
+	if _, err := newPayoutSet([]Payout{zero}, params); err == nil {
+		t.Fatal("newPayoutSet: expected error for zero weight")
+	}
+
+	// The ranged payout is chosen about three times as often as the other
+	// one and derives a new address each time.
+	fixed := parse("addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)")
+	ranged := parse("wpkh(" + testXpub + "/0/*)@3")
+	set, err = newPayoutSet([]Payout{fixed, ranged}, params)
+	if err != nil {
+		t.Fatalf("newPayoutSet: unexpected error: %v", err)
+	}
+	const rounds = 4000
+	var numFixed int
+	seen := make(map[string]struct{})
+	for i := 0; i < rounds; i++ {
+		addr, err := set.choose()
+		if err != nil {
+			t.Fatalf("choose: unexpected error: %v", err)
+		}
+		if _, ok := addr.(*btcutil.AddressPubKeyHash); ok {
+			numFixed++
+			continue
+		}
+		if _, ok := seen[addr.EncodeAddress()]; ok {
+			t.Fatalf("choose: ranged address %v chosen twice", addr)
+		}
+		seen[addr.EncodeAddress()] = struct{}{}
+	}
+	if numFixed < rounds/4-200 || numFixed > rounds/4+200 {
+		t.Fatalf("fixed payout chosen %d times, want about %d",
+			numFixed, rounds/4)
+	}
+	if uint32(len(seen)) != set.next[1] {
+		t.Fatalf("got next index %d, want %d", set.next[1], len(seen))
+	}
+}
@@ -5,9 +5,9 @@
 package mining
 
 import (
-	"bytes"
 	"container/heap"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -51,6 +51,12 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// FeeDelta is a virtual fee in Satoshi which is added to the fee when
+	// selecting transactions for inclusion in a block.  It allows miners
+	// to prioritize or deprioritize transactions regardless of the fee
+	// they actually pay.
+	FeeDelta int64
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -60,7 +66,7 @@ type TxDesc struct {
 // concurrent access with respect to the source.
 type TxSource interface {
 	// LastUpdated returns the last time a transaction was added to or
-	// removed from the source pool.
+	// removed from the source pool or had its fee delta changed.
 	LastUpdated() time.Time
 
 	// MiningDescs returns a slice of mining descriptors for all the
@@ -72,115 +78,126 @@ type TxSource interface {
 	HaveTransaction(hash *chainhash.Hash) bool
 }
 
-// txPrioItem houses a transaction along with extra information that allows the
-// transaction to be prioritized and track dependencies on other transactions
-// which have not been mined into a block yet.
-type txPrioItem struct {
-	tx       *btcutil.Tx
-	fee      int64
-	priority float64
-	feePerKB int64
-
-	// dependsOn holds a map of transaction hashes which this one depends
-	// on.  It will only be set when the transaction references other
-	// transactions in the source pool and hence must come after them in
-	// a block.
-	dependsOn map[chainhash.Hash]struct{}
+// txPackageItem houses a transaction of the source pool along with its
+// ancestors and descendants among the transactions being considered for
+// inclusion in a block.  A transaction can only be included once all of its
+// ancestors have been, so it is selected according to the fee rate of the
+// package it forms with its ancestors which have not been included yet.  This
+// allows transactions paying high fees to pull in the low-fee parents they
+// spend from, also known as child-pays-for-parent.
+type txPackageItem struct {
+	desc   *TxDesc
+	fee    int64
+	size   int64
+	weight int64
+
+	// numAncestors is the number of ancestors the transaction has before
+	// any of them are included.  Since the ancestors of a transaction are
+	// a superset of the ones of its parents, sorting a package by it
+	// yields a valid block order.
+	numAncestors int
+
+	// ancestors holds the ancestors which have not been included yet and
+	// ancestorFee, ancestorSize and ancestorWeight are the totals of the
+	// package they form with the transaction.  The fees include the fee
+	// deltas of the transactions.
+	ancestors      map[chainhash.Hash]*txPackageItem
+	ancestorFee    int64
+	ancestorSize   int64
+	ancestorWeight int64
+
+	// descendants holds all transactions which spend the outputs of this
+	// one, directly or indirectly.
+	descendants map[chainhash.Hash]*txPackageItem
+
+	included bool
+	failed   bool
 }
 
-// txPriorityQueueLessFunc describes a function that can be used as a compare
-// function for a transaction priority queue (txPriorityQueue).
-type txPriorityQueueLessFunc func(*txPriorityQueue, int, int) bool
-
-// txPriorityQueue implements a priority queue of txPrioItem elements that
-// supports an arbitrary compare function as defined by txPriorityQueueLessFunc.
-type txPriorityQueue struct {
-	lessFunc txPriorityQueueLessFunc
-	items    []*txPrioItem
+// txPackageEntry is an entry of a txPackageQueue.  It records the package
+// totals of the item at the time it was queued, so entries which have been
+// superseded by the inclusion of ancestors can be told apart.
+type txPackageEntry struct {
+	item *txPackageItem
+	fee  int64
+	size int64
 }
 
+// txPackageQueue implements a priority queue of txPackageEntry elements
+// ordered by the fee rate of the packages.
+type txPackageQueue []*txPackageEntry
+
 // Len returns the number of items in the priority queue.  It is part of the
 // heap.Interface implementation.
-func (pq *txPriorityQueue) Len() int {
-	return len(pq.items)
+func (pq txPackageQueue) Len() int {
+	return len(pq)
 }
 
 // Less returns whether the item in the priority queue with index i should sort
-// before the item with index j by deferring to the assigned less function.  It
-// is part of the heap.Interface implementation.
-func (pq *txPriorityQueue) Less(i, j int) bool {
-	return pq.lessFunc(pq, i, j)
+// before the item with index j.  Packages paying a higher fee per virtual byte
+// sort first, followed by smaller packages when the fee rates are equal.  It is
+// part of the heap.Interface implementation.
+func (pq txPackageQueue) Less(i, j int) bool {
+	// Compare the fee rates without dividing.  Floats are used since the
+	// products can overflow 64-bit integers.
+	a := float64(pq[i].fee) * float64(pq[j].size)
+	b := float64(pq[j].fee) * float64(pq[i].size)
+	if a == b {
+		return pq[i].size < pq[j].size
+	}
+	return a > b
 }
 
 // Swap swaps the items at the passed indices in the priority queue.  It is
 // part of the heap.Interface implementation.
-func (pq *txPriorityQueue) Swap(i, j int) {
-	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+func (pq txPackageQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
 }
 
 // Push pushes the passed item onto the priority queue.  It is part of the
 // heap.Interface implementation.
-func (pq *txPriorityQueue) Push(x interface{}) {
-	pq.items = append(pq.items, x.(*txPrioItem))
+func (pq *txPackageQueue) Push(x interface{}) {
+	*pq = append(*pq, x.(*txPackageEntry))
 }
 
 // Pop removes the highest priority item (according to Less) from the priority
 // queue and returns it.  It is part of the heap.Interface implementation.
-func (pq *txPriorityQueue) Pop() interface{} {
-	n := len(pq.items)
-	item := pq.items[n-1]
-	pq.items[n-1] = nil
-	pq.items = pq.items[0 : n-1]
-	return item
-}
-
-// SetLessFunc sets the compare function for the priority queue to the provided
-// function.  It also invokes heap.Init on the priority queue using the new
-// function so it can immediately be used with heap.Push/Pop.
-func (pq *txPriorityQueue) SetLessFunc(lessFunc txPriorityQueueLessFunc) {
-	pq.lessFunc = lessFunc
-	heap.Init(pq)
+func (pq *txPackageQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*pq = old[0 : n-1]
+	return entry
 }
 
-// txPQByPriority sorts a txPriorityQueue by transaction priority and then fees
-// per kilobyte.
-func txPQByPriority(pq *txPriorityQueue, i, j int) bool {
-	// Using > here so that pop gives the highest priority item as opposed
-	// to the lowest.  Sort by priority first, then fee.
-	if pq.items[i].priority == pq.items[j].priority {
-		return pq.items[i].feePerKB > pq.items[j].feePerKB
-	}
-	return pq.items[i].priority > pq.items[j].priority
-
+// push adds the passed item to the priority queue with its current package
+// totals.
+func (pq *txPackageQueue) push(item *txPackageItem) {
+	heap.Push(pq, &txPackageEntry{
+		item: item,
+		fee:  item.ancestorFee,
+		size: item.ancestorSize,
+	})
 }
 
-// txPQByFee sorts a txPriorityQueue by fees per kilobyte and then transaction
-// priority.
-func txPQByFee(pq *txPriorityQueue, i, j int) bool {
-	// Using > here so that pop gives the highest fee item as opposed
-	// to the lowest.  Sort by fee first, then priority.
-	if pq.items[i].feePerKB == pq.items[j].feePerKB {
-		return pq.items[i].priority > pq.items[j].priority
-	}
-	return pq.items[i].feePerKB > pq.items[j].feePerKB
-}
+// pop removes and returns the item whose package pays the highest fee rate,
+// skipping the items which have been included or have failed along with the
+// entries superseded since they were queued.  It returns nil once the priority
+// queue is empty.
+func (pq *txPackageQueue) pop() *txPackageItem {
+	for pq.Len() > 0 {
+		entry := heap.Pop(pq).(*txPackageEntry)
+		item := entry.item
+		if item.included || item.failed ||
+			entry.fee != item.ancestorFee ||
+			entry.size != item.ancestorSize {
 
-// newTxPriorityQueue returns a new transaction priority queue that reserves the
-// passed amount of space for the elements.  The new priority queue uses either
-// the txPQByPriority or the txPQByFee compare function depending on the
-// sortByFee parameter and is already initialized for use with heap.Push/Pop.
-// The priority queue can grow larger than the reserved space, but extra copies
-// of the underlying array can be avoided by reserving a sane value.
-func newTxPriorityQueue(reserve int, sortByFee bool) *txPriorityQueue {
-	pq := &txPriorityQueue{
-		items: make([]*txPrioItem, 0, reserve),
-	}
-	if sortByFee {
-		pq.SetLessFunc(txPQByFee)
-	} else {
-		pq.SetLessFunc(txPQByPriority)
+			continue
+		}
+		return item
 	}
-	return pq
+	return nil
 }
 
 // BlockTemplate houses a block that has yet to be solved along with additional
@@ -303,14 +320,14 @@ func spendTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil.Tx, height
 
 // logSkippedDeps logs any dependencies which are also skipped as a result of
 // skipping a transaction while generating a block template at the trace level.
-func logSkippedDeps(tx *btcutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
+func logSkippedDeps(tx *btcutil.Tx, deps map[chainhash.Hash]*txPackageItem) {
 	if deps == nil {
 		return
 	}
 
 	for _, item := range deps {
 		log.Tracef("Skipping tx %s since it depends on %s\n",
-			item.tx.Hash(), tx.Hash())
+			item.desc.Tx.Hash(), tx.Hash())
 	}
 }
 
@@ -353,6 +370,12 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+
+	// cache holds the transaction selection of the most recently created
+	// block template so it can be incrementally updated.  It is protected
+	// by cacheMtx, which also serializes the creation of templates.
+	cacheMtx sync.Mutex
+	cache    *txSelection
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -387,64 +410,78 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // coinbase which will replace the one generated for the block template.  Thus
 // the need to have configured address can be avoided.
 //
-// The transactions selected and included are prioritized according to several
-// factors.  First, each transaction has a priority calculated based on its
-// value, age of inputs, and size.  Transactions which consist of larger
-// amounts, older inputs, and small sizes have the highest priority.  Second, a
-// fee per kilobyte is calculated for each transaction.  Transactions with a
-// higher fee per kilobyte are preferred.  Finally, the block generation related
-// policy settings are all taken into account.
-//
-// Transactions which only spend outputs from other transactions already in the
-// block chain are immediately added to a priority queue which either
-// prioritizes based on the priority (then fee per kilobyte) or the fee per
-// kilobyte (then priority) depending on whether or not the BlockPrioritySize
-// policy setting allots space for high-priority transactions.  Transactions
-// which spend outputs from other transactions in the source pool are added to a
-// dependency map so they can be added to the priority queue once the
-// transactions they depend on have been included.
+// The transactions are selected according to the fee rate of the packages they
+// form with their ancestors in the source pool which have not been selected
+// yet, similar to the ancestor fee rate scoring of Bitcoin Core.  The package
+// paying the highest fee per virtual byte is added as a whole with the parents
+// ahead of their children, after which the package totals of the descendants
+// of the added transactions are updated to exclude them.  Thus a transaction
+// paying a high fee pulls in the low-fee parents it spends from, also known as
+// child-pays-for-parent, so the fees of the block are not left on the table.
+// Finally, the block generation related policy settings are all taken into
+// account.
 //
-// Once the high-priority area (if configured) has been filled with
-// transactions, or the priority falls below what is considered high-priority,
-// the priority queue is updated to prioritize by fees per kilobyte (then
-// priority).
+// When the fee per kilobyte of a package drops below the TxMinFreeFee policy
+// setting, the package will be skipped unless the BlockMinWeight policy setting
+// is nonzero, in which case the block will be filled with the low-fee/free
+// packages until the block weight reaches that minimum weight.
 //
-// When the fees per kilobyte drop below the TxMinFreeFee policy setting, the
-// transaction will be skipped unless the BlockMinSize policy setting is
-// nonzero, in which case the block will be filled with the low-fee/free
-// transactions until the block size reaches that minimum size.
-//
-// Any transactions which would cause the block to exceed the BlockMaxSize
-// policy setting, exceed the maximum allowed signature operations per block, or
-// otherwise cause the block to be invalid are skipped.
+// Any packages which would cause the block to exceed the BlockMaxWeight policy
+// setting, exceed the maximum allowed signature operations per block, or
+// otherwise cause the block to be invalid are skipped along with the
+// transactions spending their outputs.
 //
 // Given the above, a block generated by this function is of the following form:
 //
-//	 -----------------------------------  --  --
-//	|      Coinbase Transaction         |   |   |
-//	|-----------------------------------|   |   |
-//	|                                   |   |   | ----- policy.BlockPrioritySize
-//	|   High-priority Transactions      |   |   |
-//	|                                   |   |   |
-//	|-----------------------------------|   | --
+//	 -----------------------------------  --
+//	|      Coinbase Transaction         |   |
+//	|-----------------------------------|   |
 //	|                                   |   |
 //	|                                   |   |
-//	|                                   |   |--- policy.BlockMaxSize
-//	|  Transactions prioritized by fee  |   |
+//	|                                   |   |--- policy.BlockMaxWeight
+//	|  Packages prioritized by fee rate |   |
 //	|  until <= policy.TxMinFreeFee     |   |
 //	|                                   |   |
 //	|                                   |   |
 //	|                                   |   |
 //	|-----------------------------------|   |
-//	|  Low-fee/Non high-priority (free) |   |
-//	|  transactions (while block size   |   |
-//	|  <= policy.BlockMinSize)          |   |
+//	|  Low-fee/free packages (while     |   |
+//	|  block weight                     |   |
+//	|  <= policy.BlockMinWeight)        |   |
 //	 -----------------------------------  --
+//
+// Selecting the transactions from scratch requires looking up the inputs of
+// every transaction in the source pool, so the selection is cached and
+// incrementally updated with the transactions which entered or left the source
+// pool since.  New transactions are appended in order of the fee rate of the
+// packages they form with the other new transactions when they fit, and
+// removed transactions are removed along with their descendants.  The
+// selection is made from scratch again once the best chain changes or the
+// TemplateStaleness policy setting has elapsed, which also reconsiders the
+// transactions which were skipped.
 func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*BlockTemplate, error) {
+	g.cacheMtx.Lock()
+	defer g.cacheMtx.Unlock()
+
 	// Extend the most recently known best block.
 	best := g.chain.BestSnapshot()
 	nextBlockHeight := best.Height + 1
 
+	// Update the cached selection when it still builds on the best block
+	// and isn't stale.  Fall back to a new selection should the resulting
+	// block turn out to be invalid.
+	if sel := g.cachedSelection(best); sel != nil {
+		g.updateSelection(sel)
+		template, err := g.newTemplateFromSelection(best, payToAddress,
+			sel)
+		if err == nil {
+			return template, nil
+		}
+		log.Debugf("Discarding incrementally updated block template: "+
+			"%v", err)
+		g.cache = nil
+	}
+
 	// Create a standard coinbase transaction paying to the provided
 	// address.  NOTE: The coinbase value will be updated to include the
 	// fees from the selected transactions later after they have actually
@@ -465,361 +502,273 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*Bloc
 	}
 	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
 
-	// Get the current source transactions and create a priority queue to
-	// hold the transactions which are ready for inclusion into a block
-	// along with some priority related and fee metadata.  Reserve the same
-	// number of items that are available for the priority queue.  Also,
-	// choose the initial sort order for the priority queue based on whether
-	// or not there is an area allocated for high-priority transactions.
-	sourceTxns := g.txSource.MiningDescs()
-	sortedByFee := g.policy.BlockPrioritySize == 0
-	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
-
-	// Create a slice to hold the transactions to be included in the
-	// generated block with reserved space.  Also create a utxo view to
-	// house all of the input transactions so multiple lookups can be
-	// avoided.
-	blockTxns := make([]*btcutil.Tx, 0, len(sourceTxns))
-	blockTxns = append(blockTxns, coinbaseTx)
-	blockUtxos := blockchain.NewUtxoViewpoint()
+	// Query the version bits state to see if segwit has been activated, if
+	// so then this means that we'll include any transactions with witness
+	// data in the mempool, and also add the witness commitment as an
+	// OP_RETURN output in the coinbase transaction.
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
+
+	// The starting block weight is the weight of the block header plus the
+	// max possible transaction count size, plus the weight of the coinbase
+	// transaction.
+	blockWeight := uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
+		blockchain.GetTransactionWeight(coinbaseTx))
+	sel := &txSelection{
+		tipHash:          best.Hash,
+		height:           nextBlockHeight,
+		created:          time.Now(),
+		weight:           blockWeight,
+		sigOpCost:        coinbaseSigOpCost,
+		commitmentWeight: witnessCommitmentWeight(coinbaseTx),
+		segwitActive:     segwitActive,
+	}
 
-	// dependers is used to track transactions which depend on another
-	// transaction in the source pool.  This, in conjunction with the
-	// dependsOn map kept with each dependent transaction helps quickly
-	// determine which dependent transactions are now eligible for inclusion
-	// in the block once each transaction has been included.
-	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
-
-	// Create slices to hold the fees and number of signature operations
-	// for each of the selected transactions and add an entry for the
-	// coinbase.  This allows the code below to simply append details about
-	// a transaction as it is selected for inclusion in the final block.
-	// However, since the total fees aren't known yet, use a dummy value for
-	// the coinbase fee which will be updated later.
-	txFees := make([]int64, 0, len(sourceTxns))
-	txSigOpCosts := make([]int64, 0, len(sourceTxns))
-	txFees = append(txFees, -1) // Updated once known
-	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
+	// Get the current source transactions and choose which of them make it
+	// into the block.  The update time of the source is queried first, so
+	// any changes racing with the selection are picked up by the next
+	// update.
+	sel.lastTxUpdate = g.txSource.LastUpdated()
+	sourceTxns := g.txSource.MiningDescs()
+	source := make(map[chainhash.Hash]*TxDesc, len(sourceTxns))
+	sel.considered = make(map[chainhash.Hash]int64, len(sourceTxns))
+	for _, txDesc := range sourceTxns {
+		source[*txDesc.Tx.Hash()] = txDesc
+		sel.considered[*txDesc.Tx.Hash()] = txDesc.FeeDelta
+	}
 
 	log.Debugf("Considering %d transactions for inclusion to new block",
 		len(sourceTxns))
 
-mempoolLoop:
-	for _, txDesc := range sourceTxns {
-		// A block can't have more than one coinbase or contain
-		// non-finalized transactions.
-		tx := txDesc.Tx
-		if blockchain.IsCoinBase(tx) {
-			log.Tracef("Skipping coinbase tx %s", tx.Hash())
-			continue
-		}
-		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
-			g.timeSource.AdjustedTime()) {
-
-			log.Tracef("Skipping non-finalized tx %s", tx.Hash())
-			continue
-		}
+	g.addPackages(sel, sourceTxns, source)
 
-		// Fetch all of the utxos referenced by this transaction.
-		// NOTE: This intentionally does not fetch inputs from the
-		// mempool since a transaction which depends on other
-		// transactions in the mempool must come after those
-		// dependencies in the final generated block.
-		utxos, err := g.chain.FetchUtxoView(tx)
-		if err != nil {
-			log.Warnf("Unable to fetch utxo view for tx %s: %v",
-				tx.Hash(), err)
-			continue
-		}
+	// Now that the actual transactions have been selected, cache the
+	// selection so it can be incrementally updated and create the block
+	// template from it.
+	template, err := g.newTemplateFromSelection(best, payToAddress, sel)
+	if err != nil {
+		return nil, err
+	}
+	g.cache = sel
 
-		// Setup dependencies for any transactions which reference
-		// other transactions in the mempool so they can be properly
-		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
-		for _, txIn := range tx.MsgTx().TxIn {
-			originHash := &txIn.PreviousOutPoint.Hash
-			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
-			if entry == nil || entry.IsSpent() {
-				if !g.txSource.HaveTransaction(originHash) {
-					log.Tracef("Skipping tx %s because it "+
-						"references unspent output %s "+
-						"which is not available",
-						tx.Hash(), txIn.PreviousOutPoint)
-					continue mempoolLoop
-				}
-
-				// The transaction is referencing another
-				// transaction in the source pool, so setup an
-				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
-				if prioItem.dependsOn == nil {
-					prioItem.dependsOn = make(
-						map[chainhash.Hash]struct{})
-				}
-				prioItem.dependsOn[*originHash] = struct{}{}
+	return template, nil
+}
 
-			}
-		}
+// newTemplateFromSelection returns a new block template extending the passed
+// best chain state which is made up of a coinbase paying to the passed address,
+// or redeemable by anyone if it is nil, followed by the transactions of the
+// passed selection.
+func (g *BlkTmplGenerator) newTemplateFromSelection(best *blockchain.BestState,
+	payToAddress btcutil.Address, sel *txSelection) (*BlockTemplate, error) {
 
-		// Calculate the final transaction priority using the input
-		// value age sum as well as the adjusted transaction size.  The
-		// formula is: sum(inputValue * inputAge) / adjustedTxSize
-		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
-			nextBlockHeight)
+	// Create a standard coinbase transaction paying to the provided
+	// address along with the fees of the selected transactions.
+	coinbaseScript, err := standardCoinbaseScript(sel.height, 0)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+		sel.height, payToAddress)
+	if err != nil {
+		return nil, err
+	}
+	totalFees := int64(0)
+	for _, fee := range sel.fees {
+		totalFees += fee
+	}
+	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
 
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
-		prioItem.fee = txDesc.Fee
+	blockTxns := make([]*btcutil.Tx, 0, len(sel.txns)+1)
+	blockTxns = append(blockTxns, coinbaseTx)
+	blockTxns = append(blockTxns, sel.txns...)
+	txFees := make([]int64, 0, len(sel.fees)+1)
+	txFees = append(txFees, -totalFees)
+	txFees = append(txFees, sel.fees...)
+	txSigOpCosts := make([]int64, 0, len(sel.sigOpCosts)+1)
+	txSigOpCosts = append(txSigOpCosts,
+		int64(blockchain.CountSigOps(coinbaseTx))*
+			blockchain.WitnessScaleFactor)
+	txSigOpCosts = append(txSigOpCosts, sel.sigOpCosts...)
 
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
-		}
+	// Update the block weight for the real transaction count.
+	blockWeight := sel.weight - (wire.MaxVarIntPayload -
+		(uint32(wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
+			blockchain.WitnessScaleFactor))
 
-		// Merge the referenced outputs from the input transactions to
-		// this transaction into the block utxo view.  This allows the
-		// code below to avoid a second lookup.
-		mergeUtxoView(blockUtxos, utxos)
+	// If segwit is active and we included transactions with witness data,
+	// then we'll need to include a commitment to the witness data in an
+	// OP_RETURN output within the coinbase transaction.  The blocks of
+	// signet networks always need one, since the output also carries the
+	// block solution.
+	var witnessCommitment []byte
+	if sel.witnessIncluded || g.chainParams.SignetChallenge != nil {
+		witnessCommitment = AddWitnessCommitment(coinbaseTx, blockTxns)
 	}
 
-	log.Tracef("Priority queue len %d, dependers len %d",
-		priorityQueue.Len(), len(dependers))
-
-	// The starting block size is the size of the block header plus the max
-	// possible transaction count size, plus the size of the coinbase
-	// transaction.
-	blockWeight := uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
-		blockchain.GetTransactionWeight(coinbaseTx))
-	blockSigOpCost := coinbaseSigOpCost
-	totalFees := int64(0)
-
-	// Query the version bits state to see if segwit has been activated, if
-	// so then this means that we'll include any transactions with witness
-	// data in the mempool, and also add the witness commitment as an
-	// OP_RETURN output in the coinbase transaction.
-	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	// Calculate the required difficulty for the block.  The timestamp
+	// is potentially adjusted to ensure it comes after the median time of
+	// the last several blocks per the chain consensus rules.
+	ts := medianAdjustedTime(best, g.timeSource)
+	reqDifficulty, err := g.chain.CalcNextRequiredDifficulty(ts)
 	if err != nil {
 		return nil, err
 	}
-	segwitActive := segwitState == blockchain.ThresholdActive
 
-	witnessIncluded := false
+	// Calculate the next expected block version based on the state of the
+	// rule change deployments.
+	nextBlockVersion, err := g.chain.CalcNextBlockVersion()
+	if err != nil {
+		return nil, err
+	}
 
-	// Choose which transactions make it into the block.
-	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		tx := prioItem.tx
-
-		switch {
-		// If segregated witness has not been activated yet, then we
-		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
-			continue
+	// Create a new block ready to be solved.
+	var msgBlock wire.MsgBlock
+	msgBlock.Header = wire.BlockHeader{
+		Version:    nextBlockVersion,
+		PrevBlock:  best.Hash,
+		MerkleRoot: blockchain.CalcMerkleRoot(blockTxns, false),
+		Timestamp:  ts,
+		Bits:       reqDifficulty,
+	}
+	for _, tx := range blockTxns {
+		if err := msgBlock.AddTransaction(tx.MsgTx()); err != nil {
+			return nil, err
+		}
+	}
 
-		// Otherwise, Keep track of if we've included a transaction
-		// with witness data or not. If so, then we'll need to include
-		// the witness commitment as the last output in the coinbase
-		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
-			// If we're about to include a transaction bearing
-			// witness data, then we'll also need to include a
-			// witness commitment in the coinbase transaction.
-			// Therefore, we account for the additional weight
-			// within the block with a model coinbase tx with a
-			// witness commitment.
-			coinbaseCopy := btcutil.NewTx(coinbaseTx.MsgTx().Copy())
-			coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
-				bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessDataLen),
-			}
-			coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
-				PkScript: bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessPkScriptLength),
-			})
+	// Finally, perform a full check on the created block against the chain
+	// consensus rules to ensure it properly connects to the current best
+	// chain with no issues.
+	block := btcutil.NewBlock(&msgBlock)
+	block.SetHeight(sel.height)
+	if err := g.chain.CheckConnectBlockTemplate(block); err != nil {
+		return nil, err
+	}
 
-			// In order to accurately account for the weight
-			// addition due to this coinbase transaction, we'll add
-			// the difference of the transaction before and after
-			// the addition of the commitment to the block weight.
-			weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
-				blockchain.GetTransactionWeight(coinbaseTx)
+	log.Debugf("Created new block template (%d transactions, %d in "+
+		"fees, %d signature operations cost, %d weight, target difficulty "+
+		"%064x)", len(msgBlock.Transactions), totalFees, sel.sigOpCost,
+		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
 
-			blockWeight += uint32(weightDiff)
+	return &BlockTemplate{
+		Block:             &msgBlock,
+		Fees:              txFees,
+		SigOpCosts:        txSigOpCosts,
+		Height:            sel.height,
+		ValidPayAddress:   payToAddress != nil,
+		WitnessCommitment: witnessCommitment,
+	}, nil
+}
 
-			witnessIncluded = true
-		}
+// NewBlockTemplateWithTxs returns a new block template which extends the
+// current best chain with a block made up of a coinbase paying to the provided
+// address followed by exactly the passed transactions in the passed order.  The
+// coinbase claims the block subsidy along with the fees of the transactions.
+//
+// Unlike NewBlockTemplate, the transaction source is not consulted and none of
+// the mining policy limits are applied, so any set of transactions which forms
+// a block that is valid under the consensus rules is accepted.  This is useful
+// on test networks where the caller wants full control over the contents of
+// the generated blocks.  An error is returned when the resulting block would
+// not connect to the current best chain.
+func (g *BlkTmplGenerator) NewBlockTemplateWithTxs(payToAddress btcutil.Address,
+	txs []*btcutil.Tx) (*BlockTemplate, error) {
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
+	// Extend the most recently known best block.
+	best := g.chain.BestSnapshot()
+	nextBlockHeight := best.Height + 1
 
-		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
-		if blockPlusTxWeight < blockWeight ||
-			blockPlusTxWeight >= g.policy.BlockMaxWeight {
+	// Create a standard coinbase transaction paying to the provided
+	// address.  Its value is updated once the fees of the transactions
+	// are known.
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, 0)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+		nextBlockHeight, payToAddress)
+	if err != nil {
+		return nil, err
+	}
 
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
-		}
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
 
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+	blockTxns := make([]*btcutil.Tx, 0, len(txs)+1)
+	blockTxns = append(blockTxns, coinbaseTx)
+	txFees := make([]int64, 1, len(txs)+1)
+	txSigOpCosts := make([]int64, 1, len(txs)+1)
+	txSigOpCosts[0] = int64(blockchain.CountSigOps(coinbaseTx)) *
+		blockchain.WitnessScaleFactor
+
+	// Calculate the fee and signature operation cost of each transaction
+	// while keeping track of the outputs created and spent by the
+	// transactions before it, so transactions may spend the outputs of
+	// the ones earlier in the block.
+	blockUtxos := blockchain.NewUtxoViewpoint()
+	witnessIncluded := false
+	totalFees := int64(0)
+	for _, tx := range txs {
+		if blockchain.IsCoinBase(tx) {
+			return nil, fmt.Errorf("transaction %v is a coinbase",
+				tx.Hash())
 		}
-
-		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
-
-			log.Tracef("Skipping tx %s with feePerKB %d "+
-				"< TxMinFreeFee %d and block weight %d >= "+
-				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
-				g.policy.BlockMinWeight)
-			logSkippedDeps(tx, deps)
-			continue
+		if tx.HasWitness() {
+			if !segwitActive {
+				return nil, fmt.Errorf("transaction %v has "+
+					"witness data but segwit is not active",
+					tx.Hash())
+			}
+			witnessIncluded = true
 		}
 
-		// Prioritize by fee per kilobyte once the block is larger than
-		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
-			prioItem.priority <= MinHighPriority) {
-
-			log.Tracef("Switching to sort by fees per "+
-				"kilobyte blockSize %d >= BlockPrioritySize "+
-				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
-				prioItem.priority, MinHighPriority)
-
-			sortedByFee = true
-			priorityQueue.SetLessFunc(txPQByFee)
-
-			// Put the transaction back into the priority queue and
-			// skip it so it is re-priortized by fees if it won't
-			// fit into the high-priority section or the priority
-			// is too low.  Otherwise this transaction will be the
-			// final one in the high-priority section, so just fall
-			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
-				prioItem.priority < MinHighPriority {
-
-				heap.Push(priorityQueue, prioItem)
-				continue
-			}
+		utxos, err := g.chain.FetchUtxoView(tx)
+		if err != nil {
+			return nil, err
 		}
+		mergeUtxoView(blockUtxos, utxos)
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
+		fee, err := blockchain.CheckTransactionInputs(tx, nextBlockHeight,
 			blockUtxos, g.chainParams)
 		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			return nil, err
 		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
+		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+			blockUtxos, true, segwitActive)
 		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			return nil, err
 		}
-
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
 		spendTransaction(blockUtxos, tx, nextBlockHeight)
 
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
 		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
+		totalFees += fee
+		txFees = append(txFees, fee)
 		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
-			}
-		}
 	}
-
-	// Now that the actual transactions have been selected, update the
-	// block weight for the real transaction count and coinbase value with
-	// the total fees accordingly.
-	blockWeight -= wire.MaxVarIntPayload -
-		(uint32(wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
-			blockchain.WitnessScaleFactor)
 	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
 	txFees[0] = -totalFees
 
-	// If segwit is active and we included transactions with witness data,
-	// then we'll need to include a commitment to the witness data in an
-	// OP_RETURN output within the coinbase transaction.
 	var witnessCommitment []byte
-	if witnessIncluded {
+	if witnessIncluded || g.chainParams.SignetChallenge != nil {
 		witnessCommitment = AddWitnessCommitment(coinbaseTx, blockTxns)
 	}
 
-	// Calculate the required difficulty for the block.  The timestamp
-	// is potentially adjusted to ensure it comes after the median time of
-	// the last several blocks per the chain consensus rules.
 	ts := medianAdjustedTime(best, g.timeSource)
 	reqDifficulty, err := g.chain.CalcNextRequiredDifficulty(ts)
 	if err != nil {
 		return nil, err
 	}
-
-	// Calculate the next expected block version based on the state of the
-	// rule change deployments.
 	nextBlockVersion, err := g.chain.CalcNextBlockVersion()
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a new block ready to be solved.
 	var msgBlock wire.MsgBlock
 	msgBlock.Header = wire.BlockHeader{
 		Version:    nextBlockVersion,
@@ -834,19 +783,17 @@ mempoolLoop:
 		}
 	}
 
-	// Finally, perform a full check on the created block against the chain
-	// consensus rules to ensure it properly connects to the current best
-	// chain with no issues.
+	// The checks above only cover what is needed to build the block, so
+	// perform a full check against the chain consensus rules which also
+	// catches double spends, invalid scripts and oversized blocks.
 	block := btcutil.NewBlock(&msgBlock)
 	block.SetHeight(nextBlockHeight)
 	if err := g.chain.CheckConnectBlockTemplate(block); err != nil {
 		return nil, err
 	}
 
-	log.Debugf("Created new block template (%d transactions, %d in "+
-		"fees, %d signature operations cost, %d weight, target difficulty "+
-		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
-		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
+	log.Debugf("Created new block template with %d explicitly selected "+
+		"transactions (%d in fees)", len(txs), totalFees)
 
 	return &BlockTemplate{
 		Block:             &msgBlock,
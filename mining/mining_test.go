@@ -5,31 +5,26 @@
 package mining
 
 import (
-	"container/heap"
 	"math/rand"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil"
 )
 
-// TestTxFeePrioHeap ensures the priority queue for transaction fees and
-// priorities works as expected.
-func TestTxFeePrioHeap(t *testing.T) {
-	// Create some fake priority items that exercise the expected sort
+// TestTxPackageQueue ensures the priority queue for transaction packages orders
+// them by fee rate and skips superseded entries as expected.
+func TestTxPackageQueue(t *testing.T) {
+	// Create some fake package items that exercise the expected sort
 	// edge conditions.
-	testItems := []*txPrioItem{
-		{feePerKB: 5678, priority: 3},
-		{feePerKB: 5678, priority: 1},
-		{feePerKB: 5678, priority: 1}, // Duplicate fee and prio
-		{feePerKB: 5678, priority: 5},
-		{feePerKB: 5678, priority: 2},
-		{feePerKB: 1234, priority: 3},
-		{feePerKB: 1234, priority: 1},
-		{feePerKB: 1234, priority: 5},
-		{feePerKB: 1234, priority: 5}, // Duplicate fee and prio
-		{feePerKB: 1234, priority: 2},
-		{feePerKB: 10000, priority: 0}, // Higher fee, smaller prio
-		{feePerKB: 0, priority: 10000}, // Higher prio, lower fee
+	testItems := []*txPackageItem{
+		{ancestorFee: 5678, ancestorSize: 1000},
+		{ancestorFee: 5678, ancestorSize: 500},
+		{ancestorFee: 5678, ancestorSize: 500},   // Duplicate fee and size
+		{ancestorFee: 11356, ancestorSize: 2000}, // Same rate, larger
+		{ancestorFee: 1234, ancestorSize: 100},
+		{ancestorFee: 1234, ancestorSize: 250},
+		{ancestorFee: 10000, ancestorSize: 10000},
+		{ancestorFee: 0, ancestorSize: 100}, // Free
 	}
 
 	// Add random data in addition to the edge conditions already manually
@@ -42,69 +37,55 @@ func TestTxFeePrioHeap(t *testing.T) {
 	}()
 	prng := rand.New(rand.NewSource(randSeed))
 	for i := 0; i < 1000; i++ {
-		testItems = append(testItems, &txPrioItem{
-			feePerKB: int64(prng.Float64() * btcutil.SatoshiPerBitcoin),
-			priority: prng.Float64() * 100,
+		testItems = append(testItems, &txPackageItem{
+			ancestorFee:  int64(prng.Float64() * btcutil.SatoshiPerBitcoin),
+			ancestorSize: prng.Int63n(100000) + 1,
 		})
 	}
 
-	// Test sorting by fee per KB then priority.
-	var highest *txPrioItem
-	priorityQueue := newTxPriorityQueue(len(testItems), true)
-	for i := 0; i < len(testItems); i++ {
-		prioItem := testItems[i]
-		if highest == nil {
-			highest = prioItem
-		}
-		if prioItem.feePerKB >= highest.feePerKB &&
-			prioItem.priority > highest.priority {
-
-			highest = prioItem
-		}
-		heap.Push(priorityQueue, prioItem)
-	}
-
-	for i := 0; i < len(testItems); i++ {
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		if prioItem.feePerKB >= highest.feePerKB &&
-			prioItem.priority > highest.priority {
-
-			t.Fatalf("fee sort: item (fee per KB: %v, "+
-				"priority: %v) higher than than prev "+
-				"(fee per KB: %v, priority %v)",
-				prioItem.feePerKB, prioItem.priority,
-				highest.feePerKB, highest.priority)
-		}
-		highest = prioItem
+	// Queue the items and lower the fee of the first one after it has been
+	// queued, so its first entry is superseded by the second one.
+	queue := make(txPackageQueue, 0, len(testItems))
+	for _, item := range testItems {
+		queue.push(item)
 	}
+	testItems[0].ancestorFee = 1
+	queue.push(testItems[0])
 
-	// Test sorting by priority then fee per KB.
-	highest = nil
-	priorityQueue = newTxPriorityQueue(len(testItems), false)
-	for i := 0; i < len(testItems); i++ {
-		prioItem := testItems[i]
-		if highest == nil {
-			highest = prioItem
+	// Ensure the items are popped once each in order of decreasing fee
+	// rate, then increasing size.
+	var prev *txPackageItem
+	popped := make(map[*txPackageItem]struct{})
+	for item := queue.pop(); item != nil; item = queue.pop() {
+		if _, ok := popped[item]; ok {
+			t.Fatalf("item (fee: %v, size: %v) popped twice",
+				item.ancestorFee, item.ancestorSize)
 		}
-		if prioItem.priority >= highest.priority &&
-			prioItem.feePerKB > highest.feePerKB {
+		popped[item] = struct{}{}
 
-			highest = prioItem
+		if prev != nil {
+			a := float64(item.ancestorFee) * float64(prev.ancestorSize)
+			b := float64(prev.ancestorFee) * float64(item.ancestorSize)
+			if a > b || (a == b && item.ancestorSize < prev.ancestorSize) {
+				t.Fatalf("item (fee: %v, size: %v) higher than "+
+					"prev (fee: %v, size: %v)",
+					item.ancestorFee, item.ancestorSize,
+					prev.ancestorFee, prev.ancestorSize)
+			}
 		}
-		heap.Push(priorityQueue, prioItem)
+		prev = item
+	}
+	if len(popped) != len(testItems) {
+		t.Fatalf("popped %d items, want %d", len(popped), len(testItems))
 	}
 
-	for i := 0; i < len(testItems); i++ {
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		if prioItem.priority >= highest.priority &&
-			prioItem.feePerKB > highest.feePerKB {
-
-			t.Fatalf("priority sort: item (fee per KB: %v, "+
-				"priority: %v) higher than than prev "+
-				"(fee per KB: %v, priority %v)",
-				prioItem.feePerKB, prioItem.priority,
-				highest.feePerKB, highest.priority)
-		}
-		highest = prioItem
+	// Included and failed items are skipped.
+	testItems[0].included = true
+	testItems[1].failed = true
+	queue.push(testItems[0])
+	queue.push(testItems[1])
+	if item := queue.pop(); item != nil {
+		t.Fatalf("popped included or failed item (fee: %v, size: %v)",
+			item.ancestorFee, item.ancestorSize)
 	}
 }
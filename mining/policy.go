@@ -5,6 +5,8 @@
 package mining
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/wire"
@@ -37,14 +39,16 @@ type Policy struct {
 	// block template.
 	BlockMaxSize uint32
 
-	// BlockPrioritySize is the size in bytes for high-priority / low-fee
-	// transactions to be used when generating a block template.
-	BlockPrioritySize uint32
-
 	// TxMinFreeFee is the minimum fee in Satoshi/1000 bytes that is
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee btcutil.Amount
+
+	// TemplateStaleness is the maximum amount of time the transactions
+	// selected for block templates are incrementally updated with the
+	// changes of the source pool before they are selected from scratch
+	// again.  Zero selects them from scratch for every template.
+	TemplateStaleness time.Duration
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
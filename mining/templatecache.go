@@ -0,0 +1,520 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// txSelection houses the transactions selected for a block template along with
+// the running totals needed to incrementally update the selection as the
+// transactions of the source pool change.
+type txSelection struct {
+	// tipHash and height identify the block the selection builds on and
+	// the height of the block it is for.
+	tipHash chainhash.Hash
+	height  int32
+
+	// created is the time the selection was made from scratch and
+	// lastTxUpdate is the last update time of the source pool the
+	// selection reflects.
+	created      time.Time
+	lastTxUpdate time.Time
+
+	// txns holds the selected transactions other than the coinbase in
+	// block order, while fees and sigOpCosts hold their fees and signature
+	// operation costs.
+	txns       []*btcutil.Tx
+	fees       []int64
+	sigOpCosts []int64
+
+	// considered holds the fee deltas of the source pool transactions
+	// which have been considered for inclusion, whether they were selected
+	// or not, keyed by their hashes.  Transactions which were skipped are
+	// only considered again once the selection is made from scratch or
+	// their fee delta changes.
+	considered map[chainhash.Hash]int64
+
+	// weight and sigOpCost are the totals of the block including the
+	// header and the coinbase.  The weight also includes the witness
+	// commitment once a transaction with witness data has been selected.
+	weight    uint32
+	sigOpCost int64
+
+	// commitmentWeight is the weight the witness commitment adds to the
+	// coinbase.
+	commitmentWeight uint32
+
+	segwitActive    bool
+	witnessIncluded bool
+}
+
+// witnessCommitmentWeight returns the weight adding a witness commitment to the
+// passed coinbase transaction adds to it.
+func witnessCommitmentWeight(coinbaseTx *btcutil.Tx) uint32 {
+	coinbaseCopy := btcutil.NewTx(coinbaseTx.MsgTx().Copy())
+	coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
+		bytes.Repeat([]byte("a"), blockchain.CoinbaseWitnessDataLen),
+	}
+	coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
+		PkScript: bytes.Repeat([]byte("a"),
+			blockchain.CoinbaseWitnessPkScriptLength),
+	})
+
+	return uint32(blockchain.GetTransactionWeight(coinbaseCopy) -
+		blockchain.GetTransactionWeight(coinbaseTx))
+}
+
+// cachedSelection returns the cached transaction selection when it can be
+// incrementally updated to create a block template extending the passed best
+// chain state, or nil when the selection has to be made from scratch.  This is
+// the case when the best chain has changed since it was made, or it has been
+// updated for longer than the TemplateStaleness policy setting allows.
+//
+// This function MUST be called with the cache lock held.
+func (g *BlkTmplGenerator) cachedSelection(best *blockchain.BestState) *txSelection {
+	sel := g.cache
+	if sel == nil || g.policy.TemplateStaleness <= 0 ||
+		sel.tipHash != best.Hash ||
+		time.Since(sel.created) >= g.policy.TemplateStaleness {
+
+		return nil
+	}
+
+	return sel
+}
+
+// updateSelection incrementally updates the passed selection with the changes
+// of the source pool since it was last updated.  Selected transactions which
+// have left the source pool are removed along with the selected transactions
+// spending their outputs, and the transactions which have entered the source
+// pool are added in order of the fee rate of the packages they form with the
+// other new transactions when they fit.  Transactions whose fee delta changed
+// are treated as if they left the source pool and entered it again.
+//
+// This function MUST be called with the cache lock held.
+func (g *BlkTmplGenerator) updateSelection(sel *txSelection) {
+	// Nothing to do when the source pool has not changed.  The update time
+	// is queried before the transactions, so any changes racing with the
+	// update are picked up next time.
+	lastTxUpdate := g.txSource.LastUpdated()
+	if lastTxUpdate.Equal(sel.lastTxUpdate) {
+		return
+	}
+	sel.lastTxUpdate = lastTxUpdate
+
+	sourceTxns := g.txSource.MiningDescs()
+	source := make(map[chainhash.Hash]*TxDesc, len(sourceTxns))
+	for _, txDesc := range sourceTxns {
+		source[*txDesc.Tx.Hash()] = txDesc
+	}
+
+	// changed returns whether the transaction with the passed hash left
+	// the source pool or had its fee delta changed since it was
+	// considered.
+	changed := func(hash chainhash.Hash, feeDelta int64) bool {
+		txDesc, ok := source[hash]
+		return !ok || txDesc.FeeDelta != feeDelta
+	}
+
+	// Remove the selected transactions which are no longer in the source
+	// pool, such as replaced or evicted ones, or whose fee delta changed
+	// along with the ones spending their outputs.  The transactions are in
+	// dependency order, so a single pass finds all descendants.
+	removed := make(map[chainhash.Hash]struct{})
+	n := 0
+	for i, tx := range sel.txns {
+		stale := changed(*tx.Hash(), sel.considered[*tx.Hash()])
+		for _, txIn := range tx.MsgTx().TxIn {
+			if _, ok := removed[txIn.PreviousOutPoint.Hash]; ok {
+				stale = true
+				break
+			}
+		}
+		if stale {
+			log.Tracef("Removing tx %s from block template", tx.Hash())
+			removed[*tx.Hash()] = struct{}{}
+			sel.weight -= uint32(blockchain.GetTransactionWeight(tx))
+			sel.sigOpCost -= sel.sigOpCosts[i]
+			continue
+		}
+
+		sel.txns[n] = tx
+		sel.fees[n] = sel.fees[i]
+		sel.sigOpCosts[n] = sel.sigOpCosts[i]
+		n++
+	}
+	sel.txns = sel.txns[:n]
+	sel.fees = sel.fees[:n]
+	sel.sigOpCosts = sel.sigOpCosts[:n]
+
+	// Forget about the transactions which left the source pool or whose
+	// fee delta changed and the removed descendants, so they are
+	// considered again should they still be in the source pool.
+	for hash, feeDelta := range sel.considered {
+		_, wasRemoved := removed[hash]
+		if wasRemoved || changed(hash, feeDelta) {
+			delete(sel.considered, hash)
+		}
+	}
+
+	// Add the transactions which have not been considered yet.
+	var pending []*TxDesc
+	for _, txDesc := range sourceTxns {
+		hash := *txDesc.Tx.Hash()
+		if _, ok := sel.considered[hash]; ok {
+			continue
+		}
+		sel.considered[hash] = txDesc.FeeDelta
+		pending = append(pending, txDesc)
+	}
+	added := g.addPackages(sel, pending, source)
+
+	log.Debugf("Updated block template transactions (%d removed, %d "+
+		"added)", len(removed), added)
+}
+
+// addPackages adds the passed candidate transactions to the selection in order
+// of the fee rate of the packages they form with their candidate ancestors.
+// The source map holds the transactions of the source pool.  Candidates
+// spending the outputs of transactions of the source pool which are neither
+// selected nor candidates themselves are skipped.  It returns the number of
+// transactions which were added.
+//
+// This function MUST be called with the cache lock held.
+func (g *BlkTmplGenerator) addPackages(sel *txSelection, candidates []*TxDesc,
+	source map[chainhash.Hash]*TxDesc) int {
+
+	// Keep track of the outputs the selected transactions create and
+	// spend.
+	selected := make(map[chainhash.Hash]*btcutil.Tx, len(sel.txns))
+	spent := make(map[wire.OutPoint]struct{})
+	for _, tx := range sel.txns {
+		selected[*tx.Hash()] = tx
+		for _, txIn := range tx.MsgTx().TxIn {
+			spent[txIn.PreviousOutPoint] = struct{}{}
+		}
+	}
+
+	// Create the package items for the candidates which can be included
+	// in the block at all.
+	items := make(map[chainhash.Hash]*txPackageItem, len(candidates))
+	for _, txDesc := range candidates {
+		// A block can't have more than one coinbase or contain
+		// non-finalized transactions.  Transactions with witness data
+		// can't be included before segwit has activated either.
+		tx := txDesc.Tx
+		if blockchain.IsCoinBase(tx) {
+			log.Tracef("Skipping coinbase tx %s", tx.Hash())
+			continue
+		}
+		if !blockchain.IsFinalizedTransaction(tx, sel.height,
+			g.timeSource.AdjustedTime()) {
+
+			log.Tracef("Skipping non-finalized tx %s", tx.Hash())
+			continue
+		}
+		if tx.HasWitness() && !sel.segwitActive {
+			log.Tracef("Skipping witness tx %s", tx.Hash())
+			continue
+		}
+
+		// The virtual size is the weight scaled down and rounded
+		// up.
+		weight := blockchain.GetTransactionWeight(tx)
+		size := (weight + blockchain.WitnessScaleFactor - 1) /
+			blockchain.WitnessScaleFactor
+		items[*tx.Hash()] = &txPackageItem{
+			desc:        txDesc,
+			fee:         txDesc.Fee + txDesc.FeeDelta,
+			size:        size,
+			weight:      weight,
+			descendants: make(map[chainhash.Hash]*txPackageItem),
+		}
+	}
+
+	// Gather the ancestors of every candidate, skipping the ones which
+	// depend on transactions of the source pool which can't be included
+	// along with their descendants.  Parents are resolved ahead of their
+	// children, so the ancestors of a transaction are those of its parents
+	// plus the parents themselves.
+	var resolve func(item *txPackageItem) bool
+	resolve = func(item *txPackageItem) bool {
+		if item.ancestors != nil {
+			return true
+		}
+		tx := item.desc.Tx
+		ancestors := make(map[chainhash.Hash]*txPackageItem)
+		for _, txIn := range tx.MsgTx().TxIn {
+			originHash := txIn.PreviousOutPoint.Hash
+			if _, ok := selected[originHash]; ok {
+				continue
+			}
+			parent, ok := items[originHash]
+			if !ok {
+				if _, ok := source[originHash]; ok {
+					log.Tracef("Skipping tx %s since it "+
+						"depends on %s", tx.Hash(),
+						originHash)
+					return false
+				}
+				continue
+			}
+			if !resolve(parent) {
+				log.Tracef("Skipping tx %s since it depends "+
+					"on %s", tx.Hash(), originHash)
+				return false
+			}
+			ancestors[originHash] = parent
+			for hash, ancestor := range parent.ancestors {
+				ancestors[hash] = ancestor
+			}
+		}
+
+		item.ancestors = ancestors
+		item.numAncestors = len(ancestors)
+		item.ancestorFee = item.fee
+		item.ancestorSize = item.size
+		item.ancestorWeight = item.weight
+		for _, ancestor := range ancestors {
+			item.ancestorFee += ancestor.fee
+			item.ancestorSize += ancestor.size
+			item.ancestorWeight += ancestor.weight
+		}
+		return true
+	}
+	for hash, item := range items {
+		if !resolve(item) {
+			delete(items, hash)
+		}
+	}
+
+	// Queue the remaining candidates.
+	queue := make(txPackageQueue, 0, len(items))
+	for hash, item := range items {
+		for _, ancestor := range item.ancestors {
+			ancestor.descendants[hash] = item
+		}
+		queue = append(queue, &txPackageEntry{
+			item: item,
+			fee:  item.ancestorFee,
+			size: item.ancestorSize,
+		})
+	}
+	heap.Init(&queue)
+
+	// markFailed marks the passed item along with its descendants as
+	// failed, since they can't be included without it.
+	markFailed := func(item *txPackageItem) {
+		item.failed = true
+		logSkippedDeps(item.desc.Tx, item.descendants)
+		for _, descendant := range item.descendants {
+			descendant.failed = true
+		}
+	}
+
+	// Choose which packages make it into the block.
+	var added int
+	for item := queue.pop(); item != nil; item = queue.pop() {
+		tx := item.desc.Tx
+
+		// Enforce the maximum block weight for the whole package.
+		// Also check for overflow.
+		pkgWeight := uint32(item.ancestorWeight)
+		if !sel.witnessIncluded {
+			hasWitness := tx.HasWitness()
+			for _, ancestor := range item.ancestors {
+				hasWitness = hasWitness || ancestor.desc.Tx.HasWitness()
+			}
+			if hasWitness {
+				pkgWeight += sel.commitmentWeight
+			}
+		}
+		blockPlusPkgWeight := sel.weight + pkgWeight
+		if blockPlusPkgWeight < sel.weight ||
+			blockPlusPkgWeight >= g.policy.BlockMaxWeight {
+
+			log.Tracef("Skipping tx %s because its package would "+
+				"exceed the max block weight", tx.Hash())
+			markFailed(item)
+			continue
+		}
+
+		// Skip free packages once the block is larger than the minimum
+		// block weight.
+		pkgFeePerKB := item.ancestorFee * 1000 / item.ancestorSize
+		if pkgFeePerKB < int64(g.policy.TxMinFreeFee) &&
+			blockPlusPkgWeight >= g.policy.BlockMinWeight {
+
+			log.Tracef("Skipping tx %s with package feePerKB %d "+
+				"< TxMinFreeFee %d and block weight %d >= "+
+				"minBlockWeight %d", tx.Hash(), pkgFeePerKB,
+				g.policy.TxMinFreeFee, blockPlusPkgWeight,
+				g.policy.BlockMinWeight)
+			markFailed(item)
+			continue
+		}
+
+		// Add the package with the parents ahead of their children.
+		pkg := make([]*txPackageItem, 0, len(item.ancestors)+1)
+		for _, ancestor := range item.ancestors {
+			pkg = append(pkg, ancestor)
+		}
+		pkg = append(pkg, item)
+		sort.Slice(pkg, func(i, j int) bool {
+			if pkg[i].numAncestors != pkg[j].numAncestors {
+				return pkg[i].numAncestors < pkg[j].numAncestors
+			}
+			return bytes.Compare(pkg[i].desc.Tx.Hash()[:],
+				pkg[j].desc.Tx.Hash()[:]) < 0
+		})
+
+		log.Tracef("Adding package of tx %s (%d transactions, "+
+			"feePerKB %d)", tx.Hash(), len(pkg), pkgFeePerKB)
+
+		for _, pkgItem := range pkg {
+			if !g.selectTx(sel, pkgItem.desc, source, selected,
+				spent) {
+
+				markFailed(pkgItem)
+				break
+			}
+			added++
+
+			// Remove the transaction from the packages of its
+			// descendants and queue them with their new totals.
+			pkgItem.included = true
+			pkgHash := *pkgItem.desc.Tx.Hash()
+			for _, descendant := range pkgItem.descendants {
+				delete(descendant.ancestors, pkgHash)
+				descendant.ancestorFee -= pkgItem.fee
+				descendant.ancestorSize -= pkgItem.size
+				descendant.ancestorWeight -= pkgItem.weight
+				if !descendant.failed {
+					queue.push(descendant)
+				}
+			}
+		}
+	}
+
+	return added
+}
+
+// selectTx attempts to add the passed transaction to the selection and returns
+// whether it was added.  The source map holds the transactions of the source
+// pool, while the selected map and spent set describe the transactions in the
+// selection and the outputs they spend and are updated when the transaction is
+// added.
+//
+// This function MUST be called with the cache lock held.
+func (g *BlkTmplGenerator) selectTx(sel *txSelection, txDesc *TxDesc,
+	source map[chainhash.Hash]*TxDesc,
+	selected map[chainhash.Hash]*btcutil.Tx,
+	spent map[wire.OutPoint]struct{}) bool {
+
+	// Skip the transaction when it double spends a selected one or spends
+	// the outputs of a transaction of the source pool which wasn't
+	// selected.
+	tx := txDesc.Tx
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		if _, ok := spent[prevOut]; ok {
+			log.Tracef("Skipping tx %s since it double spends %v",
+				tx.Hash(), prevOut)
+			return false
+		}
+		if _, ok := selected[prevOut.Hash]; ok {
+			continue
+		}
+		if _, ok := source[prevOut.Hash]; ok {
+			log.Tracef("Skipping tx %s since it depends on %s",
+				tx.Hash(), prevOut.Hash)
+			return false
+		}
+	}
+
+	// Fetch the confirmed outputs the transaction spends and add the ones
+	// created by the selected transactions.
+	utxos, err := g.chain.FetchUtxoView(tx)
+	if err != nil {
+		log.Warnf("Unable to fetch utxo view for tx %s: %v",
+			tx.Hash(), err)
+		return false
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		if parent, ok := selected[prevOut.Hash]; ok {
+			utxos.AddTxOut(parent, prevOut.Index, sel.height)
+		}
+	}
+
+	// Enforce the block weight and signature operation limits.
+	txWeight := uint32(blockchain.GetTransactionWeight(tx))
+	weight := sel.weight + txWeight
+	if tx.HasWitness() && !sel.witnessIncluded {
+		weight += sel.commitmentWeight
+	}
+	if weight < sel.weight || weight >= g.policy.BlockMaxWeight {
+		log.Tracef("Skipping tx %s because it would exceed the max "+
+			"block weight", tx.Hash())
+		return false
+	}
+	sigOpCost, err := blockchain.GetSigOpCost(tx, false, utxos, true,
+		sel.segwitActive)
+	if err != nil {
+		log.Tracef("Skipping tx %s due to error in GetSigOpCost: %v",
+			tx.Hash(), err)
+		return false
+	}
+	if sel.sigOpCost+int64(sigOpCost) < sel.sigOpCost ||
+		sel.sigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+
+		log.Tracef("Skipping tx %s because it would exceed the "+
+			"maximum sigops per block", tx.Hash())
+		return false
+	}
+
+	// Ensure the transaction inputs pass all of the necessary
+	// preconditions before allowing it to be added to the block.
+	fee, err := blockchain.CheckTransactionInputs(tx, sel.height, utxos,
+		g.chainParams)
+	if err != nil {
+		log.Tracef("Skipping tx %s due to error in "+
+			"CheckTransactionInputs: %v", tx.Hash(), err)
+		return false
+	}
+	err = blockchain.ValidateTransactionScripts(tx, utxos,
+		txscript.StandardVerifyFlags, g.sigCache, g.hashCache)
+	if err != nil {
+		log.Tracef("Skipping tx %s due to error in "+
+			"ValidateTransactionScripts: %v", tx.Hash(), err)
+		return false
+	}
+
+	log.Tracef("Adding tx %s (feePerKB %d) to block template", tx.Hash(),
+		txDesc.FeePerKB)
+
+	sel.txns = append(sel.txns, tx)
+	sel.fees = append(sel.fees, fee)
+	sel.sigOpCosts = append(sel.sigOpCosts, int64(sigOpCost))
+	sel.weight = weight
+	sel.sigOpCost += int64(sigOpCost)
+	sel.witnessIncluded = sel.witnessIncluded || tx.HasWitness()
+	selected[*tx.Hash()] = tx
+	for _, txIn := range tx.MsgTx().TxIn {
+		spent[txIn.PreviousOutPoint] = struct{}{}
+	}
+
+	return true
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakeTxSource provides a transaction source whose contents are controlled by
+// the tests.
+type fakeTxSource struct {
+	descs       []*TxDesc
+	lastUpdated time.Time
+}
+
+// LastUpdated returns the last time a transaction was added to or removed from
+// the source.
+func (s *fakeTxSource) LastUpdated() time.Time {
+	return s.lastUpdated
+}
+
+// MiningDescs returns the descriptors of all transactions in the source.
+func (s *fakeTxSource) MiningDescs() []*TxDesc {
+	return s.descs
+}
+
+// HaveTransaction returns whether or not the passed transaction is in the
+// source.
+func (s *fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, desc := range s.descs {
+		if *desc.Tx.Hash() == *hash {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the passed transaction paying the passed fee to the source.
+func (s *fakeTxSource) add(tx *btcutil.Tx, fee int64) {
+	s.descs = append(s.descs, &TxDesc{
+		Tx:       tx,
+		Added:    time.Now(),
+		Fee:      fee,
+		FeePerKB: fee * 1000 / int64(tx.MsgTx().SerializeSize()),
+	})
+	s.lastUpdated = s.lastUpdated.Add(time.Second)
+}
+
+// remove removes the passed transaction from the source.
+func (s *fakeTxSource) remove(tx *btcutil.Tx) {
+	for i, desc := range s.descs {
+		if *desc.Tx.Hash() == *tx.Hash() {
+			s.descs = append(s.descs[:i], s.descs[i+1:]...)
+			break
+		}
+	}
+	s.lastUpdated = s.lastUpdated.Add(time.Second)
+}
+
+// prioritise sets the fee delta of the passed transaction of the source.
+func (s *fakeTxSource) prioritise(tx *btcutil.Tx, feeDelta int64) {
+	for i, desc := range s.descs {
+		if *desc.Tx.Hash() == *tx.Hash() {
+			prioritised := *desc
+			prioritised.FeeDelta = feeDelta
+			s.descs[i] = &prioritised
+		}
+	}
+	s.lastUpdated = s.lastUpdated.Add(time.Second)
+}
+
+// spendTx returns a transaction spending the passed anyone-can-spend output
+// of the passed transaction to another anyone-can-spend output, leaving the
+// passed fee.
+func spendTx(parent *btcutil.Tx, fee int64) *btcutil.Tx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(parent.Hash(), 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(parent.MsgTx().TxOut[0].Value-fee,
+		[]byte{txscript.OP_TRUE}))
+	return btcutil.NewTx(tx)
+}
+
+// newTestGenerator returns a block template generator using the passed policy
+// and source on a new regression test chain along with the coinbases of the
+// blocks it mined until the first few of them matured.
+func newTestGenerator(t *testing.T, policy *Policy,
+	source *fakeTxSource) (*BlkTmplGenerator, []*btcutil.Tx) {
+
+	t.Helper()
+
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", t.TempDir(), params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	timeSource := blockchain.NewMedianTime()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  timeSource,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	g := NewBlkTmplGenerator(policy, &params, source, chain, timeSource,
+		txscript.NewSigCache(100), txscript.NewHashCache(100))
+
+	// Mine enough blocks for the first few coinbase outputs to mature.
+	var coinbases []*btcutil.Tx
+	for i := 0; i < int(params.CoinbaseMaturity)+4; i++ {
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("NewBlockTemplate: unexpected error: %v", err)
+		}
+		header := &template.Block.Header
+		target := blockchain.CompactToBig(header.Bits)
+		for {
+			hash := header.BlockHash()
+			if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+				break
+			}
+			header.Nonce++
+		}
+		block := btcutil.NewBlock(template.Block)
+		if _, _, err := chain.ProcessBlock(block, blockchain.BFNone); err != nil {
+			t.Fatalf("ProcessBlock: unexpected error: %v", err)
+		}
+		coinbases = append(coinbases, block.Transactions()[0])
+	}
+
+	return g, coinbases
+}
+
+// checkTemplate creates a new block template with the passed generator and
+// ensures it contains the passed transactions in order along with a coinbase
+// claiming their fees.
+func checkTemplate(t *testing.T, g *BlkTmplGenerator, desc string,
+	wantTxns ...*btcutil.Tx) *BlockTemplate {
+
+	t.Helper()
+
+	template, err := g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("%s: NewBlockTemplate: unexpected error: %v", desc, err)
+	}
+	txns := template.Block.Transactions[1:]
+	if len(txns) != len(wantTxns) {
+		t.Fatalf("%s: got %d transactions, want %d", desc, len(txns),
+			len(wantTxns))
+	}
+	var totalFees int64
+	for i, tx := range txns {
+		if tx.TxHash() != *wantTxns[i].Hash() {
+			t.Fatalf("%s: transaction %d is %v, want %v", desc, i,
+				tx.TxHash(), wantTxns[i].Hash())
+		}
+		totalFees += template.Fees[i+1]
+	}
+	if template.Fees[0] != -totalFees {
+		t.Fatalf("%s: coinbase fee is %d, want %d", desc,
+			template.Fees[0], -totalFees)
+	}
+	return template
+}
+
+// TestTemplateCache ensures the transactions of block templates are
+// incrementally updated as the source pool changes and selected from scratch
+// once they are stale.
+func TestTemplateCache(t *testing.T) {
+	policy := &Policy{
+		BlockMaxWeight:    blockchain.MaxBlockWeight - 4000,
+		TemplateStaleness: time.Hour,
+	}
+	source := &fakeTxSource{lastUpdated: time.Unix(1, 0)}
+	g, coinbases := newTestGenerator(t, policy, source)
+
+	// The first template after a new block selects the transactions from
+	// scratch.
+	txA := spendTx(coinbases[0], 1000)
+	txB := spendTx(txA, 2000)
+	source.add(txA, 1000)
+	source.add(txB, 2000)
+	checkTemplate(t, g, "initial", txA, txB)
+	created := g.cache.created
+
+	// New packages are appended with the parents ahead of their children
+	// regardless of their fees.
+	txC := spendTx(coinbases[1], 1000)
+	txD := spendTx(txC, 50000)
+	source.add(txD, 50000)
+	source.add(txC, 1000)
+	checkTemplate(t, g, "added package", txA, txB, txC, txD)
+	if !g.cache.created.Equal(created) {
+		t.Fatal("added package: transactions were selected from scratch")
+	}
+
+	// Removing a transaction removes its descendants as well, and
+	// transactions double spending a selected one are skipped.
+	txE := spendTx(coinbases[1], 3000)
+	source.remove(txA)
+	source.add(txE, 3000)
+	checkTemplate(t, g, "removed parent", txC, txD)
+	if !g.cache.created.Equal(created) {
+		t.Fatal("removed parent: transactions were selected from scratch")
+	}
+
+	// Stale selections are made from scratch, which reconsiders the
+	// transactions skipped before.
+	source.remove(txC)
+	source.remove(txD)
+	policy.TemplateStaleness = 0
+	checkTemplate(t, g, "stale", txE)
+	if g.cache.created.Equal(created) {
+		t.Fatal("stale: transactions were not selected from scratch")
+	}
+}
+
+// TestAncestorFeeRate ensures transactions are selected according to the fee
+// rate of the packages they form with their unconfirmed ancestors, so children
+// paying high fees pull in their low-fee parents, both when the transactions
+// are selected from scratch and when they are incrementally updated.
+func TestAncestorFeeRate(t *testing.T) {
+	policy := &Policy{
+		BlockMaxWeight:    blockchain.MaxBlockWeight - 4000,
+		TxMinFreeFee:      1000,
+		TemplateStaleness: time.Hour,
+	}
+	source := &fakeTxSource{lastUpdated: time.Unix(1, 0)}
+	g, coinbases := newTestGenerator(t, policy, source)
+
+	// Limit the block to two transactions on top of the coinbase.
+	checkTemplate(t, g, "empty")
+	txWeight := uint32(blockchain.GetTransactionWeight(
+		spendTx(coinbases[0], 0)))
+	policy.BlockMaxWeight = g.cache.weight + 2*txWeight + 1
+
+	// The free parent is selected along with its child, whose fee makes up
+	// for it, ahead of the transaction paying the higher fee on its own.
+	txA := spendTx(coinbases[0], 5000)
+	txB := spendTx(coinbases[1], 0)
+	txC := spendTx(txB, 20000)
+	source.add(txA, 5000)
+	source.add(txB, 0)
+	source.add(txC, 20000)
+	checkTemplate(t, g, "from scratch", txB, txC)
+
+	// Packages are added the same way when the transactions are updated
+	// incrementally.
+	created := g.cache.created
+	policy.BlockMaxWeight = blockchain.MaxBlockWeight - 4000
+	txD := spendTx(coinbases[2], 0)
+	txE := spendTx(txD, 15000)
+	source.add(txD, 0)
+	source.add(txE, 15000)
+	checkTemplate(t, g, "incremental", txB, txC, txD, txE)
+	if !g.cache.created.Equal(created) {
+		t.Fatal("incremental: transactions were selected from scratch")
+	}
+
+	// Free transactions without children paying for them are skipped.
+	txF := spendTx(coinbases[3], 0)
+	source.add(txF, 0)
+	policy.TemplateStaleness = 0
+	checkTemplate(t, g, "free", txB, txC, txD, txE, txA)
+}
+
+// TestFeeDelta ensures the fee deltas of transactions are taken into account
+// when selecting them while the templates pay the actual fees, and that
+// changing a fee delta updates the cached selection.
+func TestFeeDelta(t *testing.T) {
+	policy := &Policy{
+		BlockMaxWeight:    blockchain.MaxBlockWeight - 4000,
+		TxMinFreeFee:      1000,
+		TemplateStaleness: time.Hour,
+	}
+	source := &fakeTxSource{lastUpdated: time.Unix(1, 0)}
+	g, coinbases := newTestGenerator(t, policy, source)
+
+	// Limit the block to one transaction on top of the coinbase.
+	checkTemplate(t, g, "empty")
+	txWeight := uint32(blockchain.GetTransactionWeight(
+		spendTx(coinbases[0], 0)))
+	policy.BlockMaxWeight = g.cache.weight + txWeight + 1
+
+	// A free transaction with a fee delta is selected over one paying a
+	// higher fee.
+	txA := spendTx(coinbases[0], 5000)
+	txB := spendTx(coinbases[1], 0)
+	source.add(txA, 5000)
+	source.add(txB, 0)
+	source.prioritise(txB, 10000)
+	template := checkTemplate(t, g, "prioritised", txB)
+	if template.Fees[1] != 0 {
+		t.Fatalf("prioritised: fee is %d, want the actual fee 0",
+			template.Fees[1])
+	}
+
+	// Deprioritizing the transaction removes it when the selection is
+	// updated incrementally since it no longer pays the minimum fee.  The
+	// transaction skipped before is only considered again once the
+	// selection is made from scratch.
+	created := g.cache.created
+	source.prioritise(txB, -10000)
+	checkTemplate(t, g, "deprioritised")
+	if !g.cache.created.Equal(created) {
+		t.Fatal("deprioritised: transactions were selected from scratch")
+	}
+	policy.TemplateStaleness = 0
+	checkTemplate(t, g, "stale", txA)
+}
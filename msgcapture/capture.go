@@ -0,0 +1,238 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package msgcapture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// SentFileName is the name of the file the messages sent to a peer are
+	// recorded in.
+	SentFileName = "msgs_sent.dat"
+
+	// RecvFileName is the name of the file the messages received from a
+	// peer are recorded in.
+	RecvFileName = "msgs_recv.dat"
+
+	// recordHeaderLen is the length of the header of a record.  It is the
+	// time in microseconds, the command and the length of the payload.
+	recordHeaderLen = 8 + wire.CommandSize + 4
+
+	// dirTimeFormat is the format of the time the connection was made in
+	// the names of the capture directories.
+	dirTimeFormat = "20060102T150405"
+)
+
+var (
+	// byteOrder is the byte order of the integers of the records.
+	byteOrder = binary.LittleEndian
+
+	// ErrClosed is returned when writing to a closed writer, which includes
+	// writers closed because a previous write failed.
+	ErrClosed = errors.New("message capture closed")
+)
+
+// Record is a message sent to or received from a peer.
+type Record struct {
+	// Time is the time the message was sent or received with microsecond
+	// precision.
+	Time time.Time
+
+	// Command is the command of the message.
+	Command string
+
+	// Payload is the serialized message without the header.
+	Payload []byte
+}
+
+// WriteRecord writes the passed record to w.  The record is the time in
+// microseconds since 1 Jan 1970 UTC as a little-endian int64, the command
+// padded with zeros to 12 bytes, the length of the payload as a little-endian
+// uint32 and the payload, which is the framing Bitcoin Core uses for captured
+// messages.
+func WriteRecord(w io.Writer, rec *Record) error {
+	if len(rec.Command) > wire.CommandSize {
+		return fmt.Errorf("command %q is longer than %d bytes",
+			rec.Command, wire.CommandSize)
+	}
+
+	buf := make([]byte, recordHeaderLen+len(rec.Payload))
+	byteOrder.PutUint64(buf, uint64(rec.Time.UnixNano()/1000))
+	copy(buf[8:], rec.Command)
+	byteOrder.PutUint32(buf[8+wire.CommandSize:], uint32(len(rec.Payload)))
+	copy(buf[recordHeaderLen:], rec.Payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadRecord reads a record written by WriteRecord from r.  io.EOF is returned
+// when there are no more records and io.ErrUnexpectedEOF when the last record
+// is truncated.
+func ReadRecord(r io.Reader) (*Record, error) {
+	var hdr [recordHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	micros := int64(byteOrder.Uint64(hdr[:]))
+	command := string(bytes.TrimRight(hdr[8:8+wire.CommandSize], "\x00"))
+	length := byteOrder.Uint32(hdr[8+wire.CommandSize:])
+	if length > wire.MaxMessagePayload {
+		return nil, fmt.Errorf("payload of %d bytes of %s message "+
+			"exceeds the maximum of %d bytes", length, command,
+			wire.MaxMessagePayload)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return &Record{
+		Time:    time.Unix(0, micros*1000),
+		Command: command,
+		Payload: payload,
+	}, nil
+}
+
+// Decode decodes the payload of the record into the message of its command
+// using the passed protocol version and encoding.
+func (rec *Record) Decode(pver uint32, enc wire.MessageEncoding) (wire.Message, error) {
+	// Prefix the payload with a message header so the message can be
+	// decoded by the wire package.
+	var buf bytes.Buffer
+	buf.Grow(wire.MessageHeaderSize + len(rec.Payload))
+	var hdr [wire.MessageHeaderSize]byte
+	byteOrder.PutUint32(hdr[:], uint32(wire.MainNet))
+	copy(hdr[4:], rec.Command)
+	byteOrder.PutUint32(hdr[16:], uint32(len(rec.Payload)))
+	copy(hdr[20:], chainhash.DoubleHashB(rec.Payload)[:4])
+	buf.Write(hdr[:])
+	buf.Write(rec.Payload)
+
+	_, msg, _, err := wire.ReadMessageWithEncodingN(&buf, pver,
+		wire.MainNet, enc)
+	return msg, err
+}
+
+// DirName returns the name of the directory the messages of a connection to
+// the passed address made at the passed time are recorded in.  The characters
+// of the address which are not safe in file names are replaced.
+func DirName(addr string, connected time.Time) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, addr)
+	return safe + "_" + connected.UTC().Format(dirTimeFormat)
+}
+
+// Writer records the messages sent to and received from a peer in the
+// SentFileName and RecvFileName files of a directory.
+//
+// All methods are safe for concurrent access.
+type Writer struct {
+	mtx    sync.Mutex
+	sent   *os.File
+	recv   *os.File
+	closed bool
+}
+
+// NewWriter returns a writer which records the messages of the connection to
+// the passed address made at the passed time in a directory named by DirName
+// under the passed directory.
+func NewWriter(dir, addr string, connected time.Time) (*Writer, error) {
+	dir = filepath.Join(dir, DirName(addr, connected))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	sent, err := os.OpenFile(filepath.Join(dir, SentFileName), flags, 0600)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := os.OpenFile(filepath.Join(dir, RecvFileName), flags, 0600)
+	if err != nil {
+		sent.Close()
+		return nil, err
+	}
+	return &Writer{sent: sent, recv: recv}, nil
+}
+
+// Write records the passed message, which is serialized with the passed
+// protocol version and encoding, as sent to the peer when sent is true and as
+// received from it otherwise.  The writer is closed when writing fails, so the
+// error is only returned once and ErrClosed afterwards.
+func (w *Writer) Write(msg wire.Message, pver uint32, enc wire.MessageEncoding,
+	sent bool) error {
+
+	var payload bytes.Buffer
+	if err := msg.BtcEncode(&payload, pver, enc); err != nil {
+		return err
+	}
+	rec := &Record{
+		Time:    time.Now(),
+		Command: msg.Command(),
+		Payload: payload.Bytes(),
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	f := w.recv
+	if sent {
+		f = w.sent
+	}
+	if err := WriteRecord(f, rec); err != nil {
+		w.close()
+		return err
+	}
+	return nil
+}
+
+// close closes the files of the writer.  It must be called with the mutex
+// held.
+func (w *Writer) close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.sent.Close()
+	if recvErr := w.recv.Close(); err == nil {
+		err = recvErr
+	}
+	return err
+}
+
+// Close closes the writer.
+func (w *Writer) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.close()
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package msgcapture
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestRecord ensures records round trip and truncated records are detected.
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	recs := []*Record{
+		{Time: time.Unix(1700000000, 123456000), Command: "verack"},
+		{Time: time.Unix(1700000001, 1000), Command: "ping",
+			Payload: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+	}
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		if err := WriteRecord(&buf, rec); err != nil {
+			t.Fatalf("WriteRecord: unexpected error: %v", err)
+		}
+	}
+	if buf.Len() != 2*recordHeaderLen+8 {
+		t.Fatalf("unexpected length %d of serialized records", buf.Len())
+	}
+	serialized := buf.Bytes()
+
+	for _, want := range recs {
+		got, err := ReadRecord(&buf)
+		if err != nil {
+			t.Fatalf("ReadRecord: unexpected error: %v", err)
+		}
+		if !got.Time.Equal(want.Time) || got.Command != want.Command ||
+			!bytes.Equal(got.Payload, want.Payload) {
+
+			t.Fatalf("ReadRecord: got %+v, want %+v", got, want)
+		}
+	}
+	if _, err := ReadRecord(&buf); err != io.EOF {
+		t.Fatalf("ReadRecord at end: got %v, want %v", err, io.EOF)
+	}
+
+	truncated := bytes.NewReader(serialized[:len(serialized)-1])
+	ReadRecord(truncated)
+	if _, err := ReadRecord(truncated); err != io.ErrUnexpectedEOF {
+		t.Fatalf("ReadRecord of truncated record: got %v, want %v", err,
+			io.ErrUnexpectedEOF)
+	}
+
+	err := WriteRecord(&buf, &Record{Command: "longer than twelve"})
+	if err == nil {
+		t.Fatal("WriteRecord: expected error for long command")
+	}
+}
+
+// TestWriter ensures the messages recorded by a writer are written to the
+// intended files and decode to the original messages.
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	connected := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	w, err := NewWriter(dir, "[::1]:8333", connected)
+	if err != nil {
+		t.Fatalf("NewWriter: unexpected error: %v", err)
+	}
+
+	ping := wire.NewMsgPing(42)
+	getData := wire.NewMsgGetData()
+	getData.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessBlock,
+		&chainhash.Hash{1}))
+	if err := w.Write(ping, wire.ProtocolVersion, wire.BaseEncoding, true); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	err = w.Write(getData, wire.ProtocolVersion, wire.WitnessEncoding, false)
+	if err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	err = w.Write(ping, wire.ProtocolVersion, wire.BaseEncoding, true)
+	if err != ErrClosed {
+		t.Fatalf("Write after Close: got %v, want %v", err, ErrClosed)
+	}
+
+	captureDir := filepath.Join(dir, "___1__8333_20240102T150405")
+	for name, want := range map[string]wire.Message{
+		SentFileName: ping,
+		RecvFileName: getData,
+	} {
+		f, err := os.Open(filepath.Join(captureDir, name))
+		if err != nil {
+			t.Fatalf("unable to open %s: %v", name, err)
+		}
+		defer f.Close()
+
+		rec, err := ReadRecord(f)
+		if err != nil {
+			t.Fatalf("ReadRecord(%s): unexpected error: %v", name, err)
+		}
+		msg, err := rec.Decode(wire.ProtocolVersion, wire.WitnessEncoding)
+		if err != nil {
+			t.Fatalf("Decode(%s): unexpected error: %v", name, err)
+		}
+		if !reflect.DeepEqual(msg, want) {
+			t.Fatalf("Decode(%s): got %v, want %v", name, msg, want)
+		}
+		if _, err := ReadRecord(f); err != io.EOF {
+			t.Fatalf("ReadRecord(%s) at end: got %v, want %v", name,
+				err, io.EOF)
+		}
+	}
+}
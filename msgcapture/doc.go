@@ -0,0 +1,24 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package msgcapture records the P2P messages sent to and received from peers for
+debugging the protocol.
+
+The messages of each connection are recorded in a directory named after the
+address of the peer and the time the connection was made, such as
+127.0.0.1_8333_20240102T150405, which holds a msgs_sent.dat and a msgs_recv.dat
+file.  Each file is a sequence of records of the form:
+
+	time (8 bytes)     - microseconds since 1 Jan 1970 UTC, little-endian
+	command (12 bytes) - the command of the message padded with zeros
+	length (4 bytes)   - the length of the payload, little-endian
+	payload            - the serialized message without its header
+
+This is the framing used by Bitcoin Core, so the files can be inspected with
+the tools of either implementation.  The payloads are serialized again from the
+decoded messages, so they use the protocol version and encoding negotiated with
+the peer at the time of the message.
+*/
+package msgcapture
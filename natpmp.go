@@ -0,0 +1,429 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pmpPort is the port NAT-PMP and PCP servers listen on at the gateway.
+	pmpPort = 5351
+
+	// pmpVersion and pcpVersion are the versions of the NAT-PMP and PCP
+	// protocols.
+	pmpVersion = 0
+	pcpVersion = 2
+
+	// The NAT-PMP opcodes used to query the external address and to map TCP
+	// ports.  Responses have the high bit of the opcode set.
+	pmpOpExternalAddress = 0
+	pmpOpMapTCP          = 2
+
+	// The PCP opcodes used to probe for a server and to map ports.
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+
+	// pcpResponseBit is the bit of the opcode marking PCP responses.
+	pcpResponseBit = 0x80
+
+	// pcpProtocolTCP is the IANA protocol number of TCP used by PCP.
+	pcpProtocolTCP = 6
+
+	// pmpResultUnsupportedVersion is the result code servers reply with to
+	// requests for a version of the protocol they don't support.
+	pmpResultUnsupportedVersion = 1
+
+	// pcpHeaderLen and pcpMapLen are the lengths of the PCP request and
+	// response headers and of the payload of the MAP opcode.
+	pcpHeaderLen = 24
+	pcpMapLen    = 36
+
+	// pmpInitialTimeout is the time to wait for the first response of the
+	// gateway.  It doubles with every retransmission.
+	pmpInitialTimeout = 250 * time.Millisecond
+
+	// pmpMaxAttempts is the number of times requests are sent before giving
+	// up.  RFC 6886 suggests up to 9 attempts, which would block the
+	// startup for more than a minute when there is no server, so this is
+	// lower.
+	pmpMaxAttempts = 4
+)
+
+// errNoPMPResponse is returned when the gateway doesn't respond to NAT-PMP or
+// PCP requests.
+var errNoPMPResponse = errors.New("no response from gateway")
+
+// pmpResultErrors describes the result codes of NAT-PMP and PCP responses
+// which indicate failures.  The codes above 5 are only defined by PCP.
+var pmpResultErrors = map[byte]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "network failure",
+	4:  "out of resources",
+	5:  "unsupported opcode",
+	6:  "unsupported option",
+	7:  "malformed option",
+	8:  "network failure",
+	9:  "no resources",
+	10: "unsupported protocol",
+	11: "user exceeded quota",
+	12: "cannot provide external address",
+	13: "address mismatch",
+	14: "excessive remote peers",
+}
+
+// pmpResultError returns an error describing the passed failed result code.
+func pmpResultError(code byte) error {
+	if desc, ok := pmpResultErrors[code]; ok {
+		return fmt.Errorf("gateway returned error: %s", desc)
+	}
+	return fmt.Errorf("gateway returned error code %d", code)
+}
+
+// pmpNAT implements the NAT interface for gateways supporting NAT-PMP, as
+// defined by RFC 6886, or its successor PCP, as defined by RFC 6887.
+type pmpNAT struct {
+	gateway *net.UDPAddr
+
+	// pcp is whether the gateway supports PCP rather than only NAT-PMP.
+	pcp bool
+
+	// mtx protects the fields below and serializes the requests.
+	mtx sync.Mutex
+
+	// nonce identifies the PCP mappings of this client.  The gateway only
+	// allows renewing and removing mappings with the nonce they were
+	// created with.
+	nonce [12]byte
+
+	// externalIP is the external address assigned by the last PCP mapping,
+	// since PCP has no request to query it on its own.
+	externalIP net.IP
+}
+
+// DiscoverPMP probes the default gateway for a PCP or NAT-PMP server and
+// returns a NAT for it if one responds.
+func DiscoverPMP() (NAT, error) {
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	n, err := newPMPNAT(&net.UDPAddr{IP: gateway, Port: pmpPort})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// newPMPNAT probes the passed address for a PCP or NAT-PMP server and returns
+// a NAT for it if one responds.
+func newPMPNAT(gateway *net.UDPAddr) (*pmpNAT, error) {
+	n := &pmpNAT{gateway: gateway, pcp: true}
+	if _, err := rand.Read(n.nonce[:]); err != nil {
+		return nil, err
+	}
+
+	// Probe for PCP first and fall back to NAT-PMP when the gateway only
+	// supports the older version of the protocol.
+	resp, err := n.pcpRequest(pcpOpAnnounce, 0, nil)
+	if err == nil {
+		return n, nil
+	}
+	if resp == nil || resp[0] != pmpVersion {
+		return nil, err
+	}
+	n.pcp = false
+	if _, err := n.GetExternalAddress(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Protocol returns the name of the protocol used to manipulate the NAT.  It is
+// part of the NAT interface implementation.
+func (n *pmpNAT) Protocol() string {
+	if n.pcp {
+		return "pcp"
+	}
+	return "natpmp"
+}
+
+// request sends the passed request to the gateway and returns the first
+// response accepted by the passed function, retransmitting the request with
+// exponential backoff until the attempts are exhausted.
+func (n *pmpNAT) request(req []byte, accept func([]byte) bool) ([]byte, error) {
+	conn, err := net.DialUDP("udp", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1100)
+	timeout := pmpInitialTimeout
+	for attempt := 0; attempt < pmpMaxAttempts; attempt++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		for {
+			nr, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if accept(buf[:nr]) {
+				return buf[:nr], nil
+			}
+		}
+		timeout *= 2
+	}
+	return nil, errNoPMPResponse
+}
+
+// pmpRequest sends the passed NAT-PMP request and returns the response, which
+// has been checked to be successful and at least respLen bytes long.
+func (n *pmpNAT) pmpRequest(req []byte, respLen int) ([]byte, error) {
+	resp, err := n.request(req, func(resp []byte) bool {
+		return len(resp) >= 4 && resp[0] == pmpVersion &&
+			resp[1] == req[1]|0x80
+	})
+	if err != nil {
+		return nil, err
+	}
+	if code := binary.BigEndian.Uint16(resp[2:]); code != 0 {
+		return nil, pmpResultError(byte(code))
+	}
+	if len(resp) < respLen {
+		return nil, fmt.Errorf("short NAT-PMP response of %d bytes",
+			len(resp))
+	}
+	return resp, nil
+}
+
+// pcpRequest sends a PCP request with the passed opcode, lifetime and payload
+// and returns the response.  A NAT-PMP response to it is returned along with
+// an error when the gateway doesn't support PCP.
+func (n *pmpNAT) pcpRequest(opcode byte, lifetime uint32, payload []byte) ([]byte, error) {
+	// The request includes the address the gateway sees the client at,
+	// which is the local address of a socket connected to the gateway.
+	clientIP, err := n.localIP()
+	if err != nil {
+		return nil, err
+	}
+	req := make([]byte, pcpHeaderLen, pcpHeaderLen+len(payload))
+	req[0] = pcpVersion
+	req[1] = opcode
+	binary.BigEndian.PutUint32(req[4:], lifetime)
+	copy(req[8:], clientIP.To16())
+	req = append(req, payload...)
+
+	resp, err := n.request(req, func(resp []byte) bool {
+		if len(resp) >= 4 && resp[0] == pmpVersion {
+			return true
+		}
+		return len(resp) >= pcpHeaderLen && resp[0] == pcpVersion &&
+			resp[1] == opcode|pcpResponseBit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp[0] == pmpVersion {
+		return resp, pmpResultError(pmpResultUnsupportedVersion)
+	}
+	if code := resp[3]; code != 0 {
+		return resp, pmpResultError(code)
+	}
+	return resp, nil
+}
+
+// localIP returns the local address used to reach the gateway.
+func (n *pmpNAT) localIP() (net.IP, error) {
+	conn, err := net.DialUDP("udp", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// GetExternalAddress returns the external address of the gateway.  It is part
+// of the NAT interface implementation.
+func (n *pmpNAT) GetExternalAddress() (net.IP, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if n.pcp {
+		if n.externalIP == nil {
+			return nil, errors.New("no port mapped via PCP")
+		}
+		return n.externalIP, nil
+	}
+
+	resp, err := n.pmpRequest([]byte{pmpVersion, pmpOpExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+// AddPortMapping maps the passed external port of the gateway to the passed
+// internal port for timeout seconds and returns the external port assigned
+// by the gateway, which might differ from the requested one.  Only TCP is
+// supported.  It is part of the NAT interface implementation.
+func (n *pmpNAT) AddPortMapping(protocol string, externalPort, internalPort int,
+	description string, timeout int) (int, error) {
+
+	if protocol != "tcp" {
+		return 0, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if n.pcp {
+		return n.pcpMap(externalPort, internalPort, uint32(timeout))
+	}
+	return n.pmpMap(externalPort, internalPort, uint32(timeout))
+}
+
+// DeletePortMapping removes the mapping of the passed internal port.  It is
+// part of the NAT interface implementation.
+func (n *pmpNAT) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	if protocol != "tcp" {
+		return fmt.Errorf("unsupported protocol %q", protocol)
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	// Mappings are removed by requesting them again with a zero lifetime.
+	var err error
+	if n.pcp {
+		_, err = n.pcpMap(0, internalPort, 0)
+		n.externalIP = nil
+	} else {
+		_, err = n.pmpMap(0, internalPort, 0)
+	}
+	return err
+}
+
+// pmpMap sends a NAT-PMP request to map the passed ports for the passed number
+// of seconds and returns the assigned external port.
+func (n *pmpNAT) pmpMap(externalPort, internalPort int, lifetime uint32) (int, error) {
+	req := make([]byte, 12)
+	req[0] = pmpVersion
+	req[1] = pmpOpMapTCP
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:], lifetime)
+
+	resp, err := n.pmpRequest(req, 16)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(resp[10:])), nil
+}
+
+// pcpMap sends a PCP MAP request to map the passed ports for the passed number
+// of seconds and returns the assigned external port.  The assigned external
+// address is recorded for GetExternalAddress.
+func (n *pmpNAT) pcpMap(externalPort, internalPort int, lifetime uint32) (int, error) {
+	payload := make([]byte, pcpMapLen)
+	copy(payload, n.nonce[:])
+	payload[12] = pcpProtocolTCP
+	binary.BigEndian.PutUint16(payload[16:], uint16(internalPort))
+	binary.BigEndian.PutUint16(payload[18:], uint16(externalPort))
+
+	// Suggest any IPv4 address, which is the IPv4-mapped unspecified
+	// address.
+	copy(payload[20:], net.IPv4zero.To16())
+
+	resp, err := n.pcpRequest(pcpOpMap, lifetime, payload)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) < pcpHeaderLen+pcpMapLen {
+		return 0, fmt.Errorf("short PCP response of %d bytes", len(resp))
+	}
+	mapResp := resp[pcpHeaderLen:]
+	if string(mapResp[:12]) != string(n.nonce[:]) {
+		return 0, errors.New("PCP response with unexpected nonce")
+	}
+	if lifetime != 0 {
+		n.externalIP = net.IP(append([]byte(nil), mapResp[20:36]...))
+	}
+	return int(binary.BigEndian.Uint16(mapResp[18:])), nil
+}
+
+// defaultGateway returns the IPv4 address of the default gateway.  It is read
+// from the routing table on Linux and otherwise assumed to be the first
+// address of the /24 network of the local address used to reach the internet.
+func defaultGateway() (net.IP, error) {
+	if gateway, err := procNetRouteGateway("/proc/net/route"); err == nil {
+		return gateway, nil
+	}
+
+	// Connecting a UDP socket doesn't send any packets.
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine default gateway: %v",
+			err)
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if ip == nil {
+		return nil, errors.New("unable to determine default gateway")
+	}
+	return net.IPv4(ip[0], ip[1], ip[2], 1), nil
+}
+
+// procNetRouteGateway returns the gateway of the default route listed in the
+// passed file in the format of /proc/net/route on Linux.
+func procNetRouteGateway(path string) (net.IP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// The columns are the interface, destination, gateway and flags, where
+	// the addresses are hex encoded in host byte order, which is little
+	// endian on all platforms running Linux the routing table is read on.
+	const rtfGateway = 0x2
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != 4 {
+			continue
+		}
+		return net.IPv4(gw[3], gw[2], gw[1], gw[0]), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default route")
+}
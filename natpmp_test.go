@@ -0,0 +1,149 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeGateway serves NAT-PMP and, when pcp is set, PCP requests on a loopback
+// UDP socket, mapping ports to the external port 40000 and the external
+// address 203.0.113.7.  It returns the address it listens on.
+func fakeGateway(t *testing.T, pcp bool) *net.UDPAddr {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	externalIP := net.IPv4(203, 0, 113, 7).To4()
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, raddr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+
+			var resp []byte
+			switch {
+			case req[0] == pcpVersion && pcp:
+				resp = make([]byte, pcpHeaderLen)
+				resp[0] = pcpVersion
+				resp[1] = req[1] | pcpResponseBit
+				copy(resp[4:8], req[4:8])
+				if req[1] == pcpOpMap {
+					mapResp := append([]byte(nil), req[pcpHeaderLen:]...)
+					binary.BigEndian.PutUint16(mapResp[18:], 40000)
+					copy(mapResp[20:], net.IP(externalIP).To16())
+					resp = append(resp, mapResp...)
+				}
+
+			case req[0] != pmpVersion:
+				resp = []byte{pmpVersion, req[1] | 0x80, 0,
+					pmpResultUnsupportedVersion}
+
+			case req[1] == pmpOpExternalAddress:
+				resp = make([]byte, 12)
+				resp[1] = 0x80
+				copy(resp[8:], externalIP)
+
+			case req[1] == pmpOpMapTCP:
+				resp = make([]byte, 16)
+				resp[1] = 0x80 | pmpOpMapTCP
+				copy(resp[8:10], req[4:6])
+				binary.BigEndian.PutUint16(resp[10:], 40000)
+				copy(resp[12:16], req[8:12])
+
+			default:
+				resp = []byte{pmpVersion, req[1] | 0x80, 0, 5}
+			}
+			conn.WriteToUDP(resp, raddr)
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+// TestPMPNAT ensures ports are mapped and the external address is determined
+// via both PCP and NAT-PMP, falling back to NAT-PMP when the gateway doesn't
+// support PCP.
+func TestPMPNAT(t *testing.T) {
+	t.Parallel()
+
+	for _, pcp := range []bool{true, false} {
+		n, err := newPMPNAT(fakeGateway(t, pcp))
+		if err != nil {
+			t.Fatalf("newPMPNAT(pcp=%v): unexpected error: %v", pcp, err)
+		}
+		want := "natpmp"
+		if pcp {
+			want = "pcp"
+		}
+		if n.Protocol() != want {
+			t.Fatalf("Protocol: got %s, want %s", n.Protocol(), want)
+		}
+
+		port, err := n.AddPortMapping("tcp", 8333, 8333, "test", 1200)
+		if err != nil {
+			t.Fatalf("AddPortMapping(%s): unexpected error: %v", want,
+				err)
+		}
+		if port != 40000 {
+			t.Fatalf("AddPortMapping(%s): got port %d, want 40000",
+				want, port)
+		}
+		ip, err := n.GetExternalAddress()
+		if err != nil {
+			t.Fatalf("GetExternalAddress(%s): unexpected error: %v",
+				want, err)
+		}
+		if ip.String() != "203.0.113.7" {
+			t.Fatalf("GetExternalAddress(%s): got %v", want, ip)
+		}
+		if _, err := n.AddPortMapping("udp", 8333, 8333, "", 1200); err == nil {
+			t.Fatalf("AddPortMapping(%s): expected error for udp", want)
+		}
+		if err := n.DeletePortMapping("tcp", port, 8333); err != nil {
+			t.Fatalf("DeletePortMapping(%s): unexpected error: %v",
+				want, err)
+		}
+	}
+}
+
+// TestProcNetRouteGateway ensures the default gateway is read from the routing
+// table as intended.
+func TestProcNetRouteGateway(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "route")
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\n" +
+		"eth0\t00000000\t0100A8C0\t0003\t0\t0\t0\t00000000\n"
+	if err := os.WriteFile(path, []byte(table), 0600); err != nil {
+		t.Fatalf("unable to write routing table: %v", err)
+	}
+	gateway, err := procNetRouteGateway(path)
+	if err != nil {
+		t.Fatalf("procNetRouteGateway: unexpected error: %v", err)
+	}
+	if gateway.String() != "192.168.0.1" {
+		t.Fatalf("procNetRouteGateway: got %v, want 192.168.0.1", gateway)
+	}
+
+	if err := os.WriteFile(path, []byte(table[:len(table)/2]), 0600); err != nil {
+		t.Fatalf("unable to write routing table: %v", err)
+	}
+	if _, err := procNetRouteGateway(path); err == nil {
+		t.Fatal("procNetRouteGateway: expected error without default route")
+	}
+}
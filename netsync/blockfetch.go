@@ -0,0 +1,213 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// blockFetchTimeout is the duration after which a block fetched on
+	// demand which has not been received is given up on.
+	blockFetchTimeout = 30 * time.Second
+)
+
+var (
+	// ErrBlockUnavailable is returned by FetchBlock when no connected peer
+	// provided the requested block.
+	ErrBlockUnavailable = errors.New("block is not available from any " +
+		"connected peer")
+
+	// ErrPeerNotFound is returned by RequestBlock when no connected peer
+	// has the requested ID.
+	ErrPeerNotFound = errors.New("peer does not exist")
+)
+
+// fetchBlockMsg is a message type to be sent across the message channel for
+// fetching a main chain block from a peer on demand.
+type fetchBlockMsg struct {
+	hash  chainhash.Hash
+	reply chan *btcutil.Block
+}
+
+// requestBlockMsg is a message type to be sent across the message channel for
+// requesting a block from the peer with the given ID.
+type requestBlockMsg struct {
+	hash   chainhash.Hash
+	peerID int32
+	reply  chan error
+}
+
+// blockFetch tracks a block requested from a peer on demand along with the
+// callers waiting for it.
+type blockFetch struct {
+	peer      *peerpkg.Peer
+	requested time.Time
+	replies   []chan *btcutil.Block
+}
+
+// handleFetchBlockMsg requests the main chain block with the passed hash from a
+// peer which is expected to have it.  The block is sent to the reply channel
+// once it is received, or nil is sent when it can't be fetched.
+func (sm *SyncManager) handleFetchBlockMsg(msg fetchBlockMsg) {
+	// Join an outstanding request for the same block.
+	if fetch, exists := sm.blockFetches[msg.hash]; exists {
+		fetch.replies = append(fetch.replies, msg.reply)
+		return
+	}
+
+	height, err := sm.chain.BlockHeightByHash(&msg.hash)
+	if err != nil {
+		msg.reply <- nil
+		return
+	}
+
+	// Prefer the sync peer and fall back to any other peer which should
+	// have the block.
+	var peer *peerpkg.Peer
+	if sm.syncPeer != nil && peerHasBlock(sm.syncPeer, height) {
+		peer = sm.syncPeer
+	} else {
+		for candidate, state := range sm.peerStates {
+			if state.syncCandidate && peerHasBlock(candidate, height) {
+				peer = candidate
+				break
+			}
+		}
+	}
+	if peer == nil {
+		msg.reply <- nil
+		return
+	}
+
+	iv := wire.NewInvVect(wire.InvTypeBlock, &msg.hash)
+	if peer.IsWitnessEnabled() {
+		iv.Type = wire.InvTypeWitnessBlock
+	}
+	gdmsg := wire.NewMsgGetDataSizeHint(1)
+	gdmsg.AddInvVect(iv)
+	peer.QueueMessage(gdmsg, nil)
+
+	log.Debugf("Fetching block %v (height %d) from %s on demand",
+		msg.hash, height, peer)
+	sm.blockFetches[msg.hash] = &blockFetch{
+		peer:      peer,
+		requested: time.Now(),
+		replies:   []chan *btcutil.Block{msg.reply},
+	}
+}
+
+// handleRequestBlockMsg requests the block with the passed hash from the peer
+// with the passed ID.  The block is treated like any other requested block once
+// it is received, so it is processed and stored.
+func (sm *SyncManager) handleRequestBlockMsg(msg requestBlockMsg) {
+	for peer, state := range sm.peerStates {
+		if peer.ID() != msg.peerID {
+			continue
+		}
+
+		iv := wire.NewInvVect(wire.InvTypeBlock, &msg.hash)
+		if peer.IsWitnessEnabled() {
+			iv.Type = wire.InvTypeWitnessBlock
+		}
+		gdmsg := wire.NewMsgGetDataSizeHint(1)
+		gdmsg.AddInvVect(iv)
+		peer.QueueMessage(gdmsg, nil)
+
+		log.Debugf("Requesting block %v from %s", msg.hash, peer)
+		state.requestedBlocks[msg.hash] = struct{}{}
+		sm.requestedBlocks[msg.hash] = struct{}{}
+		msg.reply <- nil
+		return
+	}
+
+	msg.reply <- ErrPeerNotFound
+}
+
+// handleFetchedBlock delivers a block fetched on demand to the callers waiting
+// for it after checking the transactions match its header.  It returns false
+// when the block wasn't fetched on demand from the passed peer.
+func (sm *SyncManager) handleFetchedBlock(peer *peerpkg.Peer, block *btcutil.Block) bool {
+	hash := block.Hash()
+	fetch, exists := sm.blockFetches[*hash]
+	if !exists || fetch.peer != peer {
+		return false
+	}
+
+	// The block hash commits to the header, so only the transactions need
+	// to be checked against the merkle root and witness commitment.
+	merkleRoot := blockchain.CalcMerkleRoot(block.Transactions(), false)
+	if block.MsgBlock().Header.MerkleRoot != merkleRoot ||
+		blockchain.ValidateWitnessCommitment(block) != nil {
+
+		log.Warnf("Block %v fetched from %s does not match its header "+
+			"-- disconnecting", hash, peer)
+		peer.Disconnect()
+		block = nil
+	}
+
+	sm.finishBlockFetch(hash, fetch, block)
+	return true
+}
+
+// finishBlockFetch removes the passed on demand block request and sends the
+// passed block, which is nil when the request failed, to all callers waiting
+// for it.
+func (sm *SyncManager) finishBlockFetch(hash *chainhash.Hash, fetch *blockFetch,
+	block *btcutil.Block) {
+
+	delete(sm.blockFetches, *hash)
+	for _, reply := range fetch.replies {
+		reply <- block
+	}
+}
+
+// failBlockFetches fails all on demand block requests made to the passed peer,
+// or all requests which timed out when the peer is nil.
+func (sm *SyncManager) failBlockFetches(peer *peerpkg.Peer) {
+	for hash, fetch := range sm.blockFetches {
+		if peer != nil && fetch.peer != peer {
+			continue
+		}
+		if peer == nil && time.Since(fetch.requested) < blockFetchTimeout {
+			continue
+		}
+
+		hash := hash
+		sm.finishBlockFetch(&hash, fetch, nil)
+	}
+}
+
+// FetchBlock fetches the main chain block with the passed hash from a connected
+// peer which is expected to have it.  It is meant to provide blocks which were
+// pruned from the local database on demand, so the block is neither processed
+// nor stored.  ErrBlockUnavailable is returned when no peer provided the block
+// in time.
+func (sm *SyncManager) FetchBlock(hash *chainhash.Hash) (*btcutil.Block, error) {
+	reply := make(chan *btcutil.Block, 1)
+	sm.msgChan <- fetchBlockMsg{hash: *hash, reply: reply}
+	if block := <-reply; block != nil {
+		return block, nil
+	}
+	return nil, ErrBlockUnavailable
+}
+
+// RequestBlock requests the block with the passed hash from the peer with the
+// passed ID without waiting for it.  The block is processed and stored like
+// any other block once it is received.  It is meant to download the blocks
+// which are only known by their headers, or which were pruned, from a chosen
+// peer.  ErrPeerNotFound is returned when no connected peer has the ID.
+func (sm *SyncManager) RequestBlock(hash *chainhash.Hash, peerID int32) error {
+	reply := make(chan error, 1)
+	sm.msgChan <- requestBlockMsg{hash: *hash, peerID: peerID, reply: reply}
+	return <-reply
+}
@@ -6,8 +6,18 @@
 Package netsync implements a concurrency safe block syncing protocol. The
 SyncManager communicates with connected peers to perform an initial block
 download, keep the chain and unconfirmed transaction pool in sync, and announce
-new blocks connected to the chain. Currently the sync manager selects a single
-sync peer that it downloads all blocks from until it is up to date with the
-longest chain the sync peer is aware of.
+new blocks connected to the chain. The sync manager selects a sync peer that it
+downloads the block headers from up to the tip of the longest chain the sync
+peer is aware of.  The blocks for those headers are then downloaded from all
+suitable peers in parallel within a moving window, with slow requests being
+requested again from other peers, and processed in order.  Peers are scored by
+the throughput they deliver blocks at, so faster peers are assigned more blocks
+and the blocks that are needed first.
+
+When the headers start from a block whose chain has less work than the minimum
+chain work of the network, they are pre-synced first: their work is added up
+without keeping them, apart from salted commitments to a sample of them, and
+they are only downloaded again and kept once they reach the minimum.  This
+prevents peers from exhausting the memory with long chains of low work headers.
 */
 package netsync
@@ -5,12 +5,16 @@
 package netsync
 
 import (
+	"math/big"
+
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blocktrace"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/reputation"
 	"github.com/btcsuite/btcd/wire"
 )
 
@@ -37,5 +41,29 @@ type Config struct {
 	DisableCheckpoints bool
 	MaxPeers           int
 
+	// MinimumChainWork overrides the minimum cumulative work of the chain
+	// parameters a chain of headers must have before its blocks are
+	// downloaded when it is not nil.
+	MinimumChainWork *big.Int
+
+	// AssumeValid is the hash of the block whose ancestors are processed
+	// without verifying their scripts once it is part of the downloaded
+	// headers.  All scripts are verified when it is nil.
+	AssumeValid *chainhash.Hash
+
 	FeeEstimator *mempool.FeeEstimator
+
+	// TimeSource is used to reject block headers with timestamps too far in
+	// the future during headers-first sync.  The local clock is used when
+	// it is nil.
+	TimeSource blockchain.MedianTimeSource
+
+	// BlockTracer records the lifecycle of the blocks relayed at the tip of
+	// the chain.  It is nil when block tracing is disabled.
+	BlockTracer *blocktrace.Tracer
+
+	// PeerReputation tracks the useful blocks and transactions delivered by
+	// the peers and the block requests to them that stalled.  It is nil
+	// when the statistics of the peers are not tracked.
+	PeerReputation *reputation.Tracker
 }
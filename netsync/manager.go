@@ -5,7 +5,7 @@
 package netsync
 
 import (
-	"container/list"
+	"math/big"
 	"math/rand"
 	"net"
 	"sync"
@@ -13,20 +13,66 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blocktrace"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/mempool"
 	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/reputation"
 	"github.com/btcsuite/btcd/wire"
 )
 
 const (
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
+	// blockDownloadWindow is the maximum number of blocks past the next
+	// block to process that are downloaded in headers-first mode.  Blocks
+	// that arrive out of order are buffered until all of the blocks before
+	// them are processed.
+	blockDownloadWindow = 1024
+
+	// maxDownloadedBlockBytes is the total serialized size of the buffered
+	// blocks downloaded in headers-first mode at which no more blocks past
+	// the next block to process are requested.  Since a window of large
+	// blocks could otherwise take several gigabytes, this bounds the memory
+	// used by the buffer to about this size plus the blocks in flight,
+	// which are limited by maxBlocksInFlightPerPeer.
+	maxDownloadedBlockBytes = 256 * 1024 * 1024
+
+	// maxQueuedHeaders is the maximum number of downloaded headers whose
+	// blocks were not processed yet in headers-first mode.  The download of
+	// the headers is paused once it is reached and resumed once half of
+	// the blocks were processed, which bounds the memory used by headers
+	// that are not fully validated yet.
+	maxQueuedHeaders = 50 * wire.MaxBlockHeadersPerMsg
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks that are
+	// requested from a single peer at the same time in headers-first mode.
+	maxBlocksInFlightPerPeer = 32
+
+	// windowStallTimeout is the time after which the request for the next
+	// block to process is considered stalled when later blocks already
+	// arrived.  The block is then requested from another peer so a single
+	// slow peer can't hold up the download window.
+	windowStallTimeout = 10 * time.Second
+
+	// blockRequestTimeout is the time after which any block request in
+	// headers-first mode is considered stalled and the block is requested
+	// from another peer.
+	blockRequestTimeout = time.Minute
+
+	// maxPeerStalls is the number of stalled block requests after which a
+	// peer is disconnected.
+	maxPeerStalls = 3
+
+	// maxBlockFailures is the number of different peers that must send an
+	// invalid block for a header before the header chain is considered
+	// invalid rather than the peers.
+	maxBlockFailures = 2
+
+	// blockStallCheckInterval is the interval at which the block requests
+	// made in headers-first mode are checked for stalls.
+	blockStallCheckInterval = 2 * time.Second
 
 	// maxRejectedTxns is the maximum number of rejected transactions
 	// hashes to store in memory.
@@ -63,6 +109,10 @@ type blockMsg struct {
 	block *btcutil.Block
 	peer  *peerpkg.Peer
 	reply chan struct{}
+
+	// size is the serialized size of the block once it is buffered in
+	// headers-first mode.
+	size int
 }
 
 // invMsg packages a bitcoin inv message and the peer it came from together
@@ -99,6 +149,12 @@ type txMsg struct {
 	reply chan struct{}
 }
 
+// getDownloadStatsMsg is a message type to be sent across the message channel
+// for retrieving the block download statistics of the peers.
+type getDownloadStatsMsg struct {
+	reply chan map[int32]*PeerDownloadStats
+}
+
 // getSyncPeerMsg is a message type to be sent across the message channel for
 // retrieving the current sync peer.
 type getSyncPeerMsg struct {
@@ -138,11 +194,87 @@ type pauseMsg struct {
 	unpause <-chan struct{}
 }
 
-// headerNode is used as a node in a list of headers that are linked together
-// between checkpoints.
+// headerNode is used as a node in the list of headers that are downloaded in
+// headers-first mode.
 type headerNode struct {
-	height int32
-	hash   *chainhash.Hash
+	height    int32
+	hash      chainhash.Hash
+	bits      uint32
+	timestamp int64
+}
+
+// downloadedHeaderCtx provides the context of a downloaded header to the
+// contextual checks of the headers that follow it.  The context of the block
+// the headers are downloaded from and its ancestors is provided by the chain.
+type downloadedHeaderCtx struct {
+	headers []headerNode
+	index   int
+	start   blockchain.HeaderCtx
+}
+
+// Ensure the downloadedHeaderCtx type implements the blockchain.HeaderCtx
+// interface.
+var _ blockchain.HeaderCtx = (*downloadedHeaderCtx)(nil)
+
+// newDownloadedHeaderCtx returns the context of the downloaded header at the
+// passed index, where the header at index zero is the block the headers are
+// downloaded from, whose context is passed as start.
+func newDownloadedHeaderCtx(headers []headerNode, index int,
+	start blockchain.HeaderCtx) blockchain.HeaderCtx {
+
+	if index == 0 {
+		return start
+	}
+	return &downloadedHeaderCtx{headers: headers, index: index, start: start}
+}
+
+// Height returns the height of the header.
+//
+// NOTE: Part of the blockchain.HeaderCtx interface.
+func (h *downloadedHeaderCtx) Height() int32 {
+	return h.headers[h.index].height
+}
+
+// Bits returns the difficulty bits of the header.
+//
+// NOTE: Part of the blockchain.HeaderCtx interface.
+func (h *downloadedHeaderCtx) Bits() uint32 {
+	return h.headers[h.index].bits
+}
+
+// Timestamp returns the timestamp of the header.
+//
+// NOTE: Part of the blockchain.HeaderCtx interface.
+func (h *downloadedHeaderCtx) Timestamp() int64 {
+	return h.headers[h.index].timestamp
+}
+
+// Parent returns the context of the previous header.
+//
+// NOTE: Part of the blockchain.HeaderCtx interface.
+func (h *downloadedHeaderCtx) Parent() blockchain.HeaderCtx {
+	return newDownloadedHeaderCtx(h.headers, h.index-1, h.start)
+}
+
+// RelativeAncestorCtx returns the context of the header distance headers
+// before this one.
+//
+// NOTE: Part of the blockchain.HeaderCtx interface.
+func (h *downloadedHeaderCtx) RelativeAncestorCtx(
+	distance int32) blockchain.HeaderCtx {
+
+	if int(distance) > h.index {
+		return h.start.RelativeAncestorCtx(distance - int32(h.index))
+	}
+	return newDownloadedHeaderCtx(h.headers, h.index-int(distance), h.start)
+}
+
+// blockRequest describes a block that was requested from a peer in
+// headers-first mode.
+type blockRequest struct {
+	peer      *peerpkg.Peer
+	height    int32
+	requested time.Time
 }
 
 // peerSyncState stores additional information that the SyncManager tracks
@@ -152,6 +284,14 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+
+	// stalls is the number of block requests made to the peer in
+	// headers-first mode that stalled.
+	stalls int
+
+	// download houses the statistics of the blocks downloaded from the
+	// peer in headers-first mode.
+	download downloadStats
 }
 
 // limitAdd is a helper function for maps that require a maximum limit by
@@ -182,6 +322,7 @@ type SyncManager struct {
 	peerNotifier   PeerNotifier
 	started        int32
 	shutdown       int32
+	headerHeight   int32 // accessed atomically
 	chain          *blockchain.BlockChain
 	txMemPool      *mempool.TxPool
 	chainParams    *chaincfg.Params
@@ -190,6 +331,10 @@ type SyncManager struct {
 	wg             sync.WaitGroup
 	quit           chan struct{}
 
+	// activity records what the block handler is doing for diagnosing
+	// a stuck handler.
+	activity activityTracker
+
 	// These fields should only be accessed from the blockHandler thread
 	rejectedTxns     map[chainhash.Hash]struct{}
 	requestedTxns    map[chainhash.Hash]struct{}
@@ -198,58 +343,95 @@ type SyncManager struct {
 	peerStates       map[*peerpkg.Peer]*peerSyncState
 	lastProgressTime time.Time
 
-	// The following fields are used for headers-first mode.
-	headersFirstMode bool
-	headerList       *list.List
-	startHeader      *list.Element
-	nextCheckpoint   *chaincfg.Checkpoint
+	// blockFetches holds the blocks requested on demand through FetchBlock
+	// which are delivered to the callers instead of being processed.
+	blockFetches map[chainhash.Hash]*blockFetch
+
+	// The following fields are used for headers-first mode.  The headers
+	// are downloaded from the sync peer up to its tip first.  The blocks
+	// are then downloaded from all sync candidates in parallel, buffered
+	// and processed in order.
+	//
+	// headers starts with the block the headers are downloaded from,
+	// which is already in the chain, and nextProcess is the index of the
+	// next header whose block must be processed.  downloadedBytes is the
+	// total size of the buffered downloadedBlocks.
+	headersFirstMode   bool
+	headersSynced      bool
+	headers            []headerNode
+	nextProcess        int
+	blockRequests      map[chainhash.Hash]*blockRequest
+	downloadedBlocks   map[chainhash.Hash]*blockMsg
+	downloadedBytes    int
+	stalledBlocks      map[chainhash.Hash]*peerpkg.Peer
+	failedBlocks       map[chainhash.Hash]int
+	checkpoints        map[int32]chainhash.Hash
+	verifiedCheckpoint int32
+	timeSource         blockchain.MedianTimeSource
+
+	// presync tracks the pre-sync of the headers when they start from a
+	// block whose chain has less work than required to keep them right
+	// away, which must be at least minChainWork.
+	presync      *headersPresync
+	minChainWork *big.Int
+
+	// headersPaused is set while the download of the headers is paused
+	// since maxQueuedHeaders headers are waiting for their blocks to be
+	// processed.  headersWork is the cumulative work of the chain of the
+	// last downloaded header, or nil before any header was downloaded.
+	headersPaused bool
+	headersWork   *big.Int
+
+	// assumeValid is the block whose ancestors are processed without
+	// verifying their scripts.  assumeValidHeader is its height once its
+	// header was downloaded or -1 otherwise, and assumeValidHeight is its
+	// height once the downloaded headers also have at least minChainWork
+	// or -1 otherwise.
+	assumeValid       *chainhash.Hash
+	assumeValidHeader int32
+	assumeValidHeight int32
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
+
+	// An optional tracer of the blocks relayed at the tip of the chain.
+	blockTracer *blocktrace.Tracer
+
+	// An optional tracker of the statistics of the peers which persist
+	// across restarts.
+	peerReputation *reputation.Tracker
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
-// syncing from a new peer.
+// syncing from a new peer.  All outstanding block requests and buffered blocks
+// are dropped.
 func (sm *SyncManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int32) {
 	sm.headersFirstMode = false
-	sm.headerList.Init()
-	sm.startHeader = nil
-
-	// When there is a next checkpoint, add an entry for the latest known
-	// block into the header pool.  This allows the next downloaded header
-	// to prove it links to the chain properly.
-	if sm.nextCheckpoint != nil {
-		node := headerNode{height: newestHeight, hash: newestHash}
-		sm.headerList.PushBack(&node)
-	}
-}
+	sm.headersSynced = false
+	sm.headersPaused = false
 
-// findNextHeaderCheckpoint returns the next checkpoint after the passed height.
-// It returns nil when there is not one either because the height is already
-// later than the final checkpoint or some other reason such as disabled
-// checkpoints.
-func (sm *SyncManager) findNextHeaderCheckpoint(height int32) *chaincfg.Checkpoint {
-	checkpoints := sm.chain.Checkpoints()
-	if len(checkpoints) == 0 {
-		return nil
-	}
-
-	// There is no next checkpoint if the height is already after the final
-	// checkpoint.
-	finalCheckpoint := &checkpoints[len(checkpoints)-1]
-	if height >= finalCheckpoint.Height {
-		return nil
-	}
-
-	// Find the next checkpoint.
-	nextCheckpoint := finalCheckpoint
-	for i := len(checkpoints) - 2; i >= 0; i-- {
-		if height >= checkpoints[i].Height {
-			break
+	// Add an entry for the latest known block into the header list.  This
+	// allows the next downloaded header to prove it links to the chain
+	// properly.
+	sm.headers = []headerNode{{height: newestHeight, hash: *newestHash}}
+	sm.nextProcess = 1
+	sm.verifiedCheckpoint = -1
+	sm.presync = nil
+	sm.assumeValidHeader = -1
+	sm.assumeValidHeight = -1
+	sm.headersWork = nil
+
+	for hash, req := range sm.blockRequests {
+		if state, exists := sm.peerStates[req.peer]; exists {
+			delete(state.requestedBlocks, hash)
 		}
-		nextCheckpoint = &checkpoints[i]
+		delete(sm.requestedBlocks, hash)
 	}
-	return nextCheckpoint
+	sm.blockRequests = make(map[chainhash.Hash]*blockRequest)
+	sm.downloadedBlocks = make(map[chainhash.Hash]*blockMsg)
+	sm.downloadedBytes = 0
+	sm.stalledBlocks = make(map[chainhash.Hash]*peerpkg.Peer)
+	sm.failedBlocks = make(map[chainhash.Hash]int)
 }
 
 // startSync will choose the best peer among the available candidate peers to
@@ -278,6 +460,12 @@ func (sm *SyncManager) startSync() {
 			continue
 		}
 
+		// Skip peers that are being disconnected, such as a sync peer
+		// that stalled, until they are removed.
+		if !peer.Connected() {
+			continue
+		}
+
 		if segwitActive && !peer.IsWitnessEnabled() {
 			log.Debugf("peer %v not witness enabled, skipping", peer)
 			continue
@@ -311,9 +499,8 @@ func (sm *SyncManager) startSync() {
 
 	// Pick randomly from the set of peers greater than our block height,
 	// falling back to a random peer of the same height if none are greater.
-	//
-	// TODO(conner): Use a better algorithm to ranking peers based on
-	// observed metrics and/or sync in parallel.
+	// The sync peer only provides the headers in headers-first mode while
+	// the blocks are downloaded from all sync candidates.
 	var bestPeer *peerpkg.Peer
 	switch {
 	case len(higherPeers) > 0:
@@ -323,6 +510,18 @@ func (sm *SyncManager) startSync() {
 		bestPeer = equalPeers[rand.Intn(len(equalPeers))]
 	}
 
+	// Continue downloading the blocks of the headers that were already
+	// downloaded from the previous sync peer, which also serves as the
+	// peer the remaining blocks are downloaded from.
+	if bestPeer != nil && sm.headersFirstMode && sm.headersSynced {
+		log.Infof("Continuing headers-first block download with sync "+
+			"peer %v", bestPeer.Addr())
+		sm.syncPeer = bestPeer
+		sm.lastProgressTime = time.Now()
+		sm.fetchHeaderBlocks()
+		return
+	}
+
 	// Start syncing from the best peer if one was selected.
 	if bestPeer != nil {
 		// Clear the requestedBlocks if the sync peer changes, otherwise
@@ -340,32 +539,35 @@ func (sm *SyncManager) startSync() {
 		log.Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
 
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
+		// Download the headers up to the tip of the sync peer first
+		// to learn about which blocks comprise the chain, then download
+		// the blocks from all sync candidates in parallel.  Since each
+		// header contains the hash of the previous header and a merkle
+		// root, the blocks up to a checkpoint can be processed with less
+		// validation when all of the received headers link together
+		// properly and the checkpoint hashes match, because the hashes
+		// for the blocks in between are then known to be accurate.
+		// Further, once the full blocks are downloaded, the merkle root
+		// is computed and compared against the value in the header
+		// which proves the full block hasn't been tampered with.  The
+		// blocks after the final checkpoint, or all blocks when
+		// checkpoints are disabled, are fully validated.
 		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if sm.nextCheckpoint != nil &&
-			best.Height < sm.nextCheckpoint.Height &&
-			sm.chainParams != &chaincfg.RegressionNetParams {
-
-			bestPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+		// Once all of the blocks are downloaded, standard inv messages
+		// are used to learn about new blocks.  The same applies when the
+		// sync peer isn't ahead of us since there are no headers to
+		// download, and peers which aren't current themselves don't
+		// answer requests for headers.  Finally, regression test mode
+		// does not support the headers-first approach so do normal
+		// block downloads when in regression test mode.
+		if sm.chainParams != &chaincfg.RegressionNetParams &&
+			bestPeer.LastBlock() > best.Height {
+
+			sm.resetHeaderState(&best.Hash, best.Height)
+			bestPeer.PushGetHeadersMsg(locator, &zeroHash)
 			sm.headersFirstMode = true
-			log.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", best.Height+1,
-				sm.nextCheckpoint.Height, bestPeer.Addr())
+			log.Infof("Downloading headers for blocks after %d "+
+				"from peer %s", best.Height, bestPeer.Addr())
 		} else {
 			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		}
@@ -505,6 +707,9 @@ func (sm *SyncManager) handleStallSample() {
 	sm.clearRequestedState(state)
 
 	disconnectSyncPeer := sm.shouldDCStalledSyncPeer()
+	if disconnectSyncPeer {
+		sm.peerReputation.Stalled(sm.syncPeer.Addr())
+	}
 	sm.updateSyncPeer(disconnectSyncPeer)
 }
 
@@ -547,11 +752,19 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 	log.Infof("Lost peer %s", peer)
 
 	sm.clearRequestedState(state)
+	sm.failBlockFetches(peer)
 
 	if peer == sm.syncPeer {
 		// Update the sync peer. The server has already disconnected the
 		// peer before signaling to the sync manager.
 		sm.updateSyncPeer(false)
+		return
+	}
+
+	// Request the blocks that were in flight from the peer from other
+	// peers.
+	if sm.downloadingHeaderBlocks() {
+		sm.fetchHeaderBlocks()
 	}
 }
 
@@ -571,6 +784,7 @@ func (sm *SyncManager) clearRequestedState(state *peerSyncState) {
 	// and request them now to speed things up a little.
 	for blockHash := range state.requestedBlocks {
 		delete(sm.requestedBlocks, blockHash)
+		delete(sm.blockRequests, blockHash)
 	}
 }
 
@@ -587,8 +801,10 @@ func (sm *SyncManager) updateSyncPeer(dcSyncPeer bool) {
 		sm.syncPeer.Disconnect()
 	}
 
-	// Reset any header state before we choose our next active sync peer.
-	if sm.headersFirstMode {
+	// Reset any header state before we choose our next active sync peer
+	// unless all of the headers were downloaded already, in which case
+	// the blocks continue to be downloaded from the remaining peers.
+	if sm.headersFirstMode && !sm.headersSynced {
 		best := sm.chain.BestSnapshot()
 		sm.resetHeaderState(&best.Hash, best.Height)
 	}
@@ -661,6 +877,9 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 		return
 	}
 
+	if len(acceptedTxs) > 0 {
+		sm.peerReputation.UsefulTx(peer.Addr())
+	}
 	sm.peerNotifier.AnnounceNewTransactions(acceptedTxs)
 }
 
@@ -694,6 +913,12 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		return
 	}
 
+	// Blocks fetched on demand are handed to the callers waiting for them
+	// rather than processed.
+	if sm.handleFetchedBlock(peer, bmsg.block) {
+		return
+	}
+
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockHash := bmsg.block.Hash()
 	if _, exists = state.requestedBlocks[*blockHash]; !exists {
@@ -710,30 +935,16 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
-	if sm.headersFirstMode {
-		firstNodeEl := sm.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(sm.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					sm.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
+	// Blocks downloaded in headers-first mode are buffered and processed
+	// in the order of the headers.
+	if _, exists := sm.blockRequests[*blockHash]; exists {
+		sm.handleDownloadedBlock(bmsg, state)
+		return
 	}
 
+	behaviorFlags := blockchain.BFNone
+	sm.blockTracer.Record(blockHash, blocktrace.StageReceived, peer.Addr())
+
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
@@ -762,6 +973,8 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 		// Convert the error into an appropriate reject message and
 		// send it.
+		sm.blockTracer.RecordRejected(blockHash, err.Error())
+
 		code, reason := mempool.ErrToRejectErr(err)
 		peer.PushRejectMsg(wire.CmdBlock, code, reason, blockHash, false)
 		return
@@ -813,6 +1026,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		if peer == sm.syncPeer {
 			sm.lastProgressTime = time.Now()
 		}
+		sm.peerReputation.UsefulBlock(peer.Addr())
 
 		// When the block is not an orphan, log information about it and
 		// update the chain state.
@@ -842,117 +1056,439 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 	// If we are not in headers first mode, it's a good time to periodically
 	// flush the blockchain cache because we don't expect new blocks immediately.
-	// After that, there is nothing more to do.
 	if !sm.headersFirstMode {
 		if err := sm.chain.FlushUtxoCache(blockchain.FlushPeriodic); err != nil {
 			log.Errorf("Error while flushing the blockchain cache: %v", err)
 		}
+	}
+}
+
+// handleDownloadedBlock buffers a block that was requested in headers-first
+// mode and processes all buffered blocks that are next in the order of the
+// headers.  More blocks are requested afterwards to keep the download window
+// full.
+func (sm *SyncManager) handleDownloadedBlock(bmsg *blockMsg, state *peerSyncState) {
+	blockHash := bmsg.block.Hash()
+	now := time.Now()
+	size := bmsg.block.MsgBlock().SerializeSize()
+	if req := sm.blockRequests[*blockHash]; req != nil && req.peer == bmsg.peer {
+		state.download.recordDownload(size, req.requested, now)
+	}
+	delete(sm.blockRequests, *blockHash)
+	delete(sm.requestedBlocks, *blockHash)
+	delete(state.requestedBlocks, *blockHash)
+	delete(sm.stalledBlocks, *blockHash)
+
+	sm.removeDownloadedBlock(blockHash)
+	bmsg.size = size
+	sm.downloadedBlocks[*blockHash] = bmsg
+	sm.downloadedBytes += size
+	sm.lastProgressTime = now
+
+	sm.processDownloadedBlocks()
+	if sm.headersFirstMode {
+		sm.resumeHeaders()
+		sm.fetchHeaderBlocks()
+	}
+}
+
+// removeDownloadedBlock removes the block with the passed hash from the buffer
+// of the blocks downloaded in headers-first mode when it is buffered.
+func (sm *SyncManager) removeDownloadedBlock(hash *chainhash.Hash) {
+	bmsg, exists := sm.downloadedBlocks[*hash]
+	if !exists {
 		return
 	}
+	sm.downloadedBytes -= bmsg.size
+	delete(sm.downloadedBlocks, *hash)
+}
 
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if sm.startHeader != nil &&
-			len(state.requestedBlocks) < minInFlightBlocks {
-			sm.fetchHeaderBlocks()
+// processDownloadedBlocks processes the buffered blocks downloaded in
+// headers-first mode in the order of the headers until a block that was not
+// downloaded yet is reached.  Headers-first mode is left once the blocks of all
+// headers are processed.
+func (sm *SyncManager) processDownloadedBlocks() {
+	for sm.nextProcess < len(sm.headers) {
+		// Skip blocks that are already known, such as the blocks of a
+		// side chain that is being reorganized to or blocks that were
+		// submitted locally.
+		node := &sm.headers[sm.nextProcess]
+		haveBlock, err := sm.chain.HaveBlock(&node.hash)
+		if err != nil {
+			log.Warnf("Unexpected failure when checking for "+
+				"existing block %v: %v", node.hash, err)
+			return
+		}
+		if haveBlock {
+			sm.removeDownloadedBlock(&node.hash)
+			sm.nextProcess++
+			continue
+		}
+
+		bmsg, exists := sm.downloadedBlocks[node.hash]
+		if !exists {
+			return
+		}
+		sm.removeDownloadedBlock(&node.hash)
+
+		// The blocks up to the most recent checkpoint that matched the
+		// downloaded headers are eligible for less validation since
+		// the headers have already been verified to link together.
+		behaviorFlags := blockchain.BFNone
+		if node.height <= sm.verifiedCheckpoint {
+			behaviorFlags |= blockchain.BFFastAdd
+		}
+
+		// The blocks up to the assumed valid block are processed
+		// without verifying their scripts.
+		if node.height <= sm.assumeValidHeight {
+			behaviorFlags |= blockchain.BFAssumeValid
 		}
+
+		_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block,
+			behaviorFlags)
+		if err != nil {
+			sm.handleDownloadedBlockError(bmsg, err)
+			return
+		}
+		if isOrphan {
+			// This can only happen when the chain was modified
+			// outside of the sync manager, so start over from the
+			// current best block.
+			log.Warnf("Downloaded block %v is an orphan -- "+
+				"restarting headers-first sync", node.hash)
+			best := sm.chain.BestSnapshot()
+			sm.resetHeaderState(&best.Hash, best.Height)
+			if sm.syncPeer != nil {
+				sm.updateSyncPeer(false)
+			}
+			return
+		}
+
+		sm.nextProcess++
+		sm.peerReputation.UsefulBlock(bmsg.peer.Addr())
+		sm.progressLogger.LogBlockHeight(bmsg.block, sm.chain)
+		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+	}
+
+	if sm.headersSynced {
+		sm.finishHeadersFirst()
+	}
+}
+
+// handleDownloadedBlockError handles a block downloaded in headers-first mode
+// that failed to process.  Since the block matches the hash of a downloaded
+// header, either the peer that sent it tampered with it or the header chain
+// provided by the sync peer is invalid.  The peer that sent the block is
+// disconnected and the block is requested again from another peer until
+// several peers sent an invalid block, in which case the sync peer is
+// disconnected and the sync is restarted.
+func (sm *SyncManager) handleDownloadedBlockError(bmsg *blockMsg, err error) {
+	blockHash := bmsg.block.Hash()
+	if _, ok := err.(blockchain.RuleError); ok {
+		log.Infof("Rejected block %v from %s: %v", blockHash,
+			bmsg.peer, err)
+	} else {
+		log.Errorf("Failed to process block %v: %v", blockHash, err)
+	}
+	if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+		database.ErrCorruption {
+		panic(dbErr)
+	}
+
+	code, reason := mempool.ErrToRejectErr(err)
+	bmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason, blockHash, false)
+	bmsg.peer.Disconnect()
+
+	sm.failedBlocks[*blockHash]++
+	if sm.failedBlocks[*blockHash] < maxBlockFailures {
+		sm.stalledBlocks[*blockHash] = bmsg.peer
+		return
+	}
+
+	log.Warnf("Block %v was rejected from %d peers -- disconnecting "+
+		"sync peer that provided its header", blockHash,
+		maxBlockFailures)
+	best := sm.chain.BestSnapshot()
+	sm.resetHeaderState(&best.Hash, best.Height)
+	if sm.syncPeer != nil {
+		sm.updateSyncPeer(bmsg.peer != sm.syncPeer)
+	}
+}
+
+// downloadingHeaderBlocks returns whether the blocks of the downloaded headers
+// are being downloaded in headers-first mode, which is the case once all of the
+// headers were downloaded or while their download is paused.
+func (sm *SyncManager) downloadingHeaderBlocks() bool {
+	return sm.headersFirstMode && (sm.headersSynced || sm.headersPaused)
+}
+
+// queuedHeaders returns the number of downloaded headers whose blocks were not
+// processed yet in headers-first mode.
+func (sm *SyncManager) queuedHeaders() int {
+	return len(sm.headers) - sm.nextProcess
+}
+
+// resumeHeaders resumes the paused download of the headers from the sync peer
+// once the blocks of half of the queued headers were processed.
+func (sm *SyncManager) resumeHeaders() {
+	if !sm.headersPaused || sm.queuedHeaders() > maxQueuedHeaders/2 ||
+		sm.syncPeer == nil {
+
 		return
 	}
 
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := sm.nextCheckpoint.Height
-	prevHash := sm.nextCheckpoint.Hash
-	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
-	if sm.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+	sm.headersPaused = false
+	last := &sm.headers[len(sm.headers)-1]
+	log.Infof("Resuming the download of the block headers after height %d "+
+		"from peer %s", last.height, sm.syncPeer.Addr())
+	requestHeaders(sm.syncPeer, &last.hash)
+}
+
+// checkHeaderContext performs the checks of the passed header, which must
+// connect to the last downloaded header, that depend on its position within
+// the chain of the headers.  Most importantly, its difficulty must match the
+// difficulty retarget rules, so a peer can't make the headers with less work
+// than they claim to have kept.  The checkpoints are verified separately.
+func (sm *SyncManager) checkHeaderContext(header *wire.BlockHeader) error {
+	start, err := sm.chain.HeaderCtxByHash(&sm.headers[0].hash)
+	if err != nil {
+		return err
+	}
+	if sm.headersWork == nil {
+		sm.headersWork, err = sm.chain.ChainWork(&sm.headers[0].hash)
 		if err != nil {
-			log.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", peer.Addr(), err)
-			return
+			return err
 		}
-		log.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
-			sm.syncPeer.Addr())
+	}
+
+	prevCtx := newDownloadedHeaderCtx(sm.headers, len(sm.headers)-1, start)
+	return blockchain.CheckBlockHeaderContext(header, prevCtx,
+		blockchain.BFNone, sm.chain, true)
+}
+
+// updateAssumeValid lets the blocks up to the assumed valid block be processed
+// without verifying their scripts once its header was downloaded and the
+// downloaded headers have at least the minimum chain work.  Otherwise a peer
+// could have the scripts of its blocks skipped by serving a chain containing
+// the block with little work on top of the main chain.
+func (sm *SyncManager) updateAssumeValid() {
+	if sm.assumeValidHeight >= 0 || sm.assumeValidHeader < 0 {
+		return
+	}
+	if sm.minChainWork != nil && sm.headersWork.Cmp(sm.minChainWork) < 0 {
 		return
 	}
 
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	sm.headersFirstMode = false
-	sm.headerList.Init()
-	log.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = peer.PushGetBlocksMsg(locator, &zeroHash)
+	sm.assumeValidHeight = sm.assumeValidHeader
+	log.Infof("Processing the blocks up to height %d without verifying "+
+		"their scripts", sm.assumeValidHeight)
+}
+
+// finishHeadersFirst leaves headers-first mode once the blocks of all of the
+// downloaded headers have been processed and requests the blocks that were
+// announced in the meantime from the sync peer.
+func (sm *SyncManager) finishHeadersFirst() {
+	best := sm.chain.BestSnapshot()
+	sm.resetHeaderState(&best.Hash, best.Height)
+	log.Infof("Headers-first block download complete at height %d -- "+
+		"switching to normal mode", best.Height)
+
+	if err := sm.chain.FlushUtxoCache(blockchain.FlushPeriodic); err != nil {
+		log.Errorf("Error while flushing the blockchain cache: %v", err)
+	}
+
+	if sm.syncPeer == nil {
+		return
+	}
+	locator := blockchain.BlockLocator([]*chainhash.Hash{&best.Hash})
+	err := sm.syncPeer.PushGetBlocksMsg(locator, &zeroHash)
 	if err != nil {
 		log.Warnf("Failed to send getblocks message to peer %s: %v",
-			peer.Addr(), err)
-		return
+			sm.syncPeer.Addr(), err)
 	}
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
+// fetchHeaderBlocks requests the blocks within the download window that are
+// not downloaded or requested yet from the sync candidates in headers-first
+// mode.  The blocks are assigned in order to the peers with the highest scores
+// that have them, avoiding the peer a previous request for a block stalled
+// with, so the fastest peers download the blocks that are needed first.
 func (sm *SyncManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if sm.startHeader == nil {
-		log.Warnf("fetchHeaderBlocks called with no start header")
+	if !sm.downloadingHeaderBlocks() {
 		return
 	}
 
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(sm.headerList.Len()))
-	numRequested := 0
-	for e := sm.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			log.Warn("Header list node type is not a headerNode")
+	peers := sm.downloadPeers()
+	if len(peers) == 0 {
+		return
+	}
+	gdmsgs := make(map[*peerpkg.Peer]*wire.MsgGetData, len(peers))
+
+	windowEnd := sm.nextProcess + blockDownloadWindow
+	if windowEnd > len(sm.headers) {
+		windowEnd = len(sm.headers)
+	}
+	now := time.Now()
+	for i := sm.nextProcess; i < windowEnd; i++ {
+		// Only the next block to process is requested once the
+		// buffered blocks reached their maximum size, which ensures
+		// the buffer is drained.
+		if i > sm.nextProcess &&
+			sm.downloadedBytes >= maxDownloadedBlockBytes {
+
+			break
+		}
+
+		node := &sm.headers[i]
+		if _, exists := sm.blockRequests[node.hash]; exists {
+			continue
+		}
+		if _, exists := sm.downloadedBlocks[node.hash]; exists {
 			continue
 		}
 
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
-		haveInv, err := sm.haveInventory(iv)
+		// Pick the highest scoring peer that has the block.
+		var best *downloadPeer
+		stalledPeer := sm.stalledBlocks[node.hash]
+		for _, dp := range peers {
+			if dp.capacity > 0 && dp.peer != stalledPeer &&
+				peerHasBlock(dp.peer, node.height) {
+
+				best = dp
+				break
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		haveBlock, err := sm.chain.HaveBlock(&node.hash)
 		if err != nil {
 			log.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
+				"existing block during header block fetch: %v",
+				err)
+			continue
+		}
+		if haveBlock {
+			continue
 		}
-		if !haveInv {
-			syncPeerState := sm.peerStates[sm.syncPeer]
 
-			sm.requestedBlocks[*node.hash] = struct{}{}
-			syncPeerState.requestedBlocks[*node.hash] = struct{}{}
+		iv := wire.NewInvVect(wire.InvTypeBlock, &node.hash)
+		if best.peer.IsWitnessEnabled() {
+			iv.Type = wire.InvTypeWitnessBlock
+		}
+		gdmsg, ok := gdmsgs[best.peer]
+		if !ok {
+			gdmsg = wire.NewMsgGetData()
+			gdmsgs[best.peer] = gdmsg
+		}
+		gdmsg.AddInvVect(iv)
+		best.capacity--
 
-			// If we're fetching from a witness enabled peer
-			// post-fork, then ensure that we receive all the
-			// witness data in the blocks.
-			if sm.syncPeer.IsWitnessEnabled() {
-				iv.Type = wire.InvTypeWitnessBlock
-			}
+		sm.blockRequests[node.hash] = &blockRequest{
+			peer:      best.peer,
+			height:    node.height,
+			requested: now,
+		}
+		sm.requestedBlocks[node.hash] = struct{}{}
+		best.state.requestedBlocks[node.hash] = struct{}{}
+	}
 
-			gdmsg.AddInvVect(iv)
-			numRequested++
+	for peer, gdmsg := range gdmsgs {
+		log.Tracef("Requesting %d blocks from %s", len(gdmsg.InvList),
+			peer.Addr())
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
+// peerHasBlock returns whether the passed peer is expected to be able to serve
+// the block at the passed height based on its advertised height and services.
+func peerHasBlock(peer *peerpkg.Peer, height int32) bool {
+	peerHeight := peer.LastBlock()
+	if startHeight := peer.StartingHeight(); startHeight > peerHeight {
+		peerHeight = startHeight
+	}
+	if height > peerHeight {
+		return false
+	}
+
+	// Pruned peers only serve the most recent blocks.
+	if !peer.Services().HasFlag(wire.SFNodeNetwork) {
+		return height > peerHeight-wire.NodeNetworkLimitedBlockThreshold
+	}
+	return true
+}
+
+// dropBlockRequest drops the request for the passed block made in
+// headers-first mode so it is requested again from another peer.  The number
+// of stalls of the peer the block was requested from is increased when stalled
+// is true and the peer is disconnected once it stalled too often.
+func (sm *SyncManager) dropBlockRequest(hash *chainhash.Hash, req *blockRequest,
+	stalled bool) {
+
+	delete(sm.blockRequests, *hash)
+	delete(sm.requestedBlocks, *hash)
+	sm.stalledBlocks[*hash] = req.peer
+
+	state, exists := sm.peerStates[req.peer]
+	if !exists {
+		return
+	}
+	delete(state.requestedBlocks, *hash)
+	if !stalled {
+		return
+	}
+
+	state.stalls++
+	sm.peerReputation.Stalled(req.peer.Addr())
+	log.Debugf("Request for block %v (height %d) from %s stalled after "+
+		"%v", hash, req.height, req.peer.Addr(),
+		time.Since(req.requested))
+	if state.stalls >= maxPeerStalls {
+		log.Infof("Peer %s stalled %d block downloads -- disconnecting",
+			req.peer.Addr(), state.stalls)
+		req.peer.Disconnect()
+	}
+}
+
+// handleBlockStalls requests the blocks whose requests made in headers-first
+// mode stalled from other peers.  The request for the next block to process is
+// considered stalled much sooner than the others when later blocks already
+// arrived since it holds up the download window.
+func (sm *SyncManager) handleBlockStalls() {
+	if !sm.downloadingHeaderBlocks() {
+		return
+	}
+
+	var nextHash *chainhash.Hash
+	if sm.nextProcess < len(sm.headers) && len(sm.downloadedBlocks) > 0 {
+		nextHash = &sm.headers[sm.nextProcess].hash
+	}
+
+	now := time.Now()
+	numStalled := 0
+	for hash, req := range sm.blockRequests {
+		timeout := blockRequestTimeout
+		if nextHash != nil && hash == *nextHash {
+			timeout = windowStallTimeout
 		}
-		sm.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
+		if now.Sub(req.requested) < timeout {
+			continue
 		}
+
+		hash := hash
+		sm.dropBlockRequest(&hash, req, true)
+		numStalled++
 	}
-	if len(gdmsg.InvList) > 0 {
-		sm.syncPeer.QueueMessage(gdmsg, nil)
+
+	if numStalled > 0 {
+		sm.fetchHeaderBlocks()
 	}
 }
 
 // handleHeadersMsg handles block header messages from all peers.  Headers are
-// requested when performing a headers-first sync.
+// requested from the sync peer when performing a headers-first sync.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	peer := hmsg.peer
 	_, exists := sm.peerStates[peer]
@@ -971,39 +1507,60 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
-	// Nothing to do for an empty headers message.
-	if numHeaders == 0 {
+	// Ignore headers that are not from the sync peer or arrive after all
+	// of the headers were downloaded or while their download is paused.
+	if peer != sm.syncPeer || sm.headersSynced || sm.headersPaused {
+		log.Debugf("Ignoring %d headers from %s", numHeaders,
+			peer.Addr())
 		return
 	}
 
 	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
-	receivedCheckpoint := false
-	var finalHash *chainhash.Hash
-	for _, blockHeader := range msg.Headers {
+	// previous, has a valid proof of work and that checkpoints match.
+	for i := range msg.Headers {
+		blockHeader := msg.Headers[i]
 		blockHash := blockHeader.BlockHash()
-		finalHash = &blockHash
+		prevNode := &sm.headers[len(sm.headers)-1]
+		presyncing := sm.presync != nil && !sm.presync.redownload
+		if presyncing {
+			prevNode = &sm.presync.last
+		}
 
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := sm.headerList.Back()
-		if prevNodeEl == nil {
-			log.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
-			peer.Disconnect()
-			return
+		// The first header connects to the fork point of the chain of
+		// the peer and the main chain, which is not the best block when
+		// the best block is not in the chain of the peer.  Start the
+		// header list from the fork point in that case.
+		firstHeader := len(sm.headers) == 1 && sm.presync == nil
+		if firstHeader && !prevNode.hash.IsEqual(&blockHeader.PrevBlock) &&
+			sm.chain.MainChainHasBlock(&blockHeader.PrevBlock) {
+
+			height, err := sm.chain.BlockHeightByHash(&blockHeader.PrevBlock)
+			if err == nil {
+				prevNode.height = height
+				prevNode.hash = blockHeader.PrevBlock
+			}
 		}
 
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := sm.headerList.PushBack(&node)
-			if sm.startHeader == nil {
-				sm.startHeader = e
+		// Pre-sync the headers without keeping them when the chain of
+		// the fork point has too little work.
+		if firstHeader {
+			presync, err := sm.newPresync(prevNode)
+			if err != nil {
+				log.Errorf("Unable to determine whether to pre-sync "+
+					"headers: %v", err)
 			}
-		} else {
+			if presync != nil {
+				log.Infof("Pre-syncing headers from peer %s until "+
+					"they have the minimum chain work",
+					peer.Addr())
+				sm.presync = presync
+				presyncing = true
+				prevNode = &presync.last
+			}
+		}
+
+		// Ensure the header properly connects to the previous one.
+		if !prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
 			log.Warnf("Received block header that does not "+
 				"properly connect to the chain from peer %s "+
 				"-- disconnecting", peer.Addr())
@@ -1011,52 +1568,151 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 			return
 		}
 
-		// Verify the header at the next checkpoint height matches.
-		if node.height == sm.nextCheckpoint.Height {
-			if node.hash.IsEqual(sm.nextCheckpoint.Hash) {
-				receivedCheckpoint = true
-				log.Infof("Verified downloaded block "+
-					"header against checkpoint at height "+
-					"%d/hash %s", node.height, node.hash)
-			} else {
+		// Ensure the header is sane, which includes checking the proof
+		// of work, since the blocks are downloaded based on it.
+		err := blockchain.CheckBlockHeaderSanity(blockHeader,
+			sm.chainParams.PowLimit, sm.timeSource, blockchain.BFNone)
+		if err != nil {
+			log.Warnf("Received invalid block header %v from peer "+
+				"%s: %v -- disconnecting", blockHash,
+				peer.Addr(), err)
+			peer.Disconnect()
+			return
+		}
+
+		// Verify the header matches the checkpoint at its height.
+		node := headerNode{
+			height:    prevNode.height + 1,
+			hash:      blockHash,
+			bits:      blockHeader.Bits,
+			timestamp: blockHeader.Timestamp.Unix(),
+		}
+		if checkpoint, ok := sm.checkpoints[node.height]; ok {
+			if !node.hash.IsEqual(&checkpoint) {
 				log.Warnf("Block header at height %d/hash "+
 					"%s from peer %s does NOT match "+
 					"expected checkpoint hash of %s -- "+
 					"disconnecting", node.height,
-					node.hash, peer.Addr(),
-					sm.nextCheckpoint.Hash)
+					node.hash, peer.Addr(), checkpoint)
+				peer.Disconnect()
+				return
+			}
+			sm.verifiedCheckpoint = node.height
+			log.Infof("Verified downloaded block header against "+
+				"checkpoint at height %d/hash %s", node.height,
+				node.hash)
+		}
+
+		if sm.presync != nil {
+			if err := sm.presync.addHeader(node, blockHeader.Bits); err != nil {
+				log.Warnf("Received invalid block header from "+
+					"peer %s: %v -- disconnecting",
+					peer.Addr(), err)
 				peer.Disconnect()
 				return
 			}
-			break
+		}
+		if !presyncing {
+			// Ensure the difficulty and the timestamp of the header
+			// follow from the previous headers, so the work of the
+			// headers that are kept is the work they claim to have.
+			if err := sm.checkHeaderContext(blockHeader); err != nil {
+				log.Warnf("Received invalid block header %v from "+
+					"peer %s: %v -- disconnecting", blockHash,
+					peer.Addr(), err)
+				peer.Disconnect()
+				return
+			}
+
+			if sm.assumeValid != nil && node.hash == *sm.assumeValid {
+				sm.assumeValidHeader = node.height
+				log.Infof("Downloaded the header of the assumed "+
+					"valid block at height %d/hash %s", node.height,
+					node.hash)
+			}
+			sm.headers = append(sm.headers, node)
+			sm.headersWork.Add(sm.headersWork,
+				blockchain.CalcWork(blockHeader.Bits))
+			sm.updateAssumeValid()
+			if node.height > atomic.LoadInt32(&sm.headerHeight) {
+				atomic.StoreInt32(&sm.headerHeight, node.height)
+			}
+			continue
+		}
+
+		// Download the headers again to keep them once the pre-synced
+		// headers have enough work.  The remaining headers of the
+		// message are downloaded again as well.
+		if sm.presync.reachedThreshold() {
+			sm.lastProgressTime = time.Now()
+			sm.presync.startRedownload()
+			start := sm.presync.start
+			log.Infof("Pre-synced headers up to height %d from peer "+
+				"%s: downloading them again from height %d",
+				node.height, peer.Addr(), start.height)
+			requestHeaders(peer, &start.hash)
+			return
 		}
 	}
+	sm.lastProgressTime = time.Now()
+
+	// Request the next batch of headers starting from the latest known
+	// header when the message is full since the peer likely has more.
+	// The download of the headers that are kept is paused instead once
+	// too many of them wait for their blocks, whose download starts.
+	presyncing := sm.presync != nil && !sm.presync.redownload
+	if numHeaders == wire.MaxBlockHeadersPerMsg {
+		finalHash := &sm.headers[len(sm.headers)-1].hash
+		if presyncing {
+			finalHash = &sm.presync.last.hash
+			log.Debugf("Pre-synced headers up to height %d from "+
+				"peer %s", sm.presync.last.height, peer.Addr())
+		} else if sm.queuedHeaders() >= maxQueuedHeaders {
+			log.Infof("Received %d block headers up to height %d: "+
+				"fetching blocks before the remaining headers",
+				sm.queuedHeaders(),
+				sm.headers[len(sm.headers)-1].height)
+			sm.headersPaused = true
+			sm.progressLogger.SetLastLogTime(time.Now())
+			sm.fetchHeaderBlocks()
+			return
+		}
+		requestHeaders(peer, finalHash)
+		return
+	}
 
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
-	if receivedCheckpoint {
-		// Since the first entry of the list is always the final block
-		// that is already in the database and is only used to ensure
-		// the next header links properly, it must be removed before
-		// fetching the blocks.
-		sm.headerList.Remove(sm.headerList.Front())
-		log.Infof("Received %v block headers: Fetching blocks",
-			sm.headerList.Len())
-		sm.progressLogger.SetLastLogTime(time.Now())
-		sm.fetchHeaderBlocks()
+	// The chain of the sync peer doesn't have enough work when the
+	// pre-synced headers end before reaching the threshold, so look for
+	// another sync peer.  The same applies when the headers downloaded
+	// again end earlier, except that the peer served another chain and is
+	// disconnected.
+	if sm.presync != nil && !sm.presync.reachedThreshold() {
+		if presyncing {
+			log.Infof("Chain of headers from peer %s ends at height "+
+				"%d without the minimum chain work -- ignoring "+
+				"peer", peer.Addr(), sm.presync.last.height)
+			sm.peerStates[peer].syncCandidate = false
+		} else {
+			log.Warnf("Headers downloaded again from peer %s end "+
+				"at height %d before the pre-synced headers -- "+
+				"disconnecting", peer.Addr(),
+				sm.presync.last.height)
+		}
+		sm.updateSyncPeer(!presyncing)
 		return
 	}
 
-	// This header is not a checkpoint, so request the next batch of
-	// headers starting from the latest known header and ending with the
-	// next checkpoint.
-	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-	if err != nil {
-		log.Warnf("Failed to send getheaders message to "+
-			"peer %s: %v", peer.Addr(), err)
+	// All of the headers up to the tip of the sync peer are downloaded, so
+	// switch to downloading the blocks from all sync candidates.
+	sm.headersSynced = true
+	if len(sm.headers) == 1 {
+		sm.finishHeadersFirst()
 		return
 	}
+	log.Infof("Received %d block headers up to height %d: fetching blocks",
+		len(sm.headers)-1, sm.headers[len(sm.headers)-1].height)
+	sm.progressLogger.SetLastLogTime(time.Now())
+	sm.fetchHeaderBlocks()
 }
 
 // handleNotFoundMsg handles notfound messages from all peers.
@@ -1067,6 +1723,7 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 		log.Warnf("Received notfound message from unknown peer %s", peer)
 		return
 	}
+	var refetch bool
 	for _, inv := range nfmsg.notFound.InvList {
 		// verify the hash was actually announced by the peer
 		// before deleting from the global requested maps.
@@ -1078,6 +1735,19 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 				delete(state.requestedBlocks, inv.Hash)
 				delete(sm.requestedBlocks, inv.Hash)
 			}
+			if req, exists := sm.blockRequests[inv.Hash]; exists &&
+				req.peer == peer {
+
+				hash := inv.Hash
+				sm.dropBlockRequest(&hash, req, false)
+				refetch = true
+			}
+			if fetch, exists := sm.blockFetches[inv.Hash]; exists &&
+				fetch.peer == peer {
+
+				hash := inv.Hash
+				sm.finishBlockFetch(&hash, fetch, nil)
+			}
 
 		case wire.InvTypeWitnessTx:
 			fallthrough
@@ -1088,6 +1758,12 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 			}
 		}
 	}
+
+	// Request the blocks the peer didn't have in headers-first mode from
+	// other peers.
+	if refetch {
+		sm.fetchHeaderBlocks()
+	}
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -1235,6 +1911,13 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				continue
 			}
 
+			// Start tracing the relay of new blocks announced
+			// while current.
+			if iv.Type == wire.InvTypeBlock && sm.current() {
+				sm.blockTracer.Record(&iv.Hash,
+					blocktrace.StageHeaderReceived, peer.Addr())
+			}
+
 			// Add it to the request queue.
 			state.requestQueue = append(state.requestQueue, iv)
 			continue
@@ -1305,6 +1988,8 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 					iv.Type = wire.InvTypeWitnessBlock
 				}
 
+				sm.blockTracer.Record(&iv.Hash,
+					blocktrace.StageRequested, peer.Addr())
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -1348,11 +2033,15 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 func (sm *SyncManager) blockHandler() {
 	stallTicker := time.NewTicker(stallSampleInterval)
 	defer stallTicker.Stop()
+	blockStallTicker := time.NewTicker(blockStallCheckInterval)
+	defer blockStallTicker.Stop()
 
 out:
 	for {
+		sm.activity.set(nil)
 		select {
 		case m := <-sm.msgChan:
+			sm.activity.set(m)
 			switch msg := m.(type) {
 			case *newPeerMsg:
 				sm.handleNewPeerMsg(msg.peer)
@@ -1377,6 +2066,18 @@ out:
 			case *donePeerMsg:
 				sm.handleDonePeerMsg(msg.peer)
 
+			case getDownloadStatsMsg:
+				msg.reply <- sm.handleDownloadStatsMsg()
+
+			case fetchBlockMsg:
+				sm.handleFetchBlockMsg(msg)
+
+			case requestBlockMsg:
+				sm.handleRequestBlockMsg(msg)
+
+			case getSyncStateMsg:
+				msg.reply <- sm.handleSyncStateMsg()
+
 			case getSyncPeerMsg:
 				var peerID int32
 				if sm.syncPeer != nil {
@@ -1412,8 +2113,14 @@ out:
 			}
 
 		case <-stallTicker.C:
+			sm.activity.set("stall sample")
 			sm.handleStallSample()
 
+		case <-blockStallTicker.C:
+			sm.activity.set("block stall check")
+			sm.handleBlockStalls()
+			sm.failBlockFetches(nil)
+
 		case <-sm.quit:
 			break out
 		}
@@ -1452,8 +2159,21 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
 		sm.peerNotifier.RelayInventory(iv, block.MsgBlock().Header)
 
+	// A block passed full validation.  Record it for the block tracer.
+	case blockchain.NTBlockValidated:
+		block, ok := notification.Data.(*btcutil.Block)
+		if !ok {
+			log.Warnf("Chain validated notification is not a block.")
+			break
+		}
+		sm.blockTracer.Record(block.Hash(), blocktrace.StageValidated, "")
+
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
+		if block, ok := notification.Data.(*btcutil.Block); ok {
+			sm.blockTracer.RecordConnected(block.Hash(), block.Height())
+		}
+
 		// Don't attempt to update the mempool if we're not current.
 		// The mempool is empty and the fee estimator is useless unless
 		// we're caught up.
@@ -1475,7 +2195,7 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveConfirmedTransaction(tx)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1485,16 +2205,7 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 
 		// Register block with the fee estimator, if it exists.
 		if sm.feeEstimator != nil {
-			err := sm.feeEstimator.RegisterBlock(block)
-
-			// If an error is somehow generated then the fee estimator
-			// has entered an invalid state. Since it doesn't know how
-			// to recover, create a new one.
-			if err != nil {
-				sm.feeEstimator = mempool.NewFeeEstimator(
-					mempool.DefaultEstimateFeeMaxRollback,
-					mempool.DefaultEstimateFeeMinRegisteredBlocks)
-			}
+			sm.feeEstimator.RegisterBlock(block)
 		}
 
 	// A block has been disconnected from the main block chain.
@@ -1517,11 +2228,6 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				sm.txMemPool.RemoveTransaction(tx, true)
 			}
 		}
-
-		// Rollback previous block recorded by the fee estimator.
-		if sm.feeEstimator != nil {
-			sm.feeEstimator.Rollback(block.Hash())
-		}
 	}
 }
 
@@ -1639,6 +2345,15 @@ func (sm *SyncManager) SyncPeerID() int32 {
 	return <-reply
 }
 
+// PeerDownloadStats returns the statistics of the blocks downloaded from the
+// peers in headers-first mode keyed by peer ID.  Peers no blocks were
+// downloaded from or requested from are not included.
+func (sm *SyncManager) PeerDownloadStats() map[int32]*PeerDownloadStats {
+	reply := make(chan map[int32]*PeerDownloadStats)
+	sm.msgChan <- getDownloadStatsMsg{reply: reply}
+	return <-reply
+}
+
 // ProcessBlock makes use of ProcessBlock on an internal instance of a block
 // chain.
 func (sm *SyncManager) ProcessBlock(block *btcutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
@@ -1656,6 +2371,19 @@ func (sm *SyncManager) IsCurrent() bool {
 	return <-reply
 }
 
+// HeaderHeight returns the height of the best header known to the sync
+// manager, which is the height of the last header downloaded in headers-first
+// mode when it is ahead of the main chain.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) HeaderHeight() int32 {
+	height := sm.chain.BestSnapshot().Height
+	if headerHeight := atomic.LoadInt32(&sm.headerHeight); headerHeight > height {
+		return headerHeight
+	}
+	return height
+}
+
 // Pause pauses the sync manager until the returned channel is closed.
 //
 // Note that while paused, all peer and block processing is halted.  The
@@ -1678,19 +2406,32 @@ func New(config *Config) (*SyncManager, error) {
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 		peerStates:      make(map[*peerpkg.Peer]*peerSyncState),
+		blockFetches:    make(map[chainhash.Hash]*blockFetch),
 		progressLogger:  newBlockProgressLogger("Processed", log),
 		msgChan:         make(chan interface{}, config.MaxPeers*3),
-		headerList:      list.New(),
+		checkpoints:     make(map[int32]chainhash.Hash),
+		timeSource:      config.TimeSource,
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
+		blockTracer:     config.BlockTracer,
+		peerReputation:  config.PeerReputation,
+		minChainWork:    config.ChainParams.MinimumChainWork,
+		assumeValid:     config.AssumeValid,
+	}
+	if config.MinimumChainWork != nil {
+		sm.minChainWork = config.MinimumChainWork
+	}
+	if sm.timeSource == nil {
+		sm.timeSource = blockchain.NewMedianTime()
 	}
 
 	best := sm.chain.BestSnapshot()
+	sm.resetHeaderState(&best.Hash, best.Height)
 	if !config.DisableCheckpoints {
-		// Initialize the next checkpoint based on the current height.
-		sm.nextCheckpoint = sm.findNextHeaderCheckpoint(best.Height)
-		if sm.nextCheckpoint != nil {
-			sm.resetHeaderState(&best.Hash, best.Height)
+		// Index the checkpoints by height so the downloaded headers
+		// can be verified against them.
+		for _, checkpoint := range sm.chain.Checkpoints() {
+			sm.checkpoints[checkpoint.Height] = *checkpoint.Hash
 		}
 	} else {
 		log.Info("Checkpoints are disabled")
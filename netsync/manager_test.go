@@ -0,0 +1,731 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newTestSyncManager returns a sync manager for a simnet chain that only
+// contains the genesis block.  The block handler is not started, so the tests
+// invoke the handlers directly.
+func newTestSyncManager(t *testing.T) *SyncManager {
+	params := &chaincfg.SimNetParams
+	db, err := database.Create("ffldb", t.TempDir(), params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	sm, err := New(&Config{
+		Chain:              chain,
+		ChainParams:        params,
+		DisableCheckpoints: true,
+		MaxPeers:           8,
+	})
+	if err != nil {
+		t.Fatalf("unable to create sync manager: %v", err)
+	}
+	return sm
+}
+
+// tcpPipe returns both ends of a loopback TCP connection.  Unlike the ends of
+// net.Pipe, they buffer writes, so both peers can send messages at the same
+// time during the handshake.
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- conn
+	}()
+	local, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	remote := <-accepted
+	if remote == nil {
+		t.Fatalf("unable to accept connection")
+	}
+	return local, remote
+}
+
+// testPeer is a peer connected to a remote peer which records the requests it
+// receives.
+type testPeer struct {
+	peer       *peerpkg.Peer
+	getData    chan *wire.MsgGetData
+	getHeaders chan *wire.MsgGetHeaders
+	getBlocks  chan *wire.MsgGetBlocks
+}
+
+// newTestPeer returns a peer that completed the handshake with a remote full
+// node whose best block is at the passed height.
+func newTestPeer(t *testing.T, id int, height int32) *testPeer {
+	tp := &testPeer{
+		getData:    make(chan *wire.MsgGetData, 100),
+		getHeaders: make(chan *wire.MsgGetHeaders, 10),
+		getBlocks:  make(chan *wire.MsgGetBlocks, 10),
+	}
+	remoteCfg := &peerpkg.Config{
+		Listeners: peerpkg.MessageListeners{
+			OnGetData: func(_ *peerpkg.Peer, msg *wire.MsgGetData) {
+				tp.getData <- msg
+			},
+			OnGetHeaders: func(_ *peerpkg.Peer, msg *wire.MsgGetHeaders) {
+				tp.getHeaders <- msg
+			},
+			OnGetBlocks: func(_ *peerpkg.Peer, msg *wire.MsgGetBlocks) {
+				tp.getBlocks <- msg
+			},
+		},
+		NewestBlock: func() (*chainhash.Hash, int32, error) {
+			return &chainhash.Hash{}, height, nil
+		},
+		ChainParams:    &chaincfg.SimNetParams,
+		Services:       wire.SFNodeNetwork | wire.SFNodeWitness,
+		AllowSelfConns: true,
+	}
+	localCfg := &peerpkg.Config{
+		ChainParams:    &chaincfg.SimNetParams,
+		AllowSelfConns: true,
+	}
+
+	addr := fmt.Sprintf("10.0.0.%d:18555", id)
+	local, err := peerpkg.NewOutboundPeer(localCfg, addr)
+	if err != nil {
+		t.Fatalf("unable to create peer: %v", err)
+	}
+	remote := peerpkg.NewInboundPeer(remoteCfg)
+	localConn, remoteConn := tcpPipe(t)
+	local.AssociateConnection(localConn)
+	remote.AssociateConnection(remoteConn)
+	t.Cleanup(func() {
+		local.Disconnect()
+		remote.Disconnect()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !local.VerAckReceived() || !remote.VerAckReceived() {
+		if time.Now().After(deadline) {
+			t.Fatalf("handshake with peer %s timed out", addr)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tp.peer = local
+	return tp
+}
+
+// addPeer adds the passed peer to the sync manager as a sync candidate without
+// starting to sync.
+func addPeer(sm *SyncManager, tp *testPeer) *peerSyncState {
+	state := &peerSyncState{
+		syncCandidate:   sm.isSyncCandidate(tp.peer),
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+	}
+	sm.peerStates[tp.peer] = state
+	return state
+}
+
+// setTestHeaders puts the sync manager in headers-first mode with all of the
+// headers up to the passed height downloaded.  The hashes of the headers are
+// made up since the blocks are never processed.
+func setTestHeaders(sm *SyncManager, height int32) {
+	best := sm.chain.BestSnapshot()
+	sm.resetHeaderState(&best.Hash, best.Height)
+	for h := best.Height + 1; h <= height; h++ {
+		sm.headers = append(sm.headers, headerNode{
+			height: h,
+			hash:   chainhash.Hash{0x01, byte(h >> 8), byte(h)},
+		})
+	}
+	sm.headersFirstMode = true
+	sm.headersSynced = true
+}
+
+// waitGetData returns the next getdata message the remote peer received or
+// fails the test when none arrives in time.
+func waitGetData(t *testing.T, tp *testPeer) *wire.MsgGetData {
+	t.Helper()
+
+	select {
+	case msg := <-tp.getData:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for getdata from %s", tp.peer.Addr())
+		return nil
+	}
+}
+
+// waitDisconnect fails the test when the passed peer doesn't disconnect in
+// time.
+func waitDisconnect(t *testing.T, tp *testPeer) {
+	t.Helper()
+
+	select {
+	case <-disconnected(tp.peer):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("peer %s was not disconnected", tp.peer.Addr())
+	}
+}
+
+// disconnected returns a channel that is closed once the passed peer
+// disconnected.
+func disconnected(p *peerpkg.Peer) <-chan struct{} {
+	c := make(chan struct{})
+	go func() {
+		p.WaitForDisconnect()
+		close(c)
+	}()
+	return c
+}
+
+// TestStartSyncPeerSelection ensures a headers-first sync is only started with
+// a peer that is ahead of the chain, while a peer at the same height is sent a
+// getblocks request instead, and that disconnected peers are never selected.
+func TestStartSyncPeerSelection(t *testing.T) {
+	// A peer at the same height as the chain is synced from with getblocks
+	// since it has no headers to provide.
+	sm := newTestSyncManager(t)
+	equal := newTestPeer(t, 1, 0)
+	addPeer(sm, equal)
+	sm.startSync()
+	if sm.syncPeer != equal.peer {
+		t.Fatalf("peer at the same height not selected as sync peer")
+	}
+	if sm.headersFirstMode {
+		t.Fatalf("headers-first mode started with a peer at the same " +
+			"height")
+	}
+	select {
+	case <-equal.getBlocks:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("getblocks not sent to peer at the same height")
+	}
+
+	// A peer ahead of the chain is preferred and headers are downloaded
+	// from it.
+	sm = newTestSyncManager(t)
+	equal = newTestPeer(t, 2, 0)
+	higher := newTestPeer(t, 3, 100)
+	addPeer(sm, equal)
+	addPeer(sm, higher)
+	sm.startSync()
+	if sm.syncPeer != higher.peer {
+		t.Fatalf("peer ahead of the chain not selected as sync peer")
+	}
+	if !sm.headersFirstMode {
+		t.Fatalf("headers-first mode not started with a peer ahead of " +
+			"the chain")
+	}
+	select {
+	case <-higher.getHeaders:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("getheaders not sent to peer ahead of the chain")
+	}
+
+	// A disconnected peer is never selected even if it is the only one
+	// ahead of the chain.
+	sm = newTestSyncManager(t)
+	equal = newTestPeer(t, 4, 0)
+	higher = newTestPeer(t, 5, 100)
+	addPeer(sm, equal)
+	addPeer(sm, higher)
+	higher.peer.Disconnect()
+	sm.startSync()
+	if sm.syncPeer != equal.peer {
+		t.Fatalf("disconnected peer selected as sync peer")
+	}
+	if sm.headersFirstMode {
+		t.Fatalf("headers-first mode started with a disconnected peer")
+	}
+}
+
+// TestFetchHeaderBlocks ensures the blocks of the downloaded headers are
+// requested from the peers that have them without exceeding the number of
+// blocks in flight per peer.
+func TestFetchHeaderBlocks(t *testing.T) {
+	sm := newTestSyncManager(t)
+	low := newTestPeer(t, 1, 5)
+	high := newTestPeer(t, 2, 100)
+	lowState := addPeer(sm, low)
+	highState := addPeer(sm, high)
+	setTestHeaders(sm, 100)
+
+	// Make the peer that is behind the faster one so it is assigned the
+	// first blocks.
+	lowState.download = downloadStats{blocks: 1, throughput: 2}
+	highState.download = downloadStats{blocks: 1, throughput: 1}
+	avgScore := sm.averageScore()
+	highMax := maxBlocksInFlight(1, avgScore)
+
+	sm.fetchHeaderBlocks()
+
+	// The peer behind is only assigned the blocks it has, and the other
+	// peer the following blocks up to the number of blocks it may have in
+	// flight.
+	perPeer := make(map[*peerpkg.Peer]int)
+	for _, req := range sm.blockRequests {
+		perPeer[req.peer]++
+		switch {
+		case req.peer == low.peer && req.height > 5:
+			t.Fatalf("block %d requested from peer at height 5",
+				req.height)
+
+		case req.peer == high.peer && req.height <= 5:
+			t.Fatalf("block %d requested from slower peer",
+				req.height)
+		}
+	}
+	if n := perPeer[low.peer]; n != 5 {
+		t.Fatalf("unexpected blocks requested from lower peer: got "+
+			"%d, want 5", n)
+	}
+	if n := perPeer[high.peer]; n != highMax {
+		t.Fatalf("unexpected blocks requested from higher peer: got "+
+			"%d, want %d", n, highMax)
+	}
+
+	// The requests reach the peers and match the tracked state.
+	for _, tp := range []*testPeer{low, high} {
+		msg := waitGetData(t, tp)
+		if len(msg.InvList) != perPeer[tp.peer] {
+			t.Fatalf("unexpected getdata size for %s: got %d, "+
+				"want %d", tp.peer.Addr(), len(msg.InvList),
+				perPeer[tp.peer])
+		}
+		state := sm.peerStates[tp.peer]
+		for _, iv := range msg.InvList {
+			if iv.Type != wire.InvTypeWitnessBlock {
+				t.Fatalf("unexpected inventory type %v", iv.Type)
+			}
+			if _, ok := state.requestedBlocks[iv.Hash]; !ok {
+				t.Fatalf("block %v not tracked as requested "+
+					"from %s", iv.Hash, tp.peer.Addr())
+			}
+		}
+	}
+
+	// Nothing more is requested while the peers are busy.
+	sm.fetchHeaderBlocks()
+	if n := len(sm.blockRequests); n != 5+highMax {
+		t.Fatalf("unexpected number of block requests: %d", n)
+	}
+}
+
+// TestDownloadedBlockBytes ensures only the next block to process is requested
+// once the buffered blocks reached their maximum size.
+func TestDownloadedBlockBytes(t *testing.T) {
+	sm := newTestSyncManager(t)
+	tp := newTestPeer(t, 1, 100)
+	addPeer(sm, tp)
+	setTestHeaders(sm, 100)
+
+	// Buffer a block after the next one that fills the buffer.
+	later := sm.headers[sm.nextProcess+1].hash
+	sm.downloadedBlocks[later] = &blockMsg{size: maxDownloadedBlockBytes}
+	sm.downloadedBytes = maxDownloadedBlockBytes
+	sm.fetchHeaderBlocks()
+	next := sm.headers[sm.nextProcess].hash
+	if len(sm.blockRequests) != 1 || sm.blockRequests[next] == nil {
+		t.Fatalf("unexpected requests with a full buffer: %d",
+			len(sm.blockRequests))
+	}
+	msg := waitGetData(t, tp)
+	if len(msg.InvList) != 1 || msg.InvList[0].Hash != next {
+		t.Fatalf("unexpected getdata with a full buffer")
+	}
+
+	// The following blocks are requested once the buffer is drained.
+	sm.removeDownloadedBlock(&later)
+	if sm.downloadedBytes != 0 {
+		t.Fatalf("unexpected buffered bytes: %d", sm.downloadedBytes)
+	}
+	sm.fetchHeaderBlocks()
+	if len(sm.blockRequests) <= 1 {
+		t.Fatalf("no blocks requested after draining the buffer")
+	}
+}
+
+// TestHandleBlockStalls ensures a stalled request for the block holding up the
+// download window is made again from another peer and that a peer which stalls
+// too often is disconnected.
+func TestHandleBlockStalls(t *testing.T) {
+	sm := newTestSyncManager(t)
+	slow := newTestPeer(t, 1, 100)
+	fast := newTestPeer(t, 2, 100)
+	slowState := addPeer(sm, slow)
+	addPeer(sm, fast)
+	setTestHeaders(sm, 100)
+
+	// Request the next block from the slow peer only.
+	delete(sm.peerStates, fast.peer)
+	next := sm.headers[sm.nextProcess]
+	sm.fetchHeaderBlocks()
+	waitGetData(t, slow)
+	sm.peerStates[fast.peer] = &peerSyncState{
+		syncCandidate:   true,
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+	}
+
+	// The request for the next block isn't stalled while no later blocks
+	// were downloaded, even after the window stall timeout.
+	req := sm.blockRequests[next.hash]
+	if req == nil || req.peer != slow.peer {
+		t.Fatalf("next block not requested from the slow peer")
+	}
+	req.requested = time.Now().Add(-2 * windowStallTimeout)
+	sm.handleBlockStalls()
+	if slowState.stalls != 0 {
+		t.Fatalf("request stalled without downloaded blocks")
+	}
+
+	// Once a later block was downloaded, the request for the next block is
+	// considered stalled and made from the other peer.
+	later := sm.headers[sm.nextProcess+1].hash
+	sm.downloadedBlocks[later] = &blockMsg{}
+	sm.handleBlockStalls()
+	if slowState.stalls != 1 {
+		t.Fatalf("unexpected stalls of slow peer: got %d, want 1",
+			slowState.stalls)
+	}
+	req = sm.blockRequests[next.hash]
+	if req == nil || req.peer != fast.peer {
+		t.Fatalf("stalled block not requested from the other peer")
+	}
+	if sm.stalledBlocks[next.hash] != slow.peer {
+		t.Fatalf("stalled block not recorded for the slow peer")
+	}
+	found := false
+	for !found {
+		msg := waitGetData(t, fast)
+		for _, iv := range msg.InvList {
+			if iv.Hash == next.hash {
+				found = true
+			}
+		}
+	}
+
+	// Any other request is stalled after the request timeout, and the
+	// slow peer is disconnected once it stalled too often.
+	var numStale int
+	for _, req := range sm.blockRequests {
+		if req.peer == slow.peer {
+			req.requested = time.Now().Add(-2 * blockRequestTimeout)
+			numStale++
+		}
+	}
+	if numStale < maxPeerStalls-1 {
+		t.Fatalf("not enough requests from the slow peer: %d", numStale)
+	}
+	sm.handleBlockStalls()
+	if slowState.stalls < maxPeerStalls {
+		t.Fatalf("unexpected stalls of slow peer: got %d, want at "+
+			"least %d", slowState.stalls, maxPeerStalls)
+	}
+	waitDisconnect(t, slow)
+	for hash, req := range sm.blockRequests {
+		if req.peer == slow.peer {
+			t.Fatalf("block %v still requested from disconnected "+
+				"peer", hash)
+		}
+	}
+}
+
+// TestHandleStallSample ensures a sync peer which made no progress for too long
+// is disconnected and replaced when it claims to have more blocks, while a sync
+// peer at the same height as the chain is kept.
+func TestHandleStallSample(t *testing.T) {
+	sm := newTestSyncManager(t)
+	stalling := newTestPeer(t, 1, 100)
+	addPeer(sm, stalling)
+	sm.startSync()
+	if sm.syncPeer != stalling.peer {
+		t.Fatalf("peer ahead of the chain not selected as sync peer")
+	}
+
+	// Add a second peer ahead of the chain once syncing started.
+	other := newTestPeer(t, 2, 100)
+	addPeer(sm, other)
+
+	// Nothing happens before the stall timeout.
+	sm.lastProgressTime = time.Now().Add(-maxStallDuration / 2)
+	sm.handleStallSample()
+	if sm.syncPeer != stalling.peer {
+		t.Fatalf("sync peer replaced before the stall timeout")
+	}
+
+	// The stalled sync peer is disconnected and the other peer, which is
+	// the only one left connected, becomes the sync peer.
+	sm.lastProgressTime = time.Now().Add(-2 * maxStallDuration)
+	sm.handleStallSample()
+	waitDisconnect(t, stalling)
+	if sm.syncPeer != other.peer {
+		t.Fatalf("stalled sync peer not replaced")
+	}
+	select {
+	case <-other.getHeaders:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("getheaders not sent to new sync peer")
+	}
+
+	// A stalled sync peer at the same height as the chain is kept since
+	// there may simply be no new blocks.
+	sm = newTestSyncManager(t)
+	equal := newTestPeer(t, 3, 0)
+	addPeer(sm, equal)
+	sm.startSync()
+	sm.lastProgressTime = time.Now().Add(-2 * maxStallDuration)
+	sm.handleStallSample()
+	if !equal.peer.Connected() {
+		t.Fatalf("sync peer at the same height disconnected")
+	}
+	if sm.syncPeer != equal.peer {
+		t.Fatalf("sync peer at the same height not kept")
+	}
+}
+
+// TestUpdateAssumeValid ensures the scripts of the blocks up to the assumed
+// valid block are only skipped once the downloaded headers containing it have
+// at least the minimum chain work.
+func TestUpdateAssumeValid(t *testing.T) {
+	sm := newTestSyncManager(t)
+	genesisWork, err := sm.chain.ChainWork(sm.chainParams.GenesisHash)
+	if err != nil {
+		t.Fatalf("unable to get chain work: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		headersWork int64
+		minWork     int64
+		noMinWork   bool
+		found       bool
+		want        int32
+	}{
+		{
+			name:        "enough work",
+			headersWork: 100,
+			minWork:     100,
+			found:       true,
+			want:        10,
+		},
+		{
+			name:        "too little work",
+			headersWork: 99,
+			minWork:     100,
+			found:       true,
+			want:        -1,
+		},
+		{
+			name:        "no minimum chain work",
+			headersWork: 0,
+			noMinWork:   true,
+			found:       true,
+			want:        10,
+		},
+		{
+			name:        "header not downloaded",
+			headersWork: 100,
+			minWork:     100,
+			want:        -1,
+		},
+	}
+	for _, test := range tests {
+		setTestHeaders(sm, 20)
+		if test.found {
+			sm.assumeValidHeader = 10
+		}
+		sm.headersWork = new(big.Int).Add(genesisWork,
+			big.NewInt(test.headersWork))
+		sm.minChainWork = new(big.Int).Add(genesisWork,
+			big.NewInt(test.minWork))
+		if test.noMinWork {
+			sm.minChainWork = nil
+		}
+		sm.updateAssumeValid()
+		if sm.assumeValidHeight != test.want {
+			t.Errorf("%s: assumed valid height %d, want %d",
+				test.name, sm.assumeValidHeight, test.want)
+		}
+	}
+}
+
+// makeTestHeaders returns a chain of the passed number of simnet block headers
+// with the passed difficulty bits that build on the passed header.  The headers
+// are eleven minutes apart, so the difficulty stays at the proof of work limit
+// when it is retargeted.
+func makeTestHeaders(prev *wire.BlockHeader, n int,
+	bits uint32) []*wire.BlockHeader {
+
+	target := blockchain.CompactToBig(bits)
+	headers := make([]*wire.BlockHeader, 0, n)
+	for i := 0; i < n; i++ {
+		header := &wire.BlockHeader{
+			Version:   4,
+			PrevBlock: prev.BlockHash(),
+			Timestamp: prev.Timestamp.Add(11 * time.Minute),
+			Bits:      bits,
+		}
+		for {
+			hash := header.BlockHash()
+			if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+				break
+			}
+			header.Nonce++
+		}
+		headers = append(headers, header)
+		prev = header
+	}
+	return headers
+}
+
+// sendTestHeaders passes the headers to the sync manager in messages of the
+// maximum size as if they were received from the passed peer.  The getheaders
+// messages the sync manager sends in response are consumed, so the remote peer
+// doesn't block on them.
+func sendTestHeaders(t *testing.T, sm *SyncManager, tp *testPeer,
+	headers []*wire.BlockHeader) {
+
+	t.Helper()
+
+	for len(headers) > 0 {
+		n := len(headers)
+		if n > wire.MaxBlockHeadersPerMsg {
+			n = wire.MaxBlockHeadersPerMsg
+		}
+		msg := wire.NewMsgHeaders()
+		for _, header := range headers[:n] {
+			msg.AddBlockHeader(header)
+		}
+		sm.handleHeadersMsg(&headersMsg{headers: msg, peer: tp.peer})
+		headers = headers[n:]
+
+		requested := n == wire.MaxBlockHeadersPerMsg &&
+			sm.syncPeer == tp.peer && !sm.headersPaused &&
+			!sm.headersSynced
+		if !requested {
+			continue
+		}
+		select {
+		case <-tp.getHeaders:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("getheaders not sent to %s", tp.peer.Addr())
+		}
+	}
+}
+
+// TestHandleHeadersMsg ensures headers whose difficulty doesn't follow from the
+// previous headers are rejected and that the download of the headers is paused
+// while too many of them wait for their blocks.
+func TestHandleHeadersMsg(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	genesis := &params.GenesisBlock.Header
+
+	// A header with a higher difficulty than required meets its proof of
+	// work, but is rejected since the difficulty doesn't match.
+	sm := newTestSyncManager(t)
+	tp := newTestPeer(t, 1, 100)
+	addPeer(sm, tp)
+	sm.startSync()
+	if sm.syncPeer != tp.peer || !sm.headersFirstMode {
+		t.Fatalf("headers-first sync not started")
+	}
+	headers := makeTestHeaders(genesis, 10, params.PowLimitBits)
+	headers = append(headers, makeTestHeaders(headers[9], 1, 0x1f7fffff)...)
+	sendTestHeaders(t, sm, tp, headers)
+	waitDisconnect(t, tp)
+	if len(sm.headers) != 11 {
+		t.Fatalf("unexpected number of headers kept: got %d, want 11",
+			len(sm.headers))
+	}
+
+	// The download of the headers is paused once the maximum number of
+	// headers wait for their blocks, and the blocks are downloaded.
+	sm = newTestSyncManager(t)
+	tp = newTestPeer(t, 2, maxQueuedHeaders+1000)
+	addPeer(sm, tp)
+	sm.startSync()
+	<-tp.getHeaders
+	headers = makeTestHeaders(genesis, maxQueuedHeaders+1000,
+		params.PowLimitBits)
+	sendTestHeaders(t, sm, tp, headers[:maxQueuedHeaders])
+	if !sm.headersPaused || sm.headersSynced {
+		t.Fatalf("header download not paused")
+	}
+	if n := sm.queuedHeaders(); n != maxQueuedHeaders {
+		t.Fatalf("unexpected queued headers: got %d, want %d", n,
+			maxQueuedHeaders)
+	}
+	waitGetData(t, tp)
+
+	// Headers sent while the download is paused are ignored.
+	sendTestHeaders(t, sm, tp, headers[maxQueuedHeaders:])
+	if n := sm.queuedHeaders(); n != maxQueuedHeaders {
+		t.Fatalf("headers kept while paused: got %d, want %d", n,
+			maxQueuedHeaders)
+	}
+
+	// The download resumes from the last header once the blocks of half
+	// of the headers were processed.
+	sm.nextProcess += maxQueuedHeaders / 2
+	sm.resumeHeaders()
+	if sm.headersPaused {
+		t.Fatalf("header download not resumed")
+	}
+	select {
+	case msg := <-tp.getHeaders:
+		last := headers[maxQueuedHeaders-1].BlockHash()
+		if len(msg.BlockLocatorHashes) != 1 ||
+			*msg.BlockLocatorHashes[0] != last {
+
+			t.Fatalf("unexpected block locator %v",
+				msg.BlockLocatorHashes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("headers not requested after resuming")
+	}
+	sendTestHeaders(t, sm, tp, headers[maxQueuedHeaders:])
+	if !sm.headersSynced {
+		t.Fatalf("headers not synced after resuming")
+	}
+	if n := len(sm.headers) - 1; n != len(headers) {
+		t.Fatalf("unexpected number of headers: got %d, want %d", n,
+			len(headers))
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// presyncCommitmentPeriod is the number of headers between the headers
+	// a commitment is stored for while pre-syncing headers.  A peer which
+	// serves another chain when the headers are downloaded again is
+	// detected with a probability of one half per commitment.
+	presyncCommitmentPeriod = 584
+
+	// maxHeadersPerSecond bounds the number of headers of a chain for the
+	// time since the genesis block, which bounds the number of commitments
+	// stored while pre-syncing.  The median time rule prevents chains from
+	// having a higher rate of blocks on average.
+	maxHeadersPerSecond = 6
+
+	// maxFutureHeaderTime is the time the timestamps of headers may be
+	// ahead of the current time.
+	maxFutureHeaderTime = 2 * time.Hour
+
+	// presyncTipBuffer is the number of blocks at the tip of the main chain
+	// whose work doesn't count toward the work a chain of headers needs to
+	// be kept without pre-syncing it, so the headers of competing chains
+	// close to the tip are kept once the chain is synced.
+	presyncTipBuffer = 144
+)
+
+// headersPresync tracks a download of the headers of the sync peer in two
+// phases, which is used when the headers start from a block whose chain has
+// less work than the anti-DoS threshold.  In the first phase the headers are
+// checked and their work is accumulated without storing them, apart from a
+// salted one bit commitment to every presyncCommitmentPeriod-th header.  Once
+// the headers reach the threshold, they are downloaded again, verified against
+// the commitments and kept.  This way peers can't exhaust the memory with long
+// chains of low work headers, and a peer can't switch to another chain with
+// less work in between without being detected.
+type headersPresync struct {
+	// start is the block the headers are downloaded from, which is in the
+	// main chain, and startWork is the cumulative work of its chain.
+	start     headerNode
+	startWork *big.Int
+
+	// threshold is the cumulative work the headers must reach.
+	threshold *big.Int
+
+	// salt and offset make the commitments and the headers they are stored
+	// for unpredictable to the peer.
+	salt   [16]byte
+	offset int32
+
+	// work and last are the cumulative work of the headers and the last
+	// header received in the current phase.
+	work *big.Int
+	last headerNode
+
+	// commitments holds a bit for each of the numCommitments commitments,
+	// which may not exceed maxCommitments.  nextCommitment is the index of
+	// the commitment the next header with a commitment is verified against
+	// once the headers are downloaded again.
+	commitments    []byte
+	numCommitments int
+	maxCommitments int
+	nextCommitment int
+	redownload     bool
+}
+
+// newHeadersPresync returns a pre-sync of the headers starting from the passed
+// block, whose chain has the passed cumulative work, until they reach the
+// passed threshold.  The chain may have at most maxHeaders headers.
+func newHeadersPresync(start headerNode, startWork, threshold *big.Int,
+	maxHeaders int64) (*headersPresync, error) {
+
+	p := &headersPresync{
+		start:          start,
+		startWork:      startWork,
+		threshold:      threshold,
+		work:           new(big.Int).Set(startWork),
+		last:           start,
+		maxCommitments: int(maxHeaders/presyncCommitmentPeriod) + 1,
+	}
+	if _, err := rand.Read(p.salt[:]); err != nil {
+		return nil, err
+	}
+	p.offset = int32(binary.LittleEndian.Uint32(p.salt[:]) %
+		presyncCommitmentPeriod)
+	return p, nil
+}
+
+// commitment returns the salted one bit commitment to the passed header hash.
+func (p *headersPresync) commitment(hash *chainhash.Hash) byte {
+	var buf [len(p.salt) + chainhash.HashSize]byte
+	copy(buf[:], p.salt[:])
+	copy(buf[len(p.salt):], hash[:])
+	return chainhash.HashB(buf[:])[0] & 1
+}
+
+// addHeader adds the next header of the current phase, which must connect to
+// the last one.  A commitment to the header is stored or verified depending on
+// the phase when its height is one commitments are made for.
+func (p *headersPresync) addHeader(node headerNode, bits uint32) error {
+	p.work.Add(p.work, blockchain.CalcWork(bits))
+	p.last = node
+	if node.height%presyncCommitmentPeriod != p.offset {
+		return nil
+	}
+
+	if !p.redownload {
+		if p.numCommitments >= p.maxCommitments {
+			return errors.New("the chain has more headers than " +
+				"possible for the time since the genesis block")
+		}
+		if p.numCommitments%8 == 0 {
+			p.commitments = append(p.commitments, 0)
+		}
+		p.commitments[p.numCommitments/8] |=
+			p.commitment(&node.hash) << (p.numCommitments % 8)
+		p.numCommitments++
+		return nil
+	}
+
+	// The headers after the ones that were pre-synced have no commitments.
+	i := p.nextCommitment
+	if i >= p.numCommitments {
+		return nil
+	}
+	if p.commitment(&node.hash) != p.commitments[i/8]>>(i%8)&1 {
+		return fmt.Errorf("header %v at height %d does not match the "+
+			"pre-synced headers", node.hash, node.height)
+	}
+	p.nextCommitment++
+	return nil
+}
+
+// reachedThreshold returns whether the headers of the current phase reached
+// the threshold.
+func (p *headersPresync) reachedThreshold() bool {
+	return p.work.Cmp(p.threshold) >= 0
+}
+
+// startRedownload switches to the phase where the headers are downloaded again
+// from the start block and kept.
+func (p *headersPresync) startRedownload() {
+	p.redownload = true
+	p.work.Set(p.startWork)
+	p.last = p.start
+}
+
+// newPresync returns a pre-sync of the headers starting from the passed block
+// when its chain has less work than the anti-DoS threshold, which is the larger
+// of the minimum chain work and the work of the main chain without its most
+// recent presyncTipBuffer blocks.  It returns nil when the headers can be kept
+// right away.
+func (sm *SyncManager) newPresync(start *headerNode) (*headersPresync, error) {
+	startWork, err := sm.chain.ChainWork(&start.hash)
+	if err != nil {
+		return nil, err
+	}
+	best := sm.chain.BestSnapshot()
+	threshold, err := sm.chain.ChainWork(&best.Hash)
+	if err != nil {
+		return nil, err
+	}
+	tipWork := new(big.Int).Mul(blockchain.CalcWork(best.Bits),
+		big.NewInt(presyncTipBuffer))
+	threshold.Sub(threshold, tipWork)
+	if sm.minChainWork != nil && sm.minChainWork.Cmp(threshold) > 0 {
+		threshold.Set(sm.minChainWork)
+	}
+	if startWork.Cmp(threshold) >= 0 {
+		return nil, nil
+	}
+
+	genesisTime := sm.chainParams.GenesisBlock.Header.Timestamp
+	maxTime := sm.timeSource.AdjustedTime().Add(maxFutureHeaderTime)
+	maxHeaders := int64(maxTime.Sub(genesisTime)/time.Second) *
+		maxHeadersPerSecond
+	return newHeadersPresync(*start, startWork, threshold, maxHeaders)
+}
+
+// requestHeaders requests the headers after the passed block from the peer.
+// The request is queued directly since the peer filters getheaders requests
+// matching the previous one, which happens when the headers are downloaded
+// again after pre-syncing them.
+func requestHeaders(peer *peerpkg.Peer, hash *chainhash.Hash) {
+	msg := wire.NewMsgGetHeaders()
+	if err := msg.AddBlockLocatorHash(hash); err != nil {
+		log.Warnf("Failed to create getheaders message: %v", err)
+		return
+	}
+	peer.QueueMessage(msg, nil)
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	peerpkg "github.com/btcsuite/btcd/peer"
+)
+
+const (
+	// minBlocksInFlightPerPeer is the minimum number of blocks that are
+	// requested from a single peer at the same time in headers-first mode
+	// regardless of how slow the peer is.
+	minBlocksInFlightPerPeer = 4
+
+	// baseBlocksInFlightPerPeer is the number of blocks that are requested
+	// from a peer with an average score at the same time in headers-first
+	// mode.  Faster peers are allowed proportionally more requests up to
+	// maxBlocksInFlightPerPeer.
+	baseBlocksInFlightPerPeer = 16
+
+	// downloadStatsDecay is the weight of a new sample in the moving
+	// averages of the latency and throughput of a peer.
+	downloadStatsDecay = 0.2
+)
+
+// PeerDownloadStats describes the blocks downloaded from a peer in
+// headers-first mode.
+type PeerDownloadStats struct {
+	// BlocksInFlight is the number of blocks currently requested from the
+	// peer.
+	BlocksInFlight int
+
+	// MaxBlocksInFlight is the number of blocks that may be requested from
+	// the peer at the same time based on its score.
+	MaxBlocksInFlight int
+
+	// BlocksDownloaded and BytesDownloaded are the number of blocks and
+	// the total size of the blocks downloaded from the peer.
+	BlocksDownloaded uint64
+	BytesDownloaded  uint64
+
+	// Stalls is the number of block requests made to the peer that
+	// stalled.
+	Stalls int
+
+	// Latency is the moving average of the time between requesting a
+	// block and receiving it.
+	Latency time.Duration
+
+	// Throughput is the moving average of the rate the peer delivers
+	// blocks at in bytes per second.
+	Throughput float64
+
+	// Score is the score the peer is prioritized by when assigning blocks
+	// to download.  Faster peers have higher scores.
+	Score float64
+}
+
+// downloadStats houses the statistics the block downloads in headers-first
+// mode are scheduled by for a peer.
+type downloadStats struct {
+	blocks       uint64
+	bytes        uint64
+	latency      time.Duration
+	throughput   float64
+	lastDelivery time.Time
+}
+
+// recordDownload updates the statistics with a block of the passed size that
+// was requested at the passed time and received now.
+func (s *downloadStats) recordDownload(size int, requested, now time.Time) {
+	latency := now.Sub(requested)
+
+	// Blocks are delivered one after another over the connection to the
+	// peer, so the throughput is based on the time since the previous
+	// delivery when requests were already outstanding at the time.
+	start := requested
+	if s.lastDelivery.After(start) {
+		start = s.lastDelivery
+	}
+	elapsed := now.Sub(start).Seconds()
+	if elapsed < 0.001 {
+		elapsed = 0.001
+	}
+	throughput := float64(size) / elapsed
+
+	if s.blocks == 0 {
+		s.latency = latency
+		s.throughput = throughput
+	} else {
+		s.latency += time.Duration(downloadStatsDecay *
+			float64(latency-s.latency))
+		s.throughput += downloadStatsDecay * (throughput - s.throughput)
+	}
+	s.blocks++
+	s.bytes += uint64(size)
+	s.lastDelivery = now
+}
+
+// score returns the score of a peer with the passed statistics and number of
+// stalls.  Peers without any downloads yet are given the passed default score
+// so they are tried.
+func (s *downloadStats) score(stalls int, defaultScore float64) float64 {
+	if s.blocks == 0 {
+		return defaultScore / float64(1+stalls)
+	}
+	return s.throughput / float64(1+stalls)
+}
+
+// averageScore returns the average score of the sync candidates that blocks
+// were downloaded from.  It returns 1 when there are none, which makes all
+// peers score equally.
+func (sm *SyncManager) averageScore() float64 {
+	var total float64
+	var count int
+	for _, state := range sm.peerStates {
+		if !state.syncCandidate || state.download.blocks == 0 {
+			continue
+		}
+		total += state.download.score(state.stalls, 0)
+		count++
+	}
+	if count == 0 || total == 0 {
+		return 1
+	}
+	return total / float64(count)
+}
+
+// maxBlocksInFlight returns the number of blocks that may be requested from a
+// peer with the passed score at the same time given the average score of all
+// peers.  It is proportional to how much faster or slower the peer is than the
+// average.
+func maxBlocksInFlight(score, avgScore float64) int {
+	n := int(math.Round(baseBlocksInFlightPerPeer * score / avgScore))
+	if n < minBlocksInFlightPerPeer {
+		return minBlocksInFlightPerPeer
+	}
+	if n > maxBlocksInFlightPerPeer {
+		return maxBlocksInFlightPerPeer
+	}
+	return n
+}
+
+// downloadPeer describes a peer blocks can be requested from by
+// fetchHeaderBlocks.
+type downloadPeer struct {
+	peer     *peerpkg.Peer
+	state    *peerSyncState
+	score    float64
+	capacity int
+}
+
+// downloadPeers returns the sync candidates more blocks should be requested
+// from in headers-first mode ordered by their score, highest first, so the
+// blocks that are needed first are assigned to the fastest peers while slower
+// peers are assigned the blocks further ahead.  Only peers that completed at
+// least half of their requests are returned so the requests are batched.
+func (sm *SyncManager) downloadPeers() []*downloadPeer {
+	avgScore := sm.averageScore()
+
+	var peers []*downloadPeer
+	for peer, state := range sm.peerStates {
+		if !state.syncCandidate || !peer.Connected() {
+			continue
+		}
+		score := state.download.score(state.stalls, avgScore)
+		maxInFlight := maxBlocksInFlight(score, avgScore)
+		capacity := maxInFlight - len(state.requestedBlocks)
+		if capacity <= 0 || capacity < (maxInFlight+1)/2 {
+			continue
+		}
+		peers = append(peers, &downloadPeer{
+			peer:     peer,
+			state:    state,
+			score:    score,
+			capacity: capacity,
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].score > peers[j].score
+	})
+	return peers
+}
+
+// handleDownloadStatsMsg returns the download statistics of all peers blocks
+// were downloaded from or are requested from, keyed by peer ID.
+func (sm *SyncManager) handleDownloadStatsMsg() map[int32]*PeerDownloadStats {
+	avgScore := sm.averageScore()
+	stats := make(map[int32]*PeerDownloadStats)
+	for peer, state := range sm.peerStates {
+		if state.download.blocks == 0 && len(state.requestedBlocks) == 0 {
+			continue
+		}
+		score := state.download.score(state.stalls, avgScore)
+		stats[peer.ID()] = &PeerDownloadStats{
+			BlocksInFlight:    len(state.requestedBlocks),
+			MaxBlocksInFlight: maxBlocksInFlight(score, avgScore),
+			BlocksDownloaded:  state.download.blocks,
+			BytesDownloaded:   state.download.bytes,
+			Stalls:            state.stalls,
+			Latency:           state.download.latency,
+			Throughput:        state.download.throughput,
+			Score:             score,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ErrNotResponding is returned by State when the block handler doesn't reply
+// in time, which means it is busy with, or stuck in, the activity reported by
+// Activity.
+var ErrNotResponding = errors.New("sync manager is not responding")
+
+// BlockRequestState describes a block requested from a peer in headers-first
+// mode.
+type BlockRequestState struct {
+	Hash      chainhash.Hash
+	Height    int32
+	Peer      int32
+	Requested time.Time
+}
+
+// PeerSyncState describes the state the sync manager tracks for a peer.
+type PeerSyncState struct {
+	ID            int32
+	Addr          string
+	SyncCandidate bool
+
+	// RequestQueue is the number of inventory vectors announced by the
+	// peer which are not requested yet.
+	RequestQueue int
+
+	// RequestedBlocks and RequestedTxns are the number of blocks and
+	// transactions requested from the peer which haven't arrived yet.
+	RequestedBlocks int
+	RequestedTxns   int
+
+	// Stalls is the number of block requests made to the peer that
+	// stalled.
+	Stalls int
+}
+
+// SyncState describes the state of the sync manager at a point in time.  It is
+// meant for diagnosing stalled syncs.
+type SyncState struct {
+	// SyncPeer is the ID of the sync peer, or zero when there is none.
+	SyncPeer int32
+
+	// HeadersFirst and HeadersSynced are whether the manager is in
+	// headers-first mode and whether the headers were downloaded up to
+	// the tip of the sync peer.
+	HeadersFirst  bool
+	HeadersSynced bool
+
+	// Headers is the number of headers downloaded in headers-first mode
+	// and NextProcessHeight the height of the next block to process from
+	// them.
+	Headers           int
+	NextProcessHeight int32
+
+	// RequestedBlocks and RequestedTxns are the number of blocks and
+	// transactions requested from all peers which haven't arrived yet.
+	RequestedBlocks int
+	RequestedTxns   int
+
+	// BlockRequests are the outstanding block requests made in
+	// headers-first mode ordered by height.
+	BlockRequests []BlockRequestState
+
+	// DownloadedBlocks is the number of downloaded blocks waiting for
+	// their parents to be processed and DownloadedBytes their total size,
+	// StalledBlocks the number of blocks whose request stalled and
+	// FailedBlocks the number of blocks which failed to download or
+	// process and are retried.
+	DownloadedBlocks int
+	DownloadedBytes  int
+	StalledBlocks    int
+	FailedBlocks     int
+
+	// LastProgress is the time the chain or the headers last advanced.
+	LastProgress time.Time
+
+	// Peers are the peers known to the manager ordered by ID.
+	Peers []PeerSyncState
+}
+
+// Activity describes what the block handler is doing.
+type Activity struct {
+	// Task describes the message or timer being handled.  It is empty when
+	// the handler is waiting for work.
+	Task string
+
+	// Since is the time the handler started the task, or started waiting
+	// when it is idle.
+	Since time.Time
+}
+
+// getSyncStateMsg is a message type to be sent across the message channel for
+// retrieving the state of the sync manager.
+type getSyncStateMsg struct {
+	reply chan *SyncState
+}
+
+// activityTracker records what the block handler is doing so it can be
+// reported while the handler is stuck.
+type activityTracker struct {
+	mtx   sync.Mutex
+	task  interface{}
+	since time.Time
+}
+
+// set records that the block handler started handling the passed message or
+// timer, or started waiting when it is nil.
+func (a *activityTracker) set(task interface{}) {
+	a.mtx.Lock()
+	a.task = task
+	a.since = time.Now()
+	a.mtx.Unlock()
+}
+
+// get returns the activity of the block handler.
+func (a *activityTracker) get() Activity {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	activity := Activity{Since: a.since}
+	if a.task != nil {
+		activity.Task = describeTask(a.task)
+	}
+	return activity
+}
+
+// describeTask returns a description of the passed message or timer handled
+// by the block handler.
+func describeTask(task interface{}) string {
+	switch t := task.(type) {
+	case string:
+		return t
+	case *newPeerMsg:
+		return fmt.Sprintf("new peer %s", t.peer)
+	case *donePeerMsg:
+		return fmt.Sprintf("done peer %s", t.peer)
+	case *blockMsg:
+		return fmt.Sprintf("block %s from %s", t.block.Hash(), t.peer)
+	case *txMsg:
+		return fmt.Sprintf("tx %s from %s", t.tx.Hash(), t.peer)
+	case *invMsg:
+		return fmt.Sprintf("inv with %d entries from %s",
+			len(t.inv.InvList), t.peer)
+	case *headersMsg:
+		return fmt.Sprintf("%d headers from %s",
+			len(t.headers.Headers), t.peer)
+	case *notFoundMsg:
+		return fmt.Sprintf("notfound with %d entries from %s",
+			len(t.notFound.InvList), t.peer)
+	case processBlockMsg:
+		return fmt.Sprintf("process block %s", t.block.Hash())
+	case fetchBlockMsg:
+		return fmt.Sprintf("fetch block %s", t.hash)
+	case requestBlockMsg:
+		return fmt.Sprintf("request block %s from peer %d", t.hash,
+			t.peerID)
+	case pauseMsg:
+		return "paused"
+	}
+	return fmt.Sprintf("%T", task)
+}
+
+// handleSyncStateMsg returns the state of the sync manager.
+func (sm *SyncManager) handleSyncStateMsg() *SyncState {
+	state := &SyncState{
+		HeadersFirst:     sm.headersFirstMode,
+		HeadersSynced:    sm.headersSynced,
+		Headers:          len(sm.headers),
+		RequestedBlocks:  len(sm.requestedBlocks),
+		RequestedTxns:    len(sm.requestedTxns),
+		BlockRequests:    make([]BlockRequestState, 0, len(sm.blockRequests)),
+		DownloadedBlocks: len(sm.downloadedBlocks),
+		DownloadedBytes:  sm.downloadedBytes,
+		StalledBlocks:    len(sm.stalledBlocks),
+		FailedBlocks:     len(sm.failedBlocks),
+		LastProgress:     sm.lastProgressTime,
+		Peers:            make([]PeerSyncState, 0, len(sm.peerStates)),
+	}
+	if sm.syncPeer != nil {
+		state.SyncPeer = sm.syncPeer.ID()
+	}
+	if sm.nextProcess < len(sm.headers) {
+		state.NextProcessHeight = sm.headers[sm.nextProcess].height
+	}
+
+	for hash, req := range sm.blockRequests {
+		state.BlockRequests = append(state.BlockRequests,
+			BlockRequestState{
+				Hash:      hash,
+				Height:    req.height,
+				Peer:      req.peer.ID(),
+				Requested: req.requested,
+			})
+	}
+	sort.Slice(state.BlockRequests, func(i, j int) bool {
+		return state.BlockRequests[i].Height <
+			state.BlockRequests[j].Height
+	})
+
+	for peer, peerState := range sm.peerStates {
+		state.Peers = append(state.Peers, PeerSyncState{
+			ID:              peer.ID(),
+			Addr:            peer.Addr(),
+			SyncCandidate:   peerState.syncCandidate,
+			RequestQueue:    len(peerState.requestQueue),
+			RequestedBlocks: len(peerState.requestedBlocks),
+			RequestedTxns:   len(peerState.requestedTxns),
+			Stalls:          peerState.stalls,
+		})
+	}
+	sort.Slice(state.Peers, func(i, j int) bool {
+		return state.Peers[i].ID < state.Peers[j].ID
+	})
+
+	return state
+}
+
+// State returns the state of the sync manager.  ErrNotResponding is returned
+// when the block handler doesn't reply within the passed timeout, so callers
+// diagnosing a stuck node don't get stuck themselves.  Activity reports what
+// the handler is doing in that case.
+func (sm *SyncManager) State(timeout time.Duration) (*SyncState, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	reply := make(chan *SyncState, 1)
+	select {
+	case sm.msgChan <- getSyncStateMsg{reply: reply}:
+	case <-timer.C:
+		return nil, ErrNotResponding
+	case <-sm.quit:
+		return nil, ErrNotResponding
+	}
+
+	select {
+	case state := <-reply:
+		return state, nil
+	case <-timer.C:
+		return nil, ErrNotResponding
+	case <-sm.quit:
+		return nil, ErrNotResponding
+	}
+}
+
+// Activity returns what the block handler is doing.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) Activity() Activity {
+	return sm.activity.get()
+}
@@ -5,6 +5,10 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -79,3 +83,46 @@ func netName(chainParams *params) string {
 		return chainParams.Name
 	}
 }
+
+// loadCustomNetParams loads the custom network parameters defined by the passed
+// JSON file and registers them.  In addition to the fields supported by
+// chaincfg.LoadParams, the file can define the "rpcport" of the network, which
+// defaults to the one of the base network.
+func loadCustomNetParams(path string) (*params, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	chainParams, err := chaincfg.LoadParams(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Base    string `json:"base"`
+		RPCPort string `json:"rpcport"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Base == "" {
+		file.Base = regressionNetParams.Name
+	}
+	if file.RPCPort == "" {
+		for _, base := range []*params{
+			&mainNetParams, &testNet3Params, &regressionNetParams,
+			&simNetParams, &sigNetParams,
+		} {
+			if base.Name == file.Base {
+				file.RPCPort = base.rpcPort
+			}
+		}
+	}
+
+	if err := chaincfg.Register(chainParams); err != nil {
+		return nil, err
+	}
+
+	return &params{Params: chainParams, rpcPort: file.RPCPort}, nil
+}
@@ -35,6 +35,8 @@ A quick overview of the major features peer provides are as follows:
   - Inventory message batching and send trickling with known inventory detection
     and avoidance
   - Automatic periodic keep-alive pinging and pong responses
+  - Optional encrypted BIP0324 v2 transport with detection of inbound v1
+    peers
   - Random nonce generation and self connection detection
   - Proper handling of bloom filter related commands when the caller does not
     specify the related flag to signal support
@@ -134,6 +136,15 @@ A snapshot of the current peer statistics can be obtained with the StatsSnapshot
 function.  This includes statistics such as the total number of bytes read and
 written, the remote address, user agent, and negotiated protocol version.
 
+# Encrypted Transport
+
+When the V2Transport field of the Config is set, connections use the encrypted
+v2 transport protocol defined by BIP0324.  Inbound peers that send a v1 version
+message are still accepted with the v1 protocol.  Outbound peers that close the
+connection without completing the v2 handshake are reported by V2Rejected so
+the caller can reconnect without the v2 protocol.  Nodes supporting the v2
+protocol signal the wire.SFNodeP2PV2 service flag.
+
 # Logging
 
 This package provides extensive logging capabilities through the UseLogger
@@ -203,6 +203,23 @@ type MessageListeners struct {
 	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 message.
 	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
 
+	// OnAttest is invoked when a peer receives an attest message.
+	OnAttest func(p *Peer, msg *wire.MsgAttest)
+
+	// OnReqRecon is invoked when a peer receives a reqrecon message.
+	OnReqRecon func(p *Peer, msg *wire.MsgReqRecon)
+
+	// OnSketch is invoked when a peer receives a sketch message.
+	OnSketch func(p *Peer, msg *wire.MsgSketch)
+
+	// OnReqSketchExt is invoked when a peer receives a reqsketchext
+	// message.
+	OnReqSketchExt func(p *Peer, msg *wire.MsgReqSketchExt)
+
+	// OnReconcilDiff is invoked when a peer receives a reconcildiff
+	// message.
+	OnReconcilDiff func(p *Peer, msg *wire.MsgReconcilDiff)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -219,6 +236,24 @@ type MessageListeners struct {
 	OnWrite func(p *Peer, bytesWritten int, msg wire.Message, err error)
 }
 
+// Authenticator is the interface used by a peer to authenticate the local node
+// to the remote peer and the remote peer to the local node during the version
+// handshake on permissioned networks.
+type Authenticator interface {
+	// AuthMsg returns the peerauth message proving the identity of the
+	// local node to the remote peer of the v2 transport session with the
+	// passed id.  Initiator is whether the local node initiated the
+	// connection.
+	AuthMsg(sessionID []byte, initiator bool) (*wire.MsgPeerAuth, error)
+
+	// VerifyAuthMsg returns an error if the passed peerauth message does
+	// not prove the identity of the remote peer of the v2 transport
+	// session with the passed id.  Initiator is whether the remote peer
+	// initiated the connection.
+	VerifyAuthMsg(msg *wire.MsgPeerAuth, sessionID []byte,
+		initiator bool) error
+}
+
 // Config is the struct to hold configuration options useful to Peer.
 type Config struct {
 	// NewestBlock specifies a callback which provides the newest block
@@ -289,6 +324,35 @@ type Config struct {
 	// scenarios where the stall behavior isn't important to the system
 	// under test.
 	DisableStallHandler bool
+
+	// Authenticator, when set, authenticates the local node to the remote
+	// peer and requires the remote peer to authenticate itself with a
+	// peerauth message during the version handshake.  Peers that fail to
+	// do so are disconnected.  The proofs are bound to the session id of
+	// the v2 transport, so it requires V2Transport and the handshake fails
+	// for peers using the v1 transport.
+	Authenticator Authenticator
+
+	// TxReconciliation specifies whether to offer transaction relay by set
+	// reconciliation (BIP0330) to the remote peer with a sendtxrcncl
+	// message during the version handshake.  It is not offered when
+	// DisableRelayTx is set.  See TxReconSalts.
+	TxReconciliation bool
+
+	// V2Transport specifies whether to use the encrypted v2 transport
+	// protocol defined by BIP0324.  Inbound peers may then use either the
+	// v1 or the v2 protocol, which is detected from the first bytes they
+	// send, while outbound connections attempt the v2 protocol.  Callers
+	// should retry outbound connections without it when V2Rejected reports
+	// the remote peer only supports the v1 protocol.
+	V2Transport bool
+
+	// Compression specifies whether to offer the compression of block and
+	// headers messages to the remote peer with a sendcmpr message during
+	// the version handshake.  The messages are compressed in both
+	// directions when both peers offer it, which saves bandwidth on slow
+	// links at the expense of CPU time.  See CompressionCodec.
+	Compression bool
 }
 
 // minUint32 is a helper function to return the minimum of two uint32s.
@@ -393,6 +457,40 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	V2Transport    bool
+	V2SessionID    []byte
+
+	// Compression is the codec block and headers messages are compressed
+	// with, or zero when compression was not negotiated.
+	Compression wire.CompressionCodec
+
+	// MinPingMicros is the shortest time a ping took to return in
+	// microseconds, or zero when no ping returned yet.
+	MinPingMicros int64
+
+	// MsgStats are the counts of the messages exchanged with the peer
+	// keyed by their command.  Messages which could not be read are
+	// counted under OtherCommand.
+	MsgStats map[string]MessageStats
+
+	// AddrsSent and AddrsRecv are the number of addresses sent to and
+	// received from the peer in addr and addrv2 messages.
+	AddrsSent uint64
+	AddrsRecv uint64
+}
+
+// OtherCommand is the command the messages which could not be read, such as
+// messages with unknown commands, are counted under in the message statistics
+// of peers.
+const OtherCommand = "*other*"
+
+// MessageStats houses the number of messages of a command exchanged with a peer
+// and the bytes they took on the wire including the message headers.
+type MessageStats struct {
+	MsgsSent  uint64
+	BytesSent uint64
+	MsgsRecv  uint64
+	BytesRecv uint64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -462,7 +560,25 @@ type Peer struct {
 	verAckReceived       bool
 	witnessEnabled       bool
 	sendAddrV2           bool
-
+	localNonce           uint64 // nonce of our version message
+	remoteNonce          uint64 // nonce of the remote version message
+	authPubKey           []byte // key the remote peer authenticated with
+	txReconSent          bool   // we sent a sendtxrcncl message
+	txReconReceived      bool   // peer sent a sendtxrcncl message
+	txReconLocalSalt     uint64 // salt of our sendtxrcncl message
+	txReconRemoteSalt    uint64 // salt of the remote sendtxrcncl message
+	v2SessionID          []byte // session id of the v2 transport
+	v2Rejected           bool   // peer rejected the v2 transport
+	cmprSent             bool   // we sent a sendcmpr message
+	cmprReceived         bool   // peer sent a sendcmpr message
+
+	// cmprCodec is the codec used to compress messages exchanged with the
+	// peer.  It is zero when the peer listed no supported codec.
+	cmprCodec wire.CompressionCodec
+
+	// transport is the transport protocol of the connection.  It is set
+	// before the version handshake and never modified afterwards.
+	transport    transport
 	wireEncoding wire.MessageEncoding
 
 	knownInventory     lru.Cache
@@ -484,6 +600,10 @@ type Peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	minPingMicros      int64     // Shortest time for a ping to return.
+	msgStats           map[string]*MessageStats
+	addrsSent          uint64
+	addrsRecv          uint64
 
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
@@ -539,6 +659,15 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// IsKnownInventory returns whether the passed inventory is in the cache of
+// known inventory for the peer, which means the peer announced it or it was
+// announced to the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) IsKnownInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Contains(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -551,7 +680,12 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	userAgent := p.userAgent
 	services := p.services
 	protocolVersion := p.advertisedProtoVer
+	sessionID := p.v2SessionID
 	p.flagsMtx.Unlock()
+	codec, ok := p.CompressionCodec()
+	if !ok {
+		codec = 0
+	}
 
 	// Get a copy of all relevant flags and stats.
 	statsSnap := &StatsSnap{
@@ -572,6 +706,16 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		V2Transport:    sessionID != nil,
+		V2SessionID:    sessionID,
+		Compression:    codec,
+		MinPingMicros:  p.minPingMicros,
+		MsgStats:       make(map[string]MessageStats, len(p.msgStats)),
+		AddrsSent:      p.addrsSent,
+		AddrsRecv:      p.addrsRecv,
+	}
+	for command, stats := range p.msgStats {
+		statsSnap.MsgStats[command] = *stats
 	}
 
 	p.statsMtx.RUnlock()
@@ -609,6 +753,12 @@ func (p *Peer) Addr() string {
 	return p.addr
 }
 
+// LogField returns the address of the peer as the peer field of the structured
+// log records of the messages the peer is logged in.
+func (p *Peer) LogField() (string, string) {
+	return "peer", p.addr
+}
+
 // Inbound returns whether the peer is inbound.
 //
 // This function is safe for concurrent access.
@@ -682,6 +832,18 @@ func (p *Peer) LastPingMicros() int64 {
 	return lastPingMicros
 }
 
+// MinPingMicros returns the shortest time a ping to the remote peer took to
+// return in microseconds, or zero when no ping returned yet.
+//
+// This function is safe for concurrent access.
+func (p *Peer) MinPingMicros() int64 {
+	p.statsMtx.RLock()
+	minPingMicros := p.minPingMicros
+	p.statsMtx.RUnlock()
+
+	return minPingMicros
+}
+
 // VersionKnown returns the whether or not the version of a peer is known
 // locally.
 //
@@ -837,6 +999,82 @@ func (p *Peer) WantsAddrV2() bool {
 	return wantsAddrV2
 }
 
+// AuthPubKey returns the serialized public key the remote peer authenticated
+// itself with during the version handshake.  It is nil when the peer is not
+// configured with an Authenticator.
+//
+// This function is safe for concurrent access.
+func (p *Peer) AuthPubKey() []byte {
+	p.flagsMtx.Lock()
+	pubKey := p.authPubKey
+	p.flagsMtx.Unlock()
+
+	return pubKey
+}
+
+// V2Transport returns whether the connection uses the encrypted v2 transport
+// protocol defined by BIP0324.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2Transport() bool {
+	return p.V2SessionID() != nil
+}
+
+// V2SessionID returns the session id of the encrypted v2 transport protocol,
+// which is the same for both sides of the connection and can be compared out
+// of band to detect man-in-the-middle attacks.  It is nil for connections
+// using the v1 transport protocol.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2SessionID() []byte {
+	p.flagsMtx.Lock()
+	sessionID := p.v2SessionID
+	p.flagsMtx.Unlock()
+
+	return sessionID
+}
+
+// V2Rejected returns whether the remote peer of an outbound connection closed
+// it in response to the attempt to establish the encrypted v2 transport
+// protocol, which indicates it only supports the v1 transport protocol.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2Rejected() bool {
+	p.flagsMtx.Lock()
+	rejected := p.v2Rejected
+	p.flagsMtx.Unlock()
+
+	return rejected
+}
+
+// TxReconSalts returns the salts of the sendtxrcncl messages the local node and
+// the remote peer exchanged during the version handshake.  The returned flag
+// is false unless both sent one, which means transaction relay by set
+// reconciliation (BIP0330) was negotiated.
+//
+// This function is safe for concurrent access.
+func (p *Peer) TxReconSalts() (uint64, uint64, bool) {
+	p.flagsMtx.Lock()
+	defer p.flagsMtx.Unlock()
+
+	ok := p.txReconSent && p.txReconReceived
+	return p.txReconLocalSalt, p.txReconRemoteSalt, ok
+}
+
+// CompressionCodec returns the codec block and headers messages exchanged with
+// the remote peer are compressed with.  The returned flag is false unless both
+// the local node and the remote peer sent a sendcmpr message listing a common
+// codec during the version handshake, which means compression was negotiated.
+//
+// This function is safe for concurrent access.
+func (p *Peer) CompressionCodec() (wire.CompressionCodec, bool) {
+	p.flagsMtx.Lock()
+	defer p.flagsMtx.Unlock()
+
+	ok := p.cmprSent && p.cmprReceived && p.cmprCodec != 0
+	return p.cmprCodec, ok
+}
+
 // PushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
@@ -1059,17 +1297,69 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 		if p.lastPingNonce != 0 && msg.Nonce == p.lastPingNonce {
 			p.lastPingMicros = time.Since(p.lastPingTime).Nanoseconds()
 			p.lastPingMicros /= 1000 // convert to usec.
+			if p.minPingMicros == 0 ||
+				p.lastPingMicros < p.minPingMicros {
+
+				p.minPingMicros = p.lastPingMicros
+			}
 			p.lastPingNonce = 0
 		}
 		p.statsMtx.Unlock()
 	}
 }
 
+// recordMessage updates the message statistics of the peer with a message that
+// was sent or received taking the passed number of bytes on the wire.  The
+// message is nil when it could not be read.
+func (p *Peer) recordMessage(msg wire.Message, n int, sent bool) {
+	if n == 0 {
+		return
+	}
+	command := OtherCommand
+	var numAddrs uint64
+	switch m := msg.(type) {
+	case nil:
+	case *wire.MsgAddr:
+		command = m.Command()
+		numAddrs = uint64(len(m.AddrList))
+	case *wire.MsgAddrV2:
+		command = m.Command()
+		numAddrs = uint64(len(m.AddrList))
+	default:
+		command = m.Command()
+	}
+
+	p.statsMtx.Lock()
+	stats, ok := p.msgStats[command]
+	if !ok {
+		stats = new(MessageStats)
+		p.msgStats[command] = stats
+	}
+	if sent {
+		stats.MsgsSent++
+		stats.BytesSent += uint64(n)
+		p.addrsSent += numAddrs
+	} else {
+		stats.MsgsRecv++
+		stats.BytesRecv += uint64(n)
+		p.addrsRecv += numAddrs
+	}
+	p.statsMtx.Unlock()
+}
+
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	n, msg, buf, err := p.transport.readMessage(p.ProtocolVersion(),
+		encoding)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	if cmsg, ok := msg.(*wire.MsgCompressed); ok && err == nil {
+		msg, buf, err = p.decompressMessage(cmsg, encoding)
+	}
+	if err != nil {
+		p.recordMessage(nil, n, false)
+	} else {
+		p.recordMessage(msg, n, false)
+	}
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1098,6 +1388,19 @@ func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte,
 	return msg, buf, nil
 }
 
+// decompressMessage returns the message carried by the passed cmpmsg message
+// along with its serialized payload.  Compressed messages are only accepted
+// from peers compression was negotiated with.
+func (p *Peer) decompressMessage(msg *wire.MsgCompressed,
+	enc wire.MessageEncoding) (wire.Message, []byte, error) {
+
+	if _, ok := p.CompressionCodec(); !ok {
+		return nil, nil, errors.New("received cmpmsg message without " +
+			"negotiating compression")
+	}
+	return msg.Decompress(p.ProtocolVersion(), enc)
+}
+
 // writeMessage sends a bitcoin message to the peer with logging.
 func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	// Don't do anything if we're disconnecting.
@@ -1129,10 +1432,24 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 		return spew.Sdump(buf.Bytes())
 	}))
 
+	// Compress block and headers messages when compression was
+	// negotiated.  The listeners are passed the uncompressed message.
+	wireMsg := msg
+	if codec, ok := p.CompressionCodec(); ok &&
+		wire.IsCompressible(msg.Command()) {
+
+		var err error
+		wireMsg, err = wire.NewMsgCompressed(msg, codec,
+			p.ProtocolVersion(), enc)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Write the message to the peer.
-	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	n, err := p.transport.writeMessage(wireMsg, p.ProtocolVersion(), enc)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	p.recordMessage(msg, n, true)
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
@@ -1471,6 +1788,21 @@ out:
 			// completed.
 			break out
 
+		case *wire.MsgPeerAuth:
+			// Disconnect if peer sends this after the handshake is
+			// completed.
+			break out
+
+		case *wire.MsgSendTxRcncl:
+			// Disconnect if peer sends this after the handshake is
+			// completed.
+			break out
+
+		case *wire.MsgSendCmpr:
+			// Disconnect if peer sends this after the handshake is
+			// completed.
+			break out
+
 		case *wire.MsgGetAddr:
 			if p.cfg.Listeners.OnGetAddr != nil {
 				p.cfg.Listeners.OnGetAddr(p, msg)
@@ -1612,6 +1944,31 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgAttest:
+			if p.cfg.Listeners.OnAttest != nil {
+				p.cfg.Listeners.OnAttest(p, msg)
+			}
+
+		case *wire.MsgReqRecon:
+			if p.cfg.Listeners.OnReqRecon != nil {
+				p.cfg.Listeners.OnReqRecon(p, msg)
+			}
+
+		case *wire.MsgSketch:
+			if p.cfg.Listeners.OnSketch != nil {
+				p.cfg.Listeners.OnSketch(p, msg)
+			}
+
+		case *wire.MsgReqSketchExt:
+			if p.cfg.Listeners.OnReqSketchExt != nil {
+				p.cfg.Listeners.OnReqSketchExt(p, msg)
+			}
+
+		case *wire.MsgReconcilDiff:
+			if p.cfg.Listeners.OnReconcilDiff != nil {
+				p.cfg.Listeners.OnReconcilDiff(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1994,6 +2351,7 @@ func (p *Peer) readRemoteVersionMsg() error {
 	p.protocolVersion = minUint32(p.protocolVersion, p.advertisedProtoVer)
 	p.versionKnown = true
 	p.services = msg.Services
+	p.remoteNonce = msg.Nonce
 	p.flagsMtx.Unlock()
 	log.Debugf("Negotiated protocol version %d for peer %s",
 		p.protocolVersion, p)
@@ -2083,9 +2441,10 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 
 	theirNA := p.na.ToLegacy()
 
-	// If p.na is a torv3 hidden service address, we'll need to send over
-	// an empty NetAddress for their address.
-	if p.na.IsTorV3() {
+	// If p.na is an address which can't be represented by a legacy
+	// NetAddress, such as a torv3 hidden service, I2P or CJDNS address,
+	// we'll need to send over an empty NetAddress for their address.
+	if theirNA == nil {
 		theirNA = wire.NewNetAddressIPPort(
 			net.IP([]byte{0, 0, 0, 0}), p.na.Port, p.na.Services,
 		)
@@ -2121,6 +2480,9 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 	// recently seen nonces.
 	nonce := uint64(rand.Int63())
 	sentNonces.Add(nonce)
+	p.flagsMtx.Lock()
+	p.localNonce = nonce
+	p.flagsMtx.Unlock()
 
 	// Version message.
 	msg := wire.NewMsgVersion(ourNA, theirNA, nonce, blockNum)
@@ -2160,6 +2522,76 @@ func (p *Peer) writeSendAddrV2Msg(pver uint32) error {
 	return p.writeMessage(sendAddrMsg, wire.LatestEncoding)
 }
 
+// writeSendTxRcnclMsg writes our sendtxrcncl message with a random salt to the
+// remote peer if transaction reconciliation is enabled and the peer supports
+// protocol version 70016 and above.
+func (p *Peer) writeSendTxRcnclMsg(pver uint32) error {
+	if !p.cfg.TxReconciliation || p.cfg.DisableRelayTx ||
+		pver < wire.AddrV2Version {
+
+		return nil
+	}
+
+	salt, err := wire.RandomUint64()
+	if err != nil {
+		return err
+	}
+
+	p.flagsMtx.Lock()
+	p.txReconSent = true
+	p.txReconLocalSalt = salt
+	p.flagsMtx.Unlock()
+
+	msg := wire.NewMsgSendTxRcncl(wire.TxReconciliationVersion, salt)
+	return p.writeMessage(msg, wire.LatestEncoding)
+}
+
+// writeSendCmprMsg writes our sendcmpr message listing the supported
+// compression codecs to the remote peer if compression is enabled and the peer
+// supports protocol version 70016 and above.
+func (p *Peer) writeSendCmprMsg(pver uint32) error {
+	if !p.cfg.Compression || pver < wire.AddrV2Version {
+		return nil
+	}
+
+	p.flagsMtx.Lock()
+	p.cmprSent = true
+	p.flagsMtx.Unlock()
+
+	msg := wire.NewMsgSendCmpr(wire.CompressionDeflate)
+	return p.writeMessage(msg, wire.LatestEncoding)
+}
+
+// writePeerAuthMsg writes our peerauth message to the remote peer if the peer
+// is configured with an Authenticator.
+func (p *Peer) writePeerAuthMsg() error {
+	if p.cfg.Authenticator == nil {
+		return nil
+	}
+
+	authMsg, err := p.cfg.Authenticator.AuthMsg(p.V2SessionID(),
+		!p.inbound)
+	if err != nil {
+		return fmt.Errorf("unable to authenticate to peer: %w", err)
+	}
+	return p.writeMessage(authMsg, wire.LatestEncoding)
+}
+
+// verifyPeerAuthMsg verifies the peerauth message received from the remote
+// peer and records the public key the peer authenticated with.
+func (p *Peer) verifyPeerAuthMsg(msg *wire.MsgPeerAuth) error {
+	err := p.cfg.Authenticator.VerifyAuthMsg(msg, p.V2SessionID(),
+		p.inbound)
+	if err != nil {
+		return fmt.Errorf("peer failed to authenticate: %w", err)
+	}
+
+	p.flagsMtx.Lock()
+	p.authPubKey = append([]byte(nil), msg.PubKey[:]...)
+	p.flagsMtx.Unlock()
+	return nil
+}
+
 // waitToFinishNegotiation waits until desired negotiation messages are
 // received, recording the remote peer's preference for sendaddrv2 as an
 // example. The list of negotiated features can be expanded in the future. If a
@@ -2189,7 +2621,62 @@ func (p *Peer) waitToFinishNegotiation(pver uint32) error {
 					p.cfg.Listeners.OnSendAddrV2(p, m)
 				}
 			}
+		case *wire.MsgSendTxRcncl:
+			// The message is ignored like any other unknown
+			// message when reconciliation is not enabled.
+			if !p.cfg.TxReconciliation {
+				continue
+			}
+
+			p.flagsMtx.Lock()
+			duplicate := p.txReconReceived
+			if m.Version >= wire.TxReconciliationVersion {
+				p.txReconReceived = true
+				p.txReconRemoteSalt = m.Salt
+			}
+			p.flagsMtx.Unlock()
+			if duplicate {
+				return errors.New("duplicate sendtxrcncl message")
+			}
+		case *wire.MsgSendCmpr:
+			// The message is ignored like any other unknown
+			// message when compression is not enabled.
+			if !p.cfg.Compression {
+				continue
+			}
+
+			p.flagsMtx.Lock()
+			duplicate := p.cmprReceived
+			p.cmprReceived = true
+			for _, codec := range m.Codecs {
+				if codec.IsSupported() {
+					p.cmprCodec = codec
+					break
+				}
+			}
+			p.flagsMtx.Unlock()
+			if duplicate {
+				return errors.New("duplicate sendcmpr message")
+			}
+		case *wire.MsgPeerAuth:
+			// The message is ignored like any other unknown
+			// message when authentication is not enabled.
+			if p.cfg.Authenticator == nil {
+				continue
+			}
+			if p.AuthPubKey() != nil {
+				return errors.New("duplicate peerauth message")
+			}
+			if err := p.verifyPeerAuthMsg(m); err != nil {
+				return err
+			}
 		case *wire.MsgVerAck:
+			// Peers must authenticate before completing the
+			// handshake when authentication is enabled.
+			if p.cfg.Authenticator != nil && p.AuthPubKey() == nil {
+				return errors.New("peer did not authenticate")
+			}
+
 			// Receiving a verack means we are done with the
 			// handshake.
 			p.processRemoteVerAckMsg(m)
@@ -2209,11 +2696,19 @@ func (p *Peer) waitToFinishNegotiation(pver uint32) error {
 //  1. Remote peer sends their version.
 //  2. We send our version.
 //  3. We send sendaddrv2 if their version is >= 70016.
-//  4. We send our verack.
-//  5. Wait until sendaddrv2 or verack is received. Unknown messages are
-//     skipped as it could be wtxidrelay or a different message in the future
-//     that btcd does not implement but bitcoind does.
-//  6. If remote peer sent sendaddrv2 above, wait until receipt of verack.
+//  4. We send sendtxrcncl if their version is >= 70016 and transaction
+//     reconciliation is enabled.
+//  5. We send sendcmpr if their version is >= 70016 and compression is
+//     enabled.
+//  6. We send peerauth if an Authenticator is configured.
+//  7. We send our verack.
+//  8. Wait until sendaddrv2, sendtxrcncl, sendcmpr, peerauth or verack is
+//     received.  Unknown messages are skipped as it could be wtxidrelay or a
+//     different message in the future that btcd does not implement but
+//     bitcoind does.
+//  9. If remote peer sent sendaddrv2, sendtxrcncl, sendcmpr or peerauth
+//     above, wait until receipt of verack.  A verack without a preceding
+//     peerauth is an error if an Authenticator is configured.
 func (p *Peer) negotiateInboundProtocol() error {
 	if err := p.readRemoteVersionMsg(); err != nil {
 		return err
@@ -2232,6 +2727,18 @@ func (p *Peer) negotiateInboundProtocol() error {
 		return err
 	}
 
+	if err := p.writeSendTxRcnclMsg(protoVersion); err != nil {
+		return err
+	}
+
+	if err := p.writeSendCmprMsg(protoVersion); err != nil {
+		return err
+	}
+
+	if err := p.writePeerAuthMsg(); err != nil {
+		return err
+	}
+
 	err := p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 	if err != nil {
 		return err
@@ -2248,10 +2755,17 @@ func (p *Peer) negotiateInboundProtocol() error {
 //  1. We send our version.
 //  2. Remote peer sends their version.
 //  3. We send sendaddrv2 if their version is >= 70016.
-//  4. We send our verack.
-//  5. We wait to receive sendaddrv2 or verack, skipping unknown messages as
-//     in the inbound case.
-//  6. If sendaddrv2 was received, wait for receipt of verack.
+//  4. We send sendtxrcncl if their version is >= 70016 and transaction
+//     reconciliation is enabled.
+//  5. We send sendcmpr if their version is >= 70016 and compression is
+//     enabled.
+//  6. We send peerauth if an Authenticator is configured.
+//  7. We send our verack.
+//  8. We wait to receive sendaddrv2, sendtxrcncl, sendcmpr, peerauth or
+//     verack, skipping unknown messages as in the inbound case.
+//  9. If sendaddrv2, sendtxrcncl, sendcmpr or peerauth was received, wait for
+//     receipt of verack.  A verack without a preceding peerauth is an error
+//     if an Authenticator is configured.
 func (p *Peer) negotiateOutboundProtocol() error {
 	if err := p.writeLocalVersionMsg(); err != nil {
 		return err
@@ -2270,6 +2784,18 @@ func (p *Peer) negotiateOutboundProtocol() error {
 		return err
 	}
 
+	if err := p.writeSendTxRcnclMsg(protoVersion); err != nil {
+		return err
+	}
+
+	if err := p.writeSendCmprMsg(protoVersion); err != nil {
+		return err
+	}
+
+	if err := p.writePeerAuthMsg(); err != nil {
+		return err
+	}
+
 	err := p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 	if err != nil {
 		return err
@@ -2279,12 +2805,64 @@ func (p *Peer) negotiateOutboundProtocol() error {
 	return p.waitToFinishNegotiation(protoVersion)
 }
 
+// setupTransport selects the transport protocol of the connection and
+// establishes the encrypted v2 transport protocol when it is used.  Inbound
+// peers are detected to use the v1 protocol when the first bytes they send are
+// the start of a version message.
+func (p *Peer) setupTransport() error {
+	net := p.cfg.ChainParams.Net
+	if !p.cfg.V2Transport {
+		p.transport = &v1Transport{r: p.conn, w: p.conn, net: net}
+		return nil
+	}
+
+	var prefix []byte
+	if p.inbound {
+		prefix = make([]byte, len(v1Prefix(net)))
+		if _, err := io.ReadFull(p.conn, prefix); err != nil {
+			return err
+		}
+		if bytes.Equal(prefix, v1Prefix(net)) {
+			p.transport = &v1Transport{
+				r:   io.MultiReader(bytes.NewReader(prefix), p.conn),
+				w:   p.conn,
+				net: net,
+			}
+			return nil
+		}
+	}
+
+	t, nRead, nWritten, err := newV2Transport(p.conn, net, !p.inbound,
+		prefix)
+	atomic.AddUint64(&p.bytesReceived, uint64(nRead))
+	atomic.AddUint64(&p.bytesSent, uint64(nWritten))
+	if err != nil {
+		if err == errV2Rejected {
+			p.flagsMtx.Lock()
+			p.v2Rejected = true
+			p.flagsMtx.Unlock()
+		}
+		return fmt.Errorf("v2 transport handshake failed: %w", err)
+	}
+
+	p.transport = t
+	p.flagsMtx.Lock()
+	p.v2SessionID = t.sessionID
+	p.flagsMtx.Unlock()
+	log.Debugf("Established v2 transport with %s", p)
+	return nil
+}
+
 // start begins processing input and output messages.
 func (p *Peer) start() error {
 	log.Tracef("Starting peer %s", p)
 
 	negotiateErr := make(chan error, 1)
 	go func() {
+		if err := p.setupTransport(); err != nil {
+			negotiateErr <- err
+			return
+		}
 		if p.inbound {
 			negotiateErr <- p.negotiateInboundProtocol()
 		} else {
@@ -2388,6 +2966,7 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		inbound:         inbound,
 		wireEncoding:    wire.BaseEncoding,
 		knownInventory:  lru.NewCache(maxKnownInventory),
+		msgStats:        make(map[string]*MessageStats),
 		stallControl:    make(chan stallControlMsg, 1), // nonblocking sync
 		outputQueue:     make(chan outMsg, outputBufferSize),
 		sendQueue:       make(chan outMsg, 1),   // nonblocking sync
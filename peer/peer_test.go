@@ -6,9 +6,11 @@
 package peer_test
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -110,6 +112,7 @@ type peerStats struct {
 	wantBytesSent       uint64
 	wantBytesReceived   uint64
 	wantWitnessEnabled  bool
+	wantMsgStats        map[string]peer.MessageStats
 }
 
 // testPeer tests the given peer's flags and stats
@@ -214,6 +217,11 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		t.Errorf("testPeer: wrong LastRecv - got %v, want %v", p.LastRecv(), stats.LastRecv)
 		return
 	}
+
+	if !reflect.DeepEqual(stats.MsgStats, s.wantMsgStats) {
+		t.Errorf("testPeer: wrong MsgStats - got %v, want %v", stats.MsgStats, s.wantMsgStats)
+		return
+	}
 }
 
 // TestPeerConnection tests connection between inbound and outbound peers.
@@ -265,6 +273,10 @@ func TestPeerConnection(t *testing.T) {
 		wantBytesSent:       167, // 143 version + 24 verack
 		wantBytesReceived:   167,
 		wantWitnessEnabled:  false,
+		wantMsgStats: map[string]peer.MessageStats{
+			wire.CmdVersion: {MsgsSent: 1, BytesSent: 143, MsgsRecv: 1, BytesRecv: 143},
+			wire.CmdVerAck:  {MsgsSent: 1, BytesSent: 24, MsgsRecv: 1, BytesRecv: 24},
+		},
 	}
 	wantStats2 := peerStats{
 		wantUserAgent:       wire.DefaultUserAgent + "peer:1.0(comment)/",
@@ -280,6 +292,10 @@ func TestPeerConnection(t *testing.T) {
 		wantBytesSent:       167, // 143 version + 24 verack
 		wantBytesReceived:   167,
 		wantWitnessEnabled:  true,
+		wantMsgStats: map[string]peer.MessageStats{
+			wire.CmdVersion: {MsgsSent: 1, BytesSent: 143, MsgsRecv: 1, BytesRecv: 143},
+			wire.CmdVerAck:  {MsgsSent: 1, BytesSent: 24, MsgsRecv: 1, BytesRecv: 24},
+		},
 	}
 
 	tests := []struct {
@@ -352,6 +368,110 @@ func TestPeerConnection(t *testing.T) {
 	}
 }
 
+// unknownMsg is a message with a command peers don't know.
+type unknownMsg struct{}
+
+func (m *unknownMsg) BtcDecode(io.Reader, uint32, wire.MessageEncoding) error {
+	return nil
+}
+
+func (m *unknownMsg) BtcEncode(io.Writer, uint32, wire.MessageEncoding) error {
+	return nil
+}
+
+func (m *unknownMsg) Command() string {
+	return "unknown"
+}
+
+func (m *unknownMsg) MaxPayloadLength(uint32) uint32 {
+	return 0
+}
+
+// TestPeerMessageStats ensures the messages and addresses exchanged with peers
+// are counted as intended, including messages which can't be read.
+func TestPeerMessageStats(t *testing.T) {
+	verack := make(chan struct{}, 2)
+	addr := make(chan struct{}, 1)
+	listeners := peer.MessageListeners{
+		OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+			verack <- struct{}{}
+		},
+		OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+			addr <- struct{}{}
+		},
+	}
+	inPeer := peer.NewInboundPeer(&peer.Config{
+		Listeners:      listeners,
+		AllowSelfConns: true,
+		ChainParams:    &chaincfg.MainNetParams,
+	})
+	outPeer, err := peer.NewOutboundPeer(&peer.Config{
+		Listeners:      listeners,
+		AllowSelfConns: true,
+		ChainParams:    &chaincfg.MainNetParams,
+	}, "10.0.0.2:8333")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setupPeerConnection(inPeer, outPeer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-verack:
+		case <-time.After(time.Second):
+			t.Fatal("verack timeout")
+		}
+	}
+
+	// The addr message is read after the unknown one, so both are counted
+	// once it is received.
+	msgAddr := wire.NewMsgAddr()
+	msgAddr.AddAddress(wire.NewNetAddressIPPort(net.ParseIP("1.2.3.4"), 8333, 0))
+	msgAddr.AddAddress(wire.NewNetAddressIPPort(net.ParseIP("5.6.7.8"), 8333, 0))
+	outPeer.QueueMessage(&unknownMsg{}, nil)
+	outPeer.QueueMessage(msgAddr, nil)
+	select {
+	case <-addr:
+	case <-time.After(time.Second):
+		t.Fatal("addr timeout")
+	}
+
+	// The addr message has a 24 byte header, a one byte count and two 30
+	// byte addresses, while the unknown message is only a header.
+	sent := outPeer.StatsSnapshot()
+	recv := inPeer.StatsSnapshot()
+	wantAddr := peer.MessageStats{MsgsSent: 1, BytesSent: 85}
+	if got := sent.MsgStats[wire.CmdAddr]; got != wantAddr {
+		t.Fatalf("sent addr stats: got %+v, want %+v", got, wantAddr)
+	}
+	wantUnknown := peer.MessageStats{MsgsSent: 1, BytesSent: 24}
+	if got := sent.MsgStats["unknown"]; got != wantUnknown {
+		t.Fatalf("sent unknown stats: got %+v, want %+v", got, wantUnknown)
+	}
+	wantAddr = peer.MessageStats{MsgsRecv: 1, BytesRecv: 85}
+	if got := recv.MsgStats[wire.CmdAddr]; got != wantAddr {
+		t.Fatalf("received addr stats: got %+v, want %+v", got, wantAddr)
+	}
+	wantOther := peer.MessageStats{MsgsRecv: 1, BytesRecv: 24}
+	if got := recv.MsgStats[peer.OtherCommand]; got != wantOther {
+		t.Fatalf("received other stats: got %+v, want %+v", got, wantOther)
+	}
+	if sent.AddrsSent != 2 || sent.AddrsRecv != 0 || recv.AddrsRecv != 2 ||
+		recv.AddrsSent != 0 {
+
+		t.Fatalf("unexpected address counts: sent %d/%d, received %d/%d",
+			sent.AddrsSent, sent.AddrsRecv, recv.AddrsRecv,
+			recv.AddrsSent)
+	}
+}
+
 // TestPeerListeners tests that the peer listeners are called as expected.
 func TestPeerListeners(t *testing.T) {
 	verack := make(chan struct{}, 1)
@@ -443,6 +563,9 @@ func TestPeerListeners(t *testing.T) {
 			OnSendHeaders: func(p *peer.Peer, msg *wire.MsgSendHeaders) {
 				ok <- msg
 			},
+			OnAttest: func(p *peer.Peer, msg *wire.MsgAttest) {
+				ok <- msg
+			},
 			OnSendAddrV2: func(p *peer.Peer, msg *wire.MsgSendAddrV2) {
 				ok <- msg
 			},
@@ -599,6 +722,11 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnAttest",
+			wire.NewMsgAttest(&chainhash.Hash{}, 1,
+				[wire.AttestPubKeySize]byte{}, nil),
+		},
 		{
 			"OnSendAddrV2",
 			wire.NewMsgSendAddrV2(),
@@ -1209,3 +1337,483 @@ func TestSendAddrV2Handshake(t *testing.T) {
 		outPeer.WaitForDisconnect()
 	}
 }
+
+// mockAuthenticator is a peer.Authenticator which authenticates with a fixed
+// public key and accepts the public keys it is configured with.  The signature
+// of its peerauth messages is the role of the signer followed by the session
+// id itself.
+type mockAuthenticator struct {
+	pubKey  [wire.PeerAuthPubKeySize]byte
+	allowed [][wire.PeerAuthPubKeySize]byte
+}
+
+// mockProof returns the signature of the mock authenticator for the passed
+// session id and role.
+func mockProof(sessionID []byte, initiator bool) []byte {
+	var role byte
+	if initiator {
+		role = 1
+	}
+	return append([]byte{role}, sessionID...)
+}
+
+// AuthMsg returns a peerauth message for the passed session id and role.
+func (a *mockAuthenticator) AuthMsg(sessionID []byte,
+	initiator bool) (*wire.MsgPeerAuth, error) {
+
+	if sessionID == nil {
+		return nil, errors.New("no session id")
+	}
+	sig := mockProof(sessionID, initiator)
+	return wire.NewMsgPeerAuth(a.pubKey, 0, nil, sig), nil
+}
+
+// VerifyAuthMsg returns an error unless the passed message is from an allowed
+// public key and signs the passed session id and role.
+func (a *mockAuthenticator) VerifyAuthMsg(msg *wire.MsgPeerAuth,
+	sessionID []byte, initiator bool) error {
+
+	if sessionID == nil {
+		return errors.New("no session id")
+	}
+	if !bytes.Equal(msg.Signature, mockProof(sessionID, initiator)) {
+		return errors.New("invalid signature")
+	}
+	for _, pubKey := range a.allowed {
+		if msg.PubKey == pubKey {
+			return nil
+		}
+	}
+	return errors.New("unknown public key")
+}
+
+// TestPeerAuthHandshake tests that peers configured with an authenticator
+// only complete the version handshake with peers that authenticate.
+func TestPeerAuthHandshake(t *testing.T) {
+	key1 := [wire.PeerAuthPubKeySize]byte{0x02, 0x01}
+	key2 := [wire.PeerAuthPubKeySize]byte{0x02, 0x02}
+	key3 := [wire.PeerAuthPubKeySize]byte{0x02, 0x03}
+
+	tests := []struct {
+		name    string
+		inAuth  peer.Authenticator
+		outAuth peer.Authenticator
+		v1      bool
+		success bool
+
+		// inRejects is true when the inbound peer rejects the outbound
+		// peer rather than the other way around.
+		inRejects bool
+	}{{
+		name: "mutual authentication",
+		inAuth: &mockAuthenticator{
+			pubKey:  key1,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key2},
+		},
+		outAuth: &mockAuthenticator{
+			pubKey:  key2,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key1},
+		},
+		success: true,
+	}, {
+		name: "outbound peer not allowed",
+		inAuth: &mockAuthenticator{
+			pubKey:  key1,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key2},
+		},
+		outAuth: &mockAuthenticator{
+			pubKey:  key3,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key1},
+		},
+		inRejects: true,
+	}, {
+		name: "outbound peer without authentication",
+		inAuth: &mockAuthenticator{
+			pubKey:  key1,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key2},
+		},
+		inRejects: true,
+	}, {
+		name: "inbound peer without authentication",
+		outAuth: &mockAuthenticator{
+			pubKey:  key2,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key1},
+		},
+	}, {
+		name: "v1 transport",
+		inAuth: &mockAuthenticator{
+			pubKey:  key1,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key2},
+		},
+		outAuth: &mockAuthenticator{
+			pubKey:  key2,
+			allowed: [][wire.PeerAuthPubKeySize]byte{key1},
+		},
+		v1: true,
+	}}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+		}
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			Authenticator:  test.inAuth,
+			V2Transport:    !test.v1,
+		})
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			Authenticator:  test.outAuth,
+			V2Transport:    !test.v1,
+		}, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := setupPeerConnection(inPeer, outPeer); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if test.success {
+			for i := 0; i < 2; i++ {
+				select {
+				case <-verack:
+				case <-time.After(time.Second * 2):
+					t.Fatalf("%s: verack timeout", test.name)
+				}
+			}
+			if !bytes.Equal(inPeer.AuthPubKey(), key2[:]) {
+				t.Fatalf("%s: got inbound auth key %x, want "+
+					"%x", test.name, inPeer.AuthPubKey(),
+					key2)
+			}
+			if !bytes.Equal(outPeer.AuthPubKey(), key1[:]) {
+				t.Fatalf("%s: got outbound auth key %x, want "+
+					"%x", test.name, outPeer.AuthPubKey(),
+					key1)
+			}
+		} else {
+			// The peers must disconnect without completing the
+			// handshake.
+			disconnected := make(chan struct{})
+			go func() {
+				inPeer.WaitForDisconnect()
+				outPeer.WaitForDisconnect()
+				close(disconnected)
+			}()
+			select {
+			case <-disconnected:
+			case <-time.After(time.Second * 5):
+				t.Fatalf("%s: peers did not disconnect",
+					test.name)
+			}
+			rejecter := outPeer
+			if test.inRejects {
+				rejecter = inPeer
+			}
+			if rejecter.VerAckReceived() {
+				t.Fatalf("%s: rejecting peer completed the "+
+					"handshake", test.name)
+			}
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
+
+// TestTxReconNegotiation ensures transaction reconciliation is only negotiated
+// when both peers offer it with a sendtxrcncl message during the handshake.
+func TestTxReconNegotiation(t *testing.T) {
+	tests := []struct {
+		name         string
+		inRecon      bool
+		outRecon     bool
+		outBlockOnly bool
+		want         bool
+	}{
+		{name: "both peers", inRecon: true, outRecon: true, want: true},
+		{name: "inbound peer only", inRecon: true},
+		{name: "outbound peer only", outRecon: true},
+		{name: "blocks only", inRecon: true, outRecon: true,
+			outBlockOnly: true},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+		}
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners:        listeners,
+			AllowSelfConns:   true,
+			ChainParams:      &chaincfg.MainNetParams,
+			TxReconciliation: test.inRecon,
+		})
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			Listeners:        listeners,
+			AllowSelfConns:   true,
+			ChainParams:      &chaincfg.MainNetParams,
+			TxReconciliation: test.outRecon,
+			DisableRelayTx:   test.outBlockOnly,
+		}, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := setupPeerConnection(inPeer, outPeer); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		inLocal, inRemote, inOK := inPeer.TxReconSalts()
+		outLocal, outRemote, outOK := outPeer.TxReconSalts()
+		if inOK != test.want || outOK != test.want {
+			t.Fatalf("%s: got negotiated %v and %v, want %v",
+				test.name, inOK, outOK, test.want)
+		}
+		if test.want && (inLocal != outRemote || inRemote != outLocal) {
+			t.Fatalf("%s: salts do not match", test.name)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
+
+// TestV2Transport ensures peers establish the encrypted v2 transport protocol
+// when both use it and fall back to the v1 protocol otherwise.
+func TestV2Transport(t *testing.T) {
+	tests := []struct {
+		name         string
+		inV2         bool
+		outV2        bool
+		wantV2       bool
+		wantRejected bool
+	}{
+		{name: "both peers", inV2: true, outV2: true, wantV2: true},
+		{name: "inbound peer only", inV2: true},
+		{name: "neither peer"},
+		{name: "outbound peer only", outV2: true, wantRejected: true},
+	}
+
+	// Send enough pings for the ciphers to switch keys.
+	const numPings = 300
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		pong := make(chan struct{}, numPings)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnPong: func(p *peer.Peer, msg *wire.MsgPong) {
+				pong <- struct{}{}
+			},
+		}
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			V2Transport:    test.inV2,
+		})
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			V2Transport:    test.outV2,
+		}, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := setupPeerConnection(inPeer, outPeer); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if test.wantRejected {
+			outPeer.WaitForDisconnect()
+			inPeer.WaitForDisconnect()
+			if !outPeer.V2Rejected() {
+				t.Fatalf("%s: v2 transport was not rejected",
+					test.name)
+			}
+			continue
+		}
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		if inPeer.V2Transport() != test.wantV2 ||
+			outPeer.V2Transport() != test.wantV2 {
+
+			t.Fatalf("%s: got v2 transport %v and %v, want %v",
+				test.name, inPeer.V2Transport(),
+				outPeer.V2Transport(), test.wantV2)
+		}
+		inID, outID := inPeer.V2SessionID(), outPeer.V2SessionID()
+		if !bytes.Equal(inID, outID) || (len(inID) != 0) != test.wantV2 {
+			t.Fatalf("%s: got session ids %x and %x", test.name,
+				inID, outID)
+		}
+		if inPeer.StatsSnapshot().V2Transport != test.wantV2 {
+			t.Fatalf("%s: stats do not report v2 transport",
+				test.name)
+		}
+
+		for i := 0; i < numPings; i++ {
+			outPeer.QueueMessage(wire.NewMsgPing(uint64(i)), nil)
+		}
+		for i := 0; i < numPings; i++ {
+			select {
+			case <-pong:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("%s: pong timeout", test.name)
+			}
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
+
+// TestCompressionNegotiation ensures the compression of block and headers
+// messages is only negotiated when both peers offer it with a sendcmpr message
+// during the handshake and that compressed messages are received intact.
+func TestCompressionNegotiation(t *testing.T) {
+	tests := []struct {
+		name    string
+		inCmpr  bool
+		outCmpr bool
+		want    bool
+	}{
+		{name: "both peers", inCmpr: true, outCmpr: true, want: true},
+		{name: "inbound peer only", inCmpr: true},
+		{name: "outbound peer only", outCmpr: true},
+	}
+
+	// Create a block with a large and easily compressed transaction.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(0, make([]byte, 50000)))
+	block := wire.NewMsgBlock(&wire.BlockHeader{Version: 1})
+	block.AddTransaction(tx)
+	var blockBuf bytes.Buffer
+	if err := block.Serialize(&blockBuf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		received := make(chan []byte, 1)
+		headers := make(chan *wire.MsgHeaders, 1)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnBlock: func(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+				received <- buf
+			},
+			OnHeaders: func(p *peer.Peer, msg *wire.MsgHeaders) {
+				headers <- msg
+			},
+		}
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			Compression:    test.inCmpr,
+		})
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			Compression:    test.outCmpr,
+		}, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := setupPeerConnection(inPeer, outPeer); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		inCodec, inOK := inPeer.CompressionCodec()
+		outCodec, outOK := outPeer.CompressionCodec()
+		if inOK != test.want || outOK != test.want {
+			t.Fatalf("%s: got negotiated %v and %v, want %v",
+				test.name, inOK, outOK, test.want)
+		}
+		if test.want && (inCodec != wire.CompressionDeflate ||
+			outCodec != wire.CompressionDeflate) {
+
+			t.Fatalf("%s: got codecs %v and %v", test.name, inCodec,
+				outCodec)
+		}
+
+		sentBefore := outPeer.BytesSent()
+		outPeer.QueueMessage(block, nil)
+		select {
+		case buf := <-received:
+			if !bytes.Equal(buf, blockBuf.Bytes()) {
+				t.Fatalf("%s: received block bytes do not match",
+					test.name)
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatalf("%s: block timeout", test.name)
+		}
+		sent := outPeer.BytesSent() - sentBefore
+		if compressed := sent < uint64(blockBuf.Len()); compressed != test.want {
+			t.Fatalf("%s: sent %d bytes for a %d byte block",
+				test.name, sent, blockBuf.Len())
+		}
+
+		hdrs := wire.NewMsgHeaders()
+		hdrs.AddBlockHeader(&block.Header)
+		inPeer.QueueMessage(hdrs, nil)
+		select {
+		case msg := <-headers:
+			if len(msg.Headers) != 1 ||
+				msg.Headers[0].BlockHash() != block.BlockHash() {
+
+				t.Fatalf("%s: received wrong headers", test.name)
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatalf("%s: headers timeout", test.name)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
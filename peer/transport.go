@@ -0,0 +1,58 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"io"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// transport is the interface implemented by the transport protocols used to
+// exchange bitcoin messages with a remote peer.
+//
+// readMessage is only called from a single goroutine at a time and the same
+// holds for writeMessage, however both may be called concurrently.
+type transport interface {
+	// readMessage reads the next message from the remote peer.  It returns
+	// the number of bytes read in addition to the parsed message and its
+	// raw payload.
+	readMessage(pver uint32, enc wire.MessageEncoding) (int, wire.Message,
+		[]byte, error)
+
+	// writeMessage sends the passed message to the remote peer and returns
+	// the number of bytes written.
+	writeMessage(msg wire.Message, pver uint32,
+		enc wire.MessageEncoding) (int, error)
+}
+
+// v1Transport implements the transport interface for the original unencrypted
+// transport protocol.
+type v1Transport struct {
+	r   io.Reader
+	w   io.Writer
+	net wire.BitcoinNet
+}
+
+// Ensure v1Transport implements the transport interface.
+var _ transport = (*v1Transport)(nil)
+
+// readMessage reads the next message from the remote peer.
+//
+// This is part of the transport interface.
+func (t *v1Transport) readMessage(pver uint32,
+	enc wire.MessageEncoding) (int, wire.Message, []byte, error) {
+
+	return wire.ReadMessageWithEncodingN(t.r, pver, t.net, enc)
+}
+
+// writeMessage sends the passed message to the remote peer.
+//
+// This is part of the transport interface.
+func (t *v1Transport) writeMessage(msg wire.Message, pver uint32,
+	enc wire.MessageEncoding) (int, error) {
+
+	return wire.WriteMessageWithEncodingN(t.w, msg, pver, t.net, enc)
+}
@@ -0,0 +1,529 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/ellswift"
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/poly1305"
+)
+
+const (
+	// v2KeySize is the size of the ElligatorSwift encoded public keys
+	// exchanged at the start of the v2 transport protocol.
+	v2KeySize = ellswift.Size
+
+	// v2GarbageTerminatorSize is the size of the garbage terminator that
+	// follows the random garbage sent after the public key.
+	v2GarbageTerminatorSize = 16
+
+	// v2MaxGarbageSize is the maximum amount of random garbage sent after
+	// the public key.
+	v2MaxGarbageSize = 4095
+
+	// v2LengthSize is the size of the encrypted length field of a packet.
+	v2LengthSize = 3
+
+	// v2HeaderSize is the size of the header of the packet contents.
+	v2HeaderSize = 1
+
+	// v2IgnoreBit is the bit of the packet header that marks decoy packets
+	// that must be ignored by the receiver.
+	v2IgnoreBit = 0x80
+
+	// v2RekeyInterval is the number of packets after which the ciphers
+	// switch to a new key to provide forward secrecy.
+	v2RekeyInterval = 224
+
+	// v2MaxContentsSize is the maximum size of the contents of a packet
+	// carrying a message.
+	v2MaxContentsSize = 1 + wire.CommandSize + wire.MaxMessagePayload
+)
+
+var (
+	// v2SaltPrefix is the prefix of the network specific salt used to
+	// derive the session keys from the shared secret.
+	v2SaltPrefix = []byte("bitcoin_v2_shared_secret")
+
+	// errV2Rejected is returned when the remote peer closes the connection
+	// without responding to an attempt to establish the v2 transport
+	// protocol, which indicates it only supports the v1 protocol.
+	errV2Rejected = errors.New("remote peer rejected the v2 transport")
+)
+
+// v1Prefix returns the first bytes sent by an initiator using the v1 transport
+// protocol, which consist of the network magic and the version command.
+// Responders supporting both protocols use them to detect v1 initiators.
+func v1Prefix(net wire.BitcoinNet) []byte {
+	prefix := make([]byte, 4+wire.CommandSize)
+	binary.LittleEndian.PutUint32(prefix, uint32(net))
+	copy(prefix[4:], wire.CmdVersion)
+	return prefix
+}
+
+// v2Nonce returns a 96-bit ChaCha20 nonce made of the passed 32-bit and 64-bit
+// little-endian counters.
+func v2Nonce(low uint32, high uint64) []byte {
+	nonce := make([]byte, chacha20.NonceSize)
+	binary.LittleEndian.PutUint32(nonce[0:4], low)
+	binary.LittleEndian.PutUint64(nonce[4:12], high)
+	return nonce
+}
+
+// fsChaCha20 is the forward secure stream cipher used to encrypt the length of
+// packets.  It switches to a new key taken from its keystream every
+// v2RekeyInterval chunks.
+type fsChaCha20 struct {
+	cipher       *chacha20.Cipher
+	chunkCounter uint64
+}
+
+// newFSChaCha20 returns a new forward secure stream cipher with the passed
+// 32-byte key.
+func newFSChaCha20(key []byte) *fsChaCha20 {
+	c := &fsChaCha20{}
+	c.rekey(key)
+	return c
+}
+
+// rekey switches the cipher to the passed key.
+func (c *fsChaCha20) rekey(key []byte) {
+	// The key and nonce sizes are always valid.
+	c.cipher, _ = chacha20.NewUnauthenticatedCipher(key,
+		v2Nonce(0, c.chunkCounter/v2RekeyInterval))
+}
+
+// crypt encrypts or decrypts the passed chunk into dst, which may be the same
+// slice as src.
+func (c *fsChaCha20) crypt(dst, src []byte) {
+	c.cipher.XORKeyStream(dst, src)
+	c.chunkCounter++
+	if c.chunkCounter%v2RekeyInterval == 0 {
+		var key [chacha20.KeySize]byte
+		c.cipher.XORKeyStream(key[:], key[:])
+		c.rekey(key[:])
+	}
+}
+
+// v2TagSize is the size of the authentication tag of the ChaCha20-Poly1305
+// AEAD.
+const v2TagSize = poly1305.TagSize
+
+// chacha20Poly1305Tag returns the authentication tag of the ChaCha20-Poly1305
+// AEAD (RFC 8439) for the passed ciphertext and associated data.
+func chacha20Poly1305Tag(polyKey *[32]byte, ciphertext, aad []byte) [v2TagSize]byte {
+	pad := func(n int) int { return (16 - n%16) % 16 }
+	macData := make([]byte, 0, len(aad)+pad(len(aad))+len(ciphertext)+
+		pad(len(ciphertext))+16)
+	macData = append(macData, aad...)
+	macData = append(macData, make([]byte, pad(len(aad)))...)
+	macData = append(macData, ciphertext...)
+	macData = append(macData, make([]byte, pad(len(ciphertext)))...)
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:8], uint64(len(aad)))
+	binary.LittleEndian.PutUint64(lengths[8:16], uint64(len(ciphertext)))
+	macData = append(macData, lengths[:]...)
+
+	var tag [v2TagSize]byte
+	poly1305.Sum(&tag, macData, polyKey)
+	return tag
+}
+
+// chacha20Poly1305Cipher returns the ChaCha20 cipher that encrypts the data of
+// the ChaCha20-Poly1305 AEAD (RFC 8439) with the passed key and nonce along
+// with the one-time Poly1305 key derived from them.
+func chacha20Poly1305Cipher(key, nonce []byte) (*chacha20.Cipher, *[32]byte) {
+	// The key and nonce sizes are always valid.
+	c, _ := chacha20.NewUnauthenticatedCipher(key, nonce)
+	var polyKey [32]byte
+	c.XORKeyStream(polyKey[:], polyKey[:])
+	c.SetCounter(1)
+	return c, &polyKey
+}
+
+// fsChaCha20Poly1305 is the forward secure AEAD used to encrypt the contents of
+// packets.  It is ChaCha20-Poly1305 (RFC 8439) switching to a new key every
+// v2RekeyInterval packets.
+type fsChaCha20Poly1305 struct {
+	key           []byte
+	packetCounter uint64
+}
+
+// newFSChaCha20Poly1305 returns a new forward secure AEAD with the passed
+// 32-byte key.
+func newFSChaCha20Poly1305(key []byte) *fsChaCha20Poly1305 {
+	return &fsChaCha20Poly1305{key: key}
+}
+
+// nonce returns the nonce of the next packet.
+func (c *fsChaCha20Poly1305) nonce() []byte {
+	return v2Nonce(uint32(c.packetCounter%v2RekeyInterval),
+		c.packetCounter/v2RekeyInterval)
+}
+
+// advance moves on to the next packet and switches to a new key at the end of
+// each rekey interval.  The new key is the encryption of zeros with a nonce
+// that is never used for packets.
+func (c *fsChaCha20Poly1305) advance() {
+	c.packetCounter++
+	if c.packetCounter%v2RekeyInterval != 0 {
+		return
+	}
+
+	nonce := v2Nonce(0xffffffff, c.packetCounter/v2RekeyInterval-1)
+	cipher, _ := chacha20Poly1305Cipher(c.key, nonce)
+	key := make([]byte, chacha20.KeySize)
+	cipher.XORKeyStream(key, key)
+	c.key = key
+}
+
+// seal appends the encryption of the passed plaintext authenticating the
+// passed associated data to dst.
+func (c *fsChaCha20Poly1305) seal(dst, plaintext, aad []byte) []byte {
+	cipher, polyKey := chacha20Poly1305Cipher(c.key, c.nonce())
+	c.advance()
+
+	offset := len(dst)
+	dst = append(dst, plaintext...)
+	ciphertext := dst[offset:]
+	cipher.XORKeyStream(ciphertext, ciphertext)
+	tag := chacha20Poly1305Tag(polyKey, ciphertext, aad)
+	return append(dst, tag[:]...)
+}
+
+// open appends the decryption of the passed ciphertext to dst after verifying
+// it and the passed associated data are authentic.  dst may be the passed
+// ciphertext truncated to zero length.
+func (c *fsChaCha20Poly1305) open(dst, ciphertext, aad []byte) ([]byte, error) {
+	cipher, polyKey := chacha20Poly1305Cipher(c.key, c.nonce())
+	c.advance()
+
+	if len(ciphertext) < v2TagSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	tagOffset := len(ciphertext) - v2TagSize
+	tag := chacha20Poly1305Tag(polyKey, ciphertext[:tagOffset], aad)
+	if subtle.ConstantTimeCompare(tag[:], ciphertext[tagOffset:]) != 1 {
+		return nil, errors.New("message authentication failed")
+	}
+
+	offset := len(dst)
+	dst = append(dst, ciphertext[:tagOffset]...)
+	plaintext := dst[offset:]
+	cipher.XORKeyStream(plaintext, plaintext)
+	return dst, nil
+}
+
+// v2Transport implements the transport interface for the encrypted v2
+// transport protocol defined by BIP0324.
+type v2Transport struct {
+	r *bufio.Reader
+	w io.Writer
+
+	sendL          *fsChaCha20
+	sendP          *fsChaCha20Poly1305
+	sendTerminator []byte
+	recvL          *fsChaCha20
+	recvP          *fsChaCha20Poly1305
+	recvTerminator []byte
+	sessionID      []byte
+}
+
+// Ensure v2Transport implements the transport interface.
+var _ transport = (*v2Transport)(nil)
+
+// newV2KeyPair returns a new secp256k1 key pair along with the ElligatorSwift
+// encoding of the public key.  Encodings starting with the network magic are
+// avoided so they can't be mistaken for the start of a v1 connection.
+func newV2KeyPair(net wire.BitcoinNet) (*btcec.PrivateKey, []byte, error) {
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	magic := v1Prefix(net)[:4]
+	for {
+		pubKey, err := ellswift.Encode(privKey.PubKey())
+		if err != nil {
+			return nil, nil, err
+		}
+		if !bytes.Equal(pubKey[:4], magic) {
+			return privKey, pubKey[:], nil
+		}
+	}
+}
+
+// randomGarbage returns a random amount of random bytes that is sent after the
+// public key to make the traffic pattern harder to recognize.
+func randomGarbage() ([]byte, error) {
+	var sizeBytes [2]byte
+	if _, err := rand.Read(sizeBytes[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint16(sizeBytes[:]) % (v2MaxGarbageSize + 1)
+	garbage := make([]byte, size)
+	if _, err := rand.Read(garbage); err != nil {
+		return nil, err
+	}
+	return garbage, nil
+}
+
+// initCiphers derives the session keys from the result of the key exchange
+// between the passed private key and ElligatorSwift encoded public keys and
+// initializes the ciphers for the role of the local peer.
+func (t *v2Transport) initCiphers(net wire.BitcoinNet, initiator bool,
+	privKey *btcec.PrivateKey, localPubKey, remotePubKey []byte) error {
+
+	secret, err := ellswift.XDH(privKey, localPubKey, remotePubKey,
+		initiator)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, len(v2SaltPrefix)+4)
+	copy(salt, v2SaltPrefix)
+	binary.LittleEndian.PutUint32(salt[len(v2SaltPrefix):], uint32(net))
+	prk := hkdf.Extract(sha256.New, secret[:], salt)
+	expand := func(label string) []byte {
+		key := make([]byte, 32)
+		// The output of HKDF-SHA256 is long enough for any key.
+		_, _ = io.ReadFull(hkdf.Expand(sha256.New, prk, []byte(label)),
+			key)
+		return key
+	}
+
+	initiatorL := newFSChaCha20(expand("initiator_L"))
+	initiatorP := newFSChaCha20Poly1305(expand("initiator_P"))
+	responderL := newFSChaCha20(expand("responder_L"))
+	responderP := newFSChaCha20Poly1305(expand("responder_P"))
+	terminators := expand("garbage_terminators")
+	t.sessionID = expand("session_id")
+	if initiator {
+		t.sendL, t.sendP = initiatorL, initiatorP
+		t.recvL, t.recvP = responderL, responderP
+		t.sendTerminator = terminators[:v2GarbageTerminatorSize]
+		t.recvTerminator = terminators[v2GarbageTerminatorSize:]
+	} else {
+		t.sendL, t.sendP = responderL, responderP
+		t.recvL, t.recvP = initiatorL, initiatorP
+		t.sendTerminator = terminators[v2GarbageTerminatorSize:]
+		t.recvTerminator = terminators[:v2GarbageTerminatorSize]
+	}
+	return nil
+}
+
+// encryptPacket returns the encrypted packet with the passed contents that
+// authenticates the passed associated data.
+func (t *v2Transport) encryptPacket(contents, aad []byte, ignore bool) []byte {
+	packet := make([]byte, v2LengthSize, v2LengthSize+v2HeaderSize+
+		len(contents)+v2TagSize)
+	packet[0] = byte(len(contents))
+	packet[1] = byte(len(contents) >> 8)
+	packet[2] = byte(len(contents) >> 16)
+	t.sendL.crypt(packet, packet)
+
+	plaintext := make([]byte, v2HeaderSize+len(contents))
+	if ignore {
+		plaintext[0] = v2IgnoreBit
+	}
+	copy(plaintext[v2HeaderSize:], contents)
+	return t.sendP.seal(packet, plaintext, aad)
+}
+
+// readPacket reads and decrypts the next packet from the remote peer that
+// authenticates the passed associated data.  It returns the contents of the
+// packet, whether it is a decoy packet that must be ignored and the number of
+// bytes read.
+func (t *v2Transport) readPacket(aad []byte) ([]byte, bool, int, error) {
+	var lenBytes [v2LengthSize]byte
+	n, err := io.ReadFull(t.r, lenBytes[:])
+	if err != nil {
+		return nil, false, n, err
+	}
+	t.recvL.crypt(lenBytes[:], lenBytes[:])
+	contentsLen := int(lenBytes[0]) | int(lenBytes[1])<<8 |
+		int(lenBytes[2])<<16
+	if contentsLen > v2MaxContentsSize {
+		return nil, false, n, fmt.Errorf("v2 packet of %d bytes "+
+			"exceeds the maximum of %d bytes", contentsLen,
+			v2MaxContentsSize)
+	}
+
+	ciphertext := make([]byte, v2HeaderSize+contentsLen+
+		v2TagSize)
+	read, err := io.ReadFull(t.r, ciphertext)
+	n += read
+	if err != nil {
+		return nil, false, n, err
+	}
+	plaintext, err := t.recvP.open(ciphertext[:0], ciphertext, aad)
+	if err != nil {
+		return nil, false, n, errors.New("v2 packet authentication " +
+			"failed")
+	}
+	ignore := plaintext[0]&v2IgnoreBit != 0
+	return plaintext[v2HeaderSize:], ignore, n, nil
+}
+
+// readGarbage reads the garbage sent by the remote peer up to and including
+// the garbage terminator.  It returns the garbage and the number of bytes
+// read.
+func (t *v2Transport) readGarbage() ([]byte, int, error) {
+	buf := make([]byte, 0, v2MaxGarbageSize+v2GarbageTerminatorSize)
+	for {
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return nil, len(buf), err
+		}
+		buf = append(buf, b)
+		garbageLen := len(buf) - v2GarbageTerminatorSize
+		if garbageLen >= 0 &&
+			bytes.Equal(buf[garbageLen:], t.recvTerminator) {
+
+			return buf[:garbageLen], len(buf), nil
+		}
+		if len(buf) == cap(buf) {
+			return nil, len(buf), errors.New("v2 garbage " +
+				"terminator not found")
+		}
+	}
+}
+
+// newV2Transport establishes the v2 transport protocol on the passed
+// connection.  Responders pass the bytes they already read from the initiator
+// to tell the protocols apart in prefix.  It returns the transport along with
+// the number of bytes read, including the prefix, and written.
+//
+// errV2Rejected is returned when an initiator finds the responder closed the
+// connection without sending its public key.
+func newV2Transport(conn io.ReadWriter, net wire.BitcoinNet, initiator bool,
+	prefix []byte) (*v2Transport, int, int, error) {
+
+	t := &v2Transport{
+		r: bufio.NewReader(io.MultiReader(bytes.NewReader(prefix), conn)),
+		w: conn,
+	}
+
+	privKey, pubKey, err := newV2KeyPair(net)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	garbage, err := randomGarbage()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// The initiator sends its public key and garbage first.  The responder
+	// sends them along with the garbage terminator and version packet once
+	// it received the key of the initiator.
+	var nRead, nWritten int
+	if initiator {
+		n, err := conn.Write(append(pubKey, garbage...))
+		nWritten += n
+		if err != nil {
+			return nil, nRead, nWritten, err
+		}
+	}
+
+	remotePubKey := make([]byte, v2KeySize)
+	n, err := io.ReadFull(t.r, remotePubKey)
+	nRead += n
+	if err != nil {
+		if initiator && n == 0 {
+			err = errV2Rejected
+		}
+		return nil, nRead, nWritten, err
+	}
+	err = t.initCiphers(net, initiator, privKey, pubKey, remotePubKey)
+	if err != nil {
+		return nil, nRead, nWritten, err
+	}
+
+	// Send the garbage terminator followed by the version packet, which
+	// authenticates the garbage.  The contents of the version packet are
+	// reserved for future extensions and empty.
+	var out []byte
+	if !initiator {
+		out = append(pubKey, garbage...)
+	}
+	out = append(out, t.sendTerminator...)
+	out = append(out, t.encryptPacket(nil, garbage, false)...)
+	n, err = conn.Write(out)
+	nWritten += n
+	if err != nil {
+		return nil, nRead, nWritten, err
+	}
+
+	// Receive the garbage of the remote peer followed by its version
+	// packet, which may be preceded by decoy packets.  Only the first
+	// packet authenticates the garbage.
+	remoteGarbage, n, err := t.readGarbage()
+	nRead += n
+	if err != nil {
+		return nil, nRead, nWritten, err
+	}
+	aad := remoteGarbage
+	for {
+		_, ignore, n, err := t.readPacket(aad)
+		nRead += n
+		if err != nil {
+			return nil, nRead, nWritten, err
+		}
+		aad = nil
+		if !ignore {
+			break
+		}
+	}
+
+	return t, nRead, nWritten, nil
+}
+
+// readMessage reads the next message from the remote peer skipping any decoy
+// packets.
+//
+// This is part of the transport interface.
+func (t *v2Transport) readMessage(pver uint32,
+	enc wire.MessageEncoding) (int, wire.Message, []byte, error) {
+
+	var totalBytes int
+	for {
+		contents, ignore, n, err := t.readPacket(nil)
+		totalBytes += n
+		if err != nil {
+			return totalBytes, nil, nil, err
+		}
+		if ignore {
+			continue
+		}
+
+		msg, payload, err := wire.DecodeV2Message(contents, pver, enc)
+		return totalBytes, msg, payload, err
+	}
+}
+
+// writeMessage sends the passed message to the remote peer.
+//
+// This is part of the transport interface.
+func (t *v2Transport) writeMessage(msg wire.Message, pver uint32,
+	enc wire.MessageEncoding) (int, error) {
+
+	contents, err := wire.EncodeV2Message(msg, pver, enc)
+	if err != nil {
+		return 0, err
+	}
+	return t.w.Write(t.encryptPacket(contents, nil, false))
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peerauth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	// proofTag is prepended to the v2 transport session id that is signed
+	// by a node to prove it holds the private key of its certificate.
+	proofTag = []byte("btcd peer auth")
+
+	// initiatorTag and responderTag follow the proof tag to bind the proof
+	// to the role of the signer, so a proof can't be reflected back to the
+	// node that created it.
+	initiatorTag = []byte("initiator")
+	responderTag = []byte("responder")
+)
+
+var (
+	// ErrInvalidProof is returned by VerifyAuthMsg when the remote peer
+	// did not sign the session id with the key of its certificate.
+	ErrInvalidProof = errors.New("invalid peer authentication signature")
+
+	// ErrNoSession is returned by AuthMsg and VerifyAuthMsg when no v2
+	// transport session id is passed.  The session id binds the proof to
+	// the encrypted connection, so peers can't be authenticated over the
+	// unencrypted v1 transport where a proof could be relayed by a man in
+	// the middle.
+	ErrNoSession = errors.New("peer authentication requires the v2 " +
+		"transport")
+
+	// ErrRevoked is returned by VerifyAuthMsg when the key of the remote
+	// peer is on the revocation list.
+	ErrRevoked = errors.New("peer key is revoked")
+)
+
+// Config is a descriptor containing the peer authenticator configuration.
+type Config struct {
+	// Authority is the public key of the authority that issues the
+	// certificates of the nodes admitted to the network.
+	Authority *btcec.PublicKey
+
+	// Key is the private key of the local node.
+	Key *btcec.PrivateKey
+
+	// Certificate is the certificate the authority issued for the public
+	// key of the local node.
+	Certificate *Certificate
+
+	// RevocationFile is the path of a file listing the hex-encoded public
+	// keys of nodes that are no longer admitted to the network, one per
+	// line.  Empty lines and lines starting with # are ignored.  It is
+	// optional.
+	RevocationFile string
+
+	// TimeSource returns the current time.  It defaults to time.Now.
+	TimeSource func() time.Time
+}
+
+// Authenticator authenticates the local node to remote peers with its
+// certificate and only admits remote peers that present a valid certificate
+// of the authority that is not revoked.  It implements the peer.Authenticator
+// interface.
+type Authenticator struct {
+	cfg    Config
+	pubKey [wire.PeerAuthPubKeySize]byte
+
+	mtx            sync.RWMutex
+	revoked        map[[wire.PeerAuthPubKeySize]byte]struct{}
+	revocationMod  time.Time
+	revocationSize int64
+}
+
+// Ensure Authenticator implements the peer.Authenticator interface.
+var _ peer.Authenticator = (*Authenticator)(nil)
+
+// proofHash returns the hash that is signed by a node to prove it holds its
+// private key to the remote peer of the v2 transport session with the passed
+// id.  The hash commits to whether the signer initiated the connection.
+func proofHash(sessionID []byte, initiator bool) chainhash.Hash {
+	role := responderTag
+	if initiator {
+		role = initiatorTag
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(proofTag) + len(role) + len(sessionID))
+	buf.Write(proofTag)
+	buf.Write(role)
+	buf.Write(sessionID)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// New returns a new peer authenticator with the passed configuration.  It
+// returns an error if the certificate is not issued by the authority for the
+// key of the local node or the revocation file can't be loaded.
+func New(cfg *Config) (*Authenticator, error) {
+	if cfg.Authority == nil || cfg.Key == nil || cfg.Certificate == nil {
+		return nil, errors.New("peer authentication requires an " +
+			"authority, a key and a certificate")
+	}
+	if !cfg.Certificate.PubKey.IsEqual(cfg.Key.PubKey()) {
+		return nil, errors.New("certificate is not issued for the key")
+	}
+
+	a := &Authenticator{
+		cfg:     *cfg,
+		revoked: make(map[[wire.PeerAuthPubKeySize]byte]struct{}),
+	}
+	if a.cfg.TimeSource == nil {
+		a.cfg.TimeSource = time.Now
+	}
+	if err := cfg.Certificate.Verify(cfg.Authority, a.cfg.TimeSource()); err != nil {
+		return nil, err
+	}
+	copy(a.pubKey[:], cfg.Key.PubKey().SerializeCompressed())
+
+	if _, err := a.ReloadRevocations(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// AuthMsg returns the peerauth message proving the identity of the local node
+// to the remote peer of the v2 transport session with the passed id.
+// Initiator is whether the local node initiated the connection.  ErrNoSession
+// is returned when the session id is empty.
+//
+// This is part of the peer.Authenticator interface.
+func (a *Authenticator) AuthMsg(sessionID []byte,
+	initiator bool) (*wire.MsgPeerAuth, error) {
+
+	if len(sessionID) == 0 {
+		return nil, ErrNoSession
+	}
+	hash := proofHash(sessionID, initiator)
+	sig := ecdsa.Sign(a.cfg.Key, hash[:])
+	cert := a.cfg.Certificate
+	return wire.NewMsgPeerAuth(a.pubKey, unixExpiry(cert.Expiry),
+		cert.Signature, sig.Serialize()), nil
+}
+
+// VerifyAuthMsg returns an error if the passed peerauth message does not carry
+// a valid and unexpired certificate of the authority for a key that is not
+// revoked, or if the message does not sign the passed v2 transport session id
+// and role of the remote peer with the key.  Initiator is whether the remote
+// peer initiated the connection.
+//
+// This is part of the peer.Authenticator interface.
+func (a *Authenticator) VerifyAuthMsg(msg *wire.MsgPeerAuth, sessionID []byte,
+	initiator bool) error {
+
+	if len(sessionID) == 0 {
+		return ErrNoSession
+	}
+	if a.IsRevoked(msg.PubKey[:]) {
+		return ErrRevoked
+	}
+
+	err := verifyCertificate(a.cfg.Authority, msg.PubKey[:], msg.Expiry,
+		msg.CertSignature, a.cfg.TimeSource())
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := btcec.ParsePubKey(msg.PubKey[:])
+	if err != nil {
+		return ErrInvalidProof
+	}
+	sig, err := ecdsa.ParseDERSignature(msg.Signature)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	hash := proofHash(sessionID, initiator)
+	if !sig.Verify(hash[:], pubKey) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// IsRevoked returns whether the passed serialized public key is on the
+// revocation list.
+//
+// This function is safe for concurrent access.
+func (a *Authenticator) IsRevoked(pubKey []byte) bool {
+	if len(pubKey) != wire.PeerAuthPubKeySize {
+		return false
+	}
+	var key [wire.PeerAuthPubKeySize]byte
+	copy(key[:], pubKey)
+
+	a.mtx.RLock()
+	_, ok := a.revoked[key]
+	a.mtx.RUnlock()
+	return ok
+}
+
+// ReloadRevocations loads the revocation file again when it was modified since
+// it was last loaded and returns whether the revocation list changed.  The
+// previous revocation list remains in effect when the file can't be loaded.
+// A revocation file that does not exist is treated as an empty list.
+//
+// This function is safe for concurrent access.
+func (a *Authenticator) ReloadRevocations() (bool, error) {
+	if a.cfg.RevocationFile == "" {
+		return false, nil
+	}
+
+	var modTime time.Time
+	var size int64
+	fi, err := os.Stat(a.cfg.RevocationFile)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return false, err
+	default:
+		modTime, size = fi.ModTime(), fi.Size()
+	}
+
+	a.mtx.RLock()
+	unchanged := modTime.Equal(a.revocationMod) && size == a.revocationSize
+	a.mtx.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	revoked := make(map[[wire.PeerAuthPubKeySize]byte]struct{})
+	if !modTime.IsZero() {
+		revoked, err = readRevocationFile(a.cfg.RevocationFile)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	a.mtx.Lock()
+	changed := len(revoked) != len(a.revoked)
+	for key := range revoked {
+		if _, ok := a.revoked[key]; !ok {
+			changed = true
+		}
+	}
+	a.revoked = revoked
+	a.revocationMod = modTime
+	a.revocationSize = size
+	a.mtx.Unlock()
+	return changed, nil
+}
+
+// readRevocationFile reads the public keys listed in the revocation file at
+// the passed path.
+func readRevocationFile(path string) (map[[wire.PeerAuthPubKeySize]byte]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	revoked := make(map[[wire.PeerAuthPubKeySize]byte]struct{})
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyBytes, err := hex.DecodeString(line)
+		if err == nil {
+			_, err = btcec.ParsePubKey(keyBytes)
+		}
+		if err != nil || len(keyBytes) != wire.PeerAuthPubKeySize {
+			return nil, fmt.Errorf("%s:%d: not a hex-encoded "+
+				"compressed public key", path, lineNum)
+		}
+
+		var key [wire.PeerAuthPubKeySize]byte
+		copy(key[:], keyBytes)
+		revoked[key] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
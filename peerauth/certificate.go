@@ -0,0 +1,156 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peerauth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// certificateTag is prepended to the public key and expiry that are signed by
+// a certificate so the signatures can't be mistaken for transaction or message
+// signatures made with the authority key.
+var certificateTag = []byte("btcd peer certificate")
+
+var (
+	// ErrInvalidCertificate is returned when the signature of a
+	// certificate is not a valid signature of the authority.
+	ErrInvalidCertificate = errors.New("invalid certificate signature")
+
+	// ErrCertificateExpired is returned when a certificate is expired.
+	ErrCertificateExpired = errors.New("certificate expired")
+)
+
+// Certificate is issued by the authority of a permissioned network to admit a
+// node to the network.  It binds the public key of the node to an optional
+// expiry time with a signature of the authority.
+type Certificate struct {
+	// PubKey is the public key of the admitted node.
+	PubKey *btcec.PublicKey
+
+	// Expiry is the time the certificate expires at.  The zero time means
+	// the certificate does not expire.
+	Expiry time.Time
+
+	// Signature is the DER encoded signature of the authority.
+	Signature []byte
+}
+
+// certificateHash returns the hash that is signed by the certificate for the
+// passed serialized public key and unix expiry time.
+func certificateHash(pubKey []byte, expiry int64) chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Grow(len(certificateTag) + len(pubKey) + 8)
+	buf.Write(certificateTag)
+	buf.Write(pubKey)
+	var expiryBytes [8]byte
+	binary.LittleEndian.PutUint64(expiryBytes[:], uint64(expiry))
+	buf.Write(expiryBytes[:])
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// unixExpiry returns the unix time of the passed expiry time, which is zero
+// for certificates that do not expire.
+func unixExpiry(expiry time.Time) int64 {
+	if expiry.IsZero() {
+		return 0
+	}
+	return expiry.Unix()
+}
+
+// expiryTime is the inverse of unixExpiry.
+func expiryTime(expiry int64) time.Time {
+	if expiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(expiry, 0)
+}
+
+// IssueCertificate returns a certificate admitting the node with the passed
+// public key until the passed expiry time signed with the passed authority
+// key.  A zero expiry time issues a certificate that does not expire.
+func IssueCertificate(authorityKey *btcec.PrivateKey, pubKey *btcec.PublicKey,
+	expiry time.Time) *Certificate {
+
+	expiry = expiryTime(unixExpiry(expiry))
+	hash := certificateHash(pubKey.SerializeCompressed(),
+		unixExpiry(expiry))
+	sig := ecdsa.Sign(authorityKey, hash[:])
+	return &Certificate{
+		PubKey:    pubKey,
+		Expiry:    expiry,
+		Signature: sig.Serialize(),
+	}
+}
+
+// Verify returns an error if the certificate is not signed by the passed
+// authority or is expired at the passed time.
+func (c *Certificate) Verify(authority *btcec.PublicKey, now time.Time) error {
+	return verifyCertificate(authority, c.PubKey.SerializeCompressed(),
+		unixExpiry(c.Expiry), c.Signature, now)
+}
+
+// verifyCertificate returns an error if the passed signature is not a valid
+// certificate signature of the authority for the passed serialized public key
+// and unix expiry time, or if the certificate is expired at the passed time.
+func verifyCertificate(authority *btcec.PublicKey, pubKey []byte,
+	expiry int64, signature []byte, now time.Time) error {
+
+	sig, err := ecdsa.ParseDERSignature(signature)
+	if err != nil {
+		return ErrInvalidCertificate
+	}
+	hash := certificateHash(pubKey, expiry)
+	if !sig.Verify(hash[:], authority) {
+		return ErrInvalidCertificate
+	}
+	if expiry != 0 && now.Unix() >= expiry {
+		return ErrCertificateExpired
+	}
+	return nil
+}
+
+// Serialize returns the serialized certificate, which consists of the
+// compressed public key, the little-endian unix expiry time and the signature.
+func (c *Certificate) Serialize() []byte {
+	buf := make([]byte, 0, wire.PeerAuthPubKeySize+8+len(c.Signature))
+	buf = append(buf, c.PubKey.SerializeCompressed()...)
+	var expiry [8]byte
+	binary.LittleEndian.PutUint64(expiry[:], uint64(unixExpiry(c.Expiry)))
+	buf = append(buf, expiry[:]...)
+	return append(buf, c.Signature...)
+}
+
+// ParseCertificate parses a certificate serialized with Serialize.  The
+// signature is not verified.
+func ParseCertificate(serialized []byte) (*Certificate, error) {
+	const minSize = wire.PeerAuthPubKeySize + 8
+	if len(serialized) <= minSize ||
+		len(serialized) > minSize+wire.MaxPeerAuthSignatureSize {
+
+		return nil, fmt.Errorf("malformed certificate of %d bytes",
+			len(serialized))
+	}
+
+	pubKey, err := btcec.ParsePubKey(serialized[:wire.PeerAuthPubKeySize])
+	if err != nil {
+		return nil, fmt.Errorf("malformed certificate public key: %w",
+			err)
+	}
+	expiry := binary.LittleEndian.Uint64(serialized[wire.PeerAuthPubKeySize:])
+	return &Certificate{
+		PubKey:    pubKey,
+		Expiry:    expiryTime(int64(expiry)),
+		Signature: append([]byte(nil), serialized[minSize:]...),
+	}, nil
+}
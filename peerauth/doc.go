@@ -0,0 +1,21 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package peerauth implements signed peer admission for permissioned networks.
+
+An authority, identified by its public key, admits nodes to the network by
+issuing certificates that bind the public key of a node to an optional expiry
+time.  During the version handshake each node sends a peerauth wire message
+with its certificate and a signature of the session id of the encrypted v2
+transport (BIP0324) and its role in the connection, which proves it holds the
+private key of the certificate.  Since the session id is only known to the two
+ends of the encrypted connection, a proof can't be relayed to another peer by a
+man in the middle, so peers are never authenticated over the v1 transport.
+Peers that don't present a valid certificate of the authority are disconnected.
+
+Certificates can be withdrawn before they expire by listing the public keys of
+the nodes in a revocation file, which is reloaded when it changes.
+*/
+package peerauth
@@ -0,0 +1,197 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peerauth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// newKey returns a new private key and fails the test on error.
+func newKey(t *testing.T) *btcec.PrivateKey {
+	t.Helper()
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	return key
+}
+
+// TestCertificate ensures certificates are verified and serialized as
+// expected.
+func TestCertificate(t *testing.T) {
+	authority := newKey(t)
+	node := newKey(t)
+	now := time.Unix(1700000000, 0)
+
+	cert := IssueCertificate(authority, node.PubKey(), now.Add(time.Hour))
+	if err := cert.Verify(authority.PubKey(), now); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+	err := cert.Verify(authority.PubKey(), now.Add(time.Hour))
+	if err != ErrCertificateExpired {
+		t.Fatalf("Verify: got %v, want %v", err, ErrCertificateExpired)
+	}
+	err = cert.Verify(newKey(t).PubKey(), now)
+	if err != ErrInvalidCertificate {
+		t.Fatalf("Verify: got %v, want %v", err, ErrInvalidCertificate)
+	}
+
+	// Certificates without expiry never expire.
+	forever := IssueCertificate(authority, node.PubKey(), time.Time{})
+	err = forever.Verify(authority.PubKey(), now.AddDate(100, 0, 0))
+	if err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+
+	for _, c := range []*Certificate{cert, forever} {
+		parsed, err := ParseCertificate(c.Serialize())
+		if err != nil {
+			t.Fatalf("ParseCertificate: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(parsed.Serialize(), c.Serialize()) ||
+			!parsed.Expiry.Equal(c.Expiry) {
+
+			t.Fatalf("ParseCertificate: got %x, want %x",
+				parsed.Serialize(), c.Serialize())
+		}
+	}
+	if _, err := ParseCertificate(cert.Serialize()[:41]); err == nil {
+		t.Fatal("ParseCertificate: did not fail for truncated " +
+			"certificate")
+	}
+}
+
+// TestAuthenticator ensures authenticators only admit peers with a valid
+// certificate that prove they hold its key and are not revoked.
+func TestAuthenticator(t *testing.T) {
+	authority := newKey(t)
+	key1, key2 := newKey(t), newKey(t)
+	revocationFile := filepath.Join(t.TempDir(), "revoked")
+
+	auth1, err := New(&Config{
+		Authority:      authority.PubKey(),
+		Key:            key1,
+		Certificate:    IssueCertificate(authority, key1.PubKey(), time.Time{}),
+		RevocationFile: revocationFile,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	auth2, err := New(&Config{
+		Authority:   authority.PubKey(),
+		Key:         key2,
+		Certificate: IssueCertificate(authority, key2.PubKey(), time.Time{}),
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	// Certificates that are not issued for the key or by the authority
+	// must be rejected.
+	_, err = New(&Config{
+		Authority:   authority.PubKey(),
+		Key:         key1,
+		Certificate: IssueCertificate(authority, key2.PubKey(), time.Time{}),
+	})
+	if err == nil {
+		t.Fatal("New: did not fail for certificate of another key")
+	}
+	_, err = New(&Config{
+		Authority:   authority.PubKey(),
+		Key:         key1,
+		Certificate: IssueCertificate(key1, key1.PubKey(), time.Time{}),
+	})
+	if err != ErrInvalidCertificate {
+		t.Fatalf("New: got %v, want %v", err, ErrInvalidCertificate)
+	}
+
+	sessionID := bytes.Repeat([]byte{0x01}, 32)
+	otherSessionID := bytes.Repeat([]byte{0x02}, 32)
+	msg, err := auth2.AuthMsg(sessionID, true)
+	if err != nil {
+		t.Fatalf("AuthMsg: unexpected error: %v", err)
+	}
+	if err := auth1.VerifyAuthMsg(msg, sessionID, true); err != nil {
+		t.Fatalf("VerifyAuthMsg: unexpected error: %v", err)
+	}
+
+	// The proof must not be accepted for another session, for the other
+	// role or without a session.
+	err = auth1.VerifyAuthMsg(msg, otherSessionID, true)
+	if err != ErrInvalidProof {
+		t.Fatalf("VerifyAuthMsg: got %v, want %v", err, ErrInvalidProof)
+	}
+	err = auth1.VerifyAuthMsg(msg, sessionID, false)
+	if err != ErrInvalidProof {
+		t.Fatalf("VerifyAuthMsg: got %v, want %v", err, ErrInvalidProof)
+	}
+	if err := auth1.VerifyAuthMsg(msg, nil, true); err != ErrNoSession {
+		t.Fatalf("VerifyAuthMsg: got %v, want %v", err, ErrNoSession)
+	}
+	if _, err := auth2.AuthMsg(nil, true); err != ErrNoSession {
+		t.Fatalf("AuthMsg: got %v, want %v", err, ErrNoSession)
+	}
+
+	// Tampering with the expiry must invalidate the certificate.
+	tampered := *msg
+	tampered.Expiry = 1
+	err = auth1.VerifyAuthMsg(&tampered, sessionID, true)
+	if err != ErrInvalidCertificate {
+		t.Fatalf("VerifyAuthMsg: got %v, want %v", err,
+			ErrInvalidCertificate)
+	}
+
+	// Revoke the key and ensure the peer is no longer admitted.
+	contents := "# revoked nodes\n\n" +
+		hex.EncodeToString(key2.PubKey().SerializeCompressed()) + "\n"
+	if err := os.WriteFile(revocationFile, []byte(contents), 0600); err != nil {
+		t.Fatalf("unable to write revocation file: %v", err)
+	}
+	changed, err := auth1.ReloadRevocations()
+	if err != nil || !changed {
+		t.Fatalf("ReloadRevocations: got %v, %v, want true, nil",
+			changed, err)
+	}
+	if err := auth1.VerifyAuthMsg(msg, sessionID, true); err != ErrRevoked {
+		t.Fatalf("VerifyAuthMsg: got %v, want %v", err, ErrRevoked)
+	}
+	changed, err = auth1.ReloadRevocations()
+	if err != nil || changed {
+		t.Fatalf("ReloadRevocations: got %v, %v, want false, nil",
+			changed, err)
+	}
+
+	// An invalid revocation file must keep the previous list in effect.
+	if err := os.WriteFile(revocationFile, []byte("junk\n"), 0600); err != nil {
+		t.Fatalf("unable to write revocation file: %v", err)
+	}
+	if _, err := auth1.ReloadRevocations(); err == nil {
+		t.Fatal("ReloadRevocations: did not fail for invalid file")
+	}
+	if !auth1.IsRevoked(key2.PubKey().SerializeCompressed()) {
+		t.Fatal("IsRevoked: revocation lost after invalid file")
+	}
+
+	// Removing the file clears the revocations.
+	if err := os.Remove(revocationFile); err != nil {
+		t.Fatalf("unable to remove revocation file: %v", err)
+	}
+	changed, err = auth1.ReloadRevocations()
+	if err != nil || !changed {
+		t.Fatalf("ReloadRevocations: got %v, %v, want true, nil",
+			changed, err)
+	}
+	if err := auth1.VerifyAuthMsg(msg, sessionID, true); err != nil {
+		t.Fatalf("VerifyAuthMsg: unexpected error: %v", err)
+	}
+}
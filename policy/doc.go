@@ -0,0 +1,29 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package policy implements the standardness rules which transactions must follow
+to be accepted into the memory pool and relayed.
+
+Standardness is a local relay policy and is unrelated to consensus: a
+transaction which is not standard can still be included in a valid block.  The
+rules help provide fair use of the system to all users and protect nodes from
+transactions which are expensive to process, while also preserving the ability
+to deploy new script features through soft forks.
+
+The limits enforced by the standardness checks are held by a Rules struct, which
+starts out with the defaults of the network and whose individual settings can be
+adjusted by name, such as from the configuration of the node, via the table of
+settings returned by Settings.  For example, the following allows larger data
+carrier (OP_RETURN) outputs:
+
+	rules := policy.DefaultRules()
+	err := rules.Set("datacarriersize", "1000")
+
+# Errors
+
+The checks return an Error, which identifies the specific Rule a transaction
+violated along with the reject code to send to the peer that relayed it.
+*/
+package policy
@@ -0,0 +1,94 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Rule identifies the standardness rule a transaction violated.  The rules are
+// named like the reject reasons of the reference implementation where one
+// exists.
+type Rule string
+
+// These constants are used to identify a specific Rule.
+const (
+	// RuleVersion indicates the transaction version is not in the range
+	// of versions which are relayed.
+	RuleVersion Rule = "version"
+
+	// RuleNonFinal indicates the transaction is not finalized.
+	RuleNonFinal Rule = "non-final"
+
+	// RuleTxSize indicates the weight of the transaction exceeds the
+	// maximum standard weight.
+	RuleTxSize Rule = "tx-size"
+
+	// RuleSigScriptSize indicates a signature script exceeds the maximum
+	// standard size.
+	RuleSigScriptSize Rule = "scriptsig-size"
+
+	// RuleSigScriptPushOnly indicates a signature script contains opcodes
+	// which don't push data.
+	RuleSigScriptPushOnly Rule = "scriptsig-not-pushonly"
+
+	// RuleScriptPubKey indicates an output script is not of a standard
+	// form.
+	RuleScriptPubKey Rule = "scriptpubkey"
+
+	// RuleBareMultiSig indicates an output script is a bare multisig
+	// script while those are not relayed.
+	RuleBareMultiSig Rule = "bare-multisig"
+
+	// RuleDust indicates an output pays an amount which costs more to
+	// spend than it is worth.
+	RuleDust Rule = "dust"
+
+	// RuleDataCarrier indicates an output is a data carrier (OP_RETURN)
+	// output while those are not relayed.
+	RuleDataCarrier Rule = "datacarrier"
+
+	// RuleDataCarrierSize indicates a data carrier output script exceeds
+	// the maximum size.
+	RuleDataCarrierSize Rule = "datacarrier-size"
+
+	// RuleMultiOpReturn indicates the transaction has more data carrier
+	// outputs than allowed.
+	RuleMultiOpReturn Rule = "multi-op-return"
+
+	// RuleP2SHSigOps indicates an input spending a pay-to-script-hash
+	// output has more signature operations than allowed.
+	RuleP2SHSigOps Rule = "p2sh-sigops"
+
+	// RuleInputScript indicates an input spends an output whose script is
+	// not of a standard form.
+	RuleInputScript Rule = "nonstandard-input"
+
+	// RuleMinRelayFee indicates the transaction doesn't pay the minimum
+	// fee required to be relayed.
+	RuleMinRelayFee Rule = "min-relay-fee"
+)
+
+// Error identifies a violation of a standardness rule.  The caller can use
+// type assertions to determine the specific Rule that was violated and the
+// RejectCode to send with reject messages.
+type Error struct {
+	Rule        Rule            // The violated rule
+	RejectCode  wire.RejectCode // The code to send with reject messages
+	Description string          // Human readable description of the issue
+}
+
+// Error satisfies the error interface and prints human-readable errors which
+// include the violated rule.
+func (e Error) Error() string {
+	return fmt.Sprintf("%s (policy rule %s)", e.Description, e.Rule)
+}
+
+// NewError creates an Error given a set of arguments.
+func NewError(rule Rule, c wire.RejectCode, desc string) Error {
+	return Error{Rule: rule, RejectCode: c, Description: desc}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// DefaultMinRelayTxFee is the minimum fee in satoshi that is required
+	// for a transaction to be treated as free for relay and mining
+	// purposes.  It is also used to help determine if a transaction is
+	// considered dust and as a base for calculating minimum required fees
+	// for larger transactions.  This value is in Satoshi/1000 bytes.
+	DefaultMinRelayTxFee = btcutil.Amount(1000)
+
+	// DefaultMaxTxVersion is the default highest transaction version which
+	// is considered standard.
+	DefaultMaxTxVersion = 2
+
+	// DefaultMaxTxWeight is the default max weight permitted by any
+	// transaction.
+	DefaultMaxTxWeight = 400000
+
+	// DefaultMaxSigScriptSize is the default maximum size allowed for a
+	// transaction input signature script to be considered standard.  This
+	// value allows for a 15-of-15 CHECKMULTISIG pay-to-script-hash with
+	// compressed keys.
+	//
+	// The form of the overall script is: OP_0 <15 signatures> OP_PUSHDATA2
+	// <2 bytes len> [OP_15 <15 pubkeys> OP_15 OP_CHECKMULTISIG]
+	//
+	// For the p2sh script portion, each of the 15 compressed pubkeys are
+	// 33 bytes (plus one for the OP_DATA_33 opcode), and the thus it totals
+	// to (15*34)+3 = 513 bytes.  Next, each of the 15 signatures is a max
+	// of 73 bytes (plus one for the OP_DATA_73 opcode).  Also, there is one
+	// extra byte for the initial extra OP_0 push and 3 bytes for the
+	// OP_PUSHDATA2 needed to specify the 513 bytes for the script push.
+	// That brings the total to 1+(15*74)+3+513 = 1627.  This value also
+	// adds a few extra bytes to provide a little buffer.
+	// (1 + 15*74 + 3) + (15*34 + 3) + 23 = 1650
+	DefaultMaxSigScriptSize = 1650
+
+	// DefaultMaxP2SHSigOps is the default maximum number of signature
+	// operations that are considered standard in a pay-to-script-hash
+	// script.
+	DefaultMaxP2SHSigOps = 15
+
+	// DefaultMaxMultiSigKeys is the default maximum number of public keys
+	// allowed in a multi-signature transaction output script for it to be
+	// considered standard.
+	DefaultMaxMultiSigKeys = 3
+
+	// DefaultMaxDataCarrierSize is the default maximum size of a data
+	// carrier output script, which allows for OP_RETURN followed by a push
+	// of txscript.MaxDataCarrierSize bytes.
+	DefaultMaxDataCarrierSize = txscript.MaxDataCarrierSize + 3
+
+	// DefaultMaxDataCarrierOutputs is the default maximum number of data
+	// carrier outputs in a standard transaction.
+	DefaultMaxDataCarrierOutputs = 1
+)
+
+// Rules houses the limits enforced by the standardness checks.  The zero
+// value isn't useful, so DefaultRules should be used to create them.
+type Rules struct {
+	// MaxTxVersion is the highest transaction version which is considered
+	// standard.
+	MaxTxVersion int32
+
+	// MaxTxWeight is the maximum weight of a standard transaction.
+	MaxTxWeight int64
+
+	// MaxSigScriptSize is the maximum size of a standard signature script.
+	MaxSigScriptSize int
+
+	// MaxP2SHSigOps is the maximum number of signature operations of the
+	// inputs spending pay-to-script-hash outputs.
+	MaxP2SHSigOps int
+
+	// MaxMultiSigKeys is the maximum number of public keys of a standard
+	// bare multi-signature output script.
+	MaxMultiSigKeys int
+
+	// PermitBareMultiSig defines whether bare multi-signature output
+	// scripts are standard.
+	PermitBareMultiSig bool
+
+	// DataCarrier defines whether data carrier (OP_RETURN) outputs are
+	// standard.
+	DataCarrier bool
+
+	// MaxDataCarrierSize is the maximum size of a data carrier output
+	// script, including the OP_RETURN opcode and the push opcodes.
+	MaxDataCarrierSize int
+
+	// MaxDataCarrierOutputs is the maximum number of data carrier outputs
+	// of a standard transaction.
+	MaxDataCarrierOutputs int
+
+	// DustRelayFee is the fee rate in satoshi/kB used to determine whether
+	// outputs are dust.  When zero, the minimum relay fee of the caller is
+	// used instead.
+	DustRelayFee btcutil.Amount
+}
+
+// DefaultRules returns the default standardness rules.
+func DefaultRules() Rules {
+	return Rules{
+		MaxTxVersion:          DefaultMaxTxVersion,
+		MaxTxWeight:           DefaultMaxTxWeight,
+		MaxSigScriptSize:      DefaultMaxSigScriptSize,
+		MaxP2SHSigOps:         DefaultMaxP2SHSigOps,
+		MaxMultiSigKeys:       DefaultMaxMultiSigKeys,
+		PermitBareMultiSig:    true,
+		DataCarrier:           true,
+		MaxDataCarrierSize:    DefaultMaxDataCarrierSize,
+		MaxDataCarrierOutputs: DefaultMaxDataCarrierOutputs,
+	}
+}
+
+// dustRelayFee returns the fee rate used to determine whether outputs are
+// dust given the minimum relay fee.
+func (r *Rules) dustRelayFee(minRelayTxFee btcutil.Amount) btcutil.Amount {
+	if r.DustRelayFee != 0 {
+		return r.DustRelayFee
+	}
+	return minRelayTxFee
+}
+
+// Setting describes a setting of the standardness rules which can be changed
+// by name.
+type Setting struct {
+	// Name is the name of the setting.
+	Name string
+
+	// Description is a human readable description of the setting.
+	Description string
+
+	// set parses the value and changes the setting of the rules.
+	set func(r *Rules, value string) error
+}
+
+// parseInt parses the value of an integer setting which must be within the
+// passed range.
+func parseInt(value string, min, max int64) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%d is not in the valid range of %d-%d",
+			n, min, max)
+	}
+	return n, nil
+}
+
+// settings is the table of the settings which can be changed by name.
+var settings = []Setting{{
+	Name:        "maxtxversion",
+	Description: "Highest standard transaction version",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 1, 1<<31-1)
+		r.MaxTxVersion = int32(n)
+		return err
+	},
+}, {
+	Name:        "maxtxweight",
+	Description: "Maximum weight of a standard transaction",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 0, 1<<62)
+		r.MaxTxWeight = n
+		return err
+	},
+}, {
+	Name:        "maxsigscriptsize",
+	Description: "Maximum size in bytes of a standard signature script",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 0, 1<<31-1)
+		r.MaxSigScriptSize = int(n)
+		return err
+	},
+}, {
+	Name: "maxp2shsigops",
+	Description: "Maximum number of signature operations of a " +
+		"pay-to-script-hash input",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 0, 1<<31-1)
+		r.MaxP2SHSigOps = int(n)
+		return err
+	},
+}, {
+	Name: "maxmultisigkeys",
+	Description: "Maximum number of public keys of a bare multisig " +
+		"output",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 1, txscript.MaxPubKeysPerMultiSig)
+		r.MaxMultiSigKeys = int(n)
+		return err
+	},
+}, {
+	Name:        "permitbaremultisig",
+	Description: "Whether bare multisig outputs are standard",
+	set: func(r *Rules, value string) error {
+		b, err := strconv.ParseBool(value)
+		r.PermitBareMultiSig = b
+		return err
+	},
+}, {
+	Name:        "datacarrier",
+	Description: "Whether data carrier (OP_RETURN) outputs are standard",
+	set: func(r *Rules, value string) error {
+		b, err := strconv.ParseBool(value)
+		r.DataCarrier = b
+		return err
+	},
+}, {
+	Name: "datacarriersize",
+	Description: "Maximum size in bytes of a data carrier output " +
+		"script",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 1, txscript.MaxScriptSize)
+		r.MaxDataCarrierSize = int(n)
+		return err
+	},
+}, {
+	Name: "maxdatacarrieroutputs",
+	Description: "Maximum number of data carrier outputs of a " +
+		"standard transaction",
+	set: func(r *Rules, value string) error {
+		n, err := parseInt(value, 0, 1<<31-1)
+		r.MaxDataCarrierOutputs = int(n)
+		return err
+	},
+}, {
+	Name: "dustrelayfee",
+	Description: "Fee rate in BTC/kB used to determine whether outputs " +
+		"are dust, or 0 to use the minimum relay fee",
+	set: func(r *Rules, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		amt, err := btcutil.NewAmount(f)
+		if err != nil {
+			return err
+		}
+		if amt < 0 {
+			return fmt.Errorf("negative fee rate %v", amt)
+		}
+		r.DustRelayFee = amt
+		return nil
+	},
+}}
+
+// Settings returns the settings of the standardness rules which can be changed
+// by name.
+func Settings() []Setting {
+	s := make([]Setting, len(settings))
+	copy(s, settings)
+	return s
+}
+
+// Set parses the passed value and changes the named setting of the rules to
+// it.  The rules are left unchanged when an error is returned.
+func (r *Rules) Set(name, value string) error {
+	for _, s := range settings {
+		if s.Name != name {
+			continue
+		}
+
+		rules := *r
+		if err := s.set(&rules, value); err != nil {
+			return fmt.Errorf("invalid value %q for policy setting "+
+				"%s: %v", value, name, err)
+		}
+		*r = rules
+		return nil
+	}
+
+	return fmt.Errorf("unknown policy setting %q", name)
+}
+
+// Apply changes a setting of the rules given in the form name=value.
+func (r *Rules) Apply(setting string) error {
+	i := strings.IndexByte(setting, '=')
+	if i == -1 {
+		return fmt.Errorf("policy setting %q is not in the form "+
+			"name=value", setting)
+	}
+	name := strings.ToLower(strings.TrimSpace(setting[:i]))
+	return r.Set(name, strings.TrimSpace(setting[i+1:]))
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestRulesSet ensures the settings of the rules are parsed and changed as
+// expected, and that the rules are left unchanged when a setting is invalid.
+func TestRulesSet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		setting string
+		valid   bool
+		check   func(r *Rules) bool
+	}{{
+		setting: "maxtxversion=3",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.MaxTxVersion == 3 },
+	}, {
+		setting: "maxtxweight=100000",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.MaxTxWeight == 100000 },
+	}, {
+		setting: "maxsigscriptsize=100",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.MaxSigScriptSize == 100 },
+	}, {
+		setting: "maxp2shsigops=20",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.MaxP2SHSigOps == 20 },
+	}, {
+		setting: "maxmultisigkeys=20",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.MaxMultiSigKeys == 20 },
+	}, {
+		setting: " PermitBareMultiSig = false ",
+		valid:   true,
+		check:   func(r *Rules) bool { return !r.PermitBareMultiSig },
+	}, {
+		setting: "datacarrier=0",
+		valid:   true,
+		check:   func(r *Rules) bool { return !r.DataCarrier },
+	}, {
+		setting: "datacarriersize=1000",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.MaxDataCarrierSize == 1000 },
+	}, {
+		setting: "maxdatacarrieroutputs=3",
+		valid:   true,
+		check: func(r *Rules) bool {
+			return r.MaxDataCarrierOutputs == 3
+		},
+	}, {
+		setting: "dustrelayfee=0.00003",
+		valid:   true,
+		check:   func(r *Rules) bool { return r.DustRelayFee == 3000 },
+	}, {
+		setting: "maxtxversion=0",
+	}, {
+		setting: "maxtxweight=-1",
+	}, {
+		setting: "maxmultisigkeys=21",
+	}, {
+		setting: "datacarrier=maybe",
+	}, {
+		setting: "datacarriersize=0",
+	}, {
+		setting: "dustrelayfee=-1",
+	}, {
+		setting: "dustrelayfee=fee",
+	}, {
+		setting: "unknown=1",
+	}, {
+		setting: "maxtxversion",
+	}}
+
+	for _, test := range tests {
+		rules := DefaultRules()
+		err := rules.Apply(test.setting)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%q: unexpected success", test.setting)
+			}
+			if rules != DefaultRules() {
+				t.Errorf("%q: rules changed by invalid setting",
+					test.setting)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.setting, err)
+			continue
+		}
+		if !test.check(&rules) {
+			t.Errorf("%q: setting not applied: %+v", test.setting,
+				rules)
+		}
+	}
+}
+
+// TestSettings ensures all of the settings have a unique name and a
+// description.
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	names := make(map[string]struct{})
+	for _, s := range Settings() {
+		if s.Name == "" || s.Description == "" || s.set == nil {
+			t.Errorf("incomplete setting %q", s.Name)
+		}
+		if _, ok := names[s.Name]; ok {
+			t.Errorf("duplicate setting %q", s.Name)
+		}
+		names[s.Name] = struct{}{}
+	}
+}
+
+// TestCheckTransactionStandardRules ensures the checks of the standardness of
+// transactions follow the configured rules.
+func TestCheckTransactionStandardRules(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	multiSigScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_1).
+		AddData(privKey.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_1).
+		AddOp(txscript.OP_CHECKMULTISIG).
+		Script()
+	if err != nil {
+		t.Fatalf("NewScriptBuilder: unexpected error: %v", err)
+	}
+	nullData := func(size int) []byte {
+		script, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_RETURN).
+			AddFullData(bytes.Repeat([]byte{0x01}, size)).
+			Script()
+		if err != nil {
+			t.Fatalf("NewScriptBuilder: unexpected error: %v", err)
+		}
+		return script
+	}
+	p2wpkhScript := append([]byte{txscript.OP_0, txscript.OP_DATA_20},
+		make([]byte, 20)...)
+
+	tests := []struct {
+		name     string
+		settings []string
+		outputs  []*wire.TxOut
+		rule     Rule
+	}{{
+		name:    "large data carrier output",
+		outputs: []*wire.TxOut{{PkScript: nullData(200)}},
+		rule:    RuleDataCarrierSize,
+	}, {
+		name:     "large data carrier output with larger limit",
+		settings: []string{"datacarriersize=204"},
+		outputs:  []*wire.TxOut{{PkScript: nullData(200)}},
+	}, {
+		name:     "data carrier output with data carrier disabled",
+		settings: []string{"datacarrier=0"},
+		outputs:  []*wire.TxOut{{PkScript: nullData(10)}},
+		rule:     RuleDataCarrier,
+	}, {
+		name: "two data carrier outputs",
+		outputs: []*wire.TxOut{
+			{PkScript: nullData(10)}, {PkScript: nullData(10)},
+		},
+		rule: RuleMultiOpReturn,
+	}, {
+		name:     "two data carrier outputs with larger limit",
+		settings: []string{"maxdatacarrieroutputs=2"},
+		outputs: []*wire.TxOut{
+			{PkScript: nullData(10)}, {PkScript: nullData(10)},
+		},
+	}, {
+		name:    "bare multisig output",
+		outputs: []*wire.TxOut{{Value: 10000, PkScript: multiSigScript}},
+	}, {
+		name:     "bare multisig output with bare multisig disabled",
+		settings: []string{"permitbaremultisig=0"},
+		outputs:  []*wire.TxOut{{Value: 10000, PkScript: multiSigScript}},
+		rule:     RuleBareMultiSig,
+	}, {
+		name:    "output above the dust limit",
+		outputs: []*wire.TxOut{{Value: 500, PkScript: p2wpkhScript}},
+	}, {
+		name:     "output below the dust limit of the dust relay fee",
+		settings: []string{"dustrelayfee=0.00003"},
+		outputs:  []*wire.TxOut{{Value: 500, PkScript: p2wpkhScript}},
+		rule:     RuleDust,
+	}}
+
+	for _, test := range tests {
+		rules := DefaultRules()
+		for _, setting := range test.settings {
+			if err := rules.Apply(setting); err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+		}
+
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			SignatureScript: []byte{txscript.OP_0},
+			Sequence:        wire.MaxTxInSequenceNum,
+		})
+		tx.TxOut = test.outputs
+
+		err := CheckTransactionStandard(btcutil.NewTx(tx), 300000,
+			time.Now(), DefaultMinRelayTxFee, &rules)
+		if test.rule == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+
+		perr, ok := err.(Error)
+		if !ok {
+			t.Errorf("%s: unexpected error type - got %T", test.name,
+				err)
+			continue
+		}
+		if perr.Rule != test.rule {
+			t.Errorf("%s: unexpected rule - got %v, want %v",
+				test.name, perr.Rule, test.rule)
+		}
+	}
+}
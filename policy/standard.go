@@ -0,0 +1,385 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// CalcMinRequiredTxRelayFee returns the minimum transaction fee required for a
+// transaction with the passed serialized size to be accepted into the memory
+// pool and relayed.
+func CalcMinRequiredTxRelayFee(serializedSize int64, minRelayTxFee btcutil.Amount) int64 {
+	// Calculate the minimum fee for a transaction to be allowed into the
+	// mempool and relayed by scaling the base fee (which is the minimum
+	// free transaction relay fee).  minRelayTxFee is in Satoshi/kB so
+	// multiply by serializedSize (which is in bytes) and divide by 1000 to
+	// get minimum Satoshis.
+	minFee := (serializedSize * int64(minRelayTxFee)) / 1000
+
+	if minFee == 0 && minRelayTxFee > 0 {
+		minFee = int64(minRelayTxFee)
+	}
+
+	// Set the minimum fee to the maximum possible value if the calculated
+	// fee is not in the valid range for monetary amounts.
+	if minFee < 0 || minFee > btcutil.MaxSatoshi {
+		minFee = btcutil.MaxSatoshi
+	}
+
+	return minFee
+}
+
+// CheckInputsStandard performs a series of checks on a transaction's inputs
+// to ensure they are "standard".  A standard transaction input within the
+// context of this function is one whose referenced public key script is of a
+// standard form and, for pay-to-script-hash, does not have more than
+// the maximum number of signature operations of the rules.  However, it should also be noted
+// that standard inputs also are those which have a clean stack after execution
+// and only contain pushed data in their signature scripts.  This function does
+// not perform those checks because the script engine already does this more
+// accurately and concisely via the txscript.ScriptVerifyCleanStack and
+// txscript.ScriptVerifySigPushOnly flags.
+func CheckInputsStandard(tx *btcutil.Tx, utxoView *blockchain.UtxoViewpoint,
+	rules *Rules) error {
+
+	// NOTE: The reference implementation also does a coinbase check here,
+	// but coinbases have already been rejected prior to calling this
+	// function so no need to recheck.
+
+	for i, txIn := range tx.MsgTx().TxIn {
+		// It is safe to elide existence and index checks here since
+		// they have already been checked prior to calling this
+		// function.
+		entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		originPkScript := entry.PkScript()
+		switch txscript.GetScriptClass(originPkScript) {
+		case txscript.ScriptHashTy:
+			numSigOps := txscript.GetPreciseSigOpCount(
+				txIn.SignatureScript, originPkScript, true)
+			if numSigOps > rules.MaxP2SHSigOps {
+				str := fmt.Sprintf("transaction input #%d has "+
+					"%d signature operations which is more "+
+					"than the allowed max amount of %d",
+					i, numSigOps, rules.MaxP2SHSigOps)
+				return NewError(RuleP2SHSigOps,
+					wire.RejectNonstandard, str)
+			}
+
+		case txscript.NonStandardTy:
+			str := fmt.Sprintf("transaction input #%d has a "+
+				"non-standard script form", i)
+			return NewError(RuleInputScript, wire.RejectNonstandard,
+				str)
+		}
+	}
+
+	return nil
+}
+
+// checkPkScriptStandard performs a series of checks on a transaction output
+// script (public key script) to ensure it is a "standard" public key script.
+// A standard public key script is one that is a recognized form, and for
+// multi-signature scripts, only contains from 1 to the maximum number of
+// public keys of the rules.
+func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass,
+	rules *Rules) error {
+
+	switch scriptClass {
+	case txscript.MultiSigTy:
+		numPubKeys, numSigs, err := txscript.CalcMultiSigStats(pkScript)
+		if err != nil {
+			str := fmt.Sprintf("multi-signature script parse "+
+				"failure: %v", err)
+			return NewError(RuleScriptPubKey,
+				wire.RejectNonstandard, str)
+		}
+
+		// A standard multi-signature public key script must contain
+		// from 1 to the maximum number of public keys of the rules.
+		if numPubKeys < 1 {
+			str := "multi-signature script with no pubkeys"
+			return NewError(RuleScriptPubKey,
+				wire.RejectNonstandard, str)
+		}
+		if numPubKeys > rules.MaxMultiSigKeys {
+			str := fmt.Sprintf("multi-signature script with %d "+
+				"public keys which is more than the allowed "+
+				"max of %d", numPubKeys, rules.MaxMultiSigKeys)
+			return NewError(RuleScriptPubKey,
+				wire.RejectNonstandard, str)
+		}
+
+		// A standard multi-signature public key script must have at
+		// least 1 signature and no more signatures than available
+		// public keys.
+		if numSigs < 1 {
+			return NewError(RuleScriptPubKey, wire.RejectNonstandard,
+				"multi-signature script with no signatures")
+		}
+		if numSigs > numPubKeys {
+			str := fmt.Sprintf("multi-signature script with %d "+
+				"signatures which is more than the available "+
+				"%d public keys", numSigs, numPubKeys)
+			return NewError(RuleScriptPubKey,
+				wire.RejectNonstandard, str)
+		}
+
+	case txscript.NonStandardTy:
+		return NewError(RuleScriptPubKey, wire.RejectNonstandard,
+			"non-standard script form")
+	}
+
+	return nil
+}
+
+// GetDustThreshold calculates the dust limit for a *wire.TxOut by taking the
+// size of a typical spending transaction and multiplying it by 3 to account
+// for the minimum dust relay fee of 3000sat/kvb.
+func GetDustThreshold(txOut *wire.TxOut) int64 {
+	// The total serialized size consists of the output and the associated
+	// input script to redeem it.  Since there is no input script
+	// to redeem it yet, use the minimum size of a typical input script.
+	//
+	// Pay-to-pubkey-hash bytes breakdown:
+	//
+	//  Output to hash (34 bytes):
+	//   8 value, 1 script len, 25 script [1 OP_DUP, 1 OP_HASH_160,
+	//   1 OP_DATA_20, 20 hash, 1 OP_EQUALVERIFY, 1 OP_CHECKSIG]
+	//
+	//  Input with compressed pubkey (148 bytes):
+	//   36 prev outpoint, 1 script len, 107 script [1 OP_DATA_72, 72 sig,
+	//   1 OP_DATA_33, 33 compressed pubkey], 4 sequence
+	//
+	//  Input with uncompressed pubkey (180 bytes):
+	//   36 prev outpoint, 1 script len, 139 script [1 OP_DATA_72, 72 sig,
+	//   1 OP_DATA_65, 65 compressed pubkey], 4 sequence
+	//
+	// Pay-to-pubkey bytes breakdown:
+	//
+	//  Output to compressed pubkey (44 bytes):
+	//   8 value, 1 script len, 35 script [1 OP_DATA_33,
+	//   33 compressed pubkey, 1 OP_CHECKSIG]
+	//
+	//  Output to uncompressed pubkey (76 bytes):
+	//   8 value, 1 script len, 67 script [1 OP_DATA_65, 65 pubkey,
+	//   1 OP_CHECKSIG]
+	//
+	//  Input (114 bytes):
+	//   36 prev outpoint, 1 script len, 73 script [1 OP_DATA_72,
+	//   72 sig], 4 sequence
+	//
+	// Pay-to-witness-pubkey-hash bytes breakdown:
+	//
+	//  Output to witness key hash (31 bytes);
+	//   8 value, 1 script len, 22 script [1 OP_0, 1 OP_DATA_20,
+	//   20 bytes hash160]
+	//
+	//  Input (67 bytes as the 107 witness stack is discounted):
+	//   36 prev outpoint, 1 script len, 0 script (not sigScript), 107
+	//   witness stack bytes [1 element length, 33 compressed pubkey,
+	//   element length 72 sig], 4 sequence
+	//
+	//
+	// Theoretically this could examine the script type of the output script
+	// and use a different size for the typical input script size for
+	// pay-to-pubkey vs pay-to-pubkey-hash inputs per the above breakdowns,
+	// but the only combination which is less than the value chosen is
+	// a pay-to-pubkey script with a compressed pubkey, which is not very
+	// common.
+	//
+	// The most common scripts are pay-to-pubkey-hash, and as per the above
+	// breakdown, the minimum size of a p2pkh input script is 148 bytes.  So
+	// that figure is used. If the output being spent is a witness program,
+	// then we apply the witness discount to the size of the signature.
+	//
+	// The segwit analogue to p2pkh is a p2wkh output. This is the smallest
+	// output possible using the new segwit features. The 107 bytes of
+	// witness data is discounted by a factor of 4, leading to a computed
+	// value of 67 bytes of witness data.
+	//
+	// Both cases share a 41 byte preamble required to reference the input
+	// being spent and the sequence number of the input.
+	totalSize := txOut.SerializeSize() + 41
+	if txscript.IsWitnessProgram(txOut.PkScript) {
+		totalSize += (107 / blockchain.WitnessScaleFactor)
+	} else {
+		totalSize += 107
+	}
+
+	return 3 * int64(totalSize)
+}
+
+// IsDust returns whether or not the passed transaction output amount is
+// considered dust or not based on the passed minimum transaction relay fee.
+// Dust is defined in terms of the minimum transaction relay fee.  In
+// particular, if the cost to the network to spend coins is more than 1/3 of the
+// minimum transaction relay fee, it is considered dust.
+func IsDust(txOut *wire.TxOut, minRelayTxFee btcutil.Amount) bool {
+	// Unspendable outputs are considered dust.
+	if txscript.IsUnspendable(txOut.PkScript) {
+		return true
+	}
+
+	// The output is considered dust if the cost to the network to spend the
+	// coins is more than 1/3 of the minimum free transaction relay fee.
+	// minFreeTxRelayFee is in Satoshi/KB, so multiply by 1000 to
+	// convert to bytes.
+	//
+	// Using the typical values for a pay-to-pubkey-hash transaction from
+	// the breakdown above and the default minimum free transaction relay
+	// fee of 1000, this equates to values less than 546 satoshi being
+	// considered dust.
+	//
+	// The following is equivalent to (value/totalSize) * (1/3) * 1000
+	// without needing to do floating point math.
+	return txOut.Value*1000/GetDustThreshold(txOut) < int64(minRelayTxFee)
+}
+
+// isDataCarrier returns whether the passed output script is a data carrier
+// script, which is OP_RETURN followed by data pushes only.  Unlike the null
+// data scripts of txscript, data carrier scripts can push any amount of data
+// to let the rules decide about their size.
+func isDataCarrier(pkScript []byte) bool {
+	return len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN &&
+		txscript.IsPushOnlyScript(pkScript[1:])
+}
+
+// CheckTransactionStandard performs a series of checks on a transaction to
+// ensure it is a "standard" transaction according to the passed rules.  A
+// standard transaction is one that conforms to several additional limiting
+// cases over what is considered a "sane" transaction such as having a version
+// in the supported range, being finalized, conforming to more stringent size
+// constraints, having scripts of recognized forms, and not containing "dust"
+// outputs (those that are so small it costs more to process them than they
+// are worth).
+//
+// The minimum relay fee is used to determine whether outputs are dust unless
+// the rules define a dust relay fee.
+func CheckTransactionStandard(tx *btcutil.Tx, height int32,
+	medianTimePast time.Time, minRelayTxFee btcutil.Amount,
+	rules *Rules) error {
+
+	// The transaction must be a currently supported version.
+	msgTx := tx.MsgTx()
+	if msgTx.Version > rules.MaxTxVersion || msgTx.Version < 1 {
+		str := fmt.Sprintf("transaction version %d is not in the "+
+			"valid range of %d-%d", msgTx.Version, 1,
+			rules.MaxTxVersion)
+		return NewError(RuleVersion, wire.RejectNonstandard, str)
+	}
+
+	// The transaction must be finalized to be standard and therefore
+	// considered for inclusion in a block.
+	if !blockchain.IsFinalizedTransaction(tx, height, medianTimePast) {
+		return NewError(RuleNonFinal, wire.RejectNonstandard,
+			"transaction is not finalized")
+	}
+
+	// Since extremely large transactions with a lot of inputs can cost
+	// almost as much to process as the sender fees, limit the maximum
+	// size of a transaction.  This also helps mitigate CPU exhaustion
+	// attacks.
+	txWeight := blockchain.GetTransactionWeight(tx)
+	if txWeight > rules.MaxTxWeight {
+		str := fmt.Sprintf("weight of transaction is larger than max "+
+			"allowed: %v > %v", txWeight, rules.MaxTxWeight)
+		return NewError(RuleTxSize, wire.RejectNonstandard, str)
+	}
+
+	for i, txIn := range msgTx.TxIn {
+		// Each transaction input signature script must not exceed the
+		// maximum size allowed for a standard transaction.  See
+		// the comment on DefaultMaxSigScriptSize for more details.
+		sigScriptLen := len(txIn.SignatureScript)
+		if sigScriptLen > rules.MaxSigScriptSize {
+			str := fmt.Sprintf("transaction input %d: signature "+
+				"script size is larger than max allowed: "+
+				"%d > %d bytes", i, sigScriptLen,
+				rules.MaxSigScriptSize)
+			return NewError(RuleSigScriptSize, wire.RejectNonstandard,
+				str)
+		}
+
+		// Each transaction input signature script must only contain
+		// opcodes which push data onto the stack.
+		if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
+			str := fmt.Sprintf("transaction input %d: signature "+
+				"script is not push only", i)
+			return NewError(RuleSigScriptPushOnly,
+				wire.RejectNonstandard, str)
+		}
+	}
+
+	// None of the output public key scripts can be a non-standard script or
+	// be "dust" (except when the script is a data carrier script).
+	dustRelayFee := rules.dustRelayFee(minRelayTxFee)
+	numDataCarrierOutputs := 0
+	for i, txOut := range msgTx.TxOut {
+		// Data carrier outputs are limited by their own rules rather
+		// than the script class, which caps the size of the data.
+		if isDataCarrier(txOut.PkScript) {
+			if !rules.DataCarrier {
+				str := fmt.Sprintf("transaction output %d: "+
+					"data carrier outputs are not relayed",
+					i)
+				return NewError(RuleDataCarrier,
+					wire.RejectNonstandard, str)
+			}
+			if len(txOut.PkScript) > rules.MaxDataCarrierSize {
+				str := fmt.Sprintf("transaction output %d: "+
+					"data carrier script size is larger "+
+					"than max allowed: %d > %d bytes", i,
+					len(txOut.PkScript),
+					rules.MaxDataCarrierSize)
+				return NewError(RuleDataCarrierSize,
+					wire.RejectNonstandard, str)
+			}
+
+			numDataCarrierOutputs++
+			continue
+		}
+
+		scriptClass := txscript.GetScriptClass(txOut.PkScript)
+		if scriptClass == txscript.MultiSigTy && !rules.PermitBareMultiSig {
+			str := fmt.Sprintf("transaction output %d: bare "+
+				"multi-signature scripts are not relayed", i)
+			return NewError(RuleBareMultiSig, wire.RejectNonstandard,
+				str)
+		}
+
+		err := checkPkScriptStandard(txOut.PkScript, scriptClass, rules)
+		if err != nil {
+			perr := err.(Error)
+			perr.Description = fmt.Sprintf("transaction output %d: "+
+				"%s", i, perr.Description)
+			return perr
+		}
+
+		// Ensure the output value is not "dust".
+		if IsDust(txOut, dustRelayFee) {
+			str := fmt.Sprintf("transaction output %d: payment is "+
+				"dust: %v", i, txOut.Value)
+			return NewError(RuleDust, wire.RejectDust, str)
+		}
+	}
+
+	// A standard transaction must not have more output scripts that only
+	// carry data than allowed.
+	if numDataCarrierOutputs > rules.MaxDataCarrierOutputs {
+		str := fmt.Sprintf("transaction has %d data carrier outputs "+
+			"which is more than the allowed max of %d",
+			numDataCarrierOutputs, rules.MaxDataCarrierOutputs)
+		return NewError(RuleMultiOpReturn, wire.RejectNonstandard, str)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package rebroadcast keeps track of the transactions submitted to the node
+locally, such as through the RPC server, and re-announces them to peers until
+they are confirmed.
+
+A transaction announced when it was submitted may never reach the network, for
+instance because the node had no peers at the time or the peers it was
+announced to disconnected or restarted before relaying it.  The manager
+remembers the peers every tracked transaction was announced to and, whenever it
+is asked to rebroadcast, announces the transaction to the connected peers which
+have not seen it yet.  Peers which already know about a transaction are never
+sent another announcement, so rebroadcasting frequently is cheap.
+
+Transactions stop being tracked once they are included in a block, once a
+conflicting transaction spending any of their inputs is included in a block, or
+once they are no longer in the memory pool, such as when they were replaced or
+evicted.  The tracked transactions are kept in memory only.
+*/
+package rebroadcast
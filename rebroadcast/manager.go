@@ -0,0 +1,274 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rebroadcast
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Peer describes a connected peer the tracked transactions are announced to.
+type Peer interface {
+	// ID returns the identifier of the peer, which is unique for the
+	// lifetime of the process.
+	ID() int32
+
+	// AnnounceTransaction announces the passed transaction to the peer
+	// unless it is already known to have it and returns whether the peer
+	// has been made aware of the transaction.  It returns false when the
+	// peer does not accept the transaction, such as when it disabled
+	// transaction relay or the fee rate is below its fee filter, so the
+	// announcement is attempted again on the next rebroadcast.
+	AnnounceTransaction(txD *mempool.TxDesc) bool
+}
+
+// Config houses the dependencies of a Manager.
+type Config struct {
+	// HaveTransaction returns whether the transaction with the passed hash
+	// is in the memory pool.  Transactions which are no longer in the
+	// memory pool stop being tracked.
+	HaveTransaction func(hash *chainhash.Hash) bool
+}
+
+// TxInfo describes a tracked transaction.
+type TxInfo struct {
+	// Tx is the tracked transaction.
+	Tx *btcutil.Tx
+
+	// Added is the time the transaction started being tracked.
+	Added time.Time
+
+	// LastAnnounced is the last time the transaction was announced to a
+	// peer by a rebroadcast.  It is the zero time when the transaction
+	// has not been rebroadcast yet.
+	LastAnnounced time.Time
+
+	// Peers is the number of connected peers which have been made aware of
+	// the transaction.
+	Peers int
+
+	// Announcements is the total number of peers the transaction was
+	// announced to by rebroadcasts, including peers which have since
+	// disconnected.
+	Announcements uint32
+
+	// Requests is the number of times peers requested the transaction
+	// from the node.
+	Requests uint32
+}
+
+// trackedTx houses the state of a tracked transaction.
+type trackedTx struct {
+	txD           *mempool.TxDesc
+	added         time.Time
+	lastAnnounced time.Time
+	peers         map[int32]struct{}
+	announcements uint32
+	requests      uint32
+}
+
+// Manager keeps track of the transactions submitted to the node locally and
+// re-announces them to the peers which have not seen them yet until they are
+// confirmed, conflicted or leave the memory pool.
+//
+// All methods are safe for concurrent access and may be called on a nil
+// manager, in which case nothing is tracked.
+type Manager struct {
+	cfg Config
+
+	mtx    sync.Mutex
+	txns   map[chainhash.Hash]*trackedTx
+	spends map[wire.OutPoint]chainhash.Hash
+}
+
+// New returns a new manager which doesn't track any transactions yet.
+func New(cfg *Config) *Manager {
+	return &Manager{
+		cfg:    *cfg,
+		txns:   make(map[chainhash.Hash]*trackedTx),
+		spends: make(map[wire.OutPoint]chainhash.Hash),
+	}
+}
+
+// Add starts tracking the passed transaction, which must have been accepted to
+// the memory pool.  Transactions which are already tracked are ignored.
+func (m *Manager) Add(txD *mempool.TxDesc) {
+	if m == nil {
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	hash := *txD.Tx.Hash()
+	if _, ok := m.txns[hash]; ok {
+		return
+	}
+	m.txns[hash] = &trackedTx{
+		txD:   txD,
+		added: time.Now(),
+		peers: make(map[int32]struct{}),
+	}
+	for _, txIn := range txD.Tx.MsgTx().TxIn {
+		m.spends[txIn.PreviousOutPoint] = hash
+	}
+	log.Debugf("Tracking transaction %v for rebroadcast", hash)
+}
+
+// remove stops tracking the transaction with the passed hash.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) remove(hash *chainhash.Hash, reason string) {
+	ttx, ok := m.txns[*hash]
+	if !ok {
+		return
+	}
+	for _, txIn := range ttx.txD.Tx.MsgTx().TxIn {
+		if m.spends[txIn.PreviousOutPoint] == *hash {
+			delete(m.spends, txIn.PreviousOutPoint)
+		}
+	}
+	delete(m.txns, *hash)
+	log.Debugf("Stopped rebroadcasting transaction %v: %s", hash, reason)
+}
+
+// TransactionConfirmed stops tracking the passed transaction, which was
+// included in a block connected to the main chain, along with the tracked
+// transactions that conflict with it.
+func (m *Manager) TransactionConfirmed(tx *btcutil.Tx) {
+	if m == nil {
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.remove(tx.Hash(), "confirmed")
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash, ok := m.spends[txIn.PreviousOutPoint]
+		if ok {
+			m.remove(&hash, "conflicted by "+tx.Hash().String())
+		}
+	}
+}
+
+// TransactionRequested records that a peer requested the transaction with the
+// passed hash.
+func (m *Manager) TransactionRequested(hash *chainhash.Hash) {
+	if m == nil {
+		return
+	}
+
+	m.mtx.Lock()
+	if ttx, ok := m.txns[*hash]; ok {
+		ttx.requests++
+	}
+	m.mtx.Unlock()
+}
+
+// Rebroadcast announces the tracked transactions to the passed connected peers
+// which have not been made aware of them yet and returns the number of
+// announcements made.  Transactions which are no longer in the memory pool stop
+// being tracked first.
+func (m *Manager) Rebroadcast(peers []Peer) int {
+	if m == nil {
+		return 0
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	connected := make(map[int32]struct{}, len(peers))
+	for _, p := range peers {
+		connected[p.ID()] = struct{}{}
+	}
+
+	now := time.Now()
+	var numAnnounced int
+	for hash, ttx := range m.txns {
+		hash := hash
+		if !m.cfg.HaveTransaction(&hash) {
+			m.remove(&hash, "no longer in the memory pool")
+			continue
+		}
+
+		// Forget about the peers which disconnected since their
+		// identifiers are never reused.
+		for id := range ttx.peers {
+			if _, ok := connected[id]; !ok {
+				delete(ttx.peers, id)
+			}
+		}
+
+		for _, p := range peers {
+			if _, ok := ttx.peers[p.ID()]; ok {
+				continue
+			}
+			if !p.AnnounceTransaction(ttx.txD) {
+				continue
+			}
+			ttx.peers[p.ID()] = struct{}{}
+			ttx.announcements++
+			ttx.lastAnnounced = now
+			numAnnounced++
+		}
+	}
+
+	if numAnnounced > 0 {
+		log.Debugf("Made %d announcements of %d tracked transactions",
+			numAnnounced, len(m.txns))
+	}
+	return numAnnounced
+}
+
+// Count returns the number of tracked transactions.
+func (m *Manager) Count() int {
+	if m == nil {
+		return 0
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return len(m.txns)
+}
+
+// Transactions returns information about the tracked transactions ordered by
+// the time they started being tracked.
+func (m *Manager) Transactions() []TxInfo {
+	if m == nil {
+		return nil
+	}
+
+	m.mtx.Lock()
+	infos := make([]TxInfo, 0, len(m.txns))
+	for _, ttx := range m.txns {
+		infos = append(infos, TxInfo{
+			Tx:            ttx.txD.Tx,
+			Added:         ttx.added,
+			LastAnnounced: ttx.lastAnnounced,
+			Peers:         len(ttx.peers),
+			Announcements: ttx.announcements,
+			Requests:      ttx.requests,
+		})
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].Added.Equal(infos[j].Added) {
+			return infos[i].Added.Before(infos[j].Added)
+		}
+		return bytes.Compare(infos[i].Tx.Hash()[:],
+			infos[j].Tx.Hash()[:]) < 0
+	})
+	return infos
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rebroadcast
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakePeer is a peer which records the transactions announced to it.
+type fakePeer struct {
+	id        int32
+	refuse    bool
+	announced map[chainhash.Hash]int
+}
+
+// ID returns the identifier of the peer.
+func (p *fakePeer) ID() int32 {
+	return p.id
+}
+
+// AnnounceTransaction records the announcement of the transaction unless the
+// peer refuses transactions.
+func (p *fakePeer) AnnounceTransaction(txD *mempool.TxDesc) bool {
+	if p.refuse {
+		return false
+	}
+	p.announced[*txD.Tx.Hash()]++
+	return true
+}
+
+// newFakePeer returns a new fake peer with the passed identifier.
+func newFakePeer(id int32) *fakePeer {
+	return &fakePeer{id: id, announced: make(map[chainhash.Hash]int)}
+}
+
+// fakePool is a memory pool which only tracks which transactions it contains.
+type fakePool struct {
+	mtx  sync.Mutex
+	txns map[chainhash.Hash]struct{}
+}
+
+// add adds the passed transaction to the pool and returns its descriptor.
+func (p *fakePool) add(tx *btcutil.Tx) *mempool.TxDesc {
+	p.mtx.Lock()
+	p.txns[*tx.Hash()] = struct{}{}
+	p.mtx.Unlock()
+	return &mempool.TxDesc{TxDesc: mining.TxDesc{Tx: tx}}
+}
+
+// HaveTransaction returns whether the transaction is in the pool.
+func (p *fakePool) HaveTransaction(hash *chainhash.Hash) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	_, ok := p.txns[*hash]
+	return ok
+}
+
+// spendTx returns a transaction which spends the passed outpoint to an output
+// of the passed value.
+func spendTx(prevOut wire.OutPoint, value int64) *btcutil.Tx {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(value, []byte{0x51}))
+	return btcutil.NewTx(msgTx)
+}
+
+// TestRebroadcast ensures tracked transactions are only announced to peers
+// which have not been made aware of them and stop being tracked once they are
+// confirmed, conflicted or leave the memory pool.
+func TestRebroadcast(t *testing.T) {
+	t.Parallel()
+
+	pool := &fakePool{txns: make(map[chainhash.Hash]struct{})}
+	m := New(&Config{HaveTransaction: pool.HaveTransaction})
+	track := func(tx *btcutil.Tx) {
+		m.Add(pool.add(tx))
+	}
+
+	txA := spendTx(wire.OutPoint{Hash: chainhash.Hash{0x01}}, 1000)
+	txB := spendTx(wire.OutPoint{Hash: chainhash.Hash{0x02}}, 2000)
+	txC := spendTx(wire.OutPoint{Hash: chainhash.Hash{0x03}}, 3000)
+	track(txA)
+	track(txB)
+	track(txC)
+	track(txA)
+	if n := m.Count(); n != 3 {
+		t.Fatalf("unexpected number of tracked transactions: got %d, "+
+			"want 3", n)
+	}
+
+	// Every transaction is announced to every peer which accepts them
+	// exactly once.
+	p1, p2, refusing := newFakePeer(1), newFakePeer(2), newFakePeer(3)
+	refusing.refuse = true
+	peers := []Peer{p1, p2, refusing}
+	if n := m.Rebroadcast(peers); n != 6 {
+		t.Fatalf("unexpected number of announcements: got %d, want 6", n)
+	}
+	if n := m.Rebroadcast(peers); n != 0 {
+		t.Fatalf("unexpected number of repeated announcements: got %d, "+
+			"want 0", n)
+	}
+	for _, tx := range []*btcutil.Tx{txA, txB, txC} {
+		if p1.announced[*tx.Hash()] != 1 || p2.announced[*tx.Hash()] != 1 {
+			t.Fatalf("transaction %v not announced once to every "+
+				"peer", tx.Hash())
+		}
+	}
+
+	// A fresh peer is only sent the transactions, and the peers that
+	// disconnected are forgotten.
+	p4 := newFakePeer(4)
+	if n := m.Rebroadcast([]Peer{p1, p4}); n != 3 {
+		t.Fatalf("unexpected number of announcements to fresh peer: "+
+			"got %d, want 3", n)
+	}
+	m.TransactionRequested(txA.Hash())
+	for _, info := range m.Transactions() {
+		if info.Peers != 2 || info.Announcements != 3 {
+			t.Fatalf("unexpected peers %d and announcements %d of "+
+				"transaction %v", info.Peers, info.Announcements,
+				info.Tx.Hash())
+		}
+		if info.LastAnnounced.IsZero() {
+			t.Fatalf("transaction %v has no announcement time",
+				info.Tx.Hash())
+		}
+		wantRequests := uint32(0)
+		if info.Tx == txA {
+			wantRequests = 1
+		}
+		if info.Requests != wantRequests {
+			t.Fatalf("unexpected requests of transaction %v: got "+
+				"%d, want %d", info.Tx.Hash(), info.Requests,
+				wantRequests)
+		}
+	}
+
+	// Confirming a transaction and a conflict of another one stops
+	// tracking both, while transactions that left the memory pool are
+	// dropped on the next rebroadcast.
+	m.TransactionConfirmed(txA)
+	m.TransactionConfirmed(spendTx(txB.MsgTx().TxIn[0].PreviousOutPoint, 1))
+	infos := m.Transactions()
+	if len(infos) != 1 || infos[0].Tx != txC {
+		t.Fatalf("unexpected tracked transactions after confirmation: "+
+			"%v", infos)
+	}
+	pool.mtx.Lock()
+	delete(pool.txns, *txC.Hash())
+	pool.mtx.Unlock()
+	if n := m.Rebroadcast([]Peer{newFakePeer(5)}); n != 0 {
+		t.Fatalf("unexpected announcements of evicted transaction: %d",
+			n)
+	}
+	if n := m.Count(); n != 0 {
+		t.Fatalf("unexpected number of tracked transactions: got %d, "+
+			"want 0", n)
+	}
+	if len(m.spends) != 0 {
+		t.Fatalf("unexpected leftover spends: %v", m.spends)
+	}
+
+	// A nil manager doesn't track anything.
+	var nilManager *Manager
+	nilManager.Add(&mempool.TxDesc{})
+	if n := nilManager.Rebroadcast(peers); n != 0 {
+		t.Fatalf("unexpected announcements of nil manager: %d", n)
+	}
+}
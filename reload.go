@@ -0,0 +1,212 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	flags "github.com/jessevdk/go-flags"
+)
+
+// reloadSignals defines the signals which request the configuration to be
+// reloaded.  It is populated during init on platforms which support them.
+var reloadSignals []os.Signal
+
+// liveConfig holds the *reloadableConfig in effect.  It is replaced as a whole
+// when the configuration is reloaded, so readers see a consistent set of
+// options without locking.
+var liveConfig atomic.Value
+
+// reloadMtx serializes reloads of the configuration.
+var reloadMtx sync.Mutex
+
+// reloadableConfig houses the options which take effect without restarting
+// btcd when the configuration is reloaded.  The options are read from here
+// instead of the global config, which is never modified after it is loaded.
+type reloadableConfig struct {
+	DebugLevel           string
+	MaxPeers             int
+	BanDuration          time.Duration
+	BanThreshold         uint32
+	RPCMaxClients        int
+	RPCMaxWebsockets     int
+	RPCMaxConcurrentReqs int
+	minRelayTxFee        btcutil.Amount
+}
+
+// newReloadableConfig returns the reloadable options of the passed validated
+// config.
+func newReloadableConfig(c *config) *reloadableConfig {
+	return &reloadableConfig{
+		DebugLevel:           c.DebugLevel,
+		MaxPeers:             c.MaxPeers,
+		BanDuration:          c.BanDuration,
+		BanThreshold:         c.BanThreshold,
+		RPCMaxClients:        c.RPCMaxClients,
+		RPCMaxWebsockets:     c.RPCMaxWebsockets,
+		RPCMaxConcurrentReqs: c.RPCMaxConcurrentReqs,
+		minRelayTxFee:        c.minRelayTxFee,
+	}
+}
+
+// liveCfg returns the reloadable options in effect.  They are derived from the
+// global config when it wasn't loaded by loadConfig, which is the case in
+// tests.
+//
+// This function is safe for concurrent access.
+func liveCfg() *reloadableConfig {
+	if c, ok := liveConfig.Load().(*reloadableConfig); ok {
+		return c
+	}
+	return newReloadableConfig(cfg)
+}
+
+// configChange describes an option whose value changed when the configuration
+// was reloaded.
+type configChange struct {
+	Option string
+	Old    string
+	New    string
+}
+
+// changes returns the options whose values differ in the passed config in the
+// order they are listed in the help.
+func (c *reloadableConfig) changes(other *reloadableConfig) []configChange {
+	var changes []configChange
+	add := func(option string, old, new interface{}) {
+		oldStr, newStr := fmt.Sprint(old), fmt.Sprint(new)
+		if oldStr != newStr {
+			changes = append(changes, configChange{
+				Option: option,
+				Old:    oldStr,
+				New:    newStr,
+			})
+		}
+	}
+	add("banduration", c.BanDuration, other.BanDuration)
+	add("banthreshold", c.BanThreshold, other.BanThreshold)
+	add("debuglevel", c.DebugLevel, other.DebugLevel)
+	add("maxpeers", c.MaxPeers, other.MaxPeers)
+	add("minrelaytxfee", c.minRelayTxFee, other.minRelayTxFee)
+	add("rpcmaxclients", c.RPCMaxClients, other.RPCMaxClients)
+	add("rpcmaxconcurrentreqs", c.RPCMaxConcurrentReqs,
+		other.RPCMaxConcurrentReqs)
+	add("rpcmaxwebsockets", c.RPCMaxWebsockets, other.RPCMaxWebsockets)
+	return changes
+}
+
+// parseReloadableConfig reads the config file and the command line options
+// again the same way loadConfig does and returns the reloadable options after
+// validating them.  The other options are ignored.
+func parseReloadableConfig() (*reloadableConfig, error) {
+	newCfg := defaultConfig()
+	serviceOpts := serviceOptions{}
+	parser := newConfigParser(&newCfg, &serviceOpts, flags.None)
+	if cfg.useConfigFile {
+		err := flags.NewIniParser(parser).ParseFile(cfg.ConfigFile)
+		if err != nil {
+			if _, ok := err.(*os.PathError); !ok {
+				return nil, fmt.Errorf("error parsing config "+
+					"file: %v", err)
+			}
+		}
+	}
+
+	// Parse command line options again to ensure they take precedence.
+	if _, err := parser.Parse(); err != nil {
+		return nil, err
+	}
+
+	if newCfg.DebugLevel == "show" {
+		return nil, fmt.Errorf("the debuglevel option may not be " +
+			"show when reloading")
+	}
+	if newCfg.BanDuration < time.Second {
+		return nil, fmt.Errorf("the banduration option may not be "+
+			"less than 1s -- parsed [%v]", newCfg.BanDuration)
+	}
+	if newCfg.RPCMaxConcurrentReqs < 0 {
+		return nil, fmt.Errorf("the rpcmaxwebsocketconcurrentrequests "+
+			"option may not be less than 0 -- parsed [%d]",
+			newCfg.RPCMaxConcurrentReqs)
+	}
+	var err error
+	newCfg.minRelayTxFee, err = btcutil.NewAmount(newCfg.MinRelayTxFee)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minrelaytxfee: %v", err)
+	}
+
+	return newReloadableConfig(&newCfg), nil
+}
+
+// reloadConfig reads the config file and the command line options again and
+// applies the reloadable options which changed.  Nothing is applied when any
+// of the reloadable options is invalid.  It returns the options which changed.
+//
+// The debug level is only applied when it changed in the configuration, so
+// levels changed with the debuglevel RPC are kept otherwise.  Lowering the
+// maximum number of peers doesn't disconnect any peers, it only prevents new
+// ones from connecting until enough of them left.
+//
+// This function is safe for concurrent access.
+func (s *server) reloadConfig() ([]configChange, error) {
+	reloadMtx.Lock()
+	defer reloadMtx.Unlock()
+
+	newCfg, err := parseReloadableConfig()
+	if err != nil {
+		srvrLog.Errorf("Unable to reload configuration: %v", err)
+		return nil, err
+	}
+
+	oldCfg := liveCfg()
+	changes := oldCfg.changes(newCfg)
+	if newCfg.DebugLevel != oldCfg.DebugLevel {
+		if err := parseAndSetDebugLevels(newCfg.DebugLevel); err != nil {
+			srvrLog.Errorf("Unable to reload configuration: %v", err)
+			return nil, err
+		}
+	}
+	if newCfg.minRelayTxFee != oldCfg.minRelayTxFee {
+		s.txMemPool.SetMinRelayTxFee(newCfg.minRelayTxFee)
+	}
+	liveConfig.Store(newCfg)
+
+	for _, change := range changes {
+		srvrLog.Infof("Reloaded %s: %s -> %s", change.Option, change.Old,
+			change.New)
+	}
+	srvrLog.Infof("Configuration reloaded (%d options changed)",
+		len(changes))
+	return changes, nil
+}
+
+// reloadHandler reloads the configuration each time one of the reload signals
+// is received.  It must be run as a goroutine.
+func (s *server) reloadHandler() {
+	defer s.wg.Done()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, reloadSignals...)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case sig := <-c:
+			srvrLog.Infof("Received signal (%s).  Reloading "+
+				"configuration...", sig)
+			s.reloadConfig()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package reputation keeps statistics about the behavior of peers which persist
+across restarts and derives a reputation from them.
+
+The statistics of a peer count the blocks and transactions it delivered that
+turned out to be useful, the block requests to it that stalled and its history
+of misbehavior, such as the ban score it accrued and the number of times it was
+banned.  They are tracked per host rather than per address since the port of
+inbound peers changes with every connection.
+
+The statistics are kept in a bucket of the database of the node and flushed
+periodically, so the reputation of a peer survives restarts.  The reputation is
+summarized by a score, which is used to derive a weight that biases the
+selection of outbound peers towards hosts that were useful in the past and away
+from those that misbehaved.  Statistics of hosts that have not been seen for a
+long time are expired.
+*/
+package reputation
@@ -0,0 +1,487 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package reputation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// flushInterval is the interval at which the modified statistics are
+	// written to the database.
+	flushInterval = 10 * time.Minute
+
+	// maxStatsAge is the duration after which the statistics of a host
+	// that has not been seen are expired.
+	maxStatsAge = 30 * 24 * time.Hour
+
+	// maxTrackedHosts is the maximum number of hosts statistics are kept
+	// for.  The statistics of the host seen least recently are evicted to
+	// make room for new ones so peers can't exhaust memory or disk space
+	// by connecting from many hosts.
+	maxTrackedHosts = 10000
+
+	// maxCredit is the maximum credit a host earns for the useful blocks
+	// and, separately, for the useful transactions it delivered so hosts
+	// can't build up unlimited credit to offset later misbehavior.
+	maxCredit = 1000
+
+	// txnsPerCredit is the number of useful transactions which earn as
+	// much credit as a single useful block.
+	txnsPerCredit = 100
+
+	// stallPenalty is the penalty for every block request to a host that
+	// stalled.
+	stallPenalty = 10
+
+	// banPenalty is the penalty for every time a host was banned.
+	banPenalty = 500
+
+	// weightScale is the score at which the weight of a host is half way
+	// between the weight of an unknown host and the maximum weight, or
+	// the minimum weight for negative scores.
+	weightScale = 100
+
+	// statsVersion is the version of the serialized statistics.
+	statsVersion = 1
+
+	// serializedStatsLen is the length of the serialized statistics.  It
+	// is the version, the counts of useful blocks and transactions, the
+	// counts of stalls, misbehavior, bans and connections, and the time
+	// the host was last seen.
+	serializedStatsLen = 1 + 8 + 8 + 4*4 + 8
+)
+
+var (
+	// bucketName is the name of the bucket of the database metadata which
+	// houses the statistics of the peers keyed by host.
+	bucketName = []byte("peerreputation")
+
+	// byteOrder is the preferred byte order used for serializing the
+	// statistics.
+	byteOrder = binary.LittleEndian
+)
+
+// Stats houses the statistics about the behavior of the peers of a host.
+type Stats struct {
+	// Blocks is the number of blocks delivered which were added to the
+	// chain.
+	Blocks uint64
+
+	// Txns is the number of transactions delivered which were accepted to
+	// the memory pool.
+	Txns uint64
+
+	// Stalls is the number of block requests that stalled.
+	Stalls uint32
+
+	// Misbehavior is the total ban score accrued.
+	Misbehavior uint32
+
+	// Bans is the number of times the host was banned.
+	Bans uint32
+
+	// Connections is the number of connections made with the host.
+	Connections uint32
+
+	// LastSeen is the last time any of the statistics were updated.
+	LastSeen time.Time
+}
+
+// Score returns the reputation score of the host.  The useful blocks and
+// transactions delivered earn credit while stalls, misbehavior and bans are
+// penalized, so the score is negative for hosts whose peers caused more trouble
+// than they were worth.
+func (s *Stats) Score() int64 {
+	blockCredit := s.Blocks
+	if blockCredit > maxCredit {
+		blockCredit = maxCredit
+	}
+	txnCredit := s.Txns / txnsPerCredit
+	if txnCredit > maxCredit {
+		txnCredit = maxCredit
+	}
+
+	penalty := int64(s.Stalls)*stallPenalty + int64(s.Misbehavior) +
+		int64(s.Bans)*banPenalty
+	return int64(blockCredit+txnCredit) - penalty
+}
+
+// Weight returns the probability in the range (0, 1] with which the host is
+// selected as an outbound peer when it is offered.  See scoreWeight for
+// details.
+func (s *Stats) Weight() float64 {
+	return scoreWeight(s.Score())
+}
+
+// scoreWeight returns the weight of a host with the passed score.  Hosts with a
+// score of zero, which includes unknown hosts, have a weight of 0.5.  The
+// weight approaches 1 as the score rises and 0 as it falls, so hosts are never
+// excluded completely.
+func scoreWeight(score int64) float64 {
+	if score >= 0 {
+		return 0.5 + 0.5*float64(score)/float64(score+weightScale)
+	}
+	return 0.5 * weightScale / float64(weightScale-score)
+}
+
+// serializeStats returns the serialized statistics.
+func serializeStats(stats *Stats) []byte {
+	serialized := make([]byte, serializedStatsLen)
+	serialized[0] = statsVersion
+	offset := 1
+	byteOrder.PutUint64(serialized[offset:], stats.Blocks)
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], stats.Txns)
+	offset += 8
+	for _, count := range []uint32{stats.Stalls, stats.Misbehavior,
+		stats.Bans, stats.Connections} {
+
+		byteOrder.PutUint32(serialized[offset:], count)
+		offset += 4
+	}
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.LastSeen.Unix()))
+	return serialized
+}
+
+// deserializeStats returns the statistics serialized by serializeStats.
+func deserializeStats(serialized []byte) (*Stats, error) {
+	if len(serialized) != serializedStatsLen {
+		return nil, fmt.Errorf("unexpected length %d of serialized peer "+
+			"statistics", len(serialized))
+	}
+	if serialized[0] != statsVersion {
+		return nil, fmt.Errorf("unknown version %d of serialized peer "+
+			"statistics", serialized[0])
+	}
+
+	var stats Stats
+	offset := 1
+	stats.Blocks = byteOrder.Uint64(serialized[offset:])
+	offset += 8
+	stats.Txns = byteOrder.Uint64(serialized[offset:])
+	offset += 8
+	for _, count := range []*uint32{&stats.Stalls, &stats.Misbehavior,
+		&stats.Bans, &stats.Connections} {
+
+		*count = byteOrder.Uint32(serialized[offset:])
+		offset += 4
+	}
+	lastSeen := int64(byteOrder.Uint64(serialized[offset:]))
+	stats.LastSeen = time.Unix(lastSeen, 0)
+	return &stats, nil
+}
+
+// HostKey returns the host of the passed address of the form host:port, which
+// the statistics are tracked by.  The address is returned unchanged when it
+// doesn't have a port.
+func HostKey(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// Tracker keeps the statistics about the behavior of the peers of each host and
+// persists them in the database.
+//
+// All methods are safe for concurrent access and may be called on a nil
+// tracker, in which case nothing is tracked.
+type Tracker struct {
+	db database.DB
+
+	mtx     sync.Mutex
+	hosts   map[string]*Stats
+	dirty   map[string]struct{}
+	removed map[string]struct{}
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New returns a tracker which persists the statistics in the passed database
+// after loading the statistics that were persisted previously.
+func New(db database.DB) (*Tracker, error) {
+	t := &Tracker{
+		db:      db,
+		hosts:   make(map[string]*Stats),
+		dirty:   make(map[string]struct{}),
+		removed: make(map[string]struct{}),
+		quit:    make(chan struct{}),
+	}
+
+	now := time.Now()
+	err := db.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			stats, err := deserializeStats(v)
+			if err != nil {
+				log.Warnf("Discarding statistics of %s: %v",
+					k, err)
+				t.removed[string(k)] = struct{}{}
+				return nil
+			}
+			if now.Sub(stats.LastSeen) > maxStatsAge {
+				t.removed[string(k)] = struct{}{}
+				return nil
+			}
+			t.hosts[string(k)] = stats
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Loaded the statistics of %d peer hosts", len(t.hosts))
+	return t, nil
+}
+
+// Start begins flushing the statistics to the database periodically.
+func (t *Tracker) Start() {
+	if t == nil {
+		return
+	}
+
+	t.wg.Add(1)
+	go t.flushHandler()
+}
+
+// Stop stops flushing the statistics periodically and flushes them one last
+// time.
+func (t *Tracker) Stop() error {
+	if t == nil {
+		return nil
+	}
+
+	close(t.quit)
+	t.wg.Wait()
+	return t.Flush()
+}
+
+// flushHandler flushes the statistics to the database periodically.  It must
+// be run as a goroutine.
+func (t *Tracker) flushHandler() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Errorf("Unable to flush peer statistics: %v",
+					err)
+			}
+
+		case <-t.quit:
+			break out
+		}
+	}
+
+	t.wg.Done()
+}
+
+// Flush writes the statistics that were modified since the last flush to the
+// database and removes the expired ones.
+func (t *Tracker) Flush() error {
+	if t == nil {
+		return nil
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := time.Now()
+	for key, stats := range t.hosts {
+		if now.Sub(stats.LastSeen) > maxStatsAge {
+			t.remove(key)
+		}
+	}
+	if len(t.dirty) == 0 && len(t.removed) == 0 {
+		return nil
+	}
+
+	err := t.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		for key := range t.removed {
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		for key := range t.dirty {
+			err := bucket.Put([]byte(key), serializeStats(t.hosts[key]))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Flushed the statistics of %d peer hosts and removed %d",
+		len(t.dirty), len(t.removed))
+	t.dirty = make(map[string]struct{})
+	t.removed = make(map[string]struct{})
+	return nil
+}
+
+// remove removes the statistics of the passed host.
+//
+// This function MUST be called with the tracker lock held.
+func (t *Tracker) remove(key string) {
+	delete(t.hosts, key)
+	delete(t.dirty, key)
+	t.removed[key] = struct{}{}
+}
+
+// update applies the passed function to the statistics of the host of the
+// passed address, creating them if needed, and marks the host as seen.
+func (t *Tracker) update(addr string, f func(stats *Stats)) {
+	if t == nil {
+		return
+	}
+
+	key := HostKey(addr)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	stats, ok := t.hosts[key]
+	if !ok {
+		// Evict the host seen least recently when the maximum number
+		// of hosts is tracked.
+		if len(t.hosts) >= maxTrackedHosts {
+			var oldestKey string
+			var oldest time.Time
+			for k, s := range t.hosts {
+				if oldestKey == "" || s.LastSeen.Before(oldest) {
+					oldestKey = k
+					oldest = s.LastSeen
+				}
+			}
+			t.remove(oldestKey)
+		}
+
+		stats = new(Stats)
+		t.hosts[key] = stats
+	}
+
+	f(stats)
+	stats.LastSeen = time.Now()
+	t.dirty[key] = struct{}{}
+	delete(t.removed, key)
+}
+
+// Connected records a connection with the peer with the passed address.
+func (t *Tracker) Connected(addr string) {
+	t.update(addr, func(stats *Stats) {
+		stats.Connections++
+	})
+}
+
+// UsefulBlock records that the peer with the passed address delivered a block
+// which was added to the chain.
+func (t *Tracker) UsefulBlock(addr string) {
+	t.update(addr, func(stats *Stats) {
+		stats.Blocks++
+	})
+}
+
+// UsefulTx records that the peer with the passed address delivered a
+// transaction which was accepted to the memory pool.
+func (t *Tracker) UsefulTx(addr string) {
+	t.update(addr, func(stats *Stats) {
+		stats.Txns++
+	})
+}
+
+// Stalled records that a block request to the peer with the passed address
+// stalled.
+func (t *Tracker) Stalled(addr string) {
+	t.update(addr, func(stats *Stats) {
+		stats.Stalls++
+	})
+}
+
+// Misbehaved records that the peer with the passed address accrued the passed
+// ban score.
+func (t *Tracker) Misbehaved(addr string, score uint32) {
+	t.update(addr, func(stats *Stats) {
+		stats.Misbehavior += score
+	})
+}
+
+// Banned records that the peer with the passed address was banned.
+func (t *Tracker) Banned(addr string) {
+	t.update(addr, func(stats *Stats) {
+		stats.Bans++
+	})
+}
+
+// Weight returns the probability in the range (0, 1] with which the host of the
+// passed address is selected as an outbound peer when it is offered.  Unknown
+// hosts have a weight of 0.5 while all hosts have a weight of 1 when nothing is
+// tracked.
+func (t *Tracker) Weight(addr string) float64 {
+	if t == nil {
+		return 1
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	stats, ok := t.hosts[HostKey(addr)]
+	if !ok {
+		return scoreWeight(0)
+	}
+	return stats.Weight()
+}
+
+// Stats returns a copy of the statistics of the host of the passed address and
+// whether any are known.
+func (t *Tracker) Stats(addr string) (Stats, bool) {
+	if t == nil {
+		return Stats{}, false
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	stats, ok := t.hosts[HostKey(addr)]
+	if !ok {
+		return Stats{}, false
+	}
+	return *stats, true
+}
+
+// AllStats returns a copy of the statistics of all hosts keyed by host.
+func (t *Tracker) AllStats() map[string]Stats {
+	if t == nil {
+		return nil
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	all := make(map[string]Stats, len(t.hosts))
+	for key, stats := range t.hosts {
+		all[key] = *stats
+	}
+	return all
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package reputation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestScoreWeight ensures useful hosts are weighted above unknown ones and
+// misbehaving hosts below them.
+func TestScoreWeight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		stats Stats
+		score int64
+	}{{
+		name:  "unknown",
+		stats: Stats{Connections: 5},
+		score: 0,
+	}, {
+		name:  "useful",
+		stats: Stats{Blocks: 50, Txns: 5000},
+		score: 100,
+	}, {
+		name:  "credit capped",
+		stats: Stats{Blocks: 5000, Txns: 500000, Stalls: 1},
+		score: 2*maxCredit - stallPenalty,
+	}, {
+		name:  "stalling",
+		stats: Stats{Blocks: 10, Stalls: 3},
+		score: 10 - 3*stallPenalty,
+	}, {
+		name:  "banned",
+		stats: Stats{Blocks: 10, Misbehavior: 120, Bans: 1},
+		score: 10 - 120 - banPenalty,
+	}}
+
+	for _, test := range tests {
+		score := test.stats.Score()
+		if score != test.score {
+			t.Errorf("%s: unexpected score: got %d, want %d",
+				test.name, score, test.score)
+			continue
+		}
+
+		weight := test.stats.Weight()
+		switch {
+		case weight <= 0 || weight > 1:
+			t.Errorf("%s: weight %v out of range", test.name, weight)
+		case score > 0 && weight <= 0.5:
+			t.Errorf("%s: weight %v of useful host not above that "+
+				"of unknown hosts", test.name, weight)
+		case score < 0 && weight >= 0.5:
+			t.Errorf("%s: weight %v of misbehaving host not below "+
+				"that of unknown hosts", test.name, weight)
+		case score == 0 && weight != 0.5:
+			t.Errorf("%s: unexpected weight %v of unknown host",
+				test.name, weight)
+		}
+	}
+
+	if got, want := scoreWeight(weightScale), 0.75; got != want {
+		t.Errorf("unexpected weight at the scale: got %v, want %v", got,
+			want)
+	}
+	if got, want := scoreWeight(-weightScale), 0.25; got != want {
+		t.Errorf("unexpected weight at the negative scale: got %v, "+
+			"want %v", got, want)
+	}
+}
+
+// TestTracker ensures the statistics of peers are tracked per host, persist
+// across restarts and expire.
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Create("ffldb", t.TempDir(), wire.SimNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	tracker, err := New(db)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	tracker.Start()
+
+	// Record the statistics of a useful host, whose peers connect from
+	// different ports, and a misbehaving host.
+	const good, bad = "10.0.0.1", "[2001:db8::1]:8333"
+	tracker.Connected(good + ":8333")
+	tracker.Connected(good + ":41234")
+	for i := 0; i < 20; i++ {
+		tracker.UsefulBlock(good + ":8333")
+	}
+	tracker.UsefulTx(good + ":41234")
+	tracker.Connected(bad)
+	tracker.Stalled(bad)
+	tracker.Misbehaved(bad, 50)
+	tracker.Misbehaved(bad, 60)
+	tracker.Banned(bad)
+
+	want := map[string]Stats{
+		good:          {Blocks: 20, Txns: 1, Connections: 2},
+		"2001:db8::1": {Stalls: 1, Misbehavior: 110, Bans: 1, Connections: 1},
+	}
+	checkStats := func(tracker *Tracker) {
+		t.Helper()
+
+		all := tracker.AllStats()
+		if len(all) != len(want) {
+			t.Fatalf("unexpected number of hosts: got %d, want %d",
+				len(all), len(want))
+		}
+		for host, wantStats := range want {
+			stats, ok := all[host]
+			if !ok {
+				t.Fatalf("statistics of %s are missing", host)
+			}
+			stats.LastSeen = time.Time{}
+			if stats != wantStats {
+				t.Fatalf("unexpected statistics of %s: got %+v, "+
+					"want %+v", host, stats, wantStats)
+			}
+		}
+	}
+	checkStats(tracker)
+
+	if tracker.Weight(good+":18333") <= tracker.Weight("10.0.0.2:8333") {
+		t.Fatal("useful host not weighted above unknown host")
+	}
+	if tracker.Weight(bad) >= tracker.Weight("10.0.0.2:8333") {
+		t.Fatal("misbehaving host not weighted below unknown host")
+	}
+
+	// The statistics are loaded again after a restart.
+	if err := tracker.Stop(); err != nil {
+		t.Fatalf("Stop: unexpected error: %v", err)
+	}
+	tracker, err = New(db)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	checkStats(tracker)
+
+	// Statistics of hosts which have not been seen for too long are
+	// expired and not loaded again.
+	tracker.mtx.Lock()
+	tracker.hosts[good].LastSeen = time.Now().Add(-maxStatsAge - time.Hour)
+	tracker.mtx.Unlock()
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Flush: unexpected error: %v", err)
+	}
+	if _, ok := tracker.Stats(good); ok {
+		t.Fatal("statistics of expired host still tracked")
+	}
+	tracker, err = New(db)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	delete(want, good)
+	checkStats(tracker)
+
+	// Nothing is tracked by a nil tracker.
+	var nilTracker *Tracker
+	nilTracker.UsefulBlock(good)
+	if _, ok := nilTracker.Stats(good); ok {
+		t.Fatal("nil tracker unexpectedly tracks statistics")
+	}
+	if nilTracker.Weight(bad) != 1 {
+		t.Fatal("nil tracker unexpectedly biases selection")
+	}
+}
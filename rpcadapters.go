@@ -5,11 +5,16 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
+	"time"
 
+	"github.com/btcsuite/btcd/addrmgr"
+	"github.com/btcsuite/btcd/banman"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/netsync"
 	"github.com/btcsuite/btcd/peer"
@@ -61,6 +66,36 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// ConnectionType returns the type of the connection to the peer, which is
+// inbound, manual, outbound-full-relay or block-relay-only.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) ConnectionType() string {
+	sp := (*serverPeer)(p)
+	switch {
+	case sp.Inbound():
+		return "inbound"
+	case sp.persistent:
+		return "manual"
+	case sp.blockRelayOnly:
+		return "block-relay-only"
+	}
+	return "outbound-full-relay"
+}
+
+// AddrRelayStats returns whether addresses are relayed to and from the peer
+// along with the number of addresses relayed by the peer which were processed
+// and which were ignored for exceeding the address rate limit.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) AddrRelayStats() (bool, uint64, uint64) {
+	sp := (*serverPeer)(p)
+	return !sp.blockRelayOnly, atomic.LoadUint64(&sp.addrProcessed),
+		atomic.LoadUint64(&sp.addrRateLimited)
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -164,6 +199,69 @@ func (cm *rpcConnManager) NetTotals() (uint64, uint64) {
 	return cm.server.NetTotals()
 }
 
+// UploadTarget returns the state of the daily upload target.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UploadTarget() connmgr.UploadTargetStatus {
+	return cm.server.uploadTarget.Status()
+}
+
+// Ban bans the passed subnet until the passed time and disconnects the peers
+// which are part of it.  Whitelisted peers are not disconnected.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Ban(subnet *net.IPNet, until time.Time) error {
+	if err := cm.server.banManager.Ban(subnet, until); err != nil {
+		return err
+	}
+
+	// Disconnect the peers in the subnet one at a time until there are no
+	// more.
+	for {
+		replyChan := make(chan error)
+		cm.server.query <- disconnectNodeMsg{
+			cmp: func(sp *serverPeer) bool {
+				host, _, err := net.SplitHostPort(sp.Addr())
+				if err != nil || sp.isWhitelisted {
+					return false
+				}
+				ip := net.ParseIP(host)
+				return ip != nil && subnet.Contains(ip)
+			},
+			reply: replyChan,
+		}
+		if <-replyChan != nil {
+			return nil
+		}
+	}
+}
+
+// Unban removes the ban of the passed subnet.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Unban(subnet *net.IPNet) error {
+	return cm.server.banManager.Unban(subnet)
+}
+
+// BanList returns the bans which have not expired.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BanList() ([]banman.Entry, error) {
+	return cm.server.banManager.BanList()
+}
+
+// ClearBanned removes all bans and discouragements.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() error {
+	return cm.server.banManager.Clear()
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -207,20 +305,16 @@ func (cm *rpcConnManager) BroadcastMessage(msg wire.Message) {
 	cm.server.BroadcastMessage(msg)
 }
 
-// AddRebroadcastInventory adds the provided inventory to the list of
-// inventories to be rebroadcast at random intervals until they show up in a
-// block.
-//
-// This function is safe for concurrent access and is part of the
-// rpcserverConnManager interface implementation.
-func (cm *rpcConnManager) AddRebroadcastInventory(iv *wire.InvVect, data interface{}) {
-	cm.server.AddRebroadcastInventory(iv, data)
-}
-
 // RelayTransactions generates and relays inventory vectors for all of the
 // passed transactions to all connected peers.
 func (cm *rpcConnManager) RelayTransactions(txns []*mempool.TxDesc) {
 	cm.server.relayTransactions(txns)
+
+	// Transactions submitted over RPC aren't announced through
+	// AnnounceNewTransactions, so notify the gRPC streams here.
+	if cm.server.grpcServer != nil {
+		cm.server.grpcServer.NotifyNewTransactions(txns)
+	}
 }
 
 // NodeAddresses returns an array consisting node addresses which can
@@ -232,6 +326,23 @@ func (cm *rpcConnManager) NodeAddresses() []*wire.NetAddressV2 {
 	return cm.server.addrManager.AddressCache()
 }
 
+// LocalAddresses returns the local addresses advertised to peers.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) LocalAddresses() []addrmgr.LocalAddress {
+	return cm.server.addrManager.LocalAddresses()
+}
+
+// PortMapping returns the current mapping of the listening port on the NAT or
+// nil when the port is not mapped.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) PortMapping() *portMapping {
+	return cm.server.PortMapping()
+}
+
 // rpcSyncMgr provides a block manager for use with the RPC server and
 // implements the rpcserverSyncManager interface.
 type rpcSyncMgr struct {
@@ -277,6 +388,15 @@ func (b *rpcSyncMgr) SyncPeerID() int32 {
 	return b.syncMgr.SyncPeerID()
 }
 
+// PeerDownloadStats returns the statistics of the blocks downloaded from the
+// peers during the initial block download keyed by peer ID.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) PeerDownloadStats() map[int32]*netsync.PeerDownloadStats {
+	return b.syncMgr.PeerDownloadStats()
+}
+
 // LocateBlocks returns the hashes of the blocks after the first known block in
 // the provided locators until the provided stop hash or the current tip is
 // reached, up to a max of wire.MaxBlockHeadersPerMsg hashes.
@@ -286,3 +406,21 @@ func (b *rpcSyncMgr) SyncPeerID() int32 {
 func (b *rpcSyncMgr) LocateHeaders(locators []*chainhash.Hash, hashStop *chainhash.Hash) []wire.BlockHeader {
 	return b.server.chain.LocateHeaders(locators, hashStop)
 }
+
+// FetchBlock fetches the main chain block with the provided hash from a
+// connected peer without processing or storing it.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) FetchBlock(hash *chainhash.Hash) (*btcutil.Block, error) {
+	return b.syncMgr.FetchBlock(hash)
+}
+
+// RequestBlock requests the block with the provided hash from the peer with the
+// provided ID without waiting for it.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) RequestBlock(hash *chainhash.Hash, peerID int32) error {
+	return b.syncMgr.RequestBlock(hash, peerID)
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// rpcCookieUser is the username of the credentials written to the
+	// cookie file.
+	rpcCookieUser = "__cookie__"
+
+	// rpcCookieFileName is the name of the cookie file in the data
+	// directory when no other file is specified.
+	rpcCookieFileName = ".cookie"
+)
+
+// Permission classes which may be granted to the users defined with the
+// rpcauth option in place of, or along with, individual methods.
+const (
+	// rpcPermAdmin allows calling all methods.
+	rpcPermAdmin = "admin"
+
+	// rpcPermLimited allows calling the methods available to the user
+	// defined with the rpclimituser option.
+	rpcPermLimited = "limited"
+
+	// rpcPermReadOnly allows calling the methods available to limited users
+	// which don't change the state of the node or relay anything.
+	rpcPermReadOnly = "readonly"
+
+	// rpcPermWallet allows calling the methods a wallet using the node as
+	// its backend needs, including the wallet methods which are forwarded
+	// to a wallet.
+	rpcPermWallet = "wallet"
+)
+
+// rpcBroadcast houses the methods available to limited users which relay
+// transactions or blocks and are therefore not read-only.
+var rpcBroadcast = map[string]struct{}{
+	"sendrawtransaction": {},
+	"submitblock":        {},
+	"submitpackage":      {},
+}
+
+// rpcWalletBackend houses the methods a wallet needs from the node in addition
+// to the ones available to limited users.
+var rpcWalletBackend = map[string]struct{}{
+	"fundrawtransaction":   {},
+	"gettxspendingprevout": {},
+	"importdescriptors":    {},
+	"listdescriptors":      {},
+	"listlockunspent":      {},
+	"listunspent":          {},
+	"lockunspent":          {},
+	"scantxoutset":         {},
+	"testmempoolaccept":    {},
+}
+
+// rpcPermissionMethods returns the methods included in the passed permission
+// class other than admin.  It returns nil for unknown classes.
+func rpcPermissionMethods(class string) map[string]struct{} {
+	methods := make(map[string]struct{})
+	switch class {
+	case rpcPermLimited:
+		for method := range rpcLimited {
+			methods[method] = struct{}{}
+		}
+
+	case rpcPermReadOnly:
+		for method := range rpcLimited {
+			if _, ok := rpcBroadcast[method]; !ok {
+				methods[method] = struct{}{}
+			}
+		}
+
+	case rpcPermWallet:
+		for method := range rpcLimited {
+			methods[method] = struct{}{}
+		}
+		for method := range rpcWalletBackend {
+			methods[method] = struct{}{}
+		}
+		for method := range rpcAskWallet {
+			methods[method] = struct{}{}
+		}
+
+	default:
+		return nil
+	}
+	return methods
+}
+
+// isKnownRPCMethod returns whether the passed method is handled by the RPC
+// server, either over HTTP POST or websockets.
+func isKnownRPCMethod(method string) bool {
+	if _, ok := rpcHandlers[method]; ok {
+		return true
+	}
+	if _, ok := rpcAskWallet[method]; ok {
+		return true
+	}
+	if _, ok := rpcUnimplemented[method]; ok {
+		return true
+	}
+	_, ok := wsHandlers[method]
+	return ok
+}
+
+// rpcUser is a user which may authenticate to the RPC server along with the
+// methods it may call.
+type rpcUser struct {
+	name    string
+	authsha [sha256.Size]byte
+	admin   bool
+	methods map[string]struct{}
+}
+
+// newRPCUser returns a user with the passed credentials which may call the
+// passed methods, or all methods when admin is set.
+func newRPCUser(name, pass string, admin bool, methods map[string]struct{}) *rpcUser {
+	login := name + ":" + pass
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	return &rpcUser{
+		name:    name,
+		authsha: sha256.Sum256([]byte(auth)),
+		admin:   admin,
+		methods: methods,
+	}
+}
+
+// parseRPCAuth parses a user defined with the rpcauth option in the form
+// <user>:<password>:<permission>[,<permission>...], where each permission is
+// either a permission class or the name of a method.
+func parseRPCAuth(auth string) (*rpcUser, error) {
+	parts := strings.SplitN(auth, ":", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed user %q -- expected "+
+			"<user>:<password>:<permission>[,<permission>...]",
+			redactRPCAuth(auth))
+	}
+	name, pass, perms := parts[0], parts[1], parts[2]
+	if name == "" || pass == "" {
+		return nil, fmt.Errorf("user %q must have a username and a "+
+			"password", redactRPCAuth(auth))
+	}
+	if name == rpcCookieUser {
+		return nil, fmt.Errorf("the username %s is reserved for "+
+			"cookie authentication", rpcCookieUser)
+	}
+
+	var admin bool
+	methods := make(map[string]struct{})
+	for _, perm := range strings.Split(perms, ",") {
+		perm = strings.TrimSpace(perm)
+		if perm == rpcPermAdmin {
+			admin = true
+			continue
+		}
+		if classMethods := rpcPermissionMethods(perm); classMethods != nil {
+			for method := range classMethods {
+				methods[method] = struct{}{}
+			}
+			continue
+		}
+		if !isKnownRPCMethod(perm) {
+			return nil, fmt.Errorf("unknown permission %q for user "+
+				"%s -- expected %s, %s, %s, %s or the name of a "+
+				"method", perm, name, rpcPermAdmin, rpcPermLimited,
+				rpcPermReadOnly, rpcPermWallet)
+		}
+		methods[perm] = struct{}{}
+	}
+
+	return newRPCUser(name, pass, admin, methods), nil
+}
+
+// redactRPCAuth returns the passed rpcauth option with the password removed
+// so it can be included in errors.
+func redactRPCAuth(auth string) string {
+	parts := strings.SplitN(auth, ":", 3)
+	if len(parts) < 2 {
+		return auth
+	}
+	parts[1] = "***"
+	return strings.Join(parts, ":")
+}
+
+// allowed returns whether the user may call the passed method.
+func (u *rpcUser) allowed(method string) bool {
+	if u.admin {
+		return true
+	}
+	_, ok := u.methods[method]
+	return ok
+}
+
+// permissions returns a description of the methods the user may call for
+// logging.
+func (u *rpcUser) permissions() string {
+	if u.admin {
+		return rpcPermAdmin
+	}
+	methods := make([]string, 0, len(u.methods))
+	for method := range u.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return fmt.Sprintf("%d methods (%s)", len(methods),
+		strings.Join(methods, ", "))
+}
+
+// authenticate returns the user whose credentials match the passed value of
+// an HTTP Basic Authorization header, or nil when none does.
+//
+// This check is time-constant with respect to the credentials of each user.
+func (s *rpcServer) authenticate(authhdr string) *rpcUser {
+	authsha := sha256.Sum256([]byte(authhdr))
+
+	var match *rpcUser
+	for _, user := range s.users {
+		cmp := subtle.ConstantTimeCompare(authsha[:], user.authsha[:])
+		if cmp == 1 && match == nil {
+			match = user
+		}
+	}
+	return match
+}
+
+// rpcCookiePath returns the path of the cookie file.
+func rpcCookiePath() string {
+	if cfg.RPCCookieFile != "" {
+		return cfg.RPCCookieFile
+	}
+	return filepath.Join(cfg.DataDir, rpcCookieFileName)
+}
+
+// generateRPCCookie writes new random credentials for the admin user to the
+// cookie file, which is only readable by the current user, and returns the
+// user.  The cookie is regenerated each time the RPC server starts, so clients
+// running on the same machine can authenticate by reading the file without a
+// password being configured.
+func generateRPCCookie(path string) (*rpcUser, error) {
+	var randomBytes [32]byte
+	if _, err := rand.Read(randomBytes[:]); err != nil {
+		return nil, err
+	}
+	pass := hex.EncodeToString(randomBytes[:])
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	// Write the cookie to a temporary file first, so clients never read a
+	// partially written cookie.
+	tmpPath := path + ".tmp"
+	err := os.WriteFile(tmpPath, []byte(rpcCookieUser+":"+pass), 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	return newRPCUser(rpcCookieUser, pass, true, nil), nil
+}
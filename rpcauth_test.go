@@ -0,0 +1,153 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseRPCAuth ensures users defined with the rpcauth option are parsed
+// with the expected permissions and invalid users are rejected.
+func TestParseRPCAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    string
+		allowed []string
+		denied  []string
+		admin   bool
+		err     bool
+	}{{
+		name:  "admin",
+		auth:  "alice:secret:admin",
+		admin: true,
+	}, {
+		name:    "readonly",
+		auth:    "monitor:secret:readonly",
+		allowed: []string{"getblockcount", "getrawmempool", "notifyblocks"},
+		denied:  []string{"sendrawtransaction", "submitblock", "stop"},
+	}, {
+		name:    "limited",
+		auth:    "limited:secret:limited",
+		allowed: []string{"getblockcount", "sendrawtransaction"},
+		denied:  []string{"stop", "setban"},
+	}, {
+		name:    "wallet",
+		auth:    "wallet:secret:wallet",
+		allowed: []string{"sendrawtransaction", "testmempoolaccept", "getbalance", "rescanblocks"},
+		denied:  []string{"stop", "addnode"},
+	}, {
+		name:    "class and methods",
+		auth:    "monitor:secret:readonly, getpeerinfo,setban",
+		allowed: []string{"getblockcount", "getpeerinfo", "setban"},
+		denied:  []string{"sendrawtransaction", "addnode"},
+	}, {
+		name: "colon in password",
+		auth: "bob:a:b:getpeerinfo",
+		err:  true,
+	}, {
+		name: "unknown permission",
+		auth: "bob:secret:getpeers",
+		err:  true,
+	}, {
+		name: "missing permissions",
+		auth: "bob:secret",
+		err:  true,
+	}, {
+		name: "empty password",
+		auth: "bob::readonly",
+		err:  true,
+	}, {
+		name: "reserved username",
+		auth: rpcCookieUser + ":secret:admin",
+		err:  true,
+	}}
+
+	for _, test := range tests {
+		user, err := parseRPCAuth(test.auth)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if user.admin != test.admin {
+			t.Errorf("%s: admin is %v, want %v", test.name,
+				user.admin, test.admin)
+		}
+		for _, method := range test.allowed {
+			if !user.allowed(method) {
+				t.Errorf("%s: %s is not allowed", test.name, method)
+			}
+		}
+		for _, method := range test.denied {
+			if user.allowed(method) {
+				t.Errorf("%s: %s is allowed", test.name, method)
+			}
+		}
+	}
+}
+
+// TestRPCAuthRedacted ensures passwords are not included in rpcauth errors.
+func TestRPCAuthRedacted(t *testing.T) {
+	_, err := parseRPCAuth("bob:hunter2")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("error contains the password: %v", err)
+	}
+}
+
+// TestRPCAuthenticate ensures clients are authenticated as the user whose
+// credentials they supply, including the credentials of the cookie file.
+func TestRPCAuthenticate(t *testing.T) {
+	cookiePath := filepath.Join(t.TempDir(), "data", rpcCookieFileName)
+	cookieUser, err := generateRPCCookie(cookiePath)
+	if err != nil {
+		t.Fatalf("unable to generate cookie: %v", err)
+	}
+	cookie, err := os.ReadFile(cookiePath)
+	if err != nil {
+		t.Fatalf("unable to read cookie: %v", err)
+	}
+	if info, err := os.Stat(cookiePath); err != nil {
+		t.Fatalf("unable to stat cookie: %v", err)
+	} else if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("cookie permissions are %v, want 0600", perm)
+	}
+	if !strings.HasPrefix(string(cookie), rpcCookieUser+":") {
+		t.Fatalf("unexpected cookie %q", cookie)
+	}
+
+	monitor, err := parseRPCAuth("monitor:secret:readonly")
+	if err != nil {
+		t.Fatalf("unable to parse user: %v", err)
+	}
+	s := &rpcServer{users: []*rpcUser{cookieUser, monitor}}
+
+	basic := func(login string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	}
+	if user := s.authenticate(basic(string(cookie))); user != cookieUser {
+		t.Fatalf("cookie credentials authenticated as %v", user)
+	}
+	if !cookieUser.allowed("stop") {
+		t.Fatal("cookie user may not call stop")
+	}
+	if user := s.authenticate(basic("monitor:secret")); user != monitor {
+		t.Fatalf("monitor credentials authenticated as %v", user)
+	}
+	if user := s.authenticate(basic("monitor:wrong")); user != nil {
+		t.Fatalf("wrong credentials authenticated as %s", user.name)
+	}
+}
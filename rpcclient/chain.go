@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -380,6 +381,44 @@ func (c *Client) GetChainTxStatsNBlocksBlockHash(nBlocks int32, blockHash chainh
 	return c.GetChainTxStatsNBlocksBlockHashAsync(nBlocks, blockHash).Receive()
 }
 
+// FutureGetIndexInfoResult is a future promise to deliver the result of a
+// GetIndexInfoAsync RPC invocation (or an applicable error).
+type FutureGetIndexInfoResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the status
+// of the indexes keyed by their names.
+func (r FutureGetIndexInfoResult) Receive() (map[string]btcjson.GetIndexInfoResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexInfo map[string]btcjson.GetIndexInfoResult
+	err = json.Unmarshal(res, &indexInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return indexInfo, nil
+}
+
+// GetIndexInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetIndexInfo for the blocking version and more details.
+func (c *Client) GetIndexInfoAsync(indexName *string) FutureGetIndexInfoResult {
+	cmd := btcjson.NewGetIndexInfoCmd(indexName)
+	return c.SendCmd(cmd)
+}
+
+// GetIndexInfo returns the status of the enabled indexes keyed by their names.
+// Only the status of the index with the passed name is returned when it isn't
+// nil.
+func (c *Client) GetIndexInfo(indexName *string) (map[string]btcjson.GetIndexInfoResult, error) {
+	return c.GetIndexInfoAsync(indexName).Receive()
+}
+
 // FutureGetDifficultyResult is a future promise to deliver the result of a
 // GetDifficultyAsync RPC invocation (or an applicable error).
 type FutureGetDifficultyResult chan *Response
@@ -582,6 +621,55 @@ func (c *Client) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
 	return c.GetBlockHashAsync(blockHeight).Receive()
 }
 
+// FutureGetBlockHashesResult is a future promise to deliver the result of a
+// GetBlockHashesAsync RPC invocation (or an applicable error).
+type FutureGetBlockHashesResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of the blocks within the requested range of time.
+func (r FutureGetBlockHashesResult) Receive() ([]chainhash.Hash, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashStrs []string
+	err = json.Unmarshal(res, &hashStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]chainhash.Hash, len(hashStrs))
+	for i, hashStr := range hashStrs {
+		hash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = *hash
+	}
+	return hashes, nil
+}
+
+// GetBlockHashesAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetBlockHashes for the blocking version and more details.
+func (c *Client) GetBlockHashesAsync(high, low uint32) FutureGetBlockHashesResult {
+	cmd := btcjson.NewGetBlockHashesCmd(high, low)
+	return c.SendCmd(cmd)
+}
+
+// GetBlockHashes returns the hashes of the blocks in the main chain with a
+// median time greater than or equal to low and less than high, both given as
+// unix timestamps.
+//
+// NOTE: This is a btcd extension which requires the timestamp index to be
+// enabled.
+func (c *Client) GetBlockHashes(high, low uint32) ([]chainhash.Hash, error) {
+	return c.GetBlockHashesAsync(high, low).Receive()
+}
+
 // FutureGetBlockHeaderResult is a future promise to deliver the result of a
 // GetBlockHeaderAsync RPC invocation (or an applicable error).
 type FutureGetBlockHeaderResult chan *Response
@@ -1063,6 +1151,139 @@ func (c *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
 
+// FutureGetSpentInfoResult is a future promise to deliver the result of a
+// GetSpentInfoAsync RPC invocation (or an applicable error).
+type FutureGetSpentInfoResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// transaction input which spends the requested output.
+func (r FutureGetSpentInfoResult) Receive() (*btcjson.GetSpentInfoResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var spentInfo btcjson.GetSpentInfoResult
+	err = json.Unmarshal(res, &spentInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spentInfo, nil
+}
+
+// GetSpentInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetSpentInfo for the blocking version and more details.
+func (c *Client) GetSpentInfoAsync(txHash *chainhash.Hash, index uint32) FutureGetSpentInfoResult {
+	hash := ""
+	if txHash != nil {
+		hash = txHash.String()
+	}
+
+	cmd := btcjson.NewGetSpentInfoCmd(hash, index)
+	return c.SendCmd(cmd)
+}
+
+// GetSpentInfo returns the transaction input which spends the output with the
+// passed transaction hash and index in the main chain.
+//
+// NOTE: This is a btcd extension which requires the spent index to be enabled.
+func (c *Client) GetSpentInfo(txHash *chainhash.Hash, index uint32) (*btcjson.GetSpentInfoResult, error) {
+	return c.GetSpentInfoAsync(txHash, index).Receive()
+}
+
+// FutureGetAddressBalanceResult is a future promise to deliver the result of a
+// GetAddressBalanceAsync RPC invocation (or an applicable error).
+type FutureGetAddressBalanceResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// balance of the requested addresses.
+func (r FutureGetAddressBalanceResult) Receive() (*btcjson.GetAddressBalanceResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance btcjson.GetAddressBalanceResult
+	err = json.Unmarshal(res, &balance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+// GetAddressBalanceAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetAddressBalance for the blocking version and more details.
+func (c *Client) GetAddressBalanceAsync(addresses []btcutil.Address) FutureGetAddressBalanceResult {
+	cmd := btcjson.NewGetAddressBalanceCmd(encodeAddresses(addresses))
+	return c.SendCmd(cmd)
+}
+
+// GetAddressBalance returns the balance of the passed addresses along with the
+// total amount they received in the main chain.
+//
+// NOTE: This is a btcd extension which requires the balance index to be
+// enabled.
+func (c *Client) GetAddressBalance(addresses []btcutil.Address) (*btcjson.GetAddressBalanceResult, error) {
+	return c.GetAddressBalanceAsync(addresses).Receive()
+}
+
+// FutureGetAddressUtxosResult is a future promise to deliver the result of a
+// GetAddressUtxosAsync RPC invocation (or an applicable error).
+type FutureGetAddressUtxosResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// unspent outputs of the requested addresses.
+func (r FutureGetAddressUtxosResult) Receive() ([]btcjson.GetAddressUtxosResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []btcjson.GetAddressUtxosResult
+	err = json.Unmarshal(res, &utxos)
+	if err != nil {
+		return nil, err
+	}
+
+	return utxos, nil
+}
+
+// GetAddressUtxosAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetAddressUtxos for the blocking version and more details.
+func (c *Client) GetAddressUtxosAsync(addresses []btcutil.Address) FutureGetAddressUtxosResult {
+	cmd := btcjson.NewGetAddressUtxosCmd(encodeAddresses(addresses))
+	return c.SendCmd(cmd)
+}
+
+// GetAddressUtxos returns the unspent outputs of the passed addresses in the
+// main chain in the order they were created.
+//
+// NOTE: This is a btcd extension which requires the balance index to be
+// enabled.
+func (c *Client) GetAddressUtxos(addresses []btcutil.Address) ([]btcjson.GetAddressUtxosResult, error) {
+	return c.GetAddressUtxosAsync(addresses).Receive()
+}
+
+// encodeAddresses returns the encoded form of the passed addresses.
+func encodeAddresses(addresses []btcutil.Address) []string {
+	encoded := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		encoded = append(encoded, addr.EncodeAddress())
+	}
+	return encoded
+}
+
 // FutureGetTxOutSetInfoResult is a future promise to deliver the result of a
 // GetTxOutSetInfoAsync RPC invocation (or an applicable error).
 type FutureGetTxOutSetInfoResult chan *Response
@@ -1091,7 +1312,7 @@ func (r FutureGetTxOutSetInfoResult) Receive() (*btcjson.GetTxOutSetInfoResult,
 //
 // See GetTxOutSetInfo for the blocking version and more details.
 func (c *Client) GetTxOutSetInfoAsync() FutureGetTxOutSetInfoResult {
-	cmd := btcjson.NewGetTxOutSetInfoCmd()
+	cmd := btcjson.NewGetTxOutSetInfoCmd(nil)
 	return c.SendCmd(cmd)
 }
 
@@ -1188,6 +1409,40 @@ func (c *Client) InvalidateBlock(blockHash *chainhash.Hash) error {
 	return c.InvalidateBlockAsync(blockHash).Receive()
 }
 
+// FuturePreciousBlockResult is a future promise to deliver the result of a
+// PreciousBlockAsync RPC invocation (or an applicable error).
+type FuturePreciousBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if the block could not be marked precious.
+func (r FuturePreciousBlockResult) Receive() error {
+	_, err := ReceiveFuture(r)
+
+	return err
+}
+
+// PreciousBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See PreciousBlock for the blocking version and more details.
+func (c *Client) PreciousBlockAsync(blockHash *chainhash.Hash) FuturePreciousBlockResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewPreciousBlockCmd(hash)
+	return c.SendCmd(cmd)
+}
+
+// PreciousBlock treats a block as if it were received before other blocks
+// with the same work, making it the tip of the main chain when it has as much
+// work as the current tip.
+func (c *Client) PreciousBlock(blockHash *chainhash.Hash) error {
+	return c.PreciousBlockAsync(blockHash).Receive()
+}
+
 // FutureGetCFilterResult is a future promise to deliver the result of a
 // GetCFilterAsync RPC invocation (or an applicable error).
 type FutureGetCFilterResult chan *Response
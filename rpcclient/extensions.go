@@ -306,6 +306,71 @@ func (c *Client) GetHeaders(blockLocators []chainhash.Hash, hashStop *chainhash.
 	return c.GetHeadersAsync(blockLocators, hashStop).Receive()
 }
 
+// FutureGetBlockHeadersResult is a future promise to deliver the result of a
+// getblockheaders RPC invocation (or an applicable error).
+//
+// NOTE: This is a btcd extension.
+type FutureGetBlockHeadersResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the block
+// headers of the requested range.
+//
+// NOTE: This is a btcd extension.
+func (r FutureGetBlockHeadersResult) Receive() ([]wire.BlockHeader, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a string of concatenated serialized headers.
+	var headersHex string
+	err = json.Unmarshal(res, &headersHex)
+	if err != nil {
+		return nil, err
+	}
+	serialized, err := hex.DecodeString(headersHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(serialized)%wire.MaxBlockHeaderPayload != 0 {
+		return nil, fmt.Errorf("serialized headers of %d bytes are "+
+			"not a multiple of the header size", len(serialized))
+	}
+
+	// Deserialize the headers one after the other.
+	headers := make([]wire.BlockHeader, len(serialized)/wire.MaxBlockHeaderPayload)
+	reader := bytes.NewReader(serialized)
+	for i := range headers {
+		if err := headers[i].Deserialize(reader); err != nil {
+			return nil, err
+		}
+	}
+	return headers, nil
+}
+
+// GetBlockHeadersAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetBlockHeaders for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetBlockHeadersAsync(startHeight int32, count uint32) FutureGetBlockHeadersResult {
+	start := btcjson.HashOrHeight{Value: int(startHeight)}
+	cmd := btcjson.NewGetBlockHeadersCmd(start, count, btcjson.String("raw"))
+	return c.SendCmd(cmd)
+}
+
+// GetBlockHeaders returns the headers of up to count consecutive main chain
+// blocks starting at the passed height.  Fewer headers are returned when the
+// range extends past the best block.  The headers are requested in the raw
+// format, which is the most compact one.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetBlockHeaders(startHeight int32, count uint32) ([]wire.BlockHeader, error) {
+	return c.GetBlockHeadersAsync(startHeight, count).Receive()
+}
+
 // FutureExportWatchingWalletResult is a future promise to deliver the result of
 // an ExportWatchingWalletAsync RPC invocation (or an applicable error).
 type FutureExportWatchingWalletResult chan *Response
@@ -108,6 +108,68 @@ func (c *Client) GenerateToAddress(numBlocks int64, address btcutil.Address, max
 	return c.GenerateToAddressAsync(numBlocks, address, maxTries).Receive()
 }
 
+// GenerateToDescriptorAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GenerateToDescriptor for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GenerateToDescriptorAsync(numBlocks int64, descriptor string, maxTries *int64) FutureGenerateToAddressResult {
+	cmd := btcjson.NewGenerateToDescriptorCmd(numBlocks, descriptor, maxTries)
+	return c.SendCmd(cmd)
+}
+
+// GenerateToDescriptor generates numBlocks blocks paying to the address of the
+// given un-ranged descriptor and returns their hashes.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GenerateToDescriptor(numBlocks int64, descriptor string, maxTries *int64) ([]*chainhash.Hash, error) {
+	return c.GenerateToDescriptorAsync(numBlocks, descriptor, maxTries).Receive()
+}
+
+// FutureGenerateBlockResult is a future promise to deliver the result of a
+// GenerateBlockAsync RPC invocation (or an applicable error).
+type FutureGenerateBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hash
+// of the generated block.
+func (r FutureGenerateBlockResult) Receive() (*chainhash.Hash, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.GenerateBlockResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(result.Hash)
+}
+
+// GenerateBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GenerateBlock for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GenerateBlockAsync(output string, transactions []string) FutureGenerateBlockResult {
+	cmd := btcjson.NewGenerateBlockCmd(output, transactions)
+	return c.SendCmd(cmd)
+}
+
+// GenerateBlock generates a block containing exactly the given transactions,
+// in order, paying to the given address or descriptor and returns its hash.
+// Each transaction is either the id of a transaction in the memory pool or a
+// raw transaction in hex.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GenerateBlock(output string, transactions []string) (*chainhash.Hash, error) {
+	return c.GenerateBlockAsync(output, transactions).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *Response
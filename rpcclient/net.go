@@ -63,6 +63,101 @@ func (c *Client) AddNode(host string, command AddNodeCommand) error {
 	return c.AddNodeAsync(host, command).Receive()
 }
 
+// FutureSetBanResult is a future promise to deliver the result of a
+// SetBanAsync RPC invocation (or an applicable error).
+type FutureSetBanResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error if
+// any occurred when performing the specified command.
+func (r FutureSetBanResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// SetBanAsync returns an instance of a type that can be used to get the result
+// of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SetBan for the blocking version and more details.
+func (c *Client) SetBanAsync(subnet string, command btcjson.SetBanSubCmd,
+	banTime *int64, absolute *bool) FutureSetBanResult {
+
+	cmd := btcjson.NewSetBanCmd(subnet, command, banTime, absolute)
+	return c.SendCmd(cmd)
+}
+
+// SetBan bans the passed subnet, or a single IP address, or removes its ban.
+// The ban lasts for banTime seconds, or until the time banTime when absolute
+// is set.  The ban duration of the server is used when banTime is nil or 0.
+func (c *Client) SetBan(subnet string, command btcjson.SetBanSubCmd,
+	banTime *int64, absolute *bool) error {
+
+	return c.SetBanAsync(subnet, command, banTime, absolute).Receive()
+}
+
+// FutureListBannedResult is a future promise to deliver the result of a
+// ListBannedAsync RPC invocation (or an applicable error).
+type FutureListBannedResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the banned
+// subnets.
+func (r FutureListBannedResult) Receive() ([]btcjson.ListBannedResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal as an array of listbanned result objects.
+	var bans []btcjson.ListBannedResult
+	err = json.Unmarshal(res, &bans)
+	if err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+// ListBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ListBanned for the blocking version and more details.
+func (c *Client) ListBannedAsync() FutureListBannedResult {
+	cmd := btcjson.NewListBannedCmd()
+	return c.SendCmd(cmd)
+}
+
+// ListBanned returns the banned subnets.
+func (c *Client) ListBanned() ([]btcjson.ListBannedResult, error) {
+	return c.ListBannedAsync().Receive()
+}
+
+// FutureClearBannedResult is a future promise to deliver the result of a
+// ClearBannedAsync RPC invocation (or an applicable error).
+type FutureClearBannedResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error if
+// any occurred when performing the specified command.
+func (r FutureClearBannedResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// ClearBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ClearBanned for the blocking version and more details.
+func (c *Client) ClearBannedAsync() FutureClearBannedResult {
+	cmd := btcjson.NewClearBannedCmd()
+	return c.SendCmd(cmd)
+}
+
+// ClearBanned removes all bans and discouragements of peers.
+func (c *Client) ClearBanned() error {
+	return c.ClearBannedAsync().Receive()
+}
+
 // FutureNodeResult is a future promise to deliver the result of a NodeAsync
 // RPC invocation (or an applicable error).
 type FutureNodeResult chan *Response
@@ -308,14 +403,15 @@ func (r FutureGetNodeAddressesResult) Receive() ([]btcjson.GetNodeAddressesResul
 // returned instance.
 //
 // See GetNodeAddresses for the blocking version and more details.
-func (c *Client) GetNodeAddressesAsync(count *int32) FutureGetNodeAddressesResult {
-	cmd := btcjson.NewGetNodeAddressesCmd(count)
+func (c *Client) GetNodeAddressesAsync(count *int32, network *string) FutureGetNodeAddressesResult {
+	cmd := btcjson.NewGetNodeAddressesCmd(count, network)
 	return c.SendCmd(cmd)
 }
 
-// GetNodeAddresses returns data about known node addresses.
-func (c *Client) GetNodeAddresses(count *int32) ([]btcjson.GetNodeAddressesResult, error) {
-	return c.GetNodeAddressesAsync(count).Receive()
+// GetNodeAddresses returns data about known node addresses, optionally only
+// those of the passed network.
+func (c *Client) GetNodeAddresses(count *int32, network *string) ([]btcjson.GetNodeAddressesResult, error) {
+	return c.GetNodeAddressesAsync(count, network).Receive()
 }
 
 // FutureGetPeerInfoResult is a future promise to deliver the result of a
@@ -790,6 +790,45 @@ func (c *Client) SearchRawTransactions(address btcutil.Address, skip, count int,
 	return c.SearchRawTransactionsAsync(address, skip, count, reverse, filterAddrs).Receive()
 }
 
+// SearchRawTransactionsAfterAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See SearchRawTransactionsAfter for the blocking version and more details.
+func (c *Client) SearchRawTransactionsAfterAsync(address btcutil.Address,
+	after *chainhash.Hash, count int, reverse,
+	includeMempool bool) FutureSearchRawTransactionsResult {
+
+	addr := address.EncodeAddress()
+	verbose := btcjson.Int(0)
+	var afterStr *string
+	if after != nil {
+		afterStr = btcjson.String(after.String())
+	}
+	cmd := btcjson.NewSearchRawTransactionsCmd(addr, verbose, nil, &count,
+		nil, &reverse, nil)
+	cmd.IncludeMempool = &includeMempool
+	cmd.After = afterStr
+	return c.SendCmd(cmd)
+}
+
+// SearchRawTransactionsAfter returns up to count transactions that involve the
+// passed address and come after the transaction with the passed hash in the
+// requested order.  Passing the hash of the last transaction returned by the
+// previous call pages through all of the transactions of the address, while
+// passing nil starts from the first one.  The pages are not affected by new
+// transactions which involve the address.
+//
+// NOTE: Chain servers do not typically provide this capability unless it has
+// specifically been enabled.
+func (c *Client) SearchRawTransactionsAfter(address btcutil.Address,
+	after *chainhash.Hash, count int, reverse,
+	includeMempool bool) ([]*wire.MsgTx, error) {
+
+	return c.SearchRawTransactionsAfterAsync(address, after, count,
+		reverse, includeMempool).Receive()
+}
+
 // FutureSearchRawTransactionsVerboseResult is a future promise to deliver the
 // result of the SearchRawTransactionsVerboseAsync RPC invocation (or an
 // applicable error).
@@ -884,6 +923,103 @@ func (c *Client) DecodeScript(serializedScript []byte) (*btcjson.DecodeScriptRes
 	return c.DecodeScriptAsync(serializedScript).Receive()
 }
 
+// FutureDebugScriptResult is a future promise to deliver the result of a
+// DebugScriptAsync or DebugTxInputAsync RPC invocation (or an applicable
+// error).
+type FutureDebugScriptResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the trace
+// of the execution of the scripts.
+func (r FutureDebugScriptResult) Receive() (*btcjson.DebugScriptResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a debugscript result object.
+	var debugScriptResult btcjson.DebugScriptResult
+	err = json.Unmarshal(res, &debugScriptResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &debugScriptResult, nil
+}
+
+// DebugScriptAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DebugScript for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) DebugScriptAsync(pkScript, sigScript []byte,
+	witness wire.TxWitness, amount btcutil.Amount) FutureDebugScriptResult {
+
+	witnessHex := make([]string, 0, len(witness))
+	for _, item := range witness {
+		witnessHex = append(witnessHex, hex.EncodeToString(item))
+	}
+	cmd := btcjson.NewDebugScriptCmd(
+		hex.EncodeToString(pkScript),
+		btcjson.String(hex.EncodeToString(sigScript)), &witnessHex,
+		btcjson.Float64(amount.ToBTC()), nil, nil,
+	)
+	return c.SendCmd(cmd)
+}
+
+// DebugScript executes the passed signature script and witness against the
+// passed public key script of an output of the passed amount, and returns the
+// trace of the execution.  Signatures are checked against a dummy transaction,
+// so see DebugTxInput to debug the input of an actual transaction.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) DebugScript(pkScript, sigScript []byte,
+	witness wire.TxWitness,
+	amount btcutil.Amount) (*btcjson.DebugScriptResult, error) {
+
+	return c.DebugScriptAsync(pkScript, sigScript, witness, amount).Receive()
+}
+
+// DebugTxInputAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DebugTxInput for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) DebugTxInputAsync(pkScript []byte, tx *wire.MsgTx,
+	inputIndex uint32, amount btcutil.Amount) FutureDebugScriptResult {
+
+	txHex := ""
+	if tx != nil {
+		// Serialize the transaction and convert to hex string.
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return newFutureError(err)
+		}
+		txHex = hex.EncodeToString(buf.Bytes())
+	}
+
+	cmd := btcjson.NewDebugScriptCmd(
+		hex.EncodeToString(pkScript), nil, nil,
+		btcjson.Float64(amount.ToBTC()), &txHex, &inputIndex,
+	)
+	return c.SendCmd(cmd)
+}
+
+// DebugTxInput executes the scripts of the passed input of the passed
+// transaction against the passed public key script of the output of the passed
+// amount it spends, and returns the trace of the execution.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) DebugTxInput(pkScript []byte, tx *wire.MsgTx,
+	inputIndex uint32,
+	amount btcutil.Amount) (*btcjson.DebugScriptResult, error) {
+
+	return c.DebugTxInputAsync(pkScript, tx, inputIndex, amount).Receive()
+}
+
 // FutureTestMempoolAcceptResult is a future promise to deliver the result
 // of a TestMempoolAccept RPC invocation (or an applicable error).
 type FutureTestMempoolAcceptResult chan *Response
@@ -1015,6 +1151,69 @@ func (c *Client) TestMempoolAccept(txns []*wire.MsgTx,
 	return c.TestMempoolAcceptAsync(txns, maxFeeRate).Receive()
 }
 
+// FutureSubmitPackageResult is a future promise to deliver the result of a
+// SubmitPackage RPC invocation (or an applicable error).
+type FutureSubmitPackageResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// response from SubmitPackage.
+func (r FutureSubmitPackageResult) Receive() (*btcjson.SubmitPackageResult,
+	error) {
+
+	response, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.SubmitPackageResult
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SubmitPackageAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SubmitPackage for the blocking version and more details.
+func (c *Client) SubmitPackageAsync(txns []*wire.MsgTx, maxFeeRate,
+	maxBurnAmount *float64) FutureSubmitPackageResult {
+
+	if len(txns) == 0 {
+		err := fmt.Errorf("%w: no transactions provided",
+			ErrInvalidParam)
+		return newFutureError(err)
+	}
+
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidParam, err)
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := btcjson.NewSubmitPackageCmd(rawTxns, maxFeeRate, maxBurnAmount)
+	return c.SendCmd(cmd)
+}
+
+// SubmitPackage submits a package of a child transaction, which must come
+// last, and its parents sorted so that parents come before their children to
+// the mempool of the server.  Parents which don't pay enough fees on their own
+// are accepted when the fee rate of the package is high enough.
+//
+// The maxFeeRate and maxBurnAmount parameters are optional, nil uses the
+// defaults of the server.
+func (c *Client) SubmitPackage(txns []*wire.MsgTx, maxFeeRate,
+	maxBurnAmount *float64) (*btcjson.SubmitPackageResult, error) {
+
+	return c.SubmitPackageAsync(txns, maxFeeRate, maxBurnAmount).Receive()
+}
+
 // FutureGetTxSpendingPrevOut is a future promise to deliver the result of a
 // GetTxSpendingPrevOut RPC invocation (or an applicable error).
 type FutureGetTxSpendingPrevOut chan *Response
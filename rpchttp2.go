@@ -0,0 +1,17 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build go1.24
+// +build go1.24
+
+package main
+
+import "net/http"
+
+// configureRPCHTTP2 applies the HTTP/2 options to the passed RPC HTTP server.
+func configureRPCHTTP2(srv *http.Server) {
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams: int(cfg.RPCMaxStreams),
+	}
+}
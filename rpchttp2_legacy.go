@@ -0,0 +1,20 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !go1.24
+// +build !go1.24
+
+package main
+
+import "net/http"
+
+// configureRPCHTTP2 applies the HTTP/2 options to the passed RPC HTTP server.
+// The HTTP/2 server of the standard library can't be configured before Go
+// 1.24, so its default maximum number of concurrent streams is used instead.
+func configureRPCHTTP2(srv *http.Server) {
+	if cfg.RPCMaxStreams != defaultRPCMaxStreams {
+		rpcsLog.Warnf("The rpcmaxconcurrentstreams option requires btcd " +
+			"to be built with Go 1.24 or later and is ignored")
+	}
+}
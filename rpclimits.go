@@ -0,0 +1,211 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/connmgr"
+)
+
+// rpcLimiterPruneInterval is the minimum time between removals of the rate
+// limit buckets of clients which didn't call the limited methods recently.
+const rpcLimiterPruneInterval = time.Minute
+
+// rpcRateLimit limits how often each client may call a method.
+type rpcRateLimit struct {
+	method   string
+	calls    int
+	interval time.Duration
+}
+
+// String returns the rate limit in the form it is configured in.
+func (l *rpcRateLimit) String() string {
+	return fmt.Sprintf("%s:%d/%v", l.method, l.calls, l.interval)
+}
+
+// parseRPCRateLimit parses a rate limit defined with the rpcratelimit option in
+// the form <method>:<calls>/<interval>, such as scantxoutset:1/1m.  Each client
+// may call the method up to the number of calls at once, after which calls are
+// allowed again at the rate of the number of calls per interval.
+func parseRPCRateLimit(limit string) (*rpcRateLimit, error) {
+	parts := strings.SplitN(limit, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed rate limit %q -- expected "+
+			"<method>:<calls>/<interval>", limit)
+	}
+	method, rate := parts[0], parts[1]
+	if !isKnownRPCMethod(method) {
+		return nil, fmt.Errorf("unknown method %q in rate limit %q",
+			method, limit)
+	}
+
+	parts = strings.SplitN(rate, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed rate limit %q -- expected "+
+			"<method>:<calls>/<interval>", limit)
+	}
+	calls, err := strconv.Atoi(parts[0])
+	if err != nil || calls < 1 {
+		return nil, fmt.Errorf("invalid number of calls in rate limit "+
+			"%q -- must be at least 1", limit)
+	}
+	interval, err := time.ParseDuration(parts[1])
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid interval in rate limit %q -- "+
+			"must be a positive duration such as 1s or 1m", limit)
+	}
+
+	return &rpcRateLimit{
+		method:   method,
+		calls:    calls,
+		interval: interval,
+	}, nil
+}
+
+// rpcBucketKey identifies the rate limit bucket of a client for a method.
+type rpcBucketKey struct {
+	client string
+	method string
+}
+
+// rpcBucket is the rate limit bucket of a client for a method along with the
+// time the client last called the method.
+type rpcBucket struct {
+	tokens   *connmgr.TokenBucket
+	lastCall time.Time
+}
+
+// rpcRateLimiter limits how often each client may call the methods which have a
+// rate limit with a token bucket per client and method.  Clients are identified
+// by their IP address, so the connections a client makes for each HTTP POST
+// request share the same buckets.
+type rpcRateLimiter struct {
+	limits map[string]*rpcRateLimit
+
+	mtx       sync.Mutex
+	buckets   map[rpcBucketKey]*rpcBucket
+	lastPrune time.Time
+}
+
+// newRPCRateLimiter returns a rate limiter which enforces the passed limits.
+func newRPCRateLimiter(limits []*rpcRateLimit) *rpcRateLimiter {
+	limiter := &rpcRateLimiter{
+		limits:  make(map[string]*rpcRateLimit, len(limits)),
+		buckets: make(map[rpcBucketKey]*rpcBucket),
+	}
+	for _, limit := range limits {
+		limiter.limits[limit.method] = limit
+	}
+	return limiter
+}
+
+// rpcClientHost returns the host of the passed remote address of a client,
+// which identifies the client for rate limiting.
+func rpcClientHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// allow returns zero when the passed client may call the passed method and
+// takes one call from its bucket.  Otherwise, it returns how long the client
+// must wait until it may call the method again.
+//
+// This function is safe for concurrent access.
+func (l *rpcRateLimiter) allow(remoteAddr, method string) time.Duration {
+	limit, ok := l.limits[method]
+	if !ok {
+		return 0
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	key := rpcBucketKey{client: rpcClientHost(remoteAddr), method: method}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		calls := float64(limit.calls)
+		bucket = &rpcBucket{
+			tokens: connmgr.NewTokenBucket(
+				calls/limit.interval.Seconds(), calls, calls),
+		}
+		l.buckets[key] = bucket
+	}
+	bucket.lastCall = now
+	return bucket.tokens.TryTake(1)
+}
+
+// prune removes the buckets of the clients which didn't call their method for
+// at least its interval, since their buckets are full again and therefore the
+// same as new ones.
+//
+// This function MUST be called with the limiter lock held (for writes).
+func (l *rpcRateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rpcLimiterPruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, bucket := range l.buckets {
+		interval := l.limits[key.method].interval
+		if now.Sub(bucket.lastCall) >= interval {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// methodDisabled returns whether the passed method was disabled with the
+// rpcdisablemethod option or not enabled with the rpcallowmethod option.
+func methodDisabled(method string) bool {
+	if _, ok := cfg.rpcDisabledMethods[method]; ok {
+		return true
+	}
+	if len(cfg.rpcAllowedMethods) == 0 {
+		return false
+	}
+	_, ok := cfg.rpcAllowedMethods[method]
+	return !ok
+}
+
+// checkMethodLimits returns an error when the passed method is disabled or the
+// client with the passed remote address exceeded the rate limit of the method.
+// It takes a call from the rate limit bucket of the client otherwise.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) checkMethodLimits(remoteAddr, method string) *btcjson.RPCError {
+	if methodDisabled(method) {
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMethodDisabled,
+			Message: fmt.Sprintf("Method disabled: %s", method),
+		}
+	}
+
+	if delay := s.limiter.allow(remoteAddr, method); delay > 0 {
+		limit := s.limiter.limits[method]
+		retry := math.Ceil(delay.Seconds())
+		rpcsLog.Debugf("Rate limited %s from %s (limit %v)", method,
+			remoteAddr, limit)
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCLimited,
+			Message: fmt.Sprintf("Rate limited: %s may be called %d "+
+				"time(s) per %v, retry after %v seconds", method,
+				limit.calls, limit.interval, retry),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// TestParseRPCRateLimit ensures rate limits defined with the rpcratelimit
+// option are parsed and invalid ones are rejected.
+func TestParseRPCRateLimit(t *testing.T) {
+	tests := []struct {
+		limit    string
+		calls    int
+		interval time.Duration
+		err      bool
+	}{
+		{limit: "scantxoutset:1/1m", calls: 1, interval: time.Minute},
+		{limit: "searchrawtransactions:10/1s", calls: 10, interval: time.Second},
+		{limit: "scantxoutset", err: true},
+		{limit: "scantxoutset:1", err: true},
+		{limit: "scantxoutset:0/1s", err: true},
+		{limit: "scantxoutset:1/0s", err: true},
+		{limit: "scantxoutset:1/1", err: true},
+		{limit: "scantxoutsets:1/1s", err: true},
+	}
+
+	for _, test := range tests {
+		limit, err := parseRPCRateLimit(test.limit)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.limit)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.limit, err)
+			continue
+		}
+		if limit.calls != test.calls || limit.interval != test.interval {
+			t.Errorf("%s: parsed %d calls per %v, want %d per %v",
+				test.limit, limit.calls, limit.interval, test.calls,
+				test.interval)
+		}
+	}
+}
+
+// TestRPCRateLimiter ensures each client may call a rate limited method up to
+// its burst before it is limited, independently of other clients and methods.
+func TestRPCRateLimiter(t *testing.T) {
+	limiter := newRPCRateLimiter([]*rpcRateLimit{{
+		method:   "scantxoutset",
+		calls:    2,
+		interval: time.Hour,
+	}})
+
+	for i := 0; i < 2; i++ {
+		if delay := limiter.allow("127.0.0.1:1000", "scantxoutset"); delay != 0 {
+			t.Fatalf("call %d within the burst delayed by %v", i, delay)
+		}
+	}
+
+	// The connections of a client share its bucket.
+	delay := limiter.allow("127.0.0.1:1001", "scantxoutset")
+	if delay <= 0 || delay > 30*time.Minute {
+		t.Fatalf("call exceeding the burst delayed by %v, want up to "+
+			"30m", delay)
+	}
+
+	// Other clients and methods are not limited.
+	if delay := limiter.allow("[::1]:1000", "scantxoutset"); delay != 0 {
+		t.Fatalf("call of another client delayed by %v", delay)
+	}
+	if delay := limiter.allow("127.0.0.1:1000", "getblockcount"); delay != 0 {
+		t.Fatalf("call of an unlimited method delayed by %v", delay)
+	}
+
+	// The buckets of clients which didn't call the method for its
+	// interval are removed.
+	if len(limiter.buckets) != 2 {
+		t.Fatalf("limiter has %d buckets, want 2", len(limiter.buckets))
+	}
+	limiter.mtx.Lock()
+	limiter.prune(time.Now().Add(2 * time.Hour))
+	limiter.mtx.Unlock()
+	if len(limiter.buckets) != 0 {
+		t.Fatalf("limiter has %d buckets after pruning, want 0",
+			len(limiter.buckets))
+	}
+}
+
+// TestCheckMethodLimits ensures disabled methods, methods missing from the
+// allowed methods and calls exceeding a rate limit are rejected with the
+// expected errors.
+func TestCheckMethodLimits(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &config{
+		rpcDisabledMethods: map[string]struct{}{"stop": {}},
+	}
+
+	s := &rpcServer{limiter: newRPCRateLimiter([]*rpcRateLimit{{
+		method:   "scantxoutset",
+		calls:    1,
+		interval: time.Minute,
+	}})}
+
+	if jsonErr := s.checkMethodLimits("127.0.0.1:1000", "stop"); jsonErr == nil ||
+		jsonErr.Code != btcjson.ErrRPCMethodDisabled {
+
+		t.Fatalf("disabled method returned %v", jsonErr)
+	}
+	if jsonErr := s.checkMethodLimits("127.0.0.1:1000", "scantxoutset"); jsonErr != nil {
+		t.Fatalf("first call returned %v", jsonErr)
+	}
+	if jsonErr := s.checkMethodLimits("127.0.0.1:1000", "scantxoutset"); jsonErr == nil ||
+		jsonErr.Code != btcjson.ErrRPCLimited {
+
+		t.Fatalf("call exceeding the rate limit returned %v", jsonErr)
+	}
+
+	// Only the allowed methods may be called when any are specified.
+	cfg.rpcAllowedMethods = map[string]struct{}{"getblockcount": {}}
+	if jsonErr := s.checkMethodLimits("127.0.0.1:1000", "getblockcount"); jsonErr != nil {
+		t.Fatalf("allowed method returned %v", jsonErr)
+	}
+	if jsonErr := s.checkMethodLimits("127.0.0.1:1000", "getpeerinfo"); jsonErr == nil ||
+		jsonErr.Code != btcjson.ErrRPCMethodDisabled {
+
+		t.Fatalf("method which isn't allowed returned %v", jsonErr)
+	}
+}
@@ -0,0 +1,1028 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PSBT roles as defined in BIP 174, in the order they process a PSBT.  The
+// analyzepsbt RPC returns the role which has to process a PSBT next.
+const (
+	psbtRoleCreator = iota
+	psbtRoleUpdater
+	psbtRoleSigner
+	psbtRoleFinalizer
+	psbtRoleExtractor
+)
+
+// psbtRoleNames maps the PSBT roles to the names returned by analyzepsbt.
+var psbtRoleNames = []string{
+	psbtRoleCreator:   "creator",
+	psbtRoleUpdater:   "updater",
+	psbtRoleSigner:    "signer",
+	psbtRoleFinalizer: "finalizer",
+	psbtRoleExtractor: "extractor",
+}
+
+const (
+	// dummySignatureLen is the length of the signatures, including the
+	// sighash type, assumed when estimating the size of a PSBT which isn't
+	// signed yet.  It is the maximum length of DER encoded signatures.
+	dummySignatureLen = 72
+
+	// dummySchnorrSignatureLen is the length of the signatures of taproot
+	// key path spends assumed when estimating the size of a PSBT.
+	dummySchnorrSignatureLen = 64
+)
+
+// decodePsbt decodes the passed base64 encoded PSBT and returns an RPC error
+// when it's invalid.
+func decodePsbt(b64 string) (*psbt.Packet, error) {
+	packet, err := psbt.NewFromRawBytes(strings.NewReader(b64), true)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+	return packet, nil
+}
+
+// psbtInputUtxo returns the output spent by the input of the passed PSBT with
+// the passed index, or nil when it isn't known.
+func psbtInputUtxo(packet *psbt.Packet, index int) *wire.TxOut {
+	input := &packet.Inputs[index]
+	if input.WitnessUtxo != nil {
+		return input.WitnessUtxo
+	}
+	if input.NonWitnessUtxo == nil {
+		return nil
+	}
+	prevOut := packet.UnsignedTx.TxIn[index].PreviousOutPoint
+	if input.NonWitnessUtxo.TxHash() != prevOut.Hash ||
+		prevOut.Index >= uint32(len(input.NonWitnessUtxo.TxOut)) {
+
+		return nil
+	}
+	return input.NonWitnessUtxo.TxOut[prevOut.Index]
+}
+
+// psbtFee returns the fee of the passed PSBT, or false when the outputs spent
+// by any of its inputs aren't known.
+func psbtFee(packet *psbt.Packet) (int64, bool) {
+	var fee int64
+	for i := range packet.Inputs {
+		utxo := psbtInputUtxo(packet, i)
+		if utxo == nil {
+			return 0, false
+		}
+		fee += utxo.Value
+	}
+	for _, txOut := range packet.UnsignedTx.TxOut {
+		fee -= txOut.Value
+	}
+	return fee, true
+}
+
+// parseWitness parses the passed serialized witness of a finalized PSBT input.
+func parseWitness(serialized []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(serialized)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count > wire.MaxMessagePayload {
+		return nil, fmt.Errorf("too many witness items (%d)", count)
+	}
+	witness := make(wire.TxWitness, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(r, 0, wire.MaxMessagePayload,
+			"witness item")
+		if err != nil {
+			return nil, err
+		}
+		witness = append(witness, item)
+	}
+	return witness, nil
+}
+
+// sighashString returns the name of the passed sighash type as used by the
+// signrawtransactionwithkey RPC.
+func sighashString(hashType txscript.SigHashType) string {
+	var name string
+	switch hashType &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashDefault:
+		name = "DEFAULT"
+	case txscript.SigHashAll:
+		name = "ALL"
+	case txscript.SigHashNone:
+		name = "NONE"
+	case txscript.SigHashSingle:
+		name = "SINGLE"
+	default:
+		return strconv.FormatUint(uint64(hashType), 10)
+	}
+	if hashType&txscript.SigHashAnyOneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// formatFingerprint returns the hex encoding of the passed master key
+// fingerprint, which PSBTs store as a little-endian integer.
+func formatFingerprint(fingerprint uint32) string {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], fingerprint)
+	return hex.EncodeToString(b[:])
+}
+
+// formatBip32Path returns the passed BIP 32 derivation path in the form
+// m/44h/0h/0h/0/1.
+func formatBip32Path(path []uint32) string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, index := range path {
+		sb.WriteString("/")
+		if index >= hdkeychain.HardenedKeyStart {
+			hardened := index - hdkeychain.HardenedKeyStart
+			sb.WriteString(strconv.FormatUint(uint64(hardened), 10))
+			sb.WriteString("h")
+			continue
+		}
+		sb.WriteString(strconv.FormatUint(uint64(index), 10))
+	}
+	return sb.String()
+}
+
+// psbtScriptResult returns the JSON representation of the passed redeem or
+// witness script.
+func psbtScriptResult(script []byte) *btcjson.PsbtScriptResult {
+	if len(script) == 0 {
+		return nil
+	}
+
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(script)
+	return &btcjson.PsbtScriptResult{
+		Asm:  disbuf,
+		Hex:  hex.EncodeToString(script),
+		Type: txscript.GetScriptClass(script).String(),
+	}
+}
+
+// psbtBip32DerivsResult returns the JSON representation of the passed BIP 32
+// derivations.
+func psbtBip32DerivsResult(derivs []*psbt.Bip32Derivation) []btcjson.PsbtBip32DerivResult {
+	if len(derivs) == 0 {
+		return nil
+	}
+	result := make([]btcjson.PsbtBip32DerivResult, 0, len(derivs))
+	for _, deriv := range derivs {
+		result = append(result, btcjson.PsbtBip32DerivResult{
+			PubKey:            hex.EncodeToString(deriv.PubKey),
+			MasterFingerprint: formatFingerprint(deriv.MasterKeyFingerprint),
+			Path:              formatBip32Path(deriv.Bip32Path),
+		})
+	}
+	return result
+}
+
+// psbtTaprootBip32DerivsResult returns the JSON representation of the passed
+// taproot BIP 32 derivations.
+func psbtTaprootBip32DerivsResult(derivs []*psbt.TaprootBip32Derivation) []btcjson.PsbtTaprootBip32DerivResult {
+	if len(derivs) == 0 {
+		return nil
+	}
+	result := make([]btcjson.PsbtTaprootBip32DerivResult, 0, len(derivs))
+	for _, deriv := range derivs {
+		leafHashes := make([]string, 0, len(deriv.LeafHashes))
+		for _, leafHash := range deriv.LeafHashes {
+			leafHashes = append(leafHashes, hex.EncodeToString(leafHash))
+		}
+		result = append(result, btcjson.PsbtTaprootBip32DerivResult{
+			PubKey:            hex.EncodeToString(deriv.XOnlyPubKey),
+			MasterFingerprint: formatFingerprint(deriv.MasterKeyFingerprint),
+			Path:              formatBip32Path(deriv.Bip32Path),
+			LeafHashes:        leafHashes,
+		})
+	}
+	return result
+}
+
+// psbtUnknownsResult returns the unknown key-value pairs of a PSBT map as hex
+// encoded keys mapped to hex encoded values.
+func psbtUnknownsResult(unknowns []*psbt.Unknown) map[string]string {
+	if len(unknowns) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(unknowns))
+	for _, unknown := range unknowns {
+		result[hex.EncodeToString(unknown.Key)] =
+			hex.EncodeToString(unknown.Value)
+	}
+	return result
+}
+
+// parseTaprootTree parses the serialized taproot tree of a PSBT output into
+// its leaves, each of which is serialized as its depth, its leaf version and
+// its script.
+func parseTaprootTree(serialized []byte) ([]btcjson.PsbtTaprootLeafResult, error) {
+	var leaves []btcjson.PsbtTaprootLeafResult
+	r := bytes.NewReader(serialized)
+	for r.Len() > 0 {
+		depth, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		leafVersion, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		script, err := wire.ReadVarBytes(r, 0, wire.MaxMessagePayload,
+			"tapscript")
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, btcjson.PsbtTaprootLeafResult{
+			Depth:   int(depth),
+			LeafVer: int(leafVersion),
+			Script:  hex.EncodeToString(script),
+		})
+	}
+	return leaves, nil
+}
+
+// decodePsbtInput returns the JSON representation of the passed PSBT input.
+func decodePsbtInput(input *psbt.PInput, params *chaincfg.Params) btcjson.DecodePsbtInputResult {
+	var result btcjson.DecodePsbtInputResult
+	if input.NonWitnessUtxo != nil {
+		mtx := input.NonWitnessUtxo
+		result.NonWitnessUtxo = &btcjson.TxRawDecodeResult{
+			Txid:     mtx.TxHash().String(),
+			Version:  mtx.Version,
+			Locktime: mtx.LockTime,
+			Vin:      createVinList(mtx),
+			Vout:     createVoutList(mtx, params, nil),
+		}
+	}
+	if input.WitnessUtxo != nil {
+		result.WitnessUtxo = &btcjson.PsbtWitnessUtxoResult{
+			Amount: btcutil.Amount(input.WitnessUtxo.Value).ToBTC(),
+			ScriptPubKey: scriptPubKeyResult(input.WitnessUtxo.PkScript,
+				params),
+		}
+	}
+	if len(input.PartialSigs) > 0 {
+		result.PartialSignatures = make(map[string]string,
+			len(input.PartialSigs))
+		for _, sig := range input.PartialSigs {
+			result.PartialSignatures[hex.EncodeToString(sig.PubKey)] =
+				hex.EncodeToString(sig.Signature)
+		}
+	}
+	if input.SighashType != 0 {
+		result.Sighash = sighashString(input.SighashType)
+	}
+	result.RedeemScript = psbtScriptResult(input.RedeemScript)
+	result.WitnessScript = psbtScriptResult(input.WitnessScript)
+	result.Bip32Derivs = psbtBip32DerivsResult(input.Bip32Derivation)
+	if len(input.FinalScriptSig) > 0 {
+		disbuf, _ := txscript.DisasmString(input.FinalScriptSig)
+		result.FinalScriptSig = &btcjson.ScriptSig{
+			Asm: disbuf,
+			Hex: hex.EncodeToString(input.FinalScriptSig),
+		}
+	}
+	if len(input.FinalScriptWitness) > 0 {
+		// The witness was validated when the PSBT was decoded.
+		witness, _ := parseWitness(input.FinalScriptWitness)
+		result.FinalScriptWitness = witness.ToHexStrings()
+	}
+	if len(input.TaprootKeySpendSig) > 0 {
+		result.TaprootKeyPathSig = hex.EncodeToString(
+			input.TaprootKeySpendSig)
+	}
+	for _, sig := range input.TaprootScriptSpendSig {
+		result.TaprootScriptPathSigs = append(result.TaprootScriptPathSigs,
+			btcjson.PsbtTaprootScriptSigResult{
+				PubKey:   hex.EncodeToString(sig.XOnlyPubKey),
+				LeafHash: hex.EncodeToString(sig.LeafHash),
+				Sig:      hex.EncodeToString(sig.Signature),
+			})
+	}
+
+	// Leaf scripts are grouped along with all the control blocks proving
+	// their inclusion like in Bitcoin Core.
+	scriptIndexes := make(map[string]int)
+	for _, leaf := range input.TaprootLeafScript {
+		key := string(leaf.Script) + string([]byte{byte(leaf.LeafVersion)})
+		i, ok := scriptIndexes[key]
+		if !ok {
+			i = len(result.TaprootScripts)
+			scriptIndexes[key] = i
+			result.TaprootScripts = append(result.TaprootScripts,
+				btcjson.PsbtTaprootScriptResult{
+					Script:  hex.EncodeToString(leaf.Script),
+					LeafVer: int(leaf.LeafVersion),
+				})
+		}
+		result.TaprootScripts[i].ControlBlocks = append(
+			result.TaprootScripts[i].ControlBlocks,
+			hex.EncodeToString(leaf.ControlBlock))
+	}
+	result.TaprootBip32Derivs = psbtTaprootBip32DerivsResult(
+		input.TaprootBip32Derivation)
+	if len(input.TaprootInternalKey) > 0 {
+		result.TaprootInternalKey = hex.EncodeToString(
+			input.TaprootInternalKey)
+	}
+	if len(input.TaprootMerkleRoot) > 0 {
+		result.TaprootMerkleRoot = hex.EncodeToString(
+			input.TaprootMerkleRoot)
+	}
+	result.Unknown = psbtUnknownsResult(input.Unknowns)
+	return result
+}
+
+// decodePsbtOutput returns the JSON representation of the passed PSBT output.
+func decodePsbtOutput(output *psbt.POutput) (btcjson.DecodePsbtOutputResult, error) {
+	result := btcjson.DecodePsbtOutputResult{
+		RedeemScript:  psbtScriptResult(output.RedeemScript),
+		WitnessScript: psbtScriptResult(output.WitnessScript),
+		Bip32Derivs:   psbtBip32DerivsResult(output.Bip32Derivation),
+		TaprootBip32Derivs: psbtTaprootBip32DerivsResult(
+			output.TaprootBip32Derivation),
+		Unknown: psbtUnknownsResult(output.Unknowns),
+	}
+	if len(output.TaprootInternalKey) > 0 {
+		result.TaprootInternalKey = hex.EncodeToString(
+			output.TaprootInternalKey)
+	}
+	if len(output.TaprootTapTree) > 0 {
+		leaves, err := parseTaprootTree(output.TaprootTapTree)
+		if err != nil {
+			return result, err
+		}
+		result.TaprootTree = leaves
+	}
+	return result, nil
+}
+
+// handleDecodePsbt implements the decodepsbt command.
+func handleDecodePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodePsbtCmd)
+
+	packet, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+
+	params := s.cfg.ChainParams
+	mtx := packet.UnsignedTx
+	result := &btcjson.DecodePsbtResult{
+		Tx: btcjson.TxRawDecodeResult{
+			Txid:     mtx.TxHash().String(),
+			Version:  mtx.Version,
+			Locktime: mtx.LockTime,
+			Vin:      createVinList(mtx),
+			Vout:     createVoutList(mtx, params, nil),
+		},
+		// The released psbt module only decodes version 0 packets.
+		PsbtVersion: 0,
+		Unknown:     psbtUnknownsResult(packet.Unknowns),
+		Inputs:      make([]btcjson.DecodePsbtInputResult, 0, len(packet.Inputs)),
+		Outputs:     make([]btcjson.DecodePsbtOutputResult, 0, len(packet.Outputs)),
+	}
+	if result.Unknown == nil {
+		result.Unknown = make(map[string]string)
+	}
+	for i := range packet.Inputs {
+		result.Inputs = append(result.Inputs,
+			decodePsbtInput(&packet.Inputs[i], params))
+	}
+	for i := range packet.Outputs {
+		output, err := decodePsbtOutput(&packet.Outputs[i])
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCDeserialization,
+				Message: fmt.Sprintf("TX decode failed: invalid "+
+					"taproot tree of output %d: %v", i, err),
+			}
+		}
+		result.Outputs = append(result.Outputs, output)
+	}
+
+	if fee, ok := psbtFee(packet); ok && fee >= 0 {
+		feeBTC := btcutil.Amount(fee).ToBTC()
+		result.Fee = &feeBTC
+	}
+	return result, nil
+}
+
+// psbtSigningData houses the public keys and scripts known for an input of a
+// PSBT and the signatures it already has.
+type psbtSigningData struct {
+	input   *psbt.PInput
+	pubKeys map[[20]byte][]byte
+	sigs    map[[20]byte]struct{}
+}
+
+// newPsbtSigningData returns the signing data of the passed PSBT input.
+func newPsbtSigningData(input *psbt.PInput) *psbtSigningData {
+	data := &psbtSigningData{
+		input:   input,
+		pubKeys: make(map[[20]byte][]byte),
+		sigs:    make(map[[20]byte]struct{}),
+	}
+	for _, deriv := range input.Bip32Derivation {
+		var keyID [20]byte
+		copy(keyID[:], btcutil.Hash160(deriv.PubKey))
+		data.pubKeys[keyID] = deriv.PubKey
+	}
+	for _, sig := range input.PartialSigs {
+		var keyID [20]byte
+		copy(keyID[:], btcutil.Hash160(sig.PubKey))
+		data.pubKeys[keyID] = sig.PubKey
+		data.sigs[keyID] = struct{}{}
+	}
+	return data
+}
+
+// complete returns whether the input has the signatures needed to
+// spend the passed script, which is either the script of the output spent by
+// the input or one of its redeem or witness scripts.  The public keys,
+// signatures and scripts which are missing otherwise are added to the passed
+// result.
+func (d *psbtSigningData) complete(script []byte, missing *btcjson.AnalyzePsbtMissingResult) bool {
+	// needSig returns whether the signature of the key with the passed
+	// hash160 is known and adds it to the missing signatures, or its
+	// public key to the missing public keys when that isn't known either,
+	// otherwise.
+	needSig := func(keyID []byte) bool {
+		var id [20]byte
+		copy(id[:], keyID)
+		if _, ok := d.sigs[id]; ok {
+			return true
+		}
+		if _, ok := d.pubKeys[id]; ok {
+			missing.Signatures = append(missing.Signatures,
+				hex.EncodeToString(keyID))
+		} else {
+			missing.PubKeys = append(missing.PubKeys,
+				hex.EncodeToString(keyID))
+		}
+		return false
+	}
+
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy:
+		return needSig(script[3:23])
+
+	case txscript.WitnessV0PubKeyHashTy:
+		return needSig(script[2:22])
+
+	case txscript.PubKeyTy:
+		pushes, err := txscript.PushedData(script)
+		if err != nil || len(pushes) != 1 {
+			return false
+		}
+		return needSig(btcutil.Hash160(pushes[0]))
+
+	case txscript.MultiSigTy:
+		_, required, err := txscript.CalcMultiSigStats(script)
+		if err != nil {
+			return false
+		}
+		pubKeys, err := txscript.PushedData(script)
+		if err != nil {
+			return false
+		}
+		var sigs int
+		var missingSigs []string
+		for _, pubKey := range pubKeys {
+			keyID := btcutil.Hash160(pubKey)
+			var id [20]byte
+			copy(id[:], keyID)
+			if _, ok := d.sigs[id]; ok {
+				sigs++
+				continue
+			}
+			missingSigs = append(missingSigs, hex.EncodeToString(keyID))
+		}
+		if sigs >= required {
+			return true
+		}
+		missing.Signatures = append(missing.Signatures, missingSigs...)
+		return false
+
+	case txscript.ScriptHashTy:
+		redeemScript := d.input.RedeemScript
+		if len(redeemScript) == 0 ||
+			!bytes.Equal(btcutil.Hash160(redeemScript), script[2:22]) {
+
+			missing.RedeemScript = hex.EncodeToString(script[2:22])
+			return false
+		}
+		return d.complete(redeemScript, missing)
+
+	case txscript.WitnessV0ScriptHashTy:
+		witnessScript := d.input.WitnessScript
+		hash := chainhash.HashB(witnessScript)
+		if len(witnessScript) == 0 || !bytes.Equal(hash, script[2:34]) {
+			missing.WitnessScript = hex.EncodeToString(script[2:34])
+			return false
+		}
+		return d.complete(witnessScript, missing)
+
+	case txscript.WitnessV1TaprootTy:
+		// Only key path spends are recognized as complete, since the
+		// leaf scripts of script path spends aren't analyzed.
+		return len(d.input.TaprootKeySpendSig) > 0
+	}
+	return false
+}
+
+// dummySatisfaction returns the signature script and witness which spend the
+// passed output script of a PSBT input with signatures of the maximum length
+// in order to estimate the size of the transaction once it is signed.  It
+// returns false when the script isn't known or its redeem or witness script is
+// missing.
+func dummySatisfaction(input *psbt.PInput, pkScript []byte) ([]byte, wire.TxWitness, bool) {
+	// pushes returns the stack items which spend the passed script, which
+	// must not be a script hash.
+	pushes := func(script []byte) ([][]byte, bool) {
+		sig := make([]byte, dummySignatureLen)
+		pubKey := make([]byte, 33)
+		switch txscript.GetScriptClass(script) {
+		case txscript.PubKeyHashTy, txscript.WitnessV0PubKeyHashTy:
+			return [][]byte{sig, pubKey}, true
+
+		case txscript.PubKeyTy:
+			return [][]byte{sig}, true
+
+		case txscript.MultiSigTy:
+			_, required, err := txscript.CalcMultiSigStats(script)
+			if err != nil {
+				return nil, false
+			}
+			items := [][]byte{nil}
+			for i := 0; i < required; i++ {
+				items = append(items, sig)
+			}
+			return items, true
+		}
+		return nil, false
+	}
+
+	// witness returns the witness which spends the passed witness
+	// program.
+	witness := func(program []byte) (wire.TxWitness, bool) {
+		switch txscript.GetScriptClass(program) {
+		case txscript.WitnessV0PubKeyHashTy:
+			items, ok := pushes(program)
+			return items, ok
+
+		case txscript.WitnessV0ScriptHashTy:
+			if len(input.WitnessScript) == 0 {
+				return nil, false
+			}
+			items, ok := pushes(input.WitnessScript)
+			if !ok {
+				return nil, false
+			}
+			return append(items, input.WitnessScript), true
+
+		case txscript.WitnessV1TaprootTy:
+			sig := make([]byte, dummySchnorrSignatureLen)
+			return wire.TxWitness{sig}, true
+		}
+		return nil, false
+	}
+
+	// sigScript returns the signature script pushing the passed items.
+	sigScript := func(items [][]byte) ([]byte, bool) {
+		builder := txscript.NewScriptBuilder()
+		for _, item := range items {
+			builder.AddData(item)
+		}
+		script, err := builder.Script()
+		return script, err == nil
+	}
+
+	switch {
+	case txscript.IsPayToScriptHash(pkScript):
+		redeemScript := input.RedeemScript
+		if len(redeemScript) == 0 {
+			return nil, nil, false
+		}
+		if txscript.IsWitnessProgram(redeemScript) {
+			wit, ok := witness(redeemScript)
+			if !ok {
+				return nil, nil, false
+			}
+			script, ok := sigScript([][]byte{redeemScript})
+			return script, wit, ok
+		}
+		items, ok := pushes(redeemScript)
+		if !ok {
+			return nil, nil, false
+		}
+		script, ok := sigScript(append(items, redeemScript))
+		return script, nil, ok
+
+	case txscript.IsWitnessProgram(pkScript):
+		wit, ok := witness(pkScript)
+		return nil, wit, ok
+	}
+
+	items, ok := pushes(pkScript)
+	if !ok {
+		return nil, nil, false
+	}
+	script, ok := sigScript(items)
+	return script, nil, ok
+}
+
+// estimatePsbtVSize returns the estimated virtual size of the transaction of
+// the passed PSBT once all of its inputs are signed, or false when it can't be
+// estimated.
+func estimatePsbtVSize(packet *psbt.Packet) (int64, bool) {
+	mtx := packet.UnsignedTx.Copy()
+	for i, txIn := range mtx.TxIn {
+		input := &packet.Inputs[i]
+		if len(input.FinalScriptSig) > 0 || len(input.FinalScriptWitness) > 0 {
+			txIn.SignatureScript = input.FinalScriptSig
+			if len(input.FinalScriptWitness) > 0 {
+				witness, err := parseWitness(input.FinalScriptWitness)
+				if err != nil {
+					return 0, false
+				}
+				txIn.Witness = witness
+			}
+			continue
+		}
+
+		utxo := psbtInputUtxo(packet, i)
+		if utxo == nil {
+			return 0, false
+		}
+		sigScript, witness, ok := dummySatisfaction(input, utxo.PkScript)
+		if !ok {
+			return 0, false
+		}
+		txIn.SignatureScript = sigScript
+		txIn.Witness = witness
+	}
+	return mempool.GetTxVirtualSize(btcutil.NewTx(mtx)), true
+}
+
+// analyzePsbt returns the analysis of the passed PSBT returned by the
+// analyzepsbt RPC.
+func analyzePsbt(packet *psbt.Packet) *btcjson.AnalyzePsbtResult {
+	result := &btcjson.AnalyzePsbtResult{
+		Inputs: make([]btcjson.AnalyzePsbtInputResult, 0,
+			len(packet.Inputs)),
+	}
+	invalid := func(format string, args ...interface{}) *btcjson.AnalyzePsbtResult {
+		return &btcjson.AnalyzePsbtResult{
+			Next:  psbtRoleNames[psbtRoleCreator],
+			Error: "PSBT is not valid. " + fmt.Sprintf(format, args...),
+		}
+	}
+
+	next := psbtRoleExtractor
+	for i := range packet.Inputs {
+		input := &packet.Inputs[i]
+		utxo := psbtInputUtxo(packet, i)
+		var inputResult btcjson.AnalyzePsbtInputResult
+		inputNext := psbtRoleExtractor
+		switch {
+		case utxo != nil && (utxo.Value < 0 || utxo.Value > btcutil.MaxSatoshi):
+			return invalid("Input %d has invalid value", i)
+
+		case utxo != nil && txscript.IsUnspendable(utxo.PkScript):
+			return invalid("Input %d spends unspendable output", i)
+
+		case utxo == nil && input.NonWitnessUtxo != nil:
+			return invalid("Input %d specifies invalid prevout", i)
+
+		case utxo == nil:
+			inputNext = psbtRoleUpdater
+
+		case len(input.FinalScriptSig) > 0 || len(input.FinalScriptWitness) > 0:
+			inputResult.HasUtxo = true
+			inputResult.IsFinal = true
+
+		default:
+			inputResult.HasUtxo = true
+			var missing btcjson.AnalyzePsbtMissingResult
+			data := newPsbtSigningData(input)
+			if data.complete(utxo.PkScript, &missing) {
+				inputNext = psbtRoleFinalizer
+			} else {
+				inputNext = psbtRoleSigner
+				if len(missing.PubKeys) > 0 ||
+					len(missing.Signatures) > 0 ||
+					missing.RedeemScript != "" ||
+					missing.WitnessScript != "" {
+
+					inputResult.Missing = &missing
+				}
+			}
+		}
+		inputResult.Next = psbtRoleNames[inputNext]
+		result.Inputs = append(result.Inputs, inputResult)
+		if inputNext < next {
+			next = inputNext
+		}
+	}
+	result.Next = psbtRoleNames[next]
+
+	// The fee and the size can only be determined when the outputs spent
+	// by all inputs are known.
+	fee, ok := psbtFee(packet)
+	if !ok {
+		return result
+	}
+	var outputSum int64
+	for _, txOut := range packet.UnsignedTx.TxOut {
+		if txOut.Value < 0 || txOut.Value > btcutil.MaxSatoshi {
+			return invalid("Output amount invalid")
+		}
+		outputSum += txOut.Value
+	}
+	if fee < 0 || outputSum > btcutil.MaxSatoshi {
+		return invalid("Input amounts are invalid")
+	}
+	feeBTC := btcutil.Amount(fee).ToBTC()
+	result.Fee = &feeBTC
+
+	if vsize, ok := estimatePsbtVSize(packet); ok && vsize > 0 {
+		feeRate := btcutil.Amount(fee * 1000 / vsize).ToBTC()
+		result.EstimatedVSize = &vsize
+		result.EstimatedFeeRate = &feeRate
+	}
+	return result
+}
+
+// handleAnalyzePsbt implements the analyzepsbt command.
+func handleAnalyzePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AnalyzePsbtCmd)
+
+	packet, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+	return analyzePsbt(packet), nil
+}
+
+// fetchPrevTx returns the transaction with the passed hash from the mempool,
+// or from the transaction index when it's enabled.  It returns nil when the
+// transaction isn't found.
+func fetchPrevTx(s *rpcServer, hash *chainhash.Hash) *wire.MsgTx {
+	if tx, err := s.cfg.TxMemPool.FetchTransaction(hash); err == nil {
+		return tx.MsgTx()
+	}
+	if s.cfg.TxIndex == nil {
+		return nil
+	}
+
+	blockRegion, err := s.cfg.TxIndex.TxBlockRegion(hash)
+	if err != nil || blockRegion == nil {
+		return nil
+	}
+	var txBytes []byte
+	err = s.cfg.DB.View(func(dbTx database.Tx) error {
+		var err error
+		txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+		return err
+	})
+	if err != nil {
+		return nil
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil
+	}
+	return &msgTx
+}
+
+// isSegWitScript returns whether the passed output script is a witness
+// program, or a script hash of a witness program when the passed redeem
+// script is one.
+func isSegWitScript(pkScript, redeemScript []byte) bool {
+	if txscript.IsWitnessProgram(pkScript) {
+		return true
+	}
+	return txscript.IsPayToScriptHash(pkScript) &&
+		txscript.IsWitnessProgram(redeemScript) &&
+		bytes.Equal(btcutil.Hash160(redeemScript), pkScript[2:22])
+}
+
+// expandScanObjects returns the expansions of the passed descriptors mapped
+// by their output scripts.  Ranged descriptors are expanded over the range of
+// their scan objects, or over the default range when they don't have one.
+func expandScanObjects(objs []btcjson.ScanObject, params *chaincfg.Params) (map[string]*descriptor.Expansion, error) {
+	expansions := make(map[string]*descriptor.Expansion)
+	for i := range objs {
+		desc, begin, end, err := parseScanObjectRange(&objs[i], params)
+		if err != nil {
+			return nil, err
+		}
+		for index := begin; ; index++ {
+			exp, err := desc.Expand(index)
+			if err != nil {
+				return nil, invalidDescriptorError(err)
+			}
+			expansions[string(exp.Script)] = exp
+			if index == end {
+				break
+			}
+		}
+	}
+	return expansions, nil
+}
+
+// hasBip32Derivation returns whether the passed derivations include one for
+// the passed public key.
+func hasBip32Derivation(derivs []*psbt.Bip32Derivation, pubKey []byte) bool {
+	for _, deriv := range derivs {
+		if bytes.Equal(deriv.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// expansionDerivations returns the BIP 32 derivations of the keys of the
+// passed expansion which are missing from the passed derivations.
+func expansionDerivations(exp *descriptor.Expansion, derivs []*psbt.Bip32Derivation) []*psbt.Bip32Derivation {
+	for _, key := range exp.Keys {
+		if hasBip32Derivation(derivs, key.PubKey) {
+			continue
+		}
+		derivs = append(derivs, &psbt.Bip32Derivation{
+			PubKey:               key.PubKey,
+			MasterKeyFingerprint: binary.LittleEndian.Uint32(key.Fingerprint[:]),
+			Bip32Path:            key.Path,
+		})
+	}
+	return derivs
+}
+
+// taprootDerivations returns the passed taproot BIP 32 derivations along with
+// the derivation of the internal key of the passed expansion when it's
+// missing.
+func taprootDerivations(exp *descriptor.Expansion, derivs []*psbt.TaprootBip32Derivation) []*psbt.TaprootBip32Derivation {
+	key := exp.InternalKey
+	for _, deriv := range derivs {
+		if bytes.Equal(deriv.XOnlyPubKey, key.PubKey) {
+			return derivs
+		}
+	}
+	return append(derivs, &psbt.TaprootBip32Derivation{
+		XOnlyPubKey:          key.PubKey,
+		MasterKeyFingerprint: binary.LittleEndian.Uint32(key.Fingerprint[:]),
+		Bip32Path:            key.Path,
+	})
+}
+
+// updatePsbtFromDescriptors adds the scripts and key derivations of the passed
+// descriptor expansions to the inputs spending and the outputs paying to their
+// output scripts.
+func updatePsbtFromDescriptors(packet *psbt.Packet, expansions map[string]*descriptor.Expansion) {
+	for i := range packet.Inputs {
+		input := &packet.Inputs[i]
+		utxo := psbtInputUtxo(packet, i)
+		if utxo == nil {
+			continue
+		}
+		exp, ok := expansions[string(utxo.PkScript)]
+		if !ok {
+			continue
+		}
+		if len(input.RedeemScript) == 0 {
+			input.RedeemScript = exp.RedeemScript
+		}
+		if len(input.WitnessScript) == 0 {
+			input.WitnessScript = exp.WitnessScript
+		}
+		input.Bip32Derivation = expansionDerivations(exp,
+			input.Bip32Derivation)
+		if exp.InternalKey != nil {
+			if len(input.TaprootInternalKey) == 0 {
+				input.TaprootInternalKey = exp.InternalKey.PubKey
+			}
+			input.TaprootBip32Derivation = taprootDerivations(exp,
+				input.TaprootBip32Derivation)
+		}
+	}
+
+	for i := range packet.Outputs {
+		output := &packet.Outputs[i]
+		pkScript := packet.UnsignedTx.TxOut[i].PkScript
+		exp, ok := expansions[string(pkScript)]
+		if !ok {
+			continue
+		}
+		if len(output.RedeemScript) == 0 {
+			output.RedeemScript = exp.RedeemScript
+		}
+		if len(output.WitnessScript) == 0 {
+			output.WitnessScript = exp.WitnessScript
+		}
+		output.Bip32Derivation = expansionDerivations(exp,
+			output.Bip32Derivation)
+		if exp.InternalKey != nil {
+			if len(output.TaprootInternalKey) == 0 {
+				output.TaprootInternalKey = exp.InternalKey.PubKey
+			}
+			output.TaprootBip32Derivation = taprootDerivations(exp,
+				output.TaprootBip32Derivation)
+		}
+	}
+}
+
+// handleUtxoUpdatePsbt implements the utxoupdatepsbt command.
+func handleUtxoUpdatePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.UtxoUpdatePsbtCmd)
+
+	packet, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+
+	var expansions map[string]*descriptor.Expansion
+	if c.Descriptors != nil {
+		expansions, err = expandScanObjects(*c.Descriptors,
+			s.cfg.ChainParams)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Add the transactions spent by the inputs from the mempool or the
+	// transaction index, and the outputs spent by segwit inputs from the
+	// utxo set when their transactions aren't found, like Bitcoin Core.
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		input := &packet.Inputs[i]
+		if input.NonWitnessUtxo != nil {
+			continue
+		}
+		prevOut := txIn.PreviousOutPoint
+		if prevTx := fetchPrevTx(s, &prevOut.Hash); prevTx != nil &&
+			prevOut.Index < uint32(len(prevTx.TxOut)) {
+
+			input.NonWitnessUtxo = prevTx
+			continue
+		}
+		if input.WitnessUtxo != nil {
+			continue
+		}
+
+		entry, err := s.cfg.Chain.FetchUtxoEntry(prevOut)
+		if err != nil || entry == nil || entry.IsSpent() {
+			continue
+		}
+		redeemScript := input.RedeemScript
+		if exp, ok := expansions[string(entry.PkScript())]; ok &&
+			len(redeemScript) == 0 {
+
+			redeemScript = exp.RedeemScript
+		}
+		if isSegWitScript(entry.PkScript(), redeemScript) {
+			input.WitnessUtxo = wire.NewTxOut(entry.Amount(),
+				entry.PkScript())
+		}
+	}
+
+	updatePsbtFromDescriptors(packet, expansions)
+
+	b64, err := packet.B64Encode()
+	if err != nil {
+		context := "Failed to encode PSBT"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return b64, nil
+}
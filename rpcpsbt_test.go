@@ -0,0 +1,276 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPsbt returns a PSBT spending a p2wpkh output of the passed key worth
+// one BTC to a p2wpkh output worth 0.9 BTC, along with the spent output.
+func newTestPsbt(t *testing.T, pubKey *btcec.PublicKey) (*psbt.Packet, *wire.TxOut) {
+	t.Helper()
+
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(pubKey.SerializeCompressed())).Script()
+	require.NoError(t, err)
+
+	prevOut := &wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	packet, err := psbt.New(
+		[]*wire.OutPoint{prevOut},
+		[]*wire.TxOut{wire.NewTxOut(90000000, pkScript)},
+		2, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	return packet, wire.NewTxOut(100000000, pkScript)
+}
+
+// TestHandleDecodePsbt ensures PSBTs are decoded along with the data of their
+// inputs and their fee.
+func TestHandleDecodePsbt(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	packet, utxo := newTestPsbt(t, pubKey)
+	packet.Inputs[0].WitnessUtxo = utxo
+	packet.Inputs[0].SighashType = txscript.SigHashAll |
+		txscript.SigHashAnyOneCanPay
+	packet.Inputs[0].Bip32Derivation = []*psbt.Bip32Derivation{{
+		PubKey:               pubKey.SerializeCompressed(),
+		MasterKeyFingerprint: 0x3fb34dd3,
+		Bip32Path: []uint32{hdkeychain.HardenedKeyStart + 84,
+			hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart,
+			0, 1},
+	}}
+	sig := ecdsa.Sign(privKey, chainhash.HashB([]byte("msg")))
+	packet.Inputs[0].PartialSigs = []*psbt.PartialSig{{
+		PubKey:    pubKey.SerializeCompressed(),
+		Signature: append(sig.Serialize(), byte(txscript.SigHashAll)),
+	}}
+	b64, err := packet.B64Encode()
+	require.NoError(t, err)
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: &chaincfg.SimNetParams}}
+	result, err := handleDecodePsbt(s, btcjson.NewDecodePsbtCmd(b64), nil)
+	require.NoError(t, err)
+
+	decoded := result.(*btcjson.DecodePsbtResult)
+	require.Equal(t, packet.UnsignedTx.TxHash().String(), decoded.Tx.Txid)
+	require.Len(t, decoded.Inputs, 1)
+	require.Len(t, decoded.Outputs, 1)
+	require.NotNil(t, decoded.Fee)
+	require.InDelta(t, 0.1, *decoded.Fee, 1e-9)
+
+	input := decoded.Inputs[0]
+	require.NotNil(t, input.WitnessUtxo)
+	require.Equal(t, 1.0, input.WitnessUtxo.Amount)
+	require.Equal(t, "witness_v0_keyhash", input.WitnessUtxo.ScriptPubKey.Type)
+	require.Equal(t, "ALL|ANYONECANPAY", input.Sighash)
+	require.Equal(t, []btcjson.PsbtBip32DerivResult{{
+		PubKey:            hex.EncodeToString(pubKey.SerializeCompressed()),
+		MasterFingerprint: "d34db33f",
+		Path:              "m/84h/0h/0h/0/1",
+	}}, input.Bip32Derivs)
+	require.Len(t, input.PartialSignatures, 1)
+
+	// Invalid PSBTs are rejected.
+	_, err = handleDecodePsbt(s, btcjson.NewDecodePsbtCmd("cHNidP8B"), nil)
+	require.Error(t, err)
+	require.Equal(t, btcjson.ErrRPCDeserialization, err.(*btcjson.RPCError).Code)
+}
+
+// TestAnalyzePsbt ensures the data missing from the inputs of PSBTs, the role
+// which has to process them next and their fee and size are determined.
+func TestAnalyzePsbt(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	keyID := hex.EncodeToString(btcutil.Hash160(pubKey.SerializeCompressed()))
+	sig := ecdsa.Sign(privKey, chainhash.HashB([]byte("msg")))
+	partialSig := &psbt.PartialSig{
+		PubKey:    pubKey.SerializeCompressed(),
+		Signature: append(sig.Serialize(), byte(txscript.SigHashAll)),
+	}
+	witnessScript := []byte{txscript.OP_TRUE}
+	p2wsh, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(chainhash.HashB(witnessScript)).Script()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		update  func(input *psbt.PInput, utxo *wire.TxOut)
+		next    string
+		missing *btcjson.AnalyzePsbtMissingResult
+		vsize   int64
+		err     bool
+	}{{
+		name:   "no utxo",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {},
+		next:   "updater",
+	}, {
+		name: "missing pubkey",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {
+			input.WitnessUtxo = utxo
+		},
+		next:    "signer",
+		missing: &btcjson.AnalyzePsbtMissingResult{PubKeys: []string{keyID}},
+		vsize:   110,
+	}, {
+		name: "missing signature",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {
+			input.WitnessUtxo = utxo
+			input.Bip32Derivation = []*psbt.Bip32Derivation{{
+				PubKey: pubKey.SerializeCompressed(),
+			}}
+		},
+		next:    "signer",
+		missing: &btcjson.AnalyzePsbtMissingResult{Signatures: []string{keyID}},
+		vsize:   110,
+	}, {
+		name: "signed",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {
+			input.WitnessUtxo = utxo
+			input.PartialSigs = []*psbt.PartialSig{partialSig}
+		},
+		next:  "finalizer",
+		vsize: 110,
+	}, {
+		name: "missing witness script",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {
+			input.WitnessUtxo = wire.NewTxOut(utxo.Value, p2wsh)
+		},
+		next: "signer",
+		missing: &btcjson.AnalyzePsbtMissingResult{
+			WitnessScript: hex.EncodeToString(chainhash.HashB(witnessScript)),
+		},
+	}, {
+		name: "finalized",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {
+			input.WitnessUtxo = utxo
+			input.FinalScriptWitness = []byte{0x01, 0x01, 0x01}
+		},
+		next:  "extractor",
+		vsize: 84,
+	}, {
+		name: "input amount less than output amount",
+		update: func(input *psbt.PInput, utxo *wire.TxOut) {
+			input.WitnessUtxo = wire.NewTxOut(1000, utxo.PkScript)
+		},
+		next: "creator",
+		err:  true,
+	}}
+
+	for _, test := range tests {
+		packet, utxo := newTestPsbt(t, pubKey)
+		test.update(&packet.Inputs[0], utxo)
+
+		result := analyzePsbt(packet)
+		require.Equal(t, test.next, result.Next, test.name)
+		if test.err {
+			require.NotEmpty(t, result.Error, test.name)
+			continue
+		}
+		require.Empty(t, result.Error, test.name)
+		require.Len(t, result.Inputs, 1, test.name)
+		require.Equal(t, test.next, result.Inputs[0].Next, test.name)
+		require.Equal(t, test.missing, result.Inputs[0].Missing, test.name)
+
+		if test.next == "updater" {
+			require.False(t, result.Inputs[0].HasUtxo, test.name)
+			require.Nil(t, result.Fee, test.name)
+			continue
+		}
+		require.True(t, result.Inputs[0].HasUtxo, test.name)
+		require.NotNil(t, result.Fee, test.name)
+		if test.vsize == 0 {
+			require.Nil(t, result.EstimatedVSize, test.name)
+			continue
+		}
+		require.NotNil(t, result.EstimatedVSize, test.name)
+		require.Equal(t, test.vsize, *result.EstimatedVSize, test.name)
+		require.InDelta(t, 0.1*1000/float64(test.vsize),
+			*result.EstimatedFeeRate, 1e-8, test.name)
+	}
+}
+
+// TestUpdatePsbtFromDescriptors ensures the scripts and key derivations of the
+// inputs and outputs described by descriptors are added to PSBTs.
+func TestUpdatePsbtFromDescriptors(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x07}, 32), params)
+	require.NoError(t, err)
+	account, err := master.Derive(hdkeychain.HardenedKeyStart + 84)
+	require.NoError(t, err)
+	xpub, err := account.Neuter()
+	require.NoError(t, err)
+	child, err := xpub.Derive(0)
+	require.NoError(t, err)
+	child, err = child.Derive(2)
+	require.NoError(t, err)
+	pubKey, err := child.ECPubKey()
+	require.NoError(t, err)
+
+	expansions, err := expandScanObjects([]btcjson.ScanObject{
+		{Desc: "sh(wpkh([d34db33f/84h]" + xpub.String() + "/0/*))"},
+	}, params)
+	require.NoError(t, err)
+	require.Len(t, expansions, defaultScanRangeEnd+1)
+
+	// Spend and pay to the nested p2wpkh script of the key with index 2.
+	redeemScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(pubKey.SerializeCompressed())).Script()
+	require.NoError(t, err)
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(redeemScript)).AddOp(txscript.OP_EQUAL).
+		Script()
+	require.NoError(t, err)
+	packet, err := psbt.New(
+		[]*wire.OutPoint{{Hash: chainhash.Hash{0x01}}},
+		[]*wire.TxOut{wire.NewTxOut(1000, pkScript)},
+		2, 0, []uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(2000, pkScript)
+
+	updatePsbtFromDescriptors(packet, expansions)
+
+	wantDerivs := []*psbt.Bip32Derivation{{
+		PubKey:               pubKey.SerializeCompressed(),
+		MasterKeyFingerprint: 0x3fb34dd3,
+		Bip32Path:            []uint32{hdkeychain.HardenedKeyStart + 84, 0, 2},
+	}}
+	require.Equal(t, redeemScript, packet.Inputs[0].RedeemScript)
+	require.Equal(t, wantDerivs, packet.Inputs[0].Bip32Derivation)
+	require.Equal(t, redeemScript, packet.Outputs[0].RedeemScript)
+	require.Equal(t, wantDerivs, packet.Outputs[0].Bip32Derivation)
+
+	// The input is now missing the signature rather than the public key.
+	result := analyzePsbt(packet)
+	require.Equal(t, "signer", result.Next)
+	require.Equal(t, &btcjson.AnalyzePsbtMissingResult{
+		Signatures: []string{hex.EncodeToString(
+			btcutil.Hash160(pubKey.SerializeCompressed()))},
+	}, result.Inputs[0].Missing)
+
+	// Updating again doesn't add duplicate derivations.
+	updatePsbtFromDescriptors(packet, expansions)
+	require.Equal(t, wantDerivs, packet.Inputs[0].Bip32Derivation)
+}
@@ -0,0 +1,662 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// restPathPrefix is the path under which the REST interface is served.
+	restPathPrefix = "/rest/"
+
+	// defaultRESTHeadersCount is the number of headers returned by the
+	// headers endpoint when the count isn't specified.
+	defaultRESTHeadersCount = 5
+
+	// maxRESTHeadersCount is the maximum number of headers returned by the
+	// headers endpoint.
+	maxRESTHeadersCount = 2000
+
+	// maxRESTOutpoints is the maximum number of outpoints which can be
+	// queried with a single getutxos request.
+	maxRESTOutpoints = 15
+
+	// restMempoolHeight is the height reported by the getutxos endpoint for
+	// the outputs of mempool transactions.
+	restMempoolHeight = 0x7fffffff
+)
+
+// restFormat identifies the format of a REST response, which is selected by
+// the extension of the requested path.
+type restFormat int
+
+// These constants define the formats of REST responses.
+const (
+	restFormatUndefined restFormat = iota
+	restFormatBinary
+	restFormatHex
+	restFormatJSON
+)
+
+// restFormatExtensions maps the path extensions to the formats they select.
+var restFormatExtensions = map[string]restFormat{
+	"bin":  restFormatBinary,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// contentType returns the content type of responses of the format.
+func (f restFormat) contentType() string {
+	switch f {
+	case restFormatBinary:
+		return "application/octet-stream"
+	case restFormatJSON:
+		return "application/json"
+	default:
+		return "text/plain"
+	}
+}
+
+// parseRESTFormat splits the passed path parameter into the parameter and the
+// format selected by its extension.  The format is restFormatUndefined when
+// the extension is missing or unknown.
+func parseRESTFormat(param string) (string, restFormat) {
+	i := strings.LastIndexByte(param, '.')
+	if i == -1 {
+		return param, restFormatUndefined
+	}
+	return param[:i], restFormatExtensions[param[i+1:]]
+}
+
+// restError is an error which is returned to REST clients as a plain text
+// message with the HTTP status code.
+type restError struct {
+	status  int
+	message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// newRESTError returns a restError with the passed status code and formatted
+// message.
+func newRESTError(status int, format string, args ...interface{}) *restError {
+	return &restError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// restFormatNotFound returns the error for a request of a format which isn't
+// supported by the endpoint.  The formats are listed with their extensions
+// such as ".bin, .hex, .json".
+func restFormatNotFound(available string) *restError {
+	return newRESTError(http.StatusNotFound, "output format not found "+
+		"(available: %s)", available)
+}
+
+// restErrorFromRPC converts an error returned by an RPC handler to the error
+// which is returned to the REST client.
+func restErrorFromRPC(err error) error {
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		return err
+	}
+	switch rpcErr.Code {
+	// ErrRPCNoTxInfo shares its code with ErrRPCBlockNotFound.
+	case btcjson.ErrRPCBlockNotFound, btcjson.ErrRPCOutOfRange:
+		return newRESTError(http.StatusNotFound, "%s", rpcErr.Message)
+	case btcjson.ErrRPCDecodeHexString, btcjson.ErrRPCInvalidParameter:
+		return newRESTError(http.StatusBadRequest, "%s", rpcErr.Message)
+	}
+	return newRESTError(http.StatusInternalServerError, "%s",
+		rpcErr.Message)
+}
+
+// restHex returns the body of a response in the hex format.
+func restHex(data []byte) []byte {
+	body := make([]byte, hex.EncodedLen(len(data))+1)
+	hex.Encode(body, data)
+	body[len(body)-1] = '\n'
+	return body
+}
+
+// restJSON returns the body of a response in the JSON format.
+func restJSON(result interface{}) ([]byte, error) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// restHandler is the signature of the functions serving the REST endpoints.
+// The parameter is the remainder of the path following the prefix of the
+// endpoint with the extension selecting the format removed.
+type restHandler func(s *rpcServer, r *http.Request, param string,
+	format restFormat) ([]byte, error)
+
+// restHandlers maps the path prefixes of the REST endpoints to the functions
+// serving them.  Prefixes are matched in order, so longer prefixes must come
+// before the prefixes they start with.
+var restHandlers = []struct {
+	prefix  string
+	handler restHandler
+}{
+	{"/rest/tx/", restTx},
+	{"/rest/block/notxdetails/", restBlockNoTxDetails},
+	{"/rest/block/", restBlock},
+	{"/rest/chaininfo", restChainInfo},
+	{"/rest/mempool/info", restMempoolInfo},
+	{"/rest/mempool/contents", restMempoolContents},
+	{"/rest/headers/", restHeaders},
+	{"/rest/getutxos", restGetUtxos},
+	{"/rest/blockhashbyheight/", restBlockHashByHeight},
+}
+
+// restRequest serves a request of the REST interface.  The interface is
+// compatible with the one of Bitcoin Core and doesn't require authentication,
+// which is why it is only served when enabled with --rest.
+func (s *rpcServer) restRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET requests are supported",
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, h := range restHandlers {
+		if !strings.HasPrefix(r.URL.Path, h.prefix) {
+			continue
+		}
+
+		param, format := parseRESTFormat(r.URL.Path[len(h.prefix):])
+		body, err := h.handler(s, r, param, format)
+		if err != nil {
+			restErr, ok := err.(*restError)
+			if !ok {
+				rpcsLog.Errorf("Unable to serve REST request %s: %v",
+					r.URL.Path, err)
+				restErr = newRESTError(http.StatusInternalServerError,
+					"%v", err)
+			}
+			http.Error(w, restErr.message, restErr.status)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.contentType())
+		if _, err := w.Write(body); err != nil {
+			rpcsLog.Debugf("Unable to write REST response to %s: %v",
+				r.RemoteAddr, err)
+		}
+		return
+	}
+
+	http.Error(w, "unknown REST endpoint", http.StatusNotFound)
+}
+
+// restTx serves /rest/tx/<txid>.<bin|hex|json>.  Confirmed transactions are
+// only available when the transaction index is enabled.
+func restTx(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	verbose := 0
+	switch format {
+	case restFormatBinary, restFormatHex:
+	case restFormatJSON:
+		verbose = 1
+	default:
+		return nil, restFormatNotFound(".bin, .hex, .json")
+	}
+
+	txHash, err := chainhash.NewHashFromStr(param)
+	if err != nil || len(param) != hex.EncodedLen(chainhash.HashSize) {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid hash: %s",
+			param)
+	}
+	cmd := &btcjson.GetRawTransactionCmd{Txid: param, Verbose: &verbose}
+	result, err := handleGetRawTransaction(s, cmd, nil)
+	if err != nil {
+		if rpcErr, ok := err.(*btcjson.RPCError); ok &&
+			rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+
+			return nil, newRESTError(http.StatusNotFound, "%v not found",
+				txHash)
+		}
+		return nil, restErrorFromRPC(err)
+	}
+
+	if format == restFormatJSON {
+		return restJSON(result)
+	}
+	txBytes, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return nil, err
+	}
+	if format == restFormatHex {
+		return restHex(txBytes), nil
+	}
+	return txBytes, nil
+}
+
+// restBlock serves /rest/block/<hash>.<bin|hex|json> with the details of the
+// transactions included in the JSON format.
+func restBlock(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	return restBlockVerbosity(s, param, format, 2)
+}
+
+// restBlockNoTxDetails serves /rest/block/notxdetails/<hash>.<bin|hex|json>
+// with only the hashes of the transactions included in the JSON format.
+func restBlockNoTxDetails(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	return restBlockVerbosity(s, param, format, 1)
+}
+
+// restBlockVerbosity serves the block endpoints.  The verbosity is the one of
+// the getblock RPC used for the JSON format.
+func restBlockVerbosity(s *rpcServer, param string, format restFormat, verbosity int) ([]byte, error) {
+	if format == restFormatUndefined {
+		return nil, restFormatNotFound(".bin, .hex, .json")
+	}
+
+	hash, err := chainhash.NewHashFromStr(param)
+	if err != nil || len(param) != hex.EncodedLen(chainhash.HashSize) {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid hash: %s",
+			param)
+	}
+
+	if format == restFormatJSON {
+		cmd := &btcjson.GetBlockCmd{Hash: param, Verbosity: &verbosity}
+		result, err := handleGetBlock(s, cmd, nil)
+		if err != nil {
+			return nil, restErrorFromRPC(err)
+		}
+		return restJSON(result)
+	}
+
+	blkBytes, err := fetchBlockBytes(s.cfg.DB, s.cfg.Chain, hash)
+	if err != nil {
+		blkBytes, err = fetchPrunedBlockBytes(s, hash)
+		if err != nil {
+			return nil, restErrorFromRPC(err)
+		}
+	}
+	if format == restFormatHex {
+		return restHex(blkBytes), nil
+	}
+	return blkBytes, nil
+}
+
+// restChainInfo serves /rest/chaininfo.json with the result of the
+// getblockchaininfo RPC.
+func restChainInfo(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	if format != restFormatJSON || param != "" {
+		return nil, restFormatNotFound(".json")
+	}
+	result, err := handleGetBlockChainInfo(s, nil, nil)
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	return restJSON(result)
+}
+
+// restMempoolInfo serves /rest/mempool/info.json with the result of the
+// getmempoolinfo RPC.
+func restMempoolInfo(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	if format != restFormatJSON || param != "" {
+		return nil, restFormatNotFound(".json")
+	}
+	result, err := handleGetMempoolInfo(s, nil, nil)
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	return restJSON(result)
+}
+
+// restMempoolContents serves /rest/mempool/contents.json with the result of
+// the getrawmempool RPC, which is verbose unless the verbose query parameter
+// is false.
+func restMempoolContents(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	if format != restFormatJSON || param != "" {
+		return nil, restFormatNotFound(".json")
+	}
+
+	verbose := true
+	switch v := r.URL.Query().Get("verbose"); v {
+	case "", "true":
+	case "false":
+		verbose = false
+	default:
+		return nil, newRESTError(http.StatusBadRequest, "The \"verbose\" "+
+			"query parameter must be either \"true\" or \"false\".")
+	}
+
+	cmd := &btcjson.GetRawMempoolCmd{Verbose: &verbose}
+	result, err := handleGetRawMempool(s, cmd, nil)
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	return restJSON(result)
+}
+
+// restHeaders serves /rest/headers/<hash>.<bin|hex|json>?count=<count> and
+// the deprecated /rest/headers/<count>/<hash>.<bin|hex|json>.  The headers of
+// the main chain starting with the block of the hash are returned, which is
+// only the header of the block itself when it isn't part of the main chain
+// and none when the block is unknown.
+func restHeaders(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	if format == restFormatUndefined {
+		return nil, restFormatNotFound(".bin, .hex, .json")
+	}
+
+	countStr := r.URL.Query().Get("count")
+	hashStr := param
+	if parts := strings.Split(param, "/"); len(parts) == 2 {
+		countStr, hashStr = parts[0], parts[1]
+	} else if len(parts) != 1 {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid URI "+
+			"format. Expected /rest/headers/<hash>.<ext>?count=<count>")
+	}
+	count := defaultRESTHeadersCount
+	if countStr != "" {
+		var err error
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count < 1 || count > maxRESTHeadersCount {
+			return nil, newRESTError(http.StatusBadRequest, "Header "+
+				"count is invalid or out of acceptable range "+
+				"(1-%d): %s", maxRESTHeadersCount, countStr)
+		}
+	}
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil || len(hashStr) != hex.EncodedLen(chainhash.HashSize) {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid hash: %s",
+			hashStr)
+	}
+
+	chain := s.cfg.Chain
+	var headers []wire.BlockHeader
+	startHeight, err := chain.BlockHeightByHash(hash)
+	inMainChain := err == nil
+	if inMainChain {
+		endHeight := startHeight + int32(count)
+		if best := chain.BestSnapshot(); endHeight > best.Height+1 {
+			endHeight = best.Height + 1
+		}
+		headers, err = chain.HeaderRange(startHeight, endHeight)
+		if err != nil {
+			return nil, err
+		}
+	} else if header, err := chain.HeaderByHash(hash); err == nil {
+		headers = []wire.BlockHeader{header}
+	}
+
+	switch format {
+	case restFormatBinary, restFormatHex:
+		buf := bytes.NewBuffer(make([]byte, 0,
+			len(headers)*wire.MaxBlockHeaderPayload))
+		for i := range headers {
+			if err := headers[i].Serialize(buf); err != nil {
+				return nil, err
+			}
+		}
+		if format == restFormatHex {
+			return restHex(buf.Bytes()), nil
+		}
+		return buf.Bytes(), nil
+	}
+
+	// The JSON results are the ones of getblockheaders for headers of the
+	// main chain and the one of getblockheader for a side chain header.
+	var result interface{} = []btcjson.GetBlockHeaderVerboseResult{}
+	switch {
+	case len(headers) == 0:
+	case inMainChain:
+		jsonFormat := "json"
+		cmd := &btcjson.GetBlockHeadersCmd{
+			Start:  btcjson.HashOrHeight{Value: hashStr},
+			Count:  uint32(len(headers)),
+			Format: &jsonFormat,
+		}
+		result, err = handleGetBlockHeaders(s, cmd, nil)
+	default:
+		verbose := true
+		cmd := &btcjson.GetBlockHeaderCmd{Hash: hashStr, Verbose: &verbose}
+		var header interface{}
+		header, err = handleGetBlockHeader(s, cmd, nil)
+		result = []interface{}{header}
+	}
+	if err != nil {
+		return nil, restErrorFromRPC(err)
+	}
+	return restJSON(result)
+}
+
+// restBlockHashByHeight serves /rest/blockhashbyheight/<height>.<bin|hex|json>
+// with the hash of the main chain block at the height.
+func restBlockHashByHeight(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	if format == restFormatUndefined {
+		return nil, restFormatNotFound(".bin, .hex, .json")
+	}
+
+	height, err := strconv.ParseInt(param, 10, 32)
+	if err != nil || height < 0 {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid height: %s",
+			param)
+	}
+	hash, err := s.cfg.Chain.BlockHashByHeight(int32(height))
+	if err != nil {
+		return nil, newRESTError(http.StatusNotFound, "Block height out "+
+			"of range")
+	}
+
+	switch format {
+	case restFormatBinary:
+		return hash[:], nil
+	case restFormatHex:
+		return []byte(hash.String() + "\n"), nil
+	}
+	return restJSON(struct {
+		BlockHash string `json:"blockhash"`
+	}{hash.String()})
+}
+
+// restUtxo is an unspent output in the JSON format of the getutxos endpoint.
+type restUtxo struct {
+	Height       int32                      `json:"height"`
+	Value        float64                    `json:"value"`
+	ScriptPubKey btcjson.ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// restUtxosResult is the JSON format of the getutxos endpoint.
+type restUtxosResult struct {
+	ChainHeight  int32      `json:"chainHeight"`
+	ChaintipHash string     `json:"chaintipHash"`
+	Bitmap       string     `json:"bitmap"`
+	Utxos        []restUtxo `json:"utxos"`
+}
+
+// restGetUtxos serves
+// /rest/getutxos[/checkmempool]/<txid>-<n>/<txid>-<n>/.../<txid>-<n>.<bin|hex|json>.
+// The response consists of the best block, a bitmap with a set bit for each
+// of the requested outpoints which is unspent, and the unspent outputs.  When
+// checkmempool is given, the outputs of mempool transactions are included
+// and outputs spent by mempool transactions are treated as spent.
+func restGetUtxos(s *rpcServer, r *http.Request, param string, format restFormat) ([]byte, error) {
+	if format == restFormatUndefined {
+		return nil, restFormatNotFound(".bin, .hex, .json")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(param, "/"), "/")
+	checkMempool := len(parts) > 0 && parts[0] == "checkmempool"
+	if checkMempool {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || (len(parts) == 1 && parts[0] == "") {
+		return nil, newRESTError(http.StatusBadRequest, "Error: empty "+
+			"request")
+	}
+	if len(parts) > maxRESTOutpoints {
+		return nil, newRESTError(http.StatusBadRequest, "Error: max "+
+			"outpoints exceeded (max: %d, tried: %d)", maxRESTOutpoints,
+			len(parts))
+	}
+	outpoints := make([]wire.OutPoint, len(parts))
+	for i, part := range parts {
+		op, err := parseRESTOutpoint(part)
+		if err != nil {
+			return nil, newRESTError(http.StatusBadRequest, "Parse error")
+		}
+		outpoints[i] = *op
+	}
+
+	var (
+		best    = s.cfg.Chain.BestSnapshot()
+		bitmap  = make([]byte, (len(outpoints)+7)/8)
+		found   = make([]bool, len(outpoints))
+		heights = make([]int32, 0, len(outpoints))
+		outs    = make([]*wire.TxOut, 0, len(outpoints))
+	)
+	for i, op := range outpoints {
+		height, out, err := s.fetchRESTUtxo(op, checkMempool)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			continue
+		}
+		found[i] = true
+		bitmap[i/8] |= 1 << (i % 8)
+		heights = append(heights, height)
+		outs = append(outs, out)
+	}
+
+	switch format {
+	case restFormatBinary, restFormatHex:
+		var buf bytes.Buffer
+		if err := serializeRESTUtxos(&buf, best.Height, &best.Hash, bitmap,
+			heights, outs); err != nil {
+			return nil, err
+		}
+		if format == restFormatHex {
+			return restHex(buf.Bytes()), nil
+		}
+		return buf.Bytes(), nil
+	}
+
+	var bitmapStr strings.Builder
+	for _, unspent := range found {
+		if unspent {
+			bitmapStr.WriteByte('1')
+		} else {
+			bitmapStr.WriteByte('0')
+		}
+	}
+	result := restUtxosResult{
+		ChainHeight:  best.Height,
+		ChaintipHash: best.Hash.String(),
+		Bitmap:       bitmapStr.String(),
+		Utxos:        make([]restUtxo, len(outs)),
+	}
+	for i, out := range outs {
+		result.Utxos[i] = restUtxo{
+			Height:       heights[i],
+			Value:        btcutil.Amount(out.Value).ToBTC(),
+			ScriptPubKey: scriptPubKeyResult(out.PkScript, s.cfg.ChainParams),
+		}
+	}
+	return restJSON(result)
+}
+
+// fetchRESTUtxo returns the height and the output of the passed outpoint when
+// it is unspent and a nil output otherwise.  The mempool is only consulted
+// when checkMempool is set.
+func (s *rpcServer) fetchRESTUtxo(op wire.OutPoint, checkMempool bool) (int32, *wire.TxOut, error) {
+	if checkMempool {
+		if s.cfg.TxMemPool.CheckSpend(op) != nil {
+			return 0, nil, nil
+		}
+		if tx, err := s.cfg.TxMemPool.FetchTransaction(&op.Hash); err == nil {
+			txOuts := tx.MsgTx().TxOut
+			if op.Index >= uint32(len(txOuts)) {
+				return 0, nil, nil
+			}
+			return restMempoolHeight, txOuts[op.Index], nil
+		}
+	}
+
+	entry, err := s.cfg.Chain.FetchUtxoEntry(op)
+	if err != nil {
+		return 0, nil, err
+	}
+	if entry == nil || entry.IsSpent() {
+		return 0, nil, nil
+	}
+	return entry.BlockHeight(), wire.NewTxOut(entry.Amount(),
+		entry.PkScript()), nil
+}
+
+// parseRESTOutpoint parses an outpoint of the form <txid>-<n>.
+func parseRESTOutpoint(s string) (*wire.OutPoint, error) {
+	i := strings.LastIndexByte(s, '-')
+	if i == -1 || i != hex.EncodedLen(chainhash.HashSize) {
+		return nil, fmt.Errorf("invalid outpoint %q", s)
+	}
+	hash, err := chainhash.NewHashFromStr(s[:i])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(s[i+1:], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// serializeRESTUtxos writes the binary format of the getutxos endpoint, which
+// is the one of Bitcoin Core: the height and hash of the best block, the
+// bitmap and the unspent outputs, each prefixed by an unused version and its
+// height.
+func serializeRESTUtxos(w io.Writer, chainHeight int32, chainTip *chainhash.Hash,
+	bitmap []byte, heights []int32, outs []*wire.TxOut) error {
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[:4], uint32(chainHeight))
+	if _, err := w.Write(buf[:4]); err != nil {
+		return err
+	}
+	if _, err := w.Write(chainTip[:]); err != nil {
+		return err
+	}
+	if err := wire.WriteVarBytes(w, 0, bitmap); err != nil {
+		return err
+	}
+	if err := wire.WriteVarInt(w, 0, uint64(len(outs))); err != nil {
+		return err
+	}
+	for i, out := range outs {
+		binary.LittleEndian.PutUint32(buf[:4], 0)
+		binary.LittleEndian.PutUint32(buf[4:], uint32(heights[i]))
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(buf[:], uint64(out.Value))
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+		if err := wire.WriteVarBytes(w, 0, out.PkScript); err != nil {
+			return err
+		}
+	}
+	return nil
+}
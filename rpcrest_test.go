@@ -0,0 +1,131 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseRESTFormat ensures the format of REST requests is selected by the
+// extension of the path.
+func TestParseRESTFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		param     string
+		wantParam string
+		want      restFormat
+	}{
+		{"abc.bin", "abc", restFormatBinary},
+		{"abc.hex", "abc", restFormatHex},
+		{"abc.json", "abc", restFormatJSON},
+		{"a.b.json", "a.b", restFormatJSON},
+		{"abc.xml", "abc", restFormatUndefined},
+		{"abc", "abc", restFormatUndefined},
+		{".json", "", restFormatJSON},
+	}
+	for _, test := range tests {
+		param, format := parseRESTFormat(test.param)
+		require.Equal(t, test.wantParam, param, test.param)
+		require.Equal(t, test.want, format, test.param)
+	}
+}
+
+// TestParseRESTOutpoint ensures outpoints of getutxos requests are parsed and
+// malformed ones are rejected.
+func TestParseRESTOutpoint(t *testing.T) {
+	t.Parallel()
+
+	txid := strings.Repeat("ab", chainhash.HashSize)
+	op, err := parseRESTOutpoint(txid + "-7")
+	require.NoError(t, err)
+	require.Equal(t, txid, op.Hash.String())
+	require.Equal(t, uint32(7), op.Index)
+
+	for _, s := range []string{
+		txid,
+		txid + "-",
+		txid + "--1",
+		txid + "-4294967296",
+		txid[2:] + "-0",
+		strings.Repeat("zz", chainhash.HashSize) + "-0",
+	} {
+		_, err := parseRESTOutpoint(s)
+		require.Error(t, err, s)
+	}
+}
+
+// TestSerializeRESTUtxos ensures the binary format of getutxos responses
+// matches the one of Bitcoin Core.
+func TestSerializeRESTUtxos(t *testing.T) {
+	t.Parallel()
+
+	tip, err := chainhash.NewHashFromStr("5d06495fc0d3977d2879d01494a02fd3" +
+		"483131f807ee041b7f17cd4bb7c4ceb5")
+	require.NoError(t, err)
+	pkScript, err := hex.DecodeString("76a9145b5a110d70e3b41b8aa24660c52b57" +
+		"2853b7294288ac")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = serializeRESTUtxos(&buf, 3, tip, []byte{0x01}, []int32{3},
+		[]*wire.TxOut{wire.NewTxOut(5000000000, pkScript)})
+	require.NoError(t, err)
+
+	want := "03000000" +
+		"b5cec4b74bcd177f1b04ee07f8313148d32fa09414d079287d97d3c05f49065d" +
+		"0101" +
+		"01" +
+		"00000000" + "03000000" + "00f2052a01000000" +
+		"19" + "76a9145b5a110d70e3b41b8aa24660c52b572853b7294288ac"
+	require.Equal(t, want, hex.EncodeToString(buf.Bytes()))
+}
+
+// TestRESTRequestErrors ensures malformed REST requests are rejected with the
+// expected status codes before any chain state is accessed.
+func TestRESTRequestErrors(t *testing.T) {
+	t.Parallel()
+
+	txid := strings.Repeat("ab", chainhash.HashSize)
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodPost, "/rest/chaininfo.json", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/rest/unknown.json", http.StatusNotFound, "unknown REST endpoint"},
+		{http.MethodGet, "/rest/chaininfo.bin", http.StatusNotFound, "available: .json"},
+		{http.MethodGet, "/rest/block/" + txid, http.StatusNotFound, "available: .bin, .hex, .json"},
+		{http.MethodGet, "/rest/block/abc.json", http.StatusBadRequest, "Invalid hash: abc"},
+		{http.MethodGet, "/rest/tx/abc.hex", http.StatusBadRequest, "Invalid hash: abc"},
+		{http.MethodGet, "/rest/headers/" + txid + ".bin?count=0", http.StatusBadRequest, "(1-2000): 0"},
+		{http.MethodGet, "/rest/headers/2001/" + txid + ".bin", http.StatusBadRequest, "(1-2000): 2001"},
+		{http.MethodGet, "/rest/blockhashbyheight/-1.json", http.StatusBadRequest, "Invalid height: -1"},
+		{http.MethodGet, "/rest/mempool/contents.json?verbose=1", http.StatusBadRequest, "verbose"},
+		{http.MethodGet, "/rest/getutxos/checkmempool.json", http.StatusBadRequest, "Error: empty request"},
+		{http.MethodGet, "/rest/getutxos/" + txid + "-x.json", http.StatusBadRequest, "Parse error"},
+		{http.MethodGet, "/rest/getutxos" + strings.Repeat("/"+txid+"-0", 16) + ".bin",
+			http.StatusBadRequest, "max outpoints exceeded (max: 15, tried: 16)"},
+	}
+
+	s := &rpcServer{}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		rec := httptest.NewRecorder()
+		s.restRequest(rec, req)
+		require.Equal(t, test.status, rec.Code, test.path)
+		require.Contains(t, rec.Body.String(), test.body, test.path)
+	}
+}
@@ -7,26 +7,31 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/btcsuite/btcd/addrmgr"
+	"github.com/btcsuite/btcd/banman"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
@@ -34,13 +39,23 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/diagnostics"
+	"github.com/btcsuite/btcd/finality"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
+	"github.com/btcsuite/btcd/netsync"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/rebroadcast"
+	"github.com/btcsuite/btcd/reputation"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/watchonly"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmq"
 	"github.com/btcsuite/websocket"
 )
 
@@ -76,9 +91,29 @@ const (
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
 
+	// maxBlockHeadersPerRequest is the maximum number of block headers the
+	// getblockheaders RPC returns as JSON objects.
+	maxBlockHeadersPerRequest = 2000
+
+	// maxRawBlockHeadersPerRequest is the maximum number of block headers
+	// the getblockheaders RPC returns as serialized headers.  It is higher
+	// than the limit for JSON objects since serialized headers are much
+	// cheaper to build and transfer.
+	maxRawBlockHeadersPerRequest = 20000
+
 	// defaultMaxFeeRate is the default value to use(0.1 BTC/kvB) when the
 	// `MaxFee` field is not set when calling `testmempoolaccept`.
 	defaultMaxFeeRate = 0.1
+
+	// maxDescriptorRangeSize is the maximum number of derivation indexes
+	// of ranged descriptors the deriveaddresses and scantxoutset RPCs
+	// expand.
+	maxDescriptorRangeSize = 1000000
+
+	// defaultScanRangeEnd is the last derivation index the scantxoutset
+	// and importdescriptors RPCs expand ranged descriptors to when no range
+	// is given.
+	defaultScanRangeEnd = 1000
 )
 
 var (
@@ -87,6 +122,12 @@ var (
 	// declared here to avoid the overhead of creating the slice on every
 	// invocation for constant data.
 	gbtMutableFields = []string{
+		"time", "transactions/add", "prevblock",
+	}
+
+	// gbtCoinbaseTxnMutableFields are the manipulations the server allows
+	// to be made to block templates which include a coinbase transaction.
+	gbtCoinbaseTxnMutableFields = []string{
 		"time", "transactions/add", "prevblock", "coinbase/append",
 	}
 
@@ -104,7 +145,9 @@ var (
 	// block template generated by the getblocktemplate RPC.    It is
 	// declared here to avoid the overhead of creating the slice on every
 	// invocation for constant data.
-	gbtCapabilities = []string{"proposal"}
+	gbtCapabilities = []string{
+		"coinbasetxn", "coinbasevalue", "longpoll", "proposal",
+	}
 
 	// JSON 2.0 batched request prefix
 	batchedRequestPrefix = []byte("[")
@@ -135,50 +178,103 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":                handleAddNode,
+	"analyzepsbt":            handleAnalyzePsbt,
+	"clearbanned":            handleClearBanned,
+	"collectprofile":         handleCollectProfile,
+	"confirmreorg":           handleConfirmReorg,
 	"createrawtransaction":   handleCreateRawTransaction,
 	"debuglevel":             handleDebugLevel,
+	"decodepsbt":             handleDecodePsbt,
 	"decoderawtransaction":   handleDecodeRawTransaction,
 	"decodescript":           handleDecodeScript,
+	"debugscript":            handleDebugScript,
+	"deriveaddresses":        handleDeriveAddresses,
+	"dumpstate":              handleDumpState,
 	"estimatefee":            handleEstimateFee,
+	"estimatesmartfee":       handleEstimateSmartFee,
+	"fundrawtransaction":     handleFundRawTransaction,
 	"generate":               handleGenerate,
+	"generateblock":          handleGenerateBlock,
+	"generatetoaddress":      handleGenerateToAddress,
+	"generatetodescriptor":   handleGenerateToDescriptor,
 	"getaddednodeinfo":       handleGetAddedNodeInfo,
+	"getaddressbalance":      handleGetAddressBalance,
+	"getaddressutxos":        handleGetAddressUtxos,
 	"getbestblock":           handleGetBestBlock,
 	"getbestblockhash":       handleGetBestBlockHash,
 	"getblock":               handleGetBlock,
 	"getblockchaininfo":      handleGetBlockChainInfo,
 	"getblockcount":          handleGetBlockCount,
+	"getblockfilter":         handleGetBlockFilter,
+	"getblockfrompeer":       handleGetBlockFromPeer,
 	"getblockhash":           handleGetBlockHash,
+	"getblockhashes":         handleGetBlockHashes,
 	"getblockheader":         handleGetBlockHeader,
+	"getblockheaders":        handleGetBlockHeaders,
+	"getblockstats":          handleGetBlockStats,
 	"getblocktemplate":       handleGetBlockTemplate,
 	"getchaintips":           handleGetChainTips,
+	"getchaintxstats":        handleGetChainTxStats,
 	"getcfilter":             handleGetCFilter,
 	"getcfilterheader":       handleGetCFilterHeader,
 	"getconnectioncount":     handleGetConnectionCount,
 	"getcurrentnet":          handleGetCurrentNet,
+	"getdescriptorinfo":      handleGetDescriptorInfo,
 	"getdifficulty":          handleGetDifficulty,
 	"getgenerate":            handleGetGenerate,
 	"gethashespersec":        handleGetHashesPerSec,
 	"getheaders":             handleGetHeaders,
+	"getindexinfo":           handleGetIndexInfo,
 	"getinfo":                handleGetInfo,
+	"getmemoryinfo":          handleGetMemoryInfo,
+	"getmempoolancestors":    handleGetMempoolAncestors,
+	"getmempooldescendants":  handleGetMempoolDescendants,
+	"getmempoolentry":        handleGetMempoolEntry,
 	"getmempoolinfo":         handleGetMempoolInfo,
 	"getmininginfo":          handleGetMiningInfo,
 	"getnettotals":           handleGetNetTotals,
 	"getnetworkhashps":       handleGetNetworkHashPS,
+	"getnetworkinfo":         handleGetNetworkInfo,
 	"getnodeaddresses":       handleGetNodeAddresses,
+	"getorphaninfo":          handleGetOrphanInfo,
 	"getpeerinfo":            handleGetPeerInfo,
+	"getpeerreputation":      handleGetPeerReputation,
+	"getpendingreorg":        handleGetPendingReorg,
 	"getrawmempool":          handleGetRawMempool,
 	"getrawtransaction":      handleGetRawTransaction,
+	"getrpcinfo":             handleGetRPCInfo,
+	"getrpcstats":            handleGetRPCStats,
+	"getspentinfo":           handleGetSpentInfo,
 	"gettxout":               handleGetTxOut,
+	"gettxoutsetinfo":        handleGetTxOutSetInfo,
+	"getunbroadcast":         handleGetUnbroadcast,
+	"getzmqnotifications":    handleGetZmqNotifications,
 	"help":                   handleHelp,
+	"importdescriptors":      handleImportDescriptors,
+	"listbanned":             handleListBanned,
+	"listdescriptors":        handleListDescriptors,
+	"listlockunspent":        handleListLockUnspent,
+	"listunspent":            handleListUnspent,
+	"lockunspent":            handleLockUnspent,
+	"matchblockfilter":       handleMatchBlockFilter,
 	"node":                   handleNode,
 	"ping":                   handlePing,
+	"preciousblock":          handlePreciousBlock,
+	"prioritisetransaction":  handlePrioritiseTransaction,
+	"reloadconfig":           handleReloadConfig,
+	"scantxoutset":           handleScanTxOutSet,
 	"searchrawtransactions":  handleSearchRawTransactions,
 	"sendrawtransaction":     handleSendRawTransaction,
+	"setban":                 handleSetBan,
 	"setgenerate":            handleSetGenerate,
+	"setminingpayouts":       handleSetMiningPayouts,
 	"signmessagewithprivkey": handleSignMessageWithPrivKey,
 	"stop":                   handleStop,
 	"submitblock":            handleSubmitBlock,
+	"submitheader":           handleSubmitHeader,
+	"submitpackage":          handleSubmitPackage,
 	"uptime":                 handleUptime,
+	"utxoupdatepsbt":         handleUtxoUpdatePsbt,
 	"validateaddress":        handleValidateAddress,
 	"verifychain":            handleVerifyChain,
 	"verifymessage":          handleVerifyMessage,
@@ -207,7 +303,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getreceivedbyaccount":   {},
 	"getreceivedbyaddress":   {},
 	"gettransaction":         {},
-	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
 	"getwalletinfo":          {},
 	"importprivkey":          {},
@@ -215,13 +310,10 @@ var rpcAskWallet = map[string]struct{}{
 	"keypoolrefill":          {},
 	"listaccounts":           {},
 	"listaddressgroupings":   {},
-	"listlockunspent":        {},
 	"listreceivedbyaccount":  {},
 	"listreceivedbyaddress":  {},
 	"listsinceblock":         {},
 	"listtransactions":       {},
-	"listunspent":            {},
-	"lockunspent":            {},
 	"move":                   {},
 	"sendfrom":               {},
 	"sendmany":               {},
@@ -238,11 +330,8 @@ var rpcAskWallet = map[string]struct{}{
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
-	"getmempoolentry":  {},
-	"getnetworkinfo":   {},
 	"getwork":          {},
 	"invalidateblock":  {},
-	"preciousblock":    {},
 	"reconsiderblock":  {},
 }
 
@@ -262,32 +351,59 @@ var rpcLimited = map[string]struct{}{
 	"help": {},
 
 	// HTTP/S-only commands
+	"analyzepsbt":           {},
 	"createrawtransaction":  {},
+	"decodepsbt":            {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
+	"debugscript":           {},
+	"deriveaddresses":       {},
 	"estimatefee":           {},
+	"estimatesmartfee":      {},
+	"getaddressbalance":     {},
+	"getaddressutxos":       {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
 	"getblockcount":         {},
+	"getblockfilter":        {},
 	"getblockhash":          {},
+	"getblockhashes":        {},
 	"getblockheader":        {},
+	"getblockheaders":       {},
+	"getblockstats":         {},
 	"getchaintips":          {},
+	"getchaintxstats":       {},
 	"getcfilter":            {},
 	"getcfilterheader":      {},
 	"getcurrentnet":         {},
+	"getdescriptorinfo":     {},
 	"getdifficulty":         {},
 	"getheaders":            {},
+	"getindexinfo":          {},
 	"getinfo":               {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
+	"getnetworkinfo":        {},
+	"getpendingreorg":       {},
+	"getmemoryinfo":         {},
+	"getmempoolancestors":   {},
+	"getorphaninfo":         {},
+	"getmempooldescendants": {},
+	"getmempoolentry":       {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
+	"getspentinfo":          {},
 	"gettxout":              {},
+	"gettxoutsetinfo":       {},
+	"getzmqnotifications":   {},
+	"matchblockfilter":      {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"submitpackage":         {},
 	"uptime":                {},
+	"utxoupdatepsbt":        {},
 	"validateaddress":       {},
 	"verifymessage":         {},
 	"version":               {},
@@ -347,14 +463,18 @@ type gbtWorkState struct {
 	template      *mining.BlockTemplate
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
+	chainParams   *chaincfg.Params
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
 // fields initialized and ready to use.
-func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
+func newGbtWorkState(timeSource blockchain.MedianTimeSource,
+	chainParams *chaincfg.Params) *gbtWorkState {
+
 	return &gbtWorkState{
-		notifyMap:  make(map[chainhash.Hash]map[int64]chan struct{}),
-		timeSource: timeSource,
+		notifyMap:   make(map[chainhash.Hash]map[int64]chan struct{}),
+		timeSource:  timeSource,
+		chainParams: chainParams,
 	}
 }
 
@@ -520,6 +640,33 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.cfg.ConnMgr.ClearBanned(); err != nil {
+		return nil, internalRPCError(err.Error(),
+			"Unable to clear the bans")
+	}
+	return nil, nil
+}
+
+// handleConfirmReorg implements the confirmreorg command.
+func handleConfirmReorg(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ConfirmReorgCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	if err := s.cfg.Chain.ConfirmReorg(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -625,6 +772,67 @@ func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	return mtxHex, nil
 }
 
+// handleCollectProfile implements the collectprofile command.
+func handleCollectProfile(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CollectProfileCmd)
+
+	profileType, err := diagnostics.ParseProfileType(c.Type)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unknown profile type %q -- supported "+
+				"types are %s", c.Type,
+				strings.Join(diagnostics.ProfileTypes(), ", ")),
+		}
+	}
+	var duration time.Duration
+	if c.Seconds != nil {
+		duration = time.Duration(*c.Seconds) * time.Second
+		if duration <= 0 || duration > diagnostics.MaxDuration {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid seconds: " + diagnostics.ErrInvalidDuration.Error(),
+			}
+		}
+	}
+
+	// Timed captures end early when the client disconnects or the server
+	// shuts down, in which case the data captured so far is kept.
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-closeChan:
+		case <-s.quit:
+		case <-done:
+			return
+		}
+		close(quit)
+	}()
+
+	rpcsLog.Infof("Collecting %s profile", profileType)
+	profile, err := s.cfg.Diagnostics.Collect(profileType, duration, quit)
+	if errors.Is(err, diagnostics.ErrCaptureInProgress) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Unable to collect profile: " + err.Error(),
+		}
+	}
+	if err != nil {
+		context := "Failed to collect profile"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	rpcsLog.Infof("Wrote %s profile to %s", profileType, profile.Path)
+
+	return &btcjson.CollectProfileResult{
+		Type:    string(profile.Type),
+		File:    profile.Path,
+		Size:    profile.Size,
+		Seconds: profile.Duration.Seconds(),
+	}, nil
+}
+
 // handleDebugLevel handles debuglevel commands.
 func handleDebugLevel(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.DebugLevelCmd)
@@ -741,6 +949,35 @@ func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap
 	return voutList
 }
 
+// scriptPubKeyResult returns the JSON representation of the passed public key
+// script.
+func scriptPubKeyResult(pkScript []byte, chainParams *chaincfg.Params) btcjson.ScriptPubKeyResult {
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
+		chainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	result := btcjson.ScriptPubKeyResult{
+		Asm:       disbuf,
+		Hex:       hex.EncodeToString(pkScript),
+		ReqSigs:   int32(reqSigs),
+		Type:      scriptClass.String(),
+		Addresses: addresses,
+	}
+	if len(addresses) == 1 && reqSigs <= 1 {
+		result.Address = addresses[0]
+	}
+	return result
+}
+
 // createTxRawResult converts the passed transaction and associated parameters
 // to a raw transaction JSON object.
 func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
@@ -864,6 +1101,265 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// handleDebugScript handles debugscript commands.
+func handleDebugScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DebugScriptCmd)
+
+	pkScript, err := hex.DecodeString(c.ScriptPubKey)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.ScriptPubKey)
+	}
+
+	var amount btcutil.Amount
+	if c.Amount != nil {
+		amount, err = btcutil.NewAmount(*c.Amount)
+		if err != nil || amount < 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid amount",
+			}
+		}
+	}
+
+	// Execute the scripts against the passed transaction, or against a
+	// transaction spending an output with the public key script otherwise.
+	var mtx *wire.MsgTx
+	var inputIndex int
+	if c.HexTx != nil && *c.HexTx != "" {
+		serializedTx, err := hex.DecodeString(*c.HexTx)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.HexTx)
+		}
+		mtx = new(wire.MsgTx)
+		err = mtx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+
+		if c.InputIndex != nil {
+			inputIndex = int(*c.InputIndex)
+		}
+		if inputIndex >= len(mtx.TxIn) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Input index %d out of range "+
+					"for a transaction with %d inputs",
+					inputIndex, len(mtx.TxIn)),
+			}
+		}
+	} else {
+		mtx = wire.NewMsgTx(wire.TxVersion)
+		mtx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+		mtx.AddTxOut(wire.NewTxOut(int64(amount), nil))
+	}
+
+	// The passed signature script and witness replace the ones of the
+	// input.
+	txIn := mtx.TxIn[inputIndex]
+	if c.ScriptSig != nil && *c.ScriptSig != "" {
+		txIn.SignatureScript, err = hex.DecodeString(*c.ScriptSig)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.ScriptSig)
+		}
+	}
+	if c.Witness != nil && len(*c.Witness) != 0 {
+		txIn.Witness = make(wire.TxWitness, 0, len(*c.Witness))
+		for _, item := range *c.Witness {
+			data, err := hex.DecodeString(item)
+			if err != nil {
+				return nil, rpcDecodeHexError(item)
+			}
+			txIn.Witness = append(txIn.Witness, data)
+		}
+	}
+
+	// The previous outputs of the other inputs aren't known, so the
+	// signatures of taproot spends of transactions with several inputs
+	// don't validate.
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+		pkScript, int64(amount),
+	)
+	sigHashes := txscript.NewTxSigHashes(mtx, prevOutFetcher)
+
+	flags := txscript.StandardVerifyFlags
+	reply := btcjson.DebugScriptResult{
+		Flags: flags.String(),
+		Steps: []btcjson.DebugScriptStep{},
+	}
+	vm, err := txscript.NewTracingEngine(
+		pkScript, mtx, inputIndex, flags, nil, sigHashes,
+		int64(amount), prevOutFetcher,
+	)
+	if err == nil {
+		err = vm.Execute()
+
+		for _, step := range vm.Trace().Steps {
+			reply.Steps = append(reply.Steps, btcjson.DebugScriptStep{
+				ScriptIndex: step.ScriptIndex,
+				OpcodeIndex: step.OpcodeIndex,
+				Opcode:      step.Opcode,
+				Executing:   step.Executing,
+				Stack:       hexStack(step.Stack),
+				AltStack:    hexStack(step.AltStack),
+			})
+		}
+	}
+
+	// Failures to set up the engine, such as a signature script which
+	// isn't push only, are reported like execution failures.
+	reply.Valid = err == nil
+	if err != nil {
+		reply.Error = err.Error()
+		if serr, ok := err.(txscript.Error); ok {
+			reply.ErrorCode = serr.ErrorCode.String()
+		}
+	}
+
+	return reply, nil
+}
+
+// hexStack returns the hex encoding of the elements of the passed stack.
+func hexStack(stack [][]byte) []string {
+	elements := make([]string, 0, len(stack))
+	for _, element := range stack {
+		elements = append(elements, hex.EncodeToString(element))
+	}
+	return elements
+}
+
+// invalidDescriptorError returns the RPC error for a descriptor which fails to
+// parse or to derive its scripts.
+func invalidDescriptorError(err error) *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidAddressOrKey,
+		Message: "Invalid descriptor: " + err.Error(),
+	}
+}
+
+// parseDescriptor parses the passed output descriptor and returns an RPC error
+// when it's invalid.
+func parseDescriptor(desc string, requireChecksum bool, params *chaincfg.Params) (*descriptor.Descriptor, error) {
+	d, err := descriptor.Parse(desc, requireChecksum, params)
+	if err != nil {
+		return nil, invalidDescriptorError(err)
+	}
+	return d, nil
+}
+
+// parseDescriptorRange returns the first and last derivation index of the
+// passed range, which is either the last index or a [begin,end] pair like in
+// Bitcoin Core.
+func parseDescriptorRange(r *btcjson.DescriptorRange) (uint32, uint32, error) {
+	var begin, end int
+	switch v := r.Value.(type) {
+	case int:
+		end = v
+	case []int:
+		if len(v) != 2 {
+			return 0, 0, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Range must be specified as [begin,end]",
+			}
+		}
+		begin, end = v[0], v[1]
+	default:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range must be specified as end or as [begin,end]",
+		}
+	}
+
+	var str string
+	switch {
+	case begin < 0 || end < 0:
+		str = "Range should be greater or equal than 0"
+	case begin > end:
+		str = "Range specified as [begin,end] must not have begin after end"
+	case end >= math.MaxInt32 || end-begin >= maxDescriptorRangeSize:
+		str = "Range is too large"
+	default:
+		return uint32(begin), uint32(end), nil
+	}
+	return 0, 0, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: str,
+	}
+}
+
+// handleDeriveAddresses implements the deriveaddresses command.
+func handleDeriveAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DeriveAddressesCmd)
+
+	// Like Bitcoin Core, the checksum is required so a mistyped descriptor
+	// can't result in addresses that can't be spent.
+	desc, err := parseDescriptor(c.Descriptor, true, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	begin, end := uint32(0), uint32(0)
+	switch {
+	case desc.IsRange() && c.Range == nil:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range must be specified for a ranged descriptor",
+		}
+
+	case desc.IsRange():
+		begin, end, err = parseDescriptorRange(c.Range)
+		if err != nil {
+			return nil, err
+		}
+
+	case c.Range != nil:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Range should not be specified for an un-ranged " +
+				"descriptor",
+		}
+	}
+
+	addresses := make(btcjson.DeriveAddressesResult, 0, end-begin+1)
+	for i := begin; ; i++ {
+		addr, err := desc.Address(i)
+		if err == descriptor.ErrNoAddress {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Descriptor does not have a corresponding " +
+					"address",
+			}
+		}
+		if err != nil {
+			return nil, invalidDescriptorError(err)
+		}
+		addresses = append(addresses, addr.EncodeAddress())
+
+		// Checking the end before incrementing the index avoids
+		// overflowing it for the last index of the range.
+		if i == end {
+			break
+		}
+	}
+	return addresses, nil
+}
+
+// handleDumpState implements the dumpstate command.
+func handleDumpState(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	dump, err := s.cfg.DumpState()
+	if err != nil {
+		context := "Failed to dump state"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.DumpStateResult{
+		File: dump.Path,
+		Size: dump.Size,
+	}, nil
+}
+
 // handleEstimateFee handles estimatefee commands.
 func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.EstimateFeeCmd)
@@ -886,28 +1382,86 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// parseEstimateMode returns whether the passed fee estimation mode is the
+// conservative one, which is the default.
+func parseEstimateMode(mode *btcjson.EstimateSmartFeeMode) (bool, error) {
+	if mode == nil {
+		return true, nil
+	}
+	switch *mode {
+	case btcjson.EstimateModeUnset, btcjson.EstimateModeConservative:
+		return true, nil
+	case btcjson.EstimateModeEconomical:
+		return false, nil
+	}
+	return false, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: "Invalid estimate_mode parameter",
+	}
+}
+
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	if c.ConfTarget < 1 || c.ConfTarget > mempool.MaxEstimateFeeTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", mempool.MaxEstimateFeeTarget),
+		}
+	}
+
+	conservative, err := parseEstimateMode(c.EstimateMode)
+	if err != nil {
+		return nil, err
+	}
+
+	feeRate, blocks, err := s.cfg.FeeEstimator.EstimateSmartFee(
+		int32(c.ConfTarget), conservative)
+	if err != nil {
+		return &btcjson.EstimateSmartFeeResult{
+			Errors: []string{err.Error()},
+			Blocks: int64(blocks),
+		}, nil
+	}
+
+	// Transactions paying less than the minimum fee rate of the pool or
+	// the minimum relay fee would not be accepted, so never estimate less.
+	if minFeeRate := s.cfg.TxMemPool.MinFeeRate(); feeRate < minFeeRate {
+		feeRate = minFeeRate
+	}
+	if minRelayTxFee := liveCfg().minRelayTxFee; feeRate < minRelayTxFee {
+		feeRate = minRelayTxFee
+	}
+
+	btcPerKvB := feeRate.ToBTC()
+	return &btcjson.EstimateSmartFeeResult{
+		FeeRate: &btcPerKvB,
+		Blocks:  int64(blocks),
+	}, nil
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
 	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
+	if !s.cfg.CPUMiner.HasPayouts() {
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInternal.Code,
 			Message: "No payment addresses specified " +
-				"via --miningaddr",
+				"via --miningaddr or --miningpayout",
 		}
 	}
 
 	// Respond with an error if there's virtually 0 chance of mining a block
 	// with the CPU.
-	if !s.cfg.ChainParams.GenerateSupported {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to mine a block with the CPU.",
-				s.cfg.ChainParams.Net),
-		}
+	if err := checkGenerateSupported(s, "generate"); err != nil {
+		return nil, err
 	}
 
 	c := cmd.(*btcjson.GenerateCmd)
@@ -940,47 +1494,245 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
-// handleGetAddedNodeInfo handles getaddednodeinfo commands.
-func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
+// checkGenerateSupported returns an error naming the passed command when the
+// current network doesn't support generating blocks with the CPU since there's
+// virtually 0 chance of mining a block.
+func checkGenerateSupported(s *rpcServer, method string) error {
+	if s.cfg.ChainParams.GenerateSupported {
+		return nil
+	}
+	return &btcjson.RPCError{
+		Code: btcjson.ErrRPCDifficulty,
+		Message: fmt.Sprintf("No support for `%s` on the current "+
+			"network, %s, as it's unlikely to be possible to mine "+
+			"a block with the CPU.", method, s.cfg.ChainParams.Net),
+	}
+}
 
-	// Retrieve a list of persistent (added) peers from the server and
-	// filter the list of peers per the specified address (if any).
-	peers := s.cfg.ConnMgr.PersistentPeers()
-	if c.Node != nil {
-		node := *c.Node
-		found := false
-		for i, peer := range peers {
-			if peer.ToPeer().Addr() == node {
-				peers = peers[i : i+1]
-				found = true
-			}
-		}
-		if !found {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCClientNodeNotAdded,
-				Message: "Node has not been added",
-			}
+// generateToAddress generates the passed number of blocks paying to the passed
+// address and returns the hashes of the blocks as the reply of the
+// generatetoaddress and generatetodescriptor commands.
+func generateToAddress(s *rpcServer, numBlocks int64,
+	addr btcutil.Address) (interface{}, error) {
+
+	if numBlocks < 0 || numBlocks > math.MaxUint32 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid number of blocks",
 		}
 	}
 
-	// Without the dns flag, the result is just a slice of the addresses as
-	// strings.
-	if !c.DNS {
-		results := make([]string, 0, len(peers))
-		for _, peer := range peers {
-			results = append(results, peer.ToPeer().Addr())
+	blockHashes, err := s.cfg.CPUMiner.GenerateNBlocksToAddress(
+		uint32(numBlocks), addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
 		}
-		return results, nil
 	}
 
-	// With the dns flag, the result is an array of JSON objects which
-	// include the result of DNS lookups for each peer.
-	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(peers))
-	for _, rpcPeer := range peers {
-		// Set the "address" of the peer which could be an ip address
-		// or a domain name.
-		peer := rpcPeer.ToPeer()
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
+// decodeGenerateAddress decodes the passed address the blocks generated by the
+// generate family of commands pay to and ensures it is for the current network.
+func decodeGenerateAddress(s *rpcServer, encodedAddr string) (btcutil.Address, error) {
+	addr, err := btcutil.DecodeAddress(encodedAddr, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + encodedAddr +
+				" is for the wrong network",
+		}
+	}
+	return addr, nil
+}
+
+// descriptorGenerateAddress returns the address of the output script of the
+// passed un-ranged descriptor which blocks generated by the generate family of
+// commands pay to.
+func descriptorGenerateAddress(s *rpcServer, descStr string) (btcutil.Address, error) {
+	desc, err := parseDescriptor(descStr, false, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	if desc.IsRange() {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Ranged descriptor not accepted",
+		}
+	}
+	addr, err := desc.Address(0)
+	if err == descriptor.ErrNoAddress {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Descriptor does not have a corresponding " +
+				"address",
+		}
+	}
+	if err != nil {
+		return nil, invalidDescriptorError(err)
+	}
+	return addr, nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+
+	if err := checkGenerateSupported(s, "generatetoaddress"); err != nil {
+		return nil, err
+	}
+	addr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	return generateToAddress(s, c.NumBlocks, addr)
+}
+
+// handleGenerateToDescriptor handles generatetodescriptor commands.
+func handleGenerateToDescriptor(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToDescriptorCmd)
+
+	if err := checkGenerateSupported(s, "generatetodescriptor"); err != nil {
+		return nil, err
+	}
+	addr, err := descriptorGenerateAddress(s, c.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	return generateToAddress(s, c.NumBlocks, addr)
+}
+
+// handleGenerateBlock handles generateblock commands.
+func handleGenerateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateBlockCmd)
+
+	if err := checkGenerateSupported(s, "generateblock"); err != nil {
+		return nil, err
+	}
+
+	// The output is either an address or a descriptor.
+	addr, err := decodeGenerateAddress(s, c.Output)
+	if err != nil {
+		addr, err = descriptorGenerateAddress(s, c.Output)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or descriptor: " +
+					c.Output,
+			}
+		}
+	}
+
+	// Each transaction is either the id of a transaction in the memory
+	// pool or a raw transaction, which doesn't need to be in the memory
+	// pool.
+	txs := make([]*btcutil.Tx, 0, len(c.Transactions))
+	for _, str := range c.Transactions {
+		if len(str) == chainhash.MaxHashStringSize {
+			txHash, err := chainhash.NewHashFromStr(str)
+			if err != nil {
+				return nil, rpcDecodeHexError(str)
+			}
+			tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidAddressOrKey,
+					Message: fmt.Sprintf("Transaction %v not "+
+						"in mempool", txHash),
+				}
+			}
+			txs = append(txs, tx)
+			continue
+		}
+
+		serializedTx, err := hex.DecodeString(str)
+		if err != nil {
+			return nil, rpcDecodeHexError(str)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "Transaction decode failed for " + str,
+			}
+		}
+		txs = append(txs, btcutil.NewTx(&msgTx))
+	}
+
+	// Failing to generate the block is reported as a verification error
+	// since it means the requested block is invalid, so check whether the
+	// server is already mining beforehand.
+	if s.cfg.CPUMiner.IsMining() {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "Server is already CPU mining. Please call " +
+				"`setgenerate 0` before calling `generateblock`.",
+		}
+	}
+	hash, err := s.cfg.CPUMiner.GenerateBlock(addr, txs)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: "Block is invalid: " + err.Error(),
+		}
+	}
+	return &btcjson.GenerateBlockResult{Hash: hash.String()}, nil
+}
+
+// handleGetAddedNodeInfo handles getaddednodeinfo commands.
+func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
+
+	// Retrieve a list of persistent (added) peers from the server and
+	// filter the list of peers per the specified address (if any).
+	peers := s.cfg.ConnMgr.PersistentPeers()
+	if c.Node != nil {
+		node := *c.Node
+		found := false
+		for i, peer := range peers {
+			if peer.ToPeer().Addr() == node {
+				peers = peers[i : i+1]
+				found = true
+			}
+		}
+		if !found {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeNotAdded,
+				Message: "Node has not been added",
+			}
+		}
+	}
+
+	// Without the dns flag, the result is just a slice of the addresses as
+	// strings.
+	if !c.DNS {
+		results := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			results = append(results, peer.ToPeer().Addr())
+		}
+		return results, nil
+	}
+
+	// With the dns flag, the result is an array of JSON objects which
+	// include the result of DNS lookups for each peer.
+	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(peers))
+	for _, rpcPeer := range peers {
+		// Set the "address" of the peer which could be an ip address
+		// or a domain name.
+		peer := rpcPeer.ToPeer()
 		var result btcjson.GetAddedNodeInfoResult
 		result.AddedNode = peer.Addr()
 		result.Connected = btcjson.Bool(peer.Connected())
@@ -1030,6 +1782,108 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
+// balanceIndexScripts returns the distinct addresses of the passed request
+// along with the scripts which pay to them.  An error is returned when the
+// balance index isn't enabled or an address is invalid.
+func balanceIndexScripts(s *rpcServer, req *btcjson.AddressesRequest) ([]string, [][]byte, error) {
+	if s.cfg.BalanceIndex == nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Balance index must be enabled (--balanceindex)",
+		}
+	}
+
+	params := s.cfg.ChainParams
+	seen := make(map[string]struct{}, len(req.Addresses))
+	addrs := make([]string, 0, len(req.Addresses))
+	scripts := make([][]byte, 0, len(req.Addresses))
+	for _, encodedAddr := range req.Addresses {
+		if _, ok := seen[encodedAddr]; ok {
+			continue
+		}
+		seen[encodedAddr] = struct{}{}
+
+		addr, err := btcutil.DecodeAddress(encodedAddr, params)
+		if err == nil && !addr.IsForNet(params) {
+			err = fmt.Errorf("address %s is not for %s", encodedAddr,
+				params.Name)
+		}
+		if err != nil {
+			return nil, nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
+		addrs = append(addrs, encodedAddr)
+		scripts = append(scripts, pkScript)
+	}
+	return addrs, scripts, nil
+}
+
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressBalanceCmd)
+	_, scripts, err := balanceIndexScripts(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.GetAddressBalanceResult
+	for _, pkScript := range scripts {
+		balance, err := s.cfg.BalanceIndex.ScriptBalance(pkScript)
+		if err != nil {
+			context := "Failed to retrieve address balance"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Balance += balance.Balance()
+		result.Received += balance.Received
+	}
+	return &result, nil
+}
+
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressUtxosCmd)
+	addrs, scripts, err := balanceIndexScripts(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]btcjson.GetAddressUtxosResult, 0)
+	for i, pkScript := range scripts {
+		utxos, err := s.cfg.BalanceIndex.ScriptUtxos(pkScript)
+		if err != nil {
+			context := "Failed to retrieve address utxos"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		scriptHex := hex.EncodeToString(pkScript)
+		for _, utxo := range utxos {
+			result = append(result, btcjson.GetAddressUtxosResult{
+				Address:     addrs[i],
+				Txid:        utxo.OutPoint.Hash.String(),
+				OutputIndex: utxo.OutPoint.Index,
+				Script:      scriptHex,
+				Satoshis:    utxo.Amount,
+				Height:      utxo.Height,
+			})
+		}
+	}
+
+	// Return the outputs in the order they were created in like the
+	// insight API does.
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Height < result[j].Height
+	})
+	return result, nil
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the
@@ -1069,6 +1923,32 @@ func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
 	return diff
 }
 
+// fetchPrunedBlockBytes fetches the main chain block with the provided hash,
+// which is no longer in the database because it was pruned, from a connected
+// peer and returns it serialized.
+func fetchPrunedBlockBytes(s *rpcServer, hash *chainhash.Hash) ([]byte, error) {
+	if !s.cfg.Chain.MainChainHasBlock(hash) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	blk, err := s.cfg.SyncMgr.FetchBlock(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not available (pruned data): " + err.Error(),
+		}
+	}
+	blkBytes, err := blk.Bytes()
+	if err != nil {
+		context := "Failed to serialize block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return blkBytes, nil
+}
+
 // handleGetBlock implements the getblock command.
 func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockCmd)
@@ -1078,16 +1958,11 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	if err != nil {
 		return nil, rpcDecodeHexError(c.Hash)
 	}
-	var blkBytes []byte
-	err = s.cfg.DB.View(func(dbTx database.Tx) error {
-		var err error
-		blkBytes, err = dbTx.FetchBlock(hash)
-		return err
-	})
+	blkBytes, err := fetchBlockBytes(s.cfg.DB, s.cfg.Chain, hash)
 	if err != nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCBlockNotFound,
-			Message: "Block not found",
+		blkBytes, err = fetchPrunedBlockBytes(s, hash)
+		if err != nil {
+			return nil, err
 		}
 	}
 	// If verbosity is 0, return the serialized block as a hex encoded string.
@@ -1153,6 +2028,13 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 
 		blockReply.Tx = txNames
 	} else {
+		// Verbosity 3 additionally includes the outputs spent by the
+		// inputs.
+		var prevOuts map[wire.OutPoint]*blockchain.SpentTxOut
+		if *c.Verbosity >= 3 {
+			prevOuts = fetchBlockPrevOuts(s, blk)
+		}
+
 		txns := blk.Transactions()
 		rawTxns := make([]btcjson.TxRawResult, len(txns))
 		for i, tx := range txns {
@@ -1162,6 +2044,18 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 			if err != nil {
 				return nil, err
 			}
+			for j, txIn := range tx.MsgTx().TxIn {
+				stxo, ok := prevOuts[txIn.PreviousOutPoint]
+				if !ok {
+					continue
+				}
+				rawTxn.Vin[j].PrevOut = &btcjson.PrevOutResult{
+					Generated:    stxo.IsCoinBase,
+					Height:       stxo.Height,
+					Value:        btcutil.Amount(stxo.Amount).ToBTC(),
+					ScriptPubKey: scriptPubKeyResult(stxo.PkScript, params),
+				}
+			}
 			rawTxns[i] = *rawTxn
 		}
 		blockReply.RawTx = rawTxns
@@ -1170,6 +2064,138 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return blockReply, nil
 }
 
+// fetchBlockPrevOuts returns the outputs spent by the inputs of the passed main
+// chain block keyed by their outpoints.  They are taken from the spend journal
+// when it is available and otherwise looked up in the block itself, the utxo
+// set and, when enabled, the transaction index.  Outputs which can't be found,
+// which happens for pruned blocks when the transaction index is disabled, are
+// omitted.
+func fetchBlockPrevOuts(s *rpcServer, blk *btcutil.Block) map[wire.OutPoint]*blockchain.SpentTxOut {
+	txns := blk.Transactions()
+	prevOuts := make(map[wire.OutPoint]*blockchain.SpentTxOut)
+
+	// The spend journal has an entry for each input of the non-coinbase
+	// transactions in the order they appear in the block.
+	stxos, err := s.cfg.Chain.FetchSpendJournal(blk)
+	if err == nil {
+		var stxoIdx int
+		for _, tx := range txns[1:] {
+			for _, txIn := range tx.MsgTx().TxIn {
+				if stxoIdx >= len(stxos) {
+					break
+				}
+				prevOuts[txIn.PreviousOutPoint] = &stxos[stxoIdx]
+				stxoIdx++
+			}
+		}
+		return prevOuts
+	}
+	rpcsLog.Debugf("Unable to fetch spend journal of block %v: %v",
+		blk.Hash(), err)
+
+	blockTxns := make(map[chainhash.Hash]*btcutil.Tx, len(txns))
+	for _, tx := range txns {
+		blockTxns[*tx.Hash()] = tx
+	}
+	for _, tx := range txns[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			op := txIn.PreviousOutPoint
+			stxo := fetchPrevOut(s, op, blockTxns, blk.Height())
+			if stxo != nil {
+				prevOuts[op] = stxo
+			}
+		}
+	}
+	return prevOuts
+}
+
+// fetchPrevOut looks up the output of the passed outpoint spent by a block at
+// the passed height with the passed transactions.  It returns nil when the
+// output can't be found.
+func fetchPrevOut(s *rpcServer, op wire.OutPoint, blockTxns map[chainhash.Hash]*btcutil.Tx,
+	blockHeight int32) *blockchain.SpentTxOut {
+
+	newSpentTxOut := func(msgTx *wire.MsgTx, height int32) *blockchain.SpentTxOut {
+		if op.Index >= uint32(len(msgTx.TxOut)) {
+			return nil
+		}
+		txOut := msgTx.TxOut[op.Index]
+		return &blockchain.SpentTxOut{
+			Amount:     txOut.Value,
+			PkScript:   txOut.PkScript,
+			Height:     height,
+			IsCoinBase: blockchain.IsCoinBaseTx(msgTx),
+		}
+	}
+
+	// Outputs created by an earlier transaction of the same block.
+	if tx, ok := blockTxns[op.Hash]; ok {
+		return newSpentTxOut(tx.MsgTx(), blockHeight)
+	}
+
+	// Outputs which haven't been spent in the main chain yet, which is the
+	// case when the block has been disconnected.
+	entry, err := s.cfg.Chain.FetchUtxoEntry(op)
+	if err == nil && entry != nil && !entry.IsSpent() {
+		return &blockchain.SpentTxOut{
+			Amount:     entry.Amount(),
+			PkScript:   entry.PkScript(),
+			Height:     entry.BlockHeight(),
+			IsCoinBase: entry.IsCoinBase(),
+		}
+	}
+
+	if s.cfg.TxIndex == nil {
+		return nil
+	}
+	blockRegion, err := s.cfg.TxIndex.TxBlockRegion(&op.Hash)
+	if err != nil || blockRegion == nil {
+		return nil
+	}
+	height, err := s.cfg.Chain.BlockHeightByHash(blockRegion.Hash)
+	if err != nil {
+		return nil
+	}
+	var txBytes []byte
+	err = s.cfg.DB.View(func(dbTx database.Tx) error {
+		var err error
+		txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+		return err
+	})
+	if err != nil {
+		return nil
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil
+	}
+	return newSpentTxOut(&msgTx, height)
+}
+
+// softForkName returns the human readable name of the BIP0009 soft-fork
+// deployment identified by the passed deployment ID along with whether or not
+// the deployment is known.
+func softForkName(deployment int) (string, bool) {
+	switch deployment {
+	case chaincfg.DeploymentTestDummy:
+		return "dummy", true
+
+	case chaincfg.DeploymentTestDummyMinActivation:
+		return "dummy-min-activation", true
+
+	case chaincfg.DeploymentCSV:
+		return "csv", true
+
+	case chaincfg.DeploymentSegwit:
+		return "segwit", true
+
+	case chaincfg.DeploymentTaproot:
+		return "taproot", true
+	}
+
+	return "", false
+}
+
 // softForkStatus converts a ThresholdState state into a human readable string
 // corresponding to the particular state.
 func softForkStatus(state blockchain.ThresholdState) (string, error) {
@@ -1204,12 +2230,26 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		BestBlockHash: chainSnapshot.Hash.String(),
 		Difficulty:    getDifficultyRatio(chainSnapshot.Bits, params),
 		MedianTime:    chainSnapshot.MedianTime.Unix(),
-		Pruned:        cfg.Prune != 0,
+		Pruned:        cfg.Prune != 0 || cfg.FiltersOnly,
 		SoftForks: &btcjson.SoftForks{
 			Bip9SoftForks: make(map[string]*btcjson.Bip9SoftForkDescription),
 		},
 	}
 
+	// Report the status of the finality module when it is enabled.
+	if s.cfg.FinalityMgr != nil {
+		status := s.cfg.FinalityMgr.Status()
+		chainInfo.Finality = &btcjson.FinalityInfo{
+			Signers:         status.Signers,
+			Quorum:          status.Quorum,
+			FinalizedHeight: status.FinalizedHeight,
+			Pending:         status.Pending,
+		}
+		if status.FinalizedHash != nil {
+			chainInfo.Finality.FinalizedHash = status.FinalizedHash.String()
+		}
+	}
+
 	// Next, populate the response with information describing the current
 	// status of soft-forks deployed via the super-majority block
 	// signalling mechanism.
@@ -1249,24 +2289,8 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 	for deployment, deploymentDetails := range params.Deployments {
 		// Map the integer deployment ID into a human readable
 		// fork-name.
-		var forkName string
-		switch deployment {
-		case chaincfg.DeploymentTestDummy:
-			forkName = "dummy"
-
-		case chaincfg.DeploymentTestDummyMinActivation:
-			forkName = "dummy-min-activation"
-
-		case chaincfg.DeploymentCSV:
-			forkName = "csv"
-
-		case chaincfg.DeploymentSegwit:
-			forkName = "segwit"
-
-		case chaincfg.DeploymentTaproot:
-			forkName = "taproot"
-
-		default:
+		forkName, ok := softForkName(deployment)
+		if !ok {
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
 				Message: fmt.Sprintf("Unknown deployment %v "+
@@ -1321,6 +2345,47 @@ func handleGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return int64(best.Height), nil
 }
 
+// handleGetBlockFromPeer implements the getblockfrompeer command.
+func handleGetBlockFromPeer(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockFromPeerCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	// Only blocks whose headers are known can be requested, since there
+	// would otherwise be no way to tell whether the peer sent the right
+	// block.
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block header missing",
+		}
+	}
+	haveBlock, err := s.cfg.Chain.HaveBlock(hash)
+	if err != nil {
+		context := "Failed to check block existence"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if haveBlock {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block already downloaded",
+		}
+	}
+
+	err = s.cfg.SyncMgr.RequestBlock(hash, c.PeerID)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Peer does not exist",
+		}
+	}
+
+	return struct{}{}, nil
+}
+
 // handleGetBlockHash implements the getblockhash command.
 func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHashCmd)
@@ -1335,6 +2400,29 @@ func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return hash.String(), nil
 }
 
+// handleGetBlockHashes implements the getblockhashes command.
+func handleGetBlockHashes(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.TimestampIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Timestamp index must be enabled (--timestampindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetBlockHashesCmd)
+	hashes, err := s.cfg.TimestampIndex.BlockHashesByTime(c.Low, c.High)
+	if err != nil {
+		context := "Failed to retrieve block hashes"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := make([]string, 0, len(hashes))
+	for i := range hashes {
+		result = append(result, hashes[i].String())
+	}
+	return result, nil
+}
+
 // handleGetBlockHeader implements the getblockheader command.
 func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHeaderCmd)
@@ -1403,6 +2491,288 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 	return blockHeaderReply, nil
 }
 
+// handleGetBlockHeaders implements the getblockheaders command.
+func handleGetBlockHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHeadersCmd)
+
+	format := "json"
+	if c.Format != nil {
+		format = *c.Format
+	}
+	var maxCount uint32
+	switch format {
+	case "json":
+		maxCount = maxBlockHeadersPerRequest
+	case "hex", "raw":
+		maxCount = maxRawBlockHeadersPerRequest
+	default:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid format %q: must be json, "+
+				"hex or raw", format),
+		}
+	}
+	if c.Count == 0 || c.Count > maxCount {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Count must be between 1 and %d "+
+				"for format %s", maxCount, format),
+		}
+	}
+
+	// Determine the height of the first requested header.  Blocks given
+	// by hash must be part of the main chain.
+	var startHeight int32
+	switch start := c.Start.Value.(type) {
+	case string:
+		hash, err := chainhash.NewHashFromStr(start)
+		if err != nil {
+			return nil, rpcDecodeHexError(start)
+		}
+		startHeight, err = s.cfg.Chain.BlockHeightByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+	case int:
+		startHeight = int32(start)
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Start must be a block hash or height",
+		}
+	}
+	best := s.cfg.Chain.BestSnapshot()
+	if startHeight < 0 || startHeight > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+
+	// Limit the range to the best block known when the request was
+	// received so the confirmations are consistent with the headers.
+	endHeight := startHeight + int32(c.Count)
+	if endHeight > best.Height+1 {
+		endHeight = best.Height + 1
+	}
+	headers, err := s.cfg.Chain.HeaderRange(startHeight, endHeight)
+	if err != nil {
+		context := "Failed to fetch block headers"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	switch format {
+	case "raw":
+		// Return the serialized headers concatenated into a single
+		// hex-encoded string.
+		buf := bytes.NewBuffer(make([]byte, 0,
+			len(headers)*wire.MaxBlockHeaderPayload))
+		for i := range headers {
+			if err := headers[i].Serialize(buf); err != nil {
+				context := "Failed to serialize block header"
+				return nil, internalRPCError(err.Error(), context)
+			}
+		}
+		return hex.EncodeToString(buf.Bytes()), nil
+
+	case "hex":
+		hexHeaders := make([]string, len(headers))
+		var buf bytes.Buffer
+		for i := range headers {
+			if err := headers[i].Serialize(&buf); err != nil {
+				context := "Failed to serialize block header"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			hexHeaders[i] = hex.EncodeToString(buf.Bytes())
+			buf.Reset()
+		}
+		return hexHeaders, nil
+	}
+
+	// The hash of each header is the previous hash of the one following
+	// it, so only the next hash of the last header has to be looked up.
+	var nextHashString string
+	lastHeight := startHeight + int32(len(headers)) - 1
+	if nextHash, err := s.cfg.Chain.BlockHashByHeight(lastHeight + 1); err == nil {
+		nextHashString = nextHash.String()
+	}
+
+	params := s.cfg.ChainParams
+	results := make([]btcjson.GetBlockHeaderVerboseResult, len(headers))
+	for i := len(headers) - 1; i >= 0; i-- {
+		header := &headers[i]
+		height := startHeight + int32(i)
+		hashString := header.BlockHash().String()
+		results[i] = btcjson.GetBlockHeaderVerboseResult{
+			Hash:          hashString,
+			Confirmations: int64(1 + best.Height - height),
+			Height:        height,
+			Version:       header.Version,
+			VersionHex:    fmt.Sprintf("%08x", header.Version),
+			MerkleRoot:    header.MerkleRoot.String(),
+			NextHash:      nextHashString,
+			PreviousHash:  header.PrevBlock.String(),
+			Nonce:         uint64(header.Nonce),
+			Time:          header.Timestamp.Unix(),
+			Bits:          strconv.FormatInt(int64(header.Bits), 16),
+			Difficulty:    getDifficultyRatio(header.Bits, params),
+		}
+		nextHashString = hashString
+	}
+	return results, nil
+}
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockStatsCmd)
+
+	var hash *chainhash.Hash
+	switch v := c.HashOrHeight.Value.(type) {
+	case int:
+		best := s.cfg.Chain.BestSnapshot()
+		if v < 0 || v > int(best.Height) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Target block height %d is "+
+					"out of range [0, %d]", v, best.Height),
+			}
+		}
+		var err error
+		hash, err = s.cfg.Chain.BlockHashByHeight(int32(v))
+		if err != nil {
+			context := "Failed to fetch block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+	case string:
+		var err error
+		hash, err = chainhash.NewHashFromStr(v)
+		if err != nil {
+			return nil, rpcDecodeHexError(v)
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "hash_or_height must be a block hash or height",
+		}
+	}
+
+	// Validate the selected statistics before doing any work.
+	var selected map[string]struct{}
+	if c.Stats != nil && len(*c.Stats) > 0 {
+		selected = make(map[string]struct{}, len(*c.Stats))
+		for _, stat := range *c.Stats {
+			if _, ok := blockStatsFields[stat]; !ok {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: fmt.Sprintf("Invalid selected "+
+						"statistic '%s'", stat),
+				}
+			}
+			selected[stat] = struct{}{}
+		}
+	}
+
+	stats, err := s.cfg.Chain.BlockStats(hash)
+	if err != nil {
+		if !s.cfg.Chain.MainChainHasBlock(hash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+
+		// The block or the outputs it spends are no longer available
+		// when the block was pruned.
+		if cfg.Prune != 0 || cfg.FiltersOnly {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not available (pruned data): " + err.Error(),
+			}
+		}
+		context := "Failed to calculate block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.GetBlockStatsResult{
+		AverageFee:             stats.AvgFee,
+		AverageFeeRate:         stats.AvgFeeRate,
+		AverageTxSize:          stats.AvgTxSize,
+		FeeratePercentiles:     stats.FeeRatePercentiles[:],
+		Hash:                   stats.Hash.String(),
+		Height:                 int64(stats.Height),
+		Ins:                    stats.Ins,
+		MaxFee:                 stats.MaxFee,
+		MaxFeeRate:             stats.MaxFeeRate,
+		MaxTxSize:              stats.MaxTxSize,
+		MedianFee:              stats.MedianFee,
+		MedianTime:             stats.MedianTime,
+		MedianTxSize:           stats.MedianTxSize,
+		MinFee:                 stats.MinFee,
+		MinFeeRate:             stats.MinFeeRate,
+		MinTxSize:              stats.MinTxSize,
+		Outs:                   stats.Outs,
+		SegWitTotalSize:        stats.SegWitTotalSize,
+		SegWitTotalWeight:      stats.SegWitTotalWeight,
+		SegWitTxs:              stats.SegWitTxs,
+		Subsidy:                stats.Subsidy,
+		Time:                   stats.Time,
+		TotalOut:               stats.TotalOut,
+		TotalSize:              stats.TotalSize,
+		TotalWeight:            stats.TotalWeight,
+		TotalFee:               stats.TotalFee,
+		Txs:                    stats.Txs,
+		UTXOIncrease:           stats.UtxoIncrease,
+		UTXOSizeIncrease:       stats.UtxoSizeIncrease,
+		UTXOIncreaseActual:     stats.UtxoIncreaseActual,
+		UTXOSizeIncreaseActual: stats.UtxoSizeIncreaseActual,
+	}
+	if selected == nil {
+		return result, nil
+	}
+	return filterBlockStats(result, selected)
+}
+
+// blockStatsFields are the names of the statistics returned by getblockstats,
+// which can be selected through its stats parameter.
+var blockStatsFields = jsonFieldNames(btcjson.GetBlockStatsResult{})
+
+// jsonFieldNames returns the JSON names of the fields of the passed struct.
+func jsonFieldNames(v interface{}) map[string]struct{} {
+	rt := reflect.TypeOf(v)
+	names := make(map[string]struct{}, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		name := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		names[name] = struct{}{}
+	}
+	return names
+}
+
+// filterBlockStats returns the passed getblockstats result reduced to the
+// selected statistics like Bitcoin Core does.
+func filterBlockStats(result *btcjson.GetBlockStatsResult, selected map[string]struct{}) (map[string]json.RawMessage, error) {
+	marshalled, err := json.Marshal(result)
+	if err != nil {
+		context := "Failed to marshal block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(marshalled, &all); err != nil {
+		context := "Failed to unmarshal block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for name := range all {
+		if _, ok := selected[name]; !ok {
+			delete(all, name)
+		}
+	}
+	return all, nil
+}
+
 // encodeTemplateID encodes the passed details into an ID that can be used to
 // uniquely identify a block template.
 func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
@@ -1480,15 +2850,17 @@ func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, lastGen
 	}
 }
 
-// NotifyBlockConnected uses the newly-connected block to notify any long poll
-// clients with a new block template when their existing block template is
-// stale due to the newly connected block.
-func (state *gbtWorkState) NotifyBlockConnected(blockHash *chainhash.Hash) {
+// NotifyBestBlock uses the hash of the tip of the best chain to notify any long
+// poll clients with a new block template when their existing block template is
+// stale due to the tip having changed.  This is the case when a block is
+// connected to the tip, the chain reorganizes to another branch or the tip is
+// disconnected.
+func (state *gbtWorkState) NotifyBestBlock(tipHash *chainhash.Hash) {
 	go func() {
 		state.Lock()
 		defer state.Unlock()
 
-		state.notifyLongPollers(blockHash, state.lastTxUpdate)
+		state.notifyLongPollers(tipHash, state.lastTxUpdate)
 	}()
 }
 
@@ -1664,18 +3036,136 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		targetDifficulty = fmt.Sprintf("%064x",
 			blockchain.CompactToBig(msgBlock.Header.Bits))
 
-		// Update the time of the block template to the current time
-		// while accounting for the median time of the past several
-		// blocks per the chain consensus rules.
-		generator.UpdateBlockTime(msgBlock)
-		msgBlock.Header.Nonce = 0
+		// Update the time of the block template to the current time
+		// while accounting for the median time of the past several
+		// blocks per the chain consensus rules.
+		generator.UpdateBlockTime(msgBlock)
+		msgBlock.Header.Nonce = 0
+
+		rpcsLog.Debugf("Updated block template (timestamp %v, "+
+			"target %s)", msgBlock.Header.Timestamp,
+			targetDifficulty)
+	}
+
+	return nil
+}
+
+// gbtDeployments houses the BIP0009 details of a block template requested by
+// a getblocktemplate client.
+type gbtDeployments struct {
+	// rules are the names of the active deployments the block must be
+	// valid under.  Rules prefixed with '!' change the block structure in
+	// ways the client must understand.
+	rules []string
+
+	// available maps the names of the deployments which are still being
+	// signalled for to their version bit.
+	available map[string]int32
+
+	// clearMask holds the version bits of the deployments the client can't
+	// signal for since it doesn't support them.
+	clearMask int32
+}
+
+// gbtForcedDeployment returns whether or not the deployment identified by the
+// passed deployment ID may be signalled for and enforced by block templates
+// without explicit support from the client.  Segwit changes the structure of
+// the coinbase and the serialization of the transactions, so clients have to
+// opt in to it.
+func gbtForcedDeployment(deployment int) bool {
+	return deployment != chaincfg.DeploymentSegwit
+}
+
+// newGBTDeployments returns the BIP0009 details of a block template for a
+// client supporting the passed rules, using the passed function to query the
+// state of each deployment for the next block.  An error is returned when an
+// active deployment requires explicit client support the client lacks.
+//
+// See https://github.com/bitcoin/bips/blob/master/bip-0009.mediawiki#getblocktemplate-changes
+func newGBTDeployments(params *chaincfg.Params, clientRules []string,
+	thresholdState func(uint32) (blockchain.ThresholdState, error)) (*gbtDeployments, error) {
+
+	supported := make(map[string]struct{}, len(clientRules))
+	for _, rule := range clientRules {
+		supported[rule] = struct{}{}
+	}
+
+	result := &gbtDeployments{
+		rules:     make([]string, 0, len(params.Deployments)),
+		available: make(map[string]int32),
+	}
+	for deployment, details := range params.Deployments {
+		name, ok := softForkName(deployment)
+		if !ok {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInternal.Code,
+				Message: fmt.Sprintf("Unknown deployment %v "+
+					"detected", deployment),
+			}
+		}
+
+		state, err := thresholdState(uint32(deployment))
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		_, clientSupports := supported[name]
+		forced := gbtForcedDeployment(deployment)
+		switch state {
+		case blockchain.ThresholdStarted, blockchain.ThresholdLockedIn:
+			// Only signal for deployments which don't require
+			// explicit support when the client lacks it.
+			bit := int32(1) << details.BitNumber
+			result.available[name] = int32(details.BitNumber)
+			if !clientSupports && !forced {
+				result.clearMask |= bit
+			}
 
-		rpcsLog.Debugf("Updated block template (timestamp %v, "+
-			"target %s)", msgBlock.Header.Timestamp,
-			targetDifficulty)
+		case blockchain.ThresholdActive:
+			rule := name
+			if !forced {
+				rule = "!" + name
+				if !clientSupports {
+					return nil, &btcjson.RPCError{
+						Code: btcjson.ErrRPCInvalidParameter,
+						Message: fmt.Sprintf("getblocktemplate "+
+							"must be called with the %s "+
+							"rule set (call with "+
+							"{\"rules\": [\"%s\"]})",
+							name, name),
+					}
+				}
+			}
+			result.rules = append(result.rules, rule)
+		}
 	}
 
-	return nil
+	return result, nil
+}
+
+// gbtWitnessCommitment returns the script of the witness commitment output the
+// coinbase of the passed block template must include.  The default commitment
+// of templates without any witness transactions commits to their transactions
+// and an all-zero witness nonce as well, which is what the client is expected
+// to place in the coinbase witness.
+func gbtWitnessCommitment(template *mining.BlockTemplate) []byte {
+	commitment := template.WitnessCommitment
+	if commitment == nil {
+		// The witness nonce is all zeroes and the wtxid of the coinbase
+		// is all zeroes by definition.
+		block := btcutil.NewBlock(template.Block)
+		witnessRoot := blockchain.CalcMerkleRoot(block.Transactions(),
+			true)
+		var preimage [64]byte
+		copy(preimage[:32], witnessRoot[:])
+		commitment = chainhash.DoubleHashB(preimage[:])
+	}
+
+	script := make([]byte, 0, len(blockchain.WitnessMagicBytes)+
+		len(commitment))
+	script = append(script, blockchain.WitnessMagicBytes...)
+	return append(script, commitment...)
 }
 
 // blockTemplateResult returns the current block template associated with the
@@ -1683,7 +3173,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 // and returned to the caller.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
+func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, deployments *gbtDeployments, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
 	// Ensure the timestamps are still in valid range for the template.
 	// This should really only ever happen if the local clock is changed
 	// after the template is generated, but it's important to avoid serving
@@ -1770,7 +3260,9 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		SigOpLimit:   blockchain.MaxBlockSigOpsCost,
 		SizeLimit:    wire.MaxBlockPayload,
 		Transactions: transactions,
-		Version:      header.Version,
+		Version:      header.Version &^ deployments.clearMask,
+		Rules:        deployments.rules,
+		VbAvailable:  deployments.available,
 		LongPollID:   templateID,
 		SubmitOld:    submitOld,
 		Target:       targetDifficulty,
@@ -1780,10 +3272,27 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		NonceRange:   gbtNonceRange,
 		Capabilities: gbtCapabilities,
 	}
-	// If the generated block template includes transactions with witness
-	// data, then include the witness commitment in the GBT result.
-	if template.WitnessCommitment != nil {
-		reply.DefaultWitnessCommitment = hex.EncodeToString(template.WitnessCommitment)
+
+	// Once segwit is active, the coinbase of every block may commit to the
+	// witness data of its transactions, so suggest the commitment matching
+	// the template as it is to clients creating their own coinbase, even
+	// when it has no witness transactions.  The coinbase provided to other
+	// clients only includes the commitment when it's required.
+	includeCommitment := template.WitnessCommitment != nil
+	for _, rule := range deployments.rules {
+		if rule == "!segwit" && useCoinbaseValue {
+			includeCommitment = true
+		}
+	}
+	if includeCommitment {
+		reply.DefaultWitnessCommitment = hex.EncodeToString(
+			gbtWitnessCommitment(template))
+	}
+
+	// The blocks of signet networks must carry a solution to the challenge
+	// of the network.
+	if challenge := state.chainParams.SignetChallenge; challenge != nil {
+		reply.SignetChallenge = hex.EncodeToString(challenge)
 	}
 
 	if useCoinbaseValue {
@@ -1819,6 +3328,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		}
 
 		reply.CoinbaseTxn = &resultTx
+		reply.Mutable = gbtCoinbaseTxnMutableFields
 	}
 
 	return &reply, nil
@@ -1834,7 +3344,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 // has passed without finding a solution.
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 for more details.
-func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeChan <-chan struct{}) (interface{}, error) {
+func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, clientRules []string, deployments *gbtDeployments, closeChan <-chan struct{}) (interface{}, error) {
 	state := s.gbtWorkState
 	state.Lock()
 	// The state unlock is intentionally not deferred here since it needs to
@@ -1850,7 +3360,8 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// the caller is invalid.
 	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
 	if err != nil {
-		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
+		result, err := state.blockTemplateResult(useCoinbaseValue,
+			deployments, nil)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1872,7 +3383,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 		// already been found and added to the block chain.
 		submitOld := prevHash.IsEqual(prevTemplateHash)
 		result, err := state.blockTemplateResult(useCoinbaseValue,
-			&submitOld)
+			deployments, &submitOld)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1908,11 +3419,20 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 		return nil, err
 	}
 
+	// The deployments may have changed state while waiting when the
+	// template is for a new block.
+	deployments, err = newGBTDeployments(s.cfg.ChainParams, clientRules,
+		s.cfg.Chain.ThresholdState)
+	if err != nil {
+		return nil, err
+	}
+
 	// Include whether or not it is valid to submit work against the old
 	// block template depending on whether or not a solution has already
 	// been found and added to the block chain.
 	submitOld := prevHash.IsEqual(&state.template.Block.Header.PrevBlock)
-	result, err := state.blockTemplateResult(useCoinbaseValue, &submitOld)
+	result, err := state.blockTemplateResult(useCoinbaseValue, deployments,
+		&submitOld)
 	if err != nil {
 		return nil, err
 	}
@@ -1926,13 +3446,15 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 // requests.  In addition, it detects the capabilities reported by the caller
 // in regards to whether or not it supports creating its own coinbase (the
 // coinbasetxn and coinbasevalue capabilities) and modifies the returned block
-// template accordingly.
+// template accordingly, as well as the deployment rules it supports.
 func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateRequest, closeChan <-chan struct{}) (interface{}, error) {
 	// Extract the relevant passed capabilities and restrict the result to
 	// either a coinbase value or a coinbase transaction object depending on
 	// the request.  Default to only providing a coinbase value.
 	useCoinbaseValue := true
+	var clientRules []string
 	if request != nil {
+		clientRules = request.Rules
 		var hasCoinbaseValue, hasCoinbaseTxn bool
 		for _, capability := range request.Capabilities {
 			switch capability {
@@ -1981,12 +3503,20 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 		}
 	}
 
+	// Ensure the client supports the rules of the deployments which affect
+	// the structure of the block before handing out any work.
+	deployments, err := newGBTDeployments(s.cfg.ChainParams, clientRules,
+		s.cfg.Chain.ThresholdState)
+	if err != nil {
+		return nil, err
+	}
+
 	// When a long poll ID was provided, this is a long poll request by the
 	// client to be notified when block template referenced by the ID should
 	// be replaced with a new one.
 	if request != nil && request.LongPollID != "" {
 		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
-			useCoinbaseValue, closeChan)
+			useCoinbaseValue, clientRules, deployments, closeChan)
 	}
 
 	// Protect concurrent access when updating block templates.
@@ -2003,7 +3533,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
 		return nil, err
 	}
-	return state.blockTemplateResult(useCoinbaseValue, nil)
+	return state.blockTemplateResult(useCoinbaseValue, deployments, nil)
 }
 
 // chainErrToGBTErrString converts an error returned from btcchain to a string
@@ -2103,7 +3633,7 @@ func chainErrToGBTErrString(err error) string {
 	case blockchain.ErrInvalidAncestorBlock:
 		return "bad-prevblk"
 	case blockchain.ErrPrevBlockNotBest:
-		return "inconclusive-not-best-prvblk"
+		return "inconclusive-not-best-prevblk"
 	}
 
 	return "rejected: " + err.Error()
@@ -2145,14 +3675,35 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 	}
 	block := btcutil.NewBlock(&msgBlock)
 
-	// Ensure the block is building from the expected previous block.
-	expectedPrevHash := s.cfg.Chain.BestSnapshot().Hash
+	// Blocks which are already known don't need to be validated again.
+	// Only the blocks of the main chain are known to be fully valid, the
+	// validity of blocks on side chains and orphans is inconclusive.
+	chain := s.cfg.Chain
+	blockHash := block.Hash()
+	if chain.MainChainHasBlock(blockHash) {
+		return "duplicate", nil
+	}
+	if chain.IsKnownInvalid(blockHash) {
+		return "duplicate-invalid", nil
+	}
+	exists, err := chain.HaveBlock(blockHash)
+	if err != nil {
+		context := "Failed to check block existence"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if exists {
+		return "duplicate-inconclusive", nil
+	}
+
+	// Ensure the block is building from the expected previous block.  A
+	// block building on anything else can't be fully checked.
+	expectedPrevHash := chain.BestSnapshot().Hash
 	prevHash := &block.MsgBlock().Header.PrevBlock
 	if !expectedPrevHash.IsEqual(prevHash) {
-		return "bad-prevblk", nil
+		return "inconclusive-not-best-prevblk", nil
 	}
 
-	if err := s.cfg.Chain.CheckConnectBlockTemplate(block); err != nil {
+	if err := chain.CheckConnectBlockTemplate(block); err != nil {
 		if _, ok := err.(blockchain.RuleError); !ok {
 			errStr := fmt.Sprintf("Failed to process block proposal: %v", err)
 			rpcsLog.Error(errStr)
@@ -2218,6 +3769,191 @@ func handleGetChainTips(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return ret, nil
 }
 
+// chainTxStatsBlockCount returns the number of blocks in the window of the
+// getchaintxstats command ending at the block with the passed height.  The
+// window defaults to about one month of blocks, shortened to exclude the genesis
+// block when the chain isn't long enough.
+func chainTxStatsBlockCount(nBlocks *int32, height int32, params *chaincfg.Params) (int32, error) {
+	if nBlocks == nil {
+		month := int32(30 * 24 * time.Hour / params.TargetTimePerBlock)
+		if month > height-1 {
+			month = height - 1
+		}
+		if month < 0 {
+			month = 0
+		}
+		return month, nil
+	}
+
+	if *nBlocks < 0 || (*nBlocks > 0 && *nBlocks >= height) {
+		return 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid block count: should be between 0 " +
+				"and the block's height - 1",
+		}
+	}
+	return *nBlocks, nil
+}
+
+// handleGetChainTxStats implements the getchaintxstats command.
+func handleGetChainTxStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.TxCountIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "The transaction count index is not available " +
+				"since the node was pruned before it was created",
+		}
+	}
+
+	c := cmd.(*btcjson.GetChainTxStatsCmd)
+	best := s.cfg.Chain.BestSnapshot()
+	hash, height := &best.Hash, best.Height
+	if c.BlockHash != nil {
+		var err error
+		hash, err = chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+		height, err = s.cfg.Chain.BlockHeightByHash(hash)
+		if err != nil {
+			if _, err := s.cfg.Chain.HeaderByHash(hash); err == nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParameter,
+					Message: "Block is not in main chain",
+				}
+			}
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+	}
+
+	blockCount, err := chainTxStatsBlockCount(c.NBlocks, height,
+		s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := s.cfg.Chain.HeaderByHash(hash)
+	if err != nil {
+		context := "Failed to fetch block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	txCount, err := s.cfg.TxCountIndex.TxCount(hash)
+	if err != nil {
+		context := "Failed to fetch transaction count"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	result := &btcjson.GetChainTxStatsResult{
+		Time:                   header.Timestamp.Unix(),
+		TxCount:                int64(txCount),
+		WindowFinalBlockHash:   hash.String(),
+		WindowFinalBlockHeight: height,
+		WindowBlockCount:       blockCount,
+	}
+	if blockCount == 0 {
+		return result, nil
+	}
+
+	// The interval of the window is measured between the median times of
+	// its final block and the block preceding the window like Bitcoin Core
+	// does.
+	pastHash, err := s.cfg.Chain.BlockHashByHeight(height - blockCount)
+	if err != nil {
+		context := "Failed to fetch block hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	pastHeader, err := s.cfg.Chain.HeaderByHash(pastHash)
+	if err != nil {
+		context := "Failed to fetch block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	pastTxCount, err := s.cfg.TxCountIndex.TxCount(pastHash)
+	if err != nil {
+		context := "Failed to fetch transaction count"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	medianTime, err := s.cfg.Chain.PastMedianTime(&header)
+	if err != nil {
+		context := "Failed to calculate median time"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	pastMedianTime, err := s.cfg.Chain.PastMedianTime(&pastHeader)
+	if err != nil {
+		context := "Failed to calculate median time"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	interval := int32(medianTime.Unix() - pastMedianTime.Unix())
+	result.WindowTxCount = int32(txCount - pastTxCount)
+	result.WindowInterval = interval
+	if interval > 0 {
+		result.TxRate = float64(result.WindowTxCount) / float64(interval)
+	}
+	return result, nil
+}
+
+// handleGetBlockFilter implements the getblockfilter command.
+func handleGetBlockFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.CfIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoCFIndex,
+			Message: "The CF index must be enabled for this command",
+		}
+	}
+
+	c := cmd.(*btcjson.GetBlockFilterCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	// The basic filter defined in BIP0158 is the only supported type.
+	if c.FilterType != nil && *c.FilterType != btcjson.FilterTypeBasic {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Unknown filtertype",
+		}
+	}
+
+	filterBytes, err := s.cfg.CfIndex.FilterByBlockHash(hash,
+		wire.GCSFilterRegular)
+	if err == nil && len(filterBytes) == 0 {
+		err = fmt.Errorf("no committed filter")
+	}
+	if err != nil {
+		rpcsLog.Debugf("Could not find committed filter for %v: %v",
+			hash, err)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+	headerBytes, err := s.cfg.CfIndex.FilterHeaderByBlockHash(hash,
+		wire.GCSFilterRegular)
+	if err == nil && len(headerBytes) == 0 {
+		err = fmt.Errorf("no committed filter header")
+	}
+	if err != nil {
+		rpcsLog.Debugf("Could not find header of committed filter for "+
+			"%v: %v", hash, err)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	// The filter header is returned in the same byte order as block
+	// hashes.
+	var header chainhash.Hash
+	copy(header[:], headerBytes)
+	return &btcjson.GetBlockFilterResult{
+		Filter: hex.EncodeToString(filterBytes),
+		Header: header.String(),
+	}, nil
+}
+
 // handleGetCFilter implements the getcfilter command.
 func handleGetCFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	if s.cfg.CfIndex == nil {
@@ -2278,6 +4014,65 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hash.String(), nil
 }
 
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	bans, err := s.cfg.ConnMgr.BanList()
+	if err != nil {
+		return nil, internalRPCError(err.Error(),
+			"Unable to list the bans")
+	}
+
+	now := time.Now()
+	result := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		result = append(result, btcjson.ListBannedResult{
+			Address:       ban.Subnet.String(),
+			BanCreated:    ban.Created.Unix(),
+			BannedUntil:   ban.Until.Unix(),
+			BanDuration:   ban.Until.Unix() - ban.Created.Unix(),
+			TimeRemaining: ban.Until.Unix() - now.Unix(),
+		})
+	}
+	return result, nil
+}
+
+// handleMatchBlockFilter implements the matchblockfilter command.
+func handleMatchBlockFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.CfIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoCFIndex,
+			Message: "The CF index must be enabled for this command",
+		}
+	}
+
+	c := cmd.(*btcjson.MatchBlockFilterCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	scripts := make([][]byte, 0, len(c.Scripts))
+	for _, scriptHex := range c.Scripts {
+		script, err := hex.DecodeString(scriptHex)
+		if err != nil {
+			return nil, rpcDecodeHexError(scriptHex)
+		}
+		scripts = append(scripts, script)
+	}
+
+	match, err := s.cfg.CfIndex.FilterMatch(hash, scripts)
+	if err != nil {
+		rpcsLog.Debugf("Could not match committed filter for %v: %v",
+			hash, err)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	return match, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
@@ -2288,6 +4083,36 @@ func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return s.cfg.ChainParams.Net, nil
 }
 
+// handleGetDescriptorInfo implements the getdescriptorinfo command.
+func handleGetDescriptorInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetDescriptorInfoCmd)
+
+	desc, err := parseDescriptor(c.Descriptor, false, s.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum is the one of the passed descriptor, which differs
+	// from the one of the normalized descriptor when it has private keys
+	// or isn't in the canonical form.
+	body := c.Descriptor
+	if i := strings.IndexByte(body, '#'); i != -1 {
+		body = body[:i]
+	}
+	checksum, err := descriptor.Checksum(body)
+	if err != nil {
+		return nil, invalidDescriptorError(err)
+	}
+
+	return &btcjson.GetDescriptorInfoResult{
+		Descriptor:     desc.String(),
+		Checksum:       checksum,
+		IsRange:        desc.IsRange(),
+		IsSolvable:     desc.IsSolvable(),
+		HasPrivateKeys: desc.HasPrivateKeys(),
+	}, nil
+}
+
 // handleGetDifficulty implements the getdifficulty command.
 func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
@@ -2345,6 +4170,54 @@ func handleGetHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return hexBlockHeaders, nil
 }
 
+// indexName returns the name of the passed index as reported by the
+// getindexinfo command.
+func indexName(indexer indexers.Indexer) string {
+	switch indexer.(type) {
+	case *indexers.TxIndex:
+		return "txindex"
+	case *indexers.AddrIndex:
+		return "addrindex"
+	case *indexers.CfIndex:
+		return "cfindex"
+	case *indexers.TxCountIndex:
+		return "txcountindex"
+	case *indexers.SpentIndex:
+		return "spentindex"
+	case *indexers.TimestampIndex:
+		return "timestampindex"
+	case *indexers.BalanceIndex:
+		return "balanceindex"
+	}
+	return indexer.Name()
+}
+
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetIndexInfoCmd)
+
+	result := make(map[string]btcjson.GetIndexInfoResult)
+	if s.cfg.IndexManager == nil {
+		return result, nil
+	}
+	statuses, err := s.cfg.IndexManager.Status()
+	if err != nil {
+		context := "Failed to fetch index status"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for _, status := range statuses {
+		name := indexName(status.Indexer)
+		if c.IndexName != nil && *c.IndexName != name {
+			continue
+		}
+		result[name] = btcjson.GetIndexInfoResult{
+			Synced:          status.Synced,
+			BestBlockHeight: status.BestHeight,
+		}
+	}
+	return result, nil
+}
+
 // handleGetInfo implements the getinfo command. We only return the fields
 // that are not related to wallet functionality.
 func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -2358,10 +4231,152 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 		Proxy:           cfg.Proxy,
 		Difficulty:      getDifficultyRatio(best.Bits, s.cfg.ChainParams),
 		TestNet:         cfg.TestNet3,
-		RelayFee:        cfg.minRelayTxFee.ToBTC(),
+		RelayFee:        liveCfg().minRelayTxFee.ToBTC(),
+	}
+
+	return ret, nil
+}
+
+// mempoolRelatives returns the passed mempool entries as an array of their
+// sorted transaction hashes unless the verbose flag is set, in which case the
+// entries are returned keyed by transaction hash.
+func mempoolRelatives(entries map[string]*btcjson.GetMempoolEntryResult,
+	verbose *bool) interface{} {
+
+	if verbose != nil && *verbose {
+		return entries
+	}
+
+	hashStrings := make([]string, 0, len(entries))
+	for hash := range entries {
+		hashStrings = append(hashStrings, hash)
+	}
+	sort.Strings(hashStrings)
+	return hashStrings
+}
+
+// handleGetMempoolAncestors implements the getmempoolancestors command.
+func handleGetMempoolAncestors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolAncestorsCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entries, err := s.cfg.TxMemPool.MempoolAncestors(txHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: "Transaction not in mempool",
+		}
+	}
+
+	return mempoolRelatives(entries, c.Verbose), nil
+}
+
+// handleGetMempoolDescendants implements the getmempooldescendants command.
+func handleGetMempoolDescendants(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolDescendantsCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entries, err := s.cfg.TxMemPool.MempoolDescendants(txHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: "Transaction not in mempool",
+		}
+	}
+
+	return mempoolRelatives(entries, c.Verbose), nil
+}
+
+// handleGetMemoryInfo implements the getmemoryinfo command.
+func handleGetMemoryInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMemoryInfoCmd)
+
+	switch *c.Mode {
+	case "stats":
+	case "mallocinfo":
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "mallocinfo mode not available",
+		}
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("unknown mode %s", *c.Mode),
+		}
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	result := &btcjson.GetMemoryInfoResult{
+		Runtime: btcjson.RuntimeMemoryInfo{
+			Sys:           memStats.Sys,
+			HeapAlloc:     memStats.HeapAlloc,
+			HeapInuse:     memStats.HeapInuse,
+			HeapIdle:      memStats.HeapIdle,
+			HeapReleased:  memStats.HeapReleased,
+			HeapObjects:   memStats.HeapObjects,
+			StackInuse:    memStats.StackInuse,
+			TotalAlloc:    memStats.TotalAlloc,
+			Goroutines:    runtime.NumGoroutine(),
+			NumGC:         memStats.NumGC,
+			GCPauseTotal:  time.Duration(memStats.PauseTotalNs).Seconds(),
+			GCCPUFraction: memStats.GCCPUFraction,
+		},
+		Mempool: btcjson.CacheMemoryInfo{
+			Entries: int64(s.cfg.TxMemPool.Count()),
+			Used:    uint64(s.cfg.TxMemPool.MemoryUsage()),
+			Max:     uint64(cfg.MaxMempool) * 1000000,
+		},
+	}
+	if memStats.LastGC != 0 {
+		result.Runtime.LastGC = time.Unix(0, int64(memStats.LastGC)).Unix()
+	}
+
+	utxo := s.cfg.Chain.UtxoCacheStatus()
+	result.UtxoCache = btcjson.CacheMemoryInfo{
+		Entries: int64(utxo.Entries),
+		Used:    utxo.MemoryUsage,
+		Max:     utxo.MaxMemoryUsage,
+	}
+
+	// The cache of the database is only reported for the ffldb backend.
+	if status, err := ffldb.DBStatus(s.cfg.DB); err == nil {
+		result.DBCache = btcjson.CacheMemoryInfo{
+			Entries: int64(status.CachedKeys + status.CachedRemoves),
+			Used:    status.CacheSize,
+			Max:     status.MaxCacheSize,
+		}
+	}
+
+	return result, nil
+}
+
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entry, err := s.cfg.TxMemPool.MempoolEntry(txHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: "Transaction not in mempool",
+		}
 	}
 
-	return ret, nil
+	return entry, nil
 }
 
 // handleGetMempoolInfo implements the getmempoolinfo command.
@@ -2373,9 +4388,20 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
 	}
 
+	minRelayTxFee := liveCfg().minRelayTxFee
+	minFeeRate := s.cfg.TxMemPool.MinFeeRate()
+	if minFeeRate < minRelayTxFee {
+		minFeeRate = minRelayTxFee
+	}
+
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:          int64(len(mempoolTxns)),
+		Bytes:         numBytes,
+		Usage:         s.cfg.TxMemPool.MemoryUsage(),
+		MaxMempool:    cfg.MaxMempool * 1000000,
+		MempoolMinFee: minFeeRate.ToBTC(),
+		MinRelayTxFee: minRelayTxFee.ToBTC(),
+		Unbroadcast:   int64(s.cfg.Rebroadcaster.Count()),
 	}
 
 	return ret, nil
@@ -2420,10 +4446,19 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 // handleGetNetTotals implements the getnettotals command.
 func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	totalBytesRecv, totalBytesSent := s.cfg.ConnMgr.NetTotals()
+	target := s.cfg.ConnMgr.UploadTarget()
 	reply := &btcjson.GetNetTotalsResult{
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget: &btcjson.GetNetTotalsUploadTarget{
+			Timeframe:             int64(target.Timeframe / time.Second),
+			Target:                target.Target,
+			TargetReached:         target.TargetReached,
+			ServeHistoricalBlocks: target.ServeHistoricalBlocks,
+			BytesLeftInCycle:      target.BytesLeft,
+			TimeLeftInCycle:       int64(target.TimeLeft / time.Second),
+		},
 	}
 	return reply, nil
 }
@@ -2522,6 +4557,102 @@ func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hashesPerSec, nil
 }
 
+// networksInfo returns the reachability of the networks and the proxies used
+// to reach them for the getnetworkinfo command.
+func networksInfo() []btcjson.NetworksResult {
+	onionProxy := cfg.OnionProxy
+	if onionProxy == "" {
+		onionProxy = cfg.Proxy
+	}
+	onionReachable := !cfg.NoOnion && onionProxy != ""
+
+	// I2P addresses are relayed but never connected to.
+	networks := []struct {
+		name      string
+		reachable bool
+		proxy     string
+	}{
+		{"ipv4", true, cfg.Proxy},
+		{"ipv6", true, cfg.Proxy},
+		{"onion", onionReachable, onionProxy},
+		{"i2p", false, ""},
+		{"cjdns", cfg.CJDNSReachable, ""},
+	}
+
+	results := make([]btcjson.NetworksResult, 0, len(networks))
+	for _, network := range networks {
+		proxy := network.proxy
+		if !network.reachable {
+			proxy = ""
+		}
+		results = append(results, btcjson.NetworksResult{
+			Name:                      network.name,
+			Limited:                   !network.reachable,
+			Reachable:                 network.reachable,
+			Proxy:                     proxy,
+			ProxyRandomizeCredentials: proxy != "" && cfg.TorIsolation,
+		})
+	}
+	return results
+}
+
+// handleGetNetworkInfo implements the getnetworkinfo command.
+func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// The user agent is built the same way as the one sent to peers.
+	msg := &wire.MsgVersion{UserAgent: wire.DefaultUserAgent}
+	err := msg.AddUserAgent(userAgentName, userAgentVersion,
+		cfg.UserAgentComments...)
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Invalid user agent")
+	}
+
+	var inbound, outbound int32
+	for _, p := range s.cfg.ConnMgr.ConnectedPeers() {
+		if p.ToPeer().Inbound() {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+
+	localAddrs := s.cfg.ConnMgr.LocalAddresses()
+	addrResults := make([]btcjson.LocalAddressesResult, 0, len(localAddrs))
+	for _, la := range localAddrs {
+		addrResults = append(addrResults, btcjson.LocalAddressesResult{
+			Address: la.NA.Addr.String(),
+			Port:    la.NA.Port,
+			Score:   int32(la.Score),
+		})
+	}
+
+	reply := &btcjson.GetNetworkInfoResult{
+		Version:         int32(1000000*appMajor + 10000*appMinor + 100*appPatch),
+		SubVersion:      msg.UserAgent,
+		ProtocolVersion: int32(maxProtocolVersion),
+		LocalServices:   fmt.Sprintf("%016x", uint64(s.cfg.Services)),
+		LocalRelay:      !cfg.BlocksOnly,
+		TimeOffset:      int64(s.cfg.TimeSource.Offset().Seconds()),
+		Connections:     inbound + outbound,
+		ConnectionsIn:   inbound,
+		ConnectionsOut:  outbound,
+		NetworkActive:   true,
+		Networks:        networksInfo(),
+		RelayFee:        liveCfg().minRelayTxFee.ToBTC(),
+		IncrementalFee:  liveCfg().minRelayTxFee.ToBTC(),
+		LocalAddresses:  addrResults,
+	}
+	if m := s.cfg.ConnMgr.PortMapping(); m != nil {
+		reply.PortMapping = &btcjson.PortMappingResult{
+			Protocol:        m.protocol,
+			ExternalAddress: m.externalIP.String(),
+			ExternalPort:    m.externalPort,
+			InternalPort:    m.internalPort,
+			Expires:         m.expires.Unix(),
+		}
+	}
+	return reply, nil
+}
+
 // handleGetNodeAddresses implements the getnodeaddresses command.
 func handleGetNodeAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetNodeAddressesCmd)
@@ -2537,18 +4668,34 @@ func handleGetNodeAddresses(s *rpcServer, cmd interface{}, closeChan <-chan stru
 		}
 	}
 
-	nodes := s.cfg.ConnMgr.NodeAddresses()
-	if n := int32(len(nodes)); n < count {
-		count = n
+	if c.Network != nil {
+		switch *c.Network {
+		case "ipv4", "ipv6", "onion", "i2p", "cjdns":
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Network not recognized: " + *c.Network,
+			}
+		}
 	}
 
+	nodes := s.cfg.ConnMgr.NodeAddresses()
 	addresses := make([]*btcjson.GetNodeAddressesResult, 0, count)
-	for _, node := range nodes[:count] {
+	for _, node := range nodes {
+		if int32(len(addresses)) >= count {
+			break
+		}
+
+		network := addrmgr.NetworkName(node)
+		if c.Network != nil && *c.Network != network {
+			continue
+		}
 		address := &btcjson.GetNodeAddressesResult{
 			Time:     node.Timestamp.Unix(),
 			Services: uint64(node.Services),
 			Address:  node.Addr.String(),
 			Port:     node.Port,
+			Network:  network,
 		}
 		addresses = append(addresses, address)
 	}
@@ -2560,6 +4707,7 @@ func handleGetNodeAddresses(s *rpcServer, cmd interface{}, closeChan <-chan stru
 func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	peers := s.cfg.ConnMgr.ConnectedPeers()
 	syncPeerID := s.cfg.SyncMgr.SyncPeerID()
+	downloadStats := s.cfg.SyncMgr.PeerDownloadStats()
 	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
 	for _, p := range peers {
 		statsSnap := p.ToPeer().StatsSnapshot()
@@ -2584,17 +4732,123 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+
+			TransportProtocolType: "v1",
+			SessionID:             hex.EncodeToString(statsSnap.V2SessionID),
+			ConnectionType:        p.ConnectionType(),
+		}
+		if statsSnap.V2Transport {
+			info.TransportProtocolType = "v2"
+		}
+		if statsSnap.Compression != 0 {
+			info.Compression = statsSnap.Compression.String()
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
 			// We actually want microseconds.
 			info.PingWait = wait / 1000
 		}
+		if statsSnap.MinPingMicros != 0 {
+			info.MinPing = float64(statsSnap.MinPingMicros)
+		}
+		info.AddrRelayEnabled, info.AddrProcessed, info.AddrRateLimited =
+			p.AddrRelayStats()
+		info.AddrSent = statsSnap.AddrsSent
+		info.AddrRecv = statsSnap.AddrsRecv
+		info.BytesSentPerMsg = make(map[string]uint64)
+		info.BytesRecvPerMsg = make(map[string]uint64)
+		info.MsgsSentPerMsg = make(map[string]uint64)
+		info.MsgsRecvPerMsg = make(map[string]uint64)
+		for command, stats := range statsSnap.MsgStats {
+			if stats.MsgsSent != 0 {
+				info.BytesSentPerMsg[command] = stats.BytesSent
+				info.MsgsSentPerMsg[command] = stats.MsgsSent
+			}
+			if stats.MsgsRecv != 0 {
+				info.BytesRecvPerMsg[command] = stats.BytesRecv
+				info.MsgsRecvPerMsg[command] = stats.MsgsRecv
+			}
+		}
+		if stats, ok := downloadStats[statsSnap.ID]; ok {
+			info.BlockDownload = &btcjson.PeerBlockDownloadInfo{
+				InFlight:    stats.BlocksInFlight,
+				MaxInFlight: stats.MaxBlocksInFlight,
+				Blocks:      stats.BlocksDownloaded,
+				Bytes:       stats.BytesDownloaded,
+				Stalls:      stats.Stalls,
+				Latency: float64(stats.Latency) /
+					float64(time.Millisecond),
+				Throughput: stats.Throughput,
+				Score:      stats.Score,
+			}
+		}
 		infos = append(infos, info)
 	}
 	return infos, nil
 }
 
+// handleGetOrphanInfo implements the getorphaninfo command.
+func handleGetOrphanInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetOrphanInfoCmd)
+	return s.cfg.TxMemPool.OrphanInfo(c.Verbose != nil && *c.Verbose), nil
+}
+
+// handleGetPeerReputation implements the getpeerreputation command.
+func handleGetPeerReputation(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetPeerReputationCmd)
+
+	var host string
+	if c.Addr != nil {
+		host = reputation.HostKey(*c.Addr)
+	}
+	results := make([]btcjson.GetPeerReputationResult, 0)
+	for key, stats := range s.cfg.PeerReputation.AllStats() {
+		if host != "" && key != host {
+			continue
+		}
+		results = append(results, btcjson.GetPeerReputationResult{
+			Host:        key,
+			Score:       stats.Score(),
+			Weight:      stats.Weight(),
+			Blocks:      stats.Blocks,
+			Txns:        stats.Txns,
+			Stalls:      stats.Stalls,
+			Misbehavior: stats.Misbehavior,
+			Bans:        stats.Bans,
+			Connections: stats.Connections,
+			LastSeen:    stats.LastSeen.Unix(),
+		})
+	}
+
+	// Sort the hosts by their score starting with the best one.
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Host < results[j].Host
+	})
+	return results, nil
+}
+
+// handleGetPendingReorg implements the getpendingreorg command.
+func handleGetPendingReorg(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	pending := s.cfg.Chain.PendingReorg()
+	if pending == nil {
+		return nil, nil
+	}
+
+	return &btcjson.GetPendingReorgResult{
+		ForkHash:     pending.ForkHash.String(),
+		ForkHeight:   pending.ForkHeight,
+		OldTipHash:   pending.OldTipHash.String(),
+		OldTipHeight: pending.OldTipHeight,
+		NewTipHash:   pending.NewTipHash.String(),
+		NewTipHeight: pending.NewTipHeight,
+		Depth:        pending.Depth,
+		Detected:     pending.Detected.Unix(),
+	}, nil
+}
+
 // handleGetRawMempool implements the getrawmempool command.
 func handleGetRawMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetRawMempoolCmd)
@@ -2734,6 +4988,55 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// handleGetRPCInfo implements the getrpcinfo command.
+func handleGetRPCInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return &btcjson.GetRPCInfoResult{
+		ActiveCommands: s.stats.activeCommands(),
+		LogPath:        filepath.Join(cfg.LogDir, defaultLogFilename),
+	}, nil
+}
+
+// handleGetRPCStats implements the getrpcstats command.
+func handleGetRPCStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.stats.snapshot(), nil
+}
+
+// handleGetSpentInfo implements the getspentinfo command.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.SpentIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spent index must be enabled (--spentindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetSpentInfoCmd)
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	outpoint := wire.NewOutPoint(txHash, c.Index)
+	info, err := s.cfg.SpentIndex.SpentInfo(outpoint)
+	if err != nil {
+		context := "Failed to retrieve spent info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if info == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: fmt.Sprintf("No spent info for output %v",
+				outpoint),
+		}
+	}
+
+	return &btcjson.GetSpentInfoResult{
+		Txid:   info.TxHash.String(),
+		Index:  info.InputIndex,
+		Height: info.Height,
+	}, nil
+}
+
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
@@ -2764,90 +5067,453 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 			return nil, rpcNoTxInfoError(txHash)
 		}
 
-		mtx := tx.MsgTx()
-		if c.Vout > uint32(len(mtx.TxOut)-1) {
-			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCInvalidTxVout,
-				Message: "Output index number (vout) does not " +
-					"exist for transaction.",
-			}
+		mtx := tx.MsgTx()
+		if c.Vout > uint32(len(mtx.TxOut)-1) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidTxVout,
+				Message: "Output index number (vout) does not " +
+					"exist for transaction.",
+			}
+		}
+
+		txOut := mtx.TxOut[c.Vout]
+		if txOut == nil {
+			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
+				"does not exist", c.Vout, txHash)
+			return nil, internalRPCError(errStr, "")
+		}
+
+		best := s.cfg.Chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 0
+		value = txOut.Value
+		pkScript = txOut.PkScript
+		isCoinbase = blockchain.IsCoinBaseTx(mtx)
+	} else {
+		out := wire.OutPoint{Hash: *txHash, Index: c.Vout}
+		entry, err := s.cfg.Chain.FetchUtxoEntry(out)
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		// To match the behavior of the reference client, return nil
+		// (JSON null) if the transaction output is spent by another
+		// transaction already in the main chain.  Mined transactions
+		// that are spent by a mempool transaction are not affected by
+		// this.
+		if entry == nil || entry.IsSpent() {
+			return nil, nil
+		}
+
+		best := s.cfg.Chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 1 + best.Height - entry.BlockHeight()
+		value = entry.Amount()
+		pkScript = entry.PkScript()
+		isCoinbase = entry.IsCoinBase()
+	}
+
+	// Disassemble script into single line printable format.
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Get further info about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
+		s.cfg.ChainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	// Address is defined when there's a single well-defined
+	// receiver address. To spend the output a signature for this,
+	// and only this, address is required.
+	if len(addresses) == 1 && reqSigs <= 1 {
+		address = addresses[0]
+	}
+
+	txOutReply := &btcjson.GetTxOutResult{
+		BestBlock:     bestBlockHash,
+		Confirmations: int64(confirmations),
+		Value:         btcutil.Amount(value).ToBTC(),
+		ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Asm:       disbuf,
+			Hex:       hex.EncodeToString(pkScript),
+			ReqSigs:   int32(reqSigs),
+			Type:      scriptClass.String(),
+			Address:   address,
+			Addresses: addresses,
+		},
+		Coinbase: isCoinbase,
+	}
+
+	return txOutReply, nil
+}
+
+// handleGetZmqNotifications implements the getzmqnotifications command.
+func handleGetZmqNotifications(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	notifications := s.cfg.ZMQNotifier.Notifications()
+	result := make(btcjson.GetZmqNotificationResult, 0, len(notifications))
+	for _, n := range notifications {
+		address, err := url.Parse(n.Endpoint)
+		if err != nil {
+			return nil, internalRPCError(err.Error(),
+				"Failed to parse ZeroMQ endpoint")
+		}
+		result = append(result, struct {
+			Type          string
+			Address       *url.URL
+			HighWaterMark int
+		}{
+			Type:          "pub" + n.Topic,
+			Address:       address,
+			HighWaterMark: n.HighWaterMark,
+		})
+	}
+
+	// The result is returned as a pointer since it is marshalled by a
+	// method with a pointer receiver.
+	return &result, nil
+}
+
+// handleGetTxOutSetInfo handles gettxoutsetinfo commands.
+func handleGetTxOutSetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutSetInfoCmd)
+
+	// The statistics of the utxo set are maintained by the chain, so only
+	// hash types that don't require scanning the utxo set are supported.
+	hashType := "muhash"
+	if c.HashType != nil {
+		hashType = *c.HashType
+	}
+	switch hashType {
+	case "muhash", "none":
+	default:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unsupported hash type %q, must be "+
+				"muhash or none", hashType),
+		}
+	}
+
+	stats := s.cfg.Chain.UtxoSetStats()
+	result := &btcjson.GetTxOutSetInfoResult{
+		Height:      int64(stats.Height),
+		BestBlock:   stats.Hash,
+		TxOuts:      int64(stats.TxOuts),
+		BogoSize:    int64(stats.BogoSize),
+		TotalAmount: btcutil.Amount(stats.TotalAmount),
+	}
+	if hashType == "muhash" {
+		result.MuHash = &stats.MuHash
+	}
+	return result, nil
+}
+
+// handleGetUnbroadcast implements the getunbroadcast command.
+func handleGetUnbroadcast(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	txns := s.cfg.Rebroadcaster.Transactions()
+	result := make([]btcjson.GetUnbroadcastResult, 0, len(txns))
+	for _, info := range txns {
+		var lastAnnounced int64
+		if !info.LastAnnounced.IsZero() {
+			lastAnnounced = info.LastAnnounced.Unix()
+		}
+		result = append(result, btcjson.GetUnbroadcastResult{
+			TxID:          info.Tx.Hash().String(),
+			WTxID:         info.Tx.WitnessHash().String(),
+			Time:          info.Added.Unix(),
+			LastAnnounced: lastAnnounced,
+			Peers:         info.Peers,
+			Announcements: info.Announcements,
+			Requests:      info.Requests,
+		})
+	}
+	return result, nil
+}
+
+// utxoScan tracks the scan of the utxo set run by scantxoutset, of which only
+// one can run at a time.
+type utxoScan struct {
+	mtx     sync.Mutex
+	running bool
+	abort   chan struct{}
+	scanned uint64
+	total   uint64
+}
+
+// start marks a scan of the utxo set with the passed number of outputs as
+// running and returns the channel which is closed to abort it.  It returns
+// false when a scan is already running.
+func (u *utxoScan) start(total uint64) (chan struct{}, bool) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	if u.running {
+		return nil, false
+	}
+	u.running = true
+	u.abort = make(chan struct{})
+	u.scanned = 0
+	u.total = total
+	return u.abort, true
+}
+
+// setScanned updates the number of outputs scanned by the running scan.
+func (u *utxoScan) setScanned(scanned uint64) {
+	u.mtx.Lock()
+	u.scanned = scanned
+	u.mtx.Unlock()
+}
+
+// finish marks the running scan as finished.
+func (u *utxoScan) finish() {
+	u.mtx.Lock()
+	u.running = false
+	u.mtx.Unlock()
+}
+
+// stop aborts the running scan and returns whether there was one.
+func (u *utxoScan) stop() bool {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	if !u.running {
+		return false
+	}
+	select {
+	case <-u.abort:
+	default:
+		close(u.abort)
+	}
+	return true
+}
+
+// progress returns the percentage of the outputs scanned by the running scan
+// and whether there is one.  The number of outputs is the one at the start of
+// the scan, so the progress is an estimate.
+func (u *utxoScan) progress() (float64, bool) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	if !u.running {
+		return 0, false
+	}
+	if u.total == 0 || u.scanned >= u.total {
+		return 100, true
+	}
+	return float64(u.scanned) * 100 / float64(u.total), true
+}
+
+// parseScanObjectRange parses the descriptor of the passed scan object and
+// returns it along with the first and last derivation index to expand it over.
+// Ranged descriptors are expanded over the range of the scan object, or over
+// the default range when it doesn't have one.
+func parseScanObjectRange(obj *btcjson.ScanObject, params *chaincfg.Params) (*descriptor.Descriptor, uint32, uint32, error) {
+	desc, err := parseDescriptor(obj.Desc, false, params)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	begin, end, err := descriptorRange(desc, obj.Range)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return desc, begin, end, nil
+}
+
+// descriptorRange returns the first and last derivation index of the passed
+// descriptor given the passed range, which defaults to the first
+// defaultScanRangeEnd indexes for ranged descriptors and must not be given
+// for un-ranged ones.
+func descriptorRange(desc *descriptor.Descriptor, r *btcjson.DescriptorRange) (uint32, uint32, error) {
+	switch {
+	case desc.IsRange() && r == nil:
+		return 0, defaultScanRangeEnd, nil
+
+	case desc.IsRange():
+		return parseDescriptorRange(r)
+
+	case r != nil:
+		return 0, 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Range should not be specified for an un-ranged " +
+				"descriptor",
+		}
+	}
+	return 0, 0, nil
+}
+
+// parseScanObject returns the public key scripts described by the passed scan
+// object mapped to the descriptors of the individual scripts.  Ranged
+// descriptors are expanded over the range of the scan object, or over the
+// default range when it doesn't have one.
+func parseScanObject(obj *btcjson.ScanObject, params *chaincfg.Params) (map[string]string, error) {
+	desc, begin, end, err := parseScanObjectRange(obj, params)
+	if err != nil {
+		return nil, err
+	}
+
+	scripts := make(map[string]string, end-begin+1)
+	for i := begin; ; i++ {
+		pkScript, err := desc.Script(i)
+		if err != nil {
+			return nil, invalidDescriptorError(err)
+		}
+		derived, err := desc.DerivedString(i)
+		if err != nil {
+			return nil, invalidDescriptorError(err)
+		}
+		scripts[string(pkScript)] = derived
+		if i == end {
+			break
+		}
+	}
+	return scripts, nil
+}
+
+// handleReloadConfig implements the reloadconfig command.
+func handleReloadConfig(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	changes, err := s.cfg.ReloadConfig()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Unable to reload configuration: " + err.Error(),
+		}
+	}
+
+	result := make([]btcjson.ReloadConfigChange, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, btcjson.ReloadConfigChange{
+			Option: change.Option,
+			Old:    change.Old,
+			New:    change.New,
+		})
+	}
+	return result, nil
+}
+
+// handleScanTxOutSet implements the scantxoutset command.
+func handleScanTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ScanTxOutSetCmd)
+
+	switch c.Action {
+	case "status":
+		progress, ok := s.utxoScan.progress()
+		if !ok {
+			return nil, nil
 		}
+		return &btcjson.ScanTxOutSetStatusResult{Progress: progress}, nil
 
-		txOut := mtx.TxOut[c.Vout]
-		if txOut == nil {
-			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
-				"does not exist", c.Vout, txHash)
-			return nil, internalRPCError(errStr, "")
+	case "abort":
+		return s.utxoScan.stop(), nil
+
+	case "start":
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid action '%s'", c.Action),
 		}
+	}
 
-		best := s.cfg.Chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 0
-		value = txOut.Value
-		pkScript = txOut.PkScript
-		isCoinbase = blockchain.IsCoinBaseTx(mtx)
-	} else {
-		out := wire.OutPoint{Hash: *txHash, Index: c.Vout}
-		entry, err := s.cfg.Chain.FetchUtxoEntry(out)
+	if c.ScanObjects == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "scanobjects argument is required for the start " +
+				"action",
+		}
+	}
+	descs := make(map[string]string, len(*c.ScanObjects))
+	for i := range *c.ScanObjects {
+		scripts, err := parseScanObject(&(*c.ScanObjects)[i],
+			s.cfg.ChainParams)
 		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
+			return nil, err
 		}
-
-		// To match the behavior of the reference client, return nil
-		// (JSON null) if the transaction output is spent by another
-		// transaction already in the main chain.  Mined transactions
-		// that are spent by a mempool transaction are not affected by
-		// this.
-		if entry == nil || entry.IsSpent() {
-			return nil, nil
+		// The descriptor of the first scan object describing a script
+		// is reported for its outputs.
+		for pkScript, desc := range scripts {
+			if _, ok := descs[pkScript]; !ok {
+				descs[pkScript] = desc
+			}
 		}
+	}
 
-		best := s.cfg.Chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 1 + best.Height - entry.BlockHeight()
-		value = entry.Amount()
-		pkScript = entry.PkScript()
-		isCoinbase = entry.IsCoinBase()
+	abort, ok := s.utxoScan.start(s.cfg.Chain.UtxoSetStats().TxOuts)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Scan already in progress, use action \"abort\" " +
+				"or \"status\"",
+		}
 	}
+	defer s.utxoScan.finish()
 
-	// Disassemble script into single line printable format.
-	// The disassembled string will contain [error] inline if the script
-	// doesn't fully parse, so ignore the error here.
-	disbuf, _ := txscript.DisasmString(pkScript)
+	// Abort the scan when the client disconnects or the server shuts down
+	// since nobody would receive the result.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-closeChan:
+			s.utxoScan.stop()
+		case <-s.quit:
+			s.utxoScan.stop()
+		case <-done:
+		}
+	}()
 
-	// Get further info about the script.
-	// Ignore the error here since an error means the script couldn't parse
-	// and there is no additional information about it anyways.
-	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		s.cfg.ChainParams)
-	addresses := make([]string, len(addrs))
-	for i, addr := range addrs {
-		addresses[i] = addr.EncodeAddress()
-	}
+	var scanned uint64
+	var totalAmount int64
+	unspents := make([]btcjson.ScanTxOutSetUnspent, 0)
+	bestHash, bestHeight, err := s.cfg.Chain.ScanUtxoSet(abort,
+		func(op wire.OutPoint, entry *blockchain.UtxoEntry) error {
+			scanned++
+			if scanned%1000 == 0 {
+				s.utxoScan.setScanned(scanned)
+			}
 
-	// Address is defined when there's a single well-defined
-	// receiver address. To spend the output a signature for this,
-	// and only this, address is required.
-	if len(addresses) == 1 && reqSigs <= 1 {
-		address = addresses[0]
-	}
+			desc, ok := descs[string(entry.PkScript())]
+			if !ok {
+				return nil
+			}
+			unspents = append(unspents, btcjson.ScanTxOutSetUnspent{
+				Txid:         op.Hash.String(),
+				Vout:         op.Index,
+				ScriptPubKey: hex.EncodeToString(entry.PkScript()),
+				Desc:         desc,
+				Amount:       btcutil.Amount(entry.Amount()).ToBTC(),
+				Coinbase:     entry.IsCoinBase(),
+				Height:       entry.BlockHeight(),
+			})
+			totalAmount += entry.Amount()
+			return nil
+		})
+	success := true
+	switch {
+	case err == blockchain.ErrUtxoScanInterrupted:
+		// The outputs found before the scan was aborted are returned
+		// as unsuccessful result like Bitcoin Core does.
+		success = false
+		best := s.cfg.Chain.BestSnapshot()
+		bestHash, bestHeight = &best.Hash, best.Height
 
-	txOutReply := &btcjson.GetTxOutResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: int64(confirmations),
-		Value:         btcutil.Amount(value).ToBTC(),
-		ScriptPubKey: btcjson.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Address:   address,
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+	case err != nil:
+		context := "Failed to scan the utxo set"
+		return nil, internalRPCError(err.Error(), context)
 	}
 
-	return txOutReply, nil
+	return &btcjson.ScanTxOutSetResult{
+		Success:     success,
+		TxOuts:      int64(scanned),
+		Height:      bestHeight,
+		BestBlock:   bestHash.String(),
+		Unspents:    unspents,
+		TotalAmount: btcutil.Amount(totalAmount).ToBTC(),
+	}, nil
 }
 
 // handleHelp implements the help command.
@@ -2902,6 +5568,43 @@ func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handlePreciousBlock implements the preciousblock command.
+func handlePreciousBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PreciousBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	if err := s.cfg.Chain.PreciousBlock(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
+// handlePrioritiseTransaction implements the prioritisetransaction command.
+func handlePrioritiseTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PrioritiseTransactionCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	s.cfg.TxMemPool.PrioritiseTransaction(txHash, c.FeeDelta)
+
+	return true, nil
+}
+
 // retrievedTx represents a transaction that was either loaded from the
 // transaction memory pool or from the database.  When a transaction is loaded
 // from the database, it is loaded with the raw serialized bytes while the
@@ -3102,13 +5805,12 @@ func createVinListPrevOut(s *rpcServer, mtx *wire.MsgTx, chainParams *chaincfg.P
 	return vinList, nil
 }
 
-// fetchMempoolTxnsForAddress queries the address index for all unconfirmed
-// transactions that involve the provided address.  The results will be limited
-// by the number to skip and the number requested.
-func fetchMempoolTxnsForAddress(s *rpcServer, addr btcutil.Address, numToSkip, numRequested uint32) ([]*btcutil.Tx, uint32) {
+// limitMempoolTxns limits the passed unconfirmed transactions by the number to
+// skip and the number requested.  It also returns the number actually skipped
+// since it could be less in the case where there are not enough entries.
+func limitMempoolTxns(mpTxns []*btcutil.Tx, numToSkip, numRequested uint32) ([]*btcutil.Tx, uint32) {
 	// There are no entries to return when there are less available than the
 	// number being skipped.
-	mpTxns := s.cfg.AddrIndex.UnconfirmedTxnsForAddress(addr)
 	numAvailable := uint32(len(mpTxns))
 	if numToSkip > numAvailable {
 		return nil, numAvailable
@@ -3192,6 +5894,71 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		reverse = *c.Reverse
 	}
 
+	// Override the flag for including unconfirmed transactions if needed.
+	includeMempool := true
+	if c.IncludeMempool != nil {
+		includeMempool = *c.IncludeMempool
+	}
+
+	// Load the unconfirmed transactions that involve the address when they
+	// are requested.  They are ordered by their hashes so they are returned
+	// in a consistent order across calls.
+	var mpTxns []*btcutil.Tx
+	if includeMempool {
+		mpTxns = addrIndex.UnconfirmedTxnsForAddress(addr)
+		sort.Slice(mpTxns, func(i, j int) bool {
+			less := bytes.Compare(mpTxns[i].Hash()[:],
+				mpTxns[j].Hash()[:]) < 0
+			return less != reverse
+		})
+	}
+
+	// Resolve the transaction to continue after when one is provided.  When
+	// it is an unconfirmed transaction, only the unconfirmed transactions
+	// after it are included, and there are no confirmed transactions after
+	// it unless the results are reversed.  Otherwise, it must be a
+	// confirmed transaction, so the confirmed transactions are loaded
+	// relative to its location, and there are no unconfirmed transactions
+	// after it when the results are reversed.
+	var afterRegion *database.BlockRegion
+	includeConfirmed := true
+	if c.After != nil {
+		afterHash, err := chainhash.NewHashFromStr(*c.After)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.After)
+		}
+
+		mpIndex := -1
+		for i, tx := range mpTxns {
+			if tx.Hash().IsEqual(afterHash) {
+				mpIndex = i
+				break
+			}
+		}
+		if mpIndex != -1 {
+			mpTxns = mpTxns[mpIndex+1:]
+			includeConfirmed = reverse
+		} else {
+			if s.cfg.TxIndex != nil {
+				afterRegion, err = s.cfg.TxIndex.TxBlockRegion(afterHash)
+				if err != nil {
+					context := "Failed to retrieve transaction location"
+					return nil, internalRPCError(err.Error(), context)
+				}
+			}
+			if afterRegion == nil {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: fmt.Sprintf("Unknown transaction "+
+						"to continue after: %v", afterHash),
+				}
+			}
+			if reverse {
+				mpTxns = nil
+			}
+		}
+	}
+
 	// Add transactions from mempool first if client asked for reverse
 	// order.  Otherwise, they will be added last (as needed depending on
 	// the requested counts).
@@ -3205,7 +5972,7 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		// Transactions in the mempool are not in a block header yet,
 		// so the block header field in the retrieved transaction struct
 		// is left nil.
-		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
+		mpTxns, mpSkipped := limitMempoolTxns(mpTxns,
 			uint32(numToSkip), uint32(numRequested))
 		numSkipped += mpSkipped
 		for _, tx := range mpTxns {
@@ -3215,11 +5982,21 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 
 	// Fetch transactions from the database in the desired order if more are
 	// needed.
-	if len(addressTxns) < numRequested {
+	if includeConfirmed && len(addressTxns) < numRequested {
 		err = s.cfg.DB.View(func(dbTx database.Tx) error {
-			regions, dbSkipped, err := addrIndex.TxRegionsForAddress(
-				dbTx, addr, uint32(numToSkip)-numSkipped,
-				uint32(numRequested-len(addressTxns)), reverse)
+			var regions []database.BlockRegion
+			var dbSkipped uint32
+			var err error
+			if afterRegion != nil {
+				regions, dbSkipped, err = addrIndex.TxRegionsForAddressAfter(
+					dbTx, addr, afterRegion,
+					uint32(numToSkip)-numSkipped,
+					uint32(numRequested-len(addressTxns)), reverse)
+			} else {
+				regions, dbSkipped, err = addrIndex.TxRegionsForAddress(
+					dbTx, addr, uint32(numToSkip)-numSkipped,
+					uint32(numRequested-len(addressTxns)), reverse)
+			}
 			if err != nil {
 				return err
 			}
@@ -3259,7 +6036,7 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		// Transactions in the mempool are not in a block header yet,
 		// so the block header field in the retrieved transaction struct
 		// is left nil.
-		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
+		mpTxns, mpSkipped := limitMempoolTxns(mpTxns,
 			uint32(numToSkip)-numSkipped, uint32(numRequested-
 				len(addressTxns)))
 		numSkipped += mpSkipped
@@ -3269,7 +6046,9 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 	}
 
 	// Address has never been used if neither source yielded any results.
-	if len(addressTxns) == 0 {
+	// There are simply no more results when continuing after a transaction
+	// though.
+	if len(addressTxns) == 0 && c.After == nil {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCNoTxInfo,
 			Message: "No information available about address",
@@ -3482,13 +6261,87 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 
 	// Keep track of all the sendrawtransaction request txns so that they
 	// can be rebroadcast if they don't make their way into a block.
-	txD := acceptedTxs[0]
-	iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
-	s.cfg.ConnMgr.AddRebroadcastInventory(iv, txD)
+	s.cfg.Rebroadcaster.Add(acceptedTxs[0])
 
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := banman.ParseSubnet(c.Subnet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: "Invalid IP/Subnet: " + err.Error(),
+		}
+	}
+
+	switch c.Command {
+	case btcjson.SBAdd:
+		var banTime, absolute = int64(0), false
+		if c.BanTime != nil {
+			banTime = *c.BanTime
+		}
+		if c.Absolute != nil {
+			absolute = *c.Absolute
+		}
+		if banTime < 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "bantime must not be negative",
+			}
+		}
+
+		// The ban lasts for the configured ban duration unless a
+		// duration in seconds, or a time when absolute is set, is
+		// given.
+		var until time.Time
+		switch {
+		case banTime == 0:
+			until = time.Now().Add(liveCfg().BanDuration)
+		case absolute:
+			until = time.Unix(banTime, 0)
+		default:
+			until = time.Now().Add(time.Duration(banTime) * time.Second)
+		}
+		if !until.After(time.Now()) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "the ban must expire in the future",
+			}
+		}
+
+		if err := s.cfg.ConnMgr.Ban(subnet, until); err != nil {
+			return nil, internalRPCError(err.Error(),
+				"Unable to ban "+subnet.String())
+		}
+
+	case btcjson.SBRemove:
+		err := s.cfg.ConnMgr.Unban(subnet)
+		if err == banman.ErrNotBanned {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: "Unban failed: " + subnet.String() +
+					" is not banned",
+			}
+		}
+		if err != nil {
+			return nil, internalRPCError(err.Error(),
+				"Unable to unban "+subnet.String())
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3510,11 +6363,11 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	} else {
 		// Respond with an error if there are no addresses to pay the
 		// created blocks to.
-		if len(cfg.miningAddrs) == 0 {
+		if !s.cfg.CPUMiner.HasPayouts() {
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
 				Message: "No payment addresses specified " +
-					"via --miningaddr",
+					"via --miningaddr or --miningpayout",
 			}
 		}
 
@@ -3525,6 +6378,45 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSetMiningPayouts implements the setminingpayouts command.
+func handleSetMiningPayouts(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetMiningPayoutsCmd)
+
+	payouts := make([]cpuminer.Payout, 0, len(c.Payouts))
+	for _, p := range c.Payouts {
+		desc, err := parseDescriptor(p.Desc, false, s.cfg.ChainParams)
+		if err != nil {
+			return nil, err
+		}
+		weight := uint32(1)
+		if p.Weight != nil {
+			weight = *p.Weight
+		}
+		payouts = append(payouts, cpuminer.Payout{
+			Descriptor: desc,
+			Weight:     weight,
+		})
+	}
+	if err := s.cfg.CPUMiner.SetPayouts(payouts); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid payout: " + err.Error(),
+		}
+	}
+
+	// Return the payouts now in effect, which are the ones of the
+	// configuration when none were passed.
+	payouts = s.cfg.CPUMiner.Payouts()
+	result := make([]btcjson.MiningPayoutResult, 0, len(payouts))
+	for _, p := range payouts {
+		result = append(result, btcjson.MiningPayoutResult{
+			Desc:   p.Descriptor.String(),
+			Weight: p.Weight,
+		})
+	}
+	return result, nil
+}
+
 // Text used to signify that a signed message follows and to prevent
 // inadvertently signing a transaction.
 const messageSignatureHeader = "Bitcoin Signed Message:\n"
@@ -3613,6 +6505,53 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSubmitHeader implements the submitheader command.
+func handleSubmitHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SubmitHeaderCmd)
+
+	// Deserialize the submitted header.
+	hexStr := c.HexData
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + c.HexData
+	}
+	serializedHeader, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var header wire.BlockHeader
+	err = header.Deserialize(bytes.NewReader(serializedHeader))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Block header decode failed: " + err.Error(),
+		}
+	}
+
+	// The previous header must be known since headers aren't kept as
+	// orphans.
+	if _, err := s.cfg.Chain.HeaderByHash(&header.PrevBlock); err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCVerify,
+			Message: fmt.Sprintf("Must submit previous header (%v) "+
+				"first", header.PrevBlock),
+		}
+	}
+
+	// Accept the header into the block index so the block is known ahead
+	// of its data, which can then be downloaded like any other block.
+	err = s.cfg.Chain.ProcessBlockHeader(&header, blockchain.BFNone)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: "Block header rejected: " + err.Error(),
+		}
+	}
+
+	rpcsLog.Infof("Accepted block header %s via submitheader",
+		header.BlockHash())
+	return nil, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3666,41 +6605,6 @@ func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	return result, nil
 }
 
-func verifyChain(s *rpcServer, level, depth int32) error {
-	best := s.cfg.Chain.BestSnapshot()
-	finishHeight := best.Height - depth
-	if finishHeight < 0 {
-		finishHeight = 0
-	}
-	rpcsLog.Infof("Verifying chain for %d blocks at level %d",
-		best.Height-finishHeight, level)
-
-	for height := best.Height; height > finishHeight; height-- {
-		// Level 0 just looks up the block.
-		block, err := s.cfg.Chain.BlockByHeight(height)
-		if err != nil {
-			rpcsLog.Errorf("Verify is unable to fetch block at "+
-				"height %d: %v", height, err)
-			return err
-		}
-
-		// Level 1 does basic chain sanity checks.
-		if level > 0 {
-			err := blockchain.CheckBlockSanity(block,
-				s.cfg.ChainParams.PowLimit, s.cfg.TimeSource)
-			if err != nil {
-				rpcsLog.Errorf("Verify is unable to validate "+
-					"block at hash %v height %d: %v",
-					block.Hash(), height, err)
-				return err
-			}
-		}
-	}
-	rpcsLog.Infof("Chain verify completed successfully")
-
-	return nil
-}
-
 // handleVerifyChain implements the verifychain command.
 func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyChainCmd)
@@ -3709,11 +6613,37 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	if c.CheckLevel != nil {
 		checkLevel = *c.CheckLevel
 	}
-	if c.CheckDepth != nil {
-		checkDepth = *c.CheckDepth
+	if c.CheckDepth != nil {
+		checkDepth = *c.CheckDepth
+	}
+
+	// Higher levels are clamped to the highest supported level.
+	if checkLevel < 0 {
+		checkLevel = 0
+	}
+	if checkLevel > blockchain.MaxVerifyLevel {
+		checkLevel = blockchain.MaxVerifyLevel
+	}
+	if checkDepth < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Check depth must not be negative",
+		}
+	}
+	if checkDepth == 0 && checkLevel > blockchain.MaxUnboundedVerifyLevel {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Check level %d requires a check "+
+				"depth, all blocks are only checked up to "+
+				"level %d", checkLevel,
+				blockchain.MaxUnboundedVerifyLevel),
+		}
 	}
 
-	err := verifyChain(s, checkLevel, checkDepth)
+	err := s.cfg.Chain.VerifyChain(checkLevel, checkDepth, closeChan)
+	if err != nil {
+		rpcsLog.Errorf("Chain verification failed: %v", err)
+	}
 	return err == nil, nil
 }
 
@@ -3801,6 +6731,16 @@ func handleTestMempoolAccept(s *rpcServer, cmd interface{},
 
 	c := cmd.(*btcjson.TestMempoolAcceptCmd)
 
+	// NOTE: This is the same error bitcoind returns for arrays which exceed
+	// the package limits.
+	if len(c.RawTxns) == 0 || len(c.RawTxns) > mempool.MaxPackageCount {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Array must contain between 1 and "+
+				"%d transactions.", mempool.MaxPackageCount),
+		}
+	}
+
 	// Create txns to hold the decoded tx.
 	txns := make([]*btcutil.Tx, 0, len(c.RawTxns))
 
@@ -3822,6 +6762,12 @@ func handleTestMempoolAccept(s *rpcServer, cmd interface{},
 		txns = append(txns, tx)
 	}
 
+	// Multiple transactions are tested as a package so they may spend the
+	// outputs of the transactions that precede them.
+	if len(txns) > 1 {
+		return s.testPackageAcceptance(txns, c.MaxFeeRate)
+	}
+
 	results := make([]*btcjson.TestMempoolAcceptResult, 0, len(txns))
 	for _, tx := range txns {
 		// Create a test result item.
@@ -3841,6 +6787,9 @@ func handleTestMempoolAccept(s *rpcServer, cmd interface{},
 			// TODO(yy): differentiate the errors and put package
 			// error in `PackageError` field.
 			item.RejectReason = err.Error()
+			item.Replacement = replacementRejectionResult(
+				mempool.ExtractReplacementRejection(err),
+			)
 
 			results = append(results, item)
 
@@ -3887,6 +6836,303 @@ func handleTestMempoolAccept(s *rpcServer, cmd interface{},
 	return results, nil
 }
 
+// testPackageAcceptance implements the testmempoolaccept command for multiple
+// transactions, which are tested as a package without adding them to the
+// mempool.  Each transaction must be acceptable on its own once the
+// transactions that precede it are accepted.  The transactions following the
+// first one which is not acceptable, or whose fee rate exceeds the passed
+// maximum, are not evaluated and their results only hold their hashes.
+func (s *rpcServer) testPackageAcceptance(txns []*btcutil.Tx,
+	maxFeeRate btcjson.BTCPerkvB) (interface{}, error) {
+
+	results := make([]*btcjson.TestMempoolAcceptResult, 0, len(txns))
+	for _, tx := range txns {
+		results = append(results, &btcjson.TestMempoolAcceptResult{
+			Txid:  tx.Hash().String(),
+			Wtxid: tx.WitnessHash().String(),
+		})
+	}
+
+	pkgResult, err := s.cfg.TxMemPool.CheckPackageAcceptance(txns)
+	if err != nil {
+		if _, ok := err.(mempool.RuleError); !ok {
+			context := "Failed to test package"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// None of the transactions are evaluated when they don't form
+		// a consistent package.
+		for _, item := range results {
+			item.PackageError = err.Error()
+		}
+		return results, nil
+	}
+
+	for i, txResult := range pkgResult.TxResults {
+		item := results[i]
+		switch {
+		// NOTE: "missing-inputs" is what bitcoind returns here, so we
+		// mimic the same error message.
+		case txResult.MissingParents != nil:
+			item.RejectReason = "missing-inputs"
+
+		case txResult.Err != nil:
+			item.RejectReason = txResult.Err.Error()
+			item.Replacement = replacementRejectionResult(
+				mempool.ExtractReplacementRejection(txResult.Err),
+			)
+
+		default:
+			item.Fees, item.Allowed = validateFeeRate(
+				txResult.Fee, txResult.VSize, maxFeeRate,
+			)
+			if !item.Allowed {
+				item.RejectReason = "max-fee-exceeded"
+				return results, nil
+			}
+			item.Vsize = int32(txResult.VSize)
+		}
+	}
+
+	return results, nil
+}
+
+// replacementRejectionResult converts the passed details of a rejected
+// replacement to the section of the testmempoolaccept result that describes
+// them.  It returns nil when no details are passed.
+func replacementRejectionResult(
+	r *mempool.ReplacementRejection) *btcjson.TestMempoolAcceptReplacement {
+
+	if r == nil {
+		return nil
+	}
+
+	conflicts := make([]string, 0, len(r.Conflicts))
+	for _, hash := range r.Conflicts {
+		conflicts = append(conflicts, hash.String())
+	}
+	return &btcjson.TestMempoolAcceptReplacement{
+		Rule:         r.Rule.String(),
+		BIP125Rule:   int32(r.Rule.BIP125Rule()),
+		Conflicts:    conflicts,
+		Fee:          btcutil.Amount(r.Fee).ToBTC(),
+		FeeRate:      btcutil.Amount(r.FeeRate).ToBTC(),
+		FeeDelta:     btcutil.Amount(r.FeeDelta).ToBTC(),
+		FeeRateDelta: btcutil.Amount(r.FeeRateDelta).ToBTC(),
+	}
+}
+
+// packageTxFeeRate returns the fee rate in BTC/kvB of the passed transaction
+// of a package.  The inputs are looked up in the preceding transactions of the
+// package, the memory pool and the utxo set.  False is returned when any input
+// can't be found, in which case the memory pool decides about the transaction.
+func (s *rpcServer) packageTxFeeRate(tx *btcutil.Tx,
+	pkgTxns map[chainhash.Hash]*btcutil.Tx) (float64, bool) {
+
+	var totalIn int64
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		prevTx, ok := pkgTxns[prevOut.Hash]
+		if !ok {
+			prevTx, _ = s.cfg.TxMemPool.FetchTransaction(&prevOut.Hash)
+		}
+		if prevTx != nil {
+			txOuts := prevTx.MsgTx().TxOut
+			if prevOut.Index >= uint32(len(txOuts)) {
+				return 0, false
+			}
+			totalIn += txOuts[prevOut.Index].Value
+			continue
+		}
+
+		entry, err := s.cfg.Chain.FetchUtxoEntry(prevOut)
+		if err != nil || entry == nil || entry.IsSpent() {
+			return 0, false
+		}
+		totalIn += entry.Amount()
+	}
+
+	var totalOut int64
+	for _, txOut := range tx.MsgTx().TxOut {
+		totalOut += txOut.Value
+	}
+
+	fee := btcutil.Amount(totalIn - totalOut)
+	vsize := mempool.GetTxVirtualSize(tx)
+	return (fee * 1e3 / btcutil.Amount(vsize)).ToBTC(), true
+}
+
+// handleSubmitPackage implements the submitpackage command.
+func handleSubmitPackage(s *rpcServer, cmd interface{},
+	closeChan <-chan struct{}) (interface{}, error) {
+
+	c := cmd.(*btcjson.SubmitPackageCmd)
+
+	maxFeeRate := defaultMaxFeeRate
+	if c.MaxFeeRate != nil {
+		maxFeeRate = *c.MaxFeeRate
+	}
+	var maxBurnAmount btcutil.Amount
+	if c.MaxBurnAmount != nil {
+		var err error
+		maxBurnAmount, err = btcutil.NewAmount(*c.MaxBurnAmount)
+		if err != nil || maxBurnAmount < 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid maxburnamount",
+			}
+		}
+	}
+
+	// Decode the transactions and reject the ones with unspendable outputs
+	// whose value exceeds the maximum burn amount.
+	txns := make([]*btcutil.Tx, 0, len(c.RawTxns))
+	msgTxns := make([]*wire.MsgTx, 0, len(c.RawTxns))
+	for _, rawTx := range c.RawTxns {
+		rawBytes, err := hex.DecodeString(rawTx)
+		if err != nil {
+			return nil, rpcDecodeHexError(rawTx)
+		}
+
+		tx, err := btcutil.NewTxFromBytes(rawBytes)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+
+		for _, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) &&
+				btcutil.Amount(txOut.Value) > maxBurnAmount {
+
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCTxError,
+					Message: "Unspendable output exceeds " +
+						"maximum configured by user " +
+						"(maxburnamount)",
+				}
+			}
+		}
+
+		txns = append(txns, tx)
+		msgTxns = append(msgTxns, tx.MsgTx())
+	}
+
+	result := &btcjson.SubmitPackageResult{
+		PackageMsg:           "success",
+		TxResults:            make(map[string]*btcjson.SubmitPackageTxResult),
+		ReplacedTransactions: []string{},
+	}
+	for _, tx := range txns {
+		result.TxResults[tx.WitnessHash().String()] =
+			&btcjson.SubmitPackageTxResult{
+				Txid: tx.Hash().String(),
+			}
+	}
+
+	// Nothing is submitted when the fee rate of any of the transactions
+	// exceeds the maximum fee rate.  A maximum fee rate of 0 disables the
+	// check.
+	if maxFeeRate != 0 {
+		pkgTxns := make(map[chainhash.Hash]*btcutil.Tx, len(txns))
+		for _, tx := range txns {
+			feeRate, ok := s.packageTxFeeRate(tx, pkgTxns)
+			pkgTxns[*tx.Hash()] = tx
+			if !ok || feeRate <= maxFeeRate {
+				continue
+			}
+
+			// NOTE: "max feerate exceeded" is what bitcoind returns
+			// here, so we mimic the same error message.
+			txResult := result.TxResults[tx.WitnessHash().String()]
+			txResult.Error = "max feerate exceeded"
+			result.PackageMsg = "transaction failed"
+			return result, nil
+		}
+	}
+
+	pkgResult, err := s.cfg.TxMemPool.ProcessPackage(msgTxns)
+	if err != nil {
+		if _, ok := err.(mempool.RuleError); ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "package topology disallowed: " + err.Error(),
+			}
+		}
+
+		rpcsLog.Errorf("Failed to process package: %v", err)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCTxError,
+			Message: "Package rejected: " + err.Error(),
+		}
+	}
+
+	if pkgResult.Err != nil {
+		rpcsLog.Debugf("Rejected package: %v", pkgResult.Err)
+		result.PackageMsg = "transaction failed"
+	}
+	for _, txResult := range pkgResult.TxResults {
+		tx := txResult.Tx
+		item := result.TxResults[tx.WitnessHash().String()]
+		if txResult.Err != nil {
+			item.Error = txResult.Err.Error()
+			continue
+		}
+
+		// Transactions which were already in the pool might have a
+		// different witness.
+		if txResult.AlreadyInPool {
+			poolTx, err := s.cfg.TxMemPool.FetchTransaction(tx.Hash())
+			if err == nil && !poolTx.WitnessHash().IsEqual(
+				tx.WitnessHash()) {
+
+				item.OtherWtxid = poolTx.WitnessHash().String()
+				continue
+			}
+		}
+
+		includes := make([]string, 0, len(txResult.EffectiveIncludes))
+		for _, included := range txResult.EffectiveIncludes {
+			includes = append(includes,
+				included.WitnessHash().String())
+		}
+		item.Vsize = int32(txResult.VSize)
+		item.Fees = &btcjson.TestMempoolAcceptFees{
+			Base: txResult.Fee.ToBTC(),
+			EffectiveFeeRate: btcutil.Amount(
+				txResult.EffectiveFeeRate).ToBTC(),
+			EffectiveIncludes: includes,
+		}
+	}
+	for _, replaced := range pkgResult.ReplacedTxns {
+		result.ReplacedTransactions = append(
+			result.ReplacedTransactions, replaced.Hash().String(),
+		)
+	}
+
+	if len(pkgResult.AcceptedTxns) == 0 {
+		return result, nil
+	}
+
+	// Generate and relay inventory vectors for all newly accepted
+	// transactions and notify both websocket and getblocktemplate long
+	// poll clients of them.
+	s.cfg.ConnMgr.RelayTransactions(pkgResult.AcceptedTxns)
+	s.NotifyNewTransactions(pkgResult.AcceptedTxns)
+
+	// Keep track of the accepted transactions of the package so that they
+	// can be rebroadcast if they don't make their way into a block.
+	for _, txD := range pkgResult.AcceptedTxns {
+		if _, ok := result.TxResults[txD.Tx.WitnessHash().String()]; !ok {
+			continue
+		}
+		s.cfg.Rebroadcaster.Add(txD)
+	}
+
+	return result, nil
+}
+
 // handleGetTxSpendingPrevOut implements the gettxspendingprevout command.
 func handleGetTxSpendingPrevOut(s *rpcServer, cmd interface{},
 	closeChan <-chan struct{}) (interface{}, error) {
@@ -3962,74 +7208,21 @@ type rpcServer struct {
 	started                int32
 	shutdown               int32
 	cfg                    rpcserverConfig
-	authsha                [sha256.Size]byte
-	limitauthsha           [sha256.Size]byte
+	users                  []*rpcUser
+	cookiePath             string
+	limiter                *rpcRateLimiter
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
-	statusLines            map[int]string
-	statusLock             sync.RWMutex
+	httpServer             *http.Server
 	wg                     sync.WaitGroup
 	gbtWorkState           *gbtWorkState
 	helpCacher             *helpCacher
+	stats                  *rpcStats
+	utxoScan               utxoScan
 	requestProcessShutdown chan struct{}
 	quit                   chan int
 }
 
-// httpStatusLine returns a response Status-Line (RFC 2616 Section 6.1)
-// for the given request and response status code.  This function was lifted and
-// adapted from the standard library HTTP server code since it's not exported.
-func (s *rpcServer) httpStatusLine(req *http.Request, code int) string {
-	// Fast path:
-	key := code
-	proto11 := req.ProtoAtLeast(1, 1)
-	if !proto11 {
-		key = -key
-	}
-	s.statusLock.RLock()
-	line, ok := s.statusLines[key]
-	s.statusLock.RUnlock()
-	if ok {
-		return line
-	}
-
-	// Slow path:
-	proto := "HTTP/1.0"
-	if proto11 {
-		proto = "HTTP/1.1"
-	}
-	codeStr := strconv.Itoa(code)
-	text := http.StatusText(code)
-	if text != "" {
-		line = proto + " " + codeStr + " " + text + "\r\n"
-		s.statusLock.Lock()
-		s.statusLines[key] = line
-		s.statusLock.Unlock()
-	} else {
-		text = "status code " + codeStr
-		line = proto + " " + codeStr + " " + text + "\r\n"
-	}
-
-	return line
-}
-
-// writeHTTPResponseHeaders writes the necessary response headers prior to
-// writing an HTTP body given a request to use for protocol negotiation, headers
-// to write, a status code, and a writer.
-func (s *rpcServer) writeHTTPResponseHeaders(req *http.Request, headers http.Header, code int, w io.Writer) error {
-	_, err := io.WriteString(w, s.httpStatusLine(req, code))
-	if err != nil {
-		return err
-	}
-
-	err = headers.Write(w)
-	if err != nil {
-		return err
-	}
-
-	_, err = io.WriteString(w, "\r\n")
-	return err
-}
-
 // Stop is used by server.go to stop the rpc listener.
 func (s *rpcServer) Stop() error {
 	if atomic.AddInt32(&s.shutdown, 1) != 1 {
@@ -4037,9 +7230,11 @@ func (s *rpcServer) Stop() error {
 		return nil
 	}
 	rpcsLog.Warnf("RPC server shutting down")
-	for _, listener := range s.cfg.Listeners {
-		err := listener.Close()
-		if err != nil {
+
+	// Close the listeners along with the connections which are kept open
+	// for reuse by clients.
+	if s.httpServer != nil {
+		if err := s.httpServer.Close(); err != nil {
 			rpcsLog.Errorf("Problem shutting down rpc: %v", err)
 			return err
 		}
@@ -4048,6 +7243,11 @@ func (s *rpcServer) Stop() error {
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
 	s.wg.Wait()
+	if s.cookiePath != "" {
+		if err := os.Remove(s.cookiePath); err != nil {
+			rpcsLog.Warnf("Unable to remove cookie file: %v", err)
+		}
+	}
 	rpcsLog.Infof("RPC server shutdown complete")
 	return nil
 }
@@ -4073,14 +7273,23 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// NotifyTxReplaced notifies websocket clients that the passed mempool
+// transactions were replaced by the passed replacements.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) NotifyTxReplaced(replaced, replacements []*mempool.TxDesc) {
+	s.ntfnMgr.NotifyTxReplaced(replaced, replacements)
+}
+
 // limitConnections responds with a 503 service unavailable and returns true if
 // adding another client would exceed the maximum allow RPC clients.
 //
 // This function is safe for concurrent access.
 func (s *rpcServer) limitConnections(w http.ResponseWriter, remoteAddr string) bool {
-	if int(atomic.LoadInt32(&s.numClients)+1) > cfg.RPCMaxClients {
+	maxClients := liveCfg().RPCMaxClients
+	if int(atomic.LoadInt32(&s.numClients)+1) > maxClients {
 		rpcsLog.Infof("Max RPC clients exceeded [%d] - "+
-			"disconnecting client %s", cfg.RPCMaxClients,
+			"disconnecting client %s", maxClients,
 			remoteAddr)
 		http.Error(w, "503 Too busy.  Try again later.",
 			http.StatusServiceUnavailable)
@@ -4109,45 +7318,32 @@ func (s *rpcServer) decrementClients() {
 
 // checkAuth checks the HTTP Basic authentication supplied by a wallet
 // or RPC client in the HTTP request r.  If the supplied authentication
-// does not match the username and password expected, a non-nil error is
+// does not match the username and password of any user, a non-nil error is
 // returned.
 //
 // This check is time-constant.
 //
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, bool, error) {
+// The returned user determines which methods the client may call.  It is nil
+// when no authentication was supplied and require is false.
+func (s *rpcServer) checkAuth(r *http.Request, require bool) (*rpcUser, error) {
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) <= 0 {
 		if require {
 			rpcsLog.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, errors.New("auth failure")
+			return nil, errors.New("auth failure")
 		}
 
-		return false, false, nil
-	}
-
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-
-	// Check for limited auth first as in environments with limited users, those
-	// are probably expected to have a higher volume of calls
-	limitcmp := subtle.ConstantTimeCompare(authsha[:], s.limitauthsha[:])
-	if limitcmp == 1 {
-		return true, false, nil
+		return nil, nil
 	}
 
-	// Check for admin-level auth
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp == 1 {
-		return true, true, nil
+	user := s.authenticate(authhdr[0])
+	if user == nil {
+		// Request's auth doesn't match any user
+		rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
+		return nil, errors.New("auth failure")
 	}
-
-	// Request's auth doesn't match either user
-	rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, errors.New("auth failure")
+	return user, nil
 }
 
 // parsedRPCCmd represents a JSON-RPC request object that has been parsed into
@@ -4157,6 +7353,7 @@ type parsedRPCCmd struct {
 	jsonrpc btcjson.RPCVersion
 	id      interface{}
 	method  string
+	params  []json.RawMessage
 	cmd     interface{}
 	err     *btcjson.RPCError
 }
@@ -4183,7 +7380,16 @@ func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct
 	return nil, btcjson.ErrRPCMethodNotFound
 handled:
 
-	return handler(s, cmd.cmd, closeChan)
+	start := time.Now()
+	id := s.stats.begin(cmd.method, start)
+	result, err := handler(s, cmd.cmd, closeChan)
+	elapsed := time.Since(start)
+	s.stats.end(id)
+	if s.stats.record(cmd.method, elapsed, err) {
+		rpcsLog.Infof("Slow RPC request: %s %s took %v", cmd.method,
+			redactParams(cmd.params), elapsed)
+	}
+	return result, err
 }
 
 // parseCmd parses a JSON-RPC request object into known concrete command.  The
@@ -4195,6 +7401,7 @@ func parseCmd(request *btcjson.Request) *parsedRPCCmd {
 		jsonrpc: request.Jsonrpc,
 		id:      request.ID,
 		method:  request.Method,
+		params:  request.Params,
 	}
 
 	cmd, err := btcjson.UnmarshalCmd(request)
@@ -4237,16 +7444,16 @@ func createMarshalledReply(rpcVersion btcjson.RPCVersion, id interface{}, result
 
 // processRequest determines the incoming request type (single or batched),
 // parses it and returns a marshalled response.
-func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, closeChan <-chan struct{}) []byte {
+func (s *rpcServer) processRequest(request *btcjson.Request, user *rpcUser, remoteAddr string, closeChan <-chan struct{}) []byte {
 	var result interface{}
 	var err error
 	var jsonErr *btcjson.RPCError
 
-	if !isAdmin {
-		if _, ok := rpcLimited[request.Method]; !ok {
-			jsonErr = internalRPCError("limited user not "+
-				"authorized for this method", "")
-		}
+	if !user.allowed(request.Method) {
+		rpcsLog.Debugf("RPC user %s is not authorized to call %s",
+			user.name, request.Method)
+		jsonErr = internalRPCError("limited user not "+
+			"authorized for this method", "")
 	}
 
 	if jsonErr == nil {
@@ -4269,6 +7476,12 @@ func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, close
 			return nil
 		}
 
+		// Reject calls to disabled methods and calls which exceed the
+		// rate limit of the method.
+		jsonErr = s.checkMethodLimits(remoteAddr, request.Method)
+	}
+
+	if jsonErr == nil {
 		// Attempt to parse the JSON-RPC request into a known
 		// concrete command.
 		parsedCmd := parseCmd(request)
@@ -4300,7 +7513,7 @@ func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, close
 }
 
 // jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, user *rpcUser) {
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
@@ -4315,41 +7528,11 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 		return
 	}
 
-	// Unfortunately, the http server doesn't provide the ability to
-	// change the read deadline for the new connection and having one breaks
-	// long polling.  However, not having a read deadline on the initial
-	// connection would mean clients can connect and idle forever.  Thus,
-	// hijack the connection from the HTTP server, clear the read deadline,
-	// and handle writing the response manually.
-	hj, ok := w.(http.Hijacker)
-	if !ok {
-		errMsg := "webserver doesn't support hijacking"
-		rpcsLog.Warnf(errMsg)
-		errCode := http.StatusInternalServerError
-		http.Error(w, strconv.Itoa(errCode)+" "+errMsg, errCode)
-		return
-	}
-	conn, buf, err := hj.Hijack()
-	if err != nil {
-		rpcsLog.Warnf("Failed to hijack HTTP connection: %v", err)
-		errCode := http.StatusInternalServerError
-		http.Error(w, strconv.Itoa(errCode)+" "+err.Error(), errCode)
-		return
-	}
-	defer conn.Close()
-	defer buf.Flush()
-	conn.SetReadDeadline(timeZeroVal)
-
-	// Attempt to parse the raw body into a JSON-RPC request.
-	// Setup a close notifier.  Since the connection is hijacked,
-	// the CloseNotifier on the ResponseWriter is not available.
-	closeChan := make(chan struct{}, 1)
-	go func() {
-		_, err = conn.Read(make([]byte, 1))
-		if err != nil {
-			close(closeChan)
-		}
-	}()
+	// The response is written through the response writer so the
+	// connection can be reused for further requests, or multiplexed with
+	// other requests over HTTP/2.  The request context is canceled when the
+	// client disconnects.
+	closeChan := r.Context().Done()
 
 	var results []json.RawMessage
 	var batchSize int
@@ -4399,7 +7582,7 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 			if req.ID == nil && !(cfg.RPCQuirks && req.Jsonrpc == "") {
 				return
 			}
-			resp = s.processRequest(&req, isAdmin, closeChan)
+			resp = s.processRequest(&req, user, r.RemoteAddr, closeChan)
 		}
 
 		if resp != nil {
@@ -4488,7 +7671,7 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 						continue
 					}
 
-					resp = s.processRequest(&req, isAdmin, closeChan)
+					resp = s.processRequest(&req, user, r.RemoteAddr, closeChan)
 					if resp != nil {
 						results = append(results, resp)
 					}
@@ -4524,17 +7707,14 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 	}
 
 	// Write the response.
-	err = s.writeHTTPResponseHeaders(r, w.Header(), http.StatusOK, buf)
-	if err != nil {
-		rpcsLog.Error(err)
-		return
-	}
-	if _, err := buf.Write(msg); err != nil {
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(msg); err != nil {
 		rpcsLog.Errorf("Failed to write marshalled reply: %v", err)
+		return
 	}
 
 	// Terminate with newline to maintain compatibility with Bitcoin Core.
-	if err := buf.WriteByte('\n'); err != nil {
+	if _, err := w.Write([]byte{'\n'}); err != nil {
 		rpcsLog.Errorf("Failed to append terminating newline to reply: %v", err)
 	}
 }
@@ -4557,13 +7737,23 @@ func (s *rpcServer) Start() {
 		Handler: rpcServeMux,
 
 		// Timeout connections which don't complete the initial
-		// handshake within the allowed timeframe.
-		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
+		// handshake and send the headers of a request within the
+		// allowed timeframe.  Reading the rest of the request isn't
+		// limited since that would break long polling.
+		ReadHeaderTimeout: time.Second * rpcAuthTimeoutSeconds,
+
+		// Keep connections open between requests so clients making
+		// many requests don't need to connect for each of them.
+		IdleTimeout: cfg.RPCIdleTimeout,
 	}
+	if cfg.RPCIdleTimeout == 0 {
+		httpServer.SetKeepAlivesEnabled(false)
+	}
+	configureRPCHTTP2(httpServer)
+	s.httpServer = httpServer
+
 	rpcServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Connection", "close")
 		w.Header().Set("Content-Type", "application/json")
-		r.Close = true
 
 		// Limit the number of connections to max allowed.
 		if s.limitConnections(w, r.RemoteAddr) {
@@ -4573,19 +7763,53 @@ func (s *rpcServer) Start() {
 		// Keep track of the number of connected clients.
 		s.incrementClients()
 		defer s.decrementClients()
-		_, isAdmin, err := s.checkAuth(r, true)
+		user, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
 			return
 		}
 
 		// Read and respond to the request.
-		s.jsonRPCRead(w, r, isAdmin)
+		s.jsonRPCRead(w, r, user)
 	})
 
+	// Unauthenticated REST interface compatible with Bitcoin Core.
+	if cfg.REST {
+		rpcServeMux.HandleFunc(restPathPrefix, func(w http.ResponseWriter, r *http.Request) {
+			// Limit the number of connections to max allowed.
+			if s.limitConnections(w, r.RemoteAddr) {
+				return
+			}
+
+			s.incrementClients()
+			defer s.decrementClients()
+			s.restRequest(w, r)
+		})
+	}
+
+	// The pprof endpoints are only served to admin users.
+	if cfg.RPCPprof {
+		pprofHandler := diagnostics.Handler()
+		rpcServeMux.HandleFunc(diagnostics.PathPrefix, func(w http.ResponseWriter, r *http.Request) {
+			// Limit the number of connections to max allowed.
+			if s.limitConnections(w, r.RemoteAddr) {
+				return
+			}
+
+			s.incrementClients()
+			defer s.decrementClients()
+			user, err := s.checkAuth(r, true)
+			if err != nil || !user.admin {
+				jsonAuthFail(w)
+				return
+			}
+			pprofHandler.ServeHTTP(w, r)
+		})
+	}
+
 	// Websocket endpoint.
 	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, isAdmin, err := s.checkAuth(r, false)
+		user, err := s.checkAuth(r, false)
 		if err != nil {
 			jsonAuthFail(w)
 			return
@@ -4602,7 +7826,7 @@ func (s *rpcServer) Start() {
 			http.Error(w, "400 Bad Request.", http.StatusBadRequest)
 			return
 		}
-		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
+		s.WebsocketHandler(ws, r.RemoteAddr, user)
 	})
 
 	for _, listener := range s.cfg.Listeners {
@@ -4661,6 +7885,16 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// ConnectionType returns the type of the connection to the peer, which
+	// is inbound, manual, outbound-full-relay or block-relay-only.
+	ConnectionType() string
+
+	// AddrRelayStats returns whether addresses are relayed to and from the
+	// peer along with the number of addresses relayed by the peer which
+	// were processed and which were ignored for exceeding the address rate
+	// limit.
+	AddrRelayStats() (enabled bool, processed, rateLimited uint64)
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4703,6 +7937,30 @@ type rpcserverConnManager interface {
 	// network for all peers.
 	NetTotals() (uint64, uint64)
 
+	// UploadTarget returns the state of the daily upload target.
+	UploadTarget() connmgr.UploadTargetStatus
+
+	// Ban bans the passed subnet until the passed time and disconnects the
+	// peers which are part of it.
+	Ban(subnet *net.IPNet, until time.Time) error
+
+	// Unban removes the ban of the passed subnet.  banman.ErrNotBanned is
+	// returned when the subnet is not banned.
+	Unban(subnet *net.IPNet) error
+
+	// BanList returns the bans which have not expired.
+	BanList() ([]banman.Entry, error)
+
+	// ClearBanned removes all bans and discouragements.
+	ClearBanned() error
+
+	// LocalAddresses returns the local addresses advertised to peers.
+	LocalAddresses() []addrmgr.LocalAddress
+
+	// PortMapping returns the current mapping of the listening port on
+	// the NAT or nil when the port is not mapped.
+	PortMapping() *portMapping
+
 	// ConnectedPeers returns an array consisting of all connected peers.
 	ConnectedPeers() []rpcserverPeer
 
@@ -4714,11 +7972,6 @@ type rpcserverConnManager interface {
 	// connected peers.
 	BroadcastMessage(msg wire.Message)
 
-	// AddRebroadcastInventory adds the provided inventory to the list of
-	// inventories to be rebroadcast at random intervals until they show up
-	// in a block.
-	AddRebroadcastInventory(iv *wire.InvVect, data interface{})
-
 	// RelayTransactions generates and relays inventory vectors for all of
 	// the passed transactions to all connected peers.
 	RelayTransactions(txns []*mempool.TxDesc)
@@ -4748,11 +8001,25 @@ type rpcserverSyncManager interface {
 	// used to sync from or 0 if there is none.
 	SyncPeerID() int32
 
+	// PeerDownloadStats returns the statistics of the blocks downloaded
+	// from the peers during the initial block download keyed by peer ID.
+	PeerDownloadStats() map[int32]*netsync.PeerDownloadStats
+
 	// LocateHeaders returns the headers of the blocks after the first known
 	// block in the provided locators until the provided stop hash or the
 	// current tip is reached, up to a max of wire.MaxBlockHeadersPerMsg
 	// hashes.
 	LocateHeaders(locators []*chainhash.Hash, hashStop *chainhash.Hash) []wire.BlockHeader
+
+	// FetchBlock fetches the main chain block with the provided hash from
+	// a connected peer without processing or storing it.  It is used to
+	// serve blocks which were pruned from the database.
+	FetchBlock(hash *chainhash.Hash) (*btcutil.Block, error)
+
+	// RequestBlock requests the block with the provided hash from the peer
+	// with the provided ID without waiting for it.  The block is processed
+	// and stored once it is received.
+	RequestBlock(hash *chainhash.Hash, peerID int32) error
 }
 
 // rpcserverConfig is a descriptor containing the RPC server configuration.
@@ -4763,6 +8030,9 @@ type rpcserverConfig struct {
 	// is stopped.
 	Listeners []net.Listener
 
+	// Services are the services advertised to peers.
+	Services wire.ServiceFlag
+
 	// StartupTime is the unix timestamp for when the server that is hosting
 	// the RPC server started.
 	StartupTime int64
@@ -4796,37 +8066,92 @@ type rpcserverConfig struct {
 
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
-	CfIndex   *indexers.CfIndex
+	TxIndex        *indexers.TxIndex
+	AddrIndex      *indexers.AddrIndex
+	CfIndex        *indexers.CfIndex
+	TxCountIndex   *indexers.TxCountIndex
+	SpentIndex     *indexers.SpentIndex
+	TimestampIndex *indexers.TimestampIndex
+	BalanceIndex   *indexers.BalanceIndex
+
+	// IndexManager reports the status of the enabled indexes.  It is nil
+	// when no indexes are enabled.
+	IndexManager *indexers.Manager
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
+
+	// FinalityMgr is the finality manager whose status is reported by
+	// getblockchaininfo.  It is nil unless the finality module is enabled.
+	FinalityMgr *finality.Manager
+
+	// PeerReputation keeps the statistics of the peers which are reported
+	// by getpeerreputation.
+	PeerReputation *reputation.Tracker
+
+	// WatchOnly is the watch-only wallet which tracks the outputs of the
+	// imported descriptors and funds transactions with them.
+	WatchOnly *watchonly.Wallet
+
+	// Rebroadcaster tracks the transactions submitted through the RPC
+	// server and re-announces them to peers until they are confirmed.
+	Rebroadcaster *rebroadcast.Manager
+
+	// Diagnostics writes the profiles requested by collectprofile.
+	Diagnostics *diagnostics.Collector
+
+	// DumpState writes a dump of the state of the server for dumpstate.
+	DumpState func() (*diagnostics.StateDump, error)
+
+	// ReloadConfig reloads the configuration for reloadconfig.
+	ReloadConfig func() ([]configChange, error)
+
+	// ZMQNotifier publishes the ZeroMQ notifications reported by
+	// getzmqnotifications.
+	ZMQNotifier *zmq.Notifier
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
 func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 	rpc := rpcServer{
 		cfg:                    *config,
-		statusLines:            make(map[int]string),
-		gbtWorkState:           newGbtWorkState(config.TimeSource),
+		gbtWorkState:           newGbtWorkState(config.TimeSource, config.ChainParams),
 		helpCacher:             newHelpCacher(),
+		stats:                  newRPCStats(cfg.RPCSlowThreshold),
+		limiter:                newRPCRateLimiter(cfg.rpcRateLimits),
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
 	}
+	rpc.stats.publish()
 	if cfg.RPCUser != "" && cfg.RPCPass != "" {
-		login := cfg.RPCUser + ":" + cfg.RPCPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.authsha = sha256.Sum256([]byte(auth))
+		rpc.users = append(rpc.users, newRPCUser(cfg.RPCUser,
+			cfg.RPCPass, true, nil))
+	} else if !cfg.NoRPCCookie {
+		// Use cookie authentication for the admin user when no admin
+		// credentials are configured.
+		rpc.cookiePath = rpcCookiePath()
+		user, err := generateRPCCookie(rpc.cookiePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write cookie file: %v",
+				err)
+		}
+		rpc.users = append(rpc.users, user)
+		rpcsLog.Infof("Wrote RPC authentication cookie to %s",
+			rpc.cookiePath)
 	}
 	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
-		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.limitauthsha = sha256.Sum256([]byte(auth))
+		rpc.users = append(rpc.users, newRPCUser(cfg.RPCLimitUser,
+			cfg.RPCLimitPass, false, rpcPermissionMethods(rpcPermLimited)))
+	}
+	for _, user := range cfg.rpcUsers {
+		rpcsLog.Debugf("RPC user %s may call %s", user.name,
+			user.permissions())
+		rpc.users = append(rpc.users, user)
 	}
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
 	rpc.cfg.Chain.Subscribe(rpc.handleBlockchainNotification)
+	rpc.cfg.Chain.SubscribeChainEvents(rpc.ntfnMgr.NotifyChainEvent)
 
 	return &rpc, nil
 }
@@ -4836,16 +8161,17 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notification) {
 	switch notification.Type {
 	case blockchain.NTBlockAccepted:
-		block, ok := notification.Data.(*btcutil.Block)
-		if !ok {
+		if _, ok := notification.Data.(*btcutil.Block); !ok {
 			rpcsLog.Warnf("Chain accepted notification is not a block.")
 			break
 		}
 
 		// Allow any clients performing long polling via the
 		// getblocktemplate RPC to be notified when the new block causes
-		// their old block template to become stale.
-		s.gbtWorkState.NotifyBlockConnected(block.Hash())
+		// their old block template to become stale.  Accepted blocks
+		// don't necessarily extend the best chain, so the tip is used
+		// rather than the block itself.
+		s.gbtWorkState.NotifyBestBlock(&s.cfg.Chain.BestSnapshot().Hash)
 
 	case blockchain.NTBlockConnected:
 		block, ok := notification.Data.(*btcutil.Block)
@@ -4864,8 +8190,23 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 			break
 		}
 
+		// The templates of long polling clients building on the
+		// disconnected block are stale.
+		s.gbtWorkState.NotifyBestBlock(&s.cfg.Chain.BestSnapshot().Hash)
+
 		// Notify registered websocket clients.
 		s.ntfnMgr.NotifyBlockDisconnected(block)
+
+	case blockchain.NTReorgPending:
+		pending, ok := notification.Data.(*blockchain.PendingReorg)
+		if !ok {
+			rpcsLog.Warnf("Chain reorg pending notification is not a " +
+				"pending reorg.")
+			break
+		}
+
+		// Notify registered websocket clients.
+		s.ntfnMgr.NotifyReorgPending(pending)
 	}
 }
 
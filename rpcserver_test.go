@@ -1,14 +1,27 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/descriptor"
 	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/stretchr/testify/require"
 )
@@ -183,17 +196,19 @@ func TestHandleTestMempoolAcceptMixedResults(t *testing.T) {
 		},
 	}
 
-	// Create a mock request with default max fee rate of 0.1 BTC/KvB.
-	cmd := btcjson.NewTestMempoolAcceptCmd(
-		[]string{txHex1, txHex2, txHex3}, 0.1,
-	)
-
-	// Call the method handler and assert the expected results are
-	// returned.
+	// Call the method handler with a mock request for each tx, since
+	// multiple txns are tested as a package, and assert the expected
+	// results are returned.
 	closeChan := make(chan struct{})
-	results, err := handleTestMempoolAccept(s, cmd, closeChan)
-	require.NoError(err)
-	require.Equal(expectedResults, results)
+	for i, txHex := range []string{txHex1, txHex2, txHex3} {
+		// Create a mock request with default max fee rate of 0.1
+		// BTC/KvB.
+		cmd := btcjson.NewTestMempoolAcceptCmd([]string{txHex}, 0.1)
+
+		results, err := handleTestMempoolAccept(s, cmd, closeChan)
+		require.NoError(err)
+		require.Equal(expectedResults[i:i+1], results)
+	}
 
 	// Assert the mocked method is called as expected.
 	mm.AssertExpectations(t)
@@ -495,3 +510,613 @@ func TestGetTxSpendingPrevOut(t *testing.T) {
 	require.NoError(err)
 	require.Equal(expectedResults, results)
 }
+
+// TestHandleSubmitPackage checks that handleSubmitPackage submits the package
+// to the mempool and reports the outcome of each transaction.
+func TestHandleSubmitPackage(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// Create a mock mempool.
+	mm := &mempool.MockTxMempool{}
+	defer mm.AssertExpectations(t)
+
+	// Create a testing server with the mock mempool.
+	s := &rpcServer{cfg: rpcserverConfig{
+		TxMemPool: mm,
+	}}
+
+	// Decode the hex so we can assert the mock mempool is called with it.
+	tx1 := decodeTxHex(t, txHex1)
+	tx2 := decodeTxHex(t, txHex2)
+	msgTxns := []*wire.MsgTx{tx1.MsgTx(), tx2.MsgTx()}
+
+	// The fee rate check is disabled so the inputs of the transactions
+	// don't have to be looked up.
+	noMaxFeeRate := btcjson.Float64(0)
+
+	// A package which doesn't form a valid package is rejected with an
+	// error.
+	topologyErr := mempool.RuleError{Err: mempool.TxRuleError{
+		RejectCode:  wire.RejectInvalid,
+		Description: "package is not a child with its parents",
+	}}
+	mm.On("ProcessPackage", msgTxns).Return(nil, topologyErr).Once()
+
+	cmd := btcjson.NewSubmitPackageCmd(
+		[]string{txHex1, txHex2}, noMaxFeeRate, nil,
+	)
+	closeChan := make(chan struct{})
+	result, err := handleSubmitPackage(s, cmd, closeChan)
+	require.Error(err)
+	require.Nil(result)
+	rpcErr, ok := err.(*btcjson.RPCError)
+	require.True(ok)
+	require.Equal(btcjson.ErrRPCInvalidParameter, rpcErr.Code)
+
+	// Otherwise the outcome of each transaction is returned.  The first
+	// transaction was already in the pool while the second one was
+	// rejected.
+	dummyErr := errors.New("dummy error")
+	const feeSats = btcutil.Amount(1000)
+	mm.On("ProcessPackage", msgTxns).Return(&mempool.PackageAcceptResult{
+		TxResults: []*mempool.PackageTxResult{{
+			Tx:                tx1,
+			AlreadyInPool:     true,
+			Fee:               feeSats,
+			VSize:             100,
+			EffectiveFeeRate:  10000,
+			EffectiveIncludes: []*btcutil.Tx{tx1},
+		}, {
+			Tx:    tx2,
+			VSize: 100,
+			Err:   dummyErr,
+		}},
+		Err: dummyErr,
+	}, nil).Once()
+	mm.On("FetchTransaction", tx1.Hash()).Return(tx1, nil).Once()
+
+	expectedResult := &btcjson.SubmitPackageResult{
+		PackageMsg: "transaction failed",
+		TxResults: map[string]*btcjson.SubmitPackageTxResult{
+			tx1.WitnessHash().String(): {
+				Txid:  tx1.Hash().String(),
+				Vsize: 100,
+				Fees: &btcjson.TestMempoolAcceptFees{
+					Base:             feeSats.ToBTC(),
+					EffectiveFeeRate: 0.0001,
+					EffectiveIncludes: []string{
+						tx1.WitnessHash().String(),
+					},
+				},
+			},
+			tx2.WitnessHash().String(): {
+				Txid:  tx2.Hash().String(),
+				Error: dummyErr.Error(),
+			},
+		},
+		ReplacedTransactions: []string{},
+	}
+
+	result, err = handleSubmitPackage(s, cmd, closeChan)
+	require.NoError(err)
+	require.Equal(expectedResult, result)
+
+	// Packages with unspendable outputs above the maximum burn amount are
+	// rejected before they are submitted.
+	burnTx := tx1.MsgTx().Copy()
+	burnTx.TxOut[0].PkScript = []byte{txscript.OP_RETURN}
+	var buf bytes.Buffer
+	require.NoError(burnTx.Serialize(&buf))
+
+	cmd = btcjson.NewSubmitPackageCmd(
+		[]string{hex.EncodeToString(buf.Bytes())}, noMaxFeeRate, nil,
+	)
+	result, err = handleSubmitPackage(s, cmd, closeChan)
+	require.Error(err)
+	require.Nil(result)
+	rpcErr, ok = err.(*btcjson.RPCError)
+	require.True(ok)
+	require.Equal(btcjson.ErrRPCTxError, rpcErr.Code)
+}
+
+// TestHandleTestMempoolAcceptReplacement checks that the details of a rejected
+// replacement are returned by handleTestMempoolAccept.
+func TestHandleTestMempoolAcceptReplacement(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// Create a mock mempool.
+	mm := &mempool.MockTxMempool{}
+	defer mm.AssertExpectations(t)
+
+	// Create a testing server with the mock mempool.
+	s := &rpcServer{cfg: rpcserverConfig{
+		TxMemPool: mm,
+	}}
+
+	tx := decodeTxHex(t, txHex1)
+	conflict := chainhash.Hash{1}
+	rejectErr := mempool.RuleError{Err: mempool.TxRuleError{
+		RejectCode:  wire.RejectInsufficientFee,
+		Description: "insufficient absolute fee",
+		Replacement: &mempool.ReplacementRejection{
+			Rule:      mempool.RBFInsufficientRelayFee,
+			Conflicts: []chainhash.Hash{conflict},
+			Fee:       1000,
+			FeeRate:   5000,
+			FeeDelta:  200,
+		},
+	}}
+	mm.On("CheckMempoolAcceptance", tx).Return(nil, rejectErr).Once()
+
+	expectedResults := []*btcjson.TestMempoolAcceptResult{{
+		Txid:         tx.Hash().String(),
+		Wtxid:        tx.WitnessHash().String(),
+		RejectReason: rejectErr.Error(),
+		Replacement: &btcjson.TestMempoolAcceptReplacement{
+			Rule:       "insufficient-relay-fee",
+			BIP125Rule: 4,
+			Conflicts:  []string{conflict.String()},
+			Fee:        0.00001,
+			FeeRate:    0.00005,
+			FeeDelta:   0.000002,
+		},
+	}}
+
+	cmd := btcjson.NewTestMempoolAcceptCmd([]string{txHex1}, 0.1)
+	closeChan := make(chan struct{})
+	results, err := handleTestMempoolAccept(s, cmd, closeChan)
+	require.NoError(err)
+	require.Equal(expectedResults, results)
+}
+
+// TestHandleTestMempoolAcceptPackage checks that multiple transactions are
+// tested as a package and the transactions following the first one which is
+// not allowed are not evaluated.
+func TestHandleTestMempoolAcceptPackage(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// Create a mock mempool.
+	mm := &mempool.MockTxMempool{}
+	defer mm.AssertExpectations(t)
+
+	// Create a testing server with the mock mempool.
+	s := &rpcServer{cfg: rpcserverConfig{
+		TxMemPool: mm,
+	}}
+
+	tx1 := decodeTxHex(t, txHex1)
+	tx2 := decodeTxHex(t, txHex2)
+	tx3 := decodeTxHex(t, txHex3)
+	txns := []*btcutil.Tx{tx1, tx2, tx3}
+	cmd := btcjson.NewTestMempoolAcceptCmd(
+		[]string{txHex1, txHex2, txHex3}, 0.1,
+	)
+	closeChan := make(chan struct{})
+
+	// None of the transactions are evaluated when they don't form a
+	// consistent package.
+	topologyErr := mempool.RuleError{Err: mempool.TxRuleError{
+		RejectCode:  wire.RejectInvalid,
+		Description: "package is not sorted",
+	}}
+	mm.On("CheckPackageAcceptance", txns).Return(nil, topologyErr).Once()
+
+	expectedResults := make([]*btcjson.TestMempoolAcceptResult, 0, 3)
+	for _, tx := range txns {
+		expectedResults = append(expectedResults,
+			&btcjson.TestMempoolAcceptResult{
+				Txid:         tx.Hash().String(),
+				Wtxid:        tx.WitnessHash().String(),
+				PackageError: topologyErr.Error(),
+			})
+	}
+	results, err := handleTestMempoolAccept(s, cmd, closeChan)
+	require.NoError(err)
+	require.Equal(expectedResults, results)
+
+	// Otherwise the results of the evaluated transactions are returned.
+	// The first transaction is allowed, the second one misses inputs and
+	// the third one wasn't evaluated.
+	const feeSats = btcutil.Amount(1000)
+	mm.On("CheckPackageAcceptance", txns).Return(&mempool.PackageAcceptResult{
+		TxResults: []*mempool.PackageTxResult{{
+			Tx:    tx1,
+			Fee:   feeSats,
+			VSize: 100,
+		}, {
+			Tx:             tx2,
+			VSize:          100,
+			MissingParents: []*chainhash.Hash{{1}},
+			Err:            errors.New("missing parent"),
+		}},
+	}, nil).Once()
+
+	expectedResults = []*btcjson.TestMempoolAcceptResult{{
+		Txid:    tx1.Hash().String(),
+		Wtxid:   tx1.WitnessHash().String(),
+		Allowed: true,
+		Vsize:   100,
+		Fees: &btcjson.TestMempoolAcceptFees{
+			Base:             feeSats.ToBTC(),
+			EffectiveFeeRate: feeSats.ToBTC() * 1e3 / 100,
+		},
+	}, {
+		Txid:         tx2.Hash().String(),
+		Wtxid:        tx2.WitnessHash().String(),
+		RejectReason: "missing-inputs",
+	}, {
+		Txid:  tx3.Hash().String(),
+		Wtxid: tx3.WitnessHash().String(),
+	}}
+	results, err = handleTestMempoolAccept(s, cmd, closeChan)
+	require.NoError(err)
+	require.Equal(expectedResults, results)
+
+	// Arrays which exceed the package limits are rejected.
+	rawTxns := make([]string, mempool.MaxPackageCount+1)
+	for i := range rawTxns {
+		rawTxns[i] = txHex1
+	}
+	cmd = btcjson.NewTestMempoolAcceptCmd(rawTxns, 0.1)
+	_, err = handleTestMempoolAccept(s, cmd, closeChan)
+	rpcErr, ok := err.(*btcjson.RPCError)
+	require.True(ok)
+	require.Equal(btcjson.ErrRPCInvalidParameter, rpcErr.Code)
+}
+
+// TestParseScanObject ensures the scan objects of scantxoutset are expanded to
+// the scripts they describe and invalid ones are rejected.
+func TestParseScanObject(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x07}, 32), params)
+	require.NoError(t, err)
+	xpub, err := master.Neuter()
+	require.NoError(t, err)
+	ranged := "pkh(" + xpub.String() + "/0/*)"
+
+	withChecksum := func(desc string) string {
+		desc, err := descriptor.AddChecksum(desc)
+		require.NoError(t, err)
+		return desc
+	}
+
+	tests := []struct {
+		name    string
+		obj     btcjson.ScanObject
+		scripts int
+		script  string
+		desc    string
+		valid   bool
+	}{{
+		name:    "p2pkh address",
+		obj:     btcjson.ScanObject{Desc: "addr(Sjuh1X5kExEJUqteqM8eNUzocDjL5sWPgZ)"},
+		scripts: 1,
+		script:  "76a914f812b27b32d844d4eac915086d363f7eca4a4c1588ac",
+		desc:    withChecksum("addr(Sjuh1X5kExEJUqteqM8eNUzocDjL5sWPgZ)"),
+		valid:   true,
+	}, {
+		name:    "raw script is normalized",
+		obj:     btcjson.ScanObject{Desc: "raw(6A0401020304)"},
+		scripts: 1,
+		script:  "6a0401020304",
+		desc:    withChecksum("raw(6a0401020304)"),
+		valid:   true,
+	}, {
+		name:    "ranged descriptor with default range",
+		obj:     btcjson.ScanObject{Desc: ranged},
+		scripts: defaultScanRangeEnd + 1,
+		valid:   true,
+	}, {
+		name: "ranged descriptor with range",
+		obj: btcjson.ScanObject{
+			Desc:  ranged,
+			Range: &btcjson.DescriptorRange{Value: []int{2, 4}},
+		},
+		scripts: 3,
+		valid:   true,
+	}, {
+		name: "address of another network",
+		obj:  btcjson.ScanObject{Desc: "addr(1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2)"},
+	}, {
+		name: "invalid key",
+		obj:  btcjson.ScanObject{Desc: "pkh(00)"},
+	}, {
+		name: "malformed",
+		obj:  btcjson.ScanObject{Desc: "raw(51"},
+	}, {
+		name: "checksum mismatch",
+		obj:  btcjson.ScanObject{Desc: "raw(51)#00000000"},
+	}, {
+		name: "range of an un-ranged descriptor",
+		obj: btcjson.ScanObject{
+			Desc:  "raw(51)",
+			Range: &btcjson.DescriptorRange{Value: 10},
+		},
+	}, {
+		name: "range too large",
+		obj: btcjson.ScanObject{
+			Desc:  ranged,
+			Range: &btcjson.DescriptorRange{Value: maxDescriptorRangeSize},
+		},
+	}}
+
+	for _, test := range tests {
+		scripts, err := parseScanObject(&test.obj, params)
+		if !test.valid {
+			require.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Len(t, scripts, test.scripts, test.name)
+		if test.script != "" {
+			script, err := hex.DecodeString(test.script)
+			require.NoError(t, err)
+			require.Equal(t, test.desc, scripts[string(script)], test.name)
+		}
+	}
+}
+
+// TestParseDescriptorRange ensures descriptor ranges are converted to their
+// first and last index and invalid ones are rejected.
+func TestParseDescriptorRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value interface{}
+		begin uint32
+		end   uint32
+		valid bool
+	}{
+		{value: 0, begin: 0, end: 0, valid: true},
+		{value: 10, begin: 0, end: 10, valid: true},
+		{value: []int{5, 7}, begin: 5, end: 7, valid: true},
+		{value: []int{3, 3}, begin: 3, end: 3, valid: true},
+		{value: []int{1000, 1000 + maxDescriptorRangeSize - 1}, begin: 1000,
+			end: 1000 + maxDescriptorRangeSize - 1, valid: true},
+		{value: -1},
+		{value: []int{-1, 5}},
+		{value: []int{7, 5}},
+		{value: []int{0, maxDescriptorRangeSize}},
+		{value: math.MaxInt32},
+		{value: "5"},
+	}
+	for _, test := range tests {
+		r := &btcjson.DescriptorRange{Value: test.value}
+		begin, end, err := parseDescriptorRange(r)
+		if !test.valid {
+			require.Error(t, err, "%v", test.value)
+			continue
+		}
+		require.NoError(t, err, "%v", test.value)
+		require.Equal(t, test.begin, begin, "%v", test.value)
+		require.Equal(t, test.end, end, "%v", test.value)
+	}
+}
+
+// TestUtxoScan ensures only one scan of the utxo set runs at a time and that
+// its progress is reported until it finishes.
+func TestUtxoScan(t *testing.T) {
+	t.Parallel()
+
+	var u utxoScan
+	_, ok := u.progress()
+	require.False(t, ok)
+	require.False(t, u.stop())
+
+	abort, ok := u.start(200)
+	require.True(t, ok)
+	_, ok = u.start(200)
+	require.False(t, ok, "second scan started")
+
+	u.setScanned(50)
+	progress, ok := u.progress()
+	require.True(t, ok)
+	require.Equal(t, 25.0, progress)
+
+	require.True(t, u.stop())
+	require.True(t, u.stop(), "stopping twice")
+	select {
+	case <-abort:
+	default:
+		t.Fatal("abort channel not closed")
+	}
+
+	u.finish()
+	_, ok = u.progress()
+	require.False(t, ok)
+	_, ok = u.start(0)
+	require.True(t, ok)
+}
+
+// TestFilterBlockStats ensures getblockstats results are reduced to the
+// selected statistics.
+func TestFilterBlockStats(t *testing.T) {
+	t.Parallel()
+
+	_, ok := blockStatsFields["utxo_size_inc_actual"]
+	require.True(t, ok)
+	require.Len(t, blockStatsFields, 31)
+
+	result := &btcjson.GetBlockStatsResult{
+		Hash:               "00",
+		Height:             7,
+		TotalFee:           1000,
+		FeeratePercentiles: []int64{1, 2, 3, 4, 5},
+	}
+	selected := map[string]struct{}{
+		"height":              {},
+		"totalfee":            {},
+		"feerate_percentiles": {},
+	}
+	filtered, err := filterBlockStats(result, selected)
+	require.NoError(t, err)
+	require.Len(t, filtered, 3)
+	require.JSONEq(t, `7`, string(filtered["height"]))
+	require.JSONEq(t, `1000`, string(filtered["totalfee"]))
+	require.JSONEq(t, `[1,2,3,4,5]`, string(filtered["feerate_percentiles"]))
+}
+
+// TestChainTxStatsBlockCount ensures the window of getchaintxstats defaults to
+// about a month of blocks and that invalid window sizes are rejected.
+func TestChainTxStatsBlockCount(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	int32p := func(v int32) *int32 { return &v }
+	tests := []struct {
+		name    string
+		nBlocks *int32
+		height  int32
+		want    int32
+		wantErr bool
+	}{
+		{"default", nil, 100000, 4320, false},
+		{"default short chain", nil, 100, 99, false},
+		{"default genesis", nil, 0, 0, false},
+		{"explicit", int32p(10), 100, 10, false},
+		{"zero", int32p(0), 0, 0, false},
+		{"whole chain", int32p(99), 100, 99, false},
+		{"too large", int32p(100), 100, 0, true},
+		{"negative", int32p(-1), 100, 0, true},
+	}
+	for _, test := range tests {
+		got, err := chainTxStatsBlockCount(test.nBlocks, test.height, params)
+		if test.wantErr {
+			require.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.want, got, test.name)
+	}
+}
+
+// TestGBTDeployments ensures the BIP0009 details of block templates follow
+// the deployment states and that clients are required to support segwit once
+// it is active.
+func TestGBTDeployments(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.RegressionNetParams
+	states := func(states map[uint32]blockchain.ThresholdState) func(uint32) (blockchain.ThresholdState, error) {
+		return func(id uint32) (blockchain.ThresholdState, error) {
+			if state, ok := states[id]; ok {
+				return state, nil
+			}
+			return blockchain.ThresholdDefined, nil
+		}
+	}
+
+	// Deployments being signalled for are available, and segwit is only
+	// signalled for when the client supports it.
+	started := states(map[uint32]blockchain.ThresholdState{
+		chaincfg.DeploymentCSV:     blockchain.ThresholdActive,
+		chaincfg.DeploymentSegwit:  blockchain.ThresholdStarted,
+		chaincfg.DeploymentTaproot: blockchain.ThresholdLockedIn,
+	})
+	deployments, err := newGBTDeployments(params, nil, started)
+	require.NoError(t, err)
+	require.Equal(t, []string{"csv"}, deployments.rules)
+	require.Equal(t, map[string]int32{"segwit": 1, "taproot": 2},
+		deployments.available)
+	require.Equal(t, int32(1<<1), deployments.clearMask)
+
+	deployments, err = newGBTDeployments(params, []string{"segwit"}, started)
+	require.NoError(t, err)
+	require.Zero(t, deployments.clearMask)
+
+	// Active segwit must be explicitly supported by the client.
+	active := states(map[uint32]blockchain.ThresholdState{
+		chaincfg.DeploymentCSV:     blockchain.ThresholdActive,
+		chaincfg.DeploymentSegwit:  blockchain.ThresholdActive,
+		chaincfg.DeploymentTaproot: blockchain.ThresholdActive,
+	})
+	_, err = newGBTDeployments(params, nil, active)
+	var rpcErr *btcjson.RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, btcjson.ErrRPCInvalidParameter, rpcErr.Code)
+
+	deployments, err = newGBTDeployments(params, []string{"segwit"}, active)
+	require.NoError(t, err)
+	require.Equal(t, []string{"csv", "!segwit", "taproot"},
+		deployments.rules)
+	require.Empty(t, deployments.available)
+	require.Zero(t, deployments.clearMask)
+}
+
+// TestGBTWitnessCommitment ensures the default witness commitment of a block
+// template without witness transactions matches the one of Bitcoin Core.
+func TestGBTWitnessCommitment(t *testing.T) {
+	t.Parallel()
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(50e8, []byte{0x51}))
+	template := &mining.BlockTemplate{
+		Block: &wire.MsgBlock{Transactions: []*wire.MsgTx{coinbase}},
+	}
+
+	const want = "6a24aa21a9ede2f61c3f71d1defd3fa999dfa36953755c690689" +
+		"799962b48bebd836974e8cf9"
+	require.Equal(t, want, hex.EncodeToString(gbtWitnessCommitment(template)))
+
+	// The commitment of the template is used when it has one.
+	template.WitnessCommitment = bytes.Repeat([]byte{0x01}, 32)
+	require.Equal(t, "6a24aa21a9ed"+strings.Repeat("01", 32),
+		hex.EncodeToString(gbtWitnessCommitment(template)))
+}
+
+// TestJSONRPCReadConnectionReuse ensures JSON-RPC responses are written without
+// closing the connection, so clients can reuse it for further requests and
+// multiplex requests over HTTP/2.
+func TestJSONRPCReadConnectionReuse(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &config{}
+
+	s := &rpcServer{limiter: newRPCRateLimiter(nil)}
+	user := newRPCUser("user", "pass", true, nil)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s.jsonRPCRead(w, r, user)
+		}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	const body = `{"jsonrpc":"1.0","id":1,"method":"nosuchmethod","params":[]}`
+	client := srv.Client()
+	for i := 0; i < 2; i++ {
+		var reused bool
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = info.Reused
+			},
+		}
+		req, err := http.NewRequest("POST", srv.URL, strings.NewReader(body))
+		require.NoError(t, err)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		reply, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, 2, resp.ProtoMajor)
+		require.Contains(t, string(reply), "Method not found")
+		require.True(t, strings.HasSuffix(string(reply), "\n"))
+		require.Equal(t, i > 0, reused, "request %d", i)
+	}
+}
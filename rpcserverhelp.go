@@ -29,11 +29,76 @@ var helpDescsEnUS = map[string]string{
 	"debuglevel--result0":    "The string 'Done.'",
 	"debuglevel--result1":    "The list of subsystems",
 
+	// CollectProfileCmd help.
+	"collectprofile--synopsis": "Writes a profile of the node to the profiles directory of the data directory.\n" +
+		"CPU profiles and execution traces are captured for the requested duration and end early when the client disconnects.\n" +
+		"The other types are snapshots written immediately.",
+	"collectprofile-type":    "The type of the profile (allocs, block, cpu, goroutine, heap, mutex, threadcreate or trace)",
+	"collectprofile-seconds": "The duration of CPU profiles and execution traces in seconds, at most 600",
+
+	// CollectProfileResult help.
+	"collectprofileresult-type":    "The type of the profile",
+	"collectprofileresult-file":    "The path of the file the profile was written to",
+	"collectprofileresult-size":    "The size of the file in bytes",
+	"collectprofileresult-seconds": "The duration the profile was captured for, omitted for snapshots",
+
+	// ReloadConfigCmd help.
+	"reloadconfig--synopsis": "Reads the config file and the command line options again and applies the options which can be changed without restarting.\n" +
+		"These are banduration, banthreshold, debuglevel, maxpeers, minrelaytxfee, rpcmaxclients, rpcmaxconcurrentreqs and rpcmaxwebsockets.\n" +
+		"Nothing is applied when any of them is invalid, and changes to the other options are ignored until the next restart.\n" +
+		"Sending SIGHUP to the process reloads the configuration as well.",
+	"reloadconfig--result0": "The options whose values changed",
+
+	// ReloadConfigChange help.
+	"reloadconfigchange-option": "The name of the option",
+	"reloadconfigchange-old":    "The previous value of the option",
+	"reloadconfigchange-new":    "The new value of the option",
+
+	// DumpStateCmd help.
+	"dumpstate--synopsis": "Writes a dump of the state of the node to the profiles directory of the data directory for diagnosing a stuck node.\n" +
+		"The dump contains what the sync manager is doing, the blocks and transactions requested from the peers, the holder of the database write lock, the cache statistics and the stacks of all goroutines.\n" +
+		"Sending SIGUSR1 to the process writes the same dump.",
+
+	// DumpStateResult help.
+	"dumpstateresult-file": "The path of the file the dump was written to",
+	"dumpstateresult-size": "The size of the file in bytes",
+
 	// AddNodeCmd help.
 	"addnode--synopsis": "Attempts to add or remove a persistent peer.",
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// AnalyzePsbtCmd help.
+	"analyzepsbt--synopsis": "Analyzes the provided base64-encoded partially signed transaction (PSBT) and returns which data its inputs are missing, the role which has to process it next and its estimated size and fee rate.",
+	"analyzepsbt-psbt":      "The base64-encoded PSBT",
+
+	// AnalyzePsbtResult help.
+	"analyzepsbtresult-inputs":            "The analysis of the inputs",
+	"analyzepsbtresult-estimated_vsize":   "The estimated virtual size of the transaction once it is signed (only if the outputs spent by all inputs are known)",
+	"analyzepsbtresult-estimated_feerate": "The estimated fee rate of the transaction once it is signed in BTC/kvB (only if the outputs spent by all inputs are known)",
+	"analyzepsbtresult-fee":               "The fee paid by the transaction in BTC (only if the outputs spent by all inputs are known)",
+	"analyzepsbtresult-next":              "The role which has to process the PSBT next (creator, updater, signer, finalizer or extractor)",
+	"analyzepsbtresult-error":             "The reason the PSBT is invalid",
+
+	// AnalyzePsbtInputResult help.
+	"analyzepsbtinputresult-has_utxo": "Whether the output spent by the input is known",
+	"analyzepsbtinputresult-is_final": "Whether the input is finalized",
+	"analyzepsbtinputresult-missing":  "The data the input is missing before it can be finalized",
+	"analyzepsbtinputresult-next":     "The role which has to process the input next",
+
+	// AnalyzePsbtMissingResult help.
+	"analyzepsbtmissingresult-pubkeys":       "The hash160 of the public keys whose public keys are missing",
+	"analyzepsbtmissingresult-signatures":    "The hash160 of the public keys whose signatures are missing",
+	"analyzepsbtmissingresult-redeemscript":  "The hash160 of the missing redeem script",
+	"analyzepsbtmissingresult-witnessscript": "The sha256 of the missing witness script",
+
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans and discouragements of peers.",
+
+	// ConfirmReorgCmd help.
+	"confirmreorg--synopsis": "Confirms the pending reorganization that is paused because it would disconnect more blocks than the maximum reorganization depth (see getpendingreorg).",
+	"confirmreorg-blockhash": "The hash of the tip of the chain to reorganize to, which must be the newtiphash of the pending reorganization",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
@@ -80,6 +145,13 @@ var helpDescsEnUS = map[string]string{
 	"vin-scriptSig":   "The signature script used to redeem the origin transaction as a JSON object (non-coinbase txns only)",
 	"vin-txinwitness": "The witness used to redeem the input encoded as a string array of its items",
 	"vin-sequence":    "The script sequence number",
+	"vin-prevout":     "The output spent by the input (getblock with verbosity 3 only)",
+
+	// PrevOutResult help.
+	"prevoutresult-generated":    "Whether the output was created by a coinbase transaction",
+	"prevoutresult-height":       "The height of the block containing the transaction of the output",
+	"prevoutresult-value":        "The value of the output in BTC",
+	"prevoutresult-scriptPubKey": "The public key script of the output as a JSON object",
 
 	// ScriptPubKeyResult help.
 	"scriptpubkeyresult-asm":       "Disassembly of the script",
@@ -105,6 +177,93 @@ var helpDescsEnUS = map[string]string{
 	"decoderawtransaction--synopsis": "Returns a JSON object representing the provided serialized, hex-encoded transaction.",
 	"decoderawtransaction-hextx":     "Serialized, hex-encoded transaction",
 
+	// DecodePsbtCmd help.
+	"decodepsbt--synopsis": "Returns a JSON object representing the provided base64-encoded partially signed transaction (PSBT).",
+	"decodepsbt-psbt":      "The base64-encoded PSBT",
+
+	// DecodePsbtResult help.
+	"decodepsbtresult-tx":             "The unsigned transaction of the PSBT",
+	"decodepsbtresult-psbt_version":   "The version of the PSBT format",
+	"decodepsbtresult-unknown":        "The unknown global fields",
+	"decodepsbtresult-unknown--key":   "key",
+	"decodepsbtresult-unknown--value": "value",
+	"decodepsbtresult-unknown--desc":  "The unknown global fields as hex-encoded keys mapped to hex-encoded values",
+	"decodepsbtresult-inputs":         "The inputs of the PSBT",
+	"decodepsbtresult-outputs":        "The outputs of the PSBT",
+	"decodepsbtresult-fee":            "The fee paid by the transaction in BTC (only if the outputs spent by all inputs are known)",
+
+	// DecodePsbtInputResult help.
+	"decodepsbtinputresult-non_witness_utxo":          "The transaction of the output spent by the input",
+	"decodepsbtinputresult-witness_utxo":              "The output spent by the input",
+	"decodepsbtinputresult-partial_signatures":        "The partial signatures of the input",
+	"decodepsbtinputresult-partial_signatures--key":   "pubkey",
+	"decodepsbtinputresult-partial_signatures--value": "signature",
+	"decodepsbtinputresult-partial_signatures--desc":  "The hex-encoded signatures mapped to the hex-encoded public keys they were made with",
+	"decodepsbtinputresult-sighash":                   "The sighash type to sign the input with",
+	"decodepsbtinputresult-redeem_script":             "The redeem script of the input",
+	"decodepsbtinputresult-witness_script":            "The witness script of the input",
+	"decodepsbtinputresult-bip32_derivs":              "The BIP 32 derivations of the public keys of the input",
+	"decodepsbtinputresult-final_scriptSig":           "The final signature script of the input",
+	"decodepsbtinputresult-final_scriptwitness":       "The hex-encoded items of the final witness of the input",
+	"decodepsbtinputresult-taproot_key_path_sig":      "The hex-encoded signature for a taproot key path spend",
+	"decodepsbtinputresult-taproot_script_path_sigs":  "The signatures for taproot script path spends",
+	"decodepsbtinputresult-taproot_scripts":           "The taproot leaf scripts along with their control blocks",
+	"decodepsbtinputresult-taproot_bip32_derivs":      "The BIP 32 derivations of the x-only public keys of the input",
+	"decodepsbtinputresult-taproot_internal_key":      "The hex-encoded x-only taproot internal key",
+	"decodepsbtinputresult-taproot_merkle_root":       "The hex-encoded taproot merkle root",
+	"decodepsbtinputresult-unknown":                   "The unknown input fields",
+	"decodepsbtinputresult-unknown--key":              "key",
+	"decodepsbtinputresult-unknown--value":            "value",
+	"decodepsbtinputresult-unknown--desc":             "The unknown input fields as hex-encoded keys mapped to hex-encoded values",
+
+	// DecodePsbtOutputResult help.
+	"decodepsbtoutputresult-redeem_script":        "The redeem script of the output",
+	"decodepsbtoutputresult-witness_script":       "The witness script of the output",
+	"decodepsbtoutputresult-bip32_derivs":         "The BIP 32 derivations of the public keys of the output",
+	"decodepsbtoutputresult-taproot_internal_key": "The hex-encoded x-only taproot internal key",
+	"decodepsbtoutputresult-taproot_tree":         "The leaves of the taproot tree in depth-first order",
+	"decodepsbtoutputresult-taproot_bip32_derivs": "The BIP 32 derivations of the x-only public keys of the output",
+	"decodepsbtoutputresult-unknown":              "The unknown output fields",
+	"decodepsbtoutputresult-unknown--key":         "key",
+	"decodepsbtoutputresult-unknown--value":       "value",
+	"decodepsbtoutputresult-unknown--desc":        "The unknown output fields as hex-encoded keys mapped to hex-encoded values",
+
+	// PsbtScriptResult help.
+	"psbtscriptresult-asm":  "Disassembly of the script",
+	"psbtscriptresult-hex":  "Hex-encoded bytes of the script",
+	"psbtscriptresult-type": "The type of the script (e.g. 'pubkeyhash')",
+
+	// PsbtWitnessUtxoResult help.
+	"psbtwitnessutxoresult-amount":       "The value of the output in BTC",
+	"psbtwitnessutxoresult-scriptPubKey": "The public key script of the output",
+
+	// PsbtBip32DerivResult help.
+	"psbtbip32derivresult-pubkey":             "The hex-encoded public key",
+	"psbtbip32derivresult-master_fingerprint": "The hex-encoded fingerprint of the master key",
+	"psbtbip32derivresult-path":               "The derivation path of the public key",
+
+	// PsbtTaprootBip32DerivResult help.
+	"psbttaprootbip32derivresult-pubkey":             "The hex-encoded x-only public key",
+	"psbttaprootbip32derivresult-master_fingerprint": "The hex-encoded fingerprint of the master key",
+	"psbttaprootbip32derivresult-path":               "The derivation path of the public key",
+	"psbttaprootbip32derivresult-leaf_hashes":        "The hashes of the leaves the public key is used in",
+
+	// PsbtTaprootScriptSigResult help.
+	"psbttaprootscriptsigresult-pubkey":    "The hex-encoded x-only public key the signature was made with",
+	"psbttaprootscriptsigresult-leaf_hash": "The hash of the leaf the signature is for",
+	"psbttaprootscriptsigresult-sig":       "The hex-encoded signature",
+
+	// PsbtTaprootScriptResult help.
+	"psbttaprootscriptresult-script":         "The hex-encoded leaf script",
+	"psbttaprootscriptresult-leaf_ver":       "The leaf version of the script",
+	"psbttaprootscriptresult-control_blocks": "The hex-encoded control blocks proving the inclusion of the script",
+
+	// PsbtTaprootLeafResult help.
+	"psbttaprootleafresult-depth":    "The depth of the leaf in the tree",
+	"psbttaprootleafresult-leaf_ver": "The leaf version of the script",
+	"psbttaprootleafresult-script":   "The hex-encoded leaf script",
+
+	// DecodeScriptResult help.
 	// DecodeScriptResult help.
 	"decodescriptresult-asm":       "Disassembly of the script",
 	"decodescriptresult-reqSigs":   "(DEPRECATED) The number of required signatures",
@@ -117,6 +276,37 @@ var helpDescsEnUS = map[string]string{
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DebugScriptCmd help.
+	"debugscript--synopsis": "Executes a signature script and witness against a public key script with the standard script flags and returns the trace of the execution, for debugging spends which fail to validate.\n" +
+		"The scripts spend a dummy transaction unless a transaction is passed, in which case the signatures are checked against the input of the transaction.",
+	"debugscript-scriptpubkey": "The hex-encoded public key script of the output being spent",
+	"debugscript-scriptsig":    "The hex-encoded signature script, which replaces the one of the input of the passed transaction when not empty",
+	"debugscript-witness":      "The hex-encoded witness items, which replace the ones of the input of the passed transaction when not empty",
+	"debugscript-amount":       "The amount of the output being spent in BTC, which segwit signatures commit to",
+	"debugscript-hextx":        "The serialized, hex-encoded transaction spending the output",
+	"debugscript-inputindex":   "The index of the input of the passed transaction spending the output",
+
+	// DebugScriptResult help.
+	"debugscriptresult-valid":     "Whether the scripts executed successfully",
+	"debugscriptresult-error":     "The reason the execution failed",
+	"debugscriptresult-errorcode": "The code of the script error the execution failed with",
+	"debugscriptresult-flags":     "The script flags the scripts were executed with",
+	"debugscriptresult-steps":     "The state of the script engine before each executed opcode, followed by the final state of the execution",
+
+	// DebugScriptStep help.
+	"debugscriptstep-scriptindex": "The index of the script being executed: 0 for the signature script, 1 for the public key script, followed by the redeem script or the witness script",
+	"debugscriptstep-opcodeindex": "The index of the opcode within its script",
+	"debugscriptstep-opcode":      "The disassembly of the opcode executed next, empty for the final state",
+	"debugscriptstep-executing":   "Whether the current conditional branch is executing",
+	"debugscriptstep-stack":       "The hex-encoded elements of the data stack, from the bottom to the top",
+	"debugscriptstep-altstack":    "The hex-encoded elements of the alt stack, from the bottom to the top",
+
+	// DeriveAddressesCmd help.
+	"deriveaddresses--synopsis":  "Derives the addresses of the output scripts of an output descriptor.",
+	"deriveaddresses-descriptor": "The output descriptor including its checksum",
+	"deriveaddresses-range":      "The end or the [begin,end] range of the derivation indexes for a ranged descriptor",
+	"deriveaddresses--result0":   "The derived addresses",
+
 	// EstimateFeeCmd help.
 	"estimatefee--synopsis": "Estimate the fee per kilobyte in satoshis " +
 		"required for a transaction to be mined before a certain number of " +
@@ -126,12 +316,74 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee rate in BTC per kilovirtual byte " +
+		"required for a transaction to begin confirmation within a number of blocks.",
+	"estimatesmartfee-conftarget":   "The confirmation target in blocks (1 - 1008)",
+	"estimatesmartfee-estimatemode": "The fee estimate mode, either ECONOMICAL or CONSERVATIVE, which is less responsive to short-term drops of the fee rates",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "Estimated fee rate in BTC/kvB, omitted when no estimate is available",
+	"estimatesmartfeeresult-errors":  "Errors encountered during processing",
+	"estimatesmartfeeresult-blocks":  "The block number the estimate is for, which may be lower than the requested target when not enough blocks were observed",
+
+	// FundRawTransactionCmd help.
+	"fundrawtransaction--synopsis": "Adds inputs spending the outputs tracked by the watch-only wallet to a transaction to cover its outputs and fee, along with a change output when needed.\n" +
+		"Outputs are selected with the branch and bound algorithm, falling back to the knapsack algorithm.  Change is sent to the next address of the active internal descriptor unless a change address is given.\n" +
+		"The added inputs are not signed, so the transaction must be signed by an external signer.",
+	"fundrawtransaction-hextx":     "The hex-encoded raw transaction",
+	"fundrawtransaction-options":   "The options for funding the transaction",
+	"fundrawtransaction-iswitness": "Whether the transaction is serialized with witness data, tried both ways when omitted",
+
+	// FundRawTransactionOpts help.
+	"fundrawtransactionopts-changeAddress":          "The address to send the change to",
+	"fundrawtransactionopts-changePosition":         "The index of the change output, random when omitted",
+	"fundrawtransactionopts-change_type":            "The type of the change output when no change address is given: legacy, p2sh-segwit, bech32 or bech32m",
+	"fundrawtransactionopts-includeWatching":        "Ignored since all outputs of the watch-only wallet are watch-only",
+	"fundrawtransactionopts-lockUnspents":           "Whether to lock the selected outputs",
+	"fundrawtransactionopts-feeRate":                "The fee rate in BTC/kvB, estimated when omitted",
+	"fundrawtransactionopts-subtractFeeFromOutputs": "The indexes of the outputs the fee is subtracted from in equal shares",
+	"fundrawtransactionopts-replaceable":            "Whether the transaction signals replaceability through the sequence numbers of the added inputs",
+	"fundrawtransactionopts-conf_target":            "The confirmation target in blocks the fee rate is estimated for",
+	"fundrawtransactionopts-estimate_mode":          "The fee estimate mode, either ECONOMICAL or CONSERVATIVE",
+	"fundrawtransactionopts-include_unsafe":         "Ignored since only confirmed outputs are tracked",
+
+	// FundRawTransactionHexResult help.
+	"fundrawtransactionhexresult-hex":       "The hex-encoded funded transaction",
+	"fundrawtransactionhexresult-fee":       "The fee the funded transaction pays in BTC",
+	"fundrawtransactionhexresult-changepos": "The index of the change output, or -1 when no change output was added",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the given address (simnet or regtest only)\n" +
+		" and returns a JSON array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase of the generated blocks pays to",
+	"generatetoaddress-maxtries":  "Unused, accepted for compatibility with Bitcoin Core",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
+	// GenerateToDescriptorCmd help
+	"generatetodescriptor--synopsis": "Generates a set number of blocks paying to the address of the given descriptor (simnet or regtest only)\n" +
+		" and returns a JSON array of their hashes.",
+	"generatetodescriptor-numblocks":  "Number of blocks to generate",
+	"generatetodescriptor-descriptor": "The un-ranged descriptor of the output the coinbase of the generated blocks pays to",
+	"generatetodescriptor-maxtries":   "Unused, accepted for compatibility with Bitcoin Core",
+	"generatetodescriptor--result0":   "The hashes, in order, of blocks generated by the call",
+
+	// GenerateBlockCmd help
+	"generateblock--synopsis": "Generates a block containing exactly the given transactions, in order, (simnet or regtest only).\n" +
+		"The transactions don't need to be in the memory pool, but the block must be valid.",
+	"generateblock-output":       "The address or un-ranged descriptor the coinbase of the block pays to",
+	"generateblock-transactions": "The ids of transactions in the memory pool or raw transactions in hex to include in the block",
+
+	// GenerateBlockResult help
+	"generateblockresult-hash": "The hash of the generated block",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -149,6 +401,31 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// AddressesRequest help.
+	"addressesrequest-addresses": "The addresses",
+
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the balance of a set of addresses along with the total amount they received in the main chain.\n" +
+		"This command requires the balance index to be enabled (--balanceindex).",
+	"getaddressbalance-request": "An object with the addresses or a single address",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-balance":  "The total amount of the unspent outputs of the addresses in satoshi",
+	"getaddressbalanceresult-received": "The total amount received by the addresses in satoshi",
+
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs of a set of addresses in the main chain in the order they were created.\n" +
+		"This command requires the balance index to be enabled (--balanceindex).",
+	"getaddressutxos-request": "An object with the addresses or a single address",
+
+	// GetAddressUtxosResult help.
+	"getaddressutxosresult-address":     "The address the output pays to",
+	"getaddressutxosresult-txid":        "The hash of the transaction which created the output",
+	"getaddressutxosresult-outputIndex": "The index of the output",
+	"getaddressutxosresult-script":      "The hex-encoded public key script of the output",
+	"getaddressutxosresult-satoshis":    "The amount of the output in satoshi",
+	"getaddressutxosresult-height":      "The height of the block which contains the output",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -164,7 +441,7 @@ var helpDescsEnUS = map[string]string{
 	// GetBlockCmd help.
 	"getblock--synopsis":   "Returns information about a block given its hash.",
 	"getblock-hash":        "The hash of the block",
-	"getblock-verbosity":   "Specifies whether the block data should be returned as a hex-encoded string (0), as parsed data with a slice of TXIDs (1), or as parsed data with parsed transaction data (2) ",
+	"getblock-verbosity":   "Specifies whether the block data should be returned as a hex-encoded string (0), as parsed data with a slice of TXIDs (1), as parsed data with parsed transaction data (2), or additionally with the outputs spent by the inputs (3)",
 	"getblock--condition0": "verbosity=0",
 	"getblock--condition1": "verbosity=1",
 	"getblock--result0":    "Hex-encoded bytes of the serialized block",
@@ -187,6 +464,14 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-initialblockdownload": "Estimate of whether this node is in Initial Block Download mode",
 	"getblockchaininforesult-softforks":            "The status of the super-majority soft-forks",
 	"getblockchaininforesult-unifiedsoftforks":     "The status of the super-majority soft-forks used by bitcoind on or after v0.19.0",
+	"getblockchaininforesult-finality":             "The status of the finality module (only present when it is enabled)",
+
+	// FinalityInfo help.
+	"finalityinfo-signers":         "The number of signers that attest to blocks",
+	"finalityinfo-quorum":          "The number of signers that must attest to a block to finalize it",
+	"finalityinfo-finalizedhash":   "The hash of the most recent finalized block (only present when a block was finalized)",
+	"finalityinfo-finalizedheight": "The height of the most recent finalized block",
+	"finalityinfo-pending":         "The number of blocks with attestations that did not reach the quorum yet",
 
 	// SoftForkDescription help.
 	"softforkdescription-reject":  "The current activation status of the softfork",
@@ -267,6 +552,52 @@ var helpDescsEnUS = map[string]string{
 	"getblockhash-index":     "The block height",
 	"getblockhash--result0":  "The block hash",
 
+	// GetBlockHashesCmd help.
+	"getblockhashes--synopsis": "Returns the hashes of the blocks in the main chain with a median time within a range in the order of the chain.\n" +
+		"This command requires the timestamp index to be enabled (--timestampindex).",
+	"getblockhashes-high":     "The end of the range as a unix timestamp, exclusive",
+	"getblockhashes-low":      "The start of the range as a unix timestamp, inclusive",
+	"getblockhashes--result0": "The hashes of the blocks",
+
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis": "Returns statistics about the fees, sizes and outputs of a block of the main chain.\n" +
+		"The block must not have been pruned since the outputs spent by it are resolved from the spend journal.",
+	"getblockstats-hashorheight": "The hash or the height of the block",
+	"getblockstats-stats":        "The statistics to return, all statistics are returned when omitted",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-avgfee":               "Average fee in the block in satoshi",
+	"getblockstatsresult-avgfeerate":           "Average fee rate in satoshi per virtual byte",
+	"getblockstatsresult-avgtxsize":            "Average transaction size in bytes",
+	"getblockstatsresult-feerate_percentiles":  "Fee rates at the 10th, 25th, 50th, 75th and 90th percentile weight unit in satoshi per virtual byte",
+	"getblockstatsresult-blockhash":            "The hash of the block",
+	"getblockstatsresult-height":               "The height of the block",
+	"getblockstatsresult-ins":                  "The number of inputs excluding the coinbase",
+	"getblockstatsresult-maxfee":               "Maximum fee in the block in satoshi",
+	"getblockstatsresult-maxfeerate":           "Maximum fee rate in satoshi per virtual byte",
+	"getblockstatsresult-maxtxsize":            "Maximum transaction size in bytes",
+	"getblockstatsresult-medianfee":            "Truncated median fee in the block in satoshi",
+	"getblockstatsresult-mediantime":           "The median time of the past 11 blocks",
+	"getblockstatsresult-mediantxsize":         "Truncated median transaction size in bytes",
+	"getblockstatsresult-minfee":               "Minimum fee in the block in satoshi",
+	"getblockstatsresult-minfeerate":           "Minimum fee rate in satoshi per virtual byte",
+	"getblockstatsresult-mintxsize":            "Minimum transaction size in bytes",
+	"getblockstatsresult-outs":                 "The number of outputs",
+	"getblockstatsresult-swtotal_size":         "Total size of all segwit transactions in bytes",
+	"getblockstatsresult-swtotal_weight":       "Total weight of all segwit transactions",
+	"getblockstatsresult-swtxs":                "The number of segwit transactions",
+	"getblockstatsresult-subsidy":              "The block subsidy in satoshi",
+	"getblockstatsresult-time":                 "The block time",
+	"getblockstatsresult-total_out":            "Total value of the outputs excluding the coinbase in satoshi",
+	"getblockstatsresult-total_size":           "Total size of all transactions excluding the coinbase in bytes",
+	"getblockstatsresult-total_weight":         "Total weight of all transactions excluding the coinbase",
+	"getblockstatsresult-totalfee":             "Total fee in satoshi",
+	"getblockstatsresult-txs":                  "The number of transactions including the coinbase",
+	"getblockstatsresult-utxo_increase":        "The increase or decrease in the number of unspent outputs",
+	"getblockstatsresult-utxo_size_inc":        "The increase or decrease in the size of the utxo set",
+	"getblockstatsresult-utxo_increase_actual": "The increase or decrease in the number of unspent outputs, excluding unspendable outputs",
+	"getblockstatsresult-utxo_size_inc_actual": "The increase or decrease in the size of the utxo set, excluding unspendable outputs",
+
 	// GetBlockHeaderCmd help.
 	"getblockheader--synopsis":   "Returns information about a block header given its hash.",
 	"getblockheader-hash":        "The hash of the block",
@@ -275,6 +606,19 @@ var helpDescsEnUS = map[string]string{
 	"getblockheader--condition1": "verbose=true",
 	"getblockheader--result0":    "The block header hash",
 
+	// GetBlockHeadersCmd help.
+	"getblockheaders--synopsis": "Returns a contiguous range of main chain block headers.\n" +
+		"Up to 2000 headers are returned as JSON objects and up to 20000 headers in the hex and raw formats.",
+	"getblockheaders-start":       "The hash (string) or height (numeric) of the first block whose header is returned",
+	"hashorheight-value":          "The block hash as a hex-encoded string or the block height as a number",
+	"getblockheaders-count":       "The maximum number of headers to return; fewer are returned when the range extends past the best block",
+	"getblockheaders-format":      "The format of the headers: json for JSON objects, hex for an array of hex-encoded headers or raw for a single hex-encoded string of the concatenated 80-byte headers",
+	"getblockheaders--condition0": "format=json",
+	"getblockheaders--condition1": "format=hex",
+	"getblockheaders--condition2": "format=raw",
+	"getblockheaders--result1":    "Array of hex-encoded serialized block headers",
+	"getblockheaders--result2":    "The hex-encoded concatenation of the serialized block headers",
+
 	// GetBlockHeaderVerboseResult help.
 	"getblockheaderverboseresult-hash":              "The hash of the block (same as provided)",
 	"getblockheaderverboseresult-confirmations":     "The number of confirmations",
@@ -291,7 +635,7 @@ var helpDescsEnUS = map[string]string{
 
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
-	"templaterequest-capabilities": "List of capabilities",
+	"templaterequest-capabilities": "List of client capabilities such as 'coinbasetxn', 'coinbasevalue', 'longpoll' and 'proposal'",
 	"templaterequest-longpollid":   "The long poll ID of a job to monitor for expiration; required and valid only for long poll requests ",
 	"templaterequest-sigoplimit":   "Number of signature operations allowed in blocks (this parameter is ignored)",
 	"templaterequest-sizelimit":    "Number of bytes allowed in blocks (this parameter is ignored)",
@@ -299,7 +643,7 @@ var helpDescsEnUS = map[string]string{
 	"templaterequest-target":       "The desired target for the block template (this parameter is ignored)",
 	"templaterequest-data":         "Hex-encoded block data (only for mode=proposal)",
 	"templaterequest-workid":       "The server provided workid if provided in block template (not applicable)",
-	"templaterequest-rules":        "Specific block rules that are to be enforced e.g. '[\"segwit\"]",
+	"templaterequest-rules":        "Deployment rules supported by the client, which must include 'segwit' once it is active e.g. '[\"segwit\"]'",
 
 	// GetBlockTemplateResultTx help.
 	"getblocktemplateresulttx-data":    "Hex-encoded transaction data (byte-for-byte)",
@@ -335,10 +679,17 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplateresult-mintime":                    "Minimum allowed time",
 	"getblocktemplateresult-mutable":                    "List of mutations the server explicitly allows",
 	"getblocktemplateresult-noncerange":                 "Two concatenated hex-encoded big-endian 32-bit integers which represent the valid ranges of nonces the miner may scan",
-	"getblocktemplateresult-capabilities":               "List of server capabilities including 'proposal' to indicate support for block proposals",
+	"getblocktemplateresult-capabilities":               "List of server capabilities including 'proposal' to indicate support for block proposals and 'longpoll' for long polling",
 	"getblocktemplateresult-reject-reason":              "Reason the proposal was invalid as-is (only applies to proposal responses)",
-	"getblocktemplateresult-default_witness_commitment": "The witness commitment itself. Will be populated if the block has witness data",
+	"getblocktemplateresult-default_witness_commitment": "Hex-encoded script of the witness commitment output for the unmodified template. Populated once segwit is active",
+	"getblocktemplateresult-signet_challenge":           "The hex-encoded challenge the block solution must satisfy on signet networks",
 	"getblocktemplateresult-weightlimit":                "The current limit on the max allowed weight of a block",
+	"getblocktemplateresult-rules":                      "Active deployment rules the block must be valid under, where a '!' prefix marks rules the client must explicitly support",
+	"getblocktemplateresult-vbavailable":                "Deployments which are still being signalled for",
+	"getblocktemplateresult-vbavailable--key":           "name",
+	"getblocktemplateresult-vbavailable--value":         "n",
+	"getblocktemplateresult-vbavailable--desc":          "The deployment name as the key and its version bit as the value",
+	"getblocktemplateresult-vbrequired":                 "Bit mask of versionbits the server requires set in submissions",
 
 	// GetBlockTemplateCmd help.
 	"getblocktemplate--synopsis": "Returns a JSON object with information necessary to construct a block to mine or accepts a proposal to validate.\n" +
@@ -354,10 +705,42 @@ var helpDescsEnUS = map[string]string{
 	"getchaintipsresult-height":    "The height of the chain tip",
 	"getchaintipsresult-hash":      "The block hash of the chain tip",
 	"getchaintipsresult-branchlen": "Returns zero for main chain. Otherwise is the length of branch connecting the tip to the main chain",
-	"getchaintipsresult-status":    "Status of the chain. Returns \"active\" for the main chain",
+	"getchaintipsresult-status":    "Status of the chain: \"active\" for the main chain, \"valid-fork\" for a branch with all blocks available, \"headers-only\" for a branch with blocks which aren't available and \"invalid\" for a branch with an invalid block",
 	// GetChainTipsCmd help.
 	"getchaintips--synopsis": "Returns information about all known tips in the block tree, including the main chain as well as orphaned branches.",
 
+	// GetChainTxStatsCmd help.
+	"getchaintxstats--synopsis": "Returns statistics about the total number and rate of transactions in the chain.",
+	"getchaintxstats-nblocks":   "Size of the window in number of blocks, defaults to about one month of blocks",
+	"getchaintxstats-blockhash": "The hash of the block which ends the window, defaults to the best block",
+
+	// GetChainTxStatsResult help.
+	"getchaintxstatsresult-time":                      "The timestamp of the final block in the window",
+	"getchaintxstatsresult-txcount":                   "The total number of transactions in the chain up to that point",
+	"getchaintxstatsresult-window_final_block_hash":   "The hash of the final block in the window",
+	"getchaintxstatsresult-window_final_block_height": "The height of the final block in the window",
+	"getchaintxstatsresult-window_block_count":        "Size of the window in number of blocks",
+	"getchaintxstatsresult-window_tx_count":           "The number of transactions in the window, only returned if window_block_count is greater than 0",
+	"getchaintxstatsresult-window_interval":           "The elapsed time in the window in seconds, only returned if window_block_count is greater than 0",
+	"getchaintxstatsresult-txrate":                    "The average rate of transactions per second in the window, only returned if window_interval is greater than 0",
+
+	// GetBlockFilterCmd help.
+	"getblockfilter--synopsis":  "Returns the BIP0158 filter and filter header of a block given its hash.",
+	"getblockfilter-blockhash":  "The hash of the block",
+	"getblockfilter-filtertype": "The type name of the filter (basic)",
+
+	// GetBlockFilterResult help.
+	"getblockfilterresult-filter": "The hex-encoded filter data",
+	"getblockfilterresult-header": "The hex-encoded filter header",
+
+	// GetBlockFromPeerCmd help.
+	"getblockfrompeer--synopsis": "Requests a block whose header is known from the given peer and stores it once it is received.\n" +
+		"This is used to download the blocks of headers submitted with submitheader.\n" +
+		"The command returns without waiting for the block.",
+	"getblockfrompeer-blockhash": "The hash of the block",
+	"getblockfrompeer-peerid":    "The ID of the peer to request the block from, as shown by getpeerinfo",
+	"getblockfrompeer--result0":  "An empty object",
+
 	// GetCFilterCmd help.
 	"getcfilter--synopsis":  "Returns a block's committed filter given its hash.",
 	"getcfilter-filtertype": "The type of filter to return (0=regular)",
@@ -378,6 +761,17 @@ var helpDescsEnUS = map[string]string{
 	"getcurrentnet--synopsis": "Get bitcoin network the server is running on.",
 	"getcurrentnet--result0":  "The network identifier",
 
+	// GetDescriptorInfoCmd help.
+	"getdescriptorinfo--synopsis":  "Analyses an output descriptor.",
+	"getdescriptorinfo-descriptor": "The output descriptor",
+
+	// GetDescriptorInfoResult help.
+	"getdescriptorinforesult-descriptor":     "The descriptor in canonical form with its checksum and without private keys",
+	"getdescriptorinforesult-checksum":       "The checksum of the passed descriptor",
+	"getdescriptorinforesult-isrange":        "Whether the descriptor is ranged",
+	"getdescriptorinforesult-issolvable":     "Whether the descriptor is solvable",
+	"getdescriptorinforesult-hasprivatekeys": "Whether the descriptor contains a private key",
+
 	// GetDifficultyCmd help.
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
@@ -426,6 +820,17 @@ var helpDescsEnUS = map[string]string{
 	"getheaders-hashstop":      "Block hash to stop including block headers for; if not found, all headers to the latest known block are returned.",
 	"getheaders--result0":      "Serialized block headers of all located blocks, limited to some arbitrary maximum number of hashes (currently 2000, which matches the wire protocol headers message, but this is not guaranteed)",
 
+	// GetIndexInfoCmd help.
+	"getindexinfo--synopsis":       "Returns the status of the enabled indexes: txindex, addrindex, cfindex, txcountindex, spentindex, timestampindex and balanceindex.",
+	"getindexinfo-indexname":       "Only return the status of the index with this name",
+	"getindexinfo--result0--desc":  "Index status objects keyed by the index name",
+	"getindexinfo--result0--key":   "Index name",
+	"getindexinfo--result0--value": "Object containing the status of the index",
+
+	// GetIndexInfoResult help.
+	"getindexinforesult-synced":            "Whether the index has caught up with the best block of the main chain",
+	"getindexinforesult-best_block_height": "The height of the most recently indexed block, -1 when no block has been indexed yet",
+
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
@@ -433,8 +838,13 @@ var helpDescsEnUS = map[string]string{
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":            "Size in bytes of the mempool",
+	"getmempoolinforesult-size":             "Number of transactions in the mempool",
+	"getmempoolinforesult-usage":            "Memory used by the transactions of the mempool in bytes",
+	"getmempoolinforesult-maxmempool":       "Maximum memory the transactions of the mempool may use in bytes",
+	"getmempoolinforesult-mempoolminfee":    "Minimum fee rate in BTC/kvB for transactions to be accepted, which is the higher of the minimum relay fee rate and the minimum fee rate the mempool was raised to by evicting transactions because it was full",
+	"getmempoolinforesult-minrelaytxfee":    "Minimum relay fee rate in BTC/kvB for transactions",
+	"getmempoolinforesult-unbroadcastcount": "Number of transactions submitted through the RPC server which are rebroadcast until they are confirmed",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -466,16 +876,67 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytesrecv": "Total bytes received",
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-uploadtarget":   "The state of the daily upload target",
+
+	// GetNetTotalsUploadTarget help.
+	"getnettotalsuploadtarget-timeframe":               "Length of the cycle the target applies to in seconds",
+	"getnettotalsuploadtarget-target":                  "Number of bytes which may be sent per cycle, or 0 when there is no target",
+	"getnettotalsuploadtarget-target_reached":          "Whether the target was reached in the current cycle",
+	"getnettotalsuploadtarget-serve_historical_blocks": "Whether blocks older than a week are still served in the current cycle",
+	"getnettotalsuploadtarget-bytes_left_in_cycle":     "Number of bytes left in the current cycle",
+	"getnettotalsuploadtarget-time_left_in_cycle":      "Seconds left until the current cycle ends",
+
+	// GetNetworkInfoCmd help.
+	"getnetworkinfo--synopsis": "Returns a JSON object containing information about the P2P networking state.",
+
+	// GetNetworkInfoResult help.
+	"getnetworkinforesult-version":         "The version of the server",
+	"getnetworkinforesult-subversion":      "The user agent of the server sent to peers",
+	"getnetworkinforesult-protocolversion": "The latest supported protocol version",
+	"getnetworkinforesult-localservices":   "The services advertised to peers as a hex string",
+	"getnetworkinforesult-localrelay":      "Whether transactions are requested from peers",
+	"getnetworkinforesult-timeoffset":      "The time offset in seconds",
+	"getnetworkinforesult-connections":     "The number of connected peers",
+	"getnetworkinforesult-connections_in":  "The number of inbound peers",
+	"getnetworkinforesult-connections_out": "The number of outbound peers",
+	"getnetworkinforesult-networkactive":   "Whether the P2P networking is enabled",
+	"getnetworkinforesult-networks":        "Information about each network",
+	"getnetworkinforesult-relayfee":        "The minimum fee rate in BTC/kB for transactions to be relayed",
+	"getnetworkinforesult-incrementalfee":  "The minimum fee rate increase in BTC/kB for replacing transactions",
+	"getnetworkinforesult-localaddresses":  "The local addresses advertised to peers",
+	"getnetworkinforesult-portmapping":     "The mapping of the listening port via UPnP, NAT-PMP or PCP, omitted when the port is not mapped",
+	"getnetworkinforesult-warnings":        "Any network warnings",
+
+	// NetworksResult help.
+	"networksresult-name":                        "The name of the network (ipv4, ipv6, onion, i2p or cjdns)",
+	"networksresult-limited":                     "Whether the network is not connected to",
+	"networksresult-reachable":                   "Whether the network is connected to",
+	"networksresult-proxy":                       "The proxy used to connect to the network, if any",
+	"networksresult-proxy_randomize_credentials": "Whether the credentials of the proxy are randomized for each connection",
+
+	// LocalAddressesResult help.
+	"localaddressesresult-address": "The advertised address",
+	"localaddressesresult-port":    "The advertised port",
+	"localaddressesresult-score":   "The priority of the method the address was discovered with",
+
+	// PortMappingResult help.
+	"portmappingresult-protocol":        "The protocol the port is mapped with (upnp, natpmp or pcp)",
+	"portmappingresult-externaladdress": "The external address of the NAT",
+	"portmappingresult-externalport":    "The port mapped on the NAT",
+	"portmappingresult-internalport":    "The listening port the external port is mapped to",
+	"portmappingresult-expires":         "The time the mapping expires unless renewed in seconds since 1 Jan 1970 GMT",
 
 	// GetNodeAddressesResult help.
 	"getnodeaddressesresult-time":     "Timestamp in seconds since epoch (Jan 1 1970 GMT) keeping track of when the node was last seen",
 	"getnodeaddressesresult-services": "The services offered",
 	"getnodeaddressesresult-address":  "The address of the node",
 	"getnodeaddressesresult-port":     "The port of the node",
+	"getnodeaddressesresult-network":  "The network of the address (ipv4, ipv6, onion, i2p or cjdns)",
 
 	// GetNodeAddressesCmd help.
 	"getnodeaddresses--synopsis": "Return known addresses which can potentially be used to find new nodes in the network",
 	"getnodeaddresses-count":     "How many addresses to return. Limited to the smaller of 2500 or 23% of all known addresses",
+	"getnodeaddresses-network":   "Only return addresses of this network (ipv4, ipv6, onion, i2p or cjdns)",
 	"getnodeaddresses--result0":  "List of node addresses",
 
 	// GetPeerInfoResult help.
@@ -492,6 +953,7 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-timeoffset":     "The time offset of the peer",
 	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
 	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-minping":        "Number of microseconds the fastest ping took (omitted until a ping returned)",
 	"getpeerinforesult-version":        "The protocol version of the peer",
 	"getpeerinforesult-subver":         "The user agent of the peer",
 	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
@@ -500,10 +962,157 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-blockdownload":  "Statistics of the blocks downloaded from the peer during the initial block download (only present when blocks were requested from the peer)",
+
+	"getpeerinforesult-transport_protocol_type": "The transport protocol of the connection (v1 or v2)",
+	"getpeerinforesult-session_id":              "The session id of the encrypted v2 transport protocol, which is the same on both sides of the connection, or an empty string for the v1 protocol",
+	"getpeerinforesult-compression":             "The codec block and headers messages exchanged with the peer are compressed with (omitted when compression is not used)",
+	"getpeerinforesult-connection_type":         "The type of the connection (inbound, manual, outbound-full-relay or block-relay-only)",
+
+	"getpeerinforesult-addr_relay_enabled": "Whether addresses are relayed to and from the peer",
+	"getpeerinforesult-addr_processed":     "The number of addresses relayed by the peer which were processed",
+	"getpeerinforesult-addr_rate_limited":  "The number of addresses relayed by the peer which were ignored for exceeding the address rate limit",
+	"getpeerinforesult-addr_sent":          "The number of addresses sent to the peer in addr and addrv2 messages",
+	"getpeerinforesult-addr_recv":          "The number of addresses received from the peer in addr and addrv2 messages",
+
+	"getpeerinforesult-bytessent_per_msg":        "The bytes sent to the peer per message command",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "bytes",
+	"getpeerinforesult-bytessent_per_msg--desc":  "The bytes sent to the peer keyed by message command, including the message headers",
+	"getpeerinforesult-bytesrecv_per_msg":        "The bytes received from the peer per message command",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "bytes",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "The bytes received from the peer keyed by message command, including the message headers, where messages which could not be read are counted under *other*",
+	"getpeerinforesult-msgssent_per_msg":         "The number of messages sent to the peer per message command",
+	"getpeerinforesult-msgssent_per_msg--key":    "command",
+	"getpeerinforesult-msgssent_per_msg--value":  "messages",
+	"getpeerinforesult-msgssent_per_msg--desc":   "The number of messages sent to the peer keyed by message command",
+	"getpeerinforesult-msgsrecv_per_msg":         "The number of messages received from the peer per message command",
+	"getpeerinforesult-msgsrecv_per_msg--key":    "command",
+	"getpeerinforesult-msgsrecv_per_msg--value":  "messages",
+	"getpeerinforesult-msgsrecv_per_msg--desc":   "The number of messages received from the peer keyed by message command, where messages which could not be read are counted under *other*",
+
+	// PeerBlockDownloadInfo help.
+	"peerblockdownloadinfo-inflight":    "The number of blocks currently requested from the peer",
+	"peerblockdownloadinfo-maxinflight": "The number of blocks that may be requested from the peer at the same time based on its score",
+	"peerblockdownloadinfo-blocks":      "The number of blocks downloaded from the peer",
+	"peerblockdownloadinfo-bytes":       "The total size of the blocks downloaded from the peer",
+	"peerblockdownloadinfo-stalls":      "The number of block requests to the peer that stalled and were requested from another peer",
+	"peerblockdownloadinfo-latency":     "The moving average of the time between requesting a block and receiving it in milliseconds",
+	"peerblockdownloadinfo-throughput":  "The moving average of the rate the peer delivers blocks at in bytes per second",
+	"peerblockdownloadinfo-score":       "The score the peer is prioritized by when assigning blocks to download",
+
+	// GetOrphanInfoCmd help.
+	"getorphaninfo--synopsis": "Returns the usage and limits of the orphan transaction pool along with the orphans relayed by each peer.\n" +
+		"Once a peer exceeds its share of the pool its own oldest orphans are evicted, and the oldest orphans of the peers that relayed the most are evicted when the pool is full.",
+	"getorphaninfo-verbose": "Also return the orphan transactions",
+
+	// GetOrphanInfoResult help.
+	"getorphaninforesult-size":          "The number of orphan transactions",
+	"getorphaninforesult-weight":        "The total weight of the orphan transactions",
+	"getorphaninforesult-maxsize":       "The maximum number of orphan transactions",
+	"getorphaninforesult-maxweight":     "The maximum total weight of the orphan transactions, 0 for no limit",
+	"getorphaninforesult-maxpeerweight": "The maximum total weight of the orphan transactions relayed by a single peer, 0 for no limit",
+	"getorphaninforesult-expiry":        "The number of seconds an orphan transaction is kept before it expires",
+	"getorphaninforesult-peers":         "The orphans relayed by each peer",
+	"getorphaninforesult-orphans":       "The orphan transactions, which expire in this order (only when verbose is true)",
+
+	// OrphanPeerResult help.
+	"orphanpeerresult-id":     "The id of the peer",
+	"orphanpeerresult-size":   "The number of orphan transactions relayed by the peer",
+	"orphanpeerresult-weight": "The total weight of the orphan transactions relayed by the peer",
+
+	// OrphanTxResult help.
+	"orphantxresult-txid":       "The hash of the orphan transaction",
+	"orphantxresult-weight":     "The weight of the orphan transaction",
+	"orphantxresult-from":       "The id of the peer which relayed the orphan transaction",
+	"orphantxresult-expiration": "The time the orphan transaction expires in seconds since 1 Jan 1970 GMT",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
 
+	// GetPeerReputationCmd help.
+	"getpeerreputation--synopsis": "Returns the statistics about the behavior of the peers of each host, which persist across restarts, along with the reputation derived from them.\n" +
+		"The reputation biases the selection of outbound peers towards hosts that were useful in the past and away from those that misbehaved.",
+	"getpeerreputation-addr": "Only return the statistics of the host of this address",
+
+	// GetPeerReputationResult help.
+	"getpeerreputationresult-host":        "The host the statistics are tracked by",
+	"getpeerreputationresult-score":       "The reputation score, the credit for the useful blocks and transactions minus the penalties for stalls, misbehavior and bans",
+	"getpeerreputationresult-weight":      "The probability in the range (0, 1] with which the host is selected as an outbound peer when it is offered, 0.5 for unknown hosts",
+	"getpeerreputationresult-blocks":      "The number of blocks delivered which were added to the chain",
+	"getpeerreputationresult-txns":        "The number of transactions delivered which were accepted to the memory pool",
+	"getpeerreputationresult-stalls":      "The number of block requests that stalled",
+	"getpeerreputationresult-misbehavior": "The total ban score accrued",
+	"getpeerreputationresult-bans":        "The number of times the host was banned",
+	"getpeerreputationresult-connections": "The number of connections made with the host",
+	"getpeerreputationresult-lastseen":    "The time any of the statistics were last updated in seconds since 1 Jan 1970 GMT",
+
+	// GetPendingReorgResult help.
+	"getpendingreorgresult-forkhash":     "The hash of the last block the main chain and the competing chain have in common",
+	"getpendingreorgresult-forkheight":   "The height of the last block the main chain and the competing chain have in common",
+	"getpendingreorgresult-oldtiphash":   "The hash of the tip of the main chain",
+	"getpendingreorgresult-oldtipheight": "The height of the tip of the main chain",
+	"getpendingreorgresult-newtiphash":   "The hash of the tip of the competing chain with more work",
+	"getpendingreorgresult-newtipheight": "The height of the tip of the competing chain with more work",
+	"getpendingreorgresult-depth":        "The number of main chain blocks the reorganization would disconnect",
+	"getpendingreorgresult-detected":     "The time the competing chain was found to have more work in seconds since 1 Jan 1970 GMT",
+
+	// GetPendingReorgCmd help.
+	"getpendingreorg--synopsis": "Returns the reorganization that is paused because it would disconnect more blocks than the maximum reorganization depth, or null when there is none.",
+
+	// GetMempoolEntryResult help.
+	"getmempoolentryresult-vsize":              "The virtual size of the transaction",
+	"getmempoolentryresult-size":               "The size of the transaction in bytes",
+	"getmempoolentryresult-weight":             "The weight of the transaction",
+	"getmempoolentryresult-fee":                "The fee of the transaction in bitcoins (deprecated, use fees.base)",
+	"getmempoolentryresult-modifiedfee":        "The fee of the transaction in bitcoins used for mining priority (deprecated, use fees.modified)",
+	"getmempoolentryresult-time":               "Local time the transaction entered the pool in seconds since 1 Jan 1970 GMT",
+	"getmempoolentryresult-height":             "Block height when the transaction entered the pool",
+	"getmempoolentryresult-descendantcount":    "Number of unconfirmed descendants of the transaction including itself",
+	"getmempoolentryresult-descendantsize":     "Virtual size of the transaction and its unconfirmed descendants",
+	"getmempoolentryresult-descendantfees":     "Fees of the transaction and its unconfirmed descendants in satoshi (deprecated, use fees.descendant)",
+	"getmempoolentryresult-ancestorcount":      "Number of unconfirmed ancestors of the transaction including itself",
+	"getmempoolentryresult-ancestorsize":       "Virtual size of the transaction and its unconfirmed ancestors",
+	"getmempoolentryresult-ancestorfees":       "Fees of the transaction and its unconfirmed ancestors in satoshi (deprecated, use fees.ancestor)",
+	"getmempoolentryresult-wtxid":              "The hash of the transaction including its witness",
+	"getmempoolentryresult-fees":               "The fees of the transaction and its relatives",
+	"getmempoolentryresult-depends":            "Unconfirmed transactions used as inputs for this transaction",
+	"getmempoolentryresult-spentby":            "Unconfirmed transactions spending outputs of this transaction",
+	"getmempoolentryresult-bip125-replaceable": "Whether the transaction or one of its unconfirmed ancestors signals replaceability (BIP0125)",
+
+	// MempoolFees help.
+	"mempoolfees-base":       "The fee of the transaction in bitcoins",
+	"mempoolfees-modified":   "The fee of the transaction in bitcoins used for mining priority",
+	"mempoolfees-ancestor":   "The fees of the transaction and its unconfirmed ancestors in bitcoins",
+	"mempoolfees-descendant": "The fees of the transaction and its unconfirmed descendants in bitcoins",
+
+	// GetMempoolAncestorsCmd help.
+	"getmempoolancestors--synopsis":       "Returns all of the unconfirmed ancestors of a transaction of the memory pool.",
+	"getmempoolancestors-txid":            "The hash of the transaction",
+	"getmempoolancestors-verbose":         "Returns JSON objects keyed by transaction hash when true or an array of transaction hashes when false",
+	"getmempoolancestors--condition0":     "verbose=false",
+	"getmempoolancestors--condition1":     "verbose=true",
+	"getmempoolancestors--result0":        "Array of transaction hashes",
+	"getmempoolancestors--result1--desc":  "Unconfirmed transactions keyed by transaction hash",
+	"getmempoolancestors--result1--key":   "The hash of the transaction",
+	"getmempoolancestors--result1--value": "An object describing the transaction",
+
+	// GetMempoolDescendantsCmd help.
+	"getmempooldescendants--synopsis":       "Returns all of the unconfirmed descendants of a transaction of the memory pool.",
+	"getmempooldescendants-txid":            "The hash of the transaction",
+	"getmempooldescendants-verbose":         "Returns JSON objects keyed by transaction hash when true or an array of transaction hashes when false",
+	"getmempooldescendants--condition0":     "verbose=false",
+	"getmempooldescendants--condition1":     "verbose=true",
+	"getmempooldescendants--result0":        "Array of transaction hashes",
+	"getmempooldescendants--result1--desc":  "Unconfirmed transactions keyed by transaction hash",
+	"getmempooldescendants--result1--key":   "The hash of the transaction",
+	"getmempooldescendants--result1--value": "An object describing the transaction",
+
+	// GetMempoolEntryCmd help.
+	"getmempoolentry--synopsis": "Returns information about a transaction of the memory pool including its unconfirmed ancestors and descendants.",
+	"getmempoolentry-txid":      "The hash of the transaction",
+
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in bitcoins",
@@ -530,6 +1139,17 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the transaction input which spends an output in the main chain.\n" +
+		"This command requires the spent index to be enabled (--spentindex).",
+	"getspentinfo-txid":  "The hash of the transaction which created the output",
+	"getspentinfo-index": "The index of the output",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":   "The hash of the spending transaction",
+	"getspentinforesult-index":  "The index of the input which spends the output",
+	"getspentinforesult-height": "The height of the block which contains the spending transaction",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -544,6 +1164,115 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutSetInfoResult help.
+	"gettxoutsetinforesult-height":            "The height of the best block",
+	"gettxoutsetinforesult-bestblock":         "The hash of the best block",
+	"gettxoutsetinforesult-transactions":      "The number of transactions with unspent outputs (not reported)",
+	"gettxoutsetinforesult-txouts":            "The number of unspent transaction outputs",
+	"gettxoutsetinforesult-bogosize":          "A database-independent metric for the size of the utxo set",
+	"gettxoutsetinforesult-hash_serialized_2": "The serialized hash of the utxo set (not reported)",
+	"gettxoutsetinforesult-muhash":            "The MuHash3072 of the utxo set, which matches the muhash reported by Bitcoin Core (only with hash type muhash)",
+	"gettxoutsetinforesult-disk_size":         "The size of the utxo set on disk (not reported)",
+	"gettxoutsetinforesult-total_amount":      "The total amount of all unspent outputs in BTC",
+
+	// GetTxOutSetInfoCmd help.
+	"gettxoutsetinfo--synopsis": "Returns statistics about the unspent transaction output set as of the best block.\n" +
+		"The statistics are maintained as blocks are connected and disconnected, so the call does not scan the utxo set.",
+	"gettxoutsetinfo-hashtype": "The type of utxo set hash to calculate, either muhash or none",
+
+	// GetUnbroadcastCmd help.
+	"getunbroadcast--synopsis": "Returns the transactions submitted through the RPC server which are not confirmed yet, ordered by the time they were submitted.\n" +
+		"They are periodically announced to the connected peers which have not seen them yet until they are confirmed, a conflicting transaction is confirmed or they leave the mempool.",
+
+	// GetUnbroadcastResult help.
+	"getunbroadcastresult-txid":          "The hash of the transaction",
+	"getunbroadcastresult-wtxid":         "The witness hash of the transaction",
+	"getunbroadcastresult-time":          "The time the transaction was submitted in seconds since 1 Jan 1970 GMT",
+	"getunbroadcastresult-lastannounced": "The last time the transaction was announced to a peer by a rebroadcast in seconds since 1 Jan 1970 GMT, or 0 if it was not rebroadcast yet",
+	"getunbroadcastresult-peers":         "The number of connected peers which have been made aware of the transaction",
+	"getunbroadcastresult-announcements": "The total number of peers the transaction was announced to by rebroadcasts, including peers which have since disconnected",
+	"getunbroadcastresult-requests":      "The number of times peers requested the transaction",
+
+	// GetRPCInfoCmd help.
+	"getrpcinfo--synopsis": "Returns details about the RPC server, including the requests which are being processed.",
+
+	// GetRPCInfoResult help.
+	"getrpcinforesult-active_commands": "The requests which are being processed, starting with the oldest",
+	"getrpcinforesult-logpath":         "The path of the log file",
+
+	// RPCActiveCommand help.
+	"rpcactivecommand-method":   "The name of the method",
+	"rpcactivecommand-duration": "How long the request has been running in microseconds",
+
+	// GetMemoryInfoCmd help.
+	"getmemoryinfo--synopsis": "Returns information about the memory usage of the node.\n" +
+		"The locked memory statistics are always zero since btcd doesn't lock memory and are only included for compatibility with Bitcoin Core.",
+	"getmemoryinfo-mode": "The kind of information to return -- only stats is supported",
+
+	// GetMemoryInfoResult help.
+	"getmemoryinforesult-locked":    "Statistics about locked memory",
+	"getmemoryinforesult-runtime":   "Memory statistics of the Go runtime",
+	"getmemoryinforesult-utxocache": "Memory used by the cache of the utxo set",
+	"getmemoryinforesult-dbcache":   "Memory used by the cache of the database for changes which are not flushed yet",
+	"getmemoryinforesult-mempool":   "Memory used by the transactions of the memory pool",
+
+	// LockedMemoryInfo help.
+	"lockedmemoryinfo-used":        "The number of bytes used",
+	"lockedmemoryinfo-free":        "The number of bytes available in the current arenas",
+	"lockedmemoryinfo-total":       "The total number of bytes managed",
+	"lockedmemoryinfo-locked":      "The number of bytes which succeeded locking",
+	"lockedmemoryinfo-chunks_used": "The number of allocated chunks",
+	"lockedmemoryinfo-chunks_free": "The number of unused chunks",
+
+	// RuntimeMemoryInfo help.
+	"runtimememoryinfo-sys":           "The number of bytes obtained from the operating system",
+	"runtimememoryinfo-heapalloc":     "The number of bytes of allocated heap objects",
+	"runtimememoryinfo-heapinuse":     "The number of bytes in in-use heap spans",
+	"runtimememoryinfo-heapidle":      "The number of bytes in idle heap spans",
+	"runtimememoryinfo-heapreleased":  "The number of bytes of heap memory returned to the operating system",
+	"runtimememoryinfo-heapobjects":   "The number of allocated heap objects",
+	"runtimememoryinfo-stackinuse":    "The number of bytes in stack spans",
+	"runtimememoryinfo-totalalloc":    "The cumulative number of bytes allocated for heap objects",
+	"runtimememoryinfo-goroutines":    "The number of goroutines",
+	"runtimememoryinfo-numgc":         "The number of completed garbage collection cycles",
+	"runtimememoryinfo-lastgc":        "The time the last garbage collection finished in seconds since 1 Jan 1970 GMT, 0 if none did",
+	"runtimememoryinfo-gcpausetotal":  "The total time the program was paused by garbage collections in seconds",
+	"runtimememoryinfo-gccpufraction": "The fraction of the available CPU time used by the garbage collector",
+
+	// CacheMemoryInfo help.
+	"cachememoryinfo-entries": "The number of entries",
+	"cachememoryinfo-used":    "The number of bytes used",
+	"cachememoryinfo-max":     "The number of bytes the cache may use, omitted when not limited",
+
+	// GetZmqNotificationsCmd help.
+	"getzmqnotifications--synopsis": "Returns the ZeroMQ notifications which are published by the node.",
+
+	// ZmqNotification help.
+	"zmqnotification-type":    "The type of the notification, such as pubhashblock",
+	"zmqnotification-address": "The endpoint the notification is published on",
+	"zmqnotification-hwm":     "The maximum number of messages queued for each subscriber",
+
+	// GetRPCStatsCmd help.
+	"getrpcstats--synopsis": "Returns latency statistics about the requests served by the RPC server per method.\n" +
+		"Requests taking longer than the slow threshold are also logged with their parameters redacted.",
+
+	// GetRPCStatsResult help.
+	"getrpcstatsresult-slowthreshold": "The duration in seconds above which requests are counted and logged as slow, 0 when disabled",
+	"getrpcstatsresult-methods":       "The statistics of each requested method",
+
+	// RPCMethodStats help.
+	"rpcmethodstats-method":    "The name of the method",
+	"rpcmethodstats-calls":     "The number of requests",
+	"rpcmethodstats-errors":    "The number of requests which failed",
+	"rpcmethodstats-slowcalls": "The number of requests slower than the slow threshold",
+	"rpcmethodstats-totaltime": "The total time spent serving requests in seconds",
+	"rpcmethodstats-maxtime":   "The time taken by the slowest request in seconds",
+	"rpcmethodstats-histogram": "The number of requests per latency bucket",
+
+	// RPCLatencyBucket help.
+	"rpclatencybucket-le":    "The upper bound of the bucket, +Inf for the last bucket",
+	"rpclatencybucket-count": "The number of requests which took longer than the previous bucket's bound and at most this bucket's bound",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -552,26 +1281,159 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ImportDescriptorsCmd help.
+	"importdescriptors--synopsis": "Imports descriptors into the watch-only wallet, whose outputs are tracked to fund transactions with fundrawtransaction.\n" +
+		"The utxo set is scanned for the outputs of the descriptors unless their timestamp is \"now\".  Private keys are not supported.\n" +
+		"Ranged descriptors are expanded to the derivation indexes 0 to 1000 unless a range is given.",
+	"importdescriptors-requests": "The descriptors to import",
+
+	// ImportDescriptorsRequest help.
+	"importdescriptorsrequest-desc":       "The descriptor including its checksum",
+	"importdescriptorsrequest-active":     "Whether the descriptor is the active one of its output type, which change is derived from when it is internal",
+	"importdescriptorsrequest-range":      "The end or the [begin,end] range of the derivation indexes to watch for a ranged descriptor",
+	"importdescriptorsrequest-next_index": "The next derivation index of a ranged descriptor to derive change from",
+	"importdescriptorsrequest-timestamp":  "The creation time of the descriptor in seconds since 1 Jan 1970 GMT, or \"now\" when it has never been used",
+	"importdescriptorsrequest-internal":   "Whether the outputs of the descriptor are change",
+	"importdescriptorsrequest-label":      "Ignored since labels are not supported",
+
+	// ImportDescriptorsResult help.
+	"importdescriptorsresult-success":  "Whether the descriptor was imported",
+	"importdescriptorsresult-warnings": "Warnings about the imported descriptor",
+	"importdescriptorsresult-error":    "The error when the descriptor was not imported",
+
+	// RPCError help.
+	"rpcerror-code":    "The error code",
+	"rpcerror-message": "The error message",
+
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":        "The banned subnet",
+	"listbannedresult-ban_created":    "The time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-banned_until":   "The time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_duration":   "The number of seconds the ban lasts in total",
+	"listbannedresult-time_remaining": "The number of seconds until the ban expires",
+
+	// ListDescriptorsCmd help.
+	"listdescriptors--synopsis": "Returns the descriptors imported into the watch-only wallet.",
+
+	// ListDescriptorsResult help.
+	"listdescriptorsresult-descriptors": "The imported descriptors sorted by descriptor",
+
+	// ListDescriptorsDescriptor help.
+	"listdescriptorsdescriptor-desc":      "The descriptor including its checksum",
+	"listdescriptorsdescriptor-timestamp": "The creation time of the descriptor in seconds since 1 Jan 1970 GMT",
+	"listdescriptorsdescriptor-active":    "Whether the descriptor is the active one of its output type",
+	"listdescriptorsdescriptor-internal":  "Whether the outputs of the descriptor are change",
+	"listdescriptorsdescriptor-range":     "The watched [begin,end] range of derivation indexes of a ranged descriptor",
+	"listdescriptorsdescriptor-next":      "The next derivation index of a ranged descriptor to derive change from",
+
+	// ListLockUnspentCmd help.
+	"listlockunspent--synopsis": "Returns the outputs of the watch-only wallet which are locked, so they are not selected to fund transactions.",
+
+	// ListUnspentCmd help.
+	"listunspent--synopsis": "Returns the confirmed unspent outputs of the watch-only wallet which are neither locked nor spent by transactions in the memory pool.",
+	"listunspent-minconf":   "The minimum number of confirmations of the outputs",
+	"listunspent-maxconf":   "The maximum number of confirmations of the outputs",
+	"listunspent-addresses": "The addresses the outputs must pay to",
+
+	// ListUnspentResult help.
+	"listunspentresult-txid":          "The hash of the transaction of the output",
+	"listunspentresult-vout":          "The index of the output",
+	"listunspentresult-address":       "The address the output pays to, when it has one",
+	"listunspentresult-account":       "Unused, always empty",
+	"listunspentresult-scriptPubKey":  "The hex-encoded public key script of the output",
+	"listunspentresult-redeemScript":  "The hex-encoded redeem script of P2SH outputs",
+	"listunspentresult-amount":        "The amount of the output in BTC",
+	"listunspentresult-confirmations": "The number of confirmations of the output",
+	"listunspentresult-spendable":     "Always false since the wallet doesn't have private keys",
+
+	// LockUnspentCmd help.
+	"lockunspent--synopsis":    "Locks outputs of the watch-only wallet, so they are not selected to fund transactions, or unlocks them.\nThe locks are not persisted across restarts.",
+	"lockunspent-unlock":       "Whether to unlock rather than lock the outputs",
+	"lockunspent-transactions": "The outputs to lock or unlock, or all locked outputs when unlocking an empty list",
+	"lockunspent--result0":     "Whether the outputs were locked or unlocked",
+
+	// MatchBlockFilterCmd help.
+	"matchblockfilter--synopsis": "Returns whether any of the passed scripts match the basic committed filter of a block.\n" +
+		"A match may be a false positive, while no match means the block does not involve any of the scripts.",
+	"matchblockfilter-blockhash": "The hash of the block",
+	"matchblockfilter-scripts":   "The hex-encoded output scripts to match against the filter",
+	"matchblockfilter--result0":  "Whether any of the scripts match the filter",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// PreciousBlockCmd help.
+	"preciousblock--synopsis": "Treats a block as if it were received before other blocks with the same work.\n" +
+		"The chain ending at the block becomes the main chain when it has as much work as the current main chain.\n" +
+		"A block marked later is preferred over one marked earlier, and the preference is dropped once the main chain gains more work.",
+	"preciousblock-blockhash": "The hash of the block to mark as precious",
+
+	// PrioritiseTransactionCmd help.
+	"prioritisetransaction--synopsis": "Adds a virtual fee to a transaction to change its priority for mining and in the mempool.\n" +
+		"The fee delta is added to the fee the transaction pays by the mempool fee policies, such as the minimum relay fee, replacements and eviction, and when selecting transactions for block templates.\n" +
+		"Deltas accumulate across calls, apply to transactions which are not in the mempool yet once they arrive, and are kept until the transaction is confirmed.",
+	"prioritisetransaction-txid":     "The hash of the transaction",
+	"prioritisetransaction-feedelta": "The fee in satoshi to add to the fee of the transaction, or subtract when negative",
+	"prioritisetransaction--result0": "Always true",
+
+	// ScanTxOutSetCmd help.
+	"scantxoutset--synopsis": "Scans the utxo set for outputs matching the passed descriptors.\n" +
+		"Only one scan can run at a time.  Its progress can be queried with the status action and it can be stopped with the abort action.\n" +
+		"Ranged descriptors are expanded to the derivation indexes 0 to 1000 unless a range is given.",
+	"scantxoutset-action":      "The action to perform: start to run a scan and wait for its result, abort to stop the running scan or status to query its progress",
+	"scantxoutset-scanobjects": "The descriptors to scan for as strings or objects with the descriptor and the range of keys to derive (required for the start action)",
+	"scantxoutset--condition0": "action=start",
+	"scantxoutset--condition1": "action=status",
+	"scantxoutset--condition2": "action=abort",
+	"scantxoutset--result2":    "Whether a running scan was aborted",
+
+	// ScanObject help.
+	"scanobject-desc":  "The descriptor",
+	"scanobject-range": "The end or the [begin,end] range of the keys to derive for a ranged descriptor",
+
+	// ScanTxOutSetResult help.
+	"scantxoutsetresult-success":      "Whether the scan completed without being aborted",
+	"scantxoutsetresult-txouts":       "The number of outputs scanned",
+	"scantxoutsetresult-height":       "The height of the block the scanned utxo set is the state of",
+	"scantxoutsetresult-bestblock":    "The hash of the block the scanned utxo set is the state of",
+	"scantxoutsetresult-unspents":     "The unspent outputs matching the descriptors",
+	"scantxoutsetresult-total_amount": "The total amount of the unspent outputs in BTC",
+
+	// ScanTxOutSetUnspent help.
+	"scantxoutsetunspent-txid":         "The hash of the transaction of the output",
+	"scantxoutsetunspent-vout":         "The index of the output",
+	"scantxoutsetunspent-scriptPubKey": "The hex-encoded public key script of the output",
+	"scantxoutsetunspent-desc":         "The descriptor of the output script with derived keys",
+	"scantxoutsetunspent-amount":       "The value of the output in BTC",
+	"scantxoutsetunspent-coinbase":     "Whether the output was created by a coinbase transaction",
+	"scantxoutsetunspent-height":       "The height of the block containing the transaction of the output",
+
+	// ScanTxOutSetStatusResult help.
+	"scantxoutsetstatusresult-progress": "The percentage of the utxo set scanned so far",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
 		"Transactions pulled from the mempool will have the 'confirmations' field set to 0.\n" +
+		"Large result sets can be paged through by passing the txid of the last transaction of the previous page as the 'after' parameter, which unlike 'skip' is not affected by new transactions involving the address.\n" +
 		"Usage of this RPC requires the optional --addrindex flag to be activated, otherwise all responses will simply return with an error stating the address index has not yet been built.\n" +
 		"Similarly, until the address index has caught up with the current best height, all requests will return an error response in order to avoid serving stale data.",
-	"searchrawtransactions-address":     "The Bitcoin address to search for",
-	"searchrawtransactions-verbose":     "Specifies the transaction is returned as a JSON object instead of hex-encoded string",
-	"searchrawtransactions--condition0": "verbose=0",
-	"searchrawtransactions--condition1": "verbose=1",
-	"searchrawtransactions-skip":        "The number of leading transactions to leave out of the final response",
-	"searchrawtransactions-count":       "The maximum number of transactions to return",
-	"searchrawtransactions-vinextra":    "Specify that extra data from previous output will be returned in vin",
-	"searchrawtransactions-reverse":     "Specifies that the transactions should be returned in reverse chronological order",
-	"searchrawtransactions-filteraddrs": "Address list.  Only inputs or outputs with matching address will be returned",
-	"searchrawtransactions--result0":    "Hex-encoded serialized transaction",
+	"searchrawtransactions-address":        "The Bitcoin address to search for",
+	"searchrawtransactions-verbose":        "Specifies the transaction is returned as a JSON object instead of hex-encoded string",
+	"searchrawtransactions--condition0":    "verbose=0",
+	"searchrawtransactions--condition1":    "verbose=1",
+	"searchrawtransactions-skip":           "The number of leading transactions to leave out of the final response",
+	"searchrawtransactions-count":          "The maximum number of transactions to return",
+	"searchrawtransactions-vinextra":       "Specify that extra data from previous output will be returned in vin",
+	"searchrawtransactions-reverse":        "Specifies that the transactions should be returned in reverse chronological order",
+	"searchrawtransactions-filteraddrs":    "Address list.  Only inputs or outputs with matching address will be returned",
+	"searchrawtransactions-includemempool": "Specifies that transactions in the mempool should be included",
+	"searchrawtransactions-after":          "The txid of the transaction to continue after in the requested order, which must be one of the returned transactions",
+	"searchrawtransactions--result0":       "Hex-encoded serialized transaction",
 
 	// SendRawTransactionCmd help.
 	"sendrawtransaction--synopsis":    "Submits the serialized, hex-encoded transaction to the local peer and relays it to the network.",
@@ -580,11 +1442,33 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction--result0":     "The hash of the transaction",
 	"allowhighfeesormaxfeerate-value": "Either the boolean value for the allowhighfees parameter in bitcoind < v0.19.0 or the numerical value for the maxfeerate field in bitcoind v0.19.0 and later",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans a subnet or removes its ban.  Peers in a banned subnet are disconnected and their connections are refused until the ban expires.",
+	"setban-subnet":    "The subnet in CIDR notation, such as 192.168.0.0/16, or a single IP address",
+	"setban-command":   "'add' to ban the subnet or 'remove' to remove its ban",
+	"setban-bantime":   "The number of seconds the ban lasts, or 0 for the configured ban duration (see --banduration)",
+	"setban-absolute":  "Whether bantime is the time the ban expires in seconds since 1 Jan 1970 GMT instead",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// MiningPayout help.
+	"miningpayout-desc":   "The output descriptor to pay to, which must have an address or be a pk() descriptor",
+	"miningpayout-weight": "The weight of the payout, which is chosen with a probability proportional to it (default: 1)",
+
+	// MiningPayoutResult help.
+	"miningpayoutresult-desc":   "The output descriptor paid to, with its private keys replaced by their public keys",
+	"miningpayoutresult-weight": "The weight of the payout",
+
+	// SetMiningPayoutsCmd help.
+	"setminingpayouts--synopsis": "Replaces the payouts the coinbase of the blocks generated by the CPU miner pays to, including the ones specified with --miningaddr and --miningpayout.\n" +
+		"Each generated block pays to one of the payouts chosen at random with a probability proportional to its weight.\n" +
+		"Ranged descriptors pay to the script derived at the next index each time they are chosen, starting over at index 0 whenever the payouts are set.",
+	"setminingpayouts-payouts":  "The payouts, or an empty array to restore the payouts of the configuration",
+	"setminingpayouts--result0": "The payouts in effect",
+
 	// SignMessageWithPrivKeyCmd help.
 	"signmessagewithprivkey--synopsis": "Sign a message with the private key of an address",
 	"signmessagewithprivkey-privkey":   "The private key to sign the message with",
@@ -606,6 +1490,11 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// SubmitHeaderCmd help.
+	"submitheader--synopsis": "Decodes the given serialized, hex-encoded block header and adds it to the block index if it is valid.\n" +
+		"The previous header must already be known.  The block itself can be downloaded with getblockfrompeer.",
+	"submitheader-hexdata": "Serialized, hex-encoded block header",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid":         "Whether or not the address is valid",
 	"validateaddresschainresult-address":         "The bitcoin address (only when isvalid is true)",
@@ -614,6 +1503,13 @@ var helpDescsEnUS = map[string]string{
 	"validateaddresschainresult-witness_version": "The version number of the witness program",
 	"validateaddresschainresult-witness_program": "The hex value of the witness program",
 
+	// UtxoUpdatePsbtCmd help.
+	"utxoupdatepsbt--synopsis": "Updates the provided base64-encoded partially signed transaction (PSBT) with the outputs its inputs spend from the mempool, the transaction index and the utxo set, and with the scripts and key derivations of the inputs and outputs described by the passed descriptors.\n" +
+		"The transactions spent by the inputs are added when they are found in the mempool or the transaction index, otherwise only the outputs spent by segwit inputs are added from the utxo set.",
+	"utxoupdatepsbt-psbt":        "The base64-encoded PSBT",
+	"utxoupdatepsbt-descriptors": "The descriptors of the inputs and outputs as strings or objects with the descriptor and the range of keys to derive (default range 0-1000)",
+	"utxoupdatepsbt--result0":    "The updated base64-encoded PSBT",
+
 	// ValidateAddressCmd help.
 	"validateaddress--synopsis": "Verify an address is valid.",
 	"validateaddress-address":   "Bitcoin address to validate",
@@ -622,10 +1518,13 @@ var helpDescsEnUS = map[string]string{
 	"verifychain--synopsis": "Verifies the block chain database.\n" +
 		"The actual checks performed by the checklevel parameter are implementation specific.\n" +
 		"For btcd this is:\n" +
-		"checklevel=0 - Look up each block and ensure it can be loaded from the database.\n" +
-		"checklevel=1 - Perform basic context-free sanity checks on each block.",
+		"checklevel=0 - Ensure each block is in the block index and links to its parent.\n" +
+		"checklevel=1 - Ensure each block is stored in the database with the header of the block index.\n" +
+		"checklevel=2 - Read each block again, which verifies its checksum, and perform basic context-free sanity checks.\n" +
+		"checklevel=3 - Disconnect the blocks from the utxo set in memory to verify their undo data.\n" +
+		"checklevel=4 - Connect the blocks again in memory to perform all consensus checks.",
 	"verifychain-checklevel": "How thorough the block verification is",
-	"verifychain-checkdepth": "The number of blocks to check",
+	"verifychain-checkdepth": "The number of blocks to check, where 0 checks all blocks, which is only supported up to checklevel 2",
 	"verifychain--result0":   "Whether or not the chain verified",
 
 	// VerifyMessageCmd help.
@@ -647,6 +1546,26 @@ var helpDescsEnUS = map[string]string{
 	// StopNotifyBlocksCmd help.
 	"stopnotifyblocks--synopsis": "Cancel registered notifications for whenever a block is connected or disconnected from the main (best) chain.",
 
+	// NotifyChainEventsCmd help.
+	"notifychainevents--synopsis":    "Send a chainevent notification with a sequence number and the spent outputs (undo data) whenever a block is connected to or disconnected from the main chain, or a block is finalized by a checkpoint.",
+	"notifychainevents-includeblock": "Include the serialized, hex-encoded block in the notifications of connected and disconnected blocks",
+
+	// StopNotifyChainEventsCmd help.
+	"stopnotifychainevents--synopsis": "Cancel registered chainevent notifications.",
+
+	// NotifyFeeHistogramCmd help.
+	"notifyfeehistogram--synopsis": "Send a feehistogram notification with the distribution of the fee rates of the transactions in the mempool right away and then periodically.",
+	"notifyfeehistogram-interval":  "The number of seconds between the notifications",
+
+	// StopNotifyFeeHistogramCmd help.
+	"stopnotifyfeehistogram--synopsis": "Cancel registered feehistogram notifications.",
+
+	// NotifyTxReplacedCmd help.
+	"notifytxreplaced--synopsis": "Send a txreplaced notification whenever transactions in the mempool are replaced by a transaction or package paying a higher fee.",
+
+	// StopNotifyTxReplacedCmd help.
+	"stopnotifytxreplaced--synopsis": "Cancel registered txreplaced notifications.",
+
 	// NotifyNewTransactionsCmd help.
 	"notifynewtransactions--synopsis": "Send either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool.",
 	"notifynewtransactions-verbose":   "Specifies which type of notification to receive. If verbose is true, then the caller receives txacceptedverbose, otherwise the caller receives txaccepted",
@@ -719,21 +1638,48 @@ var helpDescsEnUS = map[string]string{
 	"versionresult-buildmetadata": "Metadata about the current build",
 
 	// TestMempoolAcceptCmd help.
-	"testmempoolaccept--synopsis":  "Returns result of mempool acceptance tests indicating if raw transaction(s) would be accepted by mempool.",
-	"testmempoolaccept-rawtxns":    "Serialized transactions to test.",
+	"testmempoolaccept--synopsis":  "Returns result of mempool acceptance tests indicating if raw transaction(s) would be accepted by mempool.\nMultiple transactions are tested as a package without being added to the mempool, so they may spend the outputs of the transactions that precede them.\nEach transaction must pay the minimum relay fee on its own, and the transactions following the first one which is not allowed are not evaluated.",
+	"testmempoolaccept-rawtxns":    "Serialized transactions to test, sorted so parents precede their children (at most 25).",
 	"testmempoolaccept-maxfeerate": "Maximum acceptable fee rate in BTC/kB",
 
 	// TestMempoolAcceptCmd result help.
-	"testmempoolacceptresult-txid":             "The transaction hash in hex.",
-	"testmempoolacceptresult-wtxid":            "The transaction witness hash in hex.",
-	"testmempoolacceptresult-package-error":    "Package validation error, if any (only possible if rawtxs had more than 1 transaction).",
-	"testmempoolacceptresult-allowed":          "Whether the transaction would be accepted to the mempool.",
-	"testmempoolacceptresult-vsize":            "Virtual transaction size as defined in BIP 141.(only present when 'allowed' is true)",
-	"testmempoolacceptresult-reject-reason":    "Rejection string (only present when 'allowed' is false).",
-	"testmempoolacceptresult-fees":             "Transaction fees (only present if 'allowed' is true).",
-	"testmempoolacceptfees-base":               "Transaction fees (only present if 'allowed' is true).",
-	"testmempoolacceptfees-effective-feerate":  "The effective feerate in BTC per KvB.",
-	"testmempoolacceptfees-effective-includes": "Transactions whose fees and vsizes are included in effective-feerate. Each item is a transaction wtxid in hex.",
+	"testmempoolacceptresult-txid":               "The transaction hash in hex.",
+	"testmempoolacceptresult-wtxid":              "The transaction witness hash in hex.",
+	"testmempoolacceptresult-package-error":      "Package validation error, if any (only possible if rawtxs had more than 1 transaction).",
+	"testmempoolacceptresult-allowed":            "Whether the transaction would be accepted to the mempool (false without a reject reason when it wasn't evaluated because of a failure of another transaction).",
+	"testmempoolacceptresult-vsize":              "Virtual transaction size as defined in BIP 141.(only present when 'allowed' is true)",
+	"testmempoolacceptresult-reject-reason":      "Rejection string (only present when 'allowed' is false).",
+	"testmempoolacceptresult-fees":               "Transaction fees (only present if 'allowed' is true).",
+	"testmempoolacceptresult-replacement":        "The replacement rule the transaction violated (only present when it was rejected as a replacement of mempool transactions).",
+	"testmempoolacceptreplacement-rule":          "The name of the violated replacement rule.",
+	"testmempoolacceptreplacement-bip125-rule":   "The number of the violated BIP 125 rule (only present for rules of BIP 125).",
+	"testmempoolacceptreplacement-conflicts":     "The txids of the mempool transactions the rule was violated against.",
+	"testmempoolacceptreplacement-fee":           "The fee of the replacement in BTC (only present when known).",
+	"testmempoolacceptreplacement-feerate":       "The fee rate of the replacement in BTC per KvB (only present when known).",
+	"testmempoolacceptreplacement-fee-delta":     "The additional fee in BTC the replacement has to pay (only present for the absolute fee rules).",
+	"testmempoolacceptreplacement-feerate-delta": "The additional fee rate in BTC per KvB the replacement has to pay (only present for the fee rate rule).",
+	"testmempoolacceptfees-base":                 "Transaction fees (only present if 'allowed' is true).",
+	"testmempoolacceptfees-effective-feerate":    "The effective feerate in BTC per KvB.",
+	"testmempoolacceptfees-effective-includes":   "Transactions whose fees and vsizes are included in effective-feerate. Each item is a transaction wtxid in hex.",
+
+	// SubmitPackageCmd help.
+	"submitpackage--synopsis":     "Submits a package of raw transactions to the mempool and relays the accepted ones.\nThe package must consist of a child transaction, which comes last, and its parents sorted so that parents precede their children.\nParents which don't pay enough fees on their own are accepted when the fee rate of the package is high enough.",
+	"submitpackage-rawtxns":       "Serialized transactions of the package.",
+	"submitpackage-maxfeerate":    "Reject transactions whose fee rate is higher than this value in BTC/kvB, 0 disables the check",
+	"submitpackage-maxburnamount": "Reject transactions with provably unspendable outputs whose value is higher than this value in BTC",
+
+	// SubmitPackageCmd result help.
+	"submitpackageresult-package_msg":           "The transaction package result message, \"success\" indicates all transactions were accepted into or are already in the mempool.",
+	"submitpackageresult-tx-results":            "The results of the transactions of the package keyed by their wtxids",
+	"submitpackageresult-tx-results--key":       "wtxid",
+	"submitpackageresult-tx-results--value":     "An object describing the result of the transaction",
+	"submitpackageresult-tx-results--desc":      "The result of the transaction",
+	"submitpackageresult-replaced-transactions": "The txids of the transactions replaced by the package",
+	"submitpackagetxresult-txid":                "The transaction hash in hex.",
+	"submitpackagetxresult-other-wtxid":         "The wtxid of a different transaction with the same txid but different witness found in the mempool, if any.",
+	"submitpackagetxresult-vsize":               "Virtual transaction size as defined in BIP 141 (only present when the transaction is in the mempool).",
+	"submitpackagetxresult-fees":                "Transaction fees (only present when the transaction is in the mempool).",
+	"submitpackagetxresult-error":               "The transaction error string, if it was rejected by the mempool.",
 
 	// GetTxSpendingPrevOutCmd help.
 	"gettxspendingprevout--synopsis": "Scans the mempool to find transactions spending any of the given outputs",
@@ -752,50 +1698,103 @@ var helpDescsEnUS = map[string]string{
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
 	"addnode":                nil,
+	"analyzepsbt":            {(*btcjson.AnalyzePsbtResult)(nil)},
+	"clearbanned":            nil,
+	"collectprofile":         {(*btcjson.CollectProfileResult)(nil)},
+	"confirmreorg":           nil,
 	"createrawtransaction":   {(*string)(nil)},
 	"debuglevel":             {(*string)(nil), (*string)(nil)},
+	"decodepsbt":             {(*btcjson.DecodePsbtResult)(nil)},
 	"decoderawtransaction":   {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":           {(*btcjson.DecodeScriptResult)(nil)},
+	"debugscript":            {(*btcjson.DebugScriptResult)(nil)},
+	"deriveaddresses":        {(*[]string)(nil)},
+	"dumpstate":              {(*btcjson.DumpStateResult)(nil)},
 	"estimatefee":            {(*float64)(nil)},
+	"estimatesmartfee":       {(*btcjson.EstimateSmartFeeResult)(nil)},
+	"fundrawtransaction":     {(*btcjson.FundRawTransactionHexResult)(nil)},
 	"generate":               {(*[]string)(nil)},
+	"generateblock":          {(*btcjson.GenerateBlockResult)(nil)},
+	"generatetoaddress":      {(*[]string)(nil)},
+	"generatetodescriptor":   {(*[]string)(nil)},
 	"getaddednodeinfo":       {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":      {(*btcjson.GetAddressBalanceResult)(nil)},
+	"getaddressutxos":        {(*[]btcjson.GetAddressUtxosResult)(nil)},
 	"getbestblock":           {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":       {(*string)(nil)},
 	"getblock":               {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
 	"getblockcount":          {(*int64)(nil)},
+	"getblockfilter":         {(*btcjson.GetBlockFilterResult)(nil)},
+	"getblockfrompeer":       {(*struct{})(nil)},
 	"getblockhash":           {(*string)(nil)},
+	"getblockhashes":         {(*[]string)(nil)},
+	"getblockstats":          {(*btcjson.GetBlockStatsResult)(nil)},
 	"getblockheader":         {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockheaders":        {(*[]btcjson.GetBlockHeaderVerboseResult)(nil), (*[]string)(nil), (*string)(nil)},
 	"getblocktemplate":       {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
 	"getblockchaininfo":      {(*btcjson.GetBlockChainInfoResult)(nil)},
 	"getchaintips":           {(*[]btcjson.GetChainTipsResult)(nil)},
+	"getchaintxstats":        {(*btcjson.GetChainTxStatsResult)(nil)},
 	"getcfilter":             {(*string)(nil)},
 	"getcfilterheader":       {(*string)(nil)},
 	"getconnectioncount":     {(*int32)(nil)},
 	"getcurrentnet":          {(*uint32)(nil)},
+	"getdescriptorinfo":      {(*btcjson.GetDescriptorInfoResult)(nil)},
 	"getdifficulty":          {(*float64)(nil)},
 	"getgenerate":            {(*bool)(nil)},
 	"gethashespersec":        {(*float64)(nil)},
 	"getheaders":             {(*[]string)(nil)},
+	"getindexinfo":           {(*map[string]btcjson.GetIndexInfoResult)(nil)},
 	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolancestors":    {(*[]string)(nil), (*map[string]btcjson.GetMempoolEntryResult)(nil)},
+	"getmempooldescendants":  {(*[]string)(nil), (*map[string]btcjson.GetMempoolEntryResult)(nil)},
+	"getmempoolentry":        {(*btcjson.GetMempoolEntryResult)(nil)},
+	"getmemoryinfo":          {(*btcjson.GetMemoryInfoResult)(nil)},
 	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
 	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkinfo":         {(*btcjson.GetNetworkInfoResult)(nil)},
 	"getnetworkhashps":       {(*float64)(nil)},
 	"getnodeaddresses":       {(*[]btcjson.GetNodeAddressesResult)(nil)},
+	"getorphaninfo":          {(*btcjson.GetOrphanInfoResult)(nil)},
 	"getpeerinfo":            {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getpeerreputation":      {(*[]btcjson.GetPeerReputationResult)(nil)},
+	"getpendingreorg":        {(*btcjson.GetPendingReorgResult)(nil)},
 	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"getspentinfo":           {(*btcjson.GetSpentInfoResult)(nil)},
 	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutsetinfo":        {(*btcjson.GetTxOutSetInfoResult)(nil)},
+	"getunbroadcast":         {(*[]btcjson.GetUnbroadcastResult)(nil)},
+	"getzmqnotifications":    {(*[]btcjson.ZmqNotification)(nil)},
 	"node":                   nil,
+	"getrpcinfo":             {(*btcjson.GetRPCInfoResult)(nil)},
+	"getrpcstats":            {(*btcjson.GetRPCStatsResult)(nil)},
 	"help":                   {(*string)(nil), (*string)(nil)},
+	"importdescriptors":      {(*[]btcjson.ImportDescriptorsResult)(nil)},
+	"listbanned":             {(*[]btcjson.ListBannedResult)(nil)},
+	"listdescriptors":        {(*btcjson.ListDescriptorsResult)(nil)},
+	"listlockunspent":        {(*[]btcjson.TransactionInput)(nil)},
+	"listunspent":            {(*[]btcjson.ListUnspentResult)(nil)},
+	"lockunspent":            {(*bool)(nil)},
+	"matchblockfilter":       {(*bool)(nil)},
 	"ping":                   nil,
+	"preciousblock":          nil,
+	"prioritisetransaction":  {(*bool)(nil)},
+	"reloadconfig":           {(*[]btcjson.ReloadConfigChange)(nil)},
+	"scantxoutset":           {(*btcjson.ScanTxOutSetResult)(nil), (*btcjson.ScanTxOutSetStatusResult)(nil), (*bool)(nil)},
 	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":     {(*string)(nil)},
+	"setban":                 nil,
 	"setgenerate":            nil,
+	"setminingpayouts":       {(*[]btcjson.MiningPayoutResult)(nil)},
 	"signmessagewithprivkey": {(*string)(nil)},
 	"stop":                   {(*string)(nil)},
 	"submitblock":            {nil, (*string)(nil)},
+	"submitheader":           nil,
+	"submitpackage":          {(*btcjson.SubmitPackageResult)(nil)},
 	"uptime":                 {(*int64)(nil)},
+	"utxoupdatepsbt":         {(*string)(nil)},
 	"validateaddress":        {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":            {(*bool)(nil)},
 	"verifymessage":          {(*bool)(nil)},
@@ -808,6 +1807,12 @@ var rpcResultTypes = map[string][]interface{}{
 	"session":                   {(*btcjson.SessionResult)(nil)},
 	"notifyblocks":              nil,
 	"stopnotifyblocks":          nil,
+	"notifychainevents":         nil,
+	"stopnotifychainevents":     nil,
+	"notifyfeehistogram":        nil,
+	"stopnotifyfeehistogram":    nil,
+	"notifytxreplaced":          nil,
+	"stopnotifytxreplaced":      nil,
 	"notifynewtransactions":     nil,
 	"stopnotifynewtransactions": nil,
 	"notifyreceived":            nil,
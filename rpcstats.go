@@ -0,0 +1,252 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// rpcLatencyBuckets are the upper bounds of the buckets of the RPC latency
+// histograms.  Requests which take longer than the last bound are counted in
+// an additional overflow bucket.
+var rpcLatencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// rpcStatsVarOnce ensures the RPC statistics are only published once as an
+// expvar variable.
+var rpcStatsVarOnce sync.Once
+
+// rpcMethodStats houses the statistics about the requests of a single RPC
+// method.
+type rpcMethodStats struct {
+	calls     uint64
+	errors    uint64
+	slowCalls uint64
+	totalTime time.Duration
+	maxTime   time.Duration
+
+	// buckets holds the number of requests per latency bucket with the
+	// last entry counting the requests above the highest bound.
+	buckets [len(rpcLatencyBuckets) + 1]uint64
+}
+
+// rpcActiveCmd houses a request which is being processed by the RPC server.
+type rpcActiveCmd struct {
+	method  string
+	started time.Time
+}
+
+// rpcStats records the latency of the requests served by the RPC server per
+// method and logs requests which are slower than a threshold.  It also tracks
+// the requests which are being processed.
+type rpcStats struct {
+	slowThreshold time.Duration
+
+	mtx        sync.Mutex
+	methods    map[string]*rpcMethodStats
+	active     map[uint64]rpcActiveCmd
+	nextActive uint64
+}
+
+// newRPCStats returns a new RPC statistics recorder which logs requests taking
+// longer than the passed threshold.  A threshold of zero disables the slow
+// request log.
+func newRPCStats(slowThreshold time.Duration) *rpcStats {
+	return &rpcStats{
+		slowThreshold: slowThreshold,
+		methods:       make(map[string]*rpcMethodStats),
+		active:        make(map[uint64]rpcActiveCmd),
+	}
+}
+
+// begin adds a request for the passed method which started at the passed time
+// to the active requests and returns an id to remove it with once it finishes.
+//
+// This function is safe for concurrent access.
+func (s *rpcStats) begin(method string, started time.Time) uint64 {
+	s.mtx.Lock()
+	id := s.nextActive
+	s.nextActive++
+	s.active[id] = rpcActiveCmd{method: method, started: started}
+	s.mtx.Unlock()
+	return id
+}
+
+// end removes the request with the passed id from the active requests.
+//
+// This function is safe for concurrent access.
+func (s *rpcStats) end(id uint64) {
+	s.mtx.Lock()
+	delete(s.active, id)
+	s.mtx.Unlock()
+}
+
+// activeCommands returns the requests which are being processed along with how
+// long they have been running, starting with the oldest.
+//
+// This function is safe for concurrent access.
+func (s *rpcStats) activeCommands() []btcjson.RPCActiveCommand {
+	s.mtx.Lock()
+	active := make([]rpcActiveCmd, 0, len(s.active))
+	for _, cmd := range s.active {
+		active = append(active, cmd)
+	}
+	s.mtx.Unlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].started.Before(active[j].started)
+	})
+	now := time.Now()
+	cmds := make([]btcjson.RPCActiveCommand, 0, len(active))
+	for _, cmd := range active {
+		cmds = append(cmds, btcjson.RPCActiveCommand{
+			Method:   cmd.method,
+			Duration: now.Sub(cmd.started).Microseconds(),
+		})
+	}
+	return cmds
+}
+
+// record adds a request for the passed method which took the passed duration
+// to the statistics and returns whether the request was slow.
+//
+// This function is safe for concurrent access.
+func (s *rpcStats) record(method string, elapsed time.Duration, err error) bool {
+	slow := s.slowThreshold > 0 && elapsed >= s.slowThreshold
+
+	s.mtx.Lock()
+	stats, ok := s.methods[method]
+	if !ok {
+		stats = new(rpcMethodStats)
+		s.methods[method] = stats
+	}
+	stats.calls++
+	if err != nil {
+		stats.errors++
+	}
+	if slow {
+		stats.slowCalls++
+	}
+	stats.totalTime += elapsed
+	if elapsed > stats.maxTime {
+		stats.maxTime = elapsed
+	}
+	bucket := sort.Search(len(rpcLatencyBuckets), func(i int) bool {
+		return elapsed <= rpcLatencyBuckets[i]
+	})
+	stats.buckets[bucket]++
+	s.mtx.Unlock()
+
+	rpcCallDuration.With(method).Observe(elapsed.Seconds())
+	if err != nil {
+		rpcCallErrors.With(method).Inc()
+	}
+
+	return slow
+}
+
+// snapshot returns the current statistics of all methods which have been
+// requested so far sorted by method.
+//
+// This function is safe for concurrent access.
+func (s *rpcStats) snapshot() *btcjson.GetRPCStatsResult {
+	s.mtx.Lock()
+	result := &btcjson.GetRPCStatsResult{
+		SlowThreshold: s.slowThreshold.Seconds(),
+		Methods:       make([]btcjson.RPCMethodStats, 0, len(s.methods)),
+	}
+	for method, stats := range s.methods {
+		histogram := make([]btcjson.RPCLatencyBucket, 0,
+			len(stats.buckets))
+		for i, count := range stats.buckets {
+			bound := "+Inf"
+			if i < len(rpcLatencyBuckets) {
+				bound = rpcLatencyBuckets[i].String()
+			}
+			histogram = append(histogram, btcjson.RPCLatencyBucket{
+				UpperBound: bound,
+				Count:      count,
+			})
+		}
+
+		result.Methods = append(result.Methods, btcjson.RPCMethodStats{
+			Method:    method,
+			Calls:     stats.calls,
+			Errors:    stats.errors,
+			SlowCalls: stats.slowCalls,
+			TotalTime: stats.totalTime.Seconds(),
+			MaxTime:   stats.maxTime.Seconds(),
+			Histogram: histogram,
+		})
+	}
+	s.mtx.Unlock()
+
+	sort.Slice(result.Methods, func(i, j int) bool {
+		return result.Methods[i].Method < result.Methods[j].Method
+	})
+	return result
+}
+
+// publish makes the statistics available as the rpcstats expvar variable,
+// which is served along with the profiling handlers when the profile server is
+// enabled.  Only the statistics of the first RPC server are published.
+func (s *rpcStats) publish() {
+	rpcStatsVarOnce.Do(func() {
+		expvar.Publish("rpcstats", expvar.Func(func() interface{} {
+			return s.snapshot()
+		}))
+	})
+}
+
+// redactParams returns a description of the passed request parameters which
+// only contains their JSON types and the lengths of strings, arrays, and
+// objects, so it can be logged without exposing addresses, transactions, or
+// passphrases passed to the server.
+func redactParams(params []json.RawMessage) string {
+	descs := make([]string, 0, len(params))
+	for _, param := range params {
+		var value interface{}
+		if err := json.Unmarshal(param, &value); err != nil {
+			descs = append(descs, "invalid")
+			continue
+		}
+
+		var desc string
+		switch v := value.(type) {
+		case nil:
+			desc = "null"
+		case bool:
+			desc = "bool"
+		case float64:
+			desc = "number"
+		case string:
+			desc = "string(" + strconv.Itoa(len(v)) + ")"
+		case []interface{}:
+			desc = "array(" + strconv.Itoa(len(v)) + ")"
+		case map[string]interface{}:
+			desc = "object(" + strconv.Itoa(len(v)) + ")"
+		}
+		descs = append(descs, desc)
+	}
+	return "[" + strings.Join(descs, ", ") + "]"
+}
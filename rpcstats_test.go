@@ -0,0 +1,95 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRPCStats ensures RPC requests are counted in the expected latency buckets
+// and that the parameters of slow requests are redacted.
+func TestRPCStats(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	stats := newRPCStats(time.Second)
+	require.False(stats.record("getblock", 3*time.Millisecond, nil))
+	require.True(stats.record("getblock", 2*time.Second,
+		errors.New("failed")))
+	require.True(stats.record("getblock", time.Hour, nil))
+	require.False(stats.record("getbestblock", time.Millisecond, nil))
+
+	result := stats.snapshot()
+	require.Equal(1.0, result.SlowThreshold)
+	require.Len(result.Methods, 2)
+
+	best := result.Methods[0]
+	require.Equal("getbestblock", best.Method)
+	require.Equal(uint64(1), best.Calls)
+	require.Equal(uint64(1), best.Histogram[0].Count)
+
+	getBlock := result.Methods[1]
+	require.Equal("getblock", getBlock.Method)
+	require.Equal(uint64(3), getBlock.Calls)
+	require.Equal(uint64(1), getBlock.Errors)
+	require.Equal(uint64(2), getBlock.SlowCalls)
+	require.Equal(time.Hour.Seconds(), getBlock.MaxTime)
+	require.Len(getBlock.Histogram, len(rpcLatencyBuckets)+1)
+
+	wantCounts := map[string]uint64{"5ms": 1, "5s": 1, "+Inf": 1}
+	for _, bucket := range getBlock.Histogram {
+		require.Equal(wantCounts[bucket.UpperBound], bucket.Count,
+			"bucket %s", bucket.UpperBound)
+	}
+
+	params := []json.RawMessage{
+		json.RawMessage(`"secret passphrase"`),
+		json.RawMessage(`60`),
+		json.RawMessage(`["a", "b"]`),
+		json.RawMessage(`{"key": "value"}`),
+		json.RawMessage(`true`),
+		json.RawMessage(`null`),
+		json.RawMessage(`{`),
+	}
+	require.Equal("[string(17), number, array(2), object(1), bool, "+
+		"null, invalid]", redactParams(params))
+}
+
+// TestRPCActiveCommands ensures the requests being processed are reported
+// oldest first until they finish.
+func TestRPCActiveCommands(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	stats := newRPCStats(0)
+	now := time.Now()
+	scan := stats.begin("scantxoutset", now.Add(-time.Minute))
+	info := stats.begin("getrpcinfo", now)
+	block := stats.begin("getblock", now.Add(-time.Second))
+
+	active := stats.activeCommands()
+	require.Len(active, 3)
+	require.Equal("scantxoutset", active[0].Method)
+	require.Equal("getblock", active[1].Method)
+	require.Equal("getrpcinfo", active[2].Method)
+	require.GreaterOrEqual(active[0].Duration,
+		time.Minute.Microseconds())
+
+	stats.end(scan)
+	stats.end(block)
+	active = stats.activeCommands()
+	require.Len(active, 1)
+	require.Equal("getrpcinfo", active[0].Method)
+
+	stats.end(info)
+	require.Empty(stats.activeCommands())
+}
@@ -0,0 +1,519 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/watchonly"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// defaultFundConfTarget is the confirmation target the fee rate of the
+// transactions funded by the fundrawtransaction RPC is estimated for when
+// neither a fee rate nor a confirmation target is given.
+const defaultFundConfTarget = 6
+
+// changeTypeClasses maps the change types of the fundrawtransaction RPC to the
+// classes of the scripts change of the type is sent to.
+var changeTypeClasses = map[btcjson.ChangeType]txscript.ScriptClass{
+	btcjson.ChangeTypeLegacy:     txscript.PubKeyHashTy,
+	btcjson.ChangeTypeP2SHSegWit: txscript.ScriptHashTy,
+	btcjson.ChangeTypeBech32:     txscript.WitnessV0PubKeyHashTy,
+	btcjson.ChangeTypeBech32m:    txscript.WitnessV1TaprootTy,
+}
+
+// walletError returns the RPC error for the passed error of the watch-only
+// wallet.
+func walletError(err error) *btcjson.RPCError {
+	code := btcjson.ErrRPCWallet
+	switch {
+	case errors.Is(err, watchonly.ErrInsufficientFunds):
+		code = btcjson.ErrRPCWalletInsufficientFunds
+	case errors.Is(err, watchonly.ErrUnknownOutput),
+		errors.Is(err, watchonly.ErrNotLocked):
+		code = btcjson.ErrRPCInvalidParameter
+	}
+	return &btcjson.RPCError{
+		Code:    code,
+		Message: err.Error(),
+	}
+}
+
+// parseImportDescriptorsRequest converts the passed request of the
+// importdescriptors RPC to a request to import a descriptor into the
+// watch-only wallet, along with the warnings about the request.
+func parseImportDescriptorsRequest(s *rpcServer, r *btcjson.ImportDescriptorsRequest) (*watchonly.ImportRequest, []string, error) {
+	// Like Bitcoin Core, the checksum is required so a mistyped descriptor
+	// can't result in watching scripts which aren't the intended ones.
+	desc, err := parseDescriptor(r.Descriptor, true, s.cfg.ChainParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	if desc.HasPrivateKeys() {
+		return nil, nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCWallet,
+			Message: "Cannot import private keys into the watch-only " +
+				"wallet",
+		}
+	}
+	begin, end, err := descriptorRange(desc, r.Range)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := &watchonly.ImportRequest{
+		Descriptor: desc,
+		RangeStart: begin,
+		RangeEnd:   end,
+		NextIndex:  begin,
+		Active:     r.Active != nil && *r.Active,
+		Internal:   r.Internal != nil && *r.Internal,
+	}
+	if r.NextIndex != nil {
+		switch {
+		case !desc.IsRange():
+			return nil, nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Next index should not be specified for " +
+					"an un-ranged descriptor",
+			}
+
+		case *r.NextIndex < int(begin) || *r.NextIndex > int(end):
+			return nil, nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "next_index is out of range",
+			}
+		}
+		req.NextIndex = uint32(*r.NextIndex)
+	}
+	if req.Active && !desc.IsRange() {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Active descriptors must be ranged",
+		}
+	}
+	if req.Internal && r.Label != nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Internal addresses should not have a label",
+		}
+	}
+
+	// Descriptors created now have never been used, so the utxo set
+	// doesn't need to be scanned for their outputs.
+	switch v := r.Timestamp.Value.(type) {
+	case int:
+		req.Timestamp = int64(v)
+		req.Rescan = true
+	case string:
+		req.Timestamp = time.Now().Unix()
+	default:
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCType,
+			Message: "Missing required timestamp field",
+		}
+	}
+
+	var warnings []string
+	if r.Label != nil {
+		warnings = append(warnings, "Labels are not supported by the "+
+			"watch-only wallet and are ignored")
+	}
+	if !desc.IsSolvable() {
+		warnings = append(warnings, "Descriptor is not solvable, so "+
+			"its outputs are not selected to fund transactions")
+	}
+	return req, warnings, nil
+}
+
+// handleImportDescriptors implements the importdescriptors command.
+func handleImportDescriptors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ImportDescriptorsCmd)
+
+	// Stop scanning the utxo set when the client disconnects or the server
+	// shuts down.
+	interrupt := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-closeChan:
+			close(interrupt)
+		case <-s.quit:
+			close(interrupt)
+		case <-done:
+		}
+	}()
+
+	results := make([]btcjson.ImportDescriptorsResult, 0, len(c.Requests))
+	for i := range c.Requests {
+		req, warnings, err := parseImportDescriptorsRequest(s,
+			&c.Requests[i])
+		if err == nil {
+			err = s.cfg.WatchOnly.ImportDescriptor(req, interrupt)
+			if err != nil {
+				err = walletError(err)
+			}
+		}
+
+		result := btcjson.ImportDescriptorsResult{
+			Success:  err == nil,
+			Warnings: warnings,
+		}
+		if err != nil {
+			jsonErr, ok := err.(*btcjson.RPCError)
+			if !ok {
+				jsonErr = walletError(err)
+			}
+			result.Error = jsonErr
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleListDescriptors implements the listdescriptors command.
+func handleListDescriptors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	descs := s.cfg.WatchOnly.Descriptors()
+	result := &btcjson.ListDescriptorsResult{
+		Descriptors: make([]btcjson.ListDescriptorsDescriptor, 0,
+			len(descs)),
+	}
+	for _, d := range descs {
+		desc := btcjson.ListDescriptorsDescriptor{
+			Desc:      d.Descriptor,
+			Timestamp: d.Timestamp,
+			Active:    d.Active,
+			Internal:  d.Internal,
+		}
+		if d.IsRange {
+			next := int(d.NextIndex)
+			desc.Range = &[2]int{int(d.RangeStart), int(d.RangeEnd)}
+			desc.Next = &next
+		}
+		result.Descriptors = append(result.Descriptors, desc)
+	}
+	return result, nil
+}
+
+// handleListUnspent implements the listunspent command.
+func handleListUnspent(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ListUnspentCmd)
+	params := s.cfg.ChainParams
+
+	var pkScripts map[string]struct{}
+	if c.Addresses != nil {
+		pkScripts = make(map[string]struct{}, len(*c.Addresses))
+		for _, encodedAddr := range *c.Addresses {
+			addr, err := decodeGenerateAddress(s, encodedAddr)
+			if err != nil {
+				return nil, err
+			}
+			pkScript, err := txscript.PayToAddrScript(addr)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidAddressOrKey,
+					Message: "Invalid address: " + err.Error(),
+				}
+			}
+			if _, ok := pkScripts[string(pkScript)]; ok {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: "Invalid parameter, duplicated " +
+						"address: " + encodedAddr,
+				}
+			}
+			pkScripts[string(pkScript)] = struct{}{}
+		}
+	}
+
+	// Locked outputs and outputs spent by transactions in the memory pool
+	// are not listed since they can't be used to fund transactions.
+	utxos, _, height := s.cfg.WatchOnly.Unspent()
+	results := make([]*btcjson.ListUnspentResult, 0, len(utxos))
+	for i := range utxos {
+		utxo := &utxos[i]
+		if utxo.Locked || s.cfg.TxMemPool.CheckSpend(utxo.OutPoint) != nil {
+			continue
+		}
+		confirmations := int64(height - utxo.Height + 1)
+		if confirmations < int64(*c.MinConf) ||
+			confirmations > int64(*c.MaxConf) {
+
+			continue
+		}
+		if pkScripts != nil {
+			if _, ok := pkScripts[string(utxo.PkScript)]; !ok {
+				continue
+			}
+		}
+
+		result := &btcjson.ListUnspentResult{
+			TxID:          utxo.OutPoint.Hash.String(),
+			Vout:          utxo.OutPoint.Index,
+			ScriptPubKey:  hex.EncodeToString(utxo.PkScript),
+			RedeemScript:  hex.EncodeToString(utxo.RedeemScript),
+			Amount:        utxo.Amount.ToBTC(),
+			Confirmations: confirmations,
+		}
+		class, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+			utxo.PkScript, params)
+		if class != txscript.PubKeyTy && len(addrs) == 1 {
+			result.Address = addrs[0].EncodeAddress()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleLockUnspent implements the lockunspent command.
+func handleLockUnspent(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.LockUnspentCmd)
+
+	ops := make([]wire.OutPoint, 0, len(c.Transactions))
+	for _, input := range c.Transactions {
+		hash, err := chainhash.NewHashFromStr(input.Txid)
+		if err != nil {
+			return nil, rpcDecodeHexError(input.Txid)
+		}
+		ops = append(ops, wire.OutPoint{Hash: *hash, Index: input.Vout})
+	}
+
+	var err error
+	switch {
+	case c.Unlock:
+		err = s.cfg.WatchOnly.UnlockUnspent(ops)
+	case len(ops) > 0:
+		err = s.cfg.WatchOnly.LockUnspent(ops)
+	}
+	if err != nil {
+		return nil, walletError(err)
+	}
+	return true, nil
+}
+
+// handleListLockUnspent implements the listlockunspent command.
+func handleListLockUnspent(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	ops := s.cfg.WatchOnly.LockedUnspent()
+	results := make([]btcjson.TransactionInput, 0, len(ops))
+	for _, op := range ops {
+		results = append(results, btcjson.TransactionInput{
+			Txid: op.Hash.String(),
+			Vout: op.Index,
+		})
+	}
+	return results, nil
+}
+
+// decodeFundTx deserializes the transaction passed to the fundrawtransaction
+// RPC.  Transactions without inputs are ambiguous since their serialization
+// without witness data looks like the start of a serialization with witness
+// data, so the serialization without witness data is tried first unless the
+// transaction is known to have witness data, and a serialization is only
+// accepted when it is consumed entirely.
+func decodeFundTx(serializedTx []byte, isWitness *bool) (*wire.MsgTx, error) {
+	var err error
+	if isWitness == nil || !*isWitness {
+		var tx wire.MsgTx
+		r := bytes.NewReader(serializedTx)
+		err = tx.DeserializeNoWitness(r)
+		if err == nil && r.Len() == 0 {
+			return &tx, nil
+		}
+	}
+	if isWitness == nil || *isWitness {
+		var tx wire.MsgTx
+		r := bytes.NewReader(serializedTx)
+		err = tx.Deserialize(r)
+		if err == nil && r.Len() == 0 {
+			return &tx, nil
+		}
+	}
+	if err == nil {
+		err = errors.New("unexpected trailing data")
+	}
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCDeserialization,
+		Message: "TX decode failed: " + err.Error(),
+	}
+}
+
+// fundFeeRate returns the fee rate in satoshis per kvB the transaction funded
+// with the passed options of the fundrawtransaction RPC pays.  It is either the
+// passed fee rate or the fee rate estimated for the passed confirmation
+// target, and never below the minimum fee rates of the node.
+func fundFeeRate(s *rpcServer, opts *btcjson.FundRawTransactionOpts) (btcutil.Amount, error) {
+	minFeeRate := s.cfg.TxMemPool.MinFeeRate()
+	if minRelayTxFee := liveCfg().minRelayTxFee; minFeeRate < minRelayTxFee {
+		minFeeRate = minRelayTxFee
+	}
+
+	if opts.FeeRate != nil {
+		if opts.ConfTarget != nil || opts.EstimateMode != nil {
+			return 0, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Cannot specify both conf_target or " +
+					"estimate_mode and feeRate",
+			}
+		}
+		feeRate, err := btcutil.NewAmount(*opts.FeeRate)
+		if err != nil || feeRate <= 0 {
+			return 0, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid feeRate",
+			}
+		}
+		if feeRate < minFeeRate {
+			return 0, &btcjson.RPCError{
+				Code: btcjson.ErrRPCWallet,
+				Message: fmt.Sprintf("Fee rate (%v/kvB) is lower "+
+					"than the minimum fee rate setting (%v/kvB)",
+					feeRate, minFeeRate),
+			}
+		}
+		return feeRate, nil
+	}
+
+	confTarget := defaultFundConfTarget
+	if opts.ConfTarget != nil {
+		confTarget = *opts.ConfTarget
+	}
+	if confTarget < 1 || confTarget > mempool.MaxEstimateFeeTarget {
+		return 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", mempool.MaxEstimateFeeTarget),
+		}
+	}
+	conservative, err := parseEstimateMode(opts.EstimateMode)
+	if err != nil {
+		return 0, err
+	}
+	if s.cfg.FeeEstimator == nil {
+		return 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCWallet,
+			Message: "Fee estimation is disabled, specify the fee " +
+				"rate with feeRate",
+		}
+	}
+	feeRate, _, err := s.cfg.FeeEstimator.EstimateSmartFee(
+		int32(confTarget), conservative)
+	if err != nil {
+		return 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCWallet,
+			Message: "Fee estimation failed, specify the fee rate " +
+				"with feeRate: " + err.Error(),
+		}
+	}
+	if feeRate < minFeeRate {
+		feeRate = minFeeRate
+	}
+	return feeRate, nil
+}
+
+// handleFundRawTransaction implements the fundrawtransaction command.
+func handleFundRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.FundRawTransactionCmd)
+	opts := &c.Options
+
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	tx, err := decodeFundTx(serializedTx, c.IsWitness)
+	if err != nil {
+		return nil, err
+	}
+
+	feeRate, err := fundFeeRate(s, opts)
+	if err != nil {
+		return nil, err
+	}
+	fundOpts := &watchonly.FundOptions{
+		FeeRate:                feeRate,
+		DustRelayFee:           liveCfg().minRelayTxFee,
+		ChangeType:             txscript.NonStandardTy,
+		ChangePosition:         -1,
+		SubtractFeeFromOutputs: opts.SubtractFeeFromOutputs,
+		LockUnspents:           opts.LockUnspents != nil && *opts.LockUnspents,
+		Replaceable:            opts.Replaceable == nil || *opts.Replaceable,
+		IsSpent: func(op wire.OutPoint) bool {
+			return s.cfg.TxMemPool.CheckSpend(op) != nil
+		},
+	}
+	if opts.ChangeAddress != nil {
+		if opts.ChangeType != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Cannot specify both changeAddress and " +
+					"change_type",
+			}
+		}
+		addr, err := decodeGenerateAddress(s, *opts.ChangeAddress)
+		if err != nil {
+			return nil, err
+		}
+		fundOpts.ChangeScript, err = txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid change address: " + err.Error(),
+			}
+		}
+	}
+	if opts.ChangeType != nil {
+		class, ok := changeTypeClasses[*opts.ChangeType]
+		if !ok {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidAddressOrKey,
+				Message: fmt.Sprintf("Unknown change type '%s'",
+					*opts.ChangeType),
+			}
+		}
+		fundOpts.ChangeType = class
+	}
+	if opts.ChangePosition != nil {
+		if *opts.ChangePosition < -1 ||
+			*opts.ChangePosition > len(tx.TxOut) {
+
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "changePosition out of bounds",
+			}
+		}
+		fundOpts.ChangePosition = *opts.ChangePosition
+	}
+
+	result, err := s.cfg.WatchOnly.Fund(tx, fundOpts)
+	if err != nil {
+		return nil, walletError(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(result.Tx.SerializeSize())
+	if err := result.Tx.Serialize(&buf); err != nil {
+		context := "Failed to serialize transaction"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return &btcjson.FundRawTransactionHexResult{
+		Hex:       hex.EncodeToString(buf.Bytes()),
+		Fee:       result.Fee.ToBTC(),
+		ChangePos: result.ChangePosition,
+	}, nil
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseImportDescriptorsRequest ensures the requests of the
+// importdescriptors RPC are validated and converted to requests to import
+// descriptors into the watch-only wallet.
+func TestParseImportDescriptorsRequest(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	privKey, pubKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	wif, err := btcutil.NewWIF(privKey, params, true)
+	require.NoError(t, err)
+	withChecksum := func(desc string) string {
+		desc, err := descriptor.AddChecksum(desc)
+		require.NoError(t, err)
+		return desc
+	}
+	single := "wpkh(" + hex.EncodeToString(pubKey.SerializeCompressed()) + ")"
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x07}, 32), params)
+	require.NoError(t, err)
+	xpub, err := master.Neuter()
+	require.NoError(t, err)
+	ranged := "wpkh(" + xpub.String() + "/1/*)"
+
+	tests := []struct {
+		name       string
+		request    btcjson.ImportDescriptorsRequest
+		err        btcjson.RPCErrorCode
+		begin, end uint32
+		next       uint32
+		rescan     bool
+		warnings   int
+	}{{
+		name: "single key",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(single),
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		rescan: true,
+	}, {
+		name: "ranged active internal created now",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(ranged),
+			Active:     btcjson.Bool(true),
+			Internal:   btcjson.Bool(true),
+			Range:      &btcjson.DescriptorRange{Value: []int{5, 10}},
+			NextIndex:  btcjson.Int(7),
+			Timestamp:  btcjson.TimestampOrNow{Value: "now"},
+		},
+		begin: 5,
+		end:   10,
+		next:  7,
+	}, {
+		name: "ranged default range with label",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(ranged),
+			Label:      btcjson.String("label"),
+			Timestamp:  btcjson.TimestampOrNow{Value: 1},
+		},
+		end:      defaultScanRangeEnd,
+		rescan:   true,
+		warnings: 1,
+	}, {
+		name: "unsolvable",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum("raw(51)"),
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		rescan:   true,
+		warnings: 1,
+	}, {
+		name: "missing checksum",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: single,
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		err: btcjson.ErrRPCInvalidAddressOrKey,
+	}, {
+		name: "private key",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum("wpkh(" + wif.String() + ")"),
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		err: btcjson.ErrRPCWallet,
+	}, {
+		name: "range of single key",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(single),
+			Range:      &btcjson.DescriptorRange{Value: 10},
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		err: btcjson.ErrRPCInvalidParameter,
+	}, {
+		name: "next index out of range",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(ranged),
+			Range:      &btcjson.DescriptorRange{Value: 10},
+			NextIndex:  btcjson.Int(11),
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		err: btcjson.ErrRPCInvalidParameter,
+	}, {
+		name: "active single key",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(single),
+			Active:     btcjson.Bool(true),
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		err: btcjson.ErrRPCInvalidParameter,
+	}, {
+		name: "internal with label",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(ranged),
+			Internal:   btcjson.Bool(true),
+			Label:      btcjson.String("label"),
+			Timestamp:  btcjson.TimestampOrNow{Value: 0},
+		},
+		err: btcjson.ErrRPCInvalidParameter,
+	}, {
+		name: "missing timestamp",
+		request: btcjson.ImportDescriptorsRequest{
+			Descriptor: withChecksum(single),
+		},
+		err: btcjson.ErrRPCType,
+	}}
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: params}}
+	for _, test := range tests {
+		req, warnings, err := parseImportDescriptorsRequest(s,
+			&test.request)
+		if test.err != 0 {
+			require.Error(t, err, test.name)
+			require.Equal(t, test.err, err.(*btcjson.RPCError).Code,
+				test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.begin, req.RangeStart, test.name)
+		require.Equal(t, test.end, req.RangeEnd, test.name)
+		require.Equal(t, test.next, req.NextIndex, test.name)
+		require.Equal(t, test.rescan, req.Rescan, test.name)
+		require.Len(t, warnings, test.warnings, test.name)
+	}
+}
+
+// TestDecodeFundTx ensures transactions passed to the fundrawtransaction RPC
+// are decoded with and without witness data, including transactions without
+// inputs.
+func TestDecodeFundTx(t *testing.T) {
+	t.Parallel()
+
+	serialize := func(tx *wire.MsgTx) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, tx.Serialize(&buf))
+		return buf.Bytes()
+	}
+
+	unfunded := wire.NewMsgTx(wire.TxVersion)
+	unfunded.AddTxOut(wire.NewTxOut(100000, []byte{0x51}))
+	unfunded.AddTxOut(wire.NewTxOut(200000, []byte{0x52}))
+
+	witness := unfunded.Copy()
+	txIn := wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil,
+		wire.TxWitness{{0x01}})
+	witness.AddTxIn(txIn)
+
+	tests := []struct {
+		name      string
+		tx        *wire.MsgTx
+		isWitness *bool
+		fails     bool
+	}{
+		{name: "no inputs", tx: unfunded},
+		{name: "no inputs without witness", tx: unfunded,
+			isWitness: btcjson.Bool(false)},
+		{name: "no inputs with witness", tx: unfunded,
+			isWitness: btcjson.Bool(true), fails: true},
+		{name: "witness", tx: witness},
+		{name: "witness with witness", tx: witness,
+			isWitness: btcjson.Bool(true)},
+		{name: "witness without witness", tx: witness,
+			isWitness: btcjson.Bool(false), fails: true},
+	}
+
+	for _, test := range tests {
+		tx, err := decodeFundTx(serialize(test.tx), test.isWitness)
+		if test.fails {
+			require.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.tx.TxHash(), tx.TxHash(), test.name)
+		require.Len(t, tx.TxIn, len(test.tx.TxIn), test.name)
+		require.Len(t, tx.TxOut, len(test.tx.TxOut), test.name)
+	}
+}
@@ -8,8 +8,6 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -17,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -26,6 +25,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/websocket"
@@ -39,6 +39,14 @@ const (
 	// handler since notifications have their own queuing mechanism
 	// independent of the send channel buffer.
 	websocketSendBufferSize = 50
+
+	// defaultFeeHistogramInterval is the default interval at which the
+	// mempool fee histogram is sent to clients which registered for it.
+	defaultFeeHistogramInterval = 30 * time.Second
+
+	// feeHistogramCheckInterval is the interval at which the clients which
+	// registered for the mempool fee histogram are checked for being due.
+	feeHistogramCheckInterval = time.Second
 )
 
 type semaphore chan struct{}
@@ -66,14 +74,20 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifychainevents":         handleNotifyChainEvents,
+	"notifyfeehistogram":        handleNotifyFeeHistogram,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
+	"notifytxreplaced":          handleNotifyTxReplaced,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifychainevents":     handleStopNotifyChainEvents,
+	"stopnotifyfeehistogram":    handleStopNotifyFeeHistogram,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
+	"stopnotifytxreplaced":      handleStopNotifyTxReplaced,
 	"rescan":                    handleRescan,
 	"rescanblocks":              handleRescanBlocks,
 }
@@ -83,8 +97,12 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 // must be run in a separate goroutine.  It should be invoked from the websocket
 // server handler which runs each new connection in a new goroutine thereby
 // satisfying the requirement.
+//
+// The passed user is the user the client authenticated as with HTTP Basic
+// access authentication, or nil when the client must authenticate with the
+// authenticate command.
 func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
-	authenticated bool, isAdmin bool) {
+	user *rpcUser) {
 
 	// Clear the read deadline that was set before the websocket hijacked
 	// the connection.
@@ -92,9 +110,10 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 
 	// Limit max number of websocket clients.
 	rpcsLog.Infof("New websocket client %s", remoteAddr)
-	if s.ntfnMgr.NumClients()+1 > cfg.RPCMaxWebsockets {
+	maxWebsockets := liveCfg().RPCMaxWebsockets
+	if s.ntfnMgr.NumClients()+1 > maxWebsockets {
 		rpcsLog.Infof("Max websocket clients exceeded [%d] - "+
-			"disconnecting client %s", cfg.RPCMaxWebsockets,
+			"disconnecting client %s", maxWebsockets,
 			remoteAddr)
 		conn.Close()
 		return
@@ -103,7 +122,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it and any notifications it registered for.
-	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, isAdmin)
+	client, err := newWebsocketClient(s, conn, remoteAddr, user)
 	if err != nil {
 		rpcsLog.Errorf("Failed to serve client %s: %v", remoteAddr, err)
 		conn.Close()
@@ -226,6 +245,32 @@ func (m *wsNotificationManager) NotifyBlockDisconnected(block *btcutil.Block) {
 	}
 }
 
+// NotifyChainEvent passes a canonical chain event to the notification manager
+// for chain event notification processing.
+func (m *wsNotificationManager) NotifyChainEvent(event *blockchain.ChainEvent) {
+	// As NotifyChainEvent will be called by the chain and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationChainEvent)(event):
+	case <-m.quit:
+	}
+}
+
+// NotifyReorgPending passes a reorganization that waits for confirmation to
+// the notification manager for block notification processing.
+func (m *wsNotificationManager) NotifyReorgPending(pending *blockchain.PendingReorg) {
+	// As NotifyReorgPending will be called by the chain and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationReorgPending)(pending):
+	case <-m.quit:
+	}
+}
+
 // NotifyMempoolTx passes a transaction accepted by mempool to the
 // notification manager for transaction notification processing.  If
 // isNew is true, the tx is a new transaction, rather than one
@@ -246,6 +291,27 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyTxReplaced passes transactions of the mempool which were replaced by
+// the passed replacements to the notification manager for replacement
+// notification processing.
+func (m *wsNotificationManager) NotifyTxReplaced(replaced,
+	replacements []*mempool.TxDesc) {
+
+	n := &notificationTxReplaced{
+		replaced:     replaced,
+		replacements: replacements,
+	}
+
+	// As NotifyTxReplaced will be called by mempool and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -447,16 +513,31 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 // Notification types
 type notificationBlockConnected btcutil.Block
 type notificationBlockDisconnected btcutil.Block
+type notificationChainEvent blockchain.ChainEvent
+type notificationReorgPending blockchain.PendingReorg
 type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *btcutil.Tx
 }
+type notificationTxReplaced struct {
+	replaced     []*mempool.TxDesc
+	replacements []*mempool.TxDesc
+}
 
 // Notification control requests
 type notificationRegisterClient wsClient
 type notificationUnregisterClient wsClient
 type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
+type notificationRegisterChainEvents wsClient
+type notificationUnregisterChainEvents wsClient
+type notificationRegisterFeeHistogram struct {
+	wsc      *wsClient
+	interval time.Duration
+}
+type notificationUnregisterFeeHistogram wsClient
+type notificationRegisterTxReplaced wsClient
+type notificationUnregisterTxReplaced wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
 type notificationRegisterSpent struct {
@@ -490,13 +571,27 @@ func (m *wsNotificationManager) notificationHandler() {
 	// Where possible, the quit channel is used as the unique id for a client
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
+	chainEventNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	txReplacedNotifications := make(map[chan struct{}]*wsClient)
+	feeHistogramNotifications := make(map[chan struct{}]*feeHistogramClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
+	// The fee histogram is sent to each client at the interval it
+	// requested, so check for due clients periodically.
+	feeHistogramTicker := time.NewTicker(feeHistogramCheckInterval)
+	defer feeHistogramTicker.Stop()
+
 out:
 	for {
 		select {
+		case now := <-feeHistogramTicker.C:
+			if len(feeHistogramNotifications) != 0 {
+				m.notifyFeeHistogram(feeHistogramNotifications,
+					now)
+			}
+
 		case n, ok := <-m.notificationMsgs:
 			if !ok {
 				// queueHandler quit.
@@ -532,6 +627,18 @@ out:
 						block)
 				}
 
+			case *notificationReorgPending:
+				if len(blockNotifications) != 0 {
+					m.notifyReorgPending(blockNotifications,
+						(*blockchain.PendingReorg)(n))
+				}
+
+			case *notificationChainEvent:
+				if len(chainEventNotifications) != 0 {
+					m.notifyChainEvent(chainEventNotifications,
+						(*blockchain.ChainEvent)(n))
+				}
+
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
 					m.notifyForNewTx(txNotifications, n.tx)
@@ -539,6 +646,12 @@ out:
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationTxReplaced:
+				if len(txReplacedNotifications) != 0 {
+					m.notifyTxReplaced(txReplacedNotifications,
+						n.replaced, n.replacements)
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -547,6 +660,40 @@ out:
 				wsc := (*wsClient)(n)
 				delete(blockNotifications, wsc.quit)
 
+			case *notificationRegisterChainEvents:
+				wsc := (*wsClient)(n)
+				chainEventNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterChainEvents:
+				wsc := (*wsClient)(n)
+				delete(chainEventNotifications, wsc.quit)
+
+			case *notificationRegisterFeeHistogram:
+				// Send the current histogram right away so the
+				// client doesn't have to wait for the first
+				// interval to elapse.
+				c := &feeHistogramClient{
+					wsc:      n.wsc,
+					interval: n.interval,
+				}
+				now := time.Now()
+				m.notifyFeeHistogram(map[chan struct{}]*feeHistogramClient{
+					n.wsc.quit: c,
+				}, now)
+				feeHistogramNotifications[n.wsc.quit] = c
+
+			case *notificationUnregisterFeeHistogram:
+				wsc := (*wsClient)(n)
+				delete(feeHistogramNotifications, wsc.quit)
+
+			case *notificationRegisterTxReplaced:
+				wsc := (*wsClient)(n)
+				txReplacedNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterTxReplaced:
+				wsc := (*wsClient)(n)
+				delete(txReplacedNotifications, wsc.quit)
+
 			case *notificationRegisterClient:
 				wsc := (*wsClient)(n)
 				clients[wsc.quit] = wsc
@@ -556,7 +703,10 @@ out:
 				// Remove any requests made by the client as well as
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
+				delete(chainEventNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(txReplacedNotifications, wsc.quit)
+				delete(feeHistogramNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -625,6 +775,47 @@ func (m *wsNotificationManager) UnregisterBlockUpdates(wsc *wsClient) {
 	m.queueNotification <- (*notificationUnregisterBlocks)(wsc)
 }
 
+// RegisterChainEvents requests chain event notifications to the passed
+// websocket client.
+func (m *wsNotificationManager) RegisterChainEvents(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterChainEvents)(wsc)
+}
+
+// UnregisterChainEvents removes chain event notifications for the passed
+// websocket client.
+func (m *wsNotificationManager) UnregisterChainEvents(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterChainEvents)(wsc)
+}
+
+// RegisterFeeHistogram requests mempool fee histogram notifications at the
+// passed interval to the passed websocket client.
+func (m *wsNotificationManager) RegisterFeeHistogram(wsc *wsClient,
+	interval time.Duration) {
+
+	m.queueNotification <- &notificationRegisterFeeHistogram{
+		wsc:      wsc,
+		interval: interval,
+	}
+}
+
+// UnregisterFeeHistogram removes mempool fee histogram notifications for the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterFeeHistogram(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterFeeHistogram)(wsc)
+}
+
+// RegisterTxReplaced requests transaction replacement notifications to the
+// passed websocket client.
+func (m *wsNotificationManager) RegisterTxReplaced(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterTxReplaced)(wsc)
+}
+
+// UnregisterTxReplaced removes transaction replacement notifications for the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterTxReplaced(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterTxReplaced)(wsc)
+}
+
 // subscribedClients returns the set of all websocket client quit channels that
 // are registered to receive notifications regarding tx, either due to tx
 // spending a watched output or outputting to a watched address.  Matching
@@ -706,6 +897,186 @@ func (*wsNotificationManager) notifyBlockConnected(clients map[chan struct{}]*ws
 	}
 }
 
+// feeHistogramClient houses a websocket client which registered for fee
+// histogram notifications along with the interval it requested them at.
+type feeHistogramClient struct {
+	wsc      *wsClient
+	interval time.Duration
+	next     time.Time
+}
+
+// feeHistogramBounds are the lower bounds, in satoshi per virtual byte, of the
+// fee rate ranges of the feehistogram notifications.
+var feeHistogramBounds = []float64{
+	0, 1, 2, 3, 4, 5, 6, 8, 10, 12, 15, 20, 25, 30, 40, 50, 60, 80, 100,
+	125, 150, 200, 250, 300, 400, 500, 750, 1000, 2000, 5000, 10000,
+}
+
+// feeHistogram returns the non-empty fee rate ranges of the transactions in
+// the passed descriptors in ascending order of fee rate.
+func feeHistogram(txDescs []*mempool.TxDesc) []btcjson.FeeHistogramBucket {
+	counts := make([]int64, len(feeHistogramBounds))
+	vsizes := make([]int64, len(feeHistogramBounds))
+	fees := make([]int64, len(feeHistogramBounds))
+	for _, txD := range txDescs {
+		feeRate := float64(txD.FeePerKB) / 1000
+		i := sort.Search(len(feeHistogramBounds), func(i int) bool {
+			return feeHistogramBounds[i] > feeRate
+		}) - 1
+		if i < 0 {
+			i = 0
+		}
+		counts[i]++
+		vsizes[i] += mempool.GetTxVirtualSize(txD.Tx)
+		fees[i] += txD.Fee
+	}
+
+	buckets := make([]btcjson.FeeHistogramBucket, 0, len(counts))
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		buckets = append(buckets, btcjson.FeeHistogramBucket{
+			FeeRate: feeHistogramBounds[i],
+			Count:   count,
+			VSize:   vsizes[i],
+			Fees:    btcutil.Amount(fees[i]).ToBTC(),
+		})
+	}
+	return buckets
+}
+
+// notifyFeeHistogram notifies the passed fee histogram clients whose interval
+// elapsed by the passed time about the fee rates of the mempool.  The
+// histogram is only computed when at least one client is due.
+func (m *wsNotificationManager) notifyFeeHistogram(
+	clients map[chan struct{}]*feeHistogramClient, now time.Time) {
+
+	var marshalledJSON []byte
+	for _, c := range clients {
+		if now.Before(c.next) {
+			continue
+		}
+		c.next = now.Add(c.interval)
+
+		if marshalledJSON == nil {
+			txDescs := m.server.cfg.TxMemPool.TxDescs()
+			ntfn := btcjson.NewFeeHistogramNtfn(now.Unix(),
+				feeHistogram(txDescs))
+			var err error
+			marshalledJSON, err = btcjson.MarshalCmd(btcjson.RpcVersion1,
+				nil, ntfn)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal fee histogram "+
+					"notification: %v", err)
+				return
+			}
+		}
+		c.wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyTxReplaced notifies websocket clients that have registered for
+// transaction replacements about the passed replaced transactions and their
+// replacements.
+func (*wsNotificationManager) notifyTxReplaced(clients map[chan struct{}]*wsClient,
+	replaced, replacements []*mempool.TxDesc) {
+
+	txIDsAndFees := func(txDescs []*mempool.TxDesc) ([]string, float64) {
+		txIDs := make([]string, 0, len(txDescs))
+		var fees int64
+		for _, txD := range txDescs {
+			txIDs = append(txIDs, txD.Tx.Hash().String())
+			fees += txD.Fee
+		}
+		return txIDs, btcutil.Amount(fees).ToBTC()
+	}
+	replacedTxIDs, replacedFees := txIDsAndFees(replaced)
+	replacementTxIDs, replacementFees := txIDsAndFees(replacements)
+
+	ntfn := btcjson.NewTxReplacedNtfn(replacedTxIDs, replacementTxIDs,
+		replacedFees, replacementFees)
+	marshalledJSON, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal tx replaced notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// chainEventNames maps the chain event types to the names used in chainevent
+// notifications.
+var chainEventNames = map[blockchain.ChainEventType]string{
+	blockchain.ChainEventBlockConnected:    "connected",
+	blockchain.ChainEventBlockDisconnected: "disconnected",
+	blockchain.ChainEventFinalized:         "finalized",
+}
+
+// notifyChainEvent notifies websocket clients that have registered for chain
+// events about the passed event.  The serialized block is only included for
+// the clients which requested it.
+func (*wsNotificationManager) notifyChainEvent(clients map[chan struct{}]*wsClient,
+	event *blockchain.ChainEvent) {
+
+	var stxos []btcjson.ChainEventSpentTxOut
+	if event.SpentTxOuts != nil {
+		stxos = make([]btcjson.ChainEventSpentTxOut, 0,
+			len(event.SpentTxOuts))
+		for i := range event.SpentTxOuts {
+			stxo := &event.SpentTxOuts[i]
+			stxos = append(stxos, btcjson.ChainEventSpentTxOut{
+				Amount:   stxo.Amount,
+				PkScript: hex.EncodeToString(stxo.PkScript),
+				Height:   stxo.Height,
+				CoinBase: stxo.IsCoinBase,
+			})
+		}
+	}
+
+	var marshalledJSON, marshalledJSONBlock []byte
+	for _, wsc := range clients {
+		includeBlock := wsc.chainEventBlocks && event.Block != nil
+		if includeBlock && marshalledJSONBlock != nil {
+			wsc.QueueNotification(marshalledJSONBlock)
+			continue
+		}
+		if !includeBlock && marshalledJSON != nil {
+			wsc.QueueNotification(marshalledJSON)
+			continue
+		}
+
+		var blockHex string
+		if includeBlock {
+			blockBytes, err := event.Block.Bytes()
+			if err != nil {
+				rpcsLog.Errorf("Failed to serialize block for chain "+
+					"event notification: %v", err)
+				return
+			}
+			blockHex = hex.EncodeToString(blockBytes)
+		}
+
+		ntfn := btcjson.NewChainEventNtfn(chainEventNames[event.Type],
+			event.Sequence, event.Hash.String(), event.Height,
+			blockHex, stxos)
+		marshalled, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, ntfn)
+		if err != nil {
+			rpcsLog.Errorf("Failed to marshal chain event "+
+				"notification: %v", err)
+			return
+		}
+		if includeBlock {
+			marshalledJSONBlock = marshalled
+		} else {
+			marshalledJSON = marshalled
+		}
+		wsc.QueueNotification(marshalled)
+	}
+}
+
 // notifyBlockDisconnected notifies websocket clients that have registered for
 // block updates when a block is disconnected from the main chain (due to a
 // reorganize).
@@ -730,6 +1101,28 @@ func (*wsNotificationManager) notifyBlockDisconnected(clients map[chan struct{}]
 	}
 }
 
+// notifyReorgPending notifies websocket clients that have registered for block
+// updates when a chain with more work than the main chain was found, but
+// switching to it would disconnect more blocks than the maximum reorganization
+// depth and must be confirmed with the confirmreorg command.
+func (*wsNotificationManager) notifyReorgPending(clients map[chan struct{}]*wsClient,
+	pending *blockchain.PendingReorg) {
+
+	ntfn := btcjson.NewReorgPendingNtfn(pending.ForkHash.String(),
+		pending.ForkHeight, pending.OldTipHash.String(),
+		pending.OldTipHeight, pending.NewTipHash.String(),
+		pending.NewTipHeight, pending.Depth)
+	marshalledJSON, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal reorg pending notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // notifyFilteredBlockConnected notifies websocket clients that have registered for
 // block updates when a block is connected to the main chain.
 func (m *wsNotificationManager) notifyFilteredBlockConnected(clients map[chan struct{}]*wsClient,
@@ -1264,9 +1657,9 @@ type wsClient struct {
 	// and therefore is allowed to communicated over the websocket.
 	authenticated bool
 
-	// isAdmin specifies whether a client may change the state of the server;
-	// false means its access is only to the limited set of RPC calls.
-	isAdmin bool
+	// user is the user the client authenticated as, which determines the
+	// RPC calls it may make.  It is nil until the client is authenticated.
+	user *rpcUser
 
 	// sessionID is a random ID generated for each client when connected.
 	// These IDs may be queried by a client using the session RPC.  A change
@@ -1277,6 +1670,10 @@ type wsClient struct {
 	// information about all new transactions.
 	verboseTxUpdates bool
 
+	// chainEventBlocks specifies whether a client has requested the
+	// serialized blocks to be included in chain event notifications.
+	chainEventBlocks bool
+
 	// addrRequests is a set of addresses the caller has requested to be
 	// notified about.  It is maintained here so all requests can be removed
 	// when a wallet disconnects.  Owned by the notification manager.
@@ -1412,15 +1809,13 @@ out:
 				// Check credentials.
 				login := authCmd.Username + ":" + authCmd.Passphrase
 				auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-				authSha := sha256.Sum256([]byte(auth))
-				cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-				limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-				if cmp != 1 && limitcmp != 1 {
+				user := c.server.authenticate(auth)
+				if user == nil {
 					rpcsLog.Warnf("Auth failure.")
 					break out
 				}
 				c.authenticated = true
-				c.isAdmin = cmp == 1
+				c.user = user
 
 				// Marshal and send response.
 				reply, err = createMarshalledReply(cmd.jsonrpc, cmd.id, nil, nil)
@@ -1435,22 +1830,33 @@ out:
 
 			// Check if the client is using limited RPC credentials and
 			// error when not authorized to call the supplied RPC.
-			if !c.isAdmin {
-				if _, ok := rpcLimited[req.Method]; !ok {
-					jsonErr := &btcjson.RPCError{
-						Code:    btcjson.ErrRPCInvalidParams.Code,
-						Message: "limited user not authorized for this method",
-					}
-					// Marshal and send response.
-					reply, err = createMarshalledReply("", req.ID, nil, jsonErr)
-					if err != nil {
-						rpcsLog.Errorf("Failed to marshal parse failure "+
-							"reply: %v", err)
-						continue
-					}
-					c.SendMessage(reply, nil)
+			if !c.user.allowed(req.Method) {
+				jsonErr := &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParams.Code,
+					Message: "limited user not authorized for this method",
+				}
+				// Marshal and send response.
+				reply, err = createMarshalledReply("", req.ID, nil, jsonErr)
+				if err != nil {
+					rpcsLog.Errorf("Failed to marshal parse failure "+
+						"reply: %v", err)
+					continue
+				}
+				c.SendMessage(reply, nil)
+				continue
+			}
+
+			// Reject calls to disabled methods and calls which exceed
+			// the rate limit of the method.
+			if jsonErr := c.server.checkMethodLimits(c.addr, req.Method); jsonErr != nil {
+				reply, err = createMarshalledReply("", req.ID, nil, jsonErr)
+				if err != nil {
+					rpcsLog.Errorf("Failed to marshal limit failure "+
+						"reply: %v", err)
 					continue
 				}
+				c.SendMessage(reply, nil)
+				continue
 			}
 
 			// Asynchronously handle the request.  A semaphore is used to
@@ -1649,16 +2055,14 @@ out:
 							// Check credentials.
 							login := authCmd.Username + ":" + authCmd.Passphrase
 							auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-							authSha := sha256.Sum256([]byte(auth))
-							cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-							limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-							if cmp != 1 && limitcmp != 1 {
+							user := c.server.authenticate(auth)
+							if user == nil {
 								rpcsLog.Warnf("Auth failure.")
 								break out
 							}
 
 							c.authenticated = true
-							c.isAdmin = cmp == 1
+							c.user = user
 
 							// Marshal and send response.
 							reply, err = createMarshalledReply(cmd.jsonrpc, cmd.id, nil, nil)
@@ -1676,25 +2080,39 @@ out:
 
 						// Check if the client is using limited RPC credentials and
 						// error when not authorized to call the supplied RPC.
-						if !c.isAdmin {
-							if _, ok := rpcLimited[req.Method]; !ok {
-								jsonErr := &btcjson.RPCError{
-									Code:    btcjson.ErrRPCInvalidParams.Code,
-									Message: "limited user not authorized for this method",
-								}
-								// Marshal and send response.
-								reply, err = createMarshalledReply(req.Jsonrpc, req.ID, nil, jsonErr)
-								if err != nil {
-									rpcsLog.Errorf("Failed to marshal parse failure "+
-										"reply: %v", err)
-									continue
-								}
-
-								if reply != nil {
-									results = append(results, reply)
-								}
+						if !c.user.allowed(req.Method) {
+							jsonErr := &btcjson.RPCError{
+								Code:    btcjson.ErrRPCInvalidParams.Code,
+								Message: "limited user not authorized for this method",
+							}
+							// Marshal and send response.
+							reply, err = createMarshalledReply(req.Jsonrpc, req.ID, nil, jsonErr)
+							if err != nil {
+								rpcsLog.Errorf("Failed to marshal parse failure "+
+									"reply: %v", err)
 								continue
 							}
+
+							if reply != nil {
+								results = append(results, reply)
+							}
+							continue
+						}
+
+						// Reject calls to disabled methods and calls which
+						// exceed the rate limit of the method.
+						if jsonErr := c.server.checkMethodLimits(c.addr, req.Method); jsonErr != nil {
+							reply, err = createMarshalledReply(req.Jsonrpc, req.ID, nil, jsonErr)
+							if err != nil {
+								rpcsLog.Errorf("Failed to marshal limit failure "+
+									"reply: %v", err)
+								continue
+							}
+
+							if reply != nil {
+								results = append(results, reply)
+							}
+							continue
 						}
 
 						// Lookup the websocket extension for the command, if it doesn't
@@ -1991,7 +2409,7 @@ func (c *wsClient) WaitForShutdown() {
 // incoming and outgoing messages in separate goroutines complete with queuing
 // and asynchrous handling for long-running operations.
 func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
-	remoteAddr string, authenticated bool, isAdmin bool) (*wsClient, error) {
+	remoteAddr string, user *rpcUser) (*wsClient, error) {
 
 	sessionID, err := wire.RandomUint64()
 	if err != nil {
@@ -2001,13 +2419,13 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 	client := &wsClient{
 		conn:              conn,
 		addr:              remoteAddr,
-		authenticated:     authenticated,
-		isAdmin:           isAdmin,
+		authenticated:     user != nil,
+		user:              user,
 		sessionID:         sessionID,
 		server:            server,
 		addrRequests:      make(map[string]struct{}),
 		spentRequests:     make(map[wire.OutPoint]struct{}),
-		serviceRequestSem: makeSemaphore(cfg.RPCMaxConcurrentReqs),
+		serviceRequestSem: makeSemaphore(liveCfg().RPCMaxConcurrentReqs),
 		ntfnChan:          make(chan []byte, 1), // nonblocking sync
 		sendChan:          make(chan wsResponse, websocketSendBufferSize),
 		quit:              make(chan struct{}),
@@ -2114,6 +2532,71 @@ func handleNotifyBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// handleNotifyChainEvents implements the notifychainevents command extension
+// for websocket connections.
+func handleNotifyChainEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.NotifyChainEventsCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	wsc.chainEventBlocks = cmd.IncludeBlock != nil && *cmd.IncludeBlock
+	wsc.server.ntfnMgr.RegisterChainEvents(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyChainEvents implements the stopnotifychainevents command
+// extension for websocket connections.
+func handleStopNotifyChainEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterChainEvents(wsc)
+	return nil, nil
+}
+
+// handleNotifyFeeHistogram implements the notifyfeehistogram command extension
+// for websocket connections.
+func handleNotifyFeeHistogram(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.NotifyFeeHistogramCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	interval := int32(defaultFeeHistogramInterval / time.Second)
+	if cmd.Interval != nil {
+		interval = *cmd.Interval
+	}
+	if interval <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Interval must be positive",
+		}
+	}
+
+	wsc.server.ntfnMgr.RegisterFeeHistogram(wsc,
+		time.Duration(interval)*time.Second)
+	return nil, nil
+}
+
+// handleStopNotifyFeeHistogram implements the stopnotifyfeehistogram command
+// extension for websocket connections.
+func handleStopNotifyFeeHistogram(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterFeeHistogram(wsc)
+	return nil, nil
+}
+
+// handleNotifyTxReplaced implements the notifytxreplaced command extension for
+// websocket connections.
+func handleNotifyTxReplaced(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterTxReplaced(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyTxReplaced implements the stopnotifytxreplaced command
+// extension for websocket connections.
+func handleStopNotifyTxReplaced(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterTxReplaced(wsc)
+	return nil, nil
+}
+
 // handleSession implements the session command extension for websocket
 // connections.
 func handleSession(wsc *wsClient, icmd interface{}) (interface{}, error) {
@@ -2584,17 +3067,18 @@ func recoverFromReorg(chain *blockchain.BlockChain, minBlock, maxBlock int32,
 			Message: "Database error: " + err.Error(),
 		}
 	}
-	jsonErr := descendantBlock(lastBlock, blk)
+	jsonErr := descendantBlock(lastBlock, &blk.MsgBlock().Header)
 	if jsonErr != nil {
 		return nil, jsonErr
 	}
 	return hashList, nil
 }
 
-// descendantBlock returns the appropriate JSON-RPC error if a current block
-// fetched during a reorganize is not a direct child of the parent block hash.
-func descendantBlock(prevHash *chainhash.Hash, curBlock *btcutil.Block) error {
-	curHash := &curBlock.MsgBlock().Header.PrevBlock
+// descendantBlock returns the appropriate JSON-RPC error if the header of a
+// current block fetched during a reorganize is not a direct child of the parent
+// block hash.
+func descendantBlock(prevHash *chainhash.Hash, curHeader *wire.BlockHeader) error {
+	curHash := &curHeader.PrevBlock
 	if !prevHash.IsEqual(curHash) {
 		rpcsLog.Errorf("Stopping rescan for reorged block %v "+
 			"(replaced by block %v)", prevHash, curHash)
@@ -2603,26 +3087,118 @@ func descendantBlock(prevHash *chainhash.Hash, curBlock *btcutil.Block) error {
 	return nil
 }
 
+// rescanFilterScripts returns the scripts a block has to involve for it to be
+// relevant to a rescan for the passed lookup keys, which are the output scripts
+// of the addresses and the scripts of the unspent outputs.  Since the committed
+// filter of a block includes the scripts of both the outputs it creates and the
+// outputs it spends, a block whose filter matches none of them can be skipped.
+//
+// False is returned when the blocks can't be skipped based on the scripts, which
+// is the case when the script of an unspent output isn't known or a public key
+// is rescanned for since it is also matched against bare multisig outputs.
+func rescanFilterScripts(chain *blockchain.BlockChain, lookups *rescanKeys,
+	params *chaincfg.Params) ([][]byte, bool) {
+
+	scripts := make([][]byte, 0, len(lookups.addrs)+len(lookups.unspent))
+	for addrStr := range lookups.addrs {
+		// Addresses which can't be decoded never match any outputs, so
+		// they are ignored.
+		addr, err := btcutil.DecodeAddress(addrStr, params)
+		if err != nil {
+			continue
+		}
+		if _, ok := addr.(*btcutil.AddressPubKey); ok {
+			return nil, false
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			continue
+		}
+		scripts = append(scripts, pkScript)
+	}
+
+	for outpoint := range lookups.unspent {
+		entry, err := chain.FetchUtxoEntry(outpoint)
+		if err != nil || entry == nil {
+			return nil, false
+		}
+		scripts = append(scripts, entry.PkScript())
+	}
+
+	return scripts, true
+}
+
 // scanBlockChunks executes a rescan in chunked stages. We do this to limit the
 // amount of memory that we'll allocate to a given rescan. Every so often,
 // we'll send back a rescan progress notification to the websockets client. The
-// final block and block hash that we've scanned will be returned.
+// header, height and hash of the final block that we've scanned will be
+// returned.
+//
+// When the CF index is enabled, the committed filters of the blocks are checked
+// first so blocks which can't contain any matches are skipped without fetching
+// and scanning them.
 func scanBlockChunks(wsc *wsClient, cmd *btcjson.RescanCmd, lookups *rescanKeys, minBlock,
 	maxBlock int32, chain *blockchain.BlockChain) (
-	*btcutil.Block, *chainhash.Hash, error) {
+	*wire.BlockHeader, int32, *chainhash.Hash, error) {
 
-	// lastBlock and lastBlockHash track the previously-rescanned block.
-	// They equal nil when no previous blocks have been rescanned.
+	// lastHeader, lastHeight and lastBlockHash track the
+	// previously-rescanned block.  The header and hash equal nil when no
+	// previous blocks have been rescanned.
 	var (
-		lastBlock     *btcutil.Block
+		lastHeader    *wire.BlockHeader
+		lastHeight    int32
 		lastBlockHash *chainhash.Hash
 	)
 
-	// A ticker is created to wait at least 10 seconds before notifying the
-	// websocket client of the current progress completed by the rescan.
-	ticker := time.NewTicker(10 * time.Second)
+	// Determine the scripts to match the committed filters against when
+	// they are available.
+	var filterScripts [][]byte
+	cfIndex := wsc.server.cfg.CfIndex
+	useFilters := cfIndex != nil
+	if useFilters {
+		filterScripts, useFilters = rescanFilterScripts(chain, lookups,
+			wsc.server.cfg.ChainParams)
+		if !useFilters {
+			rpcsLog.Debugf("Scanning all blocks since the rescan " +
+				"can't be matched against committed filters")
+		}
+	}
+	var numSkipped int
+
+	// A ticker is created to wait at least the configured interval before
+	// notifying the websocket client of the current progress completed by
+	// the rescan.
+	ticker := time.NewTicker(cfg.RescanNtfnInterval)
 	defer ticker.Stop()
 
+	// notifyProgress notifies the websocket client of the progress
+	// completed when the progress interval has elapsed.  It returns false
+	// when the client disconnected.
+	notifyProgress := func() bool {
+		select {
+		case <-ticker.C: // fallthrough
+		default:
+			return true
+		}
+
+		n := btcjson.NewRescanProgressNtfn(lastBlockHash.String(),
+			lastHeight, lastHeader.Timestamp.Unix())
+		mn, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, n)
+		if err != nil {
+			rpcsLog.Errorf("Failed to marshal rescan "+
+				"progress notification: %v", err)
+			return true
+		}
+
+		if err = wsc.QueueNotification(mn); err == ErrClientQuit {
+			// Finished if the client disconnected.
+			rpcsLog.Debugf("Stopped rescan at height %v "+
+				"for disconnected client", lastHeight)
+			return false
+		}
+		return true
+	}
+
 	// Instead of fetching all block shas at once, fetch in smaller chunks
 	// to ensure large rescans consume a limited amount of memory.
 fetchRange:
@@ -2639,7 +3215,7 @@ fetchRange:
 		hashList, err := chain.HeightRange(minBlock, maxLoopBlock)
 		if err != nil {
 			rpcsLog.Errorf("Error looking up block range: %v", err)
-			return nil, nil, &btcjson.RPCError{
+			return nil, 0, nil, &btcjson.RPCError{
 				Code:    btcjson.ErrRPCDatabase,
 				Message: "Database error: " + err.Error(),
 			}
@@ -2678,7 +3254,7 @@ fetchRange:
 			if err != nil {
 				rpcsLog.Errorf("Error fetching best block "+
 					"hash: %v", err)
-				return nil, nil, &btcjson.RPCError{
+				return nil, 0, nil, &btcjson.RPCError{
 					Code: btcjson.ErrRPCDatabase,
 					Message: "Database error: " +
 						err.Error(),
@@ -2692,6 +3268,50 @@ fetchRange:
 
 	loopHashList:
 		for i := range hashList {
+			// Skip the block without fetching it when its committed
+			// filter doesn't match any of the scripts.  Failing to
+			// check the filter, such as when the block was reorged
+			// out or the CF index hasn't caught up to it yet, falls
+			// back to fetching and scanning the block below.
+			if useFilters {
+				match, err := cfIndex.FilterMatch(&hashList[i],
+					filterScripts)
+				if err == nil && !match {
+					header, err := chain.HeaderByHash(&hashList[i])
+					if err == nil {
+						if i == 0 && lastBlockHash != nil {
+							jsonErr := descendantBlock(
+								lastBlockHash, &header)
+							if jsonErr != nil {
+								return nil, 0, nil, jsonErr
+							}
+						}
+
+						// Stop rescans if the client
+						// requesting the rescan has
+						// disconnected.
+						select {
+						case <-wsc.quit:
+							rpcsLog.Debugf("Stopped rescan "+
+								"at height %v for "+
+								"disconnected client",
+								minBlock+int32(i))
+							return nil, 0, nil, nil
+						default:
+						}
+
+						numSkipped++
+						lastHeader = &header
+						lastHeight = minBlock + int32(i)
+						lastBlockHash = &hashList[i]
+						if !notifyProgress() {
+							return nil, 0, nil, nil
+						}
+						continue
+					}
+				}
+			}
+
 			blk, err := chain.BlockByHash(&hashList[i])
 			if err != nil {
 				// Only handle reorgs if a block could not be
@@ -2701,7 +3321,7 @@ fetchRange:
 
 					rpcsLog.Errorf("Error looking up "+
 						"block: %v", err)
-					return nil, nil, &btcjson.RPCError{
+					return nil, 0, nil, &btcjson.RPCError{
 						Code: btcjson.ErrRPCDatabase,
 						Message: "Database error: " +
 							err.Error(),
@@ -2714,7 +3334,7 @@ fetchRange:
 					rpcsLog.Errorf("Stopping rescan for "+
 						"reorged block %v",
 						cmd.EndBlock)
-					return nil, nil, &ErrRescanReorg
+					return nil, 0, nil, &ErrRescanReorg
 				}
 
 				// If the lookup for the previously valid block
@@ -2732,7 +3352,7 @@ fetchRange:
 					chain, minBlock, maxBlock, lastBlockHash,
 				)
 				if err != nil {
-					return nil, nil, err
+					return nil, 0, nil, err
 				}
 				if len(hashList) == 0 {
 					break fetchRange
@@ -2742,9 +3362,10 @@ fetchRange:
 			if i == 0 && lastBlockHash != nil {
 				// Ensure the new hashList is on the same fork
 				// as the last block from the old hashList.
-				jsonErr := descendantBlock(lastBlockHash, blk)
+				jsonErr := descendantBlock(lastBlockHash,
+					&blk.MsgBlock().Header)
 				if jsonErr != nil {
-					return nil, nil, jsonErr
+					return nil, 0, nil, jsonErr
 				}
 			}
 
@@ -2754,45 +3375,30 @@ fetchRange:
 			case <-wsc.quit:
 				rpcsLog.Debugf("Stopped rescan at height %v "+
 					"for disconnected client", blk.Height())
-				return nil, nil, nil
+				return nil, 0, nil, nil
 			default:
 				rescanBlock(wsc, lookups, blk)
-				lastBlock = blk
+				lastHeader = &blk.MsgBlock().Header
+				lastHeight = blk.Height()
 				lastBlockHash = blk.Hash()
 			}
 
 			// Periodically notify the client of the progress
-			// completed.  Continue with next block if no progress
-			// notification is needed yet.
-			select {
-			case <-ticker.C: // fallthrough
-			default:
-				continue
-			}
-
-			n := btcjson.NewRescanProgressNtfn(
-				hashList[i].String(), blk.Height(),
-				blk.MsgBlock().Header.Timestamp.Unix(),
-			)
-			mn, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, n)
-			if err != nil {
-				rpcsLog.Errorf("Failed to marshal rescan "+
-					"progress notification: %v", err)
-				continue
-			}
-
-			if err = wsc.QueueNotification(mn); err == ErrClientQuit {
-				// Finished if the client disconnected.
-				rpcsLog.Debugf("Stopped rescan at height %v "+
-					"for disconnected client", blk.Height())
-				return nil, nil, nil
+			// completed.
+			if !notifyProgress() {
+				return nil, 0, nil, nil
 			}
 		}
 
 		minBlock += int32(len(hashList))
 	}
 
-	return lastBlock, lastBlockHash, nil
+	if useFilters {
+		rpcsLog.Debugf("Skipped %d blocks during rescan based on "+
+			"their committed filters", numSkipped)
+	}
+
+	return lastHeader, lastHeight, lastBlockHash, nil
 }
 
 // handleRescan implements the rescan command extension for websocket
@@ -2871,24 +3477,25 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	}
 
 	var (
-		lastBlock     *btcutil.Block
+		lastHeader    *wire.BlockHeader
+		lastHeight    int32
 		lastBlockHash *chainhash.Hash
 	)
 	if len(lookups.addrs) != 0 || len(lookups.unspent) != 0 {
 		// With all the arguments parsed, we'll execute our chunked rescan
 		// which will notify the clients of any address deposits or output
 		// spends.
-		lastBlock, lastBlockHash, err = scanBlockChunks(
+		lastHeader, lastHeight, lastBlockHash, err = scanBlockChunks(
 			wsc, cmd, &lookups, minBlock, maxBlock, chain,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		// If the last block is nil, then this means that the client
+		// If the last header is nil, then this means that the client
 		// disconnected mid-rescan. As a result, we don't need to send
 		// anything back to them.
-		if lastBlock == nil {
+		if lastHeader == nil {
 			return nil, nil
 		}
 	} else {
@@ -2899,13 +3506,15 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		// notification.
 		chainTip := chain.BestSnapshot()
 		lastBlockHash = &chainTip.Hash
-		lastBlock, err = chain.BlockByHash(lastBlockHash)
+		lastHeight = chainTip.Height
+		header, err := chain.HeaderByHash(lastBlockHash)
 		if err != nil {
 			return nil, &btcjson.RPCError{
 				Code:    btcjson.ErrRPCBlockNotFound,
 				Message: "Error getting block: " + err.Error(),
 			}
 		}
+		lastHeader = &header
 	}
 
 	// Notify websocket client of the finished rescan.  Due to how btcd
@@ -2916,8 +3525,8 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	// is needed to safely inform clients that all rescan notifications have
 	// been sent.
 	n := btcjson.NewRescanFinishedNtfn(
-		lastBlockHash.String(), lastBlock.Height(),
-		lastBlock.MsgBlock().Header.Timestamp.Unix(),
+		lastBlockHash.String(), lastHeight,
+		lastHeader.Timestamp.Unix(),
 	)
 	if mn, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, n); err != nil {
 		rpcsLog.Errorf("Failed to marshal rescan finished "+
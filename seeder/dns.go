@@ -0,0 +1,204 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package seeder
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// maxDNSMessageSize is the maximum size of DNS messages over UDP
+	// without extensions.  The answers are limited to fit.
+	maxDNSMessageSize = 512
+
+	// dnsHeaderLen is the length of the header of DNS messages.
+	dnsHeaderLen = 12
+
+	// dnsTTL is the time in seconds resolvers may cache the answers.
+	dnsTTL = 60
+
+	// The DNS record types and the class the seed answers queries for.
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+
+	// The DNS response codes the seed uses.
+	dnsRcodeFormErr = 1
+	dnsRcodeNotImp  = 4
+	dnsRcodeRefused = 5
+
+	// dnsFlagQR, dnsFlagAA and dnsFlagRD are the flags of the header of DNS
+	// messages marking responses, authoritative answers and queries which
+	// desire recursion.
+	dnsFlagQR = 1 << 15
+	dnsFlagAA = 1 << 10
+	dnsFlagRD = 1 << 8
+
+	// dnsOpcodeMask is the mask of the opcode of the flags of the header.
+	dnsOpcodeMask = 0xf << 11
+)
+
+// errMalformedQuery is returned when a DNS query can't be parsed.
+var errMalformedQuery = errors.New("malformed DNS query")
+
+// lookupFunc returns the addresses of reliable nodes which support the passed
+// services, which are IPv6 addresses when ipv6 is true and IPv4 addresses
+// otherwise.
+type lookupFunc func(services wire.ServiceFlag, ipv6 bool) []net.IP
+
+// dnsQuestion is the question of a DNS query.
+type dnsQuestion struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+
+	// end is the offset of the end of the question in the query.
+	end int
+}
+
+// parseQuestion parses the first question of the passed DNS query.  The name is
+// returned in lowercase without the trailing dot.
+func parseQuestion(query []byte) (*dnsQuestion, error) {
+	var labels []string
+	offset := dnsHeaderLen
+	for {
+		if offset >= len(query) {
+			return nil, errMalformedQuery
+		}
+		n := int(query[offset])
+		offset++
+		if n == 0 {
+			break
+		}
+
+		// Compressed names can't appear in the first question and longer
+		// labels are invalid.
+		if n > 63 || offset+n > len(query) {
+			return nil, errMalformedQuery
+		}
+		labels = append(labels, string(query[offset:offset+n]))
+		offset += n
+	}
+	if offset+4 > len(query) {
+		return nil, errMalformedQuery
+	}
+
+	return &dnsQuestion{
+		name:   strings.ToLower(strings.Join(labels, ".")),
+		qtype:  binary.BigEndian.Uint16(query[offset:]),
+		qclass: binary.BigEndian.Uint16(query[offset+2:]),
+		end:    offset + 4,
+	}, nil
+}
+
+// requestedServices returns the services the nodes must support to answer a
+// query for the passed name, which is either the host name of the seed or the
+// host name prefixed with a label of the form x<hex services>, as used by the
+// clients of the seeds to filter the nodes by their services.  False is
+// returned when the name doesn't belong to the seed.
+func requestedServices(name, host string) (wire.ServiceFlag, bool) {
+	if name == host {
+		return wire.SFNodeNetwork, true
+	}
+
+	prefix := strings.TrimSuffix(name, "."+host)
+	if prefix == name || len(prefix) < 2 || prefix[0] != 'x' {
+		return 0, false
+	}
+	services, err := strconv.ParseUint(prefix[1:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return wire.ServiceFlag(services), true
+}
+
+// dnsResponse returns the response to the passed DNS query for the seed with
+// the passed host name, which answers A and AAAA queries with the addresses
+// returned by lookup.  Nil is returned for messages which shouldn't be answered.
+func dnsResponse(query []byte, host string, lookup lookupFunc) []byte {
+	if len(query) < dnsHeaderLen {
+		return nil
+	}
+	flags := binary.BigEndian.Uint16(query[2:])
+	if flags&dnsFlagQR != 0 {
+		return nil
+	}
+
+	// The response starts with the header of the query with the response
+	// flags set and the record counts cleared.
+	resp := make([]byte, dnsHeaderLen, maxDNSMessageSize)
+	copy(resp, query[:4])
+	respFlags := dnsFlagQR | flags&(dnsOpcodeMask|dnsFlagRD)
+	reply := func(rcode uint16) []byte {
+		binary.BigEndian.PutUint16(resp[2:], respFlags|rcode)
+		return resp
+	}
+
+	if flags&dnsOpcodeMask != 0 {
+		return reply(dnsRcodeNotImp)
+	}
+	if binary.BigEndian.Uint16(query[4:]) != 1 {
+		return reply(dnsRcodeFormErr)
+	}
+	q, err := parseQuestion(query)
+	if err != nil || q.end-dnsHeaderLen > maxDNSMessageSize/2 {
+		return reply(dnsRcodeFormErr)
+	}
+
+	// Echo the question.
+	resp = append(resp, query[dnsHeaderLen:q.end]...)
+	binary.BigEndian.PutUint16(resp[4:], 1)
+
+	services, ok := requestedServices(q.name, host)
+	if !ok || q.qclass != dnsClassIN {
+		return reply(dnsRcodeRefused)
+	}
+	respFlags |= dnsFlagAA
+
+	// Other types of queries for the seed are answered without records.
+	var ips []net.IP
+	switch q.qtype {
+	case dnsTypeA:
+		ips = lookup(services, false)
+	case dnsTypeAAAA:
+		ips = lookup(services, true)
+	}
+
+	var answers uint16
+	for _, ip := range ips {
+		rdata := ip.To4()
+		rtype := uint16(dnsTypeA)
+		if q.qtype == dnsTypeAAAA {
+			rdata = ip.To16()
+			rtype = dnsTypeAAAA
+		}
+		if rdata == nil {
+			continue
+		}
+
+		// Each record refers to the name of the question, which always
+		// starts right after the header, instead of repeating it.
+		if len(resp)+12+len(rdata) > maxDNSMessageSize {
+			break
+		}
+		var record [12]byte
+		binary.BigEndian.PutUint16(record[0:], 0xc000|dnsHeaderLen)
+		binary.BigEndian.PutUint16(record[2:], rtype)
+		binary.BigEndian.PutUint16(record[4:], dnsClassIN)
+		binary.BigEndian.PutUint32(record[6:], dnsTTL)
+		binary.BigEndian.PutUint16(record[10:], uint16(len(rdata)))
+		resp = append(resp, record[:]...)
+		resp = append(resp, rdata...)
+		answers++
+	}
+	binary.BigEndian.PutUint16(resp[6:], answers)
+	return reply(0)
+}
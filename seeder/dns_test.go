@@ -0,0 +1,155 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package seeder
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// dnsQuery returns a DNS query with the passed ID for the passed name and type.
+func dnsQuery(id uint16, name string, qtype uint16) []byte {
+	query := make([]byte, dnsHeaderLen)
+	binary.BigEndian.PutUint16(query[0:], id)
+	binary.BigEndian.PutUint16(query[2:], dnsFlagRD)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	for _, label := range strings.Split(name, ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0)
+	var typeClass [4]byte
+	binary.BigEndian.PutUint16(typeClass[0:], qtype)
+	binary.BigEndian.PutUint16(typeClass[2:], dnsClassIN)
+	return append(query, typeClass[:]...)
+}
+
+// TestDNSResponse ensures queries for the seed are answered with the addresses
+// of the nodes supporting the requested services and other queries are
+// refused or rejected.
+func TestDNSResponse(t *testing.T) {
+	t.Parallel()
+
+	const host = "seed.example.com"
+	nodes := []goodNode{
+		{net.ParseIP("1.2.3.4"), wire.SFNodeNetwork},
+		{net.ParseIP("5.6.7.8"), wire.SFNodeNetwork | wire.SFNodeWitness},
+		{net.ParseIP("2001:db8::1"), wire.SFNodeNetwork | wire.SFNodeWitness},
+	}
+	lookup := func(services wire.ServiceFlag, ipv6 bool) []net.IP {
+		var ips []net.IP
+		for _, n := range nodes {
+			if n.services&services == services && (n.ip.To4() == nil) == ipv6 {
+				ips = append(ips, n.ip)
+			}
+		}
+		return ips
+	}
+
+	tests := []struct {
+		name    string
+		query   []byte
+		rcode   uint16
+		answers []string
+	}{{
+		name:    "A query for the seed",
+		query:   dnsQuery(1, host, dnsTypeA),
+		answers: []string{"1.2.3.4", "5.6.7.8"},
+	}, {
+		name:    "case insensitive name",
+		query:   dnsQuery(2, "SEED.Example.com", dnsTypeA),
+		answers: []string{"1.2.3.4", "5.6.7.8"},
+	}, {
+		name:    "A query with service filter",
+		query:   dnsQuery(3, "x9."+host, dnsTypeA),
+		answers: []string{"5.6.7.8"},
+	}, {
+		name:    "AAAA query",
+		query:   dnsQuery(4, "x9."+host, dnsTypeAAAA),
+		answers: []string{"2001:db8::1"},
+	}, {
+		name:  "other type",
+		query: dnsQuery(5, host, 16),
+	}, {
+		name:  "other name",
+		query: dnsQuery(6, "example.com", dnsTypeA),
+		rcode: dnsRcodeRefused,
+	}, {
+		name:  "invalid service filter",
+		query: dnsQuery(7, "xz."+host, dnsTypeA),
+		rcode: dnsRcodeRefused,
+	}, {
+		name:  "truncated question",
+		query: dnsQuery(8, host, dnsTypeA)[:dnsHeaderLen+5],
+		rcode: dnsRcodeFormErr,
+	}}
+
+	for _, test := range tests {
+		resp := dnsResponse(test.query, host, lookup)
+		if len(resp) < dnsHeaderLen {
+			t.Errorf("%s: short response %x", test.name, resp)
+			continue
+		}
+		if resp[0] != test.query[0] || resp[1] != test.query[1] {
+			t.Errorf("%s: response ID mismatch", test.name)
+		}
+		flags := binary.BigEndian.Uint16(resp[2:])
+		if flags&dnsFlagQR == 0 || flags&dnsFlagRD == 0 {
+			t.Errorf("%s: unexpected flags %x", test.name, flags)
+		}
+		if rcode := flags & 0xf; rcode != test.rcode {
+			t.Errorf("%s: got rcode %d, want %d", test.name, rcode,
+				test.rcode)
+			continue
+		}
+		if test.rcode != 0 {
+			continue
+		}
+
+		numAnswers := int(binary.BigEndian.Uint16(resp[6:]))
+		if numAnswers != len(test.answers) {
+			t.Errorf("%s: got %d answers, want %d", test.name,
+				numAnswers, len(test.answers))
+			continue
+		}
+		offset := len(test.query)
+		for i := 0; i < numAnswers; i++ {
+			rdlen := int(binary.BigEndian.Uint16(resp[offset+10:]))
+			ip := net.IP(resp[offset+12 : offset+12+rdlen])
+			if ip.String() != test.answers[i] {
+				t.Errorf("%s: answer %d: got %v, want %v",
+					test.name, i, ip, test.answers[i])
+			}
+			offset += 12 + rdlen
+		}
+		if offset != len(resp) {
+			t.Errorf("%s: %d trailing bytes", test.name,
+				len(resp)-offset)
+		}
+	}
+
+	// Responses are not answered and the answers are limited to the size
+	// of a DNS message.
+	resp := dnsResponse(dnsQuery(9, host, dnsTypeA), host, lookup)
+	if dnsResponse(resp, host, lookup) != nil {
+		t.Error("response answered")
+	}
+	many := func(wire.ServiceFlag, bool) []net.IP {
+		ips := make([]net.IP, 100)
+		for i := range ips {
+			ips[i] = net.IPv4(10, 0, 0, byte(i))
+		}
+		return ips
+	}
+	resp = dnsResponse(dnsQuery(10, host, dnsTypeA), host, many)
+	if len(resp) > maxDNSMessageSize || binary.BigEndian.Uint16(resp[6:]) == 0 {
+		t.Errorf("unexpected response of %d bytes with %d answers",
+			len(resp), binary.BigEndian.Uint16(resp[6:]))
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package seeder implements a DNS seed which crawls the network for nodes and
+serves the addresses of the reliable ones over DNS.
+
+The seeder connects to the known nodes, performs the handshake and requests the
+addresses they know with getaddr messages, which are added to the nodes to
+crawl.  Nodes which were reachable are crawled again every 30 minutes, while
+unreachable nodes are retried with an exponential backoff and forgotten once
+they were unreachable for a week.  The statistics of the nodes are kept in a
+bucket of the database of the node so they survive restarts.
+
+The reliability of a node is an exponential moving average of the outcomes of
+the attempts to crawl it.  Nodes are served once they are reliable, were
+reachable within the last two hours, listen on the default port of the network
+and advertise the network service.
+
+The embedded DNS responder answers A and AAAA queries over UDP for the host name
+of the seed with random addresses of the served nodes.  Queries for subdomains
+of the form x<hex services>, such as x9.seed.example.com, are answered with the
+nodes which advertise the services, which is how clients such as btcd request
+nodes supporting specific services.  The domain of the seed must be delegated
+to the host the seeder runs on with an NS record.
+*/
+package seeder
@@ -0,0 +1,203 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package seeder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// recrawlInterval is the interval at which nodes which were reachable
+	// the last time they were crawled are crawled again.
+	recrawlInterval = 30 * time.Minute
+
+	// retryInterval is the time to wait before crawling a node again after
+	// the first failed attempt.  It doubles with every consecutive failure
+	// up to maxRetryInterval.
+	retryInterval = 15 * time.Minute
+
+	// maxRetryInterval is the maximum time to wait before crawling a node
+	// again after failed attempts.
+	maxRetryInterval = 24 * time.Hour
+
+	// maxFailures is the number of consecutive failed attempts after which
+	// a node which was not reachable for maxNodeAge is forgotten.
+	maxFailures = 10
+
+	// maxNodeAge is the time since a node was last reachable after which it
+	// is forgotten once it failed maxFailures times in a row.
+	maxNodeAge = 7 * 24 * time.Hour
+
+	// reliabilityWeight is the weight of the outcome of the latest attempt
+	// in the reliability of a node, which is an exponential moving average
+	// of the outcomes of the attempts.
+	reliabilityWeight = 0.3
+
+	// minReliability is the reliability a node must have to be served.  A
+	// node reaches it after two successful attempts in a row.
+	minReliability = 0.5
+
+	// maxGoodAge is the time since a node was last reachable after which it
+	// is no longer served.
+	maxGoodAge = 2 * time.Hour
+
+	// minProtocolVersion is the minimum protocol version a node must
+	// support to be served.
+	minProtocolVersion = wire.SendHeadersVersion
+
+	// statsVersion is the version of the serialized node statistics.
+	statsVersion = 1
+
+	// serializedStatsLen is the length of the serialized node statistics.
+	// It is the version, the services, the protocol version, the height,
+	// the times of the last attempt and the last success, the number of
+	// consecutive failures and the reliability.
+	serializedStatsLen = 1 + 8 + 4 + 4 + 8 + 8 + 4 + 8
+)
+
+// byteOrder is the preferred byte order used for serializing the node
+// statistics.
+var byteOrder = binary.LittleEndian
+
+// node houses the statistics of a crawled node.
+type node struct {
+	// services, protocolVersion and height are the services, protocol
+	// version and height the node advertised the last time it was
+	// reachable.
+	services        wire.ServiceFlag
+	protocolVersion uint32
+	height          int32
+
+	// lastTry and lastSuccess are the times of the last attempt to crawl
+	// the node and the last successful one.
+	lastTry     time.Time
+	lastSuccess time.Time
+
+	// failures is the number of consecutive failed attempts.
+	failures uint32
+
+	// reliability is the exponential moving average of the outcomes of the
+	// attempts, where a success counts as 1 and a failure as 0.
+	reliability float64
+
+	// crawling is whether the node is being crawled.  It is not persisted.
+	crawling bool
+}
+
+// due returns whether the node should be crawled at the passed time.
+func (n *node) due(now time.Time) bool {
+	if n.crawling {
+		return false
+	}
+	if n.lastTry.IsZero() {
+		return true
+	}
+	if n.failures == 0 {
+		return now.Sub(n.lastTry) >= recrawlInterval
+	}
+
+	wait := maxRetryInterval
+	if n.failures <= 16 {
+		wait = retryInterval << (n.failures - 1)
+		if wait > maxRetryInterval {
+			wait = maxRetryInterval
+		}
+	}
+	return now.Sub(n.lastTry) >= wait
+}
+
+// expired returns whether the node should be forgotten at the passed time.
+func (n *node) expired(now time.Time) bool {
+	return n.failures >= maxFailures && (n.lastSuccess.IsZero() ||
+		now.Sub(n.lastSuccess) > maxNodeAge)
+}
+
+// good returns whether the node is reliable enough to be served at the passed
+// time.
+func (n *node) good(now time.Time) bool {
+	return !n.lastSuccess.IsZero() && now.Sub(n.lastSuccess) <= maxGoodAge &&
+		n.reliability >= minReliability &&
+		n.protocolVersion >= minProtocolVersion &&
+		n.services&wire.SFNodeNetwork == wire.SFNodeNetwork
+}
+
+// record updates the statistics of the node with the outcome of an attempt to
+// crawl it at the passed time.
+func (n *node) record(res *crawlResult, now time.Time) {
+	n.lastTry = now
+	n.reliability *= 1 - reliabilityWeight
+	if !res.success {
+		n.failures++
+		return
+	}
+
+	n.reliability += reliabilityWeight
+	n.failures = 0
+	n.lastSuccess = now
+	n.services = res.services
+	n.protocolVersion = res.protocolVersion
+	n.height = res.height
+}
+
+// putTime serializes the passed time as seconds since 1 Jan 1970 UTC, where the
+// zero time is serialized as 0.
+func putTime(b []byte, t time.Time) {
+	var secs int64
+	if !t.IsZero() {
+		secs = t.Unix()
+	}
+	byteOrder.PutUint64(b, uint64(secs))
+}
+
+// getTime returns the time serialized by putTime.
+func getTime(b []byte) time.Time {
+	secs := int64(byteOrder.Uint64(b))
+	if secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// serializeNode returns the serialized statistics of the node.
+func serializeNode(n *node) []byte {
+	serialized := make([]byte, serializedStatsLen)
+	serialized[0] = statsVersion
+	byteOrder.PutUint64(serialized[1:], uint64(n.services))
+	byteOrder.PutUint32(serialized[9:], n.protocolVersion)
+	byteOrder.PutUint32(serialized[13:], uint32(n.height))
+	putTime(serialized[17:], n.lastTry)
+	putTime(serialized[25:], n.lastSuccess)
+	byteOrder.PutUint32(serialized[33:], n.failures)
+	byteOrder.PutUint64(serialized[37:], math.Float64bits(n.reliability))
+	return serialized
+}
+
+// deserializeNode returns the statistics of a node serialized by
+// serializeNode.
+func deserializeNode(serialized []byte) (*node, error) {
+	if len(serialized) != serializedStatsLen {
+		return nil, fmt.Errorf("unexpected length %d of serialized node "+
+			"statistics", len(serialized))
+	}
+	if serialized[0] != statsVersion {
+		return nil, fmt.Errorf("unknown version %d of serialized node "+
+			"statistics", serialized[0])
+	}
+
+	return &node{
+		services:        wire.ServiceFlag(byteOrder.Uint64(serialized[1:])),
+		protocolVersion: byteOrder.Uint32(serialized[9:]),
+		height:          int32(byteOrder.Uint32(serialized[13:])),
+		lastTry:         getTime(serialized[17:]),
+		lastSuccess:     getTime(serialized[25:]),
+		failures:        byteOrder.Uint32(serialized[33:]),
+		reliability:     math.Float64frombits(byteOrder.Uint64(serialized[37:])),
+	}, nil
+}
@@ -0,0 +1,585 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package seeder
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/addrmgr"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// scheduleInterval is the interval at which the nodes which are due are
+	// handed to the crawlers.
+	scheduleInterval = 5 * time.Second
+
+	// refreshInterval is the interval at which the list of the nodes which
+	// are served is rebuilt.
+	refreshInterval = time.Minute
+
+	// flushInterval is the interval at which the modified node statistics
+	// are written to the database.
+	flushInterval = 10 * time.Minute
+
+	// seedInterval is the interval at which the addresses known to the
+	// node are added to the nodes to crawl.
+	seedInterval = 10 * time.Minute
+
+	// statusInterval is the interval at which the number of known and good
+	// nodes is logged.
+	statusInterval = 10 * time.Minute
+
+	// addrTimeout is the time to wait for the addresses requested from a
+	// node after the handshake.
+	addrTimeout = 30 * time.Second
+
+	// maxNodes is the maximum number of nodes which are tracked.  Newly
+	// learned addresses are ignored while the maximum is reached.
+	maxNodes = 50000
+
+	// maxAnswers is the maximum number of addresses returned for a query.
+	// Fewer are returned when they don't fit a DNS message.
+	maxAnswers = 32
+
+	// userAgentName and userAgentVersion are the user agent the crawler
+	// identifies with.
+	userAgentName    = "btcdseeder"
+	userAgentVersion = "0.1.0"
+)
+
+// bucketName is the name of the bucket of the database metadata which houses
+// the statistics of the crawled nodes keyed by their address.
+var bucketName = []byte("seedernodes")
+
+// Config houses the configuration of a seeder.
+type Config struct {
+	// DB is the database the statistics of the crawled nodes are persisted
+	// in.
+	DB database.DB
+
+	// ChainParams identifies the network which is crawled.  Only nodes on
+	// its default port are served since DNS can't convey ports.
+	ChainParams *chaincfg.Params
+
+	// Host is the host name of the seed, such as seed.example.com.  Queries
+	// for it and for its subdomains of the form x<hex services> are
+	// answered.
+	Host string
+
+	// Listen is the UDP address DNS queries are answered on.
+	Listen string
+
+	// Crawlers is the number of nodes crawled concurrently.
+	Crawlers int
+
+	// AllowUnroutable allows crawling and serving addresses which aren't
+	// routable on the public internet, such as those of private networks.
+	AllowUnroutable bool
+
+	// Dial connects to the passed address of the form host:port.
+	Dial func(addr string) (net.Conn, error)
+
+	// NewestBlock returns the best block of the node, which is advertised
+	// to the crawled nodes.  It may be nil.
+	NewestBlock peer.HashFunc
+
+	// Seeds returns addresses of the form host:port to crawl, which are
+	// usually the addresses known to the node.  It is called periodically.
+	Seeds func() []string
+}
+
+// crawlResult is the outcome of an attempt to crawl a node.
+type crawlResult struct {
+	success         bool
+	services        wire.ServiceFlag
+	protocolVersion uint32
+	height          int32
+	addrs           []string
+}
+
+// goodNode is a node which is served.
+type goodNode struct {
+	ip       net.IP
+	services wire.ServiceFlag
+}
+
+// Seeder crawls the network for nodes, tracks their reliability and answers
+// DNS queries for the seed with the addresses of the reliable ones.
+//
+// All methods are safe for concurrent access.
+type Seeder struct {
+	cfg  Config
+	conn net.PacketConn
+
+	mtx      sync.Mutex
+	nodes    map[string]*node
+	dirty    map[string]struct{}
+	removed  map[string]struct{}
+	good     []goodNode
+	lastSeed time.Time
+
+	work chan string
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New returns a seeder after loading the statistics of the nodes persisted
+// previously and starting to listen for DNS queries.
+func New(cfg *Config) (*Seeder, error) {
+	if cfg.Crawlers < 1 {
+		return nil, errors.New("at least one crawler is required")
+	}
+
+	s := &Seeder{
+		cfg:     *cfg,
+		nodes:   make(map[string]*node),
+		dirty:   make(map[string]struct{}),
+		removed: make(map[string]struct{}),
+		work:    make(chan string, cfg.Crawlers),
+		quit:    make(chan struct{}),
+	}
+
+	now := time.Now()
+	err := cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			n, err := deserializeNode(v)
+			if err != nil {
+				log.Warnf("Discarding statistics of %s: %v", k, err)
+				s.removed[string(k)] = struct{}{}
+				return nil
+			}
+			if n.expired(now) {
+				s.removed[string(k)] = struct{}{}
+				return nil
+			}
+			s.nodes[string(k)] = n
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.refresh(now)
+
+	s.conn, err = net.ListenPacket("udp", cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Loaded the statistics of %d nodes, %d of which are good",
+		len(s.nodes), len(s.good))
+	return s, nil
+}
+
+// Start begins crawling the network and answering DNS queries.
+func (s *Seeder) Start() {
+	s.wg.Add(2 + s.cfg.Crawlers)
+	go s.dnsHandler()
+	go s.scheduleHandler()
+	for i := 0; i < s.cfg.Crawlers; i++ {
+		go s.crawlHandler()
+	}
+
+	log.Infof("Answering DNS queries for %s on %s", s.cfg.Host,
+		s.conn.LocalAddr())
+}
+
+// Stop stops crawling and answering queries and writes the modified node
+// statistics to the database.
+func (s *Seeder) Stop() error {
+	close(s.quit)
+	s.conn.Close()
+	s.wg.Wait()
+	return s.flush(time.Now())
+}
+
+// addAddress adds the passed address of the form host:port to the nodes to
+// crawl unless it is known, can't be crawled or the maximum number of nodes is
+// tracked.
+//
+// This function MUST be called with the seeder lock held.
+func (s *Seeder) addAddress(addr string) {
+	if _, ok := s.nodes[addr]; ok || len(s.nodes) >= maxNodes {
+		return
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		return
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	na := wire.NetAddressV2FromBytes(time.Now(), 0, ip, uint16(port))
+	if !s.cfg.AllowUnroutable && !addrmgr.IsRoutable(na) {
+		return
+	}
+
+	key := addrmgr.NetAddressKey(na)
+	if _, ok := s.nodes[key]; ok {
+		return
+	}
+	s.nodes[key] = new(node)
+	s.dirty[key] = struct{}{}
+	delete(s.removed, key)
+}
+
+// seed adds the addresses returned by the Seeds function of the configuration
+// to the nodes to crawl.
+func (s *Seeder) seed(now time.Time) {
+	var addrs []string
+	if s.cfg.Seeds != nil {
+		addrs = s.cfg.Seeds()
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, addr := range addrs {
+		s.addAddress(addr)
+	}
+	s.lastSeed = now
+}
+
+// schedule hands the nodes which are due to the crawlers until they are all
+// busy.
+func (s *Seeder) schedule(now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for addr, n := range s.nodes {
+		if !n.due(now) {
+			continue
+		}
+		select {
+		case s.work <- addr:
+			n.crawling = true
+		default:
+			return
+		}
+	}
+}
+
+// record updates the statistics of the node with the passed address with the
+// outcome of an attempt to crawl it and adds the addresses it returned.
+func (s *Seeder) record(addr string, res *crawlResult) {
+	now := time.Now()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	n, ok := s.nodes[addr]
+	if !ok {
+		return
+	}
+	n.crawling = false
+	n.record(res, now)
+	if n.expired(now) {
+		delete(s.nodes, addr)
+		delete(s.dirty, addr)
+		s.removed[addr] = struct{}{}
+	} else {
+		s.dirty[addr] = struct{}{}
+	}
+
+	for _, a := range res.addrs {
+		s.addAddress(a)
+	}
+}
+
+// refresh rebuilds the list of the nodes which are served.
+func (s *Seeder) refresh(now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	defaultPort := s.cfg.ChainParams.DefaultPort
+	good := make([]goodNode, 0, len(s.good))
+	for addr, n := range s.nodes {
+		if !n.good(now) {
+			continue
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || port != defaultPort {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		good = append(good, goodNode{ip: ip, services: n.services})
+	}
+	s.good = good
+}
+
+// lookup returns up to maxAnswers random addresses of the nodes which are
+// served and support the passed services, which are IPv6 addresses when ipv6 is
+// true and IPv4 addresses otherwise.
+func (s *Seeder) lookup(services wire.ServiceFlag, ipv6 bool) []net.IP {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var ips []net.IP
+	for _, i := range rand.Perm(len(s.good)) {
+		n := &s.good[i]
+		if n.services&services != services || (n.ip.To4() == nil) != ipv6 {
+			continue
+		}
+		ips = append(ips, n.ip)
+		if len(ips) == maxAnswers {
+			break
+		}
+	}
+	return ips
+}
+
+// flush writes the node statistics which were modified since the last flush to
+// the database and removes the expired ones.
+func (s *Seeder) flush(now time.Time) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for addr, n := range s.nodes {
+		if !n.crawling && n.expired(now) {
+			delete(s.nodes, addr)
+			delete(s.dirty, addr)
+			s.removed[addr] = struct{}{}
+		}
+	}
+	if len(s.dirty) == 0 && len(s.removed) == 0 {
+		return nil
+	}
+
+	err := s.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		for addr := range s.removed {
+			if err := bucket.Delete([]byte(addr)); err != nil {
+				return err
+			}
+		}
+		for addr := range s.dirty {
+			err := bucket.Put([]byte(addr), serializeNode(s.nodes[addr]))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Flushed the statistics of %d nodes and removed %d",
+		len(s.dirty), len(s.removed))
+	s.dirty = make(map[string]struct{})
+	s.removed = make(map[string]struct{})
+	return nil
+}
+
+// scheduleHandler periodically hands the nodes which are due to the crawlers,
+// rebuilds the list of the nodes which are served, adds the addresses known to
+// the node and flushes the node statistics.  It must be run as a goroutine.
+func (s *Seeder) scheduleHandler() {
+	scheduleTicker := time.NewTicker(scheduleInterval)
+	defer scheduleTicker.Stop()
+	refreshTicker := time.NewTicker(refreshInterval)
+	defer refreshTicker.Stop()
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+	statusTicker := time.NewTicker(statusInterval)
+	defer statusTicker.Stop()
+
+out:
+	for {
+		select {
+		case now := <-scheduleTicker.C:
+			s.mtx.Lock()
+			needSeeds := len(s.nodes) == 0 ||
+				now.Sub(s.lastSeed) >= seedInterval
+			s.mtx.Unlock()
+			if needSeeds {
+				s.seed(now)
+			}
+			s.schedule(now)
+
+		case now := <-refreshTicker.C:
+			s.refresh(now)
+
+		case now := <-flushTicker.C:
+			if err := s.flush(now); err != nil {
+				log.Errorf("Unable to flush node statistics: %v",
+					err)
+			}
+
+		case <-statusTicker.C:
+			s.mtx.Lock()
+			numNodes, numGood := len(s.nodes), len(s.good)
+			s.mtx.Unlock()
+			log.Infof("Tracking %d nodes, %d of which are good",
+				numNodes, numGood)
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
+
+// crawlHandler crawls the nodes handed to it by the scheduler.  It must be run
+// as a goroutine.
+func (s *Seeder) crawlHandler() {
+out:
+	for {
+		select {
+		case addr := <-s.work:
+			s.record(addr, s.crawl(addr))
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
+
+// crawl connects to the node with the passed address, performs the handshake
+// and requests the addresses it knows.
+func (s *Seeder) crawl(addr string) *crawlResult {
+	conn, err := s.cfg.Dial(addr)
+	if err != nil {
+		log.Tracef("Unable to connect to %s: %v", addr, err)
+		return &crawlResult{}
+	}
+
+	addrsChan := make(chan []string, 1)
+	sendAddrs := func(addrs []string) {
+		// Nodes announce their own address in addr messages of their
+		// own, so only replies with more addresses are of interest.
+		if len(addrs) <= 1 {
+			return
+		}
+		select {
+		case addrsChan <- addrs:
+		default:
+		}
+	}
+	peerCfg := &peer.Config{
+		NewestBlock:      s.cfg.NewestBlock,
+		UserAgentName:    userAgentName,
+		UserAgentVersion: userAgentVersion,
+		ChainParams:      s.cfg.ChainParams,
+		DisableRelayTx:   true,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				p.QueueMessage(wire.NewMsgGetAddr(), nil)
+			},
+			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+				addrs := make([]string, 0, len(msg.AddrList))
+				for _, na := range msg.AddrList {
+					addrs = append(addrs, net.JoinHostPort(
+						na.IP.String(),
+						strconv.Itoa(int(na.Port))))
+				}
+				sendAddrs(addrs)
+			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				addrs := make([]string, 0, len(msg.AddrList))
+				for _, na := range msg.AddrList {
+					if na.ToLegacy() == nil {
+						continue
+					}
+					addrs = append(addrs, addrmgr.NetAddressKey(na))
+				}
+				sendAddrs(addrs)
+			},
+		},
+	}
+	p, err := peer.NewOutboundPeer(peerCfg, addr)
+	if err != nil {
+		conn.Close()
+		return &crawlResult{}
+	}
+
+	disconnected := make(chan struct{})
+	p.AssociateConnection(conn)
+	go func() {
+		p.WaitForDisconnect()
+		close(disconnected)
+	}()
+
+	var addrs []string
+	timeout := time.NewTimer(addrTimeout)
+	select {
+	case addrs = <-addrsChan:
+	case <-disconnected:
+	case <-timeout.C:
+	case <-s.quit:
+	}
+	timeout.Stop()
+	p.Disconnect()
+
+	if !p.VerAckReceived() {
+		log.Tracef("Handshake with %s failed", addr)
+		return &crawlResult{}
+	}
+	log.Tracef("Crawled %s (services %v, height %d), which returned %d "+
+		"addresses", addr, p.Services(), p.StartingHeight(), len(addrs))
+	return &crawlResult{
+		success:         true,
+		services:        p.Services(),
+		protocolVersion: p.ProtocolVersion(),
+		height:          p.StartingHeight(),
+		addrs:           addrs,
+	}
+}
+
+// dnsHandler answers the DNS queries for the seed.  It must be run as a
+// goroutine.
+func (s *Seeder) dnsHandler() {
+	buf := make([]byte, maxDNSMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			break
+		}
+		if err != nil {
+			log.Debugf("Unable to read DNS query: %v", err)
+			continue
+		}
+
+		resp := dnsResponse(buf[:n], s.cfg.Host, s.lookup)
+		if resp == nil {
+			continue
+		}
+		if _, err := s.conn.WriteTo(resp, addr); err != nil {
+			log.Debugf("Unable to answer DNS query of %s: %v", addr,
+				err)
+		}
+	}
+
+	s.wg.Done()
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package seeder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestNode ensures the statistics of nodes determine when they are crawled,
+// served and forgotten as intended and survive serialization.
+func TestNode(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+	success := &crawlResult{
+		success:         true,
+		services:        wire.SFNodeNetwork | wire.SFNodeWitness,
+		protocolVersion: wire.ProtocolVersion,
+		height:          100,
+	}
+	failure := &crawlResult{}
+
+	n := new(node)
+	if !n.due(now) || n.good(now) || n.expired(now) {
+		t.Fatal("unexpected state of new node")
+	}
+
+	// A node is served after two successful attempts in a row.
+	n.record(success, now)
+	if n.due(now.Add(recrawlInterval-time.Second)) ||
+		!n.due(now.Add(recrawlInterval)) || n.good(now) {
+
+		t.Fatal("unexpected state after one success")
+	}
+	now = now.Add(recrawlInterval)
+	n.record(success, now)
+	if !n.good(now) || n.good(now.Add(maxGoodAge+time.Second)) {
+		t.Fatal("unexpected state after two successes")
+	}
+
+	serialized := serializeNode(n)
+	deserialized, err := deserializeNode(serialized)
+	if err != nil {
+		t.Fatalf("deserializeNode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(deserialized, n) {
+		t.Fatalf("deserializeNode: got %+v, want %+v", deserialized, n)
+	}
+	if _, err := deserializeNode(serialized[1:]); err == nil {
+		t.Fatal("deserializeNode: expected error for short statistics")
+	}
+
+	// Failed attempts back off exponentially and make the node unreliable.
+	n.record(failure, now)
+	if n.good(now) || n.due(now.Add(retryInterval-time.Second)) ||
+		!n.due(now.Add(retryInterval)) {
+
+		t.Fatal("unexpected state after one failure")
+	}
+	n.record(failure, now)
+	if n.due(now.Add(2*retryInterval-time.Second)) ||
+		!n.due(now.Add(2*retryInterval)) {
+
+		t.Fatal("unexpected backoff after two failures")
+	}
+
+	// Nodes are forgotten after failing repeatedly for a long time.
+	for i := 2; i < maxFailures; i++ {
+		n.record(failure, now)
+	}
+	if !n.due(now.Add(maxRetryInterval)) || n.expired(now) ||
+		!n.expired(now.Add(maxNodeAge+time.Second)) {
+
+		t.Fatal("unexpected state after repeated failures")
+	}
+}
+
+// TestSeeder ensures addresses are added to the nodes to crawl as intended,
+// the reliable nodes on the default port are served and the statistics persist.
+func TestSeeder(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Create("ffldb", t.TempDir(), wire.SimNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	params := chaincfg.MainNetParams
+	cfg := &Config{
+		DB:          db,
+		ChainParams: &params,
+		Host:        "seed.example.com",
+		Listen:      "127.0.0.1:0",
+		Crawlers:    1,
+		Seeds: func() []string {
+			return []string{"1.2.3.4:8333", "[2001:db8::1]:8333",
+				"5.6.7.8:18333", "10.0.0.1:8333", "bogus",
+				"[::ffff:1.2.3.4]:8333"}
+		},
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	s.conn.Close()
+
+	// Unroutable and invalid addresses are ignored.  Note 2001:db8::/32 is
+	// reserved for documentation and thus unroutable as well.
+	now := time.Now()
+	s.seed(now)
+	want := []string{"1.2.3.4:8333", "5.6.7.8:18333"}
+	if len(s.nodes) != len(want) {
+		t.Fatalf("unexpected nodes %v", s.nodes)
+	}
+	for _, addr := range want {
+		if _, ok := s.nodes[addr]; !ok {
+			t.Fatalf("%s not added", addr)
+		}
+	}
+
+	// Only nodes on the default port are served.
+	success := &crawlResult{
+		success:         true,
+		services:        wire.SFNodeNetwork,
+		protocolVersion: wire.ProtocolVersion,
+		addrs:           []string{"9.9.9.9:8333"},
+	}
+	for i := 0; i < 2; i++ {
+		for _, addr := range want {
+			s.record(addr, success)
+		}
+	}
+	if _, ok := s.nodes["9.9.9.9:8333"]; !ok {
+		t.Fatal("returned address not added")
+	}
+	s.refresh(time.Now())
+	if ips := s.lookup(wire.SFNodeNetwork, false); len(ips) != 1 ||
+		ips[0].String() != "1.2.3.4" {
+
+		t.Fatalf("unexpected served addresses %v", ips)
+	}
+	if ips := s.lookup(wire.SFNodeWitness, false); len(ips) != 0 {
+		t.Fatalf("unexpected served addresses %v", ips)
+	}
+
+	if err := s.flush(time.Now()); err != nil {
+		t.Fatalf("flush: unexpected error: %v", err)
+	}
+	s, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	s.conn.Close()
+	if len(s.nodes) != 3 || len(s.good) != 1 {
+		t.Fatalf("unexpected state after reload: %d nodes, %d good",
+			len(s.nodes), len(s.good))
+	}
+}
@@ -10,10 +10,14 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
+	mrand "math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -23,21 +27,40 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/addrmgr"
+	"github.com/btcsuite/btcd/banman"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/blocktrace"
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/bloom"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/diagnostics"
+	"github.com/btcsuite/btcd/finality"
+	"github.com/btcsuite/btcd/grpcserver"
 	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/metrics"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
+	"github.com/btcsuite/btcd/msgcapture"
 	"github.com/btcsuite/btcd/netsync"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/peerauth"
+	"github.com/btcsuite/btcd/rebroadcast"
+	"github.com/btcsuite/btcd/reputation"
+	"github.com/btcsuite/btcd/seeder"
+	"github.com/btcsuite/btcd/sv2"
+	"github.com/btcsuite/btcd/torcontrol"
+	"github.com/btcsuite/btcd/txrecon"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/watchonly"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmq"
+	"github.com/btcsuite/go-socks/socks"
 	"github.com/decred/dcrd/lru"
 )
 
@@ -54,10 +77,82 @@ const (
 	// defaultTargetOutbound is the default number of outbound peers to target.
 	defaultTargetOutbound = 8
 
+	// defaultTargetBlockRelayOnly is the default number of block relay only
+	// outbound peers to target in addition to defaultTargetOutbound.  These
+	// peers are not relayed transactions or addresses, which makes them
+	// harder to discover and to eclipse the node.
+	defaultTargetBlockRelayOnly = 2
+
+	// onionKeyFilename is the name of the file in the data directory the
+	// private key of the onion service created via the Tor control port is
+	// saved to, so the onion address stays the same across restarts.
+	onionKeyFilename = "onion_v3_private_key"
+
+	// messageCaptureDirName is the name of the directory in the data
+	// directory the messages of the peers are recorded in when message
+	// capture is enabled.
+	messageCaptureDirName = "message_capture"
+
+	// sv2StaticKeyFilename and sv2AuthorityKeyFilename are the names of the
+	// files in the data directory the keys of the Stratum V2 template
+	// provider are saved to, so clients can keep trusting the authority
+	// key across restarts.
+	sv2StaticKeyFilename    = "sv2_static_key"
+	sv2AuthorityKeyFilename = "sv2_authority_key"
+
+	// natLeaseDuration is the lifetime of the port mappings requested from
+	// NATs.  The mappings are renewed after half of it as recommended by
+	// RFC 6886 and retried every natRetryInterval after failures.
+	natLeaseDuration = time.Minute * 20
+	natRenewInterval = natLeaseDuration / 2
+	natRetryInterval = time.Minute
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// revocationReloadInterval is the interval at which the revocation
+	// list of the peer authenticator is checked for changes.
+	revocationReloadInterval = time.Minute
+
+	// txReconRequestInterval is the interval at which reconciliation
+	// rounds are started with the outbound peers that negotiated
+	// transaction reconciliation.
+	txReconRequestInterval = time.Second * 2
+
+	// maxFilterAdds is the maximum number of elements a peer may add to a
+	// loaded bloom filter with filteradd messages.  Adding more elements
+	// than a filter was sized for raises its false positive rate, which
+	// makes serving it more expensive, so peers must load a new filter
+	// instead.
+	maxFilterAdds = 1000
+
+	// maxFilterLoadBanScore is the decaying ban score increase for loading
+	// a bloom filter of the maximum size.  Smaller filters increase the
+	// score proportionally, which limits the rate at which peers can make
+	// the server rebuild filters.
+	maxFilterLoadBanScore = 20
+
+	// addrTokenRate is the rate in addresses per second at which a peer
+	// earns tokens to have the addresses it relays unsolicited processed.
+	// The addresses relayed in excess of its tokens are ignored.
+	addrTokenRate = 0.1
+
+	// addrTokenBurst is the maximum number of address tokens a peer can
+	// hold.  Sending a getaddr message to a peer also gives it this many
+	// tokens so the reply is processed.
+	addrTokenBurst = wire.MaxAddrPerMsg
+
+	// globalAddrTokenRate and globalAddrTokenBurst limit the number of
+	// addresses processed from all peers combined in the same way.
+	globalAddrTokenRate  = 10
+	globalAddrTokenBurst = 10 * wire.MaxAddrPerMsg
+
+	// historicalBlockAge is the age of the blocks which are no longer
+	// served to peers once the historical block serving part of the upload
+	// target was reached.
+	historicalBlockAge = time.Hour * 24 * 7
 )
 
 var (
@@ -73,6 +168,10 @@ var (
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
 var zeroHash chainhash.Hash
 
+// errUploadTargetReached is returned when requested data is not served since
+// the upload target was reached.
+var errUploadTargetReached = errors.New("upload target reached")
+
 // onionAddr implements the net.Addr interface and represents a tor address.
 type onionAddr struct {
 	addr string
@@ -124,14 +223,6 @@ type broadcastMsg struct {
 	excludePeers []*serverPeer
 }
 
-// broadcastInventoryAdd is a type used to declare that the InvVect it contains
-// needs to be added to the rebroadcast map
-type broadcastInventoryAdd relayMsg
-
-// broadcastInventoryDel is a type used to declare that the InvVect it contains
-// needs to be removed from the rebroadcast map
-type broadcastInventoryDel *wire.InvVect
-
 // relayMsg packages an inventory vector along with the newly discovered
 // inventory so the relay has access to that information.
 type relayMsg struct {
@@ -152,12 +243,11 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
 }
 
@@ -206,43 +296,112 @@ type server struct {
 	shutdownSched int32
 	startupTime   int64
 
-	chainParams          *chaincfg.Params
-	addrManager          *addrmgr.AddrManager
-	connManager          *connmgr.ConnManager
-	sigCache             *txscript.SigCache
-	hashCache            *txscript.HashCache
-	rpcServer            *rpcServer
-	syncManager          *netsync.SyncManager
-	chain                *blockchain.BlockChain
-	txMemPool            *mempool.TxPool
-	cpuMiner             *cpuminer.CPUMiner
-	modifyRebroadcastInv chan interface{}
-	newPeers             chan *serverPeer
-	donePeers            chan *serverPeer
-	banPeers             chan *serverPeer
-	query                chan interface{}
-	relayInv             chan relayMsg
-	broadcast            chan broadcastMsg
-	peerHeightsUpdate    chan updatePeerHeightsMsg
-	wg                   sync.WaitGroup
-	quit                 chan struct{}
-	nat                  NAT
-	db                   database.DB
-	timeSource           blockchain.MedianTimeSource
-	services             wire.ServiceFlag
+	chainParams       *chaincfg.Params
+	addrManager       *addrmgr.AddrManager
+	connManager       *connmgr.ConnManager
+	sigCache          *txscript.SigCache
+	hashCache         *txscript.HashCache
+	rpcServer         *rpcServer
+	syncManager       *netsync.SyncManager
+	chain             *blockchain.BlockChain
+	txMemPool         *mempool.TxPool
+	cpuMiner          *cpuminer.CPUMiner
+	newPeers          chan *serverPeer
+	donePeers         chan *serverPeer
+	banPeers          chan *serverPeer
+	query             chan interface{}
+	relayInv          chan relayMsg
+	broadcast         chan broadcastMsg
+	peerHeightsUpdate chan updatePeerHeightsMsg
+	wg                sync.WaitGroup
+	quit              chan struct{}
+	nat               NAT
+	db                database.DB
+	timeSource        blockchain.MedianTimeSource
+	services          wire.ServiceFlag
+
+	// diagnostics writes the profiles and state dumps requested over RPC
+	// or with the state dump signals to the profiles directory.
+	diagnostics *diagnostics.Collector
 
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
+	txIndex      *indexers.TxIndex
+	addrIndex    *indexers.AddrIndex
+	cfIndex      *indexers.CfIndex
+	txCountIndex *indexers.TxCountIndex
+	spentIndex   *indexers.SpentIndex
+	tsIndex      *indexers.TimestampIndex
+	balanceIndex *indexers.BalanceIndex
+
+	// indexManager manages the enabled indexes and backfills the ones
+	// which are far behind the best chain.  It is nil when no indexes are
+	// enabled.
+	indexManager *indexers.Manager
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	feeEstimator *mempool.FeeEstimator
 
+	// finalityMgr collects the attestations of the finality signers.  It
+	// is nil unless the finality module is enabled.
+	finalityMgr *finality.Manager
+
+	// blockTracer records the lifecycle of the blocks relayed at the tip
+	// of the chain.  It is nil unless block tracing is enabled.
+	blockTracer *blocktrace.Tracer
+
+	// peerReputation keeps the statistics of the peers across restarts
+	// and biases the selection of outbound peers by them.
+	peerReputation *reputation.Tracker
+
+	// banManager keeps the bans and discouragements of peers across
+	// restarts.
+	banManager *banman.Manager
+
+	// watchOnly tracks the outputs of the descriptors imported with
+	// importdescriptors and funds transactions with them.
+	watchOnly *watchonly.Wallet
+
+	// rebroadcaster re-announces the transactions submitted through the
+	// RPC server until they are confirmed.  It is nil when the RPC server
+	// is disabled.
+	rebroadcaster *rebroadcast.Manager
+
+	// seeder crawls the network and answers DNS queries for the seed with
+	// the addresses of reliable peers.  It is nil unless --dnsseeder is
+	// set.
+	seeder *seeder.Seeder
+
+	// peerAuth authenticates the peers of a permissioned network.  It is
+	// nil unless peer authentication is enabled.
+	peerAuth *peerauth.Authenticator
+
+	// zmqNotifier publishes notifications about blocks and transactions
+	// over ZeroMQ.  It doesn't publish anything unless any of the ZeroMQ
+	// topics is configured.
+	zmqNotifier *zmq.Notifier
+
+	// grpcServer serves the gRPC interface.  It is nil unless any gRPC
+	// listener is configured.
+	grpcServer *grpcserver.Server
+
+	// metricsServer serves the metrics of the node to Prometheus.  It is nil
+	// unless any metrics listener is configured.
+	metricsServer *metrics.Server
+
+	// sv2Server serves block templates to Stratum V2 clients.  It is nil
+	// unless any Stratum V2 listen address is configured.
+	sv2Server *sv2.Server
+
+	// v1Addrs holds the addresses of outbound peers that are known not to
+	// support the v2 transport protocol, either because they do not
+	// advertise it or because they rejected it, so connections to them use
+	// the v1 protocol right away.
+	v1Addrs lru.Cache
+
 	// cfCheckptCaches stores a cached slice of filter headers for cfcheckpt
 	// messages for each filter type.
 	cfCheckptCaches    map[wire.FilterType][]cfHeaderKV
@@ -255,6 +414,44 @@ type server struct {
 	// agentWhitelist is a list of whitelisted user agent substrings, no
 	// whitelisting will be applied if the list is empty or nil.
 	agentWhitelist []string
+
+	// asMap groups the addresses of outbound peers by the AS announcing
+	// them when it is loaded.
+	asMap *addrmgr.ASMap
+
+	// torController creates an onion service for the listening port and
+	// learns the address of the SOCKS proxy of Tor.  It is nil unless the
+	// Tor control port is configured.
+	torController *torcontrol.Controller
+
+	// uploadTarget enforces the daily upload target.  It imposes no limit
+	// unless the upload target is configured.
+	uploadTarget *connmgr.UploadTarget
+
+	// natPort is the listening port mapped on the NAT when one is in use.
+	// natMapping is the current mapping of the port, which is nil until
+	// the port is mapped and after the mapping expired.  It is protected
+	// by natMtx.
+	natPort    uint16
+	natMtx     sync.Mutex
+	natMapping *portMapping
+
+	// recvLimiter, servingLimiter and addrLimiter limit the bytes read
+	// from, the bytes of requested data sent to and the addresses
+	// processed from all peers which aren't whitelisted combined.  The
+	// byte limiters are nil unless the rate is configured.
+	recvLimiter    *connmgr.TokenBucket
+	servingLimiter *connmgr.TokenBucket
+	addrLimiter    *connmgr.TokenBucket
+}
+
+// portMapping describes a mapping of the listening port on a NAT.
+type portMapping struct {
+	protocol     string
+	externalIP   net.IP
+	externalPort uint16
+	internalPort uint16
+	expires      time.Time
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
@@ -263,20 +460,33 @@ type serverPeer struct {
 	// The following variables must only be used atomically
 	feeFilter int64
 
+	// addrProcessed and addrRateLimited are the number of addresses
+	// relayed by the peer which were processed and which were ignored for
+	// exceeding the address rate limit.
+	addrProcessed   uint64
+	addrRateLimited uint64
+
 	*peer.Peer
 
 	connReq        *connmgr.ConnReq
 	server         *server
 	persistent     bool
+	blockRelayOnly bool
 	continueHash   *chainhash.Hash
 	relayMtx       sync.Mutex
 	disableRelayTx bool
 	sentAddrs      bool
 	isWhitelisted  bool
 	filter         *bloom.Filter
+	filterAdds     uint32
 	addressesMtx   sync.RWMutex
 	knownAddresses lru.Cache
 	banScore       connmgr.DynamicBanScore
+	txRecon        *txrecon.PeerState
+	recvLimiter    *connmgr.TokenBucket
+	servingLimiter *connmgr.TokenBucket
+	addrLimiter    *connmgr.TokenBucket
+	msgCapture     *msgcapture.Writer
 	quit           chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
@@ -291,12 +501,27 @@ func newServerPeer(s *server, isPersistent bool) *serverPeer {
 		persistent:     isPersistent,
 		filter:         bloom.LoadFilter(nil),
 		knownAddresses: lru.NewCache(5000),
+		recvLimiter:    newByteRateLimiter(cfg.MaxPeerRecvRate),
+		servingLimiter: newByteRateLimiter(cfg.MaxPeerServingRate),
+		addrLimiter: connmgr.NewTokenBucket(addrTokenRate,
+			addrTokenBurst, 1),
 		quit:           make(chan struct{}),
 		txProcessed:    make(chan struct{}, 1),
 		blockProcessed: make(chan struct{}, 1),
 	}
 }
 
+// newByteRateLimiter returns a token bucket which limits the bytes transferred
+// to the passed rate in KiB/s, or nil when the rate is 0.  The bucket holds the
+// bytes of one second.
+func newByteRateLimiter(kibPerSec uint32) *connmgr.TokenBucket {
+	if kibPerSec == 0 {
+		return nil
+	}
+	rate := float64(kibPerSec) * 1024
+	return connmgr.NewTokenBucket(rate, rate, rate)
+}
+
 // newestBlock returns the current best block hash and height using the format
 // required by the configuration for the peer package.
 func (sp *serverPeer) newestBlock() (*chainhash.Hash, int32, error) {
@@ -341,6 +566,56 @@ func (sp *serverPeer) relayTxDisabled() bool {
 	return isDisabled
 }
 
+// acceptsTx returns whether the passed transaction may be relayed to the peer
+// given its transaction relay setting, fee filter and bloom filter.
+// It is safe for concurrent access.
+func (sp *serverPeer) acceptsTx(txD *mempool.TxDesc) bool {
+	// Don't relay the transaction to the peer when it has transaction
+	// relaying disabled.
+	if sp.relayTxDisabled() {
+		return false
+	}
+
+	// Don't relay the transaction if the transaction fee-per-kb is less
+	// than the peer's feefilter.
+	feeFilter := atomic.LoadInt64(&sp.feeFilter)
+	if feeFilter > 0 && txD.FeePerKB < feeFilter {
+		return false
+	}
+
+	// Don't relay the transaction if there is a bloom filter loaded and
+	// the transaction doesn't match it.
+	if sp.filter.IsLoaded() && !sp.filter.MatchTxAndUpdate(txD.Tx) {
+		return false
+	}
+
+	return true
+}
+
+// AnnounceTransaction announces the passed transaction to the peer unless it
+// is already known to have it and returns whether the peer has been made aware
+// of the transaction.  It returns false when the peer doesn't accept the
+// transaction.
+//
+// This is part of the rebroadcast.Peer interface implementation.
+func (sp *serverPeer) AnnounceTransaction(txD *mempool.TxDesc) bool {
+	if !sp.Connected() || !sp.acceptsTx(txD) {
+		return false
+	}
+
+	// Queueing the inventory is a no-op when the peer is already known
+	// to have the transaction.
+	sp.QueueInventory(wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash()))
+	return true
+}
+
+// blocksOnly returns whether only blocks are exchanged with the peer, which is
+// the case when the server runs in blocks only mode or the peer is a block
+// relay only peer.
+func (sp *serverPeer) blocksOnly() bool {
+	return cfg.BlocksOnly || sp.blockRelayOnly
+}
+
 // pushAddrMsg sends a legacy addr message to the connected peer using the
 // provided addresses.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddressV2) {
@@ -379,8 +654,10 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddressV2) {
 			continue
 		}
 
-		// Must skip the V3 addresses for legacy ADDR messages.
-		if addr.IsTorV3() {
+		// Must skip the addresses which can only be relayed in ADDRV2
+		// messages, such as torv3, I2P and CJDNS addresses, for legacy
+		// ADDR messages.
+		if addr.ToLegacy() == nil {
 			continue
 		}
 
@@ -413,7 +690,7 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddressV2) {
 // addBanScore increases the persistent and decaying ban score fields by the
 // values passed as parameters. If the resulting score exceeds half of the ban
 // threshold, a warning is logged including the reason provided. Further, if
-// the score is above the ban threshold, the peer will be banned and
+// the score is above the ban threshold, the peer will be discouraged and
 // disconnected.
 func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) bool {
 	// No warning is logged and no score is calculated if banning is disabled.
@@ -425,7 +702,8 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) b
 		return false
 	}
 
-	warnThreshold := cfg.BanThreshold >> 1
+	banThreshold := liveCfg().BanThreshold
+	warnThreshold := banThreshold >> 1
 	if transient == 0 && persistent == 0 {
 		// The score is not being increased, but a warning message is still
 		// logged if the score is above the warn threshold.
@@ -437,13 +715,15 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) b
 		return false
 	}
 	score := sp.banScore.Increase(persistent, transient)
+	sp.server.peerReputation.Misbehaved(sp.Addr(), persistent+transient)
 	if score > warnThreshold {
 		peerLog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d",
 			sp, reason, score)
-		if score > cfg.BanThreshold {
-			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
+		if score > banThreshold {
+			peerLog.Warnf("Misbehaving peer %s -- discouraging and disconnecting",
 				sp)
 			sp.server.BanPeer(sp)
+			sp.server.peerReputation.Banned(sp.Addr())
 			sp.Disconnect()
 			return true
 		}
@@ -521,8 +801,9 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 	sp.server.timeSource.AddTimeSample(sp.Addr(), msg.Timestamp)
 
 	// Choose whether or not to relay transactions before a filter command
-	// is received.
-	sp.setDisableRelayTx(msg.DisableRelayTx)
+	// is received.  Transactions are never relayed to block relay only
+	// peers.
+	sp.setDisableRelayTx(msg.DisableRelayTx || sp.blockRelayOnly)
 
 	return nil
 }
@@ -530,6 +811,14 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 // OnVerAck is invoked when a peer receives a verack bitcoin message and is used
 // to kick start communication with them.
 func (sp *serverPeer) OnVerAck(_ *peer.Peer, _ *wire.MsgVerAck) {
+	// Set up transaction reconciliation when both peers offered it during
+	// the handshake.  The peer that initiated the connection initiates the
+	// reconciliation rounds.
+	if localSalt, remoteSalt, ok := sp.TxReconSalts(); ok {
+		sp.txRecon = txrecon.NewPeerState(!sp.Inbound(), localSalt,
+			remoteSalt)
+	}
+
 	sp.server.AddPeer(sp)
 }
 
@@ -547,6 +836,15 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 		return
 	}
 
+	// Mempool requests of peers which aren't whitelisted are refused once
+	// the upload target was reached.
+	if !sp.isWhitelisted && sp.server.uploadTarget.Reached(false) {
+		peerLog.Debugf("peer %v sent mempool request with the upload "+
+			"target reached -- disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
+
 	// A decaying ban score increase is applied to prevent flooding.
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
@@ -588,7 +886,7 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // handler this does not serialize all transactions through a single thread
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
-	if cfg.BlocksOnly {
+	if sp.blocksOnly() {
 		peerLog.Tracef("Ignoring tx %v from %v - blocksonly enabled",
 			msg.TxHash(), sp)
 		return
@@ -641,7 +939,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !sp.blocksOnly() {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -1204,10 +1502,116 @@ func (sp *serverPeer) OnFeeFilter(_ *peer.Peer, msg *wire.MsgFeeFilter) {
 	atomic.StoreInt64(&sp.feeFilter, msg.MinFee)
 }
 
+// OnAttest is invoked when a peer receives an attest message.  Valid
+// attestations that were not seen before are relayed to the other peers.  The
+// message is ignored unless the finality module is enabled.
+func (sp *serverPeer) OnAttest(_ *peer.Peer, msg *wire.MsgAttest) {
+	finalityMgr := sp.server.finalityMgr
+	if finalityMgr == nil {
+		return
+	}
+
+	isNew, err := finalityMgr.ProcessAttestation(msg)
+	if err != nil {
+		peerLog.Debugf("Rejected attestation for block %v from %v: %v",
+			msg.BlockHash, sp, err)
+		sp.addBanScore(0, 20, "attest")
+		return
+	}
+	if isNew {
+		sp.server.BroadcastMessage(msg, sp)
+	}
+}
+
+// announceTxns queues inventory announcing the transactions with the passed
+// hashes to the peer as a result of transaction reconciliation.
+func (sp *serverPeer) announceTxns(hashes []chainhash.Hash) {
+	for i := range hashes {
+		sp.QueueInventory(wire.NewInvVect(wire.InvTypeTx, &hashes[i]))
+	}
+}
+
+// txReconViolation disconnects the peer after it sent a transaction
+// reconciliation message that is invalid in the state of the reconciliation or
+// without negotiating reconciliation.
+func (sp *serverPeer) txReconViolation(msg wire.Message, err error) {
+	peerLog.Debugf("Disconnecting peer %v after invalid %s message: %v",
+		sp, msg.Command(), err)
+	sp.Disconnect()
+}
+
+// OnReqRecon is invoked when a peer receives a reqrecon message.  It replies
+// with a sketch of the reconciliation set for the peer.
+func (sp *serverPeer) OnReqRecon(_ *peer.Peer, msg *wire.MsgReqRecon) {
+	if sp.txRecon == nil {
+		sp.txReconViolation(msg, txrecon.ErrUnexpectedMessage)
+		return
+	}
+
+	sketch, err := sp.txRecon.HandleReqRecon(msg)
+	if err != nil {
+		sp.txReconViolation(msg, err)
+		return
+	}
+	sp.QueueMessage(sketch, nil)
+}
+
+// OnSketch is invoked when a peer receives a sketch message.  It reconciles the
+// sketch with the reconciliation set for the peer, announces the transactions
+// the peer is missing and requests the ones the local node is missing.
+func (sp *serverPeer) OnSketch(_ *peer.Peer, msg *wire.MsgSketch) {
+	if sp.txRecon == nil {
+		sp.txReconViolation(msg, txrecon.ErrUnexpectedMessage)
+		return
+	}
+
+	reply, announce, err := sp.txRecon.HandleSketch(msg)
+	if err != nil {
+		sp.txReconViolation(msg, err)
+		return
+	}
+	sp.announceTxns(announce)
+	sp.QueueMessage(reply, nil)
+}
+
+// OnReqSketchExt is invoked when a peer receives a reqsketchext message.  It
+// replies with the extension of the sketch sent in the current reconciliation
+// round.
+func (sp *serverPeer) OnReqSketchExt(_ *peer.Peer, msg *wire.MsgReqSketchExt) {
+	if sp.txRecon == nil {
+		sp.txReconViolation(msg, txrecon.ErrUnexpectedMessage)
+		return
+	}
+
+	sketch, err := sp.txRecon.HandleReqSketchExt()
+	if err != nil {
+		sp.txReconViolation(msg, err)
+		return
+	}
+	sp.QueueMessage(sketch, nil)
+}
+
+// OnReconcilDiff is invoked when a peer receives a reconcildiff message.  It
+// announces the transactions the peer is missing.
+func (sp *serverPeer) OnReconcilDiff(_ *peer.Peer, msg *wire.MsgReconcilDiff) {
+	if sp.txRecon == nil {
+		sp.txReconViolation(msg, txrecon.ErrUnexpectedMessage)
+		return
+	}
+
+	announce, err := sp.txRecon.HandleReconcilDiff(msg)
+	if err != nil {
+		sp.txReconViolation(msg, err)
+		return
+	}
+	sp.announceTxns(announce)
+}
+
 // OnFilterAdd is invoked when a peer receives a filteradd bitcoin
 // message and is used by remote peers to add data to an already loaded bloom
 // filter.  The peer will be disconnected if a filter is not loaded when this
-// message is received or the server is not configured to allow bloom filters.
+// message is received, it already added the maximum number of elements to the
+// filter or the server is not configured to allow bloom filters.
 func (sp *serverPeer) OnFilterAdd(_ *peer.Peer, msg *wire.MsgFilterAdd) {
 	// Disconnect and/or ban depending on the node bloom services flag and
 	// negotiated protocol version.
@@ -1222,6 +1626,16 @@ func (sp *serverPeer) OnFilterAdd(_ *peer.Peer, msg *wire.MsgFilterAdd) {
 		return
 	}
 
+	// Limit the number of elements added to the filter since it was
+	// loaded.
+	if sp.filterAdds >= maxFilterAdds {
+		peerLog.Debugf("%s added more than %d elements to its bloom "+
+			"filter -- disconnecting", sp, maxFilterAdds)
+		sp.Disconnect()
+		return
+	}
+	sp.filterAdds++
+
 	sp.filter.Add(msg.Data)
 }
 
@@ -1244,13 +1658,14 @@ func (sp *serverPeer) OnFilterClear(_ *peer.Peer, msg *wire.MsgFilterClear) {
 	}
 
 	sp.filter.Unload()
+	sp.filterAdds = 0
 }
 
 // OnFilterLoad is invoked when a peer receives a filterload bitcoin
 // message and it used to load a bloom filter that should be used for
 // delivering merkle blocks and associated transactions that match the filter.
 // The peer will be disconnected if the server is not configured to allow bloom
-// filters.
+// filters or the filter exceeds the size constraints of BIP0037.
 func (sp *serverPeer) OnFilterLoad(_ *peer.Peer, msg *wire.MsgFilterLoad) {
 	// Disconnect and/or ban depending on the node bloom services flag and
 	// negotiated protocol version.
@@ -1258,9 +1673,29 @@ func (sp *serverPeer) OnFilterLoad(_ *peer.Peer, msg *wire.MsgFilterLoad) {
 		return
 	}
 
-	sp.setDisableRelayTx(false)
+	// Filters which are too large are already rejected when the message
+	// is decoded, but the limits are enforced here as well since the cost
+	// of serving a filter depends on them.
+	if len(msg.Filter) > wire.MaxFilterLoadFilterSize ||
+		msg.HashFuncs > wire.MaxFilterLoadHashFuncs {
+
+		sp.addBanScore(100, 0, "too-large bloom filter")
+		sp.Disconnect()
+		return
+	}
+
+	// A decaying ban score increase proportional to the size of the filter
+	// is applied to prevent flooding.
+	score := 1 + maxFilterLoadBanScore*uint32(len(msg.Filter))/
+		wire.MaxFilterLoadFilterSize
+	if sp.addBanScore(0, score, "filterload") {
+		return
+	}
+
+	sp.setDisableRelayTx(sp.blockRelayOnly)
 
 	sp.filter.Reload(msg)
+	sp.filterAdds = 0
 }
 
 // OnGetAddr is invoked when a peer receives a getaddr bitcoin message
@@ -1310,6 +1745,13 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Addresses are not exchanged with block relay only peers.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring addr message from block relay only "+
+			"peer %v", sp)
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
 		return
@@ -1324,12 +1766,20 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	}
 
 	addrs := make([]*wire.NetAddressV2, 0, len(msg.AddrList))
+	var numRateLimited int
 	for _, na := range msg.AddrList {
 		// Don't add more address if we're disconnecting.
 		if !sp.Connected() {
 			return
 		}
 
+		// Ignore the addresses the peer relayed in excess of its
+		// tokens.
+		if !sp.takeAddrToken() {
+			numRateLimited++
+			continue
+		}
+
 		// Set the timestamp to 5 days ago if it's more than 24 hours
 		// in the future so this address is one of the first to be
 		// removed when space is needed.
@@ -1347,6 +1797,13 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		addrs = append(addrs, currentNa)
 		sp.addKnownAddresses([]*wire.NetAddressV2{currentNa})
 	}
+	atomic.AddUint64(&sp.addrProcessed, uint64(len(addrs)))
+	atomic.AddUint64(&sp.addrRateLimited, uint64(numRateLimited))
+	if numRateLimited > 0 {
+		peerLog.Debugf("Ignored %d of %d addresses from %v exceeding "+
+			"the address rate limit", numRateLimited,
+			len(msg.AddrList), sp)
+	}
 
 	// Add addresses to server address manager.  The address manager handles
 	// the details of things such as preventing duplicate addresses, max
@@ -1364,6 +1821,13 @@ func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
 		return
 	}
 
+	// Addresses are not exchanged with block relay only peers.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring addrv2 message from block relay only "+
+			"peer %v", sp)
+		return
+	}
+
 	// An empty AddrV2 message is invalid.
 	if len(msg.AddrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any "+
@@ -1372,6 +1836,8 @@ func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
 		return
 	}
 
+	addrs := make([]*wire.NetAddressV2, 0, len(msg.AddrList))
+	var numRateLimited int
 	for _, na := range msg.AddrList {
 		// Don't add more to the set of known addresses if we're
 		// disconnecting.
@@ -1379,6 +1845,13 @@ func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
 			return
 		}
 
+		// Ignore the addresses the peer relayed in excess of its
+		// tokens.
+		if !sp.takeAddrToken() {
+			numRateLimited++
+			continue
+		}
+
 		// Set the timestamp to 5 days ago if the timestamp received is
 		// more than 10 minutes in the future so this address is one of
 		// the first to be removed.
@@ -1388,23 +1861,106 @@ func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
 		}
 
 		// Add to the set of known addresses.
+		addrs = append(addrs, na)
 		sp.addKnownAddresses([]*wire.NetAddressV2{na})
 	}
+	atomic.AddUint64(&sp.addrProcessed, uint64(len(addrs)))
+	atomic.AddUint64(&sp.addrRateLimited, uint64(numRateLimited))
+	if numRateLimited > 0 {
+		peerLog.Debugf("Ignored %d of %d addresses from %v exceeding "+
+			"the address rate limit", numRateLimited,
+			len(msg.AddrList), sp)
+	}
 
 	// Add the addresses to the addrmanager.
-	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
+	sp.server.addrManager.AddAddresses(addrs, sp.NA())
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
 // the bytes received by the server.
 func (sp *serverPeer) OnRead(_ *peer.Peer, bytesRead int, msg wire.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	if err == nil {
+		sp.captureMessage(msg, false)
+	}
+
+	// Limit the rate at which messages are read from peers which aren't
+	// whitelisted by delaying reading the next message until the bytes of
+	// this one are within the limits of the peer and the server.
+	if sp.isWhitelisted || bytesRead == 0 {
+		return
+	}
+	n := float64(bytesRead)
+	if sp.recvLimiter.Wait(n, sp.quit) {
+		sp.server.recvLimiter.Wait(n, sp.quit)
+	}
+}
+
+// limitServing delays sending requested data of the passed size to the peer
+// until it is within the serving rate limits of the peer and the server.
+// Whitelisted peers are not limited.
+func (sp *serverPeer) limitServing(size int) {
+	if sp.isWhitelisted {
+		return
+	}
+	n := float64(size)
+	if sp.servingLimiter.Wait(n, sp.quit) {
+		sp.server.servingLimiter.Wait(n, sp.quit)
+	}
+}
+
+// takeAddrToken returns whether an address relayed by the peer may be
+// processed, which takes a token from the address limiters of the peer and the
+// server.  Whitelisted peers are not limited.
+func (sp *serverPeer) takeAddrToken() bool {
+	if sp.isWhitelisted {
+		return true
+	}
+	return sp.addrLimiter.Take(1) && sp.server.addrLimiter.Take(1)
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
 // the bytes sent by the server.
 func (sp *serverPeer) OnWrite(_ *peer.Peer, bytesWritten int, msg wire.Message, err error) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	if err == nil {
+		sp.captureMessage(msg, true)
+	}
+}
+
+// captureMessage records the passed message sent to or received from the peer
+// when message capture is enabled.
+func (sp *serverPeer) captureMessage(msg wire.Message, sent bool) {
+	if sp.msgCapture == nil || msg == nil {
+		return
+	}
+
+	enc := wire.BaseEncoding
+	if sp.IsWitnessEnabled() {
+		enc = wire.WitnessEncoding
+	}
+	err := sp.msgCapture.Write(msg, sp.ProtocolVersion(), enc, sent)
+	if err != nil && err != msgcapture.ErrClosed {
+		peerLog.Warnf("Unable to capture %s message of %v: %v",
+			msg.Command(), sp, err)
+	}
+}
+
+// startMessageCapture starts recording the messages of the peer connected to
+// the passed address when message capture is enabled.
+func (sp *serverPeer) startMessageCapture(addr string) {
+	if !cfg.CaptureMessages {
+		return
+	}
+
+	dir := filepath.Join(cfg.DataDir, messageCaptureDirName)
+	w, err := msgcapture.NewWriter(dir, addr, time.Now())
+	if err != nil {
+		peerLog.Warnf("Unable to capture the messages of %s: %v", addr,
+			err)
+		return
+	}
+	sp.msgCapture = w
 }
 
 // OnNotFound is invoked when a peer sends a notfound message.
@@ -1467,28 +2023,6 @@ func randomUint16Number(max uint16) uint16 {
 	}
 }
 
-// AddRebroadcastInventory adds 'iv' to the list of inventories to be
-// rebroadcasted at random intervals until they show up in a block.
-func (s *server) AddRebroadcastInventory(iv *wire.InvVect, data interface{}) {
-	// Ignore if shutting down.
-	if atomic.LoadInt32(&s.shutdown) != 0 {
-		return
-	}
-
-	s.modifyRebroadcastInv <- broadcastInventoryAdd{invVect: iv, data: data}
-}
-
-// RemoveRebroadcastInventory removes 'iv' from the list of items to be
-// rebroadcasted if present.
-func (s *server) RemoveRebroadcastInventory(iv *wire.InvVect) {
-	// Ignore if shutting down.
-	if atomic.LoadInt32(&s.shutdown) != 0 {
-		return
-	}
-
-	s.modifyRebroadcastInv <- broadcastInventoryDel(iv)
-}
-
 // relayTransactions generates and relays inventory vectors for all of the
 // passed transactions to all connected peers.
 func (s *server) relayTransactions(txns []*mempool.TxDesc) {
@@ -1512,18 +2046,33 @@ func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
 	if s.rpcServer != nil {
 		s.rpcServer.NotifyNewTransactions(txns)
 	}
+
+	// Notify the gRPC transaction streams.
+	if s.grpcServer != nil {
+		s.grpcServer.NotifyNewTransactions(txns)
+	}
 }
 
-// Transaction has one confirmation on the main chain. Now we can mark it as no
-// longer needing rebroadcasting.
+// Transaction has one confirmation on the main chain. Now we can mark it and
+// the transactions conflicting with it as no longer needing rebroadcasting.
 func (s *server) TransactionConfirmed(tx *btcutil.Tx) {
-	// Rebroadcasting is only necessary when the RPC server is active.
-	if s.rpcServer == nil {
-		return
-	}
+	s.rebroadcaster.TransactionConfirmed(tx)
+}
 
-	iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
-	s.RemoveRebroadcastInventory(iv)
+// handleZMQNotification publishes the blocks connected to and disconnected from
+// the main chain over ZeroMQ.
+func (s *server) handleZMQNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		if block, ok := notification.Data.(*btcutil.Block); ok {
+			s.zmqNotifier.BlockConnected(block)
+		}
+
+	case blockchain.NTBlockDisconnected:
+		if block, ok := notification.Data.(*btcutil.Block); ok {
+			s.zmqNotifier.BlockDisconnected(block)
+		}
+	}
 }
 
 // pushTxMsg sends a tx message for the provided transaction hash to the
@@ -1550,23 +2099,88 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 		<-waitChan
 	}
 
+	sp.limitServing(tx.MsgTx().SerializeSize())
 	sp.QueueMessageWithEncoding(tx.MsgTx(), doneChan, encoding)
+	s.rebroadcaster.TransactionRequested(hash)
 
 	return nil
 }
 
-// pushBlockMsg sends a block message for the provided block hash to the
-// connected peer.  An error is returned if the block hash is not known.
-func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
-	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
+// fetchBlockBytes returns the serialized block with the passed hash from the
+// database.  When the block is a main chain block that was pruned and
+// --reconstructpruned is set, the block is recovered from the block files that
+// are still held in the prune trash instead of failing.  The trash is only
+// consulted for blocks the passed chain knows of, and the block is looked up in
+// the index of the trash, so requests for unknown blocks don't cause any disk
+// reads.  Recovered blocks are only returned after verifying they hash to the
+// requested block and commit to their transactions.
+func fetchBlockBytes(db database.DB, chain *blockchain.BlockChain,
+	hash *chainhash.Hash) ([]byte, error) {
 
-	// Fetch the raw block bytes from the database.
 	var blockBytes []byte
-	err := sp.server.db.View(func(dbTx database.Tx) error {
+	err := db.View(func(dbTx database.Tx) error {
 		var err error
 		blockBytes, err = dbTx.FetchBlock(hash)
 		return err
 	})
+	if err == nil || !cfg.ReconstructPruned {
+		return blockBytes, err
+	}
+	if dbErr, ok := err.(database.Error); !ok ||
+		dbErr.ErrorCode != database.ErrBlockNotFound {
+
+		return nil, err
+	}
+	if !chain.MainChainHasBlock(hash) {
+		return nil, err
+	}
+
+	trashedBytes, trashErr := ffldb.FetchTrashedBlock(db, hash)
+	if trashErr != nil {
+		return nil, err
+	}
+	block, trashErr := btcutil.NewBlockFromBytes(trashedBytes)
+	if trashErr != nil {
+		srvrLog.Warnf("Unable to deserialize pruned block %v recovered "+
+			"from the trash: %v", hash, trashErr)
+		return nil, err
+	}
+	header := &block.MsgBlock().Header
+	merkleRoot := blockchain.CalcMerkleRoot(block.Transactions(), false)
+	if *block.Hash() != *hash || header.MerkleRoot != merkleRoot {
+		srvrLog.Warnf("Pruned block %v recovered from the trash does "+
+			"not match its header", hash)
+		return nil, err
+	}
+
+	srvrLog.Debugf("Recovered pruned block %v from the trash", hash)
+	return trashedBytes, nil
+}
+
+// pushBlockMsg sends a block message for the provided block hash to the
+// connected peer.  An error is returned if the block hash is not known.
+func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
+	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
+
+	// Historical blocks are no longer served to peers which aren't
+	// whitelisted once the part of the upload target available for them
+	// was reached, which leaves the rest for serving recent blocks.
+	if !sp.isWhitelisted && s.uploadTarget.Reached(true) &&
+		s.isHistoricalBlock(hash) {
+
+		peerLog.Debugf("Historical block serving limit reached -- "+
+			"disconnecting peer %v which requested block %v", sp, hash)
+		sp.Disconnect()
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errUploadTargetReached
+	}
+
+	// Fetch the raw block bytes from the database.
+	blockBytes, err := fetchBlockBytes(sp.server.db, sp.server.chain,
+		hash)
 	if err != nil {
 		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
 			hash, err)
@@ -1595,6 +2209,8 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 		<-waitChan
 	}
 
+	sp.limitServing(len(blockBytes))
+
 	// We only send the channel for this message if we aren't sending
 	// an inv straight after.
 	var dc chan<- struct{}
@@ -1621,6 +2237,21 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	return nil
 }
 
+// isHistoricalBlock returns whether the block with the passed hash is older
+// than the best block by more than historicalBlockAge.
+func (s *server) isHistoricalBlock(hash *chainhash.Hash) bool {
+	header, err := s.chain.HeaderByHash(hash)
+	if err != nil {
+		return false
+	}
+	best := s.chain.BestSnapshot()
+	bestHeader, err := s.chain.HeaderByHash(&best.Hash)
+	if err != nil {
+		return false
+	}
+	return bestHeader.Timestamp.Sub(header.Timestamp) > historicalBlockAge
+}
+
 // pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
 // the connected peer.  Since a merkle block requires the peer to have a filter
 // loaded, this call will simply be ignored if there is no filter loaded.  An
@@ -1636,6 +2267,20 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 		return nil
 	}
 
+	// Filtered blocks are expensive to serve regardless of their age, so
+	// they are treated like historical blocks by the upload target.
+	if !sp.isWhitelisted && s.uploadTarget.Reached(true) {
+		peerLog.Debugf("Historical block serving limit reached -- "+
+			"disconnecting peer %v which requested filtered block "+
+			"%v", sp, hash)
+		sp.Disconnect()
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errUploadTargetReached
+	}
+
 	// Fetch the raw block bytes from the database.
 	blk, err := sp.server.chain.BlockByHash(hash)
 	if err != nil {
@@ -1657,6 +2302,15 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 		<-waitChan
 	}
 
+	blkTransactions := blk.MsgBlock().Transactions
+	size := wire.MaxBlockHeaderPayload + len(merkle.Hashes)*chainhash.HashSize
+	for _, txIndex := range matchedTxIndices {
+		if txIndex < uint32(len(blkTransactions)) {
+			size += blkTransactions[txIndex].SerializeSize()
+		}
+	}
+	sp.limitServing(size)
+
 	// Send the merkleblock.  Only send the done channel with this message
 	// if no transactions will be sent afterwards.
 	var dc chan<- struct{}
@@ -1666,7 +2320,6 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 	sp.QueueMessage(merkle, dc)
 
 	// Finally, send any matched transactions.
-	blkTransactions := blk.MsgBlock().Transactions
 	for i, txIndex := range matchedTxIndices {
 		// Only send the done channel on the final transaction.
 		var dc chan<- struct{}
@@ -1737,36 +2390,44 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	ip := net.ParseIP(host)
+	if ip != nil && !sp.isWhitelisted && s.banManager.IsBanned(ip) {
+		srvrLog.Debugf("Peer %s is banned - disconnecting", host)
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
 
 	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	maxPeers := liveCfg().MaxPeers
+	if state.Count() >= maxPeers {
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
-			cfg.MaxPeers, sp)
+			maxPeers, sp)
 		sp.Disconnect()
 		// TODO: how to handle permanent peers here?
 		// they should be rescheduled.
 		return false
 	}
 
+	// Inbound peers which misbehaved recently are only accepted while
+	// there is room to spare so they can't take the last slot from an
+	// honest peer.
+	if sp.Inbound() && ip != nil && !sp.isWhitelisted &&
+		state.Count()+1 >= maxPeers && s.banManager.IsDiscouraged(ip) {
+
+		srvrLog.Debugf("Peer %s is discouraged and there is no room to "+
+			"spare - disconnecting", host)
+		sp.Disconnect()
+		return false
+	}
+
 	// Add the new peer and start it.
 	srvrLog.Debugf("New peer %s", sp)
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.groupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1780,6 +2441,8 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		s.addrManager.Connected(sp.NA())
 	}
 
+	s.peerReputation.Connected(sp.Addr())
+
 	// Signal the sync manager this peer is a new sync candidate.
 	s.syncManager.NewPeer(sp.Peer)
 
@@ -1789,25 +2452,10 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// specified peers and actively avoids advertising and connecting to
 	// discovered peers.
 	if !cfg.SimNet && !sp.Inbound() {
-		// Advertise the local address when the server accepts incoming
-		// connections and it believes itself to be close to the best
-		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
-			// Get address that best matches.
-			lna := s.addrManager.GetBestLocalAddress(sp.NA())
-			if addrmgr.IsRoutable(lna) {
-				// Filter addresses the peer already knows about.
-				addresses := []*wire.NetAddressV2{lna}
-				sp.pushAddrMsg(addresses)
-			}
-		}
-
-		// Request known addresses if the server address manager needs
-		// more and the peer has a protocol version new enough to
-		// include a timestamp with addresses.
-		hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
-			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
+		// Addresses are not exchanged with block relay only peers, which
+		// makes it harder for others to learn about these connections.
+		if !sp.blockRelayOnly {
+			s.exchangeAddresses(sp)
 		}
 
 		// Mark the address as a known good address.
@@ -1817,6 +2465,35 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	return true
 }
 
+// exchangeAddresses advertises the local address to the passed outbound peer
+// and requests known addresses from it.  It is invoked from the peerHandler
+// goroutine.
+func (s *server) exchangeAddresses(sp *serverPeer) {
+	// Advertise the local address when the server accepts incoming
+	// connections and it believes itself to be close to the best known
+	// tip.
+	if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		// Get address that best matches.
+		lna := s.addrManager.GetBestLocalAddress(sp.NA())
+		if addrmgr.IsRoutable(lna) {
+			// Filter addresses the peer already knows about.
+			addresses := []*wire.NetAddressV2{lna}
+			sp.pushAddrMsg(addresses)
+		}
+	}
+
+	// Request known addresses if the server address manager needs more and
+	// the peer has a protocol version new enough to include a timestamp
+	// with addresses.
+	hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
+	if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		sp.QueueMessage(wire.NewMsgGetAddr(), nil)
+
+		// Allow the peer to reply with a full addr message.
+		sp.addrLimiter.Add(addrTokenBurst)
+	}
+}
+
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
@@ -1833,17 +2510,35 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	// our connection manager about the disconnection. This can happen if we
 	// process a peer's `done` message before its `add`.
 	if !sp.Inbound() {
+		// Connect to peers that rejected the v2 transport protocol again
+		// with the v1 protocol.  Persistent peers are retried by the
+		// connection manager.  Peer authentication requires the v2
+		// protocol, so there is no point in retrying then.
+		v1Retry := sp.V2Rejected() && s.peerAuth == nil
+		if v1Retry {
+			srvrLog.Debugf("Peer %s rejected the v2 transport, "+
+				"reconnecting with the v1 transport", sp)
+			s.v1Addrs.Add(sp.connReq.Addr.String())
+		}
+
 		if sp.persistent {
 			s.connManager.Disconnect(sp.connReq.ID())
 		} else {
 			s.connManager.Remove(sp.connReq.ID())
-			go s.connManager.NewConnReq()
+			if v1Retry {
+				go s.connManager.Connect(&connmgr.ConnReq{
+					Addr:           sp.connReq.Addr,
+					BlockRelayOnly: sp.connReq.BlockRelayOnly,
+				})
+			} else {
+				go s.newConnReq(sp.connReq)
+			}
 		}
 	}
 
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.groupKey(sp.NA())]--
 		}
 		delete(list, sp.ID())
 		srvrLog.Debugf("Removed peer %s", sp)
@@ -1851,23 +2546,36 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 }
 
-// handleBanPeerMsg deals with banning peers.  It is invoked from the
-// peerHandler goroutine.
+// handleBanPeerMsg deals with misbehaving peers, which are discouraged rather
+// than banned so the honest peers sharing their address aren't banned along
+// with them.  It is invoked from the peerHandler goroutine.
 func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 	host, _, err := net.SplitHostPort(sp.Addr())
 	if err != nil {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		srvrLog.Debugf("Not discouraging peer %s without an IP address",
+			host)
+		return
+	}
+	banDuration := liveCfg().BanDuration
+	err = s.banManager.Discourage(ip, time.Now().Add(banDuration))
+	if err != nil {
+		srvrLog.Errorf("Unable to discourage peer %s: %v", host, err)
+		return
+	}
 	direction := directionString(sp.Inbound())
-	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	srvrLog.Infof("Discouraged peer %s (%s) for %v", host, direction,
+		banDuration)
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	var numAnnounced int
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
@@ -1890,16 +2598,11 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 				return
 			}
 			sp.QueueMessage(msgHeaders, nil)
+			numAnnounced++
 			return
 		}
 
 		if msg.invVect.Type == wire.InvTypeTx {
-			// Don't relay the transaction to the peer when it has
-			// transaction relaying disabled.
-			if sp.relayTxDisabled() {
-				return
-			}
-
 			txD, ok := msg.data.(*mempool.TxDesc)
 			if !ok {
 				peerLog.Warnf("Underlying data for tx inv "+
@@ -1907,18 +2610,21 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 					msg.data)
 				return
 			}
-
-			// Don't relay the transaction if the transaction fee-per-kb
-			// is less than the peer's feefilter.
-			feeFilter := atomic.LoadInt64(&sp.feeFilter)
-			if feeFilter > 0 && txD.FeePerKB < feeFilter {
+			if !sp.acceptsTx(txD) {
 				return
 			}
 
-			// Don't relay the transaction if there is a bloom
-			// filter loaded and the transaction doesn't match it.
-			if sp.filter.IsLoaded() {
-				if !sp.filter.MatchTxAndUpdate(txD.Tx) {
+			// Transactions are flooded to outbound peers and
+			// reconciled with inbound peers that negotiated
+			// transaction reconciliation, which keeps the latency
+			// low while saving most of the announcements.  They
+			// are flooded when the reconciliation set is full.
+			if sp.txRecon != nil && !sp.txRecon.Initiator() {
+				if sp.IsKnownInventory(msg.invVect) {
+					return
+				}
+				tx := txD.Tx
+				if sp.txRecon.AddTx(tx.Hash(), tx.WitnessHash()) {
 					return
 				}
 			}
@@ -1928,7 +2634,12 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 		// It will be ignored if the peer is already known to
 		// have the inventory.
 		sp.QueueInventory(msg.invVect)
+		numAnnounced++
 	})
+
+	if msg.invVect.Type == wire.InvTypeBlock {
+		s.blockTracer.RecordAnnounced(&msg.invVect.Hash, numAnnounced)
+	}
 }
 
 // handleBroadcastMsg deals with broadcasting messages to peers.  It is invoked
@@ -2008,7 +2719,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 	case connectNodeMsg:
 		// TODO: duplicate oneshots?
 		// Limit max number of total peers.
-		if state.Count() >= cfg.MaxPeers {
+		if state.Count() >= liveCfg().MaxPeers {
 			msg.reply <- errors.New("max peers reached")
 			return
 		}
@@ -2039,7 +2750,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.groupKey(sp.NA())]--
 		})
 
 		if found {
@@ -2075,7 +2786,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.groupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -2083,7 +2794,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.groupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -2145,6 +2856,11 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnRead:         sp.OnRead,
 			OnWrite:        sp.OnWrite,
 			OnNotFound:     sp.OnNotFound,
+			OnAttest:       sp.OnAttest,
+			OnReqRecon:     sp.OnReqRecon,
+			OnSketch:       sp.OnSketch,
+			OnReqSketchExt: sp.OnReqSketchExt,
+			OnReconcilDiff: sp.OnReconcilDiff,
 
 			// Note: The reference client currently bans peers that send alerts
 			// not signed with its key.  We could verify against their key, but
@@ -2160,11 +2876,24 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments:   cfg.UserAgentComments,
 		ChainParams:         sp.server.chainParams,
 		Services:            sp.server.services,
-		DisableRelayTx:      cfg.BlocksOnly,
+		DisableRelayTx:      sp.blocksOnly(),
 		ProtocolVersion:     peer.MaxProtocolVersion,
 		TrickleInterval:     cfg.TrickleInterval,
 		DisableStallHandler: cfg.DisableStallHandler,
+		Authenticator:       sp.server.peerAuthenticator(),
+		TxReconciliation:    cfg.TxReconciliation,
+		V2Transport:         cfg.V2Transport,
+		Compression:         cfg.WireCompression,
+	}
+}
+
+// peerAuthenticator returns the authenticator peers are configured with, which
+// is nil unless peer authentication is enabled.
+func (s *server) peerAuthenticator() peer.Authenticator {
+	if s.peerAuth == nil {
+		return nil
 	}
+	return s.peerAuth
 }
 
 // inboundPeerConnected is invoked by the connection manager when a new inbound
@@ -2172,9 +2901,22 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
+	// Refuse connections from banned peers right away rather than after
+	// the handshake.
+	whitelisted := isWhitelisted(conn.RemoteAddr())
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && !whitelisted &&
+		s.banManager.IsBanned(tcpAddr.IP) {
+
+		srvrLog.Debugf("Refusing connection from banned peer %s",
+			conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
 	sp := newServerPeer(s, false)
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.isWhitelisted = whitelisted
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
+	sp.startMessageCapture(conn.RemoteAddr().String())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 }
@@ -2186,24 +2928,124 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
-	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
+	sp.blockRelayOnly = c.BlockRelayOnly && !c.Permanent
+	peerCfg := newPeerConfig(sp)
+	if s.peerAuth == nil && s.v1Addrs.Contains(c.Addr.String()) {
+		peerCfg.V2Transport = false
+	}
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
 		if c.Permanent {
 			s.connManager.Disconnect(c.ID())
 		} else {
 			s.connManager.Remove(c.ID())
-			go s.connManager.NewConnReq()
+			go s.newConnReq(c)
 		}
 		return
 	}
 	sp.Peer = p
 	sp.connReq = c
 	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.startMessageCapture(c.Addr.String())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 }
 
+// seederSeeds returns the addresses the DNS seeder crawls in addition to those
+// it learns from the crawled peers, which are the peers specified with
+// --addpeer and --connect and addresses known to the address manager.
+func (s *server) seederSeeds() []string {
+	addrs := make([]string, 0, len(cfg.AddPeers)+len(cfg.ConnectPeers))
+	addrs = append(addrs, cfg.AddPeers...)
+	addrs = append(addrs, cfg.ConnectPeers...)
+	for _, na := range s.addrManager.AddressCache() {
+		addrs = append(addrs, addrmgr.NetAddressKey(na))
+	}
+	return addrs
+}
+
+// newConnReq requests a new outbound connection to replace the passed
+// non-persistent one, which is of the same type.
+func (s *server) newConnReq(c *connmgr.ConnReq) {
+	if c.BlockRelayOnly {
+		s.connManager.NewBlockRelayOnlyConnReq()
+		return
+	}
+	s.connManager.NewConnReq()
+}
+
+// onionServiceTarget returns the local address the onion service forwards the
+// connections to for the passed listener address, which is the loopback address
+// of the same family when the listener is bound to all interfaces.
+func onionServiceTarget(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.String()
+	}
+	ip := tcpAddr.IP
+	if ip.IsUnspecified() {
+		ip = net.IPv6loopback
+		if ip4 := tcpAddr.IP.To4(); ip4 != nil {
+			ip = net.IPv4(127, 0, 0, 1)
+		}
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(tcpAddr.Port))
+}
+
+// dial connects to the passed address of an outbound peer.  Connections to
+// onion addresses, unless an onion proxy is configured or onion addresses are
+// disabled, and to the networks set with --torproxynet are made via the SOCKS
+// proxy of Tor when the Tor control port is configured.
+func (s *server) dial(addr net.Addr) (net.Conn, error) {
+	if s.torController == nil {
+		return btcdDial(addr)
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	var viaTor bool
+	if strings.HasSuffix(host, ".onion") {
+		viaTor = !cfg.NoOnion && cfg.OnionProxy == ""
+	} else {
+		network := "ipv6"
+		if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+			network = "ipv4"
+		}
+		for _, torNet := range cfg.TorProxyNets {
+			viaTor = viaTor || torNet == network
+		}
+	}
+	if !viaTor {
+		return btcdDial(addr)
+	}
+
+	// Never fall back to a direct connection for the networks which are
+	// only meant to be reached via Tor.
+	socksAddr := s.torController.SOCKSAddr()
+	if socksAddr == "" {
+		return nil, errors.New("the SOCKS proxy of Tor is not known yet")
+	}
+	proxy := &socks.Proxy{
+		Addr:         socksAddr,
+		TorIsolation: cfg.TorIsolation,
+	}
+	return proxy.DialTimeout(addr.Network(), addr.String(),
+		defaultConnectTimeout)
+}
+
+// groupKey returns the network group of the passed address, which is used to
+// diversify the outbound peers.  The addresses are grouped by the AS that
+// announces them when an AS map is loaded and by their IP range otherwise.
+func (s *server) groupKey(na *wire.NetAddressV2) string {
+	if s.asMap != nil {
+		return s.asMap.GroupKey(na)
+	}
+	return addrmgr.GroupKey(na)
+}
+
 // peerDoneHandler handles peer disconnects by notifying the server that it's
 // done along with other performing other desirable cleanup.
 func (s *server) peerDoneHandler(sp *serverPeer) {
@@ -2222,6 +3064,9 @@ func (s *server) peerDoneHandler(sp *serverPeer) {
 					"orphans"), sp, sp.ID())
 		}
 	}
+	if sp.msgCapture != nil {
+		sp.msgCapture.Close()
+	}
 	close(sp.quit)
 }
 
@@ -2243,7 +3088,6 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
@@ -2293,6 +3137,10 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the addresses of the block relay only peers so
+			// they are connected to again after a restart.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2325,6 +3173,28 @@ cleanup:
 	srvrLog.Tracef("Peer handler done")
 }
 
+// saveAnchors saves the addresses of the block relay only peers of the passed
+// state as the anchors to connect to after a restart.  It is invoked from the
+// peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	var anchors []string
+	for _, sp := range state.outboundPeers {
+		if sp.blockRelayOnly && len(anchors) < maxAnchors {
+			anchors = append(anchors, sp.connReq.Addr.String())
+		}
+	}
+	if len(anchors) == 0 {
+		return
+	}
+
+	path := filepath.Join(cfg.DataDir, anchorsFilename)
+	if err := saveAnchors(path, anchors); err != nil {
+		srvrLog.Warnf("Unable to save anchors: %v", err)
+		return
+	}
+	srvrLog.Debugf("Saved %d anchors", len(anchors))
+}
+
 // AddPeer adds a new peer that has already been connected to the server.
 func (s *server) AddPeer(sp *serverPeer) {
 	s.newPeers <- sp
@@ -2371,6 +3241,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.AddBytesSent(bytesSent)
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -2398,41 +3269,40 @@ func (s *server) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight i
 	}
 }
 
-// rebroadcastHandler keeps track of user submitted inventories that we have
-// sent out but have not yet made it into a block. We periodically rebroadcast
-// them in case our peers restarted or otherwise lost track of them.
+// rebroadcastHandler periodically announces the transactions submitted through
+// the RPC server which have not made it into a block yet to the connected peers
+// which have not seen them, in case they never reached the network or our
+// peers restarted or otherwise lost track of them.  It must be run as a
+// goroutine.
 func (s *server) rebroadcastHandler() {
-	// Wait 5 min before first tx rebroadcast.
-	timer := time.NewTimer(5 * time.Minute)
-	pendingInvs := make(map[wire.InvVect]interface{})
+	// Rebroadcast at random times around the configured interval so the
+	// announcements can't be used to tell that the transactions originated
+	// from this node.
+	nextRebroadcast := func() time.Duration {
+		jitter := cfg.RebroadcastInterval *
+			time.Duration(randomUint16Number(1000)) / 1000
+		return cfg.RebroadcastInterval/2 + jitter
+	}
+	timer := time.NewTimer(nextRebroadcast())
 
 out:
 	for {
 		select {
-		case riv := <-s.modifyRebroadcastInv:
-			switch msg := riv.(type) {
-			// Incoming InvVects are added to our map of RPC txs.
-			case broadcastInventoryAdd:
-				pendingInvs[*msg.invVect] = msg.data
-
-			// When an InvVect has been added to a block, we can
-			// now remove it, if it was present.
-			case broadcastInventoryDel:
-				delete(pendingInvs, *msg)
-			}
-
 		case <-timer.C:
-			// Any inventory we have has not made it into a block
-			// yet. We periodically resubmit them until they have.
-			for iv, data := range pendingInvs {
-				ivCopy := iv
-				s.RelayInventory(&ivCopy, data)
+			replyChan := make(chan []*serverPeer)
+			select {
+			case s.query <- getPeersMsg{reply: replyChan}:
+			case <-s.quit:
+				break out
 			}
+			serverPeers := <-replyChan
+			peers := make([]rebroadcast.Peer, 0, len(serverPeers))
+			for _, sp := range serverPeers {
+				peers = append(peers, sp)
+			}
+			s.rebroadcaster.Rebroadcast(peers)
 
-			// Process at a random time up to 30mins (in seconds)
-			// in the future.
-			timer.Reset(time.Second *
-				time.Duration(randomUint16Number(1800)))
+			timer.Reset(nextRebroadcast())
 
 		case <-s.quit:
 			break out
@@ -2440,17 +3310,143 @@ out:
 	}
 
 	timer.Stop()
+	s.wg.Done()
+}
 
-	// Drain channels before exiting so nothing is left waiting around
-	// to send.
-cleanup:
+// snapshotValidationHandler validates the history of the utxo snapshot the
+// chain was loaded from using the blocks of the database set with
+// --utxosnapshotvalidatedb.  The progress is saved, so an interrupted
+// validation resumes when the node is started again.  A shutdown is requested
+// when the snapshot is found to be invalid since the chain state can't be
+// trusted.  It must be run as a goroutine.
+func (s *server) snapshotValidationHandler() {
+	defer s.wg.Done()
+
+	status, err := s.chain.UtxoSnapshotStatus()
+	if err != nil {
+		srvrLog.Errorf("Unable to fetch the utxo snapshot status: %v", err)
+		return
+	}
+	if status == nil || status.Validated {
+		return
+	}
+
+	srvrLog.Infof("Loading the block database '%s' to validate the utxo "+
+		"snapshot history", cfg.SnapshotValidateDB)
+	srcDB, err := database.Open(cfg.DbType, cfg.SnapshotValidateDB,
+		s.chainParams.Net)
+	if err != nil {
+		srvrLog.Errorf("Unable to open the block database to validate "+
+			"the utxo snapshot: %v", err)
+		return
+	}
+	defer srcDB.Close()
+
+	fetchBlock := func(hash *chainhash.Hash) (*btcutil.Block, error) {
+		var block *btcutil.Block
+		err := srcDB.View(func(dbTx database.Tx) error {
+			blockBytes, err := dbTx.FetchBlock(hash)
+			if err != nil {
+				return err
+			}
+			block, err = btcutil.NewBlockFromBytes(blockBytes)
+			return err
+		})
+		return block, err
+	}
+	err = s.chain.ValidateUtxoSnapshot(fetchBlock, s.quit)
+	if err == nil {
+		return
+	}
+	srvrLog.Errorf("Unable to validate the utxo snapshot history: %v", err)
+
+	status, statusErr := s.chain.UtxoSnapshotStatus()
+	if statusErr != nil || status == nil || !status.Invalid {
+		return
+	}
+	srvrLog.Criticalf("The utxo snapshot at block %v is invalid -- the "+
+		"block database must be recreated", status.BaseHash)
+	select {
+	case shutdownRequestChannel <- struct{}{}:
+	case <-s.quit:
+	}
+}
+
+// peerAuthHandler periodically reloads the revocation list of the peer
+// authenticator and disconnects the connected peers whose keys were revoked.
+// It must be run as a goroutine.
+func (s *server) peerAuthHandler() {
+	ticker := time.NewTicker(revocationReloadInterval)
+	defer ticker.Stop()
+
+out:
 	for {
 		select {
-		case <-s.modifyRebroadcastInv:
-		default:
-			break cleanup
+		case <-ticker.C:
+			changed, err := s.peerAuth.ReloadRevocations()
+			if err != nil {
+				srvrLog.Errorf("Unable to reload the peer "+
+					"revocation list: %v", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			srvrLog.Infof("Reloaded the peer revocation list")
+
+			replyChan := make(chan []*serverPeer)
+			select {
+			case s.query <- getPeersMsg{reply: replyChan}:
+			case <-s.quit:
+				break out
+			}
+			for _, sp := range <-replyChan {
+				if s.peerAuth.IsRevoked(sp.AuthPubKey()) {
+					srvrLog.Infof("Disconnecting peer %s with "+
+						"revoked key %x", sp, sp.AuthPubKey())
+					sp.Disconnect()
+				}
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
+
+// txReconHandler periodically starts transaction reconciliation rounds with
+// the outbound peers that negotiated transaction reconciliation.  It must be
+// run as a goroutine.
+func (s *server) txReconHandler() {
+	ticker := time.NewTicker(txReconRequestInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			replyChan := make(chan []*serverPeer)
+			select {
+			case s.query <- getPeersMsg{reply: replyChan}:
+			case <-s.quit:
+				break out
+			}
+			for _, sp := range <-replyChan {
+				if sp.txRecon == nil {
+					continue
+				}
+				if msg := sp.txRecon.StartRound(); msg != nil {
+					sp.QueueMessage(msg, nil)
+				}
+			}
+
+		case <-s.quit:
+			break out
 		}
 	}
+
 	s.wg.Done()
 }
 
@@ -2471,25 +3467,100 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Dump the state of the server when requested with a signal.  The
+	// handler isn't waited for on shutdown since it must keep running to
+	// diagnose a hung shutdown.
+	if len(stateDumpSignals) > 0 {
+		go s.stateDumpHandler()
+	}
+
+	// Reload the configuration when requested with a signal.
+	if len(reloadSignals) > 0 {
+		s.wg.Add(1)
+		go s.reloadHandler()
+	}
+
 	if s.nat != nil {
 		s.wg.Add(1)
-		go s.upnpUpdateThread()
+		go s.natUpdateThread()
+	}
+
+	// Connect to the Tor control port if configured.
+	if s.torController != nil {
+		s.torController.Start()
+	}
+
+	// Watch the peer revocation list if peer authentication is enabled.
+	if s.peerAuth != nil {
+		s.wg.Add(1)
+		go s.peerAuthHandler()
+	}
+
+	// Validate the history of the utxo snapshot the chain was loaded from
+	// in the background if requested.
+	if cfg.SnapshotValidateDB != "" {
+		s.wg.Add(1)
+		go s.snapshotValidationHandler()
+	}
+
+	// Schedule transaction reconciliation rounds if enabled.
+	if cfg.TxReconciliation {
+		s.wg.Add(1)
+		go s.txReconHandler()
 	}
 
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
 		// Start the rebroadcastHandler, which ensures user tx received by
-		// the RPC server are rebroadcast until being included in a block.
+		// the RPC server are announced to fresh peers until being included
+		// in a block.
 		go s.rebroadcastHandler()
 
 		s.rpcServer.Start()
 	}
 
+	// Start serving the gRPC interface if enabled.
+	if s.grpcServer != nil {
+		s.grpcServer.Start()
+	}
+
+	// Start serving the metrics if enabled.
+	if s.metricsServer != nil {
+		s.metricsServer.Start()
+	}
+
+	// Start serving block templates to Stratum V2 clients if enabled.
+	if s.sv2Server != nil {
+		s.sv2Server.Start()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start attesting to blocks if the finality module is enabled.
+	if s.finalityMgr != nil {
+		s.finalityMgr.Start()
+	}
+
+	// Start exporting block traces if enabled.
+	s.blockTracer.Start()
+
+	// Start persisting the statistics of the peers periodically.
+	s.peerReputation.Start()
+
+	// Start crawling the network and answering DNS queries if the node is
+	// a DNS seed.
+	if s.seeder != nil {
+		s.seeder.Start()
+	}
+
+	// Start backfilling the indexes which are far behind the best chain.
+	if s.indexManager != nil {
+		s.indexManager.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2503,14 +3574,70 @@ func (s *server) Stop() error {
 
 	srvrLog.Warnf("Server shutting down")
 
+	// Stop the finality manager if needed.
+	if s.finalityMgr != nil {
+		s.finalityMgr.Stop()
+	}
+
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Stop backfilling the indexes if needed.
+	if s.indexManager != nil {
+		s.indexManager.Stop()
+	}
+
+	// Export the remaining block traces if enabled.
+	s.blockTracer.Stop()
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
 	}
 
+	// Disconnect the ZeroMQ subscribers.
+	s.zmqNotifier.Stop()
+
+	// Close the connection to the Tor control port, which removes the
+	// onion service.
+	if s.torController != nil {
+		s.torController.Stop()
+	}
+
+	// Shutdown the gRPC server if it's enabled.
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+
+	// Shutdown the metrics server if it's enabled.
+	if s.metricsServer != nil {
+		s.metricsServer.Stop()
+	}
+
+	// Disconnect the Stratum V2 clients if the template provider is
+	// enabled.
+	if s.sv2Server != nil {
+		s.sv2Server.Stop()
+	}
+
+	// Save the statistics of the peers in the database.
+	if err := s.peerReputation.Stop(); err != nil {
+		srvrLog.Errorf("Unable to save peer statistics: %v", err)
+	}
+
+	// Save the outputs tracked by the watch-only wallet in the database.
+	if err := s.watchOnly.Stop(); err != nil {
+		srvrLog.Errorf("Unable to save watch-only outputs: %v", err)
+	}
+
+	// Stop the DNS seeder and save the statistics of the crawled nodes.
+	if s.seeder != nil {
+		if err := s.seeder.Stop(); err != nil {
+			srvrLog.Errorf("Unable to save seeder statistics: %v",
+				err)
+		}
+	}
+
 	// Save fee estimator state in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
@@ -2613,44 +3740,102 @@ func parseListeners(addrs []string) ([]net.Addr, error) {
 	return netAddrs, nil
 }
 
-func (s *server) upnpUpdateThread() {
-	// Go off immediately to prevent code duplication, thereafter we renew
-	// lease every 15 minutes.
-	timer := time.NewTimer(0 * time.Second)
-	lport, _ := strconv.ParseInt(activeNetParams.DefaultPort, 10, 16)
-	first := true
+// mapPort maps the passed port on the NAT and returns the mapping.
+func (s *server) mapPort(port uint16) (*portMapping, error) {
+	lease := natLeaseDuration
+	externalPort, err := s.nat.AddPortMapping("tcp", int(port), int(port),
+		"btcd listen port", int(lease/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	externalIP, err := s.nat.GetExternalAddress()
+	if err != nil {
+		return nil, err
+	}
+	return &portMapping{
+		protocol:     s.nat.Protocol(),
+		externalIP:   externalIP,
+		externalPort: uint16(externalPort),
+		internalPort: port,
+		expires:      time.Now().Add(lease),
+	}, nil
+}
+
+// PortMapping returns the current mapping of the listening port on the NAT or
+// nil when the port is not mapped.
+//
+// This function is safe for concurrent access.
+func (s *server) PortMapping() *portMapping {
+	s.natMtx.Lock()
+	defer s.natMtx.Unlock()
+	return s.natMapping
+}
+
+// natUpdateThread maps the listening port on the NAT, renews the mapping before
+// its lease expires and advertises the external address of the mapping to
+// peers, replacing it when the NAT is assigned a new external address.  The
+// mapping is removed on shutdown.  It must be run as a goroutine.
+func (s *server) natUpdateThread() {
+	defer s.wg.Done()
+
+	var advertised *wire.NetAddressV2
+	unadvertise := func() {
+		if advertised != nil {
+			s.addrManager.RemoveLocalAddress(advertised)
+			advertised = nil
+		}
+	}
+
+	protocol := s.nat.Protocol()
+	timer := time.NewTimer(0)
 out:
 	for {
 		select {
 		case <-timer.C:
-			// TODO: pick external port  more cleverly
-			// TODO: know which ports we are listening to on an external net.
-			// TODO: if specific listen port doesn't work then ask for wildcard
-			// listen port?
-			// XXX this assumes timeout is in seconds.
-			listenPort, err := s.nat.AddPortMapping("tcp", int(lport), int(lport),
-				"btcd listen port", 20*60)
+			mapping, err := s.mapPort(s.natPort)
 			if err != nil {
-				srvrLog.Warnf("can't add UPnP port mapping: %v", err)
-			}
-			if first && err == nil {
-				// TODO: look this up periodically to see if upnp domain changed
-				// and so did ip.
-				externalip, err := s.nat.GetExternalAddress()
-				if err != nil {
-					srvrLog.Warnf("UPnP can't get external address: %v", err)
-					continue out
+				srvrLog.Warnf("Unable to map port %d via %s: %v",
+					s.natPort, protocol, err)
+
+				// Stop advertising the address once the previous
+				// mapping expired.
+				s.natMtx.Lock()
+				if s.natMapping != nil &&
+					time.Now().After(s.natMapping.expires) {
+
+					s.natMapping = nil
+					unadvertise()
 				}
-				na := wire.NetAddressV2FromBytes(time.Now(), s.services,
-					externalip, uint16(listenPort))
-				err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
+				s.natMtx.Unlock()
+
+				timer.Reset(natRetryInterval)
+				continue
+			}
+
+			na := wire.NetAddressV2FromBytes(time.Now(), s.services,
+				mapping.externalIP, mapping.externalPort)
+			key := addrmgr.NetAddressKey(na)
+			if advertised == nil ||
+				key != addrmgr.NetAddressKey(advertised) {
+
+				unadvertise()
+				srvrLog.Infof("Mapped port %d to %s via %s",
+					s.natPort, key, protocol)
+				err := s.addrManager.AddLocalAddress(na,
+					addrmgr.UpnpPrio)
 				if err != nil {
-					// XXX DeletePortMapping?
+					srvrLog.Warnf("Not advertising mapped "+
+						"address %s: %v", key, err)
 				}
-				srvrLog.Warnf("Successfully bound via UPnP to %s", addrmgr.NetAddressKey(na))
-				first = false
+				advertised = na
 			}
-			timer.Reset(time.Minute * 15)
+
+			s.natMtx.Lock()
+			s.natMapping = mapping
+			s.natMtx.Unlock()
+
+			timer.Reset(natRenewInterval)
+
 		case <-s.quit:
 			break out
 		}
@@ -2658,13 +3843,21 @@ out:
 
 	timer.Stop()
 
-	if err := s.nat.DeletePortMapping("tcp", int(lport), int(lport)); err != nil {
-		srvrLog.Warnf("unable to remove UPnP port mapping: %v", err)
+	s.natMtx.Lock()
+	mapping := s.natMapping
+	s.natMapping = nil
+	s.natMtx.Unlock()
+	if mapping == nil {
+		return
+	}
+	err := s.nat.DeletePortMapping("tcp", int(mapping.externalPort),
+		int(mapping.internalPort))
+	if err != nil {
+		srvrLog.Warnf("Unable to remove %s port mapping: %v", protocol,
+			err)
 	} else {
-		srvrLog.Debugf("successfully disestablished UPnP port mapping")
+		srvrLog.Debugf("Removed %s port mapping", protocol)
 	}
-
-	s.wg.Done()
 }
 
 // setupRPCListeners returns a slice of listeners that are configured for use
@@ -2692,6 +3885,13 @@ func setupRPCListeners() ([]net.Listener, error) {
 			MinVersion:   tls.VersionTLS12,
 		}
 
+		// Offer HTTP/2 to clients supporting it, which allows them to
+		// make many concurrent requests over a single connection.
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		if !cfg.NoRPCHTTP2 {
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+
 		// Change the standard net.Listen function to the tls one.
 		listenFunc = func(net string, laddr string) (net.Listener, error) {
 			return tls.Listen(net, laddr, &tlsConfig)
@@ -2730,9 +3930,17 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
-	if cfg.Prune != 0 {
+	if cfg.Prune != 0 || cfg.FiltersOnly {
 		services &^= wire.SFNodeNetwork
 	}
+	// Both the v2 transport and wire compression are btcd-only extensions
+	// that are signaled with bits from the experimental range.
+	if cfg.V2Transport {
+		services |= wire.SFNodeP2PV2
+	}
+	if cfg.WireCompression {
+		services |= wire.SFNodeCompression
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
@@ -2756,27 +3964,90 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		srvrLog.Infof("User-agent whitelist %s", agentWhitelist)
 	}
 
+	var asMap *addrmgr.ASMap
+	if cfg.ASMap != "" {
+		f, err := os.Open(cfg.ASMap)
+		if err != nil {
+			return nil, err
+		}
+		asMap, err = addrmgr.LoadASMap(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load AS map %s: %v",
+				cfg.ASMap, err)
+		}
+		srvrLog.Infof("Using AS map %s to diversify outbound peers",
+			cfg.ASMap)
+	}
+
 	s := server{
-		chainParams:          chainParams,
-		addrManager:          amgr,
-		newPeers:             make(chan *serverPeer, cfg.MaxPeers),
-		donePeers:            make(chan *serverPeer, cfg.MaxPeers),
-		banPeers:             make(chan *serverPeer, cfg.MaxPeers),
-		query:                make(chan interface{}),
-		relayInv:             make(chan relayMsg, cfg.MaxPeers),
-		broadcast:            make(chan broadcastMsg, cfg.MaxPeers),
-		quit:                 make(chan struct{}),
-		modifyRebroadcastInv: make(chan interface{}),
-		peerHeightsUpdate:    make(chan updatePeerHeightsMsg),
-		nat:                  nat,
-		db:                   db,
-		timeSource:           blockchain.NewMedianTime(),
-		services:             services,
-		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
-		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
-		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
-		agentBlacklist:       agentBlacklist,
-		agentWhitelist:       agentWhitelist,
+		chainParams:       chainParams,
+		addrManager:       amgr,
+		newPeers:          make(chan *serverPeer, cfg.MaxPeers),
+		donePeers:         make(chan *serverPeer, cfg.MaxPeers),
+		banPeers:          make(chan *serverPeer, cfg.MaxPeers),
+		query:             make(chan interface{}),
+		relayInv:          make(chan relayMsg, cfg.MaxPeers),
+		broadcast:         make(chan broadcastMsg, cfg.MaxPeers),
+		quit:              make(chan struct{}),
+		peerHeightsUpdate: make(chan updatePeerHeightsMsg),
+		nat:               nat,
+		db:                db,
+		timeSource:        blockchain.NewMedianTime(),
+		services:          services,
+		diagnostics:       diagnostics.NewCollector(filepath.Join(cfg.DataDir, profilesDirName)),
+		sigCache:          txscript.NewSigCache(cfg.SigCacheMaxSize),
+		hashCache:         txscript.NewHashCache(cfg.SigCacheMaxSize),
+		cfCheckptCaches:   make(map[wire.FilterType][]cfHeaderKV),
+		agentBlacklist:    agentBlacklist,
+		agentWhitelist:    agentWhitelist,
+		peerAuth:          cfg.peerAuth,
+		v1Addrs:           lru.NewCache(1000),
+		asMap:             asMap,
+		uploadTarget:      connmgr.NewUploadTarget(cfg.MaxUploadTarget * 1024 * 1024),
+		recvLimiter:       newByteRateLimiter(cfg.MaxRecvRate),
+		servingLimiter:    newByteRateLimiter(cfg.MaxServingRate),
+		addrLimiter: connmgr.NewTokenBucket(globalAddrTokenRate,
+			globalAddrTokenBurst, globalAddrTokenBurst),
+	}
+
+	// Map the port of the first listener on the NAT.
+	if nat != nil {
+		s.natPort = uint16(listeners[0].Addr().(*net.TCPAddr).Port)
+	}
+
+	// Create an onion service for the first listener via the Tor control
+	// port and advertise its address.
+	if cfg.TorControl != "" {
+		var target string
+		if len(listeners) > 0 {
+			target = onionServiceTarget(listeners[0].Addr())
+		}
+		virtualPort, err := strconv.ParseUint(activeNetParams.DefaultPort,
+			10, 16)
+		if err != nil {
+			return nil, err
+		}
+		s.torController = torcontrol.New(&torcontrol.Config{
+			ControlAddr:    cfg.TorControl,
+			Password:       cfg.TorPassword,
+			Target:         target,
+			VirtualPort:    uint16(virtualPort),
+			PrivateKeyFile: filepath.Join(cfg.DataDir, onionKeyFilename),
+			OnService: func(host string, port uint16) {
+				na, err := amgr.HostToNetAddress(host, port, services)
+				if err != nil {
+					srvrLog.Warnf("Unable to parse onion "+
+						"address %s: %v", host, err)
+					return
+				}
+				err = amgr.AddLocalAddress(na, addrmgr.ManualPrio)
+				if err != nil {
+					srvrLog.Warnf("Unable to add onion "+
+						"address %s: %v", host, err)
+				}
+			},
+		})
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2805,16 +4076,58 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.SpentIndex {
+		indxLog.Info("Spent index is enabled")
+		s.spentIndex = indexers.NewSpentIndex(db)
+		indexes = append(indexes, s.spentIndex)
+	}
+	if cfg.TimestampIndex {
+		indxLog.Info("Timestamp index is enabled")
+		s.tsIndex = indexers.NewTimestampIndex(db)
+		indexes = append(indexes, s.tsIndex)
+	}
+	if cfg.BalanceIndex {
+		indxLog.Info("Balance index is enabled")
+		s.balanceIndex = indexers.NewBalanceIndex(db)
+		indexes = append(indexes, s.balanceIndex)
+	}
 	if !cfg.NoCFilters {
 		indxLog.Info("Committed filter index is enabled")
 		s.cfIndex = indexers.NewCfIndex(db, chainParams)
 		indexes = append(indexes, s.cfIndex)
 	}
 
+	// The transaction count index can only be built from blocks which are
+	// still stored, so nodes which were pruned before it existed can't
+	// maintain it.
+	var beenPruned bool
+	dbErr := db.View(func(dbTx database.Tx) error {
+		var err error
+		beenPruned, err = dbTx.BeenPruned()
+		return err
+	})
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	if beenPruned && !indexers.TxCountIndexInitialized(db) {
+		indxLog.Warnf("Transaction count index is disabled since the " +
+			"node has been pruned before it was created")
+	} else {
+		indxLog.Info("Transaction count index is enabled")
+		s.txCountIndex = indexers.NewTxCountIndex(db)
+		indexes = append(indexes, s.txCountIndex)
+	}
+
 	// Create an index manager if any of the optional indexes are enabled.
+	//
+	// The concrete manager is also kept so the indexes can be backfilled
+	// and their status reported, while the interface passed to the chain
+	// must remain nil when there are no indexes.
 	var indexManager blockchain.IndexManager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		s.indexManager = indexers.NewManager(db, indexes)
+		s.indexManager.SetBackfillRate(cfg.IndexBackfillRate)
+		indexManager = s.indexManager
 	}
 
 	// Merge given checkpoints with the default ones unless they are disabled.
@@ -2828,24 +4141,95 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		btcdLog.Infof("Prune set to %d MiB", cfg.Prune)
 	}
 
+	// Only the most recent blocks are kept in filters-only mode, which is
+	// enough to serve them as a NODE_NETWORK_LIMITED peer.
+	var pruneDepth uint32
+	if cfg.FiltersOnly {
+		pruneDepth = wire.NodeNetworkLimitedBlockThreshold
+		btcdLog.Infof("Filters-only mode enabled, keeping the last %d "+
+			"blocks", pruneDepth)
+	}
+
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:               s.db,
-		Interrupt:        interrupt,
-		ChainParams:      s.chainParams,
-		Checkpoints:      checkpoints,
-		TimeSource:       s.timeSource,
-		SigCache:         s.sigCache,
-		IndexManager:     indexManager,
-		HashCache:        s.hashCache,
-		Prune:            cfg.Prune * 1024 * 1024,
-		UtxoCacheMaxSize: uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		DB:                      s.db,
+		Interrupt:               interrupt,
+		ChainParams:             s.chainParams,
+		Checkpoints:             checkpoints,
+		TimeSource:              s.timeSource,
+		SigCache:                s.sigCache,
+		IndexManager:            indexManager,
+		HashCache:               s.hashCache,
+		Prune:                   cfg.Prune * 1024 * 1024,
+		PruneDepth:              pruneDepth,
+		UtxoCacheMaxSize:        uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		ScriptValidationWorkers: cfg.ScriptWorkers,
+		MaxReorgDepth:           cfg.MaxReorgDepth,
+		UndoPruneDepth:          cfg.UndoPruneDepth,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Verify the consistency of the most recent blocks with the utxo set
+	// before using the chain.
+	err = s.chain.VerifyChain(cfg.CheckLevel, cfg.CheckBlocks, interrupt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify the chain: %v", err)
+	}
+
+	// Refuse to use a chain loaded from a utxo snapshot that was found to
+	// be invalid, and warn when the history of the snapshot isn't being
+	// validated.
+	snapStatus, err := s.chain.UtxoSnapshotStatus()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case snapStatus == nil || snapStatus.Validated:
+	case snapStatus.Invalid:
+		return nil, fmt.Errorf("the utxo snapshot at block %v the chain "+
+			"was loaded from is invalid -- the block database must "+
+			"be recreated", snapStatus.BaseHash)
+	case cfg.SnapshotValidateDB == "":
+		srvrLog.Warnf("The history of the utxo snapshot at block %v "+
+			"(height %d) is not validated -- use "+
+			"--utxosnapshotvalidatedb to validate it",
+			snapStatus.BaseHash, snapStatus.BaseHeight)
+	}
+
+	// Create the finality manager when finality signers are configured.
+	if len(cfg.finalitySigners) > 0 {
+		s.finalityMgr, err = finality.New(&finality.Config{
+			Chain:       s.chain,
+			Signers:     cfg.finalitySigners,
+			Quorum:      cfg.FinalityQuorum,
+			SigningKey:  cfg.finalityKey,
+			AttestDepth: cfg.FinalityDepth,
+			Broadcast: func(msg *wire.MsgAttest) {
+				s.BroadcastMessage(msg)
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the watch-only wallet and keep it up to date with the changes
+	// made to the main chain.
+	s.watchOnly, err = watchonly.New(&watchonly.Config{
+		DB:           db,
+		ChainParams:  chainParams,
+		BestSnapshot: s.chain.BestSnapshot,
+		ScanUtxoSet:  s.chain.ScanUtxoSet,
+		Interrupt:    interrupt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.chain.SubscribeChainEvents(s.watchOnly.HandleChainEvent)
+
 	// Search for a FeeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
 	db.Update(func(tx database.Tx) error {
@@ -2869,11 +4253,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	})
 
 	// If no feeEstimator has been found, or if the one that has been found
-	// is behind somehow, create a new one and start over.
-	if s.feeEstimator == nil || s.feeEstimator.LastKnownHeight() != s.chain.BestSnapshot().Height {
-		s.feeEstimator = mempool.NewFeeEstimator(
-			mempool.DefaultEstimateFeeMaxRollback,
-			mempool.DefaultEstimateFeeMinRegisteredBlocks)
+	// is ahead of the chain or too far behind it, create a new one and
+	// start over.
+	if s.feeEstimator == nil || s.feeEstimator.IsStale(s.chain.BestSnapshot().Height) {
+		s.feeEstimator = mempool.NewFeeEstimator()
 	}
 
 	txC := mempool.Config{
@@ -2883,10 +4266,19 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			FreeTxRelayLimit:     cfg.FreeTxRelayLimit,
 			MaxOrphanTxs:         cfg.MaxOrphanTxs,
 			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
+			MaxOrphanWeight:      cfg.MaxOrphanWeight,
+			MaxOrphanPeerWeight:  cfg.MaxOrphanPeerWeight,
+			OrphanTTL:            cfg.OrphanExpiry,
 			MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
 			MinRelayTxFee:        cfg.minRelayTxFee,
-			MaxTxVersion:         2,
+			Rules:                cfg.policyRules,
 			RejectReplacement:    cfg.RejectReplacement,
+			FullRBF:              cfg.MempoolFullRBF,
+			MaxPoolSize:          cfg.MaxMempool * 1000000,
+			MaxAncestorCount:     cfg.LimitAncestorCount,
+			MaxAncestorSize:      cfg.LimitAncestorSize * 1000,
+			MaxDescendantCount:   cfg.LimitDescendantCount,
+			MaxDescendantSize:    cfg.LimitDescendantSize * 1000,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2901,8 +4293,89 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
 	}
+
+	// Publish notifications about blocks and transactions over ZeroMQ when
+	// any of the topics is configured.
+	s.zmqNotifier, err = zmq.New(&zmq.Config{
+		HashBlock:     cfg.ZMQPubHashBlock,
+		HashTx:        cfg.ZMQPubHashTx,
+		RawBlock:      cfg.ZMQPubRawBlock,
+		RawTx:         cfg.ZMQPubRawTx,
+		Sequence:      cfg.ZMQPubSequence,
+		HighWaterMark: cfg.ZMQPubHWM,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.zmqNotifier.Enabled() {
+		txC.TxAdded = s.zmqNotifier.TxAdded
+		txC.TxRemoved = s.zmqNotifier.TxRemoved
+		s.chain.Subscribe(s.handleZMQNotification)
+	}
+
+	// Notify websocket clients about transactions replaced in the mempool.
+	// The RPC server is created after the mempool, so it is looked up when
+	// a replacement happens.
+	txC.TxReplaced = func(replaced, replacements []*mempool.TxDesc) {
+		if s.rpcServer != nil {
+			s.rpcServer.NotifyTxReplaced(replaced, replacements)
+		}
+	}
+
 	s.txMemPool = mempool.New(&txC)
 
+	// Transactions are only submitted locally through the RPC server, so
+	// there is nothing to rebroadcast without it.
+	if !cfg.DisableRPC {
+		s.rebroadcaster = rebroadcast.New(&rebroadcast.Config{
+			HaveTransaction: s.txMemPool.IsTransactionInPool,
+		})
+	}
+
+	// Create the block tracer when block tracing is enabled.
+	if cfg.blockTraceExporter != nil {
+		s.blockTracer = blocktrace.New(&blocktrace.Config{
+			Exporter: cfg.blockTraceExporter,
+		})
+	}
+
+	s.peerReputation, err = reputation.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	s.banManager, err = banman.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DNSSeeder != "" {
+		s.seeder, err = seeder.New(&seeder.Config{
+			DB:          db,
+			ChainParams: chainParams,
+			Host:        cfg.DNSSeeder,
+			Listen:      cfg.DNSSeederListen,
+			Crawlers:    cfg.DNSSeederCrawlers,
+			AllowUnroutable: cfg.RegressionTest || cfg.SimNet ||
+				cfg.ChainParamsFile != "",
+			Dial: func(addr string) (net.Conn, error) {
+				tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+				if err != nil {
+					return nil, err
+				}
+				return btcdDial(tcpAddr)
+			},
+			NewestBlock: func() (*chainhash.Hash, int32, error) {
+				best := s.chain.BestSnapshot()
+				return &best.Hash, best.Height, nil
+			},
+			Seeds: s.seederSeeds,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	s.syncManager, err = netsync.New(&netsync.Config{
 		PeerNotifier:       &s,
 		Chain:              s.chain,
@@ -2910,7 +4383,12 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		ChainParams:        s.chainParams,
 		DisableCheckpoints: cfg.DisableCheckpoints,
 		MaxPeers:           cfg.MaxPeers,
+		MinimumChainWork:   cfg.minChainWork,
+		AssumeValid:        cfg.assumeValid,
 		FeeEstimator:       s.feeEstimator,
+		TimeSource:         s.timeSource,
+		BlockTracer:        s.blockTracer,
+		PeerReputation:     s.peerReputation,
 	})
 	if err != nil {
 		return nil, err
@@ -2926,8 +4404,8 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		BlockMaxWeight:    cfg.BlockMaxWeight,
 		BlockMinSize:      cfg.BlockMinSize,
 		BlockMaxSize:      cfg.BlockMaxSize,
-		BlockPrioritySize: cfg.BlockPrioritySize,
 		TxMinFreeFee:      cfg.minRelayTxFee,
+		TemplateStaleness: cfg.BlockTemplateStale,
 	}
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
@@ -2936,6 +4414,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
 		MiningAddrs:            cfg.miningAddrs,
+		Payouts:                cfg.miningPayouts,
 		ProcessBlock:           s.syncManager.ProcessBlock,
 		ConnectedCount:         s.ConnectedCount,
 		IsCurrent:              s.syncManager.IsCurrent,
@@ -2962,11 +4441,30 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.groupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
 
+				// I2P addresses are only relayed since connecting
+				// to them is not supported, and CJDNS addresses
+				// are only reachable when the host is part of the
+				// CJDNS network.
+				if addr.NetAddress().IsI2P() {
+					continue
+				}
+				if addr.NetAddress().IsCJDNS() && !cfg.CJDNSReachable {
+					continue
+				}
+
+				// Don't connect to banned or discouraged peers.
+				if ip := addr.NetAddress().ToLegacy(); ip != nil &&
+					(s.banManager.IsBanned(ip.IP) ||
+						s.banManager.IsDiscouraged(ip.IP)) {
+
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -2979,10 +4477,25 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 					continue
 				}
 
+				// Prefer hosts that were useful in the past over
+				// unknown and misbehaving ones according to their
+				// reputation until 70 failed tries.
+				addrString := addrmgr.NetAddressKey(addr.NetAddress())
+				if tries < 70 && mrand.Float64() >=
+					s.peerReputation.Weight(addrString) {
+					continue
+				}
+
 				// Mark an attempt for the valid address.
 				s.addrManager.Attempt(addr.NetAddress())
 
-				addrString := addrmgr.NetAddressKey(addr.NetAddress())
+				// Only attempt the v2 transport protocol with
+				// peers that advertise it.
+				if addr.NetAddress().HasService(wire.SFNodeP2PV2) {
+					s.v1Addrs.Delete(addrString)
+				} else {
+					s.v1Addrs.Add(addrString)
+				}
 				return addrStringToNetAddr(addrString)
 			}
 
@@ -2990,19 +4503,49 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 	}
 
+	// Connect to the block relay only peers before the last shutdown
+	// again, which prevents an attacker from taking over all outbound
+	// connections with a restart.  The anchors are only used when new
+	// peers are connected to automatically.
+	anchorAddrs, err := loadAnchors(filepath.Join(cfg.DataDir,
+		anchorsFilename))
+	if err != nil {
+		srvrLog.Warnf("Unable to load anchors: %v", err)
+	}
+	var anchors []net.Addr
+	if newAddressFunc != nil {
+		for _, addr := range anchorAddrs {
+			netAddr, err := addrStringToNetAddr(addr)
+			if err != nil {
+				srvrLog.Debugf("Ignoring anchor %s: %v", addr, err)
+				continue
+			}
+			anchors = append(anchors, netAddr)
+		}
+		if len(anchors) > 0 {
+			srvrLog.Infof("Connecting to %d anchors", len(anchors))
+		}
+	}
+
 	// Create a connection manager.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	targetBlockRelayOnly := defaultTargetBlockRelayOnly
+	if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+		targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       uint32(targetOutbound),
+		TargetBlockRelayOnly: uint32(targetBlockRelayOnly),
+		Anchors:              anchors,
+		Dial:                 s.dial,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -3038,21 +4581,35 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
+			Listeners:      rpcListeners,
+			StartupTime:    s.startupTime,
+			Services:       s.services,
+			ConnMgr:        &rpcConnManager{&s},
+			SyncMgr:        &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:     s.timeSource,
+			Chain:          s.chain,
+			ChainParams:    chainParams,
+			DB:             db,
+			TxMemPool:      s.txMemPool,
+			Generator:      blockTemplateGenerator,
+			CPUMiner:       s.cpuMiner,
+			TxIndex:        s.txIndex,
+			AddrIndex:      s.addrIndex,
+			CfIndex:        s.cfIndex,
+			TxCountIndex:   s.txCountIndex,
+			SpentIndex:     s.spentIndex,
+			TimestampIndex: s.tsIndex,
+			BalanceIndex:   s.balanceIndex,
+			IndexManager:   s.indexManager,
+			FeeEstimator:   s.feeEstimator,
+			FinalityMgr:    s.finalityMgr,
+			PeerReputation: s.peerReputation,
+			WatchOnly:      s.watchOnly,
+			Rebroadcaster:  s.rebroadcaster,
+			Diagnostics:    s.diagnostics,
+			DumpState:      s.dumpState,
+			ReloadConfig:   s.reloadConfig,
+			ZMQNotifier:    s.zmqNotifier,
 		})
 		if err != nil {
 			return nil, err
@@ -3065,12 +4622,237 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}()
 	}
 
+	if len(cfg.GRPCListeners) > 0 {
+		grpcListeners, tlsConfig, err := setupGRPCListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(grpcListeners) == 0 {
+			return nil, errors.New("GRPC: No valid listen address")
+		}
+
+		grpcCfg := grpcserver.Config{
+			Listeners: grpcListeners,
+			Chain:     s.chain,
+			TxMemPool: s.txMemPool,
+			ProcessTransaction: func(tx *btcutil.Tx) error {
+				// Use 0 for the tag to represent local node.
+				acceptedTxs, err := s.txMemPool.ProcessTransaction(
+					tx, false, false, 0)
+				if err != nil {
+					return err
+				}
+				s.AnnounceNewTransactions(acceptedTxs)
+				return nil
+			},
+			Token:     cfg.GRPCToken,
+			TLSConfig: tlsConfig,
+		}
+		if s.txIndex != nil {
+			grpcCfg.FetchConfirmedTx = s.fetchConfirmedTx
+		}
+		s.grpcServer = grpcserver.New(&grpcCfg)
+		s.chain.Subscribe(s.handleGRPCNotification)
+	}
+
+	if len(cfg.SV2Listeners) > 0 {
+		s.sv2Server, err = newSV2Server(&s, blockTemplateGenerator)
+		if err != nil {
+			return nil, err
+		}
+		s.chain.Subscribe(s.handleSV2Notification)
+	}
+
+	if len(cfg.MetricsListeners) > 0 {
+		metricsListeners, err := setupMetricsListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(metricsListeners) == 0 {
+			return nil, errors.New("MTRC: No valid listen address")
+		}
+
+		s.registerMetrics()
+		s.metricsServer = metrics.New(&metrics.Config{
+			Listeners: metricsListeners,
+			Token:     cfg.MetricsToken,
+		})
+	}
+
 	return &s, nil
 }
 
+// setupGRPCListeners returns the listeners of the configured gRPC listen
+// addresses along with the TLS configuration to serve them with.  The
+// certificate of the RPC server is used and generated when it doesn't exist.
+func setupGRPCListeners() ([]net.Listener, *tls.Config, error) {
+	if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
+		err := genCertPair(cfg.RPCCert, cfg.RPCKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keypair},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	netAddrs, err := parseListeners(cfg.GRPCListeners)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			grpcLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, tlsConfig, nil
+}
+
+// fetchConfirmedTx returns the serialized transaction with the passed hash
+// along with the hash of the block including it by looking it up in the
+// transaction index.  It returns nil when the transaction isn't indexed.
+func (s *server) fetchConfirmedTx(txHash *chainhash.Hash) ([]byte, *chainhash.Hash, error) {
+	blockRegion, err := s.txIndex.TxBlockRegion(txHash)
+	if err != nil || blockRegion == nil {
+		return nil, nil, err
+	}
+
+	var txBytes []byte
+	err = s.db.View(func(dbTx database.Tx) error {
+		var err error
+		txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return txBytes, blockRegion.Hash, nil
+}
+
+// handleGRPCNotification notifies the gRPC block streams about the blocks
+// connected to and disconnected from the main chain.
+func (s *server) handleGRPCNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		if block, ok := notification.Data.(*btcutil.Block); ok {
+			s.grpcServer.NotifyBlockConnected(block)
+		}
+
+	case blockchain.NTBlockDisconnected:
+		if block, ok := notification.Data.(*btcutil.Block); ok {
+			s.grpcServer.NotifyBlockDisconnected(block)
+		}
+	}
+}
+
+// loadSV2Key returns the private key saved to the file with the passed name in
+// the data directory.  A new key is generated and saved when the file doesn't
+// exist.
+func loadSV2Key(name string) (*btcec.PrivateKey, error) {
+	path := filepath.Join(cfg.DataDir, name)
+	keyHex, err := os.ReadFile(path)
+	if err == nil {
+		keyBytes, err := hex.DecodeString(strings.TrimSpace(string(keyHex)))
+		if err != nil || len(keyBytes) != btcec.PrivKeyBytesLen {
+			return nil, fmt.Errorf("%s does not contain a hex-encoded "+
+				"private key", path)
+		}
+		key, _ := btcec.PrivKeyFromBytes(keyBytes)
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	keyHex = []byte(hex.EncodeToString(key.Serialize()) + "\n")
+	if err := os.WriteFile(path, keyHex, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newSV2Server returns the Stratum V2 template provider serving the templates
+// of the passed generator on the configured listen addresses.
+func newSV2Server(s *server, generator *mining.BlkTmplGenerator) (*sv2.Server, error) {
+	staticKey, err := loadSV2Key(sv2StaticKeyFilename)
+	if err != nil {
+		return nil, err
+	}
+	authorityKey, err := loadSV2Key(sv2AuthorityKeyFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	netAddrs, err := parseListeners(cfg.SV2Listeners)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			stv2Log.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("STV2: No valid listen address")
+	}
+
+	return sv2.New(&sv2.Config{
+		Listeners:    listeners,
+		StaticKey:    staticKey,
+		AuthorityKey: authorityKey,
+		NewTemplate: func() (*mining.BlockTemplate, error) {
+			// The clients replace the coinbase transaction, so it
+			// doesn't pay to any address.
+			return generator.NewBlockTemplate(nil)
+		},
+		SubmitBlock: func(block *btcutil.Block) error {
+			isOrphan, err := s.syncManager.ProcessBlock(block,
+				blockchain.BFNone)
+			if err != nil {
+				return err
+			}
+			if isOrphan {
+				return errors.New("block is an orphan")
+			}
+			return nil
+		},
+		IsCurrent:    s.syncManager.IsCurrent,
+		LastTxUpdate: s.txMemPool.LastUpdated,
+		Interval:     cfg.SV2Interval,
+		FeeDelta:     cfg.SV2FeeDelta,
+	}), nil
+}
+
+// handleSV2Notification lets the Stratum V2 template provider know about the
+// blocks connected to the main chain.
+func (s *server) handleSV2Notification(notification *blockchain.Notification) {
+	if notification.Type == blockchain.NTBlockConnected {
+		s.sv2Server.NotifyBlockConnected()
+	}
+}
+
 // initListeners initializes the configured net listeners and adds any bound
 // addresses to the address manager. Returns the listeners and a NAT interface,
-// which is non-nil if UPnP is in use.
+// which is non-nil if UPnP, NAT-PMP or PCP is in use.
 func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wire.ServiceFlag) ([]net.Listener, NAT, error) {
 	// Listen for TCP connections at the configured addresses
 	netAddrs, err := parseListeners(listenAddrs)
@@ -3132,6 +4914,14 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 			}
 			// nil nat here is fine, just means no upnp on network.
 		}
+		if nat == nil && cfg.NATPMP {
+			var err error
+			nat, err = DiscoverPMP()
+			if err != nil {
+				srvrLog.Warnf("Can't discover NAT-PMP or PCP "+
+					"gateway: %v", err)
+			}
+		}
 
 		// Add bound addresses to address manager to be advertised to peers.
 		for _, listener := range listeners {
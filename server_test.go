@@ -0,0 +1,192 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btclog"
+)
+
+// setUpFilterTest replaces the global config with the default ban threshold
+// and disables the peer log, which has no log file to write to in tests, until
+// the test finishes.
+func setUpFilterTest(t *testing.T) {
+	oldCfg, oldLog := cfg, peerLog
+	t.Cleanup(func() { cfg, peerLog = oldCfg, oldLog })
+	cfg = &config{BanThreshold: defaultBanThreshold}
+	peerLog = btclog.Disabled
+}
+
+// newFilterTestPeer returns a server peer of a server which supports bloom
+// filters.  The peer is not connected, so disconnecting it only marks it as
+// disconnected.
+func newFilterTestPeer() (*serverPeer, *server) {
+	s := &server{
+		services: wire.SFNodeNetwork | wire.SFNodeBloom,
+		banPeers: make(chan *serverPeer, 1),
+	}
+	sp := newServerPeer(s, false)
+	sp.Peer = peer.NewInboundPeer(&peer.Config{})
+	return sp, s
+}
+
+// isDisconnected returns whether the passed peer was disconnected.
+func isDisconnected(sp *serverPeer) bool {
+	done := make(chan struct{})
+	go func() {
+		sp.WaitForDisconnect()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+// isBanned returns whether the passed peer was banned by the passed server.
+func isBanned(s *server, sp *serverPeer) bool {
+	select {
+	case banned := <-s.banPeers:
+		return banned == sp
+	default:
+		return false
+	}
+}
+
+// filterLoadMsg returns a filterload message with a filter of the passed size
+// and number of hash functions.
+func filterLoadMsg(size int, hashFuncs uint32) *wire.MsgFilterLoad {
+	return wire.NewMsgFilterLoad(make([]byte, size), hashFuncs, 0,
+		wire.BloomUpdateNone)
+}
+
+// TestFilterAddLimit ensures a peer may add up to maxFilterAdds elements to a
+// loaded bloom filter and is disconnected once it adds more, and that loading
+// or clearing the filter resets the limit.
+func TestFilterAddLimit(t *testing.T) {
+	setUpFilterTest(t)
+
+	sp, s := newFilterTestPeer()
+	addMsg := wire.NewMsgFilterAdd([]byte{0x01, 0x02, 0x03})
+
+	// An honest peer stays within the limit and resets it by loading or
+	// clearing its filter.
+	sp.OnFilterLoad(nil, filterLoadMsg(1000, 10))
+	for i := 0; i < maxFilterAdds; i++ {
+		sp.OnFilterAdd(nil, addMsg)
+	}
+	if sp.filterAdds != maxFilterAdds {
+		t.Fatalf("unexpected number of filter additions: got %d, "+
+			"want %d", sp.filterAdds, maxFilterAdds)
+	}
+	sp.OnFilterLoad(nil, filterLoadMsg(1000, 10))
+	if sp.filterAdds != 0 {
+		t.Fatalf("filter additions not reset by filterload: %d",
+			sp.filterAdds)
+	}
+	for i := 0; i < maxFilterAdds; i++ {
+		sp.OnFilterAdd(nil, addMsg)
+	}
+	sp.OnFilterClear(nil, wire.NewMsgFilterClear())
+	if sp.filterAdds != 0 {
+		t.Fatalf("filter additions not reset by filterclear: %d",
+			sp.filterAdds)
+	}
+	sp.OnFilterLoad(nil, filterLoadMsg(1000, 10))
+	sp.OnFilterAdd(nil, addMsg)
+	if isDisconnected(sp) || isBanned(s, sp) {
+		t.Fatal("peer within the filter limits was disconnected")
+	}
+
+	// Adding more elements than allowed disconnects the peer.
+	sp, s = newFilterTestPeer()
+	sp.OnFilterLoad(nil, filterLoadMsg(1000, 10))
+	for i := 0; i < maxFilterAdds; i++ {
+		sp.OnFilterAdd(nil, addMsg)
+	}
+	if isDisconnected(sp) {
+		t.Fatal("peer disconnected before exceeding the filter " +
+			"additions")
+	}
+	sp.OnFilterAdd(nil, addMsg)
+	if !isDisconnected(sp) {
+		t.Fatal("peer exceeding the filter additions not disconnected")
+	}
+	if sp.filterAdds != maxFilterAdds {
+		t.Fatalf("filter addition past the limit counted: %d",
+			sp.filterAdds)
+	}
+}
+
+// TestFilterLoadBanScore ensures loading bloom filters increases the ban score
+// of a peer in proportion to their size, that a peer flooding the server with
+// large filters is banned while one loading filters occasionally is not, and
+// that a peer loading a filter which is too large is disconnected.
+func TestFilterLoadBanScore(t *testing.T) {
+	setUpFilterTest(t)
+
+	// Loading a filter of the maximum size increases the ban score by
+	// maxFilterLoadBanScore while small filters barely do.
+	sp, s := newFilterTestPeer()
+	sp.OnFilterLoad(nil, filterLoadMsg(10, 10))
+	if score := sp.banScore.Int(); score != 1 {
+		t.Fatalf("unexpected ban score after small filter: got %d, "+
+			"want 1", score)
+	}
+	sp.OnFilterLoad(nil, filterLoadMsg(wire.MaxFilterLoadFilterSize, 10))
+	if score := sp.banScore.Int(); score != maxFilterLoadBanScore+2 {
+		t.Fatalf("unexpected ban score after large filter: got %d, "+
+			"want %d", score, maxFilterLoadBanScore+2)
+	}
+	if isDisconnected(sp) || isBanned(s, sp) {
+		t.Fatal("peer loading two filters was disconnected")
+	}
+	if !sp.filter.IsLoaded() {
+		t.Fatal("filter of honest peer not loaded")
+	}
+
+	// A peer loading filters of the maximum size in a row is banned once
+	// its score exceeds the ban threshold.
+	sp, s = newFilterTestPeer()
+	numLoads := defaultBanThreshold/(maxFilterLoadBanScore+1) + 1
+	for i := 1; i <= numLoads; i++ {
+		sp.OnFilterLoad(nil, filterLoadMsg(wire.MaxFilterLoadFilterSize,
+			10))
+		if i < numLoads && isBanned(s, sp) {
+			t.Fatalf("peer banned after %d filter loads", i)
+		}
+	}
+	if !isBanned(s, sp) || !isDisconnected(sp) {
+		t.Fatalf("peer flooding filter loads not banned, ban score %d",
+			sp.banScore.Int())
+	}
+
+	// A filter exceeding the size constraints disconnects the peer right
+	// away.
+	for _, msg := range []*wire.MsgFilterLoad{
+		filterLoadMsg(wire.MaxFilterLoadFilterSize+1, 10),
+		filterLoadMsg(1000, wire.MaxFilterLoadHashFuncs+1),
+	} {
+		sp, _ = newFilterTestPeer()
+		sp.OnFilterLoad(nil, msg)
+		if !isDisconnected(sp) {
+			t.Fatal("peer loading too large filter not disconnected")
+		}
+		if score := sp.banScore.Int(); score != 100 {
+			t.Fatalf("unexpected ban score after too large filter: "+
+				"got %d, want 100", score)
+		}
+		if sp.filter.IsLoaded() {
+			t.Fatal("too large filter loaded")
+		}
+	}
+}
@@ -14,4 +14,6 @@ import (
 
 func init() {
 	interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	stateDumpSignals = []os.Signal{syscall.SIGUSR1}
+	reloadSignals = []os.Signal{syscall.SIGHUP}
 }
@@ -0,0 +1,185 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/diagnostics"
+)
+
+const (
+	// profilesDirName is the name of the directory in the data directory
+	// profiles and state dumps are written to.
+	profilesDirName = "profiles"
+
+	// syncStateTimeout is the maximum amount of time to wait for the sync
+	// manager to report its state for a state dump.
+	syncStateTimeout = 2 * time.Second
+)
+
+// stateDumpSignals defines the signals which request a state dump.  It is
+// populated during init on platforms which support them.
+var stateDumpSignals []os.Signal
+
+// stateDumpHandler writes a state dump each time one of the state dump signals
+// is received.  It keeps running while the server shuts down, so a hung
+// shutdown can be diagnosed as well, and must be run as a goroutine.
+func (s *server) stateDumpHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, stateDumpSignals...)
+	for sig := range c {
+		srvrLog.Infof("Received signal (%s).  Dumping state...", sig)
+		s.dumpState()
+	}
+}
+
+// dumpState writes a dump of the state of the server along with the stacks of
+// all goroutines to the profiles directory in the data directory.
+//
+// This function is safe for concurrent access.
+func (s *server) dumpState() (*diagnostics.StateDump, error) {
+	dump, err := s.diagnostics.DumpState(s.stateSections())
+	if err != nil {
+		srvrLog.Errorf("Unable to write state dump: %v", err)
+		return nil, err
+	}
+	srvrLog.Infof("Wrote state dump to %s", dump.Path)
+	return dump, nil
+}
+
+// stateSections returns the sections of the state dumps of the server.
+func (s *server) stateSections() []diagnostics.Section {
+	return []diagnostics.Section{
+		{Name: "Node", Write: s.writeNodeState},
+		{Name: "Sync manager", Write: s.writeSyncState},
+		{Name: "Database", Write: s.writeDBState},
+		{Name: "Chain", Write: s.writeChainState},
+		{Name: "Memory pool", Write: s.writeMempoolState},
+	}
+}
+
+// writeNodeState writes the version, uptime and runtime statistics of the
+// node.
+func (s *server) writeNodeState(w io.Writer) error {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	started := time.Unix(s.startupTime, 0)
+	fmt.Fprintf(w, "version:    %s\n", version())
+	fmt.Fprintf(w, "started:    %v (%v ago)\n", started,
+		time.Since(started).Truncate(time.Second))
+	fmt.Fprintf(w, "goroutines: %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(w, "heap:       %d bytes in use, %d bytes from the OS\n",
+		memStats.HeapInuse, memStats.HeapSys)
+	_, err := fmt.Fprintf(w, "gc:         %d cycles\n", memStats.NumGC)
+	return err
+}
+
+// writeSyncState writes what the block handler of the sync manager is doing
+// and, unless it is stuck, the blocks and transactions requested from the
+// peers.
+func (s *server) writeSyncState(w io.Writer) error {
+	activity := s.syncManager.Activity()
+	task := activity.Task
+	if task == "" {
+		task = "waiting for work"
+	}
+	fmt.Fprintf(w, "handler:          %s for %v\n", task,
+		time.Since(activity.Since).Truncate(time.Millisecond))
+
+	state, err := s.syncManager.State(syncStateTimeout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "sync peer:        %d\n", state.SyncPeer)
+	if state.HeadersFirst {
+		fmt.Fprintf(w, "headers-first:    %d headers (synced: %v), "+
+			"next block %d\n", state.Headers, state.HeadersSynced,
+			state.NextProcessHeight)
+	} else {
+		fmt.Fprintf(w, "headers-first:    false\n")
+	}
+	fmt.Fprintf(w, "last progress:    %s\n", since(state.LastProgress))
+	fmt.Fprintf(w, "requested:        %d blocks, %d transactions\n",
+		state.RequestedBlocks, state.RequestedTxns)
+	fmt.Fprintf(w, "buffered blocks:  %d downloaded (%d bytes), %d "+
+		"stalled, %d failed\n", state.DownloadedBlocks,
+		state.DownloadedBytes, state.StalledBlocks, state.FailedBlocks)
+
+	fmt.Fprintf(w, "\npeers:\n")
+	for _, peer := range state.Peers {
+		fmt.Fprintf(w, "  %d %s candidate=%v queued=%d blocks=%d "+
+			"txns=%d stalls=%d\n", peer.ID, peer.Addr,
+			peer.SyncCandidate, peer.RequestQueue,
+			peer.RequestedBlocks, peer.RequestedTxns, peer.Stalls)
+	}
+
+	fmt.Fprintf(w, "\nblock requests:\n")
+	for _, req := range state.BlockRequests {
+		fmt.Fprintf(w, "  %d %s from peer %d %s\n", req.Height,
+			req.Hash, req.Peer, since(req.Requested))
+	}
+	return nil
+}
+
+// writeDBState writes the holder of the database write lock and the status of
+// the database cache.
+func (s *server) writeDBState(w io.Writer) error {
+	status, err := ffldb.DBStatus(s.db)
+	if err != nil {
+		return err
+	}
+	if status.Writer != 0 {
+		fmt.Fprintf(w, "write lock:  held by goroutine %d since %s\n",
+			status.Writer, since(status.WriteLocked))
+	} else {
+		fmt.Fprintf(w, "write lock:  not held by a transaction\n")
+	}
+	fmt.Fprintf(w, "cache:       %d keys, %d removals, %d of %d bytes\n",
+		status.CachedKeys, status.CachedRemoves, status.CacheSize,
+		status.MaxCacheSize)
+	_, err = fmt.Fprintf(w, "last flush:  %s\n", since(status.LastFlush))
+	return err
+}
+
+// writeChainState writes the best block and the status of the UTXO cache.
+func (s *server) writeChainState(w io.Writer) error {
+	best := s.chain.BestSnapshot()
+	fmt.Fprintf(w, "best block:  %d %s\n", best.Height, best.Hash)
+	fmt.Fprintf(w, "best header: %d\n", s.syncManager.HeaderHeight())
+
+	// The UTXO cache status waits for the chain lock, so it is written
+	// last in case block processing is stuck.
+	utxo := s.chain.UtxoCacheStatus()
+	fmt.Fprintf(w, "utxo cache:  %d entries, %d of %d bytes\n",
+		utxo.Entries, utxo.MemoryUsage, utxo.MaxMemoryUsage)
+	_, err := fmt.Fprintf(w, "utxo flush:  %s at block %v\n",
+		since(utxo.LastFlushTime), utxo.LastFlushHash)
+	return err
+}
+
+// writeMempoolState writes the size of the memory pool.
+func (s *server) writeMempoolState(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "transactions: %d (%d bytes, %d bytes of "+
+		"memory)\n", s.txMemPool.Count(), s.txMemPool.Bytes(),
+		s.txMemPool.MemoryUsage())
+	return err
+}
+
+// since returns how long ago the passed time was, or never when it is the zero
+// time.
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%v ago", time.Since(t).Truncate(time.Millisecond))
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package sv2 implements a Stratum V2 template provider, which serves block
+templates to pools and job declarators over the Template Distribution Protocol
+of Stratum V2 as an alternative to long polling getblocktemplate.
+
+Clients connect over TCP and secure the connection with the
+Noise_NX_Secp256k1+EllSwift_ChaChaPoly_SHA256 handshake.  The public keys are
+exchanged in the ElligatorSwift encoding of BIP 324 and the static key of the
+server is authenticated by a certificate signed with an authority key.  Every
+message is carried in a frame with a 6-byte header made up of the extension
+type, the message type and the length of the payload, which is encrypted with
+ChaCha20-Poly1305 in chunks of at most 65535 bytes.
+
+Once a client has set up the connection for the template distribution protocol,
+it sends CoinbaseOutputConstraints to reserve space for its coinbase outputs.
+The server then sends it a NewTemplate followed by SetNewPrevHash, and pushes a
+new template whenever a block is connected to the main chain or the fees
+available in the memory pool increased by the configured delta since the last
+template.  The transactions of a template are sent on request and solutions are
+submitted with SubmitSolution, after which the solved block is processed by the
+node.
+
+The coinbase transaction of the templates is built by the clients.  The
+templates only carry the value available for the coinbase outputs, the prefix
+of the coinbase script which commits to the height of the block and the outputs
+required by the node, which is the witness commitment if any.
+*/
+package sv2
@@ -0,0 +1,201 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sv2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// frameHeaderSize is the size of the header of a frame, which consists
+	// of the extension type, message type and length of the payload.
+	frameHeaderSize = 6
+
+	// maxPayloadSize is the maximum size of the payload of a frame, which
+	// is limited by its 24-bit length.
+	maxPayloadSize = 1<<24 - 1
+)
+
+// errShortPayload is returned when a payload ends before a message is fully
+// decoded.
+var errShortPayload = errors.New("payload too short")
+
+// frameHeader is the header of a frame.
+type frameHeader struct {
+	extensionType uint16
+	msgType       uint8
+	length        uint32
+}
+
+// encodeFrameHeader returns the serialized frame header.
+func encodeFrameHeader(h frameHeader) [frameHeaderSize]byte {
+	var b [frameHeaderSize]byte
+	binary.LittleEndian.PutUint16(b[0:2], h.extensionType)
+	b[2] = h.msgType
+	b[3] = byte(h.length)
+	b[4] = byte(h.length >> 8)
+	b[5] = byte(h.length >> 16)
+	return b
+}
+
+// decodeFrameHeader returns the frame header serialized in the passed bytes.
+func decodeFrameHeader(b []byte) frameHeader {
+	return frameHeader{
+		extensionType: binary.LittleEndian.Uint16(b[0:2]),
+		msgType:       b[2],
+		length:        uint32(b[3]) | uint32(b[4])<<8 | uint32(b[5])<<16,
+	}
+}
+
+// writer serializes the data types of Stratum V2.  Errors are sticky, so a
+// message is serialized completely before its error is checked.
+type writer struct {
+	buf []byte
+	err error
+}
+
+func (w *writer) u8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *writer) boolean(v bool) {
+	if v {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+
+func (w *writer) u16(v uint16) {
+	w.buf = append(w.buf, byte(v), byte(v>>8))
+}
+
+func (w *writer) u24(v uint32) {
+	w.buf = append(w.buf, byte(v), byte(v>>8), byte(v>>16))
+}
+
+func (w *writer) u32(v uint32) {
+	w.buf = append(w.buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (w *writer) u64(v uint64) {
+	w.u32(uint32(v))
+	w.u32(uint32(v >> 32))
+}
+
+func (w *writer) u256(v *chainhash.Hash) {
+	w.buf = append(w.buf, v[:]...)
+}
+
+// bytes writes the passed bytes prefixed with their length, which is encoded
+// in lenSize bytes.
+func (w *writer) bytes(b []byte, lenSize int) {
+	if uint64(len(b)) >= 1<<(8*uint(lenSize)) {
+		if w.err == nil {
+			w.err = fmt.Errorf("field of %d bytes exceeds the "+
+				"maximum of %d bytes", len(b), 1<<(8*uint(lenSize))-1)
+		}
+		return
+	}
+	w.seqLen(len(b), lenSize)
+	w.buf = append(w.buf, b...)
+}
+
+// seqLen writes the length of a sequence in lenSize bytes.
+func (w *writer) seqLen(n int, lenSize int) {
+	switch lenSize {
+	case 1:
+		w.u8(uint8(n))
+	case 2:
+		w.u16(uint16(n))
+	case 3:
+		w.u24(uint32(n))
+	}
+}
+
+// str0255 writes a STR0_255.
+func (w *writer) str0255(s string) {
+	w.bytes([]byte(s), 1)
+}
+
+// reader deserializes the data types of Stratum V2.  Errors are sticky, so a
+// message is deserialized completely before its error is checked.
+type reader struct {
+	buf []byte
+	err error
+}
+
+// next returns the next n bytes of the payload.
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.buf) < n {
+		r.err = errShortPayload
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) u8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *reader) boolean() bool {
+	return r.u8()&1 == 1
+}
+
+func (r *reader) u16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *reader) u24() uint32 {
+	b := r.next(3)
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func (r *reader) u32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *reader) u64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *reader) u256() chainhash.Hash {
+	var h chainhash.Hash
+	copy(h[:], r.next(chainhash.HashSize))
+	return h
+}
+
+// seqLen reads the length of a sequence encoded in lenSize bytes.
+func (r *reader) seqLen(lenSize int) int {
+	switch lenSize {
+	case 1:
+		return int(r.u8())
+	case 2:
+		return int(r.u16())
+	default:
+		return int(r.u24())
+	}
+}
+
+// bytes reads bytes prefixed with their length, which is encoded in lenSize
+// bytes.
+func (r *reader) bytes(lenSize int) []byte {
+	n := r.seqLen(lenSize)
+	return append([]byte(nil), r.next(n)...)
+}
+
+// str0255 reads a STR0_255.
+func (r *reader) str0255() string {
+	return string(r.bytes(1))
+}
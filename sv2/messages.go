@@ -0,0 +1,411 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sv2
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Protocol identifies a subprotocol of Stratum V2 in SetupConnection.
+type Protocol uint8
+
+// These constants define the subprotocols of Stratum V2.
+const (
+	ProtocolMining               Protocol = 0
+	ProtocolJobDeclaration       Protocol = 1
+	ProtocolTemplateDistribution Protocol = 2
+)
+
+// These constants define the types of the messages which are implemented.  All
+// of them belong to the common messages or the template distribution protocol
+// and use extension type 0.
+const (
+	MsgTypeSetupConnection               = 0x00
+	MsgTypeSetupConnectionSuccess        = 0x01
+	MsgTypeSetupConnectionError          = 0x02
+	MsgTypeCoinbaseOutputConstraints     = 0x70
+	MsgTypeNewTemplate                   = 0x71
+	MsgTypeSetNewPrevHash                = 0x72
+	MsgTypeRequestTransactionData        = 0x73
+	MsgTypeRequestTransactionDataSuccess = 0x74
+	MsgTypeRequestTransactionDataError   = 0x75
+	MsgTypeSubmitSolution                = 0x76
+)
+
+// These constants define the error codes sent in SetupConnection.Error and
+// RequestTransactionData.Error messages.
+const (
+	ErrUnsupportedFeatureFlags = "unsupported-feature-flags"
+	ErrUnsupportedProtocol     = "unsupported-protocol"
+	ErrProtocolVersionMismatch = "protocol-version-mismatch"
+	ErrTemplateIDNotFound      = "template-id-not-found"
+	ErrStaleTemplateID         = "stale-template-id"
+)
+
+// Message is a message of Stratum V2.
+type Message interface {
+	// MsgType returns the type of the message.
+	MsgType() uint8
+
+	encode(w *writer)
+	decode(r *reader)
+}
+
+// SetupConnection is sent by the client to initiate the connection.
+type SetupConnection struct {
+	Protocol        Protocol
+	MinVersion      uint16
+	MaxVersion      uint16
+	Flags           uint32
+	EndpointHost    string
+	EndpointPort    uint16
+	Vendor          string
+	HardwareVersion string
+	Firmware        string
+	DeviceID        string
+}
+
+// MsgType returns the type of the message.
+func (m *SetupConnection) MsgType() uint8 { return MsgTypeSetupConnection }
+
+func (m *SetupConnection) encode(w *writer) {
+	w.u8(uint8(m.Protocol))
+	w.u16(m.MinVersion)
+	w.u16(m.MaxVersion)
+	w.u32(m.Flags)
+	w.str0255(m.EndpointHost)
+	w.u16(m.EndpointPort)
+	w.str0255(m.Vendor)
+	w.str0255(m.HardwareVersion)
+	w.str0255(m.Firmware)
+	w.str0255(m.DeviceID)
+}
+
+func (m *SetupConnection) decode(r *reader) {
+	m.Protocol = Protocol(r.u8())
+	m.MinVersion = r.u16()
+	m.MaxVersion = r.u16()
+	m.Flags = r.u32()
+	m.EndpointHost = r.str0255()
+	m.EndpointPort = r.u16()
+	m.Vendor = r.str0255()
+	m.HardwareVersion = r.str0255()
+	m.Firmware = r.str0255()
+	m.DeviceID = r.str0255()
+}
+
+// SetupConnectionSuccess is the response to an accepted SetupConnection.
+type SetupConnectionSuccess struct {
+	UsedVersion uint16
+	Flags       uint32
+}
+
+// MsgType returns the type of the message.
+func (m *SetupConnectionSuccess) MsgType() uint8 { return MsgTypeSetupConnectionSuccess }
+
+func (m *SetupConnectionSuccess) encode(w *writer) {
+	w.u16(m.UsedVersion)
+	w.u32(m.Flags)
+}
+
+func (m *SetupConnectionSuccess) decode(r *reader) {
+	m.UsedVersion = r.u16()
+	m.Flags = r.u32()
+}
+
+// SetupConnectionError is the response to a rejected SetupConnection.
+type SetupConnectionError struct {
+	Flags     uint32
+	ErrorCode string
+}
+
+// MsgType returns the type of the message.
+func (m *SetupConnectionError) MsgType() uint8 { return MsgTypeSetupConnectionError }
+
+func (m *SetupConnectionError) encode(w *writer) {
+	w.u32(m.Flags)
+	w.str0255(m.ErrorCode)
+}
+
+func (m *SetupConnectionError) decode(r *reader) {
+	m.Flags = r.u32()
+	m.ErrorCode = r.str0255()
+}
+
+// CoinbaseOutputConstraints is sent by the client to reserve space for the
+// outputs it adds to the coinbase transaction.  No templates are sent before
+// it's received.
+type CoinbaseOutputConstraints struct {
+	MaxAdditionalSize   uint32
+	MaxAdditionalSigops uint16
+}
+
+// MsgType returns the type of the message.
+func (m *CoinbaseOutputConstraints) MsgType() uint8 { return MsgTypeCoinbaseOutputConstraints }
+
+func (m *CoinbaseOutputConstraints) encode(w *writer) {
+	w.u32(m.MaxAdditionalSize)
+	w.u16(m.MaxAdditionalSigops)
+}
+
+func (m *CoinbaseOutputConstraints) decode(r *reader) {
+	m.MaxAdditionalSize = r.u32()
+
+	// Older clients only send the size as CoinbaseOutputDataSize.
+	if len(r.buf) != 0 {
+		m.MaxAdditionalSigops = r.u16()
+	}
+}
+
+// NewTemplate announces a block template.  A future template applies to the
+// previous block announced by the next SetNewPrevHash, while other templates
+// apply to the previous block announced most recently.
+type NewTemplate struct {
+	TemplateID               uint64
+	FutureTemplate           bool
+	Version                  uint32
+	CoinbaseTxVersion        uint32
+	CoinbasePrefix           []byte
+	CoinbaseTxInputSequence  uint32
+	CoinbaseTxValueRemaining uint64
+	CoinbaseTxOutputsCount   uint32
+	CoinbaseTxOutputs        []byte
+	CoinbaseTxLocktime       uint32
+	MerklePath               []chainhash.Hash
+}
+
+// MsgType returns the type of the message.
+func (m *NewTemplate) MsgType() uint8 { return MsgTypeNewTemplate }
+
+func (m *NewTemplate) encode(w *writer) {
+	w.u64(m.TemplateID)
+	w.boolean(m.FutureTemplate)
+	w.u32(m.Version)
+	w.u32(m.CoinbaseTxVersion)
+	w.bytes(m.CoinbasePrefix, 1)
+	w.u32(m.CoinbaseTxInputSequence)
+	w.u64(m.CoinbaseTxValueRemaining)
+	w.u32(m.CoinbaseTxOutputsCount)
+	w.bytes(m.CoinbaseTxOutputs, 2)
+	w.u32(m.CoinbaseTxLocktime)
+	if len(m.MerklePath) > 255 && w.err == nil {
+		w.err = fmt.Errorf("merkle path of %d hashes exceeds the "+
+			"maximum of 255", len(m.MerklePath))
+	}
+	w.seqLen(len(m.MerklePath), 1)
+	for i := range m.MerklePath {
+		w.u256(&m.MerklePath[i])
+	}
+}
+
+func (m *NewTemplate) decode(r *reader) {
+	m.TemplateID = r.u64()
+	m.FutureTemplate = r.boolean()
+	m.Version = r.u32()
+	m.CoinbaseTxVersion = r.u32()
+	m.CoinbasePrefix = r.bytes(1)
+	m.CoinbaseTxInputSequence = r.u32()
+	m.CoinbaseTxValueRemaining = r.u64()
+	m.CoinbaseTxOutputsCount = r.u32()
+	m.CoinbaseTxOutputs = r.bytes(2)
+	m.CoinbaseTxLocktime = r.u32()
+	n := r.seqLen(1)
+	m.MerklePath = make([]chainhash.Hash, 0, n)
+	for i := 0; i < n; i++ {
+		m.MerklePath = append(m.MerklePath, r.u256())
+	}
+}
+
+// SetNewPrevHash announces the previous block the future template with the
+// passed ID applies to, which is the new tip of the chain.
+type SetNewPrevHash struct {
+	TemplateID      uint64
+	PrevHash        chainhash.Hash
+	HeaderTimestamp uint32
+	NBits           uint32
+	Target          chainhash.Hash
+}
+
+// MsgType returns the type of the message.
+func (m *SetNewPrevHash) MsgType() uint8 { return MsgTypeSetNewPrevHash }
+
+func (m *SetNewPrevHash) encode(w *writer) {
+	w.u64(m.TemplateID)
+	w.u256(&m.PrevHash)
+	w.u32(m.HeaderTimestamp)
+	w.u32(m.NBits)
+	w.u256(&m.Target)
+}
+
+func (m *SetNewPrevHash) decode(r *reader) {
+	m.TemplateID = r.u64()
+	m.PrevHash = r.u256()
+	m.HeaderTimestamp = r.u32()
+	m.NBits = r.u32()
+	m.Target = r.u256()
+}
+
+// RequestTransactionData requests the transactions of a template.
+type RequestTransactionData struct {
+	TemplateID uint64
+}
+
+// MsgType returns the type of the message.
+func (m *RequestTransactionData) MsgType() uint8 { return MsgTypeRequestTransactionData }
+
+func (m *RequestTransactionData) encode(w *writer) {
+	w.u64(m.TemplateID)
+}
+
+func (m *RequestTransactionData) decode(r *reader) {
+	m.TemplateID = r.u64()
+}
+
+// RequestTransactionDataSuccess carries the serialized transactions of a
+// template, excluding the coinbase transaction.
+type RequestTransactionDataSuccess struct {
+	TemplateID      uint64
+	ExcessData      []byte
+	TransactionList [][]byte
+}
+
+// MsgType returns the type of the message.
+func (m *RequestTransactionDataSuccess) MsgType() uint8 {
+	return MsgTypeRequestTransactionDataSuccess
+}
+
+func (m *RequestTransactionDataSuccess) encode(w *writer) {
+	w.u64(m.TemplateID)
+	w.bytes(m.ExcessData, 2)
+	if len(m.TransactionList) > 0xffff && w.err == nil {
+		w.err = fmt.Errorf("transaction list of %d transactions "+
+			"exceeds the maximum of 65535", len(m.TransactionList))
+	}
+	w.seqLen(len(m.TransactionList), 2)
+	for _, tx := range m.TransactionList {
+		w.bytes(tx, 3)
+	}
+}
+
+func (m *RequestTransactionDataSuccess) decode(r *reader) {
+	m.TemplateID = r.u64()
+	m.ExcessData = r.bytes(2)
+	n := r.seqLen(2)
+	m.TransactionList = make([][]byte, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		m.TransactionList = append(m.TransactionList, r.bytes(3))
+	}
+}
+
+// RequestTransactionDataError is the response to a RequestTransactionData for
+// a template that's unknown or no longer valid.
+type RequestTransactionDataError struct {
+	TemplateID uint64
+	ErrorCode  string
+}
+
+// MsgType returns the type of the message.
+func (m *RequestTransactionDataError) MsgType() uint8 {
+	return MsgTypeRequestTransactionDataError
+}
+
+func (m *RequestTransactionDataError) encode(w *writer) {
+	w.u64(m.TemplateID)
+	w.str0255(m.ErrorCode)
+}
+
+func (m *RequestTransactionDataError) decode(r *reader) {
+	m.TemplateID = r.u64()
+	m.ErrorCode = r.str0255()
+}
+
+// SubmitSolution submits a solved block built from a template.
+type SubmitSolution struct {
+	TemplateID      uint64
+	Version         uint32
+	HeaderTimestamp uint32
+	HeaderNonce     uint32
+	CoinbaseTx      []byte
+}
+
+// MsgType returns the type of the message.
+func (m *SubmitSolution) MsgType() uint8 { return MsgTypeSubmitSolution }
+
+func (m *SubmitSolution) encode(w *writer) {
+	w.u64(m.TemplateID)
+	w.u32(m.Version)
+	w.u32(m.HeaderTimestamp)
+	w.u32(m.HeaderNonce)
+	w.bytes(m.CoinbaseTx, 2)
+}
+
+func (m *SubmitSolution) decode(r *reader) {
+	m.TemplateID = r.u64()
+	m.Version = r.u32()
+	m.HeaderTimestamp = r.u32()
+	m.HeaderNonce = r.u32()
+	m.CoinbaseTx = r.bytes(2)
+}
+
+// makeEmptyMessage returns a message of the passed type, or nil when the type
+// isn't implemented.
+func makeEmptyMessage(msgType uint8) Message {
+	switch msgType {
+	case MsgTypeSetupConnection:
+		return &SetupConnection{}
+	case MsgTypeSetupConnectionSuccess:
+		return &SetupConnectionSuccess{}
+	case MsgTypeSetupConnectionError:
+		return &SetupConnectionError{}
+	case MsgTypeCoinbaseOutputConstraints:
+		return &CoinbaseOutputConstraints{}
+	case MsgTypeNewTemplate:
+		return &NewTemplate{}
+	case MsgTypeSetNewPrevHash:
+		return &SetNewPrevHash{}
+	case MsgTypeRequestTransactionData:
+		return &RequestTransactionData{}
+	case MsgTypeRequestTransactionDataSuccess:
+		return &RequestTransactionDataSuccess{}
+	case MsgTypeRequestTransactionDataError:
+		return &RequestTransactionDataError{}
+	case MsgTypeSubmitSolution:
+		return &SubmitSolution{}
+	}
+	return nil
+}
+
+// encodeMessage returns the serialized payload of the passed message.
+func encodeMessage(msg Message) ([]byte, error) {
+	var w writer
+	msg.encode(&w)
+	if w.err != nil {
+		return nil, w.err
+	}
+	if len(w.buf) > maxPayloadSize {
+		return nil, fmt.Errorf("payload of %d bytes exceeds the "+
+			"maximum of %d bytes", len(w.buf), maxPayloadSize)
+	}
+	return w.buf, nil
+}
+
+// decodeMessage returns the message of the passed type serialized in the
+// passed payload.  It returns nil without an error when the type isn't
+// implemented.
+func decodeMessage(msgType uint8, payload []byte) (Message, error) {
+	msg := makeEmptyMessage(msgType)
+	if msg == nil {
+		return nil, nil
+	}
+	r := reader{buf: payload}
+	msg.decode(&r)
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed message of type %#02x: %v",
+			msgType, r.err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,540 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sv2
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/ellswift"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/poly1305"
+)
+
+const (
+	// noiseProtocolName is the name of the Noise protocol which secures the
+	// connections.
+	noiseProtocolName = "Noise_NX_Secp256k1+EllSwift_ChaChaPoly_SHA256"
+
+	// macSize is the size of the authentication tag of each encrypted
+	// message.
+	macSize = poly1305.TagSize
+
+	// maxNoiseMessageSize is the maximum size of an encrypted Noise
+	// message.  Payloads of frames are split into chunks which don't
+	// exceed it.
+	maxNoiseMessageSize = 65535
+
+	// maxChunkSize is the maximum size of the plaintext of a chunk.
+	maxChunkSize = maxNoiseMessageSize - macSize
+
+	// certificateSize is the size of a serialized certificate.
+	certificateSize = 2 + 4 + 4 + schnorr.SignatureSize
+
+	// certificateVersion is the version of the certificates.
+	certificateVersion = 0
+
+	// handshakeAct1Size is the size of the first handshake message, which
+	// consists of the ephemeral key of the initiator.
+	handshakeAct1Size = ellswift.Size
+
+	// handshakeAct2Size is the size of the second handshake message, which
+	// consists of the ephemeral key of the responder followed by its
+	// encrypted static key and certificate.
+	handshakeAct2Size = ellswift.Size + ellswift.Size + macSize +
+		certificateSize + macSize
+
+	// publicKeyVersion is the version prefixed to the public keys when they
+	// are encoded to be displayed.
+	publicKeyVersion = 1
+
+	// channelMsgBit is the bit of the extension type which marks messages
+	// addressed to a channel.
+	channelMsgBit = 0x8000
+)
+
+// chacha20Poly1305Tag returns the authentication tag of the ChaCha20-Poly1305
+// AEAD (RFC 8439) for the passed ciphertext and associated data.
+func chacha20Poly1305Tag(polyKey *[32]byte, ciphertext, ad []byte) [macSize]byte {
+	pad := func(n int) int { return (16 - n%16) % 16 }
+	macData := make([]byte, 0, len(ad)+pad(len(ad))+len(ciphertext)+
+		pad(len(ciphertext))+16)
+	macData = append(macData, ad...)
+	macData = append(macData, make([]byte, pad(len(ad)))...)
+	macData = append(macData, ciphertext...)
+	macData = append(macData, make([]byte, pad(len(ciphertext)))...)
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:8], uint64(len(ad)))
+	binary.LittleEndian.PutUint64(lengths[8:16], uint64(len(ciphertext)))
+	macData = append(macData, lengths[:]...)
+
+	var tag [macSize]byte
+	poly1305.Sum(&tag, macData, polyKey)
+	return tag
+}
+
+// cipherState encrypts and decrypts Noise messages with ChaCha20-Poly1305
+// (RFC 8439) using a key and an incrementing nonce.
+type cipherState struct {
+	key   []byte
+	nonce uint64
+}
+
+// newCipherState returns a cipher state using the passed 32-byte key.
+func newCipherState(key []byte) *cipherState {
+	return &cipherState{key: key}
+}
+
+// nextCipher returns the ChaCha20 cipher of the next message along with its
+// one-time Poly1305 key.  The nonce is made up of 32 zero bits followed by the
+// little-endian counter.
+func (c *cipherState) nextCipher() (*chacha20.Cipher, *[32]byte) {
+	var nonce [chacha20.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], c.nonce)
+	c.nonce++
+
+	// The key and nonce sizes are always valid.
+	cipher, _ := chacha20.NewUnauthenticatedCipher(c.key, nonce[:])
+	var polyKey [32]byte
+	cipher.XORKeyStream(polyKey[:], polyKey[:])
+	cipher.SetCounter(1)
+	return cipher, &polyKey
+}
+
+// encrypt returns the passed plaintext encrypted with the passed associated
+// data.
+func (c *cipherState) encrypt(ad, plaintext []byte) []byte {
+	cipher, polyKey := c.nextCipher()
+	ciphertext := make([]byte, len(plaintext), len(plaintext)+macSize)
+	cipher.XORKeyStream(ciphertext, plaintext)
+	tag := chacha20Poly1305Tag(polyKey, ciphertext, ad)
+	return append(ciphertext, tag[:]...)
+}
+
+// decrypt returns the passed ciphertext decrypted with the passed associated
+// data after verifying they are authentic.
+func (c *cipherState) decrypt(ad, ciphertext []byte) ([]byte, error) {
+	cipher, polyKey := c.nextCipher()
+	if len(ciphertext) < macSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	tagOffset := len(ciphertext) - macSize
+	tag := chacha20Poly1305Tag(polyKey, ciphertext[:tagOffset], ad)
+	if subtle.ConstantTimeCompare(tag[:], ciphertext[tagOffset:]) != 1 {
+		return nil, errors.New("message authentication failed")
+	}
+	plaintext := make([]byte, tagOffset)
+	cipher.XORKeyStream(plaintext, ciphertext[:tagOffset])
+	return plaintext, nil
+}
+
+// hkdf returns the two outputs of the HKDF function of the Noise protocol.
+func hkdf(chainingKey, inputKeyMaterial []byte) ([32]byte, [32]byte) {
+	mac := hmac.New(sha256.New, chainingKey)
+	mac.Write(inputKeyMaterial)
+	tempKey := mac.Sum(nil)
+
+	var out1, out2 [32]byte
+	mac = hmac.New(sha256.New, tempKey)
+	mac.Write([]byte{0x01})
+	copy(out1[:], mac.Sum(nil))
+
+	mac = hmac.New(sha256.New, tempKey)
+	mac.Write(out1[:])
+	mac.Write([]byte{0x02})
+	copy(out2[:], mac.Sum(nil))
+	return out1, out2
+}
+
+// symmetricState is the state of the handshake shared by both parties.
+type symmetricState struct {
+	chainingKey [32]byte
+	hash        [32]byte
+	cs          *cipherState
+}
+
+// newSymmetricState returns the initial handshake state of the protocol with
+// an empty prologue.
+func newSymmetricState() *symmetricState {
+	s := &symmetricState{hash: sha256.Sum256([]byte(noiseProtocolName))}
+	s.chainingKey = s.hash
+	s.mixHash(nil)
+	return s
+}
+
+// mixHash mixes the passed data into the handshake hash.
+func (s *symmetricState) mixHash(data []byte) {
+	h := sha256.New()
+	h.Write(s.hash[:])
+	h.Write(data)
+	copy(s.hash[:], h.Sum(nil))
+}
+
+// mixKey mixes the passed key material into the chaining key and derives a new
+// encryption key.
+func (s *symmetricState) mixKey(inputKeyMaterial []byte) {
+	var key [32]byte
+	s.chainingKey, key = hkdf(s.chainingKey[:], inputKeyMaterial)
+	s.cs = newCipherState(key[:])
+}
+
+// encryptAndHash encrypts the passed plaintext with the handshake hash as
+// associated data when a key has been derived and mixes the result into the
+// handshake hash.
+func (s *symmetricState) encryptAndHash(plaintext []byte) []byte {
+	ciphertext := plaintext
+	if s.cs != nil {
+		ciphertext = s.cs.encrypt(s.hash[:], plaintext)
+	}
+	s.mixHash(ciphertext)
+	return ciphertext
+}
+
+// decryptAndHash is the counterpart of encryptAndHash.
+func (s *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext := ciphertext
+	if s.cs != nil {
+		var err error
+		plaintext, err = s.cs.decrypt(s.hash[:], ciphertext)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+// split returns the cipher states of the messages sent by the initiator and by
+// the responder once the handshake is complete.
+func (s *symmetricState) split() (*cipherState, *cipherState) {
+	key1, key2 := hkdf(s.chainingKey[:], nil)
+	return newCipherState(key1[:]), newCipherState(key2[:])
+}
+
+// certificate is the signature noise message which authenticates the static
+// key of the responder by a signature of the authority key.
+type certificate struct {
+	version       uint16
+	validFrom     uint32
+	notValidAfter uint32
+	signature     [schnorr.SignatureSize]byte
+}
+
+// sigHash returns the hash of the certificate for the passed static key which
+// is signed.
+func (c *certificate) sigHash(staticKey *btcec.PublicKey) []byte {
+	var b [2 + 4 + 4]byte
+	binary.LittleEndian.PutUint16(b[0:2], c.version)
+	binary.LittleEndian.PutUint32(b[2:6], c.validFrom)
+	binary.LittleEndian.PutUint32(b[6:10], c.notValidAfter)
+	h := sha256.New()
+	h.Write(b[:])
+	h.Write(schnorr.SerializePubKey(staticKey))
+	return h.Sum(nil)
+}
+
+// newCertificate returns a certificate for the passed static key which is
+// valid in the passed period and signed by the passed authority key.
+func newCertificate(authorityKey *btcec.PrivateKey, staticKey *btcec.PublicKey,
+	validFrom, notValidAfter time.Time) (*certificate, error) {
+
+	c := &certificate{
+		version:       certificateVersion,
+		validFrom:     uint32(validFrom.Unix()),
+		notValidAfter: uint32(notValidAfter.Unix()),
+	}
+	sig, err := schnorr.Sign(authorityKey, c.sigHash(staticKey))
+	if err != nil {
+		return nil, err
+	}
+	copy(c.signature[:], sig.Serialize())
+	return c, nil
+}
+
+// serialize returns the serialized certificate.
+func (c *certificate) serialize() []byte {
+	b := make([]byte, certificateSize)
+	binary.LittleEndian.PutUint16(b[0:2], c.version)
+	binary.LittleEndian.PutUint32(b[2:6], c.validFrom)
+	binary.LittleEndian.PutUint32(b[6:10], c.notValidAfter)
+	copy(b[10:], c.signature[:])
+	return b
+}
+
+// parseCertificate returns the certificate serialized in the passed bytes.
+func parseCertificate(b []byte) (*certificate, error) {
+	if len(b) != certificateSize {
+		return nil, fmt.Errorf("certificate of %d bytes, want %d",
+			len(b), certificateSize)
+	}
+	c := &certificate{
+		version:       binary.LittleEndian.Uint16(b[0:2]),
+		validFrom:     binary.LittleEndian.Uint32(b[2:6]),
+		notValidAfter: binary.LittleEndian.Uint32(b[6:10]),
+	}
+	copy(c.signature[:], b[10:])
+	return c, nil
+}
+
+// verify returns an error unless the certificate is valid at the passed time
+// and authenticates the passed static key by a signature of the passed
+// authority key.
+func (c *certificate) verify(authorityKey, staticKey *btcec.PublicKey,
+	now time.Time) error {
+
+	if c.version != certificateVersion {
+		return fmt.Errorf("unsupported certificate version %d",
+			c.version)
+	}
+	unix := now.Unix()
+	if unix < int64(c.validFrom) || unix > int64(c.notValidAfter) {
+		return errors.New("certificate is not valid at this time")
+	}
+	sig, err := schnorr.ParseSignature(c.signature[:])
+	if err != nil {
+		return err
+	}
+	if !sig.Verify(c.sigHash(staticKey), authorityKey) {
+		return errors.New("invalid certificate signature")
+	}
+	return nil
+}
+
+// EncodePublicKey returns the passed public key encoded as it's displayed to
+// users of Stratum V2, which is the base58check encoding of its X coordinate
+// prefixed with a 2-byte version.
+func EncodePublicKey(pubKey *btcec.PublicKey) string {
+	b := append([]byte{0}, schnorr.SerializePubKey(pubKey)...)
+	return base58.CheckEncode(b, publicKeyVersion)
+}
+
+// transport reads and writes the frames of a connection secured by a
+// completed handshake.
+type transport struct {
+	rw   io.ReadWriter
+	send *cipherState
+	recv *cipherState
+}
+
+// respondHandshake performs the handshake over the passed connection as the
+// responder, authenticating with the passed static key and certificate.
+func respondHandshake(rw io.ReadWriter, staticKey *btcec.PrivateKey,
+	cert *certificate) (*transport, error) {
+
+	staticEll, err := ellswift.Encode(staticKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+	ephemeralKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	ephemeralEll, err := ellswift.Encode(ephemeralKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+
+	// -> e
+	s := newSymmetricState()
+	var remoteEphemeral [handshakeAct1Size]byte
+	if _, err := io.ReadFull(rw, remoteEphemeral[:]); err != nil {
+		return nil, err
+	}
+	s.mixHash(remoteEphemeral[:])
+	s.mixHash(nil)
+
+	// <- e, ee, s, es, SIGNATURE_NOISE_MESSAGE
+	s.mixHash(ephemeralEll[:])
+	secret, err := ellswift.XDH(ephemeralKey, ephemeralEll[:],
+		remoteEphemeral[:], false)
+	if err != nil {
+		return nil, err
+	}
+	s.mixKey(secret[:])
+	encStatic := s.encryptAndHash(staticEll[:])
+	secret, err = ellswift.XDH(staticKey, staticEll[:], remoteEphemeral[:],
+		false)
+	if err != nil {
+		return nil, err
+	}
+	s.mixKey(secret[:])
+	encCert := s.encryptAndHash(cert.serialize())
+
+	act2 := make([]byte, 0, handshakeAct2Size)
+	act2 = append(act2, ephemeralEll[:]...)
+	act2 = append(act2, encStatic...)
+	act2 = append(act2, encCert...)
+	if _, err := rw.Write(act2); err != nil {
+		return nil, err
+	}
+
+	recv, send := s.split()
+	return &transport{rw: rw, send: send, recv: recv}, nil
+}
+
+// initiateHandshake performs the handshake over the passed connection as the
+// initiator.  The certificate of the responder is verified when an authority
+// key is passed.  It returns the static key of the responder along with the
+// transport.
+func initiateHandshake(rw io.ReadWriter, authorityKey *btcec.PublicKey) (*transport,
+	*btcec.PublicKey, error) {
+
+	ephemeralKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	ephemeralEll, err := ellswift.Encode(ephemeralKey.PubKey())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// -> e
+	s := newSymmetricState()
+	s.mixHash(ephemeralEll[:])
+	s.mixHash(nil)
+	if _, err := rw.Write(ephemeralEll[:]); err != nil {
+		return nil, nil, err
+	}
+
+	// <- e, ee, s, es, SIGNATURE_NOISE_MESSAGE
+	var act2 [handshakeAct2Size]byte
+	if _, err := io.ReadFull(rw, act2[:]); err != nil {
+		return nil, nil, err
+	}
+	remoteEphemeral := act2[:ellswift.Size]
+	s.mixHash(remoteEphemeral)
+	secret, err := ellswift.XDH(ephemeralKey, ephemeralEll[:],
+		remoteEphemeral, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mixKey(secret[:])
+	staticEll, err := s.decryptAndHash(act2[ellswift.Size : 2*ellswift.Size+macSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	secret, err = ellswift.XDH(ephemeralKey, ephemeralEll[:], staticEll,
+		true)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mixKey(secret[:])
+	certBytes, err := s.decryptAndHash(act2[2*ellswift.Size+macSize:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	staticKey, err := ellswift.Decode(staticEll)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authorityKey != nil {
+		cert, err := parseCertificate(certBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = cert.verify(authorityKey, staticKey, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	send, recv := s.split()
+	return &transport{rw: rw, send: send, recv: recv}, staticKey, nil
+}
+
+// encryptedPayloadSize returns the size of an encrypted payload of the passed
+// plaintext size.
+func encryptedPayloadSize(n int) int {
+	chunks := (n + maxChunkSize - 1) / maxChunkSize
+	return n + chunks*macSize
+}
+
+// writeFrame writes a frame of the passed type carrying the passed payload.
+func (t *transport) writeFrame(msgType uint8, payload []byte) error {
+	header := encodeFrameHeader(frameHeader{
+		msgType: msgType,
+		length:  uint32(len(payload)),
+	})
+	frame := make([]byte, 0, frameHeaderSize+macSize+
+		encryptedPayloadSize(len(payload)))
+	frame = append(frame, t.send.encrypt(nil, header[:])...)
+	for len(payload) > 0 {
+		n := len(payload)
+		if n > maxChunkSize {
+			n = maxChunkSize
+		}
+		frame = append(frame, t.send.encrypt(nil, payload[:n])...)
+		payload = payload[n:]
+	}
+	_, err := t.rw.Write(frame)
+	return err
+}
+
+// readFrame reads the next frame and returns its header along with its
+// decrypted payload.
+func (t *transport) readFrame() (frameHeader, []byte, error) {
+	var encHeader [frameHeaderSize + macSize]byte
+	if _, err := io.ReadFull(t.rw, encHeader[:]); err != nil {
+		return frameHeader{}, nil, err
+	}
+	headerBytes, err := t.recv.decrypt(nil, encHeader[:])
+	if err != nil {
+		return frameHeader{}, nil, err
+	}
+	header := decodeFrameHeader(headerBytes)
+
+	encPayload := make([]byte, encryptedPayloadSize(int(header.length)))
+	if _, err := io.ReadFull(t.rw, encPayload); err != nil {
+		return frameHeader{}, nil, err
+	}
+	payload := make([]byte, 0, header.length)
+	for len(encPayload) > 0 {
+		n := len(encPayload)
+		if n > maxNoiseMessageSize {
+			n = maxNoiseMessageSize
+		}
+		chunk, err := t.recv.decrypt(nil, encPayload[:n])
+		if err != nil {
+			return frameHeader{}, nil, err
+		}
+		payload = append(payload, chunk...)
+		encPayload = encPayload[n:]
+	}
+	return header, payload, nil
+}
+
+// writeMessage writes a frame carrying the passed message.
+func (t *transport) writeMessage(msg Message) error {
+	payload, err := encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	return t.writeFrame(msg.MsgType(), payload)
+}
+
+// readMessage reads the next frame and returns the message it carries.  The
+// message is nil when the frame is an extension message or its type isn't
+// implemented.
+func (t *transport) readMessage() (Message, frameHeader, error) {
+	header, payload, err := t.readFrame()
+	if err != nil {
+		return nil, header, err
+	}
+	if header.extensionType&^channelMsgBit != 0 {
+		return nil, header, nil
+	}
+	msg, err := decodeMessage(header.msgType, payload)
+	return msg, header, err
+}
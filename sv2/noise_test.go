@@ -0,0 +1,119 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sv2
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// TestHandshake ensures both sides of a handshake derive the same keys, the
+// certificate of the responder is verified and frames of any size can be sent
+// over the resulting transport.
+func TestHandshake(t *testing.T) {
+	t.Parallel()
+
+	staticKey, _ := btcec.NewPrivateKey()
+	authorityKey, _ := btcec.NewPrivateKey()
+	otherKey, _ := btcec.NewPrivateKey()
+	now := time.Now()
+	cert, err := newCertificate(authorityKey, staticKey.PubKey(),
+		now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("newCertificate: unexpected error: %v", err)
+	}
+
+	handshake := func(authority *btcec.PublicKey) (*transport, *transport, error) {
+		c1, c2 := net.Pipe()
+		t.Cleanup(func() {
+			c1.Close()
+			c2.Close()
+		})
+		respErr := make(chan error, 1)
+		var responder *transport
+		go func() {
+			var err error
+			responder, err = respondHandshake(c2, staticKey, cert)
+			respErr <- err
+			if err != nil {
+				c2.Close()
+			}
+		}()
+		initiator, remoteKey, err := initiateHandshake(c1, authority)
+		if err != nil {
+			c1.Close()
+			<-respErr
+			return nil, nil, err
+		}
+		if err := <-respErr; err != nil {
+			return nil, nil, err
+		}
+		// Only the X coordinate of the static key is transmitted.
+		if remoteKey.X().Cmp(staticKey.PubKey().X()) != 0 {
+			t.Fatalf("initiateHandshake: got static key %x, want %x",
+				remoteKey.SerializeCompressed(),
+				staticKey.PubKey().SerializeCompressed())
+		}
+		return initiator, responder, nil
+	}
+
+	if _, _, err := handshake(otherKey.PubKey()); err == nil {
+		t.Fatal("handshake: expected error for untrusted authority")
+	}
+	initiator, responder, err := handshake(authorityKey.PubKey())
+	if err != nil {
+		t.Fatalf("handshake: unexpected error: %v", err)
+	}
+
+	// Send payloads which fit in a single chunk and which span several
+	// chunks in both directions.
+	for _, size := range []int{0, 10, maxChunkSize, maxChunkSize + 1,
+		3*maxChunkSize + 100} {
+
+		payload := bytes.Repeat([]byte{byte(size)}, size)
+		for _, dir := range [][2]*transport{{initiator, responder},
+			{responder, initiator}} {
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- dir[0].writeFrame(0x42, payload)
+			}()
+			header, got, err := dir[1].readFrame()
+			if err != nil {
+				t.Fatalf("readFrame(%d): unexpected error: %v",
+					size, err)
+			}
+			if err := <-errChan; err != nil {
+				t.Fatalf("writeFrame(%d): unexpected error: %v",
+					size, err)
+			}
+			if header.msgType != 0x42 ||
+				header.length != uint32(size) ||
+				!bytes.Equal(got, payload) {
+
+				t.Fatalf("readFrame(%d): got type %#x and %d "+
+					"bytes", size, header.msgType, len(got))
+			}
+		}
+	}
+
+	// Certificates outside of their validity period are rejected.
+	if err := cert.verify(authorityKey.PubKey(), staticKey.PubKey(),
+		now.Add(2*time.Hour)); err == nil {
+
+		t.Fatal("verify: expected error for expired certificate")
+	}
+	parsed, err := parseCertificate(cert.serialize())
+	if err != nil {
+		t.Fatalf("parseCertificate: unexpected error: %v", err)
+	}
+	if *parsed != *cert {
+		t.Fatalf("parseCertificate: got %+v, want %+v", parsed, cert)
+	}
+}
@@ -0,0 +1,740 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sv2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// ProtocolVersion is the version of the template distribution protocol
+	// which is implemented.
+	ProtocolVersion = 2
+
+	// DefaultInterval is the default minimum interval between templates
+	// sent because the memory pool changed.
+	DefaultInterval = 30 * time.Second
+
+	// DefaultFeeDelta is the default minimum increase of the fees of a
+	// template in satoshi for a new template to be sent because the memory
+	// pool changed.
+	DefaultFeeDelta = 1000
+
+	// handshakeTimeout is the time a client has to complete the handshake
+	// and set up the connection.
+	handshakeTimeout = 10 * time.Second
+
+	// writeTimeout is the time a client has to receive a message before it
+	// is disconnected.
+	writeTimeout = 30 * time.Second
+
+	// certificateValidity is the validity period of the certificates which
+	// are issued to clients during the handshake.
+	certificateValidity = 24 * time.Hour
+
+	// maxTemplates is the maximum number of templates kept for each client
+	// to accept solutions for.
+	maxTemplates = 100
+)
+
+// Config is the configuration of a Server.
+type Config struct {
+	// Listeners defines a slice of listeners on which clients are accepted.
+	Listeners []net.Listener
+
+	// StaticKey is the static key which secures the connections.
+	StaticKey *btcec.PrivateKey
+
+	// AuthorityKey signs the certificates of the static key which clients
+	// verify during the handshake.
+	AuthorityKey *btcec.PrivateKey
+
+	// NewTemplate returns a new block template extending the current tip
+	// of the main chain.  Its coinbase transaction is replaced by the
+	// clients.
+	NewTemplate func() (*mining.BlockTemplate, error)
+
+	// SubmitBlock processes a block solved by a client.
+	SubmitBlock func(block *btcutil.Block) error
+
+	// IsCurrent returns whether the chain is believed to be synced.  No
+	// templates are sent while it isn't.
+	IsCurrent func() bool
+
+	// LastTxUpdate returns the last time a transaction was added to or
+	// removed from the memory pool.
+	LastTxUpdate func() time.Time
+
+	// Interval is the minimum interval between templates sent because the
+	// memory pool changed.  DefaultInterval is used when it is zero.
+	Interval time.Duration
+
+	// FeeDelta is the minimum increase of the fees of a template in
+	// satoshi for a new template to be sent because the memory pool
+	// changed.
+	FeeDelta int64
+}
+
+// template houses a block template sent to a client.  Its coinbase transaction
+// only carries the outputs required by the node.
+type template struct {
+	block *wire.MsgBlock
+}
+
+// client houses the state of a connected client.
+type client struct {
+	conn      net.Conn
+	transport *transport
+
+	// mtx protects the fields below and serializes writes to the client.
+	mtx         sync.Mutex
+	constraints *CoinbaseOutputConstraints
+	templates   map[uint64]*template
+	prevHash    *chainhash.Hash
+}
+
+// Server serves block templates to Stratum V2 clients, such as pools and job
+// declarators, over the template distribution protocol.
+type Server struct {
+	cfg      Config
+	started  int32
+	shutdown int32
+
+	// nextTemplateID is the ID of the next template sent to any client.
+	// It must be accessed atomically.
+	nextTemplateID uint64
+
+	mtx     sync.Mutex
+	clients map[*client]struct{}
+
+	// The fields below are only accessed by the template handler.
+	current      *mining.BlockTemplate
+	lastTxUpdate time.Time
+
+	blockConnected chan struct{}
+	constraintsSet chan *client
+	wg             sync.WaitGroup
+	quit           chan struct{}
+}
+
+// New returns a new template distribution server with the passed
+// configuration.
+func New(cfg *Config) *Server {
+	s := &Server{
+		cfg:            *cfg,
+		nextTemplateID: 1,
+		clients:        make(map[*client]struct{}),
+		blockConnected: make(chan struct{}, 1),
+		constraintsSet: make(chan *client),
+		quit:           make(chan struct{}),
+	}
+	if s.cfg.Interval <= 0 {
+		s.cfg.Interval = DefaultInterval
+	}
+	return s
+}
+
+// Start begins accepting clients on the configured listeners.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	log.Infof("Stratum V2 authority public key: %s",
+		EncodePublicKey(s.cfg.AuthorityKey.PubKey()))
+	for _, listener := range s.cfg.Listeners {
+		log.Infof("Stratum V2 template provider listening on %s",
+			listener.Addr())
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+
+	s.wg.Add(1)
+	go s.templateHandler()
+}
+
+// Stop closes the listeners, disconnects the clients and waits for the server
+// to finish.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		return
+	}
+
+	log.Infof("Stratum V2 template provider shutting down")
+	close(s.quit)
+	for _, listener := range s.cfg.Listeners {
+		listener.Close()
+	}
+	s.mtx.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.mtx.Unlock()
+	s.wg.Wait()
+}
+
+// NotifyBlockConnected lets the server know that a block was connected to the
+// main chain, so new templates are sent to the clients.
+//
+// This function is safe for concurrent access.
+func (s *Server) NotifyBlockConnected() {
+	select {
+	case s.blockConnected <- struct{}{}:
+	default:
+	}
+}
+
+// listenHandler accepts the clients connecting to the passed listener.
+//
+// This function MUST be run as a goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+			default:
+				log.Errorf("Can't accept Stratum V2 connection on "+
+					"%s: %v", listener.Addr(), err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go s.clientHandler(conn)
+	}
+}
+
+// setupClient performs the handshake with the client connected over the passed
+// connection and handles its SetupConnection message.
+func (s *Server) setupClient(conn net.Conn) (*client, error) {
+	now := time.Now()
+	cert, err := newCertificate(s.cfg.AuthorityKey, s.cfg.StaticKey.PubKey(),
+		now.Add(-time.Hour), now.Add(certificateValidity))
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(now.Add(handshakeTimeout))
+	t, err := respondHandshake(conn, s.cfg.StaticKey, cert)
+	if err != nil {
+		return nil, fmt.Errorf("handshake failed: %v", err)
+	}
+	msg, _, err := t.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	setup, ok := msg.(*SetupConnection)
+	if !ok {
+		return nil, errors.New("connection wasn't set up")
+	}
+
+	var reject *SetupConnectionError
+	switch {
+	case setup.Protocol != ProtocolTemplateDistribution:
+		reject = &SetupConnectionError{ErrorCode: ErrUnsupportedProtocol}
+	case setup.MinVersion > ProtocolVersion ||
+		setup.MaxVersion < ProtocolVersion:
+		reject = &SetupConnectionError{
+			ErrorCode: ErrProtocolVersionMismatch,
+		}
+	case setup.Flags != 0:
+		// The template distribution protocol doesn't define any
+		// flags.
+		reject = &SetupConnectionError{
+			Flags:     setup.Flags,
+			ErrorCode: ErrUnsupportedFeatureFlags,
+		}
+	}
+	if reject != nil {
+		t.writeMessage(reject)
+		return nil, fmt.Errorf("connection rejected: %s",
+			reject.ErrorCode)
+	}
+	err = t.writeMessage(&SetupConnectionSuccess{
+		UsedVersion: ProtocolVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	log.Infof("Stratum V2 client %s connected (vendor %q, firmware %q)",
+		conn.RemoteAddr(), setup.Vendor, setup.Firmware)
+	return &client{
+		conn:      conn,
+		transport: t,
+		templates: make(map[uint64]*template),
+	}, nil
+}
+
+// clientHandler handles the messages of the client connected over the passed
+// connection until it disconnects.
+//
+// This function MUST be run as a goroutine.
+func (s *Server) clientHandler(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	c, err := s.setupClient(conn)
+	if err != nil {
+		log.Debugf("Stratum V2 client %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	s.mtx.Lock()
+	select {
+	case <-s.quit:
+		s.mtx.Unlock()
+		return
+	default:
+	}
+	s.clients[c] = struct{}{}
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		delete(s.clients, c)
+		s.mtx.Unlock()
+		log.Infof("Stratum V2 client %s disconnected", conn.RemoteAddr())
+	}()
+
+	for {
+		msg, header, err := c.transport.readMessage()
+		if err != nil {
+			log.Debugf("Stratum V2 client %s: %v", conn.RemoteAddr(),
+				err)
+			return
+		}
+
+		switch m := msg.(type) {
+		case *CoinbaseOutputConstraints:
+			c.mtx.Lock()
+			c.constraints = m
+			c.mtx.Unlock()
+			select {
+			case s.constraintsSet <- c:
+			case <-s.quit:
+				return
+			}
+
+		case *RequestTransactionData:
+			err = s.handleRequestTransactionData(c, m)
+
+		case *SubmitSolution:
+			s.handleSubmitSolution(c, m)
+
+		default:
+			log.Debugf("Ignoring Stratum V2 message of type %#02x "+
+				"(extension %#04x) from %s", header.msgType,
+				header.extensionType, conn.RemoteAddr())
+		}
+		if err != nil {
+			log.Debugf("Stratum V2 client %s: %v", conn.RemoteAddr(),
+				err)
+			return
+		}
+	}
+}
+
+// handleRequestTransactionData sends the transactions of the requested
+// template to the client.
+func (s *Server) handleRequestTransactionData(c *client, m *RequestTransactionData) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	tmpl, ok := c.templates[m.TemplateID]
+	if !ok {
+		return c.write(&RequestTransactionDataError{
+			TemplateID: m.TemplateID,
+			ErrorCode:  ErrTemplateIDNotFound,
+		})
+	}
+	if c.prevHash == nil || tmpl.block.Header.PrevBlock != *c.prevHash {
+		return c.write(&RequestTransactionDataError{
+			TemplateID: m.TemplateID,
+			ErrorCode:  ErrStaleTemplateID,
+		})
+	}
+
+	txns := tmpl.block.Transactions[1:]
+	resp := &RequestTransactionDataSuccess{
+		TemplateID:      m.TemplateID,
+		TransactionList: make([][]byte, 0, len(txns)),
+	}
+	for _, tx := range txns {
+		var buf bytes.Buffer
+		buf.Grow(tx.SerializeSize())
+		if err := tx.Serialize(&buf); err != nil {
+			return err
+		}
+		resp.TransactionList = append(resp.TransactionList, buf.Bytes())
+	}
+	return c.write(resp)
+}
+
+// handleSubmitSolution assembles the block solved by the client from the
+// template and the submitted coinbase transaction and processes it.
+func (s *Server) handleSubmitSolution(c *client, m *SubmitSolution) {
+	c.mtx.Lock()
+	tmpl, ok := c.templates[m.TemplateID]
+	c.mtx.Unlock()
+	if !ok {
+		log.Warnf("Stratum V2 client %s submitted a solution for "+
+			"unknown template %d", c.conn.RemoteAddr(), m.TemplateID)
+		return
+	}
+
+	var coinbase wire.MsgTx
+	if err := coinbase.Deserialize(bytes.NewReader(m.CoinbaseTx)); err != nil {
+		log.Warnf("Stratum V2 client %s submitted a malformed "+
+			"coinbase transaction: %v", c.conn.RemoteAddr(), err)
+		return
+	}
+
+	msgBlock := &wire.MsgBlock{
+		Header:       tmpl.block.Header,
+		Transactions: make([]*wire.MsgTx, 0, len(tmpl.block.Transactions)),
+	}
+	msgBlock.Transactions = append(msgBlock.Transactions, &coinbase)
+	msgBlock.Transactions = append(msgBlock.Transactions,
+		tmpl.block.Transactions[1:]...)
+	msgBlock.Header.Version = int32(m.Version)
+	msgBlock.Header.Timestamp = time.Unix(int64(m.HeaderTimestamp), 0)
+	msgBlock.Header.Nonce = m.HeaderNonce
+	merkleRoot := blockchain.CalcMerkleRoot(
+		btcutil.NewBlock(msgBlock).Transactions(), false)
+	msgBlock.Header.MerkleRoot = merkleRoot
+
+	block := btcutil.NewBlock(msgBlock)
+	if err := s.cfg.SubmitBlock(block); err != nil {
+		log.Warnf("Block %v submitted by Stratum V2 client %s was "+
+			"rejected: %v", block.Hash(), c.conn.RemoteAddr(), err)
+		return
+	}
+	log.Infof("Block %v submitted by Stratum V2 client %s was accepted",
+		block.Hash(), c.conn.RemoteAddr())
+}
+
+// write sends the passed message to the client.
+//
+// This function MUST be called with the client lock held.
+func (c *client) write(msg Message) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.transport.writeMessage(msg)
+}
+
+// templateHandler sends templates to the clients when a block is connected,
+// when the memory pool changes and when a client sets its coinbase output
+// constraints.
+//
+// This function MUST be run as a goroutine.
+func (s *Server) templateHandler() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.blockConnected:
+			s.updateTemplate(true)
+
+		case <-ticker.C:
+			s.updateTemplate(false)
+
+		case c := <-s.constraintsSet:
+			if s.current == nil && !s.generateTemplate() {
+				continue
+			}
+			s.sendTemplate(c, s.current)
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// generateTemplate generates a new current template.  It returns false when
+// no template can be generated because the chain isn't synced or an error
+// occurred.
+//
+// This function MUST only be called from the template handler.
+func (s *Server) generateTemplate() bool {
+	if !s.cfg.IsCurrent() {
+		return false
+	}
+	lastTxUpdate := s.cfg.LastTxUpdate()
+	tmpl, err := s.cfg.NewTemplate()
+	if err != nil {
+		log.Errorf("Failed to create new block template: %v", err)
+		return false
+	}
+	s.current = tmpl
+	s.lastTxUpdate = lastTxUpdate
+	return true
+}
+
+// templateFees returns the total fees of the transactions in the passed
+// template.
+func templateFees(tmpl *mining.BlockTemplate) int64 {
+	return -tmpl.Fees[0]
+}
+
+// updateTemplate sends a new template to the clients when a block was
+// connected, or when the memory pool changed and the fees of the template
+// increased by at least the configured delta.
+//
+// This function MUST only be called from the template handler.
+func (s *Server) updateTemplate(newTip bool) {
+	s.mtx.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mtx.Unlock()
+
+	// Templates are generated on demand when there are no clients.
+	if len(clients) == 0 {
+		s.current = nil
+		return
+	}
+
+	prev := s.current
+	if !newTip && prev != nil && s.cfg.LastTxUpdate().Equal(s.lastTxUpdate) {
+		return
+	}
+	if !s.generateTemplate() {
+		return
+	}
+	if prev != nil && prev.Block.Header.PrevBlock ==
+		s.current.Block.Header.PrevBlock &&
+		templateFees(s.current) < templateFees(prev)+s.cfg.FeeDelta {
+
+		s.current = prev
+		return
+	}
+
+	for _, c := range clients {
+		s.sendTemplate(c, s.current)
+	}
+}
+
+// sendTemplate sends the passed template to the client once it has set its
+// coinbase output constraints.  The template is a future template followed by
+// SetNewPrevHash when it extends a different block than the previous template
+// sent to the client.
+//
+// This function MUST only be called from the template handler.
+func (s *Server) sendTemplate(c *client, tmpl *mining.BlockTemplate) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.constraints == nil {
+		return
+	}
+	block := adaptTemplate(tmpl, c.constraints)
+	prevHash := block.Header.PrevBlock
+	future := c.prevHash == nil || *c.prevHash != prevHash
+	id := atomic.AddUint64(&s.nextTemplateID, 1) - 1
+
+	msg, err := newTemplateMessage(id, future, tmpl.Height, block)
+	if err == nil {
+		err = c.write(msg)
+	}
+	if err == nil && future {
+		err = c.write(&SetNewPrevHash{
+			TemplateID:      id,
+			PrevHash:        prevHash,
+			HeaderTimestamp: uint32(block.Header.Timestamp.Unix()),
+			NBits:           block.Header.Bits,
+			Target:          targetFromBits(block.Header.Bits),
+		})
+	}
+	if err != nil {
+		log.Debugf("Stratum V2 client %s: %v", c.conn.RemoteAddr(), err)
+		c.conn.Close()
+		return
+	}
+	log.Debugf("Sent template %d (height %d, %d transactions) to Stratum "+
+		"V2 client %s", id, tmpl.Height, len(block.Transactions)-1,
+		c.conn.RemoteAddr())
+
+	// Solutions for the templates extending the previous tip are still
+	// accepted in case a client solves one before it learns about the new
+	// tip, while older templates are dropped.
+	if future {
+		for id, t := range c.templates {
+			if c.prevHash == nil || t.block.Header.PrevBlock != *c.prevHash {
+				delete(c.templates, id)
+			}
+		}
+		c.prevHash = &prevHash
+	}
+	c.templates[id] = &template{block: block}
+	for len(c.templates) > maxTemplates {
+		oldest := id
+		for id := range c.templates {
+			if id < oldest {
+				oldest = id
+			}
+		}
+		delete(c.templates, oldest)
+	}
+}
+
+// isWitnessCommitment returns whether the passed script is the script of a
+// witness commitment output.
+func isWitnessCommitment(pkScript []byte) bool {
+	return len(pkScript) == len(blockchain.WitnessMagicBytes)+
+		chainhash.HashSize &&
+		bytes.HasPrefix(pkScript, blockchain.WitnessMagicBytes)
+}
+
+// adaptTemplate returns the block of the passed template with enough space
+// left for the additional coinbase outputs of the passed constraints.  The
+// transactions which don't fit are removed from the end of the block, which
+// never leaves a transaction without its parents, and the coinbase value and
+// witness commitment are updated accordingly.
+func adaptTemplate(tmpl *mining.BlockTemplate,
+	constraints *CoinbaseOutputConstraints) *wire.MsgBlock {
+
+	txns := tmpl.Block.Transactions
+	weight := blockchain.GetBlockWeight(btcutil.NewBlock(tmpl.Block))
+	var sigOpCost int64
+	for _, cost := range tmpl.SigOpCosts {
+		sigOpCost += cost
+	}
+	extraWeight := int64(constraints.MaxAdditionalSize) *
+		blockchain.WitnessScaleFactor
+	extraSigOpCost := int64(constraints.MaxAdditionalSigops) *
+		blockchain.WitnessScaleFactor
+
+	n := len(txns)
+	var droppedFees int64
+	for n > 1 && (weight+extraWeight > blockchain.MaxBlockWeight ||
+		sigOpCost+extraSigOpCost > blockchain.MaxBlockSigOpsCost) {
+
+		n--
+		weight -= blockchain.GetTransactionWeight(btcutil.NewTx(txns[n]))
+		sigOpCost -= tmpl.SigOpCosts[n]
+		droppedFees += tmpl.Fees[n]
+	}
+
+	coinbase := txns[0].Copy()
+	block := &wire.MsgBlock{
+		Header:       tmpl.Block.Header,
+		Transactions: make([]*wire.MsgTx, 0, n),
+	}
+	block.Transactions = append(block.Transactions, coinbase)
+	block.Transactions = append(block.Transactions, txns[1:n]...)
+	if n == len(txns) {
+		return block
+	}
+
+	log.Debugf("Removed %d transactions (%d in fees) from the template to "+
+		"satisfy the coinbase output constraints", len(txns)-n,
+		droppedFees)
+	coinbase.TxOut[0].Value -= droppedFees
+	if tmpl.WitnessCommitment != nil {
+		outputs := make([]*wire.TxOut, 0, len(coinbase.TxOut))
+		for _, out := range coinbase.TxOut {
+			if !isWitnessCommitment(out.PkScript) {
+				outputs = append(outputs, out)
+			}
+		}
+		coinbase.TxOut = outputs
+		mining.AddWitnessCommitment(btcutil.NewTx(coinbase),
+			btcutil.NewBlock(block).Transactions())
+	}
+	return block
+}
+
+// merklePath returns the hashes needed to calculate the merkle root of a block
+// containing the passed transactions after its coinbase transaction from the
+// hash of the coinbase transaction.
+func merklePath(txns []*wire.MsgTx) []chainhash.Hash {
+	// The hash of the coinbase transaction is never used since the path
+	// only consists of hashes of the right branches.
+	level := make([]chainhash.Hash, len(txns)+1)
+	for i, tx := range txns {
+		level[i+1] = tx.TxHash()
+	}
+
+	var path []chainhash.Hash
+	for len(level) > 1 {
+		path = append(path, level[1])
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([]chainhash.Hash, len(level)/2)
+		for i := range next {
+			next[i] = blockchain.HashMerkleBranches(&level[2*i],
+				&level[2*i+1])
+		}
+		level = next
+	}
+	return path
+}
+
+// targetFromBits returns the target encoded in the passed compact
+// representation as a little-endian 256-bit integer.
+func targetFromBits(bits uint32) chainhash.Hash {
+	var target chainhash.Hash
+	blockchain.CompactToBig(bits).FillBytes(target[:])
+	for i := 0; i < chainhash.HashSize/2; i++ {
+		j := chainhash.HashSize - 1 - i
+		target[i], target[j] = target[j], target[i]
+	}
+	return target
+}
+
+// newTemplateMessage returns the NewTemplate message of the passed block at the
+// passed height.  The first output of its coinbase transaction is replaced by
+// the outputs of the client, so it's only used for its value.
+func newTemplateMessage(id uint64, future bool, height int32,
+	block *wire.MsgBlock) (*NewTemplate, error) {
+
+	coinbase := block.Transactions[0]
+	prefix, err := txscript.NewScriptBuilder().AddInt64(int64(height)).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	var outputs bytes.Buffer
+	for _, out := range coinbase.TxOut[1:] {
+		err := wire.WriteTxOut(&outputs, 0, coinbase.Version, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &NewTemplate{
+		TemplateID:               id,
+		FutureTemplate:           future,
+		Version:                  uint32(block.Header.Version),
+		CoinbaseTxVersion:        uint32(coinbase.Version),
+		CoinbasePrefix:           prefix,
+		CoinbaseTxInputSequence:  coinbase.TxIn[0].Sequence,
+		CoinbaseTxValueRemaining: uint64(coinbase.TxOut[0].Value),
+		CoinbaseTxOutputsCount:   uint32(len(coinbase.TxOut) - 1),
+		CoinbaseTxOutputs:        outputs.Bytes(),
+		CoinbaseTxLocktime:       coinbase.LockTime,
+		MerklePath:               merklePath(block.Transactions[1:]),
+	}, nil
+}
@@ -0,0 +1,407 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package sv2
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testTemplate returns a block template extending the passed block with the
+// passed number of transactions, each paying a fee of 1000 satoshi and costing
+// 400 signature operations.
+func testTemplate(prevHash chainhash.Hash, numTxns int) *mining.BlockTemplate {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x51, 0x00},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: prevHash,
+			Timestamp: time.Unix(1700000000, 0),
+			Bits:      0x207fffff,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+	fees := []int64{0}
+	sigOpCosts := []int64{0}
+	for i := 0; i < numTxns; i++ {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: uint32(i)},
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(wire.NewTxOut(1000, bytes.Repeat([]byte{0x6a}, 1000)))
+		block.Transactions = append(block.Transactions, tx)
+		fees = append(fees, 1000)
+		fees[0] -= 1000
+		sigOpCosts = append(sigOpCosts, 400)
+		coinbase.TxOut[0].Value += 1000
+	}
+	witnessCommitment := mining.AddWitnessCommitment(btcutil.NewTx(coinbase),
+		btcutil.NewBlock(block).Transactions())
+
+	return &mining.BlockTemplate{
+		Block:             block,
+		Fees:              fees,
+		SigOpCosts:        sigOpCosts,
+		Height:            100,
+		WitnessCommitment: witnessCommitment,
+	}
+}
+
+// testNode provides the templates of a Server and records the blocks submitted
+// to it.
+type testNode struct {
+	mtx          sync.Mutex
+	template     *mining.BlockTemplate
+	lastTxUpdate time.Time
+	submitted    chan *btcutil.Block
+}
+
+func (n *testNode) setTemplate(tmpl *mining.BlockTemplate) {
+	n.mtx.Lock()
+	n.template = tmpl
+	n.lastTxUpdate = time.Now()
+	n.mtx.Unlock()
+}
+
+func (n *testNode) newTemplate() (*mining.BlockTemplate, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.template, nil
+}
+
+func (n *testNode) txUpdate() time.Time {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.lastTxUpdate
+}
+
+// testClient connects to the passed server address, completes the handshake
+// and sets up the connection for the template distribution protocol.
+func testClient(t *testing.T, addr string, authority *btcec.PublicKey) *transport {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	tr, _, err := initiateHandshake(conn, authority)
+	if err != nil {
+		t.Fatalf("initiateHandshake: unexpected error: %v", err)
+	}
+	err = tr.writeMessage(&SetupConnection{
+		Protocol:   ProtocolTemplateDistribution,
+		MinVersion: ProtocolVersion,
+		MaxVersion: ProtocolVersion,
+		Vendor:     "test",
+	})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	msg, _, err := tr.readMessage()
+	if err != nil {
+		t.Fatalf("readMessage: unexpected error: %v", err)
+	}
+	if _, ok := msg.(*SetupConnectionSuccess); !ok {
+		t.Fatalf("got %T, want *SetupConnectionSuccess", msg)
+	}
+	return tr
+}
+
+// readMsg reads the next message from the passed transport and ensures it has
+// the type of the passed message.
+func readMsg(t *testing.T, tr *transport, want Message) Message {
+	t.Helper()
+
+	msg, _, err := tr.readMessage()
+	if err != nil {
+		t.Fatalf("readMessage: unexpected error: %v", err)
+	}
+	if msg == nil || msg.MsgType() != want.MsgType() {
+		t.Fatalf("got %T, want %T", msg, want)
+	}
+	return msg
+}
+
+// TestServer ensures templates are pushed to clients once they set their
+// coinbase output constraints and when a block is connected, the transactions
+// of the templates are served and solutions are assembled into valid blocks.
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	staticKey, _ := btcec.NewPrivateKey()
+	authorityKey, _ := btcec.NewPrivateKey()
+	node := &testNode{submitted: make(chan *btcutil.Block, 1)}
+	node.setTemplate(testTemplate(chainhash.Hash{0x01}, 5))
+	server := New(&Config{
+		Listeners:    []net.Listener{listener},
+		StaticKey:    staticKey,
+		AuthorityKey: authorityKey,
+		NewTemplate:  node.newTemplate,
+		SubmitBlock: func(block *btcutil.Block) error {
+			node.submitted <- block
+			return nil
+		},
+		IsCurrent:    func() bool { return true },
+		LastTxUpdate: node.txUpdate,
+		Interval:     time.Hour,
+	})
+	server.Start()
+	defer server.Stop()
+
+	// Clients asking for another protocol are rejected.
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+	tr, _, err := initiateHandshake(conn, authorityKey.PubKey())
+	if err != nil {
+		t.Fatalf("initiateHandshake: unexpected error: %v", err)
+	}
+	err = tr.writeMessage(&SetupConnection{
+		Protocol:   ProtocolMining,
+		MinVersion: ProtocolVersion,
+		MaxVersion: ProtocolVersion,
+	})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	setupErr := readMsg(t, tr, &SetupConnectionError{}).(*SetupConnectionError)
+	if setupErr.ErrorCode != ErrUnsupportedProtocol {
+		t.Fatalf("got error code %q, want %q", setupErr.ErrorCode,
+			ErrUnsupportedProtocol)
+	}
+
+	// A future template followed by SetNewPrevHash is sent once the
+	// constraints are set.
+	tr = testClient(t, listener.Addr().String(), authorityKey.PubKey())
+	err = tr.writeMessage(&CoinbaseOutputConstraints{
+		MaxAdditionalSize:   100,
+		MaxAdditionalSigops: 10,
+	})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	tmplMsg := readMsg(t, tr, &NewTemplate{}).(*NewTemplate)
+	if !tmplMsg.FutureTemplate {
+		t.Fatal("first template isn't a future template")
+	}
+	if tmplMsg.CoinbaseTxValueRemaining != 5000005000 {
+		t.Fatalf("got coinbase value %d, want 5000005000",
+			tmplMsg.CoinbaseTxValueRemaining)
+	}
+	if tmplMsg.CoinbaseTxOutputsCount != 1 ||
+		!bytes.Equal(tmplMsg.CoinbasePrefix, []byte{0x01, 0x64}) {
+
+		t.Fatalf("unexpected coinbase outputs count %d or prefix %x",
+			tmplMsg.CoinbaseTxOutputsCount, tmplMsg.CoinbasePrefix)
+	}
+	prevHashMsg := readMsg(t, tr, &SetNewPrevHash{}).(*SetNewPrevHash)
+	if prevHashMsg.TemplateID != tmplMsg.TemplateID ||
+		prevHashMsg.PrevHash != (chainhash.Hash{0x01}) {
+
+		t.Fatalf("unexpected SetNewPrevHash %+v", prevHashMsg)
+	}
+	if prevHashMsg.Target[31] != 0x7f || prevHashMsg.Target[29] != 0xff {
+		t.Fatalf("unexpected target %x", prevHashMsg.Target[:])
+	}
+
+	// The transactions of the template are served, and unknown templates
+	// are reported.
+	err = tr.writeMessage(&RequestTransactionData{
+		TemplateID: tmplMsg.TemplateID,
+	})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	txData := readMsg(t, tr, &RequestTransactionDataSuccess{}).(*RequestTransactionDataSuccess)
+	if len(txData.TransactionList) != 5 {
+		t.Fatalf("got %d transactions, want 5",
+			len(txData.TransactionList))
+	}
+	err = tr.writeMessage(&RequestTransactionData{TemplateID: 1000})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	txDataErr := readMsg(t, tr, &RequestTransactionDataError{}).(*RequestTransactionDataError)
+	if txDataErr.ErrorCode != ErrTemplateIDNotFound {
+		t.Fatalf("got error code %q, want %q", txDataErr.ErrorCode,
+			ErrTemplateIDNotFound)
+	}
+
+	// Build a coinbase transaction from the template and submit it.  The
+	// submitted block must commit to the transactions of the template
+	// with the merkle root the client derives from the merkle path.
+	coinbase := wire.NewMsgTx(int32(tmplMsg.CoinbaseTxVersion))
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  append(tmplMsg.CoinbasePrefix, 0x00, 0x00),
+		Sequence:         tmplMsg.CoinbaseTxInputSequence,
+		Witness:          wire.TxWitness{make([]byte, 32)},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(int64(tmplMsg.CoinbaseTxValueRemaining),
+		[]byte{0x00, 0x14, 0x01}))
+	r := bytes.NewReader(tmplMsg.CoinbaseTxOutputs)
+	for i := uint32(0); i < tmplMsg.CoinbaseTxOutputsCount; i++ {
+		var out wire.TxOut
+		if err := wire.ReadTxOut(r, 0, coinbase.Version, &out); err != nil {
+			t.Fatalf("ReadTxOut: unexpected error: %v", err)
+		}
+		coinbase.AddTxOut(&out)
+	}
+	var buf bytes.Buffer
+	if err := coinbase.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	err = tr.writeMessage(&SubmitSolution{
+		TemplateID:      tmplMsg.TemplateID,
+		Version:         tmplMsg.Version,
+		HeaderTimestamp: prevHashMsg.HeaderTimestamp + 1,
+		HeaderNonce:     42,
+		CoinbaseTx:      buf.Bytes(),
+	})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	var block *btcutil.Block
+	select {
+	case block = <-node.submitted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no block submitted")
+	}
+	root := coinbase.TxHash()
+	for i := range tmplMsg.MerklePath {
+		root = blockchain.HashMerkleBranches(&root, &tmplMsg.MerklePath[i])
+	}
+	header := block.MsgBlock().Header
+	if header.MerkleRoot != root || header.Nonce != 42 ||
+		len(block.Transactions()) != 6 {
+
+		t.Fatalf("unexpected block: merkle root %v (want %v), nonce %d, "+
+			"%d transactions", header.MerkleRoot, root, header.Nonce,
+			len(block.Transactions()))
+	}
+	if err := blockchain.ValidateWitnessCommitment(block); err != nil {
+		t.Fatalf("ValidateWitnessCommitment: unexpected error: %v", err)
+	}
+
+	// A new template is pushed when a block is connected and the template
+	// of the previous tip becomes stale.
+	node.setTemplate(testTemplate(chainhash.Hash{0x02}, 2))
+	server.NotifyBlockConnected()
+	newTmplMsg := readMsg(t, tr, &NewTemplate{}).(*NewTemplate)
+	if !newTmplMsg.FutureTemplate ||
+		newTmplMsg.TemplateID == tmplMsg.TemplateID {
+
+		t.Fatalf("unexpected template %+v", newTmplMsg)
+	}
+	prevHashMsg = readMsg(t, tr, &SetNewPrevHash{}).(*SetNewPrevHash)
+	if prevHashMsg.PrevHash != (chainhash.Hash{0x02}) {
+		t.Fatalf("unexpected SetNewPrevHash %+v", prevHashMsg)
+	}
+	err = tr.writeMessage(&RequestTransactionData{
+		TemplateID: tmplMsg.TemplateID,
+	})
+	if err != nil {
+		t.Fatalf("writeMessage: unexpected error: %v", err)
+	}
+	txDataErr = readMsg(t, tr, &RequestTransactionDataError{}).(*RequestTransactionDataError)
+	if txDataErr.ErrorCode != ErrStaleTemplateID {
+		t.Fatalf("got error code %q, want %q", txDataErr.ErrorCode,
+			ErrStaleTemplateID)
+	}
+}
+
+// TestAdaptTemplate ensures transactions are removed from the end of templates
+// to satisfy the coinbase output constraints while the coinbase value and the
+// witness commitment are kept consistent.
+func TestAdaptTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl := testTemplate(chainhash.Hash{}, 10)
+	tests := []struct {
+		name        string
+		constraints CoinbaseOutputConstraints
+		wantTxns    int
+	}{{
+		name:        "fits",
+		constraints: CoinbaseOutputConstraints{MaxAdditionalSize: 1000},
+		wantTxns:    10,
+	}, {
+		name: "size",
+		constraints: CoinbaseOutputConstraints{
+			MaxAdditionalSize: blockchain.MaxBlockWeight/4 - 3000,
+		},
+		wantTxns: 2,
+	}, {
+		name: "sigops",
+		constraints: CoinbaseOutputConstraints{
+			MaxAdditionalSigops: blockchain.MaxBlockSigOpsCost/4 - 300,
+		},
+		wantTxns: 3,
+	}}
+	for _, test := range tests {
+		block := adaptTemplate(tmpl, &test.constraints)
+		if got := len(block.Transactions) - 1; got != test.wantTxns {
+			t.Errorf("%s: got %d transactions, want %d", test.name,
+				got, test.wantTxns)
+			continue
+		}
+		wantValue := int64(5000000000 + 1000*test.wantTxns)
+		if got := block.Transactions[0].TxOut[0].Value; got != wantValue {
+			t.Errorf("%s: got coinbase value %d, want %d", test.name,
+				got, wantValue)
+		}
+		err := blockchain.ValidateWitnessCommitment(btcutil.NewBlock(block))
+		if err != nil {
+			t.Errorf("%s: ValidateWitnessCommitment: unexpected "+
+				"error: %v", test.name, err)
+		}
+	}
+
+	// The merkle path and the hash of the coinbase transaction make up
+	// the merkle root.
+	for n := 0; n <= 10; n++ {
+		block := &wire.MsgBlock{
+			Transactions: tmpl.Block.Transactions[:n+1],
+		}
+		root := block.Transactions[0].TxHash()
+		path := merklePath(block.Transactions[1:])
+		for i := range path {
+			root = blockchain.HashMerkleBranches(&root, &path[i])
+		}
+		want := blockchain.CalcMerkleRoot(btcutil.NewBlock(block).
+			Transactions(), false)
+		if root != want {
+			t.Fatalf("merklePath(%d): got root %v, want %v", n, root,
+				want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package torcontrol
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// statusOK is the status code of successful replies.
+	statusOK = 250
+
+	// statusAsyncEvent is the status code of asynchronous event
+	// notifications.
+	statusAsyncEvent = 650
+
+	// cookieSize is the size of the authentication cookie of Tor.
+	cookieSize = 32
+
+	// safeCookieServerKey and safeCookieClientKey are the keys of the
+	// HMACs exchanged during SAFECOOKIE authentication.
+	safeCookieServerKey = "Tor safe cookie authentication server-to-controller hash"
+	safeCookieClientKey = "Tor safe cookie authentication controller-to-server hash"
+)
+
+// reply is a reply of the Tor control port, which consists of a status code
+// and one or more lines.  A line which was followed by data holds the data
+// after a newline.
+type reply struct {
+	code  int
+	lines []string
+}
+
+// conn is a connection to the Tor control port.
+type conn struct {
+	text *textproto.Conn
+}
+
+// newConn returns a connection to the Tor control port over the passed
+// network connection.
+func newConn(c net.Conn) *conn {
+	return &conn{text: textproto.NewConn(c)}
+}
+
+// readReply reads the next reply from the control port, skipping asynchronous
+// event notifications.
+func (c *conn) readReply() (*reply, error) {
+	r := &reply{}
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 4 {
+			return nil, fmt.Errorf("malformed reply line %q", line)
+		}
+		code, err := strconv.Atoi(line[:3])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply line %q", line)
+		}
+		if len(r.lines) > 0 && code != r.code {
+			return nil, fmt.Errorf("reply line %q has status %d "+
+				"instead of %d", line, code, r.code)
+		}
+		r.code = code
+
+		data := line[4:]
+		switch line[3] {
+		case ' ':
+			r.lines = append(r.lines, data)
+			if r.code == statusAsyncEvent {
+				r = &reply{}
+				continue
+			}
+			return r, nil
+
+		case '-':
+			r.lines = append(r.lines, data)
+
+		case '+':
+			lines, err := c.text.ReadDotLines()
+			if err != nil {
+				return nil, err
+			}
+			r.lines = append(r.lines, data+"\n"+
+				strings.Join(lines, "\n"))
+
+		default:
+			return nil, fmt.Errorf("malformed reply line %q", line)
+		}
+	}
+}
+
+// command sends the passed command to the control port and returns its reply,
+// or an error when it failed.  The error only includes the keyword of the
+// command, so it never includes credentials.
+func (c *conn) command(cmd string) (*reply, error) {
+	if err := c.text.PrintfLine("%s", cmd); err != nil {
+		return nil, err
+	}
+	r, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if r.code != statusOK {
+		keyword := strings.SplitN(cmd, " ", 2)[0]
+		return nil, fmt.Errorf("%s failed: %d %s", keyword, r.code,
+			strings.Join(r.lines, " "))
+	}
+	return r, nil
+}
+
+// authenticate authenticates the connection with the passed password, or with
+// the cookie or without credentials depending on what Tor allows when the
+// password is empty.
+func (c *conn) authenticate(password string) error {
+	r, err := c.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	methods := make(map[string]bool)
+	var cookieFile string
+	for _, line := range r.lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		values, err := parseKeyValues(line[len("AUTH "):])
+		if err != nil {
+			return err
+		}
+		for _, method := range strings.Split(values["METHODS"], ",") {
+			methods[method] = true
+		}
+		cookieFile = values["COOKIEFILE"]
+	}
+
+	switch {
+	case password != "":
+		if !methods["HASHEDPASSWORD"] {
+			return errors.New("tor does not allow password " +
+				"authentication")
+		}
+		_, err = c.command("AUTHENTICATE " + quote(password))
+
+	case methods["NULL"]:
+		_, err = c.command("AUTHENTICATE")
+
+	case methods["SAFECOOKIE"]:
+		err = c.authenticateSafeCookie(cookieFile)
+
+	case methods["COOKIE"]:
+		var cookie []byte
+		cookie, err = readCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		_, err = c.command("AUTHENTICATE " + hex.EncodeToString(cookie))
+
+	default:
+		return errors.New("tor requires a password for authentication")
+	}
+	return err
+}
+
+// authenticateSafeCookie authenticates the connection with the cookie of the
+// passed file using the SAFECOOKIE method, which also proves that Tor knows the
+// cookie before it is used.
+func (c *conn) authenticateSafeCookie(cookieFile string) error {
+	cookie, err := readCookie(cookieFile)
+	if err != nil {
+		return err
+	}
+	var clientNonce [32]byte
+	if _, err := rand.Read(clientNonce[:]); err != nil {
+		return err
+	}
+
+	r, err := c.command("AUTHCHALLENGE SAFECOOKIE " +
+		hex.EncodeToString(clientNonce[:]))
+	if err != nil {
+		return err
+	}
+	values, err := parseKeyValues(strings.TrimPrefix(r.lines[0],
+		"AUTHCHALLENGE "))
+	if err != nil {
+		return err
+	}
+	serverHash, err := hex.DecodeString(values["SERVERHASH"])
+	if err != nil {
+		return fmt.Errorf("malformed AUTHCHALLENGE server hash: %v", err)
+	}
+	serverNonce, err := hex.DecodeString(values["SERVERNONCE"])
+	if err != nil {
+		return fmt.Errorf("malformed AUTHCHALLENGE server nonce: %v", err)
+	}
+
+	msg := make([]byte, 0, len(cookie)+len(clientNonce)+len(serverNonce))
+	msg = append(msg, cookie...)
+	msg = append(msg, clientNonce[:]...)
+	msg = append(msg, serverNonce...)
+	if !hmac.Equal(serverHash, hmacSHA256(safeCookieServerKey, msg)) {
+		return errors.New("tor does not know the authentication cookie")
+	}
+
+	clientHash := hmacSHA256(safeCookieClientKey, msg)
+	_, err = c.command("AUTHENTICATE " + hex.EncodeToString(clientHash))
+	return err
+}
+
+// readCookie returns the authentication cookie of Tor from the passed file.
+func readCookie(cookieFile string) ([]byte, error) {
+	if cookieFile == "" {
+		return nil, errors.New("tor did not provide the cookie file")
+	}
+	cookie, err := os.ReadFile(cookieFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(cookie) != cookieSize {
+		return nil, fmt.Errorf("cookie file %s has %d bytes instead of "+
+			"%d", cookieFile, len(cookie), cookieSize)
+	}
+	return cookie, nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the passed message with the passed key.
+func hmacSHA256(key string, msg []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+// quote returns the passed string as a quoted string of the control protocol.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseKeyValues parses the space separated KEY=VALUE pairs of a reply line,
+// where the values may be quoted strings.  Keywords without a value are
+// skipped.
+func parseKeyValues(line string) (map[string]string, error) {
+	values := make(map[string]string)
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+
+		end := strings.IndexAny(line, " =")
+		if end == -1 || line[end] == ' ' {
+			// A keyword without a value.
+			if end == -1 {
+				break
+			}
+			line = line[end:]
+			continue
+		}
+		key := line[:end]
+		line = line[end+1:]
+
+		if !strings.HasPrefix(line, `"`) {
+			end := strings.IndexByte(line, ' ')
+			if end == -1 {
+				end = len(line)
+			}
+			values[key] = line[:end]
+			line = line[end:]
+			continue
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+			}
+			value.WriteByte(line[i])
+		}
+		if i == len(line) {
+			return nil, fmt.Errorf("unterminated quoted value of %s",
+				key)
+		}
+		values[key] = value.String()
+		line = line[i+1:]
+	}
+	return values, nil
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package torcontrol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// dialTimeout is the timeout of connecting to the control port.
+	dialTimeout = 10 * time.Second
+
+	// minRetryDelay and maxRetryDelay bound the delay before reconnecting
+	// to the control port, which doubles after each failed attempt.
+	minRetryDelay = time.Second
+	maxRetryDelay = 10 * time.Minute
+)
+
+// Config is the configuration of a Controller.
+type Config struct {
+	// ControlAddr is the address of the control port of Tor, such as
+	// 127.0.0.1:9051.
+	ControlAddr string
+
+	// Password is the password to authenticate with.  The cookie is used,
+	// if any, when it is empty.
+	Password string
+
+	// Target is the local address the onion service forwards connections
+	// to, such as 127.0.0.1:8333.  No onion service is created when it is
+	// empty.
+	Target string
+
+	// VirtualPort is the port of the onion service.
+	VirtualPort uint16
+
+	// PrivateKeyFile is the file the private key of the onion service is
+	// saved to and loaded from, so it keeps its address across restarts.
+	// A new key is used each time when it is empty.
+	PrivateKeyFile string
+
+	// OnService is invoked with the host and port of the onion service
+	// each time it was created.  It may be nil.
+	OnService func(host string, port uint16)
+}
+
+// Controller maintains a connection to the control port of Tor, which is used
+// to learn the address of the Tor SOCKS proxy and to create an onion service.
+type Controller struct {
+	cfg Config
+
+	mtx       sync.Mutex
+	netConn   net.Conn
+	socksAddr string
+	service   string
+	stopped   bool
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns a controller with the passed configuration.  Use Start to
+// connect to the control port.
+func New(cfg *Config) *Controller {
+	return &Controller{
+		cfg:  *cfg,
+		quit: make(chan struct{}),
+	}
+}
+
+// Start connects to the control port in the background.
+func (c *Controller) Start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Stop closes the connection to the control port, which removes the onion
+// service, and waits until the controller stopped.
+func (c *Controller) Stop() {
+	c.mtx.Lock()
+	if c.stopped {
+		c.mtx.Unlock()
+		return
+	}
+	c.stopped = true
+	if c.netConn != nil {
+		c.netConn.Close()
+	}
+	c.mtx.Unlock()
+
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// SOCKSAddr returns the address of the SOCKS proxy of Tor, or an empty string
+// while it is not known yet.  The last known address is kept while the
+// controller reconnects.
+func (c *Controller) SOCKSAddr() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.socksAddr
+}
+
+// ServiceAddr returns the host and port of the onion service, or an empty
+// string while there is none.
+func (c *Controller) ServiceAddr() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.service == "" {
+		return ""
+	}
+	return net.JoinHostPort(c.service, fmt.Sprint(c.cfg.VirtualPort))
+}
+
+// run maintains the connection to the control port until the controller is
+// stopped.  It must be run as a goroutine.
+func (c *Controller) run() {
+	defer c.wg.Done()
+
+	delay := minRetryDelay
+	for {
+		established, err := c.session()
+
+		c.mtx.Lock()
+		c.service = ""
+		stopped := c.stopped
+		c.mtx.Unlock()
+		if stopped {
+			return
+		}
+
+		if established {
+			delay = minRetryDelay
+		}
+		log.Warnf("Connection to the Tor control port %s failed: %v "+
+			"-- reconnecting in %v", c.cfg.ControlAddr, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-c.quit:
+			return
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// session connects to the control port, authenticates, learns the address of
+// the SOCKS proxy and creates the onion service.  It then keeps the connection
+// open until it fails.  It returns whether the setup succeeded along with the
+// error the connection failed with.
+func (c *Controller) session() (bool, error) {
+	netConn, err := net.DialTimeout("tcp", c.cfg.ControlAddr, dialTimeout)
+	if err != nil {
+		return false, err
+	}
+	c.mtx.Lock()
+	if c.stopped {
+		c.mtx.Unlock()
+		netConn.Close()
+		return false, errors.New("controller stopped")
+	}
+	c.netConn = netConn
+	c.mtx.Unlock()
+	defer netConn.Close()
+
+	conn := newConn(netConn)
+	if err := conn.authenticate(c.cfg.Password); err != nil {
+		return false, err
+	}
+
+	socksAddr, err := c.querySOCKSAddr(conn)
+	if err != nil {
+		return false, err
+	}
+	c.mtx.Lock()
+	changed := c.socksAddr != socksAddr
+	c.socksAddr = socksAddr
+	c.mtx.Unlock()
+	if changed {
+		log.Infof("Using the Tor SOCKS proxy at %s", socksAddr)
+	}
+
+	if c.cfg.Target != "" {
+		if err := c.addOnion(conn); err != nil {
+			return false, err
+		}
+	}
+
+	// Keep the connection open since Tor removes the onion service once it
+	// is closed.  Tor doesn't send anything since no events are
+	// subscribed to.
+	for {
+		if _, err := conn.readReply(); err != nil {
+			return true, err
+		}
+	}
+}
+
+// querySOCKSAddr returns the address of the first SOCKS listener of Tor.
+func (c *Controller) querySOCKSAddr(conn *conn) (string, error) {
+	const key = "net/listeners/socks"
+	r, err := conn.command("GETINFO " + key)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range r.lines {
+		values, err := parseKeyValues(line)
+		if err != nil {
+			return "", err
+		}
+		if addr := values[key]; addr != "" {
+			return addr, nil
+		}
+	}
+	return "", errors.New("tor has no SOCKS listener")
+}
+
+// addOnion creates the onion service with the saved private key, or with a new
+// one which is saved when there is none.
+func (c *Controller) addOnion(conn *conn) error {
+	key := "NEW:ED25519-V3"
+	if c.cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(c.cfg.PrivateKeyFile)
+		switch {
+		case err == nil:
+			key = strings.TrimSpace(string(data))
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+
+	r, err := conn.command(fmt.Sprintf("ADD_ONION %s Port=%d,%s", key,
+		c.cfg.VirtualPort, c.cfg.Target))
+	if err != nil {
+		return err
+	}
+	var serviceID, privateKey string
+	for _, line := range r.lines {
+		values, err := parseKeyValues(line)
+		if err != nil {
+			return err
+		}
+		if id, ok := values["ServiceID"]; ok {
+			serviceID = id
+		}
+		if pk, ok := values["PrivateKey"]; ok {
+			privateKey = pk
+		}
+	}
+	if serviceID == "" {
+		return errors.New("ADD_ONION did not return the service id")
+	}
+	if privateKey != "" && c.cfg.PrivateKeyFile != "" {
+		err := os.WriteFile(c.cfg.PrivateKeyFile, []byte(privateKey),
+			0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	host := serviceID + ".onion"
+	c.mtx.Lock()
+	c.service = host
+	c.mtx.Unlock()
+	log.Infof("Created the onion service %s for %s",
+		net.JoinHostPort(host, fmt.Sprint(c.cfg.VirtualPort)),
+		c.cfg.Target)
+
+	if c.cfg.OnService != nil {
+		c.cfg.OnService(host, c.cfg.VirtualPort)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package torcontrol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockTor is a minimal Tor control port which records the commands it receives
+// and accepts the configured authentication.
+type mockTor struct {
+	listener   net.Listener
+	password   string
+	cookie     []byte
+	cookieFile string
+	commands   chan string
+}
+
+// newMockTor returns a mock control port accepting the passed password, or the
+// passed cookie via SAFECOOKIE when the password is empty.
+func newMockTor(t *testing.T, password string, cookie []byte) *mockTor {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	m := &mockTor{
+		listener: listener,
+		password: password,
+		cookie:   cookie,
+		commands: make(chan string, 100),
+	}
+	if cookie != nil {
+		m.cookieFile = filepath.Join(t.TempDir(), "control_auth_cookie")
+		if err := os.WriteFile(m.cookieFile, cookie, 0600); err != nil {
+			t.Fatalf("unable to write cookie: %v", err)
+		}
+	}
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(c)
+		}
+	}()
+	return m
+}
+
+// serve answers the commands of a single control connection.
+func (m *mockTor) serve(c net.Conn) {
+	defer c.Close()
+	var clientNonce, serverNonce []byte
+	scanner := bufio.NewScanner(c)
+	for scanner.Scan() {
+		line := scanner.Text()
+		m.commands <- line
+		var reply string
+		switch {
+		case line == "PROTOCOLINFO 1":
+			methods := "HASHEDPASSWORD"
+			if m.cookie != nil {
+				methods = "COOKIE,SAFECOOKIE"
+			}
+			reply = fmt.Sprintf("250-PROTOCOLINFO 1\r\n"+
+				"250-AUTH METHODS=%s COOKIEFILE=%s\r\n"+
+				"250-VERSION Tor=\"0.4.8.10\"\r\n250 OK\r\n",
+				methods, quote(m.cookieFile))
+
+		case strings.HasPrefix(line, "AUTHCHALLENGE SAFECOOKIE "):
+			clientNonce, _ = hex.DecodeString(line[25:])
+			serverNonce = bytes.Repeat([]byte{0x11}, 32)
+			msg := append(append(append([]byte{}, m.cookie...),
+				clientNonce...), serverNonce...)
+			reply = fmt.Sprintf("250 AUTHCHALLENGE SERVERHASH=%x "+
+				"SERVERNONCE=%x\r\n",
+				hmacSHA256(safeCookieServerKey, msg), serverNonce)
+
+		case strings.HasPrefix(line, "AUTHENTICATE "):
+			want := quote(m.password)
+			if m.cookie != nil {
+				msg := append(append(append([]byte{}, m.cookie...),
+					clientNonce...), serverNonce...)
+				want = hex.EncodeToString(hmacSHA256(
+					safeCookieClientKey, msg))
+			}
+			reply = "250 OK\r\n"
+			if line[13:] != want {
+				reply = "515 Authentication failed\r\n"
+			}
+
+		case line == "GETINFO net/listeners/socks":
+			reply = "250-net/listeners/socks=\"127.0.0.1:9050\"\r\n" +
+				"250 OK\r\n"
+
+		case strings.HasPrefix(line, "ADD_ONION NEW:ED25519-V3 "):
+			reply = "250-ServiceID=abcdef\r\n" +
+				"250-PrivateKey=ED25519-V3:c2VjcmV0\r\n250 OK\r\n"
+
+		case strings.HasPrefix(line, "ADD_ONION ED25519-V3:c2VjcmV0 "):
+			reply = "250-ServiceID=abcdef\r\n250 OK\r\n"
+
+		default:
+			reply = "510 Unrecognized command\r\n"
+		}
+		if _, err := c.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// expectCommand waits for the next command the mock control port receives and
+// ensures it has the passed prefix.
+func (m *mockTor) expectCommand(t *testing.T, prefix string) {
+	t.Helper()
+	select {
+	case cmd := <-m.commands:
+		if !strings.HasPrefix(cmd, prefix) {
+			t.Fatalf("got command %q, want %q", cmd, prefix)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for command %q", prefix)
+	}
+}
+
+// TestController ensures the controller authenticates, learns the address of
+// the SOCKS proxy and creates an onion service whose key is saved and reused.
+func TestController(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		cookie   []byte
+		auth     string
+	}{{
+		name:     "password",
+		password: `pass"word`,
+		auth:     `AUTHENTICATE "pass\"word"`,
+	}, {
+		name:   "safe cookie",
+		cookie: bytes.Repeat([]byte{0x42}, cookieSize),
+		auth:   "AUTHCHALLENGE SAFECOOKIE ",
+	}}
+
+	for _, test := range tests {
+		tor := newMockTor(t, test.password, test.cookie)
+		keyFile := filepath.Join(t.TempDir(), "onion_v3_private_key")
+		services := make(chan string, 1)
+		c := New(&Config{
+			ControlAddr:    tor.listener.Addr().String(),
+			Password:       test.password,
+			Target:         "127.0.0.1:8333",
+			VirtualPort:    8333,
+			PrivateKeyFile: keyFile,
+			OnService: func(host string, port uint16) {
+				services <- net.JoinHostPort(host, fmt.Sprint(port))
+			},
+		})
+		c.Start()
+
+		tor.expectCommand(t, "PROTOCOLINFO 1")
+		tor.expectCommand(t, test.auth)
+		if test.cookie != nil {
+			tor.expectCommand(t, "AUTHENTICATE ")
+		}
+		tor.expectCommand(t, "GETINFO net/listeners/socks")
+		tor.expectCommand(t, "ADD_ONION NEW:ED25519-V3 Port=8333,127.0.0.1:8333")
+		select {
+		case service := <-services:
+			if service != "abcdef.onion:8333" {
+				t.Fatalf("%s: got onion service %s", test.name,
+					service)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timeout waiting for the onion service",
+				test.name)
+		}
+		if addr := c.SOCKSAddr(); addr != "127.0.0.1:9050" {
+			t.Fatalf("%s: got SOCKS address %s", test.name, addr)
+		}
+		if addr := c.ServiceAddr(); addr != "abcdef.onion:8333" {
+			t.Fatalf("%s: got service address %s", test.name, addr)
+		}
+		key, err := os.ReadFile(keyFile)
+		if err != nil || string(key) != "ED25519-V3:c2VjcmV0" {
+			t.Fatalf("%s: got saved key %q, %v", test.name, key, err)
+		}
+		c.Stop()
+
+		// The saved key is used again by a new controller.
+		c = New(&Config{
+			ControlAddr:    tor.listener.Addr().String(),
+			Password:       test.password,
+			Target:         "127.0.0.1:8333",
+			VirtualPort:    8333,
+			PrivateKeyFile: keyFile,
+		})
+		c.Start()
+		tor.expectCommand(t, "PROTOCOLINFO 1")
+		tor.expectCommand(t, test.auth)
+		if test.cookie != nil {
+			tor.expectCommand(t, "AUTHENTICATE ")
+		}
+		tor.expectCommand(t, "GETINFO net/listeners/socks")
+		tor.expectCommand(t, "ADD_ONION ED25519-V3:c2VjcmV0 Port=8333,127.0.0.1:8333")
+		c.Stop()
+		tor.listener.Close()
+	}
+}
+
+// TestParseKeyValues ensures the key value pairs of reply lines are parsed as
+// intended.
+func TestParseKeyValues(t *testing.T) {
+	tests := []struct {
+		line string
+		want map[string]string
+	}{{
+		line: `METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/run/tor/control.authcookie"`,
+		want: map[string]string{
+			"METHODS":    "COOKIE,SAFECOOKIE",
+			"COOKIEFILE": "/run/tor/control.authcookie",
+		},
+	}, {
+		line: `Tor="0.4.8.10" extra KEY="a \"quoted\" \\ value"`,
+		want: map[string]string{
+			"Tor": "0.4.8.10",
+			"KEY": `a "quoted" \ value`,
+		},
+	}, {
+		line: `net/listeners/socks="127.0.0.1:9050" "[::1]:9050"`,
+		want: map[string]string{
+			"net/listeners/socks": "127.0.0.1:9050",
+		},
+	}}
+
+	for _, test := range tests {
+		got, err := parseKeyValues(test.line)
+		if err != nil {
+			t.Errorf("parseKeyValues(%q): unexpected error: %v",
+				test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseKeyValues(%q): got %v, want %v",
+				test.line, got, test.want)
+		}
+	}
+
+	if _, err := parseKeyValues(`KEY="unterminated`); err == nil {
+		t.Error("parseKeyValues: expected error for unterminated value")
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package torcontrol implements a client of the Tor control protocol which makes
+the node reachable as a Tor onion service without configuring Tor for it.
+
+A Controller connects to the control port of a running Tor instance and
+authenticates with a password, a cookie file via the SAFECOOKIE or COOKIE
+methods, or without credentials when Tor allows it.  It then queries the
+address of the SOCKS proxy of Tor, so outbound connections can be made through
+it, and creates an ephemeral v3 onion service which forwards connections to the
+listening port of the node.
+
+The private key of the onion service is saved to a file, so the node keeps the
+same onion address across restarts.  Since Tor removes ephemeral onion services
+once the control connection is closed, the controller keeps the connection open
+and reconnects with an increasing delay when it is lost, recreating the onion
+service each time.
+*/
+package torcontrol
@@ -0,0 +1,32 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package txrecon implements transaction relay by set reconciliation (Erlay) as
+described by BIP0330.
+
+Instead of announcing every transaction to every peer with inv messages, a
+node keeps a reconciliation set of the transactions it would announce to each
+peer that supports reconciliation.  The peer that initiated the connection
+periodically requests a sketch of the set of the other peer.  A sketch of a set
+of 32-bit short transaction IDs with a capacity of c is only 4*c bytes, and the
+sum of the sketches of two sets is the sketch of the elements that are in only
+one of them, which can be decoded as long as there are at most c of them.  The
+initiator therefore learns which transactions either peer is missing from
+the sketch it received and a sketch of its own set, and only those
+transactions are announced.
+
+When the difference exceeds the capacity of the sketch, the initiator requests
+an extension that doubles the capacity, and when that does not suffice either,
+both peers announce their whole sets.
+
+The sketches are PinSketches over GF(2^32) and the short IDs are computed with
+SipHash-2-4 keyed with the combined salts the peers exchanged in their
+sendtxrcncl messages during the version handshake.
+
+btcd does not implement the wtxidrelay message, so the transactions are
+announced by their txids, which means reconciliation is only used between btcd
+nodes.
+*/
+package txrecon
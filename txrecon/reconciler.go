@@ -0,0 +1,391 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txrecon
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MaxSetSize is the maximum number of transactions in the
+	// reconciliation set for a peer.  Transactions that don't fit are
+	// announced to the peer right away.
+	MaxSetSize = 3000
+
+	// MaxCapacity is the maximum capacity of the sketch sent in reply to a
+	// reconciliation request.  The capacity is doubled when the sketch is
+	// extended.
+	MaxCapacity = wire.MaxReconSketchCapacity / 2
+
+	// DefaultQ is the coefficient, scaled by QScale, that initiators use
+	// to estimate the difference between the reconciliation sets from
+	// their sizes.
+	DefaultQ = QScale / 4
+
+	// QScale is the value of the coefficient q that corresponds to 1.
+	QScale = 1<<15 - 1
+
+	// RoundTimeout is the time after which an initiator abandons a
+	// reconciliation round the responder did not complete.
+	RoundTimeout = time.Minute
+)
+
+// ErrUnexpectedMessage is returned when a peer sends a reconciliation message
+// that is not expected in the current state of the reconciliation.  Peers that
+// do so violate the protocol.
+var ErrUnexpectedMessage = errors.New("unexpected reconciliation message")
+
+// roundPhase describes the progress of a reconciliation round.
+type roundPhase int
+
+const (
+	// phaseIdle means no reconciliation round is in progress.
+	phaseIdle roundPhase = iota
+
+	// phaseRequested means the initiator requested a sketch.
+	phaseRequested
+
+	// phaseSketchSent means the responder sent a sketch.
+	phaseSketchSent
+
+	// phaseExtRequested means the initiator requested a sketch
+	// extension.
+	phaseExtRequested
+
+	// phaseExtSent means the responder sent a sketch extension.
+	phaseExtSent
+)
+
+// PeerState tracks the transaction reconciliation (BIP0330) with a single
+// peer.  The peer that initiated the connection is the initiator of the
+// reconciliation rounds, which it starts periodically by requesting a sketch of
+// the reconciliation set of the responder.  The initiator decodes the
+// difference between both sets from the sketch and a sketch of its own set,
+// requests the transactions it is missing and announces the transactions the
+// responder is missing.  When the difference can't be decoded, the initiator
+// requests a sketch extension, and when the difference still can't be decoded,
+// both peers announce their whole sets.
+//
+// The methods return the messages to send to the peer along with the hashes of
+// the transactions to announce to it.  It is safe for concurrent access.
+type PeerState struct {
+	initiator bool
+	key       [16]byte
+
+	mtx sync.Mutex
+
+	// set holds the transactions to reconcile with the peer in the next
+	// round keyed by their short IDs.
+	set map[uint32]chainhash.Hash
+
+	// roundSet holds the transactions that are reconciled in the current
+	// round.
+	roundSet map[uint32]chainhash.Hash
+
+	phase        roundPhase
+	roundStarted time.Time
+
+	// capacity is the capacity of the sketch of the current round and
+	// remoteSketch holds the syndromes received by the initiator.
+	capacity     int
+	remoteSketch []byte
+}
+
+// NewPeerState returns the reconciliation state for a peer that negotiated
+// transaction reconciliation with the passed salts.  The initiator flag must
+// be set when the local node initiated the connection.
+func NewPeerState(initiator bool, localSalt, remoteSalt uint64) *PeerState {
+	return &PeerState{
+		initiator: initiator,
+		key:       ShortIDKey(localSalt, remoteSalt),
+		set:       make(map[uint32]chainhash.Hash),
+	}
+}
+
+// Initiator returns whether the local node is the initiator of the
+// reconciliation rounds with the peer.
+func (s *PeerState) Initiator() bool {
+	return s.initiator
+}
+
+// SetSize returns the number of transactions waiting to be reconciled with the
+// peer.
+func (s *PeerState) SetSize() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.set) + len(s.roundSet)
+}
+
+// AddTx adds the transaction with the passed hashes to the reconciliation set
+// for the peer.  It returns false when the set is full, in which case the
+// transaction must be announced to the peer right away.
+func (s *PeerState) AddTx(txid, wtxid *chainhash.Hash) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.set) >= MaxSetSize {
+		return false
+	}
+	s.set[ShortID(&s.key, wtxid)] = *txid
+	return true
+}
+
+// StartRound returns the reqrecon message that starts a new reconciliation
+// round with the peer.  It returns nil when the local node is not the
+// initiator or a round is in progress.  A round that did not complete within
+// RoundTimeout is abandoned and its transactions are reconciled in the new
+// round.
+//
+// This function is safe for concurrent access.
+func (s *PeerState) StartRound() *wire.MsgReqRecon {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.initiator {
+		return nil
+	}
+	if s.phase != phaseIdle {
+		if time.Since(s.roundStarted) < RoundTimeout {
+			return nil
+		}
+		s.endRound()
+	}
+
+	s.startRound()
+	return wire.NewMsgReqRecon(uint16(len(s.roundSet)), DefaultQ)
+}
+
+// startRound moves the reconciliation set to the set of the new round.
+//
+// This function MUST be called with the state lock held.
+func (s *PeerState) startRound() {
+	s.roundSet = s.set
+	s.set = make(map[uint32]chainhash.Hash)
+	s.phase = phaseRequested
+	s.roundStarted = time.Now()
+}
+
+// endRound ends the current round and moves the transactions of the round that
+// were not announced back to the reconciliation set.
+//
+// This function MUST be called with the state lock held.
+func (s *PeerState) endRound() {
+	for id, txid := range s.roundSet {
+		if len(s.set) >= MaxSetSize {
+			break
+		}
+		if _, ok := s.set[id]; !ok {
+			s.set[id] = txid
+		}
+	}
+	s.roundSet = nil
+	s.remoteSketch = nil
+	s.phase = phaseIdle
+}
+
+// roundTxns returns the hashes of all transactions of the current round.
+//
+// This function MUST be called with the state lock held.
+func (s *PeerState) roundTxns() []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0, len(s.roundSet))
+	for _, txid := range s.roundSet {
+		hashes = append(hashes, txid)
+	}
+	return hashes
+}
+
+// estimateCapacity returns the capacity of the sketch the responder sends for
+// the passed set sizes and coefficient q, which is the estimated difference
+// between the sets plus one.  It returns zero when the estimate exceeds
+// MaxCapacity.
+func estimateCapacity(localSize, remoteSize int, q uint16) int {
+	diff, minSize := localSize-remoteSize, localSize
+	if diff < 0 {
+		diff, minSize = -diff, remoteSize
+	}
+	capacity := diff + minSize*int(q)/QScale + 1
+	if capacity > MaxCapacity {
+		return 0
+	}
+	return capacity
+}
+
+// sketch returns the sketch of the transactions of the current round with the
+// passed capacity.
+//
+// This function MUST be called with the state lock held.
+func (s *PeerState) sketch(capacity int) *Sketch {
+	sketch := NewSketch(capacity)
+	for id := range s.roundSet {
+		sketch.Add(id)
+	}
+	return sketch
+}
+
+// HandleReqRecon handles a reqrecon message from the initiator and returns the
+// sketch message to reply with.  A sketch without syndromes is returned when
+// the difference between the sets is estimated to be too large to reconcile,
+// in which case both peers announce their whole sets.
+//
+// This function is safe for concurrent access.
+func (s *PeerState) HandleReqRecon(msg *wire.MsgReqRecon) (*wire.MsgSketch, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.initiator {
+		return nil, ErrUnexpectedMessage
+	}
+
+	// A new request while a round is in progress means the initiator
+	// abandoned the round.
+	if s.phase != phaseIdle {
+		s.endRound()
+	}
+	s.startRound()
+	s.phase = phaseSketchSent
+
+	s.capacity = estimateCapacity(len(s.roundSet), int(msg.SetSize), msg.Q)
+	if s.capacity == 0 {
+		return wire.NewMsgSketch(nil), nil
+	}
+	return wire.NewMsgSketch(s.sketch(s.capacity).Serialize()), nil
+}
+
+// HandleReqSketchExt handles a reqsketchext message from the initiator and
+// returns the sketch message with the syndromes that extend the sketch sent
+// for the current round to twice its capacity.
+//
+// This function is safe for concurrent access.
+func (s *PeerState) HandleReqSketchExt() (*wire.MsgSketch, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.initiator || s.phase != phaseSketchSent || s.capacity == 0 {
+		return nil, ErrUnexpectedMessage
+	}
+	s.phase = phaseExtSent
+
+	extended := s.sketch(2 * s.capacity).Serialize()
+	return wire.NewMsgSketch(extended[s.capacity*4:]), nil
+}
+
+// HandleReconcilDiff handles the reconcildiff message that concludes the
+// current round and returns the hashes of the transactions to announce to the
+// initiator, which are the requested transactions when the reconciliation
+// succeeded and all transactions of the round otherwise.
+//
+// This function is safe for concurrent access.
+func (s *PeerState) HandleReconcilDiff(msg *wire.MsgReconcilDiff) ([]chainhash.Hash, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.initiator || (s.phase != phaseSketchSent && s.phase != phaseExtSent) {
+		return nil, ErrUnexpectedMessage
+	}
+
+	var announce []chainhash.Hash
+	if msg.Success {
+		for _, id := range msg.AskShortIDs {
+			if txid, ok := s.roundSet[id]; ok {
+				announce = append(announce, txid)
+				delete(s.roundSet, id)
+			}
+		}
+	} else {
+		announce = s.roundTxns()
+	}
+
+	s.roundSet = nil
+	s.phase = phaseIdle
+	return announce, nil
+}
+
+// HandleSketch handles a sketch message from the responder.  It returns the
+// message to reply with, which is either a reqsketchext message requesting a
+// sketch extension or the reconcildiff message that concludes the round, along
+// with the hashes of the transactions to announce to the responder.
+//
+// This function is safe for concurrent access.
+func (s *PeerState) HandleSketch(msg *wire.MsgSketch) (wire.Message, []chainhash.Hash, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.initiator || (s.phase != phaseRequested && s.phase != phaseExtRequested) {
+		return nil, nil, ErrUnexpectedMessage
+	}
+	remote, err := ParseSketch(msg.Sketch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The responder sends a sketch without syndromes when the difference
+	// is too large to reconcile.
+	if s.phase == phaseRequested && remote.Capacity() == 0 {
+		announce := s.roundTxns()
+		return s.failRound(), announce, nil
+	}
+
+	// Combine the sketch extension with the initial sketch.
+	if s.phase == phaseExtRequested {
+		if remote.Capacity() != len(s.remoteSketch)/4 {
+			return nil, nil, ErrUnexpectedMessage
+		}
+		combined := make([]byte, 0, len(s.remoteSketch)+len(msg.Sketch))
+		combined = append(combined, s.remoteSketch...)
+		combined = append(combined, msg.Sketch...)
+		remote, _ = ParseSketch(combined)
+	}
+	if remote.Capacity() > wire.MaxReconSketchCapacity {
+		return nil, nil, ErrUnexpectedMessage
+	}
+
+	local := s.sketch(remote.Capacity())
+	local.Merge(remote)
+	diff, err := local.Decode()
+	if err != nil {
+		if s.phase == phaseExtRequested {
+			announce := s.roundTxns()
+			return s.failRound(), announce, nil
+		}
+		s.remoteSketch = msg.Sketch
+		s.phase = phaseExtRequested
+		return wire.NewMsgReqSketchExt(), nil, nil
+	}
+
+	// The difference consists of the transactions of the round the
+	// responder is missing and the ones the initiator is missing.
+	var announce []chainhash.Hash
+	var ask []uint32
+	for _, id := range diff {
+		if txid, ok := s.roundSet[id]; ok {
+			announce = append(announce, txid)
+			continue
+		}
+		ask = append(ask, id)
+	}
+
+	s.roundSet = nil
+	s.remoteSketch = nil
+	s.phase = phaseIdle
+	return wire.NewMsgReconcilDiff(true, ask), announce, nil
+}
+
+// failRound ends the current round after the difference could not be decoded
+// and returns the reconcildiff message that tells the responder to announce
+// its whole set.  The caller announces the transactions of the round.
+//
+// This function MUST be called with the state lock held.
+func (s *PeerState) failRound() *wire.MsgReconcilDiff {
+	s.roundSet = nil
+	s.phase = phaseIdle
+	s.remoteSketch = nil
+	return wire.NewMsgReconcilDiff(false, nil)
+}
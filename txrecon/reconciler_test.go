@@ -0,0 +1,249 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txrecon
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testTx returns distinct txid and wtxid hashes for the passed index.
+func testTx(i int) (chainhash.Hash, chainhash.Hash) {
+	txid := chainhash.Hash{byte(i), byte(i >> 8), 1}
+	wtxid := chainhash.Hash{byte(i), byte(i >> 8), 2}
+	return txid, wtxid
+}
+
+// addTxns adds the test transactions in [from, to) to the passed state.
+func addTxns(t *testing.T, s *PeerState, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		txid, wtxid := testTx(i)
+		if !s.AddTx(&txid, &wtxid) {
+			t.Fatalf("AddTx: set full at transaction %d", i)
+		}
+	}
+}
+
+// txRange returns the sorted txids of the test transactions in [from, to).
+func txRange(from, to int) []chainhash.Hash {
+	var hashes []chainhash.Hash
+	for i := from; i < to; i++ {
+		txid, _ := testTx(i)
+		hashes = append(hashes, txid)
+	}
+	return sortHashes(hashes)
+}
+
+// sortHashes sorts the passed hashes.
+func sortHashes(hashes []chainhash.Hash) []chainhash.Hash {
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].String() < hashes[j].String()
+	})
+	return hashes
+}
+
+// checkHashes ensures the passed hashes match the expected ones in any order.
+func checkHashes(t *testing.T, what string, got, want []chainhash.Hash) {
+	t.Helper()
+	got = sortHashes(append([]chainhash.Hash(nil), got...))
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d transactions, want %d", what, len(got),
+			len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", what, got, want)
+		}
+	}
+}
+
+// TestShortIDKey ensures both peers derive the same short ID key.
+func TestShortIDKey(t *testing.T) {
+	if ShortIDKey(1, 2) != ShortIDKey(2, 1) {
+		t.Fatal("short ID key depends on the order of the salts")
+	}
+	if ShortIDKey(1, 2) == ShortIDKey(1, 3) {
+		t.Fatal("short ID key does not depend on the salts")
+	}
+	key := ShortIDKey(1, 2)
+	if ShortID(&key, &chainhash.Hash{}) == 0 {
+		t.Fatal("short ID is zero")
+	}
+}
+
+// TestReconciliation ensures reconciliation rounds announce exactly the
+// transactions each peer is missing.
+func TestReconciliation(t *testing.T) {
+	initiator := NewPeerState(true, 1, 2)
+	responder := NewPeerState(false, 2, 1)
+
+	// Only the initiator starts rounds.
+	if responder.StartRound() != nil {
+		t.Fatal("StartRound: responder started a round")
+	}
+
+	// Transactions 0-99 are known to both peers, 100-102 only to the
+	// initiator and 103-106 only to the responder.
+	addTxns(t, initiator, 0, 103)
+	addTxns(t, responder, 0, 100)
+	addTxns(t, responder, 103, 107)
+
+	req := initiator.StartRound()
+	if req == nil || req.SetSize != 103 {
+		t.Fatalf("StartRound: unexpected request %v", req)
+	}
+	if initiator.StartRound() != nil {
+		t.Fatal("StartRound: started a round while one is in progress")
+	}
+
+	// New transactions are reconciled in the next round.
+	addTxns(t, initiator, 200, 201)
+
+	sketch, err := responder.HandleReqRecon(req)
+	if err != nil {
+		t.Fatalf("HandleReqRecon: unexpected error: %v", err)
+	}
+	reply, announce, err := initiator.HandleSketch(sketch)
+	if err != nil {
+		t.Fatalf("HandleSketch: unexpected error: %v", err)
+	}
+	diff, ok := reply.(*wire.MsgReconcilDiff)
+	if !ok || !diff.Success || len(diff.AskShortIDs) != 4 {
+		t.Fatalf("HandleSketch: unexpected reply %v", reply)
+	}
+	checkHashes(t, "initiator announcements", announce, txRange(100, 103))
+
+	announce, err = responder.HandleReconcilDiff(diff)
+	if err != nil {
+		t.Fatalf("HandleReconcilDiff: unexpected error: %v", err)
+	}
+	checkHashes(t, "responder announcements", announce, txRange(103, 107))
+
+	if size := initiator.SetSize(); size != 1 {
+		t.Fatalf("SetSize: got %d, want 1", size)
+	}
+	if _, err := responder.HandleReconcilDiff(diff); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReconcilDiff: got %v, want %v", err,
+			ErrUnexpectedMessage)
+	}
+}
+
+// TestReconciliationExtension ensures the sketch is extended when the
+// difference exceeds its capacity and that both peers announce their sets
+// when the extension does not suffice either.
+func TestReconciliationExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		onlyRemote  int
+		wantSuccess bool
+	}{
+		{"extension", 20, true},
+		{"fallback", 40, false},
+	}
+	for _, test := range tests {
+		initiator := NewPeerState(true, 1, 2)
+		responder := NewPeerState(false, 2, 1)
+
+		// The sets have equal sizes, so the estimated capacity of 25
+		// is too small for a difference of 2*onlyRemote.
+		addTxns(t, initiator, 0, 100)
+		addTxns(t, responder, 0, 100-test.onlyRemote)
+		addTxns(t, responder, 1000, 1000+test.onlyRemote)
+
+		sketch, err := responder.HandleReqRecon(initiator.StartRound())
+		if err != nil {
+			t.Fatalf("%s: HandleReqRecon: unexpected error: %v",
+				test.name, err)
+		}
+		reply, _, err := initiator.HandleSketch(sketch)
+		if err != nil {
+			t.Fatalf("%s: HandleSketch: unexpected error: %v",
+				test.name, err)
+		}
+		if _, ok := reply.(*wire.MsgReqSketchExt); !ok {
+			t.Fatalf("%s: HandleSketch: unexpected reply %v",
+				test.name, reply)
+		}
+
+		ext, err := responder.HandleReqSketchExt()
+		if err != nil {
+			t.Fatalf("%s: HandleReqSketchExt: unexpected error: %v",
+				test.name, err)
+		}
+		reply, initiatorAnnounce, err := initiator.HandleSketch(ext)
+		if err != nil {
+			t.Fatalf("%s: HandleSketch: unexpected error: %v",
+				test.name, err)
+		}
+		diff, ok := reply.(*wire.MsgReconcilDiff)
+		if !ok || diff.Success != test.wantSuccess {
+			t.Fatalf("%s: HandleSketch: unexpected reply %v",
+				test.name, reply)
+		}
+		responderAnnounce, err := responder.HandleReconcilDiff(diff)
+		if err != nil {
+			t.Fatalf("%s: HandleReconcilDiff: unexpected error: %v",
+				test.name, err)
+		}
+
+		if test.wantSuccess {
+			checkHashes(t, test.name, initiatorAnnounce,
+				txRange(100-test.onlyRemote, 100))
+			checkHashes(t, test.name, responderAnnounce,
+				txRange(1000, 1000+test.onlyRemote))
+		} else if len(initiatorAnnounce) != 100 ||
+			len(responderAnnounce) != 100 {
+
+			t.Fatalf("%s: got %d and %d announcements, want 100",
+				test.name, len(initiatorAnnounce),
+				len(responderAnnounce))
+		}
+	}
+}
+
+// TestReconciliationUnexpected ensures messages that are not expected in the
+// state of the reconciliation are rejected.
+func TestReconciliationUnexpected(t *testing.T) {
+	initiator := NewPeerState(true, 1, 2)
+	responder := NewPeerState(false, 2, 1)
+
+	if _, err := initiator.HandleReqRecon(wire.NewMsgReqRecon(0, 0)); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReqRecon: got %v, want %v", err,
+			ErrUnexpectedMessage)
+	}
+	if _, _, err := initiator.HandleSketch(wire.NewMsgSketch(nil)); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleSketch: got %v, want %v", err,
+			ErrUnexpectedMessage)
+	}
+	if _, err := responder.HandleReqSketchExt(); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReqSketchExt: got %v, want %v", err,
+			ErrUnexpectedMessage)
+	}
+	diff := wire.NewMsgReconcilDiff(false, nil)
+	if _, err := responder.HandleReconcilDiff(diff); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReconcilDiff: got %v, want %v", err,
+			ErrUnexpectedMessage)
+	}
+
+	// Too large differences are not reconciled.
+	addTxns(t, initiator, 0, MaxCapacity)
+	sketch, err := responder.HandleReqRecon(initiator.StartRound())
+	if err != nil || len(sketch.Sketch) != 0 {
+		t.Fatalf("HandleReqRecon: got %v, %v, want empty sketch",
+			sketch, err)
+	}
+	reply, announce, err := initiator.HandleSketch(sketch)
+	if err != nil || len(announce) != MaxCapacity {
+		t.Fatalf("HandleSketch: got %d announcements, %v", len(announce),
+			err)
+	}
+	if diff, ok := reply.(*wire.MsgReconcilDiff); !ok || diff.Success {
+		t.Fatalf("HandleSketch: unexpected reply %v", reply)
+	}
+}
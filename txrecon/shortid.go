@@ -0,0 +1,39 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txrecon
+
+import (
+	"encoding/binary"
+
+	"github.com/aead/siphash"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// saltTag is the tag of the hash the salts of both peers are combined with to
+// derive the key of the short IDs.
+var saltTag = []byte("Tx Relay Salting")
+
+// ShortIDKey returns the SipHash key used to compute the short IDs of the
+// transactions reconciled between two peers from their salts.  The salts are
+// combined in ascending order, so both peers derive the same key.
+func ShortIDKey(salt1, salt2 uint64) [16]byte {
+	if salt1 > salt2 {
+		salt1, salt2 = salt2, salt1
+	}
+	var salts [16]byte
+	binary.LittleEndian.PutUint64(salts[:8], salt1)
+	binary.LittleEndian.PutUint64(salts[8:], salt2)
+	hash := chainhash.TaggedHash(saltTag, salts[:])
+
+	var key [16]byte
+	copy(key[:], hash[:16])
+	return key
+}
+
+// ShortID returns the 32-bit short ID of the transaction with the passed
+// witness hash, which is a nonzero sketch element.
+func ShortID(key *[16]byte, wtxid *chainhash.Hash) uint32 {
+	return uint32(1 + siphash.Sum64(wtxid[:], key)%0xffffffff)
+}
@@ -0,0 +1,383 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txrecon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// fieldPoly is the low part of the irreducible polynomial
+// x^32 + x^7 + x^3 + x^2 + 1 that defines the field GF(2^32) sketch elements
+// belong to.
+const fieldPoly = 0x8d
+
+// maxSplitAttempts is the maximum number of attempts to split a polynomial
+// into two factors while finding its roots.  Every attempt succeeds with a
+// probability of at least one half.
+const maxSplitAttempts = 64
+
+// ErrDecode is returned by Sketch.Decode when the sketch holds more elements
+// than its capacity and can't be decoded.
+var ErrDecode = errors.New("sketch can't be decoded")
+
+// gfMul returns the product of a and b in GF(2^32).
+func gfMul(a, b uint32) uint32 {
+	var r uint32
+	for b != 0 {
+		if b&1 != 0 {
+			r ^= a
+		}
+		b >>= 1
+		carry := a >> 31
+		a <<= 1
+		if carry != 0 {
+			a ^= fieldPoly
+		}
+	}
+	return r
+}
+
+// gfInv returns the multiplicative inverse of the passed nonzero element,
+// which is a^(2^32-2).
+func gfInv(a uint32) uint32 {
+	// 2^32-2 consists of 31 one bits followed by a zero bit.
+	r := uint32(1)
+	for i := 0; i < 31; i++ {
+		r = gfMul(gfMul(r, r), a)
+	}
+	return gfMul(r, r)
+}
+
+// Sketch is a PinSketch of a set of nonzero 32-bit elements.  A sketch with a
+// capacity of c consists of the odd power sums x^1, x^3, ..., x^(2c-1) over
+// the elements x of the set in GF(2^32), which is 4*c bytes regardless of
+// the size of the set.  Since adding an element twice removes it again, the
+// sum of the sketches of two sets is the sketch of their symmetric difference,
+// which can be decoded as long as it has at most c elements.
+//
+// The syndromes of a sketch are a prefix of the syndromes of a sketch of the
+// same set with a higher capacity, so a sketch can be extended by sending the
+// additional syndromes.
+type Sketch struct {
+	syndromes []uint32
+}
+
+// NewSketch returns an empty sketch with the passed capacity.
+func NewSketch(capacity int) *Sketch {
+	return &Sketch{syndromes: make([]uint32, capacity)}
+}
+
+// ParseSketch parses a sketch serialized with Serialize.
+func ParseSketch(serialized []byte) (*Sketch, error) {
+	if len(serialized)%4 != 0 {
+		return nil, fmt.Errorf("malformed sketch of %d bytes",
+			len(serialized))
+	}
+
+	s := NewSketch(len(serialized) / 4)
+	for i := range s.syndromes {
+		s.syndromes[i] = binary.LittleEndian.Uint32(serialized[i*4:])
+	}
+	return s, nil
+}
+
+// Capacity returns the maximum number of elements that can be decoded from the
+// sketch.
+func (s *Sketch) Capacity() int {
+	return len(s.syndromes)
+}
+
+// Add adds the passed element to the sketch or removes it when it was already
+// added.  The element must not be zero.
+func (s *Sketch) Add(element uint32) {
+	square := gfMul(element, element)
+	power := element
+	for i := range s.syndromes {
+		s.syndromes[i] ^= power
+		power = gfMul(power, square)
+	}
+}
+
+// Merge adds the elements of the passed sketch to the sketch, which results in
+// a sketch of the symmetric difference of both sets.  Both sketches must have
+// the same capacity.
+func (s *Sketch) Merge(other *Sketch) {
+	for i := range s.syndromes {
+		s.syndromes[i] ^= other.syndromes[i]
+	}
+}
+
+// Serialize returns the serialized sketch, which consists of the little-endian
+// syndromes.
+func (s *Sketch) Serialize() []byte {
+	serialized := make([]byte, len(s.syndromes)*4)
+	for i, syndrome := range s.syndromes {
+		binary.LittleEndian.PutUint32(serialized[i*4:], syndrome)
+	}
+	return serialized
+}
+
+// Decode returns the elements of the set the sketch was built from.  It
+// returns ErrDecode when the set has more elements than the capacity of the
+// sketch.
+func (s *Sketch) Decode() ([]uint32, error) {
+	c := len(s.syndromes)
+
+	// Derive the even power sums from the odd ones, which is possible
+	// since (a+b)^2 = a^2 + b^2 in fields of characteristic 2.
+	sums := make([]uint32, 2*c)
+	for i := range sums {
+		if i%2 == 0 {
+			sums[i] = s.syndromes[i/2]
+		} else {
+			half := sums[i/2]
+			sums[i] = gfMul(half, half)
+		}
+	}
+
+	locator := berlekampMassey(sums)
+	degree := len(locator) - 1
+	if degree == 0 {
+		return nil, nil
+	}
+	if degree > c || locator[degree] == 0 {
+		return nil, ErrDecode
+	}
+
+	// The elements are the inverses of the roots of the error locator
+	// polynomial, which are the roots of the reversed polynomial.
+	reversed := make(poly, degree+1)
+	for i, coef := range locator {
+		reversed[degree-i] = coef
+	}
+	roots, ok := findRoots(reversed)
+	if !ok {
+		return nil, ErrDecode
+	}
+
+	// Ensure the elements reproduce the sketch, which guards against
+	// decoding sets that are larger than the capacity to garbage.
+	check := NewSketch(c)
+	for _, root := range roots {
+		check.Add(root)
+	}
+	for i := range check.syndromes {
+		if check.syndromes[i] != s.syndromes[i] {
+			return nil, ErrDecode
+		}
+	}
+	return roots, nil
+}
+
+// poly is a polynomial over GF(2^32) with the coefficients ordered from the
+// constant term to the highest degree term.
+type poly []uint32
+
+// trim removes the leading zero coefficients of the polynomial.
+func (p poly) trim() poly {
+	for len(p) > 0 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// berlekampMassey returns the shortest linear feedback shift register, in the
+// form of its connection polynomial, that generates the passed sequence.  The
+// degree of the returned polynomial is the length of the register, so its
+// leading coefficient may be zero.  For the power sums of a set, it is the
+// error locator polynomial whose roots are the inverses of the elements of the
+// set.
+func berlekampMassey(sums []uint32) poly {
+	current := poly{1}
+	prev := poly{1}
+	var length int
+	shift := 1
+	prevDiscrepancy := uint32(1)
+	for n := range sums {
+		discrepancy := sums[n]
+		for i := 1; i <= length && i < len(current); i++ {
+			discrepancy ^= gfMul(current[i], sums[n-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+
+		factor := gfMul(discrepancy, gfInv(prevDiscrepancy))
+		next := make(poly, maxInt(len(current), len(prev)+shift))
+		copy(next, current)
+		for i, coef := range prev {
+			next[i+shift] ^= gfMul(factor, coef)
+		}
+		if 2*length <= n {
+			prev = current
+			length = n + 1 - length
+			prevDiscrepancy = discrepancy
+			shift = 1
+		} else {
+			shift++
+		}
+		current = next
+	}
+	locator := make(poly, length+1)
+	copy(locator, current)
+	return locator
+}
+
+// maxInt returns the larger of the passed integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// polyMod returns a mod m for a monic polynomial m.
+func polyMod(a, m poly) poly {
+	r := append(poly(nil), a...)
+	degree := len(m) - 1
+	for i := len(r) - 1; i >= degree; i-- {
+		coef := r[i]
+		if coef == 0 {
+			continue
+		}
+		for j := 0; j < degree; j++ {
+			r[i-degree+j] ^= gfMul(coef, m[j])
+		}
+		r[i] = 0
+	}
+	return r.trim()
+}
+
+// polySquareMod returns a^2 mod m for a monic polynomial m.  Squaring is linear
+// in fields of characteristic 2, so the square only consists of the squared
+// coefficients.
+func polySquareMod(a, m poly) poly {
+	if len(a) == 0 {
+		return nil
+	}
+	square := make(poly, 2*len(a)-1)
+	for i, coef := range a {
+		square[2*i] = gfMul(coef, coef)
+	}
+	return polyMod(square, m)
+}
+
+// polyMonic returns the passed nonzero polynomial divided by its leading
+// coefficient.
+func polyMonic(p poly) poly {
+	inv := gfInv(p[len(p)-1])
+	monic := make(poly, len(p))
+	for i, coef := range p {
+		monic[i] = gfMul(coef, inv)
+	}
+	return monic
+}
+
+// polyGCD returns the monic greatest common divisor of the passed polynomials,
+// the first of which must be monic and nonzero.
+func polyGCD(a, b poly) poly {
+	for len(b) > 0 {
+		a, b = b, polyMod(a, polyMonic(b))
+	}
+	return polyMonic(a)
+}
+
+// polyDiv returns a / b for a monic polynomial b that divides a.
+func polyDiv(a, b poly) poly {
+	r := append(poly(nil), a...)
+	degree := len(b) - 1
+	quotient := make(poly, len(a)-degree)
+	for i := len(r) - 1; i >= degree; i-- {
+		coef := r[i]
+		quotient[i-degree] = coef
+		if coef == 0 {
+			continue
+		}
+		for j := 0; j <= degree; j++ {
+			r[i-degree+j] ^= gfMul(coef, b[j])
+		}
+	}
+	return quotient
+}
+
+// findRoots returns the roots of the passed monic polynomial.  It returns false
+// when the polynomial does not have as many distinct roots in GF(2^32) as its
+// degree.
+func findRoots(f poly) ([]uint32, bool) {
+	// A polynomial has distinct roots in GF(2^32) for all of its degree
+	// when it divides x^(2^32) - x.
+	x := poly{0, 1}
+	power := polyMod(x, f)
+	for i := 0; i < 32; i++ {
+		power = polySquareMod(power, f)
+	}
+	expected := polyMod(x, f)
+	if len(power) != len(expected) {
+		return nil, false
+	}
+	for i := range power {
+		if power[i] != expected[i] {
+			return nil, false
+		}
+	}
+
+	roots := make([]uint32, 0, len(f)-1)
+	seed := uint32(1)
+	return roots, splitRoots(f, &roots, &seed)
+}
+
+// splitRoots appends the roots of the passed monic polynomial, which must have
+// distinct roots in GF(2^32) for all of its degree, to roots.  It uses the
+// Berlekamp trace algorithm, which splits the polynomial into the factors with
+// roots r for which the trace of beta*r is zero and one for a random beta.
+func splitRoots(f poly, roots *[]uint32, seed *uint32) bool {
+	switch len(f) - 1 {
+	case 0:
+		return true
+	case 1:
+		// The root of x + a is a since -a = a.
+		*roots = append(*roots, f[0])
+		return true
+	}
+
+	for attempt := 0; attempt < maxSplitAttempts; attempt++ {
+		// Derive the next beta with a xorshift generator, which is
+		// sufficient since the roots are not chosen by an adversary
+		// who knows the sequence in a way that matters for anything
+		// but performance.
+		*seed ^= *seed << 13
+		*seed ^= *seed >> 17
+		*seed ^= *seed << 5
+		beta := *seed
+
+		// Compute the trace map Tr(beta*x) = sum (beta*x)^(2^i) mod f.
+		term := polyMod(poly{0, beta}, f)
+		trace := append(poly(nil), term...)
+		for i := 1; i < 32; i++ {
+			term = polySquareMod(term, f)
+			for len(trace) < len(term) {
+				trace = append(trace, 0)
+			}
+			for j, coef := range term {
+				trace[j] ^= coef
+			}
+		}
+		trace = trace.trim()
+		if len(trace) == 0 {
+			continue
+		}
+
+		factor := polyGCD(f, trace)
+		if len(factor) <= 1 || len(factor) == len(f) {
+			continue
+		}
+		return splitRoots(factor, roots, seed) &&
+			splitRoots(polyDiv(f, factor), roots, seed)
+	}
+	return false
+}
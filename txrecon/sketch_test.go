@@ -0,0 +1,150 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txrecon
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomElements returns count distinct random nonzero elements.
+func randomElements(rng *rand.Rand, count int) []uint32 {
+	seen := make(map[uint32]struct{}, count)
+	elements := make([]uint32, 0, count)
+	for len(elements) < count {
+		e := rng.Uint32()
+		if _, ok := seen[e]; ok || e == 0 {
+			continue
+		}
+		seen[e] = struct{}{}
+		elements = append(elements, e)
+	}
+	return elements
+}
+
+// sortedElements returns a sorted copy of the passed elements.
+func sortedElements(elements []uint32) []uint32 {
+	sorted := append([]uint32(nil), elements...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// TestGF ensures the field arithmetic is consistent.
+func TestGF(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		a, b, c := rng.Uint32(), rng.Uint32(), rng.Uint32()
+		if gfMul(a, b) != gfMul(b, a) {
+			t.Fatalf("multiplication of %x and %x not commutative",
+				a, b)
+		}
+		if gfMul(a, b^c) != gfMul(a, b)^gfMul(a, c) {
+			t.Fatalf("multiplication of %x not distributive", a)
+		}
+		if a != 0 && gfMul(a, gfInv(a)) != 1 {
+			t.Fatalf("wrong inverse %x of %x", gfInv(a), a)
+		}
+	}
+}
+
+// TestSketchDecode ensures sketches decode to the set they were built from as
+// long as it does not exceed their capacity.
+func TestSketchDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	const capacity = 64
+	for _, size := range []int{0, 1, 2, 3, 10, 63, 64} {
+		elements := randomElements(rng, size)
+		sketch := NewSketch(capacity)
+		for _, e := range elements {
+			sketch.Add(e)
+		}
+
+		decoded, err := sketch.Decode()
+		if err != nil {
+			t.Fatalf("size %d: unexpected error: %v", size, err)
+		}
+		got, want := sortedElements(decoded), sortedElements(elements)
+		if len(got) != len(want) {
+			t.Fatalf("size %d: got %d elements, want %d", size,
+				len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("size %d: got %x, want %x", size, got,
+					want)
+			}
+		}
+	}
+
+	// Sets that exceed the capacity must fail to decode.
+	for _, size := range []int{capacity + 1, 2 * capacity, 500} {
+		sketch := NewSketch(capacity)
+		for _, e := range randomElements(rng, size) {
+			sketch.Add(e)
+		}
+		if _, err := sketch.Decode(); err != ErrDecode {
+			t.Fatalf("size %d: got %v, want %v", size, err,
+				ErrDecode)
+		}
+	}
+}
+
+// TestSketchDifference ensures merging sketches yields the symmetric
+// difference of the sets and that sketches of higher capacity extend sketches
+// of lower capacity.
+func TestSketchDifference(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	common := randomElements(rng, 200)
+	onlyA := randomElements(rng, 6)
+	onlyB := randomElements(rng, 4)
+
+	build := func(capacity int, sets ...[]uint32) *Sketch {
+		sketch := NewSketch(capacity)
+		for _, set := range sets {
+			for _, e := range set {
+				sketch.Add(e)
+			}
+		}
+		return sketch
+	}
+
+	// A sketch of capacity 5 can't decode the difference of 10 elements,
+	// but extending both sketches to capacity 10 can.
+	a, b := build(5, common, onlyA), build(5, common, onlyB)
+	a.Merge(b)
+	if _, err := a.Decode(); err != ErrDecode {
+		t.Fatalf("got %v, want %v", err, ErrDecode)
+	}
+
+	extA, extB := build(10, common, onlyA), build(10, common, onlyB)
+	if !bytes.HasPrefix(extA.Serialize(), build(5, common, onlyA).Serialize()) {
+		t.Fatal("extended sketch does not extend the sketch")
+	}
+	parsed, err := ParseSketch(extB.Serialize())
+	if err != nil {
+		t.Fatalf("ParseSketch: unexpected error: %v", err)
+	}
+	extA.Merge(parsed)
+	diff, err := extA.Decode()
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %v", err)
+	}
+	want := sortedElements(append(append([]uint32(nil), onlyA...), onlyB...))
+	got := sortedElements(diff)
+	if len(got) != len(want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %x, want %x", got, want)
+		}
+	}
+
+	if _, err := ParseSketch([]byte{1, 2, 3}); err == nil {
+		t.Fatal("ParseSketch: did not fail for malformed sketch")
+	}
+}
@@ -232,6 +232,9 @@ type Engine struct {
 	// prevOutFetcher is used to look up all the previous output of
 	// taproot transactions, as that information is hashed into the
 	// sighash digest for such inputs.
+	//
+	// schnorrBatch, when set, receives the schnorr signatures of taproot
+	// spends instead of them being verified during execution.
 	flags          ScriptFlags
 	tx             wire.MsgTx
 	txIdx          int
@@ -240,6 +243,7 @@ type Engine struct {
 	sigCache       *SigCache
 	hashCache      *TxSigHashes
 	prevOutFetcher PrevOutputFetcher
+	schnorrBatch   SchnorrBatchVerifier
 
 	// The following fields handle keeping track of the current execution state
 	// of the engine.
@@ -656,9 +660,10 @@ func (vm *Engine) verifyWitnessProgram(witness wire.TxWitness) error {
 			// removing the annex), we'll do normal taproot
 			// keyspend validation.
 			rawSig := witness[0]
-			err := VerifyTaprootKeySpend(
+			err := verifyTaprootKeySpend(
 				vm.witnessProgram, rawSig, &vm.tx, vm.txIdx,
 				vm.prevOutFetcher, vm.hashCache, vm.sigCache,
+				vm.schnorrBatch,
 			)
 			if err != nil {
 				// TODO(roasbeef): proper error
@@ -1459,6 +1464,16 @@ func (vm *Engine) SetAltStack(data [][]byte) {
 	setStack(&vm.astack, data)
 }
 
+// SetSchnorrBatch sets the batch the schnorr signatures of taproot spends are
+// deferred to instead of verifying them during execution.  The signatures in
+// the batch MUST be verified once the script has been executed since it is
+// only valid when all of them are.  See SchnorrBatchVerifier for details.
+//
+// It must be called before executing the script.
+func (vm *Engine) SetSchnorrBatch(batch SchnorrBatchVerifier) {
+	vm.schnorrBatch = batch
+}
+
 // NewEngine returns a new script engine for the provided public key script,
 // transaction, and input index.  The flags modify the behavior of the script
 // engine according to the description provided by each flag.
@@ -0,0 +1,46 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package miniscript implements Miniscript, a structured representation of a
+subset of the bitcoin script language which can be analyzed, composed and
+satisfied generically, along with a compiler from the higher level spending
+policy language to Miniscript.
+
+A description of Miniscript can be found at https://bitcoin.sipa.be/miniscript.
+This package implements Miniscript for P2WSH witness scripts.
+
+# Parsing and Type Checking
+
+Miniscript expressions such as "and_v(v:pk(K1),or_d(pk(K2),older(144)))" are
+parsed with Parse, which also type checks them according to the correctness
+type system of Miniscript.  Keys are hex-encoded compressed public keys and
+hashes are hex-encoded digests.  The resulting Node tree can be converted back
+to its string representation with String and compiled to the witness script
+with Script.
+
+# Spending Policies
+
+Spending policies such as "or(99@pk(K1),and(pk(K2),older(144)))" describe the
+conditions under which coins can be spent without choosing how to express them
+in script.  CompilePolicy parses a policy and compiles it to a Miniscript
+expression.  The compiler is straightforward and doesn't search for the
+smallest possible script, but it orders the branches of disjunctions by their
+probabilities so the likelier branches are cheaper to satisfy.
+
+# Satisfaction
+
+The SatisfactionBounds method of a Node computes the minimum and maximum size
+of the witness stack satisfying the script, which is needed to estimate the fee
+of spending transactions before they are signed.  The WitnessTemplates method
+lists the witness stacks of all the ways to satisfy the script, made of
+placeholders for the signatures and preimages they require along with the lock
+times they rely on, while Satisfy assembles the smallest witness stack the
+signatures and preimages provided by a Satisfier allow.
+
+The non-malleability properties of Miniscript are not analyzed, so a script
+which can be satisfied in a malleable way is not rejected and the satisfactions
+produced may be malleable by third parties.
+*/
+package miniscript
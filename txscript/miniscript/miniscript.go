@@ -0,0 +1,922 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Fragment identifies the kind of a Miniscript expression.
+type Fragment uint8
+
+// These constants are the fragments of Miniscript.  The syntactic sugar of
+// Miniscript, such as pk(K) for c:pk_k(K), has no fragments of its own and is
+// expanded by the parser instead.
+const (
+	// Frag0 is the 0 expression, which can't be satisfied.
+	Frag0 Fragment = iota
+
+	// Frag1 is the 1 expression, which is always satisfied.
+	Frag1
+
+	// FragPkK is the pk_k(key) expression, which pushes a key whose
+	// signature must be checked by a c: wrapper.
+	FragPkK
+
+	// FragPkH is the pk_h(key) expression, which pushes a key matching a
+	// key hash whose signature must be checked by a c: wrapper.
+	FragPkH
+
+	// FragOlder is the older(n) expression, which requires a relative
+	// lock time of n.
+	FragOlder
+
+	// FragAfter is the after(n) expression, which requires an absolute
+	// lock time of n.
+	FragAfter
+
+	// FragSha256 is the sha256(h) expression, which requires a preimage of
+	// the SHA256 hash h.
+	FragSha256
+
+	// FragHash256 is the hash256(h) expression, which requires a preimage
+	// of the double SHA256 hash h.
+	FragHash256
+
+	// FragRipemd160 is the ripemd160(h) expression, which requires a
+	// preimage of the RIPEMD160 hash h.
+	FragRipemd160
+
+	// FragHash160 is the hash160(h) expression, which requires a preimage
+	// of the RIPEMD160(SHA256) hash h.
+	FragHash160
+
+	// FragAndOr is the andor(X,Y,Z) expression, which requires either both
+	// X and Y or Z.
+	FragAndOr
+
+	// FragAndV is the and_v(X,Y) expression, which requires both X, which
+	// must be verified, and Y.
+	FragAndV
+
+	// FragAndB is the and_b(X,Y) expression, which requires both X and Y.
+	FragAndB
+
+	// FragOrB is the or_b(X,Z) expression, which requires either X or Z,
+	// both of which are evaluated.
+	FragOrB
+
+	// FragOrC is the or_c(X,Z) expression, which requires either X or Z,
+	// where Z must be verified.
+	FragOrC
+
+	// FragOrD is the or_d(X,Z) expression, which requires either X or Z.
+	FragOrD
+
+	// FragOrI is the or_i(X,Z) expression, which requires either X or Z,
+	// chosen by the satisfier.
+	FragOrI
+
+	// FragThresh is the thresh(k,X1,...,Xn) expression, which requires k
+	// of the n sub-expressions.
+	FragThresh
+
+	// FragMulti is the multi(k,key1,...,keyn) expression, which requires
+	// signatures of k of the n keys.
+	FragMulti
+
+	// FragWrapA is the a: wrapper, which evaluates its sub-expression on
+	// the alt stack.
+	FragWrapA
+
+	// FragWrapS is the s: wrapper, which swaps the top two elements of the
+	// stack before evaluating its sub-expression.
+	FragWrapS
+
+	// FragWrapC is the c: wrapper, which checks the signature of the key
+	// pushed by its sub-expression.
+	FragWrapC
+
+	// FragWrapD is the d: wrapper, which makes its sub-expression
+	// dissatisfiable.
+	FragWrapD
+
+	// FragWrapV is the v: wrapper, which verifies its sub-expression.
+	FragWrapV
+
+	// FragWrapJ is the j: wrapper, which skips its sub-expression when the
+	// top element of the stack is empty.
+	FragWrapJ
+
+	// FragWrapN is the n: wrapper, which turns the nonzero result of its
+	// sub-expression into exactly 1.
+	FragWrapN
+)
+
+// fragmentNames maps the fragments to their names in Miniscript expressions.
+// The wrappers are named after their letter.
+var fragmentNames = map[Fragment]string{
+	Frag0:         "0",
+	Frag1:         "1",
+	FragPkK:       "pk_k",
+	FragPkH:       "pk_h",
+	FragOlder:     "older",
+	FragAfter:     "after",
+	FragSha256:    "sha256",
+	FragHash256:   "hash256",
+	FragRipemd160: "ripemd160",
+	FragHash160:   "hash160",
+	FragAndOr:     "andor",
+	FragAndV:      "and_v",
+	FragAndB:      "and_b",
+	FragOrB:       "or_b",
+	FragOrC:       "or_c",
+	FragOrD:       "or_d",
+	FragOrI:       "or_i",
+	FragThresh:    "thresh",
+	FragMulti:     "multi",
+	FragWrapA:     "a",
+	FragWrapS:     "s",
+	FragWrapC:     "c",
+	FragWrapD:     "d",
+	FragWrapV:     "v",
+	FragWrapJ:     "j",
+	FragWrapN:     "n",
+}
+
+// wrapperFragments maps the letters of the wrappers to their fragments.
+var wrapperFragments = map[byte]Fragment{
+	'a': FragWrapA,
+	's': FragWrapS,
+	'c': FragWrapC,
+	'd': FragWrapD,
+	'v': FragWrapV,
+	'j': FragWrapJ,
+	'n': FragWrapN,
+}
+
+// String returns the name of the fragment in Miniscript expressions.
+func (f Fragment) String() string {
+	if name, ok := fragmentNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown Fragment (%d)", uint8(f))
+}
+
+// isWrapper returns whether the fragment is a wrapper.
+func (f Fragment) isWrapper() bool {
+	return f >= FragWrapA && f <= FragWrapN
+}
+
+// hashSize returns the size of the hash of hash fragments, or 0 for the other
+// fragments.
+func (f Fragment) hashSize() int {
+	switch f {
+	case FragSha256, FragHash256:
+		return 32
+	case FragRipemd160, FragHash160:
+		return 20
+	}
+	return 0
+}
+
+// Node is a Miniscript expression.  It can be created by parsing an
+// expression with Parse or by hand, in which case Type should be used to make
+// sure it is valid.
+type Node struct {
+	// Fragment is the kind of the expression.
+	Fragment Fragment
+
+	// K is the threshold of thresh and multi expressions and the lock time
+	// of older and after expressions.
+	K uint32
+
+	// Keys are the serialized compressed public keys of the expression,
+	// which pk_k and pk_h expressions have one of.
+	Keys [][]byte
+
+	// Hash is the hash of hash expressions.
+	Hash []byte
+
+	// Subs are the sub-expressions of the expression.
+	Subs []*Node
+}
+
+// Type is a bit field holding the basic type and the properties of a
+// Miniscript expression, as defined by the correctness type system of
+// Miniscript.
+type Type uint16
+
+const (
+	// TypeB is the base type of expressions which consume their inputs
+	// and push a nonzero value when satisfied or an exact 0 when
+	// dissatisfied.
+	TypeB Type = 1 << iota
+
+	// TypeV is the verify type of expressions which consume their inputs
+	// and push nothing when satisfied, and can't be dissatisfied.
+	TypeV
+
+	// TypeK is the key type of expressions which consume their inputs and
+	// push a public key whose signature is to be checked.
+	TypeK
+
+	// TypeW is the wrapped type of expressions which consume their inputs
+	// from below the top element of the stack and push their result,
+	// like a TypeB expression, on top of it.
+	TypeW
+
+	// TypeZ is the zero-arg property of expressions which always consume
+	// exactly 0 stack elements.
+	TypeZ
+
+	// TypeO is the one-arg property of expressions which always consume
+	// exactly 1 stack element.
+	TypeO
+
+	// TypeN is the nonzero property of expressions whose satisfactions
+	// never require the top input to be zero.
+	TypeN
+
+	// TypeD is the dissatisfiable property of expressions which can be
+	// dissatisfied without aborting the script.
+	TypeD
+
+	// TypeU is the unit property of expressions which push exactly 1 when
+	// satisfied.
+	TypeU
+)
+
+// basicTypes is the mask of the basic types of a Type.
+const basicTypes = TypeB | TypeV | TypeK | TypeW
+
+// String returns the letters of the basic type and the properties of the type,
+// such as "Bdu".
+func (t Type) String() string {
+	var s strings.Builder
+	for i, letter := range "BVKWzondu" {
+		if t&(1<<uint(i)) != 0 {
+			s.WriteRune(letter)
+		}
+	}
+	return s.String()
+}
+
+// has returns whether the type has all of the passed types and properties.
+func (t Type) has(want Type) bool {
+	return t&want == want
+}
+
+// Type type checks the expression and returns its type.  An error is
+// returned if the expression, or any of its sub-expressions, is invalid.
+func (n *Node) Type() (Type, error) {
+	subTypes := make([]Type, len(n.Subs))
+	for i, sub := range n.Subs {
+		if sub == nil {
+			return 0, fmt.Errorf("%v: missing sub-expression",
+				n.Fragment)
+		}
+
+		subType, err := sub.Type()
+		if err != nil {
+			return 0, err
+		}
+		subTypes[i] = subType
+	}
+
+	if err := n.checkArgs(); err != nil {
+		return 0, fmt.Errorf("%v: %w", n.Fragment, err)
+	}
+
+	t := computeType(n.Fragment, subTypes)
+	if t&basicTypes == 0 {
+		return 0, fmt.Errorf("%v: invalid types of sub-expressions "+
+			"%v", n.Fragment, subTypes)
+	}
+
+	return t, nil
+}
+
+// checkArgs ensures the expression has the number of sub-expressions and the
+// arguments its fragment requires, and that they are in range.
+func (n *Node) checkArgs() error {
+	numSubs, numKeys, hashSize := 0, 0, n.Fragment.hashSize()
+	switch n.Fragment {
+	case Frag0, Frag1:
+
+	case FragPkK, FragPkH:
+		numKeys = 1
+
+	case FragOlder, FragAfter:
+		if n.K < 1 || n.K > math.MaxInt32 {
+			return fmt.Errorf("lock time %d out of range", n.K)
+		}
+
+	case FragSha256, FragHash256, FragRipemd160, FragHash160:
+		if len(n.Hash) != hashSize {
+			return fmt.Errorf("hash of %d bytes instead of %d",
+				len(n.Hash), hashSize)
+		}
+
+	case FragAndOr:
+		numSubs = 3
+
+	case FragAndV, FragAndB, FragOrB, FragOrC, FragOrD, FragOrI:
+		numSubs = 2
+
+	case FragThresh:
+		numSubs = len(n.Subs)
+		if n.K < 1 || int(n.K) > numSubs {
+			return fmt.Errorf("threshold %d out of range for %d "+
+				"sub-expressions", n.K, numSubs)
+		}
+
+	case FragMulti:
+		numKeys = len(n.Keys)
+		if numKeys > txscript.MaxPubKeysPerMultiSig {
+			return fmt.Errorf("%d keys exceed the maximum of %d",
+				numKeys, txscript.MaxPubKeysPerMultiSig)
+		}
+		if n.K < 1 || int(n.K) > numKeys {
+			return fmt.Errorf("threshold %d out of range for %d "+
+				"keys", n.K, numKeys)
+		}
+
+	default:
+		if !n.Fragment.isWrapper() {
+			return errors.New("unknown fragment")
+		}
+		numSubs = 1
+	}
+
+	if len(n.Subs) != numSubs {
+		return fmt.Errorf("%d sub-expressions instead of %d",
+			len(n.Subs), numSubs)
+	}
+	if len(n.Keys) != numKeys {
+		return fmt.Errorf("%d keys instead of %d", len(n.Keys),
+			numKeys)
+	}
+	if hashSize == 0 && n.Hash != nil {
+		return errors.New("unexpected hash")
+	}
+	for _, key := range n.Keys {
+		if err := checkKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkKey ensures the passed key is a valid compressed public key.
+func checkKey(key []byte) error {
+	if len(key) != btcec.PubKeyBytesLenCompressed {
+		return fmt.Errorf("key %x is not a compressed public key", key)
+	}
+	if _, err := btcec.ParsePubKey(key); err != nil {
+		return fmt.Errorf("invalid key %x: %v", key, err)
+	}
+	return nil
+}
+
+// computeType returns the type of an expression of the passed fragment with
+// sub-expressions of the passed types, or 0 if their types are invalid for
+// the fragment.
+func computeType(frag Fragment, subs []Type) Type {
+	// prop returns the passed property if the condition holds.
+	prop := func(cond bool, p Type) Type {
+		if cond {
+			return p
+		}
+		return 0
+	}
+
+	var x, y, z Type
+	switch len(subs) {
+	case 3:
+		z = subs[2]
+		fallthrough
+	case 2:
+		y = subs[1]
+		fallthrough
+	case 1:
+		x = subs[0]
+	}
+
+	switch frag {
+	case Frag0:
+		return TypeB | TypeZ | TypeU | TypeD
+
+	case Frag1:
+		return TypeB | TypeZ | TypeU
+
+	case FragPkK:
+		return TypeK | TypeO | TypeN | TypeD | TypeU
+
+	case FragPkH:
+		return TypeK | TypeN | TypeD | TypeU
+
+	case FragOlder, FragAfter:
+		return TypeB | TypeZ
+
+	case FragSha256, FragHash256, FragRipemd160, FragHash160:
+		return TypeB | TypeO | TypeN | TypeD | TypeU
+
+	case FragAndOr:
+		base := y & basicTypes
+		if !x.has(TypeB|TypeD|TypeU) || base != z&basicTypes ||
+			base == TypeW {
+
+			return 0
+		}
+		return base |
+			prop(x.has(TypeZ) && y.has(TypeZ) && z.has(TypeZ), TypeZ) |
+			prop(x.has(TypeZ) && y.has(TypeO) && z.has(TypeO) ||
+				x.has(TypeO) && y.has(TypeZ) && z.has(TypeZ), TypeO) |
+			prop(y.has(TypeU) && z.has(TypeU), TypeU) |
+			prop(z.has(TypeD), TypeD)
+
+	case FragAndV:
+		base := y & basicTypes
+		if !x.has(TypeV) || base == TypeW {
+			return 0
+		}
+		return base |
+			prop(x.has(TypeZ) && y.has(TypeZ), TypeZ) |
+			prop(x.has(TypeZ) && y.has(TypeO) ||
+				x.has(TypeO) && y.has(TypeZ), TypeO) |
+			prop(x.has(TypeN) || x.has(TypeZ) && y.has(TypeN), TypeN) |
+			prop(y.has(TypeU), TypeU)
+
+	case FragAndB:
+		if !x.has(TypeB) || !y.has(TypeW) {
+			return 0
+		}
+		return TypeB | TypeU |
+			prop(x.has(TypeZ) && y.has(TypeZ), TypeZ) |
+			prop(x.has(TypeZ) && y.has(TypeO) ||
+				x.has(TypeO) && y.has(TypeZ), TypeO) |
+			prop(x.has(TypeN) || x.has(TypeZ) && y.has(TypeN), TypeN) |
+			prop(x.has(TypeD) && y.has(TypeD), TypeD)
+
+	case FragOrB:
+		if !x.has(TypeB|TypeD) || !y.has(TypeW|TypeD) {
+			return 0
+		}
+		return TypeB | TypeD | TypeU |
+			prop(x.has(TypeZ) && y.has(TypeZ), TypeZ) |
+			prop(x.has(TypeZ) && y.has(TypeO) ||
+				x.has(TypeO) && y.has(TypeZ), TypeO)
+
+	case FragOrC:
+		if !x.has(TypeB|TypeD|TypeU) || !y.has(TypeV) {
+			return 0
+		}
+		return TypeV |
+			prop(x.has(TypeZ) && y.has(TypeZ), TypeZ) |
+			prop(x.has(TypeO) && y.has(TypeZ), TypeO)
+
+	case FragOrD:
+		if !x.has(TypeB|TypeD|TypeU) || !y.has(TypeB) {
+			return 0
+		}
+		return TypeB |
+			prop(x.has(TypeZ) && y.has(TypeZ), TypeZ) |
+			prop(x.has(TypeO) && y.has(TypeZ), TypeO) |
+			prop(y.has(TypeD), TypeD) |
+			prop(y.has(TypeU), TypeU)
+
+	case FragOrI:
+		base := x & basicTypes
+		if base != y&basicTypes || base == TypeW {
+			return 0
+		}
+		return base |
+			prop(x.has(TypeZ) && y.has(TypeZ), TypeO) |
+			prop(x.has(TypeU) && y.has(TypeU), TypeU) |
+			prop(x.has(TypeD) || y.has(TypeD), TypeD)
+
+	case FragThresh:
+		numZero, numOne := 0, 0
+		for i, sub := range subs {
+			want := TypeW | TypeD | TypeU
+			if i == 0 {
+				want = TypeB | TypeD | TypeU
+			}
+			if !sub.has(want) {
+				return 0
+			}
+			switch {
+			case sub.has(TypeZ):
+				numZero++
+			case sub.has(TypeO):
+				numOne++
+			}
+		}
+		return TypeB | TypeD | TypeU |
+			prop(numZero == len(subs), TypeZ) |
+			prop(numZero == len(subs)-1 && numOne == 1, TypeO)
+
+	case FragMulti:
+		return TypeB | TypeN | TypeD | TypeU
+
+	case FragWrapA:
+		if !x.has(TypeB) {
+			return 0
+		}
+		return TypeW | x&(TypeD|TypeU)
+
+	case FragWrapS:
+		if !x.has(TypeB | TypeO) {
+			return 0
+		}
+		return TypeW | x&(TypeD|TypeU)
+
+	case FragWrapC:
+		if !x.has(TypeK) {
+			return 0
+		}
+		return TypeB | TypeU | x&(TypeO|TypeN|TypeD)
+
+	case FragWrapD:
+		// The d: wrapper only has the unit property in tapscript, where
+		// the argument of OP_IF must be minimal by consensus.
+		if !x.has(TypeV | TypeZ) {
+			return 0
+		}
+		return TypeB | TypeO | TypeN | TypeD
+
+	case FragWrapV:
+		if !x.has(TypeB) {
+			return 0
+		}
+		return TypeV | x&(TypeZ|TypeO|TypeN)
+
+	case FragWrapJ:
+		if !x.has(TypeB | TypeN) {
+			return 0
+		}
+		return TypeB | TypeN | TypeD | x&(TypeO|TypeU)
+
+	case FragWrapN:
+		if !x.has(TypeB) {
+			return 0
+		}
+		return TypeB | TypeU | x&(TypeZ|TypeO|TypeN|TypeD)
+	}
+
+	return 0
+}
+
+// Parse parses the passed Miniscript expression and type checks it.  The
+// expression must be of type B to be usable as a witness script.
+func Parse(expr string) (*Node, error) {
+	node, err := parseNode(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := node.Type()
+	if err != nil {
+		return nil, err
+	}
+	if !t.has(TypeB) {
+		return nil, fmt.Errorf("expression of type %v instead of B", t)
+	}
+
+	return node, nil
+}
+
+// parseNode parses the passed expression without type checking it.
+func parseNode(expr string) (*Node, error) {
+	// Wrappers are written as letters separated from the expression they
+	// wrap with a colon, with the outermost wrapper first.
+	open := strings.IndexByte(expr, '(')
+	colon := strings.IndexByte(expr, ':')
+	if colon != -1 && (open == -1 || colon < open) {
+		node, err := parseNode(expr[colon+1:])
+		if err != nil {
+			return nil, err
+		}
+		for i := colon - 1; i >= 0; i-- {
+			node, err = wrap(expr[i], node)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	}
+
+	if open == -1 {
+		switch expr {
+		case "0":
+			return &Node{Fragment: Frag0}, nil
+		case "1":
+			return &Node{Fragment: Frag1}, nil
+		}
+		return nil, fmt.Errorf("invalid expression %q", expr)
+	}
+	if !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("missing closing parenthesis in %q",
+			expr)
+	}
+
+	name := expr[:open]
+	args, err := splitArgs(expr[open+1 : len(expr)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	// wantArgs ensures the expression has the passed number of arguments.
+	wantArgs := func(num int) error {
+		if len(args) != num {
+			return fmt.Errorf("%s: %d arguments instead of %d",
+				name, len(args), num)
+		}
+		return nil
+	}
+
+	switch name {
+	case "pk_k", "pk_h", "pk", "pkh":
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		key, err := parseKey(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		node := &Node{Fragment: FragPkK, Keys: [][]byte{key}}
+		if name == "pk_h" || name == "pkh" {
+			node.Fragment = FragPkH
+		}
+		if name == "pk" || name == "pkh" {
+			node = &Node{Fragment: FragWrapC, Subs: []*Node{node}}
+		}
+		return node, nil
+
+	case "older", "after":
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		lockTime, err := parseUint32(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		frag := FragOlder
+		if name == "after" {
+			frag = FragAfter
+		}
+		return &Node{Fragment: frag, K: lockTime}, nil
+
+	case "sha256", "hash256", "ripemd160", "hash160":
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		hash, err := hex.DecodeString(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash %q: %v", args[0],
+				err)
+		}
+
+		frag := map[string]Fragment{
+			"sha256":    FragSha256,
+			"hash256":   FragHash256,
+			"ripemd160": FragRipemd160,
+			"hash160":   FragHash160,
+		}[name]
+		return &Node{Fragment: frag, Hash: hash}, nil
+
+	case "andor", "and_n", "and_v", "and_b", "or_b", "or_c", "or_d",
+		"or_i":
+
+		numArgs := 2
+		if name == "andor" {
+			numArgs = 3
+		}
+		if err := wantArgs(numArgs); err != nil {
+			return nil, err
+		}
+		subs, err := parseSubs(args)
+		if err != nil {
+			return nil, err
+		}
+
+		// The and_n(X,Y) expression is andor(X,Y,0).
+		if name == "and_n" {
+			name = "andor"
+			subs = append(subs, &Node{Fragment: Frag0})
+		}
+
+		frag := map[string]Fragment{
+			"andor": FragAndOr,
+			"and_v": FragAndV,
+			"and_b": FragAndB,
+			"or_b":  FragOrB,
+			"or_c":  FragOrC,
+			"or_d":  FragOrD,
+			"or_i":  FragOrI,
+		}[name]
+		return &Node{Fragment: frag, Subs: subs}, nil
+
+	case "thresh", "multi":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s: missing arguments", name)
+		}
+		k, err := parseUint32(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "multi" {
+			keys := make([][]byte, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				key, err := parseKey(arg)
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, key)
+			}
+			return &Node{Fragment: FragMulti, K: k, Keys: keys}, nil
+		}
+
+		subs, err := parseSubs(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Fragment: FragThresh, K: k, Subs: subs}, nil
+	}
+
+	return nil, fmt.Errorf("unknown fragment %q", name)
+}
+
+// wrap returns the passed expression wrapped by the wrapper with the passed
+// letter, expanding the t:, l: and u: syntactic sugar.
+func wrap(letter byte, node *Node) (*Node, error) {
+	switch letter {
+	case 't':
+		return &Node{Fragment: FragAndV, Subs: []*Node{
+			node, {Fragment: Frag1},
+		}}, nil
+
+	case 'l':
+		return &Node{Fragment: FragOrI, Subs: []*Node{
+			{Fragment: Frag0}, node,
+		}}, nil
+
+	case 'u':
+		return &Node{Fragment: FragOrI, Subs: []*Node{
+			node, {Fragment: Frag0},
+		}}, nil
+	}
+
+	frag, ok := wrapperFragments[letter]
+	if !ok {
+		return nil, fmt.Errorf("unknown wrapper %q", letter)
+	}
+	return &Node{Fragment: frag, Subs: []*Node{node}}, nil
+}
+
+// splitArgs splits the passed arguments of an expression at the commas which
+// aren't nested in the arguments of sub-expressions.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses "+
+					"in %q", s)
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+	}
+
+	return append(args, s[start:]), nil
+}
+
+// parseSubs parses the passed sub-expressions.
+func parseSubs(args []string) ([]*Node, error) {
+	subs := make([]*Node, 0, len(args))
+	for _, arg := range args {
+		sub, err := parseNode(arg)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// parseKey parses a hex-encoded compressed public key.
+func parseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %q: %v", s, err)
+	}
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// parseUint32 parses a decimal threshold or lock time.
+func parseUint32(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return uint32(n), nil
+}
+
+// String returns the Miniscript expression, using the pk and pkh syntactic
+// sugar for the signature checks of keys.
+func (n *Node) String() string {
+	var wrappers strings.Builder
+	node := n
+	for node.Fragment.isWrapper() && len(node.Subs) == 1 {
+		if node.Fragment == FragWrapC && isKeyNode(node.Subs[0]) {
+			break
+		}
+		wrappers.WriteString(node.Fragment.String())
+		node = node.Subs[0]
+	}
+
+	s := node.unwrappedString()
+	if wrappers.Len() == 0 {
+		return s
+	}
+	return wrappers.String() + ":" + s
+}
+
+// isKeyNode returns whether the expression is a pk_k or pk_h expression.
+func isKeyNode(node *Node) bool {
+	return node != nil && (node.Fragment == FragPkK ||
+		node.Fragment == FragPkH)
+}
+
+// unwrappedString returns the expression without the wrapper prefix.
+func (n *Node) unwrappedString() string {
+	name := n.Fragment.String()
+	var args []string
+	switch n.Fragment {
+	case Frag0, Frag1:
+		return name
+
+	case FragWrapC:
+		// The signature check of a key is written as pk(key) or
+		// pkh(key).
+		name = "pk"
+		if n.Subs[0].Fragment == FragPkH {
+			name = "pkh"
+		}
+		for _, key := range n.Subs[0].Keys {
+			args = append(args, hex.EncodeToString(key))
+		}
+
+	case FragOlder, FragAfter:
+		args = append(args, strconv.FormatUint(uint64(n.K), 10))
+
+	case FragSha256, FragHash256, FragRipemd160, FragHash160:
+		args = append(args, hex.EncodeToString(n.Hash))
+
+	default:
+		if n.Fragment == FragThresh || n.Fragment == FragMulti {
+			args = append(args, strconv.FormatUint(uint64(n.K), 10))
+		}
+		for _, key := range n.Keys {
+			args = append(args, hex.EncodeToString(key))
+		}
+		for _, sub := range n.Subs {
+			args = append(args, sub.String())
+		}
+	}
+
+	return name + "(" + strings.Join(args, ",") + ")"
+}
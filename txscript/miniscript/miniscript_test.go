@@ -0,0 +1,537 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/ripemd160"
+)
+
+var (
+	// testPrivKeys are the private keys of the K1 to K4 placeholders of
+	// the test expressions.
+	testPrivKeys = makeTestPrivKeys(4)
+
+	// testPreimage is the preimage of the hashes of the test expressions.
+	testPreimage = bytes.Repeat([]byte{0x42}, 32)
+)
+
+// makeTestPrivKeys returns the passed number of deterministic private keys.
+func makeTestPrivKeys(num int) []*btcec.PrivateKey {
+	privKeys := make([]*btcec.PrivateKey, num)
+	for i := range privKeys {
+		var secret [32]byte
+		secret[31] = byte(i + 1)
+		privKeys[i], _ = btcec.PrivKeyFromBytes(secret[:])
+	}
+	return privKeys
+}
+
+// testKey returns the hex-encoded public key of the test private key with the
+// passed index.
+func testKey(i int) string {
+	return hex.EncodeToString(
+		testPrivKeys[i].PubKey().SerializeCompressed(),
+	)
+}
+
+// testHashes maps the hash placeholders of the test expressions to the
+// hex-encoded hashes of the test preimage.
+func testHashes() map[string]string {
+	sha := sha256.Sum256(testPreimage)
+	ripemd := ripemd160.New()
+	ripemd.Write(testPreimage)
+
+	return map[string]string{
+		"H256":  hex.EncodeToString(chainhash.DoubleHashB(testPreimage)),
+		"HR160": hex.EncodeToString(ripemd.Sum(nil)),
+		"H160":  hex.EncodeToString(btcutil.Hash160(testPreimage)),
+		"H":     hex.EncodeToString(sha[:]),
+	}
+}
+
+// expandTest replaces the key and hash placeholders of the passed expression
+// with test keys and hashes.
+func expandTest(expr string) string {
+	hashes := testHashes()
+	return strings.NewReplacer(
+		"K1", testKey(0), "K2", testKey(1), "K3", testKey(2),
+		"K4", testKey(3), "H256", hashes["H256"],
+		"HR160", hashes["HR160"], "H160", hashes["H160"],
+		"H", hashes["H"],
+	).Replace(expr)
+}
+
+// TestParse ensures valid expressions are parsed, type checked, printed and
+// compiled to script as expected.
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		expr   string
+		str    string // expected string if different from expr
+		typ    string
+		script string // expected disassembly with keys as K1 to K4
+	}{{
+		name:   "pk",
+		expr:   "pk(K1)",
+		typ:    "Bondu",
+		script: "K1 OP_CHECKSIG",
+	}, {
+		name: "pkh",
+		expr: "pkh(K1)",
+		typ:  "Bndu",
+		script: "OP_DUP OP_HASH160 HK1 OP_EQUALVERIFY " +
+			"OP_CHECKSIG",
+	}, {
+		name:   "c:pk_k",
+		expr:   "c:pk_k(K1)",
+		str:    "pk(K1)",
+		typ:    "Bondu",
+		script: "K1 OP_CHECKSIG",
+	}, {
+		name:   "and_v",
+		expr:   "and_v(v:pk(K1),pk(K2))",
+		typ:    "Bnu",
+		script: "K1 OP_CHECKSIGVERIFY K2 OP_CHECKSIG",
+	}, {
+		name: "or_d with timelock",
+		expr: "or_d(pk(K1),and_v(v:pk(K2),older(144)))",
+		typ:  "B",
+		script: "K1 OP_CHECKSIG OP_IFDUP OP_NOTIF K2 " +
+			"OP_CHECKSIGVERIFY 9000 OP_CHECKSEQUENCEVERIFY " +
+			"OP_ENDIF",
+	}, {
+		name: "andor",
+		expr: "andor(pk(K1),older(1000),pk(K2))",
+		typ:  "Bd",
+		script: "K1 OP_CHECKSIG OP_NOTIF K2 OP_CHECKSIG OP_ELSE " +
+			"e803 OP_CHECKSEQUENCEVERIFY OP_ENDIF",
+	}, {
+		name: "sha256",
+		expr: "sha256(H)",
+		typ:  "Bondu",
+		script: "OP_SIZE 20 OP_EQUALVERIFY OP_SHA256 H " +
+			"OP_EQUAL",
+	}, {
+		name: "thresh with wrappers",
+		expr: "thresh(2,pk(K1),s:pk(K2),sln:after(100))",
+		typ:  "Bdu",
+		script: "K1 OP_CHECKSIG OP_SWAP K2 OP_CHECKSIG OP_ADD " +
+			"OP_SWAP OP_IF 0 OP_ELSE 64 " +
+			"OP_CHECKLOCKTIMEVERIFY OP_0NOTEQUAL OP_ENDIF " +
+			"OP_ADD 2 OP_EQUAL",
+		str: "thresh(2,pk(K1),s:pk(K2),s:or_i(0,n:after(100)))",
+	}, {
+		name:   "multi",
+		expr:   "multi(2,K1,K2,K3)",
+		typ:    "Bndu",
+		script: "2 K1 K2 K3 3 OP_CHECKMULTISIG",
+	}, {
+		name: "or_i with j:",
+		expr: "or_i(pk(K1),j:pk(K2))",
+		typ:  "Bdu",
+		script: "OP_IF K1 OP_CHECKSIG OP_ELSE OP_SIZE " +
+			"OP_0NOTEQUAL OP_IF K2 OP_CHECKSIG OP_ENDIF OP_ENDIF",
+	}, {
+		name: "or_b with a:",
+		expr: "or_b(pk(K1),a:pk(K2))",
+		typ:  "Bdu",
+		script: "K1 OP_CHECKSIG OP_TOALTSTACK K2 OP_CHECKSIG " +
+			"OP_FROMALTSTACK OP_BOOLOR",
+	}, {
+		name: "t:or_c",
+		expr: "t:or_c(pk(K1),v:pk(K2))",
+		str:  "and_v(or_c(pk(K1),v:pk(K2)),1)",
+		typ:  "Bu",
+		script: "K1 OP_CHECKSIG OP_NOTIF K2 OP_CHECKSIGVERIFY " +
+			"OP_ENDIF 1",
+	}, {
+		name: "and_n",
+		expr: "and_n(pk(K1),sha256(H))",
+		str:  "andor(pk(K1),sha256(H),0)",
+		typ:  "Bdu",
+		script: "K1 OP_CHECKSIG OP_NOTIF 0 OP_ELSE OP_SIZE 20 " +
+			"OP_EQUALVERIFY OP_SHA256 H OP_EQUAL OP_ENDIF",
+	}, {
+		name: "d:v:",
+		expr: "and_b(1,adv:older(1))",
+		typ:  "Bu",
+		script: "1 OP_TOALTSTACK OP_DUP OP_IF 1 " +
+			"OP_CHECKSEQUENCEVERIFY OP_VERIFY OP_ENDIF " +
+			"OP_FROMALTSTACK OP_BOOLAND",
+	}}
+
+	hashes := testHashes()
+	for _, test := range tests {
+		node, err := Parse(expandTest(test.expr))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		wantStr := test.str
+		if wantStr == "" {
+			wantStr = test.expr
+		}
+		if got := node.String(); got != expandTest(wantStr) {
+			t.Errorf("%s: mismatched string -- got %s, want %s",
+				test.name, got, expandTest(wantStr))
+		}
+
+		typ, err := node.Type()
+		if err != nil {
+			t.Errorf("%s: unexpected type error: %v", test.name,
+				err)
+			continue
+		}
+		if typ.String() != test.typ {
+			t.Errorf("%s: mismatched type -- got %v, want %s",
+				test.name, typ, test.typ)
+		}
+
+		script, err := node.Script()
+		if err != nil {
+			t.Errorf("%s: unexpected script error: %v", test.name,
+				err)
+			continue
+		}
+		disasm, err := txscript.DisasmString(script)
+		if err != nil {
+			t.Errorf("%s: unexpected disasm error: %v", test.name,
+				err)
+			continue
+		}
+		for i := range testPrivKeys {
+			key := testPrivKeys[i].PubKey().SerializeCompressed()
+			disasm = strings.ReplaceAll(disasm,
+				hex.EncodeToString(btcutil.Hash160(key)),
+				"HK"+string(rune('1'+i)))
+			disasm = strings.ReplaceAll(disasm,
+				hex.EncodeToString(key), "K"+string(rune('1'+i)))
+		}
+		disasm = strings.ReplaceAll(disasm, hashes["H"], "H")
+		if disasm != test.script {
+			t.Errorf("%s: mismatched script -- got %s, want %s",
+				test.name, disasm, test.script)
+		}
+
+		// Parsing the string of the expression must lead to the same
+		// expression.
+		reparsed, err := Parse(node.String())
+		if err != nil {
+			t.Errorf("%s: unexpected error parsing %s: %v",
+				test.name, node.String(), err)
+			continue
+		}
+		if reparsed.String() != node.String() {
+			t.Errorf("%s: mismatched reparsed string -- got %s, "+
+				"want %s", test.name, reparsed, node)
+		}
+	}
+}
+
+// TestParseInvalid ensures invalid expressions are rejected.
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"unknown fragment", "pk_x(K1)"},
+		{"unknown wrapper", "x:pk(K1)"},
+		{"invalid key", "pk(02aa)"},
+		{"uncompressed key", "pk(04" + strings.Repeat("aa", 64) + ")"},
+		{"invalid hash size", "sha256(aabb)"},
+		{"zero timelock", "older(0)"},
+		{"timelock out of range", "after(2147483648)"},
+		{"missing argument", "and_v(v:pk(K1))"},
+		{"unbalanced parentheses", "and_v(v:pk(K1),pk(K2)"},
+		{"not of type B", "v:pk(K1)"},
+		{"or_b without W", "or_b(pk(K1),pk(K2))"},
+		{"and_v without V", "and_v(pk(K1),pk(K2))"},
+		{"or_d without du", "or_d(older(1),pk(K1))"},
+		{"thresh out of range", "thresh(3,pk(K1),s:pk(K2))"},
+		{"multi out of range", "multi(0,K1,K2)"},
+		{"s: without o", "and_b(pk(K1),s:older(1))"},
+		{"c: without K", "c:older(1)"},
+		{"d: without z", "or_b(pk(K1),a:dv:pk(K2))"},
+	}
+
+	for _, test := range tests {
+		if _, err := Parse(expandTest(test.expr)); err == nil {
+			t.Errorf("%s: parsed invalid expression %s", test.name,
+				test.expr)
+		}
+	}
+}
+
+// testSatisfier is a Satisfier signing the input of a test transaction with a
+// subset of the test keys.
+type testSatisfier struct {
+	tx        *wire.MsgTx
+	sigHashes *txscript.TxSigHashes
+	script    []byte
+	amount    int64
+	keys      map[string]*btcec.PrivateKey
+	preimage  bool
+	older     uint32
+	after     uint32
+}
+
+// Sign returns the signature of the passed key if it is one of the keys of
+// the satisfier.
+func (s *testSatisfier) Sign(pubKey []byte) ([]byte, bool) {
+	privKey, ok := s.keys[string(pubKey)]
+	if !ok {
+		return nil, false
+	}
+
+	sig, err := txscript.RawTxInWitnessSignature(
+		s.tx, s.sigHashes, 0, s.amount, s.script, txscript.SigHashAll,
+		privKey,
+	)
+	return sig, err == nil
+}
+
+// Preimage returns the test preimage if the satisfier knows it.
+func (s *testSatisfier) Preimage(Fragment, []byte) ([]byte, bool) {
+	return testPreimage, s.preimage
+}
+
+// CheckOlder returns whether the relative lock time is satisfied by the
+// sequence number of the test transaction.
+func (s *testSatisfier) CheckOlder(lockTime uint32) bool {
+	return lockTime <= s.older
+}
+
+// CheckAfter returns whether the absolute lock time is satisfied by the lock
+// time of the test transaction.
+func (s *testSatisfier) CheckAfter(lockTime uint32) bool {
+	return lockTime <= s.after
+}
+
+// TestSatisfy ensures the witnesses produced by Satisfy spend the P2WSH outputs
+// of the expressions, and that they are within the bounds computed for the
+// expressions and their witness templates.
+func TestSatisfy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		expr     string
+		keys     []int
+		preimage bool
+		older    uint32
+		after    uint32
+		fail     bool
+	}{{
+		name: "pk",
+		expr: "pk(K1)",
+		keys: []int{0},
+	}, {
+		name: "pk without key",
+		expr: "pk(K1)",
+		keys: []int{1},
+		fail: true,
+	}, {
+		name: "and_v",
+		expr: "and_v(v:pk(K1),pkh(K2))",
+		keys: []int{0, 1},
+	}, {
+		name:  "or_d second branch",
+		expr:  "or_d(pk(K1),and_v(v:pk(K2),older(144)))",
+		keys:  []int{1},
+		older: 144,
+	}, {
+		name:  "or_d second branch without timelock",
+		expr:  "or_d(pk(K1),and_v(v:pk(K2),older(144)))",
+		keys:  []int{1},
+		older: 143,
+		fail:  true,
+	}, {
+		name:     "andor first branch",
+		expr:     "andor(pk(K1),sha256(H),pkh(K2))",
+		keys:     []int{0},
+		preimage: true,
+	}, {
+		name: "andor second branch",
+		expr: "andor(pk(K1),sha256(H),pkh(K2))",
+		keys: []int{1},
+	}, {
+		name:  "thresh",
+		expr:  "thresh(2,pk(K1),s:pk(K2),sln:after(100))",
+		keys:  []int{0},
+		after: 100,
+	}, {
+		name: "thresh without enough",
+		expr: "thresh(2,pk(K1),s:pk(K2),sln:after(100))",
+		keys: []int{0},
+		fail: true,
+	}, {
+		name: "multi",
+		expr: "multi(2,K1,K2,K3)",
+		keys: []int{0, 2},
+	}, {
+		name: "or_i",
+		expr: "or_i(pk(K1),j:pk(K2))",
+		keys: []int{1},
+	}, {
+		name: "or_b",
+		expr: "or_b(pk(K1),a:pk(K2))",
+		keys: []int{1},
+	}, {
+		name: "or_c",
+		expr: "t:or_c(pk(K1),v:pk(K2))",
+		keys: []int{1},
+	}, {
+		name: "and_b",
+		expr: "and_b(pk(K1),s:pk(K2))",
+		keys: []int{0, 1},
+	}, {
+		name: "hashes",
+		expr: "thresh(3,sha256(H),a:hash256(H256)," +
+			"a:ripemd160(HR160),a:hash160(H160))",
+		preimage: true,
+	}, {
+		name: "d:",
+		expr: "or_b(pk(K1),s:dv:older(10))",
+		keys: []int{0},
+	}, {
+		name:  "d: satisfied",
+		expr:  "or_b(pk(K1),a:dv:older(10))",
+		older: 10,
+	}}
+
+	for _, test := range tests {
+		node, err := Parse(expandTest(test.expr))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		script, err := node.Script()
+		if err != nil {
+			t.Errorf("%s: unexpected script error: %v", test.name,
+				err)
+			continue
+		}
+
+		bounds, err := node.SatisfactionBounds()
+		if err != nil {
+			t.Errorf("%s: unexpected bounds error: %v", test.name,
+				err)
+			continue
+		}
+		templates, err := node.WitnessTemplates()
+		if err != nil {
+			t.Errorf("%s: unexpected templates error: %v",
+				test.name, err)
+			continue
+		}
+		minSize, maxSize, maxElems := templates[0].Size(), 0, 0
+		for i := range templates {
+			size := templates[i].Size()
+			if size < minSize {
+				minSize = size
+			}
+			if size > maxSize {
+				maxSize = size
+			}
+			if len(templates[i].Elements) > maxElems {
+				maxElems = len(templates[i].Elements)
+			}
+		}
+		wantBounds := SatisfactionBounds{minSize, maxSize, maxElems}
+		if *bounds != wantBounds {
+			t.Errorf("%s: mismatched bounds -- got %+v, want %+v",
+				test.name, *bounds, wantBounds)
+		}
+
+		witnessHash := sha256.Sum256(script)
+		pkScript := append([]byte{txscript.OP_0, txscript.OP_DATA_32},
+			witnessHash[:]...)
+		const amount = 100000
+		tx := wire.NewMsgTx(2)
+		tx.LockTime = test.after
+		tx.AddTxIn(&wire.TxIn{Sequence: wire.MaxTxInSequenceNum - 1})
+		if test.older != 0 {
+			tx.TxIn[0].Sequence = test.older
+		}
+		tx.AddTxOut(wire.NewTxOut(amount-1000, pkScript))
+
+		fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, amount)
+		satisfier := &testSatisfier{
+			tx:        tx,
+			sigHashes: txscript.NewTxSigHashes(tx, fetcher),
+			script:    script,
+			amount:    amount,
+			keys:      make(map[string]*btcec.PrivateKey),
+			preimage:  test.preimage,
+			older:     test.older,
+			after:     test.after,
+		}
+		for _, i := range test.keys {
+			key := testPrivKeys[i].PubKey().SerializeCompressed()
+			satisfier.keys[string(key)] = testPrivKeys[i]
+		}
+
+		witness, err := node.Satisfy(satisfier)
+		if test.fail {
+			if !errors.Is(err, ErrNotSatisfiable) {
+				t.Errorf("%s: unexpected error -- got %v, want "+
+					"%v", test.name, err, ErrNotSatisfiable)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected satisfy error: %v", test.name,
+				err)
+			continue
+		}
+
+		// Signatures can be shorter than assumed by the bounds, so only
+		// the upper bounds hold for the witness.
+		var size int
+		for _, element := range witness {
+			size += wire.VarIntSerializeSize(uint64(len(element))) +
+				len(element)
+		}
+		if size > bounds.MaxWitnessSize ||
+			len(witness) > bounds.MaxWitnessElements {
+
+			t.Errorf("%s: witness size %d with %d elements out of "+
+				"bounds %+v", test.name, size, len(witness),
+				*bounds)
+		}
+
+		tx.TxIn[0].Witness = append(witness, script)
+		vm, err := txscript.NewEngine(
+			pkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+			satisfier.sigHashes, amount, fetcher,
+		)
+		if err != nil {
+			t.Errorf("%s: unexpected engine error: %v", test.name,
+				err)
+			continue
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("%s: witness failed to execute: %v",
+				test.name, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// CompilePolicy parses the passed spending policy and compiles it to a type
+// checked Miniscript expression of type B.
+//
+// The policy language consists of the pk(key), after(n), older(n),
+// sha256(h), hash256(h), ripemd160(h) and hash160(h) conditions, which are
+// combined with and(X,Y), or(X,Y) and thresh(k,X1,...,Xn).  The branches of
+// or(X,Y) can be prefixed with their relative probability, such as in
+// or(9@pk(key1),pk(key2)), to make the likelier branch cheaper to satisfy.
+func CompilePolicy(policy string) (*Node, error) {
+	node, err := compilePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The compiled expression is type checked again to catch any mistake
+	// of the compiler.
+	t, err := node.Type()
+	if err != nil {
+		return nil, err
+	}
+	if !t.has(TypeB) {
+		return nil, fmt.Errorf("policy compiled to expression of type "+
+			"%v instead of B", t)
+	}
+
+	return node, nil
+}
+
+// compilePolicy compiles the passed policy to an expression of type B.
+func compilePolicy(policy string) (*Node, error) {
+	open := strings.IndexByte(policy, '(')
+	if open == -1 || !strings.HasSuffix(policy, ")") {
+		return nil, fmt.Errorf("invalid policy %q", policy)
+	}
+
+	name := policy[:open]
+	args, err := splitArgs(policy[open+1 : len(policy)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "pk", "after", "older", "sha256", "hash256", "ripemd160",
+		"hash160":
+
+		// The conditions are written like the Miniscript expressions
+		// they compile to.
+		return parseNode(policy)
+
+	case "and":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("and: %d arguments instead of 2",
+				len(args))
+		}
+		subs, err := compilePolicies(args)
+		if err != nil {
+			return nil, err
+		}
+
+		verify := &Node{Fragment: FragWrapV, Subs: subs[:1]}
+		return &Node{Fragment: FragAndV, Subs: []*Node{
+			verify, subs[1],
+		}}, nil
+
+	case "or":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("or: %d arguments instead of 2",
+				len(args))
+		}
+
+		var probs [2]uint32
+		for i, arg := range args {
+			probs[i] = 1
+			at := strings.IndexByte(arg, '@')
+			if at == -1 {
+				continue
+			}
+
+			probs[i], err = parseUint32(arg[:at])
+			if err != nil {
+				return nil, err
+			}
+			if probs[i] == 0 {
+				return nil, fmt.Errorf("or: zero probability "+
+					"in %q", arg)
+			}
+			args[i] = arg[at+1:]
+		}
+
+		subs, err := compilePolicies(args)
+		if err != nil {
+			return nil, err
+		}
+		return compileOr(subs[0], subs[1], probs[0] < probs[1])
+
+	case "thresh":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("thresh: missing arguments")
+		}
+		k, err := parseUint32(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if k < 1 || int(k) > len(args)-1 {
+			return nil, fmt.Errorf("thresh: threshold %d out of "+
+				"range for %d sub-policies", k, len(args)-1)
+		}
+		subs, err := compilePolicies(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return compileThresh(k, subs)
+	}
+
+	return nil, fmt.Errorf("unknown policy %q", name)
+}
+
+// compilePolicies compiles the passed policies.
+func compilePolicies(policies []string) ([]*Node, error) {
+	subs := make([]*Node, 0, len(policies))
+	for _, policy := range policies {
+		sub, err := compilePolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// compileOr returns the disjunction of the passed expressions, which are
+// swapped first when the second one is the likelier to be satisfied.
+//
+// The or_d fragment is used when one of the expressions can be dissatisfied
+// without needing a unit result, which makes it the first branch, so that
+// satisfying it doesn't require anything else.  Otherwise or_i is used, which
+// costs an extra witness element to select either of the branches.
+func compileOr(x, y *Node, swap bool) (*Node, error) {
+	if swap {
+		x, y = y, x
+	}
+
+	xType, err := x.Type()
+	if err != nil {
+		return nil, err
+	}
+	yType, err := y.Type()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case xType.has(TypeD | TypeU):
+		return &Node{Fragment: FragOrD, Subs: []*Node{x, y}}, nil
+
+	case yType.has(TypeD | TypeU):
+		return &Node{Fragment: FragOrD, Subs: []*Node{y, x}}, nil
+	}
+
+	return &Node{Fragment: FragOrI, Subs: []*Node{x, y}}, nil
+}
+
+// compileThresh returns the threshold of the passed expressions.  Thresholds
+// of keys compile to multi expressions, while the others compile to thresh
+// expressions whose sub-expressions are wrapped into the types thresh
+// requires.
+func compileThresh(k uint32, subs []*Node) (*Node, error) {
+	keys := make([][]byte, 0, len(subs))
+	for _, sub := range subs {
+		if sub.Fragment != FragWrapC || sub.Subs[0].Fragment != FragPkK {
+			break
+		}
+		keys = append(keys, sub.Subs[0].Keys[0])
+	}
+	if len(keys) == len(subs) && len(keys) <= txscript.MaxPubKeysPerMultiSig {
+		return &Node{Fragment: FragMulti, K: k, Keys: keys}, nil
+	}
+
+	wrapped := make([]*Node, 0, len(subs))
+	for i, sub := range subs {
+		sub, err := makeDissatisfiableUnit(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		// All of the sub-expressions but the first one are wrapped to
+		// leave the sum of the previous ones on the stack.
+		if i > 0 {
+			t, err := sub.Type()
+			if err != nil {
+				return nil, err
+			}
+
+			frag := FragWrapA
+			if t.has(TypeO) {
+				frag = FragWrapS
+			}
+			sub = &Node{Fragment: frag, Subs: []*Node{sub}}
+		}
+		wrapped = append(wrapped, sub)
+	}
+
+	return &Node{Fragment: FragThresh, K: k, Subs: wrapped}, nil
+}
+
+// makeDissatisfiableUnit wraps the passed expression of type B so that it has
+// the dissatisfiable and unit properties.
+func makeDissatisfiableUnit(node *Node) (*Node, error) {
+	t, err := node.Type()
+	if err != nil {
+		return nil, err
+	}
+
+	// The u: wrapper, or or_i(X,0), can be dissatisfied by choosing its
+	// second branch.
+	if !t.has(TypeD) {
+		node, err = wrap('u', node)
+		if err != nil {
+			return nil, err
+		}
+		t, err = node.Type()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !t.has(TypeU) {
+		node = &Node{Fragment: FragWrapN, Subs: []*Node{node}}
+	}
+
+	return node, nil
+}
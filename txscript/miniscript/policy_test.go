@@ -0,0 +1,109 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"testing"
+)
+
+// TestCompilePolicy ensures policies compile to the expected Miniscript
+// expressions, which must compile to script.
+func TestCompilePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy string
+		expr   string
+	}{{
+		name:   "pk",
+		policy: "pk(K1)",
+		expr:   "pk(K1)",
+	}, {
+		name:   "and",
+		policy: "and(pk(K1),older(144))",
+		expr:   "and_v(v:pk(K1),older(144))",
+	}, {
+		name:   "or of keys",
+		policy: "or(pk(K1),pk(K2))",
+		expr:   "or_d(pk(K1),pk(K2))",
+	}, {
+		name:   "or with likelier second branch",
+		policy: "or(pk(K1),9@pk(K2))",
+		expr:   "or_d(pk(K2),pk(K1))",
+	}, {
+		name:   "or with timelocked branch",
+		policy: "or(99@pk(K1),and(pk(K2),older(144)))",
+		expr:   "or_d(pk(K1),and_v(v:pk(K2),older(144)))",
+	}, {
+		name:   "or with dissatisfiable second branch",
+		policy: "or(older(10),pk(K1))",
+		expr:   "or_d(pk(K1),older(10))",
+	}, {
+		name:   "or without dissatisfiable branch",
+		policy: "or(and(pk(K1),older(10)),and(pk(K2),after(20)))",
+		expr: "or_i(and_v(v:pk(K1),older(10))," +
+			"and_v(v:pk(K2),after(20)))",
+	}, {
+		name:   "thresh of keys",
+		policy: "thresh(2,pk(K1),pk(K2),pk(K3))",
+		expr:   "multi(2,K1,K2,K3)",
+	}, {
+		name:   "thresh",
+		policy: "thresh(2,pk(K1),sha256(H),older(144))",
+		expr:   "thresh(2,pk(K1),s:sha256(H),sn:or_i(older(144),0))",
+	}, {
+		name:   "thresh of and",
+		policy: "thresh(1,and(pk(K1),pk(K2)),pk(K3))",
+		expr: "thresh(1,or_i(and_v(v:pk(K1),pk(K2)),0)," +
+			"s:pk(K3))",
+	}}
+
+	for _, test := range tests {
+		node, err := CompilePolicy(expandTest(test.policy))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got := node.String(); got != expandTest(test.expr) {
+			t.Errorf("%s: mismatched expression -- got %s, want %s",
+				test.name, got, expandTest(test.expr))
+		}
+		if _, err := node.Script(); err != nil {
+			t.Errorf("%s: unexpected script error: %v", test.name,
+				err)
+		}
+		if _, err := node.SatisfactionBounds(); err != nil {
+			t.Errorf("%s: unexpected bounds error: %v", test.name,
+				err)
+		}
+	}
+}
+
+// TestCompilePolicyInvalid ensures invalid policies are rejected.
+func TestCompilePolicyInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"unknown policy", "foo(K1)"},
+		{"miniscript fragment", "and_v(v:pk(K1),pk(K2))"},
+		{"missing parenthesis", "pk(K1"},
+		{"missing argument", "and(pk(K1))"},
+		{"zero probability", "or(0@pk(K1),pk(K2))"},
+		{"invalid probability", "or(x@pk(K1),pk(K2))"},
+		{"thresh out of range", "thresh(3,pk(K1),pk(K2))"},
+		{"invalid key", "pk(02aa)"},
+	}
+
+	for _, test := range tests {
+		if _, err := CompilePolicy(expandTest(test.policy)); err == nil {
+			t.Errorf("%s: compiled invalid policy %s", test.name,
+				test.policy)
+		}
+	}
+}
@@ -0,0 +1,750 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// sigSize is the maximum size of a witness element holding a DER
+	// encoded ECDSA signature along with its sighash type, including the
+	// length prefix of the element.
+	sigSize = 1 + 73
+
+	// keySize is the size of a witness element holding a compressed public
+	// key, including the length prefix of the element.
+	keySize = 1 + 33
+
+	// preimageSize is the size of a witness element holding a preimage,
+	// which must be 32 bytes long, including the length prefix of the
+	// element.
+	preimageSize = 1 + 32
+
+	// emptySize is the size of an empty witness element.
+	emptySize = 1
+
+	// oneSize is the size of a witness element holding the number 1.
+	oneSize = 1 + 1
+
+	// maxTemplates is the maximum number of satisfactions or
+	// dissatisfactions of an expression which are enumerated.
+	maxTemplates = 1000
+)
+
+var (
+	// ErrNotSatisfiable is returned when an expression can't be satisfied
+	// at all, or not with the signatures, preimages and lock times
+	// provided by a Satisfier.
+	ErrNotSatisfiable = errors.New("expression is not satisfiable")
+
+	// ErrTooManyTemplates is returned when the number of ways to satisfy
+	// an expression is too large to enumerate them.
+	ErrTooManyTemplates = fmt.Errorf("expression has more than %d "+
+		"satisfactions", maxTemplates)
+)
+
+// SatisfactionBounds holds the bounds of the size of the witness stacks
+// satisfying an expression.  The sizes are the sum of the sizes of the stack
+// elements including their length prefixes, and don't include the witness
+// script, which is the last element of the witness, nor the number of
+// elements of the witness.
+type SatisfactionBounds struct {
+	// MinWitnessSize is the size of the smallest satisfaction.
+	MinWitnessSize int
+
+	// MaxWitnessSize is the size of the largest satisfaction.
+	MaxWitnessSize int
+
+	// MaxWitnessElements is the largest number of stack elements of a
+	// satisfaction.
+	MaxWitnessElements int
+}
+
+// cost holds the bounds of the size and of the number of elements of the
+// satisfactions or dissatisfactions of an expression.
+type cost struct {
+	ok       bool
+	min      int
+	max      int
+	maxElems int
+}
+
+// impossible is the cost of a missing satisfaction or dissatisfaction.
+var impossible = cost{}
+
+// stackCost returns the cost of a single witness stack with the passed size
+// and number of elements.
+func stackCost(size, elems int) cost {
+	return cost{ok: true, min: size, max: size, maxElems: elems}
+}
+
+// and returns the cost of witness stacks made of the stacks of both of the
+// passed costs.
+func (c cost) and(other cost) cost {
+	if !c.ok || !other.ok {
+		return impossible
+	}
+	return cost{
+		ok:       true,
+		min:      c.min + other.min,
+		max:      c.max + other.max,
+		maxElems: c.maxElems + other.maxElems,
+	}
+}
+
+// or returns the cost of witness stacks which are the stacks of either of the
+// passed costs.
+func (c cost) or(other cost) cost {
+	switch {
+	case !c.ok:
+		return other
+	case !other.ok:
+		return c
+	}
+	return cost{
+		ok:       true,
+		min:      minInt(c.min, other.min),
+		max:      maxInt(c.max, other.max),
+		maxElems: maxInt(c.maxElems, other.maxElems),
+	}
+}
+
+// minInt returns the smaller of the passed integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of the passed integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// SatisfactionBounds type checks the expression and returns the bounds of the
+// size of the witness stacks satisfying it.  ErrNotSatisfiable is returned if
+// the expression can't be satisfied.
+func (n *Node) SatisfactionBounds() (*SatisfactionBounds, error) {
+	if _, err := n.Type(); err != nil {
+		return nil, err
+	}
+
+	sat, _ := n.costs()
+	if !sat.ok {
+		return nil, ErrNotSatisfiable
+	}
+
+	return &SatisfactionBounds{
+		MinWitnessSize:     sat.min,
+		MaxWitnessSize:     sat.max,
+		MaxWitnessElements: sat.maxElems,
+	}, nil
+}
+
+// costs returns the costs of the satisfactions and of the dissatisfactions of
+// the expression, which must have been type checked.
+func (n *Node) costs() (cost, cost) {
+	subSats := make([]cost, len(n.Subs))
+	subDsats := make([]cost, len(n.Subs))
+	for i, sub := range n.Subs {
+		subSats[i], subDsats[i] = sub.costs()
+	}
+
+	var satX, dsatX, satY, dsatY, satZ, dsatZ cost
+	switch len(n.Subs) {
+	case 3:
+		satZ, dsatZ = subSats[2], subDsats[2]
+		fallthrough
+	case 2:
+		satY, dsatY = subSats[1], subDsats[1]
+		fallthrough
+	case 1:
+		satX, dsatX = subSats[0], subDsats[0]
+	}
+
+	switch n.Fragment {
+	case Frag0:
+		return impossible, stackCost(0, 0)
+
+	case Frag1:
+		return stackCost(0, 0), impossible
+
+	case FragPkK:
+		return stackCost(sigSize, 1), stackCost(emptySize, 1)
+
+	case FragPkH:
+		return stackCost(sigSize+keySize, 2),
+			stackCost(emptySize+keySize, 2)
+
+	case FragOlder, FragAfter:
+		return stackCost(0, 0), impossible
+
+	case FragSha256, FragHash256, FragRipemd160, FragHash160:
+		return stackCost(preimageSize, 1), stackCost(preimageSize, 1)
+
+	case FragAndOr:
+		// For andor(X,Y,Z), Y and Z are the second and third
+		// sub-expressions.
+		return satY.and(satX).or(satZ.and(dsatX)), dsatZ.and(dsatX)
+
+	case FragAndV:
+		return satY.and(satX), dsatY.and(satX)
+
+	case FragAndB:
+		return satY.and(satX), dsatY.and(dsatX)
+
+	case FragOrB:
+		return dsatY.and(satX).or(satY.and(dsatX)), dsatY.and(dsatX)
+
+	case FragOrC:
+		return satX.or(satY.and(dsatX)), impossible
+
+	case FragOrD:
+		return satX.or(satY.and(dsatX)), dsatY.and(dsatX)
+
+	case FragOrI:
+		one, empty := stackCost(oneSize, 1), stackCost(emptySize, 1)
+		return satX.and(one).or(satY.and(empty)),
+			dsatX.and(one).or(dsatY.and(empty))
+
+	case FragThresh:
+		// The costs of satisfying exactly i of the sub-expressions
+		// considered so far are computed by dynamic programming.
+		counts := []cost{stackCost(0, 0)}
+		for i := range n.Subs {
+			next := make([]cost, len(counts)+1)
+			for j := range next {
+				next[j] = impossible
+				if j < len(counts) {
+					next[j] = counts[j].and(subDsats[i])
+				}
+				if j > 0 {
+					next[j] = next[j].or(
+						counts[j-1].and(subSats[i]),
+					)
+				}
+			}
+			counts = next
+		}
+		return counts[n.K], counts[0]
+
+	case FragMulti:
+		return stackCost(emptySize+int(n.K)*sigSize, int(n.K)+1),
+			stackCost(emptySize*(int(n.K)+1), int(n.K)+1)
+
+	case FragWrapA, FragWrapS, FragWrapC, FragWrapN:
+		return satX, dsatX
+
+	case FragWrapD:
+		return satX.and(stackCost(oneSize, 1)), stackCost(emptySize, 1)
+
+	case FragWrapV:
+		return satX, impossible
+
+	case FragWrapJ:
+		return satX, stackCost(emptySize, 1)
+	}
+
+	return impossible, impossible
+}
+
+// ElementKind identifies the kind of an element of a witness template.
+type ElementKind uint8
+
+const (
+	// ElementData is a witness element whose data is known in advance.
+	ElementData ElementKind = iota
+
+	// ElementSignature is a witness element which must be replaced by a
+	// signature of the public key held by the element.
+	ElementSignature
+
+	// ElementPubKey is a witness element holding a public key, which is
+	// pushed for pk_h expressions.
+	ElementPubKey
+
+	// ElementPreimage is a witness element which must be replaced by a
+	// preimage of the hash held by the element.
+	ElementPreimage
+)
+
+// String returns the ElementKind in human-readable form.
+func (k ElementKind) String() string {
+	switch k {
+	case ElementData:
+		return "data"
+	case ElementSignature:
+		return "signature"
+	case ElementPubKey:
+		return "pubkey"
+	case ElementPreimage:
+		return "preimage"
+	}
+	return fmt.Sprintf("Unknown ElementKind (%d)", uint8(k))
+}
+
+// TemplateElement is an element of a witness template.
+type TemplateElement struct {
+	// Kind is the kind of the element.
+	Kind ElementKind
+
+	// Data is the data of ElementData elements, the public key of
+	// ElementSignature and ElementPubKey elements, or the hash of
+	// ElementPreimage elements.
+	Data []byte
+
+	// HashType is the hash fragment whose hash the preimage of
+	// ElementPreimage elements must match.
+	HashType Fragment
+}
+
+// size returns the maximum size of the element in the witness, including its
+// length prefix.
+func (e *TemplateElement) size() int {
+	switch e.Kind {
+	case ElementSignature:
+		return sigSize
+	case ElementPreimage:
+		return preimageSize
+	}
+	return wire.VarIntSerializeSize(uint64(len(e.Data))) + len(e.Data)
+}
+
+// WitnessTemplate is a witness stack satisfying an expression, made of
+// placeholders for the signatures and preimages the satisfaction requires.
+type WitnessTemplate struct {
+	// Elements are the elements of the witness stack, in the order of the
+	// witness, so the last element is the top of the stack.
+	Elements []TemplateElement
+
+	// Older is the relative lock time the satisfaction relies on as
+	// encoded in the sequence number of the input, or 0 if it doesn't rely
+	// on one.
+	Older uint32
+
+	// After is the absolute lock time the satisfaction relies on, or 0 if
+	// it doesn't rely on one.
+	After uint32
+}
+
+// Size returns the maximum size of the witness stacks made from the template,
+// including the length prefixes of their elements.
+func (t *WitnessTemplate) Size() int {
+	var size int
+	for i := range t.Elements {
+		size += t.Elements[i].size()
+	}
+	return size
+}
+
+// concatTemplates returns the templates made of the elements of each of the
+// passed bottom templates followed by the elements of each of the passed top
+// templates.  The combinations relying on lock times of different types, which
+// can't be satisfied at once, are skipped.
+func concatTemplates(bottom, top []WitnessTemplate) ([]WitnessTemplate, error) {
+	if len(bottom)*len(top) > maxTemplates {
+		return nil, ErrTooManyTemplates
+	}
+
+	templates := make([]WitnessTemplate, 0, len(bottom)*len(top))
+	for i := range bottom {
+		for j := range top {
+			older, ok := combineLockTimes(
+				bottom[i].Older, top[j].Older, isTimeOlder,
+			)
+			if !ok {
+				continue
+			}
+			after, ok := combineLockTimes(
+				bottom[i].After, top[j].After, isTimeAfter,
+			)
+			if !ok {
+				continue
+			}
+
+			elements := make(
+				[]TemplateElement, 0,
+				len(bottom[i].Elements)+len(top[j].Elements),
+			)
+			elements = append(elements, bottom[i].Elements...)
+			elements = append(elements, top[j].Elements...)
+			templates = append(templates, WitnessTemplate{
+				Elements: elements,
+				Older:    older,
+				After:    after,
+			})
+		}
+	}
+
+	return templates, nil
+}
+
+// combineLockTimes returns the lock time satisfying both of the passed lock
+// times, where 0 means no lock time, and whether they can be satisfied at
+// once, which isn't the case when one of them is based on block heights and
+// the other on block times according to the passed function.
+func combineLockTimes(a, b uint32, isTime func(uint32) bool) (uint32, bool) {
+	switch {
+	case a == 0:
+		return b, true
+	case b == 0:
+		return a, true
+	case isTime(a) != isTime(b):
+		return 0, false
+	case a > b:
+		return a, true
+	}
+	return b, true
+}
+
+// isTimeOlder returns whether the passed relative lock time is time based.
+func isTimeOlder(lockTime uint32) bool {
+	return lockTime&wire.SequenceLockTimeIsSeconds != 0
+}
+
+// isTimeAfter returns whether the passed absolute lock time is time based.
+func isTimeAfter(lockTime uint32) bool {
+	return lockTime >= txscript.LockTimeThreshold
+}
+
+// dataTemplate returns the template of a witness stack made of the passed
+// data elements.
+func dataTemplate(data ...[]byte) []WitnessTemplate {
+	elements := make([]TemplateElement, 0, len(data))
+	for _, d := range data {
+		elements = append(elements, TemplateElement{
+			Kind: ElementData,
+			Data: d,
+		})
+	}
+	return []WitnessTemplate{{Elements: elements}}
+}
+
+// emptyTemplate is the template of an empty witness stack.
+var emptyTemplate = []WitnessTemplate{{}}
+
+// oneData is the data of a witness element holding the number 1.
+var oneData = []byte{1}
+
+// WitnessTemplates type checks the expression and returns the templates of the
+// witness stacks of all the ways to satisfy it.  ErrNotSatisfiable is returned
+// if the expression can't be satisfied, and ErrTooManyTemplates if there are
+// too many ways to satisfy it to enumerate them.
+func (n *Node) WitnessTemplates() ([]WitnessTemplate, error) {
+	if _, err := n.Type(); err != nil {
+		return nil, err
+	}
+
+	sats, _, err := n.templates()
+	if err != nil {
+		return nil, err
+	}
+	if len(sats) == 0 {
+		return nil, ErrNotSatisfiable
+	}
+
+	return sats, nil
+}
+
+// templates returns the templates of the satisfactions and of the
+// dissatisfactions of the expression, which must have been type checked.
+func (n *Node) templates() ([]WitnessTemplate, []WitnessTemplate, error) {
+	subSats := make([][]WitnessTemplate, len(n.Subs))
+	subDsats := make([][]WitnessTemplate, len(n.Subs))
+	for i, sub := range n.Subs {
+		var err error
+		subSats[i], subDsats[i], err = sub.templates()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// and returns the concatenations of the passed templates, with the
+	// ones of the sub-expressions executed first last since they are on
+	// top of the stack.
+	var err error
+	and := func(bottom, top []WitnessTemplate) []WitnessTemplate {
+		if err != nil {
+			return nil
+		}
+		var templates []WitnessTemplate
+		templates, err = concatTemplates(bottom, top)
+		return templates
+	}
+
+	// or returns the union of the passed templates.
+	or := func(alternatives ...[]WitnessTemplate) []WitnessTemplate {
+		var templates []WitnessTemplate
+		for _, alternative := range alternatives {
+			templates = append(templates, alternative...)
+		}
+		if len(templates) > maxTemplates && err == nil {
+			err = ErrTooManyTemplates
+		}
+		return templates
+	}
+
+	var sats, dsats []WitnessTemplate
+	switch n.Fragment {
+	case Frag0:
+		dsats = emptyTemplate
+
+	case Frag1:
+		sats = emptyTemplate
+
+	case FragPkK:
+		sats = []WitnessTemplate{{Elements: []TemplateElement{{
+			Kind: ElementSignature,
+			Data: n.Keys[0],
+		}}}}
+		dsats = dataTemplate(nil)
+
+	case FragPkH:
+		key := TemplateElement{Kind: ElementPubKey, Data: n.Keys[0]}
+		sats = []WitnessTemplate{{Elements: []TemplateElement{{
+			Kind: ElementSignature,
+			Data: n.Keys[0],
+		}, key}}}
+		dsats = []WitnessTemplate{{Elements: []TemplateElement{{
+			Kind: ElementData,
+		}, key}}}
+
+	case FragOlder:
+		sats = []WitnessTemplate{{Older: n.K}}
+
+	case FragAfter:
+		sats = []WitnessTemplate{{After: n.K}}
+
+	case FragSha256, FragHash256, FragRipemd160, FragHash160:
+		sats = []WitnessTemplate{{Elements: []TemplateElement{{
+			Kind:     ElementPreimage,
+			Data:     n.Hash,
+			HashType: n.Fragment,
+		}}}}
+		dsats = dataTemplate(make([]byte, 32))
+
+	case FragAndOr:
+		sats = or(
+			and(subSats[1], subSats[0]),
+			and(subSats[2], subDsats[0]),
+		)
+		dsats = and(subDsats[2], subDsats[0])
+
+	case FragAndV:
+		sats = and(subSats[1], subSats[0])
+		dsats = and(subDsats[1], subSats[0])
+
+	case FragAndB:
+		sats = and(subSats[1], subSats[0])
+		dsats = and(subDsats[1], subDsats[0])
+
+	case FragOrB:
+		sats = or(
+			and(subDsats[1], subSats[0]),
+			and(subSats[1], subDsats[0]),
+		)
+		dsats = and(subDsats[1], subDsats[0])
+
+	case FragOrC:
+		sats = or(subSats[0], and(subSats[1], subDsats[0]))
+
+	case FragOrD:
+		sats = or(subSats[0], and(subSats[1], subDsats[0]))
+		dsats = and(subDsats[1], subDsats[0])
+
+	case FragOrI:
+		one, empty := dataTemplate(oneData), dataTemplate(nil)
+		sats = or(and(subSats[0], one), and(subSats[1], empty))
+		dsats = or(and(subDsats[0], one), and(subDsats[1], empty))
+
+	case FragThresh:
+		// The templates satisfying exactly i of the sub-expressions
+		// considered so far are built by dynamic programming.  The
+		// first sub-expression is executed first, so its elements are
+		// on top of the stack.
+		counts := [][]WitnessTemplate{emptyTemplate}
+		for i := range n.Subs {
+			next := make([][]WitnessTemplate, len(counts)+1)
+			for j := range next {
+				if j < len(counts) {
+					next[j] = and(subDsats[i], counts[j])
+				}
+				if j > 0 {
+					next[j] = or(
+						next[j], and(subSats[i], counts[j-1]),
+					)
+				}
+			}
+			counts = next
+		}
+		sats, dsats = counts[n.K], counts[0]
+
+	case FragMulti:
+		sats, err = multiTemplates(n.K, n.Keys)
+		dsats = dataTemplate(make([][]byte, n.K+1)...)
+
+	case FragWrapA, FragWrapS, FragWrapC, FragWrapN:
+		sats, dsats = subSats[0], subDsats[0]
+
+	case FragWrapD:
+		sats = and(subSats[0], dataTemplate(oneData))
+		dsats = dataTemplate(nil)
+
+	case FragWrapV:
+		sats = subSats[0]
+
+	case FragWrapJ:
+		sats = subSats[0]
+		dsats = dataTemplate(nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sats, dsats, nil
+}
+
+// multiTemplates returns the templates satisfying a multi expression with the
+// passed threshold and keys, which are made of the dummy element consumed by
+// OP_CHECKMULTISIG followed by the signatures of k of the keys in the order of
+// the keys.
+func multiTemplates(k uint32, keys [][]byte) ([]WitnessTemplate, error) {
+	var templates []WitnessTemplate
+	var choose func(start int, elements []TemplateElement) error
+	choose = func(start int, elements []TemplateElement) error {
+		if len(elements) == int(k)+1 {
+			if len(templates) == maxTemplates {
+				return ErrTooManyTemplates
+			}
+			templates = append(templates, WitnessTemplate{
+				Elements: append([]TemplateElement(nil),
+					elements...),
+			})
+			return nil
+		}
+
+		for i := start; i < len(keys); i++ {
+			err := choose(i+1, append(elements, TemplateElement{
+				Kind: ElementSignature,
+				Data: keys[i],
+			}))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := choose(0, []TemplateElement{{Kind: ElementData}})
+	return templates, err
+}
+
+// Satisfier provides the signatures, preimages and lock times which are
+// available to satisfy an expression.
+type Satisfier interface {
+	// Sign returns the signature of the passed public key, along with its
+	// sighash type, and whether it is available.
+	Sign(pubKey []byte) ([]byte, bool)
+
+	// Preimage returns the preimage of the passed hash of the passed hash
+	// fragment and whether it is available.
+	Preimage(hashType Fragment, hash []byte) ([]byte, bool)
+
+	// CheckOlder returns whether the input being spent satisfies the
+	// passed relative lock time.
+	CheckOlder(lockTime uint32) bool
+
+	// CheckAfter returns whether the transaction being signed satisfies
+	// the passed absolute lock time.
+	CheckAfter(lockTime uint32) bool
+}
+
+// Satisfy type checks the expression and returns the smallest witness stack
+// satisfying it with the signatures, preimages and lock times provided by the
+// passed satisfier.  The witness script isn't included in the returned stack.
+// ErrNotSatisfiable is returned if no satisfaction is possible with the
+// provided data.
+func (n *Node) Satisfy(satisfier Satisfier) (wire.TxWitness, error) {
+	templates, err := n.WitnessTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		best     wire.TxWitness
+		bestSize int
+		sigs     = make(map[string][]byte)
+	)
+	for i := range templates {
+		witness, ok := fillTemplate(&templates[i], satisfier, sigs)
+		if !ok {
+			continue
+		}
+
+		size := witness.SerializeSize()
+		if best == nil || size < bestSize {
+			best, bestSize = witness, size
+		}
+	}
+	if best == nil {
+		return nil, ErrNotSatisfiable
+	}
+
+	return best, nil
+}
+
+// fillTemplate returns the witness stack made from the passed template with
+// the data provided by the passed satisfier, and whether all of the required
+// data is available.  The signatures are cached in the passed map so each key
+// is only signed once.
+func fillTemplate(template *WitnessTemplate, satisfier Satisfier,
+	sigs map[string][]byte) (wire.TxWitness, bool) {
+
+	if template.Older != 0 && !satisfier.CheckOlder(template.Older) {
+		return nil, false
+	}
+	if template.After != 0 && !satisfier.CheckAfter(template.After) {
+		return nil, false
+	}
+
+	witness := make(wire.TxWitness, 0, len(template.Elements))
+	for _, element := range template.Elements {
+		switch element.Kind {
+		case ElementSignature:
+			sig, ok := sigs[string(element.Data)]
+			if !ok {
+				sig, ok = satisfier.Sign(element.Data)
+				if !ok {
+					return nil, false
+				}
+				sigs[string(element.Data)] = sig
+			}
+			witness = append(witness, sig)
+
+		case ElementPreimage:
+			preimage, ok := satisfier.Preimage(
+				element.HashType, element.Data,
+			)
+			if !ok || len(preimage) != 32 {
+				return nil, false
+			}
+			witness = append(witness, preimage)
+
+		default:
+			witness = append(witness, element.Data)
+		}
+	}
+
+	return witness, true
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// MaxStandardScriptSize is the maximum size of a P2WSH witness script which is
+// relayed by default.
+const MaxStandardScriptSize = 3600
+
+// verifyOpcodes maps the opcodes which have a VERIFY variant to it, which the
+// v: wrapper uses instead of appending OP_VERIFY.
+var verifyOpcodes = map[byte]byte{
+	txscript.OP_EQUAL:         txscript.OP_EQUALVERIFY,
+	txscript.OP_NUMEQUAL:      txscript.OP_NUMEQUALVERIFY,
+	txscript.OP_CHECKSIG:      txscript.OP_CHECKSIGVERIFY,
+	txscript.OP_CHECKMULTISIG: txscript.OP_CHECKMULTISIGVERIFY,
+}
+
+// hashOpcodes maps the hash fragments to the opcodes hashing their preimage.
+var hashOpcodes = map[Fragment]byte{
+	FragSha256:    txscript.OP_SHA256,
+	FragHash256:   txscript.OP_HASH256,
+	FragRipemd160: txscript.OP_RIPEMD160,
+	FragHash160:   txscript.OP_HASH160,
+}
+
+// Script type checks the expression and compiles it to a witness script.  An
+// error is returned if the expression isn't of type B, or if the script
+// exceeds the standard size of witness scripts or the maximum number of
+// opcodes allowed in a script.
+func (n *Node) Script() ([]byte, error) {
+	t, err := n.Type()
+	if err != nil {
+		return nil, err
+	}
+	if !t.has(TypeB) {
+		return nil, fmt.Errorf("expression of type %v instead of B", t)
+	}
+
+	script, err := n.compile()
+	if err != nil {
+		return nil, err
+	}
+	if len(script) > MaxStandardScriptSize {
+		return nil, fmt.Errorf("script size %d exceeds the maximum "+
+			"of %d", len(script), MaxStandardScriptSize)
+	}
+
+	numOps, err := countOps(script)
+	if err != nil {
+		return nil, err
+	}
+	if numOps > txscript.MaxOpsPerScript {
+		return nil, fmt.Errorf("script with %d opcodes exceeds the "+
+			"maximum of %d", numOps, txscript.MaxOpsPerScript)
+	}
+
+	return script, nil
+}
+
+// compile returns the script of the expression, which must have been type
+// checked.
+func (n *Node) compile() ([]byte, error) {
+	subs := make([][]byte, len(n.Subs))
+	for i, sub := range n.Subs {
+		script, err := sub.compile()
+		if err != nil {
+			return nil, err
+		}
+		subs[i] = script
+	}
+
+	b := txscript.NewScriptBuilder()
+	switch n.Fragment {
+	case Frag0:
+		b.AddOp(txscript.OP_0)
+
+	case Frag1:
+		b.AddOp(txscript.OP_1)
+
+	case FragPkK:
+		b.AddData(n.Keys[0])
+
+	case FragPkH:
+		b.AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160)
+		b.AddData(btcutil.Hash160(n.Keys[0]))
+		b.AddOp(txscript.OP_EQUALVERIFY)
+
+	case FragOlder:
+		b.AddInt64(int64(n.K)).AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+
+	case FragAfter:
+		b.AddInt64(int64(n.K)).AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+
+	case FragSha256, FragHash256, FragRipemd160, FragHash160:
+		b.AddOp(txscript.OP_SIZE).AddInt64(32)
+		b.AddOp(txscript.OP_EQUALVERIFY).AddOp(hashOpcodes[n.Fragment])
+		b.AddData(n.Hash).AddOp(txscript.OP_EQUAL)
+
+	case FragAndOr:
+		b.AddOps(subs[0]).AddOp(txscript.OP_NOTIF)
+		b.AddOps(subs[2]).AddOp(txscript.OP_ELSE)
+		b.AddOps(subs[1]).AddOp(txscript.OP_ENDIF)
+
+	case FragAndV:
+		b.AddOps(subs[0]).AddOps(subs[1])
+
+	case FragAndB:
+		b.AddOps(subs[0]).AddOps(subs[1]).AddOp(txscript.OP_BOOLAND)
+
+	case FragOrB:
+		b.AddOps(subs[0]).AddOps(subs[1]).AddOp(txscript.OP_BOOLOR)
+
+	case FragOrC:
+		b.AddOps(subs[0]).AddOp(txscript.OP_NOTIF)
+		b.AddOps(subs[1]).AddOp(txscript.OP_ENDIF)
+
+	case FragOrD:
+		b.AddOps(subs[0]).AddOp(txscript.OP_IFDUP)
+		b.AddOp(txscript.OP_NOTIF)
+		b.AddOps(subs[1]).AddOp(txscript.OP_ENDIF)
+
+	case FragOrI:
+		b.AddOp(txscript.OP_IF).AddOps(subs[0])
+		b.AddOp(txscript.OP_ELSE).AddOps(subs[1])
+		b.AddOp(txscript.OP_ENDIF)
+
+	case FragThresh:
+		b.AddOps(subs[0])
+		for _, sub := range subs[1:] {
+			b.AddOps(sub).AddOp(txscript.OP_ADD)
+		}
+		b.AddInt64(int64(n.K)).AddOp(txscript.OP_EQUAL)
+
+	case FragMulti:
+		b.AddInt64(int64(n.K))
+		for _, key := range n.Keys {
+			b.AddData(key)
+		}
+		b.AddInt64(int64(len(n.Keys)))
+		b.AddOp(txscript.OP_CHECKMULTISIG)
+
+	case FragWrapA:
+		b.AddOp(txscript.OP_TOALTSTACK).AddOps(subs[0])
+		b.AddOp(txscript.OP_FROMALTSTACK)
+
+	case FragWrapS:
+		b.AddOp(txscript.OP_SWAP).AddOps(subs[0])
+
+	case FragWrapC:
+		b.AddOps(subs[0]).AddOp(txscript.OP_CHECKSIG)
+
+	case FragWrapD:
+		b.AddOp(txscript.OP_DUP).AddOp(txscript.OP_IF)
+		b.AddOps(subs[0]).AddOp(txscript.OP_ENDIF)
+
+	case FragWrapV:
+		script, err := addVerify(subs[0])
+		if err != nil {
+			return nil, err
+		}
+		b.AddOps(script)
+
+	case FragWrapJ:
+		b.AddOp(txscript.OP_SIZE).AddOp(txscript.OP_0NOTEQUAL)
+		b.AddOp(txscript.OP_IF).AddOps(subs[0])
+		b.AddOp(txscript.OP_ENDIF)
+
+	case FragWrapN:
+		b.AddOps(subs[0]).AddOp(txscript.OP_0NOTEQUAL)
+
+	default:
+		return nil, fmt.Errorf("unknown fragment %v", n.Fragment)
+	}
+
+	return b.Script()
+}
+
+// addVerify returns the passed script with its last opcode replaced by its
+// VERIFY variant when it has one, or with OP_VERIFY appended otherwise.
+func addVerify(script []byte) ([]byte, error) {
+	// The script is tokenized to tell the last opcode apart from the last
+	// byte of data pushed by the script.
+	var lastOp byte
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		lastOp = tokenizer.Opcode()
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, err
+	}
+
+	verified := make([]byte, len(script), len(script)+1)
+	copy(verified, script)
+
+	if verifyOp, ok := verifyOpcodes[lastOp]; ok {
+		verified[len(verified)-1] = verifyOp
+		return verified, nil
+	}
+
+	return append(verified, txscript.OP_VERIFY), nil
+}
+
+// countOps returns the number of opcodes of the script which count towards the
+// maximum allowed per script, counting the keys of all of the multisig checks
+// as if they were all executed.
+func countOps(script []byte) (int, error) {
+	var numOps int
+	var prevOp byte
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		op := tokenizer.Opcode()
+		if op > txscript.OP_16 {
+			numOps++
+		}
+
+		// The number of keys of multisig checks is pushed right before
+		// them by the scripts of multi expressions.
+		if op == txscript.OP_CHECKMULTISIG ||
+			op == txscript.OP_CHECKMULTISIGVERIFY {
+
+			if txscript.IsSmallInt(prevOp) {
+				numOps += txscript.AsSmallInt(prevOp)
+			} else {
+				numOps += txscript.MaxPubKeysPerMultiSig
+			}
+		}
+		prevOp = op
+	}
+
+	return numOps, tokenizer.Err()
+}
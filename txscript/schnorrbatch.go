@@ -0,0 +1,403 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// schnorrBatchWindow is the width of the windowed non-adjacent form of
+	// the scalars used by the multi-scalar multiplication of the batch
+	// verification.  Each point requires a table of 2^(w-2) precomputed
+	// odd multiples while a wider window reduces the number of additions.
+	schnorrBatchWindow = 5
+)
+
+// SchnorrBatchVerifier defines the interface used by the script engine to defer
+// the verification of the BIP 340 signatures of taproot spends so they can be
+// verified together once all of the scripts have been executed.
+//
+// Deferring the verification is possible since, unlike the signatures checked
+// by legacy and segwit v0 scripts, a taproot signature which fails verification
+// always causes the script to fail.  The engine therefore assumes the added
+// signatures are valid, which means callers MUST verify all of the signatures
+// added to the batch and treat the scripts as invalid when any of them fail.
+type SchnorrBatchVerifier interface {
+	// Add queues the signature of the message by the public key for
+	// verification.  The serialized signature and public key are the ones
+	// found in the script, which identify the signature in a signature
+	// cache.
+	Add(pubKey *btcec.PublicKey, msg []byte, sig *schnorr.Signature,
+		sigBytes, pubKeyBytes []byte)
+}
+
+// schnorrBatchEntry houses a signature queued in a SchnorrBatch along with the
+// message and the public key it has to be verified against.
+type schnorrBatchEntry struct {
+	pubKey      *btcec.PublicKey
+	msg         []byte
+	sig         *schnorr.Signature
+	sigBytes    []byte
+	pubKeyBytes []byte
+}
+
+// SchnorrBatch is a SchnorrBatchVerifier which collects the added signatures
+// and verifies them together with the batch verification algorithm described
+// in BIP 340 when Verify is called.  The signatures of a batch that verifies are
+// added to the signature cache of the batch, if any, so later executions of the
+// same scripts don't have to verify them again.
+//
+// It is safe for concurrent access so the same batch can be shared by engines
+// executing in parallel.
+type SchnorrBatch struct {
+	mtx      sync.Mutex
+	entries  []schnorrBatchEntry
+	sigCache *SigCache
+}
+
+// Ensure the SchnorrBatch type implements the SchnorrBatchVerifier interface.
+var _ SchnorrBatchVerifier = (*SchnorrBatch)(nil)
+
+// NewSchnorrBatch returns a new empty batch of schnorr signatures which adds
+// the signatures it verifies to the passed signature cache.  The cache may be
+// nil.
+func NewSchnorrBatch(sigCache *SigCache) *SchnorrBatch {
+	return &SchnorrBatch{sigCache: sigCache}
+}
+
+// Add queues the signature of the message by the public key for verification.
+//
+// This is part of the SchnorrBatchVerifier interface.
+func (b *SchnorrBatch) Add(pubKey *btcec.PublicKey, msg []byte,
+	sig *schnorr.Signature, sigBytes, pubKeyBytes []byte) {
+
+	msgCopy := make([]byte, len(msg))
+	copy(msgCopy, msg)
+
+	b.mtx.Lock()
+	b.entries = append(b.entries, schnorrBatchEntry{
+		pubKey:      pubKey,
+		msg:         msgCopy,
+		sig:         sig,
+		sigBytes:    sigBytes,
+		pubKeyBytes: pubKeyBytes,
+	})
+	b.mtx.Unlock()
+}
+
+// Len returns the number of signatures in the batch.
+func (b *SchnorrBatch) Len() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return len(b.entries)
+}
+
+// Verify returns whether all of the signatures in the batch are valid.  The
+// batch is emptied afterwards so it can be reused.
+//
+// The signatures are verified at once by checking a random linear combination
+// of their verification equations, which is considerably faster than verifying
+// them one by one.  A batch that contains an invalid signature is rejected with
+// overwhelming probability, but Verify doesn't tell which of the signatures is
+// invalid, so callers have to verify them individually to find out.
+func (b *SchnorrBatch) Verify() bool {
+	b.mtx.Lock()
+	entries := b.entries
+	b.entries = nil
+	b.mtx.Unlock()
+
+	var valid bool
+	switch len(entries) {
+	case 0:
+		return true
+	case 1:
+		valid = entries[0].sig.Verify(entries[0].msg, entries[0].pubKey)
+	default:
+		valid = verifySchnorrBatch(entries)
+	}
+	if valid && b.sigCache != nil {
+		for _, entry := range entries {
+			sigHash, err := chainhash.NewHash(entry.msg)
+			if err != nil {
+				continue
+			}
+			b.sigCache.Add(*sigHash, entry.sigBytes,
+				entry.pubKeyBytes)
+		}
+	}
+	return valid
+}
+
+// verifySchnorrBatch verifies the passed signatures with the batch verification
+// algorithm from BIP 340.  That is, it checks
+//
+//	(a_1*s_1 + ... + a_u*s_u)*G = a_1*R_1 + ... + a_u*R_u +
+//	                              a_1*e_1*P_1 + ... + a_u*e_u*P_u
+//
+// where a_1 = 1 and the remaining a_i are derived from a hash of all of the
+// public keys, messages and signatures, R_i is the point with the x coordinate
+// r_i and an even y coordinate, and e_i is the challenge of the signature.
+func verifySchnorrBatch(entries []schnorrBatchEntry) bool {
+	// Serialize the public keys and signatures and derive the seed of the
+	// random coefficients from all of the inputs.
+	pubKeys := make([][]byte, len(entries))
+	sigs := make([][]byte, len(entries))
+	seedHash := sha256.New()
+	for i := range entries {
+		pubKeys[i] = schnorr.SerializePubKey(entries[i].pubKey)
+		seedHash.Write(pubKeys[i])
+	}
+	for i := range entries {
+		seedHash.Write(entries[i].msg)
+	}
+	for i := range entries {
+		sigs[i] = entries[i].sig.Serialize()
+		seedHash.Write(sigs[i])
+	}
+	seed := seedHash.Sum(nil)
+
+	points := make([]btcec.JacobianPoint, 0, 2*len(entries))
+	scalars := make([]btcec.ModNScalar, 0, 2*len(entries))
+	var sumS btcec.ModNScalar
+	for i := range entries {
+		if len(entries[i].msg) != chainhash.HashSize {
+			return false
+		}
+
+		// Derive the coefficient of the signature.
+		var a btcec.ModNScalar
+		if i == 0 {
+			a.SetInt(1)
+		} else {
+			var buf [sha256.Size + 4]byte
+			copy(buf[:], seed)
+			binary.BigEndian.PutUint32(buf[sha256.Size:], uint32(i))
+			coef := sha256.Sum256(buf[:])
+			a.SetBytes(&coef)
+			if a.IsZero() {
+				a.SetInt(1)
+			}
+		}
+
+		// Parse r and s, which have already been ensured to be in range
+		// when the signature was parsed.
+		sig := sigs[i]
+		var r btcec.FieldVal
+		if overflow := r.SetByteSlice(sig[:32]); overflow {
+			return false
+		}
+		var s btcec.ModNScalar
+		if overflow := s.SetByteSlice(sig[32:]); overflow {
+			return false
+		}
+
+		// R = lift_x(r).
+		var R btcec.JacobianPoint
+		if !btcec.DecompressY(&r, false, &R.Y) {
+			return false
+		}
+		R.X.Set(&r)
+		R.Y.Normalize()
+		R.Z.SetInt(1)
+
+		// P = lift_x(pk), so use the point with the even y coordinate.
+		var P btcec.JacobianPoint
+		entries[i].pubKey.AsJacobian(&P)
+		if P.Y.IsOdd() {
+			P.Y.Negate(1).Normalize()
+		}
+
+		// e = int(hash_BIP0340/challenge(bytes(r) || bytes(P) || m)).
+		commitment := chainhash.TaggedHash(
+			chainhash.TagBIP0340Challenge, sig[:32], pubKeys[i],
+			entries[i].msg,
+		)
+		var e btcec.ModNScalar
+		e.SetBytes((*[32]byte)(commitment))
+
+		var as btcec.ModNScalar
+		sumS.Add(as.Mul2(&a, &s))
+		points = append(points, R, P)
+		scalars = append(scalars, a, *e.Mul(&a))
+	}
+
+	var lhs, rhs btcec.JacobianPoint
+	btcec.ScalarBaseMultNonConst(&sumS, &lhs)
+	multiScalarMultNonConst(points, scalars, &rhs)
+	return jacobianPointsEqual(&lhs, &rhs)
+}
+
+// jacobianPointsEqual returns whether the passed normalized points are the same
+// point.  The points are converted to affine coordinates in the process.
+func jacobianPointsEqual(p1, p2 *btcec.JacobianPoint) bool {
+	p1Inf := p1.Z.IsZero()
+	p2Inf := p2.Z.IsZero()
+	if p1Inf || p2Inf {
+		return p1Inf == p2Inf
+	}
+
+	p1.ToAffine()
+	p2.ToAffine()
+	return p1.X.Equals(&p2.X) && p1.Y.Equals(&p2.Y)
+}
+
+// multiScalarMultNonConst calculates the sum of the passed points multiplied by
+// their respective scalar and stores the result in the provided result param in
+// *non-constant* time.
+//
+// The sum is calculated with Strauss' algorithm, so the doublings are shared
+// among all of the points, and with the scalars in windowed non-adjacent form,
+// so only about one in every schnorrBatchWindow+1 bits requires an addition.
+//
+// NOTE: The points must be normalized for this function to return the correct
+// result.  The resulting point will be normalized.
+func multiScalarMultNonConst(points []btcec.JacobianPoint,
+	scalars []btcec.ModNScalar, result *btcec.JacobianPoint) {
+
+	// Precompute the odd multiples P, 3P, 5P, ... of every point and
+	// convert the scalars to their windowed non-adjacent form.
+	const tableSize = 1 << (schnorrBatchWindow - 2)
+	tables := make([][tableSize]btcec.JacobianPoint, len(points))
+	nafs := make([][]int8, len(points))
+	var maxLen int
+	for i := range points {
+		table := &tables[i]
+		table[0].Set(&points[i])
+		var double btcec.JacobianPoint
+		btcec.DoubleNonConst(&points[i], &double)
+		for j := 1; j < tableSize; j++ {
+			btcec.AddNonConst(&table[j-1], &double, &table[j])
+		}
+
+		nafs[i] = wnaf(&scalars[i], schnorrBatchWindow)
+		if len(nafs[i]) > maxLen {
+			maxLen = len(nafs[i])
+		}
+	}
+	tableToAffine(tables)
+
+	// Q = ∞ (point at infinity).
+	var q, neg btcec.JacobianPoint
+	for bit := maxLen - 1; bit >= 0; bit-- {
+		btcec.DoubleNonConst(&q, &q)
+		for i, naf := range nafs {
+			if bit >= len(naf) || naf[bit] == 0 {
+				continue
+			}
+
+			digit := naf[bit]
+			if digit > 0 {
+				btcec.AddNonConst(&q, &tables[i][digit/2], &q)
+				continue
+			}
+			neg.Set(&tables[i][-digit/2])
+			neg.Y.Negate(1).Normalize()
+			btcec.AddNonConst(&q, &neg, &q)
+		}
+	}
+
+	result.Set(&q)
+}
+
+// tableToAffine converts all of the points in the passed tables of precomputed
+// multiples to affine coordinates, which makes adding them considerably faster.
+// The inverses of the z values are calculated with a single inversion using
+// Montgomery's trick.  The points must not be the point at infinity.
+func tableToAffine(tables [][1 << (schnorrBatchWindow - 2)]btcec.JacobianPoint) {
+	numPoints := len(tables) * len(tables[0])
+	if numPoints == 0 {
+		return
+	}
+	point := func(i int) *btcec.JacobianPoint {
+		return &tables[i/len(tables[0])][i%len(tables[0])]
+	}
+
+	// Calculate the running products of the z values, invert the total
+	// product and then walk back to obtain the inverse of every z value.
+	products := make([]btcec.FieldVal, numPoints)
+	products[0].Set(&point(0).Z)
+	for i := 1; i < numPoints; i++ {
+		products[i].Mul2(&products[i-1], &point(i).Z).Normalize()
+	}
+	var inv, zInv, zInv2 btcec.FieldVal
+	inv.Set(&products[numPoints-1]).Inverse()
+	for i := numPoints - 1; i >= 0; i-- {
+		p := point(i)
+		if i > 0 {
+			zInv.Mul2(&inv, &products[i-1])
+			inv.Mul(&p.Z)
+		} else {
+			zInv.Set(&inv)
+		}
+
+		zInv2.SquareVal(&zInv)
+		p.X.Mul(&zInv2).Normalize()
+		p.Y.Mul(zInv2.Mul(&zInv)).Normalize()
+		p.Z.SetInt(1)
+	}
+}
+
+// wnaf returns the windowed non-adjacent form of the passed scalar with the
+// passed window width as a little endian slice of digits.  Every non-zero digit
+// is odd, has an absolute value less than 2^(w-1) and is followed by at least
+// w-1 zero digits.
+func wnaf(k *btcec.ModNScalar, w uint) []int8 {
+	// Load the scalar into little endian 64-bit words with an additional
+	// word for the carry that subtracting negative digits might produce.
+	var words [5]uint64
+	kBytes := k.Bytes()
+	for i := 0; i < 4; i++ {
+		words[i] = binary.BigEndian.Uint64(kBytes[24-8*i:])
+	}
+	isZero := func() bool {
+		return words[0]|words[1]|words[2]|words[3]|words[4] == 0
+	}
+
+	naf := make([]int8, 0, 258)
+	window := uint64(1) << w
+	for !isZero() {
+		var digit int64
+		if words[0]&1 == 1 {
+			digit = int64(words[0] & (window - 1))
+			if digit >= int64(window>>1) {
+				digit -= int64(window)
+			}
+
+			// Subtract the digit from the scalar, which makes the
+			// low w bits zero.
+			if digit > 0 {
+				var borrow uint64
+				words[0], borrow = bits.Sub64(words[0], uint64(digit), 0)
+				for j := 1; j < len(words) && borrow != 0; j++ {
+					words[j], borrow = bits.Sub64(words[j], 0, borrow)
+				}
+			} else {
+				var carry uint64
+				words[0], carry = bits.Add64(words[0], uint64(-digit), 0)
+				for j := 1; j < len(words) && carry != 0; j++ {
+					words[j], carry = bits.Add64(words[j], 0, carry)
+				}
+			}
+		}
+		naf = append(naf, int8(digit))
+
+		// Shift the scalar right by one bit.
+		for j := 0; j < len(words)-1; j++ {
+			words[j] = words[j]>>1 | words[j+1]<<63
+		}
+		words[len(words)-1] >>= 1
+	}
+
+	return naf
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchnorrBatch tests that the engine defers the verification of the
+// signatures of taproot key and script path spends to the schnorr batch when
+// one is set, and that the batch detects invalid signatures.
+func TestSchnorrBatch(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	internalKey := privKey.PubKey()
+
+	builder := NewScriptBuilder()
+	builder.AddData(schnorr.SerializePubKey(internalKey))
+	builder.AddOp(OP_CHECKSIG)
+	leafScript, err := builder.Script()
+	require.NoError(t, err)
+	tapLeaf := NewBaseTapLeaf(leafScript)
+	tapScriptTree := AssembleTaprootScriptTree(tapLeaf)
+	ctrlBlock, err := tapScriptTree.ControlBlock(tapLeaf, internalKey)
+	require.NoError(t, err)
+
+	// The first input is spent through the key path of a BIP 86 output and
+	// the second one through the script path of the output with the tree.
+	keySpendScript, err := PayToTaprootScript(
+		ComputeTaprootKeyNoScript(internalKey),
+	)
+	require.NoError(t, err)
+	tapScriptRootHash := tapScriptTree.RootNode.TapHash()
+	scriptSpendScript, err := PayToTaprootScript(
+		ComputeTaprootOutputKey(internalKey, tapScriptRootHash[:]),
+	)
+	require.NoError(t, err)
+
+	prevOuts := map[wire.OutPoint]*wire.TxOut{
+		{Index: 0}: {Value: 1e8, PkScript: keySpendScript},
+		{Index: 1}: {Value: 2e8, PkScript: scriptSpendScript},
+	}
+	testTx := wire.NewMsgTx(2)
+	testTx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 0}})
+	testTx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 1}})
+	testTx.AddTxOut(&wire.TxOut{Value: 3e8, PkScript: keySpendScript})
+	prevFetcher := NewMultiPrevOutFetcher(prevOuts)
+	sigHashes := NewTxSigHashes(testTx, prevFetcher)
+
+	keySpendWitness, err := TaprootWitnessSignature(
+		testTx, sigHashes, 0, 1e8, keySpendScript, SigHashDefault,
+		privKey,
+	)
+	require.NoError(t, err)
+	testTx.TxIn[0].Witness = keySpendWitness
+	scriptSpendWitness, err := TapscriptWitnessSignature(
+		testTx, sigHashes, 1, 2e8, scriptSpendScript, tapLeaf,
+		ctrlBlock, SigHashDefault, privKey,
+	)
+	require.NoError(t, err)
+	testTx.TxIn[1].Witness = scriptSpendWitness
+
+	// execute runs the scripts of both inputs with the passed batch.
+	execute := func(tx *wire.MsgTx, batch *SchnorrBatch) {
+		t.Helper()
+
+		for i, txIn := range tx.TxIn {
+			prevOut := prevOuts[txIn.PreviousOutPoint]
+			vm, err := NewEngine(
+				prevOut.PkScript, tx, i, StandardVerifyFlags,
+				nil, sigHashes, prevOut.Value, prevFetcher,
+			)
+			require.NoError(t, err)
+			vm.SetSchnorrBatch(batch)
+			require.NoError(t, vm.Execute())
+		}
+	}
+
+	batch := NewSchnorrBatch(nil)
+	execute(testTx, batch)
+	require.Equal(t, 2, batch.Len())
+	require.True(t, batch.Verify())
+	require.Equal(t, 0, batch.Len())
+
+	// Corrupting the signatures doesn't fail the execution since their
+	// verification is deferred, but it fails the batch.
+	for i := range testTx.TxIn {
+		badTx := testTx.Copy()
+		badTx.TxIn[i].Witness[0][0] ^= 0x01
+		execute(badTx, batch)
+		require.Equal(t, 2, batch.Len())
+		require.False(t, batch.Verify())
+	}
+
+	// The signatures of a batch are only added to its signature cache
+	// once the batch verifies.
+	sigCache := NewSigCache(10)
+	batch = NewSchnorrBatch(sigCache)
+	badTx := testTx.Copy()
+	badTx.TxIn[0].Witness[0][0] ^= 0x01
+	execute(badTx, batch)
+	require.False(t, batch.Verify())
+	require.Empty(t, sigCache.validSigs)
+	execute(testTx, batch)
+	require.True(t, batch.Verify())
+	require.Len(t, sigCache.validSigs, 2)
+}
+
+// schnorrBatchEntries returns the passed number of valid signatures of random
+// messages by random keys.
+func schnorrBatchEntries(t testing.TB, n int) []schnorrBatchEntry {
+	entries := make([]schnorrBatchEntry, n)
+	for i := range entries {
+		privKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+		msg := make([]byte, 32)
+		_, err = rand.Read(msg)
+		require.NoError(t, err)
+		sig, err := schnorr.Sign(privKey, msg)
+		require.NoError(t, err)
+		entries[i] = schnorrBatchEntry{
+			pubKey: privKey.PubKey(),
+			msg:    msg,
+			sig:    sig,
+		}
+	}
+	return entries
+}
+
+// TestSchnorrBatchVerify ensures the batch verification accepts batches of
+// valid signatures and rejects batches with any kind of invalid signature.
+func TestSchnorrBatchVerify(t *testing.T) {
+	t.Parallel()
+
+	// verify adds the entries to a batch and verifies it.
+	verify := func(entries []schnorrBatchEntry) bool {
+		batch := NewSchnorrBatch(nil)
+		for _, entry := range entries {
+			batch.Add(entry.pubKey, entry.msg, entry.sig, nil, nil)
+		}
+		return batch.Verify()
+	}
+
+	require.True(t, verify(nil))
+	for _, n := range []int{1, 2, 3, 16, 50} {
+		entries := schnorrBatchEntries(t, n)
+		require.True(t, verify(entries), "batch of %d", n)
+
+		// Invalidate each of the signatures of the batch in turn by
+		// changing the message, the public key, and the signature.
+		for i := range entries {
+			bad := append([]schnorrBatchEntry(nil), entries...)
+			badMsg := append([]byte(nil), entries[i].msg...)
+			badMsg[0] ^= 0x01
+			bad[i].msg = badMsg
+			require.False(t, verify(bad), "batch of %d, bad "+
+				"message %d", n, i)
+
+			bad[i] = entries[i]
+			bad[i].pubKey = entries[(i+1)%n].pubKey
+			if n > 1 {
+				require.False(t, verify(bad), "batch of %d, "+
+					"bad public key %d", n, i)
+			}
+
+			bad[i] = entries[i]
+			sigBytes := entries[i].sig.Serialize()
+			sigBytes[63] ^= 0x01
+			badSig, err := schnorr.ParseSignature(sigBytes)
+			require.NoError(t, err)
+			bad[i].sig = badSig
+			require.False(t, verify(bad), "batch of %d, bad "+
+				"signature %d", n, i)
+		}
+
+		// Swapping the signatures of two messages must fail too.
+		if n > 1 {
+			bad := append([]schnorrBatchEntry(nil), entries...)
+			bad[0].sig, bad[1].sig = bad[1].sig, bad[0].sig
+			require.False(t, verify(bad), "batch of %d, swapped "+
+				"signatures", n)
+		}
+	}
+}
+
+// TestWNAF ensures the windowed non-adjacent form of scalars has the expected
+// properties and represents the original scalar.
+func TestWNAF(t *testing.T) {
+	t.Parallel()
+
+	var scalars []btcec.ModNScalar
+	for _, v := range []uint32{0, 1, 15, 16, 17, 31, 0xffffffff} {
+		var k btcec.ModNScalar
+		k.SetInt(v)
+		scalars = append(scalars, k)
+	}
+	var nMinusOne btcec.ModNScalar
+	nMinusOne.SetInt(1).Negate()
+	scalars = append(scalars, nMinusOne)
+	for i := 0; i < 100; i++ {
+		var b [32]byte
+		_, err := rand.Read(b[:])
+		require.NoError(t, err)
+		var k btcec.ModNScalar
+		k.SetBytes(&b)
+		scalars = append(scalars, k)
+	}
+
+	for _, k := range scalars {
+		k := k
+		naf := wnaf(&k, schnorrBatchWindow)
+
+		// Rebuild the scalar from the digits while checking the digits
+		// are odd, in range, and separated by enough zeros.
+		var got, digit btcec.ModNScalar
+		lastNonZero := -schnorrBatchWindow
+		for i := len(naf) - 1; i >= 0; i-- {
+			got.Add(&got)
+			d := naf[i]
+			if d == 0 {
+				continue
+			}
+			require.Equal(t, int8(1), d&1, "even digit %d", d)
+			require.Less(t, int(d), 1<<(schnorrBatchWindow-1))
+			require.Greater(t, int(d), -(1 << (schnorrBatchWindow - 1)))
+			if lastNonZero != -schnorrBatchWindow {
+				require.GreaterOrEqual(t, lastNonZero-i,
+					schnorrBatchWindow)
+			}
+			lastNonZero = i
+			if d > 0 {
+				digit.SetInt(uint32(d))
+			} else {
+				digit.SetInt(uint32(-d)).Negate()
+			}
+			got.Add(&digit)
+		}
+		require.True(t, got.Equals(&k), "scalar %v", k)
+	}
+}
+
+// BenchmarkSchnorrBatchVerify benchmarks the verification of batches of
+// signatures of different sizes compared to verifying them individually.
+func BenchmarkSchnorrBatchVerify(b *testing.B) {
+	for _, n := range []int{2, 16, 64} {
+		entries := schnorrBatchEntries(b, n)
+
+		b.Run(fmt.Sprintf("individual/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, entry := range entries {
+					if !entry.sig.Verify(entry.msg, entry.pubKey) {
+						b.Fatal("invalid signature")
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("batch/%d", n), func(b *testing.B) {
+			batch := NewSchnorrBatch(nil)
+			for i := 0; i < b.N; i++ {
+				for _, entry := range entries {
+					batch.Add(entry.pubKey, entry.msg, entry.sig, nil, nil)
+				}
+				if !batch.Verify() {
+					b.Fatal("invalid batch")
+				}
+			}
+		})
+	}
+}
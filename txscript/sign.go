@@ -172,6 +172,43 @@ func RawTxInTapscriptSignature(tx *wire.MsgTx, sigHashes *TxSigHashes, idx int,
 	return signature.Serialize(), nil
 }
 
+// TapscriptWitness returns the witness stack which spends a taproot output
+// through the script path of the passed leaf.  The script inputs are the items
+// the leaf script expects on the stack, such as signatures, ordered from the
+// bottom of the stack to the top, and the control block proves the leaf is
+// committed to by the output key.
+func TapscriptWitness(scriptInputs [][]byte, tapLeaf TapLeaf,
+	ctrlBlock *ControlBlock) (wire.TxWitness, error) {
+
+	ctrlBlockBytes, err := ctrlBlock.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	witness := make(wire.TxWitness, 0, len(scriptInputs)+2)
+	witness = append(witness, scriptInputs...)
+	return append(witness, tapLeaf.Script, ctrlBlockBytes), nil
+}
+
+// TapscriptWitnessSignature returns a valid witness stack that can be used to
+// spend the script path of a taproot input through a leaf whose script only
+// requires a single signature by the passed private key, such as
+// <pubkey> OP_CHECKSIG.  The control block of the leaf can be obtained from the
+// script tree with IndexedTapScriptTree.ControlBlock.
+func TapscriptWitnessSignature(tx *wire.MsgTx, sigHashes *TxSigHashes, idx int,
+	amt int64, pkScript []byte, tapLeaf TapLeaf, ctrlBlock *ControlBlock,
+	hashType SigHashType, privKey *btcec.PrivateKey) (wire.TxWitness, error) {
+
+	sig, err := RawTxInTapscriptSignature(
+		tx, sigHashes, idx, amt, pkScript, tapLeaf, hashType, privKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return TapscriptWitness([][]byte{sig}, tapLeaf, ctrlBlock)
+}
+
 // RawTxInSignature returns the serialized ECDSA signature for the input idx of
 // the given transaction, with hashType appended to it.
 func RawTxInSignature(tx *wire.MsgTx, idx int, subScript []byte,
@@ -306,6 +343,11 @@ func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 	case NullDataTy:
 		return nil, class, nil, 0,
 			errors.New("can't sign NULLDATA transactions")
+	case WitnessV1TaprootTy:
+		return nil, class, nil, 0,
+			errors.New("can't sign taproot transactions with a " +
+				"signature script, use TaprootWitnessSignature " +
+				"or TapscriptWitnessSignature")
 	default:
 		return nil, class, nil, 0,
 			errors.New("can't sign unknown transactions")
@@ -1896,3 +1896,75 @@ func TestRawTxInTapscriptSignature(t *testing.T) {
 		})
 	}
 }
+
+// TestTapscriptWitnessSignature tests that the witness produced for a script
+// path spend of a leaf of a script tree with several leaves is valid.
+func TestTapscriptWitnessSignature(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	internalKey := privKey.PubKey()
+
+	// The leaf spent below requires a signature by the private key, while
+	// the other leaves are never satisfied.
+	builder := NewScriptBuilder()
+	builder.AddData(schnorr.SerializePubKey(internalKey))
+	builder.AddOp(OP_CHECKSIG)
+	leafScript, err := builder.Script()
+	require.NoError(t, err)
+	tapLeaf := NewBaseTapLeaf(leafScript)
+	tapScriptTree := AssembleTaprootScriptTree(
+		NewBaseTapLeaf([]byte{OP_RETURN}), tapLeaf,
+		NewBaseTapLeaf([]byte{OP_RETURN, OP_1}),
+	)
+
+	_, err = tapScriptTree.ControlBlock(
+		NewBaseTapLeaf([]byte{OP_2}), internalKey,
+	)
+	require.Error(t, err)
+	ctrlBlock, err := tapScriptTree.ControlBlock(tapLeaf, internalKey)
+	require.NoError(t, err)
+
+	tapScriptRootHash := tapScriptTree.RootNode.TapHash()
+	outputKey := ComputeTaprootOutputKey(
+		internalKey, tapScriptRootHash[:],
+	)
+	p2trScript, err := PayToTaprootScript(outputKey)
+	require.NoError(t, err)
+
+	testTx := wire.NewMsgTx(2)
+	testTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{
+			Index: 1,
+		},
+	})
+	txOut := &wire.TxOut{
+		Value: 1e8, PkScript: p2trScript,
+	}
+	testTx.AddTxOut(txOut)
+
+	prevFetcher := NewCannedPrevOutputFetcher(txOut.PkScript, txOut.Value)
+	sigHashes := NewTxSigHashes(testTx, prevFetcher)
+	witness, err := TapscriptWitnessSignature(
+		testTx, sigHashes, 0, txOut.Value, txOut.PkScript, tapLeaf,
+		ctrlBlock, SigHashDefault, privKey,
+	)
+	require.NoError(t, err)
+	require.Len(t, witness, 3)
+
+	testTx.TxIn[0].Witness = witness
+	vm, err := NewEngine(
+		txOut.PkScript, testTx, 0, StandardVerifyFlags, nil, sigHashes,
+		txOut.Value, prevFetcher,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+
+	// Taproot outputs can't be signed with a signature script.
+	_, err = SignTxOutput(
+		&chaincfg.TestNet3Params, testTx, 0, txOut.PkScript, SigHashAll,
+		mkGetKey(nil), mkGetScript(nil), nil,
+	)
+	require.Error(t, err)
+}
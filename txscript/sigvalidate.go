@@ -249,6 +249,10 @@ type taprootSigVerifier struct {
 	annex []byte
 
 	prevOuts PrevOutputFetcher
+
+	// batch, when set, is used to defer the verification of the signature
+	// instead of verifying it right away.
+	batch SchnorrBatchVerifier
 }
 
 // parseTaprootSigAndPubKey attempts to parse the public key and signature for
@@ -349,6 +353,15 @@ func (t *taprootSigVerifier) verifySig(sigHash []byte) bool {
 		}
 	}
 
+	// When a batch is set, the verification is deferred to it and the
+	// signature is assumed to be valid.  This is only sound since an
+	// invalid taproot signature always fails the script, so the batch
+	// failing invalidates the script just the same.
+	if t.batch != nil {
+		t.batch.Add(t.pubKey, sigHash, t.sig, t.fullSigBytes, t.pkBytes)
+		return true
+	}
+
 	// If we didn't find the entry in the cache, then we'll perform full
 	// verification as normal, adding the entry to the cache if it's found
 	// to be valid.
@@ -426,6 +439,7 @@ func newBaseTapscriptSigVerifier(pkBytes, rawSig []byte,
 		if err != nil {
 			return nil, err
 		}
+		baseTaprootVerifier.batch = vm.schnorrBatch
 
 		return &baseTapscriptSigVerifier{
 			taprootSigVerifier: baseTaprootVerifier,
@@ -61,6 +61,19 @@ func VerifyTaprootKeySpend(witnessProgram []byte, rawSig []byte, tx *wire.MsgTx,
 	inputIndex int, prevOuts PrevOutputFetcher, hashCache *TxSigHashes,
 	sigCache *SigCache) error {
 
+	return verifyTaprootKeySpend(
+		witnessProgram, rawSig, tx, inputIndex, prevOuts, hashCache,
+		sigCache, nil,
+	)
+}
+
+// verifyTaprootKeySpend is the same as VerifyTaprootKeySpend except the
+// verification of the signature is deferred to the passed batch when it is
+// not nil.
+func verifyTaprootKeySpend(witnessProgram []byte, rawSig []byte, tx *wire.MsgTx,
+	inputIndex int, prevOuts PrevOutputFetcher, hashCache *TxSigHashes,
+	sigCache *SigCache, batch SchnorrBatchVerifier) error {
+
 	// First, we'll need to extract the public key from the witness
 	// program.
 	rawKey := witnessProgram
@@ -83,6 +96,7 @@ func VerifyTaprootKeySpend(witnessProgram []byte, rawSig []byte, tx *wire.MsgTx,
 	if err != nil {
 		return err
 	}
+	keySpendVerifier.batch = batch
 
 	result := keySpendVerifier.Verify()
 	if result.sigValid {
@@ -571,6 +585,23 @@ func NewIndexedTapScriptTree(numLeaves int) *IndexedTapScriptTree {
 	}
 }
 
+// ControlBlock returns the control block which proves the passed leaf is
+// included in the script tree when spending an output whose key commits to the
+// tree with the passed internal key.  An error is returned when the leaf isn't
+// part of the tree.
+func (t *IndexedTapScriptTree) ControlBlock(leaf TapLeaf,
+	internalKey *btcec.PublicKey) (*ControlBlock, error) {
+
+	proofIndex, ok := t.LeafProofIndex[leaf.TapHash()]
+	if !ok {
+		return nil, fmt.Errorf("leaf %v is not part of the script tree",
+			leaf.TapHash())
+	}
+
+	ctrlBlock := t.LeafMerkleProofs[proofIndex].ToControlBlock(internalKey)
+	return &ctrlBlock, nil
+}
+
 // hashTapNodes takes a left and right now, and returns the left and right tap
 // hashes, along with the new combined node. If both nodes are nil, nil
 // pointers are returned. If the right now is nil, then the left node is passed
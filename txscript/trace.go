@@ -0,0 +1,203 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// scriptFlagStrings is an ordered list of the script flags along with their
+// human-readable names.
+var scriptFlagStrings = []struct {
+	flag ScriptFlags
+	name string
+}{
+	{ScriptBip16, "ScriptBip16"},
+	{ScriptStrictMultiSig, "ScriptStrictMultiSig"},
+	{ScriptDiscourageUpgradableNops, "ScriptDiscourageUpgradableNops"},
+	{ScriptVerifyCheckLockTimeVerify, "ScriptVerifyCheckLockTimeVerify"},
+	{ScriptVerifyCheckSequenceVerify, "ScriptVerifyCheckSequenceVerify"},
+	{ScriptVerifyCleanStack, "ScriptVerifyCleanStack"},
+	{ScriptVerifyDERSignatures, "ScriptVerifyDERSignatures"},
+	{ScriptVerifyLowS, "ScriptVerifyLowS"},
+	{ScriptVerifyMinimalData, "ScriptVerifyMinimalData"},
+	{ScriptVerifyNullFail, "ScriptVerifyNullFail"},
+	{ScriptVerifySigPushOnly, "ScriptVerifySigPushOnly"},
+	{ScriptVerifyStrictEncoding, "ScriptVerifyStrictEncoding"},
+	{ScriptVerifyWitness, "ScriptVerifyWitness"},
+	{ScriptVerifyDiscourageUpgradeableWitnessProgram,
+		"ScriptVerifyDiscourageUpgradeableWitnessProgram"},
+	{ScriptVerifyMinimalIf, "ScriptVerifyMinimalIf"},
+	{ScriptVerifyWitnessPubKeyType, "ScriptVerifyWitnessPubKeyType"},
+	{ScriptVerifyTaproot, "ScriptVerifyTaproot"},
+	{ScriptVerifyDiscourageUpgradeableTaprootVersion,
+		"ScriptVerifyDiscourageUpgradeableTaprootVersion"},
+	{ScriptVerifyDiscourageOpSuccess, "ScriptVerifyDiscourageOpSuccess"},
+	{ScriptVerifyDiscourageUpgradeablePubkeyType,
+		"ScriptVerifyDiscourageUpgradeablePubkeyType"},
+	{ScriptVerifyConstScriptCode, "ScriptVerifyConstScriptCode"},
+}
+
+// String returns the ScriptFlags in human-readable form, as the names of the
+// set flags separated by "|".
+func (f ScriptFlags) String() string {
+	// No flags are set.
+	if f == 0 {
+		return "0x0"
+	}
+
+	// Add individual bit flags.
+	var names []string
+	for _, s := range scriptFlagStrings {
+		if f&s.flag == s.flag {
+			names = append(names, s.name)
+			f -= s.flag
+		}
+	}
+
+	// Add any remaining flags which aren't accounted for as hex.
+	if f != 0 {
+		names = append(names, "0x"+strconv.FormatUint(uint64(f), 16))
+	}
+	return strings.Join(names, "|")
+}
+
+// TraceStep houses the state of the script engine right before an opcode is
+// executed, as recorded in an ExecutionTrace.
+type TraceStep struct {
+	// ScriptIndex is the index of the script the opcode belongs to.  Index
+	// 0 is the signature script and 1 is the public key script, while the
+	// redeem script of pay-to-script-hash spends and the witness script of
+	// witness spends follow them.
+	ScriptIndex int
+
+	// OpcodeIndex is the index of the opcode within its script.
+	OpcodeIndex int
+
+	// Opcode is the disassembly of the opcode which is executed next.  It
+	// is empty for the final step of a trace, which holds the state of the
+	// engine once all of the scripts were executed.
+	Opcode string
+
+	// Executing is whether the current conditional branch is executing,
+	// which is false when the opcode is skipped by a conditional.
+	Executing bool
+
+	// Stack is the content of the data stack, from the bottom to the top.
+	Stack [][]byte
+
+	// AltStack is the content of the alt stack, from the bottom to the
+	// top.
+	AltStack [][]byte
+}
+
+// ExecutionTrace is a structured trace of the execution of a script by a
+// DebugEngine.
+type ExecutionTrace struct {
+	// Flags are the script flags the scripts were executed with.
+	Flags ScriptFlags
+
+	// Steps are the states of the engine before each executed opcode,
+	// followed by the final state when the execution completed.  When the
+	// execution failed, the last step holds the opcode which failed, or no
+	// opcode when the final state of the stacks was invalid.
+	Steps []TraceStep
+
+	// Err is the reason the execution failed, or nil when the scripts
+	// executed successfully.
+	Err error
+}
+
+// ErrorCode returns the code of the script error the execution failed with.
+// The boolean is false if the execution succeeded, or failed with an error
+// which isn't a script error.
+func (t *ExecutionTrace) ErrorCode() (ErrorCode, bool) {
+	var serr Error
+	if !errors.As(t.Err, &serr) {
+		return 0, false
+	}
+	return serr.ErrorCode, true
+}
+
+// DebugEngine is a script engine which records a structured trace of the
+// execution of the scripts, which is useful to find out why a spend fails.
+//
+// NOTE: Recording the trace copies both stacks for every executed opcode, so
+// a DebugEngine SHOULD NOT be used during regular operation.
+type DebugEngine struct {
+	*Engine
+
+	trace ExecutionTrace
+}
+
+// NewTracingEngine returns a new script engine for the provided public key
+// script, transaction, and input index which records a trace of the execution
+// of the scripts when Execute is called.  See NewEngine for details on the
+// parameters.
+func NewTracingEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int,
+	flags ScriptFlags, sigCache *SigCache, hashCache *TxSigHashes,
+	inputAmount int64,
+	prevOutFetcher PrevOutputFetcher) (*DebugEngine, error) {
+
+	vm, err := NewEngine(
+		scriptPubKey, tx, txIdx, flags, sigCache, hashCache,
+		inputAmount, prevOutFetcher,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DebugEngine{
+		Engine: vm,
+		trace:  ExecutionTrace{Flags: flags},
+	}
+	vm.stepCallback = d.recordStep
+	return d, nil
+}
+
+// recordStep adds the current state of the engine to the trace.  It is used as
+// the step callback of the engine, so it is called before the first opcode is
+// executed and after each executed opcode.
+func (d *DebugEngine) recordStep(info *StepInfo) error {
+	// The disassembly of the next opcode isn't available once all of the
+	// scripts were executed.
+	opcode, err := d.DisasmPC()
+	if err == nil {
+		// Strip the script and opcode indexes prefixing the opcode.
+		if i := strings.Index(opcode, ": "); i != -1 {
+			opcode = opcode[i+2:]
+		}
+	} else {
+		opcode = ""
+	}
+
+	d.trace.Steps = append(d.trace.Steps, TraceStep{
+		ScriptIndex: info.ScriptIndex,
+		OpcodeIndex: info.OpcodeIndex,
+		Opcode:      opcode,
+		Executing:   d.isBranchExecuting(),
+		Stack:       info.Stack,
+		AltStack:    info.AltStack,
+	})
+	return nil
+}
+
+// Execute executes all of the scripts like the Execute method of Engine, while
+// recording the trace of the execution.
+func (d *DebugEngine) Execute() error {
+	err := d.Engine.Execute()
+	d.trace.Err = err
+	return err
+}
+
+// Trace returns the trace of the execution of the scripts, which is complete
+// once Execute returned.
+func (d *DebugEngine) Trace() *ExecutionTrace {
+	return &d.trace
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTracingEngine ensures the trace recorded by a DebugEngine holds the
+// state of the engine before each executed opcode along with the reason the
+// execution failed.
+func TestTracingEngine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		sigScript []byte
+		pkScript  []byte
+		opcodes   []string
+		stacks    [][][]byte
+		errCode   ErrorCode
+		fail      bool
+	}{{
+		name:      "success",
+		sigScript: []byte{OP_1},
+		pkScript:  []byte{OP_2, OP_ADD, OP_3, OP_EQUAL},
+		opcodes:   []string{"OP_1", "OP_2", "OP_ADD", "OP_3", "OP_EQUAL", ""},
+		stacks: [][][]byte{
+			{}, {{1}}, {{1}, {2}}, {{3}}, {{3}, {3}}, {{1}},
+		},
+	}, {
+		name:      "failed opcode",
+		sigScript: []byte{OP_2},
+		pkScript:  []byte{OP_2, OP_ADD, OP_3, OP_EQUALVERIFY, OP_1},
+		opcodes: []string{
+			"OP_2", "OP_2", "OP_ADD", "OP_3", "OP_EQUALVERIFY",
+		},
+		stacks: [][][]byte{
+			{}, {{2}}, {{2}, {2}}, {{4}}, {{4}, {3}},
+		},
+		errCode: ErrEqualVerify,
+		fail:    true,
+	}, {
+		name:      "false result",
+		sigScript: []byte{OP_2},
+		pkScript:  []byte{OP_2, OP_ADD, OP_3, OP_EQUAL},
+		opcodes: []string{
+			"OP_2", "OP_2", "OP_ADD", "OP_3", "OP_EQUAL", "",
+		},
+		stacks: [][][]byte{
+			{}, {{2}}, {{2}, {2}}, {{4}}, {{4}, {3}}, {nil},
+		},
+		errCode: ErrEvalFalse,
+		fail:    true,
+	}}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{SignatureScript: test.sigScript})
+		vm, err := NewTracingEngine(
+			test.pkScript, tx, 0, StandardVerifyFlags, nil, nil, 0,
+			nil,
+		)
+		require.NoError(t, err, test.name)
+
+		err = vm.Execute()
+		trace := vm.Trace()
+		require.Equal(t, err, trace.Err, test.name)
+		require.Equal(t, StandardVerifyFlags, trace.Flags, test.name)
+
+		errCode, ok := trace.ErrorCode()
+		require.Equal(t, test.fail, ok, test.name)
+		if test.fail {
+			require.Equal(t, test.errCode, errCode, test.name)
+		}
+
+		require.Len(t, trace.Steps, len(test.opcodes), test.name)
+		for i, step := range trace.Steps {
+			require.Equal(t, test.opcodes[i], step.Opcode, test.name)
+			require.True(t, step.Executing, test.name)
+			require.Len(t, step.Stack, len(test.stacks[i]),
+				"%s: step %d", test.name, i)
+			for j := range step.Stack {
+				require.Equal(t, len(test.stacks[i][j]),
+					len(step.Stack[j]), test.name)
+				if len(step.Stack[j]) != 0 {
+					require.Equal(t, test.stacks[i][j],
+						step.Stack[j], test.name)
+				}
+			}
+		}
+	}
+}
+
+// TestScriptFlagsString ensures script flags are printed as expected.
+func TestScriptFlagsString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		flags ScriptFlags
+		want  string
+	}{
+		{0, "0x0"},
+		{ScriptBip16, "ScriptBip16"},
+		{ScriptBip16 | ScriptVerifyWitness,
+			"ScriptBip16|ScriptVerifyWitness"},
+		{ScriptVerifyConstScriptCode << 1, "0x200000"},
+		{ScriptVerifyTaproot | ScriptVerifyConstScriptCode<<1,
+			"ScriptVerifyTaproot|0x200000"},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, test.flags.String())
+	}
+}
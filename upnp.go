@@ -57,6 +57,8 @@ type NAT interface {
 	// Remove a previously added port mapping from external port to
 	// internal port.
 	DeletePortMapping(protocol string, externalPort, internalPort int) (err error)
+	// Protocol returns the name of the protocol used to manipulate the NAT.
+	Protocol() string
 }
 
 type upnpNAT struct {
@@ -332,6 +334,12 @@ type getExternalIPAddressResponse struct {
 	ExternalIPAddress string   `xml:"NewExternalIPAddress"`
 }
 
+// Protocol implements the NAT interface by returning the name of the UPnP
+// protocol.
+func (n *upnpNAT) Protocol() string {
+	return "upnp"
+}
+
 // GetExternalAddress implements the NAT interface by fetching the external IP
 // from the UPnP router.
 func (n *upnpNAT) GetExternalAddress() (addr net.IP, err error) {
@@ -0,0 +1,270 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// bnbMaxTries is the maximum number of steps of the branch and bound
+	// search before it gives up.
+	bnbMaxTries = 100000
+
+	// knapsackIterations is the number of random subsets the knapsack
+	// algorithm tries to find the one closest to the target.
+	knapsackIterations = 1000
+
+	// minChange is the amount of change the knapsack algorithm aims for,
+	// so it doesn't create change which is barely worth spending.
+	minChange = 1000000
+)
+
+// coin is an output which may be selected to fund a transaction.
+type coin struct {
+	utxo *Utxo
+
+	// weight is the estimated weight of the input spending the output.
+	weight int64
+
+	// fee is the fee of the input at the fee rate of the transaction and
+	// longTermFee the fee at the long term fee rate, which is what the
+	// input would cost when spent later instead.
+	fee         int64
+	longTermFee int64
+
+	// value is the amount the output contributes to the selection, which
+	// is its amount less the fee of its input unless the fee is subtracted
+	// from the outputs of the transaction.
+	value int64
+}
+
+// waste returns how much more the input spending the coin costs now rather
+// than at the long term fee rate.
+func (c *coin) waste() int64 {
+	return c.fee - c.longTermFee
+}
+
+// sumValues returns the total value of the passed coins.
+func sumValues(coins []*coin) int64 {
+	var total int64
+	for _, c := range coins {
+		total += c.value
+	}
+	return total
+}
+
+// selectBnB returns the coins whose total value is within the target and the
+// target plus the cost of change, so the transaction can be funded without a
+// change output, using the branch and bound algorithm.  Of the matching sets
+// found within the limit of tries, the one which wastes the least is returned.
+// It returns nil when no set matches.
+//
+// The coins are explored as a binary tree in which each level decides whether
+// a coin is included, starting with the most valuable coins.  Branches are cut
+// as soon as they can't reach the target, overshoot the target plus the cost of
+// change or waste more than the best set found so far.
+func selectBnB(coins []*coin, target, costOfChange int64) []*coin {
+	sorted := make([]*coin, len(coins))
+	copy(sorted, coins)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].value > sorted[j].value
+	})
+
+	available := sumValues(sorted)
+	if available < target {
+		return nil
+	}
+
+	var value, waste int64
+	var selection, best []bool
+	bestWaste := int64(math.MaxInt64)
+	for tries := 0; tries < bnbMaxTries; tries++ {
+		backtrack := false
+		switch {
+		// The branch can't reach the target, overshoots it or can't be
+		// better than the best set since adding inputs only increases
+		// the waste while fees are above the long term fee rate.
+		case value+available < target ||
+			value > target+costOfChange ||
+			(waste > bestWaste && sorted[0].waste() > 0):
+
+			backtrack = true
+
+		// The branch matches, so record it when it wastes less than the
+		// best set, counting the excess over the target as waste.
+		case value >= target:
+			if waste+value-target <= bestWaste {
+				best = append(best[:0], selection...)
+				bestWaste = waste + value - target
+			}
+			backtrack = true
+		}
+
+		if backtrack {
+			// Walk back to the last included coin and move on to
+			// the branch that omits it.
+			for len(selection) > 0 && !selection[len(selection)-1] {
+				available += sorted[len(selection)-1].value
+				selection = selection[:len(selection)-1]
+			}
+			if len(selection) == 0 {
+				break
+			}
+			selection[len(selection)-1] = false
+			c := sorted[len(selection)-1]
+			value -= c.value
+			waste -= c.waste()
+			continue
+		}
+
+		// Include the next coin unless the previous coin is equivalent
+		// and was omitted, since the branch including it instead was
+		// already explored.
+		i := len(selection)
+		c := sorted[i]
+		available -= c.value
+		if i > 0 && !selection[i-1] && c.value == sorted[i-1].value &&
+			c.fee == sorted[i-1].fee {
+
+			selection = append(selection, false)
+			continue
+		}
+		selection = append(selection, true)
+		value += c.value
+		waste += c.waste()
+	}
+
+	if best == nil {
+		return nil
+	}
+	var selected []*coin
+	for i, included := range best {
+		if included {
+			selected = append(selected, sorted[i])
+		}
+	}
+	return selected
+}
+
+// selectKnapsack returns coins whose total value is at least the target using
+// the knapsack algorithm.  It prefers a single coin matching the target, then
+// the smallest set of the coins below the target plus the minimum change which
+// reaches the target or, failing that, the target plus the minimum change, and
+// otherwise the smallest coin above the target.  It returns nil when the coins
+// don't reach the target.
+func selectKnapsack(coins []*coin, target int64, rng *rand.Rand) []*coin {
+	shuffled := make([]*coin, len(coins))
+	copy(shuffled, coins)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	var lowestLarger *coin
+	var applicable []*coin
+	var total int64
+	for _, c := range shuffled {
+		switch {
+		case c.value == target:
+			return []*coin{c}
+
+		case c.value < target+minChange:
+			applicable = append(applicable, c)
+			total += c.value
+
+		case lowestLarger == nil || c.value < lowestLarger.value:
+			lowestLarger = c
+		}
+	}
+
+	if total == target {
+		return applicable
+	}
+	if total < target {
+		if lowestLarger == nil {
+			return nil
+		}
+		return []*coin{lowestLarger}
+	}
+
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return applicable[i].value > applicable[j].value
+	})
+	best, bestValue := approximateBestSubset(applicable, total, target, rng)
+	if bestValue != target && total >= target+minChange {
+		best, bestValue = approximateBestSubset(applicable, total,
+			target+minChange, rng)
+	}
+
+	// Prefer the smallest coin above the target when the subset doesn't
+	// leave the minimum change or is worth more.
+	if lowestLarger != nil && ((bestValue != target &&
+		bestValue < target+minChange) || lowestLarger.value <= bestValue) {
+
+		return []*coin{lowestLarger}
+	}
+
+	var selected []*coin
+	for i, included := range best {
+		if included {
+			selected = append(selected, applicable[i])
+		}
+	}
+	return selected
+}
+
+// approximateBestSubset returns the subset of the passed coins with the lowest
+// total value which reaches the target among random subsets, along with its
+// value.  The coins must be sorted by descending value and add up to the passed
+// total, which must be at least the target.
+func approximateBestSubset(coins []*coin, total, target int64, rng *rand.Rand) ([]bool, int64) {
+	best := make([]bool, len(coins))
+	for i := range best {
+		best[i] = true
+	}
+	bestValue := total
+
+	included := make([]bool, len(coins))
+	for rep := 0; rep < knapsackIterations && bestValue != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+
+		// Add random coins in the first pass and the remaining ones in
+		// the second pass, dropping the coin that reaches the target
+		// each time to look for a closer subset.
+		var value int64
+		reachedTarget := false
+		for pass := 0; pass < 2 && !reachedTarget; pass++ {
+			for i, c := range coins {
+				var include bool
+				if pass == 0 {
+					include = rng.Intn(2) == 1
+				} else {
+					include = !included[i]
+				}
+				if !include {
+					continue
+				}
+
+				value += c.value
+				included[i] = true
+				if value < target {
+					continue
+				}
+				reachedTarget = true
+				if value < bestValue {
+					bestValue = value
+					copy(best, included)
+				}
+				value -= c.value
+				included[i] = false
+			}
+		}
+	}
+	return best, bestValue
+}
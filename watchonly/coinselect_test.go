@@ -0,0 +1,139 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testCoins returns coins with the passed values whose inputs cost more at the
+// current fee rate than at the long term fee rate.
+func testCoins(values ...int64) []*coin {
+	coins := make([]*coin, 0, len(values))
+	for i, value := range values {
+		coins = append(coins, &coin{
+			utxo: &Utxo{
+				OutPoint: wire.OutPoint{Index: uint32(i)},
+			},
+			fee:         100,
+			longTermFee: 50,
+			value:       value,
+		})
+	}
+	return coins
+}
+
+// TestSelectBnB ensures the branch and bound algorithm finds the set of coins
+// within the target and the target plus the cost of change which wastes the
+// least, and no set when none matches.
+func TestSelectBnB(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		values       []int64
+		target       int64
+		costOfChange int64
+		numSelected  int
+		total        int64
+	}{{
+		name:         "exact match with the fewest inputs",
+		values:       []int64{100000, 200000, 300000, 400000},
+		target:       500000,
+		costOfChange: 0,
+		numSelected:  2,
+		total:        500000,
+	}, {
+		name:         "match within the cost of change",
+		values:       []int64{300000, 500000},
+		target:       400000,
+		costOfChange: 100000,
+		numSelected:  1,
+		total:        500000,
+	}, {
+		name:         "all coins",
+		values:       []int64{100000, 200000, 300000},
+		target:       600000,
+		costOfChange: 1000,
+		numSelected:  3,
+		total:        600000,
+	}, {
+		name:         "no match within the cost of change",
+		values:       []int64{300000, 500000},
+		target:       400000,
+		costOfChange: 50000,
+	}, {
+		name:         "insufficient funds",
+		values:       []int64{100000, 200000},
+		target:       400000,
+		costOfChange: 50000,
+	}}
+
+	for _, test := range tests {
+		selected := selectBnB(testCoins(test.values...), test.target,
+			test.costOfChange)
+		if len(selected) != test.numSelected {
+			t.Errorf("%s: selected %d coins, want %d", test.name,
+				len(selected), test.numSelected)
+			continue
+		}
+		if total := sumValues(selected); total != test.total {
+			t.Errorf("%s: selected coins worth %d, want %d",
+				test.name, total, test.total)
+		}
+	}
+}
+
+// TestSelectKnapsack ensures the knapsack algorithm prefers a coin matching the
+// target, then the smallest subset of smaller coins reaching the target and
+// otherwise the smallest larger coin.
+func TestSelectKnapsack(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values []int64
+		target int64
+		total  int64
+	}{{
+		name:   "single coin matching the target",
+		values: []int64{100000, 500000, 2000000},
+		target: 500000,
+		total:  500000,
+	}, {
+		name:   "closest subset of smaller coins",
+		values: []int64{100000, 200000, 400000},
+		target: 250000,
+		total:  300000,
+	}, {
+		name:   "smallest larger coin",
+		values: []int64{100000, 5000000, 9000000},
+		target: 300000,
+		total:  5000000,
+	}, {
+		name:   "smallest larger coin over change below the minimum",
+		values: []int64{200000, 300000, 3000000},
+		target: 400000,
+		total:  3000000,
+	}, {
+		name:   "insufficient funds",
+		values: []int64{100000, 200000},
+		target: 400000,
+		total:  0,
+	}}
+
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		selected := selectKnapsack(testCoins(test.values...),
+			test.target, rng)
+		if total := sumValues(selected); total != test.total {
+			t.Errorf("%s: selected coins worth %d, want %d",
+				test.name, total, test.total)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// descriptorVersion is the version of the serialized descriptors.
+	descriptorVersion = 1
+
+	// serializedDescriptorLen is the length of a serialized descriptor
+	// excluding the descriptor itself, which is the key it is stored at.
+	// It is the version, the flags, the range and next index and the
+	// timestamp.
+	serializedDescriptorLen = 1 + 1 + 4*3 + 8
+
+	// Flags of the serialized descriptors.
+	flagActive   = 1 << 0
+	flagInternal = 1 << 1
+
+	// serializedOutPointLen is the length of a serialized outpoint.
+	serializedOutPointLen = chainhash.HashSize + 4
+
+	// minSerializedUtxoLen is the length of a serialized unspent output
+	// excluding its script.  It is the amount, the height and whether it
+	// was created by a coinbase.
+	minSerializedUtxoLen = 8 + 4 + 1
+)
+
+var (
+	// bucketName is the name of the bucket of the database metadata which
+	// houses the state of the wallet.
+	bucketName = []byte("watchonly")
+
+	// descriptorsBucketName is the name of the bucket which houses the
+	// imported descriptors keyed by descriptor.
+	descriptorsBucketName = []byte("descriptors")
+
+	// utxosBucketName is the name of the bucket which houses the tracked
+	// outputs saved on shutdown keyed by outpoint.
+	utxosBucketName = []byte("utxos")
+
+	// tipKeyName is the key of the hash of the block the saved outputs are
+	// current as of.  It is removed when the outputs are loaded, so they
+	// are rebuilt after an unclean shutdown.
+	tipKeyName = []byte("tip")
+
+	// byteOrder is the preferred byte order used for serializing the
+	// state of the wallet.
+	byteOrder = binary.LittleEndian
+)
+
+// serializeDescriptor returns the serialized state of the passed descriptor.
+func serializeDescriptor(d *trackedDescriptor) []byte {
+	serialized := make([]byte, serializedDescriptorLen)
+	serialized[0] = descriptorVersion
+	if d.active {
+		serialized[1] |= flagActive
+	}
+	if d.internal {
+		serialized[1] |= flagInternal
+	}
+	byteOrder.PutUint32(serialized[2:], d.rangeStart)
+	byteOrder.PutUint32(serialized[6:], d.rangeEnd)
+	byteOrder.PutUint32(serialized[10:], d.nextIndex)
+	byteOrder.PutUint64(serialized[14:], uint64(d.timestamp))
+	return serialized
+}
+
+// deserializeDescriptor returns the descriptor serialized by
+// serializeDescriptor.
+func deserializeDescriptor(desc *descriptor.Descriptor, serialized []byte) (*trackedDescriptor, error) {
+	if len(serialized) != serializedDescriptorLen {
+		return nil, fmt.Errorf("unexpected length %d of serialized "+
+			"descriptor", len(serialized))
+	}
+	if serialized[0] != descriptorVersion {
+		return nil, fmt.Errorf("unknown version %d of serialized "+
+			"descriptor", serialized[0])
+	}
+
+	return &trackedDescriptor{
+		desc:       desc,
+		str:        desc.String(),
+		active:     serialized[1]&flagActive != 0,
+		internal:   serialized[1]&flagInternal != 0,
+		rangeStart: byteOrder.Uint32(serialized[2:]),
+		rangeEnd:   byteOrder.Uint32(serialized[6:]),
+		nextIndex:  byteOrder.Uint32(serialized[10:]),
+		timestamp:  int64(byteOrder.Uint64(serialized[14:])),
+	}, nil
+}
+
+// serializeOutPoint returns the serialized outpoint.
+func serializeOutPoint(op *wire.OutPoint) []byte {
+	serialized := make([]byte, serializedOutPointLen)
+	copy(serialized, op.Hash[:])
+	byteOrder.PutUint32(serialized[chainhash.HashSize:], op.Index)
+	return serialized
+}
+
+// serializeUtxo returns the serialized unspent output excluding its outpoint,
+// which is the key it is stored at.
+func serializeUtxo(utxo *Utxo) []byte {
+	serialized := make([]byte, minSerializedUtxoLen+len(utxo.PkScript))
+	byteOrder.PutUint64(serialized, uint64(utxo.Amount))
+	byteOrder.PutUint32(serialized[8:], uint32(utxo.Height))
+	if utxo.IsCoinBase {
+		serialized[12] = 1
+	}
+	copy(serialized[minSerializedUtxoLen:], utxo.PkScript)
+	return serialized
+}
+
+// deserializeUtxo returns the unspent output serialized by serializeOutPoint
+// and serializeUtxo.
+func deserializeUtxo(key, serialized []byte) (*Utxo, error) {
+	if len(key) != serializedOutPointLen {
+		return nil, fmt.Errorf("unexpected length %d of serialized "+
+			"outpoint", len(key))
+	}
+	if len(serialized) < minSerializedUtxoLen {
+		return nil, fmt.Errorf("unexpected length %d of serialized "+
+			"output", len(serialized))
+	}
+
+	var utxo Utxo
+	copy(utxo.OutPoint.Hash[:], key)
+	utxo.OutPoint.Index = byteOrder.Uint32(key[chainhash.HashSize:])
+	utxo.Amount = btcutil.Amount(byteOrder.Uint64(serialized))
+	utxo.Height = int32(byteOrder.Uint32(serialized[8:]))
+	utxo.IsCoinBase = serialized[12] != 0
+	utxo.PkScript = make([]byte, len(serialized)-minSerializedUtxoLen)
+	copy(utxo.PkScript, serialized[minSerializedUtxoLen:])
+	return &utxo, nil
+}
+
+// load loads the imported descriptors and, when the chain is still at the
+// block they were saved at, the tracked outputs.  It returns whether the
+// outputs were loaded.  It is only called when the wallet is created.
+func (w *Wallet) load() (bool, error) {
+	var synced bool
+	err := w.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+			bucketName)
+		if err != nil {
+			return err
+		}
+		descsBucket, err := bucket.CreateBucketIfNotExists(
+			descriptorsBucketName)
+		if err != nil {
+			return err
+		}
+		utxosBucket, err := bucket.CreateBucketIfNotExists(
+			utxosBucketName)
+		if err != nil {
+			return err
+		}
+
+		err = descsBucket.ForEach(func(k, v []byte) error {
+			desc, err := descriptor.Parse(string(k), true,
+				w.cfg.ChainParams)
+			if err != nil {
+				log.Warnf("Discarding descriptor %s: %v", k, err)
+				return nil
+			}
+			d, err := deserializeDescriptor(desc, v)
+			if err != nil {
+				log.Warnf("Discarding descriptor %s: %v", k, err)
+				return nil
+			}
+			if err := expand(w.scripts, d, d.rangeStart,
+				d.rangeEnd); err != nil {
+
+				return err
+			}
+			w.descriptors = append(w.descriptors, d)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		tip := bucket.Get(tipKeyName)
+		synced = bytes.Equal(tip, w.tipHash[:])
+		if !synced {
+			return nil
+		}
+		err = utxosBucket.ForEach(func(k, v []byte) error {
+			utxo, err := deserializeUtxo(k, v)
+			if err != nil {
+				return err
+			}
+			origin, ok := w.scripts[string(utxo.PkScript)]
+			if !ok {
+				return nil
+			}
+			utxo.origin = origin
+			w.utxos[utxo.OutPoint] = utxo
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return bucket.Delete(tipKeyName)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	sortDescriptors(w.descriptors)
+	return synced, nil
+}
+
+// putDescriptor stores the passed descriptor in the database.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) putDescriptor(d *trackedDescriptor) error {
+	return w.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName).Bucket(
+			descriptorsBucketName)
+		return bucket.Put([]byte(d.str), serializeDescriptor(d))
+	})
+}
+
+// saveUtxos stores the tracked outputs in the database along with the block
+// they are current as of.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) saveUtxos() error {
+	err := w.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		if err := bucket.DeleteBucket(utxosBucketName); err != nil {
+			return err
+		}
+		utxosBucket, err := bucket.CreateBucket(utxosBucketName)
+		if err != nil {
+			return err
+		}
+		for op, utxo := range w.utxos {
+			err := utxosBucket.Put(serializeOutPoint(&op),
+				serializeUtxo(utxo))
+			if err != nil {
+				return err
+			}
+		}
+		return bucket.Put(tipKeyName, w.tipHash[:])
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Saved %d unspent outputs as of block %v", len(w.utxos),
+		w.tipHash)
+	return nil
+}
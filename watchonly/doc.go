@@ -0,0 +1,25 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package watchonly implements a minimal wallet which tracks the unspent outputs
+of imported output descriptors and funds transactions with them, without
+holding any private keys.
+
+Descriptors are imported along with the range of derivation indexes to watch.
+The outputs they already own are found with a scan of the utxo set of the
+chain, and the wallet is kept up to date with the events of the chain as blocks
+are connected and disconnected, so it only ever knows about confirmed outputs.
+The imported descriptors are stored in a bucket of the database of the node
+together with the tracked outputs, which are saved on shutdown and rebuilt with
+a new scan of the utxo set when the chain moved on without the wallet.
+
+Transactions are funded by selecting outputs with the branch and bound
+algorithm, which looks for a set of outputs that avoids creating change, and
+falling back to the knapsack algorithm otherwise.  The size of the inputs is
+estimated from the descriptors that own the outputs, and change is sent to the
+next index of an active internal descriptor or to an address given by the
+caller.  Signing the funded transactions is left to an external signer.
+*/
+package watchonly
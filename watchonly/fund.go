@@ -0,0 +1,617 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/policy"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// dummySignatureLen is the length of the ECDSA signatures assumed when
+	// estimating the size of inputs, which is the maximum length of a DER
+	// encoded signature with a low S value along with its hash type.
+	dummySignatureLen = 72
+
+	// dummySchnorrSignatureLen is the length of the Schnorr signatures
+	// assumed when estimating the size of inputs, which is the length of
+	// signatures with the default hash type.
+	dummySchnorrSignatureLen = 64
+
+	// LongTermFeeRate is the fee rate in satoshis per kvB at which the
+	// outputs are assumed to be spent when they are not spent now.  It
+	// decides whether spending more inputs now is wasteful and how much
+	// creating change costs.
+	LongTermFeeRate = 10000
+)
+
+var (
+	// ErrInsufficientFunds is returned when the tracked outputs don't
+	// cover the outputs of a transaction and its fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrNoChangeDescriptor is returned when a transaction needs change,
+	// but no change script is given and no active internal descriptor of
+	// the requested type is imported.
+	ErrNoChangeDescriptor = errors.New("no active internal descriptor " +
+		"to derive change from")
+
+	// ErrAmountTooSmall is returned when an output the fee is subtracted
+	// from is too small to pay its share of the fee.
+	ErrAmountTooSmall = errors.New("the transaction amount is too small " +
+		"to pay the fee")
+)
+
+// FundOptions houses the options of funding a transaction.
+type FundOptions struct {
+	// FeeRate is the fee rate in satoshis per kvB the funded transaction
+	// pays.
+	FeeRate btcutil.Amount
+
+	// DustRelayFee is the fee rate used to determine whether the change is
+	// dust, in which case it is added to the fee instead.
+	DustRelayFee btcutil.Amount
+
+	// ChangeScript is the script change is sent to.  Change is sent to the
+	// next index of an active internal descriptor when it is nil.
+	ChangeScript []byte
+
+	// ChangeType is the type of script of the active internal descriptor
+	// change is derived from.  Any type is used when it is
+	// txscript.NonStandardTy.
+	ChangeType txscript.ScriptClass
+
+	// ChangePosition is the index of the change output, or -1 for a
+	// random position.
+	ChangePosition int
+
+	// SubtractFeeFromOutputs are the indexes of the outputs the fee is
+	// subtracted from in equal shares instead of being added to the
+	// amount the inputs must cover.
+	SubtractFeeFromOutputs []int
+
+	// LockUnspents locks the selected outputs, so they are not selected
+	// again until they are unlocked.
+	LockUnspents bool
+
+	// Replaceable signals that the funded transaction may be replaced
+	// through the sequence numbers of the added inputs.
+	Replaceable bool
+
+	// IsSpent reports whether an output is spent by a transaction which is
+	// not confirmed yet, so it is not selected.  It may be nil.
+	IsSpent func(wire.OutPoint) bool
+}
+
+// FundResult is the result of funding a transaction.
+type FundResult struct {
+	// Tx is the funded transaction.
+	Tx *wire.MsgTx
+
+	// Fee is the fee the transaction pays.
+	Fee btcutil.Amount
+
+	// ChangePosition is the index of the change output, or -1 when the
+	// transaction doesn't have change.
+	ChangePosition int
+}
+
+// feeForWeight returns the fee at the passed fee rate in satoshis per kvB of an
+// item with the passed weight, rounded up.
+func feeForWeight(feeRate btcutil.Amount, weight int64) int64 {
+	vsize := (weight + blockchain.WitnessScaleFactor - 1) /
+		blockchain.WitnessScaleFactor
+	return (int64(feeRate)*vsize + 999) / 1000
+}
+
+// pushes returns a script which pushes the passed data.
+func pushes(data ...[]byte) []byte {
+	builder := txscript.NewScriptBuilder()
+	for _, d := range data {
+		builder.AddData(d)
+	}
+	script, _ := builder.Script()
+	return script
+}
+
+// scriptStack returns the stack with dummy signatures which satisfies the
+// passed script, which is a pay-to-pubkey, pay-to-pubkey-hash or multisig
+// script.
+func scriptStack(script []byte, pubKeyLen int) ([][]byte, error) {
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyTy:
+		return [][]byte{make([]byte, dummySignatureLen)}, nil
+
+	case txscript.PubKeyHashTy:
+		return [][]byte{make([]byte, dummySignatureLen),
+			make([]byte, pubKeyLen)}, nil
+
+	case txscript.MultiSigTy:
+		_, numSigs, err := txscript.CalcMultiSigStats(script)
+		if err != nil {
+			return nil, err
+		}
+
+		// The extra item works around the off-by-one bug of
+		// OP_CHECKMULTISIG.
+		stack := [][]byte{nil}
+		for i := 0; i < numSigs; i++ {
+			stack = append(stack, make([]byte, dummySignatureLen))
+		}
+		return stack, nil
+	}
+	return nil, ErrNotSolvable
+}
+
+// satisfaction returns the signature script and witness with dummy signatures
+// of an input spending the output of the passed expansion of a descriptor.
+func satisfaction(exp *descriptor.Expansion) ([]byte, wire.TxWitness, error) {
+	pubKeyLen := 33
+	if len(exp.Keys) > 0 {
+		pubKeyLen = len(exp.Keys[0].PubKey)
+	}
+
+	// witnessStack returns the witness satisfying a P2WPKH or P2WSH
+	// script.
+	witnessStack := func(script []byte) (wire.TxWitness, error) {
+		if txscript.IsPayToWitnessPubKeyHash(script) {
+			return wire.TxWitness{make([]byte, dummySignatureLen),
+				make([]byte, pubKeyLen)}, nil
+		}
+		stack, err := scriptStack(exp.WitnessScript, pubKeyLen)
+		if err != nil {
+			return nil, err
+		}
+		return append(stack, exp.WitnessScript), nil
+	}
+
+	script := exp.Script
+	switch txscript.GetScriptClass(script) {
+	case txscript.ScriptHashTy:
+		redeemScript := exp.RedeemScript
+		if txscript.IsWitnessProgram(redeemScript) {
+			witness, err := witnessStack(redeemScript)
+			if err != nil {
+				return nil, nil, err
+			}
+			return pushes(redeemScript), witness, nil
+		}
+		stack, err := scriptStack(redeemScript, pubKeyLen)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pushes(append(stack, redeemScript)...), nil, nil
+
+	case txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy:
+		witness, err := witnessStack(script)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, witness, nil
+
+	case txscript.WitnessV1TaprootTy:
+		if exp.InternalKey == nil {
+			return nil, nil, ErrNotSolvable
+		}
+		return nil, wire.TxWitness{
+			make([]byte, dummySchnorrSignatureLen)}, nil
+	}
+
+	stack, err := scriptStack(script, pubKeyLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pushes(stack...), nil, nil
+}
+
+// inputWeight returns the weight of an input with the passed signature script
+// and witness in a transaction with witness data.
+func inputWeight(sigScript []byte, witness wire.TxWitness) int64 {
+	txIn := wire.TxIn{SignatureScript: sigScript}
+	return int64(txIn.SerializeSize()*blockchain.WitnessScaleFactor +
+		witness.SerializeSize())
+}
+
+// outputWeight returns the weight of an output with the passed script.
+func outputWeight(pkScript []byte) int64 {
+	txOut := wire.TxOut{PkScript: pkScript}
+	return int64(txOut.SerializeSize() * blockchain.WitnessScaleFactor)
+}
+
+// originWeight returns the estimated weight of an input spending the script of
+// the passed origin.
+func originWeight(origin *scriptOrigin) (int64, error) {
+	if !origin.desc.desc.IsSolvable() {
+		return 0, ErrNotSolvable
+	}
+	exp, err := origin.desc.desc.Expand(origin.index)
+	if err != nil {
+		return 0, err
+	}
+	sigScript, witness, err := satisfaction(exp)
+	if err != nil {
+		return 0, err
+	}
+	return inputWeight(sigScript, witness), nil
+}
+
+// estimateWeight returns the weight of the passed transaction once its inputs,
+// which must all spend tracked outputs, are signed.
+//
+// This function MUST be called with the wallet lock held (for reads).
+func (w *Wallet) estimateWeight(tx *wire.MsgTx) (int64, error) {
+	signed := tx.Copy()
+	for _, txIn := range signed.TxIn {
+		utxo := w.utxos[txIn.PreviousOutPoint]
+		exp, err := utxo.origin.desc.desc.Expand(utxo.origin.index)
+		if err != nil {
+			return 0, err
+		}
+		txIn.SignatureScript, txIn.Witness, err = satisfaction(exp)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return blockchain.GetTransactionWeight(btcutil.NewTx(signed)), nil
+}
+
+// changeDescriptor returns the active internal descriptor change of the passed
+// type is derived from, or nil when there is none.  Segwit descriptors are
+// preferred when any type is requested.
+//
+// This function MUST be called with the wallet lock held (for reads).
+func (w *Wallet) changeDescriptor(class txscript.ScriptClass) *trackedDescriptor {
+	preference := []txscript.ScriptClass{class}
+	if class == txscript.NonStandardTy {
+		preference = []txscript.ScriptClass{
+			txscript.WitnessV0PubKeyHashTy,
+			txscript.WitnessV1TaprootTy,
+			txscript.ScriptHashTy,
+			txscript.PubKeyHashTy,
+		}
+	}
+	for _, class := range preference {
+		for _, d := range w.descriptors {
+			if d.active && d.internal && d.scriptClass() == class {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// eligibleCoins returns the tracked outputs which may be selected to fund a
+// transaction with the passed options.  Locked outputs, outputs spent by
+// unconfirmed transactions, immature coinbase outputs and outputs which are
+// worth less than the fee of spending them are excluded, as are the passed
+// outputs which are already spent by the transaction.
+//
+// This function MUST be called with the wallet lock held (for reads).
+func (w *Wallet) eligibleCoins(opts *FundOptions, exclude map[wire.OutPoint]struct{}) ([]*coin, error) {
+	sffo := len(opts.SubtractFeeFromOutputs) > 0
+
+	var coins []*coin
+	for op, utxo := range w.utxos {
+		if _, ok := exclude[op]; ok {
+			continue
+		}
+		if _, ok := w.locked[op]; ok {
+			continue
+		}
+		if utxo.IsCoinBase {
+			maturity := blockchain.CalcCoinbaseMaturity(utxo.Height,
+				w.cfg.ChainParams)
+			if w.tipHeight+1-utxo.Height < maturity {
+				continue
+			}
+		}
+		if opts.IsSpent != nil && opts.IsSpent(op) {
+			continue
+		}
+
+		weight, err := originWeight(utxo.origin)
+		if errors.Is(err, ErrNotSolvable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c := &coin{
+			utxo:        utxo,
+			weight:      weight,
+			fee:         feeForWeight(opts.FeeRate, weight),
+			longTermFee: feeForWeight(LongTermFeeRate, weight),
+			value:       int64(utxo.Amount),
+		}
+		if !sffo {
+			c.value -= c.fee
+		}
+		if c.value <= 0 {
+			continue
+		}
+		coins = append(coins, c)
+	}
+
+	// Sort the coins for a deterministic order of the coins the branch
+	// and bound algorithm considers equivalent.
+	sortCoins(coins)
+	return coins, nil
+}
+
+// sortCoins sorts the passed coins by their outpoints.
+func sortCoins(coins []*coin) {
+	sort.Slice(coins, func(i, j int) bool {
+		return outPointLess(&coins[i].utxo.OutPoint,
+			&coins[j].utxo.OutPoint)
+	})
+}
+
+// Fund returns a copy of the passed transaction with inputs spending tracked
+// outputs added to cover its outputs and fee and, unless the excess is dust,
+// a change output.  The inputs already spent by the transaction must spend
+// tracked outputs as well.  The passed transaction is not modified.
+//
+// Outputs are selected with the branch and bound algorithm, which looks for
+// a set of outputs that doesn't need change, falling back to the knapsack
+// algorithm.  The added inputs and the change output are not signed, so the
+// transaction must be signed by an external signer.
+func (w *Wallet) Fund(tx *wire.MsgTx, opts *FundOptions) (*FundResult, error) {
+	if len(tx.TxOut) == 0 {
+		return nil, errors.New("transaction must have at least one " +
+			"output")
+	}
+	subtractFrom := make(map[int]struct{})
+	for _, idx := range opts.SubtractFeeFromOutputs {
+		if idx < 0 || idx >= len(tx.TxOut) {
+			return nil, fmt.Errorf("output index %d to subtract the "+
+				"fee from is out of bounds", idx)
+		}
+		if _, ok := subtractFrom[idx]; ok {
+			return nil, fmt.Errorf("output index %d to subtract the "+
+				"fee from is duplicated", idx)
+		}
+		subtractFrom[idx] = struct{}{}
+	}
+	if opts.ChangePosition > len(tx.TxOut) {
+		return nil, fmt.Errorf("change position %d is out of bounds",
+			opts.ChangePosition)
+	}
+	sffo := len(subtractFrom) > 0
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	funded := tx.Copy()
+	var outputsValue int64
+	for _, txOut := range funded.TxOut {
+		outputsValue += txOut.Value
+	}
+
+	// The inputs the transaction already has must be known to estimate
+	// their size.
+	preset := make(map[wire.OutPoint]struct{}, len(funded.TxIn))
+	var presetValue, presetWeight int64
+	for _, txIn := range funded.TxIn {
+		op := txIn.PreviousOutPoint
+		utxo, ok := w.utxos[op]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownOutput, op)
+		}
+		weight, err := originWeight(utxo.origin)
+		if err != nil {
+			return nil, err
+		}
+		preset[op] = struct{}{}
+		presetValue += int64(utxo.Amount)
+		presetWeight += weight
+	}
+
+	// Find out where change goes.  The cost of change is estimated for a
+	// P2WPKH output when neither a change script nor a change descriptor
+	// is available, in which case funding fails only when change is
+	// actually needed.
+	changeScript := opts.ChangeScript
+	changeSpendWeight := inputWeight(nil, wire.TxWitness{
+		make([]byte, dummySignatureLen), make([]byte, 33)})
+	changeDesc := w.changeDescriptor(opts.ChangeType)
+	if changeScript == nil && changeDesc != nil {
+		var err error
+		changeScript, err = changeDesc.desc.Script(changeDesc.nextIndex)
+		if err != nil {
+			return nil, err
+		}
+		origin := &scriptOrigin{desc: changeDesc,
+			index: changeDesc.nextIndex}
+		if weight, err := originWeight(origin); err == nil {
+			changeSpendWeight = weight
+		}
+	}
+	dustScript := changeScript
+	if dustScript == nil {
+		dustScript = make([]byte, 22)
+	}
+	changeFee := feeForWeight(opts.FeeRate, outputWeight(dustScript))
+	changeSpendFee := feeForWeight(LongTermFeeRate, changeSpendWeight)
+	costOfChange := changeFee + changeSpendFee
+
+	// The weight of the transaction without its inputs assumes it has
+	// witness data and the maximum size of the input count, so the fee is
+	// never underestimated.
+	baseWeight := int64(8+wire.MaxVarIntPayload+
+		wire.VarIntSerializeSize(uint64(len(funded.TxOut)+1)))*
+		blockchain.WitnessScaleFactor + 2
+	for _, txOut := range funded.TxOut {
+		baseWeight += outputWeight(txOut.PkScript)
+	}
+
+	target := outputsValue - presetValue
+	if !sffo {
+		target += feeForWeight(opts.FeeRate, baseWeight+presetWeight)
+	}
+	var selected []*coin
+	if target > 0 {
+		coins, err := w.eligibleCoins(opts, preset)
+		if err != nil {
+			return nil, err
+		}
+		if !sffo {
+			selected = selectBnB(coins, target, costOfChange)
+		}
+		if selected == nil {
+			selected = selectKnapsack(coins, target+changeFee, w.rng)
+		}
+		if selected == nil {
+			selected = selectKnapsack(coins, target, w.rng)
+		}
+		if selected == nil {
+			return nil, ErrInsufficientFunds
+		}
+	}
+
+	sequence := uint32(wire.MaxTxInSequenceNum - 1)
+	if opts.Replaceable {
+		sequence = wire.MaxTxInSequenceNum - 2
+	}
+	inputsValue := presetValue
+	for _, c := range selected {
+		txIn := wire.NewTxIn(&c.utxo.OutPoint, nil, nil)
+		txIn.Sequence = sequence
+		funded.AddTxIn(txIn)
+		inputsValue += int64(c.utxo.Amount)
+	}
+
+	// Calculate the fee from the estimated weight of the signed
+	// transaction with and without change.
+	weight, err := w.estimateWeight(funded)
+	if err != nil {
+		return nil, err
+	}
+	fee := feeForWeight(opts.FeeRate, weight)
+	feeWithChange := fee + changeFee
+	if changeScript != nil {
+		funded.AddTxOut(wire.NewTxOut(0, changeScript))
+		weight, err := w.estimateWeight(funded)
+		if err != nil {
+			return nil, err
+		}
+		funded.TxOut = funded.TxOut[:len(funded.TxOut)-1]
+		feeWithChange = feeForWeight(opts.FeeRate, weight)
+	}
+
+	// Change is what remains of the inputs once the outputs and the fee
+	// are paid for, unless the fee is subtracted from the outputs.  Change
+	// which is dust or not worth more than the fee of spending it later is
+	// added to the fee instead.
+	change := inputsValue - outputsValue
+	if !sffo {
+		change -= feeWithChange
+	}
+	hasChange := change > changeSpendFee && !policy.IsDust(
+		&wire.TxOut{Value: change, PkScript: dustScript},
+		opts.DustRelayFee)
+	if hasChange && changeScript == nil {
+		return nil, ErrNoChangeDescriptor
+	}
+
+	// Find the fee that is left to pay and take it from the outputs the
+	// fee is subtracted from in equal shares, with the first one paying
+	// the remainder.
+	toSubtract := int64(0)
+	switch {
+	case hasChange:
+		fee = feeWithChange
+		if sffo {
+			toSubtract = fee
+		}
+
+	case sffo:
+		excess := inputsValue - outputsValue
+		if excess < fee {
+			toSubtract = fee - excess
+		} else {
+			fee = excess
+		}
+
+	default:
+		fee = inputsValue - outputsValue
+	}
+	if toSubtract > 0 {
+		share := toSubtract / int64(len(subtractFrom))
+		first := true
+		for i, txOut := range funded.TxOut {
+			if _, ok := subtractFrom[i]; !ok {
+				continue
+			}
+			txOut.Value -= share
+			if first {
+				txOut.Value -= toSubtract % int64(len(subtractFrom))
+				first = false
+			}
+			if txOut.Value < 0 || policy.IsDust(txOut,
+				opts.DustRelayFee) {
+
+				return nil, ErrAmountTooSmall
+			}
+		}
+	}
+
+	changePos := -1
+	if hasChange {
+		changePos = opts.ChangePosition
+		if changePos < 0 {
+			changePos = w.rng.Intn(len(funded.TxOut) + 1)
+		}
+		funded.TxOut = append(funded.TxOut, nil)
+		copy(funded.TxOut[changePos+1:], funded.TxOut[changePos:])
+		funded.TxOut[changePos] = wire.NewTxOut(change, changeScript)
+
+		if opts.ChangeScript == nil {
+			if err := w.useChangeIndex(changeDesc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.LockUnspents {
+		for _, c := range selected {
+			w.locked[c.utxo.OutPoint] = struct{}{}
+		}
+	}
+
+	return &FundResult{
+		Tx:             funded,
+		Fee:            btcutil.Amount(fee),
+		ChangePosition: changePos,
+	}, nil
+}
+
+// useChangeIndex moves the passed change descriptor on to its next index after
+// change was sent to the current one, extending the range of watched scripts
+// as needed so the change is tracked once it confirms.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) useChangeIndex(d *trackedDescriptor) error {
+	if !d.desc.IsRange() {
+		return nil
+	}
+
+	index := d.nextIndex
+	if index > d.rangeEnd {
+		if err := expand(w.scripts, d, d.rangeEnd+1, index); err != nil {
+			return err
+		}
+		d.rangeEnd = index
+	}
+	d.nextIndex++
+	return w.putDescriptor(d)
+}
@@ -0,0 +1,322 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestSatisfaction ensures the weights of inputs spending the outputs of the
+// supported descriptors are estimated with maximum size signatures.
+func TestSatisfaction(t *testing.T) {
+	t.Parallel()
+
+	var pubKeys []string
+	for i := byte(1); i <= 3; i++ {
+		_, pubKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{i}, 32))
+		pubKeys = append(pubKeys,
+			hex.EncodeToString(pubKey.SerializeCompressed()))
+	}
+
+	tests := []struct {
+		desc   string
+		weight int64
+	}{
+		{desc: "pkh(" + pubKeys[0] + ")", weight: 593},
+		{desc: "wpkh(" + pubKeys[0] + ")", weight: 272},
+		{desc: "sh(wpkh(" + pubKeys[0] + "))", weight: 364},
+		{desc: "tr(" + pubKeys[0] + ")", weight: 230},
+		{desc: "pk(" + pubKeys[0] + ")", weight: 457},
+		{
+			desc: "wsh(multi(2," + pubKeys[0] + "," + pubKeys[1] +
+				"," + pubKeys[2] + "))",
+			weight: 418,
+		},
+		{
+			desc: "sh(multi(2," + pubKeys[0] + "," + pubKeys[1] +
+				"," + pubKeys[2] + "))",
+			weight: 1189,
+		},
+		{desc: "raw(51)", weight: -1},
+	}
+
+	for _, test := range tests {
+		d, err := descriptor.Parse(test.desc, false, &chaincfg.SimNetParams)
+		if err != nil {
+			t.Errorf("%s: unable to parse: %v", test.desc, err)
+			continue
+		}
+		origin := &scriptOrigin{desc: &trackedDescriptor{desc: d}}
+		weight, err := originWeight(origin)
+		if test.weight < 0 {
+			if !errors.Is(err, ErrNotSolvable) {
+				t.Errorf("%s: unexpected error: %v", test.desc,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if weight != test.weight {
+			t.Errorf("%s: unexpected weight: got %d, want %d",
+				test.desc, weight, test.weight)
+		}
+	}
+}
+
+// TestFund ensures transactions are funded with the tracked outputs, pay the
+// requested fee rate and send change to the active internal descriptor.
+func TestFund(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Create("ffldb", t.TempDir(), wire.SimNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain := newTestChain()
+	recv, change := testDescriptors(t)
+	amounts := []int64{100000000, 200000000, 50000000}
+	var total int64
+	for i, amount := range amounts {
+		op := wire.OutPoint{Hash: chainhash.Hash{byte(i + 1)}}
+		chain.addUtxo(op, amount, mustScript(t, recv, uint32(i)), 100)
+		total += amount
+	}
+	w, err := New(chain.config(db))
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	w.rng = rand.New(rand.NewSource(1))
+	for _, req := range []*ImportRequest{
+		{Descriptor: recv, RangeEnd: 9, Rescan: true},
+		{Descriptor: change, RangeEnd: 1, Active: true, Internal: true},
+	} {
+		if err := w.ImportDescriptor(req, nil); err != nil {
+			t.Fatalf("ImportDescriptor: unexpected error: %v", err)
+		}
+	}
+
+	payTo := []byte{txscript.OP_0, txscript.OP_DATA_20}
+	payTo = append(payTo, bytes.Repeat([]byte{0xaa}, 20)...)
+	newTx := func(amount int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(wire.NewTxOut(amount, payTo))
+		return tx
+	}
+	defaultOpts := func() *FundOptions {
+		return &FundOptions{
+			FeeRate:        2000,
+			DustRelayFee:   1000,
+			ChangePosition: -1,
+			Replaceable:    true,
+		}
+	}
+
+	// checkFunded ensures the funded transaction pays the fee it reports
+	// and at least the fee rate.
+	checkFunded := func(name string, result *FundResult, feeRate btcutil.Amount) {
+		t.Helper()
+
+		var in, out int64
+		for _, txIn := range result.Tx.TxIn {
+			utxo, ok := w.utxos[txIn.PreviousOutPoint]
+			if !ok {
+				t.Fatalf("%s: input %v not tracked", name,
+					txIn.PreviousOutPoint)
+			}
+			in += int64(utxo.Amount)
+			if txIn.Sequence != wire.MaxTxInSequenceNum-2 {
+				t.Fatalf("%s: input not replaceable", name)
+			}
+		}
+		for _, txOut := range result.Tx.TxOut {
+			out += txOut.Value
+		}
+		if in-out != int64(result.Fee) {
+			t.Fatalf("%s: transaction pays fee %d, reported %d",
+				name, in-out, result.Fee)
+		}
+		w.mtx.Lock()
+		weight, err := w.estimateWeight(result.Tx)
+		w.mtx.Unlock()
+		if err != nil {
+			t.Fatalf("%s: unable to estimate weight: %v", name, err)
+		}
+		if minFee := feeForWeight(feeRate, weight); int64(result.Fee) < minFee {
+			t.Fatalf("%s: fee %d below minimum fee %d", name,
+				result.Fee, minFee)
+		}
+	}
+
+	// An amount which can't be paid without change sends the change to
+	// the next index of the change descriptor.
+	tx := newTx(120000000)
+	result, err := w.Fund(tx, defaultOpts())
+	if err != nil {
+		t.Fatalf("Fund: unexpected error: %v", err)
+	}
+	checkFunded("change", result, 2000)
+	if len(tx.TxIn) != 0 || len(tx.TxOut) != 1 {
+		t.Fatal("Fund modified the passed transaction")
+	}
+	if result.ChangePosition < 0 || !bytes.Equal(
+		result.Tx.TxOut[result.ChangePosition].PkScript,
+		mustScript(t, change, 0)) {
+
+		t.Fatalf("unexpected change position %d",
+			result.ChangePosition)
+	}
+	if descs := w.Descriptors(); descs[1].NextIndex != 1 {
+		t.Fatalf("change descriptor at next index %d, want 1",
+			descs[1].NextIndex)
+	}
+
+	// An amount which an output covers without change worth creating is
+	// funded without change.
+	opts := defaultOpts()
+	opts.FeeRate = 1000
+	result, err = w.Fund(newTx(amounts[2]-500), opts)
+	if err != nil {
+		t.Fatalf("Fund: unexpected error: %v", err)
+	}
+	checkFunded("changeless", result, 1000)
+	if result.ChangePosition != -1 || len(result.Tx.TxIn) != 1 ||
+		result.Fee != 500 {
+
+		t.Fatalf("unexpected changeless result: %d inputs, fee %v, "+
+			"change position %d", len(result.Tx.TxIn), result.Fee,
+			result.ChangePosition)
+	}
+
+	// Change is sent to the passed script at the passed position and the
+	// selected outputs are locked when requested.
+	opts = defaultOpts()
+	opts.ChangeScript = []byte{txscript.OP_TRUE}
+	opts.ChangePosition = 0
+	opts.LockUnspents = true
+	result, err = w.Fund(newTx(120000000), opts)
+	if err != nil {
+		t.Fatalf("Fund: unexpected error: %v", err)
+	}
+	checkFunded("locked", result, 2000)
+	if result.ChangePosition != 0 ||
+		!bytes.Equal(result.Tx.TxOut[0].PkScript, opts.ChangeScript) {
+
+		t.Fatal("change not sent to the passed script")
+	}
+	if locked := w.LockedUnspent(); len(locked) != len(result.Tx.TxIn) {
+		t.Fatalf("%d outputs locked, want %d", len(locked),
+			len(result.Tx.TxIn))
+	}
+	if _, err := w.Fund(newTx(total-100000), defaultOpts()); !errors.Is(err,
+		ErrInsufficientFunds) {
+
+		t.Fatalf("Fund with locked outputs: unexpected error: %v", err)
+	}
+	if err := w.UnlockUnspent(nil); err != nil {
+		t.Fatalf("UnlockUnspent: unexpected error: %v", err)
+	}
+
+	// The fee is subtracted from the outputs when requested, so all of
+	// the tracked outputs can be sent.
+	opts = defaultOpts()
+	opts.SubtractFeeFromOutputs = []int{0}
+	result, err = w.Fund(newTx(total), opts)
+	if err != nil {
+		t.Fatalf("Fund: unexpected error: %v", err)
+	}
+	checkFunded("subtract fee", result, 2000)
+	if result.ChangePosition != -1 || len(result.Tx.TxIn) != len(amounts) ||
+		result.Tx.TxOut[0].Value != total-int64(result.Fee) {
+
+		t.Fatal("fee not subtracted from the output")
+	}
+
+	// Inputs the transaction already has are kept and must be tracked.
+	tx = newTx(10000000)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0x03}},
+		nil, nil))
+	result, err = w.Fund(tx, defaultOpts())
+	if err != nil {
+		t.Fatalf("Fund: unexpected error: %v", err)
+	}
+	if len(result.Tx.TxIn) != 1 ||
+		result.Tx.TxIn[0].PreviousOutPoint != tx.TxIn[0].PreviousOutPoint {
+
+		t.Fatal("preset input not kept")
+	}
+	tx.TxIn[0].PreviousOutPoint.Index = 1
+	if _, err := w.Fund(tx, defaultOpts()); !errors.Is(err,
+		ErrUnknownOutput) {
+
+		t.Fatalf("Fund with unknown input: unexpected error: %v", err)
+	}
+
+	// Funding fails when the tracked outputs don't cover the amount, and
+	// outputs spent by unconfirmed transactions or immature coinbase
+	// outputs are not selected.
+	if _, err := w.Fund(newTx(total), defaultOpts()); !errors.Is(err,
+		ErrInsufficientFunds) {
+
+		t.Fatalf("Fund: unexpected error: %v", err)
+	}
+	opts = defaultOpts()
+	opts.IsSpent = func(op wire.OutPoint) bool {
+		return op.Hash == chainhash.Hash{0x02}
+	}
+	if _, err := w.Fund(newTx(160000000), opts); !errors.Is(err,
+		ErrInsufficientFunds) {
+
+		t.Fatalf("Fund with spent outputs: unexpected error: %v", err)
+	}
+	w.mtx.Lock()
+	w.utxos[wire.OutPoint{Hash: chainhash.Hash{0x02}}].IsCoinBase = true
+	w.tipHeight = 150
+	w.mtx.Unlock()
+	if _, err := w.Fund(newTx(160000000), defaultOpts()); !errors.Is(err,
+		ErrInsufficientFunds) {
+
+		t.Fatalf("Fund with immature outputs: unexpected error: %v", err)
+	}
+	w.mtx.Lock()
+	w.tipHeight = int32(chaincfg.SimNetParams.CoinbaseMaturity) + 100
+	w.mtx.Unlock()
+	if _, err := w.Fund(newTx(160000000), defaultOpts()); err != nil {
+		t.Fatalf("Fund with mature outputs: unexpected error: %v", err)
+	}
+
+	// The maturity of coinbase outputs follows the maturity function of
+	// networks which define one.
+	params := chaincfg.SimNetParams
+	params.CoinbaseMaturityFunc = func(height int32) uint16 {
+		return uint16(params.CoinbaseMaturity) + 1000
+	}
+	w.mtx.Lock()
+	w.cfg.ChainParams = &params
+	w.mtx.Unlock()
+	if _, err := w.Fund(newTx(160000000), defaultOpts()); !errors.Is(err,
+		ErrInsufficientFunds) {
+
+		t.Fatalf("Fund with outputs immature by the maturity "+
+			"function: unexpected error: %v", err)
+	}
+}
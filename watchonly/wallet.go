@@ -0,0 +1,640 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	// ErrUnknownOutput is returned when an output which isn't tracked by
+	// the wallet is locked or used as an input of a funded transaction.
+	ErrUnknownOutput = errors.New("output is not tracked by the wallet")
+
+	// ErrNotLocked is returned when an output which isn't locked is
+	// unlocked.
+	ErrNotLocked = errors.New("output is not locked")
+
+	// ErrNotSolvable is returned when the size of the inputs spending the
+	// outputs of a descriptor can't be estimated, such as for raw() and
+	// addr() descriptors.
+	ErrNotSolvable = errors.New("descriptor is not solvable")
+)
+
+// Config houses the dependencies of the wallet.
+type Config struct {
+	// DB is the database the imported descriptors and the tracked outputs
+	// are stored in.
+	DB database.DB
+
+	// ChainParams identifies the network the descriptors are for.
+	ChainParams *chaincfg.Params
+
+	// BestSnapshot returns the current best state of the chain.
+	BestSnapshot func() *blockchain.BestState
+
+	// ScanUtxoSet calls the passed function with each output of the utxo
+	// set of the chain and returns the block the set is the state of.
+	ScanUtxoSet func(interrupt <-chan struct{},
+		fn blockchain.UtxoScanFunc) (*chainhash.Hash, int32, error)
+
+	// Interrupt specifies a channel the caller can close to stop the scan
+	// of the utxo set when the tracked outputs are rebuilt on startup.
+	Interrupt <-chan struct{}
+}
+
+// ImportRequest describes a descriptor to import.
+type ImportRequest struct {
+	// Descriptor is the descriptor to import.
+	Descriptor *descriptor.Descriptor
+
+	// RangeStart and RangeEnd are the first and last derivation index of
+	// the scripts of ranged descriptors that are watched.
+	RangeStart uint32
+	RangeEnd   uint32
+
+	// NextIndex is the next derivation index to hand out for change.
+	NextIndex uint32
+
+	// Active marks the descriptor as the one to derive change from when
+	// it is internal.  A descriptor made active replaces the active one
+	// with the same type of script.
+	Active bool
+
+	// Internal marks the descriptor as used for change.
+	Internal bool
+
+	// Timestamp is the time the descriptor was created in seconds since
+	// epoch.  It is kept for reference only since the outputs are found
+	// in the utxo set rather than in the history of the chain.
+	Timestamp int64
+
+	// Rescan indicates whether the utxo set is scanned for the outputs
+	// the descriptor already owns.  It is not needed for descriptors that
+	// have never been used.
+	Rescan bool
+}
+
+// DescriptorInfo describes an imported descriptor.
+type DescriptorInfo struct {
+	// Descriptor is the descriptor with its checksum.
+	Descriptor string
+
+	// IsRange reports whether the descriptor is ranged, in which case
+	// RangeStart, RangeEnd and NextIndex are set.
+	IsRange    bool
+	RangeStart uint32
+	RangeEnd   uint32
+	NextIndex  uint32
+
+	// Active and Internal are the flags the descriptor was imported with.
+	Active   bool
+	Internal bool
+
+	// Timestamp is the time the descriptor was created.
+	Timestamp int64
+}
+
+// Utxo is an unspent output owned by an imported descriptor.
+type Utxo struct {
+	OutPoint   wire.OutPoint
+	Amount     btcutil.Amount
+	PkScript   []byte
+	Height     int32
+	IsCoinBase bool
+
+	// Descriptor is the descriptor of the script of the output derived at
+	// the index of the script.
+	Descriptor string
+
+	// RedeemScript is the redeem script of outputs owned by sh()
+	// descriptors.
+	RedeemScript []byte
+
+	// Locked reports whether the output is locked, so it is not selected
+	// to fund transactions.
+	Locked bool
+
+	origin *scriptOrigin
+}
+
+// trackedDescriptor is an imported descriptor along with its state.
+type trackedDescriptor struct {
+	desc       *descriptor.Descriptor
+	str        string
+	rangeStart uint32
+	rangeEnd   uint32
+	nextIndex  uint32
+	active     bool
+	internal   bool
+	timestamp  int64
+}
+
+// scriptClass returns the type of the scripts of the descriptor.
+func (d *trackedDescriptor) scriptClass() txscript.ScriptClass {
+	script, err := d.desc.Script(d.rangeStart)
+	if err != nil {
+		return txscript.NonStandardTy
+	}
+	return txscript.GetScriptClass(script)
+}
+
+// info returns the description of the descriptor.
+func (d *trackedDescriptor) info() DescriptorInfo {
+	info := DescriptorInfo{
+		Descriptor: d.str,
+		IsRange:    d.desc.IsRange(),
+		Active:     d.active,
+		Internal:   d.internal,
+		Timestamp:  d.timestamp,
+	}
+	if info.IsRange {
+		info.RangeStart = d.rangeStart
+		info.RangeEnd = d.rangeEnd
+		info.NextIndex = d.nextIndex
+	}
+	return info
+}
+
+// scriptOrigin identifies the descriptor and derivation index of a watched
+// script.
+type scriptOrigin struct {
+	desc  *trackedDescriptor
+	index uint32
+}
+
+// Wallet tracks the unspent outputs of the imported descriptors and funds
+// transactions with them.
+//
+// All methods are safe for concurrent access.
+type Wallet struct {
+	cfg Config
+
+	mtx sync.Mutex
+
+	// descriptors houses the imported descriptors sorted by descriptor.
+	descriptors []*trackedDescriptor
+	scripts     map[string]*scriptOrigin
+	utxos       map[wire.OutPoint]*Utxo
+	locked      map[wire.OutPoint]struct{}
+	tipHash     chainhash.Hash
+	tipHeight   int32
+	rng         *rand.Rand
+}
+
+// newWallet returns a wallet without any descriptors which is synced to the
+// current best block of the chain.
+func newWallet(cfg *Config) *Wallet {
+	best := cfg.BestSnapshot()
+	return &Wallet{
+		cfg:       *cfg,
+		scripts:   make(map[string]*scriptOrigin),
+		utxos:     make(map[wire.OutPoint]*Utxo),
+		locked:    make(map[wire.OutPoint]struct{}),
+		tipHash:   best.Hash,
+		tipHeight: best.Height,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// New returns a wallet with the descriptors that were imported previously.
+// The outputs saved on shutdown are loaded when the chain is still at the block
+// they were saved at, and they are rebuilt with a scan of the utxo set
+// otherwise.
+//
+// The caller must subscribe HandleChainEvent to the events of the chain before
+// any blocks are processed to keep the wallet up to date.
+func New(cfg *Config) (*Wallet, error) {
+	w := newWallet(cfg)
+
+	synced, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	if synced || len(w.descriptors) == 0 {
+		log.Infof("Loaded %d descriptors with %d unspent outputs",
+			len(w.descriptors), len(w.utxos))
+		return w, nil
+	}
+
+	log.Infof("Scanning the utxo set for the outputs of %d descriptors",
+		len(w.descriptors))
+	w.utxos = make(map[wire.OutPoint]*Utxo)
+	if err := w.scan(w.scripts, cfg.Interrupt); err != nil {
+		return nil, err
+	}
+	log.Infof("Found %d unspent outputs", len(w.utxos))
+	return w, nil
+}
+
+// Stop saves the tracked outputs, so they don't have to be rebuilt with a scan
+// of the utxo set on the next start.
+func (w *Wallet) Stop() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.saveUtxos()
+}
+
+// expand adds the scripts of the passed descriptor from the first to the last
+// index to the passed map of watched scripts.
+func expand(scripts map[string]*scriptOrigin, d *trackedDescriptor, first, last uint32) error {
+	for i := first; ; i++ {
+		script, err := d.desc.Script(i)
+		if err != nil {
+			return err
+		}
+		scripts[string(script)] = &scriptOrigin{desc: d, index: i}
+		if i == last || !d.desc.IsRange() {
+			return nil
+		}
+	}
+}
+
+// scan adds the outputs of the utxo set paying to the passed scripts.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) scan(scripts map[string]*scriptOrigin, interrupt <-chan struct{}) error {
+	_, _, err := w.cfg.ScanUtxoSet(interrupt, func(op wire.OutPoint,
+		entry *blockchain.UtxoEntry) error {
+
+		origin, ok := scripts[string(entry.PkScript())]
+		if !ok {
+			return nil
+		}
+		w.utxos[op] = &Utxo{
+			OutPoint:   op,
+			Amount:     btcutil.Amount(entry.Amount()),
+			PkScript:   entry.PkScript(),
+			Height:     entry.BlockHeight(),
+			IsCoinBase: entry.IsCoinBase(),
+			origin:     origin,
+		}
+		return nil
+	})
+	return err
+}
+
+// ImportDescriptor imports the passed descriptor and, when requested, scans the
+// utxo set for the outputs it already owns.  Importing a descriptor again
+// updates its flags and extends its range.  The scan is stopped when the
+// interrupt channel is closed, in which case the descriptor is not imported.
+//
+// Blocks which are connected during the scan are applied once it is done, so
+// the tracked outputs stay consistent with the chain.
+func (w *Wallet) ImportDescriptor(req *ImportRequest, interrupt <-chan struct{}) error {
+	if req.RangeStart > req.RangeEnd {
+		return fmt.Errorf("range start %d is after range end %d",
+			req.RangeStart, req.RangeEnd)
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	str := req.Descriptor.String()
+	var existing *trackedDescriptor
+	for _, d := range w.descriptors {
+		if d.str == str {
+			existing = d
+			break
+		}
+	}
+
+	d := &trackedDescriptor{
+		desc:       req.Descriptor,
+		str:        str,
+		rangeStart: req.RangeStart,
+		rangeEnd:   req.RangeEnd,
+		nextIndex:  req.NextIndex,
+		active:     req.Active,
+		internal:   req.Internal,
+		timestamp:  req.Timestamp,
+	}
+	if existing != nil {
+		if existing.rangeStart < d.rangeStart {
+			d.rangeStart = existing.rangeStart
+		}
+		if existing.rangeEnd > d.rangeEnd {
+			d.rangeEnd = existing.rangeEnd
+		}
+		if existing.nextIndex > d.nextIndex {
+			d.nextIndex = existing.nextIndex
+		}
+	}
+	if d.nextIndex < d.rangeStart {
+		d.nextIndex = d.rangeStart
+	}
+
+	scripts := make(map[string]*scriptOrigin)
+	if err := expand(scripts, d, d.rangeStart, d.rangeEnd); err != nil {
+		return err
+	}
+
+	// Only the scripts which weren't watched before need to be scanned
+	// for.
+	newScripts := make(map[string]*scriptOrigin)
+	for script, origin := range scripts {
+		if _, ok := w.scripts[script]; !ok {
+			newScripts[script] = origin
+		}
+	}
+	if req.Rescan && len(newScripts) > 0 {
+		numUtxos := len(w.utxos)
+		if err := w.scan(newScripts, interrupt); err != nil {
+			for op, utxo := range w.utxos {
+				if _, ok := newScripts[string(utxo.PkScript)]; ok {
+					delete(w.utxos, op)
+				}
+			}
+			return err
+		}
+		log.Infof("Found %d unspent outputs of descriptor %s",
+			len(w.utxos)-numUtxos, str)
+	}
+
+	if err := w.putDescriptor(d); err != nil {
+		return err
+	}
+
+	// Replace the previously imported descriptor and the active internal
+	// descriptor with the same type of script.
+	if d.active && d.internal {
+		class := d.scriptClass()
+		for _, other := range w.descriptors {
+			if other != existing && other.active && other.internal &&
+				other.scriptClass() == class {
+
+				other.active = false
+				if err := w.putDescriptor(other); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if existing != nil {
+		for i, other := range w.descriptors {
+			if other == existing {
+				w.descriptors[i] = d
+			}
+		}
+	} else {
+		w.descriptors = append(w.descriptors, d)
+		sortDescriptors(w.descriptors)
+	}
+
+	for script, origin := range scripts {
+		w.scripts[script] = origin
+	}
+	for _, utxo := range w.utxos {
+		if origin, ok := scripts[string(utxo.PkScript)]; ok {
+			utxo.origin = origin
+		}
+	}
+	return nil
+}
+
+// sortDescriptors sorts the passed descriptors by their string representation.
+func sortDescriptors(descs []*trackedDescriptor) {
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].str < descs[j].str
+	})
+}
+
+// Descriptors returns the imported descriptors sorted by descriptor.
+func (w *Wallet) Descriptors() []DescriptorInfo {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	infos := make([]DescriptorInfo, 0, len(w.descriptors))
+	for _, d := range w.descriptors {
+		infos = append(infos, d.info())
+	}
+	return infos
+}
+
+// Unspent returns the tracked unspent outputs along with the best block they
+// are current as of, sorted by the height of the outputs and their outpoints.
+func (w *Wallet) Unspent() ([]Utxo, chainhash.Hash, int32) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	utxos := make([]Utxo, 0, len(w.utxos))
+	for op, utxo := range w.utxos {
+		u := *utxo
+		_, u.Locked = w.locked[op]
+		u.Descriptor, _ = u.origin.desc.desc.DerivedString(
+			u.origin.index)
+		if exp, err := u.origin.desc.desc.Expand(u.origin.index); err == nil {
+			u.RedeemScript = exp.RedeemScript
+		}
+		utxos = append(utxos, u)
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Height != utxos[j].Height {
+			return utxos[i].Height < utxos[j].Height
+		}
+		return outPointLess(&utxos[i].OutPoint, &utxos[j].OutPoint)
+	})
+	return utxos, w.tipHash, w.tipHeight
+}
+
+// outPointLess returns whether the first outpoint sorts before the second one.
+func outPointLess(a, b *wire.OutPoint) bool {
+	for i := range a.Hash {
+		if a.Hash[i] != b.Hash[i] {
+			return a.Hash[i] < b.Hash[i]
+		}
+	}
+	return a.Index < b.Index
+}
+
+// LockUnspent locks the passed outputs, so they are not selected to fund
+// transactions.  The locks are not persisted and all outputs are unlocked on
+// the next start.  All outputs must be tracked by the wallet.
+func (w *Wallet) LockUnspent(ops []wire.OutPoint) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for i := range ops {
+		if _, ok := w.utxos[ops[i]]; !ok {
+			return fmt.Errorf("%w: %v", ErrUnknownOutput, ops[i])
+		}
+	}
+	for _, op := range ops {
+		w.locked[op] = struct{}{}
+	}
+	return nil
+}
+
+// UnlockUnspent unlocks the passed outputs, which must all be locked, or all
+// outputs when none are passed.
+func (w *Wallet) UnlockUnspent(ops []wire.OutPoint) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if len(ops) == 0 {
+		w.locked = make(map[wire.OutPoint]struct{})
+		return nil
+	}
+	for i := range ops {
+		if _, ok := w.locked[ops[i]]; !ok {
+			return fmt.Errorf("%w: %v", ErrNotLocked, ops[i])
+		}
+	}
+	for _, op := range ops {
+		delete(w.locked, op)
+	}
+	return nil
+}
+
+// LockedUnspent returns the locked outputs sorted by their outpoints.
+func (w *Wallet) LockedUnspent() []wire.OutPoint {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	ops := make([]wire.OutPoint, 0, len(w.locked))
+	for op := range w.locked {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return outPointLess(&ops[i], &ops[j])
+	})
+	return ops
+}
+
+// HandleChainEvent updates the tracked outputs with the outputs created and
+// spent by the blocks connected to and disconnected from the main chain.  It
+// is meant to be subscribed to the events of the chain.
+func (w *Wallet) HandleChainEvent(event *blockchain.ChainEvent) {
+	switch event.Type {
+	case blockchain.ChainEventBlockConnected:
+		w.mtx.Lock()
+		w.connectBlock(event.Block, event.SpentTxOuts)
+		w.tipHash = event.Hash
+		w.tipHeight = event.Height
+		w.mtx.Unlock()
+
+	case blockchain.ChainEventBlockDisconnected:
+		w.mtx.Lock()
+		w.disconnectBlock(event.Block, event.SpentTxOuts)
+		w.tipHash = event.Block.MsgBlock().Header.PrevBlock
+		w.tipHeight = event.Height - 1
+		w.mtx.Unlock()
+	}
+}
+
+// connectBlock removes the outputs spent by the passed block and adds the
+// outputs it creates which pay to a watched script.  Applying a block more than
+// once has no effect, which happens when a block is connected while the utxo
+// set is scanned.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) connectBlock(block *btcutil.Block, stxos []blockchain.SpentTxOut) {
+	if len(w.scripts) == 0 {
+		return
+	}
+
+	for _, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		isCoinBase := blockchain.IsCoinBaseTx(msgTx)
+		if !isCoinBase {
+			for _, txIn := range msgTx.TxIn {
+				op := txIn.PreviousOutPoint
+				if _, ok := w.utxos[op]; ok {
+					delete(w.utxos, op)
+					delete(w.locked, op)
+				}
+			}
+		}
+
+		for i, txOut := range msgTx.TxOut {
+			origin, ok := w.scripts[string(txOut.PkScript)]
+			if !ok {
+				continue
+			}
+			op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			w.utxos[op] = &Utxo{
+				OutPoint:   op,
+				Amount:     btcutil.Amount(txOut.Value),
+				PkScript:   txOut.PkScript,
+				Height:     block.Height(),
+				IsCoinBase: isCoinBase,
+				origin:     origin,
+			}
+		}
+	}
+}
+
+// disconnectBlock removes the outputs created by the passed block and adds back
+// the outputs it spent which pay to a watched script.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) disconnectBlock(block *btcutil.Block, stxos []blockchain.SpentTxOut) {
+	if len(w.scripts) == 0 {
+		return
+	}
+
+	// The spent outputs are in the order the transactions spend them, so
+	// find where the spent outputs of each transaction start first.
+	txns := block.Transactions()
+	stxoStarts := make([]int, len(txns))
+	var numStxos int
+	for i, tx := range txns {
+		stxoStarts[i] = numStxos
+		if i != 0 {
+			numStxos += len(tx.MsgTx().TxIn)
+		}
+	}
+	if numStxos != len(stxos) {
+		log.Errorf("Block %v spends %d outputs, but %d spent outputs "+
+			"were provided", block.Hash(), numStxos, len(stxos))
+		return
+	}
+
+	// Undo the transactions in reverse order, so the outputs which are
+	// created and spent by the block are not added back.
+	for i := len(txns) - 1; i >= 0; i-- {
+		msgTx := txns[i].MsgTx()
+		for j := range msgTx.TxOut {
+			op := wire.OutPoint{Hash: *txns[i].Hash(), Index: uint32(j)}
+			delete(w.utxos, op)
+			delete(w.locked, op)
+		}
+		if i == 0 {
+			continue
+		}
+
+		for j, txIn := range msgTx.TxIn {
+			stxo := &stxos[stxoStarts[i]+j]
+			origin, ok := w.scripts[string(stxo.PkScript)]
+			if !ok {
+				continue
+			}
+			op := txIn.PreviousOutPoint
+			w.utxos[op] = &Utxo{
+				OutPoint:   op,
+				Amount:     btcutil.Amount(stxo.Amount),
+				PkScript:   stxo.PkScript,
+				Height:     stxo.Height,
+				IsCoinBase: stxo.IsCoinBase,
+				origin:     origin,
+			}
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package watchonly
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testChain provides the chain functions the wallet depends on with a best
+// block and utxo set controlled by the tests.
+type testChain struct {
+	best  blockchain.BestState
+	utxos map[wire.OutPoint]*blockchain.UtxoEntry
+	scans int
+}
+
+// config returns the configuration of a wallet using the chain and the passed
+// database.
+func (c *testChain) config(db database.DB) *Config {
+	return &Config{
+		DB:          db,
+		ChainParams: &chaincfg.SimNetParams,
+		BestSnapshot: func() *blockchain.BestState {
+			best := c.best
+			return &best
+		},
+		ScanUtxoSet: func(interrupt <-chan struct{},
+			fn blockchain.UtxoScanFunc) (*chainhash.Hash, int32, error) {
+
+			c.scans++
+			for op, entry := range c.utxos {
+				if err := fn(op, entry); err != nil {
+					return nil, 0, err
+				}
+			}
+			return &c.best.Hash, c.best.Height, nil
+		},
+	}
+}
+
+// addUtxo adds an output paying to the passed script to the utxo set.
+func (c *testChain) addUtxo(op wire.OutPoint, amount int64, pkScript []byte, height int32) {
+	c.utxos[op] = blockchain.NewUtxoEntry(wire.NewTxOut(amount, pkScript),
+		height, false)
+}
+
+// newTestChain returns a chain at height 200 with an empty utxo set.
+func newTestChain() *testChain {
+	return &testChain{
+		best: blockchain.BestState{
+			Hash:   chainhash.Hash{0x01},
+			Height: 200,
+		},
+		utxos: make(map[wire.OutPoint]*blockchain.UtxoEntry),
+	}
+}
+
+// testDescriptors returns a ranged receive descriptor and a ranged change
+// descriptor of the same account.
+func testDescriptors(t *testing.T) (*descriptor.Descriptor, *descriptor.Descriptor) {
+	t.Helper()
+
+	params := &chaincfg.SimNetParams
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x07}, 32), params)
+	if err != nil {
+		t.Fatalf("unable to create master key: %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("unable to neuter master key: %v", err)
+	}
+	recv, err := descriptor.Parse("wpkh("+xpub.String()+"/0/*)", false,
+		params)
+	if err != nil {
+		t.Fatalf("unable to parse receive descriptor: %v", err)
+	}
+	change, err := descriptor.Parse("wpkh("+xpub.String()+"/1/*)", false,
+		params)
+	if err != nil {
+		t.Fatalf("unable to parse change descriptor: %v", err)
+	}
+	return recv, change
+}
+
+// mustScript returns the script of the passed descriptor at the passed index.
+func mustScript(t *testing.T, d *descriptor.Descriptor, index uint32) []byte {
+	t.Helper()
+
+	script, err := d.Script(index)
+	if err != nil {
+		t.Fatalf("unable to derive script %d: %v", index, err)
+	}
+	return script
+}
+
+// checkUnspent ensures the wallet tracks exactly the passed outputs.
+func checkUnspent(t *testing.T, w *Wallet, want ...wire.OutPoint) {
+	t.Helper()
+
+	utxos, _, _ := w.Unspent()
+	if len(utxos) != len(want) {
+		t.Fatalf("wallet tracks %d outputs, want %d", len(utxos),
+			len(want))
+	}
+	tracked := make(map[wire.OutPoint]struct{}, len(utxos))
+	for _, utxo := range utxos {
+		tracked[utxo.OutPoint] = struct{}{}
+	}
+	for _, op := range want {
+		if _, ok := tracked[op]; !ok {
+			t.Fatalf("wallet doesn't track output %v", op)
+		}
+	}
+}
+
+// TestWallet ensures descriptors are imported with a scan of the utxo set, the
+// tracked outputs follow the blocks connected to and disconnected from the
+// chain, and the wallet is restored on restart.
+func TestWallet(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Create("ffldb", t.TempDir(), wire.SimNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain := newTestChain()
+	w, err := New(chain.config(db))
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	recv, change := testDescriptors(t)
+
+	// Outputs paying to the watched range of the receive descriptor are
+	// found by the scan of the utxo set.
+	scanned := wire.OutPoint{Hash: chainhash.Hash{0x02}}
+	outOfRange := wire.OutPoint{Hash: chainhash.Hash{0x03}}
+	chain.addUtxo(scanned, 100000000, mustScript(t, recv, 3), 100)
+	chain.addUtxo(outOfRange, 100000000, mustScript(t, recv, 20), 100)
+	err = w.ImportDescriptor(&ImportRequest{
+		Descriptor: recv,
+		RangeEnd:   9,
+		Rescan:     true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("ImportDescriptor: unexpected error: %v", err)
+	}
+	err = w.ImportDescriptor(&ImportRequest{
+		Descriptor: change,
+		RangeEnd:   9,
+		Active:     true,
+		Internal:   true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("ImportDescriptor: unexpected error: %v", err)
+	}
+	checkUnspent(t, w, scanned)
+
+	utxos, _, _ := w.Unspent()
+	wantDesc, _ := recv.DerivedString(3)
+	if utxos[0].Descriptor != wantDesc {
+		t.Fatalf("unexpected descriptor of output: got %s, want %s",
+			utxos[0].Descriptor, wantDesc)
+	}
+	descs := w.Descriptors()
+	if len(descs) != 2 {
+		t.Fatalf("wallet has %d descriptors, want 2", len(descs))
+	}
+
+	// Connect a block with a coinbase paying to the receive descriptor
+	// and a transaction spending the scanned output to the change
+	// descriptor.
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil, nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, mustScript(t, recv, 5)))
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(&scanned, nil, nil))
+	spend.AddTxOut(wire.NewTxOut(40000000, []byte{0x51}))
+	spend.AddTxOut(wire.NewTxOut(59990000, mustScript(t, change, 0)))
+	msgBlock := &wire.MsgBlock{
+		Header:       wire.BlockHeader{PrevBlock: chain.best.Hash},
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	}
+	block := btcutil.NewBlock(msgBlock)
+	block.SetHeight(201)
+	stxos := []blockchain.SpentTxOut{{
+		Amount:   100000000,
+		PkScript: mustScript(t, recv, 3),
+		Height:   100,
+	}}
+	event := &blockchain.ChainEvent{
+		Type:        blockchain.ChainEventBlockConnected,
+		Hash:        *block.Hash(),
+		Height:      201,
+		Block:       block,
+		SpentTxOuts: stxos,
+	}
+	w.HandleChainEvent(event)
+	coinbaseOut := wire.OutPoint{Hash: coinbase.TxHash()}
+	changeOut := wire.OutPoint{Hash: spend.TxHash(), Index: 1}
+	checkUnspent(t, w, coinbaseOut, changeOut)
+
+	// Disconnecting the block restores the spent output.
+	w.HandleChainEvent(&blockchain.ChainEvent{
+		Type:        blockchain.ChainEventBlockDisconnected,
+		Hash:        *block.Hash(),
+		Height:      201,
+		Block:       block,
+		SpentTxOuts: stxos,
+	})
+	checkUnspent(t, w, scanned)
+	if _, hash, height := w.Unspent(); hash != chain.best.Hash ||
+		height != 200 {
+
+		t.Fatalf("wallet synced to block %v (%d), want %v (200)",
+			hash, height, chain.best.Hash)
+	}
+
+	// The outputs saved on shutdown are loaded when the chain didn't move
+	// on, so the utxo set isn't scanned.
+	w.HandleChainEvent(event)
+	chain.best.Hash = *block.Hash()
+	chain.best.Height = 201
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop: unexpected error: %v", err)
+	}
+	scans := chain.scans
+	w, err = New(chain.config(db))
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if chain.scans != scans {
+		t.Fatal("utxo set scanned although the saved outputs are " +
+			"current")
+	}
+	checkUnspent(t, w, coinbaseOut, changeOut)
+	descs = w.Descriptors()
+	if len(descs) != 2 || descs[0].Active || !descs[1].Active ||
+		!descs[1].Internal || descs[1].RangeEnd != 9 {
+
+		t.Fatalf("unexpected descriptors after restart: %+v", descs)
+	}
+
+	// The outputs are rebuilt from the utxo set when the wallet wasn't
+	// stopped cleanly.
+	delete(chain.utxos, scanned)
+	chain.addUtxo(changeOut, 59990000, mustScript(t, change, 0), 201)
+	w, err = New(chain.config(db))
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if chain.scans != scans+1 {
+		t.Fatal("utxo set not scanned after an unclean shutdown")
+	}
+	checkUnspent(t, w, changeOut)
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import "sync/atomic"
+
+const (
+	// arenaTxChunk is the maximum number of transactions allocated at once
+	// by a block arena.
+	arenaTxChunk = 1024
+
+	// arenaTxInChunk and arenaTxOutChunk are the number of inputs and
+	// outputs allocated at once by a block arena.
+	arenaTxInChunk  = 4096
+	arenaTxOutChunk = 4096
+
+	// arenaWitnessChunk is the number of witness items allocated at once by
+	// a block arena.
+	arenaWitnessChunk = 8192
+
+	// arenaScriptChunk is the number of script bytes allocated at once by a
+	// block arena.
+	arenaScriptChunk = 1 << 18
+)
+
+// blockArenaEnabled indicates whether blocks are decoded using a block arena.
+// It is accessed atomically.
+var blockArenaEnabled int32
+
+// SetBlockArena sets whether the transactions of blocks are allocated from a
+// per-block arena when blocks are decoded.
+//
+// Decoding a block otherwise makes several allocations per transaction, which
+// the garbage collector needs to track individually and which become garbage
+// at the same time once the block has been connected.  The arena instead
+// allocates the transactions of a block along with their inputs, outputs,
+// witnesses and scripts from a small number of large chunks that are released
+// together, which considerably reduces the garbage collection work caused by
+// large blocks.
+//
+// The trade-off is that any reference to a transaction of the block, or one of
+// its scripts, that outlives the block keeps all of the chunks it shares alive.
+//
+// This function is safe for concurrent access.
+func SetBlockArena(enable bool) {
+	var enabled int32
+	if enable {
+		enabled = 1
+	}
+	atomic.StoreInt32(&blockArenaEnabled, enabled)
+}
+
+// newBlockArena returns a new arena for decoding a block with the passed
+// number of transactions when decoding blocks using an arena is enabled, and
+// nil otherwise.
+func newBlockArena(txCount uint64) *blockArena {
+	if atomic.LoadInt32(&blockArenaEnabled) == 0 {
+		return nil
+	}
+
+	return &blockArena{remainingTxs: txCount}
+}
+
+// blockArena allocates the transactions of a block and their inputs, outputs,
+// witnesses and scripts from chunks which are shared by all transactions of the
+// block.  New chunks are allocated as the previous ones are used up, so the
+// memory of a chunk is only released once none of the transactions allocated
+// from it are referenced anymore.
+//
+// A nil arena allocates everything individually.
+type blockArena struct {
+	// remainingTxs is the number of transactions of the block which have
+	// not been allocated yet.  It bounds the size of the transaction
+	// chunks so that the transaction count of a block, which is not
+	// validated before the transactions are decoded, can't cause large
+	// allocations on its own.
+	remainingTxs uint64
+
+	txs       []MsgTx
+	txIns     []TxIn
+	txInPtrs  []*TxIn
+	txOuts    []TxOut
+	txOutPtrs []*TxOut
+	witnesses [][]byte
+	scripts   []byte
+}
+
+// newTx returns a transaction allocated from the arena.
+func (a *blockArena) newTx() *MsgTx {
+	if a == nil || a.remainingTxs == 0 {
+		return &MsgTx{}
+	}
+
+	if len(a.txs) == 0 {
+		size := a.remainingTxs
+		if size > arenaTxChunk {
+			size = arenaTxChunk
+		}
+		a.txs = make([]MsgTx, size)
+	}
+
+	a.remainingTxs--
+	tx := &a.txs[0]
+	a.txs = a.txs[1:]
+	return tx
+}
+
+// newTxIns returns the passed number of inputs and pointers to them.
+func (a *blockArena) newTxIns(count int) ([]TxIn, []*TxIn) {
+	if a == nil {
+		return make([]TxIn, count), make([]*TxIn, count)
+	}
+
+	if count > len(a.txIns) {
+		size := count
+		if size < arenaTxInChunk {
+			size = arenaTxInChunk
+		}
+		a.txIns = make([]TxIn, size)
+		a.txInPtrs = make([]*TxIn, size)
+	}
+
+	txIns := a.txIns[:count:count]
+	txInPtrs := a.txInPtrs[:count:count]
+	a.txIns = a.txIns[count:]
+	a.txInPtrs = a.txInPtrs[count:]
+	return txIns, txInPtrs
+}
+
+// newTxOuts returns the passed number of outputs and pointers to them.
+func (a *blockArena) newTxOuts(count int) ([]TxOut, []*TxOut) {
+	if a == nil {
+		return make([]TxOut, count), make([]*TxOut, count)
+	}
+
+	if count > len(a.txOuts) {
+		size := count
+		if size < arenaTxOutChunk {
+			size = arenaTxOutChunk
+		}
+		a.txOuts = make([]TxOut, size)
+		a.txOutPtrs = make([]*TxOut, size)
+	}
+
+	txOuts := a.txOuts[:count:count]
+	txOutPtrs := a.txOutPtrs[:count:count]
+	a.txOuts = a.txOuts[count:]
+	a.txOutPtrs = a.txOutPtrs[count:]
+	return txOuts, txOutPtrs
+}
+
+// newWitness returns a witness with the passed number of items.
+func (a *blockArena) newWitness(count int) TxWitness {
+	if a == nil {
+		return make(TxWitness, count)
+	}
+
+	if count > len(a.witnesses) {
+		size := count
+		if size < arenaWitnessChunk {
+			size = arenaWitnessChunk
+		}
+		a.witnesses = make([][]byte, size)
+	}
+
+	witness := a.witnesses[:count:count]
+	a.witnesses = a.witnesses[count:]
+	return TxWitness(witness)
+}
+
+// newScripts returns a buffer of the passed size to house the scripts of a
+// transaction.
+func (a *blockArena) newScripts(size int) []byte {
+	if a == nil {
+		return make([]byte, size)
+	}
+
+	if size > len(a.scripts) {
+		// Scripts which are larger than a chunk get their own
+		// allocation rather than wasting the rest of the current chunk.
+		if size > arenaScriptChunk {
+			return make([]byte, size)
+		}
+		a.scripts = make([]byte, arenaScriptChunk)
+	}
+
+	scripts := a.scripts[:size:size]
+	a.scripts = a.scripts[size:]
+	return scripts
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestBlockArena ensures blocks decoded using a block arena are identical to
+// those decoded without one.
+func TestBlockArena(t *testing.T) {
+	raw, err := os.ReadFile("testdata/block-00000000000000000021868c2cefc52a480d173c849412fe81c4e5ab806f94ab.blk")
+	if err != nil {
+		t.Fatalf("Failed to read block data: %v", err)
+	}
+
+	var want MsgBlock
+	if err := want.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	wantTxLocs, err := new(MsgBlock).DeserializeTxLoc(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("DeserializeTxLoc: unexpected error: %v", err)
+	}
+
+	SetBlockArena(true)
+	defer SetBlockArena(false)
+
+	var block MsgBlock
+	if err := block.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&block, &want) {
+		t.Fatal("block decoded using an arena differs")
+	}
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), raw) {
+		t.Fatal("serialized block decoded using an arena differs")
+	}
+
+	var txLocBlock MsgBlock
+	txLocs, err := txLocBlock.DeserializeTxLoc(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("DeserializeTxLoc: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&txLocBlock, &want) ||
+		!reflect.DeepEqual(txLocs, wantTxLocs) {
+
+		t.Fatal("block decoded using an arena with locations differs")
+	}
+
+	// Modifying the scripts of a transaction must not affect the other
+	// transactions sharing its chunks.
+	first, second := block.Transactions[1], block.Transactions[2]
+	first.TxOut[0].PkScript = append(first.TxOut[0].PkScript, 0x00)
+	first.TxIn[0].SignatureScript = append(
+		first.TxIn[0].SignatureScript, 0x00,
+	)
+	if !reflect.DeepEqual(second, want.Transactions[2]) {
+		t.Fatal("modifying a transaction changed another one")
+	}
+
+	// A block claiming more transactions than it contains fails to decode
+	// without allocating them upfront.
+	truncated := append([]byte(nil), raw[:80]...)
+	truncated = append(truncated, 0xfe, 0xff, 0xff, 0xff, 0x00)
+	if err := new(MsgBlock).Deserialize(bytes.NewReader(truncated)); err == nil {
+		t.Fatal("Deserialize: expected error for truncated block")
+	}
+}
@@ -627,6 +627,30 @@ func BenchmarkDeserializeBlock(b *testing.B) {
 	}
 }
 
+// BenchmarkDeserializeBlockArena performs a benchmark on how long it takes to
+// deserialize a block using a block arena.
+func BenchmarkDeserializeBlockArena(b *testing.B) {
+	buf, err := os.ReadFile(
+		"testdata/block-00000000000000000021868c2cefc52a480d173c849412fe81c4e5ab806f94ab.blk",
+	)
+	if err != nil {
+		b.Fatalf("Failed to read block data: %v", err)
+	}
+
+	SetBlockArena(true)
+	defer SetBlockArena(false)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	r := bytes.NewReader(buf)
+	var block MsgBlock
+	for i := 0; i < b.N; i++ {
+		r.Seek(0, 0)
+		block.Deserialize(r)
+	}
+}
+
 func BenchmarkSerializeBlock(b *testing.B) {
 	buf, err := os.ReadFile(
 		"testdata/block-00000000000000000021868c2cefc52a480d173c849412fe81c4e5ab806f94ab.blk",
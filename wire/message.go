@@ -59,6 +59,15 @@ const (
 	CmdCFHeaders    = "cfheaders"
 	CmdCFCheckpt    = "cfcheckpt"
 	CmdSendAddrV2   = "sendaddrv2"
+	CmdAttest       = "attest"
+	CmdPeerAuth     = "peerauth"
+	CmdSendTxRcncl  = "sendtxrcncl"
+	CmdReqRecon     = "reqrecon"
+	CmdSketch       = "sketch"
+	CmdReqSketchExt = "reqsketchext"
+	CmdReconcilDiff = "reconcildiff"
+	CmdSendCmpr     = "sendcmpr"
+	CmdCompressed   = "cmpmsg"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -195,6 +204,33 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdCFCheckpt:
 		msg = &MsgCFCheckpt{}
 
+	case CmdAttest:
+		msg = &MsgAttest{}
+
+	case CmdPeerAuth:
+		msg = &MsgPeerAuth{}
+
+	case CmdSendTxRcncl:
+		msg = &MsgSendTxRcncl{}
+
+	case CmdReqRecon:
+		msg = &MsgReqRecon{}
+
+	case CmdSketch:
+		msg = &MsgSketch{}
+
+	case CmdReqSketchExt:
+		msg = &MsgReqSketchExt{}
+
+	case CmdReconcilDiff:
+		msg = &MsgReconcilDiff{}
+
+	case CmdSendCmpr:
+		msg = &MsgSendCmpr{}
+
+	case CmdCompressed:
+		msg = &MsgCompressed{}
+
 	default:
 		return nil, ErrUnknownMessage
 	}
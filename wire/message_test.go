@@ -76,6 +76,21 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgAttest := NewMsgAttest(&chainhash.Hash{}, 0,
+		[AttestPubKeySize]byte{0x02}, []byte("signature"))
+	msgPeerAuth := NewMsgPeerAuth([PeerAuthPubKeySize]byte{0x02}, 0,
+		[]byte("certsig"), []byte("signature"))
+	msgSendTxRcncl := NewMsgSendTxRcncl(TxReconciliationVersion, 1)
+	msgReqRecon := NewMsgReqRecon(10, 8191)
+	msgSketch := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04})
+	msgReqSketchExt := NewMsgReqSketchExt()
+	msgReconcilDiff := NewMsgReconcilDiff(true, []uint32{1})
+	msgSendCmpr := NewMsgSendCmpr(CompressionDeflate)
+	msgCompressed := &MsgCompressed{
+		Codec:        CompressionDeflate,
+		InnerCommand: CmdHeaders,
+		Payload:      []byte{0x01},
+	}
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -111,6 +126,15 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
 		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{msgAttest, msgAttest, pver, MainNet, 103},
+		{msgPeerAuth, msgPeerAuth, pver, MainNet, 83},
+		{msgSendTxRcncl, msgSendTxRcncl, pver, MainNet, 36},
+		{msgReqRecon, msgReqRecon, pver, MainNet, 28},
+		{msgSketch, msgSketch, pver, MainNet, 29},
+		{msgReqSketchExt, msgReqSketchExt, pver, MainNet, 24},
+		{msgReconcilDiff, msgReconcilDiff, pver, MainNet, 30},
+		{msgSendCmpr, msgSendCmpr, pver, MainNet, 26},
+		{msgCompressed, msgCompressed, pver, MainNet, 35},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,118 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// AttestPubKeySize is the size of the compressed public key of the
+	// signer of an attestation.
+	AttestPubKeySize = 33
+
+	// MaxAttestSignatureSize is the maximum byte size of the DER encoded
+	// signature of an attestation.
+	MaxAttestSignatureSize = 72
+)
+
+// MsgAttest implements the Message interface and represents a bitcoin attest
+// message.  It is used on permissioned networks by the signers of a finality
+// quorum to announce that they attest to a block, which makes the block
+// irreversibly final once a quorum of signers has attested to it.
+//
+// This message is not part of the standard bitcoin protocol and is only sent
+// to peers when the finality module is enabled.  Peers that don't know the
+// message ignore it.
+type MsgAttest struct {
+	BlockHash chainhash.Hash
+	Height    int32
+	PubKey    [AttestPubKeySize]byte
+	Signature []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgAttest) BtcDecode(r io.Reader, pver uint32, _ MessageEncoding) error {
+	buf := binarySerializer.Borrow()
+	defer binarySerializer.Return(buf)
+
+	if _, err := io.ReadFull(r, msg.BlockHash[:]); err != nil {
+		return err
+	}
+
+	height, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	msg.Height = int32(height)
+
+	if _, err := io.ReadFull(r, msg.PubKey[:]); err != nil {
+		return err
+	}
+
+	msg.Signature, err = ReadVarBytesBuf(r, pver, buf,
+		MaxAttestSignatureSize, "attest signature")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgAttest) BtcEncode(w io.Writer, pver uint32, _ MessageEncoding) error {
+	size := len(msg.Signature)
+	if size > MaxAttestSignatureSize {
+		str := fmt.Sprintf("attest signature too large for message "+
+			"[size %v, max %v]", size, MaxAttestSignatureSize)
+		return messageError("MsgAttest.BtcEncode", str)
+	}
+
+	buf := binarySerializer.Borrow()
+	defer binarySerializer.Return(buf)
+
+	if _, err := w.Write(msg.BlockHash[:]); err != nil {
+		return err
+	}
+
+	littleEndian.PutUint32(buf[:4], uint32(msg.Height))
+	if _, err := w.Write(buf[:4]); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg.PubKey[:]); err != nil {
+		return err
+	}
+
+	return WriteVarBytesBuf(w, pver, msg.Signature, buf)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgAttest) Command() string {
+	return CmdAttest
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgAttest) MaxPayloadLength(pver uint32) uint32 {
+	return chainhash.HashSize + 4 + AttestPubKeySize +
+		uint32(VarIntSerializeSize(MaxAttestSignatureSize)) +
+		MaxAttestSignatureSize
+}
+
+// NewMsgAttest returns a new bitcoin attest message that conforms to the
+// Message interface.  See MsgAttest for details.
+func NewMsgAttest(blockHash *chainhash.Hash, height int32,
+	pubKey [AttestPubKeySize]byte, signature []byte) *MsgAttest {
+
+	return &MsgAttest{
+		BlockHash: *blockHash,
+		Height:    height,
+		PubKey:    pubKey,
+		Signature: signature,
+	}
+}
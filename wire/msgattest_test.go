@@ -0,0 +1,67 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAttestWire tests the MsgAttest wire encode and decode.
+func TestAttestWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := chainhash.Hash{0x01, 0x02}
+	pubKey := [AttestPubKeySize]byte{0x03, 0x04}
+	msg := NewMsgAttest(&hash, 0x0a0b0c0d, pubKey, []byte{0x30, 0x05})
+
+	// Ensure the command is expected value.
+	wantCmd := "attest"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgAttest: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(142)
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length - got "+
+			"%v, want %v", maxPayload, wantPayload)
+	}
+
+	want := make([]byte, 0, 72)
+	want = append(want, hash[:]...)
+	want = append(want, 0x0d, 0x0c, 0x0b, 0x0a)
+	want = append(want, pubKey[:]...)
+	want = append(want, 0x02, 0x30, 0x05)
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode: got %s want %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var readMsg MsgAttest
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BtcDecode: got %s want %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure signatures that are too large are rejected.
+	msg.Signature = make([]byte, MaxAttestSignatureSize+1)
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcEncode: expected error for oversized signature")
+	}
+}
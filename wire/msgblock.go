@@ -101,14 +101,15 @@ func (msg *MsgBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) er
 	scriptBuf := scriptPool.Borrow()
 	defer scriptPool.Return(scriptBuf)
 
+	arena := newBlockArena(txCount)
 	msg.Transactions = make([]*MsgTx, 0, txCount)
 	for i := uint64(0); i < txCount; i++ {
-		tx := MsgTx{}
-		err := tx.btcDecode(r, pver, enc, buf, scriptBuf[:])
+		tx := arena.newTx()
+		err := tx.btcDecode(r, pver, enc, buf, scriptBuf[:], arena)
 		if err != nil {
 			return err
 		}
-		msg.Transactions = append(msg.Transactions, &tx)
+		msg.Transactions = append(msg.Transactions, tx)
 	}
 
 	return nil
@@ -179,16 +180,18 @@ func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 
 	// Deserialize each transaction while keeping track of its location
 	// within the byte stream.
+	arena := newBlockArena(txCount)
 	msg.Transactions = make([]*MsgTx, 0, txCount)
 	txLocs := make([]TxLoc, txCount)
 	for i := uint64(0); i < txCount; i++ {
 		txLocs[i].TxStart = fullLen - r.Len()
-		tx := MsgTx{}
-		err := tx.btcDecode(r, 0, WitnessEncoding, buf, scriptBuf[:])
+		tx := arena.newTx()
+		err := tx.btcDecode(r, 0, WitnessEncoding, buf, scriptBuf[:],
+			arena)
 		if err != nil {
 			return nil, err
 		}
-		msg.Transactions = append(msg.Transactions, &tx)
+		msg.Transactions = append(msg.Transactions, tx)
 		txLocs[i].TxLen = (fullLen - r.Len()) - txLocs[i].TxStart
 	}
 
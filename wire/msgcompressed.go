@@ -0,0 +1,193 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// CompressionCodec identifies the codec used to compress the payload of a
+// cmpmsg message.
+type CompressionCodec uint8
+
+const (
+	// CompressionDeflate compresses payloads with DEFLATE (RFC 1951).
+	CompressionDeflate CompressionCodec = 1
+)
+
+// Map of compression codecs back to their names for pretty printing.
+var compressionCodecStrings = map[CompressionCodec]string{
+	CompressionDeflate: "deflate",
+}
+
+// String returns the CompressionCodec in human-readable form.
+func (c CompressionCodec) String() string {
+	if s, ok := compressionCodecStrings[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown CompressionCodec (%d)", uint8(c))
+}
+
+// IsSupported returns whether the codec is implemented by this package.
+func (c CompressionCodec) IsSupported() bool {
+	_, ok := compressionCodecStrings[c]
+	return ok
+}
+
+// IsCompressible returns whether messages with the passed command may be sent
+// compressed in a cmpmsg message.  Only block and headers messages are
+// compressed since they make up most of the traffic between peers and
+// compress well.
+func IsCompressible(command string) bool {
+	return command == CmdBlock || command == CmdHeaders
+}
+
+// MsgCompressed implements the Message interface and represents a bitcoin
+// cmpmsg message.  It carries another message whose payload is compressed with
+// the codec the remote peer listed in its sendcmpr message.  See MsgSendCmpr.
+type MsgCompressed struct {
+	// Codec is the codec the payload is compressed with.
+	Codec CompressionCodec
+
+	// InnerCommand is the command of the compressed message.
+	InnerCommand string
+
+	// Payload is the compressed payload of the message.
+	Payload []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCompressed) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	codec, err := binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+	msg.Codec = CompressionCodec(codec)
+
+	msg.InnerCommand, err = ReadVarString(r, pver)
+	if err != nil {
+		return err
+	}
+	if len(msg.InnerCommand) > CommandSize {
+		str := fmt.Sprintf("inner command too long [len %v, max %v]",
+			len(msg.InnerCommand), CommandSize)
+		return messageError("MsgCompressed.BtcDecode", str)
+	}
+
+	msg.Payload, err = ReadVarBytes(r, pver, MaxMessagePayload,
+		"compressed payload")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCompressed) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if len(msg.InnerCommand) > CommandSize {
+		str := fmt.Sprintf("inner command too long [len %v, max %v]",
+			len(msg.InnerCommand), CommandSize)
+		return messageError("MsgCompressed.BtcEncode", str)
+	}
+
+	err := binarySerializer.PutUint8(w, uint8(msg.Codec))
+	if err != nil {
+		return err
+	}
+	if err := WriteVarString(w, pver, msg.InnerCommand); err != nil {
+		return err
+	}
+	return WriteVarBytes(w, pver, msg.Payload)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCompressed) Command() string {
+	return CmdCompressed
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCompressed) MaxPayloadLength(pver uint32) uint32 {
+	return MaxMessagePayload
+}
+
+// Decompress decompresses the payload and decodes it into the message it
+// carries.  The decompressed payload, which is the serialized inner message, is
+// returned as well.  Its size is limited to the maximum payload length of the
+// inner message, so a small compressed payload can't expand into an
+// arbitrarily large one.
+func (msg *MsgCompressed) Decompress(pver uint32, enc MessageEncoding) (Message, []byte, error) {
+	if !IsCompressible(msg.InnerCommand) {
+		str := fmt.Sprintf("command %q may not be compressed",
+			msg.InnerCommand)
+		return nil, nil, messageError("MsgCompressed.Decompress", str)
+	}
+	if msg.Codec != CompressionDeflate {
+		str := fmt.Sprintf("unsupported compression codec %v", msg.Codec)
+		return nil, nil, messageError("MsgCompressed.Decompress", str)
+	}
+
+	inner, err := makeEmptyMessage(msg.InnerCommand)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Read one byte more than allowed to detect oversized payloads.
+	maxLen := inner.MaxPayloadLength(pver)
+	fr := flate.NewReader(bytes.NewReader(msg.Payload))
+	defer fr.Close()
+	payload, err := io.ReadAll(io.LimitReader(fr, int64(maxLen)+1))
+	if err != nil {
+		str := fmt.Sprintf("invalid compressed payload: %v", err)
+		return nil, nil, messageError("MsgCompressed.Decompress", str)
+	}
+	if uint32(len(payload)) > maxLen {
+		str := fmt.Sprintf("decompressed payload exceeds the maximum "+
+			"length of %s messages [max %v]", msg.InnerCommand, maxLen)
+		return nil, nil, messageError("MsgCompressed.Decompress", str)
+	}
+
+	if err := inner.BtcDecode(bytes.NewReader(payload), pver, enc); err != nil {
+		return nil, nil, err
+	}
+	return inner, payload, nil
+}
+
+// NewMsgCompressed returns a new bitcoin cmpmsg message that carries the passed
+// message compressed with the passed codec.  See MsgCompressed for details.
+func NewMsgCompressed(inner Message, codec CompressionCodec, pver uint32,
+	enc MessageEncoding) (*MsgCompressed, error) {
+
+	command := inner.Command()
+	if !IsCompressible(command) {
+		str := fmt.Sprintf("command %q may not be compressed", command)
+		return nil, messageError("NewMsgCompressed", str)
+	}
+	if codec != CompressionDeflate {
+		str := fmt.Sprintf("unsupported compression codec %v", codec)
+		return nil, messageError("NewMsgCompressed", str)
+	}
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if err := inner.BtcEncode(fw, pver, enc); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &MsgCompressed{
+		Codec:        codec,
+		InnerCommand: command,
+		Payload:      buf.Bytes(),
+	}, nil
+}
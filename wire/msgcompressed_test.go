@@ -0,0 +1,150 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"compress/flate"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCompressedWire tests the MsgCompressed wire encode and decode as well as
+// the compression and decompression of the messages it carries.
+func TestCompressedWire(t *testing.T) {
+	pver := ProtocolVersion
+	enc := WitnessEncoding
+
+	headers := NewMsgHeaders()
+	for i := 0; i < 100; i++ {
+		headers.AddBlockHeader(&blockOne.Header)
+	}
+
+	tests := []struct {
+		name  string
+		inner Message
+	}{
+		{name: "block", inner: &blockOne},
+		{name: "headers", inner: headers},
+	}
+
+	for _, test := range tests {
+		msg, err := NewMsgCompressed(test.inner, CompressionDeflate,
+			pver, enc)
+		if err != nil {
+			t.Fatalf("%s: NewMsgCompressed: unexpected error: %v",
+				test.name, err)
+		}
+		if msg.Command() != "cmpmsg" {
+			t.Errorf("%s: wrong command - got %v", test.name,
+				msg.Command())
+		}
+		if msg.InnerCommand != test.inner.Command() {
+			t.Errorf("%s: wrong inner command - got %v want %v",
+				test.name, msg.InnerCommand, test.inner.Command())
+		}
+
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, pver, enc); err != nil {
+			t.Fatalf("%s: BtcEncode: unexpected error: %v",
+				test.name, err)
+		}
+		var readMsg MsgCompressed
+		if err := readMsg.BtcDecode(&buf, pver, enc); err != nil {
+			t.Fatalf("%s: BtcDecode: unexpected error: %v",
+				test.name, err)
+		}
+		if !reflect.DeepEqual(&readMsg, msg) {
+			t.Fatalf("%s: BtcDecode: got %s want %s", test.name,
+				spew.Sdump(readMsg), spew.Sdump(msg))
+		}
+
+		inner, payload, err := readMsg.Decompress(pver, enc)
+		if err != nil {
+			t.Fatalf("%s: Decompress: unexpected error: %v",
+				test.name, err)
+		}
+		if !reflect.DeepEqual(inner, test.inner) {
+			t.Fatalf("%s: Decompress: got %s want %s", test.name,
+				spew.Sdump(inner), spew.Sdump(test.inner))
+		}
+		var want bytes.Buffer
+		if err := test.inner.BtcEncode(&want, pver, enc); err != nil {
+			t.Fatalf("%s: BtcEncode: unexpected error: %v",
+				test.name, err)
+		}
+		if !bytes.Equal(payload, want.Bytes()) {
+			t.Fatalf("%s: Decompress: wrong payload", test.name)
+		}
+	}
+
+	// Repeated headers compress well.
+	msg, err := NewMsgCompressed(headers, CompressionDeflate, pver, enc)
+	if err != nil {
+		t.Fatalf("NewMsgCompressed: unexpected error: %v", err)
+	}
+	var raw bytes.Buffer
+	if err := headers.BtcEncode(&raw, pver, enc); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if len(msg.Payload) >= raw.Len()/10 {
+		t.Errorf("headers were not compressed: %d of %d bytes",
+			len(msg.Payload), raw.Len())
+	}
+
+	// Ensure only block and headers messages may be compressed.
+	if _, err := NewMsgCompressed(NewMsgPing(1), CompressionDeflate, pver,
+		enc); err == nil {
+
+		t.Fatal("NewMsgCompressed: expected error for ping message")
+	}
+	ping := &MsgCompressed{
+		Codec:        CompressionDeflate,
+		InnerCommand: CmdPing,
+		Payload:      msg.Payload,
+	}
+	if _, _, err := ping.Decompress(pver, enc); err == nil {
+		t.Fatal("Decompress: expected error for ping message")
+	}
+
+	// Ensure unsupported codecs are rejected.
+	if _, err := NewMsgCompressed(headers, CompressionCodec(7), pver,
+		enc); err == nil {
+
+		t.Fatal("NewMsgCompressed: expected error for unknown codec")
+	}
+	unknown := *msg
+	unknown.Codec = CompressionCodec(7)
+	if _, _, err := unknown.Decompress(pver, enc); err == nil {
+		t.Fatal("Decompress: expected error for unknown codec")
+	}
+
+	// Ensure payloads which decompress to more than the maximum payload
+	// length of the inner message are rejected.
+	var bomb bytes.Buffer
+	fw, _ := flate.NewWriter(&bomb, flate.BestCompression)
+	fw.Write(make([]byte, headers.MaxPayloadLength(pver)+1))
+	fw.Close()
+	oversized := &MsgCompressed{
+		Codec:        CompressionDeflate,
+		InnerCommand: CmdHeaders,
+		Payload:      bomb.Bytes(),
+	}
+	if _, _, err := oversized.Decompress(pver, enc); err == nil {
+		t.Fatal("Decompress: expected error for oversized payload")
+	}
+
+	// Ensure invalid compressed data is rejected.
+	invalid := &MsgCompressed{
+		Codec:        CompressionDeflate,
+		InnerCommand: CmdHeaders,
+		Payload:      []byte{0xff, 0xff, 0xff},
+	}
+	if _, _, err := invalid.Decompress(pver, enc); err == nil {
+		t.Fatal("Decompress: expected error for invalid payload")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// PeerAuthPubKeySize is the size of the compressed public key of the
+	// node that authenticates itself with a peerauth message.
+	PeerAuthPubKeySize = 33
+
+	// MaxPeerAuthSignatureSize is the maximum byte size of the DER encoded
+	// signatures of a peerauth message.
+	MaxPeerAuthSignatureSize = 72
+)
+
+// MsgPeerAuth implements the Message interface and represents a bitcoin
+// peerauth message.  It is used on permissioned networks during the version
+// handshake for a node to prove it was admitted to the network.  The message
+// carries the certificate the network authority issued for the public key of
+// the node along with a signature of the v2 transport session id and the role
+// of the node in the connection made with the key, which proves the node holds
+// the private key.
+//
+// This message is not part of the standard bitcoin protocol and is only sent
+// to peers when peer authentication is enabled.  Peers that don't know the
+// message ignore it.
+type MsgPeerAuth struct {
+	// PubKey is the compressed public key of the node.
+	PubKey [PeerAuthPubKeySize]byte
+
+	// Expiry is the unix time the certificate expires at.  Zero means the
+	// certificate does not expire.
+	Expiry int64
+
+	// CertSignature is the signature of the authority over the public key
+	// and expiry.
+	CertSignature []byte
+
+	// Signature is the signature of the node over the v2 transport session
+	// id and its role in the connection.
+	Signature []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgPeerAuth) BtcDecode(r io.Reader, pver uint32, _ MessageEncoding) error {
+	buf := binarySerializer.Borrow()
+	defer binarySerializer.Return(buf)
+
+	if _, err := io.ReadFull(r, msg.PubKey[:]); err != nil {
+		return err
+	}
+
+	expiry, err := binarySerializer.Uint64(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	msg.Expiry = int64(expiry)
+
+	msg.CertSignature, err = ReadVarBytesBuf(r, pver, buf,
+		MaxPeerAuthSignatureSize, "peerauth certificate signature")
+	if err != nil {
+		return err
+	}
+
+	msg.Signature, err = ReadVarBytesBuf(r, pver, buf,
+		MaxPeerAuthSignatureSize, "peerauth signature")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgPeerAuth) BtcEncode(w io.Writer, pver uint32, _ MessageEncoding) error {
+	for _, sig := range [][]byte{msg.CertSignature, msg.Signature} {
+		if len(sig) > MaxPeerAuthSignatureSize {
+			str := fmt.Sprintf("peerauth signature too large for "+
+				"message [size %v, max %v]", len(sig),
+				MaxPeerAuthSignatureSize)
+			return messageError("MsgPeerAuth.BtcEncode", str)
+		}
+	}
+
+	buf := binarySerializer.Borrow()
+	defer binarySerializer.Return(buf)
+
+	if _, err := w.Write(msg.PubKey[:]); err != nil {
+		return err
+	}
+
+	littleEndian.PutUint64(buf[:8], uint64(msg.Expiry))
+	if _, err := w.Write(buf[:8]); err != nil {
+		return err
+	}
+
+	if err := WriteVarBytesBuf(w, pver, msg.CertSignature, buf); err != nil {
+		return err
+	}
+
+	return WriteVarBytesBuf(w, pver, msg.Signature, buf)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgPeerAuth) Command() string {
+	return CmdPeerAuth
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgPeerAuth) MaxPayloadLength(pver uint32) uint32 {
+	return PeerAuthPubKeySize + 8 + 2*(uint32(VarIntSerializeSize(
+		MaxPeerAuthSignatureSize))+MaxPeerAuthSignatureSize)
+}
+
+// NewMsgPeerAuth returns a new bitcoin peerauth message that conforms to the
+// Message interface.  See MsgPeerAuth for details.
+func NewMsgPeerAuth(pubKey [PeerAuthPubKeySize]byte, expiry int64,
+	certSignature, signature []byte) *MsgPeerAuth {
+
+	return &MsgPeerAuth{
+		PubKey:        pubKey,
+		Expiry:        expiry,
+		CertSignature: certSignature,
+		Signature:     signature,
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestPeerAuthWire tests the MsgPeerAuth wire encode and decode.
+func TestPeerAuthWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	pubKey := [PeerAuthPubKeySize]byte{0x02, 0x03}
+	msg := NewMsgPeerAuth(pubKey, 0x0102030405060708, []byte{0x30, 0x05},
+		[]byte{0x30, 0x06, 0x07})
+
+	// Ensure the command is expected value.
+	wantCmd := "peerauth"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgPeerAuth: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(187)
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length - got "+
+			"%v, want %v", maxPayload, wantPayload)
+	}
+
+	want := make([]byte, 0, 48)
+	want = append(want, pubKey[:]...)
+	want = append(want, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01)
+	want = append(want, 0x02, 0x30, 0x05)
+	want = append(want, 0x03, 0x30, 0x06, 0x07)
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode: got %s want %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var readMsg MsgPeerAuth
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BtcDecode: got %s want %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure signatures that are too large are rejected.
+	msg.CertSignature = make([]byte, MaxPeerAuthSignatureSize+1)
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcEncode: expected error for oversized certificate " +
+			"signature")
+	}
+	msg.CertSignature = nil
+	msg.Signature = make([]byte, MaxPeerAuthSignatureSize+1)
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcEncode: expected error for oversized signature")
+	}
+}
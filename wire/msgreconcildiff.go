@@ -0,0 +1,96 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgReconcilDiff implements the Message interface and represents a bitcoin
+// reconcildiff message.  It concludes a round of transaction set
+// reconciliation (BIP0330).  On success it lists the short IDs of the
+// transactions the initiator is missing, which the receiver announces in
+// reply.  On failure the receiver announces its whole reconciliation set.
+type MsgReconcilDiff struct {
+	// Success indicates whether the set difference was decoded.
+	Success bool
+
+	// AskShortIDs are the short IDs of the transactions of the receiver
+	// that the sender is missing.
+	AskShortIDs []uint32
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if err := readElement(r, &msg.Success); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > MaxReconSketchCapacity {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, MaxReconSketchCapacity)
+		return messageError("MsgReconcilDiff.BtcDecode", str)
+	}
+
+	msg.AskShortIDs = make([]uint32, count)
+	for i := range msg.AskShortIDs {
+		if err := readElement(r, &msg.AskShortIDs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	count := len(msg.AskShortIDs)
+	if count > MaxReconSketchCapacity {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, MaxReconSketchCapacity)
+		return messageError("MsgReconcilDiff.BtcEncode", str)
+	}
+
+	if err := writeElement(w, msg.Success); err != nil {
+		return err
+	}
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+	for _, id := range msg.AskShortIDs {
+		if err := writeElement(w, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReconcilDiff) Command() string {
+	return CmdReconcilDiff
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) MaxPayloadLength(pver uint32) uint32 {
+	return 1 + uint32(VarIntSerializeSize(MaxReconSketchCapacity)) +
+		MaxReconSketchCapacity*4
+}
+
+// NewMsgReconcilDiff returns a new bitcoin reconcildiff message that conforms
+// to the Message interface.  See MsgReconcilDiff for details.
+func NewMsgReconcilDiff(success bool, askShortIDs []uint32) *MsgReconcilDiff {
+	return &MsgReconcilDiff{
+		Success:     success,
+		AskShortIDs: askShortIDs,
+	}
+}
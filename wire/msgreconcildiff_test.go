@@ -0,0 +1,69 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestReconcilDiffWire tests the MsgReconcilDiff wire encode and decode.
+func TestReconcilDiffWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgReconcilDiff(true, []uint32{0x01020304, 0x05060708})
+
+	// Ensure the command is expected value.
+	wantCmd := "reconcildiff"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgReconcilDiff: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(2052)
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length - got "+
+			"%v, want %v", maxPayload, wantPayload)
+	}
+
+	want := []byte{
+		0x01,                   // Success
+		0x02,                   // Number of short ids
+		0x04, 0x03, 0x02, 0x01, // Short id
+		0x08, 0x07, 0x06, 0x05, // Short id
+	}
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode: got %s want %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var readMsg MsgReconcilDiff
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BtcDecode: got %s want %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure messages with too many short ids are rejected.
+	msg.AskShortIDs = make([]uint32, MaxReconSketchCapacity+1)
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcEncode: expected error for too many short ids")
+	}
+	buf.Reset()
+	buf.Write([]byte{0x00, 0xfd, 0x01, 0x02})
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcDecode: expected error for too many short ids")
+	}
+}
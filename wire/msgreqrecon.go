@@ -0,0 +1,57 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+)
+
+// MsgReqRecon implements the Message interface and represents a bitcoin
+// reqrecon message.  It is sent by the peer that initiated the connection to
+// start a round of transaction set reconciliation (BIP0330).  The receiver
+// replies with a sketch of its reconciliation set sized according to the
+// fields of the message.
+type MsgReqRecon struct {
+	// SetSize is the number of transactions in the reconciliation set of
+	// the sender.
+	SetSize uint16
+
+	// Q is the coefficient used to estimate the set difference from the
+	// set sizes, scaled by 2^15-1.
+	Q uint16
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReqRecon) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElements(r, &msg.SetSize, &msg.Q)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReqRecon) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElements(w, msg.SetSize, msg.Q)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReqRecon) Command() string {
+	return CmdReqRecon
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReqRecon) MaxPayloadLength(pver uint32) uint32 {
+	return 4
+}
+
+// NewMsgReqRecon returns a new bitcoin reqrecon message that conforms to the
+// Message interface.  See MsgReqRecon for details.
+func NewMsgReqRecon(setSize, q uint16) *MsgReqRecon {
+	return &MsgReqRecon{
+		SetSize: setSize,
+		Q:       q,
+	}
+}
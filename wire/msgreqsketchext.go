@@ -0,0 +1,47 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+)
+
+// MsgReqSketchExt implements the Message interface and represents a bitcoin
+// reqsketchext message.  It is sent by the initiator of a reconciliation round
+// when the sketch it received could not be decoded to request the syndromes
+// that extend the sketch to twice its capacity (BIP0330).
+//
+// This message has no payload.
+type MsgReqSketchExt struct{}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReqSketchExt) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReqSketchExt) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReqSketchExt) Command() string {
+	return CmdReqSketchExt
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReqSketchExt) MaxPayloadLength(pver uint32) uint32 {
+	return 0
+}
+
+// NewMsgReqSketchExt returns a new bitcoin reqsketchext message that conforms
+// to the Message interface.
+func NewMsgReqSketchExt() *MsgReqSketchExt {
+	return &MsgReqSketchExt{}
+}
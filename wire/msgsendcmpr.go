@@ -0,0 +1,92 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxCompressionCodecs is the maximum number of compression codecs a sendcmpr
+// message may list.
+const MaxCompressionCodecs = 8
+
+// MsgSendCmpr implements the Message interface and represents a bitcoin
+// sendcmpr message.  It is sent during the version handshake, before the
+// verack, to signal that the sender accepts block and headers messages
+// compressed with one of the listed codecs in cmpmsg messages.  Compressed
+// messages are only sent to peers which sent a sendcmpr message and received
+// one.
+type MsgSendCmpr struct {
+	// Codecs are the compression codecs the sender is able to decompress.
+	Codecs []CompressionCodec
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendCmpr) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > MaxCompressionCodecs {
+		str := fmt.Sprintf("too many compression codecs for message "+
+			"[count %v, max %v]", count, MaxCompressionCodecs)
+		return messageError("MsgSendCmpr.BtcDecode", str)
+	}
+
+	msg.Codecs = make([]CompressionCodec, count)
+	for i := range msg.Codecs {
+		codec, err := binarySerializer.Uint8(r)
+		if err != nil {
+			return err
+		}
+		msg.Codecs[i] = CompressionCodec(codec)
+	}
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendCmpr) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	count := len(msg.Codecs)
+	if count > MaxCompressionCodecs {
+		str := fmt.Sprintf("too many compression codecs for message "+
+			"[count %v, max %v]", count, MaxCompressionCodecs)
+		return messageError("MsgSendCmpr.BtcEncode", str)
+	}
+
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+	for _, codec := range msg.Codecs {
+		err := binarySerializer.PutUint8(w, uint8(codec))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendCmpr) Command() string {
+	return CmdSendCmpr
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendCmpr) MaxPayloadLength(pver uint32) uint32 {
+	return uint32(VarIntSerializeSize(MaxCompressionCodecs)) +
+		MaxCompressionCodecs
+}
+
+// NewMsgSendCmpr returns a new bitcoin sendcmpr message that conforms to the
+// Message interface.  See MsgSendCmpr for details.
+func NewMsgSendCmpr(codecs ...CompressionCodec) *MsgSendCmpr {
+	return &MsgSendCmpr{
+		Codecs: codecs,
+	}
+}
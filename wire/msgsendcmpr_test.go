@@ -0,0 +1,67 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSendCmprWire tests the MsgSendCmpr wire encode and decode.
+func TestSendCmprWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgSendCmpr(CompressionDeflate, CompressionCodec(7))
+
+	// Ensure the command is expected value.
+	wantCmd := "sendcmpr"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendCmpr: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(9)
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length - got "+
+			"%v, want %v", maxPayload, wantPayload)
+	}
+
+	want := []byte{
+		0x02,       // Number of codecs
+		0x01, 0x07, // Codecs
+	}
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode: got %s want %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var readMsg MsgSendCmpr
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BtcDecode: got %s want %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure messages with too many codecs are rejected.
+	msg.Codecs = make([]CompressionCodec, MaxCompressionCodecs+1)
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcEncode: expected error for too many codecs")
+	}
+	buf.Reset()
+	buf.Write([]byte{MaxCompressionCodecs + 1})
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcDecode: expected error for too many codecs")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+)
+
+// TxReconciliationVersion is the version of the transaction reconciliation
+// protocol (BIP0330) advertised in sendtxrcncl messages.
+const TxReconciliationVersion uint32 = 1
+
+// MsgSendTxRcncl implements the Message interface and represents a bitcoin
+// sendtxrcncl message.  It is sent during the version handshake, before the
+// verack, to signal support for transaction set reconciliation as defined by
+// BIP0330.  Both peers must send the message for reconciliation to be used.
+type MsgSendTxRcncl struct {
+	// Version is the highest reconciliation protocol version supported by
+	// the sender.
+	Version uint32
+
+	// Salt is the random salt of the sender.  The salts of both peers are
+	// combined to compute the short transaction IDs used in sketches.
+	Salt uint64
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElements(r, &msg.Version, &msg.Salt)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElements(w, msg.Version, msg.Salt)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendTxRcncl) Command() string {
+	return CmdSendTxRcncl
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) MaxPayloadLength(pver uint32) uint32 {
+	return 12
+}
+
+// NewMsgSendTxRcncl returns a new bitcoin sendtxrcncl message that conforms to
+// the Message interface.  See MsgSendTxRcncl for details.
+func NewMsgSendTxRcncl(version uint32, salt uint64) *MsgSendTxRcncl {
+	return &MsgSendTxRcncl{
+		Version: version,
+		Salt:    salt,
+	}
+}
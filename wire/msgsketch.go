@@ -0,0 +1,67 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxReconSketchCapacity is the maximum number of 32-bit syndromes a sketch
+// message may carry.
+const MaxReconSketchCapacity = 512
+
+// MsgSketch implements the Message interface and represents a bitcoin sketch
+// message.  It is sent in reply to a reqrecon or reqsketchext message and
+// carries a sketch of the reconciliation set of the sender (BIP0330).  In reply
+// to a reqsketchext message it carries the syndromes that extend the
+// previously sent sketch.
+type MsgSketch struct {
+	// Sketch is the serialized sketch, which consists of 4 bytes per
+	// syndrome.
+	Sketch []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSketch) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var err error
+	msg.Sketch, err = ReadVarBytes(r, pver, MaxReconSketchCapacity*4,
+		"sketch")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSketch) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if len(msg.Sketch) > MaxReconSketchCapacity*4 {
+		str := fmt.Sprintf("sketch too large for message [size %v, "+
+			"max %v]", len(msg.Sketch), MaxReconSketchCapacity*4)
+		return messageError("MsgSketch.BtcEncode", str)
+	}
+
+	return WriteVarBytes(w, pver, msg.Sketch)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSketch) Command() string {
+	return CmdSketch
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSketch) MaxPayloadLength(pver uint32) uint32 {
+	return uint32(VarIntSerializeSize(MaxReconSketchCapacity*4)) +
+		MaxReconSketchCapacity*4
+}
+
+// NewMsgSketch returns a new bitcoin sketch message that conforms to the
+// Message interface.  See MsgSketch for details.
+func NewMsgSketch(sketch []byte) *MsgSketch {
+	return &MsgSketch{
+		Sketch: sketch,
+	}
+}
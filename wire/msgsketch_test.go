@@ -0,0 +1,67 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSketchWire tests the MsgSketch wire encode and decode.
+func TestSketchWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08})
+
+	// Ensure the command is expected value.
+	wantCmd := "sketch"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSketch: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(2051)
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length - got "+
+			"%v, want %v", maxPayload, wantPayload)
+	}
+
+	want := []byte{0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode: got %s want %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var readMsg MsgSketch
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BtcDecode: got %s want %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure sketches that are too large are rejected.
+	msg.Sketch = make([]byte, MaxReconSketchCapacity*4+1)
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcEncode: expected error for oversized sketch")
+	}
+	buf.Reset()
+	if err := WriteVarBytes(&buf, pver, msg.Sketch); err != nil {
+		t.Fatalf("WriteVarBytes: unexpected error: %v", err)
+	}
+	if err := readMsg.BtcDecode(&buf, pver, BaseEncoding); err == nil {
+		t.Fatal("BtcDecode: expected error for oversized sketch")
+	}
+}
@@ -482,12 +482,15 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 	sbuf := scriptPool.Borrow()
 	defer scriptPool.Return(sbuf)
 
-	err := msg.btcDecode(r, pver, enc, buf, sbuf[:])
+	err := msg.btcDecode(r, pver, enc, buf, sbuf[:], nil)
 	return err
 }
 
+// btcDecode decodes r into the receiver using the passed scratch buffers.  The
+// inputs, outputs, witnesses and scripts of the transaction are allocated from
+// the passed block arena, which may be nil to allocate them individually.
 func (msg *MsgTx) btcDecode(r io.Reader, pver uint32, enc MessageEncoding,
-	buf, sbuf []byte) error {
+	buf, sbuf []byte, arena *blockArena) error {
 
 	if _, err := io.ReadFull(r, buf[:4]); err != nil {
 		return err
@@ -536,8 +539,8 @@ func (msg *MsgTx) btcDecode(r io.Reader, pver uint32, enc MessageEncoding,
 
 	// Deserialize the inputs.
 	var totalScriptSize uint64
-	txIns := make([]TxIn, count)
-	msg.TxIn = make([]*TxIn, count)
+	txIns, txInPtrs := arena.newTxIns(int(count))
+	msg.TxIn = txInPtrs
 	for i := uint64(0); i < count; i++ {
 		// The pointer is set now in case a script buffer is borrowed
 		// and needs to be returned to the pool on error.
@@ -567,8 +570,8 @@ func (msg *MsgTx) btcDecode(r io.Reader, pver uint32, enc MessageEncoding,
 	}
 
 	// Deserialize the outputs.
-	txOuts := make([]TxOut, count)
-	msg.TxOut = make([]*TxOut, count)
+	txOuts, txOutPtrs := arena.newTxOuts(int(count))
+	msg.TxOut = txOutPtrs
 	for i := uint64(0); i < count; i++ {
 		// The pointer is set now in case a script buffer is borrowed
 		// and needs to be returned to the pool on error.
@@ -606,7 +609,7 @@ func (msg *MsgTx) btcDecode(r io.Reader, pver uint32, enc MessageEncoding,
 			// Then for witCount number of stack items, each item
 			// has a varint length prefix, followed by the witness
 			// item itself.
-			txin.Witness = make([][]byte, witCount)
+			txin.Witness = arena.newWitness(int(witCount))
 			for j := uint64(0); j < witCount; j++ {
 				txin.Witness[j], err = readScriptBuf(
 					r, pver, buf, sbuf, "script witness item",
@@ -646,7 +649,7 @@ func (msg *MsgTx) btcDecode(r io.Reader, pver uint32, enc MessageEncoding,
 	// scripts in the transaction inputs and outputs no longer point to the
 	// buffers.
 	var offset uint64
-	scripts := make([]byte, totalScriptSize)
+	scripts := arena.newScripts(int(totalScriptSize))
 	for i := 0; i < len(msg.TxIn); i++ {
 		// Copy the signature script into the contiguous buffer at the
 		// appropriate offset.
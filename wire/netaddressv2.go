@@ -24,14 +24,13 @@ var (
 	// maximum size for an unknown networkID.
 	ErrInvalidAddressSize = fmt.Errorf("invalid address size")
 
-	// ErrSkippedNetworkID is returned when the cjdns, i2p, or unknown
-	// networks are encountered during decoding. btcd does not support i2p
-	// or cjdns addresses. In the case of an unknown networkID, this is so
-	// that a future BIP reserving a new networkID does not cause older
-	// addrv2-supporting btcd software to disconnect upon receiving the new
-	// addresses. This error can also be returned when an OnionCat-encoded
-	// torv2 address is received with the ipv6 networkID. This error
-	// signals to the caller to continue reading.
+	// ErrSkippedNetworkID is returned when unknown networks are
+	// encountered during decoding. This is so that a future BIP reserving
+	// a new networkID does not cause older addrv2-supporting btcd software
+	// to disconnect upon receiving the new addresses. This error can also
+	// be returned when an OnionCat-encoded torv2 address is received with
+	// the ipv6 networkID or a cjdns address is not in the fc00::/8 range.
+	// This error signals to the caller to continue reading.
 	ErrSkippedNetworkID = fmt.Errorf("skipped networkID")
 )
 
@@ -107,8 +106,8 @@ func (na *NetAddressV2) AddService(service ServiceFlag) {
 
 // ToLegacy attempts to convert a NetAddressV2 to a legacy NetAddress. This
 // only works for ipv4, ipv6, or torv2 addresses as they can be encoded with
-// the OnionCat encoding. If this method is called on a torv3 address, nil will
-// be returned.
+// the OnionCat encoding. If this method is called on a torv3, i2p or cjdns
+// address, nil will be returned.
 func (na *NetAddressV2) ToLegacy() *NetAddress {
 	legacyNa := &NetAddress{
 		Timestamp: na.Timestamp,
@@ -123,7 +122,7 @@ func (na *NetAddressV2) ToLegacy() *NetAddress {
 		legacyNa.IP = a.addr[:]
 	case *torv2Addr:
 		legacyNa.IP = a.onionCatEncoding()
-	case *torv3Addr:
+	case *torv3Addr, *i2pAddr, *cjdnsAddr:
 		return nil
 	}
 
@@ -149,6 +148,32 @@ func (na *NetAddressV2) TorV3Key() byte {
 	return addr.addr[0]
 }
 
+// IsI2P returns a bool that signals to the caller whether or not this is an
+// i2p address.
+func (na *NetAddressV2) IsI2P() bool {
+	_, ok := na.Addr.(*i2pAddr)
+	return ok
+}
+
+// I2PKey returns the first byte of the i2p destination hash. This is used in
+// the addrmgr to calculate a key from a network group.
+func (na *NetAddressV2) I2PKey() byte {
+	// This should never be called on a non-i2p address.
+	addr, ok := na.Addr.(*i2pAddr)
+	if !ok {
+		panic("unexpected I2PKey call on non-i2p address")
+	}
+
+	return addr.addr[0]
+}
+
+// IsCJDNS returns a bool that signals to the caller whether or not this is a
+// cjdns address.
+func (na *NetAddressV2) IsCJDNS() bool {
+	_, ok := na.Addr.(*cjdnsAddr)
+	return ok
+}
+
 // NetAddressV2FromBytes creates a NetAddressV2 from a byte slice. It will
 // also handle a torv2 address using the OnionCat encoding.
 func NetAddressV2FromBytes(timestamp time.Time, services ServiceFlag,
@@ -194,6 +219,40 @@ func NetAddressV2FromBytes(timestamp time.Time, services ServiceFlag,
 	}
 }
 
+// NetAddressV2FromI2P creates a NetAddressV2 for the i2p address with the
+// passed 32 byte destination hash.
+func NetAddressV2FromI2P(timestamp time.Time, services ServiceFlag,
+	addrBytes []byte, port uint16) *NetAddressV2 {
+
+	addr := &i2pAddr{}
+	addr.netID = i2p
+	copy(addr.addr[:], addrBytes)
+
+	return &NetAddressV2{
+		Timestamp: timestamp,
+		Services:  services,
+		Addr:      addr,
+		Port:      port,
+	}
+}
+
+// NetAddressV2FromCJDNS creates a NetAddressV2 for the cjdns address with the
+// passed 16 bytes, which are an ipv6 address in the fc00::/8 range.
+func NetAddressV2FromCJDNS(timestamp time.Time, services ServiceFlag,
+	addrBytes []byte, port uint16) *NetAddressV2 {
+
+	addr := &cjdnsAddr{}
+	addr.netID = cjdns
+	copy(addr.addr[:], addrBytes)
+
+	return &NetAddressV2{
+		Timestamp: timestamp,
+		Services:  services,
+		Addr:      addr,
+		Port:      port,
+	}
+}
+
 // writeNetAddressV2 writes a NetAddressV2 to a writer.
 func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddressV2) error {
 	err := writeElement(w, uint32(na.Timestamp.Unix()))
@@ -223,6 +282,12 @@ func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddressV2) error {
 	case *torv3Addr:
 		netID = a.netID
 		address = a.addr[:]
+	case *i2pAddr:
+		netID = a.netID
+		address = a.addr[:]
+	case *cjdnsAddr:
+		netID = a.netID
+		address = a.addr[:]
 	default:
 		// This should not occur.
 		return fmt.Errorf("unexpected address type")
@@ -291,10 +356,9 @@ func readNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
 		return ErrSkippedNetworkID
 	}
 
-	// If the netID is an i2p or cjdns address, we'll advance the reader
-	// and return a special error to signal to the caller to not use the
-	// passed NetAddressV2 struct. Otherwise, we'll just read the address
-	// and port without returning an error.
+	// Read the address and port. A special error is returned to signal to
+	// the caller to not use the passed NetAddressV2 struct when the address
+	// must be ignored.
 	switch networkID(netID) {
 	case ipv4:
 		addr := &ipv4Addr{}
@@ -388,7 +452,7 @@ func readNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
 			return err
 		}
 
-		return ErrSkippedNetworkID
+		na.Addr = addr
 	case cjdns:
 		addr := &cjdnsAddr{}
 		addr.netID = cjdns
@@ -405,14 +469,18 @@ func readNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
 			return err
 		}
 
-		return ErrSkippedNetworkID
+		na.Addr = addr
+
+		// BIP-155 says cjdns addresses must be in the fc00::/8 range.
+		if addr.addr[0] != 0xfc {
+			return ErrSkippedNetworkID
+		}
 	}
 
 	return nil
 }
 
-// networkID represents the network that a given address is in. CJDNS and I2P
-// addresses are not included.
+// networkID represents the network that a given address is in.
 type networkID uint8
 
 const (
@@ -463,6 +531,10 @@ const (
 	// TorV3EncodedSize is the size of a torv3 address encoded in base32
 	// with the ".onion" suffix.
 	TorV3EncodedSize = 62
+
+	// I2PEncodedSize is the size of an i2p address encoded in base32
+	// without padding and with the ".b32.i2p" suffix.
+	I2PEncodedSize = 60
 )
 
 // isKnownNetworkID returns true if the networkID is one listed above and false
@@ -582,7 +654,39 @@ type i2pAddr struct {
 	netID networkID
 }
 
+// Part of the net.Addr interface.
+func (a *i2pAddr) String() string {
+	// An i2p address is the unpadded base32 encoding of the SHA256 hash of
+	// the destination followed by the ".b32.i2p" suffix.
+	base32Hash := base32.StdEncoding.WithPadding(base32.NoPadding).
+		EncodeToString(a.addr[:])
+	return strings.ToLower(base32Hash) + ".b32.i2p"
+}
+
+// Part of the net.Addr interface.
+func (a *i2pAddr) Network() string {
+	return string(a.netID)
+}
+
+// Compile-time constraints to check that i2pAddr meets the net.Addr
+// interface.
+var _ net.Addr = (*i2pAddr)(nil)
+
 type cjdnsAddr struct {
 	addr  [cjdnsSize]byte
 	netID networkID
 }
+
+// Part of the net.Addr interface.
+func (a *cjdnsAddr) String() string {
+	return net.IP(a.addr[:]).String()
+}
+
+// Part of the net.Addr interface.
+func (a *cjdnsAddr) Network() string {
+	return string(a.netID)
+}
+
+// Compile-time constraints to check that cjdnsAddr meets the net.Addr
+// interface.
+var _ net.Addr = (*cjdnsAddr)(nil)
@@ -236,7 +236,7 @@ func TestReadNetAddressV2(t *testing.T) {
 				0x22,
 			},
 			string(i2p),
-			ErrSkippedNetworkID,
+			nil,
 		},
 
 		// Invalid cjdns size.
@@ -246,7 +246,7 @@ func TestReadNetAddressV2(t *testing.T) {
 			ErrInvalidAddressSize,
 		},
 
-		// Valid cjdns encoding.
+		// Cjdns addresses outside of fc00::/8 are skipped.
 		{
 			[]byte{
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x10, 0x20,
@@ -257,6 +257,18 @@ func TestReadNetAddressV2(t *testing.T) {
 			string(cjdns),
 			ErrSkippedNetworkID,
 		},
+
+		// Valid cjdns encoding.
+		{
+			[]byte{
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x10, 0xfc,
+				0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20,
+				0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x22,
+				0x22,
+			},
+			string(cjdns),
+			nil,
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -283,3 +295,66 @@ func TestReadNetAddressV2(t *testing.T) {
 		}
 	}
 }
+
+// TestNetAddressV2I2PAndCJDNS tests that i2p and cjdns addresses are encoded as
+// expected and can't be converted to legacy addresses.
+func TestNetAddressV2I2PAndCJDNS(t *testing.T) {
+	i2pBytes := make([]byte, i2pSize)
+	for i := range i2pBytes {
+		i2pBytes[i] = byte(0xa0 + i)
+	}
+	cjdnsBytes := []byte{
+		0xfc, 0x32, 0x17, 0xea, 0x58, 0x3a, 0x41, 0xb5,
+		0x55, 0x7b, 0x8d, 0x6f, 0x8c, 0x03, 0x11, 0x87,
+	}
+
+	tests := []struct {
+		na              *NetAddressV2
+		expectedString  string
+		expectedNetwork string
+	}{
+		{
+			NetAddressV2FromI2P(time.Time{}, 0, i2pBytes, 0),
+			"ucq2fi5euwtkpkfjvkv2zlnov6yldmvtws23nn5yxg5lxpf5x27q.b32.i2p",
+			string(i2p),
+		},
+		{
+			NetAddressV2FromCJDNS(time.Time{}, 0, cjdnsBytes, 8333),
+			"fc32:17ea:583a:41b5:557b:8d6f:8c03:1187",
+			string(cjdns),
+		},
+	}
+
+	for i, test := range tests {
+		if test.na.ToLegacy() != nil {
+			t.Errorf("Test #%d has legacy encoding", i)
+		}
+		if test.na.IsI2P() != (test.expectedNetwork == string(i2p)) ||
+			test.na.IsCJDNS() != (test.expectedNetwork == string(cjdns)) {
+
+			t.Errorf("Test #%d has unexpected network type", i)
+		}
+		if test.na.Addr.String() != test.expectedString {
+			t.Errorf("Test #%d got string %v, want %v", i,
+				test.na.Addr.String(), test.expectedString)
+		}
+
+		// The address must be read back unchanged.
+		var b bytes.Buffer
+		if err := writeNetAddressV2(&b, 0, test.na); err != nil {
+			t.Errorf("Test #%d failed writing address %v", i, err)
+			continue
+		}
+		var na NetAddressV2
+		if err := readNetAddressV2(&b, 0, &na); err != nil {
+			t.Errorf("Test #%d failed reading address %v", i, err)
+			continue
+		}
+		if na.Addr.String() != test.expectedString ||
+			na.Addr.Network() != test.expectedNetwork ||
+			na.Port != test.na.Port {
+
+			t.Errorf("Test #%d read back %v:%d", i, na.Addr, na.Port)
+		}
+	}
+}
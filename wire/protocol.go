@@ -103,6 +103,16 @@ const (
 	// SFNodeNetWorkLimited is a flag used to indicate a peer supports serving
 	// the last 288 blocks.
 	SFNodeNetworkLimited = 1 << 10
+
+	// SFNodeP2PV2 is a flag used to indicate a peer supports the encrypted
+	// v2 transport protocol defined by BIP0324.
+	SFNodeP2PV2 = 1 << 11
+
+	// SFNodeCompression is a flag used to indicate a peer supports the
+	// compression of block and headers messages negotiated with sendcmpr
+	// messages.  The extension is only understood by btcd, so it also uses
+	// one of the bits reserved for temporary experiments.
+	SFNodeCompression = 1 << 25
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -116,6 +126,8 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeCF:             "SFNodeCF",
 	SFNode2X:             "SFNode2X",
 	SFNodeNetworkLimited: "SFNodeNetworkLimited",
+	SFNodeP2PV2:          "SFNodeP2PV2",
+	SFNodeCompression:    "SFNodeCompression",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -130,6 +142,8 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeCF,
 	SFNode2X,
 	SFNodeNetworkLimited,
+	SFNodeP2PV2,
+	SFNodeCompression,
 }
 
 // HasFlag returns a bool indicating if the service has the given flag.
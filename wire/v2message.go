@@ -0,0 +1,181 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// v2Commands maps the one byte message ids assigned by BIP0324 for the v2
+// transport protocol to the commands they abbreviate.  Id zero indicates the
+// message is identified by its full command instead.  The compact block relay
+// (BIP0152) commands are not implemented by this package and are only listed
+// to reserve their ids.
+var v2Commands = [...]string{
+	1:  CmdAddr,
+	2:  CmdBlock,
+	3:  "blocktxn",
+	4:  "cmpctblock",
+	5:  CmdFeeFilter,
+	6:  CmdFilterAdd,
+	7:  CmdFilterClear,
+	8:  CmdFilterLoad,
+	9:  CmdGetBlocks,
+	10: "getblocktxn",
+	11: CmdGetData,
+	12: CmdGetHeaders,
+	13: CmdHeaders,
+	14: CmdInv,
+	15: CmdMemPool,
+	16: CmdMerkleBlock,
+	17: CmdNotFound,
+	18: CmdPing,
+	19: CmdPong,
+	20: "sendcmpct",
+	21: CmdTx,
+	22: CmdGetCFilters,
+	23: CmdCFilter,
+	24: CmdGetCFHeaders,
+	25: CmdCFHeaders,
+	26: CmdGetCFCheckpt,
+	27: CmdCFCheckpt,
+	28: CmdAddrV2,
+}
+
+// v2MessageIDs is the inverse of v2Commands.
+var v2MessageIDs = func() map[string]byte {
+	ids := make(map[string]byte, len(v2Commands))
+	for id, cmd := range v2Commands {
+		if cmd != "" {
+			ids[cmd] = byte(id)
+		}
+	}
+	return ids
+}()
+
+// EncodeV2Message returns the contents of a BIP0324 v2 transport protocol packet
+// carrying the passed message.  The contents consist of the one byte message id
+// of the command followed by the payload, or a zero byte, the zero-padded
+// command and the payload for commands without a message id.
+func EncodeV2Message(msg Message, pver uint32, enc MessageEncoding) ([]byte, error) {
+	cmd := msg.Command()
+	if len(cmd) > CommandSize {
+		str := fmt.Sprintf("command [%s] is too long [max %v]",
+			cmd, CommandSize)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	var bw bytes.Buffer
+	if id, ok := v2MessageIDs[cmd]; ok {
+		bw.WriteByte(id)
+	} else {
+		var command [CommandSize + 1]byte
+		copy(command[1:], cmd)
+		bw.Write(command[:])
+	}
+	hdrLen := bw.Len()
+
+	if err := msg.BtcEncode(&bw, pver, enc); err != nil {
+		return nil, err
+	}
+	lenp := bw.Len() - hdrLen
+
+	// Enforce maximum overall message payload.
+	if lenp > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	// Enforce maximum message payload based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, cmd, mpl)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	return bw.Bytes(), nil
+}
+
+// DecodeV2Message parses the message carried by the passed contents of a
+// BIP0324 v2 transport protocol packet as created by EncodeV2Message.  It
+// returns the parsed message and its raw payload.  ErrUnknownMessage is
+// returned for unknown message ids and commands.
+func DecodeV2Message(contents []byte, pver uint32, enc MessageEncoding) (Message, []byte, error) {
+	if len(contents) == 0 {
+		return nil, nil, messageError("DecodeV2Message",
+			"missing message id")
+	}
+
+	var command string
+	var payload []byte
+	if id := contents[0]; id != 0 {
+		if int(id) >= len(v2Commands) || v2Commands[id] == "" {
+			return nil, nil, ErrUnknownMessage
+		}
+		command = v2Commands[id]
+		payload = contents[1:]
+	} else {
+		if len(contents) < CommandSize+1 {
+			return nil, nil, messageError("DecodeV2Message",
+				"truncated command")
+		}
+		rawCommand := contents[1 : CommandSize+1]
+		payload = contents[CommandSize+1:]
+
+		// The command must be padded with zeros only.
+		end := bytes.IndexByte(rawCommand, 0)
+		if end == -1 {
+			end = CommandSize
+		}
+		for _, b := range rawCommand[end:] {
+			if b != 0 {
+				str := fmt.Sprintf("invalid command padding %v",
+					rawCommand)
+				return nil, nil, messageError("DecodeV2Message",
+					str)
+			}
+		}
+		command = string(rawCommand[:end])
+		if !utf8.ValidString(command) {
+			str := fmt.Sprintf("invalid command %v", rawCommand)
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+	}
+
+	// Enforce maximum message payload.
+	if len(payload) > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - %d bytes, "+
+			"but max message payload is %d bytes", len(payload),
+			MaxMessagePayload)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(len(payload)) > mpl {
+		str := fmt.Sprintf("payload exceeds max length - %v bytes, "+
+			"but max payload size for messages of type [%v] is %v.",
+			len(payload), command, mpl)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// NOTE: This must be a *bytes.Buffer since the MsgVersion BtcDecode
+	// function requires it.
+	if err := msg.BtcDecode(bytes.NewBuffer(payload), pver, enc); err != nil {
+		return nil, nil, err
+	}
+
+	return msg, payload, nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestV2Message tests the encoding and decoding of messages carried by v2
+// transport protocol packets.
+func TestV2Message(t *testing.T) {
+	pver := ProtocolVersion
+
+	tests := []struct {
+		msg  Message
+		want []byte
+	}{
+		// Commands with a message id are abbreviated.
+		{
+			msg: NewMsgPing(0x0102030405060708),
+			want: []byte{
+				18,
+				0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01,
+			},
+		},
+		{
+			msg:  NewMsgMemPool(),
+			want: []byte{15},
+		},
+		// Other commands are sent in full.
+		{
+			msg: NewMsgVerAck(),
+			want: []byte{
+				0x00,
+				'v', 'e', 'r', 'a', 'c', 'k', 0, 0, 0, 0, 0, 0,
+			},
+		},
+		{
+			msg: NewMsgSendTxRcncl(1, 0x0102030405060708),
+			want: []byte{
+				0x00,
+				's', 'e', 'n', 'd', 't', 'x', 'r', 'c', 'n', 'c',
+				'l', 0,
+				0x01, 0x00, 0x00, 0x00,
+				0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		contents, err := EncodeV2Message(test.msg, pver, BaseEncoding)
+		if err != nil {
+			t.Fatalf("EncodeV2Message #%d: unexpected error: %v", i,
+				err)
+		}
+		if !bytes.Equal(contents, test.want) {
+			t.Errorf("EncodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(contents), spew.Sdump(test.want))
+			continue
+		}
+
+		msg, _, err := DecodeV2Message(contents, pver, BaseEncoding)
+		if err != nil {
+			t.Fatalf("DecodeV2Message #%d: unexpected error: %v", i,
+				err)
+		}
+		if !reflect.DeepEqual(msg, test.msg) {
+			t.Errorf("DecodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.msg))
+		}
+	}
+
+	// Unknown message ids and commands must be reported as unknown.
+	unknown := [][]byte{
+		{0xff},
+		{4},
+		{0x00, 'f', 'o', 'o', 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	for i, contents := range unknown {
+		_, _, err := DecodeV2Message(contents, pver, BaseEncoding)
+		if err != ErrUnknownMessage {
+			t.Errorf("DecodeV2Message unknown #%d: got %v, want %v",
+				i, err, ErrUnknownMessage)
+		}
+	}
+
+	// Malformed contents must be rejected.
+	malformed := [][]byte{
+		{},
+		{0x00, 'v', 'e', 'r', 'a', 'c', 'k'},
+		{0x00, 'v', 'e', 'r', 'a', 'c', 'k', 0, 'x', 0, 0, 0, 0},
+		{19, 0x01},
+	}
+	for i, contents := range malformed {
+		_, _, err := DecodeV2Message(contents, pver, BaseEncoding)
+		if err == nil || err == ErrUnknownMessage {
+			t.Errorf("DecodeV2Message malformed #%d: unexpected "+
+				"error %v", i, err)
+		}
+	}
+}
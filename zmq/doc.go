@@ -0,0 +1,35 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package zmq publishes notifications about blocks and transactions over ZeroMQ
+using the same topics and message layout as Bitcoin Core, so existing tools
+which consume the ZeroMQ notifications of Bitcoin Core, such as indexers and
+block explorer backends, can follow this node without polling it.
+
+The package implements the publishing side of version 3 of the ZeroMQ Message
+Transport Protocol (ZMTP) with the NULL security mechanism directly, so it
+doesn't depend on the ZeroMQ C library.  A Publisher accepts the connections of
+SUB sockets on a TCP endpoint and sends each message to the subscribers whose
+subscriptions match its topic.  Messages are queued per subscriber and dropped
+once the queue of a subscriber reaches its high water mark, so slow subscribers
+never hold up the node.
+
+A Notifier publishes the following topics, each on its configured endpoint:
+
+  - hashblock: the hash of each block connected to the main chain
+  - hashtx: the hash of each transaction added to the memory pool or included
+    in a block that was connected to or disconnected from the main chain
+  - rawblock: the serialized block of each block connected to the main chain
+  - rawtx: the serialized transaction of each transaction published to hashtx
+  - sequence: the hash of each block connected (C) to or disconnected (D) from
+    the main chain and of each transaction added to (A) or removed from (R)
+    the memory pool for a reason other than being included in a block, followed
+    by the mempool sequence number for transactions
+
+Each message consists of three frames: the topic, the body and the sequence
+number of the message for its topic as a 4-byte little-endian integer.  Hashes
+are sent in the byte order they are displayed in.
+*/
+package zmq
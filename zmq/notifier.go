@@ -0,0 +1,309 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Topics published by a Notifier.
+const (
+	TopicHashBlock = "hashblock"
+	TopicHashTx    = "hashtx"
+	TopicRawBlock  = "rawblock"
+	TopicRawTx     = "rawtx"
+	TopicSequence  = "sequence"
+)
+
+// Labels of the messages of the sequence topic.
+const (
+	sequenceBlockConnected    = 'C'
+	sequenceBlockDisconnected = 'D'
+	sequenceTxAdded           = 'A'
+	sequenceTxRemoved         = 'R'
+)
+
+// Config is the configuration of a Notifier.  Each topic is published on the
+// endpoint it is set to, such as tcp://127.0.0.1:28332, and not published when
+// it is empty.  Several topics may share an endpoint.
+type Config struct {
+	HashBlock string
+	HashTx    string
+	RawBlock  string
+	RawTx     string
+	Sequence  string
+
+	// HighWaterMark is the maximum number of messages queued for each
+	// subscriber.  DefaultHighWaterMark is used when it is not positive.
+	HighWaterMark int
+}
+
+// topic houses a topic which is published along with the sequence number of
+// its next message.
+type topic struct {
+	endpoint  string
+	publisher *Publisher
+	sequence  uint32
+}
+
+// topicOrder is the order topics are listed in by Notifications, which matches
+// the order of Bitcoin Core.
+var topicOrder = []string{
+	TopicHashBlock,
+	TopicHashTx,
+	TopicRawBlock,
+	TopicRawTx,
+	TopicSequence,
+}
+
+// Notification describes a published topic.
+type Notification struct {
+	// Topic is the name of the topic, such as hashblock.
+	Topic string
+
+	// Endpoint is the endpoint the topic is published on as configured.
+	Endpoint string
+
+	// HighWaterMark is the maximum number of messages queued for each
+	// subscriber of the endpoint.
+	HighWaterMark int
+}
+
+// Notifier publishes notifications about the blocks and transactions of the
+// node to the topics of Bitcoin Core.
+type Notifier struct {
+	mtx        sync.Mutex
+	topics     map[string]*topic
+	publishers map[string]*Publisher
+}
+
+// New returns a notifier publishing the topics of the passed configuration.
+// It returns an error when any of the endpoints can't be bound.
+func New(cfg *Config) (*Notifier, error) {
+	n := &Notifier{
+		topics:     make(map[string]*topic),
+		publishers: make(map[string]*Publisher),
+	}
+	endpoints := []struct {
+		topic    string
+		endpoint string
+	}{
+		{TopicHashBlock, cfg.HashBlock},
+		{TopicHashTx, cfg.HashTx},
+		{TopicRawBlock, cfg.RawBlock},
+		{TopicRawTx, cfg.RawTx},
+		{TopicSequence, cfg.Sequence},
+	}
+	for _, e := range endpoints {
+		if e.endpoint == "" {
+			continue
+		}
+		publisher, ok := n.publishers[e.endpoint]
+		if !ok {
+			var err error
+			publisher, err = Listen(e.endpoint, cfg.HighWaterMark)
+			if err != nil {
+				n.Stop()
+				return nil, err
+			}
+			n.publishers[e.endpoint] = publisher
+		}
+		n.topics[e.topic] = &topic{
+			endpoint:  e.endpoint,
+			publisher: publisher,
+		}
+		log.Infof("Publishing %s notifications on %s", e.topic,
+			publisher.Addr())
+	}
+	return n, nil
+}
+
+// Enabled returns whether any topic is published.
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.topics) > 0
+}
+
+// Stop closes the endpoints of the notifier, which disconnects all of the
+// subscribers.
+func (n *Notifier) Stop() {
+	if n == nil {
+		return
+	}
+	for _, publisher := range n.publishers {
+		publisher.Close()
+	}
+}
+
+// Notifications returns the published topics in the order of Bitcoin Core.
+func (n *Notifier) Notifications() []Notification {
+	if n == nil {
+		return nil
+	}
+	notifications := make([]Notification, 0, len(n.topics))
+	for _, name := range topicOrder {
+		t, ok := n.topics[name]
+		if !ok {
+			continue
+		}
+		notifications = append(notifications, Notification{
+			Topic:         name,
+			Endpoint:      t.endpoint,
+			HighWaterMark: t.publisher.highWaterMark,
+		})
+	}
+	return notifications
+}
+
+// enabled returns whether the passed topic is published.
+func (n *Notifier) enabled(name string) bool {
+	_, ok := n.topics[name]
+	return ok
+}
+
+// publish publishes the passed body to the passed topic along with the
+// sequence number of the message when the topic is enabled.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publish(name string, body []byte) {
+	t, ok := n.topics[name]
+	if !ok {
+		return
+	}
+
+	var sequence [4]byte
+	binary.LittleEndian.PutUint32(sequence[:], t.sequence)
+	t.sequence++
+	t.publisher.Publish([]byte(name), body, sequence[:])
+}
+
+// reversedHash returns the passed hash in the byte order it is displayed in.
+func reversedHash(hash *chainhash.Hash) []byte {
+	reversed := make([]byte, chainhash.HashSize)
+	for i, b := range hash {
+		reversed[chainhash.HashSize-1-i] = b
+	}
+	return reversed
+}
+
+// publishSequence publishes a message of the sequence topic for the passed
+// hash and label, which is followed by the mempool sequence number for
+// transactions.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publishSequence(hash *chainhash.Hash, label byte,
+	mempoolSequence *uint64) {
+
+	if !n.enabled(TopicSequence) {
+		return
+	}
+
+	body := append(reversedHash(hash), label)
+	if mempoolSequence != nil {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], *mempoolSequence)
+		body = append(body, buf[:]...)
+	}
+	n.publish(TopicSequence, body)
+}
+
+// publishTx publishes the passed transaction to the hashtx and rawtx topics.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publishTx(tx *btcutil.Tx) {
+	n.publish(TopicHashTx, reversedHash(tx.Hash()))
+	if n.enabled(TopicRawTx) {
+		var buf bytes.Buffer
+		buf.Grow(tx.MsgTx().SerializeSize())
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			log.Errorf("Unable to serialize transaction %v: %v",
+				tx.Hash(), err)
+			return
+		}
+		n.publish(TopicRawTx, buf.Bytes())
+	}
+}
+
+// BlockConnected publishes the transactions of the passed block, which was
+// connected to the main chain, followed by the block itself.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) BlockConnected(block *btcutil.Block) {
+	if !n.Enabled() {
+		return
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		n.publishTx(tx)
+	}
+	n.publishSequence(block.Hash(), sequenceBlockConnected, nil)
+	n.publish(TopicHashBlock, reversedHash(block.Hash()))
+	if n.enabled(TopicRawBlock) {
+		serialized, err := block.Bytes()
+		if err != nil {
+			log.Errorf("Unable to serialize block %v: %v",
+				block.Hash(), err)
+			return
+		}
+		n.publish(TopicRawBlock, serialized)
+	}
+}
+
+// BlockDisconnected publishes the transactions of the passed block, which was
+// disconnected from the main chain, along with the disconnection of the block
+// to the sequence topic.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) BlockDisconnected(block *btcutil.Block) {
+	if !n.Enabled() {
+		return
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		n.publishTx(tx)
+	}
+	n.publishSequence(block.Hash(), sequenceBlockDisconnected, nil)
+}
+
+// TxAdded publishes the passed transaction, which was added to the memory pool
+// with the passed mempool sequence number.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) TxAdded(tx *btcutil.Tx, mempoolSequence uint64) {
+	if !n.Enabled() {
+		return
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.publishTx(tx)
+	n.publishSequence(tx.Hash(), sequenceTxAdded, &mempoolSequence)
+}
+
+// TxRemoved publishes the removal of the passed transaction from the memory
+// pool with the passed mempool sequence number to the sequence topic.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) TxRemoved(tx *btcutil.Tx, mempoolSequence uint64) {
+	if !n.Enabled() {
+		return
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.publishSequence(tx.Hash(), sequenceTxRemoved, &mempoolSequence)
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testSubscriber is a minimal ZeroMQ SUB socket.
+type testSubscriber struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// subscribe connects a SUB socket of the passed type to the passed publisher
+// with the passed subscriptions and waits until the publisher tracks them.
+func subscribe(t *testing.T, p *Publisher, socketType string,
+	prefixes ...string) *testSubscriber {
+
+	t.Helper()
+
+	conn, err := net.Dial("tcp", p.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	s := &testSubscriber{t: t, conn: conn, r: bufio.NewReader(conn)}
+	if _, err := conn.Write(greeting()); err != nil {
+		t.Fatalf("unable to send greeting: %v", err)
+	}
+	if err := readGreeting(s.r); err != nil {
+		t.Fatalf("unable to read greeting: %v", err)
+	}
+	if _, err := conn.Write(encodeReady(socketType)); err != nil {
+		t.Fatalf("unable to send ready: %v", err)
+	}
+	flags, body, err := readFrame(s.r)
+	if err != nil || flags&flagCommand == 0 {
+		t.Fatalf("unable to read ready: %v", err)
+	}
+	if name, _, _ := parseCommand(body); name != cmdReady {
+		t.Fatalf("unexpected command %q", name)
+	}
+
+	for _, prefix := range prefixes {
+		msg := encodeMessage([][]byte{append([]byte{1}, prefix...)})
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatalf("unable to subscribe: %v", err)
+		}
+	}
+
+	// Wait for the publisher to track the subscriptions.
+	for i := 0; ; i++ {
+		var tracked bool
+		p.mtx.Lock()
+		for sub := range p.subscribers {
+			if sub.conn.RemoteAddr().String() != conn.LocalAddr().String() {
+				continue
+			}
+			sub.mtx.Lock()
+			tracked = len(sub.subscriptions) == len(prefixes)
+			sub.mtx.Unlock()
+		}
+		p.mtx.Unlock()
+		if tracked {
+			break
+		}
+		if i == 100 {
+			t.Fatal("publisher did not track the subscriptions")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return s
+}
+
+// receive reads a message and returns its parts.
+func (s *testSubscriber) receive() [][]byte {
+	s.t.Helper()
+
+	var parts [][]byte
+	for {
+		flags, body, err := readFrame(s.r)
+		if err != nil {
+			s.t.Fatalf("unable to read message: %v", err)
+		}
+		parts = append(parts, body)
+		if flags&flagMore == 0 {
+			return parts
+		}
+	}
+}
+
+// expect reads a message and ensures it consists of the passed topic, body
+// and sequence number.
+func (s *testSubscriber) expect(topic string, body []byte, sequence uint32) {
+	s.t.Helper()
+
+	parts := s.receive()
+	if len(parts) != 3 {
+		s.t.Fatalf("unexpected number of parts: got %d, want 3",
+			len(parts))
+	}
+	if string(parts[0]) != topic {
+		s.t.Fatalf("unexpected topic: got %q, want %q", parts[0], topic)
+	}
+	if !bytes.Equal(parts[1], body) {
+		s.t.Fatalf("unexpected %s body: got %x, want %x", topic,
+			parts[1], body)
+	}
+	if got := binary.LittleEndian.Uint32(parts[2]); got != sequence {
+		s.t.Fatalf("unexpected %s sequence: got %d, want %d", topic,
+			got, sequence)
+	}
+}
+
+// TestListenAddress ensures endpoints are converted to the expected listen
+// addresses.
+func TestListenAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		endpoint string
+		addr     string
+		valid    bool
+	}{
+		{"tcp://127.0.0.1:28332", "127.0.0.1:28332", true},
+		{"tcp://*:28332", ":28332", true},
+		{"tcp://[::1]:28332", "[::1]:28332", true},
+		{"ipc:///tmp/btcd.sock", "", false},
+		{"127.0.0.1:28332", "", false},
+		{"tcp://127.0.0.1", "", false},
+	}
+	for _, test := range tests {
+		addr, err := ListenAddress(test.endpoint)
+		if (err == nil) != test.valid || addr != test.addr {
+			t.Errorf("ListenAddress(%q): got %q (%v), want %q",
+				test.endpoint, addr, err, test.addr)
+		}
+	}
+}
+
+// TestNotifier ensures the notifier publishes the topics on their endpoints
+// with the layout of Bitcoin Core and only to subscribers with matching
+// subscriptions.
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	n, err := New(&Config{
+		HashTx:   "tcp://127.0.0.1:0",
+		Sequence: "tcp://127.0.0.1:0",
+		RawBlock: "tcp://localhost:0",
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	defer n.Stop()
+
+	if len(n.publishers) != 2 {
+		t.Fatalf("unexpected number of endpoints: got %d, want 2",
+			len(n.publishers))
+	}
+	txPublisher := n.topics[TopicHashTx].publisher
+	if n.topics[TopicSequence].publisher != txPublisher {
+		t.Fatal("topics of the same endpoint do not share a publisher")
+	}
+	blockPublisher := n.topics[TopicRawBlock].publisher
+
+	// The published topics are listed in the order of Bitcoin Core with
+	// their endpoints as configured.
+	want := []Notification{
+		{TopicHashTx, "tcp://127.0.0.1:0", DefaultHighWaterMark},
+		{TopicRawBlock, "tcp://localhost:0", DefaultHighWaterMark},
+		{TopicSequence, "tcp://127.0.0.1:0", DefaultHighWaterMark},
+	}
+	if got := n.Notifications(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected notifications: got %v, want %v", got, want)
+	}
+
+	// A subscription to the empty prefix matches all topics of the endpoint
+	// while the other subscriber only receives the hashes.
+	all := subscribe(t, txPublisher, "SUB", "")
+	hashes := subscribe(t, txPublisher, "SUB", "hash")
+	blocks := subscribe(t, blockPublisher, "XSUB", "rawblock")
+
+	tx := btcutil.NewTx(&wire.MsgTx{Version: 2})
+	hash := reversedHash(tx.Hash())
+	n.TxAdded(tx, 7)
+	n.TxRemoved(tx, 8)
+
+	all.expect(TopicHashTx, hash, 0)
+	all.expect(TopicSequence, append(append(hash, 'A'),
+		7, 0, 0, 0, 0, 0, 0, 0), 0)
+	all.expect(TopicSequence, append(append(hash, 'R'),
+		8, 0, 0, 0, 0, 0, 0, 0), 1)
+	hashes.expect(TopicHashTx, hash, 0)
+
+	msgBlock := &wire.MsgBlock{Header: wire.BlockHeader{Version: 1}}
+	msgBlock.AddTransaction(tx.MsgTx())
+	block := btcutil.NewBlock(msgBlock)
+	serialized, err := block.Bytes()
+	if err != nil {
+		t.Fatalf("unable to serialize block: %v", err)
+	}
+	n.BlockConnected(block)
+
+	blockHash := reversedHash(block.Hash())
+	blocks.expect(TopicRawBlock, serialized, 0)
+	all.expect(TopicHashTx, hash, 1)
+	all.expect(TopicSequence, append(blockHash, 'C'), 2)
+	hashes.expect(TopicHashTx, hash, 1)
+
+	// The sequence topic is not delivered to the hash subscriber, so the
+	// next message it receives is the transaction of the disconnected
+	// block.
+	n.BlockDisconnected(block)
+	all.expect(TopicHashTx, hash, 2)
+	all.expect(TopicSequence, append(blockHash, 'D'), 3)
+	hashes.expect(TopicHashTx, hash, 2)
+}
+
+// TestPublisherRejectsPublisher ensures sockets which are not subscribers are
+// disconnected during the handshake.
+func TestPublisherRejectsPublisher(t *testing.T) {
+	t.Parallel()
+
+	p, err := Listen("tcp://127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	defer p.Close()
+
+	conn, err := net.Dial("tcp", p.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r := bufio.NewReader(conn)
+	conn.Write(greeting())
+	conn.Write(encodeReady("PUB"))
+	if err := readGreeting(r); err != nil {
+		t.Fatalf("unable to read greeting: %v", err)
+	}
+
+	// The publisher sends its ready command followed by an error before
+	// disconnecting.
+	var names []string
+	for {
+		_, body, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		name, _, _ := parseCommand(body)
+		names = append(names, name)
+	}
+	if len(names) != 2 || names[0] != cmdReady || names[1] != cmdError {
+		t.Fatalf("unexpected commands: %v", names)
+	}
+}
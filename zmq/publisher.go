@@ -0,0 +1,353 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHighWaterMark is the default maximum number of messages queued
+	// for a subscriber before further messages are dropped.
+	DefaultHighWaterMark = 1000
+
+	// handshakeTimeout is the maximum amount of time a subscriber may take
+	// to complete the handshake after connecting.
+	handshakeTimeout = 10 * time.Second
+)
+
+// ListenAddress returns the TCP address to listen on for the passed ZeroMQ
+// endpoint, such as tcp://127.0.0.1:28332.  A host of * listens on all
+// interfaces.
+func ListenAddress(endpoint string) (string, error) {
+	addr := strings.TrimPrefix(endpoint, "tcp://")
+	if addr == endpoint {
+		return "", fmt.Errorf("unsupported endpoint %q -- only tcp:// "+
+			"endpoints are supported", endpoint)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %v", endpoint, err)
+	}
+	if host == "*" {
+		host = ""
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Publisher is a ZeroMQ PUB socket bound to a TCP endpoint.  It sends each
+// published message to the connected SUB sockets with a subscription matching
+// the topic of the message.
+type Publisher struct {
+	listener      net.Listener
+	highWaterMark int
+
+	mtx         sync.Mutex
+	subscribers map[*subscriber]struct{}
+	closed      bool
+
+	wg sync.WaitGroup
+}
+
+// Listen returns a publisher bound to the passed endpoint, such as
+// tcp://127.0.0.1:28332, which queues at most highWaterMark messages for each
+// subscriber.  DefaultHighWaterMark is used when highWaterMark is not
+// positive.
+func Listen(endpoint string, highWaterMark int) (*Publisher, error) {
+	addr, err := ListenAddress(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if highWaterMark <= 0 {
+		highWaterMark = DefaultHighWaterMark
+	}
+
+	p := &Publisher{
+		listener:      listener,
+		highWaterMark: highWaterMark,
+		subscribers:   make(map[*subscriber]struct{}),
+	}
+	p.wg.Add(1)
+	go p.acceptHandler()
+	return p, nil
+}
+
+// Addr returns the address the publisher listens on.
+func (p *Publisher) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Publish sends a message consisting of the passed parts, the first of which
+// is the topic, to the subscribers whose subscriptions match the topic.  The
+// message is dropped for subscribers whose queue is full.
+//
+// This function is safe for concurrent access.
+func (p *Publisher) Publish(parts ...[]byte) {
+	if len(parts) == 0 {
+		return
+	}
+	topic := parts[0]
+
+	var msg []byte
+	p.mtx.Lock()
+	for sub := range p.subscribers {
+		if !sub.matches(topic) {
+			continue
+		}
+		if msg == nil {
+			msg = encodeMessage(parts)
+		}
+		select {
+		case sub.sendQueue <- msg:
+		default:
+			log.Debugf("Dropping %s message for subscriber %s: high "+
+				"water mark reached", topic, sub.conn.RemoteAddr())
+		}
+	}
+	p.mtx.Unlock()
+}
+
+// Close stops accepting subscribers, disconnects the connected ones and waits
+// for their handlers to finish.
+func (p *Publisher) Close() error {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return nil
+	}
+	p.closed = true
+	for sub := range p.subscribers {
+		sub.conn.Close()
+	}
+	p.mtx.Unlock()
+
+	err := p.listener.Close()
+	p.wg.Wait()
+	return err
+}
+
+// acceptHandler accepts the connections of subscribers until the listener is
+// closed.
+//
+// This must be run as a goroutine.
+func (p *Publisher) acceptHandler() {
+	defer p.wg.Done()
+
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+
+		p.wg.Add(1)
+		go p.subscriberHandler(conn)
+	}
+}
+
+// subscriberHandler performs the handshake with a newly connected subscriber
+// and then tracks its subscriptions until it disconnects.
+//
+// This must be run as a goroutine.
+func (p *Publisher) subscriberHandler(conn net.Conn) {
+	defer p.wg.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	if err := p.handshake(conn, r); err != nil {
+		log.Debugf("Handshake with subscriber %s failed: %v",
+			conn.RemoteAddr(), err)
+		return
+	}
+
+	sub := &subscriber{
+		conn:          conn,
+		subscriptions: make(map[string]int),
+		sendQueue:     make(chan []byte, p.highWaterMark),
+		quit:          make(chan struct{}),
+	}
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return
+	}
+	p.subscribers[sub] = struct{}{}
+	p.mtx.Unlock()
+	log.Debugf("New subscriber %s", conn.RemoteAddr())
+
+	p.wg.Add(1)
+	go sub.sendHandler(&p.wg)
+
+	err := sub.readSubscriptions(r)
+	log.Debugf("Subscriber %s disconnected: %v", conn.RemoteAddr(), err)
+
+	p.mtx.Lock()
+	delete(p.subscribers, sub)
+	p.mtx.Unlock()
+	close(sub.quit)
+}
+
+// handshake exchanges the greeting and the READY command with a subscriber
+// and ensures it is a SUB socket.
+func (p *Publisher) handshake(conn net.Conn, r *bufio.Reader) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(greeting()); err != nil {
+		return err
+	}
+	if err := readGreeting(r); err != nil {
+		return err
+	}
+	if _, err := conn.Write(encodeReady("PUB")); err != nil {
+		return err
+	}
+
+	flags, body, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	if flags&flagCommand == 0 {
+		return fmt.Errorf("expected command, got message")
+	}
+	name, data, err := parseCommand(body)
+	if err != nil {
+		return err
+	}
+	if name != cmdReady {
+		return fmt.Errorf("expected %s command, got %q", cmdReady, name)
+	}
+	props, err := parseMetadata(data)
+	if err != nil {
+		return err
+	}
+	socketType := props[propSocketType]
+	if socketType != "SUB" && socketType != "XSUB" {
+		reason := "invalid socket type"
+		data := append([]byte{byte(len(reason))}, reason...)
+		conn.Write(encodeCommand(cmdError, data))
+		return fmt.Errorf("unsupported socket type %q", socketType)
+	}
+	return nil
+}
+
+// subscriber houses the state of a connected SUB socket.
+type subscriber struct {
+	conn net.Conn
+
+	// subscriptions counts the subscriptions to each topic prefix.  It is
+	// protected by the mutex.
+	mtx           sync.Mutex
+	subscriptions map[string]int
+
+	sendQueue chan []byte
+	quit      chan struct{}
+}
+
+// matches returns whether any subscription of the subscriber matches the
+// passed topic.
+func (s *subscriber) matches(topic []byte) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for prefix := range s.subscriptions {
+		if bytes.HasPrefix(topic, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe adds or cancels a subscription to the passed topic prefix.
+func (s *subscriber) subscribe(prefix []byte, add bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	key := string(prefix)
+	if add {
+		s.subscriptions[key]++
+		return
+	}
+	if s.subscriptions[key] <= 1 {
+		delete(s.subscriptions, key)
+		return
+	}
+	s.subscriptions[key]--
+}
+
+// readSubscriptions reads the subscriptions and commands of the subscriber
+// until it disconnects.
+func (s *subscriber) readSubscriptions(r *bufio.Reader) error {
+	for {
+		flags, body, err := readFrame(r)
+		if err != nil {
+			return err
+		}
+
+		// ZMTP 3.0 subscriptions are messages starting with 1 to
+		// subscribe and 0 to cancel a subscription.
+		if flags&flagCommand == 0 {
+			if len(body) > 0 && body[0] <= 1 {
+				s.subscribe(body[1:], body[0] == 1)
+			}
+			continue
+		}
+
+		name, data, err := parseCommand(body)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case cmdSubscribe:
+			s.subscribe(data, true)
+		case cmdCancel:
+			s.subscribe(data, false)
+		case cmdPing:
+			// The ping consists of the time to live and the context
+			// to echo in the pong.
+			if len(data) >= 2 {
+				pong := encodeCommand(cmdPong, data[2:])
+				select {
+				case s.sendQueue <- pong:
+				default:
+				}
+			}
+		case cmdError:
+			return fmt.Errorf("subscriber reported error")
+		}
+	}
+}
+
+// sendHandler writes the queued messages to the subscriber until it
+// disconnects.
+//
+// This must be run as a goroutine.
+func (s *subscriber) sendHandler(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		select {
+		case msg := <-s.sendQueue:
+			if _, err := s.conn.Write(msg); err != nil {
+				s.conn.Close()
+				return
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// greetingSize is the size of the greeting which starts a ZMTP
+	// connection.
+	greetingSize = 64
+
+	// zmtpMajorVersion and zmtpMinorVersion are the version of ZMTP
+	// announced in the greeting.  Announcing 3.0 makes peers send
+	// subscriptions as messages rather than commands, although both are
+	// understood.
+	zmtpMajorVersion = 3
+	zmtpMinorVersion = 0
+
+	// nullMechanism is the name of the security mechanism which doesn't
+	// authenticate or encrypt anything.
+	nullMechanism = "NULL"
+
+	// maxIncomingFrameSize is the maximum size of the frames read from
+	// subscribers, which only send commands and subscriptions.
+	maxIncomingFrameSize = 1 << 16
+)
+
+// Flags of the frames of ZMTP.
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// Names of the ZMTP commands and metadata properties which are used.
+const (
+	cmdReady     = "READY"
+	cmdError     = "ERROR"
+	cmdSubscribe = "SUBSCRIBE"
+	cmdCancel    = "CANCEL"
+	cmdPing      = "PING"
+	cmdPong      = "PONG"
+
+	propSocketType = "Socket-Type"
+)
+
+// greeting returns the greeting of a peer using the NULL security mechanism,
+// which has no notion of client and server roles.
+func greeting() []byte {
+	var g [greetingSize]byte
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = zmtpMajorVersion
+	g[11] = zmtpMinorVersion
+	copy(g[12:32], nullMechanism)
+	return g[:]
+}
+
+// readGreeting reads the greeting of a peer and ensures it speaks ZMTP 3 or
+// later using the NULL security mechanism.
+func readGreeting(r io.Reader) error {
+	var g [greetingSize]byte
+	if _, err := io.ReadFull(r, g[:]); err != nil {
+		return err
+	}
+	if g[0] != 0xff || g[9]&0x01 == 0 {
+		return errors.New("invalid greeting signature")
+	}
+	if g[10] < zmtpMajorVersion {
+		return fmt.Errorf("unsupported ZMTP version %d.%d", g[10], g[11])
+	}
+	mechanism := string(bytes.TrimRight(g[12:32], "\x00"))
+	if mechanism != nullMechanism {
+		return fmt.Errorf("unsupported security mechanism %q", mechanism)
+	}
+	return nil
+}
+
+// appendFrame appends the passed frame with the passed flags to buf.
+func appendFrame(buf []byte, flags byte, body []byte) []byte {
+	if len(body) > 255 {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(body)))
+		buf = append(buf, flags|flagLong)
+		buf = append(buf, size[:]...)
+	} else {
+		buf = append(buf, flags, byte(len(body)))
+	}
+	return append(buf, body...)
+}
+
+// encodeMessage returns the frames of a message consisting of the passed
+// parts.
+func encodeMessage(parts [][]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += 9 + len(part)
+	}
+	buf := make([]byte, 0, size)
+	for i, part := range parts {
+		var flags byte
+		if i < len(parts)-1 {
+			flags = flagMore
+		}
+		buf = appendFrame(buf, flags, part)
+	}
+	return buf
+}
+
+// encodeCommand returns the frame of the passed command.
+func encodeCommand(name string, data []byte) []byte {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, data...)
+	return appendFrame(nil, flagCommand, body)
+}
+
+// encodeReady returns the frame of the READY command announcing a socket of
+// the passed type.
+func encodeReady(socketType string) []byte {
+	var data []byte
+	data = append(data, byte(len(propSocketType)))
+	data = append(data, propSocketType...)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(socketType)))
+	data = append(data, size[:]...)
+	data = append(data, socketType...)
+	return encodeCommand(cmdReady, data)
+}
+
+// readFrame reads a frame and returns its flags and body.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	if flags&flagLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > maxIncomingFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the maximum "+
+			"of %d bytes", size, maxIncomingFrameSize)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// parseCommand splits the body of a command frame into the name and the data
+// of the command.
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", nil, errors.New("malformed command")
+	}
+	nameLen := int(body[0])
+	return string(body[1 : 1+nameLen]), body[1+nameLen:], nil
+}
+
+// parseMetadata parses the properties of a READY command.
+func parseMetadata(data []byte) (map[string]string, error) {
+	props := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+4 {
+			return nil, errors.New("malformed metadata")
+		}
+		name := string(data[1 : 1+nameLen])
+		data = data[1+nameLen:]
+		valueLen := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(valueLen) {
+			return nil, errors.New("malformed metadata")
+		}
+		props[name] = string(data[:valueLen])
+		data = data[valueLen:]
+	}
+	return props, nil
+}